@@ -1,6 +1,18 @@
 #!/bin/sh
 set -ex
 
+# upsert_node_local_dns deploys the node-local DNS cache if the cluster
+# is configured with the node-local-dns provider, i.e. the installer
+# has rendered the node-local-dns config map, and removes it otherwise
+upsert_node_local_dns() {
+    if kubectl get configmap/node-local-dns --namespace=kube-system > /dev/null 2>&1; then
+        echo "Creating node-local DNS cache"
+        rig upsert -f /var/lib/gravity/resources/node-local-dns.yaml
+    else
+        rig delete ds/node-local-dns --resource-namespace=kube-system --force --debug || true
+    fi
+}
+
 echo "Assuming changeset from the environment: $RIG_CHANGESET"
 if [ $1 = "update" ]; then
     echo "Checking: $RIG_CHANGESET"
@@ -16,6 +28,7 @@ if [ $1 = "update" ]; then
 
     echo "Creating new resources"
     rig upsert -f /var/lib/gravity/resources/dns.yaml
+    upsert_node_local_dns
 
     echo "Checking status"
     rig status $RIG_CHANGESET --retry-attempts=120 --retry-period=1s --debug
@@ -28,6 +41,7 @@ elif [ $1 = "rollback" ]; then
 elif [ $1 = "install" ]; then
     echo "Creating new resources"
     rig upsert -f /var/lib/gravity/resources/dns.yaml
+    upsert_node_local_dns
     echo "Freezing"
     rig freeze
 else
@@ -0,0 +1,168 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admission implements the client for the external validation
+// webhook that gates changes to sensitive cluster resources
+package admission
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OperationUpsert is the operation that creates or updates a resource
+	OperationUpsert = "upsert"
+	// OperationDelete is the operation that deletes a resource
+	OperationDelete = "delete"
+)
+
+// Request describes a change to a cluster resource submitted to the webhook for validation
+type Request struct {
+	// UID uniquely identifies this request
+	UID string `json:"uid"`
+	// Cluster is the name of the cluster
+	Cluster string `json:"cluster"`
+	// Operation is the type of the change: upsert or delete
+	Operation string `json:"operation"`
+	// Kind is the kind of the resource being changed
+	Kind string `json:"kind"`
+	// Name is the name of the resource being changed
+	Name string `json:"name"`
+	// User is the name of the user making the change
+	User string `json:"user,omitempty"`
+	// Object is the new resource with secrets removed.
+	// Empty for delete operations
+	Object json.RawMessage `json:"object,omitempty"`
+}
+
+// Response is the webhook decision about the change
+type Response struct {
+	// UID is the ID of the request this response is for
+	UID string `json:"uid"`
+	// Allowed specifies whether the change is allowed
+	Allowed bool `json:"allowed"`
+	// Message optionally explains the decision
+	Message string `json:"message,omitempty"`
+}
+
+// Review submits the specified request to the webhook unless the webhook
+// is not configured to validate resources of this kind.
+//
+// Returns AccessDenied if the webhook rejects the change. If the webhook
+// cannot be reached, the change is rejected or allowed according to the
+// webhook failure policy
+func Review(ctx context.Context, webhook storage.AdmissionWebhook, req Request) error {
+	if !utils.StringInSlice(webhook.GetResources(), req.Kind) {
+		return nil
+	}
+	if req.UID == "" {
+		req.UID = uuid.New()
+	}
+	logger := log.WithFields(log.Fields{
+		"uid":  req.UID,
+		"kind": req.Kind,
+		"name": req.Name,
+		"url":  webhook.GetURL(),
+	})
+	resp, err := send(ctx, webhook, req)
+	if err != nil {
+		if webhook.GetFailurePolicy() == storage.AdmissionFailurePolicyIgnore {
+			logger.WithError(err).Warn("Admission webhook failed, allowing change per failure policy.")
+			return nil
+		}
+		return trace.Wrap(err, "admission webhook failed")
+	}
+	if !resp.Allowed {
+		logger.WithField("message", resp.Message).Info("Admission webhook denied change.")
+		if resp.Message == "" {
+			return trace.AccessDenied("change to %v %q denied by admission webhook", req.Kind, req.Name)
+		}
+		return trace.AccessDenied("change to %v %q denied by admission webhook: %v",
+			req.Kind, req.Name, resp.Message)
+	}
+	logger.Debug("Admission webhook allowed change.")
+	return nil
+}
+
+func send(ctx context.Context, webhook storage.AdmissionWebhook, req Request) (*Response, error) {
+	client, err := newClient(webhook)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, webhook.GetURL(), bytes.NewReader(data))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, trace.BadParameter("webhook %v responded with %v",
+			webhook.GetURL(), httpResp.Status)
+	}
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, trace.Wrap(err, "invalid webhook response")
+	}
+	if resp.UID != req.UID {
+		return nil, trace.BadParameter("webhook response UID %q does not match request UID %q",
+			resp.UID, req.UID)
+	}
+	return &resp, nil
+}
+
+func newClient(webhook storage.AdmissionWebhook) (*http.Client, error) {
+	tlsConfig := &tls.Config{}
+	if webhook.GetCACert() != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(webhook.GetCACert())) {
+			return nil, trace.BadParameter("failed to parse webhook CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	timeout := webhook.GetTimeout()
+	if timeout == 0 {
+		timeout = defaults.AdmissionWebhookTimeout
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           httplib.ProxyFromPolicy,
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: timeout,
+	}, nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+func TestAdmission(t *testing.T) { check.TestingT(t) }
+
+type WebhookSuite struct{}
+
+var _ = check.Suite(&WebhookSuite{})
+
+func (s *WebhookSuite) TestAllowsAndDenies(c *check.C) {
+	var requests []Request
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), check.IsNil)
+		requests = append(requests, req)
+		resp := Response{UID: req.UID, Allowed: req.User == "admin@example.com"}
+		if !resp.Allowed {
+			resp.Message = "only admin can change " + req.Kind
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	webhook := newWebhook(c, server, storage.AdmissionFailurePolicyFail)
+
+	err := Review(context.TODO(), webhook, Request{
+		Operation: OperationUpsert,
+		Kind:      storage.KindClusterConfiguration,
+		Name:      "config",
+		User:      "admin@example.com",
+		Object:    json.RawMessage(`{"kind":"clusterconfiguration"}`),
+	})
+	c.Assert(err, check.IsNil)
+
+	err = Review(context.TODO(), webhook, Request{
+		Operation: OperationDelete,
+		Kind:      storage.KindClusterConfiguration,
+		Name:      "config",
+		User:      "alice@example.com",
+	})
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(trace.UserMessage(err), check.Matches, ".*only admin can change clusterconfiguration")
+
+	c.Assert(requests, check.HasLen, 2)
+	c.Assert(requests[0].UID, check.Not(check.Equals), "")
+	c.Assert(string(requests[0].Object), check.Equals, `{"kind":"clusterconfiguration"}`)
+	c.Assert(requests[1].Operation, check.Equals, OperationDelete)
+}
+
+func (s *WebhookSuite) TestReviewsPersistentStorage(c *check.C) {
+	var requests []Request
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), check.IsNil)
+		requests = append(requests, req)
+		json.NewEncoder(w).Encode(Response{UID: req.UID, Message: "storage changes are frozen"})
+	}))
+	defer server.Close()
+	webhook := storage.NewAdmissionWebhook(storage.AdmissionWebhookSpecV2{
+		URL:    server.URL,
+		CACert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+	})
+	c.Assert(webhook.CheckAndSetDefaults(), check.IsNil)
+
+	err := Review(context.TODO(), webhook, Request{
+		Operation: OperationUpsert,
+		Kind:      storage.KindPersistentStorage,
+		Name:      storage.KindPersistentStorage,
+		Object:    json.RawMessage(`{"kind":"persistentstorage"}`),
+	})
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(trace.UserMessage(err), check.Matches, ".*storage changes are frozen")
+	c.Assert(requests, check.HasLen, 1)
+	c.Assert(requests[0].Kind, check.Equals, storage.KindPersistentStorage)
+}
+
+func (s *WebhookSuite) TestSkipsUnselectedResources(c *check.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Error("unexpected webhook request")
+	}))
+	defer server.Close()
+	webhook := newWebhook(c, server, storage.AdmissionFailurePolicyFail)
+
+	err := Review(context.TODO(), webhook, Request{Kind: "github", Name: "example"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *WebhookSuite) TestFailurePolicy(c *check.C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	req := Request{Kind: storage.KindClusterConfiguration, Name: "config"}
+
+	err := Review(context.TODO(), newWebhook(c, server, storage.AdmissionFailurePolicyFail), req)
+	c.Assert(err, check.NotNil)
+	c.Assert(trace.IsAccessDenied(err), check.Equals, false)
+
+	err = Review(context.TODO(), newWebhook(c, server, storage.AdmissionFailurePolicyIgnore), req)
+	c.Assert(err, check.IsNil)
+}
+
+func newWebhook(c *check.C, server *httptest.Server, failurePolicy string) storage.AdmissionWebhook {
+	caCert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+	webhook := storage.NewAdmissionWebhook(storage.AdmissionWebhookSpecV2{
+		URL:           server.URL,
+		CACert:        string(caCert),
+		Resources:     []string{storage.KindClusterConfiguration},
+		FailurePolicy: failurePolicy,
+	})
+	c.Assert(webhook.CheckAndSetDefaults(), check.IsNil)
+	return webhook
+}
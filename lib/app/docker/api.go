@@ -54,6 +54,11 @@ type ImageService interface {
 	// Unwrap translates the specified image name to point to the original repository
 	// if it's prefixed with this registry address - functional inverse of Wrap
 	Unwrap(image string) string
+
+	// Mirror copies the specified image from its upstream registry
+	// into this private docker registry.
+	// Returns the tag of the mirrored image
+	Mirror(ctx context.Context, image string) (*TagSpec, error)
 }
 
 // DockerPuller defines an interface to pull images
@@ -33,6 +33,7 @@ import (
 
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/utils"
@@ -290,7 +291,7 @@ func initTransport(req RegistryConnectionRequest) (*http.Transport, string) {
 	const handshakeTimeout = 30 * time.Second
 
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: httplib.ProxyFromPolicy,
 		Dial: (&net.Dialer{
 			Timeout:   connectTimeout,
 			KeepAlive: keepAlivePeriod,
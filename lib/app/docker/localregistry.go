@@ -0,0 +1,113 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/docker/distribution/context"
+	"github.com/gravitational/trace"
+)
+
+// ListLocalImages returns the images stored in the local directory
+// specified with dir in docker registry 2.x format.
+//
+// Every image is verified to have its manifest and all referenced
+// blobs present in the directory.
+func ListLocalImages(ctx context.Context, dir string) (images []TagSpec, err error) {
+	isDir, err := utils.IsDirectory(filepath.Join(dir, registryRepositoriesDir))
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	if !isDir {
+		return nil, trace.NotFound("%q does not contain a docker registry", dir)
+	}
+	localStore, err := openLocal(dir)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to open directory %q as local registry", dir)
+	}
+	repos, err := ListRepos(ctx, localStore)
+	if err != nil && err != io.EOF {
+		return nil, trace.Wrap(err, "failed to list repositories in %q", dir)
+	}
+	for _, repoName := range repos {
+		repo, err := localStore.Repository(ctx, repoName)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		manifests, err := repo.Manifests(ctx)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		tags, err := repo.Tags(ctx).All(ctx)
+		if err != nil {
+			return nil, trace.Wrap(err, "failed to list tags of repository %v", repoName)
+		}
+		for _, tag := range tags {
+			image := TagSpec{Name: repoName, Version: tag}
+			desc, err := repo.Tags(ctx).Get(ctx, tag)
+			if err != nil {
+				return nil, trace.Wrap(err, "failed to read image %v", image)
+			}
+			manifest, err := manifests.Get(ctx, desc.Digest)
+			if err != nil {
+				return nil, trace.Wrap(err, "failed to read manifest of image %v", image)
+			}
+			for _, ref := range manifest.References() {
+				_, err := repo.Blobs(ctx).Stat(ctx, ref.Digest)
+				if err != nil {
+					return nil, trace.NotFound("image %v is missing blob %v", image, ref.Digest)
+				}
+			}
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// ImageFromRegistryPath returns the image referenced by the specified path
+// of a tag link file in docker registry 2.x layout, e.g.
+// docker/registry/v2/repositories/<name>/_manifests/tags/<tag>/current/link.
+// Returns false if the path does not reference a tag link
+func ImageFromRegistryPath(path string) (image TagSpec, ok bool) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	idx := strings.Index(path, registryRepositoriesDir)
+	if idx < 0 || !strings.HasSuffix(path, registryTagLinkSuffix) {
+		return TagSpec{}, false
+	}
+	path = strings.TrimSuffix(path[idx+len(registryRepositoriesDir):], registryTagLinkSuffix)
+	parts := strings.Split(path, registryTagsDir)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return TagSpec{}, false
+	}
+	return TagSpec{Name: parts[0], Version: parts[1]}, true
+}
+
+const (
+	// registryRepositoriesDir is the repositories directory
+	// in docker registry 2.x layout
+	registryRepositoriesDir = "docker/registry/v2/repositories/"
+	// registryTagsDir separates repository name from the tag
+	// in the path of a tag link
+	registryTagsDir = "/_manifests/tags/"
+	// registryTagLinkSuffix is the suffix of the current tag link file
+	registryTagLinkSuffix = "/current/link"
+)
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/docker/distribution/context"
+	"github.com/gravitational/trace"
+	. "gopkg.in/check.v1"
+)
+
+type LocalRegistrySuite struct{}
+
+var _ = Suite(&LocalRegistrySuite{})
+
+func (s *LocalRegistrySuite) TestImageFromRegistryPath(c *C) {
+	var testCases = []struct {
+		path  string
+		image TagSpec
+		ok    bool
+	}{
+		{
+			path:  "registry/docker/registry/v2/repositories/nginx/_manifests/tags/1.17/current/link",
+			image: TagSpec{Name: "nginx", Version: "1.17"},
+			ok:    true,
+		},
+		{
+			path:  "docker/registry/v2/repositories/gravitational/debian-tall/_manifests/tags/0.0.1/current/link",
+			image: TagSpec{Name: "gravitational/debian-tall", Version: "0.0.1"},
+			ok:    true,
+		},
+		{
+			path: "registry/docker/registry/v2/repositories/nginx/_manifests/tags/1.17/index/sha256/abc/link",
+		},
+		{
+			path: "registry/docker/registry/v2/blobs/sha256/ab/abc/data",
+		},
+	}
+	for _, tc := range testCases {
+		image, ok := ImageFromRegistryPath(tc.path)
+		c.Assert(ok, Equals, tc.ok, Commentf(tc.path))
+		c.Assert(image, DeepEquals, tc.image, Commentf(tc.path))
+	}
+}
+
+func (s *LocalRegistrySuite) TestListsEmptyRegistry(c *C) {
+	dir := c.MkDir()
+	_, err := ListLocalImages(context.Background(), dir)
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("expected error for directory without registry"))
+
+	c.Assert(os.MkdirAll(filepath.Join(dir, registryRepositoriesDir), 0755), IsNil)
+	images, err := ListLocalImages(context.Background(), dir)
+	c.Assert(err, IsNil)
+	c.Assert(images, HasLen, 0)
+}
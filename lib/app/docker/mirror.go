@@ -0,0 +1,195 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/manifest/manifestlist"
+	dockerref "github.com/docker/distribution/reference"
+	registryclient "github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/docker/distribution/registry/client/transport"
+	"github.com/gravitational/trace"
+	"github.com/opencontainers/go-digest"
+)
+
+// Mirror copies the specified upstream image into the registry this image
+// service is managing.
+//
+// The image is a fully qualified reference with a tag and an optional digest,
+// e.g. quay.io/coreos/etcd:v3.3.15@sha256:<digest>. If the digest is specified,
+// the upstream manifest is verified against it.
+// The image is stored in the registry under its repository path without
+// the upstream domain, e.g. coreos/etcd:v3.3.15, so it is found by the
+// applications vendored with the image.
+//
+// Only anonymous pulls from upstream registries are supported
+func (r *imageService) Mirror(ctx context.Context, image string) (*TagSpec, error) {
+	if err := storage.CheckMirrorImage(image); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := r.connect(ctx); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	named, err := dockerref.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ref := dockerref.Reference(named)
+	tag := ref.(dockerref.Tagged).Tag()
+	upstream, err := connectUpstream(ctx, named)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to connect to upstream registry of %v", image)
+	}
+	manifest, dgst, err := getUpstreamManifest(ctx, upstream, ref)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to fetch manifest of %v", image)
+	}
+	tagSpec := TagSpec{
+		Name:    mirrorRepository(named),
+		Version: tag,
+	}
+	local, err := r.remoteStore.Repository(ctx, tagSpec.Name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	desc, err := local.Tags(ctx).Get(ctx, tag)
+	if err == nil && desc.Digest == dgst {
+		r.Debugf("Image %v is up-to-date.", tagSpec)
+		return &tagSpec, nil
+	}
+	r.Infof("Mirroring %v to %v.", image, tagSpec)
+	if err := r.remoteStore.updateRepo(ctx, local, upstream, manifest, tag); err != nil {
+		return nil, trace.Wrap(err, "failed to mirror %v", image)
+	}
+	return &tagSpec, nil
+}
+
+// getUpstreamManifest returns the manifest of the image specified with ref
+// from the upstream repository along with the digest of the manifest.
+// If the image is a manifest list, the manifest for the platform of this
+// process is returned
+func getUpstreamManifest(ctx context.Context, repo distribution.Repository, ref dockerref.Reference) (distribution.Manifest, digest.Digest, error) {
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	var manifest distribution.Manifest
+	if digested, ok := ref.(dockerref.Digested); ok {
+		manifest, err = manifests.Get(ctx, digested.Digest())
+	} else {
+		manifest, err = manifests.Get(ctx, "", distribution.WithTag(ref.(dockerref.Tagged).Tag()))
+	}
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	dgst := digest.FromBytes(payload)
+	if digested, ok := ref.(dockerref.Digested); ok && digested.Digest() != dgst {
+		return nil, "", trace.BadParameter("manifest digest mismatch: expected %v, got %v",
+			digested.Digest(), dgst)
+	}
+	list, ok := manifest.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		return manifest, dgst, nil
+	}
+	for _, desc := range list.Manifests {
+		if desc.Platform.OS != runtime.GOOS || desc.Platform.Architecture != runtime.GOARCH {
+			continue
+		}
+		manifest, err = manifests.Get(ctx, desc.Digest)
+		if err != nil {
+			return nil, "", trace.Wrap(err)
+		}
+		return manifest, desc.Digest, nil
+	}
+	return nil, "", trace.NotFound("no manifest for %v/%v", runtime.GOOS, runtime.GOARCH)
+}
+
+// connectUpstream returns the repository of the specified image
+// in the upstream registry
+func connectUpstream(ctx context.Context, named dockerref.Named) (distribution.Repository, error) {
+	const connectTimeout = 30 * time.Second
+	const handshakeTimeout = 30 * time.Second
+	base := &http.Transport{
+		Proxy: httplib.ProxyFromPolicy,
+		Dial: (&net.Dialer{
+			Timeout: connectTimeout,
+		}).Dial,
+		TLSHandshakeTimeout: handshakeTimeout,
+	}
+	endpoint := "https://" + upstreamEndpoint(dockerref.Domain(named))
+	// the registry responds with the authentication challenge
+	// describing how to obtain the token for anonymous pulls
+	manager := challenge.NewSimpleManager()
+	client := &http.Client{Transport: base, Timeout: connectTimeout}
+	resp, err := client.Get(endpoint + "/v2/")
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if err := manager.AddResponse(resp); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	path := dockerref.Path(named)
+	authorizer := auth.NewAuthorizer(manager,
+		auth.NewTokenHandler(base, nil, path, "pull"))
+	name, err := dockerref.WithName(path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return registryclient.NewRepository(ctx, name, endpoint,
+		transport.NewTransport(base, authorizer))
+}
+
+// upstreamEndpoint returns the address of the registry API for the specified domain
+func upstreamEndpoint(domain string) string {
+	if domain == dockerHubDomain {
+		return dockerHubEndpoint
+	}
+	return domain
+}
+
+// mirrorRepository returns the name of the repository the specified
+// upstream image is mirrored to
+func mirrorRepository(named dockerref.Named) string {
+	if dockerref.Domain(named) == dockerHubDomain {
+		// images from Docker Hub are referenced without the library prefix
+		return dockerref.FamiliarName(named)
+	}
+	return dockerref.Path(named)
+}
+
+const (
+	// dockerHubDomain is the domain of Docker Hub images
+	dockerHubDomain = "docker.io"
+	// dockerHubEndpoint is the address of the Docker Hub registry API
+	dockerHubEndpoint = "registry-1.docker.io"
+)
@@ -0,0 +1,46 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	dockerref "github.com/docker/distribution/reference"
+	. "gopkg.in/check.v1"
+)
+
+type MirrorSuite struct{}
+
+var _ = Suite(&MirrorSuite{})
+
+func (s *MirrorSuite) TestMirrorRepository(c *C) {
+	var testCases = []struct {
+		image      string
+		repository string
+		endpoint   string
+	}{
+		{image: "docker.io/library/nginx:1.17", repository: "nginx", endpoint: "registry-1.docker.io"},
+		{image: "docker.io/nginx:1.17", repository: "nginx", endpoint: "registry-1.docker.io"},
+		{image: "docker.io/bitnami/redis:5.0", repository: "bitnami/redis", endpoint: "registry-1.docker.io"},
+		{image: "quay.io/coreos/etcd:v3.3.15", repository: "coreos/etcd", endpoint: "quay.io"},
+		{image: "registry.example.com:5000/app:1.0", repository: "app", endpoint: "registry.example.com:5000"},
+	}
+	for _, tc := range testCases {
+		named, err := dockerref.ParseNormalizedNamed(tc.image)
+		c.Assert(err, IsNil)
+		c.Assert(mirrorRepository(named), Equals, tc.repository, Commentf(tc.image))
+		c.Assert(upstreamEndpoint(dockerref.Domain(named)), Equals, tc.endpoint, Commentf(tc.image))
+	}
+}
@@ -367,7 +367,7 @@ func (r *Runner) evalJobStatus(ctx context.Context, eventsC <-chan watch.Event)
 			}
 			if failure := findFailure(*job); failure != nil {
 				log.Debugf("Failed: %v.", failure.Message)
-				return trace.BadParameter(failure.Message)
+				return r.jobFailure(job, failure.Message)
 			}
 		case <-ctx.Done():
 			return nil
@@ -375,6 +375,47 @@ func (r *Runner) evalJobStatus(ctx context.Context, eventsC <-chan watch.Event)
 	}
 }
 
+// jobFailure returns the error describing the failed job.
+// If the hook container has exited with a non-zero exit code,
+// the error carries the exit code
+func (r *Runner) jobFailure(job *batchv1.Job, message string) error {
+	err := trace.BadParameter(message)
+	pods, errCollect := r.collectPods(job)
+	if errCollect != nil {
+		r.WithError(errCollect).Warn("Failed to collect job pods.")
+		return err
+	}
+	if exitCode := findExitCode(pods); exitCode != 0 {
+		return utils.WrapExitCodeError(exitCode, err)
+	}
+	return err
+}
+
+// findExitCode returns the exit code of the most recently terminated
+// container of the specified pods that has exited with an error.
+// Returns 0 if no such container is found
+func findExitCode(pods map[string]v1.Pod) (exitCode int) {
+	var finishedAt time.Time
+	for _, pod := range pods {
+		var statuses []v1.ContainerStatus
+		statuses = append(statuses, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			for _, state := range []v1.ContainerState{status.State, status.LastTerminationState} {
+				terminated := state.Terminated
+				if terminated == nil || terminated.ExitCode == 0 {
+					continue
+				}
+				if exitCode == 0 || terminated.FinishedAt.Time.After(finishedAt) {
+					exitCode = int(terminated.ExitCode)
+					finishedAt = terminated.FinishedAt.Time
+				}
+			}
+		}
+	}
+	return exitCode
+}
+
 func newJobWatch(client batch.BatchV1Interface, ref JobRef) (watch.Interface, error) {
 	watcher, err := client.Jobs(ref.Namespace).Watch(metav1.ListOptions{
 		TypeMeta: metav1.TypeMeta{
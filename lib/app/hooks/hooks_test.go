@@ -292,3 +292,63 @@ func (s *HooksSuite) TestHookFailPodRestart(c *check.C) {
 	err = runner.DeleteJob(context.TODO(), DeleteJobRequest{JobRef: *ref})
 	c.Assert(err, check.IsNil)
 }
+
+type JobStatusSuite struct{}
+
+var _ = check.Suite(&JobStatusSuite{})
+
+func (s *JobStatusSuite) TestFindsExitCode(c *check.C) {
+	now := time.Now()
+	terminated := func(exitCode int32, finishedAt time.Time) v1.ContainerState {
+		return v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			ExitCode:   exitCode,
+			FinishedAt: metav1.NewTime(finishedAt),
+		}}
+	}
+	var testCases = []struct {
+		comment  string
+		pods     map[string]v1.Pod
+		exitCode int
+	}{
+		{
+			comment:  "no pods",
+			exitCode: 0,
+		},
+		{
+			comment: "successful container",
+			pods: map[string]v1.Pod{
+				"hook": {Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+					{State: terminated(0, now)},
+				}}},
+			},
+			exitCode: 0,
+		},
+		{
+			comment: "failed init container",
+			pods: map[string]v1.Pod{
+				"hook": {Status: v1.PodStatus{InitContainerStatuses: []v1.ContainerStatus{
+					{State: terminated(2, now)},
+				}}},
+			},
+			exitCode: 2,
+		},
+		{
+			comment: "most recent failure across restarts",
+			pods: map[string]v1.Pod{
+				"hook-1": {Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+					{State: terminated(3, now.Add(-time.Minute))},
+				}}},
+				"hook-2": {Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+					{
+						State:                v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+						LastTerminationState: terminated(4, now),
+					},
+				}}},
+			},
+			exitCode: 4,
+		},
+	}
+	for _, tc := range testCases {
+		c.Assert(findExitCode(tc.pods), check.Equals, tc.exitCode, check.Commentf(tc.comment))
+	}
+}
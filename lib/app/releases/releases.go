@@ -0,0 +1,457 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package releases implements the lifecycle of auxiliary applications
+// installed into an existing cluster: dependency resolution, application
+// hooks and the history of release revisions
+package releases
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/helm"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/helm/pkg/proto/hapi/release"
+)
+
+// Config is the configuration of the release lifecycle
+type Config struct {
+	// Apps is the cluster application service
+	Apps app.Applications
+	// Packages is the cluster package service
+	Packages pack.PackageService
+	// Records stores the history of release revisions
+	Records storage.ReleaseRecords
+	// Helm is the Helm client
+	Helm helm.Client
+	// ClusterName is the name of the cluster
+	ClusterName string
+	// ServiceUser is the cluster service user hooks are run as
+	ServiceUser storage.OSUser
+	// Clock is used to mock time in tests
+	Clock clockwork.Clock
+	// FieldLogger is used for logging
+	log.FieldLogger
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (r *Config) CheckAndSetDefaults() error {
+	if r.Apps == nil {
+		return trace.BadParameter("missing Apps")
+	}
+	if r.Packages == nil {
+		return trace.BadParameter("missing Packages")
+	}
+	if r.Records == nil {
+		return trace.BadParameter("missing Records")
+	}
+	if r.Helm == nil {
+		return trace.BadParameter("missing Helm")
+	}
+	if r.ClusterName == "" {
+		return trace.BadParameter("missing ClusterName")
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = log.WithField(trace.Component, "releases")
+	}
+	return nil
+}
+
+// Releases manages the releases of auxiliary applications
+type Releases struct {
+	Config
+}
+
+// New returns a new release lifecycle manager
+func New(config Config) (*Releases, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Releases{Config: config}, nil
+}
+
+// InstallRequest describes a request to install an application
+type InstallRequest struct {
+	// Application is the application package to install
+	Application loc.Locator
+	// Name is an optional release name
+	Name string
+	// Namespace is the namespace to install the release into
+	Namespace string
+	// Values is a list of files with values
+	Values []string
+	// Set is a list of values set on the command line
+	Set []string
+}
+
+// UpgradeRequest describes a request to upgrade a release
+type UpgradeRequest struct {
+	// Release is the name of the release to upgrade
+	Release string
+	// Application is the application package to upgrade to
+	Application loc.Locator
+	// Values is a list of files with values
+	Values []string
+	// Set is a list of values set on the command line
+	Set []string
+}
+
+// RollbackRequest describes a request to roll back a release
+type RollbackRequest struct {
+	// Release is the name of the release to roll back
+	Release string
+	// Revision is the revision to roll back to
+	Revision int
+}
+
+// Install installs the specified application along with the applications
+// it depends on that are not yet installed in the cluster.
+//
+// The install and postInstall hooks of the application are run after the chart
+// has been installed
+func (r *Releases) Install(ctx context.Context, req InstallRequest) (*storage.ReleaseRecord, error) {
+	application, err := r.Apps.GetApp(req.Application)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	dependencies, err := r.installDependencies(ctx, *application, req.Namespace)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	r.Infof("Installing %v.", application.Package)
+	var result storage.Release
+	err = r.withChart(application.Package, func(path string) (err error) {
+		result, err = r.Helm.Install(helm.InstallParameters{
+			Path:      path,
+			Values:    req.Values,
+			Set:       req.Set,
+			Name:      req.Name,
+			Namespace: req.Namespace,
+		})
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	record := r.newRecord(result, *application, storage.ReleaseActionInstall)
+	record.Dependencies = dependencies
+	hookErr := r.runHooks(ctx, *application, schema.HookInstall, schema.HookInstalled)
+	return r.finish(record, hookErr)
+}
+
+// Upgrade upgrades the specified release to a new version of the application.
+// Dependencies of the new version missing in the cluster are installed first.
+//
+// The preUpdate hook is run before the chart is upgraded, the update and
+// postUpdate hooks are run after
+func (r *Releases) Upgrade(ctx context.Context, req UpgradeRequest) (*storage.ReleaseRecord, error) {
+	current, err := r.Helm.Get(req.Release)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	application, err := r.Apps.GetApp(req.Application)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	dependencies, err := r.installDependencies(ctx, *application, current.GetNamespace())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := r.runHooks(ctx, *application, schema.HookBeforeUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	r.Infof("Upgrading release %v to %v.", req.Release, application.Package)
+	var result storage.Release
+	err = r.withChart(application.Package, func(path string) (err error) {
+		result, err = r.Helm.Upgrade(helm.UpgradeParameters{
+			Release: req.Release,
+			Path:    path,
+			Values:  req.Values,
+			Set:     req.Set,
+		})
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	record := r.newRecord(result, *application, storage.ReleaseActionUpgrade)
+	record.Dependencies = dependencies
+	hookErr := r.runHooks(ctx, *application, schema.HookUpdate, schema.HookUpdated)
+	return r.finish(record, hookErr)
+}
+
+// Rollback rolls the specified release back to one of its previous revisions.
+//
+// The rollback and postRollback hooks of the application version that is being
+// rolled back are run after the chart has been rolled back
+func (r *Releases) Rollback(ctx context.Context, req RollbackRequest) (*storage.ReleaseRecord, error) {
+	target, err := r.Records.GetReleaseRecord(r.ClusterName, req.Release, req.Revision)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	current, err := r.Helm.Get(req.Release)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if current.GetRevision() == target.Revision {
+		return nil, trace.BadParameter("release %v is already at revision %v",
+			req.Release, target.Revision)
+	}
+	// Hooks are run for the application version being rolled back
+	// so use the one recorded for the current revision if available
+	locator := current.GetLocator()
+	if record, err := r.Records.GetReleaseRecord(r.ClusterName, req.Release, current.GetRevision()); err == nil {
+		locator = record.Application
+	}
+	application, err := r.Apps.GetApp(locator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	r.Infof("Rolling back release %v to revision %v (%v).", req.Release,
+		target.Revision, target.Application)
+	result, err := r.Helm.Rollback(helm.RollbackParameters{
+		Release:  req.Release,
+		Revision: req.Revision,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	record := r.newRecord(result, *application, storage.ReleaseActionRollback)
+	record.Application = target.Application
+	record.Dependencies = target.Dependencies
+	record.Description = fmt.Sprintf("Rolled back to revision %v", target.Revision)
+	hookErr := r.runHooks(ctx, *application, schema.HookRollback, schema.HookRolledBack)
+	return r.finish(record, hookErr)
+}
+
+// Uninstall uninstalls the specified release.
+//
+// The preUninstall hook is run before the chart is removed, the uninstall
+// hook is run after
+func (r *Releases) Uninstall(ctx context.Context, name string) (storage.Release, error) {
+	current, err := r.Helm.Get(name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	records, err := r.Records.GetReleaseRecords(r.ClusterName, name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	locator := current.GetLocator()
+	if len(records) != 0 {
+		locator = records[len(records)-1].Application
+	}
+	application, err := r.Apps.GetApp(locator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := r.runHooks(ctx, *application, schema.HookUninstalling); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	r.Infof("Uninstalling release %v.", name)
+	result, err := r.Helm.Uninstall(name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := r.runHooks(ctx, *application, schema.HookUninstall); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(records) != 0 {
+		record := records[len(records)-1]
+		record.Status = storage.ReleaseStatusUninstalled
+		record.Updated = r.Clock.Now().UTC()
+		if _, err := r.Records.UpsertReleaseRecord(record); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return result, nil
+}
+
+// History returns the recorded revisions of the specified release
+func (r *Releases) History(name string) ([]storage.ReleaseRecord, error) {
+	records, err := r.Records.GetReleaseRecords(r.ClusterName, name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return records, nil
+}
+
+// installDependencies installs the applications the specified application
+// depends on that do not have a deployed release in the cluster yet.
+// Returns the application dependencies
+func (r *Releases) installDependencies(ctx context.Context, application app.Application, namespace string) ([]loc.Locator, error) {
+	dependencies, err := app.GetDependencies(&application, r.Apps)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("%v has a dependency that is not available "+
+				"in the cluster, sync it with \"gravity app sync\" first: %v",
+				application.Package, err)
+		}
+		return nil, trace.Wrap(err)
+	}
+	releases, err := r.Helm.List(helm.ListParameters{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var locators []loc.Locator
+	// Applications are ordered so that dependencies precede the applications
+	// that depend on them
+	for _, locator := range dependencies.Apps {
+		dependency, err := r.Apps.GetApp(locator)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if dependency.Manifest.Kind != schema.KindApplication {
+			continue
+		}
+		locators = append(locators, locator)
+		if isDeployed(locator, releases) {
+			r.Debugf("Dependency %v is already installed.", locator)
+			continue
+		}
+		r.Infof("Installing dependency %v of %v.", locator, application.Package)
+		var result storage.Release
+		err = r.withChart(locator, func(path string) (err error) {
+			result, err = r.Helm.Install(helm.InstallParameters{
+				Path:      path,
+				Name:      locator.Name,
+				Namespace: namespace,
+			})
+			return trace.Wrap(err)
+		})
+		if err != nil {
+			return nil, trace.Wrap(err, "failed to install dependency %v", locator)
+		}
+		releases = append(releases, result)
+		record := r.newRecord(result, *dependency, storage.ReleaseActionInstall)
+		hookErr := r.runHooks(ctx, *dependency, schema.HookInstall, schema.HookInstalled)
+		if _, err := r.finish(record, hookErr); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return locators, nil
+}
+
+// withChart unpacks the chart of the specified application into
+// a temporary directory and invokes fn with the path to the chart
+func (r *Releases) withChart(locator loc.Locator, fn func(path string) error) error {
+	tmp, err := ioutil.TempDir("", "release")
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer os.RemoveAll(tmp)
+	err = pack.Unpack(r.Packages, locator, tmp, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return fn(filepath.Join(tmp, "resources"))
+}
+
+// runHooks runs the specified hooks of the application skipping
+// those the application does not define
+func (r *Releases) runHooks(ctx context.Context, application app.Application, hooks ...schema.HookType) error {
+	for _, hook := range hooks {
+		if !application.Manifest.HasHook(hook) {
+			continue
+		}
+		r.Infof("Executing %v hook for %v.", hook, application.Package)
+		_, out, err := app.RunAppHook(ctx, r.Apps, app.HookRunRequest{
+			Application: application.Package,
+			Hook:        hook,
+			ServiceUser: r.ServiceUser,
+		})
+		if err != nil {
+			return trace.Wrap(err, "%v %v hook failed: %s", application.Package, hook, out)
+		}
+	}
+	return nil
+}
+
+// newRecord returns a new record for the specified release revision
+func (r *Releases) newRecord(result storage.Release, application app.Application, action string) storage.ReleaseRecord {
+	return storage.ReleaseRecord{
+		Name:        result.GetName(),
+		ClusterName: r.ClusterName,
+		Revision:    result.GetRevision(),
+		Namespace:   result.GetNamespace(),
+		Application: application.Package,
+		Action:      action,
+		Status:      storage.ReleaseStatusDeployed,
+		Updated:     r.Clock.Now().UTC(),
+	}
+}
+
+// finish saves the record of the new release revision and marks the
+// previously deployed revisions as superseded.
+// If hookErr is not nil, the revision is recorded as failed and hookErr is returned
+func (r *Releases) finish(record storage.ReleaseRecord, hookErr error) (*storage.ReleaseRecord, error) {
+	if hookErr != nil {
+		record.Status = storage.ReleaseStatusFailed
+		if record.Description == "" {
+			record.Description = trace.UserMessage(hookErr)
+		}
+	}
+	records, err := r.Records.GetReleaseRecords(r.ClusterName, record.Name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, previous := range records {
+		if previous.Revision >= record.Revision || previous.Status != storage.ReleaseStatusDeployed {
+			continue
+		}
+		previous.Status = storage.ReleaseStatusSuperseded
+		if _, err := r.Records.UpsertReleaseRecord(previous); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	out, err := r.Records.UpsertReleaseRecord(record)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if hookErr != nil {
+		return nil, trace.Wrap(hookErr)
+	}
+	return out, nil
+}
+
+// isDeployed returns true if there is a deployed release of the specified application
+func isDeployed(locator loc.Locator, releases []storage.Release) bool {
+	for _, item := range releases {
+		if item.GetLocator().Name == locator.Name &&
+			item.GetStatus() == deployedStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// deployedStatus is the status of a deployed Helm release
+var deployedStatus = release.Status_DEPLOYED.String()
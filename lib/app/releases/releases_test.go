@@ -0,0 +1,315 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releases
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/app/service"
+	"github.com/gravitational/gravity/lib/app/service/test"
+	"github.com/gravitational/gravity/lib/archive"
+	"github.com/gravitational/gravity/lib/blob/fs"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/helm"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack/localpack"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/storage/keyval"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"gopkg.in/check.v1"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/proto/hapi/release"
+)
+
+func TestReleases(t *testing.T) { check.TestingT(t) }
+
+type ReleasesSuite struct {
+	releases *Releases
+	helm     *fakeHelm
+	backend  storage.Backend
+}
+
+var _ = check.Suite(&ReleasesSuite{})
+
+func (s *ReleasesSuite) SetUpTest(c *check.C) {
+	dir := c.MkDir()
+	var err error
+	s.backend, err = keyval.NewBolt(keyval.BoltConfig{
+		Path: filepath.Join(dir, "bolt.db"),
+	})
+	c.Assert(err, check.IsNil)
+	objects, err := fs.New(dir)
+	c.Assert(err, check.IsNil)
+	packages, err := localpack.New(localpack.Config{
+		Backend:     s.backend,
+		UnpackedDir: filepath.Join(dir, defaults.UnpackedDir),
+		Objects:     objects,
+	})
+	c.Assert(err, check.IsNil)
+	charts, err := helm.NewRepository(helm.Config{
+		Packages: packages,
+		Backend:  s.backend,
+	})
+	c.Assert(err, check.IsNil)
+	apps, err := service.New(service.Config{
+		Backend:  s.backend,
+		StateDir: filepath.Join(dir, defaults.ImportDir),
+		Packages: packages,
+		Charts:   charts,
+	})
+	c.Assert(err, check.IsNil)
+
+	createApp(c, apps, "gravitational.io/metrics:0.0.1", "")
+	createApp(c, apps, "gravitational.io/dashboard:0.0.1", "gravitational.io/metrics:0.0.1")
+	createApp(c, apps, "gravitational.io/dashboard:0.0.2", "gravitational.io/metrics:0.0.1")
+
+	clock := clockwork.NewFakeClock()
+	s.helm = &fakeHelm{clock: clock, releases: make(map[string][]*storage.ReleaseV1)}
+	s.releases, err = New(Config{
+		Apps:        apps,
+		Packages:    packages,
+		Records:     s.backend,
+		Helm:        s.helm,
+		ClusterName: "example.com",
+		Clock:       clock,
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ReleasesSuite) TestInstallsDependencies(c *check.C) {
+	record, err := s.releases.Install(context.TODO(), InstallRequest{
+		Application: loc.MustParseLocator("gravitational.io/dashboard:0.0.1"),
+		Name:        "dashboard",
+		Namespace:   "monitoring",
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(record.Revision, check.Equals, 1)
+	c.Assert(record.Namespace, check.Equals, "monitoring")
+	c.Assert(record.Status, check.Equals, storage.ReleaseStatusDeployed)
+	c.Assert(record.Dependencies, check.DeepEquals, []loc.Locator{
+		loc.MustParseLocator("gravitational.io/metrics:0.0.1"),
+	})
+
+	metrics, err := s.helm.Get("metrics")
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics.GetChart(), check.Equals, "metrics-0.0.1")
+	c.Assert(metrics.GetNamespace(), check.Equals, "monitoring")
+	records, err := s.releases.History("metrics")
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.HasLen, 1)
+	c.Assert(records[0].Application, check.Equals, loc.MustParseLocator("gravitational.io/metrics:0.0.1"))
+
+	// Dependencies already deployed are not installed again
+	_, err = s.releases.Install(context.TODO(), InstallRequest{
+		Application: loc.MustParseLocator("gravitational.io/dashboard:0.0.2"),
+		Name:        "dashboard-2",
+	})
+	c.Assert(err, check.IsNil)
+	revisions, err := s.helm.Revisions("metrics")
+	c.Assert(err, check.IsNil)
+	c.Assert(revisions, check.HasLen, 1)
+}
+
+func (s *ReleasesSuite) TestUpgradesAndRollsBack(c *check.C) {
+	_, err := s.releases.Install(context.TODO(), InstallRequest{
+		Application: loc.MustParseLocator("gravitational.io/dashboard:0.0.1"),
+		Name:        "dashboard",
+	})
+	c.Assert(err, check.IsNil)
+
+	record, err := s.releases.Upgrade(context.TODO(), UpgradeRequest{
+		Release:     "dashboard",
+		Application: loc.MustParseLocator("gravitational.io/dashboard:0.0.2"),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(record.Revision, check.Equals, 2)
+	c.Assert(record.Action, check.Equals, storage.ReleaseActionUpgrade)
+
+	record, err = s.releases.Rollback(context.TODO(), RollbackRequest{
+		Release:  "dashboard",
+		Revision: 1,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(record.Revision, check.Equals, 3)
+	c.Assert(record.Action, check.Equals, storage.ReleaseActionRollback)
+	c.Assert(record.Application, check.Equals, loc.MustParseLocator("gravitational.io/dashboard:0.0.1"))
+
+	current, err := s.helm.Get("dashboard")
+	c.Assert(err, check.IsNil)
+	c.Assert(current.GetChart(), check.Equals, "dashboard-0.0.1")
+
+	records, err := s.releases.History("dashboard")
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses(records), check.DeepEquals, []string{
+		storage.ReleaseStatusSuperseded,
+		storage.ReleaseStatusSuperseded,
+		storage.ReleaseStatusDeployed,
+	})
+
+	_, err = s.releases.Rollback(context.TODO(), RollbackRequest{
+		Release:  "dashboard",
+		Revision: 5,
+	})
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *ReleasesSuite) TestUninstalls(c *check.C) {
+	_, err := s.releases.Install(context.TODO(), InstallRequest{
+		Application: loc.MustParseLocator("gravitational.io/dashboard:0.0.1"),
+		Name:        "dashboard",
+	})
+	c.Assert(err, check.IsNil)
+
+	_, err = s.releases.Uninstall(context.TODO(), "dashboard")
+	c.Assert(err, check.IsNil)
+
+	records, err := s.releases.History("dashboard")
+	c.Assert(err, check.IsNil)
+	c.Assert(statuses(records), check.DeepEquals, []string{storage.ReleaseStatusUninstalled})
+}
+
+func statuses(records []storage.ReleaseRecord) (out []string) {
+	for _, record := range records {
+		out = append(out, record.Status)
+	}
+	return out
+}
+
+func createApp(c *check.C, apps app.Applications, locator, dependency string) {
+	app := loc.MustParseLocator(locator)
+	manifest := fmt.Sprintf(`apiVersion: bundle.gravitational.io/v2
+kind: Application
+metadata:
+  name: %v
+  resourceVersion: %v
+  repository: gravitational.io`, app.Name, app.Version)
+	if dependency != "" {
+		manifest += fmt.Sprintf(`
+dependencies:
+  apps:
+  - %v`, dependency)
+	}
+	test.CreateApplicationFromData(apps, app, []*archive.Item{
+		archive.DirItem("resources"),
+		archive.ItemFromString("resources/Chart.yaml", fmt.Sprintf(
+			"name: %v\nversion: %v", app.Name, app.Version)),
+		archive.ItemFromString("resources/app.yaml", manifest),
+	}, c)
+}
+
+// fakeHelm is an in-memory Helm client that keeps all release revisions
+type fakeHelm struct {
+	helm.Client
+	clock    clockwork.Clock
+	releases map[string][]*storage.ReleaseV1
+}
+
+func (h *fakeHelm) Install(p helm.InstallParameters) (storage.Release, error) {
+	chart, err := chartutil.LoadChartfile(filepath.Join(p.Path, "Chart.yaml"))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	name := p.Name
+	if name == "" {
+		name = chart.Name
+	}
+	if _, ok := h.releases[name]; ok {
+		return nil, trace.AlreadyExists("release %v already exists", name)
+	}
+	return h.add(name, p.Namespace, chart.Name, chart.Version), nil
+}
+
+func (h *fakeHelm) List(helm.ListParameters) (out []storage.Release, err error) {
+	for _, revisions := range h.releases {
+		out = append(out, revisions[len(revisions)-1])
+	}
+	return out, nil
+}
+
+func (h *fakeHelm) Get(name string) (storage.Release, error) {
+	revisions, ok := h.releases[name]
+	if !ok {
+		return nil, trace.NotFound("release %v not found", name)
+	}
+	return revisions[len(revisions)-1], nil
+}
+
+func (h *fakeHelm) Upgrade(p helm.UpgradeParameters) (storage.Release, error) {
+	current, err := h.Get(p.Release)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	chart, err := chartutil.LoadChartfile(filepath.Join(p.Path, "Chart.yaml"))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return h.add(p.Release, current.GetNamespace(), chart.Name, chart.Version), nil
+}
+
+func (h *fakeHelm) Rollback(p helm.RollbackParameters) (storage.Release, error) {
+	revisions, ok := h.releases[p.Release]
+	if !ok || p.Revision > len(revisions) {
+		return nil, trace.NotFound("release %v revision %v not found", p.Release, p.Revision)
+	}
+	target := revisions[p.Revision-1]
+	return h.add(p.Release, target.GetNamespace(), target.GetChartName(), target.GetChartVersion()), nil
+}
+
+func (h *fakeHelm) Revisions(name string) (out []storage.Release, err error) {
+	for _, revision := range h.releases[name] {
+		out = append(out, revision)
+	}
+	return out, nil
+}
+
+func (h *fakeHelm) Uninstall(name string) (storage.Release, error) {
+	current, err := h.Get(name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	current.(*storage.ReleaseV1).Status.Status = release.Status_DELETED.String()
+	return current, nil
+}
+
+func (h *fakeHelm) add(name, namespace, chartName, chartVersion string) *storage.ReleaseV1 {
+	revisions := h.releases[name]
+	for _, revision := range revisions {
+		revision.Status.Status = release.Status_SUPERSEDED.String()
+	}
+	result := &storage.ReleaseV1{
+		Kind: storage.KindRelease,
+		Spec: storage.ReleaseSpecV1{
+			ChartName:    chartName,
+			ChartVersion: chartVersion,
+			Namespace:    namespace,
+		},
+		Status: storage.ReleaseStatusV1{
+			Status:   release.Status_DEPLOYED.String(),
+			Revision: len(revisions) + 1,
+			Updated:  h.clock.Now(),
+		},
+	}
+	result.SetName(name)
+	h.releases[name] = append(revisions, result)
+	return result
+}
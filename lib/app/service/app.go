@@ -348,6 +348,33 @@ func (r *applications) injectEnvVars(req *appservice.HookRunRequest, client *kub
 	for name, value := range configMap.Data {
 		req.Env[name] = value
 	}
+	return trace.Wrap(r.injectProxyEnvVars(req, client))
+}
+
+// injectProxyEnvVars updates the provided hook run request with the cluster
+// proxy settings if the application has requested them
+func (r *applications) injectProxyEnvVars(req *appservice.HookRunRequest, client *kubernetes.Clientset) error {
+	app, err := r.GetApp(req.Application)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	options := app.Manifest.SystemOptions
+	if options == nil || !options.ProxyEnvironment {
+		return nil
+	}
+	configMap, err := client.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(
+		constants.ClusterProxyMap, metav1.GetOptions{})
+	if err != nil {
+		err = rigging.ConvertError(err)
+		if trace.IsNotFound(err) {
+			r.Debug("No cluster proxy settings.")
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	for name, value := range configMap.Data {
+		req.Env[name] = value
+	}
 	return nil
 }
 
@@ -18,7 +18,11 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -181,21 +185,15 @@ func pullPackage(req PackagePullRequest) (*pack.PackageEnvelope, error) {
 	req.Infof("Pulling package %v.", req.Package)
 
 	reader := ioutil.NopCloser(utils.NopReader())
+	var downloadPath string
 	if req.MetadataOnly {
 		env, err = req.SrcPack.ReadPackageEnvelope(req.Package)
 	} else {
-		env, reader, err = req.SrcPack.ReadPackage(req.Package)
+		env, reader, downloadPath, err = readPackageContents(req.SrcPack, req.DstPack, req.Package, req.Progress, req.FieldLogger)
 	}
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-
-	if req.Progress != nil {
-		reader = utils.TeeReadCloser(reader, &pack.ProgressWriter{
-			Size: env.SizeBytes,
-			R:    req.Progress,
-		})
-	}
 	defer reader.Close()
 
 	err = req.DstPack.UpsertRepository(env.Locator.Repository, time.Time{})
@@ -213,7 +211,9 @@ func pullPackage(req PackagePullRequest) (*pack.PackageEnvelope, error) {
 		}
 	}
 
-	if req.Upsert {
+	if dst, src, ok := chunkedUpload(req.DstPack, reader); ok {
+		env, err = uploadPackage(dst, src, env.Locator, req.Upsert, req.Progress, pack.WithLabels(req.Labels))
+	} else if req.Upsert {
 		env, err = req.DstPack.UpsertPackage(
 			env.Locator, reader, pack.WithLabels(req.Labels))
 	} else {
@@ -224,9 +224,161 @@ func pullPackage(req PackagePullRequest) (*pack.PackageEnvelope, error) {
 		return nil, trace.Wrap(err)
 	}
 
+	if downloadPath != "" {
+		if err := pack.RemoveDownload(downloadPath); err != nil {
+			req.WithError(err).Warn("Failed to remove downloaded package.")
+		}
+	}
 	return env, nil
 }
 
+// readPackageContents returns the contents of the specified package from
+// the source package service along with its envelope.
+// The package is downloaded in chunks if the source package service supports it,
+// otherwise, e.g. if the source is an older version, the contents are read
+// as a whole. Returns the path of the downloaded file in the former case
+func readPackageContents(src, dst pack.PackageService, locator loc.Locator, progress pack.ProgressReporter, logger logrus.FieldLogger) (*pack.PackageEnvelope, io.ReadCloser, string, error) {
+	if chunked, ok := src.(pack.ChunkedReader); ok {
+		env, reader, path, err := downloadPackage(src, chunked, locator, progress, logger)
+		if !trace.IsNotImplemented(err) {
+			return env, reader, path, trace.Wrap(err)
+		}
+		logger.WithError(err).Info("Falling back to downloading the whole package.")
+	}
+	env, reader, err := src.ReadPackage(locator)
+	if err != nil {
+		return nil, nil, "", trace.Wrap(err)
+	}
+	if _, _, chunked := chunkedUpload(dst, reader); progress != nil && !chunked {
+		reader = utils.TeeReadCloser(reader, &pack.ProgressWriter{
+			Size: env.SizeBytes,
+			R:    progress,
+		})
+	}
+	return env, reader, "", nil
+}
+
+// downloadPackage downloads the package contents into a local file using
+// resumable chunked transfer and verifies the downloaded package against
+// the checksum from the package envelope.
+// Returns the package envelope, the reader for the downloaded contents and
+// the path of the downloaded file.
+// The downloaded file is kept if the pull fails so the next attempt can resume
+// the transfer instead of starting over
+func downloadPackage(packages pack.PackageService, src pack.ChunkedReader, locator loc.Locator, progress pack.ProgressReporter, logger logrus.FieldLogger) (*pack.PackageEnvelope, io.ReadCloser, string, error) {
+	env, err := packages.ReadPackageEnvelope(locator)
+	if err != nil {
+		return nil, nil, "", trace.Wrap(err)
+	}
+	manifest, err := src.GetPackageChunks(env.Locator)
+	if err != nil {
+		// Package services of older versions do not serve chunk manifests
+		if trace.IsNotFound(err) || trace.IsNotImplemented(err) {
+			return nil, nil, "", trace.NotImplemented(
+				"package service does not support chunked downloads: %v", err)
+		}
+		return nil, nil, "", trace.Wrap(err)
+	}
+	dir := defaults.InTempDir(defaults.PackageDownloadsDir)
+	if err := os.MkdirAll(dir, defaults.SharedDirMask); err != nil {
+		return nil, nil, "", trace.ConvertSystemError(err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%v-%v-%v",
+		env.Locator.Repository, env.Locator.Name, env.Locator.Version))
+	err = pack.DownloadPackage(context.TODO(), pack.DownloadRequest{
+		Reader:   src,
+		Package:  env.Locator,
+		Path:     path,
+		Progress: progress,
+		Manifest: manifest,
+	})
+	if err != nil {
+		return nil, nil, "", trace.Wrap(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, "", trace.ConvertSystemError(err)
+	}
+	if env.SHA512 != "" {
+		checksum, err := utils.SHA512HalfReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, "", trace.Wrap(err)
+		}
+		if checksum != env.SHA512 {
+			f.Close()
+			if err := pack.RemoveDownload(path); err != nil {
+				logger.WithError(err).Warn("Failed to remove downloaded package.")
+			}
+			return nil, nil, "", trace.BadParameter("checksum mismatch for package %v: got %v, expected %v",
+				env.Locator, checksum, env.SHA512)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, nil, "", trace.ConvertSystemError(err)
+		}
+	}
+	return env, f, path, nil
+}
+
+// chunkedUpload returns the destination package service and the package
+// contents to upload the package in parts if the destination supports
+// chunked uploads and the contents can be read at arbitrary offsets
+func chunkedUpload(dst pack.PackageService, reader io.Reader) (pack.ChunkedWriter, chunkedReader, bool) {
+	writer, ok := dst.(pack.ChunkedWriter)
+	if !ok {
+		return nil, nil, false
+	}
+	src, ok := reader.(chunkedReader)
+	if !ok {
+		return nil, nil, false
+	}
+	return writer, src, true
+}
+
+// uploadPackage uploads the package contents read from src
+// to the destination package service in parts
+func uploadPackage(dst pack.ChunkedWriter, src chunkedReader, locator loc.Locator, upsert bool, progress pack.ProgressReporter, options ...pack.PackageOption) (*pack.PackageEnvelope, error) {
+	size, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	return pack.UploadPackage(context.TODO(), pack.UploadRequest{
+		Writer:   dst,
+		Package:  locator,
+		Reader:   src,
+		Size:     size,
+		Upsert:   upsert,
+		Options:  options,
+		Progress: progress,
+	})
+}
+
+// uploadApp uploads the application package to the destination package service
+// in parts and returns the application from the destination application service.
+// The package is created with the same attributes the application service
+// would set for an application of this kind
+func uploadApp(req AppPullRequest, dst pack.ChunkedWriter, src chunkedReader, env pack.PackageEnvelope, manifest schema.Manifest) (*app.Application, error) {
+	err := req.DstPack.UpsertRepository(env.Locator.Repository, time.Time{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	_, err = uploadPackage(dst, src, env.Locator, req.Upsert, req.Progress,
+		pack.WithLabels(req.Labels),
+		pack.WithManifest(env.Type, env.Manifest),
+		pack.WithHidden(manifest.Metadata.Hidden))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return req.DstApp.GetApp(env.Locator)
+}
+
+// chunkedReader reads package contents at arbitrary offsets
+type chunkedReader interface {
+	io.ReaderAt
+	io.Seeker
+}
+
 // PullApp pulls the application specified with app, along with all its dependencies
 // and base application, from the "source" application service and replicates it in
 // the "destination" application service
@@ -304,24 +456,20 @@ func pullApp(req AppPullRequest, state *pullState) (*app.Application, error) {
 	// pull the application itself
 	var env *pack.PackageEnvelope
 	reader := ioutil.NopCloser(utils.NopReader())
+	var downloadPath string
 	if req.MetadataOnly {
 		env, err = req.SrcPack.ReadPackageEnvelope(req.Package)
 	} else {
-		env, reader, err = req.SrcPack.ReadPackage(req.Package)
+		env, reader, downloadPath, err = readPackageContents(req.SrcPack, req.DstPack, req.Package, req.Progress, req.FieldLogger)
 	}
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-
-	if req.Progress != nil {
-		reader = utils.TeeReadCloser(reader, &pack.ProgressWriter{
-			Size: env.SizeBytes,
-			R:    req.Progress,
-		})
-	}
 	defer reader.Close()
 
-	if req.Upsert {
+	if dst, src, ok := chunkedUpload(req.DstPack, reader); ok && manifest.Kind != schema.KindApplication {
+		application, err = uploadApp(req, dst, src, *env, *manifest)
+	} else if req.Upsert {
 		application, err = req.DstApp.UpsertApp(env.Locator, reader, req.Labels)
 	} else {
 		application, err = req.DstApp.CreateAppWithManifest(
@@ -331,6 +479,11 @@ func pullApp(req AppPullRequest, state *pullState) (*app.Application, error) {
 		return nil, trace.Wrap(err)
 	}
 
+	if downloadPath != "" {
+		if err := pack.RemoveDownload(downloadPath); err != nil {
+			req.WithError(err).Warn("Failed to remove downloaded package.")
+		}
+	}
 	return application, nil
 }
 
@@ -18,6 +18,8 @@ package service
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"path/filepath"
 	"time"
 
@@ -85,6 +87,28 @@ func (s *PullerSuite) TestPullPackage(c *C) {
 	c.Assert(trace.IsAlreadyExists(err), Equals, true)
 }
 
+func (s *PullerSuite) TestFallsBackToFullDownload(c *C) {
+	loc := loc.MustParseLocator("example.com/package:0.0.1")
+	_, err := s.srcPack.CreatePackage(loc, bytes.NewBuffer([]byte("data")))
+	c.Assert(err, IsNil)
+
+	env, err := PullPackage(PackagePullRequest{
+		FieldLogger: log.WithField("test", "FallsBackToFullDownload"),
+		SrcPack:     &unchunkedPackages{PackageService: s.srcPack},
+		DstPack:     s.dstPack,
+		Package:     loc,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(env.Locator, Equals, loc)
+
+	_, reader, err := s.dstPack.ReadPackage(loc)
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "data")
+}
+
 func (s *PullerSuite) TestPullApp(c *C) {
 	s.pullApp(c, 0)
 }
@@ -205,3 +229,17 @@ type packagesByName []loc.Locator
 func (r packagesByName) Len() int           { return len(r) }
 func (r packagesByName) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
 func (r packagesByName) Less(i, j int) bool { return r[i].String() < r[j].String() }
+
+// unchunkedPackages is a package service that advertises chunked downloads
+// but does not serve chunk manifests, like an older package service
+type unchunkedPackages struct {
+	pack.PackageService
+}
+
+func (r *unchunkedPackages) GetPackageChunks(loc.Locator) (*pack.ChunkManifest, error) {
+	return nil, trace.NotFound("not found")
+}
+
+func (r *unchunkedPackages) ReadPackageRange(loc.Locator, int64, int64) (io.ReadCloser, error) {
+	return nil, trace.NotFound("not found")
+}
@@ -41,6 +41,9 @@ type SyncRequest struct {
 	ImageService docker.ImageService
 	Package      loc.Locator
 	Progress     utils.Printer
+	// DisabledComponents lists the application components excluded
+	// from the cluster, their applications are not synced
+	DisabledComponents []string
 }
 
 // CheckAndSetDefaults validates the request and sets some defaults.
@@ -79,7 +82,11 @@ func SyncApp(ctx context.Context, req SyncRequest) error {
 	}
 
 	// sync dependencies
+	disabled := application.Manifest.Components.Apps(req.DisabledComponents)
 	for _, dep := range application.Manifest.Dependencies.Apps {
+		if utils.StringInSlice(disabled, dep.Locator.Name) {
+			continue
+		}
 		err = SyncApp(ctx, SyncRequest{
 			PackService:  req.PackService,
 			AppService:   req.AppService,
@@ -0,0 +1,379 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ClusterLabel is the label on the instance template of the managed
+	// instance group that specifies the name of the cluster to join
+	ClusterLabel = "gravity-cluster"
+	// RoleLabel is the label on the instance template of the managed
+	// instance group that specifies the role of the joining nodes
+	RoleLabel = "gravity-role"
+	// ComputeURL is the base URL of the Compute Engine API
+	ComputeURL = "https://compute.googleapis.com/compute/v1"
+	// SecretManagerURL is the base URL of the Secret Manager API
+	SecretManagerURL = "https://secretmanager.googleapis.com/v1"
+	// createdByAttribute is the instance attribute that references the
+	// managed instance group the instance was created by
+	createdByAttribute = "created-by"
+)
+
+// Autoscaler is GCE autoscaler server, it enables nodes started as a part
+// of managed instance groups to discover cluster information via GCP Secret Manager
+type Autoscaler struct {
+	// Config is Autoscaler config
+	Config
+	*log.Entry
+
+	// publishedToken is the token that has been published to Secret Manager
+	publishedToken string
+	// publishedServiceURL is the service url that has been published to Secret Manager
+	publishedServiceURL string
+}
+
+// Config is autoscaler config
+type Config struct {
+	// ClusterName is a Gravity cluster name,
+	// used to discover configuration in the cluster
+	ClusterName string
+	// Client is an optional kubernetes client
+	Client *kubernetes.Clientset
+	// Metadata is the instance metadata server
+	Metadata Metadata
+	// HTTPClient is the HTTP client authenticated to access GCP APIs
+	HTTPClient *http.Client
+	// ComputeURL is the base URL of the Compute Engine API
+	ComputeURL string
+	// SecretManagerURL is the base URL of the Secret Manager API
+	SecretManagerURL string
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (cfg *Config) CheckAndSetDefaults() error {
+	if cfg.ClusterName == "" {
+		return trace.BadParameter("missing parameter ClusterName")
+	}
+	if cfg.Metadata == nil {
+		var err error
+		cfg.Metadata, err = NewMetadata()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = newHTTPClient(context.Background())
+	}
+	if cfg.ComputeURL == "" {
+		cfg.ComputeURL = ComputeURL
+	}
+	if cfg.SecretManagerURL == "" {
+		cfg.SecretManagerURL = SecretManagerURL
+	}
+	return nil
+}
+
+// New returns new instance of GCE autoscaler
+func New(cfg Config) (*Autoscaler, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Autoscaler{
+		Config: cfg,
+		Entry:  log.WithFields(log.Fields{trace.Component: "autoscale"}),
+	}, nil
+}
+
+// InstanceGroup describes the managed instance group an instance belongs to
+type InstanceGroup struct {
+	// Name is the instance group name
+	Name string
+	// Template is the name of the instance template of the group
+	Template string
+	// Labels are the labels of the instance template
+	Labels map[string]string
+}
+
+// Cluster returns the name of the cluster the nodes of this group should join
+func (r InstanceGroup) Cluster() string {
+	return r.Labels[ClusterLabel]
+}
+
+// Role returns the role of the nodes of this group
+func (r InstanceGroup) Role() string {
+	return r.Labels[RoleLabel]
+}
+
+// GetInstanceGroup returns the managed instance group this instance has been created by
+func (a *Autoscaler) GetInstanceGroup(ctx context.Context) (*InstanceGroup, error) {
+	createdBy, err := a.Metadata.InstanceAttributeValue(createdByAttribute)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("instance does not belong to a managed instance group")
+		}
+		return nil, trace.Wrap(err)
+	}
+	a.Debugf("GetInstanceGroup(%v)", createdBy)
+	var manager struct {
+		Name             string `json:"name"`
+		InstanceTemplate string `json:"instanceTemplate"`
+	}
+	if err := a.get(ctx, a.computeURL(createdBy), &manager); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var template struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"properties"`
+	}
+	if err := a.get(ctx, a.computeURL(manager.InstanceTemplate), &template); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &InstanceGroup{
+		Name:     manager.Name,
+		Template: template.Name,
+		Labels:   template.Properties.Labels,
+	}, nil
+}
+
+// DiscoverInstanceGroup returns the managed instance group this instance
+// has been created by and makes sure it is labeled for this cluster
+func (a *Autoscaler) DiscoverInstanceGroup(ctx context.Context) (*InstanceGroup, error) {
+	group, err := a.GetInstanceGroup(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if group.Cluster() != LabelValue(a.ClusterName) {
+		return nil, trace.NotFound("instance group %v is not labeled with %v=%v",
+			group.Name, ClusterLabel, LabelValue(a.ClusterName))
+	}
+	return group, nil
+}
+
+// GetJoinToken fetches cluster join token from the Secret Manager
+func (a *Autoscaler) GetJoinToken(ctx context.Context) (string, error) {
+	return a.accessSecret(ctx, a.tokenSecret())
+}
+
+// GetServiceURL fetches cluster service URL from the Secret Manager
+func (a *Autoscaler) GetServiceURL(ctx context.Context) (string, error) {
+	return a.accessSecret(ctx, a.serviceURLSecret())
+}
+
+func (a *Autoscaler) publishServiceURL(ctx context.Context, serviceURL string, force bool) error {
+	// only publish if there is a change
+	if serviceURL == a.publishedServiceURL && !force {
+		return nil
+	}
+	if err := a.publishSecret(ctx, a.serviceURLSecret(), serviceURL); err != nil {
+		return trace.Wrap(err)
+	}
+	a.publishedServiceURL = serviceURL
+	return nil
+}
+
+func (a *Autoscaler) publishJoinToken(ctx context.Context, token string, force bool) error {
+	// only publish if there is a change
+	if token == a.publishedToken && !force {
+		return nil
+	}
+	if err := a.publishSecret(ctx, a.tokenSecret(), token); err != nil {
+		return trace.Wrap(err)
+	}
+	a.publishedToken = token
+	return nil
+}
+
+func (a *Autoscaler) accessSecret(ctx context.Context, name string) (string, error) {
+	a.Debugf("AccessSecret(%v)", name)
+	path, err := a.secretPath(name)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	var version secretVersion
+	err = a.get(ctx, fmt.Sprintf("%v/%v/versions/latest:access", a.SecretManagerURL, path), &version)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	data, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return "", trace.Wrap(err, "failed to decode secret %v", name)
+	}
+	return string(data), nil
+}
+
+// publishSecret adds a new version of the secret with the specified name
+// creating the secret if necessary
+func (a *Autoscaler) publishSecret(ctx context.Context, name, value string) error {
+	a.Debugf("PublishSecret(%v)", name)
+	project, err := a.Metadata.ProjectID()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = a.post(ctx, fmt.Sprintf("%v/projects/%v/secrets?secretId=%v",
+		a.SecretManagerURL, project, url.QueryEscape(name)),
+		map[string]interface{}{
+			"replication": map[string]interface{}{"automatic": struct{}{}},
+		})
+	if err != nil && !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	path, err := a.secretPath(name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var version secretVersion
+	version.Payload.Data = base64.StdEncoding.EncodeToString([]byte(value))
+	err = a.post(ctx, fmt.Sprintf("%v/%v:addVersion", a.SecretManagerURL, path), version)
+	return trace.Wrap(err)
+}
+
+func (a *Autoscaler) secretPath(name string) (string, error) {
+	project, err := a.Metadata.ProjectID()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return fmt.Sprintf("projects/%v/secrets/%v", project, name), nil
+}
+
+func (a *Autoscaler) tokenSecret() string {
+	return fmt.Sprintf("gravity-%v-token", secretID(a.ClusterName))
+}
+
+func (a *Autoscaler) serviceURLSecret() string {
+	return fmt.Sprintf("gravity-%v-service", secretID(a.ClusterName))
+}
+
+// computeURL returns the Compute Engine API URL for the specified resource
+// given either as a relative path or a fully qualified URL
+func (a *Autoscaler) computeURL(resource string) string {
+	if index := strings.Index(resource, "projects/"); index != -1 {
+		resource = resource[index:]
+	}
+	return fmt.Sprintf("%v/%v", strings.TrimSuffix(a.ComputeURL, "/"), resource)
+}
+
+func (a *Autoscaler) get(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.roundtrip(ctx, req, out))
+}
+
+func (a *Autoscaler) post(ctx context.Context, url string, in interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return trace.Wrap(a.roundtrip(ctx, req, nil))
+}
+
+func (a *Autoscaler) roundtrip(ctx context.Context, req *http.Request, out interface{}) error {
+	resp, err := a.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return trace.ConnectionProblem(err, "failed to query %v", req.URL)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ConvertError(resp.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+	return trace.Wrap(json.Unmarshal(data, out))
+}
+
+// ConvertError converts the error response of a GCP API
+// to trace-compatible error
+func ConvertError(statusCode int, data []byte) error {
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &resp); err == nil && resp.Error.Message != "" {
+		message = resp.Error.Message
+	}
+	switch statusCode {
+	case http.StatusNotFound:
+		return trace.NotFound("%v", message)
+	case http.StatusConflict:
+		return trace.AlreadyExists("%v", message)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return trace.AccessDenied("%v", message)
+	default:
+		return trace.BadParameter("%v", message)
+	}
+}
+
+// LabelValue converts the specified value to a valid GCE label value
+func LabelValue(value string) string {
+	value = invalidLabelChars.ReplaceAllString(strings.ToLower(value), "-")
+	if len(value) > maxLabelLength {
+		value = value[:maxLabelLength]
+	}
+	return value
+}
+
+// secretID converts the specified value to a valid Secret Manager secret ID
+func secretID(value string) string {
+	return invalidSecretChars.ReplaceAllString(value, "-")
+}
+
+type secretVersion struct {
+	Payload struct {
+		Data string `json:"data"`
+	} `json:"payload"`
+}
+
+// maxLabelLength limits the length of the label value.
+// See https://cloud.google.com/compute/docs/labeling-resources
+const maxLabelLength = 63
+
+// invalidLabelChars matches characters not allowed in label values
+var invalidLabelChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// invalidSecretChars matches characters not allowed in secret IDs
+var invalidSecretChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
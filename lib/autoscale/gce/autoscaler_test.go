@@ -0,0 +1,259 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func TestAutoscaler(t *testing.T) { check.TestingT(t) }
+
+type AutoscalerSuite struct {
+	api    *fakeAPI
+	server *httptest.Server
+}
+
+var _ = check.Suite(&AutoscalerSuite{})
+
+func (s *AutoscalerSuite) SetUpTest(c *check.C) {
+	s.api = newFakeAPI()
+	s.server = httptest.NewServer(s.api)
+}
+
+func (s *AutoscalerSuite) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+func (s *AutoscalerSuite) TestDiscoversInstanceGroup(c *check.C) {
+	s.api.templates["projects/project-1/global/instanceTemplates/workers"] = map[string]string{
+		ClusterLabel: "example-com",
+		RoleLabel:    "worker",
+	}
+	a := s.newAutoscaler(c, "example.com", "projects/1234/zones/us-central1-a/instanceGroupManagers/workers")
+
+	group, err := a.DiscoverInstanceGroup(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(group.Name, check.Equals, "workers")
+	c.Assert(group.Role(), check.Equals, "worker")
+
+	a.ClusterName = "other.example.com"
+	_, err = a.DiscoverInstanceGroup(context.TODO())
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *AutoscalerSuite) TestRequiresInstanceGroup(c *check.C) {
+	a := s.newAutoscaler(c, "example.com", "")
+	_, err := a.DiscoverInstanceGroup(context.TODO())
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *AutoscalerSuite) TestPublishesDiscovery(c *check.C) {
+	a := s.newAutoscaler(c, "example.com", "")
+
+	_, err := a.GetJoinToken(context.TODO())
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+
+	operator := &mockOperator{token: "token-1"}
+	err = a.publishJoinToken(context.TODO(), operator.token, false)
+	c.Assert(err, check.IsNil)
+	err = a.publishServiceURL(context.TODO(), "https://10.0.0.1:3009", false)
+	c.Assert(err, check.IsNil)
+
+	token, err := a.GetJoinToken(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "token-1")
+	serviceURL, err := a.GetServiceURL(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(serviceURL, check.Equals, "https://10.0.0.1:3009")
+
+	// unchanged values are not published again
+	err = a.publishJoinToken(context.TODO(), "token-1", false)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.api.versions["projects/project-1/secrets/gravity-example-com-token"], check.HasLen, 1)
+
+	err = a.publishJoinToken(context.TODO(), "token-2", false)
+	c.Assert(err, check.IsNil)
+	token, err = a.GetJoinToken(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "token-2")
+}
+
+func (s *AutoscalerSuite) TestLabelValue(c *check.C) {
+	c.Assert(LabelValue("Example.com"), check.Equals, "example-com")
+	c.Assert(LabelValue(strings.Repeat("a", 100)), check.HasLen, maxLabelLength)
+}
+
+func (s *AutoscalerSuite) newAutoscaler(c *check.C, clusterName, createdBy string) *Autoscaler {
+	a, err := New(Config{
+		ClusterName: clusterName,
+		Metadata: &mockMetadata{
+			projectID: "project-1",
+			attributes: map[string]string{
+				createdByAttribute: createdBy,
+			},
+		},
+		HTTPClient:       s.server.Client(),
+		ComputeURL:       s.server.URL + "/compute/v1",
+		SecretManagerURL: s.server.URL + "/secretmanager/v1",
+	})
+	c.Assert(err, check.IsNil)
+	return a
+}
+
+type mockMetadata struct {
+	projectID  string
+	attributes map[string]string
+}
+
+func (r *mockMetadata) ProjectID() (string, error) {
+	return r.projectID, nil
+}
+
+func (r *mockMetadata) InternalIP() (string, error) {
+	return "10.0.0.2", nil
+}
+
+func (r *mockMetadata) InstanceAttributeValue(attr string) (string, error) {
+	if value := r.attributes[attr]; value != "" {
+		return value, nil
+	}
+	return "", trace.NotFound("instance attribute %q is not defined", attr)
+}
+
+type mockOperator struct {
+	token string
+}
+
+func (r *mockOperator) GetLocalSite() (*ops.Site, error) {
+	return &ops.Site{AccountID: "1", Domain: "example.com"}, nil
+}
+
+func (r *mockOperator) GetExpandToken(ops.SiteKey) (*storage.ProvisioningToken, error) {
+	return &storage.ProvisioningToken{Token: r.token}, nil
+}
+
+// fakeAPI implements the subset of Compute Engine and Secret Manager APIs
+// used by the autoscaler
+type fakeAPI struct {
+	sync.Mutex
+	// templates maps instance templates to their labels
+	templates map[string]map[string]string
+	// versions maps secrets to their versions
+	versions map[string][]string
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		templates: make(map[string]map[string]string),
+		versions:  make(map[string][]string),
+	}
+}
+
+func (r *fakeAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	path := req.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/compute/v1/"):
+		r.serveCompute(w, strings.TrimPrefix(path, "/compute/v1/"))
+	case strings.HasPrefix(path, "/secretmanager/v1/"):
+		r.serveSecrets(w, req, strings.TrimPrefix(path, "/secretmanager/v1/"))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (r *fakeAPI) serveCompute(w http.ResponseWriter, path string) {
+	if labels, ok := r.templates[path]; ok {
+		writeJSON(w, map[string]interface{}{
+			"name":       path[strings.LastIndex(path, "/")+1:],
+			"properties": map[string]interface{}{"labels": labels},
+		})
+		return
+	}
+	if strings.Contains(path, "/instanceGroupManagers/") {
+		name := path[strings.LastIndex(path, "/")+1:]
+		writeJSON(w, map[string]interface{}{
+			"name":             name,
+			"instanceTemplate": "https://www.googleapis.com/compute/v1/projects/project-1/global/instanceTemplates/" + name,
+		})
+		return
+	}
+	writeError(w, http.StatusNotFound, "resource not found")
+}
+
+func (r *fakeAPI) serveSecrets(w http.ResponseWriter, req *http.Request, path string) {
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(path, "/secrets"):
+		name := path + "/" + req.URL.Query().Get("secretId")
+		if _, ok := r.versions[name]; ok {
+			writeError(w, http.StatusConflict, "secret already exists")
+			return
+		}
+		r.versions[name] = nil
+		writeJSON(w, map[string]string{"name": name})
+	case req.Method == http.MethodPost && strings.HasSuffix(path, ":addVersion"):
+		name := strings.TrimSuffix(path, ":addVersion")
+		if _, ok := r.versions[name]; !ok {
+			writeError(w, http.StatusNotFound, "secret not found")
+			return
+		}
+		var version secretVersion
+		if err := json.NewDecoder(req.Body).Decode(&version); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		r.versions[name] = append(r.versions[name], version.Payload.Data)
+		writeJSON(w, map[string]string{"name": name})
+	case req.Method == http.MethodGet && strings.HasSuffix(path, "/versions/latest:access"):
+		name := strings.TrimSuffix(path, "/versions/latest:access")
+		versions := r.versions[name]
+		if len(versions) == 0 {
+			writeError(w, http.StatusNotFound, "secret not found")
+			return
+		}
+		var version secretVersion
+		version.Payload.Data = versions[len(versions)-1]
+		writeJSON(w, version)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/gravitational/trace"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PublishDiscovery periodically updates discovery information
+func (a *Autoscaler) PublishDiscovery(ctx context.Context, operator Operator) {
+	a.Info("Start publishing discovery info.")
+	err := a.syncDiscovery(ctx, operator, true)
+	if err != nil {
+		a.Errorf("Failed to publish discovery: %v.", trace.DebugReport(err))
+	}
+	publishTicker := time.NewTicker(defaults.DiscoveryPublishInterval)
+	defer publishTicker.Stop()
+	resyncTicker := time.NewTicker(defaults.DiscoveryResyncInterval)
+	defer resyncTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.Info("Stop publishing discovery info.")
+			return
+		case <-publishTicker.C:
+			err = a.syncDiscovery(ctx, operator, false)
+			if err != nil {
+				a.Errorf("Failed to publish discovery: %v.", trace.DebugReport(err))
+			}
+		case <-resyncTicker.C:
+			err = a.syncDiscovery(ctx, operator, true)
+			if err != nil {
+				a.Errorf("Failed to publish discovery: %v.", trace.DebugReport(err))
+			}
+		}
+	}
+}
+
+// syncDiscovery syncs cluster discovery information in the Secret Manager
+func (a *Autoscaler) syncDiscovery(ctx context.Context, operator Operator, force bool) error {
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	joinToken, err := operator.GetExpandToken(cluster.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := a.publishJoinToken(ctx, joinToken.Token, force); err != nil {
+		return trace.Wrap(err)
+	}
+	serviceURL, err := a.getServiceURL()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.publishServiceURL(ctx, serviceURL, force))
+}
+
+func (a *Autoscaler) getServiceURL() (string, error) {
+	if a.Client == nil {
+		return "", trace.BadParameter("kubernetes client is required to discover %v",
+			constants.GravityServiceName)
+	}
+	service, err := a.Client.CoreV1().Services(constants.KubeSystemNamespace).Get(constants.GravityServiceName, v1.GetOptions{})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	var port int32
+	for _, p := range service.Spec.Ports {
+		if p.Name == constants.GravityServicePortName {
+			port = p.Port
+			break
+		}
+	}
+	if port == 0 {
+		return "", trace.NotFound("no port %q found for service %q", constants.GravityServicePortName, constants.GravityServiceName)
+	}
+	// load balancers on GCE are exposed with IP addresses
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return fmt.Sprintf("https://%v:%v", ingress.IP, port), nil
+		}
+		if ingress.Hostname != "" {
+			return fmt.Sprintf("https://%v:%v", ingress.Hostname, port), nil
+		}
+	}
+	return "", trace.NotFound("ingress load balancer not found for %v", constants.GravityServiceName)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/* package gce implements autoscaling integration for Google Compute Engine
+
+Design
+------
+
+* Nodes are started as a part of a managed instance group created from an
+  instance template labeled with the name of the cluster to join
+  (gravity-cluster label) and optionally the role of the node (gravity-role label).
+* Autoscaler runs on master nodes and publishes the Gravity load balancer service
+  address and the join token to GCP Secret Manager.
+* Instances started up as a part of the managed instance group identify
+  themselves using the metadata server, discover the cluster via the labels
+  of the instance group they belong to and read the join token and the service
+  address from the Secret Manager.
+
+The service account the instances run with needs permissions to read
+instance group managers and instance templates (e.g. roles/compute.viewer)
+and to access the secrets (roles/secretmanager.secretAccessor). The service
+account of master nodes additionally needs permissions to create secrets
+and add secret versions (e.g. roles/secretmanager.admin).
+
+*/
+package gce
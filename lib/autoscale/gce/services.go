@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	gcemeta "cloud.google.com/go/compute/metadata"
+	"github.com/gravitational/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Metadata is an interface representing GCE instance metadata server
+type Metadata interface {
+	// ProjectID returns the ID of the project the instance belongs to
+	ProjectID() (string, error)
+	// InternalIP returns the private IP address of the instance
+	InternalIP() (string, error)
+	// InstanceAttributeValue returns the value of the specified instance attribute
+	InstanceAttributeValue(attr string) (string, error)
+}
+
+// Operator is a simplified operator interface to mock in tests
+type Operator interface {
+	GetLocalSite() (*ops.Site, error)
+	GetExpandToken(ops.SiteKey) (*storage.ProvisioningToken, error)
+}
+
+// NewMetadata returns the metadata of the instance this process runs on
+func NewMetadata() (Metadata, error) {
+	if !gcemeta.OnGCE() {
+		return nil, trace.NotFound("not running on GCE")
+	}
+	return metadata{}, nil
+}
+
+type metadata struct{}
+
+// ProjectID returns the ID of the project the instance belongs to
+func (metadata) ProjectID() (string, error) {
+	return gcemeta.ProjectID()
+}
+
+// InternalIP returns the private IP address of the instance
+func (metadata) InternalIP() (string, error) {
+	return gcemeta.InternalIP()
+}
+
+// InstanceAttributeValue returns the value of the specified instance attribute
+func (metadata) InstanceAttributeValue(attr string) (string, error) {
+	value, err := gcemeta.InstanceAttributeValue(attr)
+	if err != nil {
+		if _, ok := err.(gcemeta.NotDefinedError); ok {
+			return "", trace.NotFound("instance attribute %q is not defined", attr)
+		}
+		return "", trace.Wrap(err)
+	}
+	return value, nil
+}
+
+// newHTTPClient returns a new HTTP client authenticated with the
+// default service account of the instance
+func newHTTPClient(ctx context.Context) *http.Client {
+	return oauth2.NewClient(ctx, google.ComputeTokenSource(""))
+}
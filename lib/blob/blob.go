@@ -31,6 +31,14 @@ type Envelope struct {
 	Modified time.Time `json:"modified"`
 }
 
+const (
+	// BackendCluster replicates BLOBs between local storages
+	// of package service instances
+	BackendCluster = "cluster"
+	// BackendS3 stores BLOBs in an S3 bucket or an S3-compatible object storage
+	BackendS3 = "s3"
+)
+
 // ReadSeekCloser implements Reader, Seeker and Closer
 type ReadSeekCloser interface {
 	io.Reader
@@ -0,0 +1,445 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package s3 implements BLOB storage backed by an S3 bucket or
+// an S3-compatible object storage such as MinIO.
+//
+// BLOBs are stored in the bucket under <prefix>/blobs/<hash>. Each node keeps
+// a local BLOB storage as a read-through cache: BLOBs are written to the cache
+// first to compute their hash and uploaded to the bucket afterwards, and BLOBs
+// missing from the cache are downloaded from the bucket on first read.
+//
+// Since BLOBs are content-addressed, cached BLOBs are served without consulting
+// the bucket. The cache is bounded in size and the least recently used BLOBs
+// are evicted from it once it grows past its capacity.
+package s3
+
+import (
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/gravity/lib/blob"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the S3 BLOB storage configuration
+type Config struct {
+	// Bucket is the S3 bucket name
+	Bucket string
+	// Prefix is the optional prefix of BLOB keys in the bucket
+	Prefix string
+	// Region is the bucket region
+	Region string
+	// Endpoint is the optional URL of an S3-compatible object storage,
+	// for example MinIO
+	Endpoint string
+	// ForcePathStyle enables path-style bucket addressing
+	// required by most S3-compatible object storages
+	ForcePathStyle bool
+	// AccessKeyID is the optional access key ID.
+	// If unspecified, the default AWS credentials chain is used
+	AccessKeyID string
+	// SecretAccessKey is the optional secret access key
+	SecretAccessKey string
+	// AbortIncompleteUploadDays is the number of days after which the bucket
+	// lifecycle configuration aborts incomplete multipart uploads
+	AbortIncompleteUploadDays int64
+	// Local is the local BLOB storage used as a read-through cache
+	Local blob.Objects
+	// CacheCapacityBytes is the maximum size of the local cache in bytes
+	CacheCapacityBytes int64
+	// S3 is optional S3 API client
+	S3 s3iface.S3API
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+}
+
+// CheckAndSetDefaults validates config and sets defaults
+func (c *Config) CheckAndSetDefaults() error {
+	if c.Bucket == "" {
+		return trace.BadParameter("missing parameter Bucket")
+	}
+	if c.Local == nil {
+		return trace.BadParameter("missing parameter Local")
+	}
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		return trace.BadParameter("both access key ID and secret access key must be provided")
+	}
+	if c.Region == "" {
+		c.Region = defaults.AWSRegion
+	}
+	if c.AbortIncompleteUploadDays == 0 {
+		c.AbortIncompleteUploadDays = defaults.BlobS3AbortIncompleteUploadDays
+	}
+	if c.CacheCapacityBytes < 0 {
+		return trace.BadParameter("cache capacity can't be negative")
+	}
+	if c.CacheCapacityBytes == 0 {
+		c.CacheCapacityBytes = defaults.BlobS3CacheCapacityBytes
+	}
+	if c.FieldLogger == nil {
+		c.FieldLogger = logrus.WithFields(logrus.Fields{
+			trace.Component: constants.ComponentBLOB,
+			"bucket":        c.Bucket,
+		})
+	}
+	if c.S3 == nil {
+		config := &aws.Config{
+			Region:           aws.String(c.Region),
+			S3ForcePathStyle: aws.Bool(c.ForcePathStyle),
+		}
+		if c.Endpoint != "" {
+			config.Endpoint = aws.String(c.Endpoint)
+		}
+		if c.AccessKeyID != "" {
+			config.Credentials = credentials.NewStaticCredentials(c.AccessKeyID, c.SecretAccessKey, "")
+		}
+		session, err := session.NewSession(config)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		c.S3 = s3.New(session)
+	}
+	return nil
+}
+
+// New returns a new BLOB storage backed by the configured S3 bucket.
+//
+// It makes sure the bucket lifecycle configuration aborts incomplete
+// multipart uploads so interrupted writes do not accumulate in the bucket
+func New(config Config) (blob.Objects, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	o := &objects{
+		Config:   config,
+		uploader: s3manager.NewUploaderWithClient(config.S3),
+		accessed: make(map[string]time.Time),
+	}
+	if err := o.updateLifecycle(); err != nil {
+		// The bucket might be managed externally with credentials
+		// that do not permit lifecycle changes
+		o.WithError(err).Warn("Failed to update bucket lifecycle configuration.")
+	}
+	return o, nil
+}
+
+type objects struct {
+	Config
+	uploader *s3manager.Uploader
+	// Mutex guards the access times and serializes cache eviction
+	sync.Mutex
+	// accessed maps hashes of cached BLOBs to the time they were last
+	// accessed by this process
+	accessed map[string]time.Time
+}
+
+// Close closes the local cache
+func (o *objects) Close() error {
+	return o.Local.Close()
+}
+
+// WriteBLOB writes the BLOB to the local cache and uploads it to the bucket
+func (o *objects) WriteBLOB(data io.Reader) (*blob.Envelope, error) {
+	envelope, err := o.Local.WriteBLOB(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	o.touch(envelope.SHA512)
+	defer o.evict()
+	existing, err := o.GetBLOBEnvelope(envelope.SHA512)
+	if err == nil && existing.SizeBytes == envelope.SizeBytes {
+		// BLOBs are content-addressed so there is nothing to upload
+		return existing, nil
+	}
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	reader, err := o.Local.OpenBLOB(envelope.SHA512)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer reader.Close()
+	_, err = o.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(o.key(envelope.SHA512)),
+		Body:   reader,
+	})
+	if err != nil {
+		return nil, trace.Wrap(utils.ConvertS3Error(err))
+	}
+	o.Debugf("Uploaded BLOB %v.", envelope.SHA512)
+	return o.GetBLOBEnvelope(envelope.SHA512)
+}
+
+// OpenBLOB opens the BLOB from the local cache, downloading it
+// from the bucket first if it has not been cached yet.
+//
+// Cached BLOBs are served without checking the bucket: the contents of
+// a BLOB never change, and whether the BLOB is still referenced is decided
+// by the package metadata shared by all nodes
+func (o *objects) OpenBLOB(hash string) (blob.ReadSeekCloser, error) {
+	reader, err := o.Local.OpenBLOB(hash)
+	if err == nil {
+		o.touch(hash)
+		return reader, nil
+	}
+	if !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	if err := o.fetch(hash); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// The reader keeps the file open so the BLOB can be
+	// evicted from the cache while it is being read
+	reader, err = o.Local.OpenBLOB(hash)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	o.evict()
+	return reader, nil
+}
+
+// fetch downloads the BLOB from the bucket into the local cache
+func (o *objects) fetch(hash string) error {
+	out, err := o.S3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(o.key(hash)),
+	})
+	if err != nil {
+		return trace.Wrap(utils.ConvertS3Error(err))
+	}
+	defer out.Body.Close()
+	envelope, err := o.Local.WriteBLOB(out.Body)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if envelope.SHA512 != hash {
+		if err := o.Local.DeleteBLOB(envelope.SHA512); err != nil {
+			o.WithError(err).Warnf("Failed to delete corrupted BLOB %v.", envelope.SHA512)
+		}
+		return trace.BadParameter("BLOB %v is corrupted: hash mismatch %v", hash, envelope.SHA512)
+	}
+	o.touch(hash)
+	o.Debugf("Cached BLOB %v.", hash)
+	return nil
+}
+
+// touch records the access to the cached BLOB with the specified hash
+func (o *objects) touch(hash string) {
+	o.Lock()
+	defer o.Unlock()
+	o.accessed[hash] = time.Now()
+}
+
+// evict removes the least recently used BLOBs from the local cache
+// until its size does not exceed the configured capacity
+func (o *objects) evict() {
+	o.Lock()
+	defer o.Unlock()
+	hashes, err := o.Local.GetBLOBs()
+	if err != nil {
+		o.WithError(err).Warn("Failed to list cached BLOBs.")
+		return
+	}
+	var size int64
+	var cached []cachedBLOB
+	for _, hash := range hashes {
+		envelope, err := o.Local.GetBLOBEnvelope(hash)
+		if err != nil {
+			if !trace.IsNotFound(err) {
+				o.WithError(err).Warnf("Failed to query cached BLOB %v.", hash)
+			}
+			continue
+		}
+		accessed, ok := o.accessed[hash]
+		if !ok {
+			// Cached by a previous process
+			accessed = envelope.Modified
+		}
+		size += envelope.SizeBytes
+		cached = append(cached, cachedBLOB{
+			hash:      hash,
+			sizeBytes: envelope.SizeBytes,
+			accessed:  accessed,
+		})
+	}
+	if size <= o.CacheCapacityBytes {
+		return
+	}
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].accessed.Before(cached[j].accessed)
+	})
+	for _, b := range cached {
+		if size <= o.CacheCapacityBytes {
+			break
+		}
+		err := o.Local.DeleteBLOB(b.hash)
+		if err != nil && !trace.IsNotFound(err) {
+			o.WithError(err).Warnf("Failed to evict BLOB %v from cache.", b.hash)
+			continue
+		}
+		delete(o.accessed, b.hash)
+		size -= b.sizeBytes
+		o.Debugf("Evicted BLOB %v from cache.", b.hash)
+	}
+}
+
+// cachedBLOB describes a BLOB in the local cache
+type cachedBLOB struct {
+	hash      string
+	sizeBytes int64
+	accessed  time.Time
+}
+
+// DeleteBLOB deletes the BLOB from the bucket and the local cache
+func (o *objects) DeleteBLOB(hash string) error {
+	if _, err := o.GetBLOBEnvelope(hash); err != nil {
+		return trace.Wrap(err)
+	}
+	_, err := o.S3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(o.key(hash)),
+	})
+	if err != nil {
+		return trace.Wrap(utils.ConvertS3Error(err))
+	}
+	err = o.Local.DeleteBLOB(hash)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	o.Lock()
+	delete(o.accessed, hash)
+	o.Unlock()
+	return nil
+}
+
+// GetBLOBs returns the list of BLOBs in the bucket
+func (o *objects) GetBLOBs() ([]string, error) {
+	var hashes []string
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(o.Bucket),
+		Prefix: aws.String(o.prefix()),
+	}
+	for {
+		out, err := o.S3.ListObjectsV2(input)
+		if err != nil {
+			return nil, trace.Wrap(utils.ConvertS3Error(err))
+		}
+		for _, object := range out.Contents {
+			hashes = append(hashes, path.Base(aws.StringValue(object.Key)))
+		}
+		if !aws.BoolValue(out.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+// GetBLOBEnvelope returns the envelope of the BLOB in the bucket
+func (o *objects) GetBLOBEnvelope(hash string) (*blob.Envelope, error) {
+	out, err := o.S3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(o.Bucket),
+		Key:    aws.String(o.key(hash)),
+	})
+	if err != nil {
+		return nil, trace.Wrap(utils.ConvertS3Error(err))
+	}
+	return &blob.Envelope{
+		SizeBytes: aws.Int64Value(out.ContentLength),
+		SHA512:    hash,
+		Modified:  aws.TimeValue(out.LastModified).UTC(),
+	}, nil
+}
+
+// updateLifecycle adds the rule that aborts incomplete multipart uploads
+// of BLOBs to the bucket lifecycle configuration, preserving other rules
+func (o *objects) updateLifecycle() error {
+	var rules []*s3.LifecycleRule
+	out, err := o.S3.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(o.Bucket),
+	})
+	if err != nil && !isNoSuchLifecycleConfiguration(err) {
+		return trace.Wrap(utils.ConvertS3Error(err))
+	}
+	if out != nil {
+		for _, rule := range out.Rules {
+			if aws.StringValue(rule.ID) != lifecycleRuleID {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	rules = append(rules, &s3.LifecycleRule{
+		ID:     aws.String(lifecycleRuleID),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		Filter: &s3.LifecycleRuleFilter{
+			Prefix: aws.String(o.prefix()),
+		},
+		AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int64(o.AbortIncompleteUploadDays),
+		},
+	})
+	_, err = o.S3.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(o.Bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: rules,
+		},
+	})
+	return trace.Wrap(utils.ConvertS3Error(err))
+}
+
+// key returns the bucket key of the BLOB with the specified hash
+func (o *objects) key(hash string) string {
+	return o.prefix() + hash
+}
+
+// prefix returns the bucket prefix BLOBs are stored under
+func (o *objects) prefix() string {
+	return strings.TrimPrefix(path.Join(o.Prefix, blobsPrefix), "/") + "/"
+}
+
+func isNoSuchLifecycleConfiguration(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == errCodeNoSuchLifecycleConfiguration
+}
+
+const (
+	// blobsPrefix is the bucket prefix BLOBs are stored under
+	blobsPrefix = "blobs"
+	// lifecycleRuleID identifies the bucket lifecycle rule managed by gravity
+	lifecycleRuleID = "gravity-blobs-abort-incomplete-uploads"
+	// errCodeNoSuchLifecycleConfiguration is returned if the bucket
+	// has no lifecycle configuration
+	errCodeNoSuchLifecycleConfiguration = "NoSuchLifecycleConfiguration"
+)
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gravitational/gravity/lib/blob"
+	"github.com/gravitational/gravity/lib/blob/fs"
+	"github.com/gravitational/gravity/lib/blob/suite"
+	"github.com/gravitational/gravity/lib/testutils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gravitational/trace"
+	. "gopkg.in/check.v1"
+)
+
+func TestS3(t *testing.T) { TestingT(t) }
+
+type S3Suite struct {
+	suite suite.BLOBSuite
+	s3    *testutils.S3
+}
+
+var _ = Suite(&S3Suite{})
+
+func (s *S3Suite) SetUpTest(c *C) {
+	s.s3 = testutils.NewS3()
+	s.suite.Objects = s.newObjects(c, 0)
+}
+
+func (s *S3Suite) newObjects(c *C, cacheCapacityBytes int64) blob.Objects {
+	local, err := fs.New(c.MkDir())
+	c.Assert(err, IsNil)
+	objects, err := New(Config{
+		Bucket: "blobs",
+		Prefix: "cluster",
+		Local:              local,
+		S3:                 s.s3,
+		CacheCapacityBytes: cacheCapacityBytes,
+	})
+	c.Assert(err, IsNil)
+	return objects
+}
+
+func (s *S3Suite) TestBLOB(c *C) {
+	s.suite.BLOB(c)
+}
+
+func (s *S3Suite) TestBLOBSeek(c *C) {
+	s.suite.BLOBSeek(c)
+}
+
+func (s *S3Suite) TestBLOBWriteTwice(c *C) {
+	s.suite.BLOBWriteTwice(c)
+}
+
+func (s *S3Suite) TestBLOBList(c *C) {
+	s.suite.BLOBList(c)
+}
+
+func (s *S3Suite) TestReadsThroughCache(c *C) {
+	data := []byte("hello, blob")
+	envelope, err := s.suite.Objects.WriteBLOB(bytes.NewBuffer(data))
+	c.Assert(err, IsNil)
+	c.Assert(s.s3.Objects["cluster/blobs/"+envelope.SHA512].Data, DeepEquals, data)
+
+	// another node with an empty cache downloads the BLOB from the bucket
+	other := s.newObjects(c, 0)
+	reader, err := other.OpenBLOB(envelope.SHA512)
+	c.Assert(err, IsNil)
+	out, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(reader.Close(), IsNil)
+	c.Assert(out, DeepEquals, data)
+
+	// the cached copy is served without querying the bucket
+	delete(s.s3.Objects, "cluster/blobs/"+envelope.SHA512)
+	reader, err = other.OpenBLOB(envelope.SHA512)
+	c.Assert(err, IsNil)
+	c.Assert(reader.Close(), IsNil)
+
+	// a node without a cached copy does not find the deleted BLOB
+	_, err = s.newObjects(c, 0).OpenBLOB(envelope.SHA512)
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%v", err))
+}
+
+func (s *S3Suite) TestEvictsLeastRecentlyUsedBLOBs(c *C) {
+	local, err := fs.New(c.MkDir())
+	c.Assert(err, IsNil)
+	objects, err := New(Config{
+		Bucket:             "blobs",
+		Local:              local,
+		S3:                 s.s3,
+		CacheCapacityBytes: 20,
+	})
+	c.Assert(err, IsNil)
+
+	first, err := objects.WriteBLOB(bytes.NewBufferString("first blob"))
+	c.Assert(err, IsNil)
+	second, err := objects.WriteBLOB(bytes.NewBufferString("second blob"))
+	c.Assert(err, IsNil)
+	// the first BLOB is evicted to fit the second one
+	_, err = local.GetBLOBEnvelope(first.SHA512)
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%v", err))
+
+	// and is downloaded from the bucket again, evicting the second one
+	reader, err := objects.OpenBLOB(first.SHA512)
+	c.Assert(err, IsNil)
+	out, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(reader.Close(), IsNil)
+	c.Assert(string(out), Equals, "first blob")
+	_, err = local.GetBLOBEnvelope(second.SHA512)
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%v", err))
+	hashes, err := local.GetBLOBs()
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []string{first.SHA512})
+}
+
+func (s *S3Suite) TestPreservesLifecycleRules(c *C) {
+	c.Assert(s.s3.Lifecycle.Rules, HasLen, 1)
+	c.Assert(aws.StringValue(s.s3.Lifecycle.Rules[0].ID), Equals, lifecycleRuleID)
+	c.Assert(aws.StringValue(s.s3.Lifecycle.Rules[0].Filter.Prefix), Equals, "cluster/blobs/")
+
+	s.s3.Lifecycle.Rules = append(s.s3.Lifecycle.Rules, &s3.LifecycleRule{
+		ID:     aws.String("user-rule"),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+	})
+	s.newObjects(c, 0)
+	c.Assert(s.s3.Lifecycle.Rules, HasLen, 2)
+	c.Assert(aws.StringValue(s.s3.Lifecycle.Rules[0].ID), Equals, "user-rule")
+	c.Assert(aws.StringValue(s.s3.Lifecycle.Rules[1].ID), Equals, lifecycleRuleID)
+}
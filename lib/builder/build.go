@@ -23,6 +23,7 @@ import (
 	"os"
 	"runtime"
 
+	"github.com/gravitational/gravity/lib/builder/diskimage"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/utils"
@@ -48,17 +49,33 @@ func Build(ctx context.Context, builder *Builder) error {
 		}
 	}
 
+	var steps int
 	switch builder.Manifest.Kind {
 	case schema.KindBundle, schema.KindCluster:
-		builder.Config.Progress = utils.NewProgress(ctx, "Build",
-			clusterBuildSteps, builder.Config.Silent)
+		steps = clusterBuildSteps
 	case schema.KindApplication:
-		builder.Config.Progress = utils.NewProgress(ctx, "Build",
-			appBuildSteps, builder.Config.Silent)
+		steps = appBuildSteps
 	default:
 		return trace.BadParameter("unknown manifest kind %q",
 			builder.Manifest.Kind)
 	}
+	if builder.Upstream != "" {
+		steps++
+	}
+	if builder.DiskImage != nil {
+		if builder.Manifest.Kind == schema.KindApplication {
+			return trace.BadParameter("disk images can only be built for cluster images")
+		}
+		steps++
+	}
+	builder.Config.Progress = utils.NewProgress(ctx, "Build", steps, builder.Config.Silent)
+
+	if builder.Upstream != "" {
+		builder.NextStep("Checking vendored resources against upstream")
+		if err := builder.CheckUpstream(ctx); err != nil {
+			return trace.Wrap(err)
+		}
+	}
 
 	switch builder.Manifest.Kind {
 	case schema.KindBundle, schema.KindCluster:
@@ -111,6 +128,21 @@ func Build(ctx context.Context, builder *Builder) error {
 		return trace.Wrap(err)
 	}
 
+	if builder.DiskImage != nil {
+		builder.NextStep("Building %v disk image", builder.DiskImage.Format)
+		config := *builder.DiskImage
+		config.InstallerPath = builder.OutPath
+		image, err := diskimage.Build(ctx, config)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if image.ImageID != "" {
+			builder.PrintInfo("Registered AMI %v", image.ImageID)
+		} else {
+			builder.PrintInfo("Saved disk image as %v", image.Path)
+		}
+	}
+
 	return nil
 }
 
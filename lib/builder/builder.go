@@ -30,11 +30,13 @@ import (
 	"github.com/gravitational/gravity/lib/app"
 	"github.com/gravitational/gravity/lib/app/service"
 	blobfs "github.com/gravitational/gravity/lib/blob/fs"
+	"github.com/gravitational/gravity/lib/builder/diskimage"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/localenv/credentials"
+	"github.com/gravitational/gravity/lib/ops/resources"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/pack/layerpack"
 	"github.com/gravitational/gravity/lib/pack/localpack"
@@ -76,6 +78,12 @@ type Config struct {
 	SkipVersionCheck bool
 	// VendorReq combines vendoring options
 	VendorReq service.VendorRequest
+	// Upstream optionally specifies how to check vendored third-party
+	// resources against their upstream sources
+	Upstream UpstreamMode
+	// DiskImage optionally configures a bootable disk image
+	// to build with the cluster image preinstalled
+	DiskImage *diskimage.Config
 	// Generator is used to generate installer
 	Generator Generator
 	// NewSyncer is used to initialize package cache syncer for the builder
@@ -188,6 +196,9 @@ func New(config Config) (*Builder, error) {
 				trace.Unwrap(err)) // show original parsing error
 		}
 	}
+	if _, err := resources.DefaultResources(*manifest); err != nil {
+		return nil, trace.Wrap(err)
+	}
 	b := &Builder{
 		Config:   config,
 		Manifest: *manifest,
@@ -0,0 +1,194 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskimage
+
+import (
+	"context"
+	"os"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// AMIConfig defines the AMI registration parameters
+type AMIConfig struct {
+	// Name is the name of the AMI
+	Name string
+	// Description is the optional AMI description
+	Description string
+	// Region is the AWS region to register the AMI in
+	Region string
+	// Bucket is the S3 bucket the disk image is uploaded to for import
+	Bucket string
+	// RoleName is the name of the VM Import service role
+	RoleName string
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (c *AMIConfig) CheckAndSetDefaults() error {
+	if c.Name == "" {
+		return trace.BadParameter("AMI name is required")
+	}
+	if c.Bucket == "" {
+		return trace.BadParameter("S3 bucket to import the AMI from is required")
+	}
+	if c.Region == "" {
+		c.Region = defaults.AWSRegion
+	}
+	if c.RoleName == "" {
+		c.RoleName = defaults.AWSVMImportRole
+	}
+	return nil
+}
+
+// importAMI uploads the raw disk image at path to S3, imports it
+// as an EBS snapshot and registers an AMI backed by the snapshot.
+// Returns the ID of the registered AMI
+func importAMI(ctx context.Context, path string, config AMIConfig, logger logrus.FieldLogger) (imageID string, err error) {
+	session, err := session.NewSession(&aws.Config{
+		Region: aws.String(config.Region),
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	key := config.Name + FormatRaw.Extension()
+	logger.Infof("Uploading disk image to s3://%v/%v.", config.Bucket, key)
+	if err := uploadImage(ctx, session, path, config.Bucket, key); err != nil {
+		return "", trace.Wrap(err)
+	}
+	defer func() {
+		_, err := s3.New(session).DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(config.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			logger.WithError(err).Warnf("Failed to remove s3://%v/%v.", config.Bucket, key)
+		}
+	}()
+
+	client := ec2.New(session)
+	logger.Info("Importing disk image as a snapshot.")
+	task, err := client.ImportSnapshotWithContext(ctx, &ec2.ImportSnapshotInput{
+		Description: aws.String(config.Name),
+		RoleName:    aws.String(config.RoleName),
+		DiskContainer: &ec2.SnapshotDiskContainer{
+			Format: aws.String(string(FormatRaw)),
+			UserBucket: &ec2.UserBucket{
+				S3Bucket: aws.String(config.Bucket),
+				S3Key:    aws.String(key),
+			},
+		},
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	snapshotID, err := waitSnapshotImported(ctx, client, aws.StringValue(task.ImportTaskId), logger)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	logger.Infof("Registering AMI %v from snapshot %v.", config.Name, snapshotID)
+	image, err := client.RegisterImageWithContext(ctx, &ec2.RegisterImageInput{
+		Name:               aws.String(config.Name),
+		Description:        aws.String(config.Description),
+		Architecture:       aws.String(ec2.ArchitectureValuesX8664),
+		VirtualizationType: aws.String(amiVirtualizationType),
+		EnaSupport:         aws.Bool(true),
+		RootDeviceName:     aws.String(amiRootDevice),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{{
+			DeviceName: aws.String(amiRootDevice),
+			Ebs: &ec2.EbsBlockDevice{
+				SnapshotId:          aws.String(snapshotID),
+				DeleteOnTermination: aws.Bool(true),
+				VolumeType:          aws.String(ec2.VolumeTypeGp2),
+			},
+		}},
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return aws.StringValue(image.ImageId), nil
+}
+
+func uploadImage(ctx context.Context, session *session.Session, path, bucket, key string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	_, err = s3manager.NewUploader(session).UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	})
+	return trace.Wrap(err)
+}
+
+// waitSnapshotImported waits for the snapshot import task with the specified
+// ID to complete and returns the ID of the imported snapshot
+func waitSnapshotImported(ctx context.Context, client *ec2.EC2, taskID string, logger logrus.FieldLogger) (snapshotID string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaults.AWSImportTimeout)
+	defer cancel()
+	interval := backoff.NewConstantBackOff(defaults.AWSImportPollInterval)
+	err = utils.RetryWithInterval(ctx, interval, func() error {
+		out, err := client.DescribeImportSnapshotTasksWithContext(ctx, &ec2.DescribeImportSnapshotTasksInput{
+			ImportTaskIds: []*string{aws.String(taskID)},
+		})
+		if err != nil {
+			return &backoff.PermanentError{Err: trace.Wrap(err)}
+		}
+		if len(out.ImportSnapshotTasks) == 0 || out.ImportSnapshotTasks[0].SnapshotTaskDetail == nil {
+			return &backoff.PermanentError{Err: trace.NotFound("snapshot import task %v not found", taskID)}
+		}
+		detail := out.ImportSnapshotTasks[0].SnapshotTaskDetail
+		switch status := aws.StringValue(detail.Status); status {
+		case importStatusCompleted:
+			snapshotID = aws.StringValue(detail.SnapshotId)
+			return nil
+		case importStatusDeleting, importStatusDeleted:
+			return &backoff.PermanentError{Err: trace.BadParameter("snapshot import task %v failed: %v",
+				taskID, aws.StringValue(detail.StatusMessage))}
+		default:
+			logger.Infof("Snapshot import is %v (%v%%).", status, aws.StringValue(detail.Progress))
+			return trace.CompareFailed("snapshot import is %v", status)
+		}
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return snapshotID, nil
+}
+
+const (
+	// amiRootDevice is the name of the AMI root device
+	amiRootDevice = "/dev/sda1"
+	// amiVirtualizationType is the AMI virtualization type
+	amiVirtualizationType = "hvm"
+
+	importStatusCompleted = "completed"
+	importStatusDeleting  = "deleting"
+	importStatusDeleted   = "deleted"
+)
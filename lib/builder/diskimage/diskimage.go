@@ -0,0 +1,284 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package diskimage builds bootable disk images with a cluster image
+// preinstalled.
+//
+// A disk image is built from a base operating system cloud image: the cluster
+// image tarball is copied into the disk image along with a systemd service
+// that installs the cluster when the machine boots for the first time.
+// Depending on the first boot mode, the service either starts the installation
+// right away or waits for the installation flags to be provided, for example
+// with cloud-init user data.
+//
+// Images are built with qemu-img and virt-customize (libguestfs), which
+// have to be available on the build machine. AMIs are created by importing
+// the raw disk image as an EBS snapshot.
+package diskimage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// Config defines the disk image build configuration
+type Config struct {
+	// InstallerPath is the path to the cluster image tarball to preinstall
+	InstallerPath string
+	// BaseImage is the path to the base operating system disk image
+	BaseImage string
+	// Format is the format of the resulting disk image
+	Format Format
+	// OutPath is the path to the resulting disk image.
+	// Not used for AMIs
+	OutPath string
+	// Size is the optional virtual size of the disk image, e.g. 50G.
+	// The base image size is used if unspecified
+	Size string
+	// FirstBoot defines how the cluster is installed on first boot
+	FirstBoot FirstBootMode
+	// InstallFlags lists additional flags for 'gravity install'
+	InstallFlags []string
+	// AMI defines the AMI registration parameters for the AMI format
+	AMI AMIConfig
+	// Runner executes the image tools
+	Runner utils.CommandRunner
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (c *Config) CheckAndSetDefaults() error {
+	if c.InstallerPath == "" {
+		return trace.BadParameter("missing InstallerPath")
+	}
+	if c.BaseImage == "" {
+		return trace.BadParameter("base operating system image is required to build a disk image")
+	}
+	if err := c.Format.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if c.FirstBoot == "" {
+		c.FirstBoot = FirstBootConfigure
+	}
+	if err := c.FirstBoot.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if c.Format == FormatAMI {
+		if err := c.AMI.CheckAndSetDefaults(); err != nil {
+			return trace.Wrap(err)
+		}
+	} else if c.OutPath == "" {
+		c.OutPath = strings.TrimSuffix(c.InstallerPath, filepath.Ext(c.InstallerPath)) +
+			c.Format.Extension()
+	}
+	if c.Runner == nil {
+		c.Runner = utils.Runner
+	}
+	if c.FieldLogger == nil {
+		c.FieldLogger = logrus.WithField(trace.Component, "diskimage")
+	}
+	return nil
+}
+
+// Format is the disk image format
+type Format string
+
+// Check makes sure the format is supported
+func (r Format) Check() error {
+	switch r {
+	case FormatRaw, FormatQcow2, FormatAMI:
+		return nil
+	}
+	return trace.BadParameter("unsupported disk image format %q, supported formats are: %v",
+		r, strings.Join(Formats, ", "))
+}
+
+// Extension returns the file extension of the disk image in this format
+func (r Format) Extension() string {
+	if r == FormatQcow2 {
+		return ".qcow2"
+	}
+	return ".img"
+}
+
+// diskFormat returns the qemu-img format of the disk image file
+func (r Format) diskFormat() string {
+	if r == FormatQcow2 {
+		return string(FormatQcow2)
+	}
+	// AMIs are imported from raw disk images
+	return string(FormatRaw)
+}
+
+// FirstBootMode defines how the cluster is installed on first boot
+type FirstBootMode string
+
+// Check makes sure the first boot mode is supported
+func (r FirstBootMode) Check() error {
+	switch r {
+	case FirstBootInstall, FirstBootConfigure:
+		return nil
+	}
+	return trace.BadParameter("unsupported first boot mode %q, supported modes are: %v",
+		r, strings.Join(FirstBootModes, ", "))
+}
+
+// Result describes the built disk image
+type Result struct {
+	// Path is the path to the disk image file for file-based formats
+	Path string
+	// ImageID is the ID of the registered AMI for the AMI format
+	ImageID string
+}
+
+// Build builds a bootable disk image with the cluster image preinstalled
+func Build(ctx context.Context, config Config) (*Result, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := checkTools(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	tempDir, err := ioutil.TempDir("", "diskimage")
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	diskPath := config.OutPath
+	if config.Format == FormatAMI {
+		diskPath = filepath.Join(tempDir, "disk.img")
+	}
+	config.Infof("Converting base image %v.", config.BaseImage)
+	err = config.run(ctx, "qemu-img", "convert", "-O", config.Format.diskFormat(),
+		config.BaseImage, diskPath)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to convert base image %v", config.BaseImage)
+	}
+	if config.Size != "" {
+		err = config.run(ctx, "qemu-img", "resize", "-f", config.Format.diskFormat(),
+			diskPath, config.Size)
+		if err != nil {
+			return nil, trace.Wrap(err, "failed to resize disk image to %v", config.Size)
+		}
+	}
+	args, err := customizeArgs(tempDir, diskPath, config)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	config.Info("Copying the cluster image into the disk image.")
+	if err := config.run(ctx, args...); err != nil {
+		return nil, trace.Wrap(err, "failed to customize disk image")
+	}
+	if config.Format != FormatAMI {
+		return &Result{Path: diskPath}, nil
+	}
+	imageID, err := importAMI(ctx, diskPath, config.AMI, config.FieldLogger)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Result{ImageID: imageID}, nil
+}
+
+// customizeArgs returns the virt-customize command that preinstalls the
+// cluster image into the disk image at diskPath.
+// The first boot files are generated in dir
+func customizeArgs(dir, diskPath string, config Config) ([]string, error) {
+	scriptPath := filepath.Join(dir, firstBootScriptName)
+	err := writeTemplate(scriptPath, firstBootScriptTemplate, firstBootParams(config))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	unitPath := filepath.Join(dir, firstBootServiceName)
+	err = writeTemplate(unitPath, firstBootUnitTemplate, firstBootParams(config))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return []string{
+		"virt-customize", "-a", diskPath,
+		"--mkdir", InstallerDir,
+		"--upload", fmt.Sprintf("%v:%v", config.InstallerPath, installerTarball),
+		"--upload", fmt.Sprintf("%v:%v", scriptPath, firstBootScriptPath),
+		"--chmod", fmt.Sprintf("0755:%v", firstBootScriptPath),
+		"--upload", fmt.Sprintf("%v:%v", unitPath, firstBootUnitPath),
+		"--run-command", fmt.Sprintf("systemctl enable %v", firstBootServiceName),
+	}, nil
+}
+
+func (c Config) run(ctx context.Context, args ...string) error {
+	c.WithField("args", args).Debug("Run command.")
+	var out bytes.Buffer
+	if err := c.Runner.RunStream(ctx, &out, args...); err != nil {
+		return trace.Wrap(err, "%v: %s", args[0], out.String())
+	}
+	return nil
+}
+
+// checkTools makes sure the tools required to build disk images are available
+func checkTools() error {
+	for _, tool := range []string{"qemu-img", "virt-customize"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return trace.NotFound("%v is required to build disk images, "+
+				"install qemu-utils and libguestfs-tools", tool)
+		}
+	}
+	return nil
+}
+
+const (
+	// FormatRaw is the raw disk image format
+	FormatRaw Format = "raw"
+	// FormatQcow2 is the QEMU copy-on-write disk image format
+	FormatQcow2 Format = "qcow2"
+	// FormatAMI builds the disk image and registers it as an Amazon Machine Image
+	FormatAMI Format = "ami"
+
+	// FirstBootInstall installs the cluster on first boot right away
+	FirstBootInstall FirstBootMode = "install"
+	// FirstBootConfigure waits for the installation flags to be provided
+	// in the configuration file before installing the cluster on first boot
+	FirstBootConfigure FirstBootMode = "configure"
+
+	// InstallerDir is the directory with the cluster image inside the disk image
+	InstallerDir = "/var/lib/gravity-image"
+	// ConfigPath is the path to the first boot configuration file inside the
+	// disk image. Every line of the file is a single 'gravity install' flag
+	ConfigPath = "/etc/gravity/install.conf"
+
+	installerTarball     = InstallerDir + "/installer.tar"
+	firstBootScriptName  = "gravity-firstboot"
+	firstBootScriptPath  = "/usr/local/bin/" + firstBootScriptName
+	firstBootServiceName = firstBootScriptName + ".service"
+	firstBootUnitPath    = "/etc/systemd/system/" + firstBootServiceName
+)
+
+// Formats lists supported disk image formats
+var Formats = []string{string(FormatRaw), string(FormatQcow2), string(FormatAMI)}
+
+// FirstBootModes lists supported first boot modes
+var FirstBootModes = []string{string(FirstBootInstall), string(FirstBootConfigure)}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskimage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+func TestDiskImage(t *testing.T) { check.TestingT(t) }
+
+type DiskImageSuite struct{}
+
+var _ = check.Suite(&DiskImageSuite{})
+
+func (s *DiskImageSuite) TestConfigDefaults(c *check.C) {
+	config := Config{
+		InstallerPath: "/build/cluster-1.0.0.tar",
+		BaseImage:     "/images/centos.qcow2",
+		Format:        FormatQcow2,
+	}
+	c.Assert(config.CheckAndSetDefaults(), check.IsNil)
+	c.Assert(config.OutPath, check.Equals, "/build/cluster-1.0.0.qcow2")
+	c.Assert(config.FirstBoot, check.Equals, FirstBootConfigure)
+
+	config = Config{
+		InstallerPath: "/build/cluster-1.0.0.tar",
+		BaseImage:     "/images/centos.qcow2",
+		Format:        FormatAMI,
+		AMI:           AMIConfig{Name: "cluster", Bucket: "images"},
+	}
+	c.Assert(config.CheckAndSetDefaults(), check.IsNil)
+	c.Assert(config.OutPath, check.Equals, "")
+	c.Assert(config.AMI.Region, check.Equals, defaults.AWSRegion)
+	c.Assert(config.AMI.RoleName, check.Equals, defaults.AWSVMImportRole)
+}
+
+func (s *DiskImageSuite) TestValidatesConfig(c *check.C) {
+	for _, config := range []Config{
+		{InstallerPath: "cluster.tar", Format: FormatRaw},
+		{InstallerPath: "cluster.tar", BaseImage: "base.img", Format: "vmdk"},
+		{InstallerPath: "cluster.tar", BaseImage: "base.img", Format: FormatRaw, FirstBoot: "later"},
+		{InstallerPath: "cluster.tar", BaseImage: "base.img", Format: FormatAMI},
+	} {
+		err := config.CheckAndSetDefaults()
+		c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", config))
+	}
+}
+
+func (s *DiskImageSuite) TestCustomizesImage(c *check.C) {
+	dir := c.MkDir()
+	config := Config{
+		InstallerPath: "/build/cluster.tar",
+		FirstBoot:     FirstBootInstall,
+		InstallFlags:  []string{"--cluster=it's-a-cluster", "--flavor=small"},
+	}
+	args, err := customizeArgs(dir, "/build/cluster.img", config)
+	c.Assert(err, check.IsNil)
+	c.Assert(args, check.DeepEquals, []string{
+		"virt-customize", "-a", "/build/cluster.img",
+		"--mkdir", "/var/lib/gravity-image",
+		"--upload", "/build/cluster.tar:/var/lib/gravity-image/installer.tar",
+		"--upload", filepath.Join(dir, "gravity-firstboot") + ":/usr/local/bin/gravity-firstboot",
+		"--chmod", "0755:/usr/local/bin/gravity-firstboot",
+		"--upload", filepath.Join(dir, "gravity-firstboot.service") + ":/etc/systemd/system/gravity-firstboot.service",
+		"--run-command", "systemctl enable gravity-firstboot.service",
+	})
+
+	script, err := ioutil.ReadFile(filepath.Join(dir, "gravity-firstboot"))
+	c.Assert(err, check.IsNil)
+	c.Assert(string(script), check.Matches, `(?s).*FLAGS=\('--cluster=it'\\''s-a-cluster' '--flavor=small' \).*`)
+	c.Assert(string(script), check.Not(check.Matches), `(?s).*Waiting for the installation flags.*`)
+	c.Assert(string(script), check.Matches, `(?s).*growpart "\$disk" "\$partition".*`)
+	unit, err := ioutil.ReadFile(filepath.Join(dir, "gravity-firstboot.service"))
+	c.Assert(err, check.IsNil)
+	c.Assert(string(unit), check.Matches, `(?s).*\nType=oneshot\n.*`)
+
+	config.FirstBoot = FirstBootConfigure
+	_, err = customizeArgs(dir, "/build/cluster.img", config)
+	c.Assert(err, check.IsNil)
+	script, err = ioutil.ReadFile(filepath.Join(dir, "gravity-firstboot"))
+	c.Assert(err, check.IsNil)
+	c.Assert(string(script), check.Matches, `(?s).*while \[ ! -f /etc/gravity/install.conf \].*`)
+	unit, err = ioutil.ReadFile(filepath.Join(dir, "gravity-firstboot.service"))
+	c.Assert(err, check.IsNil)
+	c.Assert(string(unit), check.Matches, `(?s).*\nType=simple\n.*`)
+}
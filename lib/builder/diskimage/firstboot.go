@@ -0,0 +1,144 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskimage
+
+import (
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/gravitational/trace"
+)
+
+// firstBootTemplateParams defines the parameters of the first boot files
+type firstBootTemplateParams struct {
+	// Mode is the first boot mode
+	Mode FirstBootMode
+	// InstallFlags lists the flags baked into the image
+	InstallFlags []string
+	// InstallerDir is the directory with the cluster image
+	InstallerDir string
+	// Tarball is the path to the cluster image tarball
+	Tarball string
+	// ConfigPath is the path to the first boot configuration file
+	ConfigPath string
+	// ScriptPath is the path to the first boot script
+	ScriptPath string
+}
+
+func firstBootParams(config Config) firstBootTemplateParams {
+	return firstBootTemplateParams{
+		Mode:         config.FirstBoot,
+		InstallFlags: config.InstallFlags,
+		InstallerDir: InstallerDir,
+		Tarball:      installerTarball,
+		ConfigPath:   ConfigPath,
+		ScriptPath:   firstBootScriptPath,
+	}
+}
+
+func writeTemplate(path string, tmpl *template.Template, params firstBootTemplateParams) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaults.SharedExecutableMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	if err := tmpl.Execute(f, params); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(f.Close())
+}
+
+// shellQuote quotes s to be used as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+var firstBootScriptTemplate = template.Must(template.New("script").
+	Funcs(template.FuncMap{"quote": shellQuote}).
+	Parse(`#!/bin/bash
+# Installs the cluster image preinstalled in this disk image on first boot.
+set -euo pipefail
+
+MARKER={{.InstallerDir}}/.installed
+if [ -f "$MARKER" ]; then
+  exit 0
+fi
+
+# grow the root partition and filesystem to the size of the disk
+# which may have been resized when the image was built or launched
+grow_root() {
+  local device disk partition
+  device=$(findmnt -n -o SOURCE /)
+  disk=/dev/$(lsblk -n -o PKNAME "$device" | head -n 1)
+  partition=$(cat /sys/class/block/$(basename "$device")/partition)
+  if ! command -v growpart >/dev/null; then
+    echo "growpart is not available, not growing the root partition."
+    return
+  fi
+  # growpart exits with 1 if the partition cannot be grown any further
+  growpart "$disk" "$partition" || true
+  case $(findmnt -n -o FSTYPE /) in
+    xfs) xfs_growfs / ;;
+    ext*) resize2fs "$device" ;;
+    *) echo "Not growing the root filesystem of unsupported type." ;;
+  esac
+}
+grow_root || echo "Failed to grow the root filesystem."
+{{if eq .Mode "configure"}}
+echo "Waiting for the installation flags in {{.ConfigPath}}."
+while [ ! -f {{.ConfigPath}} ]; do
+  sleep 5
+done
+{{end}}
+FLAGS=({{range .InstallFlags}}{{quote .}} {{end}})
+if [ -f {{.ConfigPath}} ]; then
+  # every line of the configuration file is a single flag
+  while IFS= read -r line || [ -n "$line" ]; do
+    if [ -n "$line" ] && [ "${line:0:1}" != "#" ]; then
+      FLAGS+=("$line")
+    fi
+  done < {{.ConfigPath}}
+fi
+
+mkdir -p {{.InstallerDir}}/installer
+tar -xf {{.Tarball}} -C {{.InstallerDir}}/installer
+cd {{.InstallerDir}}/installer
+./gravity install "${FLAGS[@]}"
+touch "$MARKER"
+`))
+
+// firstBootUnitTemplate defines the first boot service. In configure mode
+// the service is not a oneshot one so that waiting for the configuration
+// file does not hold up multi-user.target
+var firstBootUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=Install the cluster image on first boot
+Wants=network-online.target
+After=network-online.target cloud-init.service
+ConditionPathExists=!{{.InstallerDir}}/.installed
+
+[Service]
+Type={{if eq .Mode "configure"}}simple{{else}}oneshot{{end}}
+ExecStart={{.ScriptPath}}
+TimeoutStartSec=0
+RemainAfterExit=yes
+
+[Install]
+WantedBy=multi-user.target
+`))
@@ -0,0 +1,210 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+
+	"github.com/ghodss/yaml"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// UpstreamMode defines how vendored third-party resources are checked
+// against their upstream sources during build
+type UpstreamMode string
+
+const (
+	// UpstreamCheck fails the build if any vendored resource differs from upstream
+	UpstreamCheck UpstreamMode = "check"
+	// UpstreamRefresh replaces the vendored resources that differ from upstream
+	UpstreamRefresh UpstreamMode = "refresh"
+)
+
+// UpstreamModes lists all supported upstream check modes
+var UpstreamModes = []string{string(UpstreamCheck), string(UpstreamRefresh)}
+
+// UpstreamFileName is the name of the file next to the manifest that lists
+// the upstream sources of the vendored third-party resources
+const UpstreamFileName = "upstream.yaml"
+
+// UpstreamSources lists the upstream sources of the third-party resources
+// (e.g. manifests or charts) vendored with the application:
+//
+//	sources:
+//	- path: resources/ingress.yaml
+//	  url: https://raw.githubusercontent.com/kubernetes/ingress-nginx/nginx-0.25.1/deploy/static/mandatory.yaml
+//	- path: charts/cert-manager-v0.9.1.tgz
+//	  url: https://charts.jetstack.io/charts/cert-manager-v0.9.1.tgz
+type UpstreamSources struct {
+	// Sources lists the vendored resources
+	Sources []UpstreamSource `json:"sources"`
+}
+
+// UpstreamSource describes a single vendored resource
+type UpstreamSource struct {
+	// Path is the path to the vendored copy relative to the manifest directory
+	Path string `json:"path"`
+	// URL is the upstream location of the resource
+	URL string `json:"url"`
+}
+
+// Check validates the source
+func (r UpstreamSource) Check() error {
+	if r.Path == "" {
+		return trace.BadParameter("upstream source path is required")
+	}
+	if filepath.IsAbs(r.Path) || strings.HasPrefix(filepath.Clean(r.Path), "..") {
+		return trace.BadParameter("upstream source path %q should be relative to the manifest directory", r.Path)
+	}
+	if r.URL == "" {
+		return trace.BadParameter("upstream source URL is required for %v", r.Path)
+	}
+	return nil
+}
+
+// ReadUpstreamSources reads the upstream sources file from the specified directory
+func ReadUpstreamSources(dir string) (*UpstreamSources, error) {
+	path := filepath.Join(dir, UpstreamFileName)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	var sources UpstreamSources
+	if err := yaml.Unmarshal(data, &sources); err != nil {
+		return nil, trace.Wrap(err, "failed to parse %v", path)
+	}
+	for _, source := range sources.Sources {
+		if err := source.Check(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return &sources, nil
+}
+
+// UpstreamCheckRequest describes a request to compare the vendored resources
+// against their upstream sources
+type UpstreamCheckRequest struct {
+	// Dir is the directory the source paths are relative to
+	Dir string
+	// Sources lists the vendored resources to check
+	Sources []UpstreamSource
+	// Mode specifies whether to fail or refresh on drift
+	Mode UpstreamMode
+	// Client is the HTTP client to fetch upstream resources with
+	Client *http.Client
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+}
+
+// CheckUpstream compares the vendored resources against their upstream sources.
+// In check mode, returns trace.CompareFailed listing all drifted resources.
+// In refresh mode, replaces the drifted resources with the upstream versions.
+// Returns the list of drifted resources
+func CheckUpstream(ctx context.Context, req UpstreamCheckRequest) (drifted []UpstreamSource, err error) {
+	for _, source := range req.Sources {
+		req.WithField("source", source).Debug("Check upstream.")
+		upstream, err := fetchUpstream(ctx, req.Client, source.URL)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		path := filepath.Join(req.Dir, source.Path)
+		vendored, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, trace.ConvertSystemError(err)
+		}
+		if bytes.Equal(vendored, upstream) {
+			continue
+		}
+		drifted = append(drifted, source)
+		if req.Mode != UpstreamRefresh {
+			continue
+		}
+		req.WithField("source", source).Info("Refresh vendored resource.")
+		if err := os.MkdirAll(filepath.Dir(path), defaults.SharedDirMask); err != nil {
+			return nil, trace.ConvertSystemError(err)
+		}
+		if err := ioutil.WriteFile(path, upstream, defaults.SharedReadMask); err != nil {
+			return nil, trace.ConvertSystemError(err)
+		}
+	}
+	if len(drifted) != 0 && req.Mode == UpstreamCheck {
+		paths := make([]string, 0, len(drifted))
+		for _, source := range drifted {
+			paths = append(paths, source.Path)
+		}
+		return drifted, trace.CompareFailed("vendored resources differ from upstream: %v. "+
+			"Use --upstream=%v to update them", strings.Join(paths, ", "), UpstreamRefresh)
+	}
+	return drifted, nil
+}
+
+func fetchUpstream(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, trace.ConnectionProblem(err, "failed to fetch %v", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, trace.BadParameter("failed to fetch %v: %v", url, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return data, nil
+}
+
+// CheckUpstream compares the vendored third-party resources listed in the
+// upstream sources file next to the manifest against their upstream versions
+func (b *Builder) CheckUpstream(ctx context.Context) error {
+	sources, err := ReadUpstreamSources(b.manifestDir)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("upstream check requires %v in %v",
+				UpstreamFileName, b.manifestDir)
+		}
+		return trace.Wrap(err)
+	}
+	drifted, err := CheckUpstream(ctx, UpstreamCheckRequest{
+		Dir:         b.manifestDir,
+		Sources:     sources.Sources,
+		Mode:        b.Upstream,
+		Client:      httplib.GetClient(b.Insecure),
+		FieldLogger: b.FieldLogger,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, source := range drifted {
+		b.PrintSubStep("Refreshed %v from %v", source.Path, source.URL)
+	}
+	return nil
+}
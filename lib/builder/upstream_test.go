@@ -0,0 +1,133 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	check "gopkg.in/check.v1"
+)
+
+type UpstreamSuite struct {
+	server *httptest.Server
+	dir    string
+}
+
+var _ = check.Suite(&UpstreamSuite{})
+
+func (s *UpstreamSuite) SetUpTest(c *check.C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ingress.yaml":
+			w.Write([]byte("ingress: v2"))
+		case "/cert-manager.yaml":
+			w.Write([]byte("cert-manager: v1"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	s.dir = c.MkDir()
+	writeFile(c, filepath.Join(s.dir, "resources", "ingress.yaml"), "ingress: v1")
+	writeFile(c, filepath.Join(s.dir, "resources", "cert-manager.yaml"), "cert-manager: v1")
+}
+
+func (s *UpstreamSuite) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+func (s *UpstreamSuite) TestFailsOnDrift(c *check.C) {
+	drifted, err := CheckUpstream(context.TODO(), s.request(UpstreamCheck))
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(drifted, check.DeepEquals, []UpstreamSource{s.sources()[0]})
+	c.Assert(readFile(c, filepath.Join(s.dir, "resources", "ingress.yaml")), check.Equals, "ingress: v1")
+}
+
+func (s *UpstreamSuite) TestRefreshesOnDrift(c *check.C) {
+	drifted, err := CheckUpstream(context.TODO(), s.request(UpstreamRefresh))
+	c.Assert(err, check.IsNil)
+	c.Assert(drifted, check.DeepEquals, []UpstreamSource{s.sources()[0]})
+	c.Assert(readFile(c, filepath.Join(s.dir, "resources", "ingress.yaml")), check.Equals, "ingress: v2")
+
+	drifted, err = CheckUpstream(context.TODO(), s.request(UpstreamCheck))
+	c.Assert(err, check.IsNil)
+	c.Assert(drifted, check.HasLen, 0)
+}
+
+func (s *UpstreamSuite) TestFailsIfUpstreamIsUnavailable(c *check.C) {
+	req := s.request(UpstreamRefresh)
+	req.Sources = append(req.Sources, UpstreamSource{
+		Path: "resources/missing.yaml",
+		URL:  s.server.URL + "/missing.yaml",
+	})
+	_, err := CheckUpstream(context.TODO(), req)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *UpstreamSuite) TestReadsSources(c *check.C) {
+	writeFile(c, filepath.Join(s.dir, UpstreamFileName), `sources:
+- path: resources/ingress.yaml
+  url: https://example.com/ingress.yaml
+`)
+	sources, err := ReadUpstreamSources(s.dir)
+	c.Assert(err, check.IsNil)
+	c.Assert(sources.Sources, check.DeepEquals, []UpstreamSource{{
+		Path: "resources/ingress.yaml",
+		URL:  "https://example.com/ingress.yaml",
+	}})
+
+	writeFile(c, filepath.Join(s.dir, UpstreamFileName), `sources:
+- path: ../ingress.yaml
+  url: https://example.com/ingress.yaml
+`)
+	_, err = ReadUpstreamSources(s.dir)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *UpstreamSuite) request(mode UpstreamMode) UpstreamCheckRequest {
+	return UpstreamCheckRequest{
+		Dir:         s.dir,
+		Sources:     s.sources(),
+		Mode:        mode,
+		Client:      s.server.Client(),
+		FieldLogger: logrus.WithField("test", "upstream"),
+	}
+}
+
+func (s *UpstreamSuite) sources() []UpstreamSource {
+	return []UpstreamSource{
+		{Path: "resources/ingress.yaml", URL: s.server.URL + "/ingress.yaml"},
+		{Path: "resources/cert-manager.yaml", URL: s.server.URL + "/cert-manager.yaml"},
+	}
+}
+
+func writeFile(c *check.C, path, data string) {
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte(data), 0644), check.IsNil)
+}
+
+func readFile(c *check.C, path string) string {
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	return string(data)
+}
@@ -84,6 +84,7 @@ func ValidateManifest(
 	failedProbes = append(failedProbes, failed...)
 
 	failedProbes = append(failedProbes, schema.ValidateKubelet(profile, manifest)...)
+	failedProbes = append(failedProbes, schema.ValidateCNI(manifest)...)
 	return failedProbes, trace.NewAggregate(errors...)
 }
 
@@ -146,7 +147,7 @@ func (r *LocalChecksResult) GetFailed() []*agentpb.Probe {
 
 // ValidateLocal runs checks on the local node and returns their outcome
 func ValidateLocal(ctx context.Context, req LocalChecksRequest) (*LocalChecksResult, error) {
-	if ifTestsDisabled() {
+	if IfTestsDisabled() {
 		log.Infof("Skipping local checks due to %v set.", constants.PreflightChecksOffEnvVar)
 		return &LocalChecksResult{}, nil
 	}
@@ -166,7 +167,9 @@ func ValidateLocal(ctx context.Context, req LocalChecksRequest) (*LocalChecksRes
 		return nil, trace.Wrap(err)
 	}
 
-	autofix.AutoloadModules(ctx, schema.DefaultKernelModules, req.Progress)
+	modules := append(append([]monitoring.ModuleRequest{}, schema.DefaultKernelModules...),
+		req.Manifest.CNIRequirements().KernelModules...)
+	autofix.AutoloadModules(ctx, modules, req.Progress)
 
 	dockerConfig := DockerConfigFromSchemaValue(req.Manifest.SystemDocker())
 	OverrideDockerConfig(&dockerConfig, req.Docker)
@@ -269,6 +272,9 @@ type Config struct {
 	Requirements map[string]Requirements
 	// Features allows to turn certain checks off.
 	Features
+	// OverlayMTU is the configured overlay network MTU.
+	// If unspecified, the path MTU is only checked against the minimum
+	OverlayMTU int
 }
 
 // check validates the checker configuration.
@@ -296,6 +302,9 @@ type Features struct {
 	// TestEtcdDisk specifies whether the device where etcd data resides
 	// should be performance-tested.
 	TestEtcdDisk bool
+	// TestPathMTU specifies whether the path MTU between nodes
+	// should be tested.
+	TestPathMTU bool
 }
 
 // String return textual representation of this server object
@@ -313,7 +322,7 @@ type Server struct {
 
 // Run runs a full set of checks on the servers specified in r.servers
 func (r *checker) Run(ctx context.Context) error {
-	if ifTestsDisabled() {
+	if IfTestsDisabled() {
 		log.Infof("Skipping checks due to %q set.",
 			constants.PreflightChecksOffEnvVar)
 		return nil
@@ -334,7 +343,7 @@ func (r *checker) Run(ctx context.Context) error {
 
 // CheckNode executes checks for the provided individual server.
 func (r *checker) CheckNode(ctx context.Context, server Server) error {
-	if ifTestsDisabled() {
+	if IfTestsDisabled() {
 		log.Infof("Skipping single-node checks due to %q set.",
 			constants.PreflightChecksOffEnvVar)
 		return nil
@@ -400,7 +409,7 @@ func (r *checker) CheckNode(ctx context.Context, server Server) error {
 
 // CheckNodes executes checks that take all provided servers into account.
 func (r *checker) CheckNodes(ctx context.Context, servers []Server) error {
-	if ifTestsDisabled() {
+	if IfTestsDisabled() {
 		log.Infof("Skipping multi-node checks due to %q set.",
 			constants.PreflightChecksOffEnvVar)
 		return nil
@@ -438,6 +447,13 @@ func (r *checker) CheckNodes(ctx context.Context, servers []Server) error {
 		}
 	}
 
+	if r.TestPathMTU {
+		err = r.checkPathMTU(ctx, servers)
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
 	return trace.NewAggregate(errors...)
 }
 
@@ -1015,7 +1031,9 @@ func formatProbe(probe agentpb.Probe) string {
 	return probe.Detail
 }
 
-func ifTestsDisabled() bool {
+// IfTestsDisabled returns true if the preflight checks have been
+// disabled with the environment variable
+func IfTestsDisabled() bool {
 	envVar := os.Getenv(constants.PreflightChecksOffEnvVar)
 	if envVar == "" {
 		return false
@@ -33,6 +33,10 @@ func (r *checker) checkEtcdDisk(ctx context.Context, server Server) error {
 	// Test file should reside where etcd data will be.
 	testPath := state.InEtcdDir(server.ServerInfo.StateDir, testFile)
 	res, err := r.Remote.CheckDisks(ctx, server.AdvertiseIP, fioEtcdJob(testPath))
+	if trace.IsNotImplemented(err) {
+		log.Warnf("Skipping etcd disk check on %v: %v.", server.Hostname, err)
+		return nil
+	}
 	if err != nil {
 		return trace.Wrap(err)
 	}
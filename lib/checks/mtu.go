@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checks
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// ProbePathMTU determines the path MTU to the node given with addr
+// by sending non-fragmentable ICMP echo requests of varying size
+// using the specified runner
+func ProbePathMTU(ctx context.Context, runner utils.CommandRunner, addr string) (int, error) {
+	if !probeMTU(ctx, runner, addr, defaults.MinProbeMTU) {
+		return 0, trace.ConnectionProblem(nil,
+			"failed to reach %v with packets of %v bytes", addr, defaults.MinProbeMTU)
+	}
+	// Binary search for the largest MTU that does not require fragmentation
+	low, high := defaults.MinProbeMTU, defaults.MaxPathMTU
+	for low < high {
+		mtu := (low + high + 1) / 2
+		if probeMTU(ctx, runner, addr, mtu) {
+			low = mtu
+		} else {
+			high = mtu - 1
+		}
+	}
+	return low, nil
+}
+
+// DetectPathMTU determines the smallest path MTU between the specified servers.
+// Each server probes the next one in the list, with the last one probing the first
+func DetectPathMTU(ctx context.Context, remote Remote, servers []Server) (int, error) {
+	if len(servers) < 2 {
+		return 0, trace.BadParameter("at least 2 servers are required to detect path MTU")
+	}
+	var result int
+	for i, server := range servers {
+		peer := servers[(i+1)%len(servers)]
+		mtu, err := ProbePathMTU(ctx, &serverRemote{server, remote}, peer.AdvertiseIP)
+		if err != nil {
+			return 0, trace.Wrap(err, "failed to detect path MTU from %v to %v", server, peer)
+		}
+		log.WithField("mtu", mtu).Infof("Path MTU from %v to %v.", server, peer)
+		if result == 0 || mtu < result {
+			result = mtu
+		}
+	}
+	return result, nil
+}
+
+// OverlayMTU returns the overlay network MTU suitable for the given path MTU
+func OverlayMTU(pathMTU int) int {
+	return pathMTU - defaults.VxlanOverhead
+}
+
+// ValidatePathMTU verifies that the overlay network can operate with
+// the specified path MTU between cluster nodes.
+// If overlayMTU is not zero, it also verifies that packets of the configured
+// overlay network MTU will not be fragmented
+func ValidatePathMTU(pathMTU, overlayMTU int) error {
+	if pathMTU < defaults.MinPathMTU {
+		return trace.BadParameter("path MTU between nodes is %v bytes, "+
+			"at least %v bytes are required for the overlay network",
+			pathMTU, defaults.MinPathMTU)
+	}
+	if overlayMTU != 0 && OverlayMTU(pathMTU) < overlayMTU {
+		return trace.BadParameter("overlay network MTU %v exceeds the maximum of %v bytes "+
+			"supported by the path MTU %v between nodes, which would cause packet fragmentation",
+			overlayMTU, OverlayMTU(pathMTU), pathMTU)
+	}
+	return nil
+}
+
+// checkPathMTU verifies the path MTU between the specified servers
+func (r *checker) checkPathMTU(ctx context.Context, servers []Server) error {
+	mtu, err := DetectPathMTU(ctx, r.Remote, servers)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(ValidatePathMTU(mtu, r.OverlayMTU))
+}
+
+// probeMTU returns true if a packet of the specified size can be sent
+// to addr without fragmentation
+func probeMTU(ctx context.Context, runner utils.CommandRunner, addr string, mtu int) bool {
+	var out bytes.Buffer
+	err := runner.RunStream(ctx, &out, "ping", "-c", "1", "-W", "1", "-M", "do",
+		"-s", strconv.Itoa(mtu-icmpHeadersSize), addr)
+	if err != nil {
+		log.WithError(err).WithField("mtu", mtu).Debugf("Probe failed: %s.", out.Bytes())
+		return false
+	}
+	return true
+}
+
+// icmpHeadersSize is the combined size of the IP and ICMP headers
+// that ping adds to the payload
+const icmpHeadersSize = 28
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checks
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type MTUSuite struct{}
+
+var _ = check.Suite(&MTUSuite{})
+
+func (s *MTUSuite) TestProbesPathMTU(c *check.C) {
+	mtu, err := ProbePathMTU(context.TODO(), pathWithMTU(1400), "10.0.0.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(mtu, check.Equals, 1400)
+
+	mtu, err = ProbePathMTU(context.TODO(), pathWithMTU(9001), "10.0.0.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(mtu, check.Equals, 9000)
+
+	_, err = ProbePathMTU(context.TODO(), pathWithMTU(0), "10.0.0.1")
+	c.Assert(trace.IsConnectionProblem(err), check.Equals, true)
+}
+
+func (s *MTUSuite) TestValidatesPathMTU(c *check.C) {
+	var testCases = []struct {
+		pathMTU    int
+		overlayMTU int
+		valid      bool
+		comment    string
+	}{
+		{pathMTU: 1500, valid: true, comment: "default MTU"},
+		{pathMTU: 1000, valid: false, comment: "path MTU below minimum"},
+		{pathMTU: 1500, overlayMTU: 1450, valid: true, comment: "overlay MTU fits"},
+		{pathMTU: 1450, overlayMTU: 1450, valid: false, comment: "overlay MTU causes fragmentation"},
+	}
+	for _, tc := range testCases {
+		err := ValidatePathMTU(tc.pathMTU, tc.overlayMTU)
+		if tc.valid {
+			c.Assert(err, check.IsNil, check.Commentf(tc.comment))
+		} else {
+			c.Assert(err, check.NotNil, check.Commentf(tc.comment))
+		}
+	}
+}
+
+// pathWithMTU returns a command runner that simulates ping
+// over a network path with the specified MTU
+func pathWithMTU(mtu int) utils.CommandRunner {
+	return utils.CommandRunnerFunc(func(ctx context.Context, w io.Writer, args ...string) error {
+		size, err := strconv.Atoi(args[len(args)-2])
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if size+icmpHeadersSize > mtu {
+			return trace.BadParameter("message too long")
+		}
+		return nil
+	})
+}
@@ -65,6 +65,9 @@ func RequirementsFromManifest(manifest schema.Manifest) (map[string]Requirements
 		if err != nil {
 			return nil, trace.Wrap(err)
 		}
+		cni := manifest.CNIRequirements()
+		tcp = append(tcp, cni.TCP...)
+		udp = append(udp, cni.UDP...)
 		req := Requirements{
 			CPU:     &manifest.NodeProfiles[i].Requirements.CPU,
 			RAM:     &manifest.NodeProfiles[i].Requirements.RAM,
@@ -0,0 +1,145 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checks
+
+import (
+	"context"
+
+	"github.com/gravitational/satellite/agent/proto/agentpb"
+	"github.com/gravitational/satellite/monitoring"
+	"github.com/gravitational/trace"
+)
+
+// RunLocal executes the local preflight checks for the node profile specified
+// in the request and returns the structured results.
+//
+// RunLocal is the entry point for external tooling (e.g. CI pipelines) that
+// validates hosts before installation
+func RunLocal(ctx context.Context, req LocalChecksRequest) (*Results, error) {
+	result, err := ValidateLocal(ctx, req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return NewResults(req.Role, *result), nil
+}
+
+// NewResults converts the outcome of local checks into structured results
+func NewResults(profile string, result LocalChecksResult) *Results {
+	results := &Results{
+		Profile: profile,
+		Passed:  len(result.GetFailed()) == 0,
+		Results: []CheckResult{},
+	}
+	for _, probe := range result.Failed {
+		results.Results = append(results.Results, newResult(*probe, StatusFailed, false))
+	}
+	for _, probe := range result.Fixable {
+		results.Results = append(results.Results, newResult(*probe, StatusFailed, true))
+	}
+	for _, probe := range result.Fixed {
+		results.Results = append(results.Results, newResult(*probe, StatusFixed, true))
+	}
+	return results
+}
+
+// Results describes the outcome of preflight checks on a node
+type Results struct {
+	// Profile is the node profile the node has been checked against
+	Profile string `json:"profile"`
+	// Passed is whether all checks have passed
+	Passed bool `json:"passed"`
+	// Results lists failed and auto-fixed checks
+	Results []CheckResult `json:"results"`
+}
+
+// CheckResult describes a single failed or auto-fixed check
+type CheckResult struct {
+	// ProbeID identifies the check that produced the result
+	ProbeID string `json:"probe_id"`
+	// Severity is the result severity: critical or warning
+	Severity string `json:"severity"`
+	// Status is the check status: failed or fixed
+	Status string `json:"status"`
+	// Message describes the problem
+	Message string `json:"message"`
+	// Remediation describes how to fix the problem
+	Remediation string `json:"remediation,omitempty"`
+	// AutoFixable is whether the problem can be fixed with --autofix
+	AutoFixable bool `json:"auto_fixable"`
+}
+
+const (
+	// StatusFailed is the status of a failed check
+	StatusFailed = "failed"
+	// StatusFixed is the status of a failed check that has been auto-fixed
+	StatusFixed = "fixed"
+
+	// SeverityCritical is the severity of a check that blocks the installation
+	SeverityCritical = "critical"
+	// SeverityWarning is the severity of a check that does not block the installation
+	SeverityWarning = "warning"
+)
+
+func newResult(probe agentpb.Probe, status string, autoFixable bool) CheckResult {
+	severity := SeverityCritical
+	if probe.Severity == agentpb.Probe_Warning {
+		severity = SeverityWarning
+	}
+	remediation := Remediation(probe.Checker)
+	if autoFixable && status == StatusFailed {
+		remediation = "Run the check with --autofix to fix the problem automatically. " + remediation
+	}
+	return CheckResult{
+		ProbeID:     probe.Checker,
+		Severity:    severity,
+		Status:      status,
+		Message:     formatProbe(probe),
+		Remediation: remediation,
+		AutoFixable: autoFixable,
+	}
+}
+
+// Remediation returns the hint how to fix the failure of the probe
+// produced by the specified checker.
+// The hints are shared by the preflight checks and the cluster health probes
+func Remediation(checker string) string {
+	return remediations[checker]
+}
+
+// remediations maps checker IDs to the description of how to fix the problem
+var remediations = map[string]string{
+	"cpu-ram":                        "Make sure the node meets the CPU and RAM requirements of the node profile.",
+	"process-checker":                "Stop the conflicting process listed in the error.",
+	"port-checker":                   "Free the port listed in the error or stop the process using it.",
+	"os-checker":                     "Use one of the operating system distributions supported by the cluster image.",
+	monitoring.KernelModuleCheckerID: "Load the missing kernel module with modprobe and add it to /etc/modules-load.d.",
+	"cgroup-mounts":                  "Mount the missing cgroup controllers.",
+	"dtype-check":                    "Use a filesystem formatted with d_type support (for XFS, ftype=1) for the state directory.",
+	"ping-checker":                   "Check the network latency between the nodes.",
+	"aws":                            "Assign an IAM instance profile to the node.",
+	"io-check":                       "Check the write performance of the disk with the state directory or use a faster disk.",
+	monitoring.FileHandleAllocatableCheckerID: "Raise the fs.file-max kernel parameter.",
+	monitoring.IPForwardCheckerID:             "Enable IPv4 forwarding with 'sysctl -w net.ipv4.ip_forward=1' and persist it in /etc/sysctl.d.",
+	monitoring.NetfilterCheckerID:             "Load the br_netfilter kernel module and set 'net.bridge.bridge-nf-call-iptables=1' with sysctl.",
+	monitoring.MountsCheckerID:                "Set 'fs.may_detach_mounts=1' with sysctl and persist it in /etc/sysctl.d.",
+	monitoring.DiskSpaceCheckerID:             "Free up disk space or mount a larger volume for the directory listed in the error.",
+	monitoring.NodeStatusCheckerID:            "Make sure the node is running and can reach the other cluster nodes.",
+	monitoring.NodesStatusCheckerID:           "Make sure all nodes are running and can reach each other.",
+	"dns":                                     "Check that the cluster DNS service is running and resolving names.",
+	"systemd":                                 "Check the failed systemd units with systemctl --failed inside planet.",
+	"time-drift":                              "Synchronize the clocks on the nodes, for example, with NTP.",
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checks
+
+import (
+	"github.com/gravitational/satellite/agent/proto/agentpb"
+	"github.com/gravitational/satellite/monitoring"
+	"gopkg.in/check.v1"
+)
+
+type ResultsSuite struct{}
+
+var _ = check.Suite(&ResultsSuite{})
+
+func (s *ResultsSuite) TestConvertsResults(c *check.C) {
+	results := NewResults("node", LocalChecksResult{
+		Failed: []*agentpb.Probe{{
+			Checker:  "time-drift",
+			Detail:   "time drift is too high",
+			Status:   agentpb.Probe_Failed,
+			Severity: agentpb.Probe_Warning,
+		}},
+		Fixable: []*agentpb.Probe{{
+			Checker:  monitoring.IPForwardCheckerID,
+			Error:    "ipv4 forwarding is off",
+			Status:   agentpb.Probe_Failed,
+			Severity: agentpb.Probe_Critical,
+		}},
+	})
+	c.Assert(results, check.DeepEquals, &Results{
+		Profile: "node",
+		Passed:  false,
+		Results: []CheckResult{
+			{
+				ProbeID:     "time-drift",
+				Severity:    SeverityWarning,
+				Status:      StatusFailed,
+				Message:     "time drift is too high",
+				Remediation: Remediation("time-drift"),
+			},
+			{
+				ProbeID:     monitoring.IPForwardCheckerID,
+				Severity:    SeverityCritical,
+				Status:      StatusFailed,
+				Message:     "ipv4 forwarding is off",
+				Remediation: "Run the check with --autofix to fix the problem automatically. " + Remediation(monitoring.IPForwardCheckerID),
+				AutoFixable: true,
+			},
+		},
+	})
+}
+
+func (s *ResultsSuite) TestPassesWithoutFailures(c *check.C) {
+	results := NewResults("node", LocalChecksResult{
+		Fixed: []*agentpb.Probe{{
+			Checker: monitoring.KernelModuleCheckerID,
+			Detail:  "overlay module is not loaded",
+			Status:  agentpb.Probe_Failed,
+		}},
+	})
+	c.Assert(results.Passed, check.Equals, true)
+	c.Assert(results.Results, check.HasLen, 1)
+	c.Assert(results.Results[0].Status, check.Equals, StatusFixed)
+}
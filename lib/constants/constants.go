@@ -213,6 +213,10 @@ const (
 	// BlockingOperationEnvVar specifies whether to wait for operation to complete
 	BlockingOperationEnvVar = "GRAVITY_BLOCKING_OPERATION"
 
+	// NoProxyPolicyEnvVar names the environment variable that specifies the policy
+	// for excluding internal destinations from HTTP proxying
+	NoProxyPolicyEnvVar = "GRAVITY_NO_PROXY_POLICY"
+
 	// DockerRegistry is a default name for private docker registry
 	DockerRegistry = "leader.telekube.local:5000"
 
@@ -260,6 +264,9 @@ const (
 
 	// RootKeyPair is a name of the K8s root certificate authority keypair
 	RootKeyPair = "root"
+	// RootChainKeyPair is a name of the certificate-only key pair with the chain of
+	// intermediate certificates that issued the root certificate authority
+	RootChainKeyPair = "root-chain"
 	// APIServerKeyPair is a name of the K8s apiserver key pair
 	APIServerKeyPair = "apiserver"
 	// APIServerKubeletClientKeyPair is the name of the cert for the API server to connect to kubelet
@@ -497,6 +504,9 @@ const (
 	// RoleReader gives access to some system packages and roles
 	// used in tele build to download artifacts from ops centers
 	RoleReader = "@reader"
+	// RoleReadOnly gives read-only access to the cluster status, operations
+	// and resources without the ability to modify them
+	RoleReadOnly = "@readonly"
 	// RoleOneTimeLink is a role for one-time link installation
 	RoleOneTimeLink = "@onetimelink"
 
@@ -509,6 +519,10 @@ const (
 	// UserContext is a context field that contains authenticated user name
 	UserContext = "user.context"
 
+	// ClientAddrContext is a context field that contains the address
+	// of the client that made the request
+	ClientAddrContext = "client.addr.context"
+
 	// PrivilegedKubeconfig is a path to privileged kube config
 	// that is stored on K8s master node
 	PrivilegedKubeconfig = "/etc/kubernetes/scheduler.kubeconfig"
@@ -546,6 +560,10 @@ const (
 	// ClusterEnvironmentMap is the name of the ConfigMap that contains cluster environment
 	ClusterEnvironmentMap = "runtimeenvironment"
 
+	// ClusterProxyMap is the name of the ConfigMap that projects the proxy settings
+	// from the cluster environment for use by applications
+	ClusterProxyMap = "cluster-proxy"
+
 	// PreviousKeyValuesAnnotationKey defines the annotation field that keeps the old
 	// environment variables after the update
 	PreviousKeyValuesAnnotationKey = "previous-values"
@@ -553,6 +571,9 @@ const (
 	// ClusterConfigurationMap is the name of the ConfigMap that hosts cluster configuration resource
 	ClusterConfigurationMap = "cluster-configuration"
 
+	// ClusterDNSMap is the name of the ConfigMap that hosts cluster DNS configuration resource
+	ClusterDNSMap = "cluster-dns"
+
 	// ClusterInfoMap is the name of the ConfigMap that contains cluster information.
 	ClusterInfoMap = "cluster-info"
 	// ClusterNameEnv is the environment variable that contains cluster domain name.
@@ -586,6 +607,31 @@ const (
 	// AuthGatewayConfigMap is the name of config map with auth gateway configuration.
 	AuthGatewayConfigMap = "auth-gateway"
 
+	// HealthReportConfigMap is the name of config map with scheduled health report configuration.
+	HealthReportConfigMap = "health-report"
+
+	// AdmissionWebhookConfigMap is the name of config map with the resource admission webhook configuration.
+	AdmissionWebhookConfigMap = "admission-webhook"
+
+	// OperationPolicyConfigMap is the name of config map with the cluster operation policy.
+	OperationPolicyConfigMap = "operation-policy"
+
+	// RegistryConfigConfigMap is the name of config map with the cluster registry configuration.
+	RegistryConfigConfigMap = "registry-config"
+
+	// PersistentStorageConfigMap is the name of config map with the cluster persistent storage configuration.
+	PersistentStorageConfigMap = "persistent-storage"
+
+	// RetentionPolicyConfigMap is the name of config map with the cluster retention policy.
+	RetentionPolicyConfigMap = "retention-policy"
+
+	// NodeTombstonesConfigMap is the name of config map with the tombstones
+	// of nodes that have left the cluster forcibly.
+	NodeTombstonesConfigMap = "node-tombstones"
+
+	// LogLevelsConfigMap is the name of config map with log level overrides of cluster controllers.
+	LogLevelsConfigMap = "log-levels"
+
 	// LVMSystemDir specifies the default location where lvm2 keeps state and configuration data
 	LVMSystemDir = "/etc/lvm"
 	// LVMSystemDirEnvvar defines the name of the environment variable that overrides the
@@ -615,6 +661,32 @@ const (
 	// SystemLabel is used to identify object as a system
 	SystemLabel = "gravitational.io/system"
 
+	// PlatformNamespaceLabel marks the namespaces with the platform services
+	// exempt from the baseline network policies
+	PlatformNamespaceLabel = "gravitational.io/platform"
+
+	// NetworkPolicyLabel identifies the network policies managed by gravity
+	NetworkPolicyLabel = "gravitational.io/network-policy"
+
+	// NetworkPolicyBaseline is the value of NetworkPolicyLabel for the baseline network policies
+	NetworkPolicyBaseline = "baseline"
+
+	// VolumeSnapshotLabel is the label with the name of the gravity volume
+	// snapshot assigned to the Kubernetes volume snapshots it consists of
+	VolumeSnapshotLabel = "gravitational.io/volume-snapshot"
+
+	// MigrationLabel marks the helper pods that copy persistent volume
+	// data during migration between clusters
+	MigrationLabel = "gravitational.io/migration"
+
+	// DefaultDenyNetworkPolicy is the name of the network policy that denies
+	// all traffic in a namespace
+	DefaultDenyNetworkPolicy = "gravity-default-deny"
+
+	// PlatformNetworkPolicy is the name of the network policy that allows
+	// the traffic required by the platform services in a namespace
+	PlatformNetworkPolicy = "gravity-allow-platform"
+
 	// True is a boolean 'true' value
 	True = "true"
 
@@ -695,6 +767,15 @@ const (
 	// AnnotationSize contains image size in bytes.
 	AnnotationSize = "gravitational.io/size"
 
+	// PreDrainCommandAnnotation specifies the command to execute in a pod
+	// before it is evicted from a drained node, as a JSON list of arguments
+	PreDrainCommandAnnotation = "pre-drain.gravitational.io/command"
+	// PreDrainContainerAnnotation specifies the pod container to execute
+	// the pre-drain command in. Defaults to the first container
+	PreDrainContainerAnnotation = "pre-drain.gravitational.io/container"
+	// PreDrainTimeoutAnnotation specifies the maximum duration of the pre-drain command
+	PreDrainTimeoutAnnotation = "pre-drain.gravitational.io/timeout"
+
 	// ServiceAutoscaler is the name of the service that monitors autoscaling
 	// events and launches appropriate operations.
 	//
@@ -723,6 +804,8 @@ var (
 	EncodingShort Format = "short"
 	// EncodingYAML is for the YAML encoding format
 	EncodingYAML Format = "yaml"
+	// EncodingCSV is for the CSV encoding format
+	EncodingCSV Format = "csv"
 	// OutputFormats is a list of recognized output formats for gravity CLI commands
 	OutputFormats = []Format{
 		EncodingText,
@@ -85,12 +85,42 @@ const (
 	// EtcdRetryInterval is the retry interval for some etcd commands
 	EtcdRetryInterval = 3 * time.Second
 
+	// OperatorRetryTimeout is the maximum time to retry a cluster operator
+	// request failing with a transient error for
+	OperatorRetryTimeout = 1 * time.Minute
+	// OperatorFailureThreshold is the number of consecutive transient failures
+	// of cluster operator requests after which the requests start failing fast
+	OperatorFailureThreshold = 5
+	// OperatorBreakerResetTimeout is how long the cluster operator requests
+	// fail fast for before the cluster controller is tried again
+	OperatorBreakerResetTimeout = 10 * time.Second
+
 	// InstallApplicationTimeout is the max allowed time for k8s application to install
 	InstallApplicationTimeout = 90 * time.Minute // 1.5 hours
 
 	// PhaseTimeout is the default phase execution timeout
 	PhaseTimeout = "1h"
 
+	// DrainPhaseTimeout is the maximum amount of time the node drain phase
+	// is allowed to run. It exceeds DrainTimeout to account for checkpoints
+	DrainPhaseTimeout = 90 * time.Minute
+
+	// EtcdPhaseTimeout is the maximum amount of time an etcd upgrade phase
+	// is allowed to run
+	EtcdPhaseTimeout = 30 * time.Minute
+
+	// SystemUpgradePhaseTimeout is the maximum amount of time the system
+	// software upgrade phase is allowed to run on a node
+	SystemUpgradePhaseTimeout = 1 * time.Hour
+
+	// PhaseCancelGracePeriod is how often a phase that has exceeded its timeout
+	// is reported if it has not stopped after its context has been canceled
+	PhaseCancelGracePeriod = 1 * time.Minute
+
+	// PhaseLogsMaxSize is the maximum size of the output of a phase executed
+	// on a remote node that is recorded with the phase
+	PhaseLogsMaxSize = 128 * 1024
+
 	// UpdateTimeout is the max allowed time for system update
 	UpdateTimeout = 30 * time.Minute
 
@@ -123,6 +153,12 @@ const (
 	// InstallTokenBytes is the length of the token generated for a one-time installation
 	InstallTokenBytes = 16
 
+	// DownloadTokenBytes is the length of the token generated for an application download URL
+	DownloadTokenBytes = 32
+
+	// OperationTokenBytes is the length of the token granting access to an operation
+	OperationTokenBytes = 32
+
 	// InstallTokenTTL is the TTL for the install token after the installation
 	// has been completed/or failed
 	InstallTokenTTL = time.Hour
@@ -136,12 +172,52 @@ const (
 	// MaxExpandConcurrency is the number of servers that can be joining the cluster concurrently
 	MaxExpandConcurrency = 5
 
+	// ExpandEtcdLockTTL is the time after which the lock serializing etcd member
+	// additions is released if the joining node that holds it has failed
+	ExpandEtcdLockTTL = 10 * time.Minute
+
+	// ResourceUpdateLockTTL is the time after which the lock serializing
+	// revision-guarded updates of a cluster resource is released if the
+	// process holding it has failed
+	ResourceUpdateLockTTL = 1 * time.Minute
+
 	// DownloadRetryPeriod is the period between failed retry attempts
 	DownloadRetryPeriod = 5 * time.Second
 
 	// DownloadRetryAttempts is the number of attempts to download package/file before giving up
 	DownloadRetryAttempts = 20
 
+	// DownloadChunkSize is the size of a package contents chunk that is
+	// downloaded and verified as a unit by resumable downloads
+	DownloadChunkSize int64 = 32 * 1024 * 1024
+	// ChunkManifestCacheSize is the maximum number of package chunk
+	// manifests cached by the package service
+	ChunkManifestCacheSize = 256
+	// ChunkManifestCacheTTL is how long the package service caches
+	// a package chunk manifest
+	ChunkManifestCacheTTL = 30 * time.Minute
+
+	// DeltaBlockSize is the size of a block of package contents that is
+	// matched against the existing file by delta transfers
+	DeltaBlockSize int64 = 64 * 1024
+
+	// MinDeltaBlockSize is the smallest block size accepted for delta transfers
+	MinDeltaBlockSize int64 = 4 * 1024
+
+	// UploadChunkSize is the size of a package contents part that is
+	// uploaded and verified as a unit by chunked uploads
+	UploadChunkSize int64 = 32 * 1024 * 1024
+
+	// UploadParallel is the number of package parts uploaded concurrently
+	UploadParallel = 4
+
+	// PackageUploadTTL is the time after which an abandoned package upload is removed
+	PackageUploadTTL = 24 * time.Hour
+
+	// PackageDownloadsDir is the name of the directory inside the temporary
+	// directory where partially downloaded packages are kept between attempts
+	PackageDownloadsDir = "gravity-downloads"
+
 	// ProgressPollTimeout defines the timeout between progress polling attempts
 	ProgressPollTimeout = 500 * time.Millisecond
 
@@ -195,6 +271,10 @@ const (
 	// GravityRPCInstallerServiceName defines systemd unit service name for the installer
 	GravityRPCInstallerServiceName = "gravity-installer.service"
 
+	// GravityUninstallServiceName defines the name of the transient systemd unit
+	// that tears down a node as a part of the cluster uninstall
+	GravityUninstallServiceName = "gravity-uninstall.service"
+
 	// AgentValidationTimeout specifies the maximum amount of time for a remote validation
 	// request during the preflight test
 	AgentValidationTimeout = 1 * time.Minute
@@ -348,6 +428,11 @@ const (
 	// installation or join the state directory can be formatted)
 	GravityEphemeralDir = "/usr/local/share/gravity"
 
+	// GravityReinstallDir is used to keep the application data directories
+	// preserved during the node reinstall until they are restored into
+	// the freshly installed cluster
+	GravityReinstallDir = "/var/lib/gravity-reinstall"
+
 	// GravityConfigFilename is the name of the file with gravity configuration
 	GravityConfigFilename = ".gravity.config"
 
@@ -397,6 +482,10 @@ const (
 	// UpdateDir is the gravity subdirectory where update related data is stored
 	UpdateDir = "update"
 
+	// UpdateDownloadDir is the update subdirectory where the cluster image
+	// downloaded ahead of the upgrade is staged
+	UpdateDownloadDir = "download"
+
 	// AgentDir is the gravity subdirectory where update agent stores its data
 	AgentDir = "agent"
 
@@ -494,6 +583,10 @@ const (
 	// idle connection deadline
 	ConnectionIdleTimeout = 2 * time.Minute
 
+	// LocalClusterQueryTimeout is how long commands wait for the local cluster
+	// state when configuring the proxy policy
+	LocalClusterQueryTimeout = 5 * time.Second
+
 	// ReadHeadersTimeout is a default TCP timeout when we wait
 	// for the response headers to arrive
 	ReadHeadersTimeout = 30 * time.Second
@@ -563,6 +656,14 @@ const (
 	// to be considered successfull
 	WriteFactor = 1
 
+	// BlobS3AbortIncompleteUploadDays is the number of days after which
+	// incomplete multipart uploads to the S3 BLOB storage bucket are aborted
+	BlobS3AbortIncompleteUploadDays = 1
+
+	// BlobS3CacheCapacityBytes is the default maximum size of the local
+	// cache of BLOBs downloaded from the S3 BLOB storage bucket
+	BlobS3CacheCapacityBytes = 20 * 1024 * 1024 * 1024
+
 	// ElectionTerm is a leader election term for multiple gravity instances
 	ElectionTerm = 10 * time.Second
 
@@ -589,9 +690,91 @@ const (
 	// LicenseCheckInterval is how often local gravity site will check the installed license
 	LicenseCheckInterval = 1 * time.Minute
 
+	// LicenseGracePeriod is how long an expired license is still honored
+	// before the operations that require a valid license are refused
+	LicenseGracePeriod = 14 * 24 * time.Hour
+
+	// LicenseExpiryWarningPeriod is how long before the license expiration
+	// the cluster status starts warning about it
+	LicenseExpiryWarningPeriod = 30 * 24 * time.Hour
+
 	// SiteStatusCheckInterval is how often local gravity site will invoke app status hook
 	SiteStatusCheckInterval = 1 * time.Minute
 
+	// HealthReportCheckInterval is how often local gravity site checks whether
+	// a scheduled health report is due
+	HealthReportCheckInterval = 10 * time.Minute
+
+	// HealthReportTimeout is the maximum amount of time to collect and deliver a health report
+	HealthReportTimeout = 1 * time.Minute
+
+	// RetentionCheckInterval is how often local gravity site purges the records
+	// past the cluster retention policy
+	RetentionCheckInterval = 1 * time.Hour
+
+	// RetentionExportTimeout is the maximum amount of time to post records
+	// to the export webhook before they are purged
+	RetentionExportTimeout = 1 * time.Minute
+
+	// NodeTombstoneReconcileInterval is how often local gravity site verifies
+	// that the nodes that have left the cluster forcibly have been removed
+	NodeTombstoneReconcileInterval = 5 * time.Minute
+
+	// LeaveOperationTimeout is the maximum amount of time to wait for the node
+	// removal operation launched by gravity leave to complete
+	LeaveOperationTimeout = 30 * time.Minute
+
+	// AdmissionWebhookTimeout is the default timeout for resource admission webhook requests
+	AdmissionWebhookTimeout = 10 * time.Second
+
+	// OPATimeout is the default timeout for Open Policy Agent server requests
+	OPATimeout = 10 * time.Second
+
+	// OperationPolicyDecision is the default path of the decision
+	// document of Rego operation policies
+	OperationPolicyDecision = "gravity/operations/decision"
+
+	// OperationPolicyModule is the ID of the Rego operation policy
+	// module in the Open Policy Agent server
+	OperationPolicyModule = "gravity-operation-policy"
+
+	// OperationApprovalTTL is how long operation approval requests remain valid by default
+	OperationApprovalTTL = time.Hour
+
+	// DownloadTokenTTL is how long application download tokens remain valid by default
+	DownloadTokenTTL = 24 * time.Hour
+
+	// MaxDownloadTokenTTL is the maximum lifetime of an application download token
+	MaxDownloadTokenTTL = 30 * 24 * time.Hour
+
+	// OperationTokenTTL is how long a token granting access to the plan
+	// and progress of an operation remains valid
+	OperationTokenTTL = time.Hour
+
+	// CertificateExpiryWarning is how long before the expiration of the cluster
+	// certificate the health report starts warning about it
+	CertificateExpiryWarning = 30 * 24 * time.Hour
+
+	// CertificateRotationThreshold is how long before the expiration of the
+	// node certificates they are rotated automatically
+	CertificateRotationThreshold = 60 * 24 * time.Hour
+
+	// CertificateRotationCheckInterval is how often the node certificates
+	// are checked for expiration
+	CertificateRotationCheckInterval = 6 * time.Hour
+
+	// StorageQuotaWarningThreshold is the percentage of a persistent storage
+	// quota that, once used, raises a warning in the cluster status
+	StorageQuotaWarningThreshold = 80
+
+	// StorageUsageTimeout is the maximum amount of time to wait for
+	// the volume usage statistics of a single node
+	StorageUsageTimeout = 10 * time.Second
+
+	// MaxStorageUsageConcurrency is the number of nodes queried
+	// for volume usage statistics concurrently
+	MaxStorageUsageConcurrency = 5
+
 	// OfflineCheckInterval is how often OpsCenter checks whether its sites are online/offline
 	OfflineCheckInterval = 10 * time.Second
 
@@ -599,11 +782,63 @@ const (
 	RegistrySyncInterval = 20 * time.Second
 	// AppSyncInterval is how often app images are synced with the local registry
 	AppSyncInterval = 30 * time.Second
+	// RegistryReplicationInterval is how often images are replicated between
+	// the registries of master nodes if the registry is configured for replication
+	RegistryReplicationInterval = time.Minute
+	// MinRegistryReplicationInterval is the minimum supported registry replication interval
+	MinRegistryReplicationInterval = 10 * time.Second
+	// RegistryConfigCheckInterval is how often the registry configuration
+	// is checked for replication settings
+	RegistryConfigCheckInterval = time.Minute
+	// RegistryMirrorInterval is how often the upstream images are mirrored
+	// into the cluster registry by default
+	RegistryMirrorInterval = time.Hour
+	// MinRegistryMirrorInterval is the minimum supported image mirroring interval
+	MinRegistryMirrorInterval = time.Minute
 
 	// KubeSystemNamespace is the name of k8s namespace where all our system stuff goes
 	KubeSystemNamespace = "kube-system"
 	// MonitoringNamespace is the name of k8s namespace for the monitoring-related resources
 	MonitoringNamespace = "monitoring"
+	// OpenEBSNamespace is the name of k8s namespace with the OpenEBS resources
+	OpenEBSNamespace = "openebs"
+
+	// CStorMaxDefaultReplicas is the maximum number of cStor volume replicas
+	// used if the number of replicas has not been configured explicitly
+	CStorMaxDefaultReplicas = 3
+
+	// StoragePoolReconcileInterval is how often the OpenEBS storage pools
+	// are reconciled with the persistent storage configuration
+	StoragePoolReconcileInterval = 5 * time.Minute
+
+	// BlockDeviceDiscoveryTimeout is how long to wait for the OpenEBS node
+	// disk manager to discover the block devices claimed by storage pools
+	BlockDeviceDiscoveryTimeout = 5 * time.Minute
+
+	// VolumeSnapshotTimeout is the maximum amount of time to wait for
+	// persistent volume snapshots to become ready to use
+	VolumeSnapshotTimeout = 10 * time.Minute
+	// VolumeSnapshotPollInterval is how often the status of persistent
+	// volume snapshots is checked while waiting for them to become ready
+	VolumeSnapshotPollInterval = 5 * time.Second
+	// VolumeSnapshotsDir is the gravity subdirectory where the etcd backups
+	// taken along with persistent volume snapshots are stored
+	VolumeSnapshotsDir = "snapshots"
+	// VolumeSnapshotEtcdBackupFile is the name of the etcd backup file
+	// taken along with persistent volume snapshots
+	VolumeSnapshotEtcdBackupFile = "etcd.backup"
+	// MigrationChunkSize is the maximum size of a single chunk of volume
+	// data in a migration archive
+	MigrationChunkSize = 4 * 1024 * 1024
+	// MigrationPodTimeout is the maximum amount of time to wait for
+	// the helper pod that copies volume data during migration to start
+	MigrationPodTimeout = 5 * time.Minute
+	// MigrationImportTimeout is the maximum amount of time the operation
+	// importing the migrated application data is allowed to run
+	MigrationImportTimeout = 6 * time.Hour
+	// ComponentOperationTimeout is the maximum amount of time to wait for
+	// the operation enabling or disabling an application component
+	ComponentOperationTimeout = time.Hour
 
 	// SystemServiceWantedBy sets default target for system services installed by gravity
 	SystemServiceWantedBy = "multi-user.target"
@@ -817,6 +1052,12 @@ const (
 
 	// AWSRegion is the default AWS region
 	AWSRegion = "us-east-1"
+	// AWSVMImportRole is the default name of the AWS VM Import service role
+	AWSVMImportRole = "vmimport"
+	// AWSImportPollInterval is the interval between checks of the AWS snapshot import status
+	AWSImportPollInterval = 30 * time.Second
+	// AWSImportTimeout is the maximum amount of time to wait for the AWS snapshot import
+	AWSImportTimeout = 2 * time.Hour
 	// AWSVPCCIDR is the default AWS VPC CIDR
 	AWSVPCCIDR = "10.1.0.0/16"
 	// AWSSubnetCIDR is the default AWS subnet CIDR
@@ -877,6 +1118,9 @@ const (
 	EtcdGravityPrefix = "/gravity"
 	// EtcdPlanetPrefix is etcd prefix under which planet keeps its data
 	EtcdPlanetPrefix = "/planet"
+	// ExpandEtcdLockKey is the etcd key of the lock that serializes
+	// adding etcd members by the concurrently joining master nodes
+	ExpandEtcdLockKey = "/gravity/expand/etcd-lock"
 
 	// SchedulerKeyFilename is the kube-scheduler private key filename
 	SchedulerKeyFilename = "scheduler.key"
@@ -920,6 +1164,10 @@ const (
 	// DrainTimeout defines the total drain operation timeout
 	DrainTimeout = 1 * time.Hour
 
+	// PreDrainHookTimeout is the default timeout of the pre-drain command
+	// executed in a pod before it is evicted from a drained node
+	PreDrainHookTimeout = 5 * time.Minute
+
 	// TerminationWaitTimeout defines an amount of time above the Kubernetes
 	// TerminationGracePeriod to wait for a pod to be terminated. Kubernetes
 	// may take some amount of time to force kill a pod, which we want to
@@ -984,6 +1232,20 @@ const (
 	// VxlanPort is the port used for overlay network
 	VxlanPort = 8472
 
+	// VxlanOverhead is the number of bytes the VXLAN encapsulation adds
+	// to each packet of the overlay network
+	VxlanOverhead = 50
+
+	// MinPathMTU is the smallest path MTU between cluster nodes
+	// the overlay network can operate with
+	MinPathMTU = 1280
+
+	// MaxPathMTU is the largest path MTU probed between cluster nodes
+	MaxPathMTU = 9000
+
+	// MinProbeMTU is the smallest MTU used when probing the path MTU
+	MinProbeMTU = 576
+
 	// DNSListenAddr is the default address coredns will be configured to listen on
 	DNSListenAddr = "127.0.0.2"
 
@@ -994,6 +1256,9 @@ const (
 	// DNSPort is the default DNS port coredns will be configured with
 	DNSPort = 53
 
+	// NodeLocalDNSAddr is the link-local address the node-local DNS cache listens on
+	NodeLocalDNSAddr = "169.254.20.10"
+
 	// ModulesPath is the path to the list of gravity-specific kernel modules loaded at boot
 	ModulesPath = "/etc/modules-load.d/gravity.conf"
 	// SysctlPath is the path to gravity-specific kernel parameters configuration
@@ -1134,6 +1399,35 @@ var (
 	// healthy node status
 	NodeStatusTimeout = 5 * time.Minute
 
+	// NodeRebootTimeout specifies the maximum amount of time to wait for
+	// a node to come back after a reboot
+	NodeRebootTimeout = 15 * time.Minute
+
+	// NodeRebootDelay specifies the delay before a node reboot scheduled
+	// by an operation so that the remote command can complete first
+	NodeRebootDelay = 5 * time.Second
+
+	// AgentRestartDelay specifies the delay before an agent restart scheduled
+	// by an operation so that the remote command can complete first
+	AgentRestartDelay = 2 * time.Second
+
+	// AgentRestartTimeout specifies the maximum amount of time to wait for
+	// an agent to come back after a restart
+	AgentRestartTimeout = 2 * time.Minute
+
+	// BinaryRestoreTimeout specifies the amount of time after which the previous
+	// gravity binary is restored on a node during the binary update unless
+	// the node has been verified with the new binary
+	BinaryRestoreTimeout = AgentRestartTimeout + NodeStatusTimeout + 5*time.Minute
+
+	// ServiceRestartDelay specifies the delay before a service restart
+	// scheduled by an operation so that the remote command can complete first
+	ServiceRestartDelay = 5 * time.Second
+
+	// PodsRestartTimeout specifies the maximum amount of time to wait for
+	// the pods in a namespace to become ready after a restart
+	PodsRestartTimeout = 10 * time.Minute
+
 	// NodeLeaveTimeout specifies the maximum amount of time to wait for
 	// node to leave the cluster
 	NodeLeaveTimeout = 1 * time.Minute
@@ -1173,6 +1467,46 @@ var (
 	// MetricsStep is the default interval b/w cluster metrics data points.
 	MetricsStep = 15 * time.Second
 
+	// StatusWatchInterval is the default interval the cluster status is
+	// refreshed with in watch mode
+	StatusWatchInterval = 5 * time.Second
+
+	// NodeInspectOperations is the number of most recent operations
+	// searched for the phases of a node by node inspect
+	NodeInspectOperations = 5
+
+	// ReportJournalPeriod is how far back the journal entries
+	// of gravity units are collected in the diagnostics report
+	ReportJournalPeriod = "48h"
+
+	// ReportMaxFileSize is the maximum size of a single file
+	// in the cluster diagnostics report
+	ReportMaxFileSize int64 = 200 * 1024 * 1024
+
+	// ReportMaxSize is the maximum total size of the files
+	// in the cluster diagnostics report
+	ReportMaxSize int64 = 2 * 1024 * 1024 * 1024
+
+	// ClusterExecTimeout is the default timeout of a command
+	// executed on the cluster nodes with gravity exec
+	ClusterExecTimeout = "5m"
+
+	// KubernetesEventsInterval is the interval at which the rate limiter of
+	// Kubernetes events published by the cluster allows another event
+	KubernetesEventsInterval = 5 * time.Second
+
+	// KubernetesEventsBurst is the maximum number of Kubernetes events
+	// the cluster publishes at once before rate limiting kicks in
+	KubernetesEventsBurst = 20
+
+	// KubernetesEventsComponent is the source component of Kubernetes events
+	// published by the cluster
+	KubernetesEventsComponent = "gravity-site"
+
+	// GenericErrorExitCode specifies the exit code for this process when
+	// an error does not specify a more specific exit code
+	GenericErrorExitCode = 255
+
 	// AbortedOperationExitCode specifies the exit code for this process when an operation is aborted.
 	// The exit code is used to prevent the installer service from restarting in case the operation
 	// is aborted
@@ -1187,6 +1521,20 @@ var (
 	// A failed precondition usually means a configuration error when an operation cannot be retried.
 	// The exit code is used to prevent the agent service from restarting after shutdown
 	FailedPreconditionExitCode = 252
+
+	// TimeoutExitCode specifies the exit code to indicate that a command
+	// has timed out. Matches the exit code of the coreutils timeout command
+	TimeoutExitCode = 124
+
+	// WaitTimeout is the default timeout of gravity wait
+	WaitTimeout = 10 * time.Minute
+
+	// WaitInterval is the default interval between condition checks in gravity wait
+	WaitInterval = 5 * time.Second
+
+	// ProxyProtocolReadHeaderTimeout is the maximum time to wait for
+	// the PROXY protocol header on a new connection
+	ProxyProtocolReadHeaderTimeout = 5 * time.Second
 )
 
 // HookSecurityContext returns default securityContext for hook pods
@@ -20,12 +20,16 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/users"
 
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/auth"
 
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
 )
@@ -46,6 +50,8 @@ func init() {
 type registryACL struct {
 	// Users is the cluster users service.
 	Users users.Identity
+	// GetConfig returns the cluster registry configuration.
+	GetConfig func() (storage.RegistryConfig, error)
 	// FieldLogger is used for logging.
 	logrus.FieldLogger
 }
@@ -59,8 +65,17 @@ func newACL(parameters map[string]interface{}) (auth.AccessController, error) {
 	if !ok {
 		return nil, trace.BadParameter("expected users.Identity, got: %T", usersI)
 	}
+	configI, ok := parameters["config"]
+	if !ok {
+		return nil, trace.BadParameter("missing GetConfig: %v", parameters)
+	}
+	getConfig, ok := configI.(func() (storage.RegistryConfig, error))
+	if !ok {
+		return nil, trace.BadParameter("expected registry config getter, got: %T", configI)
+	}
 	return &registryACL{
 		Users:       users,
+		GetConfig:   getConfig,
 		FieldLogger: logrus.WithField(trace.Component, "reg.acl"),
 	}, nil
 }
@@ -75,12 +90,20 @@ func newACL(parameters map[string]interface{}) (auth.AccessController, error) {
 // "challenge" error type is returned which is converted to a 401 HTTP response
 // and recognized by Docker client so it can send credentials.
 //
+// Pushing images is only allowed if enabled with the cluster registry
+// configuration and requires permissions to create applications.
+//
 // On success returns context that includes authenticated user information.
 func (acl *registryACL) Authorized(ctx context.Context, access ...auth.Access) (context.Context, error) {
-	// We do not allow direct pushes to the cluster's registry atm.
-	for _, a := range access {
-		if a.Action == "push" {
-			return nil, trace.AccessDenied("pushing images directly is not supported")
+	push := isPush(access)
+	if push {
+		config, err := acl.GetConfig()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if !config.GetAllowPush() {
+			return nil, trace.AccessDenied("pushing images directly is not enabled, " +
+				"see the registry resource to enable it")
 		}
 	}
 	request, err := context.GetRequest(ctx)
@@ -98,7 +121,7 @@ func (acl *registryACL) Authorized(ctx context.Context, access ...auth.Access) (
 		}
 	}
 	acl.Debugf("Auth request: %v %#v.", authCreds.Username, access)
-	user, _, err := acl.Users.AuthenticateUser(*authCreds)
+	user, checker, err := acl.Users.AuthenticateUser(*authCreds)
 	if err != nil {
 		// Basic auth credentials were provided but incorrect.
 		acl.Warnf("Auth failure: %v %v.", authCreds.Username, err)
@@ -107,12 +130,34 @@ func (acl *registryACL) Authorized(ctx context.Context, access ...auth.Access) (
 			err:   auth.ErrAuthenticationFailure,
 		}
 	}
+	if push {
+		err := checker.CheckAccessToRule(&users.Context{
+			Context: teleservices.Context{
+				User:     user,
+				Resource: storage.NewRepository(defaults.SystemAccountOrg),
+			},
+		}, teledefaults.Namespace, storage.KindApp, teleservices.VerbCreate, false)
+		if err != nil {
+			acl.Warnf("Push denied for %v: %v.", user.GetName(), err)
+			return nil, trace.Wrap(err)
+		}
+	}
 	// Authentication success, populate the context with the user info.
 	return auth.WithUser(ctx, auth.UserInfo{
 		Name: user.GetName(),
 	}), nil
 }
 
+// isPush returns true if any of the requested accesses is a push
+func isPush(access []auth.Access) bool {
+	for _, a := range access {
+		if a.Action == "push" {
+			return true
+		}
+	}
+	return false
+}
+
 // challenge is a special error type which is used by registry to send
 // 401 Unauthorized responses to Docker.
 //
@@ -21,6 +21,7 @@ import (
 	"net/http"
 
 	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/users"
 
 	"github.com/docker/distribution/configuration"
@@ -35,6 +36,8 @@ type Config struct {
 	Context context.Context
 	// Users is the cluster users service.
 	Users users.Identity
+	// GetConfig returns the cluster registry configuration.
+	GetConfig func() (storage.RegistryConfig, error)
 }
 
 // Check validates the registry handler configuration.
@@ -45,6 +48,9 @@ func (c Config) Check() error {
 	if c.Users == nil {
 		return trace.BadParameter("missing Users")
 	}
+	if c.GetConfig == nil {
+		return trace.BadParameter("missing GetConfig")
+	}
 	return nil
 }
 
@@ -72,7 +78,8 @@ func NewRegistry(config Config) (http.Handler, error) {
 			// The parameters here will be passed to the access controller's
 			// constructor.
 			"gravityACL": configuration.Parameters{
-				"users": config.Users,
+				"users":  config.Users,
+				"config": config.GetConfig,
 			},
 		},
 	})
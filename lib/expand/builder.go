@@ -58,6 +58,8 @@ type planBuilder struct {
 	ServiceUser storage.OSUser
 	// DNSConfig specifies the custom cluster DNS configuration
 	DNSConfig storage.DNSConfig
+	// SELinux specifies whether to configure SELinux on the joining node
+	SELinux bool
 }
 
 // AddInitPhase appends initialization phase to the plan.
@@ -104,7 +106,7 @@ func (b *planBuilder) AddBootstrapPhase(plan *storage.OperationPlan) {
 	if !b.JoiningNode.IsMaster() {
 		agent = &b.RegularAgent
 	}
-	plan.Phases = append(plan.Phases, storage.OperationPhase{
+	phase := storage.OperationPhase{
 		ID:          installphases.BootstrapPhase,
 		Description: "Bootstrap the joining node",
 		Data: &storage.OperationPhaseData{
@@ -114,7 +116,11 @@ func (b *planBuilder) AddBootstrapPhase(plan *storage.OperationPlan) {
 			Agent:       agent,
 			ServiceUser: &b.ServiceUser,
 		},
-	})
+	}
+	if b.SELinux {
+		phase = installphases.NewSELinuxBootstrapPhase(phase)
+	}
+	plan.Phases = append(plan.Phases, phase)
 }
 
 // AddPullPhase appends package pull phase to the plan
@@ -357,6 +363,7 @@ func (p *Peer) getPlanBuilder(ctx operationContext) (*planBuilder, error) {
 		RegularAgent:    *regularAgent,
 		ServiceUser:     ctx.Cluster.ServiceUser,
 		DNSConfig:       ctx.Cluster.DNSConfig,
+		SELinux:         ctx.Cluster.SELinux,
 	}, nil
 }
 
@@ -161,6 +161,8 @@ func (e *fsmEngine) ChangePhaseState(ctx context.Context, change fsm.StateChange
 		PhaseID:     change.Phase,
 		NewState:    change.State,
 		Error:       utils.ToRawTrace(change.Error),
+		Transient:   change.IsTransient(),
+		Logs:        change.Logs,
 		Created:     time.Now().UTC(),
 	}
 	_, err := e.JoinBackend.CreateOperationPlanChange(planChange)
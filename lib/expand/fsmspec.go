@@ -46,6 +46,11 @@ func FSMSpec(config FSMConfig) fsm.FSMSpecFunc {
 			return installphases.NewConfigure(p,
 				config.Operator)
 
+		case strings.HasPrefix(p.Phase.ID, installphases.BootstrapPhase) &&
+			strings.HasSuffix(p.Phase.ID, "/"+installphases.SELinuxPhase):
+			return installphases.NewSELinux(p,
+				config.Operator, remote)
+
 		case strings.HasPrefix(p.Phase.ID, installphases.BootstrapPhase):
 			return installphases.NewBootstrap(p,
 				config.Operator,
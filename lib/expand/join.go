@@ -718,7 +718,7 @@ func (p *Peer) runLocalChecksExpand(operator ops.Operator, cluster ops.Site) err
 
 func (p *Peer) runLocalChecks(cluster ops.Site, installOperation ops.SiteOperation) error {
 	return checks.RunLocalChecks(p.ctx, checks.LocalChecksRequest{
-		Manifest: cluster.App.Manifest,
+		Manifest: cluster.App.Manifest.WithCNI(cluster.CNI),
 		Role:     p.Role,
 		Docker:   cluster.ClusterState.Docker,
 		Options: &validationpb.ValidateOptions{
@@ -69,18 +69,25 @@ func (p *checksExecutor) Execute(ctx context.Context) error {
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	reqs, err := checks.RequirementsFromManifest(cluster.App.Manifest)
+	manifest := cluster.App.Manifest.WithCNI(cluster.CNI)
+	reqs, err := checks.RequirementsFromManifest(manifest)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	overlayMTU, err := p.getOverlayMTU(cluster.Key())
 	if err != nil {
 		return trace.Wrap(err)
 	}
 	checker, err := checks.New(checks.Config{
 		Remote:       checks.NewRemote(p.Runner),
 		Servers:      []checks.Server{*master, *node},
-		Manifest:     cluster.App.Manifest,
+		Manifest:     manifest,
 		Requirements: reqs,
 		Features: checks.Features{
 			TestEtcdDisk: true,
+			TestPathMTU:  true,
 		},
+		OverlayMTU: overlayMTU,
 	})
 	if err != nil {
 		return trace.Wrap(err)
@@ -93,6 +100,28 @@ func (p *checksExecutor) Execute(ctx context.Context) error {
 		checker.CheckNodes(ctx, []checks.Server{*master, *node}))
 }
 
+// getOverlayMTU returns the overlay network MTU configured for the cluster
+// or 0 if the MTU has not been configured
+func (p *checksExecutor) getOverlayMTU(key ops.SiteKey) (int, error) {
+	config, err := p.Operator.GetClusterConfiguration(key)
+	if err != nil && !trace.IsNotFound(err) {
+		return 0, trace.Wrap(err)
+	}
+	if config != nil {
+		if global := config.GetGlobalConfig(); global != nil && global.OverlayMTU != 0 {
+			return global.OverlayMTU, nil
+		}
+	}
+	operation, err := p.Operator.GetSiteOperation(opKey(p.Plan))
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	if operation.InstallExpand == nil {
+		return 0, nil
+	}
+	return operation.InstallExpand.OverlayMTU, nil
+}
+
 // Rollback is no-op for this phase.
 func (*checksExecutor) Rollback(context.Context) error { return nil }
 
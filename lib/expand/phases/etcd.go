@@ -57,7 +57,7 @@ func NewEtcd(p fsm.ExecutorParams, operator ops.Operator, runner rpc.AgentReposi
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	etcdClient, err := clients.EtcdMembers(&clients.EtcdConfig{
+	etcdClient, err := clients.Etcd(&clients.EtcdConfig{
 		Endpoints:  endpoints,
 		SecretsDir: state.SecretDir(stateDir),
 	})
@@ -74,7 +74,8 @@ func NewEtcd(p fsm.ExecutorParams, operator ops.Operator, runner rpc.AgentReposi
 	}
 	return &etcdExecutor{
 		FieldLogger:    logger,
-		Etcd:           etcdClient,
+		Etcd:           etcd.NewMembersAPI(etcdClient),
+		Keys:           etcd.NewKeysAPI(etcdClient),
 		Runner:         runner,
 		Master:         *p.Phase.Data.Master,
 		ExecutorParams: p,
@@ -86,6 +87,8 @@ type etcdExecutor struct {
 	logrus.FieldLogger
 	// Etcd is client to the cluster's etcd members API
 	Etcd etcd.MembersAPI
+	// Keys is client to the cluster's etcd keys API
+	Keys etcd.KeysAPI
 	// Runner is used to run remote commands
 	Runner rpc.AgentRepository
 	// Master is one of the master nodes
@@ -94,18 +97,134 @@ type etcdExecutor struct {
 	fsm.ExecutorParams
 }
 
-// Execute adds the joining node to the cluster's etcd cluster
+// Execute adds the joining node to the cluster's etcd cluster.
+//
+// Several master nodes can be joining the cluster at the same time but
+// etcd members have to be added one at a time: a new member counts towards
+// the quorum as soon as it has been added so adding another member before
+// the previous one has started might make the cluster lose the quorum.
+// The member is added under a cluster-wide lock which is released once
+// the new member has started
 func (p *etcdExecutor) Execute(ctx context.Context) error {
+	p.Progress.NextStep("Waiting for other nodes to finish adding etcd members")
+	err := p.acquireLock(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	p.Progress.NextStep("Adding etcd member")
-	member, err := p.Etcd.Add(ctx, fmt.Sprintf("https://%v:%v",
-		p.Phase.Data.Server.AdvertiseIP, defaults.EtcdPeerPort))
+	peerURL := fmt.Sprintf("https://%v:%v", p.Phase.Data.Server.AdvertiseIP, defaults.EtcdPeerPort)
+	member, err := p.findMember(ctx, peerURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if member == nil {
+		member, err = p.Etcd.Add(ctx, peerURL)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		p.Infof("Added etcd member: %v.", member)
+	} else {
+		p.Infof("Etcd member already added: %v.", member)
+	}
+	err = p.waitMemberStarted(ctx, peerURL)
 	if err != nil {
+		// keep the lock until it expires so other nodes do not add
+		// members while this one might still be starting
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(p.releaseLock(ctx))
+}
+
+// acquireLock obtains the lock serializing etcd member additions.
+// The lock is keyed by the operation ID so the phase can be resumed
+func (p *etcdExecutor) acquireLock(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, defaults.ExpandEtcdLockTTL)
+	defer cancel()
+	err := utils.RetryWithInterval(ctx, utils.NewUnlimitedExponentialBackOff(), func() error {
+		_, err := p.Keys.Set(ctx, defaults.ExpandEtcdLockKey, p.Plan.OperationID, &etcd.SetOptions{
+			PrevExist: etcd.PrevNoExist,
+			TTL:       defaults.ExpandEtcdLockTTL,
+		})
+		if err == nil {
+			return nil
+		}
+		if !isEtcdError(err, etcd.ErrorCodeNodeExist) {
+			return trace.Wrap(err)
+		}
+		resp, err := p.Keys.Get(ctx, defaults.ExpandEtcdLockKey, nil)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if resp.Node.Value == p.Plan.OperationID {
+			return nil
+		}
+		p.Infof("Operation %v is adding etcd member, will retry.", resp.Node.Value)
+		return trace.CompareFailed("etcd member is being added by operation %v", resp.Node.Value)
+	})
+	return trace.Wrap(err)
+}
+
+// releaseLock releases the lock serializing etcd member additions
+// if it is held by this operation
+func (p *etcdExecutor) releaseLock(ctx context.Context) error {
+	_, err := p.Keys.Delete(ctx, defaults.ExpandEtcdLockKey, &etcd.DeleteOptions{
+		PrevValue: p.Plan.OperationID,
+	})
+	if err != nil && !isEtcdError(err, etcd.ErrorCodeKeyNotFound, etcd.ErrorCodeTestFailed) {
 		return trace.Wrap(err)
 	}
-	p.Infof("Added etcd member: %v.", member)
 	return nil
 }
 
+// findMember returns the etcd member with the specified peer URL
+// or nil if there is no such member
+func (p *etcdExecutor) findMember(ctx context.Context, peerURL string) (*etcd.Member, error) {
+	members, err := p.Etcd.List(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, member := range members {
+		if utils.StringInSlice(member.PeerURLs, peerURL) {
+			member := member
+			return &member, nil
+		}
+	}
+	return nil, nil
+}
+
+// waitMemberStarted waits until the etcd member with the specified
+// peer URL has joined the cluster
+func (p *etcdExecutor) waitMemberStarted(ctx context.Context, peerURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaults.ExpandEtcdLockTTL)
+	defer cancel()
+	err := utils.RetryWithInterval(ctx, utils.NewUnlimitedExponentialBackOff(), func() error {
+		member, err := p.findMember(ctx, peerURL)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		// a member is assigned its name once it has started
+		if member == nil || member.Name == "" {
+			return trace.NotFound("etcd member %v has not started yet", peerURL)
+		}
+		return nil
+	})
+	return trace.Wrap(err)
+}
+
+// isEtcdError returns true if err is an etcd error with one of the specified codes
+func isEtcdError(err error, codes ...int) bool {
+	etcdErr, ok := trace.Unwrap(err).(etcd.Error)
+	if !ok {
+		return false
+	}
+	for _, code := range codes {
+		if etcdErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
 // Rollback removes the joined node from the cluster's etcd cluster
 func (p *etcdExecutor) Rollback(ctx context.Context) error {
 	p.Progress.NextStep("Restoring etcd data")
@@ -272,6 +272,23 @@ func (s *PlanSuite) verifyBootstrapPhase(c *check.C, phase storage.OperationPhas
 	}, phase)
 }
 
+func (s *PlanSuite) TestSELinuxBootstrapPhase(c *check.C) {
+	builder := planBuilder{
+		JoiningNode: storage.Server{Hostname: "node-2", AdvertiseIP: "10.10.0.2"},
+		SELinux:     true,
+	}
+	var plan storage.OperationPlan
+	builder.AddBootstrapPhase(&plan)
+	c.Assert(plan.Phases, check.HasLen, 1)
+	phase := plan.Phases[0]
+	c.Assert(phase.ID, check.Equals, installphases.BootstrapPhase)
+	c.Assert(phase.Phases, check.HasLen, 2)
+	c.Assert(phase.Phases[0].ID, check.Equals, "/bootstrap/selinux")
+	c.Assert(phase.Phases[1].ID, check.Equals, "/bootstrap/system")
+	c.Assert(phase.Phases[1].Requires, check.DeepEquals, []string{"/bootstrap/selinux"})
+	c.Assert(phase.Phases[1].Data.Agent, check.DeepEquals, &builder.RegularAgent)
+}
+
 func (s *PlanSuite) verifyPullPhase(c *check.C, phase storage.OperationPhase) {
 	storage.DeepComparePhases(c, storage.OperationPhase{
 		ID: installphases.PullPhase,
@@ -0,0 +1,272 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// PlanDiff describes the differences between two operation plans
+type PlanDiff struct {
+	// From identifies the operation of the first plan
+	From string `json:"from"`
+	// To identifies the operation of the second plan
+	To string `json:"to"`
+	// Added lists the phases only present in the second plan.
+	// Sub-phases of an added phase are not listed separately
+	Added []PhaseSummary `json:"added,omitempty"`
+	// Removed lists the phases only present in the first plan.
+	// Sub-phases of a removed phase are not listed separately
+	Removed []PhaseSummary `json:"removed,omitempty"`
+	// Changed lists the phases present in both plans that differ
+	Changed []PhaseChange `json:"changed,omitempty"`
+	// Packages lists the packages referenced with different versions
+	Packages []PackageChange `json:"packages,omitempty"`
+}
+
+// IsEmpty returns true if the plans are identical
+func (r PlanDiff) IsEmpty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 &&
+		len(r.Changed) == 0 && len(r.Packages) == 0
+}
+
+// PhaseSummary identifies a phase of the plan
+type PhaseSummary struct {
+	// ID is the phase ID
+	ID string `json:"id"`
+	// Description is the phase description
+	Description string `json:"description"`
+	// State is the phase state
+	State string `json:"state"`
+}
+
+// PhaseChange describes how a phase differs between two plans
+type PhaseChange struct {
+	// ID is the phase ID
+	ID string `json:"id"`
+	// Fields lists the phase attributes that differ
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange describes a phase attribute that differs between two plans
+type FieldChange struct {
+	// Name is the attribute name
+	Name string `json:"name"`
+	// From is the attribute value in the first plan
+	From string `json:"from"`
+	// To is the attribute value in the second plan
+	To string `json:"to"`
+}
+
+// PackageChange describes a package referenced with different
+// versions by two plans
+type PackageChange struct {
+	// Package is the package repository and name
+	Package string `json:"package"`
+	// From lists the package versions referenced by the first plan
+	From []string `json:"from,omitempty"`
+	// To lists the package versions referenced by the second plan
+	To []string `json:"to,omitempty"`
+}
+
+// DiffPlans compares the specified operation plans and returns the differences
+// in their phases and in the versions of the referenced packages
+func DiffPlans(from, to storage.OperationPlan) PlanDiff {
+	diff := PlanDiff{
+		From: from.OperationID,
+		To:   to.OperationID,
+	}
+	fromPhases := indexPhases(&from)
+	toPhases := indexPhases(&to)
+	for _, phase := range FlattenPlan(&from) {
+		other, ok := toPhases[phase.ID]
+		if !ok {
+			if _, ok := toPhases[parentID(phase.ID)]; ok || parentID(phase.ID) == "" {
+				diff.Removed = append(diff.Removed, summarizePhase(*phase))
+			}
+			continue
+		}
+		if fields := diffPhases(*phase, *other); len(fields) != 0 {
+			diff.Changed = append(diff.Changed, PhaseChange{ID: phase.ID, Fields: fields})
+		}
+	}
+	for _, phase := range FlattenPlan(&to) {
+		if _, ok := fromPhases[phase.ID]; ok {
+			continue
+		}
+		if _, ok := fromPhases[parentID(phase.ID)]; ok || parentID(phase.ID) == "" {
+			diff.Added = append(diff.Added, summarizePhase(*phase))
+		}
+	}
+	diff.Packages = diffPackages(planPackages(from), planPackages(to))
+	return diff
+}
+
+func diffPhases(from, to storage.OperationPhase) (fields []FieldChange) {
+	add := func(name, from, to string) {
+		if from != to {
+			fields = append(fields, FieldChange{Name: name, From: from, To: to})
+		}
+	}
+	add("description", from.Description, to.Description)
+	add("executor", from.Executor, to.Executor)
+	add("node", formatPhaseServer(from), formatPhaseServer(to))
+	add("requires", formatRequires(from.Requires), formatRequires(to.Requires))
+	add("package", formatPhasePackage(from), formatPhasePackage(to))
+	// The state of a phase with sub-phases is derived from the sub-phases
+	// which are compared separately
+	if len(from.Phases) == 0 && len(to.Phases) == 0 {
+		add("state", formatState(from.GetState()), formatState(to.GetState()))
+		add("attempts", formatAttempts(from), formatAttempts(to))
+		add("error", formatPhaseError(from), formatPhaseError(to))
+	}
+	return fields
+}
+
+func summarizePhase(phase storage.OperationPhase) PhaseSummary {
+	return PhaseSummary{
+		ID:          phase.ID,
+		Description: phase.Description,
+		State:       formatState(phase.GetState()),
+	}
+}
+
+func indexPhases(plan *storage.OperationPlan) map[string]*storage.OperationPhase {
+	phases := make(map[string]*storage.OperationPhase)
+	for _, phase := range FlattenPlan(plan) {
+		phases[phase.ID] = phase
+	}
+	return phases
+}
+
+// parentID returns the ID of the parent of the specified phase
+// or an empty string for top-level phases
+func parentID(phaseID string) string {
+	if i := strings.LastIndex(phaseID, "/"); i > 0 {
+		return phaseID[:i]
+	}
+	return ""
+}
+
+// planPackages returns the versions of the packages referenced
+// by the plan indexed by package repository and name
+func planPackages(plan storage.OperationPlan) map[string][]string {
+	packages := make(map[string][]string)
+	add := func(locator *loc.Locator) {
+		if locator == nil || locator.IsEmpty() {
+			return
+		}
+		name := fmt.Sprintf("%v/%v", locator.Repository, locator.Name)
+		if !utils.StringInSlice(packages[name], locator.Version) {
+			packages[name] = append(packages[name], locator.Version)
+		}
+	}
+	add(&plan.GravityPackage)
+	for _, phase := range FlattenPlan(&plan) {
+		if phase.Data == nil {
+			continue
+		}
+		add(phase.Data.Package)
+		add(phase.Data.InstalledPackage)
+		add(phase.Data.RuntimePackage)
+		if phase.Data.Update == nil {
+			continue
+		}
+		for _, server := range phase.Data.Update.Servers {
+			add(&server.Runtime.Installed)
+			add(server.Runtime.SecretsPackage)
+			if server.Runtime.Update != nil {
+				add(&server.Runtime.Update.Package)
+				add(&server.Runtime.Update.ConfigPackage)
+			}
+			add(&server.Teleport.Installed)
+			if server.Teleport.Update != nil {
+				add(&server.Teleport.Update.Package)
+				add(&server.Teleport.Update.NodeConfigPackage)
+			}
+		}
+	}
+	for _, versions := range packages {
+		sort.Strings(versions)
+	}
+	return packages
+}
+
+func diffPackages(from, to map[string][]string) (changes []PackageChange) {
+	names := make(map[string]struct{})
+	for name := range from {
+		names[name] = struct{}{}
+	}
+	for name := range to {
+		names[name] = struct{}{}
+	}
+	for name := range names {
+		if strings.Join(from[name], ",") == strings.Join(to[name], ",") {
+			continue
+		}
+		changes = append(changes, PackageChange{
+			Package: name,
+			From:    from[name],
+			To:      to[name],
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Package < changes[j].Package
+	})
+	return changes
+}
+
+func formatPhaseServer(phase storage.OperationPhase) string {
+	if phase.Data == nil || phase.Data.Server == nil {
+		return "-"
+	}
+	return phase.Data.Server.Hostname
+}
+
+func formatPhasePackage(phase storage.OperationPhase) string {
+	if phase.Data == nil || phase.Data.Package == nil {
+		return "-"
+	}
+	return phase.Data.Package.String()
+}
+
+func formatAttempts(phase storage.OperationPhase) string {
+	if phase.Checkpoint == nil {
+		return "0"
+	}
+	return fmt.Sprintf("%v", phase.Checkpoint.Attempts)
+}
+
+// formatPhaseError returns the message of the error the phase has failed with
+func formatPhaseError(phase storage.OperationPhase) string {
+	if phase.Error == nil {
+		return "-"
+	}
+	var phaseErr trace.TraceErr
+	if err := utils.UnmarshalError(phase.Error.Err, &phaseErr); err != nil || phaseErr.Err == nil {
+		return phase.Error.Message
+	}
+	return phaseErr.Err.Error()
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"bytes"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type DiffSuite struct{}
+
+var _ = check.Suite(&DiffSuite{})
+
+func (s *DiffSuite) TestDiffsPlans(c *check.C) {
+	node1 := storage.Server{Hostname: "node-1", AdvertiseIP: "10.0.0.1"}
+	node2 := storage.Server{Hostname: "node-2", AdvertiseIP: "10.0.0.2"}
+	from := storage.OperationPlan{
+		OperationID:    "op-1",
+		GravityPackage: loc.MustParseLocator("gravitational.io/gravity:6.1.0"),
+		Phases: []storage.OperationPhase{
+			{ID: "/init", Description: "Initialize", State: storage.OperationPhaseStateCompleted},
+			{
+				ID:          "/masters",
+				Description: "Update masters",
+				Phases: []storage.OperationPhase{
+					{
+						ID:          "/masters/node-1",
+						Description: "Update node-1",
+						State:       storage.OperationPhaseStateFailed,
+						Data: &storage.OperationPhaseData{
+							Server:  &node1,
+							Package: newLocator("gravitational.io/planet:6.1.0"),
+						},
+						Error:      utils.ToRawTrace(trace.BadParameter("disk is full")),
+						Checkpoint: &storage.PhaseCheckpoint{Attempts: 1},
+					},
+				},
+			},
+			{ID: "/cleanup", Description: "Clean up"},
+		},
+	}
+	to := storage.OperationPlan{
+		OperationID:    "op-2",
+		GravityPackage: loc.MustParseLocator("gravitational.io/gravity:6.1.0"),
+		Phases: []storage.OperationPhase{
+			{ID: "/init", Description: "Initialize", State: storage.OperationPhaseStateCompleted},
+			{
+				ID:          "/masters",
+				Description: "Update masters",
+				Phases: []storage.OperationPhase{
+					{
+						ID:          "/masters/node-1",
+						Description: "Update node-1",
+						State:       storage.OperationPhaseStateCompleted,
+						Data: &storage.OperationPhaseData{
+							Server:  &node1,
+							Package: newLocator("gravitational.io/planet:6.1.1"),
+						},
+						Checkpoint: &storage.PhaseCheckpoint{Attempts: 1},
+					},
+					{
+						ID:          "/masters/node-2",
+						Description: "Update node-2",
+						Data:        &storage.OperationPhaseData{Server: &node2},
+						Phases: []storage.OperationPhase{
+							{ID: "/masters/node-2/drain", Description: "Drain node-2"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	diff := DiffPlans(from, to)
+	c.Assert(diff, check.DeepEquals, PlanDiff{
+		From: "op-1",
+		To:   "op-2",
+		Added: []PhaseSummary{
+			{ID: "/masters/node-2", Description: "Update node-2", State: "Unstarted"},
+		},
+		Removed: []PhaseSummary{
+			{ID: "/cleanup", Description: "Clean up", State: "Unstarted"},
+		},
+		Changed: []PhaseChange{
+			{
+				ID: "/masters/node-1",
+				Fields: []FieldChange{
+					{Name: "package", From: "gravitational.io/planet:6.1.0", To: "gravitational.io/planet:6.1.1"},
+					{Name: "state", From: "Failed", To: "Completed"},
+					{Name: "error", From: "disk is full", To: "-"},
+				},
+			},
+		},
+		Packages: []PackageChange{
+			{Package: "gravitational.io/planet", From: []string{"6.1.0"}, To: []string{"6.1.1"}},
+		},
+	})
+
+	var buf bytes.Buffer
+	FormatPlanDiffText(&buf, diff)
+	c.Assert(buf.String(), check.Matches, "(?s).*Added phases:.*/masters/node-2.*"+
+		"Removed phases:.*/cleanup.*state: Failed -> Completed.*"+
+		"gravitational.io/planet +6.1.0 -> 6.1.1.*")
+}
+
+func (s *DiffSuite) TestIdenticalPlans(c *check.C) {
+	plan := newPlan()
+	diff := DiffPlans(plan, plan)
+	c.Assert(diff.IsEmpty(), check.Equals, true)
+}
+
+func newLocator(locator string) *loc.Locator {
+	parsed := loc.MustParseLocator(locator)
+	return &parsed
+}
@@ -79,7 +79,7 @@ func printPhase(w io.Writer, phase storage.OperationPhase, indent int) {
 		strings.Repeat("  ", indent),
 		marker,
 		formatName(phase.ID),
-		phase.Description,
+		formatDescription(phase),
 		formatState(phase.GetState()),
 		formatNode(phase),
 		formatRequires(phase.Requires),
@@ -121,6 +121,57 @@ func printPhaseShort(w io.Writer, phase storage.OperationPhase, indent int) {
 	}
 }
 
+// FormatPlanDiffText formats the differences between two operation plans as text
+func FormatPlanDiffText(w io.Writer, diff PlanDiff) {
+	if diff.IsEmpty() {
+		fmt.Fprintf(w, "Plans of operations %v and %v are identical.\n", diff.From, diff.To)
+		return
+	}
+	fmt.Fprintf(w, "Comparing plan of operation %v with plan of operation %v.\n", diff.From, diff.To)
+	var t tabwriter.Writer
+	if len(diff.Added) != 0 {
+		fmt.Fprintf(w, "\nAdded phases:\n")
+		t.Init(w, 0, 10, 5, ' ', 0)
+		for _, phase := range diff.Added {
+			fmt.Fprintf(&t, "  + %v\t%v\t%v\n", phase.ID, phase.Description, phase.State)
+		}
+		t.Flush()
+	}
+	if len(diff.Removed) != 0 {
+		fmt.Fprintf(w, "\nRemoved phases:\n")
+		t.Init(w, 0, 10, 5, ' ', 0)
+		for _, phase := range diff.Removed {
+			fmt.Fprintf(&t, "  - %v\t%v\t%v\n", phase.ID, phase.Description, phase.State)
+		}
+		t.Flush()
+	}
+	if len(diff.Changed) != 0 {
+		fmt.Fprintf(w, "\nChanged phases:\n")
+		for _, phase := range diff.Changed {
+			fmt.Fprintf(w, "  ~ %v\n", phase.ID)
+			for _, field := range phase.Fields {
+				fmt.Fprintf(w, "      %v: %v -> %v\n", field.Name, field.From, field.To)
+			}
+		}
+	}
+	if len(diff.Packages) != 0 {
+		fmt.Fprintf(w, "\nPackage versions:\n")
+		t.Init(w, 0, 10, 5, ' ', 0)
+		for _, pkg := range diff.Packages {
+			fmt.Fprintf(&t, "  %v\t%v -> %v\n", pkg.Package,
+				formatVersions(pkg.From), formatVersions(pkg.To))
+		}
+		t.Flush()
+	}
+}
+
+func formatVersions(versions []string) string {
+	if len(versions) == 0 {
+		return "-"
+	}
+	return strings.Join(versions, ",")
+}
+
 func formatNode(phase storage.OperationPhase) string {
 	if phase.Data == nil || phase.Data.ExecServer == nil {
 		return "-"
@@ -133,6 +184,13 @@ func formatName(phaseID string) string {
 	return parts[len(parts)-1]
 }
 
+func formatDescription(phase storage.OperationPhase) string {
+	if phase.Pause {
+		return fmt.Sprintf("%v (pause)", phase.Description)
+	}
+	return phase.Description
+}
+
 func formatRequires(requires []string) string {
 	if len(requires) == 0 {
 		return "-"
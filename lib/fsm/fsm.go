@@ -20,7 +20,9 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"time"
 
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/rpc"
 	"github.com/gravitational/gravity/lib/storage"
@@ -128,6 +130,10 @@ type Config struct {
 	Insecure bool
 	// Logger allows to override default logger
 	Logger logrus.FieldLogger
+	// CancelGracePeriod is how often a phase that has exceeded its timeout
+	// but has not stopped yet is reported while the operation waits for it.
+	// Defaults to defaults.PhaseCancelGracePeriod
+	CancelGracePeriod time.Duration
 }
 
 // CheckAndSetDefaults makes sure the config is valid and sets some defaults
@@ -138,6 +144,9 @@ func (c *Config) CheckAndSetDefaults() error {
 	if c.Logger == nil {
 		c.Logger = logrus.WithField(trace.Component, "fsm")
 	}
+	if c.CancelGracePeriod == 0 {
+		c.CancelGracePeriod = defaults.PhaseCancelGracePeriod
+	}
 	return nil
 }
 
@@ -167,18 +176,28 @@ func (f *FSM) ExecutePlan(ctx context.Context, progress utils.Progress) error {
 			Resume:   true,
 		})
 		if err != nil {
+			if IsPaused(err) {
+				return trace.Wrap(err)
+			}
 			return trace.Wrap(err, "failed to execute phase %q", phase.ID)
 		}
+		if err := checkPause(phase); err != nil {
+			return trace.Wrap(err)
+		}
 	}
 	return nil
 }
 
-// ExecutePhase executes the specified phase of the plan
+// ExecutePhase executes the specified phase of the plan.
+// If the phase ID is a glob pattern, all matching phases are executed
 func (f *FSM) ExecutePhase(ctx context.Context, p Params) error {
 	err := p.CheckAndSetDefaults()
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	if IsPhasePattern(p.PhaseID) {
+		return trace.Wrap(f.executeMatchingPhases(ctx, p))
+	}
 	plan, err := f.GetPlan()
 	if err != nil {
 		return trace.Wrap(err)
@@ -216,12 +235,16 @@ func (f *FSM) ExecutePhase(ctx context.Context, p Params) error {
 	return nil
 }
 
-// RollbackPhase rolls back the specified phase of the plan
+// RollbackPhase rolls back the specified phase of the plan.
+// If the phase ID is a glob pattern, all matching phases are rolled back
 func (f *FSM) RollbackPhase(ctx context.Context, p Params) error {
 	err := p.CheckAndSetDefaults()
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	if IsPhasePattern(p.PhaseID) {
+		return trace.Wrap(f.rollbackMatchingPhases(ctx, p))
+	}
 	plan, err := f.GetPlan()
 	if err != nil {
 		return trace.Wrap(err)
@@ -278,6 +301,77 @@ func (f *FSM) RollbackPhase(ctx context.Context, p Params) error {
 	return nil
 }
 
+// executeMatchingPhases executes the phases matching the phase pattern
+// from params in the order of their dependencies.
+// The phases are executed concurrently if they are all subphases of the same
+// parallel phase and do not depend on each other
+func (f *FSM) executeMatchingPhases(ctx context.Context, p Params) error {
+	plan, err := f.GetPlan()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	phases, err := MatchPhases(plan, p.PhaseID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	f.WithField("phases", phaseIDs(phases)).Infof("Executing phases matching %q.", p.PhaseID)
+	if canExecuteConcurrently(plan, phases) {
+		return trace.Wrap(f.executePhasesConcurrently(ctx, p, phases))
+	}
+	return trace.Wrap(f.executePhasesSequentially(ctx, p, phases))
+}
+
+// rollbackMatchingPhases rolls back the phases matching the phase pattern
+// from params in the reverse order of their execution
+func (f *FSM) rollbackMatchingPhases(ctx context.Context, p Params) error {
+	plan, err := f.GetPlan()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	phases, err := MatchPhases(plan, p.PhaseID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	f.WithField("phases", phaseIDs(phases)).Infof("Rolling back phases matching %q.", p.PhaseID)
+	for i := len(phases) - 1; i >= 0; i-- {
+		p.PhaseID = phases[i].ID
+		if err := f.RollbackPhase(ctx, p); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// canExecuteConcurrently returns true if the specified phases are subphases
+// of the same parallel phase and do not depend on each other
+func canExecuteConcurrently(plan *storage.OperationPlan, phases []storage.OperationPhase) bool {
+	if len(phases) < 2 {
+		return false
+	}
+	parent, err := FindPhase(plan, path.Dir(phases[0].ID))
+	if err != nil || !parent.Parallel {
+		return false
+	}
+	for _, phase := range phases {
+		if path.Dir(phase.ID) != parent.ID {
+			return false
+		}
+		for _, other := range phases {
+			if other.ID != phase.ID && dependsOn(plan, phase, other.ID) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func phaseIDs(phases []storage.OperationPhase) (ids []string) {
+	for _, phase := range phases {
+		ids = append(ids, phase.ID)
+	}
+	return ids
+}
+
 // ChangePhaseState updates the specified phase state.
 func (f *FSM) ChangePhaseState(ctx context.Context, change StateChange) error {
 	if err := change.Check(); err != nil {
@@ -376,7 +470,35 @@ func (f *FSM) executePhaseRemotely(ctx context.Context, p Params, phase storage.
 	p.Progress.NextStep("Executing %q on remote node %v", phase.ID,
 		server.Hostname)
 
-	return f.RunCommand(ctx, f.Runner, server, p)
+	if timeout := phase.GetTimeout(); timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	logs := newTailBuffer(defaults.PhaseLogsMaxSize)
+	err := f.RunCommand(ctx, &logRunner{RemoteRunner: f.Runner, w: logs}, server, p)
+	f.attachLogs(ctx, phase.ID, logs)
+	return trace.Wrap(err)
+}
+
+// attachLogs records the output captured while executing the specified
+// phase on a remote node
+func (f *FSM) attachLogs(ctx context.Context, phaseID string, logs *tailBuffer) {
+	if logs.Len() == 0 {
+		return
+	}
+	compressed, err := CompressLogs(logs.Bytes())
+	if err != nil {
+		f.WithError(err).Warnf("Failed to compress logs of phase %v.", phaseID)
+		return
+	}
+	err = f.Engine.ChangePhaseState(ctx, StateChange{
+		Phase: phaseID,
+		Logs:  compressed,
+	})
+	if err != nil {
+		f.WithError(err).Warnf("Failed to record logs of phase %v.", phaseID)
+	}
 }
 
 // executePhaseLocally executes the specified operation phase on this server
@@ -392,19 +514,59 @@ func (f *FSM) executePhaseLocally(ctx context.Context, p Params, phase storage.O
 }
 
 func (f *FSM) executeSubphasesSequentially(ctx context.Context, p Params, phase storage.OperationPhase) error {
-	for _, subphase := range phase.Phases {
+	return trace.Wrap(f.executePhasesSequentially(ctx, p, phase.Phases))
+}
+
+func (f *FSM) executePhasesSequentially(ctx context.Context, p Params, phases []storage.OperationPhase) error {
+	for _, subphase := range phases {
 		p.PhaseID = subphase.ID
 		err := f.ExecutePhase(ctx, p)
 		if err != nil {
 			return trace.Wrap(err)
 		}
+		if err := checkPause(subphase); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// checkPause returns PausedError if the specified phase is a pause point
+// that has just been executed.
+// A phase that had been completed before the execution started is resumed past
+func checkPause(phase storage.OperationPhase) error {
+	if phase.Pause && !phase.IsCompleted() {
+		return &PausedError{PhaseID: phase.ID}
 	}
 	return nil
 }
 
+// PausedError is returned when plan execution stops after a phase
+// marked as a pause point
+type PausedError struct {
+	// PhaseID is the ID of the phase the execution has been paused after
+	PhaseID string
+}
+
+// Error returns the error message
+func (r *PausedError) Error() string {
+	return fmt.Sprintf("operation paused after phase %q", r.PhaseID)
+}
+
+// IsPaused returns true if the specified error indicates that
+// plan execution has been paused
+func IsPaused(err error) bool {
+	_, ok := trace.Unwrap(err).(*PausedError)
+	return ok
+}
+
 func (f *FSM) executeSubphasesConcurrently(ctx context.Context, p Params, phase storage.OperationPhase) error {
-	errorsCh := make(chan error, len(phase.Phases))
-	for _, subphase := range phase.Phases {
+	return trace.Wrap(f.executePhasesConcurrently(ctx, p, phase.Phases))
+}
+
+func (f *FSM) executePhasesConcurrently(ctx context.Context, p Params, phases []storage.OperationPhase) error {
+	errorsCh := make(chan error, len(phases))
+	for _, subphase := range phases {
 		go func(p Params, subphase storage.OperationPhase) {
 			p.PhaseID = subphase.ID
 			err := f.ExecutePhase(ctx, p)
@@ -451,9 +613,12 @@ func (f *FSM) executeOnePhase(ctx context.Context, p Params, phase storage.Opera
 
 	executor.Infof("Executing phase: %v.", phase.ID)
 
-	err = executor.Execute(ctx)
+	err = runWithTimeout(ctx, executor, phase, f.CancelGracePeriod, executor.Execute)
 	if err != nil {
 		executor.Errorf("Phase execution failed: %v.", err)
+		if trace.IsLimitExceeded(err) {
+			f.rollbackTimedOutPhase(ctx, executor, phase)
+		}
 		if err := f.ChangePhaseState(ctx,
 			StateChange{
 				Phase: phase.ID,
@@ -506,7 +671,7 @@ func (f *FSM) rollbackPhase(ctx context.Context, p Params, phase storage.Operati
 		return trace.Wrap(err)
 	}
 
-	err = executor.Rollback(ctx)
+	err = runWithTimeout(ctx, executor, phase, f.CancelGracePeriod, executor.Rollback)
 	if err != nil {
 		executor.Errorf("Phase %v rollback failed: %v.", phase.ID, err)
 		if err := f.ChangePhaseState(ctx,
@@ -532,6 +697,17 @@ func (f *FSM) rollbackPhase(ctx context.Context, p Params, phase storage.Operati
 	return nil
 }
 
+// rollbackTimedOutPhase rolls back the phase that has exceeded its timeout.
+// The executor is guaranteed to have stopped by now
+func (f *FSM) rollbackTimedOutPhase(ctx context.Context, executor PhaseExecutor, phase storage.OperationPhase) {
+	executor.Infof("Rolling back timed out phase %v.", phase.ID)
+	err := runWithTimeout(ctx, executor, phase, f.CancelGracePeriod, executor.Rollback)
+	if err != nil {
+		executor.Warnf("Failed to roll back timed out phase %v: %v.",
+			phase.ID, trace.DebugReport(err))
+	}
+}
+
 // prerequisitesComplete checks if specified phase can be executed in the
 // provided plan
 func (f *FSM) prerequisitesComplete(phaseID string) error {
@@ -566,6 +742,9 @@ type StateChange struct {
 	State string
 	// Error is the error that happened during phase execution
 	Error trace.Error
+	// Logs is the compressed output of the phase execution on a remote node.
+	// A change with logs but without a state only attaches the logs to the phase
+	Logs []byte
 }
 
 // Check verifies that state change is valid.
@@ -573,6 +752,9 @@ func (c StateChange) Check() error {
 	if c.Phase == "" {
 		return trace.BadParameter("phase name must not be empty")
 	}
+	if c.State == "" && len(c.Logs) != 0 {
+		return nil
+	}
 	if !storage.IsValidOperationPhaseState(c.State) {
 		return trace.BadParameter("unknown phase state %q, supported are: %v",
 			c.State, storage.OperationPhaseStates)
@@ -580,6 +762,12 @@ func (c StateChange) Check() error {
 	return nil
 }
 
+// IsTransient returns true if the state change records a failure
+// caused by a transient error
+func (c StateChange) IsTransient() bool {
+	return c.Error != nil && utils.IsTransientClusterError(c.Error)
+}
+
 // String returns a textual representation of this state change
 func (c StateChange) String() string {
 	if c.Error != nil {
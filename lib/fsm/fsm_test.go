@@ -0,0 +1,312 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitational/gravity/lib/rpc"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/check.v1"
+)
+
+func TestFSM(t *testing.T) { check.TestingT(t) }
+
+type FSMSuite struct{}
+
+var _ = check.Suite(&FSMSuite{})
+
+func (s *FSMSuite) TestTimedOutPhaseIsRolledBackAndFailed(c *check.C) {
+	executor := &testExecutor{
+		FieldLogger: logrus.WithField("phase", "/init"),
+		execute: func(ctx context.Context) error {
+			<-ctx.Done()
+			return trace.Wrap(ctx.Err())
+		},
+	}
+	engine := newTestEngine(executor, time.Millisecond)
+	fsm, err := New(Config{Engine: engine, CancelGracePeriod: time.Second})
+	c.Assert(err, check.IsNil)
+
+	err = fsm.ExecutePhase(context.TODO(), Params{PhaseID: "/init"})
+	c.Assert(trace.IsLimitExceeded(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(executor.rolledBack, check.Equals, true)
+	c.Assert(engine.state("/init"), check.Equals, storage.OperationPhaseStateFailed)
+}
+
+func (s *FSMSuite) TestWaitsForPhaseIgnoringCancellation(c *check.C) {
+	var stopped bool
+	executor := &testExecutor{
+		FieldLogger: logrus.WithField("phase", "/init"),
+		execute: func(context.Context) error {
+			time.Sleep(50 * time.Millisecond)
+			stopped = true
+			return nil
+		},
+	}
+	engine := newTestEngine(executor, time.Millisecond)
+	fsm, err := New(Config{Engine: engine, CancelGracePeriod: time.Millisecond})
+	c.Assert(err, check.IsNil)
+
+	err = fsm.ExecutePhase(context.TODO(), Params{PhaseID: "/init"})
+	c.Assert(trace.IsLimitExceeded(err), check.Equals, true, check.Commentf("%v", err))
+	// the executor must have stopped before the phase is rolled back
+	c.Assert(stopped, check.Equals, true)
+	c.Assert(executor.rolledBack, check.Equals, true)
+	c.Assert(engine.state("/init"), check.Equals, storage.OperationPhaseStateFailed)
+}
+
+func (s *FSMSuite) TestSerializesPhaseTimeoutAsDuration(c *check.C) {
+	phase := storage.OperationPhase{
+		ID:      "/drain",
+		Timeout: &teleservices.Duration{Duration: 90 * time.Minute},
+	}
+	bytes, err := json.Marshal(phase)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(bytes), check.Matches, `.*"timeout":"1h30m0s".*`)
+
+	var decoded storage.OperationPhase
+	c.Assert(json.Unmarshal(bytes, &decoded), check.IsNil)
+	c.Assert(decoded.GetTimeout(), check.Equals, 90*time.Minute)
+}
+
+func (s *FSMSuite) TestPhaseWithinTimeoutCompletes(c *check.C) {
+	executor := &testExecutor{
+		FieldLogger: logrus.WithField("phase", "/init"),
+		execute:     func(context.Context) error { return nil },
+	}
+	engine := newTestEngine(executor, time.Minute)
+	fsm, err := New(Config{Engine: engine})
+	c.Assert(err, check.IsNil)
+
+	c.Assert(fsm.ExecutePhase(context.TODO(), Params{PhaseID: "/init"}), check.IsNil)
+	c.Assert(executor.rolledBack, check.Equals, false)
+	c.Assert(engine.state("/init"), check.Equals, storage.OperationPhaseStateCompleted)
+}
+
+func (s *FSMSuite) TestPausesAfterPausePoint(c *check.C) {
+	executor := &testExecutor{
+		FieldLogger: logrus.WithField("phase", "/init"),
+		execute:     func(context.Context) error { return nil },
+	}
+	engine := newTestEngine(executor, time.Minute)
+	engine.plan.Phases = append(engine.plan.Phases,
+		storage.OperationPhase{ID: "/etcd", Executor: "etcd"},
+		storage.OperationPhase{ID: "/finish", Executor: "finish"})
+	c.Assert(SetPausePoints(&engine.plan, []string{"/etcd"}), check.IsNil)
+	fsm, err := New(Config{Engine: engine})
+	c.Assert(err, check.IsNil)
+
+	err = fsm.ExecutePlan(context.TODO(), utils.DiscardProgress)
+	c.Assert(IsPaused(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(engine.state("/etcd"), check.Equals, storage.OperationPhaseStateCompleted)
+	c.Assert(engine.state("/finish"), check.Not(check.Equals), storage.OperationPhaseStateCompleted)
+
+	c.Assert(fsm.ExecutePlan(context.TODO(), utils.DiscardProgress), check.IsNil)
+	c.Assert(engine.state("/finish"), check.Equals, storage.OperationPhaseStateCompleted)
+}
+
+func (s *FSMSuite) TestValidatesPausePoints(c *check.C) {
+	plan := storage.OperationPlan{
+		Phases: []storage.OperationPhase{{
+			ID:       "/masters",
+			Parallel: true,
+			Phases:   []storage.OperationPhase{{ID: "/masters/node-1"}},
+		}},
+	}
+	c.Assert(trace.IsNotFound(SetPausePoints(&plan, []string{"/etcd"})), check.Equals, true)
+	c.Assert(trace.IsBadParameter(SetPausePoints(&plan, []string{"/masters/node-1"})), check.Equals, true)
+	c.Assert(SetPausePoints(&plan, []string{"/masters"}), check.IsNil)
+	c.Assert(plan.Phases[0].Pause, check.Equals, true)
+}
+
+func (s *FSMSuite) TestMatchesPhasePatterns(c *check.C) {
+	plan := storage.OperationPlan{
+		Phases: []storage.OperationPhase{
+			{
+				ID:       "/pull",
+				Parallel: true,
+				Phases: []storage.OperationPhase{
+					{ID: "/pull/node-1"},
+					{ID: "/pull/node-2"},
+					{ID: "/pull/other"},
+				},
+			},
+			{
+				ID: "/masters",
+				Phases: []storage.OperationPhase{
+					{
+						ID:       "/masters/node-2",
+						Requires: []string{"/masters/node-1"},
+						Phases:   []storage.OperationPhase{{ID: "/masters/node-2/drain"}},
+					},
+					{
+						ID:     "/masters/node-1",
+						Phases: []storage.OperationPhase{{ID: "/masters/node-1/drain"}},
+					},
+				},
+			},
+		},
+	}
+	var tests = []struct {
+		pattern    string
+		phases     []string
+		concurrent bool
+		comment    string
+	}{
+		{
+			pattern:    "/pull/node-*",
+			phases:     []string{"/pull/node-1", "/pull/node-2"},
+			concurrent: true,
+			comment:    "independent subphases of a parallel phase",
+		},
+		{
+			pattern: "/masters/*",
+			phases:  []string{"/masters/node-1", "/masters/node-2"},
+			comment: "ordered by dependencies, nested phases are not matched",
+		},
+		{
+			pattern: "/masters/*/drain",
+			phases:  []string{"/masters/node-1/drain", "/masters/node-2/drain"},
+			comment: "dependencies of the parent phases are respected",
+		},
+	}
+	for _, tc := range tests {
+		comment := check.Commentf(tc.comment)
+		phases, err := MatchPhases(&plan, tc.pattern)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(phaseIDs(phases), check.DeepEquals, tc.phases, comment)
+		c.Assert(canExecuteConcurrently(&plan, phases), check.Equals, tc.concurrent, comment)
+	}
+	_, err := MatchPhases(&plan, "/etcd/*")
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+	_, err = MatchPhases(&plan, "/masters/[")
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
+
+func (s *FSMSuite) TestSelectsServerPhases(c *check.C) {
+	node1 := &storage.Server{AdvertiseIP: "10.0.0.1"}
+	node2 := &storage.Server{AdvertiseIP: "10.0.0.2"}
+	plan := storage.OperationPlan{
+		Phases: []storage.OperationPhase{
+			{ID: "/init", Data: &storage.OperationPhaseData{Server: node1}},
+			{
+				ID:   "/masters",
+				Data: &storage.OperationPhaseData{Server: node2},
+				Phases: []storage.OperationPhase{
+					{ID: "/masters/node-1", Data: &storage.OperationPhaseData{Server: node1}},
+					{ID: "/masters/node-2", Data: &storage.OperationPhaseData{Server: node2}},
+				},
+			},
+			{ID: "/taint", Data: &storage.OperationPhaseData{ExecServer: node2, Server: node1}},
+			{ID: "/app"},
+		},
+	}
+	c.Assert(phaseIDs(ServerPhases(plan, "10.0.0.2")), check.DeepEquals,
+		[]string{"/masters/node-2", "/taint"})
+	c.Assert(phaseIDs(ServerPhases(plan, "10.0.0.1")), check.DeepEquals,
+		[]string{"/init", "/masters/node-1", "/taint"})
+	c.Assert(ServerPhases(plan, "10.0.0.3"), check.HasLen, 0)
+}
+
+func newTestEngine(executor PhaseExecutor, timeout time.Duration) *testEngine {
+	var phaseTimeout *teleservices.Duration
+	if timeout != 0 {
+		phaseTimeout = &teleservices.Duration{Duration: timeout}
+	}
+	return &testEngine{
+		executor: executor,
+		plan: storage.OperationPlan{
+			OperationID: "operation-1",
+			Phases: []storage.OperationPhase{{
+				ID:       "/init",
+				Executor: "init",
+				Timeout:  phaseTimeout,
+			}},
+		},
+	}
+}
+
+type testEngine struct {
+	sync.Mutex
+	executor PhaseExecutor
+	plan     storage.OperationPlan
+}
+
+func (r *testEngine) GetExecutor(ExecutorParams, Remote) (PhaseExecutor, error) {
+	return r.executor, nil
+}
+
+func (r *testEngine) ChangePhaseState(ctx context.Context, change StateChange) error {
+	r.Lock()
+	defer r.Unlock()
+	for i, phase := range r.plan.Phases {
+		if phase.ID == change.Phase {
+			r.plan.Phases[i].State = change.State
+		}
+	}
+	return nil
+}
+
+func (r *testEngine) GetPlan() (*storage.OperationPlan, error) {
+	r.Lock()
+	defer r.Unlock()
+	plan := r.plan
+	plan.Phases = append([]storage.OperationPhase(nil), r.plan.Phases...)
+	return &plan, nil
+}
+
+func (r *testEngine) RunCommand(context.Context, rpc.RemoteRunner, storage.Server, Params) error {
+	return trace.NotImplemented("not implemented")
+}
+
+func (r *testEngine) Complete(error) error {
+	return nil
+}
+
+func (r *testEngine) state(phaseID string) string {
+	plan, _ := r.GetPlan()
+	phase, _ := FindPhase(plan, phaseID)
+	return phase.State
+}
+
+type testExecutor struct {
+	logrus.FieldLogger
+	execute    func(context.Context) error
+	rolledBack bool
+}
+
+func (r *testExecutor) PreCheck(context.Context) error  { return nil }
+func (r *testExecutor) PostCheck(context.Context) error { return nil }
+
+func (r *testExecutor) Execute(ctx context.Context) error {
+	return r.execute(ctx)
+}
+
+func (r *testExecutor) Rollback(context.Context) error {
+	r.rolledBack = true
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/rpc"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+// OutputRunner is a remote runner that can capture the output
+// of the commands it runs
+type OutputRunner interface {
+	// RunWithOutput executes a command on a remote node
+	// writing its output into w
+	RunWithOutput(ctx context.Context, server storage.Server, w io.Writer, command ...string) error
+}
+
+// CompressLogs compresses the specified phase execution logs
+func CompressLogs(logs []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(logs); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressLogs decompresses the phase execution logs
+// compressed with CompressLogs
+func DecompressLogs(compressed []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer r.Close()
+	logs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return logs, nil
+}
+
+// logRunner is a remote runner that captures the output
+// of the remote commands into w
+type logRunner struct {
+	rpc.RemoteRunner
+	w io.Writer
+}
+
+// Run executes a command on a remote node capturing its output
+// if the underlying runner supports it
+func (r *logRunner) Run(ctx context.Context, server storage.Server, command ...string) error {
+	if runner, ok := r.RemoteRunner.(OutputRunner); ok {
+		return runner.RunWithOutput(ctx, server, r.w, command...)
+	}
+	return r.RemoteRunner.Run(ctx, server, command...)
+}
+
+// newTailBuffer returns a buffer that keeps at most the last size bytes
+// written to it
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+// tailBuffer is a buffer that keeps the last written bytes
+// up to the configured size
+type tailBuffer struct {
+	sync.Mutex
+	size      int
+	buf       []byte
+	truncated bool
+}
+
+// Write appends p to the buffer discarding the oldest data
+// beyond the buffer size
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-b.size:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// Len returns the number of bytes in the buffer
+func (b *tailBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+	return len(b.buf)
+}
+
+// Bytes returns the buffer contents prefixed with a marker
+// if the older data has been discarded
+func (b *tailBuffer) Bytes() []byte {
+	b.Lock()
+	defer b.Unlock()
+	if !b.truncated {
+		return append([]byte(nil), b.buf...)
+	}
+	return append([]byte(truncatedLogsMarker), b.buf...)
+}
+
+const truncatedLogsMarker = "...(truncated)\n"
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"gopkg.in/check.v1"
+)
+
+type LogsSuite struct{}
+
+var _ = check.Suite(&LogsSuite{})
+
+func (s *LogsSuite) TestKeepsLogsTail(c *check.C) {
+	buf := newTailBuffer(8)
+	buf.Write([]byte("abcd"))
+	c.Assert(string(buf.Bytes()), check.Equals, "abcd")
+	buf.Write([]byte("efghij"))
+	c.Assert(buf.Len(), check.Equals, 8)
+	c.Assert(string(buf.Bytes()), check.Equals, truncatedLogsMarker+"cdefghij")
+}
+
+func (s *LogsSuite) TestCompressesLogs(c *check.C) {
+	compressed, err := CompressLogs([]byte("phase output"))
+	c.Assert(err, check.IsNil)
+	logs, err := DecompressLogs(compressed)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(logs), check.Equals, "phase output")
+}
+
+func (s *LogsSuite) TestResolvesPhaseLogs(c *check.C) {
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	changelog := storage.PlanChangelog{
+		newChange("/init", storage.OperationPhaseStateInProgress, created, nil),
+		newChange("/init", storage.OperationPhaseStateFailed, created.Add(time.Minute), nil),
+		newLogsChange("/init", "first attempt", created.Add(2*time.Minute)),
+		newChange("/init", storage.OperationPhaseStateInProgress, created.Add(3*time.Minute), nil),
+		newChange("/init", storage.OperationPhaseStateFailed, created.Add(4*time.Minute), nil),
+		newLogsChange("/init", "second attempt", created.Add(5*time.Minute)),
+	}
+	plan := ResolvePlan(newPlan(), changelog)
+	c.Assert(plan.Phases[0].State, check.Equals, storage.OperationPhaseStateFailed)
+	c.Assert(plan.Phases[0].Updated, check.Equals, created.Add(4*time.Minute))
+	c.Assert(plan.Phases[0].Checkpoint.Attempts, check.Equals, 2)
+	c.Assert(string(plan.Phases[0].Checkpoint.Logs), check.Equals, "second attempt")
+}
+
+func newLogsChange(phaseID, logs string, created time.Time) storage.PlanChange {
+	return storage.PlanChange{
+		PhaseID: phaseID,
+		Logs:    []byte(logs),
+		Created: created,
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// AutoResumeConfig defines the configuration for resuming an operation
+// automatically after transient failures
+type AutoResumeConfig struct {
+	// Resume resumes the operation
+	Resume func(context.Context) error
+	// GetPlan returns the up-to-date operation plan
+	GetPlan func() (*storage.OperationPlan, error)
+	// BackOff specifies the interval between attempts
+	BackOff backoff.BackOff
+	// OnRetry is an optional callback invoked with the failed phases
+	// before the operation is resumed again
+	OnRetry func(failed []storage.OperationPhase)
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+}
+
+// CheckAndSetDefaults validates the config and sets default values
+func (r *AutoResumeConfig) CheckAndSetDefaults() error {
+	if r.Resume == nil {
+		return trace.BadParameter("Resume is required")
+	}
+	if r.GetPlan == nil {
+		return trace.BadParameter("GetPlan is required")
+	}
+	if r.BackOff == nil {
+		return trace.BadParameter("BackOff is required")
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = logrus.WithField(trace.Component, "fsm")
+	}
+	return nil
+}
+
+// AutoResume resumes the operation until it either completes or fails
+// with a permanent error.
+// The failure is considered transient if all failed phases have last failed
+// with a transient error according to their checkpoints.
+// Returns the last error if the backoff interval has been exhausted
+func AutoResume(ctx context.Context, config AutoResumeConfig) error {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(utils.RetryWithInterval(ctx, config.BackOff, func() error {
+		err := config.Resume(ctx)
+		if err == nil {
+			return nil
+		}
+		plan, planErr := config.GetPlan()
+		if planErr != nil {
+			config.WithError(planErr).Warn("Failed to query operation plan.")
+			return &backoff.PermanentError{Err: err}
+		}
+		if !IsTransientFailure(plan, err) {
+			return &backoff.PermanentError{Err: err}
+		}
+		failed := FailedPhases(plan)
+		for _, phase := range failed {
+			config.WithFields(logrus.Fields{
+				"phase":    phase.ID,
+				"attempts": phase.Checkpoint.Attempts,
+			}).Info("Phase failed with transient error, will retry.")
+		}
+		if config.OnRetry != nil {
+			config.OnRetry(failed)
+		}
+		return trace.Wrap(err)
+	}))
+}
+
+// IsTransientFailure returns true if the specified plan has failed due to
+// transient errors and can be resumed.
+// If the plan has no failed phases, err is classified instead
+func IsTransientFailure(plan *storage.OperationPlan, err error) bool {
+	failed := FailedPhases(plan)
+	if len(failed) == 0 {
+		return utils.IsTransientClusterError(err)
+	}
+	for _, phase := range failed {
+		if phase.Checkpoint == nil || !phase.Checkpoint.Transient {
+			return false
+		}
+	}
+	return true
+}
+
+// FailedPhases returns the failed leaf phases of the specified plan
+func FailedPhases(plan *storage.OperationPlan) (failed []storage.OperationPhase) {
+	for _, phase := range FlattenPlan(plan) {
+		if !phase.HasSubphases() && phase.IsFailed() {
+			failed = append(failed, *phase)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type ResumeSuite struct{}
+
+var _ = check.Suite(&ResumeSuite{})
+
+func (s *ResumeSuite) TestResolvesCheckpoints(c *check.C) {
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	changelog := storage.PlanChangelog{
+		newChange("/init", storage.OperationPhaseStateInProgress, created, nil),
+		newChange("/init", storage.OperationPhaseStateFailed, created.Add(time.Minute),
+			trace.ConnectionProblem(nil, "connection refused")),
+		newChange("/init", storage.OperationPhaseStateInProgress, created.Add(2*time.Minute), nil),
+		newChange("/init", storage.OperationPhaseStateFailed, created.Add(3*time.Minute),
+			trace.BadParameter("invalid configuration")),
+	}
+	plan := ResolvePlan(newPlan(), changelog)
+
+	c.Assert(plan.Phases[0].Checkpoint, check.DeepEquals, &storage.PhaseCheckpoint{
+		Attempts:  2,
+		Started:   created,
+		Updated:   created.Add(3 * time.Minute),
+		LastError: changelog[3].Error,
+		Transient: false,
+	})
+	c.Assert(plan.Phases[1].Checkpoint, check.IsNil)
+	c.Assert(IsTransientFailure(plan, nil), check.Equals, false)
+
+	changelog = changelog[:2]
+	plan = ResolvePlan(newPlan(), changelog)
+	c.Assert(plan.Phases[0].Checkpoint.Attempts, check.Equals, 1)
+	c.Assert(plan.Phases[0].Checkpoint.Transient, check.Equals, true)
+	c.Assert(IsTransientFailure(plan, nil), check.Equals, true)
+}
+
+func (s *ResumeSuite) TestRetriesTransientFailures(c *check.C) {
+	created := time.Now().UTC()
+	changelog := storage.PlanChangelog{
+		newChange("/init", storage.OperationPhaseStateInProgress, created, nil),
+		newChange("/init", storage.OperationPhaseStateFailed, created.Add(time.Second),
+			trace.ConnectionProblem(nil, "connection refused")),
+	}
+	var attempts int
+	err := AutoResume(context.TODO(), AutoResumeConfig{
+		Resume: func(context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return trace.BadParameter("phase /init failed")
+			}
+			return nil
+		},
+		GetPlan: func() (*storage.OperationPlan, error) {
+			return ResolvePlan(newPlan(), changelog), nil
+		},
+		BackOff: backoff.NewConstantBackOff(time.Millisecond),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.Equals, 3)
+}
+
+func (s *ResumeSuite) TestStopsOnPermanentFailure(c *check.C) {
+	created := time.Now().UTC()
+	changelog := storage.PlanChangelog{
+		newChange("/init", storage.OperationPhaseStateInProgress, created, nil),
+		newChange("/init", storage.OperationPhaseStateFailed, created.Add(time.Second),
+			trace.BadParameter("invalid configuration")),
+	}
+	var attempts int
+	err := AutoResume(context.TODO(), AutoResumeConfig{
+		Resume: func(context.Context) error {
+			attempts++
+			return trace.BadParameter("phase /init failed")
+		},
+		GetPlan: func() (*storage.OperationPlan, error) {
+			return ResolvePlan(newPlan(), changelog), nil
+		},
+		BackOff: backoff.NewConstantBackOff(time.Millisecond),
+	})
+	c.Assert(err, check.NotNil)
+	c.Assert(attempts, check.Equals, 1)
+}
+
+func newPlan() storage.OperationPlan {
+	return storage.OperationPlan{
+		OperationID: "operation-1",
+		Phases: []storage.OperationPhase{
+			{ID: "/init", Executor: "init"},
+			{ID: "/checks", Executor: "checks"},
+		},
+	}
+}
+
+func newChange(phaseID, state string, created time.Time, err error) storage.PlanChange {
+	change := StateChange{Phase: phaseID, State: state}
+	if err != nil {
+		change.Error = trace.Wrap(err)
+	}
+	return storage.PlanChange{
+		PhaseID:   phaseID,
+		NewState:  state,
+		Created:   created,
+		Error:     utils.ToRawTrace(change.Error),
+		Transient: change.IsTransient(),
+	}
+}
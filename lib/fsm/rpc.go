@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 	"path/filepath"
 	"sync"
@@ -90,6 +91,13 @@ func NewAgentRunner(creds credentials.TransportCredentials) *agentRunner {
 // Run executes a command on the remote server
 // Implements rpc.RemoteRunner
 func (r *agentRunner) Run(ctx context.Context, server storage.Server, args ...string) error {
+	return r.RunWithOutput(ctx, server, nil, args...)
+}
+
+// RunWithOutput executes a command on the remote server writing
+// its output into w
+// Implements OutputRunner
+func (r *agentRunner) RunWithOutput(ctx context.Context, server storage.Server, w io.Writer, args ...string) error {
 	logger := r.WithFields(logrus.Fields{
 		"gravity": args,
 		"server":  serverName(server),
@@ -106,6 +114,9 @@ func (r *agentRunner) Run(ctx context.Context, server storage.Server, args ...st
 	case CanRunLocally:
 		logger.Debug("Executing locally.")
 		out, err := RunCommand(append([]string{constants.GravityBin}, args...))
+		if w != nil {
+			w.Write(out)
+		}
 		if err != nil {
 			logger.Warnf("Failed to execute gravity command %q: %s (%v).",
 				args, out, trace.DebugReport(err))
@@ -119,7 +130,7 @@ func (r *agentRunner) Run(ctx context.Context, server storage.Server, args ...st
 				args, serverName(server))
 		}
 		logger.Debug("Executing remotely: ", args)
-		err = agent.GravityCommand(ctx, logger, nil, args...)
+		err = agent.GravityCommand(ctx, logger, w, args...)
 		return trace.Wrap(err)
 	default:
 		return trace.Errorf("internal error, canExecute=%v", canRun)
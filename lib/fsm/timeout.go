@@ -0,0 +1,77 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fsm
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// runWithTimeout invokes fn with the context bound by the phase timeout.
+//
+// If the phase has no timeout, fn is invoked with the provided context.
+// Otherwise, once the timeout expires (or the parent context is canceled),
+// fn is expected to observe the cancellation and return.
+// fn is never abandoned: a retry of the phase (or its rollback) would
+// otherwise race with the still running executor. Instead, the executor
+// that has not stopped within the grace period is reported with logger
+// every grace period until it returns.
+//
+// If the phase has timed out, the returned error is trace.LimitExceeded
+func runWithTimeout(ctx context.Context, logger logrus.FieldLogger, phase storage.OperationPhase, gracePeriod time.Duration, fn func(context.Context) error) error {
+	timeout := phase.GetTimeout()
+	if timeout == 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn(ctx)
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return newPhaseTimeoutError(phase)
+		}
+		return trace.Wrap(err)
+	case <-ctx.Done():
+	}
+	ticker := time.NewTicker(gracePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-errCh:
+			if ctx.Err() == context.DeadlineExceeded {
+				return newPhaseTimeoutError(phase)
+			}
+			return trace.Wrap(ctx.Err())
+		case <-ticker.C:
+			logger.Warnf("Phase %v has been canceled but has not stopped yet, waiting.", phase.ID)
+		}
+	}
+}
+
+func newPhaseTimeoutError(phase storage.OperationPhase) error {
+	return trace.LimitExceeded("phase %q has not completed within %v",
+		phase.ID, phase.GetTimeout())
+}
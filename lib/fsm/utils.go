@@ -17,6 +17,9 @@ limitations under the License.
 package fsm
 
 import (
+	"path"
+	"strings"
+
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
@@ -90,6 +93,141 @@ func FindPhase(plan *storage.OperationPlan, phaseID string) (*storage.OperationP
 	return nil, trace.NotFound("phase %q not found", phaseID)
 }
 
+// IsPhasePattern returns true if the specified phase ID is a glob pattern
+// that can match multiple phases, for example, /masters/*
+func IsPhasePattern(phaseID string) bool {
+	return strings.ContainsAny(phaseID, "*?[")
+}
+
+// MatchPhases returns the phases of the provided plan with IDs matching
+// the specified glob pattern ordered by their dependencies.
+// Phases nested in another matching phase are not returned since they are
+// executed as part of their parent.
+// As with path.Match, a wildcard does not match across the '/' separator
+func MatchPhases(plan *storage.OperationPlan, pattern string) (phases []storage.OperationPhase, err error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, trace.BadParameter("invalid phase pattern %q: %v", pattern, err)
+	}
+	for _, phase := range FlattenPlan(plan) {
+		if len(phases) != 0 && strings.HasPrefix(phase.ID, phases[len(phases)-1].ID+"/") {
+			continue
+		}
+		if matched, _ := path.Match(pattern, phase.ID); matched {
+			phases = append(phases, *phase)
+		}
+	}
+	if len(phases) == 0 {
+		return nil, trace.NotFound("no phases match %q", pattern)
+	}
+	return orderPhases(plan, phases), nil
+}
+
+// orderPhases sorts the specified phases so that each phase comes after
+// the phases it requires. Independent phases keep their order in the plan
+func orderPhases(plan *storage.OperationPlan, phases []storage.OperationPhase) (result []storage.OperationPhase) {
+	done := make([]bool, len(phases))
+	for len(result) < len(phases) {
+		next := -1
+		for i := range phases {
+			if !done[i] && !requiresPending(plan, phases[i], phases, done) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			// Dependency cycle, fall back to the plan order for the rest
+			for i := range phases {
+				if !done[i] {
+					next = i
+					break
+				}
+			}
+		}
+		done[next] = true
+		result = append(result, phases[next])
+	}
+	return result
+}
+
+// requiresPending returns true if the phase requires any of the phases
+// that have not been ordered yet
+func requiresPending(plan *storage.OperationPlan, phase storage.OperationPhase, phases []storage.OperationPhase, done []bool) bool {
+	for i, other := range phases {
+		if done[i] || other.ID == phase.ID {
+			continue
+		}
+		if dependsOn(plan, phase, other.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependsOn returns true if the phase, any of its subphases or any of
+// its parents requires the phase with the specified ID, any of its
+// subphases or any of its parents
+func dependsOn(plan *storage.OperationPlan, phase storage.OperationPhase, phaseID string) bool {
+	for parentID := path.Dir(phase.ID); parentID != RootPhase; parentID = path.Dir(parentID) {
+		parent, err := FindPhase(plan, parentID)
+		if err != nil {
+			break
+		}
+		if requiresAny(parent.Requires, phaseID) {
+			return true
+		}
+	}
+	return subphasesDependOn(phase, phaseID)
+}
+
+func subphasesDependOn(phase storage.OperationPhase, phaseID string) bool {
+	if requiresAny(phase.Requires, phaseID) {
+		return true
+	}
+	for _, subphase := range phase.Phases {
+		if subphasesDependOn(subphase, phaseID) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiresAny returns true if the requirements include the phase with
+// the specified ID, any of its subphases or any of its parents
+func requiresAny(requires []string, phaseID string) bool {
+	for _, required := range requires {
+		if required == phaseID ||
+			strings.HasPrefix(required, phaseID+"/") ||
+			strings.HasPrefix(phaseID, required+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPausePoints marks the phases with the specified IDs as pause points.
+// Plan execution stops after a pause point has been completed
+func SetPausePoints(plan *storage.OperationPlan, phaseIDs []string) error {
+	for _, phaseID := range phaseIDs {
+		phase, err := FindPhase(plan, phaseID)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		for _, parent := range FlattenPlan(plan) {
+			if !parent.Parallel {
+				continue
+			}
+			for _, subphase := range parent.Phases {
+				if subphase.ID == phaseID {
+					return trace.BadParameter("cannot pause after phase %q executed in parallel with other phases, pause after %q instead",
+						phaseID, parent.ID)
+				}
+			}
+		}
+		phase.Pause = true
+	}
+	return nil
+}
+
 // FlattenPlan returns a slice of pointers to all phases of the provided plan
 func FlattenPlan(plan *storage.OperationPlan) []*storage.OperationPhase {
 	var result []*storage.OperationPhase
@@ -99,6 +237,24 @@ func FlattenPlan(plan *storage.OperationPlan) []*storage.OperationPhase {
 	return result
 }
 
+// ServerPhases returns the leaf phases of the plan that operate on or are
+// executed on the server with the specified advertise IP
+func ServerPhases(plan storage.OperationPlan, advertiseIP string) (phases []storage.OperationPhase) {
+	for _, phase := range FlattenPlan(&plan) {
+		if len(phase.Phases) != 0 || phase.Data == nil {
+			continue
+		}
+		if isServer(phase.Data.Server, advertiseIP) || isServer(phase.Data.ExecServer, advertiseIP) {
+			phases = append(phases, *phase)
+		}
+	}
+	return phases
+}
+
+func isServer(server *storage.Server, advertiseIP string) bool {
+	return server != nil && server.AdvertiseIP == advertiseIP
+}
+
 // SplitServers splits the specified server list into servers with master cluster role
 // and regular nodes.
 func SplitServers(servers []storage.Server) (masters, nodes []storage.Server) {
@@ -139,6 +295,7 @@ func ResolvePlan(plan storage.OperationPlan, changelog storage.PlanChangelog) *s
 			allPhases[i].State = latest.NewState
 			allPhases[i].Updated = latest.Created
 			allPhases[i].Error = latest.Error
+			allPhases[i].Checkpoint = changelog.Checkpoint(phase.ID)
 		}
 	}
 	return &plan
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthreport
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/status"
+
+	"github.com/gravitational/trace"
+)
+
+// Report is the summary of the cluster health sent to the configured recipients
+type Report struct {
+	// Cluster is the cluster name
+	Cluster string `json:"cluster"`
+	// Application is the cluster application package
+	Application loc.Locator `json:"application"`
+	// Generated is the time the report has been generated
+	Generated time.Time `json:"generated"`
+	// Check is the result of the cluster health check
+	Check status.Check `json:"check"`
+	// Nodes lists the status of the cluster nodes
+	Nodes []status.ClusterServer `json:"nodes,omitempty"`
+	// Certificates lists the expiration times of the cluster certificates
+	Certificates []Certificate `json:"certificates,omitempty"`
+	// Updates lists application updates uploaded to the cluster but not yet installed
+	Updates []loc.Locator `json:"updates,omitempty"`
+}
+
+// Certificate describes the expiration time of a cluster certificate
+type Certificate struct {
+	// Name is the certificate name
+	Name string `json:"name"`
+	// NotAfter is the time the certificate expires
+	NotAfter time.Time `json:"not_after"`
+}
+
+// Subject returns the subject line for the report email
+func (r Report) Subject() string {
+	return fmt.Sprintf("[%v] %v", r.Cluster, r.Check)
+}
+
+// WriteText writes the report in human-readable format to w
+func (r Report) WriteText(out io.Writer) error {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "Cluster:\t%v\n", r.Cluster)
+	if r.Application.Name != "" {
+		fmt.Fprintf(w, "Cluster image:\t%v:%v\n", r.Application.Name, r.Application.Version)
+	}
+	fmt.Fprintf(w, "Generated:\t%v\n", r.Generated.Format(constants.HumanDateFormat))
+	fmt.Fprintf(w, "Status:\t%v\n", r.Check.Status)
+	for _, reason := range r.Check.Reasons {
+		fmt.Fprintf(w, "    * %v\n", reason)
+	}
+	if len(r.Nodes) != 0 {
+		fmt.Fprintf(w, "Nodes:\n")
+		for _, node := range r.Nodes {
+			fmt.Fprintf(w, "    * %v (%v)\t%v\n", node.Hostname, node.AdvertiseIP, node.Status)
+		}
+	}
+	if len(r.Certificates) != 0 {
+		fmt.Fprintf(w, "Certificates:\n")
+		for _, cert := range r.Certificates {
+			fmt.Fprintf(w, "    * %v\texpires %v\n", cert.Name,
+				cert.NotAfter.Format(constants.HumanDateFormat))
+		}
+	}
+	if len(r.Updates) != 0 {
+		fmt.Fprintf(w, "Pending updates:\n")
+		for _, update := range r.Updates {
+			fmt.Fprintf(w, "    * %v:%v\n", update.Name, update.Version)
+		}
+	}
+	return trace.Wrap(w.Flush())
+}
+
+// checkCertificates escalates the check result to a warning if any of
+// the certificates expires within the specified interval
+func (r *Report) checkCertificates(now time.Time, warnBefore time.Duration) {
+	for _, cert := range r.Certificates {
+		if cert.NotAfter.Sub(now) > warnBefore {
+			continue
+		}
+		if r.Check.Code == status.CheckOK {
+			r.Check.Code = status.CheckWarning
+			r.Check.Status = status.CheckWarning.String()
+		}
+		if cert.NotAfter.Before(now) {
+			r.Check.Reasons = append(r.Check.Reasons,
+				fmt.Sprintf("%v has expired", cert.Name))
+			continue
+		}
+		r.Check.Reasons = append(r.Check.Reasons,
+			fmt.Sprintf("%v expires on %v", cert.Name,
+				cert.NotAfter.Format(constants.HumanDateFormat)))
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package healthreport implements the service that periodically sends
+// the cluster health summary to the configured email recipients and webhooks.
+package healthreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/status"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/sirupsen/logrus"
+)
+
+// Operator defines the subset of the cluster operator used by the reporter
+type Operator interface {
+	// GetLocalSite returns the local cluster record
+	GetLocalSite() (*ops.Site, error)
+	// GetHealthReport returns the cluster health report configuration
+	GetHealthReport(ops.SiteKey) (storage.HealthReport, error)
+	// UpdateHealthReport updates the cluster health report configuration
+	UpdateHealthReport(context.Context, ops.SiteKey, storage.HealthReport) error
+	// GetSMTPConfig returns the cluster SMTP configuration
+	GetSMTPConfig(ops.SiteKey) (storage.SMTPConfig, error)
+	// GetClusterCertificate returns the cluster certificate
+	GetClusterCertificate(key ops.SiteKey, withSecrets bool) (*ops.ClusterCertificate, error)
+}
+
+// Config defines the health reporter configuration
+type Config struct {
+	// Operator is the cluster operator
+	Operator Operator
+	// Packages is the cluster package service used to look up pending updates.
+	// Pending updates are not reported if unspecified
+	Packages pack.PackageService
+	// Clock is used to determine whether the report is due
+	Clock clockwork.Clock
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+	// getStatus queries the cluster status
+	getStatus func(context.Context, []storage.Server) (*status.Agent, error)
+	// sendMail sends the email message
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// CheckAndSetDefaults validates the configuration and sets defaults
+func (r *Config) CheckAndSetDefaults() error {
+	if r.Operator == nil {
+		return trace.BadParameter("missing Operator")
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = logrus.WithField(trace.Component, "healthreport")
+	}
+	if r.getStatus == nil {
+		r.getStatus = status.FromPlanetAgent
+	}
+	if r.sendMail == nil {
+		r.sendMail = smtp.SendMail
+	}
+	return nil
+}
+
+// New returns a new health reporter
+func New(config Config) (*Reporter, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Reporter{Config: config}, nil
+}
+
+// Reporter periodically sends the cluster health report
+type Reporter struct {
+	// Config is the reporter configuration
+	Config
+}
+
+// Run periodically checks whether the health report is due and sends it.
+// Blocks until the context is canceled
+func (r *Reporter) Run(ctx context.Context) {
+	r.Info("Starting health reporter.")
+	ticker := r.Clock.NewTicker(defaults.HealthReportCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.Chan():
+			if err := r.SendIfDue(ctx); err != nil {
+				r.WithError(err).Warn("Failed to send health report.")
+			}
+		case <-ctx.Done():
+			r.Info("Stopping health reporter.")
+			return
+		}
+	}
+}
+
+// SendIfDue sends the health report if health reports are configured
+// and the configured interval has elapsed since the last report
+func (r *Reporter) SendIfDue(ctx context.Context) error {
+	cluster, err := r.Operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	config, err := r.Operator.GetHealthReport(cluster.Key())
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	now := r.Clock.Now().UTC()
+	if now.Sub(config.GetLastSent()) < config.GetInterval() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaults.HealthReportTimeout)
+	defer cancel()
+	report := r.collect(ctx, *cluster, now)
+	if err := r.send(ctx, cluster.Key(), config, report); err != nil {
+		return trace.Wrap(err)
+	}
+	r.WithField("status", report.Check.Status).Info("Sent health report.")
+	config.SetLastSent(now)
+	return trace.Wrap(r.Operator.UpdateHealthReport(ctx, cluster.Key(), config))
+}
+
+// collect generates the health report for the specified cluster.
+// Details that cannot be collected are omitted from the report
+func (r *Reporter) collect(ctx context.Context, cluster ops.Site, now time.Time) Report {
+	report := Report{
+		Cluster:     cluster.Domain,
+		Application: cluster.App.Package,
+		Generated:   now,
+	}
+	agent, err := r.getStatus(ctx, cluster.ClusterState.Servers)
+	if err != nil {
+		r.WithError(err).Warn("Failed to query cluster status.")
+	}
+	report.Check = status.NewCheck(agent)
+	if agent != nil {
+		report.Nodes = agent.Nodes
+	}
+	cert, err := r.Operator.GetClusterCertificate(cluster.Key(), false)
+	if err != nil {
+		r.WithError(err).Warn("Failed to query cluster certificate.")
+	} else if parsed, err := teleutils.ParseCertificatePEM(cert.Certificate); err != nil {
+		r.WithError(err).Warn("Failed to parse cluster certificate.")
+	} else {
+		report.Certificates = append(report.Certificates, Certificate{
+			Name:     "cluster web certificate",
+			NotAfter: parsed.NotAfter,
+		})
+	}
+	report.checkCertificates(now, defaults.CertificateExpiryWarning)
+	if r.Packages != nil {
+		report.Updates, err = pack.FindNewerPackages(r.Packages, cluster.App.Package)
+		if err != nil {
+			r.WithError(err).Warn("Failed to query pending updates.")
+		}
+	}
+	return report
+}
+
+// send delivers the report to all configured destinations
+func (r *Reporter) send(ctx context.Context, key ops.SiteKey, config storage.HealthReport, report Report) error {
+	var errors []error
+	if len(config.GetRecipients()) != 0 {
+		if err := r.sendEmail(key, config, report); err != nil {
+			errors = append(errors, trace.Wrap(err, "failed to email health report"))
+		}
+	}
+	if config.GetWebhookURL() != "" {
+		if err := sendWebhook(ctx, config.GetWebhookURL(), report); err != nil {
+			errors = append(errors, trace.Wrap(err, "failed to post health report"))
+		}
+	}
+	return trace.NewAggregate(errors...)
+}
+
+func (r *Reporter) sendEmail(key ops.SiteKey, config storage.HealthReport, report Report) error {
+	smtpConfig, err := r.Operator.GetSMTPConfig(key)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	from := config.GetFrom()
+	if from == "" {
+		from = smtpConfig.GetUsername()
+	}
+	message, err := formatEmail(from, config.GetRecipients(), report)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	addr := net.JoinHostPort(smtpConfig.GetHost(), strconv.Itoa(smtpConfig.GetPort()))
+	auth := smtp.PlainAuth("", smtpConfig.GetUsername(), smtpConfig.GetPassword(), smtpConfig.GetHost())
+	return trace.Wrap(r.sendMail(addr, auth, from, config.GetRecipients(), message))
+}
+
+func formatEmail(from string, to []string, report Report) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %v\r\n", from)
+	fmt.Fprintf(&buf, "To: %v\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %v\r\n", report.Subject())
+	fmt.Fprintf(&buf, "Date: %v\r\n", report.Generated.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	if err := report.WriteText(&buf); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func sendWebhook(ctx context.Context, url string, report Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return trace.BadParameter("webhook %v responded with %v", url, resp.Status)
+	}
+	return nil
+}
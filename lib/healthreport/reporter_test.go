@@ -0,0 +1,168 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package healthreport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/status"
+	"github.com/gravitational/gravity/lib/storage"
+
+	pb "github.com/gravitational/satellite/agent/proto/agentpb"
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"gopkg.in/check.v1"
+)
+
+func TestHealthReport(t *testing.T) { check.TestingT(t) }
+
+type ReporterSuite struct{}
+
+var _ = check.Suite(&ReporterSuite{})
+
+func (s *ReporterSuite) TestSendsReport(c *check.C) {
+	var posted Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(json.NewDecoder(r.Body).Decode(&posted), check.IsNil)
+	}))
+	defer server.Close()
+
+	clock := clockwork.NewFakeClockAt(time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC))
+	operator := newTestOperator(storage.HealthReportSpecV2{
+		Recipients: []string{"ops@example.com"},
+		WebhookURL: server.URL,
+	})
+	var mails []string
+	reporter := newTestReporter(c, operator, clock, func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		c.Assert(addr, check.Equals, "smtp.example.com:587")
+		c.Assert(from, check.Equals, "gravity@example.com")
+		c.Assert(to, check.DeepEquals, []string{"ops@example.com"})
+		mails = append(mails, string(msg))
+		return nil
+	})
+
+	c.Assert(reporter.SendIfDue(context.TODO()), check.IsNil)
+	c.Assert(mails, check.HasLen, 1)
+	c.Assert(mails[0], check.Matches, `(?s).*Subject: \[example.com\] WARNING - node-1 \(192.168.1.1\): disk is 85% full.*`)
+	c.Assert(posted.Cluster, check.Equals, "example.com")
+	c.Assert(posted.Check.Code, check.Equals, status.CheckWarning)
+	c.Assert(operator.config.GetLastSent().Equal(clock.Now()), check.Equals, true)
+
+	// next report is not due until the interval elapses
+	clock.Advance(time.Hour)
+	c.Assert(reporter.SendIfDue(context.TODO()), check.IsNil)
+	c.Assert(mails, check.HasLen, 1)
+
+	clock.Advance(24 * time.Hour)
+	c.Assert(reporter.SendIfDue(context.TODO()), check.IsNil)
+	c.Assert(mails, check.HasLen, 2)
+}
+
+func (s *ReporterSuite) TestWarnsAboutExpiringCertificate(c *check.C) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	report := Report{
+		Check: status.NewCheck(&status.Agent{
+			SystemStatus: status.SystemStatus(pb.SystemStatus_Running),
+		}),
+		Certificates: []Certificate{{
+			Name:     "cluster web certificate",
+			NotAfter: now.Add(7 * 24 * time.Hour),
+		}},
+	}
+	report.checkCertificates(now, 30*24*time.Hour)
+	c.Assert(report.Check.Code, check.Equals, status.CheckWarning)
+	c.Assert(report.Check.Reasons, check.DeepEquals, []string{
+		"cluster web certificate expires on Sat Jun  8 12:00 UTC",
+	})
+}
+
+func newTestReporter(c *check.C, operator *testOperator, clock clockwork.Clock, sendMail func(string, smtp.Auth, string, []string, []byte) error) *Reporter {
+	reporter, err := New(Config{
+		Operator: operator,
+		Clock:    clock,
+		getStatus: func(context.Context, []storage.Server) (*status.Agent, error) {
+			return &status.Agent{
+				SystemStatus: status.SystemStatus(pb.SystemStatus_Running),
+				Nodes: []status.ClusterServer{{
+					Hostname:    "node-1",
+					AdvertiseIP: "192.168.1.1",
+					Status:      status.NodeHealthy,
+					WarnProbes:  []string{"disk is 85% full"},
+				}},
+			}, nil
+		},
+		sendMail: sendMail,
+	})
+	c.Assert(err, check.IsNil)
+	return reporter
+}
+
+func newTestOperator(spec storage.HealthReportSpecV2) *testOperator {
+	return &testOperator{
+		cluster: ops.Site{
+			AccountID: "system",
+			Domain:    "example.com",
+			App: ops.Application{
+				Package: loc.MustParseLocator("example.com/app:1.0.0"),
+			},
+		},
+		config: storage.NewHealthReport(spec),
+		smtp: &storage.SMTPConfigV2{
+			Spec: storage.SMTPConfigSpecV2{
+				Host:     "smtp.example.com",
+				Port:     587,
+				Username: "gravity@example.com",
+				Password: "secret",
+			},
+		},
+	}
+}
+
+type testOperator struct {
+	cluster ops.Site
+	config  storage.HealthReport
+	smtp    storage.SMTPConfig
+}
+
+func (r *testOperator) GetLocalSite() (*ops.Site, error) {
+	return &r.cluster, nil
+}
+
+func (r *testOperator) GetHealthReport(ops.SiteKey) (storage.HealthReport, error) {
+	return r.config, nil
+}
+
+func (r *testOperator) UpdateHealthReport(ctx context.Context, key ops.SiteKey, config storage.HealthReport) error {
+	r.config = config
+	return nil
+}
+
+func (r *testOperator) GetSMTPConfig(ops.SiteKey) (storage.SMTPConfig, error) {
+	return r.smtp, nil
+}
+
+func (r *testOperator) GetClusterCertificate(ops.SiteKey, bool) (*ops.ClusterCertificate, error) {
+	return nil, trace.NotFound("no cluster certificate")
+}
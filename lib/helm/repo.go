@@ -18,10 +18,10 @@ package helm
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/gravitational/gravity/lib/defaults"
@@ -126,14 +126,26 @@ func (r *clusterRepository) FetchChart(locator loc.Locator) (io.ReadCloser, erro
 }
 
 // GetIndexFile returns the chart repository index file.
+//
+// The index is generated from the cluster packages if it does not exist yet.
+// Chart URLs are served relative to the repository URL so the repository
+// can be accessed via any endpoint, e.g. the cluster controller or the
+// Ops Center the cluster is connected to.
 func (r *clusterRepository) GetIndexFile() (io.Reader, error) {
 	indexFile, err := r.Backend.GetIndexFile()
 	if err != nil && !trace.IsNotFound(err) {
 		return nil, trace.Wrap(err)
 	}
 	if trace.IsNotFound(err) {
-		indexFile = repo.NewIndexFile()
+		if err := r.RebuildIndex(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		indexFile, err = r.Backend.GetIndexFile()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
 	}
+	relativeChartURLs(indexFile)
 	data, err := yaml.Marshal(indexFile)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -266,10 +278,24 @@ func (r *clusterRepository) chartForLocator(locator loc.Locator) (*chart.Chart,
 	return chart, nil
 }
 
-// chartURL returns URL of the specified chart in the repository.
+// chartURL returns URL of the specified chart relative to the repository URL.
 func (r *clusterRepository) chartURL(chart *chart.Chart) string {
-	return fmt.Sprintf("%v/charts/%v", r.Packages.PortalURL(),
-		helmutils.ToChartFilename(chart.Metadata.Name, chart.Metadata.Version))
+	return helmutils.ToChartFilename(chart.Metadata.Name, chart.Metadata.Version)
+}
+
+// relativeChartURLs replaces chart URLs in the provided index file with
+// the chart archive filenames that Helm clients resolve relative to the
+// repository URL.
+//
+// Older indexes contain absolute URLs pointing to the cluster controller.
+func relativeChartURLs(indexFile *repo.IndexFile) {
+	for _, versions := range indexFile.Entries {
+		for _, version := range versions {
+			for i, url := range version.URLs {
+				version.URLs[i] = path.Base(url)
+			}
+		}
+	}
 }
 
 // digest returns a sha256 hash of the specified application data.
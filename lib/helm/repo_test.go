@@ -0,0 +1,33 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	check "gopkg.in/check.v1"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/repo"
+)
+
+func (s *HelmSuite) TestRelativeChartURLs(c *check.C) {
+	indexFile := repo.NewIndexFile()
+	indexFile.Add(&chart.Metadata{Name: "alpine", Version: "0.1.0"},
+		"https://gravity-site.kube-system.svc.cluster.local:3009/charts/alpine-0.1.0.tgz", "", "")
+	indexFile.Add(&chart.Metadata{Name: "nginx", Version: "1.0.0"}, "nginx-1.0.0.tgz", "", "")
+	relativeChartURLs(indexFile)
+	c.Assert(indexFile.Entries["alpine"][0].URLs, check.DeepEquals, []string{"alpine-0.1.0.tgz"})
+	c.Assert(indexFile.Entries["nginx"][0].URLs, check.DeepEquals, []string{"nginx-1.0.0.tgz"})
+}
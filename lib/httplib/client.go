@@ -162,6 +162,16 @@ func WithCA(cert []byte) ClientOption {
 	}
 }
 
+// WithProxy sets the function that selects the HTTP proxy for the client requests,
+// e.g. the Proxy method of a ProxyPolicy.
+// By default, the client uses the proxy policy of the process
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOption {
+	return func(c *http.Client) {
+		transport := c.Transport.(*http.Transport)
+		transport.Proxy = proxy
+	}
+}
+
 // WithIdleConnTimeout overrides the transport connection idle timeout
 func WithIdleConnTimeout(timeout time.Duration) ClientOption {
 	return func(c *http.Client) {
@@ -173,6 +183,7 @@ func WithIdleConnTimeout(timeout time.Duration) ClientOption {
 // GetClient returns secure or insecure client based on settings
 func GetClient(insecure bool, options ...ClientOption) *http.Client {
 	transport := &http.Transport{
+		Proxy:           ProxyFromPolicy,
 		TLSClientConfig: &tls.Config{},
 	}
 	if insecure {
@@ -381,6 +392,8 @@ func getKubeClient(dnsAddr string, tlsConfig rest.TLSClientConfig, options ...Ku
 			switch t.(type) {
 			case *http.Transport:
 				t.(*http.Transport).DialContext = DialFromEnviron(dnsAddr)
+				// Kubernetes clients only honor the proxy environment by default
+				t.(*http.Transport).Proxy = ProxyFromPolicy
 			}
 			return t
 		},
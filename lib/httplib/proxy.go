@@ -0,0 +1,329 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package httplib
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+)
+
+// ProxyEnvVars lists the environment variables that configure an HTTP proxy
+var ProxyEnvVars = []string{
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+	"http_proxy", "https_proxy", "no_proxy",
+}
+
+// ProxyEnvironment returns the subset of the specified environment
+// that configures an HTTP proxy
+func ProxyEnvironment(env map[string]string) map[string]string {
+	proxyEnv := make(map[string]string)
+	for _, name := range ProxyEnvVars {
+		if value, ok := env[name]; ok {
+			proxyEnv[name] = value
+		}
+	}
+	return proxyEnv
+}
+
+// NoProxyPolicy defines how the process extends the NO_PROXY environment
+// to avoid sending internal traffic through a configured HTTP proxy
+type NoProxyPolicy string
+
+const (
+	// NoProxyAll bypasses the proxy for any destination specified by IP address
+	// and any domain with the .local suffix.
+	// This is the legacy behavior and the default
+	NoProxyAll NoProxyPolicy = "all"
+	// NoProxyCluster bypasses the proxy only for the cluster subnets,
+	// the networks of the local interfaces and the .local domains.
+	// IP-addressed destinations outside the cluster still use the proxy
+	NoProxyCluster NoProxyPolicy = "cluster"
+	// NoProxyNone leaves the NO_PROXY environment intact
+	NoProxyNone NoProxyPolicy = "none"
+)
+
+// NoProxyPolicies lists all supported no-proxy policies
+var NoProxyPolicies = []string{string(NoProxyAll), string(NoProxyCluster), string(NoProxyNone)}
+
+// Check validates this policy
+func (r NoProxyPolicy) Check() error {
+	if !utils.StringInSlice(NoProxyPolicies, string(r)) {
+		return trace.BadParameter("unsupported no-proxy policy %q, supported are: %v",
+			r, NoProxyPolicies)
+	}
+	return nil
+}
+
+// NoProxyConfig describes the configuration of the NO_PROXY environment
+type NoProxyConfig struct {
+	// Policy specifies the no-proxy policy
+	Policy NoProxyPolicy
+	// Subnets lists the cluster subnets (e.g. pod and service subnets).
+	// Only used with the cluster policy
+	Subnets []string
+	// Addrs lists the IP networks and addresses of the cluster nodes.
+	// Only used with the cluster policy
+	Addrs []string
+	// Extra lists additional entries to add to NO_PROXY
+	Extra []string
+}
+
+// Entries returns the list of NO_PROXY entries to add according to this configuration
+func (r NoProxyConfig) Entries() []string {
+	var entries []string
+	switch r.Policy {
+	case NoProxyAll, "":
+		entries = append(entries, "0.0.0.0/0", ".local")
+	case NoProxyCluster:
+		entries = append(entries, r.Subnets...)
+		entries = append(entries, r.Addrs...)
+		entries = append(entries, ".local")
+	}
+	entries = append(entries, r.Extra...)
+	return teleutils.Deduplicate(entries)
+}
+
+// Rule returns the proxy rule that bypasses the proxy for the destinations
+// described by this configuration.
+// Returns nil if there are no destinations to bypass the proxy for
+func (r NoProxyConfig) Rule() (*ProxyRule, error) {
+	if r.Policy != "" {
+		if err := r.Policy.Check(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	entries := r.Entries()
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &ProxyRule{Destinations: entries}, nil
+}
+
+// NoProxyEnv returns the NO_PROXY environment for the child processes
+// of this process that excludes the destinations of the specified rule.
+//
+// The commands started by the process (e.g. helm or kubectl) do not use
+// the proxy policy and only honor the environment so it needs to exclude
+// the same destinations. getenv returns the value of the environment
+// variable the specified rule extends.
+// The returned variables are meant to be set with utils.SetCommandEnv
+func NoProxyEnv(rule ProxyRule, getenv func(string) string) map[string]string {
+	// The golang HTTP proxy env variable detection only uses the first detected http proxy env variable
+	// and other tools prefer the lower case variant so both are set to the same value.
+	// https://github.com/golang/net/blob/c21de06aaf072cea07f3a65d6970e5c7d8b6cd6d/http/httpproxy/proxy.go#L91-L107
+	var entries []string
+	for _, key := range []string{"NO_PROXY", "no_proxy"} {
+		if value := getenv(key); value != "" {
+			entries = append(entries, value)
+			break
+		}
+	}
+	entries = append(entries, rule.Destinations...)
+	noProxy := strings.Join(entries, ",")
+	return map[string]string{
+		"NO_PROXY": noProxy,
+		"no_proxy": noProxy,
+	}
+}
+
+// ProxyPolicy selects the HTTP proxy per destination.
+// Rules are evaluated in order and the first matching rule wins.
+// Destinations that do not match any rule use the proxy configured
+// in the environment
+type ProxyPolicy struct {
+	// Rules lists the proxy rules
+	Rules []ProxyRule `yaml:"rules" json:"rules"`
+}
+
+// Check validates this policy
+func (r ProxyPolicy) Check() error {
+	for _, rule := range r.Rules {
+		if err := rule.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// Proxy returns the URL of the proxy to use for the specified request.
+// Returns nil URL if the request should not use a proxy
+func (r ProxyPolicy) Proxy(req *http.Request) (*url.URL, error) {
+	host := req.URL.Hostname()
+	for _, rule := range r.Rules {
+		if !rule.Matches(host) {
+			continue
+		}
+		if rule.Proxy == "" {
+			return nil, nil
+		}
+		proxyURL, err := url.Parse(rule.Proxy)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return proxyURL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// ProxyRule defines the proxy for a set of destinations
+type ProxyRule struct {
+	// Destinations lists the destinations the rule applies to: IP addresses,
+	// CIDR blocks, domain names or "*" for any destination.
+	// A domain name matches the domain and all its subdomains, a domain name
+	// with the leading dot only matches the subdomains
+	Destinations []string `yaml:"destinations" json:"destinations"`
+	// Proxy is the URL of the HTTP proxy to use for the matching destinations.
+	// The matching destinations are connected to directly if unspecified
+	Proxy string `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+}
+
+// Check validates this rule
+func (r ProxyRule) Check() error {
+	if len(r.Destinations) == 0 {
+		return trace.BadParameter("proxy rule needs at least one destination")
+	}
+	for _, destination := range r.Destinations {
+		if destination == "" {
+			return trace.BadParameter("proxy rule destination cannot be empty")
+		}
+		if strings.Contains(destination, "/") {
+			if _, _, err := net.ParseCIDR(destination); err != nil {
+				return trace.BadParameter("invalid proxy rule destination %q: %v", destination, err)
+			}
+		}
+	}
+	if r.Proxy != "" {
+		proxyURL, err := url.Parse(r.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return trace.BadParameter("invalid proxy URL %q, expected scheme://host:port", r.Proxy)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the specified host matches any of this rule's destinations
+func (r ProxyRule) Matches(host string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, destination := range r.Destinations {
+		destination = strings.ToLower(destination)
+		switch {
+		case destination == "*":
+			return true
+		case strings.Contains(destination, "/"):
+			_, block, err := net.ParseCIDR(destination)
+			if err == nil && ip != nil && block.Contains(ip) {
+				return true
+			}
+		case ip != nil:
+			if destinationIP := net.ParseIP(destination); destinationIP != nil && destinationIP.Equal(ip) {
+				return true
+			}
+		case strings.HasPrefix(destination, "."):
+			if strings.HasSuffix(host, destination) {
+				return true
+			}
+		case host == destination || strings.HasSuffix(host, "."+destination):
+			return true
+		}
+	}
+	return false
+}
+
+// SetProxyPolicy sets the proxy policy for the HTTP clients of this process.
+// The policy also applies to the clients using the default transport
+func SetProxyPolicy(policy ProxyPolicy) {
+	proxyPolicy.Lock()
+	proxyPolicy.policy = policy
+	proxyPolicy.resolve = nil
+	proxyPolicy.Unlock()
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.Proxy = ProxyFromPolicy
+	}
+}
+
+// SetProxyPolicyFunc sets the function that returns the proxy policy
+// for the HTTP clients of this process.
+// The function is called once, when the policy is first used, so the
+// processes that never make HTTP requests do not need to resolve it
+func SetProxyPolicyFunc(resolve func() ProxyPolicy) {
+	proxyPolicy.Lock()
+	proxyPolicy.policy = ProxyPolicy{}
+	proxyPolicy.resolve = resolve
+	proxyPolicy.Unlock()
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.Proxy = ProxyFromPolicy
+	}
+}
+
+// GetProxyPolicy returns the proxy policy for the HTTP clients of this process
+func GetProxyPolicy() ProxyPolicy {
+	proxyPolicy.RLock()
+	if proxyPolicy.resolve == nil {
+		defer proxyPolicy.RUnlock()
+		return proxyPolicy.policy
+	}
+	proxyPolicy.RUnlock()
+	proxyPolicy.Lock()
+	defer proxyPolicy.Unlock()
+	if proxyPolicy.resolve != nil {
+		proxyPolicy.policy = proxyPolicy.resolve()
+		proxyPolicy.resolve = nil
+	}
+	return proxyPolicy.policy
+}
+
+// ProxyFromPolicy returns the URL of the proxy to use for the specified request
+// according to the proxy policy of this process.
+// It is meant to be used as the Proxy function of http.Transport
+func ProxyFromPolicy(req *http.Request) (*url.URL, error) {
+	return GetProxyPolicy().Proxy(req)
+}
+
+var proxyPolicy struct {
+	sync.RWMutex
+	policy ProxyPolicy
+	// resolve returns the policy when it is first used
+	resolve func() ProxyPolicy
+}
+
+// LocalNoProxyAddrs returns the list of networks of the local interfaces
+// in a format suitable for NO_PROXY.
+// Loopback networks are skipped as they are never proxied
+func LocalNoProxyAddrs() ([]string, error) {
+	blocks, err := utils.LocalIPNetworks()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var addrs []string
+	for _, block := range blocks {
+		if block.IP.IsLoopback() || block.IP.To4() == nil {
+			continue
+		}
+		ipNet := net.IPNet{IP: block.IP.Mask(block.Mask), Mask: block.Mask}
+		addrs = append(addrs, ipNet.String())
+	}
+	return addrs, nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package httplib
+
+import (
+	"net/http"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *testHTTPSuite) TestNoProxyEntries(c *C) {
+	var testCases = []struct {
+		comment string
+		config  NoProxyConfig
+		entries []string
+	}{
+		{
+			comment: "default policy excludes all IP addresses",
+			config:  NoProxyConfig{},
+			entries: []string{"0.0.0.0/0", ".local"},
+		},
+		{
+			comment: "cluster policy excludes cluster subnets and node networks",
+			config: NoProxyConfig{
+				Policy:  NoProxyCluster,
+				Subnets: []string{"10.244.0.0/16", "10.100.0.0/16"},
+				Addrs:   []string{"192.168.1.0/24"},
+				Extra:   []string{"example.com", ".local"},
+			},
+			entries: []string{"10.244.0.0/16", "10.100.0.0/16", "192.168.1.0/24", ".local", "example.com"},
+		},
+		{
+			comment: "none policy only adds extra entries",
+			config: NoProxyConfig{
+				Policy: NoProxyNone,
+				Extra:  []string{"example.com"},
+			},
+			entries: []string{"example.com"},
+		},
+	}
+	for _, tc := range testCases {
+		c.Assert(tc.config.Entries(), DeepEquals, tc.entries, Commentf(tc.comment))
+	}
+}
+
+func (s *testHTTPSuite) TestNoProxyRule(c *C) {
+	rule, err := NoProxyConfig{Policy: NoProxyAll}.Rule()
+	c.Assert(err, IsNil)
+	c.Assert(rule, DeepEquals, &ProxyRule{Destinations: []string{"0.0.0.0/0", ".local"}})
+
+	rule, err = NoProxyConfig{Policy: NoProxyNone}.Rule()
+	c.Assert(err, IsNil)
+	c.Assert(rule, IsNil)
+
+	_, err = NoProxyConfig{Policy: "invalid"}.Rule()
+	c.Assert(err, NotNil)
+}
+
+func (s *testHTTPSuite) TestNoProxyEnv(c *C) {
+	env := map[string]string{"no_proxy": "example.com"}
+	getenv := func(name string) string { return env[name] }
+
+	noProxyEnv := NoProxyEnv(ProxyRule{Destinations: []string{"0.0.0.0/0", ".local"}}, getenv)
+	c.Assert(noProxyEnv, DeepEquals, map[string]string{
+		"NO_PROXY": "example.com,0.0.0.0/0,.local",
+		"no_proxy": "example.com,0.0.0.0/0,.local",
+	})
+}
+
+func (s *testHTTPSuite) TestClientWithProxy(c *C) {
+	policy := ProxyPolicy{
+		Rules: []ProxyRule{{Destinations: []string{"*"}, Proxy: "http://proxy:3128"}},
+	}
+	client := GetClient(false, WithProxy(policy.Proxy))
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	c.Assert(err, IsNil)
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	c.Assert(err, IsNil)
+	c.Assert(proxyURL.String(), Equals, "http://proxy:3128")
+}
+
+func (s *testHTTPSuite) TestProxyPolicy(c *C) {
+	policy := ProxyPolicy{
+		Rules: []ProxyRule{
+			{Destinations: []string{"10.0.0.0/8", ".local", "192.168.1.1"}},
+			{Destinations: []string{"example.com"}, Proxy: "http://proxy-a:3128"},
+			{Destinations: []string{"*"}, Proxy: "http://proxy-b:3128"},
+		},
+	}
+	c.Assert(policy.Check(), IsNil)
+	var testCases = []struct {
+		url   string
+		proxy string
+	}{
+		{url: "https://10.1.2.3:3009", proxy: ""},
+		{url: "https://192.168.1.1", proxy: ""},
+		{url: "https://gravity-site.kube-system.svc.cluster.local", proxy: ""},
+		{url: "https://example.com", proxy: "http://proxy-a:3128"},
+		{url: "https://hub.example.com", proxy: "http://proxy-a:3128"},
+		{url: "https://notexample.com", proxy: "http://proxy-b:3128"},
+		{url: "https://192.168.1.2", proxy: "http://proxy-b:3128"},
+	}
+	for _, tc := range testCases {
+		req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+		c.Assert(err, IsNil)
+		proxyURL, err := policy.Proxy(req)
+		c.Assert(err, IsNil)
+		var proxy string
+		if proxyURL != nil {
+			proxy = proxyURL.String()
+		}
+		c.Assert(proxy, Equals, tc.proxy, Commentf(tc.url))
+	}
+
+	c.Assert(ProxyPolicy{Rules: []ProxyRule{{}}}.Check(), NotNil)
+	c.Assert(ProxyPolicy{Rules: []ProxyRule{{Destinations: []string{"10.0.0.0/33"}}}}.Check(), NotNil)
+	c.Assert(ProxyPolicy{Rules: []ProxyRule{{Destinations: []string{"*"}, Proxy: "proxy"}}}.Check(), NotNil)
+}
+
+func (s *testHTTPSuite) TestResolvesProxyPolicyOnFirstUse(c *C) {
+	defer SetProxyPolicy(ProxyPolicy{})
+	var resolved int
+	SetProxyPolicyFunc(func() ProxyPolicy {
+		resolved++
+		return ProxyPolicy{Rules: []ProxyRule{{Destinations: []string{"*"}, Proxy: "http://proxy:3128"}}}
+	})
+	c.Assert(resolved, Equals, 0)
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		c.Assert(err, IsNil)
+		proxyURL, err := ProxyFromPolicy(req)
+		c.Assert(err, IsNil)
+		c.Assert(proxyURL.String(), Equals, "http://proxy:3128")
+	}
+	c.Assert(resolved, Equals, 1)
+}
+
+func (s *testHTTPSuite) TestProxyEnvironment(c *C) {
+	env := ProxyEnvironment(map[string]string{
+		"HTTP_PROXY":  "http://proxy:3128",
+		"https_proxy": "http://proxy:3128",
+		"NO_PROXY":    "example.com",
+		"EDITOR":      "vim",
+	})
+	c.Assert(env, DeepEquals, map[string]string{
+		"HTTP_PROXY":  "http://proxy:3128",
+		"https_proxy": "http://proxy:3128",
+		"NO_PROXY":    "example.com",
+	})
+	c.Assert(ProxyEnvironment(nil), DeepEquals, map[string]string{})
+}
@@ -39,6 +39,7 @@ func NewAgent(config AgentConfig) (*rpcserver.PeerServer, error) {
 	if err := FetchCloudMetadata(config.CloudProvider, &config.RuntimeConfig); err != nil {
 		return nil, trace.Wrap(err)
 	}
+	addMachineSerial(&config.RuntimeConfig)
 	config.WithFields(log.Fields{
 		"provider": config.CloudProvider,
 		"metadata": config.RuntimeConfig.CloudMetadata,
@@ -75,7 +76,7 @@ func NewAgent(config AgentConfig) (*rpcserver.PeerServer, error) {
 // CheckAndSetDefaults validates this config object and sets defaults
 func (r *AgentConfig) CheckAndSetDefaults() (err error) {
 	if r.AdvertiseAddr == "" {
-		r.AdvertiseAddr, err = utils.PickAdvertiseIP()
+		r.AdvertiseAddr, err = utils.PickAdvertiseIPWithPolicy(r.AdvertisePolicy)
 		if err != nil {
 			return trace.Wrap(err,
 				"failed to choose network interface on host and none was provided")
@@ -97,6 +98,9 @@ type AgentConfig struct {
 	CloudProvider string
 	// AdvertiseAddr is the IP address to advertise
 	AdvertiseAddr string
+	// AdvertisePolicy specifies how to select the advertise address
+	// if it has not been specified
+	AdvertisePolicy utils.AdvertiseAddrPolicy
 	// ServerAddr specifies the address of the agent server
 	ServerAddr string
 	// RuntimeConfig specifies runtime configuration
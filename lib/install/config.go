@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/gravitational/gravity/lib/app"
 	"github.com/gravitational/gravity/lib/checks"
@@ -63,8 +64,13 @@ func NewFSMConfig(operator ops.Operator, operationKey ops.SiteOperationKey, conf
 
 // RunLocalChecks executes host-local preflight checks for this configuration
 func (c *Config) RunLocalChecks(ctx context.Context) error {
+	if c.CertAuthority != nil {
+		if err := c.CertAuthority.Check(time.Now()); err != nil {
+			return trace.Wrap(err, "invalid certificate authority")
+		}
+	}
 	return trace.Wrap(checks.RunLocalChecks(ctx, checks.LocalChecksRequest{
-		Manifest: c.App.Manifest,
+		Manifest: c.App.Manifest.WithCNI(c.CNI),
 		Role:     c.Role,
 		Docker:   c.Docker,
 		Options: &validationpb.ValidateOptions{
@@ -158,6 +164,26 @@ type Config struct {
 	Packages pack.PackageService
 	// LocalAgent specifies whether the installer will also run an agent
 	LocalAgent bool
+	// SELinux specifies whether to configure SELinux on the nodes
+	SELinux bool
+	// RegistryDir specifies the optional pre-seeded docker registry directory
+	// to populate the cluster registry from instead of exporting the images
+	RegistryDir string
+	// DisabledComponents lists the optional application components
+	// to exclude from the cluster
+	DisabledComponents []string
+	// DefaultDenyNetworkPolicy specifies whether to deploy the baseline
+	// network policies
+	DefaultDenyNetworkPolicy bool
+	// CNI specifies the network plugin to install the cluster with
+	CNI string
+	// NodeVars specifies the agent runtime parameters with additional
+	// Kubernetes labels and taints for the installer node
+	NodeVars map[string]string
+	// CertAuthority specifies the optional certificate authority
+	// to issue the cluster certificates with instead of generating
+	// a self-signed one
+	CertAuthority *utils.CertAuthority
 }
 
 // checkAndSetDefaults checks the parameters and autodetects some defaults
@@ -240,6 +266,11 @@ func (r *clusterFactory) NewCluster() ops.NewSiteRequest {
 		DNSOverrides: r.DNSOverrides,
 		DNSConfig:    r.DNSConfig,
 		Docker:       r.Docker,
+
+		DisabledComponents:       r.DisabledComponents,
+		DefaultDenyNetworkPolicy: r.DefaultDenyNetworkPolicy,
+		CNI:                      r.CNI,
+		SELinux:                  r.SELinux,
 	}
 }
 
@@ -313,6 +344,7 @@ func newAgent(ctx context.Context, config Config) (*rpcserver.PeerServer, error)
 		DockerDevice: config.DockerDevice,
 		Role:         config.Role,
 		Mounts:       mounts,
+		KeyValues:    config.NodeVars,
 	}
 	return NewAgent(AgentConfig{
 		FieldLogger:   config.FieldLogger.WithField(trace.Component, "agent:rpc"),
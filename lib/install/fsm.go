@@ -215,6 +215,8 @@ func (f *fsmEngine) ChangePhaseState(ctx context.Context, change fsm.StateChange
 			PhaseID:     change.Phase,
 			NewState:    change.State,
 			Error:       utils.ToRawTrace(change.Error),
+			Transient:   change.IsTransient(),
+			Logs:        change.Logs,
 			Created:     time.Now().UTC(),
 		})
 	if err != nil {
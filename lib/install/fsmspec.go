@@ -49,6 +49,11 @@ func FSMSpec(config FSMConfig) fsm.FSMSpecFunc {
 			return phases.NewConfigure(p,
 				config.Operator)
 
+		case strings.HasPrefix(p.Phase.ID, phases.BootstrapPhase) &&
+			strings.HasSuffix(p.Phase.ID, "/"+phases.SELinuxPhase):
+			return phases.NewSELinux(p,
+				config.Operator, remote)
+
 		case strings.HasPrefix(p.Phase.ID, phases.BootstrapPhase):
 			return phases.NewBootstrap(p,
 				config.Operator,
@@ -118,6 +123,15 @@ func FSMSpec(config FSMConfig) fsm.FSMSpecFunc {
 				config.LocalPackages,
 				config.LocalApps, remote)
 
+		case p.Phase.ID == phases.OpenEBSPhase:
+			client, err := getKubeClient(p)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			return phases.NewOpenEBSPhase(p,
+				config.Operator,
+				client)
+
 		case strings.HasPrefix(p.Phase.ID, phases.RuntimePhase), strings.HasPrefix(p.Phase.ID, phases.AppPhase):
 			return phases.NewApp(p,
 				config.Operator,
@@ -15,6 +15,7 @@ import (
 	"github.com/gravitational/gravity/lib/modules"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/events"
+	"github.com/gravitational/gravity/lib/ops/opsservice"
 	"github.com/gravitational/gravity/lib/status"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/system/signals"
@@ -48,6 +49,9 @@ func (i *Installer) NotifyOperationAvailable(op ops.SiteOperation) error {
 	if err := i.upsertAdminAgent(op.SiteDomain); err != nil {
 		return trace.Wrap(err)
 	}
+	if err := i.upsertCertAuthority(op); err != nil {
+		return trace.Wrap(err)
+	}
 	go ProgressPoller{
 		FieldLogger:  i.FieldLogger,
 		Operator:     i.config.Operator,
@@ -237,6 +241,26 @@ func (i *Installer) upsertAdminAgent(clusterName string) error {
 	return nil
 }
 
+// upsertCertAuthority creates the certificate authority package of the cluster
+// with the certificate authority provided by the operator, if any, so the cluster
+// certificates are issued by it instead of a generated self-signed one
+func (i *Installer) upsertCertAuthority(operation ops.SiteOperation) error {
+	if i.config.CertAuthority == nil {
+		return nil
+	}
+	caPackage, err := opsservice.PlanetCertAuthorityPackage(operation.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = opsservice.CreateCertAuthorityPackage(i.config.Packages, *caPackage,
+		*i.config.CertAuthority, operation.ID)
+	if err != nil && !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	i.WithField("package", caPackage).Info("Created certificate authority package.")
+	return nil
+}
+
 // uploadInstallLog uploads user-facing operation log to the installed cluster
 func (i *Installer) uploadInstallLog(operationKey ops.SiteOperationKey) error {
 	file, err := os.Open(i.config.UserLogFile)
@@ -34,6 +34,7 @@ import (
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/system/environ"
 	"github.com/gravitational/gravity/lib/systeminfo"
 	"github.com/gravitational/gravity/lib/utils"
 
@@ -126,10 +127,18 @@ func (p *bootstrapExecutor) Execute(ctx context.Context) error {
 			return trace.Wrap(err)
 		}
 	}
+	err = p.seedRegistry()
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	err = p.configureSystemDirectories()
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	err = p.restorePreservedDirectories()
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	err = p.configureApplicationVolumes()
 	if err != nil {
 		return trace.Wrap(err)
@@ -177,6 +186,34 @@ func (p *bootstrapExecutor) configureDeviceMapper() error {
 	return nil
 }
 
+// seedRegistry populates the cluster registry backend on a master node from
+// the pre-seeded registry directory if the install is using one.
+// The ownership of the registry backend is adjusted along with other
+// system directories
+func (p *bootstrapExecutor) seedRegistry() error {
+	if p.Phase.Data.Install == nil || p.Phase.Data.Install.RegistryDir == "" {
+		return nil
+	}
+	registryDir := p.Phase.Data.Install.RegistryDir
+	p.Progress.NextStep("Populating cluster registry from %v", registryDir)
+	p.Infof("Populating cluster registry from %v.", registryDir)
+	isDir, err := utils.IsDirectory(registryDir)
+	if err != nil || !isDir {
+		return trace.NotFound("pre-seeded registry directory %v is not found on node %v, "+
+			"it must be present on all master nodes", registryDir, p.Phase.Data.Server.Hostname)
+	}
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = utils.CopyDirContents(registryDir,
+		filepath.Join(stateDir, defaults.PlanetDir, defaults.StateRegistryDir))
+	if err != nil {
+		return trace.Wrap(err, "failed to populate cluster registry from %v", registryDir)
+	}
+	return nil
+}
+
 // configureSystemDirectories creates necessary system directories with
 // proper permissions
 func (p *bootstrapExecutor) configureSystemDirectories() error {
@@ -254,6 +291,12 @@ func (p *bootstrapExecutor) configureSystemDirectories() error {
 	return nil
 }
 
+// restorePreservedDirectories restores application data directories
+// preserved by gravity reinstall on this node
+func (p *bootstrapExecutor) restorePreservedDirectories() error {
+	return trace.Wrap(environ.RestoreDirectories(p.FieldLogger))
+}
+
 // configureApplicationVolumes creates necessary directories for
 // application mounts
 func (p *bootstrapExecutor) configureApplicationVolumes() error {
@@ -37,10 +37,11 @@ func NewConfigure(p fsm.ExecutorParams, operator ops.Operator) (*configureExecut
 		Operator: operator,
 	}
 	var env map[string]string
-	var config []byte
+	var config, dns []byte
 	if p.Phase.Data != nil && p.Phase.Data.Install != nil {
 		env = p.Phase.Data.Install.Env
 		config = p.Phase.Data.Install.Config
+		dns = p.Phase.Data.Install.DNS
 	}
 	return &configureExecutor{
 		FieldLogger:    logger,
@@ -48,6 +49,7 @@ func NewConfigure(p fsm.ExecutorParams, operator ops.Operator) (*configureExecut
 		ExecutorParams: p,
 		env:            env,
 		config:         config,
+		dns:            dns,
 	}, nil
 }
 
@@ -60,6 +62,7 @@ type configureExecutor struct {
 	fsm.ExecutorParams
 	env    map[string]string
 	config []byte
+	dns    []byte
 }
 
 // Execute executes the configure phase
@@ -70,6 +73,7 @@ func (p *configureExecutor) Execute(ctx context.Context) error {
 		SiteOperationKey: fsm.OperationKey(p.Plan),
 		Env:              p.env,
 		Config:           p.config,
+		DNS:              p.dns,
 	})
 	if err != nil {
 		return trace.Wrap(err)
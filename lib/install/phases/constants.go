@@ -53,6 +53,8 @@ const (
 	ExportPhase = "/export"
 	// RuntimePhase is a phase that installs system applications
 	RuntimePhase = "/runtime"
+	// OpenEBSPhase is a phase that configures the OpenEBS storage pools
+	OpenEBSPhase = "/openebs"
 	// AppPhase is a phase that installs user application
 	AppPhase = "/app"
 	// ConnectInstallerPhase is a phase that connects cluster to the installer
@@ -63,4 +65,9 @@ const (
 	EnableElectionPhase = "/election"
 	// InstallOverlayPhase installs a custom overlay network
 	InstallOverlayPhase = "/overlay"
+	// SELinuxPhase is a bootstrap sub-phase that configures SELinux on a node
+	SELinuxPhase = "selinux"
+	// SystemPhase is a bootstrap sub-phase that prepares the node system
+	// when the node bootstrap consists of several steps
+	SystemPhase = "system"
 )
@@ -31,7 +31,6 @@ import (
 	"github.com/alecthomas/template"
 	"github.com/gravitational/trace"
 	log "github.com/sirupsen/logrus"
-	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -46,6 +45,10 @@ type corednsExecutor struct {
 	Client *kubernetes.Clientset
 	// DNSOverrides is the user configured DNS overrides
 	DNSOverrides storage.DNSOverrides
+	// DNS is the cluster DNS configuration
+	DNS storage.ClusterDNS
+	// Provider renders the configuration for the configured DNS provider
+	Provider DNSProvider
 }
 
 // NewCorednsPhase creates a new coredns phase executor
@@ -64,11 +67,26 @@ func NewCorednsPhase(p fsm.ExecutorParams, operator ops.Operator, client *kubern
 		return nil, trace.Wrap(err)
 	}
 
+	dns := storage.DefaultClusterDNS()
+	if p.Phase.Data != nil && p.Phase.Data.Install != nil && len(p.Phase.Data.Install.DNS) != 0 {
+		dns, err = storage.UnmarshalClusterDNS(p.Phase.Data.Install.DNS)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+
+	provider, err := NewDNSProvider(dns.GetProvider())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	return &corednsExecutor{
 		FieldLogger:    logger,
 		ExecutorParams: p,
 		Client:         client,
 		DNSOverrides:   cluster.DNSOverrides,
+		DNS:            dns,
+		Provider:       provider,
 	}, nil
 }
 
@@ -84,8 +102,8 @@ func (r *corednsExecutor) PostCheck(context.Context) error {
 
 // Execute generates coredns configuration
 func (r *corednsExecutor) Execute(ctx context.Context) error {
-	r.Progress.NextStep("Configuring CoreDNS")
-	r.Info("Configuring CoreDNS.")
+	r.Progress.NextStep("Configuring cluster DNS")
+	r.Infof("Configuring cluster DNS with %v provider.", r.Provider.Name())
 
 	// Read the resolv.conf from the host doing installation
 	// it will be used for configuring coredns upstream servers
@@ -103,27 +121,17 @@ func (r *corednsExecutor) Execute(ctx context.Context) error {
 	}
 
 	upstreams := mergeUpstreamResolvers(resolvConf, systemdResolvConf)
-	conf, err := GenerateCorefile(CorednsConfig{
-		UpstreamNameservers: upstreams,
-		Rotate:              resolvConf.Rotate,
-		Hosts:               r.DNSOverrides.Hosts,
-		Zones:               r.DNSOverrides.Zones,
-	})
+	configMaps, err := r.Provider.Render(NewCorednsConfig(
+		r.DNS, r.DNSOverrides, upstreams, resolvConf.Rotate))
 	if err != nil {
 		return trace.Wrap(err)
 	}
 
-	_, err = r.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).Create(&v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "coredns",
-			Namespace: constants.KubeSystemNamespace,
-		},
-		Data: map[string]string{
-			"Corefile": conf,
-		},
-	})
-	if err != nil {
-		return trace.Wrap(err)
+	for _, configMap := range configMaps {
+		_, err = r.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).Create(&configMap)
+		if err != nil {
+			return trace.Wrap(err)
+		}
 	}
 
 	return nil
@@ -151,11 +159,13 @@ func mergeUpstreamResolvers(configs ...*storage.ResolvConf) []string {
 	return upstreams
 }
 
-// Rollback deletes the coredns configmap that was created in the execute step
+// Rollback deletes the DNS configmaps that were created in the execute step
 func (r *corednsExecutor) Rollback(context.Context) error {
-	err := r.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).Delete("coredns", &metav1.DeleteOptions{})
-	if err != nil && !trace.IsNotFound(err) {
-		return trace.Wrap(err)
+	for _, name := range r.Provider.ConfigMaps() {
+		err := r.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
 	}
 
 	return nil
@@ -182,6 +192,20 @@ type CorednsConfig struct {
 	// Rotate indicates whether the upstream servers should be round-robin load balanced as detected from the system
 	// resolv.conf
 	Rotate bool
+	// Policy optionally specifies the upstream server selection policy.
+	// Takes precedence over Rotate
+	Policy string
+}
+
+// ForwardPolicy returns the upstream server selection policy
+func (r CorednsConfig) ForwardPolicy() string {
+	if r.Policy != "" {
+		return r.Policy
+	}
+	if r.Rotate {
+		return storage.DNSUpstreamPolicyRandom
+	}
+	return storage.DNSUpstreamPolicySequential
 }
 
 var coreDNSTemplate = template.Must(template.New("coredns").Parse(coreDNSTemplateText))
@@ -208,7 +232,7 @@ const coreDNSTemplateText = `
     policy sequential
   }{{end}}
   {{if .UpstreamNameservers}}forward . {{range $server := .UpstreamNameservers}}{{$server}} {{end}}{
-    {{if .Rotate}}policy random{{else}}policy sequential{{end}}
+    policy {{.ForwardPolicy}}
     health_check 0
   }{{end}}
 }
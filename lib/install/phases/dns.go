@@ -0,0 +1,229 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"bytes"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/alecthomas/template"
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DNSProvider renders cluster DNS configuration for a specific DNS server setup
+type DNSProvider interface {
+	// Name returns the name of this provider
+	Name() string
+	// Render returns the config maps with DNS server configuration
+	Render(CorednsConfig) ([]v1.ConfigMap, error)
+	// ConfigMaps returns the names of the config maps this provider renders
+	ConfigMaps() []string
+}
+
+// NewDNSProvider returns the DNS provider with the specified name
+func NewDNSProvider(name string) (DNSProvider, error) {
+	switch name {
+	case storage.DNSProviderCoreDNS, "":
+		return corednsProvider{}, nil
+	case storage.DNSProviderNodeLocal:
+		return nodeLocalDNSProvider{}, nil
+	}
+	return nil, trace.BadParameter("unsupported DNS provider %q, supported are: %v",
+		name, storage.DNSProviders)
+}
+
+// NewCorednsConfig returns the DNS configuration from the specified DNS resource,
+// user DNS overrides and the host resolver configuration
+func NewCorednsConfig(dns storage.ClusterDNS, overrides storage.DNSOverrides, upstreams []string, rotate bool) CorednsConfig {
+	config := CorednsConfig{
+		UpstreamNameservers: upstreams,
+		Rotate:              rotate,
+		Hosts:               overrides.Hosts,
+		Zones:               make(map[string][]string),
+	}
+	for zone, nameservers := range overrides.Zones {
+		config.Zones[zone] = nameservers
+	}
+	if dns == nil {
+		return config
+	}
+	upstream := dns.GetUpstream()
+	if len(upstream.Nameservers) != 0 {
+		config.UpstreamNameservers = upstream.Nameservers
+	}
+	config.Policy = upstream.Policy
+	for _, forwarder := range dns.GetForwarders() {
+		config.Zones[forwarder.Zone] = forwarder.Nameservers
+	}
+	return config
+}
+
+// ClusterDNSFromConfigMap returns the DNS configuration resource
+// stored in the specified config map
+func ClusterDNSFromConfigMap(configMap v1.ConfigMap) (storage.ClusterDNS, error) {
+	spec, ok := configMap.Data["spec"]
+	if !ok {
+		return nil, trace.NotFound("no DNS configuration in config map %v", configMap.Name)
+	}
+	dns, err := storage.UnmarshalClusterDNS([]byte(spec))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return dns, nil
+}
+
+// corednsProvider configures CoreDNS as the cluster DNS server
+type corednsProvider struct{}
+
+// Name returns the name of this provider
+func (corednsProvider) Name() string {
+	return storage.DNSProviderCoreDNS
+}
+
+// Render returns the CoreDNS config map
+func (corednsProvider) Render(config CorednsConfig) ([]v1.ConfigMap, error) {
+	conf, err := GenerateCorefile(config)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return []v1.ConfigMap{newDNSConfigMap("coredns", conf)}, nil
+}
+
+// ConfigMaps returns the names of the config maps this provider renders
+func (corednsProvider) ConfigMaps() []string {
+	return []string{"coredns"}
+}
+
+// nodeLocalDNSProvider configures CoreDNS as the cluster DNS server
+// with the node-local DNS cache running on each node.
+// The cache answers cluster queries by forwarding them to CoreDNS
+// and resolves external names using the upstream servers directly.
+//
+// The cache DaemonSet is deployed by the DNS application when the
+// node-local-dns config map exists, and kubelet on each node is pointed
+// to the cache with --cluster-dns set to defaults.NodeLocalDNSAddr
+type nodeLocalDNSProvider struct {
+	corednsProvider
+}
+
+// Name returns the name of this provider
+func (nodeLocalDNSProvider) Name() string {
+	return storage.DNSProviderNodeLocal
+}
+
+// Render returns the CoreDNS and node-local DNS cache config maps
+func (r nodeLocalDNSProvider) Render(config CorednsConfig) ([]v1.ConfigMap, error) {
+	configMaps, err := r.corednsProvider.Render(config)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	conf, err := GenerateNodeLocalCorefile(config)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return append(configMaps, newDNSConfigMap("node-local-dns", conf)), nil
+}
+
+// ConfigMaps returns the names of the config maps this provider renders
+func (nodeLocalDNSProvider) ConfigMaps() []string {
+	return []string{"coredns", "node-local-dns"}
+}
+
+// GenerateNodeLocalCorefile generates configuration for the node-local DNS cache
+func GenerateNodeLocalCorefile(config CorednsConfig) (string, error) {
+	var buf bytes.Buffer
+	err := nodeLocalDNSTemplate.Execute(&buf, nodeLocalDNSConfig{
+		CorednsConfig: config,
+		LocalAddr:     defaults.NodeLocalDNSAddr,
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return buf.String(), nil
+}
+
+func newDNSConfigMap(name, corefile string) v1.ConfigMap {
+	return v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: constants.KubeSystemNamespace,
+		},
+		Data: map[string]string{
+			"Corefile": corefile,
+		},
+	}
+}
+
+type nodeLocalDNSConfig struct {
+	CorednsConfig
+	// LocalAddr is the address the cache listens on
+	LocalAddr string
+}
+
+var nodeLocalDNSTemplate = template.Must(template.New("node-local-dns").Parse(nodeLocalDNSTemplateText))
+
+// nodeLocalDNSTemplateText is the configuration of the node-local DNS cache.
+// __PILLAR__CLUSTER__DNS__ is substituted with the cluster DNS service address
+// by the cache on startup
+const nodeLocalDNSTemplateText = `
+cluster.local:53 in-addr.arpa:53 ip6.arpa:53 {
+  errors
+  cache {
+    success 9984 30
+    denial 9984 5
+  }
+  reload
+  loop
+  bind {{.LocalAddr}}
+  forward . __PILLAR__CLUSTER__DNS__ {
+    force_tcp
+  }
+  prometheus :9253
+  health {{.LocalAddr}}:8080
+}{{range $zone, $servers := .Zones}}
+{{$zone}}:53 {
+  errors
+  cache 30
+  reload
+  loop
+  bind {{$.LocalAddr}}
+  forward . {{range $server := $servers}}{{$server}} {{end}}{
+    policy sequential
+  }
+  prometheus :9253
+}{{end}}
+.:53 {
+  errors
+  cache 30
+  reload
+  loop
+  bind {{.LocalAddr}}{{if .Hosts}}
+  hosts { {{range $hostname, $ip := .Hosts}}
+    {{$ip}} {{$hostname}}{{end}}
+    fallthrough
+  }{{end}}
+  {{if .UpstreamNameservers}}forward . {{range $server := .UpstreamNameservers}}{{$server}} {{end}}{
+    policy {{.ForwardPolicy}}
+  }{{end}}
+  prometheus :9253
+}
+`
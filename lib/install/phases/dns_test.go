@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"strings"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"gopkg.in/check.v1"
+)
+
+type DNSSuite struct{}
+
+var _ = check.Suite(&DNSSuite{})
+
+func (*DNSSuite) TestCorednsConfigFromResource(c *check.C) {
+	dns := storage.NewClusterDNS(storage.ClusterDNSSpecV2{
+		Provider: storage.DNSProviderCoreDNS,
+		Upstream: storage.DNSUpstream{
+			Policy:      storage.DNSUpstreamPolicyRoundRobin,
+			Nameservers: []string{"9.9.9.9"},
+		},
+		Forwarders: []storage.DNSForwarder{
+			{Zone: "example.com", Nameservers: []string{"10.0.0.1"}},
+		},
+	})
+	overrides := storage.DNSOverrides{
+		Hosts: map[string]string{"override.com": "5.5.5.5"},
+		Zones: map[string][]string{
+			"example.com":  {"1.1.1.1"},
+			"example2.com": {"2.2.2.2"},
+		},
+	}
+	config := NewCorednsConfig(dns, overrides, []string{"8.8.8.8"}, true)
+	c.Assert(config, check.DeepEquals, CorednsConfig{
+		Hosts: map[string]string{"override.com": "5.5.5.5"},
+		Zones: map[string][]string{
+			"example.com":  {"10.0.0.1"},
+			"example2.com": {"2.2.2.2"},
+		},
+		UpstreamNameservers: []string{"9.9.9.9"},
+		Rotate:              true,
+		Policy:              storage.DNSUpstreamPolicyRoundRobin,
+	})
+	c.Assert(config.ForwardPolicy(), check.Equals, storage.DNSUpstreamPolicyRoundRobin)
+
+	config = NewCorednsConfig(storage.DefaultClusterDNS(), storage.DNSOverrides{}, []string{"8.8.8.8"}, true)
+	c.Assert(config.UpstreamNameservers, check.DeepEquals, []string{"8.8.8.8"})
+	c.Assert(config.ForwardPolicy(), check.Equals, storage.DNSUpstreamPolicyRandom)
+}
+
+func (*DNSSuite) TestProviders(c *check.C) {
+	config := CorednsConfig{
+		Zones:               map[string][]string{"example.com": {"10.0.0.1"}},
+		UpstreamNameservers: []string{"1.1.1.1"},
+	}
+
+	provider, err := NewDNSProvider(storage.DNSProviderCoreDNS)
+	c.Assert(err, check.IsNil)
+	configMaps, err := provider.Render(config)
+	c.Assert(err, check.IsNil)
+	c.Assert(configMaps, check.HasLen, 1)
+	c.Assert(configMaps[0].Name, check.Equals, "coredns")
+
+	provider, err = NewDNSProvider(storage.DNSProviderNodeLocal)
+	c.Assert(err, check.IsNil)
+	configMaps, err = provider.Render(config)
+	c.Assert(err, check.IsNil)
+	c.Assert(configMaps, check.HasLen, 2)
+	c.Assert(configMaps[1].Name, check.Equals, "node-local-dns")
+	c.Assert(provider.ConfigMaps(), check.DeepEquals, []string{"coredns", "node-local-dns"})
+	corefile := configMaps[1].Data["Corefile"]
+	c.Assert(strings.Contains(corefile, "bind 169.254.20.10"), check.Equals, true)
+	c.Assert(strings.Contains(corefile, "example.com:53 {"), check.Equals, true)
+	c.Assert(strings.Contains(corefile, "forward . 1.1.1.1 {\n    policy sequential"), check.Equals, true)
+
+	_, err = NewDNSProvider("bind")
+	c.Assert(err, check.NotNil)
+}
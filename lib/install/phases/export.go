@@ -54,6 +54,13 @@ func NewExport(p fsm.ExecutorParams, operator ops.Operator, packages pack.Packag
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	cluster, err := operator.GetSite(ops.SiteKey{
+		AccountID:  defaults.SystemAccountID,
+		SiteDomain: p.Plan.ClusterName,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
 	logger := &fsm.Logger{
 		FieldLogger: logrus.WithFields(logrus.Fields{
 			constants.FieldPhase:       p.Phase.ID,
@@ -72,6 +79,8 @@ func NewExport(p fsm.ExecutorParams, operator ops.Operator, packages pack.Packag
 		StateDir:       stateDir,
 		ExecutorParams: p,
 		remote:         remote,
+
+		disabledComponents: cluster.DisabledComponents,
 	}, nil
 }
 
@@ -90,6 +99,9 @@ type exportExecutor struct {
 	fsm.ExecutorParams
 	// remote specifies the server remote control interface
 	remote fsm.Remote
+	// disabledComponents lists the application components excluded
+	// from the cluster
+	disabledComponents []string
 }
 
 // Execute executes the export phase
@@ -98,7 +110,12 @@ func (p *exportExecutor) Execute(ctx context.Context) error {
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	skipApps := app.Manifest.Components.Apps(p.disabledComponents)
 	for _, dep := range app.Manifest.Dependencies.Apps {
+		if utils.StringInSlice(skipApps, dep.Locator.Name) {
+			p.Infof("Skip disabled application %v.", dep.Locator)
+			continue
+		}
 		err = p.unpackApp(dep.Locator)
 		if err != nil {
 			return trace.Wrap(err)
@@ -0,0 +1,109 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/openebs"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewOpenEBSPhase returns a new executor for the phase that configures
+// the OpenEBS storage pools declared in the persistent storage configuration
+func NewOpenEBSPhase(p fsm.ExecutorParams, operator ops.Operator, client *kubernetes.Clientset) (fsm.PhaseExecutor, error) {
+	if p.Phase.Data == nil || p.Phase.Data.Install == nil || len(p.Phase.Data.Install.PersistentStorage) == 0 {
+		return nil, trace.BadParameter("persistent storage configuration is required")
+	}
+	config, err := storage.UnmarshalPersistentStorage(p.Phase.Data.Install.PersistentStorage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	logger := &fsm.Logger{
+		FieldLogger: log.WithField(constants.FieldPhase, p.Phase.ID),
+		Key:         opKey(p.Plan),
+		Operator:    operator,
+	}
+	return &openEBSExecutor{
+		FieldLogger:    logger,
+		ExecutorParams: p,
+		Client:         client,
+		Config:         config,
+	}, nil
+}
+
+type openEBSExecutor struct {
+	// FieldLogger specifies the logger used by the executor
+	log.FieldLogger
+	// ExecutorParams contains common executor parameters
+	fsm.ExecutorParams
+	// Client is the Kubernetes client
+	Client *kubernetes.Clientset
+	// Config is the persistent storage configuration
+	Config storage.PersistentStorage
+}
+
+// Execute creates the cStor pool clusters and storage classes of the configured
+// storage pools. The phase waits for the node disk manager to discover
+// the block devices claimed by the pools
+func (r *openEBSExecutor) Execute(ctx context.Context) error {
+	r.Progress.NextStep("Configuring OpenEBS storage pools")
+	ctx, cancel := context.WithTimeout(ctx, defaults.BlockDeviceDiscoveryTimeout)
+	defer cancel()
+	err := utils.RetryWithInterval(ctx, backoff.NewConstantBackOff(5*time.Second), func() error {
+		err := openebs.Reconcile(r.Client, openebs.Config{
+			Pools:       r.Config.GetPools(),
+			Servers:     r.Plan.Servers,
+			FieldLogger: r.FieldLogger,
+		})
+		if err != nil && !trace.IsNotFound(err) {
+			return &backoff.PermanentError{Err: err}
+		}
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	r.Info("Configured OpenEBS storage pools.")
+	return nil
+}
+
+// Rollback is no-op for this phase
+func (*openEBSExecutor) Rollback(context.Context) error {
+	return nil
+}
+
+// PreCheck is no-op for this phase
+func (*openEBSExecutor) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is no-op for this phase
+func (*openEBSExecutor) PostCheck(context.Context) error {
+	return nil
+}
@@ -330,8 +330,16 @@ func (p *pullExecutor) unpackPackages() error {
 		return trace.Wrap(err)
 	}
 	for _, locator := range locators {
+		staged, err := pack.IsStaged(p.LocalPackages, locator)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if staged {
+			p.Infof("Package %v has been staged, skip unpacking.", locator)
+			continue
+		}
 		p.Infof("Unpacking package %v.", locator)
-		err := pack.Unpack(p.LocalPackages, locator, "", nil)
+		err = pack.Unpack(p.LocalPackages, locator, "", nil)
 		if err != nil {
 			return trace.Wrap(err)
 		}
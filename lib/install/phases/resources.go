@@ -24,8 +24,10 @@ import (
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/fsm"
+	libkubernetes "github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/resources"
+	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/utils"
@@ -57,6 +59,7 @@ func NewSystemResources(p fsm.ExecutorParams, operator ops.Operator, client *kub
 		ExecutorParams: p,
 		Client:         client,
 		Cluster:        ops.ConvertOpsSite(*cluster),
+		Manifest:       cluster.App.Manifest,
 	}, nil
 }
 
@@ -70,6 +73,8 @@ type systemResources struct {
 	Client *kubernetes.Clientset
 	// Cluster is the cluster that is being installed.
 	Cluster storage.Site
+	// Manifest is the manifest of the application being installed.
+	Manifest schema.Manifest
 }
 
 // Execute creates system Kubernetes resources.
@@ -79,6 +84,12 @@ func (r *systemResources) Execute(ctx context.Context) error {
 	if err := r.createClusterInfoMap(); err != nil {
 		return trace.Wrap(err)
 	}
+	if policy := libkubernetes.GetNetworkPolicy(r.Manifest, r.Cluster.DefaultDenyNetworkPolicy); policy != nil {
+		r.Info("Creating baseline network policies.")
+		if err := libkubernetes.ApplyBaselineNetworkPolicies(r.Client, *policy); err != nil {
+			return trace.Wrap(err)
+		}
+	}
 	return nil
 }
 
@@ -96,6 +107,11 @@ func (r *systemResources) createClusterInfoMap() error {
 
 // Rollback deletes created system Kubernetes resources.
 func (r *systemResources) Rollback(context.Context) error {
+	if policy := libkubernetes.GetNetworkPolicy(r.Manifest, r.Cluster.DefaultDenyNetworkPolicy); policy != nil {
+		if err := libkubernetes.RemoveBaselineNetworkPolicies(r.Client, *policy); err != nil {
+			return trace.Wrap(err)
+		}
+	}
 	err := rigging.ConvertError(r.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).Delete(
 		constants.ClusterInfoMap, &metav1.DeleteOptions{}))
 	if err != nil && !trace.IsNotFound(err) {
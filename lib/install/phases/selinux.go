@@ -0,0 +1,162 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/system/selinux"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// NewSELinuxBootstrapPhase splits the specified node bootstrap phase into
+// a sub-phase that configures SELinux on the node followed by the
+// regular system bootstrap
+func NewSELinuxBootstrapPhase(phase storage.OperationPhase) storage.OperationPhase {
+	node := phase.Data.Server
+	system := phase
+	system.ID = fmt.Sprintf("%v/%v", phase.ID, SystemPhase)
+	system.Description = fmt.Sprintf("Configure system on node %v", node.Hostname)
+	system.Requires = []string{fmt.Sprintf("%v/%v", phase.ID, SELinuxPhase)}
+	return storage.OperationPhase{
+		ID:          phase.ID,
+		Description: phase.Description,
+		Phases: []storage.OperationPhase{
+			{
+				ID:          fmt.Sprintf("%v/%v", phase.ID, SELinuxPhase),
+				Description: fmt.Sprintf("Configure SELinux on node %v", node.Hostname),
+				Data: &storage.OperationPhaseData{
+					Server:     phase.Data.Server,
+					ExecServer: phase.Data.ExecServer,
+				},
+				Step: phase.Step,
+			},
+			system,
+		},
+		Requires: phase.Requires,
+		Step:     phase.Step,
+	}
+}
+
+// NewSELinux returns a new executor that configures SELinux on a node
+func NewSELinux(p fsm.ExecutorParams, operator ops.Operator, remote fsm.Remote) (*selinuxExecutor, error) {
+	if p.Phase.Data == nil || p.Phase.Data.Server == nil {
+		return nil, trace.BadParameter("server is required: %#v", p.Phase.Data)
+	}
+	logger := &fsm.Logger{
+		FieldLogger: logrus.WithFields(logrus.Fields{
+			constants.FieldPhase:       p.Phase.ID,
+			constants.FieldAdvertiseIP: p.Phase.Data.Server.AdvertiseIP,
+			constants.FieldHostname:    p.Phase.Data.Server.Hostname,
+		}),
+		Key:      opKey(p.Plan),
+		Operator: operator,
+		Server:   p.Phase.Data.Server,
+	}
+	return &selinuxExecutor{
+		FieldLogger:    logger,
+		ExecutorParams: p,
+		remote:         remote,
+	}, nil
+}
+
+type selinuxExecutor struct {
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+	// ExecutorParams is common executor params
+	fsm.ExecutorParams
+	// remote specifies the server remote control interface
+	remote fsm.Remote
+}
+
+// Execute loads the gravity SELinux policy module and labels
+// the state directory and device files
+func (p *selinuxExecutor) Execute(ctx context.Context) error {
+	p.Progress.NextStep("Configuring SELinux")
+	config, err := p.getConfig()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(selinux.Bootstrap(ctx, *config))
+}
+
+// Rollback removes the labels and unloads the gravity SELinux policy module
+func (p *selinuxExecutor) Rollback(ctx context.Context) error {
+	config, err := p.getConfig()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(selinux.Unload(ctx, *config))
+}
+
+// PreCheck makes sure this phase is executed on a proper server
+// and that SELinux is enabled on it
+func (p *selinuxExecutor) PreCheck(ctx context.Context) error {
+	err := p.remote.CheckServer(ctx, *p.Phase.Data.Server)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	mode, err := systeminfo.GetSELinuxMode()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !mode.IsEnabled() {
+		return trace.BadParameter("SELinux is disabled on node %v",
+			p.Phase.Data.Server.Hostname)
+	}
+	return nil
+}
+
+// PostCheck is no-op for this phase
+func (*selinuxExecutor) PostCheck(ctx context.Context) error {
+	return nil
+}
+
+func (p *selinuxExecutor) getConfig() (*selinux.Config, error) {
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var devices []string
+	if device := p.Phase.Data.Server.Docker.Device.Path(); device != "" {
+		devices = append(devices, device)
+	}
+	return &selinux.Config{
+		FieldLogger: p.FieldLogger,
+		StateDir:    stateDir,
+		// Directories used by the cluster services. Package BLOBs
+		// in the local package storage can be large and are only
+		// accessed by gravity itself, so they are not relabeled
+		Paths: []string{
+			defaults.PlanetDir,
+			defaults.SiteDir,
+			defaults.TeleportDir,
+			defaults.SecretsDir,
+		},
+		Devices: devices,
+	}, nil
+}
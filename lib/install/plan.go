@@ -18,7 +18,6 @@ package install
 
 import (
 	"github.com/gravitational/gravity/lib/ops"
-	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
 
 	"github.com/gravitational/trace"
@@ -86,11 +85,18 @@ func (r *Planner) GetOperationPlan(operator ops.Operator, cluster ops.Site, oper
 	builder.AddSystemResourcesPhase(plan)
 	builder.AddUserResourcesPhase(plan)
 
-	// export applications to registries
-	builder.AddExportPhase(plan)
+	// export applications to registries unless the registries
+	// are populated from the pre-seeded registry directory
+	if builder.RegistryDir == "" {
+		builder.AddExportPhase(plan)
+	}
 
-	if cluster.App.Manifest.HasHook(schema.HookNetworkInstall) {
-		builder.AddInstallOverlayPhase(plan, &cluster.App.Package)
+	networkApp, err := cluster.App.Manifest.NetworkApp(cluster.CNI, cluster.App.Package)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if networkApp != nil {
+		builder.AddInstallOverlayPhase(plan, networkApp)
 	}
 	builder.AddHealthPhase(plan)
 
@@ -100,6 +106,10 @@ func (r *Planner) GetOperationPlan(operator ops.Operator, cluster ops.Site, oper
 		return nil, trace.Wrap(err)
 	}
 
+	// configure storage pools before the user application
+	// so it can request volumes from them
+	builder.AddOpenEBSPhase(plan)
+
 	// install user application
 	err = builder.AddApplicationPhase(plan)
 	if err != nil {
@@ -512,6 +512,18 @@ func (s *PlanSuite) verifyUserResourcesPhase(c *check.C, phase storage.Operation
   "data": {
     "HTTP_PROXY": "example.com:8081"
   }
+}
+{
+  "kind":"ConfigMap",
+  "apiVersion":"v1",
+  "metadata": {
+    "name": "cluster-proxy",
+    "namespace": "kube-system",
+    "creationTimestamp": null
+  },
+  "data": {
+    "HTTP_PROXY": "example.com:8081"
+  }
 }
 	`)
 	phase.Data.Install.Resources = nil // Compare resources separately
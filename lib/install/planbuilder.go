@@ -34,6 +34,7 @@ import (
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/gravitational/trace"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -71,12 +72,23 @@ type PlanBuilder struct {
 	env map[string]string
 	// config specifies the optional cluster configuration
 	config []byte
+	// dns specifies the optional cluster DNS configuration
+	dns []byte
+	// persistentStorage specifies the optional persistent storage
+	// configuration with OpenEBS storage pools
+	persistentStorage []byte
 	// resources specifies the optional Kubernetes resources to create
 	resources []byte
 	// gravityResources specifies the optional Gravity resources to create upon successful install
 	gravityResources []storage.UnknownResource
 	// InstallerTrustedCluster represents the trusted cluster for installer process
 	InstallerTrustedCluster storage.TrustedCluster
+	// SELinux specifies whether to configure SELinux on the nodes
+	SELinux bool
+	// RegistryDir specifies the optional pre-seeded docker registry directory.
+	// If specified, the cluster registry is populated from this directory
+	// and the application images are not exported
+	RegistryDir string
 }
 
 // AddInitPhase appends initialization phase to the provided plan
@@ -127,6 +139,7 @@ func (b *PlanBuilder) AddConfigurePhase(plan *storage.OperationPlan) {
 			Install: &storage.InstallOperationData{
 				Env:    b.env,
 				Config: b.config,
+				DNS:    b.dns,
 			},
 		},
 		Step: 3,
@@ -147,7 +160,7 @@ func (b *PlanBuilder) AddBootstrapPhase(plan *storage.OperationPlan) {
 			description = "Bootstrap node %v"
 			agent = &b.RegularAgent
 		}
-		bootstrapPhases = append(bootstrapPhases, storage.OperationPhase{
+		phase := storage.OperationPhase{
 			ID:          fmt.Sprintf("%v/%v", phases.BootstrapPhase, node.Hostname),
 			Description: fmt.Sprintf(description, node.Hostname),
 			Data: &storage.OperationPhaseData{
@@ -158,7 +171,16 @@ func (b *PlanBuilder) AddBootstrapPhase(plan *storage.OperationPlan) {
 				ServiceUser: &b.ServiceUser,
 			},
 			Step: 3,
-		})
+		}
+		if b.RegistryDir != "" && node.IsMaster() {
+			phase.Data.Install = &storage.InstallOperationData{
+				RegistryDir: b.RegistryDir,
+			}
+		}
+		if b.SELinux {
+			phase = phases.NewSELinuxBootstrapPhase(phase)
+		}
+		bootstrapPhases = append(bootstrapPhases, phase)
 	}
 	plan.Phases = append(plan.Phases, storage.OperationPhase{
 		ID:          phases.BootstrapPhase,
@@ -422,14 +444,20 @@ func (b *PlanBuilder) AddInstallOverlayPhase(plan *storage.OperationPlan, locato
 
 // AddCorednsPhase generates default coredns configuration for the cluster
 func (b *PlanBuilder) AddCorednsPhase(plan *storage.OperationPlan) {
+	data := &storage.OperationPhaseData{
+		Server: &b.Master,
+	}
+	if len(b.dns) != 0 {
+		data.Install = &storage.InstallOperationData{
+			DNS: b.dns,
+		}
+	}
 	plan.Phases = append(plan.Phases, storage.OperationPhase{
 		ID:          phases.CorednsPhase,
 		Description: "Configure CoreDNS",
-		Data: &storage.OperationPhaseData{
-			Server: &b.Master,
-		},
-		Requires: []string{phases.WaitPhase},
-		Step:     4,
+		Data:        data,
+		Requires:    []string{phases.WaitPhase},
+		Step:        4,
 	})
 }
 
@@ -494,6 +522,26 @@ func (b *PlanBuilder) AddRuntimePhase(plan *storage.OperationPlan) error {
 	return nil
 }
 
+// AddOpenEBSPhase appends the phase that configures the OpenEBS storage pools
+// to the provided plan if the persistent storage configuration declares any
+func (b *PlanBuilder) AddOpenEBSPhase(plan *storage.OperationPlan) {
+	if len(b.persistentStorage) == 0 {
+		return
+	}
+	plan.Phases = append(plan.Phases, storage.OperationPhase{
+		ID:          phases.OpenEBSPhase,
+		Description: "Configure OpenEBS storage pools",
+		Data: &storage.OperationPhaseData{
+			Server: &b.Master,
+			Install: &storage.InstallOperationData{
+				PersistentStorage: b.persistentStorage,
+			},
+		},
+		Requires: []string{phases.RuntimePhase},
+		Step:     5,
+	})
+}
+
 // AddApplicationPhase appends user application installation phase to the provided plan
 func (b *PlanBuilder) AddApplicationPhase(plan *storage.OperationPlan) error {
 	applicationLocators, err := app.GetDirectDeps(b.Application)
@@ -502,6 +550,9 @@ func (b *PlanBuilder) AddApplicationPhase(plan *storage.OperationPlan) error {
 	}
 	var applicationPhases []storage.OperationPhase
 	for i, locator := range applicationLocators {
+		if b.skipComponent(locator) {
+			continue
+		}
 		applicationPhases = append(applicationPhases, storage.OperationPhase{
 			ID: fmt.Sprintf("%v/%v", phases.AppPhase, locator.Name),
 			Description: fmt.Sprintf("Install application %v:%v",
@@ -563,7 +614,14 @@ func (b *PlanBuilder) skipDependency(dep loc.Locator) bool {
 	if dep.Name == constants.BootstrapConfigPackage {
 		return true // rbac-app is installed separately
 	}
-	return schema.ShouldSkipApp(b.Application.Manifest, dep)
+	return schema.ShouldSkipApp(b.Application.Manifest, dep, b.Cluster.DisabledComponents...)
+}
+
+// skipComponent returns true if the application package specified by dep
+// belongs to one of the application components excluded from the cluster
+func (b *PlanBuilder) skipComponent(dep loc.Locator) bool {
+	return utils.StringInSlice(
+		b.Application.Manifest.Components.Apps(b.Cluster.DisabledComponents), dep.Name)
 }
 
 // GetPlanBuilder returns a new plan builder for this installer and provided
@@ -653,8 +711,18 @@ func (c *Config) GetPlanBuilder(operator ops.Operator, cluster ops.Site, op ops.
 			GID:  strconv.Itoa(c.ServiceUser.GID),
 		},
 		InstallerTrustedCluster: trustedCluster,
+		SELinux:                 c.SELinux,
+		RegistryDir:             c.RegistryDir,
 	}
-	err = addResources(builder, cluster.Resources, c.RuntimeResources, c.ClusterResources)
+	// Resources bundled with the cluster image are created unless overridden
+	// with the resources specified on the command line or uploaded via the install API
+	defaultResources, err := resourceutil.DefaultResources(cluster.App.Manifest)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	clusterResources := append(append([]storage.UnknownResource{}, c.ClusterResources...),
+		cluster.InstallResources...)
+	err = addResources(builder, cluster.Resources, c.RuntimeResources, defaultResources, clusterResources)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -709,12 +777,12 @@ func splitServers(servers []storage.Server, app app.Application) (masters []stor
 	return masters, nodes, nil
 }
 
-func addResources(builder *PlanBuilder, resourceBytes []byte, runtimeResources []runtime.Object, clusterResources []storage.UnknownResource) error {
+func addResources(builder *PlanBuilder, resourceBytes []byte, runtimeResources []runtime.Object, defaultResources, clusterResources []storage.UnknownResource) error {
 	kubernetesResources, gravityResources, err := resourceutil.Split(bytes.NewReader(resourceBytes))
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	gravityResources = append(gravityResources, clusterResources...)
+	gravityResources = resourceutil.Merge(defaultResources, append(gravityResources, clusterResources...))
 	rest := gravityResources[:0]
 	for _, res := range gravityResources {
 		switch res.Kind {
@@ -725,11 +793,29 @@ func addResources(builder *PlanBuilder, resourceBytes []byte, runtimeResources [
 			}
 			builder.env = env.GetKeyValues()
 			configmap := opsservice.NewEnvironmentConfigMap(env.GetKeyValues())
-			kubernetesResources = append(kubernetesResources, configmap)
+			proxyConfigmap := opsservice.NewProxyConfigMap(env.GetKeyValues())
+			kubernetesResources = append(kubernetesResources, configmap, proxyConfigmap)
 		case storage.KindClusterConfiguration:
 			builder.config = res.Raw
 			configmap := opsservice.NewConfigurationConfigMap(res.Raw)
 			kubernetesResources = append(kubernetesResources, configmap)
+		case storage.KindClusterDNS:
+			if _, err := storage.UnmarshalClusterDNS(res.Raw); err != nil {
+				return trace.Wrap(err)
+			}
+			builder.dns = res.Raw
+			configmap := opsservice.NewClusterDNSConfigMap(res.Raw)
+			kubernetesResources = append(kubernetesResources, configmap)
+		case storage.KindPersistentStorage:
+			config, err := storage.UnmarshalPersistentStorage(res.Raw)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			if len(config.GetPools()) != 0 {
+				builder.persistentStorage = res.Raw
+			}
+			// The configuration itself is created using the regular workflow
+			rest = append(rest, res)
 		default:
 			// Filter out resources that are created using the regular workflow
 			rest = append(rest, res)
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"github.com/gravitational/gravity/lib/install/phases"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"gopkg.in/check.v1"
+)
+
+type PlanBuilderSuite struct{}
+
+var _ = check.Suite(&PlanBuilderSuite{})
+
+func (s *PlanBuilderSuite) TestSELinuxBootstrapPhase(c *check.C) {
+	master := storage.Server{Hostname: "node-1", ClusterRole: string(schema.ServiceRoleMaster)}
+	builder := PlanBuilder{
+		Masters:     []storage.Server{master},
+		ServiceUser: storage.OSUser{Name: "planet", UID: "1000", GID: "1000"},
+		SELinux:     true,
+	}
+	builder.Application.Package = loc.MustParseLocator("gravitational.io/app:0.0.1")
+	var plan storage.OperationPlan
+	builder.AddBootstrapPhase(&plan)
+
+	storage.DeepComparePhases(c, storage.OperationPhase{
+		ID: phases.BootstrapPhase,
+		Phases: []storage.OperationPhase{
+			{
+				ID: "/bootstrap/node-1",
+				Phases: []storage.OperationPhase{
+					{
+						ID: "/bootstrap/node-1/selinux",
+						Data: &storage.OperationPhaseData{
+							Server:     &master,
+							ExecServer: &master,
+						},
+					},
+					{
+						ID: "/bootstrap/node-1/system",
+						Data: &storage.OperationPhaseData{
+							Server:      &master,
+							ExecServer:  &master,
+							Package:     &builder.Application.Package,
+							Agent:       &builder.AdminAgent,
+							ServiceUser: &builder.ServiceUser,
+						},
+						Requires: []string{"/bootstrap/node-1/selinux"},
+					},
+				},
+			},
+		},
+		Parallel: true,
+	}, plan.Phases[0])
+}
+
+func (s *PlanBuilderSuite) TestRegistryDirBootstrapPhase(c *check.C) {
+	master := storage.Server{Hostname: "node-1", ClusterRole: string(schema.ServiceRoleMaster)}
+	node := storage.Server{Hostname: "node-2", ClusterRole: string(schema.ServiceRoleNode)}
+	builder := PlanBuilder{
+		Masters:     []storage.Server{master},
+		Nodes:       []storage.Server{node},
+		ServiceUser: storage.OSUser{Name: "planet", UID: "1000", GID: "1000"},
+		RegistryDir: "/var/lib/registry",
+	}
+	builder.Application.Package = loc.MustParseLocator("gravitational.io/app:0.0.1")
+	var plan storage.OperationPlan
+	builder.AddBootstrapPhase(&plan)
+
+	storage.DeepComparePhases(c, storage.OperationPhase{
+		ID: phases.BootstrapPhase,
+		Phases: []storage.OperationPhase{
+			{
+				ID: "/bootstrap/node-1",
+				Data: &storage.OperationPhaseData{
+					Server:      &master,
+					ExecServer:  &master,
+					Package:     &builder.Application.Package,
+					Agent:       &builder.AdminAgent,
+					ServiceUser: &builder.ServiceUser,
+					Install: &storage.InstallOperationData{
+						RegistryDir: "/var/lib/registry",
+					},
+				},
+			},
+			{
+				ID: "/bootstrap/node-2",
+				Data: &storage.OperationPhaseData{
+					Server:      &node,
+					ExecServer:  &node,
+					Package:     &builder.Application.Package,
+					Agent:       &builder.RegularAgent,
+					ServiceUser: &builder.ServiceUser,
+				},
+			},
+		},
+		Parallel: true,
+	}, plan.Phases[0])
+}
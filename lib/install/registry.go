@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/app/docker"
+	"github.com/gravitational/gravity/lib/archive"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/utils"
+
+	dockerarchive "github.com/docker/docker/pkg/archive"
+	"github.com/gravitational/trace"
+)
+
+// ValidateRegistryDir makes sure that the pre-seeded registry directory specified
+// with dir contains the images of the application and all its enabled dependencies
+// so the images do not need to be exported to the cluster registry during install
+func ValidateRegistryDir(ctx context.Context, dir string, application app.Application, disabledComponents []string, packages pack.PackageService) error {
+	images, err := docker.ListLocalImages(ctx, dir)
+	if err != nil {
+		return trace.Wrap(err, "invalid registry directory %v", dir)
+	}
+	present := make(map[docker.TagSpec]bool, len(images))
+	for _, image := range images {
+		present[image] = true
+	}
+	var missing []string
+	for _, locator := range registryApps(application, disabledComponents) {
+		expected, err := packageImages(packages, locator)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		for _, image := range expected {
+			if !present[image] {
+				missing = append(missing, fmt.Sprintf("%v (%v)", image, locator.Name))
+			}
+		}
+	}
+	if len(missing) != 0 {
+		return trace.NotFound("registry directory %v is missing images: %v",
+			dir, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// registryApps returns the applications whose images are exported
+// to the cluster registry during install
+func registryApps(application app.Application, disabledComponents []string) (apps []loc.Locator) {
+	skipApps := application.Manifest.Components.Apps(disabledComponents)
+	for _, dep := range application.Manifest.Dependencies.Apps {
+		if !utils.StringInSlice(skipApps, dep.Locator.Name) {
+			apps = append(apps, dep.Locator)
+		}
+	}
+	return append(apps, application.Package)
+}
+
+// packageImages returns the images vendored in the registry
+// directory of the specified application package
+func packageImages(packages pack.PackageService, locator loc.Locator) (images []docker.TagSpec, err error) {
+	_, rc, err := packages.ReadPackage(locator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer rc.Close()
+	decompressed, err := dockerarchive.DecompressStream(rc)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer decompressed.Close()
+	err = archive.TarGlobWithPrefix(tar.NewReader(decompressed), defaults.RegistryDir,
+		func(header *tar.Header, _ *tar.Reader) error {
+			if image, ok := docker.ImageFromRegistryPath(header.Name); ok {
+				images = append(images, image)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to read images of %v", locator)
+	}
+	return images, nil
+}
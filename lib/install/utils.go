@@ -194,9 +194,39 @@ func FetchCloudMetadata(cloudProvider string, config *pb.RuntimeConfig) error {
 			"--cloud-provider=generic flag", strings.ToUpper(cloudProvider), err, docLink)
 	}
 	config.CloudMetadata = metadata
+	zone, err := rpcserver.GetAvailabilityZone(cloudProvider)
+	if err != nil {
+		log.WithError(err).Warn("Failed to determine availability zone.")
+		return nil
+	}
+	setKeyValue(config, ops.AgentAvailabilityZone, zone)
 	return nil
 }
 
+// addMachineSerial records the serial number of this machine
+// in the agent runtime configuration if it is available
+func addMachineSerial(config *pb.RuntimeConfig) {
+	dmi, err := systeminfo.QueryDMI()
+	if err != nil {
+		log.WithError(err).Warn("Failed to query machine identification data.")
+		return
+	}
+	if dmi.SerialNumber != "" {
+		setKeyValue(config, ops.AgentMachineSerial, dmi.SerialNumber)
+	}
+}
+
+// setKeyValue sets the agent runtime parameter on a copy of the parameters
+// so the caller's map is not modified
+func setKeyValue(config *pb.RuntimeConfig, key, value string) {
+	vars := make(map[string]string, len(config.KeyValues)+1)
+	for k, v := range config.KeyValues {
+		vars[k] = v
+	}
+	vars[key] = value
+	config.KeyValues = vars
+}
+
 // LoadRPCCredentials loads and validates the contents of the default RPC credentials package
 func LoadRPCCredentials(ctx context.Context, packages pack.PackageService) (*rpcserver.Credentials, error) {
 	tls, err := loadCredentialsFromPackage(ctx, packages, loc.RPCSecrets)
@@ -251,6 +281,10 @@ func GetServerUpdateRequest(op ops.SiteOperation, servers []checks.ServerInfo) (
 			return nil, trace.BadParameter("%v has no role", serverInfo)
 		}
 		ip, _ := utils.SplitHostPort(serverInfo.AdvertiseAddr, "")
+		labels, taints, err := ops.ParseNodeVars(serverInfo.KeyValues)
+		if err != nil {
+			return nil, trace.Wrap(err, "invalid runtime configuration of %v", serverInfo)
+		}
 		server := storage.Server{
 			AdvertiseIP: ip,
 			Hostname:    serverInfo.GetHostname(),
@@ -260,6 +294,11 @@ func GetServerUpdateRequest(op ops.SiteOperation, servers []checks.ServerInfo) (
 			User:        serverInfo.GetUser(),
 			Provisioner: op.Provisioner,
 			Created:     time.Now().UTC(),
+			Labels:      labels,
+			Taints:      taints,
+		}
+		if err := ops.SetNodeMetadata(&server, serverInfo.KeyValues); err != nil {
+			return nil, trace.Wrap(err, "invalid runtime configuration of %v", serverInfo)
 		}
 		if serverInfo.CloudMetadata != nil {
 			server.Nodename = serverInfo.CloudMetadata.NodeName
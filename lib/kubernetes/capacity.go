@@ -0,0 +1,453 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubernetes/pkg/apis/core/v1/helper"
+)
+
+// Headroom describes whether the other nodes of the cluster can host
+// the pods evicted when the node is drained
+type Headroom struct {
+	// Node is the name of the drained node
+	Node string `json:"node"`
+	// RequestedCPU is the CPU in millicores requested by the evicted pods
+	RequestedCPU int64 `json:"requested_cpu"`
+	// RequestedMemory is the memory in bytes requested by the evicted pods
+	RequestedMemory int64 `json:"requested_memory"`
+	// AvailableCPU is the unrequested CPU in millicores on the other schedulable nodes
+	AvailableCPU int64 `json:"available_cpu"`
+	// AvailableMemory is the unrequested memory in bytes on the other schedulable nodes
+	AvailableMemory int64 `json:"available_memory"`
+	// UnschedulablePods lists the evicted pods that do not fit on any
+	// of the other nodes
+	UnschedulablePods []UnschedulablePod `json:"unschedulable_pods,omitempty"`
+	// StrandedPods lists the evicted pods that cannot be rescheduled because
+	// their persistent volumes are not accessible from any other node
+	StrandedPods []StrandedPod `json:"stranded_pods,omitempty"`
+}
+
+// UnschedulablePod is an evicted pod that does not fit on any
+// of the other nodes
+type UnschedulablePod struct {
+	// Namespace is the pod namespace
+	Namespace string `json:"namespace"`
+	// Name is the pod name
+	Name string `json:"name"`
+	// Reason describes why the pod does not fit on the other nodes
+	Reason string `json:"reason"`
+}
+
+// String returns a textual representation of this pod
+func (r UnschedulablePod) String() string {
+	return fmt.Sprintf("%v/%v", r.Namespace, r.Name)
+}
+
+// StrandedPod is an evicted pod bound to a persistent volume
+// that is not accessible from any other node
+type StrandedPod struct {
+	// Namespace is the pod namespace
+	Namespace string `json:"namespace"`
+	// Name is the pod name
+	Name string `json:"name"`
+	// Volume is the name of the persistent volume
+	Volume string `json:"volume"`
+}
+
+// String returns a textual representation of this pod
+func (r StrandedPod) String() string {
+	return fmt.Sprintf("%v/%v (volume %v)", r.Namespace, r.Name, r.Volume)
+}
+
+// HasResources returns true if each evicted pod fits on one of the other nodes
+func (r Headroom) HasResources() bool {
+	return len(r.UnschedulablePods) == 0
+}
+
+// Sufficient returns true if all evicted pods can be rescheduled
+// on the other nodes
+func (r Headroom) Sufficient() bool {
+	return r.HasResources() && len(r.StrandedPods) == 0
+}
+
+// Problems returns the reasons the evicted pods cannot be rescheduled
+func (r Headroom) Problems() (problems []string) {
+	for _, pod := range r.UnschedulablePods {
+		problems = append(problems, fmt.Sprintf("pod %v cannot be rescheduled: %v", pod, pod.Reason))
+	}
+	for _, pod := range r.StrandedPods {
+		problems = append(problems, fmt.Sprintf("pod %v cannot be rescheduled, "+
+			"the volume is not accessible from the other nodes", pod))
+	}
+	return problems
+}
+
+// HeadroomProblems returns the reasons the pods evicted from the nodes
+// with the specified headrooms cannot be rescheduled, prefixed with the node name
+func HeadroomProblems(headrooms ...Headroom) (problems []string) {
+	for _, headroom := range headrooms {
+		for _, problem := range headroom.Problems() {
+			problems = append(problems, fmt.Sprintf("node %v: %v", headroom.Node, problem))
+		}
+	}
+	return problems
+}
+
+// CheckHeadroom logs a warning if the other nodes do not have the capacity
+// to host the pods evicted from the specified node.
+// Capacity is verified before an operation is started so this only
+// reports the changes in the cluster since then
+func CheckHeadroom(client *kubernetes.Clientset, node string, logger log.FieldLogger) {
+	headrooms, err := GetHeadroom(client, node)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to verify cluster capacity.")
+		return
+	}
+	for _, problem := range HeadroomProblems(headrooms...) {
+		logger.Warnf("Insufficient capacity to drain: %v.", problem)
+	}
+}
+
+// GetHeadroom returns the headroom for draining each of the specified nodes
+func GetHeadroom(client *kubernetes.Clientset, nodeNames ...string) ([]Headroom, error) {
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	volumes, err := client.CoreV1().PersistentVolumes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	headrooms := make([]Headroom, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		headrooms = append(headrooms, ComputeHeadroom(nodeName,
+			nodes.Items, pods.Items, volumes.Items))
+	}
+	return headrooms, nil
+}
+
+// ComputeHeadroom computes the headroom for draining the specified node
+// given the current state of the cluster.
+//
+// The evicted pods are placed on the other schedulable and ready nodes one
+// at a time, the way the scheduler would, honoring the node selector, the required
+// node affinity and the tolerations of each pod as well as the node affinity
+// of its persistent volumes.
+//
+// DaemonSet and static pods are not evicted and pods not managed
+// by a controller are not rescheduled so neither counts against the capacity
+func ComputeHeadroom(nodeName string, nodes []v1.Node, pods []v1.Pod, volumes []v1.PersistentVolume) Headroom {
+	headroom := Headroom{Node: nodeName}
+	var others []v1.Node
+	for _, node := range nodes {
+		if node.Name != nodeName && !node.Spec.Unschedulable && IsNodeReady(node) {
+			others = append(others, node)
+		}
+	}
+	claims := make(map[string]v1.PersistentVolume)
+	for _, volume := range volumes {
+		if ref := volume.Spec.ClaimRef; ref != nil {
+			claims[ref.Namespace+"/"+ref.Name] = volume
+		}
+	}
+	requested := make(map[string]v1.ResourceList)
+	podCounts := make(map[string]int64)
+	var evicted v1.ResourceList
+	var evictedPods []v1.Pod
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if pod.Spec.NodeName != nodeName {
+			AddResources(requested, pod.Spec.NodeName, PodRequests(pod))
+			podCounts[pod.Spec.NodeName]++
+			continue
+		}
+		if IsDaemonSetPod(pod) || IsMirrorPod(pod) || !HasController(pod) {
+			continue
+		}
+		evicted = SumResources(evicted, PodRequests(pod))
+		stranded := podVolumes(pod, claims, func(volume v1.PersistentVolume) bool {
+			return !isAccessible(volume, others)
+		})
+		for _, volume := range stranded {
+			headroom.StrandedPods = append(headroom.StrandedPods, StrandedPod{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				Volume:    volume.Name,
+			})
+		}
+		if len(stranded) == 0 {
+			evictedPods = append(evictedPods, pod)
+		}
+	}
+	free := make(map[string]v1.ResourceList, len(others))
+	var available v1.ResourceList
+	for _, node := range others {
+		free[node.Name] = FreeResources(node.Status.Allocatable, requested[node.Name])
+		available = SumResources(available, free[node.Name])
+	}
+	headroom.UnschedulablePods = schedulePods(evictedPods, others, free, podCounts, claims)
+	headroom.RequestedCPU = evicted.Cpu().MilliValue()
+	headroom.RequestedMemory = evicted.Memory().Value()
+	headroom.AvailableCPU = available.Cpu().MilliValue()
+	headroom.AvailableMemory = available.Memory().Value()
+	return headroom
+}
+
+// schedulePods places the specified pods on the nodes in order of decreasing
+// resource requests, each on the first node it fits on, and updates the free
+// resources and the pod counts of the nodes accordingly.
+// Returns the pods that do not fit on any node
+func schedulePods(pods []v1.Pod, nodes []v1.Node, free map[string]v1.ResourceList, podCounts map[string]int64, claims map[string]v1.PersistentVolume) (unschedulable []UnschedulablePod) {
+	sort.SliceStable(pods, func(i, j int) bool {
+		a, b := PodRequests(pods[i]), PodRequests(pods[j])
+		if cmp := a.Cpu().Cmp(*b.Cpu()); cmp != 0 {
+			return cmp > 0
+		}
+		return a.Memory().Cmp(*b.Memory()) > 0
+	})
+	for _, pod := range pods {
+		requests := PodRequests(pod)
+		reasons := make(map[string]int)
+		scheduled := false
+		for _, node := range nodes {
+			reason := checkFit(pod, requests, node, free[node.Name], podCounts[node.Name], claims)
+			if reason != "" {
+				reasons[reason]++
+				continue
+			}
+			free[node.Name] = FreeResources(free[node.Name], requests)
+			podCounts[node.Name]++
+			scheduled = true
+			break
+		}
+		if !scheduled {
+			unschedulable = append(unschedulable, UnschedulablePod{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				Reason:    formatFitReasons(len(nodes), reasons),
+			})
+		}
+	}
+	return unschedulable
+}
+
+// checkFit returns the reason the pod with the specified requests does not fit
+// on the node with the specified free resources and number of pods,
+// or an empty string if it fits
+func checkFit(pod v1.Pod, requests v1.ResourceList, node v1.Node, free v1.ResourceList, pods int64, claims map[string]v1.PersistentVolume) string {
+	if !helper.TolerationsTolerateTaintsWithFilter(pod.Spec.Tolerations, node.Spec.Taints, isSchedulingTaint) {
+		return "node(s) had taints that the pod didn't tolerate"
+	}
+	if !matchesNodeSelector(pod, node) {
+		return "node(s) didn't match node selector"
+	}
+	conflicts := podVolumes(pod, claims, func(volume v1.PersistentVolume) bool {
+		return !isAccessible(volume, []v1.Node{node})
+	})
+	if len(conflicts) != 0 {
+		return "node(s) had volume node affinity conflict"
+	}
+	if maxPods, ok := node.Status.Allocatable[v1.ResourcePods]; ok && pods >= maxPods.Value() {
+		return "too many pods"
+	}
+	if requests.Cpu().Cmp(*free.Cpu()) > 0 {
+		return "insufficient cpu"
+	}
+	if requests.Memory().Cmp(*free.Memory()) > 0 {
+		return "insufficient memory"
+	}
+	return ""
+}
+
+// formatFitReasons formats the reasons a pod does not fit on any of the nodes
+// similar to the scheduler, e.g. "0/2 nodes are available: 2 insufficient cpu"
+func formatFitReasons(nodes int, reasons map[string]int) string {
+	if nodes == 0 {
+		return "no other schedulable and ready nodes"
+	}
+	var details []string
+	for reason, count := range reasons {
+		details = append(details, fmt.Sprintf("%v %v", count, reason))
+	}
+	sort.Strings(details)
+	return fmt.Sprintf("0/%v nodes are available: %v", nodes, strings.Join(details, ", "))
+}
+
+// isSchedulingTaint returns true if the taint prevents scheduling pods
+// that do not tolerate it
+func isSchedulingTaint(taint *v1.Taint) bool {
+	return taint.Effect == v1.TaintEffectNoSchedule || taint.Effect == v1.TaintEffectNoExecute
+}
+
+// matchesNodeSelector returns true if the node matches the node selector
+// and the required node affinity of the pod
+func matchesNodeSelector(pod v1.Pod, node v1.Node) bool {
+	if len(pod.Spec.NodeSelector) != 0 {
+		selector := labels.SelectorFromSet(labels.Set(pod.Spec.NodeSelector))
+		if !selector.Matches(labels.Set(node.Labels)) {
+			return false
+		}
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil ||
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	return helper.MatchNodeSelectorTerms(
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms,
+		labels.Set(node.Labels), fields.Set{"metadata.name": node.Name})
+}
+
+// podVolumes returns the persistent volumes bound to the claims of the pod
+// that satisfy the specified filter
+func podVolumes(pod v1.Pod, claims map[string]v1.PersistentVolume, filter func(v1.PersistentVolume) bool) (result []v1.PersistentVolume) {
+	for _, podVolume := range pod.Spec.Volumes {
+		if podVolume.PersistentVolumeClaim == nil {
+			continue
+		}
+		volume, ok := claims[pod.Namespace+"/"+podVolume.PersistentVolumeClaim.ClaimName]
+		if ok && filter(volume) {
+			result = append(result, volume)
+		}
+	}
+	return result
+}
+
+// IsNodeReady returns true if the node reports the ready condition
+func IsNodeReady(node v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isAccessible returns true if the persistent volume can be attached
+// to at least one of the specified nodes
+func isAccessible(volume v1.PersistentVolume, nodes []v1.Node) bool {
+	affinity := volume.Spec.NodeAffinity
+	if affinity == nil || affinity.Required == nil {
+		return true
+	}
+	for _, node := range nodes {
+		if helper.MatchNodeSelectorTerms(affinity.Required.NodeSelectorTerms,
+			labels.Set(node.Labels), fields.Set{"metadata.name": node.Name}) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasController returns true if the pod is managed by a controller
+func HasController(pod v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDaemonSetPod returns true if the pod is managed by a DaemonSet
+func IsDaemonSetPod(pod v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == rigging.KindDaemonSet {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMirrorPod returns true if the pod is the API server mirror of a static pod
+func IsMirrorPod(pod v1.Pod) bool {
+	_, ok := pod.Annotations[v1.MirrorPodAnnotationKey]
+	return ok
+}
+
+// PodRequests returns the resources requested by the pod: the total requests
+// of its containers or the largest requests of its init containers, whichever
+// is greater, since init containers run one at a time before the containers
+func PodRequests(pod v1.Pod) (requests v1.ResourceList) {
+	for _, container := range pod.Spec.Containers {
+		requests = SumResources(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		requests = MaxResources(requests, container.Resources.Requests)
+	}
+	return requests
+}
+
+// FreeResources returns the allocatable resources not requested by pods
+func FreeResources(allocatable, requested v1.ResourceList) v1.ResourceList {
+	free := v1.ResourceList{}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		quantity := allocatable[name].DeepCopy()
+		quantity.Sub(requested[name])
+		if quantity.Sign() > 0 {
+			free[name] = quantity
+		}
+	}
+	return free
+}
+
+// SumResources returns the sum of CPU and memory of the specified resource lists
+func SumResources(a, b v1.ResourceList) v1.ResourceList {
+	sum := v1.ResourceList{}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		quantity := a[name].DeepCopy()
+		quantity.Add(b[name])
+		sum[name] = quantity
+	}
+	return sum
+}
+
+// MaxResources returns the maximum of CPU and memory of the specified resource lists
+func MaxResources(a, b v1.ResourceList) v1.ResourceList {
+	max := v1.ResourceList{}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		quantity := a[name].DeepCopy()
+		if quantity.Cmp(b[name]) < 0 {
+			quantity = b[name].DeepCopy()
+		}
+		max[name] = quantity
+	}
+	return max
+}
+
+// AddResources adds the requests to the resources of the specified node
+func AddResources(resources map[string]v1.ResourceList, node string, requests v1.ResourceList) {
+	resources[node] = SumResources(resources[node], requests)
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "gopkg.in/check.v1"
+)
+
+type CapacitySuite struct{}
+
+var _ = Suite(&CapacitySuite{})
+
+func (s *CapacitySuite) TestComputesHeadroom(c *C) {
+	nodes := []v1.Node{
+		newCapacityNode("node-1", "8", "8Gi"),
+		newCapacityNode("node-2", "2", "4Gi"),
+		newCapacityNode("node-3", "2", "4Gi"),
+	}
+	nodes[2].Spec.Unschedulable = true
+	pods := []v1.Pod{
+		newCapacityPod("web-1", "node-1", "ReplicaSet", "1", "2Gi", ""),
+		newCapacityPod("db-0", "node-1", "StatefulSet", "1", "1Gi", "data-db-0"),
+		newCapacityPod("bare", "node-1", "", "2", "2Gi", ""),
+		newCapacityPod("agent", "node-1", "DaemonSet", "1", "1Gi", ""),
+		newCapacityPod("web-2", "node-2", "ReplicaSet", "1", "1Gi", ""),
+	}
+	volumes := []v1.PersistentVolume{newCapacityVolume("pv-1", "data-db-0", "node-1")}
+
+	headroom := ComputeHeadroom("node-1", nodes, pods, volumes)
+	c.Assert(headroom, DeepEquals, Headroom{
+		Node:            "node-1",
+		RequestedCPU:    2000,
+		RequestedMemory: 3 * 1024 * 1024 * 1024,
+		AvailableCPU:    1000,
+		AvailableMemory: 3 * 1024 * 1024 * 1024,
+		StrandedPods: []StrandedPod{
+			{Namespace: "default", Name: "db-0", Volume: "pv-1"},
+		},
+	})
+	c.Assert(headroom.HasResources(), Equals, true)
+	c.Assert(headroom.Sufficient(), Equals, false)
+	c.Assert(HeadroomProblems(headroom), DeepEquals, []string{
+		"node node-1: pod default/db-0 (volume pv-1) cannot be rescheduled, " +
+			"the volume is not accessible from the other nodes",
+	})
+
+	headroom = ComputeHeadroom("node-2", nodes, pods, volumes)
+	c.Assert(headroom.RequestedCPU, Equals, int64(1000))
+	c.Assert(headroom.AvailableCPU, Equals, int64(3000))
+	c.Assert(headroom.Sufficient(), Equals, true)
+	c.Assert(HeadroomProblems(headroom), HasLen, 0)
+}
+
+func (s *CapacitySuite) TestPlacesEvictedPodsOnSingleNodes(c *C) {
+	nodes := []v1.Node{
+		newCapacityNode("node-1", "8", "8Gi"),
+		newCapacityNode("node-2", "2", "4Gi"),
+		newCapacityNode("node-3", "2", "4Gi"),
+	}
+	pods := []v1.Pod{
+		newCapacityPod("web-1", "node-1", "ReplicaSet", "1500m", "1Gi", ""),
+		newCapacityPod("web-2", "node-1", "ReplicaSet", "1500m", "1Gi", ""),
+		newCapacityPod("web-3", "node-1", "ReplicaSet", "1", "1Gi", ""),
+	}
+	headroom := ComputeHeadroom("node-1", nodes, pods, nil)
+	// The other nodes have 4 CPUs in total but no single node
+	// has enough CPU left for the last pod
+	c.Assert(headroom.RequestedCPU, Equals, int64(4000))
+	c.Assert(headroom.AvailableCPU, Equals, int64(4000))
+	c.Assert(headroom.UnschedulablePods, DeepEquals, []UnschedulablePod{{
+		Namespace: "default",
+		Name:      "web-3",
+		Reason:    "0/2 nodes are available: 2 insufficient cpu",
+	}})
+	c.Assert(HeadroomProblems(headroom), DeepEquals, []string{
+		"node node-1: pod default/web-3 cannot be rescheduled: 0/2 nodes are available: 2 insufficient cpu",
+	})
+}
+
+func (s *CapacitySuite) TestHonorsSchedulingConstraints(c *C) {
+	nodes := []v1.Node{
+		newCapacityNode("node-1", "8", "8Gi"),
+		newCapacityNode("node-2", "2", "4Gi"),
+		newCapacityNode("node-3", "2", "4Gi"),
+		newCapacityNode("node-4", "2", "4Gi"),
+	}
+	nodes[1].Spec.Taints = []v1.Taint{{Key: "dedicated", Value: "db", Effect: v1.TaintEffectNoSchedule}}
+	nodes[2].Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}}
+	nodes[3].Labels["disk"] = "hdd"
+
+	web := newCapacityPod("web", "node-1", "ReplicaSet", "500m", "1Gi", "")
+	ssd := newCapacityPod("ssd", "node-1", "ReplicaSet", "500m", "1Gi", "")
+	ssd.Spec.NodeSelector = map[string]string{"disk": "ssd"}
+	db := newCapacityPod("db", "node-1", "StatefulSet", "500m", "1Gi", "")
+	db.Spec.Tolerations = []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual,
+		Value: "db", Effect: v1.TaintEffectNoSchedule}}
+	db.Spec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{{
+				MatchExpressions: []v1.NodeSelectorRequirement{{
+					Key:      "kubernetes.io/hostname",
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{"node-2"},
+				}},
+			}},
+		},
+	}}
+	migrate := newCapacityPod("migrate", "node-1", "Job", "100m", "1Gi", "")
+	migrate.Spec.InitContainers = []v1.Container{{
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("3")},
+		},
+	}}
+
+	headroom := ComputeHeadroom("node-1", nodes, []v1.Pod{web, ssd, db, migrate}, nil)
+	c.Assert(headroom.RequestedCPU, Equals, int64(4500))
+	c.Assert(headroom.UnschedulablePods, DeepEquals, []UnschedulablePod{
+		{
+			Namespace: "default",
+			Name:      "migrate",
+			Reason: "0/2 nodes are available: 1 insufficient cpu, " +
+				"1 node(s) had taints that the pod didn't tolerate",
+		},
+		{
+			Namespace: "default",
+			Name:      "ssd",
+			Reason: "0/2 nodes are available: 1 node(s) didn't match node selector, " +
+				"1 node(s) had taints that the pod didn't tolerate",
+		},
+	})
+	c.Assert(headroom.HasResources(), Equals, false)
+}
+
+func newCapacityNode(name, cpu, memory string) v1.Node {
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"kubernetes.io/hostname": name},
+		},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func newCapacityPod(name, node, controllerKind, cpu, memory, claim string) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse(cpu),
+						v1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if controllerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			Kind:       controllerKind,
+			Name:       name,
+			Controller: &controller,
+		}}
+	}
+	if claim != "" {
+		pod.Spec.Volumes = []v1.Volume{{
+			Name: "data",
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+			},
+		}}
+	}
+	return pod
+}
+
+func newCapacityVolume(name, claim, node string) v1.PersistentVolume {
+	return v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: "default", Name: claim},
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{
+						MatchExpressions: []v1.NodeSelectorRequirement{{
+							Key:      "kubernetes.io/hostname",
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{node},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
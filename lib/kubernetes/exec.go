@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// PodExecutor executes commands inside pod containers
+type PodExecutor interface {
+	// Exec executes the command in the specified pod container streaming
+	// stdin to and stdout from the command
+	Exec(namespace, pod, container string, command []string, stdin io.Reader, stdout io.Writer) error
+}
+
+// NewPodExecutor returns a new executor that runs commands
+// using the exec API of the cluster specified with config
+func NewPodExecutor(client kubernetes.Interface, config *rest.Config) PodExecutor {
+	return &podExecutor{client: client, config: config}
+}
+
+type podExecutor struct {
+	client kubernetes.Interface
+	config *rest.Config
+}
+
+// Exec executes the command in the specified pod container
+func (r *podExecutor) Exec(namespace, pod, container string, command []string, stdin io.Reader, stdout io.Writer) error {
+	req := r.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(r.config, "POST", req.URL())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var stderr bytes.Buffer
+	err = executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return trace.Wrap(err, "failed to execute %q in %v/%v: %s",
+			strings.Join(command, " "), namespace, pod, stderr.String())
+	}
+	return nil
+}
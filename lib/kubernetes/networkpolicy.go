@@ -0,0 +1,207 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/schema"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetNetworkPolicy returns the baseline network policy configuration
+// for the cluster running the application with the specified manifest.
+// defaultDeny specifies whether the policies have been requested during installation.
+// Returns nil if the baseline network policies are not enabled
+func GetNetworkPolicy(manifest schema.Manifest, defaultDeny bool) *schema.NetworkPolicy {
+	if policy := manifest.NetworkPolicy(); policy != nil {
+		return policy
+	}
+	if defaultDeny {
+		return &schema.NetworkPolicy{DefaultDeny: true}
+	}
+	return nil
+}
+
+// ApplyBaselineNetworkPolicies creates or updates the baseline network policies
+// in the namespaces specified with config and labels the platform namespaces
+// so the traffic from/to the platform services is exempt from the policies.
+// Namespaces that do not exist yet are created
+func ApplyBaselineNetworkPolicies(client kubernetes.Interface, config schema.NetworkPolicy) error {
+	for _, namespace := range PlatformNamespaces {
+		err := upsertNamespace(client, namespace, map[string]string{
+			constants.PlatformNamespaceLabel: constants.True,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	for _, namespace := range config.GetNamespaces() {
+		err := upsertNamespace(client, namespace, nil)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		for _, policy := range BaselineNetworkPolicies(namespace) {
+			if err := upsertNetworkPolicy(client, policy); err != nil {
+				return trace.Wrap(err)
+			}
+			log.WithField("namespace", namespace).Infof("Applied network policy %v.", policy.Name)
+		}
+	}
+	return nil
+}
+
+// RemoveBaselineNetworkPolicies deletes the baseline network policies
+// from the namespaces specified with config
+func RemoveBaselineNetworkPolicies(client kubernetes.Interface, config schema.NetworkPolicy) error {
+	for _, namespace := range config.GetNamespaces() {
+		for _, policy := range BaselineNetworkPolicies(namespace) {
+			err := rigging.ConvertError(client.NetworkingV1().NetworkPolicies(namespace).
+				Delete(policy.Name, &metav1.DeleteOptions{}))
+			if err != nil && !trace.IsNotFound(err) {
+				return trace.Wrap(err)
+			}
+		}
+	}
+	return nil
+}
+
+// BaselineNetworkPolicies returns the baseline network policies for the specified namespace.
+// The policies deny all traffic to and from the pods in the namespace except for
+// the traffic from and to the platform namespaces, DNS queries and requests
+// to the Kubernetes API server
+func BaselineNetworkPolicies(namespace string) []networkingv1.NetworkPolicy {
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				constants.NetworkPolicyLabel: constants.NetworkPolicyBaseline,
+			},
+		}
+	}
+	platform := []networkingv1.NetworkPolicyPeer{{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{constants.PlatformNamespaceLabel: constants.True},
+		},
+	}}
+	return []networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: meta(constants.DefaultDenyNetworkPolicy),
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{
+					networkingv1.PolicyTypeIngress,
+					networkingv1.PolicyTypeEgress,
+				},
+			},
+		},
+		{
+			ObjectMeta: meta(constants.PlatformNetworkPolicy),
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{
+					networkingv1.PolicyTypeIngress,
+					networkingv1.PolicyTypeEgress,
+				},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{From: platform}},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					{To: platform},
+					{Ports: []networkingv1.NetworkPolicyPort{
+						policyPort(v1.ProtocolUDP, defaults.DNSPort),
+						policyPort(v1.ProtocolTCP, defaults.DNSPort),
+						policyPort(v1.ProtocolTCP, defaults.APIServerSecurePort),
+					}},
+				},
+			},
+		},
+	}
+}
+
+// PlatformNamespaces lists the namespaces with the platform services
+// exempt from the baseline network policies
+var PlatformNamespaces = []string{
+	defaults.KubeSystemNamespace,
+	defaults.MonitoringNamespace,
+}
+
+func upsertNetworkPolicy(client kubernetes.Interface, policy networkingv1.NetworkPolicy) error {
+	policies := client.NetworkingV1().NetworkPolicies(policy.Namespace)
+	_, err := policies.Create(&policy)
+	err = rigging.ConvertError(err)
+	if err == nil || !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	existing, err := policies.Get(policy.Name, metav1.GetOptions{})
+	if err != nil {
+		return trace.Wrap(rigging.ConvertError(err))
+	}
+	policy.ResourceVersion = existing.ResourceVersion
+	_, err = policies.Update(&policy)
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+// upsertNamespace creates the namespace with the specified name and labels
+// or adds the labels to the existing namespace
+func upsertNamespace(client kubernetes.Interface, name string, labels map[string]string) error {
+	_, err := client.CoreV1().Namespaces().Create(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	})
+	err = rigging.ConvertError(err)
+	if err == nil || !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	namespace, err := client.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return trace.Wrap(rigging.ConvertError(err))
+	}
+	var updated bool
+	for key, value := range labels {
+		if namespace.Labels[key] == value {
+			continue
+		}
+		if namespace.Labels == nil {
+			namespace.Labels = make(map[string]string)
+		}
+		namespace.Labels[key] = value
+		updated = true
+	}
+	if !updated {
+		return nil
+	}
+	_, err = client.CoreV1().Namespaces().Update(namespace)
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+func policyPort(protocol v1.Protocol, port int) networkingv1.NetworkPolicyPort {
+	portValue := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{
+		Protocol: &protocol,
+		Port:     &portValue,
+	}
+}
@@ -111,6 +111,15 @@ func UpdateLabels(ctx context.Context, client corev1.NodeInterface, nodeName str
 	return rigging.ConvertError(err)
 }
 
+// RemoveLabels removes labels with the specified keys from the node specified with nodeName
+func RemoveLabels(ctx context.Context, client corev1.NodeInterface, nodeName string, keys []string) error {
+	err := Retry(ctx, func() error {
+		return trace.Wrap(removeLabels(client, nodeName, keys))
+	})
+
+	return rigging.ConvertError(err)
+}
+
 // GetNode returns Kubernetes node corresponding to the provided server
 func GetNode(client *kubernetes.Clientset, server storage.Server) (*v1.Node, error) {
 	nodes, err := client.Core().Nodes().List(metav1.ListOptions{
@@ -174,6 +183,21 @@ func updateLabels(client corev1.NodeInterface, nodeName string, labels map[strin
 	return rigging.ConvertError(err)
 }
 
+// removeLabels removes labels with the specified keys from the node specified with nodeName
+func removeLabels(client corev1.NodeInterface, nodeName string, keys []string) error {
+	node, err := client.Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	for _, key := range keys {
+		delete(node.Labels, key)
+	}
+
+	_, err = client.Update(node)
+	return rigging.ConvertError(err)
+}
+
 // deleteTaints deletes the given taints from the node's list of taints
 func deleteTaints(taintsToDelete []v1.Taint, newTaints *[]v1.Taint) (deleted bool, err error) {
 	var errors []error
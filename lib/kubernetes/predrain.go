@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RunPreDrainHooks cordons the specified node and executes the pre-drain
+// command of every pod on the node that has opted into it with the pre-drain
+// annotations.
+//
+// The hook lets workloads that cannot tolerate restarts save or hand over their
+// application state before they are evicted. It is not a live migration: the pods
+// are still evicted and restarted on other nodes.
+//
+// The node is not drained if any of the commands fails
+func RunPreDrainHooks(ctx context.Context, client kubernetes.Interface, executor PodExecutor, nodeName string) error {
+	err := SetUnschedulable(ctx, client.CoreV1().Nodes(), nodeName, true)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	podList, err := client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": nodeName}).String(),
+	})
+	if err != nil {
+		return rigging.ConvertError(err)
+	}
+	return trace.Wrap(runPreDrainHooks(ctx, executor, podList.Items))
+}
+
+// runPreDrainHooks executes the pre-drain command of each of the specified pods
+// that has opted into it
+func runPreDrainHooks(ctx context.Context, executor PodExecutor, pods []v1.Pod) error {
+	for _, pod := range pods {
+		hook, err := getPreDrainHook(pod)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if hook == nil {
+			continue
+		}
+		logger := log.WithFields(podFields(pod))
+		logger.WithField("command", hook.command).Info("Run pre-drain hook.")
+		if err := hook.run(ctx, executor, pod); err != nil {
+			return trace.Wrap(err, "pre-drain hook of pod %v failed", formatPod(pod))
+		}
+		logger.Info("Pre-drain hook completed.")
+	}
+	return nil
+}
+
+// getPreDrainHook returns the pre-drain hook of the specified pod.
+// Returns nil if the pod has not opted into it or is not going to be evicted
+func getPreDrainHook(pod v1.Pod) (*preDrainHook, error) {
+	spec, ok := pod.Annotations[constants.PreDrainCommandAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	if pod.Status.Phase != v1.PodRunning || isDaemonSetPod(pod) {
+		return nil, nil
+	}
+	hook := preDrainHook{
+		container: pod.Annotations[constants.PreDrainContainerAnnotation],
+		timeout:   defaults.PreDrainHookTimeout,
+	}
+	if err := json.Unmarshal([]byte(spec), &hook.command); err != nil || len(hook.command) == 0 {
+		return nil, trace.BadParameter("pod %v: %v should be a non-empty JSON list of arguments, got %q",
+			formatPod(pod), constants.PreDrainCommandAnnotation, spec)
+	}
+	if hook.container == "" && len(pod.Spec.Containers) != 0 {
+		hook.container = pod.Spec.Containers[0].Name
+	}
+	if timeout, ok := pod.Annotations[constants.PreDrainTimeoutAnnotation]; ok {
+		var err error
+		hook.timeout, err = time.ParseDuration(timeout)
+		if err != nil || hook.timeout <= 0 {
+			return nil, trace.BadParameter("pod %v: %v should be a positive duration, got %q",
+				formatPod(pod), constants.PreDrainTimeoutAnnotation, timeout)
+		}
+	}
+	return &hook, nil
+}
+
+// run executes the pre-drain command in the specified pod.
+// The exec API cannot be canceled, so the command keeps running
+// after the timeout: the command should bound its own duration
+func (r preDrainHook) run(ctx context.Context, executor PodExecutor, pod v1.Pod) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- executor.Exec(pod.Namespace, pod.Name, r.container, r.command, nil, nil)
+	}()
+	select {
+	case err := <-errCh:
+		return trace.Wrap(err)
+	case <-ctx.Done():
+		return trace.LimitExceeded("pre-drain hook has not completed in %v", r.timeout)
+	}
+}
+
+// preDrainHook describes the pre-drain command of a pod
+type preDrainHook struct {
+	// container is the container to execute the command in
+	container string
+	// command is the pre-drain command
+	command []string
+	// timeout is the maximum duration of the command
+	timeout time.Duration
+}
+
+func isDaemonSetPod(pod v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == rigging.KindDaemonSet {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+
+	"github.com/gravitational/trace"
+	. "gopkg.in/check.v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type PreDrainSuite struct{}
+
+var _ = Suite(&PreDrainSuite{})
+
+func (s *PreDrainSuite) TestRunsAnnotatedHooks(c *C) {
+	pods := []v1.Pod{
+		newPreDrainPod("db-0", map[string]string{
+			constants.PreDrainCommandAnnotation:   `["save-state.sh","/state"]`,
+			constants.PreDrainContainerAnnotation: "db",
+		}),
+		newPreDrainPod("web-0", nil),
+		newPreDrainPod("cache-0", map[string]string{
+			constants.PreDrainCommandAnnotation: `["save-state"]`,
+		}),
+	}
+	executor := &testPodExecutor{}
+	err := runPreDrainHooks(context.TODO(), executor, pods)
+	c.Assert(err, IsNil)
+	c.Assert(executor.execs, DeepEquals, []testExec{
+		{pod: "db-0", container: "db", command: []string{"save-state.sh", "/state"}},
+		{pod: "cache-0", container: "main", command: []string{"save-state"}},
+	})
+}
+
+func (s *PreDrainSuite) TestSkipsDaemonSetAndPendingPods(c *C) {
+	annotations := map[string]string{constants.PreDrainCommandAnnotation: `["save-state"]`}
+	daemon := newPreDrainPod("agent", annotations)
+	daemon.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent"}}
+	pending := newPreDrainPod("pending", annotations)
+	pending.Status.Phase = v1.PodPending
+
+	executor := &testPodExecutor{}
+	err := runPreDrainHooks(context.TODO(), executor, []v1.Pod{daemon, pending})
+	c.Assert(err, IsNil)
+	c.Assert(executor.execs, HasLen, 0)
+}
+
+func (s *PreDrainSuite) TestFailsOnHookError(c *C) {
+	pods := []v1.Pod{newPreDrainPod("db-0", map[string]string{
+		constants.PreDrainCommandAnnotation: `["save-state"]`,
+	})}
+	executor := &testPodExecutor{err: trace.BadParameter("criu failed")}
+	err := runPreDrainHooks(context.TODO(), executor, pods)
+	c.Assert(err, ErrorMatches, ".*criu failed.*")
+}
+
+func (s *PreDrainSuite) TestTimesOut(c *C) {
+	pods := []v1.Pod{newPreDrainPod("db-0", map[string]string{
+		constants.PreDrainCommandAnnotation: `["save-state"]`,
+		constants.PreDrainTimeoutAnnotation: "10ms",
+	})}
+	executor := &testPodExecutor{delay: time.Second}
+	err := runPreDrainHooks(context.TODO(), executor, pods)
+	c.Assert(trace.IsLimitExceeded(err), Equals, true, Commentf("%v", err))
+}
+
+func (s *PreDrainSuite) TestValidatesAnnotations(c *C) {
+	for _, annotations := range []map[string]string{
+		{constants.PreDrainCommandAnnotation: "save-state"},
+		{constants.PreDrainCommandAnnotation: "[]"},
+		{constants.PreDrainCommandAnnotation: `["save-state"]`, constants.PreDrainTimeoutAnnotation: "soon"},
+	} {
+		_, err := getPreDrainHook(newPreDrainPod("db-0", annotations))
+		c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", annotations))
+	}
+}
+
+func newPreDrainPod(name string, annotations map[string]string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: name},
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "main"}, {Name: "db"}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+type testPodExecutor struct {
+	execs []testExec
+	err   error
+	delay time.Duration
+}
+
+type testExec struct {
+	pod       string
+	container string
+	command   []string
+}
+
+func (r *testPodExecutor) Exec(namespace, pod, container string, command []string, stdin io.Reader, stdout io.Writer) error {
+	time.Sleep(r.delay)
+	r.execs = append(r.execs, testExec{pod: pod, container: container, command: command})
+	return r.err
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// UpdateServiceAccountCA replaces the certificate authority bundle in all
+// service account token secrets of the cluster with caPEM, so the pods
+// trust the API server during and after the certificate authority rotation.
+//
+// The pods only pick up the new bundle when restarted
+func UpdateServiceAccountCA(client kubernetes.Interface, caPEM []byte) error {
+	secrets, err := client.CoreV1().Secrets(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: "type=" + string(v1.SecretTypeServiceAccountToken),
+	})
+	if err != nil {
+		return trace.Wrap(rigging.ConvertError(err))
+	}
+	for _, secret := range secretsWithoutCA(secrets.Items, caPEM) {
+		secret.Data[v1.ServiceAccountRootCAKey] = caPEM
+		_, err := client.CoreV1().Secrets(secret.Namespace).Update(&secret)
+		if err != nil {
+			return trace.Wrap(rigging.ConvertError(err))
+		}
+		log.WithField("namespace", secret.Namespace).Infof("Updated CA bundle of secret %v.", secret.Name)
+	}
+	return nil
+}
+
+// secretsWithoutCA returns the service account token secrets
+// that do not have the specified certificate authority bundle
+func secretsWithoutCA(secrets []v1.Secret, caPEM []byte) (result []v1.Secret) {
+	for _, secret := range secrets {
+		if secret.Type != v1.SecretTypeServiceAccountToken {
+			continue
+		}
+		if bytes.Equal(secret.Data[v1.ServiceAccountRootCAKey], caPEM) {
+			continue
+		}
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		result = append(result, secret)
+	}
+	return result
+}
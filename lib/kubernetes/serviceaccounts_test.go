@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	. "gopkg.in/check.v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ServiceAccountSuite struct{}
+
+var _ = Suite(&ServiceAccountSuite{})
+
+func (s *ServiceAccountSuite) TestSelectsSecretsWithoutCA(c *C) {
+	bundle := []byte("old-ca\nnew-ca\n")
+	secrets := []v1.Secret{
+		newTokenSecret("default-token", map[string][]byte{v1.ServiceAccountRootCAKey: []byte("old-ca\n")}),
+		newTokenSecret("updated-token", map[string][]byte{v1.ServiceAccountRootCAKey: bundle}),
+		newTokenSecret("empty-token", nil),
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "opaque", Namespace: "default"},
+			Type:       v1.SecretTypeOpaque,
+		},
+	}
+	var names []string
+	for _, secret := range secretsWithoutCA(secrets, bundle) {
+		c.Assert(secret.Data, NotNil)
+		names = append(names, secret.Name)
+	}
+	c.Assert(names, DeepEquals, []string{"default-token", "empty-token"})
+}
+
+func newTokenSecret(name string, data map[string][]byte) v1.Secret {
+	return v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Type:       v1.SecretTypeServiceAccountToken,
+		Data:       data,
+	}
+}
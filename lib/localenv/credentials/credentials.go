@@ -190,6 +190,37 @@ func (s *credentialsService) For(clusterURL string) (*Credentials, error) {
 	return nil, newCredentialsNotFoundError("no credentials for %v", clusterURL)
 }
 
+// FromTeleportProfile returns the credentials of the current profile
+// in the Teleport key store in the specified directory (defaults to ~/.tsh),
+// i.e. the credentials of the user that has logged into a cluster with 'tsh login'.
+//
+// Returns the credentials not found error if the user has not logged in.
+func FromTeleportProfile(keyStoreDir string) (*Credentials, error) {
+	s, err := New(Config{TeleportKeyStoreDir: keyStoreDir})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	profile, err := s.currentProfile()
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, newCredentialsNotFoundError("not logged into any cluster")
+		}
+		return nil, trace.Wrap(err)
+	}
+	key, err := s.keyForProfile(*profile)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, newCredentialsNotFoundError("no key for %v in the Teleport key store", profile.WebProxyAddr)
+		}
+		return nil, trace.Wrap(err)
+	}
+	tlsConfig, err := makeTLSConfig(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return credentialsFromProfile(*profile, tlsConfig), nil
+}
+
 // credentialsNotFoundError is returned if requested credentials weren't found.
 type credentialsNotFoundError struct {
 	message string
@@ -21,6 +21,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	appbase "github.com/gravitational/gravity/lib/app"
@@ -37,6 +39,7 @@ import (
 	"github.com/gravitational/gravity/lib/ops/opsclient"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/pack/localpack"
+	"github.com/gravitational/gravity/lib/pack/ocipack"
 	"github.com/gravitational/gravity/lib/pack/webpack"
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/storage"
@@ -69,7 +72,7 @@ type LocalEnvironmentArgs struct {
 	// Defaults to EtcdRetryInterval if unspecified
 	EtcdRetryTimeout time.Duration
 	// BoltOpenTimeout specifies the timeout on opening the local state database.
-	// Negative value means no timeout.
+	// Use keyval.NoTimeout to fail immediately if the database is locked.
 	// Defaults to defaults.DBOpenTimeout if unspecified
 	BoltOpenTimeout time.Duration
 	// Reporter controls progress output
@@ -79,8 +82,17 @@ type LocalEnvironmentArgs struct {
 	// ReadonlyBackend specifies if the backend should be opened
 	// read-only.
 	ReadonlyBackend bool
+	// BackendSnapshot specifies if the backend should read from a read-only
+	// snapshot of the database instead of blocking on the database lock
+	// held by another process.
+	// Use it for commands that only display the local state
+	BackendSnapshot bool
 	// Credentials is the predefined static credentials entry
 	Credentials *credentials.Credentials
+	// OperatorRetry configures retries of the operator requests failing
+	// with transient errors. Negative retry timeout disables retries.
+	// Defaults to retrying with defaults.OperatorRetryTimeout if unspecified
+	OperatorRetry opsclient.RetryConfig
 	// Close allows to perform extra cleanup actions
 	Close func() error
 }
@@ -119,6 +131,16 @@ type LocalEnvironment struct {
 	Apps appbase.Applications
 	// Credentials provides access to user credentials
 	Credentials credentials.Service
+
+	// breakers are the circuit breakers shared by the operator clients
+	breakers *circuitBreakers
+}
+
+// circuitBreakers maps service addresses to the circuit breakers
+// shared by the operator clients of the same service
+type circuitBreakers struct {
+	sync.Mutex
+	breakers map[string]*opsclient.CircuitBreaker
 }
 
 // New is a shortcut that creates a local environment from provided state directory
@@ -142,7 +164,12 @@ func NewLocalEnvironment(args LocalEnvironmentArgs) (*LocalEnvironment, error) {
 		return nil, trace.Wrap(err)
 	}
 
-	env := &LocalEnvironment{LocalEnvironmentArgs: args}
+	env := &LocalEnvironment{
+		LocalEnvironmentArgs: args,
+		breakers: &circuitBreakers{
+			breakers: make(map[string]*opsclient.CircuitBreaker),
+		},
+	}
 	if err = env.init(); err != nil {
 		env.Close()
 		return nil, trace.Wrap(err)
@@ -160,6 +187,7 @@ func (env *LocalEnvironment) init() error {
 		Path:     filepath.Join(env.StateDir, defaults.GravityDBFile),
 		Multi:    true,
 		Readonly: env.ReadonlyBackend,
+		Snapshot: env.BackendSnapshot,
 		Timeout:  env.BoltOpenTimeout,
 	})
 	if err != nil {
@@ -262,6 +290,9 @@ func (env *LocalEnvironment) PackageService(opsCenterURL string, options ...http
 	if opsCenterURL == "" { // assume local OpsCenter
 		return env.Packages, nil
 	}
+	if ocipack.IsURL(opsCenterURL) {
+		return env.ociPackageService(opsCenterURL)
+	}
 	if opsCenterURL == defaults.GravityServiceURL {
 		options = append(options, httplib.WithLocalResolver(env.DNS.Addr()))
 	}
@@ -282,6 +313,26 @@ func (env *LocalEnvironment) PackageService(opsCenterURL string, options ...http
 	return client, nil
 }
 
+// ociPackageService returns the package service for the OCI registry
+// specified with registryURL in oci://<registry>[/<namespace>] format.
+// The registry credentials are read from the Docker client configuration
+func (env *LocalEnvironment) ociPackageService(registryURL string) (pack.PackageService, error) {
+	registry, namespace, err := ocipack.ParseURL(registryURL)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	credentials, err := ocipack.CredentialsFromDockerConfig(ocipack.DockerConfigPath(), registry)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return ocipack.New(ocipack.Config{
+		Registry:    registry,
+		Namespace:   namespace,
+		Credentials: *credentials,
+		Insecure:    env.Insecure,
+	})
+}
+
 // CurrentUser returns name of the currently logged in user
 func (env *LocalEnvironment) CurrentUser() string {
 	credentials, err := env.Credentials.Current()
@@ -308,9 +359,14 @@ func (env *LocalEnvironment) OperatorService(opsCenterURL string, options ...htt
 	if credentials.TLS != nil {
 		options = append(options, httplib.WithTLSClientConfig(credentials.TLS))
 	}
+	retry, err := env.operatorRetry(credentials.URL)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
 	params := []opsclient.ClientParam{
 		opsclient.HTTPClient(env.HTTPClient(options...)),
 		opsclient.WithLocalDialer(httplib.LocalResolverDialer(env.DNS.Addr())),
+		opsclient.WithRetry(*retry),
 	}
 	client, err := NewOpsClient(credentials.Entry, credentials.URL, params...)
 	if err != nil {
@@ -326,6 +382,58 @@ func (env *LocalEnvironment) SiteOperator() (*opsclient.Client, error) {
 		httplib.WithInsecure())
 }
 
+// SiteOperatorAt returns Operator for the local gravity site that talks to
+// the cluster controller through the specified master node instead of the
+// in-cluster service address. It allows to keep communicating with the
+// cluster while the local node is being removed from it
+func (env *LocalEnvironment) SiteOperatorAt(masterIP string) (*opsclient.Client, error) {
+	credentials, err := env.Credentials.For(defaults.GravityServiceURL)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var options []httplib.ClientOption
+	if credentials.TLS != nil {
+		options = append(options, httplib.WithTLSClientConfig(credentials.TLS))
+	}
+	// the cluster certificate is issued for the service address
+	options = append(options, httplib.WithInsecure())
+	clusterURL := utils.EnsurePortURL(masterIP, strconv.Itoa(defaults.GravitySiteNodePort))
+	retry, err := env.operatorRetry(clusterURL)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client, err := NewOpsClient(credentials.Entry, clusterURL,
+		opsclient.HTTPClient(env.HTTPClient(options...)),
+		opsclient.WithRetry(*retry))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client, nil
+}
+
+// operatorRetry returns the retry configuration for the operator client
+// of the service at the specified address
+func (env *LocalEnvironment) operatorRetry(addr string) (*opsclient.RetryConfig, error) {
+	config := env.OperatorRetry
+	if config.Timeout < 0 {
+		return &config, nil
+	}
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if env.breakers == nil {
+		return &config, nil
+	}
+	env.breakers.Lock()
+	defer env.breakers.Unlock()
+	if breaker, ok := env.breakers.breakers[addr]; ok {
+		config.Breaker = breaker
+	} else {
+		env.breakers.breakers[addr] = config.Breaker
+	}
+	return &config, nil
+}
+
 // LocalCluster queries a local Gravity cluster.
 func (env *LocalEnvironment) LocalCluster() (*ops.Site, error) {
 	operator, err := env.SiteOperator()
@@ -357,6 +465,14 @@ func (env *LocalEnvironment) AppService(opsCenterURL string, config AppConfig, o
 	if opsCenterURL == "" {
 		return env.AppServiceLocal(config)
 	}
+	if ocipack.IsURL(opsCenterURL) {
+		packages, err := env.ociPackageService(opsCenterURL)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		config.Packages = packages
+		return env.AppServiceLocal(config)
+	}
 	credentials, err := env.Credentials.For(opsCenterURL)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -2,6 +2,7 @@ package localenv
 
 import (
 	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/storage/keyval"
 
 	"github.com/gravitational/trace"
 )
@@ -9,7 +10,17 @@ import (
 // NewLocalWizardEnvironment creates a new local environment to access
 // wizard-specific state
 func NewLocalWizardEnvironment() (*LocalEnvironment, error) {
-	const failImmediatelyIfLocked = -1
+	return newLocalWizardEnvironment(false)
+}
+
+// NewLocalWizardSnapshotEnvironment creates a new local environment to read
+// wizard-specific state without blocking on the database lock
+// held by the wizard process
+func NewLocalWizardSnapshotEnvironment() (*LocalEnvironment, error) {
+	return newLocalWizardEnvironment(true)
+}
+
+func newLocalWizardEnvironment(snapshot bool) (*LocalEnvironment, error) {
 	stateDir, err := state.GravityInstallDir()
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -17,7 +28,8 @@ func NewLocalWizardEnvironment() (*LocalEnvironment, error) {
 	args := LocalEnvironmentArgs{
 		StateDir:         stateDir,
 		LocalKeyStoreDir: stateDir,
-		BoltOpenTimeout:  failImmediatelyIfLocked,
+		BoltOpenTimeout:  keyval.NoTimeout,
+		BackendSnapshot:  snapshot,
 	}
 	return NewLocalEnvironment(args)
 }
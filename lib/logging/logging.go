@@ -0,0 +1,228 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging implements leveled logging with per-subsystem levels
+// on top of the standard logrus logger.
+//
+// The subsystem of a log entry is determined by its trace.Component field:
+// the entry belongs to the subsystem with the longest name that is a prefix of
+// the component, e.g. entries of "fsm:remote" component belong to "fsm" subsystem.
+package logging
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSubsystem is the name of the level applied to subsystems
+// without an explicitly configured level
+const DefaultSubsystem = "default"
+
+// Config defines logging configuration
+type Config struct {
+	// Level is the default log level
+	Level string `yaml:"level,omitempty"`
+	// Subsystems maps subsystems such as fsm, rpc, pack or ops to log levels
+	Subsystems map[string]string `yaml:"subsystems,omitempty"`
+	// JSON enables JSON-formatted log output
+	JSON bool `yaml:"json,omitempty"`
+}
+
+// Levels returns the log levels defined by this configuration
+func (r Config) Levels() (Levels, error) {
+	levels := Levels{}
+	if r.Level != "" {
+		levels[DefaultSubsystem] = r.Level
+	}
+	for subsystem, level := range r.Subsystems {
+		levels[subsystem] = level
+	}
+	if err := levels.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return levels, nil
+}
+
+// Levels maps subsystem names to log levels
+type Levels map[string]string
+
+// ParseLevels parses log levels from the list of subsystem=level items.
+// Items without a subsystem set the default level
+func ParseLevels(items []string) (Levels, error) {
+	levels := Levels{}
+	for _, item := range items {
+		subsystem, level := DefaultSubsystem, item
+		if parts := strings.SplitN(item, "=", 2); len(parts) == 2 {
+			subsystem, level = parts[0], parts[1]
+		}
+		levels[strings.TrimSpace(subsystem)] = strings.TrimSpace(level)
+	}
+	if err := levels.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return levels, nil
+}
+
+// Check validates these log levels
+func (r Levels) Check() error {
+	for subsystem, level := range r {
+		if subsystem == "" {
+			return trace.BadParameter("subsystem name is required for level %q", level)
+		}
+		if _, err := logrus.ParseLevel(level); err != nil {
+			return trace.BadParameter("invalid log level %q for %v", level, subsystem)
+		}
+	}
+	return nil
+}
+
+// Merge returns a copy of these levels updated with the specified levels
+func (r Levels) Merge(other Levels) Levels {
+	result := make(Levels, len(r)+len(other))
+	for subsystem, level := range r {
+		result[subsystem] = level
+	}
+	for subsystem, level := range other {
+		result[subsystem] = level
+	}
+	return result
+}
+
+// String returns levels formatted as a comma-separated list of subsystem=level items
+func (r Levels) String() string {
+	var items []string
+	for subsystem, level := range r {
+		items = append(items, subsystem+"="+level)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// Init configures the standard logger with the specified configuration.
+// defaultLevel is used unless the configuration overrides the default level
+func Init(config Config, defaultLevel logrus.Level) error {
+	levels, err := config.Levels()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var formatter logrus.Formatter = &trace.TextFormatter{}
+	if config.JSON {
+		formatter = &logrus.JSONFormatter{}
+	}
+	filter := &Filter{
+		Formatter:    formatter,
+		defaultLevel: defaultLevel,
+	}
+	filter.SetLevels(levels)
+	logrus.SetFormatter(filter)
+	logrus.SetLevel(filter.maxLevel())
+	mu.Lock()
+	standardFilter = filter
+	mu.Unlock()
+	return nil
+}
+
+// SetLevels replaces the levels of the standard logger configured with Init
+func SetLevels(levels Levels) error {
+	if err := levels.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if standardFilter == nil {
+		return trace.NotFound("logging has not been initialized")
+	}
+	standardFilter.SetLevels(levels)
+	logrus.SetLevel(standardFilter.maxLevel())
+	return nil
+}
+
+// Filter is a log formatter that discards entries less severe
+// than the level of their subsystem
+type Filter struct {
+	// Formatter formats entries that pass the filter
+	logrus.Formatter
+	// defaultLevel is used for subsystems without explicit level
+	// unless overridden by the levels
+	defaultLevel logrus.Level
+	mu           sync.RWMutex
+	levels       map[string]logrus.Level
+}
+
+// SetLevels replaces the filter levels. The levels are assumed to be valid
+func (r *Filter) SetLevels(levels Levels) {
+	parsed := make(map[string]logrus.Level, len(levels))
+	for subsystem, level := range levels {
+		parsed[subsystem], _ = logrus.ParseLevel(level)
+	}
+	r.mu.Lock()
+	r.levels = parsed
+	r.mu.Unlock()
+}
+
+// Format formats the specified entry or discards it if filtered out
+func (r *Filter) Format(entry *logrus.Entry) ([]byte, error) {
+	component, _ := entry.Data[trace.Component].(string)
+	if entry.Level > r.level(component) {
+		return nil, nil
+	}
+	return r.Formatter.Format(entry)
+}
+
+// level returns the log level for the specified component
+func (r *Filter) level(component string) logrus.Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	level, ok := r.levels[DefaultSubsystem]
+	if !ok {
+		level = r.defaultLevel
+	}
+	var match string
+	for subsystem, subsystemLevel := range r.levels {
+		if subsystem == DefaultSubsystem || len(subsystem) <= len(match) {
+			continue
+		}
+		if strings.HasPrefix(component, subsystem) {
+			match, level = subsystem, subsystemLevel
+		}
+	}
+	return level
+}
+
+// maxLevel returns the most verbose of the filter levels
+func (r *Filter) maxLevel() logrus.Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	max, ok := r.levels[DefaultSubsystem]
+	if !ok {
+		max = r.defaultLevel
+	}
+	for _, level := range r.levels {
+		if level > max {
+			max = level
+		}
+	}
+	return max
+}
+
+var (
+	mu             sync.Mutex
+	standardFilter *Filter
+)
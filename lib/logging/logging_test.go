@@ -0,0 +1,93 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"testing"
+
+	"github.com/gravitational/gravity/lib/compare"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	check "gopkg.in/check.v1"
+)
+
+func TestLogging(t *testing.T) { check.TestingT(t) }
+
+type LoggingSuite struct{}
+
+var _ = check.Suite(&LoggingSuite{})
+
+func (s *LoggingSuite) TestParsesLevels(c *check.C) {
+	levels, err := ParseLevels([]string{"info", "fsm=debug", "rpc = warn"})
+	c.Assert(err, check.IsNil)
+	c.Assert(levels, compare.DeepEquals, Levels{
+		DefaultSubsystem: "info",
+		"fsm":            "debug",
+		"rpc":            "warn",
+	})
+	c.Assert(levels.String(), check.Equals, "default=info,fsm=debug,rpc=warn")
+
+	_, err = ParseLevels([]string{"fsm=verbose"})
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+	_, err = ParseLevels([]string{"=debug"})
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
+
+func (s *LoggingSuite) TestConfigLevels(c *check.C) {
+	levels, err := Config{
+		Level:      "warn",
+		Subsystems: map[string]string{"ops": "debug"},
+	}.Levels()
+	c.Assert(err, check.IsNil)
+	c.Assert(levels.Merge(Levels{"ops": "info", "pack": "error"}), compare.DeepEquals, Levels{
+		DefaultSubsystem: "warn",
+		"ops":            "info",
+		"pack":           "error",
+	})
+}
+
+func (s *LoggingSuite) TestFiltersBySubsystem(c *check.C) {
+	filter := &Filter{
+		Formatter:    &logrus.TextFormatter{DisableTimestamp: true},
+		defaultLevel: logrus.InfoLevel,
+	}
+	filter.SetLevels(Levels{"fsm": "debug", "fsm:remote": "error", "rpc": "warn"})
+	c.Assert(filter.maxLevel(), check.Equals, logrus.DebugLevel)
+
+	var testCases = []struct {
+		component string
+		level     logrus.Level
+		filtered  bool
+	}{
+		{component: "fsm", level: logrus.DebugLevel, filtered: false},
+		{component: "fsm:join", level: logrus.DebugLevel, filtered: false},
+		{component: "fsm:remote", level: logrus.WarnLevel, filtered: true},
+		{component: "rpcserver", level: logrus.InfoLevel, filtered: true},
+		{component: "ops", level: logrus.InfoLevel, filtered: false},
+		{component: "ops", level: logrus.DebugLevel, filtered: true},
+		{component: "", level: logrus.DebugLevel, filtered: true},
+	}
+	for _, tc := range testCases {
+		entry := logrus.WithField(trace.Component, tc.component)
+		entry.Level = tc.level
+		entry.Message = "test"
+		out, err := filter.Format(entry)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(out) == 0, check.Equals, tc.filtered, check.Commentf("%v at %v", tc.component, tc.level))
+	}
+}
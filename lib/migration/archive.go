@@ -0,0 +1,411 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/gravitational/trace"
+)
+
+// Manifest describes the contents of a migration archive.
+// It is written as the last entry of the archive
+type Manifest struct {
+	// Version is the archive format version
+	Version string `json:"version"`
+	// Cluster is the name of the source cluster
+	Cluster string `json:"cluster"`
+	// Created is the time the archive was created
+	Created time.Time `json:"created"`
+	// Namespaces lists the migrated namespaces
+	Namespaces []string `json:"namespaces"`
+	// Resources lists the migrated resources
+	Resources []Resource `json:"resources,omitempty"`
+	// Volumes lists the migrated persistent volume claims
+	Volumes []Volume `json:"volumes,omitempty"`
+}
+
+// Resource is a Kubernetes resource in a migration archive
+type Resource struct {
+	// Kind is the resource kind
+	Kind string `json:"kind"`
+	// Namespace is the resource namespace
+	Namespace string `json:"namespace"`
+	// Name is the resource name
+	Name string `json:"name"`
+	// Checksum is the SHA256 checksum of the resource
+	Checksum string `json:"checksum"`
+	// Object is the JSON-encoded resource
+	Object json.RawMessage `json:"-"`
+}
+
+// String returns a textual representation of this resource
+func (r Resource) String() string {
+	return fmt.Sprintf("%v %v/%v", r.Kind, r.Namespace, r.Name)
+}
+
+// Volume describes a persistent volume claim in a migration archive
+type Volume struct {
+	// Namespace is the namespace of the claim
+	Namespace string `json:"namespace"`
+	// Claim is the name of the claim
+	Claim string `json:"claim"`
+	// StorageClass is the storage class of the claim
+	StorageClass string `json:"storage_class,omitempty"`
+	// AccessModes lists the access modes of the claim
+	AccessModes []string `json:"access_modes,omitempty"`
+	// Capacity is the requested capacity of the claim
+	Capacity string `json:"capacity"`
+	// Size is the size of the volume data stream in bytes
+	Size int64 `json:"size"`
+	// Checksum is the SHA256 checksum of the volume data stream
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// String returns a textual representation of this volume
+func (r Volume) String() string {
+	return fmt.Sprintf("%v/%v", r.Namespace, r.Claim)
+}
+
+// NewWriter returns a new archive writer that writes to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		tw:        tar.NewWriter(w),
+		chunkSize: defaults.MigrationChunkSize,
+	}
+}
+
+// Writer writes a migration archive.
+//
+// The archive is a tar stream. Volume data is split into chunks of limited
+// size so it can be streamed without knowing the size of the volume upfront.
+// Every entry carries its SHA256 checksum verified by the reader
+type Writer struct {
+	tw        *tar.Writer
+	chunkSize int
+	resources []Resource
+	volumes   []Volume
+}
+
+// WriteResource adds the specified resource to the archive
+func (w *Writer) WriteResource(resource Resource) error {
+	resource.Checksum = checksum(resource.Object)
+	err := w.writeEntry(resourcePath(resource), resource.Object, resource.Checksum)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	w.resources = append(w.resources, resource)
+	return nil
+}
+
+// WriteVolume adds the specified volume with the data read from the provided
+// reader to the archive.
+// Returns the volume with the size and checksum of the data
+func (w *Writer) WriteVolume(volume Volume, data io.Reader) (*Volume, error) {
+	claim, err := json.Marshal(volume)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := w.writeEntry(volumeClaimPath(volume), claim, checksum(claim)); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	hash := sha256.New()
+	buf := make([]byte, w.chunkSize)
+	for chunk := 0; ; chunk++ {
+		n, err := io.ReadFull(data, buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			volume.Size += int64(n)
+			err := w.writeEntry(volumeChunkPath(volume, chunk), buf[:n], checksum(buf[:n]))
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, trace.Wrap(err, "failed to read data of %v", volume)
+		}
+	}
+	volume.Checksum = hex.EncodeToString(hash.Sum(nil))
+	w.volumes = append(w.volumes, volume)
+	return &volume, nil
+}
+
+// Close writes the manifest with the resources and volumes added
+// to the archive and flushes the archive
+func (w *Writer) Close(manifest Manifest) error {
+	manifest.Version = archiveVersion
+	manifest.Resources = w.resources
+	manifest.Volumes = w.volumes
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := w.writeEntry(manifestPath, data, checksum(data)); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(w.tw.Close())
+}
+
+func (w *Writer) writeEntry(name string, data []byte, sum string) error {
+	err := w.tw.WriteHeader(&tar.Header{
+		Name:       name,
+		Mode:       defaults.SharedReadMask,
+		Size:       int64(len(data)),
+		Typeflag:   tar.TypeReg,
+		Format:     tar.FormatPAX,
+		PAXRecords: map[string]string{checksumRecord: sum},
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = w.tw.Write(data)
+	return trace.Wrap(err)
+}
+
+// NewReader returns a new archive reader that reads from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{tr: tar.NewReader(r)}
+}
+
+// Reader reads a migration archive verifying the checksums of its entries
+type Reader struct {
+	tr *tar.Reader
+	// pending is the header read past the end of the current volume
+	pending *tar.Header
+	// current is the data of the volume being read
+	current *VolumeReader
+}
+
+// Entry is an entry of a migration archive.
+// Exactly one of the fields is set
+type Entry struct {
+	// Resource is a Kubernetes resource
+	Resource *Resource
+	// Volume is the data of a persistent volume claim.
+	// The data is verified while it is read
+	Volume *VolumeReader
+	// Manifest is the archive manifest, the last entry of the archive
+	Manifest *Manifest
+}
+
+// Next returns the next entry of the archive.
+// The data of the previous volume is skipped if it has not been read completely
+func (r *Reader) Next() (*Entry, error) {
+	if r.current != nil {
+		if _, err := io.Copy(ioutil.Discard, r.current); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		r.current = nil
+	}
+	header, err := r.next()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	data, err := r.readEntry(header)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch {
+	case header.Name == manifestPath:
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if manifest.Version != archiveVersion {
+			return nil, trace.BadParameter("unsupported migration archive version %q", manifest.Version)
+		}
+		return &Entry{Manifest: &manifest}, nil
+	case strings.HasPrefix(header.Name, resourcesDir+"/"):
+		resource, err := parseResourcePath(header.Name)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resource.Object = data
+		resource.Checksum = header.PAXRecords[checksumRecord]
+		return &Entry{Resource: resource}, nil
+	case strings.HasPrefix(header.Name, volumesDir+"/") && path.Base(header.Name) == claimFile:
+		var volume Volume
+		if err := json.Unmarshal(data, &volume); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		r.current = &VolumeReader{
+			Volume: volume,
+			reader: r,
+			prefix: path.Join(path.Dir(header.Name), dataDir) + "/",
+			hash:   sha256.New(),
+		}
+		return &Entry{Volume: r.current}, nil
+	}
+	return nil, trace.BadParameter("unexpected entry %v in migration archive", header.Name)
+}
+
+func (r *Reader) next() (*tar.Header, error) {
+	if r.pending != nil {
+		header := r.pending
+		r.pending = nil
+		return header, nil
+	}
+	header, err := r.tr.Next()
+	if err == io.EOF {
+		return nil, trace.BadParameter("migration archive is missing the manifest")
+	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return header, nil
+}
+
+func (r *Reader) readEntry(header *tar.Header) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r.tr); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := verifyChecksum(header, buf.Bytes()); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// VolumeReader reads the data of a persistent volume claim from
+// a migration archive verifying the checksum of every chunk
+type VolumeReader struct {
+	// Volume describes the persistent volume claim
+	Volume Volume
+	reader *Reader
+	prefix string
+	hash   hash.Hash
+	size   int64
+	chunk  *bytes.Reader
+	done   bool
+}
+
+// Read reads the volume data
+func (r *VolumeReader) Read(p []byte) (int, error) {
+	for {
+		if r.chunk != nil && r.chunk.Len() != 0 {
+			n, _ := r.chunk.Read(p)
+			r.hash.Write(p[:n])
+			r.size += int64(n)
+			return n, nil
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		header, err := r.reader.tr.Next()
+		if err == io.EOF {
+			r.done = true
+			continue
+		}
+		if err != nil {
+			return 0, trace.Wrap(err)
+		}
+		if !strings.HasPrefix(header.Name, r.prefix) {
+			r.reader.pending = header
+			r.done = true
+			continue
+		}
+		data, err := r.reader.readEntry(header)
+		if err != nil {
+			return 0, trace.Wrap(err)
+		}
+		r.chunk = bytes.NewReader(data)
+	}
+}
+
+// Size returns the number of bytes read so far
+func (r *VolumeReader) Size() int64 {
+	return r.size
+}
+
+// Checksum returns the SHA256 checksum of the data read so far
+func (r *VolumeReader) Checksum() string {
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+func verifyChecksum(header *tar.Header, data []byte) error {
+	expected := header.PAXRecords[checksumRecord]
+	if expected == "" {
+		return trace.BadParameter("entry %v in migration archive has no checksum", header.Name)
+	}
+	if actual := checksum(data); actual != expected {
+		return trace.BadParameter("checksum mismatch for %v in migration archive: expected %v, got %v",
+			header.Name, expected, actual)
+	}
+	return nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func resourcePath(resource Resource) string {
+	return path.Join(resourcesDir, resource.Namespace, resource.Kind, resource.Name+".json")
+}
+
+func parseResourcePath(name string) (*Resource, error) {
+	parts := strings.Split(strings.TrimPrefix(name, resourcesDir+"/"), "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".json") {
+		return nil, trace.BadParameter("invalid resource entry %v in migration archive", name)
+	}
+	return &Resource{
+		Namespace: parts[0],
+		Kind:      parts[1],
+		Name:      strings.TrimSuffix(parts[2], ".json"),
+	}, nil
+}
+
+func volumeClaimPath(volume Volume) string {
+	return path.Join(volumesDir, volume.Namespace, volume.Claim, claimFile)
+}
+
+func volumeChunkPath(volume Volume, chunk int) string {
+	return path.Join(volumesDir, volume.Namespace, volume.Claim, dataDir, fmt.Sprintf("%08d", chunk))
+}
+
+const (
+	// archiveVersion is the version of the migration archive format
+	archiveVersion = "v1"
+	// manifestPath is the path of the manifest in the archive
+	manifestPath = "manifest.json"
+	// resourcesDir is the archive directory with Kubernetes resources
+	resourcesDir = "resources"
+	// volumesDir is the archive directory with volume data
+	volumesDir = "volumes"
+	// claimFile is the name of the file with the volume claim description
+	claimFile = "claim.json"
+	// dataDir is the volume directory with the data chunks
+	dataDir = "data"
+	// checksumRecord is the PAX record with the SHA256 checksum of the entry
+	checksumRecord = "GRAVITY.sha256"
+)
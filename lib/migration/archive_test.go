@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func TestMigration(t *testing.T) { check.TestingT(t) }
+
+type ArchiveSuite struct{}
+
+var _ = check.Suite(&ArchiveSuite{})
+
+func (s *ArchiveSuite) TestRoundtrip(c *check.C) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	// use small chunks to split the volume data into several entries
+	w.chunkSize = 4
+
+	volume, err := w.WriteVolume(Volume{
+		Namespace:    "default",
+		Claim:        "data",
+		StorageClass: "openebs-cstor",
+		AccessModes:  []string{"ReadWriteOnce"},
+		Capacity:     "1Gi",
+	}, strings.NewReader("volume contents"))
+	c.Assert(err, check.IsNil)
+	c.Assert(volume.Size, check.Equals, int64(len("volume contents")))
+	c.Assert(volume.Checksum, check.Equals, checksum([]byte("volume contents")))
+
+	_, err = w.WriteVolume(Volume{Namespace: "default", Claim: "empty", Capacity: "1Gi"}, strings.NewReader(""))
+	c.Assert(err, check.IsNil)
+
+	err = w.WriteResource(Resource{
+		Kind:      "ConfigMap",
+		Namespace: "default",
+		Name:      "config",
+		Object:    []byte(`{"kind":"ConfigMap"}`),
+	})
+	c.Assert(err, check.IsNil)
+
+	created := time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)
+	err = w.Close(Manifest{Cluster: "example.com", Created: created, Namespaces: []string{"default"}})
+	c.Assert(err, check.IsNil)
+
+	r := NewReader(&buf)
+	entry, err := r.Next()
+	c.Assert(err, check.IsNil)
+	c.Assert(entry.Volume, check.NotNil)
+	c.Assert(entry.Volume.Volume.String(), check.Equals, "default/data")
+	c.Assert(entry.Volume.Volume.StorageClass, check.Equals, "openebs-cstor")
+	data, err := ioutil.ReadAll(entry.Volume)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "volume contents")
+	c.Assert(entry.Volume.Checksum(), check.Equals, volume.Checksum)
+
+	entry, err = r.Next()
+	c.Assert(err, check.IsNil)
+	c.Assert(entry.Volume, check.NotNil)
+	c.Assert(entry.Volume.Volume.String(), check.Equals, "default/empty")
+	// leave the volume data unread, it is skipped by the next call
+
+	entry, err = r.Next()
+	c.Assert(err, check.IsNil)
+	c.Assert(entry.Resource, check.NotNil)
+	c.Assert(entry.Resource.String(), check.Equals, "ConfigMap default/config")
+	c.Assert(string(entry.Resource.Object), check.Equals, `{"kind":"ConfigMap"}`)
+
+	entry, err = r.Next()
+	c.Assert(err, check.IsNil)
+	c.Assert(entry.Manifest, check.NotNil)
+	c.Assert(entry.Manifest.Cluster, check.Equals, "example.com")
+	c.Assert(entry.Manifest.Created, check.DeepEquals, created)
+	c.Assert(entry.Manifest.Resources, check.HasLen, 1)
+	c.Assert(entry.Manifest.Volumes, check.HasLen, 2)
+	c.Assert(entry.Manifest.Volumes[0], check.DeepEquals, *volume)
+}
+
+func (s *ArchiveSuite) TestDetectsCorruptedData(c *check.C) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_, err := w.WriteVolume(Volume{Namespace: "default", Claim: "data", Capacity: "1Gi"},
+		strings.NewReader("volume contents"))
+	c.Assert(err, check.IsNil)
+	c.Assert(w.Close(Manifest{Cluster: "example.com"}), check.IsNil)
+
+	corrupted := bytes.Replace(buf.Bytes(), []byte("volume contents"), []byte("volume c0ntents"), 1)
+	r := NewReader(bytes.NewReader(corrupted))
+	entry, err := r.Next()
+	c.Assert(err, check.IsNil)
+	_, err = ioutil.ReadAll(entry.Volume)
+	c.Assert(err, check.NotNil)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+	c.Assert(err.Error(), check.Matches, "checksum mismatch.*")
+}
+
+func (s *ArchiveSuite) TestRequiresManifest(c *check.C) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.WriteResource(Resource{Kind: "Secret", Namespace: "default", Name: "secret", Object: []byte("{}")})
+	c.Assert(err, check.IsNil)
+	c.Assert(w.tw.Close(), check.IsNil)
+
+	r := NewReader(&buf)
+	_, err = r.Next()
+	c.Assert(err, check.IsNil)
+	_, err = r.Next()
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
@@ -0,0 +1,641 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migration implements migration of application data between clusters.
+//
+// The data of the selected persistent volume claims along with the application
+// resources from the selected namespaces is exported into an archive that is
+// streamed to the target cluster and imported there. Every entry of the archive
+// is checksummed so the data corrupted in transit is detected on import.
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libkubernetes "github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Config defines the migration configuration
+type Config struct {
+	// Client is the Kubernetes client
+	Client kubernetes.Interface
+	// Dynamic is the dynamic Kubernetes client used to access application resources
+	Dynamic dynamic.Interface
+	// Executor executes commands in the helper pods that copy volume data
+	Executor libkubernetes.PodExecutor
+	// Clock is used to track time
+	Clock clockwork.Clock
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+}
+
+// CheckAndSetDefaults validates the configuration and sets default values
+func (r *Config) CheckAndSetDefaults() error {
+	if r.Client == nil {
+		return trace.BadParameter("missing Client")
+	}
+	if r.Dynamic == nil {
+		return trace.BadParameter("missing Dynamic")
+	}
+	if r.Executor == nil {
+		return trace.BadParameter("missing Executor")
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = log
+	}
+	return nil
+}
+
+// New returns a new migrator
+func New(config Config) (*Migrator, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Migrator{Config: config}, nil
+}
+
+// Migrator exports application data from and imports it into a cluster
+type Migrator struct {
+	// Config is the migrator configuration
+	Config
+}
+
+// ExportRequest describes the application data to export
+type ExportRequest struct {
+	// Cluster is the name of the source cluster
+	Cluster string
+	// Namespaces lists the namespaces to export
+	Namespaces []string
+	// Claims optionally limits the exported persistent volume claims.
+	// Claims are specified either by name or as namespace/name.
+	// All claims from the exported namespaces are exported if unspecified
+	Claims []string
+	// RateLimit is the maximum rate in bytes per second the archive
+	// is written with. Zero means unlimited
+	RateLimit int64
+}
+
+// Check validates this request
+func (r ExportRequest) Check() error {
+	if len(r.Namespaces) == 0 {
+		return trace.BadParameter("at least one namespace is required")
+	}
+	for _, namespace := range r.Namespaces {
+		if err := checkNamespace(namespace); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if r.RateLimit < 0 {
+		return trace.BadParameter("rate limit cannot be negative")
+	}
+	return nil
+}
+
+// Export writes the application data described with the request
+// as a migration archive to w
+func (r *Migrator) Export(ctx context.Context, req ExportRequest, w io.Writer) (*Manifest, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	archive := NewWriter(NewThrottledWriter(ctx, w, req.RateLimit))
+	claims, err := r.selectClaims(req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// Volumes are exported first so the claims exist on the target cluster
+	// by the time the workloads using them are created
+	for _, claim := range claims {
+		if err := r.exportVolume(ctx, archive, claim); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	for _, namespace := range req.Namespaces {
+		if err := r.exportResources(archive, namespace); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	manifest := Manifest{
+		Cluster:    req.Cluster,
+		Created:    r.Clock.Now().UTC(),
+		Namespaces: req.Namespaces,
+	}
+	if err := archive.Close(manifest); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	manifest.Version = archiveVersion
+	manifest.Resources = archive.resources
+	manifest.Volumes = archive.volumes
+	return &manifest, nil
+}
+
+// selectClaims returns the bound persistent volume claims to export
+func (r *Migrator) selectClaims(req ExportRequest) (result []v1.PersistentVolumeClaim, err error) {
+	selected := make(map[string]bool)
+	for _, namespace := range req.Namespaces {
+		claims, err := r.Client.CoreV1().PersistentVolumeClaims(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, trace.Wrap(rigging.ConvertError(err))
+		}
+		for _, claim := range claims.Items {
+			name := claimSelected(claim, req.Claims)
+			if name == "" {
+				continue
+			}
+			selected[name] = true
+			if claim.Status.Phase != v1.ClaimBound {
+				r.Warnf("Skipping claim %v/%v that is %v.", claim.Namespace, claim.Name, claim.Status.Phase)
+				continue
+			}
+			result = append(result, claim)
+		}
+	}
+	for _, claim := range req.Claims {
+		if !selected[claim] {
+			return nil, trace.NotFound("persistent volume claim %v not found in namespaces %v",
+				claim, strings.Join(req.Namespaces, ", "))
+		}
+	}
+	return result, nil
+}
+
+func (r *Migrator) exportVolume(ctx context.Context, archive *Writer, claim v1.PersistentVolumeClaim) error {
+	volume := Volume{
+		Namespace:    claim.Namespace,
+		Claim:        claim.Name,
+		StorageClass: claimStorageClass(claim),
+		Capacity:     claimCapacity(claim),
+	}
+	for _, mode := range claim.Spec.AccessModes {
+		volume.AccessModes = append(volume.AccessModes, string(mode))
+	}
+	r.Infof("Exporting volume %v.", volume)
+	pod, err := startVolumePod(ctx, r.Client, claim.Namespace, claim.Name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer pod.delete()
+	reader, writer := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		err := pod.export(r.Executor, writer)
+		writer.CloseWithError(err)
+		errCh <- err
+	}()
+	exported, err := archive.WriteVolume(volume, reader)
+	reader.CloseWithError(err)
+	if errExport := <-errCh; errExport != nil && err == nil {
+		err = errExport
+	}
+	if err != nil {
+		return trace.Wrap(err, "failed to export volume %v", volume)
+	}
+	r.Infof("Exported volume %v: %v bytes.", exported, exported.Size)
+	return nil
+}
+
+func (r *Migrator) exportResources(archive *Writer, namespace string) error {
+	for _, kind := range resourceKinds {
+		list, err := r.Dynamic.Resource(kind.Resource).Namespace(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			err = rigging.ConvertError(err)
+			if trace.IsNotFound(err) {
+				r.Debugf("Resource %v is not available.", kind.Resource)
+				continue
+			}
+			return trace.Wrap(err)
+		}
+		for _, item := range list.Items {
+			item.SetKind(kind.Kind)
+			if !sanitize(&item) {
+				continue
+			}
+			data, err := item.MarshalJSON()
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			resource := Resource{
+				Kind:      kind.Kind,
+				Namespace: namespace,
+				Name:      item.GetName(),
+				Object:    data,
+			}
+			if err := archive.WriteResource(resource); err != nil {
+				return trace.Wrap(err)
+			}
+			r.Debugf("Exported %v.", resource)
+		}
+	}
+	return nil
+}
+
+// ImportRequest describes how to import application data
+type ImportRequest struct {
+	// StorageClasses maps storage classes of the source cluster
+	// to the storage classes to use on the target cluster
+	StorageClasses map[string]string
+	// Progress is optionally called after every imported resource and volume
+	// with the number of the imported archive entries
+	Progress func(imported int, message string)
+}
+
+// ParseStorageClasses parses the storage class mappings specified
+// as source:target pairs
+func ParseStorageClasses(mappings []string) (map[string]string, error) {
+	result := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		parts := strings.Split(mapping, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, trace.BadParameter("invalid storage class mapping %q, "+
+				"expected source:target", mapping)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+// FormatStorageClasses formats the storage class mappings as source:target pairs
+func FormatStorageClasses(storageClasses map[string]string) (mappings []string) {
+	for source, target := range storageClasses {
+		mappings = append(mappings, fmt.Sprintf("%v:%v", source, target))
+	}
+	sort.Strings(mappings)
+	return mappings
+}
+
+// Summary describes the result of the import
+type Summary struct {
+	// Cluster is the name of the source cluster
+	Cluster string `json:"cluster"`
+	// Namespaces lists the imported namespaces
+	Namespaces []string `json:"namespaces"`
+	// Resources lists the created resources
+	Resources []Resource `json:"resources,omitempty"`
+	// Skipped lists the resources that already existed
+	// on the target cluster and have been left intact
+	Skipped []Resource `json:"skipped,omitempty"`
+	// Volumes lists the restored volumes
+	Volumes []Volume `json:"volumes,omitempty"`
+}
+
+// Verify reads the complete migration archive from reader and verifies it
+// without applying anything: the checksums of all entries, the completeness
+// of the archive against its manifest and that no entry belongs to a system
+// namespace. Returns the manifest of the archive
+func Verify(reader io.Reader) (*Manifest, error) {
+	archive := NewReader(reader)
+	var summary Summary
+	for {
+		entry, err := archive.Next()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		switch {
+		case entry.Manifest != nil:
+			for _, namespace := range entry.Manifest.Namespaces {
+				if err := checkNamespace(namespace); err != nil {
+					return nil, trace.Wrap(err)
+				}
+			}
+			if err := verify(*entry.Manifest, summary); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			return entry.Manifest, nil
+		case entry.Resource != nil:
+			if err := checkResource(*entry.Resource); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			summary.Resources = append(summary.Resources, *entry.Resource)
+		case entry.Volume != nil:
+			volume := entry.Volume.Volume
+			if err := checkVolume(volume); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			if _, err := io.Copy(ioutil.Discard, entry.Volume); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			volume.Size = entry.Volume.Size()
+			volume.Checksum = entry.Volume.Checksum()
+			summary.Volumes = append(summary.Volumes, volume)
+		}
+	}
+}
+
+// Import imports the application data from the migration archive read from r.
+// The archive is expected to have been checked with Verify
+func (r *Migrator) Import(ctx context.Context, req ImportRequest, reader io.Reader) (*Summary, error) {
+	archive := NewReader(reader)
+	importer := &importer{
+		Migrator:   r,
+		req:        req,
+		namespaces: make(map[string]bool),
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		entry, err := archive.Next()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		switch {
+		case entry.Manifest != nil:
+			summary := &importer.summary
+			summary.Cluster = entry.Manifest.Cluster
+			summary.Namespaces = entry.Manifest.Namespaces
+			if err := verify(*entry.Manifest, *summary); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			return summary, nil
+		case entry.Resource != nil:
+			skipped := len(importer.summary.Skipped)
+			if err := importer.importResource(*entry.Resource); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			if len(importer.summary.Skipped) > skipped {
+				importer.progress("Skipped %v: already exists.", entry.Resource)
+			} else {
+				importer.progress("Imported %v.", entry.Resource)
+			}
+		case entry.Volume != nil:
+			if err := importer.importVolume(ctx, entry.Volume); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			importer.progress("Imported volume %v.", entry.Volume.Volume)
+		}
+	}
+}
+
+type importer struct {
+	*Migrator
+	req        ImportRequest
+	namespaces map[string]bool
+	summary    Summary
+	// imported is the number of imported archive entries
+	imported int
+}
+
+// progress reports the progress of the import after an archive entry
+// has been imported
+func (r *importer) progress(format string, args ...interface{}) {
+	r.imported++
+	if r.req.Progress != nil {
+		r.req.Progress(r.imported, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *importer) importResource(resource Resource) error {
+	if err := checkResource(resource); err != nil {
+		return trace.Wrap(err)
+	}
+	kind, err := findResourceKind(resource.Kind)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := r.ensureNamespace(resource.Namespace); err != nil {
+		return trace.Wrap(err)
+	}
+	var object unstructured.Unstructured
+	if err := object.UnmarshalJSON(resource.Object); err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.Dynamic.Resource(kind.Resource).Namespace(resource.Namespace).Create(&object, metav1.CreateOptions{})
+	err = rigging.ConvertError(err)
+	if trace.IsAlreadyExists(err) {
+		r.Infof("%v already exists, skipping.", resource)
+		r.summary.Skipped = append(r.summary.Skipped, resource)
+		return nil
+	}
+	if err != nil {
+		return trace.Wrap(err, "failed to create %v", resource)
+	}
+	r.Debugf("Created %v.", resource)
+	r.summary.Resources = append(r.summary.Resources, resource)
+	return nil
+}
+
+func (r *importer) importVolume(ctx context.Context, data *VolumeReader) error {
+	volume := data.Volume
+	if err := checkVolume(volume); err != nil {
+		return trace.Wrap(err)
+	}
+	r.Infof("Importing volume %v.", volume)
+	if err := r.ensureNamespace(volume.Namespace); err != nil {
+		return trace.Wrap(err)
+	}
+	claim, err := r.newClaim(volume)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.Client.CoreV1().PersistentVolumeClaims(volume.Namespace).Create(claim)
+	if err != nil {
+		err = rigging.ConvertError(err)
+		if trace.IsAlreadyExists(err) {
+			return trace.AlreadyExists("persistent volume claim %v already exists, "+
+				"remove it to import the volume data", volume)
+		}
+		return trace.Wrap(err)
+	}
+	pod, err := startVolumePod(ctx, r.Client, volume.Namespace, volume.Claim)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer pod.delete()
+	if err := pod.restore(r.Executor, data); err != nil {
+		return trace.Wrap(err, "failed to import volume %v", volume)
+	}
+	// tar stops reading at the end-of-archive marker, read the
+	// trailing padding so the complete data stream is verified
+	if _, err := io.Copy(ioutil.Discard, data); err != nil {
+		return trace.Wrap(err)
+	}
+	volume.Size = data.Size()
+	volume.Checksum = data.Checksum()
+	r.Infof("Imported volume %v: %v bytes.", volume, volume.Size)
+	r.summary.Volumes = append(r.summary.Volumes, volume)
+	return nil
+}
+
+func (r *importer) newClaim(volume Volume) (*v1.PersistentVolumeClaim, error) {
+	capacity, err := resource.ParseQuantity(volume.Capacity)
+	if err != nil {
+		return nil, trace.Wrap(err, "invalid capacity of volume %v", volume)
+	}
+	claim := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      volume.Claim,
+			Namespace: volume.Namespace,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: capacity,
+				},
+			},
+		},
+	}
+	for _, mode := range volume.AccessModes {
+		claim.Spec.AccessModes = append(claim.Spec.AccessModes, v1.PersistentVolumeAccessMode(mode))
+	}
+	storageClass := volume.StorageClass
+	if target, ok := r.req.StorageClasses[storageClass]; ok {
+		storageClass = target
+	}
+	if storageClass != "" {
+		claim.Spec.StorageClassName = &storageClass
+	}
+	return claim, nil
+}
+
+func (r *importer) ensureNamespace(namespace string) error {
+	if r.namespaces[namespace] {
+		return nil
+	}
+	_, err := r.Client.CoreV1().Namespaces().Create(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+	})
+	err = rigging.ConvertError(err)
+	if err != nil && !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	r.namespaces[namespace] = true
+	return nil
+}
+
+// verify makes sure that all resources and volumes listed in the manifest
+// have been imported and that the volume data is intact
+func verify(manifest Manifest, summary Summary) error {
+	imported := make(map[string]string)
+	for _, resource := range append(summary.Resources, summary.Skipped...) {
+		imported[resource.String()] = resource.Checksum
+	}
+	for _, resource := range manifest.Resources {
+		checksum, ok := imported[resource.String()]
+		if !ok {
+			return trace.BadParameter("%v is missing from migration archive", resource)
+		}
+		if checksum != resource.Checksum {
+			return trace.BadParameter("checksum mismatch for %v", resource)
+		}
+	}
+	volumes := make(map[string]Volume)
+	for _, volume := range summary.Volumes {
+		volumes[volume.String()] = volume
+	}
+	for _, volume := range manifest.Volumes {
+		imported, ok := volumes[volume.String()]
+		if !ok {
+			return trace.BadParameter("volume %v is missing from migration archive", volume)
+		}
+		if imported.Size != volume.Size || imported.Checksum != volume.Checksum {
+			return trace.BadParameter("data of volume %v is corrupted: expected %v bytes with checksum %v, got %v bytes with checksum %v",
+				volume, volume.Size, volume.Checksum, imported.Size, imported.Checksum)
+		}
+	}
+	return nil
+}
+
+// checkResource verifies that the resource from a migration archive
+// can be imported
+func checkResource(resource Resource) error {
+	if err := checkNamespace(resource.Namespace); err != nil {
+		return trace.Wrap(err)
+	}
+	_, err := findResourceKind(resource.Kind)
+	return trace.Wrap(err)
+}
+
+// checkVolume verifies that the volume from a migration archive
+// can be imported
+func checkVolume(volume Volume) error {
+	if err := checkNamespace(volume.Namespace); err != nil {
+		return trace.Wrap(err)
+	}
+	if _, err := resource.ParseQuantity(volume.Capacity); err != nil {
+		return trace.BadParameter("invalid capacity of volume %v: %v", volume, err)
+	}
+	return nil
+}
+
+// checkNamespace verifies that the data of the specified namespace can be migrated
+func checkNamespace(namespace string) error {
+	if namespace == "" {
+		return trace.BadParameter("only namespaced resources can be migrated")
+	}
+	if utils.StringInSlice(systemNamespaces, namespace) {
+		return trace.BadParameter("system namespace %v cannot be migrated", namespace)
+	}
+	return nil
+}
+
+// claimSelected returns the entry of claims the specified claim matches.
+// Returns the name of the claim if no claims have been specified and
+// an empty string if the claim does not match
+func claimSelected(claim v1.PersistentVolumeClaim, claims []string) string {
+	name := claim.Namespace + "/" + claim.Name
+	switch {
+	case len(claims) == 0, utils.StringInSlice(claims, name):
+		return name
+	case utils.StringInSlice(claims, claim.Name):
+		return claim.Name
+	}
+	return ""
+}
+
+func claimStorageClass(claim v1.PersistentVolumeClaim) string {
+	if claim.Spec.StorageClassName != nil {
+		return *claim.Spec.StorageClassName
+	}
+	return ""
+}
+
+func claimCapacity(claim v1.PersistentVolumeClaim) string {
+	if capacity, ok := claim.Status.Capacity[v1.ResourceStorage]; ok {
+		return capacity.String()
+	}
+	capacity := claim.Spec.Resources.Requests[v1.ResourceStorage]
+	return capacity.String()
+}
+
+// systemNamespaces lists the namespaces that cannot be migrated
+var systemNamespaces = []string{
+	defaults.KubeSystemNamespace,
+	defaults.MonitoringNamespace,
+	defaults.OpenEBSNamespace,
+	metav1.NamespacePublic,
+}
+
+var log = logrus.WithField(trace.Component, "migration")
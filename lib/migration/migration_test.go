@@ -0,0 +1,199 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type MigrationSuite struct{}
+
+var _ = check.Suite(&MigrationSuite{})
+
+func (s *MigrationSuite) TestSanitizesResources(c *check.C) {
+	service := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":              "web",
+			"namespace":         "default",
+			"uid":               "1234",
+			"resourceVersion":   "100",
+			"creationTimestamp": "2019-03-01T00:00:00Z",
+			"annotations": map[string]interface{}{
+				lastAppliedConfigAnnotation: "{}",
+				"example.com/owner":         "team",
+			},
+		},
+		"spec": map[string]interface{}{
+			"type":      "ClusterIP",
+			"clusterIP": "10.100.0.10",
+			"ports": []interface{}{
+				map[string]interface{}{"port": int64(80), "nodePort": int64(30080)},
+			},
+		},
+		"status": map[string]interface{}{"loadBalancer": map[string]interface{}{}},
+	}}
+	c.Assert(sanitize(service), check.Equals, true)
+	c.Assert(service.Object, check.DeepEquals, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "web",
+			"namespace": "default",
+			"annotations": map[string]interface{}{
+				"example.com/owner": "team",
+			},
+		},
+		"spec": map[string]interface{}{
+			"type": "ClusterIP",
+			"ports": []interface{}{
+				map[string]interface{}{"port": int64(80)},
+			},
+		},
+	})
+
+	headless := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Service",
+		"metadata": map[string]interface{}{"name": "db"},
+		"spec":     map[string]interface{}{"clusterIP": v1.ClusterIPNone},
+	}}
+	c.Assert(sanitize(headless), check.Equals, true)
+	clusterIP, _, _ := unstructured.NestedString(headless.Object, "spec", "clusterIP")
+	c.Assert(clusterIP, check.Equals, v1.ClusterIPNone)
+}
+
+func (s *MigrationSuite) TestSkipsGeneratedResources(c *check.C) {
+	var testCases = []struct {
+		object  *unstructured.Unstructured
+		comment string
+	}{
+		{
+			object: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "Secret",
+				"type": string(v1.SecretTypeServiceAccountToken),
+			}},
+			comment: "service account token",
+		},
+		{
+			object: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind":     "ServiceAccount",
+				"metadata": map[string]interface{}{"name": defaultServiceAccount},
+			}},
+			comment: "default service account",
+		},
+		{
+			object: &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"metadata": map[string]interface{}{
+					"ownerReferences": []interface{}{
+						map[string]interface{}{"kind": "Deployment", "name": "web", "uid": "1234"},
+					},
+				},
+			}},
+			comment: "owned resource",
+		},
+	}
+	for _, tc := range testCases {
+		c.Assert(sanitize(tc.object), check.Equals, false, check.Commentf(tc.comment))
+	}
+}
+
+func (s *MigrationSuite) TestVerifiesImport(c *check.C) {
+	manifest := Manifest{
+		Resources: []Resource{
+			{Kind: "ConfigMap", Namespace: "default", Name: "config", Checksum: "a"},
+			{Kind: "Secret", Namespace: "default", Name: "secret", Checksum: "b"},
+		},
+		Volumes: []Volume{{Namespace: "default", Claim: "data", Size: 10, Checksum: "c"}},
+	}
+	summary := Summary{
+		Resources: manifest.Resources[:1],
+		Skipped:   manifest.Resources[1:],
+		Volumes:   manifest.Volumes,
+	}
+	c.Assert(verify(manifest, summary), check.IsNil)
+
+	summary.Volumes = []Volume{{Namespace: "default", Claim: "data", Size: 9, Checksum: "c"}}
+	c.Assert(verify(manifest, summary), check.ErrorMatches, "data of volume default/data is corrupted.*")
+
+	summary.Volumes = nil
+	c.Assert(verify(manifest, summary), check.ErrorMatches, "volume default/data is missing.*")
+
+	summary.Skipped = nil
+	c.Assert(verify(manifest, summary), check.ErrorMatches, "Secret default/secret is missing.*")
+}
+
+func (s *MigrationSuite) TestVerifiesArchive(c *check.C) {
+	archive := newTestArchive(c, "default")
+	manifest, err := Verify(bytes.NewReader(archive))
+	c.Assert(err, check.IsNil)
+	c.Assert(manifest.Namespaces, check.DeepEquals, []string{"default"})
+	c.Assert(manifest.Volumes, check.HasLen, 1)
+
+	corrupted := bytes.Replace(archive, []byte("volume contents"), []byte("volume c0ntents"), 1)
+	_, err = Verify(bytes.NewReader(corrupted))
+	c.Assert(err, check.NotNil)
+
+	_, err = Verify(bytes.NewReader(newTestArchive(c, "kube-system")))
+	c.Assert(err, check.ErrorMatches, "system namespace kube-system cannot be migrated")
+}
+
+func newTestArchive(c *check.C, namespace string) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_, err := w.WriteVolume(Volume{Namespace: namespace, Claim: "data", Capacity: "1Gi"},
+		strings.NewReader("volume contents"))
+	c.Assert(err, check.IsNil)
+	err = w.WriteResource(Resource{
+		Kind:      "ConfigMap",
+		Namespace: namespace,
+		Name:      "config",
+		Object:    []byte(`{"kind":"ConfigMap"}`),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(w.Close(Manifest{Cluster: "example.com", Namespaces: []string{namespace}}), check.IsNil)
+	return buf.Bytes()
+}
+
+func (s *MigrationSuite) TestSelectsClaims(c *check.C) {
+	claim := v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "db"}}
+	c.Assert(claimSelected(claim, nil), check.Equals, "db/data")
+	c.Assert(claimSelected(claim, []string{"data"}), check.Equals, "data")
+	c.Assert(claimSelected(claim, []string{"db/data"}), check.Equals, "db/data")
+	c.Assert(claimSelected(claim, []string{"web/data", "logs"}), check.Equals, "")
+}
+
+func (s *MigrationSuite) TestParsesStorageClasses(c *check.C) {
+	storageClasses, err := ParseStorageClasses([]string{"openebs-cstor:openebs-zfs", "local:fast"})
+	c.Assert(err, check.IsNil)
+	c.Assert(storageClasses, check.DeepEquals, map[string]string{
+		"openebs-cstor": "openebs-zfs",
+		"local":         "fast",
+	})
+	c.Assert(FormatStorageClasses(storageClasses), check.DeepEquals,
+		[]string{"local:fast", "openebs-cstor:openebs-zfs"})
+
+	_, err = ParseStorageClasses([]string{"openebs-cstor"})
+	c.Assert(err, check.ErrorMatches, "invalid storage class mapping.*")
+}
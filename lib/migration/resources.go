@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resourceKind describes a kind of Kubernetes resources
+// migrated along with the volume data
+type resourceKind struct {
+	// Kind is the resource kind as recorded in the archive
+	Kind string
+	// Resource identifies the resource in the API
+	Resource schema.GroupVersionResource
+}
+
+// resourceKinds lists the migrated kinds of resources in the order
+// they are created on the target cluster
+var resourceKinds = []resourceKind{
+	{Kind: "ServiceAccount", Resource: schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}},
+	{Kind: "Secret", Resource: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}},
+	{Kind: "ConfigMap", Resource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}},
+	{Kind: "Service", Resource: schema.GroupVersionResource{Version: "v1", Resource: "services"}},
+	{Kind: "Deployment", Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+	{Kind: "StatefulSet", Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}},
+	{Kind: "DaemonSet", Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}},
+	{Kind: "CronJob", Resource: schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}},
+}
+
+// findResourceKind returns the resource kind with the specified name
+func findResourceKind(kind string) (*resourceKind, error) {
+	for _, resourceKind := range resourceKinds {
+		if resourceKind.Kind == kind {
+			return &resourceKind, nil
+		}
+	}
+	return nil, trace.BadParameter("unsupported resource kind %q", kind)
+}
+
+// sanitize prepares the specified object for creation on another cluster
+// by removing the cluster-specific metadata and the status.
+// Returns false if the object should not be migrated
+func sanitize(object *unstructured.Unstructured) bool {
+	// Objects managed by controllers are re-created by their owners
+	if len(object.GetOwnerReferences()) != 0 {
+		return false
+	}
+	switch object.GetKind() {
+	case "ServiceAccount":
+		if object.GetName() == defaultServiceAccount {
+			return false
+		}
+		// Token secrets are generated for the service account on the target cluster
+		unstructured.RemoveNestedField(object.Object, "secrets")
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(object.Object, "type")
+		if secretType == string(v1.SecretTypeServiceAccountToken) {
+			return false
+		}
+	case "Service":
+		clusterIP, _, _ := unstructured.NestedString(object.Object, "spec", "clusterIP")
+		if clusterIP != v1.ClusterIPNone {
+			unstructured.RemoveNestedField(object.Object, "spec", "clusterIP")
+		}
+		if ports, ok, _ := unstructured.NestedSlice(object.Object, "spec", "ports"); ok {
+			serviceType, _, _ := unstructured.NestedString(object.Object, "spec", "type")
+			if serviceType != string(v1.ServiceTypeNodePort) {
+				for _, port := range ports {
+					if port, ok := port.(map[string]interface{}); ok {
+						delete(port, "nodePort")
+					}
+				}
+			}
+			unstructured.SetNestedSlice(object.Object, ports, "spec", "ports")
+		}
+	}
+	for _, field := range []string{"uid", "resourceVersion", "selfLink", "creationTimestamp", "generation", "managedFields"} {
+		unstructured.RemoveNestedField(object.Object, "metadata", field)
+	}
+	annotations := object.GetAnnotations()
+	delete(annotations, lastAppliedConfigAnnotation)
+	object.SetAnnotations(annotations)
+	unstructured.RemoveNestedField(object.Object, "status")
+	return true
+}
+
+const (
+	// defaultServiceAccount is the name of the service account
+	// created automatically in every namespace
+	defaultServiceAccount = "default"
+	// lastAppliedConfigAnnotation is the annotation kubectl apply
+	// stores the applied configuration in
+	lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"io"
+
+	"github.com/gravitational/trace"
+	"golang.org/x/time/rate"
+)
+
+// NewThrottledWriter returns a writer that limits the rate of writes
+// to w to the specified number of bytes per second.
+// Returns w unmodified if the limit is not positive
+func NewThrottledWriter(ctx context.Context, w io.Writer, bytesPerSecond int64) io.Writer {
+	if bytesPerSecond <= 0 {
+		return w
+	}
+	burst := int(bytesPerSecond)
+	if burst > maxBurst {
+		burst = maxBurst
+	}
+	return &throttledWriter{
+		ctx:     ctx,
+		w:       w,
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), burst),
+	}
+}
+
+type throttledWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+// Write writes p to the underlying writer waiting as necessary
+// to stay within the rate limit
+func (r *throttledWriter) Write(p []byte) (written int, err error) {
+	for len(p) > 0 {
+		size := len(p)
+		if size > r.limiter.Burst() {
+			size = r.limiter.Burst()
+		}
+		if err := r.limiter.WaitN(r.ctx, size); err != nil {
+			return written, trace.Wrap(err)
+		}
+		n, err := r.w.Write(p[:size])
+		written += n
+		if err != nil {
+			return written, trace.Wrap(err)
+		}
+		p = p[size:]
+	}
+	return written, nil
+}
+
+// maxBurst is the maximum number of bytes written at once by the throttled writer
+const maxBurst = 64 * 1024
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"io"
+
+	"github.com/gravitational/gravity/lib/app/hooks"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	libkubernetes "github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// volumePod is a helper pod with a persistent volume claim mounted
+// that is used to copy the volume data
+type volumePod struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// startVolumePod starts a helper pod with the specified claim mounted
+// and waits for it to become running
+func startVolumePod(ctx context.Context, client kubernetes.Interface, namespace, claim string) (*volumePod, error) {
+	pod, err := client.CoreV1().Pods(namespace).Create(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: volumePodPrefix,
+			Namespace:    namespace,
+			Labels: map[string]string{
+				constants.MigrationLabel: claim,
+			},
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			// The pod has to run on whichever node the volume is available on
+			Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+			SecurityContext: &v1.PodSecurityContext{
+				RunAsUser: utils.Int64Ptr(0),
+			},
+			Containers: []v1.Container{{
+				Name:    volumeContainer,
+				Image:   hooks.InitContainerImage,
+				Command: []string{"sleep", "infinity"},
+				VolumeMounts: []v1.VolumeMount{{
+					Name:      volumeName,
+					MountPath: volumeMountPath,
+				}},
+			}},
+			Volumes: []v1.Volume{{
+				Name: volumeName,
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+						ClaimName: claim,
+					},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	helper := &volumePod{client: client, namespace: namespace, name: pod.Name}
+	err = utils.RetryWithInterval(ctx, utils.NewExponentialBackOff(defaults.MigrationPodTimeout), func() error {
+		pod, err := client.CoreV1().Pods(namespace).Get(helper.name, metav1.GetOptions{})
+		if err != nil {
+			return trace.Wrap(rigging.ConvertError(err))
+		}
+		switch pod.Status.Phase {
+		case v1.PodRunning:
+			return nil
+		case v1.PodFailed, v1.PodSucceeded:
+			return &backoff.PermanentError{Err: trace.BadParameter(
+				"helper pod %v/%v for claim %v has terminated", namespace, helper.name, claim)}
+		}
+		return trace.NotFound("helper pod %v/%v is %v", namespace, helper.name, pod.Status.Phase)
+	})
+	if err != nil {
+		helper.delete()
+		return nil, trace.Wrap(err)
+	}
+	return helper, nil
+}
+
+// export streams the contents of the volume as a tarball into w
+func (r *volumePod) export(executor libkubernetes.PodExecutor, w io.Writer) error {
+	return executor.Exec(r.namespace, r.name, volumeContainer,
+		[]string{"tar", "-C", volumeMountPath, "-cf", "-", "."}, nil, w)
+}
+
+// restore unpacks the tarball read from the provided reader into the volume
+func (r *volumePod) restore(executor libkubernetes.PodExecutor, data io.Reader) error {
+	return executor.Exec(r.namespace, r.name, volumeContainer,
+		[]string{"tar", "-C", volumeMountPath, "-xpf", "-"}, data, nil)
+}
+
+// delete removes the helper pod
+func (r *volumePod) delete() {
+	err := r.client.CoreV1().Pods(r.namespace).Delete(r.name, &metav1.DeleteOptions{
+		GracePeriodSeconds: utils.Int64Ptr(0),
+	})
+	if err != nil {
+		log.WithError(err).Warnf("Failed to delete helper pod %v/%v.", r.namespace, r.name)
+	}
+}
+
+const (
+	// volumePodPrefix is the name prefix of the helper pods
+	volumePodPrefix = "gravity-migration-"
+	// volumeContainer is the name of the helper pod container
+	volumeContainer = "migration"
+	// volumeName is the name of the helper pod volume
+	volumeName = "data"
+	// volumeMountPath is where the claim is mounted inside the helper pod
+	volumeMountPath = "/data"
+)
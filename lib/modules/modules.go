@@ -122,6 +122,7 @@ func (m *defaultModules) DefaultAuthPreference(string) (teleservices.AuthPrefere
 func (m *defaultModules) SupportedConnectors() []string {
 	return []string{
 		teleservices.KindOIDCConnector,
+		teleservices.KindSAMLConnector,
 		teleservices.KindGithubConnector,
 	}
 }
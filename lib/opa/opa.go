@@ -0,0 +1,185 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package opa implements the client for the REST API of an Open Policy Agent
+// server that evaluates Rego policies on behalf of the cluster controller
+package opa
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+
+	"github.com/gravitational/trace"
+)
+
+// Config is the OPA client configuration
+type Config struct {
+	// URL is the address of the OPA server, e.g. http://opa.kube-system.svc:8181
+	URL string
+	// CACert is the optional PEM-encoded certificate authority
+	// to verify the server certificate with
+	CACert string
+	// Client is the optional HTTP client
+	Client *http.Client
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (r *Config) CheckAndSetDefaults() error {
+	if r.URL == "" {
+		return trace.BadParameter("missing OPA server URL")
+	}
+	r.URL = strings.TrimSuffix(r.URL, "/")
+	if r.Client == nil {
+		tlsConfig := &tls.Config{}
+		if r.CACert != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(r.CACert)) {
+				return trace.BadParameter("failed to parse OPA server CA certificate")
+			}
+			tlsConfig.RootCAs = pool
+		}
+		r.Client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           httplib.ProxyFromPolicy,
+				TLSClientConfig: tlsConfig,
+			},
+			Timeout: defaults.OPATimeout,
+		}
+	}
+	return nil
+}
+
+// New returns a new OPA client
+func New(config Config) (*Client, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Client{Config: config}, nil
+}
+
+// Client is the OPA REST API client
+type Client struct {
+	Config
+}
+
+// UpsertPolicy creates or updates the policy module with the specified ID.
+// The server compiles the module and rejects it if it is invalid
+func (r *Client) UpsertPolicy(ctx context.Context, id, module string) error {
+	resp, err := r.do(ctx, http.MethodPut, r.policyURL(id), "text/plain", strings.NewReader(module))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	return trace.Wrap(checkResponse(resp))
+}
+
+// DeletePolicy deletes the policy module with the specified ID
+func (r *Client) DeletePolicy(ctx context.Context, id string) error {
+	resp, err := r.do(ctx, http.MethodDelete, r.policyURL(id), "", nil)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	return trace.Wrap(checkResponse(resp))
+}
+
+// Evaluate evaluates the document at the specified path, e.g.
+// gravity/operations/decision, with the provided input and
+// unmarshals the result into out.
+//
+// Returns NotFound if the document is undefined for the input
+func (r *Client) Evaluate(ctx context.Context, path string, input, out interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	url := fmt.Sprintf("%v/v1/data/%v", r.URL, strings.Trim(path, "/"))
+	resp, err := r.do(ctx, http.MethodPost, url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return trace.Wrap(err)
+	}
+	var result struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return trace.Wrap(err, "invalid OPA response")
+	}
+	if len(result.Result) == 0 {
+		return trace.NotFound("document %v is undefined", path)
+	}
+	return trace.Wrap(json.Unmarshal(result.Result, out))
+}
+
+func (r *Client) do(ctx context.Context, method, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, trace.ConnectionProblem(err, "failed to connect to OPA server %v", r.URL)
+	}
+	return resp, nil
+}
+
+func (r *Client) policyURL(id string) string {
+	return fmt.Sprintf("%v/v1/policies/%v", r.URL, id)
+}
+
+// checkResponse converts an error response of the OPA server to an error
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var apiErr struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Message == "" {
+		return trace.BadParameter("OPA server responded with %v", resp.Status)
+	}
+	message := apiErr.Message
+	for _, e := range apiErr.Errors {
+		message = fmt.Sprintf("%v: %v", message, e.Message)
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return trace.NotFound(message)
+	case http.StatusBadRequest:
+		return trace.BadParameter(message)
+	}
+	return trace.BadParameter("OPA server responded with %v: %v", resp.Status, message)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func TestOPA(t *testing.T) { check.TestingT(t) }
+
+type OPASuite struct{}
+
+var _ = check.Suite(&OPASuite{})
+
+func (s *OPASuite) TestUpsertsPolicy(c *check.C) {
+	var module string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.Method, check.Equals, http.MethodPut)
+		c.Assert(r.URL.Path, check.Equals, "/v1/policies/gravity")
+		data, _ := ioutil.ReadAll(r.Body)
+		module = string(data)
+		if module == "invalid" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"invalid_parameter","message":"error(s) occurred while compiling module(s)",` +
+				`"errors":[{"message":"rego_parse_error: package expected"}]}`))
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	client, err := New(Config{URL: server.URL + "/"})
+	c.Assert(err, check.IsNil)
+
+	c.Assert(client.UpsertPolicy(context.TODO(), "gravity", "package gravity.operations"), check.IsNil)
+	c.Assert(module, check.Equals, "package gravity.operations")
+
+	err = client.UpsertPolicy(context.TODO(), "gravity", "invalid")
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(err, check.ErrorMatches, ".*rego_parse_error: package expected")
+}
+
+func (s *OPASuite) TestEvaluatesDocument(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.Method, check.Equals, http.MethodPost)
+		c.Assert(r.URL.Path, check.Equals, "/v1/data/gravity/operations/decision")
+		var req struct {
+			Input struct {
+				User string `json:"user"`
+			} `json:"input"`
+		}
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), check.IsNil)
+		if req.Input.User == "alice" {
+			w.Write([]byte(`{"result":{"decision":"deny"}}`))
+			return
+		}
+		// undefined document
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client, err := New(Config{URL: server.URL})
+	c.Assert(err, check.IsNil)
+
+	var out struct {
+		Decision string `json:"decision"`
+	}
+	err = client.Evaluate(context.TODO(), "/gravity/operations/decision",
+		map[string]string{"user": "alice"}, &out)
+	c.Assert(err, check.IsNil)
+	c.Assert(out.Decision, check.Equals, "deny")
+
+	err = client.Evaluate(context.TODO(), "gravity/operations/decision",
+		map[string]string{"user": "bob"}, &out)
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
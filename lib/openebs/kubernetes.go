@@ -0,0 +1,261 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openebs
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// kubeAPI defines the subset of the Kubernetes API used to reconcile
+// the OpenEBS storage pools
+type kubeAPI interface {
+	// listBlockDevices returns the block devices discovered by the node disk manager
+	listBlockDevices() ([]blockDevice, error)
+	// tagBlockDevice labels the specified block device with the LocalPV device tag
+	tagBlockDevice(name, tag string) error
+	// getPoolCluster returns the cStor pool cluster with the specified name
+	getPoolCluster(name string) (*poolCluster, error)
+	// createPoolCluster creates a new cStor pool cluster
+	createPoolCluster(poolCluster) error
+	// updatePoolCluster replaces the spec of the existing cStor pool cluster
+	updatePoolCluster(poolCluster) error
+	// getStorageClass returns the storage class with the specified name
+	getStorageClass(name string) (*storagev1.StorageClass, error)
+	// createStorageClass creates a new storage class
+	createStorageClass(storagev1.StorageClass) error
+	// deleteStorageClass deletes the storage class with the specified name
+	deleteStorageClass(name string) error
+}
+
+// newKubeAPI returns the Kubernetes API implementation backed by the provided client.
+// OpenEBS resources are accessed with the raw REST client as they
+// are not a part of the client library
+func newKubeAPI(client kubernetes.Interface) *kubeClient {
+	return &kubeClient{client: client}
+}
+
+type kubeClient struct {
+	client kubernetes.Interface
+}
+
+func (r *kubeClient) listBlockDevices() ([]blockDevice, error) {
+	data, err := r.client.CoreV1().RESTClient().Get().
+		AbsPath(blockDeviceAPIPath, "namespaces", defaults.OpenEBSNamespace, "blockdevices").
+		DoRaw()
+	if err != nil {
+		err = rigging.ConvertError(err)
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("OpenEBS block device API %v is not available, "+
+				"make sure OpenEBS is installed", blockDeviceAPIVersion)
+		}
+		return nil, trace.Wrap(err)
+	}
+	var list blockDeviceList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return list.Items, nil
+}
+
+func (r *kubeClient) tagBlockDevice(name, tag string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{blockDeviceTagLabel: tag},
+		},
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.client.CoreV1().RESTClient().Patch(types.MergePatchType).
+		AbsPath(blockDeviceAPIPath, "namespaces", defaults.OpenEBSNamespace, "blockdevices", name).
+		Body(data).
+		DoRaw()
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+func (r *kubeClient) getPoolCluster(name string) (*poolCluster, error) {
+	data, err := r.client.CoreV1().RESTClient().Get().
+		AbsPath(poolClusterAPIPath, "namespaces", defaults.OpenEBSNamespace, "cstorpoolclusters", name).
+		DoRaw()
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	var cluster poolCluster
+	if err := json.Unmarshal(data, &cluster); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &cluster, nil
+}
+
+func (r *kubeClient) createPoolCluster(cluster poolCluster) error {
+	cluster.APIVersion = poolClusterAPIVersion
+	cluster.Kind = "CStorPoolCluster"
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.client.CoreV1().RESTClient().Post().
+		AbsPath(poolClusterAPIPath, "namespaces", cluster.Metadata.Namespace, "cstorpoolclusters").
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw()
+	if trace.IsNotFound(rigging.ConvertError(err)) {
+		return trace.NotFound("cStor pool cluster API %v is not available, "+
+			"make sure the OpenEBS cStor operator is installed", poolClusterAPIVersion)
+	}
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+func (r *kubeClient) updatePoolCluster(cluster poolCluster) error {
+	// The spec is merge-patched so the fields maintained by the cStor operator are retained
+	data, err := json.Marshal(map[string]interface{}{"spec": cluster.Spec})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.client.CoreV1().RESTClient().Patch(types.MergePatchType).
+		AbsPath(poolClusterAPIPath, "namespaces", cluster.Metadata.Namespace, "cstorpoolclusters", cluster.Metadata.Name).
+		Body(data).
+		DoRaw()
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+func (r *kubeClient) getStorageClass(name string) (*storagev1.StorageClass, error) {
+	class, err := r.client.StorageV1().StorageClasses().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	return class, nil
+}
+
+func (r *kubeClient) createStorageClass(class storagev1.StorageClass) error {
+	_, err := r.client.StorageV1().StorageClasses().Create(&class)
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+func (r *kubeClient) deleteStorageClass(name string) error {
+	err := r.client.StorageV1().StorageClasses().Delete(name, nil)
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+// blockDeviceList is a list of OpenEBS block device resources.
+//
+// Only the fields required to match the block devices with the devices
+// on cluster nodes are decoded
+type blockDeviceList struct {
+	Items []blockDevice `json:"items"`
+}
+
+// blockDevice is an OpenEBS block device resource
+type blockDevice struct {
+	Metadata struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Path    string `json:"path"`
+		Details struct {
+			Vendor string `json:"vendor"`
+		} `json:"details"`
+		NodeAttributes struct {
+			NodeName string `json:"nodeName"`
+		} `json:"nodeAttributes"`
+	} `json:"spec"`
+	Status struct {
+		ClaimState string `json:"claimState"`
+	} `json:"status"`
+}
+
+// node returns the name of the node the block device is attached to
+func (r blockDevice) node() string {
+	if r.Spec.NodeAttributes.NodeName != "" {
+		return r.Spec.NodeAttributes.NodeName
+	}
+	return r.Metadata.Labels[defaults.KubernetesHostnameLabel]
+}
+
+// poolCluster is a cStor pool cluster resource.
+//
+// It describes the cStor pools that make up a single storage pool
+// with a pool instance per node
+type poolCluster struct {
+	// APIVersion is the resource API version
+	APIVersion string `json:"apiVersion"`
+	// Kind is the resource kind
+	Kind string `json:"kind"`
+	// Metadata is the resource metadata
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	// Spec is the pool cluster spec
+	Spec poolClusterSpec `json:"spec"`
+}
+
+// poolClusterSpec lists the pool instances of a cStor pool cluster
+type poolClusterSpec struct {
+	// Pools lists the pool instances
+	Pools []poolSpec `json:"pools"`
+}
+
+// poolSpec describes the cStor pool instance on a single node
+type poolSpec struct {
+	// NodeSelector selects the node of the pool instance
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// DataRaidGroups lists the groups of block devices that store the pool data
+	DataRaidGroups []raidGroup `json:"dataRaidGroups"`
+	// PoolConfig configures the pool instance
+	PoolConfig poolConfig `json:"poolConfig"`
+}
+
+// raidGroup is a group of block devices of a pool instance
+type raidGroup struct {
+	// BlockDevices lists the block devices of the group
+	BlockDevices []blockDeviceRef `json:"blockDevices"`
+}
+
+// blockDeviceRef references a block device resource
+type blockDeviceRef struct {
+	// BlockDeviceName is the name of the block device resource
+	BlockDeviceName string `json:"blockDeviceName"`
+}
+
+// poolConfig configures a pool instance
+type poolConfig struct {
+	// DataRaidGroupType is the RAID layout of the data groups
+	DataRaidGroupType string `json:"dataRaidGroupType"`
+}
+
+const (
+	// blockDeviceAPIVersion is the API version of the OpenEBS block devices
+	blockDeviceAPIVersion = "openebs.io/v1alpha1"
+	// blockDeviceAPIPath is the REST path of the OpenEBS block device API
+	blockDeviceAPIPath = "/apis/" + blockDeviceAPIVersion
+	// poolClusterAPIVersion is the API version of the cStor pool clusters
+	poolClusterAPIVersion = "cstor.openebs.io/v1"
+	// poolClusterAPIPath is the REST path of the cStor pool cluster API
+	poolClusterAPIPath = "/apis/" + poolClusterAPIVersion
+
+	// blockDeviceTagLabel is the label that reserves a block device
+	// for the LocalPV storage classes with the matching device tag
+	blockDeviceTagLabel = "openebs.io/block-device-tag"
+)
@@ -0,0 +1,453 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package openebs renders the OpenEBS storage pools declared in the persistent
+// storage configuration into the cStor pool clusters, block device tags and
+// storage classes and reconciles them with the cluster state.
+//
+// The resources of the pools removed from the configuration are left intact
+// since deleting a pool would destroy the data of its volumes.
+package openebs
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Config defines the storage pools to reconcile
+type Config struct {
+	// Pools lists the storage pools from the persistent storage configuration
+	Pools []storage.StoragePool
+	// Servers lists the cluster nodes
+	Servers storage.Servers
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (r *Config) CheckAndSetDefaults() error {
+	if len(r.Servers) == 0 {
+		return trace.BadParameter("missing Servers")
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = logrus.WithField(trace.Component, "openebs")
+	}
+	return nil
+}
+
+// Reconcile creates or updates the cStor pool clusters, block device tags
+// and storage classes of the configured storage pools.
+//
+// Returns NotFound if any of the block devices claimed by the pools
+// has not been discovered by the node disk manager yet
+func Reconcile(client kubernetes.Interface, config Config) error {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	return reconcile(newKubeAPI(client), config)
+}
+
+// BlockDevice describes a block device discovered by the node disk manager
+type BlockDevice struct {
+	// Name is the name of the OpenEBS block device resource
+	Name string
+	// Node is the name of the Kubernetes node the device is attached to
+	Node string
+	// Path is the device path
+	Path string
+	// Vendor is the device vendor
+	Vendor string
+	// Claimed is whether the device is bound to a block device claim
+	Claimed bool
+}
+
+// ListBlockDevices returns the block devices discovered by the node disk manager.
+// Returns NotFound if OpenEBS is not installed in the cluster
+func ListBlockDevices(client kubernetes.Interface) ([]BlockDevice, error) {
+	items, err := newKubeAPI(client).listBlockDevices()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	devices := make([]BlockDevice, 0, len(items))
+	for _, item := range items {
+		devices = append(devices, BlockDevice{
+			Name:    item.Metadata.Name,
+			Node:    item.node(),
+			Path:    item.Spec.Path,
+			Vendor:  item.Spec.Details.Vendor,
+			Claimed: item.Status.ClaimState == blockDeviceClaimed,
+		})
+	}
+	return devices, nil
+}
+
+func reconcile(api kubeAPI, config Config) error {
+	if len(config.Pools) == 0 {
+		return nil
+	}
+	devices, err := api.listBlockDevices()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	resources, err := render(config, devices)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, cluster := range resources.poolClusters {
+		if err := reconcilePoolCluster(api, cluster, config.FieldLogger); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	for _, device := range devices {
+		tag, ok := resources.deviceTags[device.Metadata.Name]
+		if !ok || device.Metadata.Labels[blockDeviceTagLabel] == tag {
+			continue
+		}
+		if err := api.tagBlockDevice(device.Metadata.Name, tag); err != nil {
+			return trace.Wrap(err)
+		}
+		config.WithField("device", device.Metadata.Name).Infof("Reserved block device for storage pool %v.", tag)
+	}
+	for _, class := range resources.storageClasses {
+		if err := reconcileStorageClass(api, class, config.FieldLogger); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+func reconcilePoolCluster(api kubeAPI, cluster poolCluster, logger logrus.FieldLogger) error {
+	logger = logger.WithField("pool", cluster.Metadata.Name)
+	existing, err := api.getPoolCluster(cluster.Metadata.Name)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	if existing == nil {
+		if err := api.createPoolCluster(cluster); err != nil {
+			return trace.Wrap(err)
+		}
+		logger.Info("Created cStor pool cluster.")
+		return nil
+	}
+	if poolClusterMatches(*existing, cluster) {
+		return nil
+	}
+	if err := api.updatePoolCluster(cluster); err != nil {
+		return trace.Wrap(err)
+	}
+	logger.Info("Updated cStor pool cluster.")
+	return nil
+}
+
+// reconcileStorageClass creates the storage class or re-creates it if it
+// does not match the configuration since the storage class parameters
+// cannot be updated. Existing volumes are not affected
+func reconcileStorageClass(api kubeAPI, class storagev1.StorageClass, logger logrus.FieldLogger) error {
+	logger = logger.WithField("storage-class", class.Name)
+	existing, err := api.getStorageClass(class.Name)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	if existing != nil {
+		if storageClassMatches(*existing, class) {
+			return nil
+		}
+		if err := api.deleteStorageClass(class.Name); err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+	}
+	if err := api.createStorageClass(class); err != nil {
+		return trace.Wrap(err)
+	}
+	logger.Info("Configured storage class.")
+	return nil
+}
+
+// storageClassMatches returns true if the existing storage class
+// has the attributes of the rendered one
+func storageClassMatches(existing, class storagev1.StorageClass) bool {
+	if existing.Provisioner != class.Provisioner {
+		return false
+	}
+	if len(existing.Parameters) != 0 || len(class.Parameters) != 0 {
+		if !reflect.DeepEqual(existing.Parameters, class.Parameters) {
+			return false
+		}
+	}
+	for _, key := range []string{casConfigAnnotation, casTypeAnnotation, defaultClassAnnotation} {
+		if existing.Annotations[key] != class.Annotations[key] {
+			return false
+		}
+	}
+	if len(existing.AllowedTopologies) != 0 || len(class.AllowedTopologies) != 0 {
+		if !reflect.DeepEqual(existing.AllowedTopologies, class.AllowedTopologies) {
+			return false
+		}
+	}
+	return true
+}
+
+// poolClusterMatches returns true if the existing pool cluster has the
+// pool instances of the rendered one. Only the fields managed by the
+// configuration are compared since the server defaults the rest of the spec
+func poolClusterMatches(existing, cluster poolCluster) bool {
+	if len(existing.Spec.Pools) != len(cluster.Spec.Pools) {
+		return false
+	}
+	existingPools := make(map[string]poolSpec, len(existing.Spec.Pools))
+	for _, pool := range existing.Spec.Pools {
+		existingPools[pool.NodeSelector[defaults.KubernetesHostnameLabel]] = pool
+	}
+	for _, pool := range cluster.Spec.Pools {
+		existingPool, ok := existingPools[pool.NodeSelector[defaults.KubernetesHostnameLabel]]
+		if !ok {
+			return false
+		}
+		raidType := pool.PoolConfig.DataRaidGroupType
+		if raidType != "" && existingPool.PoolConfig.DataRaidGroupType != raidType {
+			return false
+		}
+		if !utils.CompareStringSlices(raidGroupKeys(existingPool), raidGroupKeys(pool)) {
+			return false
+		}
+	}
+	return true
+}
+
+// raidGroupKeys returns the block device names of each RAID group
+// of the pool instance joined in a single key per group
+func raidGroupKeys(pool poolSpec) []string {
+	keys := make([]string, 0, len(pool.DataRaidGroups))
+	for _, group := range pool.DataRaidGroups {
+		names := make([]string, 0, len(group.BlockDevices))
+		for _, device := range group.BlockDevices {
+			names = append(names, device.BlockDeviceName)
+		}
+		sort.Strings(names)
+		keys = append(keys, strings.Join(names, ","))
+	}
+	return keys
+}
+
+// resources lists the Kubernetes resources of the storage pools
+type resources struct {
+	// poolClusters lists the cStor pool clusters
+	poolClusters []poolCluster
+	// deviceTags maps the block devices claimed by the LocalPV pools
+	// to the pool names
+	deviceTags map[string]string
+	// storageClasses lists the storage classes of all pools
+	storageClasses []storagev1.StorageClass
+}
+
+// render returns the Kubernetes resources of the configured storage pools.
+// The block device paths are resolved to the block device resources
+// discovered by the node disk manager
+func render(config Config, devices []blockDevice) (*resources, error) {
+	result := &resources{deviceTags: make(map[string]string)}
+	for _, pool := range config.Pools {
+		nodes, err := resolveNodes(pool, config.Servers, devices)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if pool.IsCStor() {
+			result.poolClusters = append(result.poolClusters, renderPoolCluster(pool, nodes))
+		} else {
+			for _, node := range nodes {
+				for _, device := range node.devices {
+					result.deviceTags[device] = pool.Name
+				}
+			}
+		}
+		result.storageClasses = append(result.storageClasses, renderStorageClass(pool, nodes))
+	}
+	return result, nil
+}
+
+// poolNode describes the block devices of a storage pool on a Kubernetes node
+type poolNode struct {
+	// name is the Kubernetes node name
+	name string
+	// devices lists the names of the block device resources
+	devices []string
+}
+
+// resolveNodes returns the Kubernetes nodes of the specified pool along with
+// the block device resources of the block devices the pool claims on them
+func resolveNodes(pool storage.StoragePool, servers storage.Servers, devices []blockDevice) ([]poolNode, error) {
+	nodes := make([]poolNode, 0, len(pool.Nodes))
+	for _, node := range pool.Nodes {
+		server := findServer(servers, node.Node)
+		if server == nil {
+			return nil, trace.NotFound("node %v of storage pool %v is not a cluster node",
+				node.Node, pool.Name)
+		}
+		resolved := poolNode{name: server.KubeNodeID()}
+		for _, path := range node.Devices {
+			device := findBlockDevice(devices, resolved.name, path)
+			if device == nil {
+				return nil, trace.NotFound("block device %v on node %v of storage pool %v "+
+					"has not been discovered by OpenEBS", path, node.Node, pool.Name)
+			}
+			resolved.devices = append(resolved.devices, device.Metadata.Name)
+		}
+		nodes = append(nodes, resolved)
+	}
+	return nodes, nil
+}
+
+func renderPoolCluster(pool storage.StoragePool, nodes []poolNode) poolCluster {
+	cluster := poolCluster{
+		Metadata: metav1.ObjectMeta{
+			Name:      pool.Name,
+			Namespace: defaults.OpenEBSNamespace,
+			Labels:    map[string]string{storagePoolLabel: pool.Name},
+		},
+	}
+	groupSize := 1
+	if pool.RAIDType == storage.StoragePoolRAIDMirror {
+		groupSize = 2
+	}
+	for _, node := range nodes {
+		spec := poolSpec{
+			NodeSelector: map[string]string{defaults.KubernetesHostnameLabel: node.name},
+			PoolConfig:   poolConfig{DataRaidGroupType: pool.RAIDType},
+		}
+		if groupSize == 1 {
+			spec.DataRaidGroups = []raidGroup{{BlockDevices: blockDeviceRefs(node.devices)}}
+		} else {
+			for i := 0; i+groupSize <= len(node.devices); i += groupSize {
+				spec.DataRaidGroups = append(spec.DataRaidGroups, raidGroup{
+					BlockDevices: blockDeviceRefs(node.devices[i : i+groupSize]),
+				})
+			}
+		}
+		cluster.Spec.Pools = append(cluster.Spec.Pools, spec)
+	}
+	return cluster
+}
+
+func renderStorageClass(pool storage.StoragePool, nodes []poolNode) storagev1.StorageClass {
+	reclaimPolicy := v1.PersistentVolumeReclaimDelete
+	class := storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pool.StorageClass,
+			Labels:      map[string]string{storagePoolLabel: pool.Name},
+			Annotations: make(map[string]string),
+		},
+		ReclaimPolicy: &reclaimPolicy,
+	}
+	if pool.Default {
+		class.Annotations[defaultClassAnnotation] = "true"
+	}
+	if pool.IsCStor() {
+		allowExpansion := true
+		class.Provisioner = cstorProvisioner
+		class.AllowVolumeExpansion = &allowExpansion
+		class.Parameters = map[string]string{
+			"cas-type":         storage.StoragePoolCStor,
+			"cstorPoolCluster": pool.Name,
+			"replicaCount":     strconv.Itoa(pool.Replicas),
+		}
+		return class
+	}
+	bindingMode := storagev1.VolumeBindingWaitForFirstConsumer
+	class.Provisioner = localProvisioner
+	class.VolumeBindingMode = &bindingMode
+	class.Annotations[casTypeAnnotation] = "local"
+	if pool.Path != "" {
+		class.Annotations[casConfigAnnotation] = fmt.Sprintf(
+			"- name: StorageType\n  value: hostpath\n- name: BasePath\n  value: %q\n", pool.Path)
+	} else {
+		class.Annotations[casConfigAnnotation] = fmt.Sprintf(
+			"- name: StorageType\n  value: device\n- name: BlockDeviceTag\n  value: %q\n", pool.Name)
+	}
+	if len(nodes) != 0 {
+		names := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			names = append(names, node.name)
+		}
+		sort.Strings(names)
+		class.AllowedTopologies = []v1.TopologySelectorTerm{{
+			MatchLabelExpressions: []v1.TopologySelectorLabelRequirement{{
+				Key:    defaults.KubernetesHostnameLabel,
+				Values: names,
+			}},
+		}}
+	}
+	return class
+}
+
+func blockDeviceRefs(names []string) (refs []blockDeviceRef) {
+	for _, name := range names {
+		refs = append(refs, blockDeviceRef{BlockDeviceName: name})
+	}
+	return refs
+}
+
+// findServer returns the server with the specified hostname or advertise IP
+func findServer(servers storage.Servers, node string) *storage.Server {
+	for i, server := range servers {
+		if server.Hostname == node || server.AdvertiseIP == node {
+			return &servers[i]
+		}
+	}
+	return nil
+}
+
+func findBlockDevice(devices []blockDevice, node, path string) *blockDevice {
+	for i, device := range devices {
+		if device.node() == node && device.Spec.Path == path {
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+const (
+	// cstorProvisioner is the provisioner of the cStor volumes
+	cstorProvisioner = "cstor.csi.openebs.io"
+	// localProvisioner is the provisioner of the LocalPV volumes
+	localProvisioner = "openebs.io/local"
+
+	// casTypeAnnotation specifies the storage engine of a LocalPV storage class
+	casTypeAnnotation = "openebs.io/cas-type"
+	// casConfigAnnotation configures the volumes of a LocalPV storage class
+	casConfigAnnotation = "cas.openebs.io/config"
+	// defaultClassAnnotation marks the default storage class of the cluster
+	defaultClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+	// storagePoolLabel marks the resources rendered for a storage pool
+	storagePoolLabel = "gravitational.io/storage-pool"
+
+	// blockDeviceClaimed is the claim state of a block device
+	// bound to a block device claim
+	blockDeviceClaimed = "Claimed"
+)
@@ -0,0 +1,246 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openebs
+
+import (
+	"testing"
+
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/check.v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+func TestOpenEBS(t *testing.T) { check.TestingT(t) }
+
+type OpenEBSSuite struct{}
+
+var _ = check.Suite(&OpenEBSSuite{})
+
+func (s *OpenEBSSuite) TestRendersPools(c *check.C) {
+	resources, err := render(newTestConfig(), newTestDevices())
+	c.Assert(err, check.IsNil)
+	c.Assert(resources.poolClusters, compare.DeepEquals, []poolCluster{{
+		Metadata: newTestPoolCluster().Metadata,
+		Spec: poolClusterSpec{
+			Pools: []poolSpec{
+				{
+					NodeSelector: map[string]string{"kubernetes.io/hostname": "192.168.1.1"},
+					DataRaidGroups: []raidGroup{{BlockDevices: []blockDeviceRef{
+						{BlockDeviceName: "bd-1"}, {BlockDeviceName: "bd-2"},
+					}}},
+					PoolConfig: poolConfig{DataRaidGroupType: "mirror"},
+				},
+				{
+					NodeSelector: map[string]string{"kubernetes.io/hostname": "192.168.1.2"},
+					DataRaidGroups: []raidGroup{{BlockDevices: []blockDeviceRef{
+						{BlockDeviceName: "bd-3"}, {BlockDeviceName: "bd-4"},
+					}}},
+					PoolConfig: poolConfig{DataRaidGroupType: "mirror"},
+				},
+			},
+		},
+	}})
+	c.Assert(resources.deviceTags, compare.DeepEquals, map[string]string{"bd-5": "local-pool"})
+	c.Assert(resources.storageClasses, check.HasLen, 2)
+	cstor := resources.storageClasses[0]
+	c.Assert(cstor.Provisioner, check.Equals, cstorProvisioner)
+	c.Assert(cstor.Parameters, compare.DeepEquals, map[string]string{
+		"cas-type":         "cstor",
+		"cstorPoolCluster": "cstor-pool",
+		"replicaCount":     "2",
+	})
+	c.Assert(cstor.Annotations[defaultClassAnnotation], check.Equals, "true")
+	local := resources.storageClasses[1]
+	c.Assert(local.Provisioner, check.Equals, localProvisioner)
+	c.Assert(local.Annotations[casConfigAnnotation], check.Equals,
+		"- name: StorageType\n  value: device\n- name: BlockDeviceTag\n  value: \"local-pool\"\n")
+	c.Assert(local.AllowedTopologies[0].MatchLabelExpressions[0].Values, compare.DeepEquals,
+		[]string{"192.168.1.2"})
+}
+
+func (s *OpenEBSSuite) TestFailsOnUndiscoveredDevice(c *check.C) {
+	_, err := render(newTestConfig(), newTestDevices()[1:])
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *OpenEBSSuite) TestReconcilesPools(c *check.C) {
+	api := newFakeAPI()
+	c.Assert(reconcile(api, newTestConfig()), check.IsNil)
+	c.Assert(api.poolClusters, check.HasLen, 1)
+	c.Assert(api.storageClasses, check.HasLen, 2)
+	c.Assert(api.tags, compare.DeepEquals, map[string]string{"bd-5": "local-pool"})
+	c.Assert(api.creates, check.Equals, 3)
+
+	// Reconciling unchanged configuration does not touch the resources
+	c.Assert(reconcile(api, newTestConfig()), check.IsNil)
+	c.Assert(api.creates, check.Equals, 3)
+	c.Assert(api.updates, check.Equals, 0)
+
+	// Fields defaulted by the server do not cause updates
+	cluster := api.poolClusters["cstor-pool"]
+	cluster.Spec.Pools[0], cluster.Spec.Pools[1] = cluster.Spec.Pools[1], cluster.Spec.Pools[0]
+	for i := range cluster.Spec.Pools {
+		cluster.Spec.Pools[i].NodeSelector["kubernetes.io/os"] = "linux"
+	}
+	api.poolClusters["cstor-pool"] = cluster
+	c.Assert(reconcile(api, newTestConfig()), check.IsNil)
+	c.Assert(api.updates, check.Equals, 0)
+
+	// Changed pool instances are updated
+	cluster.Spec.Pools[0].DataRaidGroups = cluster.Spec.Pools[0].DataRaidGroups[:0]
+	api.poolClusters["cstor-pool"] = cluster
+	c.Assert(reconcile(api, newTestConfig()), check.IsNil)
+	c.Assert(api.updates, check.Equals, 1)
+
+	// Changed storage class is re-created
+	config := newTestConfig()
+	config.Pools[0].Replicas = 1
+	c.Assert(reconcile(api, config), check.IsNil)
+	c.Assert(api.creates, check.Equals, 4)
+	c.Assert(api.storageClasses["openebs-cstor"].Parameters["replicaCount"], check.Equals, "1")
+}
+
+func newTestConfig() Config {
+	return Config{
+		Pools: []storage.StoragePool{
+			{
+				Name:         "cstor-pool",
+				Type:         storage.StoragePoolCStor,
+				StorageClass: "openebs-cstor",
+				Default:      true,
+				Replicas:     2,
+				RAIDType:     storage.StoragePoolRAIDMirror,
+				Nodes: []storage.StoragePoolNode{
+					{Node: "node-1", Devices: []string{"/dev/sdb", "/dev/sdc"}},
+					{Node: "192.168.1.2", Devices: []string{"/dev/sdb", "/dev/sdc"}},
+				},
+			},
+			{
+				Name:         "local-pool",
+				Type:         storage.StoragePoolLocalPV,
+				StorageClass: "openebs-device",
+				Nodes: []storage.StoragePoolNode{
+					{Node: "node-2", Devices: []string{"/dev/sdd"}},
+				},
+			},
+		},
+		Servers: storage.Servers{
+			{Hostname: "node-1", AdvertiseIP: "192.168.1.1"},
+			{Hostname: "node-2", AdvertiseIP: "192.168.1.2"},
+		},
+		FieldLogger: logrus.WithField(trace.Component, "openebs"),
+	}
+}
+
+func newTestDevices() []blockDevice {
+	return []blockDevice{
+		newTestDevice("bd-1", "192.168.1.1", "/dev/sdb"),
+		newTestDevice("bd-2", "192.168.1.1", "/dev/sdc"),
+		newTestDevice("bd-3", "192.168.1.2", "/dev/sdb"),
+		newTestDevice("bd-4", "192.168.1.2", "/dev/sdc"),
+		newTestDevice("bd-5", "192.168.1.2", "/dev/sdd"),
+	}
+}
+
+func newTestDevice(name, node, path string) blockDevice {
+	var device blockDevice
+	device.Metadata.Name = name
+	device.Spec.NodeAttributes.NodeName = node
+	device.Spec.Path = path
+	return device
+}
+
+func newTestPoolCluster() poolCluster {
+	return renderPoolCluster(storage.StoragePool{Name: "cstor-pool"}, nil)
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		devices:        newTestDevices(),
+		tags:           make(map[string]string),
+		poolClusters:   make(map[string]poolCluster),
+		storageClasses: make(map[string]storagev1.StorageClass),
+	}
+}
+
+type fakeAPI struct {
+	devices        []blockDevice
+	tags           map[string]string
+	poolClusters   map[string]poolCluster
+	storageClasses map[string]storagev1.StorageClass
+	creates        int
+	updates        int
+}
+
+func (r *fakeAPI) listBlockDevices() ([]blockDevice, error) {
+	devices := make([]blockDevice, 0, len(r.devices))
+	for _, device := range r.devices {
+		if tag, ok := r.tags[device.Metadata.Name]; ok {
+			device.Metadata.Labels = map[string]string{blockDeviceTagLabel: tag}
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+func (r *fakeAPI) tagBlockDevice(name, tag string) error {
+	r.tags[name] = tag
+	return nil
+}
+
+func (r *fakeAPI) getPoolCluster(name string) (*poolCluster, error) {
+	cluster, ok := r.poolClusters[name]
+	if !ok {
+		return nil, trace.NotFound("pool cluster %v not found", name)
+	}
+	return &cluster, nil
+}
+
+func (r *fakeAPI) createPoolCluster(cluster poolCluster) error {
+	r.creates++
+	r.poolClusters[cluster.Metadata.Name] = cluster
+	return nil
+}
+
+func (r *fakeAPI) updatePoolCluster(cluster poolCluster) error {
+	r.updates++
+	r.poolClusters[cluster.Metadata.Name] = cluster
+	return nil
+}
+
+func (r *fakeAPI) getStorageClass(name string) (*storagev1.StorageClass, error) {
+	class, ok := r.storageClasses[name]
+	if !ok {
+		return nil, trace.NotFound("storage class %v not found", name)
+	}
+	return &class, nil
+}
+
+func (r *fakeAPI) createStorageClass(class storagev1.StorageClass) error {
+	r.creates++
+	r.storageClasses[class.Name] = class
+	return nil
+}
+
+func (r *fakeAPI) deleteStorageClass(name string) error {
+	delete(r.storageClasses, name)
+	return nil
+}
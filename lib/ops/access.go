@@ -0,0 +1,55 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+
+	"github.com/gravitational/trace"
+)
+
+// AccessChecker defines the interface to check permissions
+// of the current user against the cluster roles
+type AccessChecker interface {
+	// CheckAccess returns an access denied error if the current user
+	// is not allowed to perform the specified action in the cluster
+	CheckAccess(context.Context, CheckAccessRequest) error
+}
+
+// CheckAccessRequest describes the action to check the permission for
+type CheckAccessRequest struct {
+	// SiteKey identifies the cluster
+	SiteKey
+	// Kind is the resource kind
+	Kind string `json:"kind"`
+	// Verb is the action on the resource, e.g. read or update
+	Verb string `json:"verb"`
+}
+
+// Check validates the request
+func (r CheckAccessRequest) Check() error {
+	if err := r.SiteKey.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Kind == "" {
+		return trace.BadParameter("missing resource kind")
+	}
+	if r.Verb == "" {
+		return trace.BadParameter("missing verb")
+	}
+	return nil
+}
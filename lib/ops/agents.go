@@ -17,12 +17,19 @@ limitations under the License.
 package ops
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/gravitational/gravity/lib/checks"
 	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
 
 	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	kubetaints "k8s.io/kubernetes/pkg/util/taints"
 )
 
 // AgentReport provides information about servers as
@@ -131,3 +138,97 @@ func (s *AgentReport) MatchFlavor(flavor *schema.Flavor) (needed map[string]int,
 	}
 	return
 }
+
+// NodeVars returns the agent runtime parameters with the specified
+// additional Kubernetes node labels and taints
+func NodeVars(labels map[string]string, taints []v1.Taint) map[string]string {
+	vars := make(map[string]string)
+	if len(labels) != 0 {
+		values := make([]string, 0, len(labels))
+		for key, value := range labels {
+			values = append(values, fmt.Sprintf("%v=%v", key, value))
+		}
+		sort.Strings(values)
+		vars[AgentNodeLabels] = strings.Join(values, ",")
+	}
+	if len(taints) != 0 {
+		values := make([]string, 0, len(taints))
+		for _, taint := range taints {
+			values = append(values, fmt.Sprintf("%v=%v:%v", taint.Key, taint.Value, taint.Effect))
+		}
+		vars[AgentNodeTaints] = strings.Join(values, ",")
+	}
+	return vars
+}
+
+// ParseNodeVars returns the additional Kubernetes node labels and taints
+// from the specified agent runtime parameters
+func ParseNodeVars(vars map[string]string) (labels map[string]string, taints []v1.Taint, err error) {
+	if value := vars[AgentNodeLabels]; value != "" {
+		labels, err = ParseNodeLabels(strings.Split(value, ","))
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+	}
+	if value := vars[AgentNodeTaints]; value != "" {
+		taints, err = ParseNodeTaints(strings.Split(value, ","))
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+	}
+	return labels, taints, nil
+}
+
+// SetNodeMetadata records the cloud and hardware metadata and the join flags
+// reported by the agent with the specified runtime parameters on the server
+func SetNodeMetadata(server *storage.Server, vars map[string]string) error {
+	server.AvailabilityZone = vars[AgentAvailabilityZone]
+	server.MachineSerial = vars[AgentMachineSerial]
+	if value := vars[AgentJoinFlags]; value != "" {
+		if err := json.Unmarshal([]byte(value), &server.JoinFlags); err != nil {
+			return trace.Wrap(err, "invalid join flags %q", value)
+		}
+	}
+	return nil
+}
+
+// ParseNodeLabels parses and validates Kubernetes node labels given
+// as a list of key=value pairs
+func ParseNodeLabels(specs []string) (map[string]string, error) {
+	labels := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, trace.BadParameter("invalid node label %q, expected key=value", spec)
+		}
+		if err := CheckNodeLabel(parts[0], parts[1]); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	return labels, nil
+}
+
+// CheckNodeLabel validates the specified Kubernetes node label
+func CheckNodeLabel(key, value string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+		return trace.BadParameter("invalid node label key %q: %v", key, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+		return trace.BadParameter("invalid node label value %q: %v", value, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ParseNodeTaints parses and validates Kubernetes node taints given
+// as a list of key=value:effect specs
+func ParseNodeTaints(specs []string) ([]v1.Taint, error) {
+	result, remove, err := kubetaints.ParseTaints(specs)
+	if err != nil {
+		return nil, trace.BadParameter("invalid node taints %q: %v", specs, err)
+	}
+	if len(remove) != 0 {
+		return nil, trace.BadParameter("node taints should be specified as key=value:effect")
+	}
+	return result, nil
+}
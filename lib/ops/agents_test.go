@@ -23,8 +23,10 @@ import (
 	"github.com/gravitational/gravity/lib/compare"
 	"github.com/gravitational/gravity/lib/rpc/proto"
 	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
 
 	check "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
 )
 
 func TestOps(t *testing.T) { check.TestingT(t) }
@@ -73,6 +75,57 @@ func (s *AgentReportSuite) TestCheck(c *check.C) {
 	c.Assert(extra, compare.DeepEquals, []checks.ServerInfo{server3})
 }
 
+func (s *AgentReportSuite) TestNodeVars(c *check.C) {
+	labels := map[string]string{"dedicated": "db", "example.com/tier": "storage"}
+	taints, err := ParseNodeTaints([]string{"dedicated=db:NoSchedule"})
+	c.Assert(err, check.IsNil)
+
+	vars := NodeVars(labels, taints)
+	c.Assert(vars, compare.DeepEquals, map[string]string{
+		AgentNodeLabels: "dedicated=db,example.com/tier=storage",
+		AgentNodeTaints: "dedicated=db:NoSchedule",
+	})
+
+	parsedLabels, parsedTaints, err := ParseNodeVars(vars)
+	c.Assert(err, check.IsNil)
+	c.Assert(parsedLabels, compare.DeepEquals, labels)
+	c.Assert(parsedTaints, compare.DeepEquals, []v1.Taint{
+		{Key: "dedicated", Value: "db", Effect: v1.TaintEffectNoSchedule},
+	})
+
+	parsedLabels, parsedTaints, err = ParseNodeVars(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(parsedLabels, check.IsNil)
+	c.Assert(parsedTaints, check.IsNil)
+}
+
+func (s *AgentReportSuite) TestSetsNodeMetadata(c *check.C) {
+	var server storage.Server
+	err := SetNodeMetadata(&server, map[string]string{
+		AgentAvailabilityZone: "us-east-1a",
+		AgentMachineSerial:    "VMware-42 1d",
+		AgentJoinFlags:        `["join","10.0.0.1","--token=<redacted>"]`,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(server, compare.DeepEquals, storage.Server{
+		AvailabilityZone: "us-east-1a",
+		MachineSerial:    "VMware-42 1d",
+		JoinFlags:        []string{"join", "10.0.0.1", "--token=<redacted>"},
+	})
+
+	err = SetNodeMetadata(&server, map[string]string{AgentJoinFlags: "join"})
+	c.Assert(err, check.NotNil)
+}
+
+func (s *AgentReportSuite) TestValidatesNodeVars(c *check.C) {
+	c.Assert(CheckNodeLabel("invalid key", "value"), check.NotNil)
+	c.Assert(CheckNodeLabel("key", "invalid value"), check.NotNil)
+	_, err := ParseNodeTaints([]string{"dedicated=db:Unknown"})
+	c.Assert(err, check.NotNil)
+	_, err = ParseNodeTaints([]string{"dedicated-"})
+	c.Assert(err, check.NotNil)
+}
+
 func serverInfo(addr, role string) checks.ServerInfo {
 	return checks.ServerInfo{
 		RuntimeConfig: proto.RuntimeConfig{
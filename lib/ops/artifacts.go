@@ -0,0 +1,36 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import "fmt"
+
+const (
+	// OperationArtifactOperation is the name of the operation record in the artifacts tarball
+	OperationArtifactOperation = "operation.json"
+	// OperationArtifactPlan is the name of the operation plan in the artifacts tarball
+	OperationArtifactPlan = "plan.json"
+	// OperationArtifactProgress is the name of the last progress entry in the artifacts tarball
+	OperationArtifactProgress = "progress.json"
+	// OperationArtifactLog is the name of the operation log in the artifacts tarball
+	OperationArtifactLog = "operation.log"
+)
+
+// OperationArtifactsTarball returns the file name of the artifacts tarball
+// for the operation with the specified ID
+func OperationArtifactsTarball(operationID string) string {
+	return fmt.Sprintf("operation-%v.tar.gz", operationID)
+}
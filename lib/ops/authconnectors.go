@@ -0,0 +1,246 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+)
+
+// AuthConnectors defines the interface to manage external SSO connectors
+// (OIDC and SAML) of the cluster.
+//
+// The connectors are stored in the cluster backend shared with the embedded
+// Teleport auth server which picks them up for single sign-on
+type AuthConnectors interface {
+	// UpsertAuthConnector creates or updates an OIDC or SAML connector
+	UpsertAuthConnector(ctx context.Context, key SiteKey, connector teleservices.Resource) error
+	// GetAuthConnector returns the connector of the specified kind by name
+	GetAuthConnector(key SiteKey, kind, name string, withSecrets bool) (teleservices.Resource, error)
+	// GetAuthConnectors returns all connectors of the specified kind
+	GetAuthConnectors(key SiteKey, kind string, withSecrets bool) ([]teleservices.Resource, error)
+	// DeleteAuthConnector deletes the connector of the specified kind by name
+	DeleteAuthConnector(ctx context.Context, key SiteKey, kind, name string) error
+}
+
+// CheckAuthConnectorKind makes sure the kind is a kind of auth connector
+// managed with the AuthConnectors interface
+func CheckAuthConnectorKind(kind string) error {
+	if !utils.StringInSlice(AuthConnectorKinds, kind) {
+		return trace.BadParameter("unsupported auth connector kind %q, supported kinds are: %v",
+			kind, strings.Join(AuthConnectorKinds, ", "))
+	}
+	return nil
+}
+
+// AuthConnectorKind returns the kind of the specified OIDC or SAML connector
+func AuthConnectorKind(connector teleservices.Resource) string {
+	switch connector.(type) {
+	case teleservices.OIDCConnector:
+		return teleservices.KindOIDCConnector
+	case teleservices.SAMLConnector:
+		return teleservices.KindSAMLConnector
+	}
+	return ""
+}
+
+// MarshalAuthConnector marshals the OIDC or SAML connector to JSON
+func MarshalAuthConnector(connector teleservices.Resource) ([]byte, error) {
+	switch c := connector.(type) {
+	case teleservices.OIDCConnector:
+		return teleservices.GetOIDCConnectorMarshaler().MarshalOIDCConnector(c)
+	case teleservices.SAMLConnector:
+		return teleservices.GetSAMLConnectorMarshaler().MarshalSAMLConnector(c)
+	}
+	return nil, trace.BadParameter("unsupported auth connector %T", connector)
+}
+
+// UnmarshalAuthConnector unmarshals the OIDC or SAML connector
+// depending on the kind specified in data
+func UnmarshalAuthConnector(data []byte) (teleservices.Resource, error) {
+	var header teleservices.ResourceHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch header.Kind {
+	case teleservices.KindOIDCConnector:
+		return teleservices.GetOIDCConnectorMarshaler().UnmarshalOIDCConnector(data)
+	case teleservices.KindSAMLConnector:
+		return teleservices.GetSAMLConnectorMarshaler().UnmarshalSAMLConnector(data)
+	}
+	return nil, trace.Wrap(CheckAuthConnectorKind(header.Kind))
+}
+
+// AuthConnectorWithoutSecrets returns a copy of the specified connector
+// with the client secret and the signing key removed
+func AuthConnectorWithoutSecrets(connector teleservices.Resource) (teleservices.Resource, error) {
+	data, err := MarshalAuthConnector(connector)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	result, err := UnmarshalAuthConnector(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch c := result.(type) {
+	case teleservices.OIDCConnector:
+		c.SetClientSecret("")
+	case teleservices.SAMLConnector:
+		if keyPair := c.GetSigningKeyPair(); keyPair != nil {
+			c.SetSigningKeyPair(&teleservices.SigningKeyPair{Cert: keyPair.Cert})
+		}
+	}
+	return result, nil
+}
+
+// CheckAuthConnector validates the OIDC or SAML connector.
+//
+// Besides the checks done by Teleport, it makes sure the URLs the identity
+// provider redirects to are absolute HTTPS URLs pointing to the cluster
+// callback endpoints and that the certificates are valid
+func CheckAuthConnector(connector teleservices.Resource) error {
+	switch c := connector.(type) {
+	case teleservices.OIDCConnector:
+		return trace.Wrap(checkOIDCConnector(c))
+	case teleservices.SAMLConnector:
+		return trace.Wrap(checkSAMLConnector(c))
+	}
+	return trace.BadParameter("unsupported auth connector %T", connector)
+}
+
+func checkOIDCConnector(connector teleservices.OIDCConnector) error {
+	if err := connector.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := checkHTTPSURL("issuer_url", connector.GetIssuerURL()); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(checkCallbackURL("redirect_url", connector.GetRedirectURL(), OIDCCallbackPath))
+}
+
+func checkSAMLConnector(connector teleservices.SAMLConnector) error {
+	if connector.GetName() == "" {
+		return trace.BadParameter("missing connector name")
+	}
+	err := checkCallbackURL("acs", connector.GetAssertionConsumerService(), SAMLCallbackPath)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if connector.GetEntityDescriptor() == "" && connector.GetEntityDescriptorURL() == "" && connector.GetSSO() == "" {
+		return trace.BadParameter("either entity_descriptor, entity_descriptor_url " +
+			"or sso with cert must be set")
+	}
+	if descriptorURL := connector.GetEntityDescriptorURL(); descriptorURL != "" {
+		if err := checkHTTPSURL("entity_descriptor_url", descriptorURL); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if sso := connector.GetSSO(); sso != "" {
+		if err := checkHTTPSURL("sso", sso); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if cert := connector.GetCert(); cert != "" {
+		if err := checkCertificateBundle("cert", cert); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if keyPair := connector.GetSigningKeyPair(); keyPair != nil && keyPair.PrivateKey != "" {
+		if _, err := tls.X509KeyPair([]byte(keyPair.Cert), []byte(keyPair.PrivateKey)); err != nil {
+			return trace.BadParameter("signing_key_pair: invalid certificate or private key: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkCallbackURL makes sure that rawURL is an absolute HTTPS URL
+// of the specified cluster callback endpoint
+func checkCallbackURL(field, rawURL, callbackPath string) error {
+	if err := checkHTTPSURL(field, rawURL); err != nil {
+		return trace.Wrap(err)
+	}
+	u, _ := url.Parse(rawURL)
+	if !strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), callbackPath) {
+		return trace.BadParameter("%v: %q should point to the cluster callback endpoint, "+
+			"e.g. https://<cluster-address>%v", field, rawURL, callbackPath)
+	}
+	return nil
+}
+
+// checkHTTPSURL makes sure that rawURL is an absolute HTTPS URL
+func checkHTTPSURL(field, rawURL string) error {
+	if rawURL == "" {
+		return trace.BadParameter("%v: missing URL", field)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return trace.BadParameter("%v: invalid URL %q: %v", field, rawURL, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return trace.BadParameter("%v: %q should be an absolute https:// URL", field, rawURL)
+	}
+	return nil
+}
+
+// checkCertificateBundle makes sure that data is a PEM-encoded bundle
+// of one or more valid X.509 certificates
+func checkCertificateBundle(field, data string) error {
+	rest := []byte(data)
+	var count int
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return trace.BadParameter("%v: unexpected PEM block %q, expected CERTIFICATE", field, block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return trace.BadParameter("%v: invalid certificate: %v", field, err)
+		}
+		count++
+	}
+	if count == 0 || len(strings.TrimSpace(string(rest))) != 0 {
+		return trace.BadParameter("%v: expected PEM-encoded certificates", field)
+	}
+	return nil
+}
+
+// AuthConnectorKinds lists the kinds of auth connectors managed
+// with the AuthConnectors interface
+var AuthConnectorKinds = []string{
+	teleservices.KindOIDCConnector,
+	teleservices.KindSAMLConnector,
+}
+
+const (
+	// OIDCCallbackPath is the path of the cluster OIDC callback endpoint
+	OIDCCallbackPath = "/portalapi/v1/oidc/callback"
+	// SAMLCallbackPath is the path of the cluster SAML assertion consumer service
+	SAMLCallbackPath = "/portalapi/v1/saml/callback"
+)
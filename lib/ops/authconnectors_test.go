@@ -0,0 +1,166 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"crypto/x509/pkix"
+	"time"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+type AuthConnectorsSuite struct{}
+
+var _ = check.Suite(&AuthConnectorsSuite{})
+
+func (s *AuthConnectorsSuite) TestCheckOIDCConnector(c *check.C) {
+	testCases := []struct {
+		issuerURL   string
+		redirectURL string
+		valid       bool
+		comment     string
+	}{
+		{
+			issuerURL:   "https://accounts.example.com",
+			redirectURL: "https://cluster.example.com/portalapi/v1/oidc/callback",
+			valid:       true,
+			comment:     "valid connector",
+		},
+		{
+			issuerURL:   "http://accounts.example.com",
+			redirectURL: "https://cluster.example.com/portalapi/v1/oidc/callback",
+			comment:     "issuer is not https",
+		},
+		{
+			issuerURL:   "https://accounts.example.com",
+			redirectURL: "https://cluster.example.com/v1/webapi/oidc/callback",
+			comment:     "redirect URL does not point to the cluster callback",
+		},
+		{
+			issuerURL:   "https://accounts.example.com",
+			redirectURL: "/portalapi/v1/oidc/callback",
+			comment:     "redirect URL is not absolute",
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		connector := teleservices.NewOIDCConnector("example", teleservices.OIDCConnectorSpecV2{
+			IssuerURL:    tc.issuerURL,
+			ClientID:     "id",
+			ClientSecret: "secret",
+			RedirectURL:  tc.redirectURL,
+		})
+		err := CheckAuthConnector(connector)
+		if tc.valid {
+			c.Assert(err, check.IsNil, comment)
+		} else {
+			c.Assert(trace.IsBadParameter(err), check.Equals, true, comment)
+		}
+	}
+}
+
+func (s *AuthConnectorsSuite) TestCheckSAMLConnector(c *check.C) {
+	keyPEM, certPEM, err := teleutils.GenerateSelfSignedSigningCert(pkix.Name{CommonName: "example"}, nil, time.Hour)
+	c.Assert(err, check.IsNil)
+	_, otherCertPEM, err := teleutils.GenerateSelfSignedSigningCert(pkix.Name{CommonName: "other"}, nil, time.Hour)
+	c.Assert(err, check.IsNil)
+
+	acs := "https://cluster.example.com/portalapi/v1/saml/callback"
+	testCases := []struct {
+		spec    teleservices.SAMLConnectorSpecV2
+		valid   bool
+		comment string
+	}{
+		{
+			spec:    teleservices.SAMLConnectorSpecV2{AssertionConsumerService: acs, SSO: "https://idp.example.com/sso", Cert: string(certPEM)},
+			valid:   true,
+			comment: "valid connector",
+		},
+		{
+			spec:    teleservices.SAMLConnectorSpecV2{AssertionConsumerService: acs, EntityDescriptorURL: "https://idp.example.com/metadata"},
+			valid:   true,
+			comment: "valid connector with entity descriptor URL",
+		},
+		{
+			spec:    teleservices.SAMLConnectorSpecV2{AssertionConsumerService: "https://cluster.example.com/acs", SSO: "https://idp.example.com/sso"},
+			comment: "acs does not point to the cluster callback",
+		},
+		{
+			spec:    teleservices.SAMLConnectorSpecV2{AssertionConsumerService: acs},
+			comment: "no identity provider",
+		},
+		{
+			spec:    teleservices.SAMLConnectorSpecV2{AssertionConsumerService: acs, EntityDescriptorURL: "http://idp.example.com/metadata"},
+			comment: "entity descriptor URL is not https",
+		},
+		{
+			spec:    teleservices.SAMLConnectorSpecV2{AssertionConsumerService: acs, SSO: "https://idp.example.com/sso", Cert: "not a certificate"},
+			comment: "invalid certificate",
+		},
+		{
+			spec:    teleservices.SAMLConnectorSpecV2{AssertionConsumerService: acs, SSO: "https://idp.example.com/sso", Cert: string(certPEM) + string(keyPEM)},
+			comment: "private key in certificate bundle",
+		},
+		{
+			spec: teleservices.SAMLConnectorSpecV2{
+				AssertionConsumerService: acs,
+				SSO:                      "https://idp.example.com/sso",
+				SigningKeyPair:           &teleservices.SigningKeyPair{Cert: string(otherCertPEM), PrivateKey: string(keyPEM)},
+			},
+			comment: "mismatched signing key pair",
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		err := CheckAuthConnector(teleservices.NewSAMLConnector("example", tc.spec))
+		if tc.valid {
+			c.Assert(err, check.IsNil, comment)
+		} else {
+			c.Assert(trace.IsBadParameter(err), check.Equals, true, comment)
+		}
+	}
+}
+
+func (s *AuthConnectorsSuite) TestWithoutSecrets(c *check.C) {
+	oidc := teleservices.NewOIDCConnector("example", teleservices.OIDCConnectorSpecV2{
+		IssuerURL:    "https://accounts.example.com",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RedirectURL:  "https://cluster.example.com/portalapi/v1/oidc/callback",
+	})
+	result, err := AuthConnectorWithoutSecrets(oidc)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.(teleservices.OIDCConnector).GetClientSecret(), check.Equals, "")
+	c.Assert(oidc.GetClientSecret(), check.Equals, "secret")
+
+	saml := teleservices.NewSAMLConnector("example", teleservices.SAMLConnectorSpecV2{
+		AssertionConsumerService: "https://cluster.example.com/portalapi/v1/saml/callback",
+		SSO:                      "https://idp.example.com/sso",
+		SigningKeyPair:           &teleservices.SigningKeyPair{Cert: "cert", PrivateKey: "key"},
+		AttributesToRoles: []teleservices.AttributeMapping{
+			{Name: "groups", Value: "admins", Roles: []string{"@teleadmin"}},
+		},
+	})
+	result, err = AuthConnectorWithoutSecrets(saml)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.(teleservices.SAMLConnector).GetSigningKeyPair(), check.DeepEquals,
+		&teleservices.SigningKeyPair{Cert: "cert"})
+	c.Assert(AuthConnectorKind(result), check.Equals, teleservices.KindSAMLConnector)
+}
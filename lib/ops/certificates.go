@@ -0,0 +1,118 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+
+	cfsslhelpers "github.com/cloudflare/cfssl/helpers"
+	"github.com/gravitational/trace"
+)
+
+// RotatedCertificateNames lists the node certificates renewed by
+// certificate rotation. The certificates are stored in the node secrets
+// directory as <name>.cert along with their private keys as <name>.key
+var RotatedCertificateNames = []string{
+	constants.APIServerKeyPair,
+	constants.ETCDKeyPair,
+	constants.KubeletKeyPair,
+	constants.ProxyKeyPair,
+	constants.SchedulerKeyPair,
+	constants.KubectlKeyPair,
+}
+
+// NodeCertificate describes a certificate installed on a cluster node
+type NodeCertificate struct {
+	// Name is the certificate name, e.g. "etcd"
+	Name string `json:"name"`
+	// Subject is the certificate subject common name
+	Subject string `json:"subject"`
+	// Issuer is the certificate issuer common name
+	Issuer string `json:"issuer"`
+	// IsCA is whether this is the certificate authority certificate
+	IsCA bool `json:"is_ca,omitempty"`
+	// NotAfter is the certificate expiration time
+	NotAfter time.Time `json:"not_after"`
+}
+
+// NodeCertificates lists the certificates installed on a cluster node
+type NodeCertificates struct {
+	// Hostname is the node hostname
+	Hostname string `json:"hostname"`
+	// AdvertiseIP is the node advertise IP address
+	AdvertiseIP string `json:"advertise_ip"`
+	// Certificates lists the node certificates
+	Certificates []NodeCertificate `json:"certificates,omitempty"`
+	// Error is the error encountered while collecting the node certificates
+	Error string `json:"error,omitempty"`
+}
+
+// Expiring returns the rotated certificates of the node that
+// expire before the specified deadline
+func (r NodeCertificates) Expiring(deadline time.Time) (expiring []NodeCertificate) {
+	for _, cert := range r.Certificates {
+		if cert.IsCA || !cert.NotAfter.Before(deadline) {
+			continue
+		}
+		expiring = append(expiring, cert)
+	}
+	return expiring
+}
+
+// ReadNodeCertificates reads the certificate authority and the rotated
+// certificates from the specified secrets directory.
+// Certificates that are not present on the node are skipped
+func ReadNodeCertificates(secretsDir string) ([]NodeCertificate, error) {
+	names := append([]string{constants.RootKeyPair}, RotatedCertificateNames...)
+	var certs []NodeCertificate
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(secretsDir, name+".cert"))
+		if err != nil {
+			err = trace.ConvertSystemError(err)
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		// certificates issued by a custom certificate authority
+		// are followed by the chain of its issuers
+		chain, err := cfsslhelpers.ParseCertificatesPEM(data)
+		if err != nil {
+			return nil, trace.BadParameter("failed to parse certificate %v: %v", name, err)
+		}
+		if len(chain) == 0 {
+			return nil, trace.BadParameter("no certificates found in %v", name)
+		}
+		cert := chain[0]
+		certs = append(certs, NodeCertificate{
+			Name:     name,
+			Subject:  cert.Subject.CommonName,
+			Issuer:   cert.Issuer.CommonName,
+			IsCA:     cert.IsCA,
+			NotAfter: cert.NotAfter.UTC(),
+		})
+	}
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].NotAfter.Before(certs[j].NotAfter)
+	})
+	return certs, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/gravitational/license/authority"
+	check "gopkg.in/check.v1"
+)
+
+type CertificatesSuite struct{}
+
+var _ = check.Suite(&CertificatesSuite{})
+
+func (s *CertificatesSuite) TestReadsNodeCertificates(c *check.C) {
+	dir := c.MkDir()
+	ca, err := authority.GenerateSelfSignedCA(csr.CertificateRequest{CN: "cluster-ca"})
+	c.Assert(err, check.IsNil)
+	writeCert(c, dir, constants.RootKeyPair, ca.CertPEM)
+	etcd, err := authority.GenerateCertificate(csr.CertificateRequest{CN: "etcd"}, ca, ca.KeyPEM, 24*time.Hour)
+	c.Assert(err, check.IsNil)
+	// certificates issued by a custom certificate authority include its chain
+	writeCert(c, dir, constants.ETCDKeyPair, append(etcd.CertPEM, ca.CertPEM...))
+	kubelet, err := authority.GenerateCertificate(csr.CertificateRequest{CN: "kubelet"}, ca, ca.KeyPEM, 48*time.Hour)
+	c.Assert(err, check.IsNil)
+	writeCert(c, dir, constants.KubeletKeyPair, kubelet.CertPEM)
+	// only the known certificates are read
+	writeCert(c, dir, "other", kubelet.CertPEM)
+
+	certs, err := ReadNodeCertificates(dir)
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, cert := range certs {
+		names = append(names, cert.Name)
+	}
+	c.Assert(names, check.DeepEquals, []string{constants.ETCDKeyPair, constants.KubeletKeyPair, constants.RootKeyPair})
+	c.Assert(certs[0].Subject, check.Equals, "etcd")
+	c.Assert(certs[0].Issuer, check.Equals, "cluster-ca")
+	c.Assert(certs[2].IsCA, check.Equals, true)
+
+	node := NodeCertificates{Certificates: certs}
+	expiring := node.Expiring(time.Now().Add(36 * time.Hour))
+	c.Assert(expiring, check.HasLen, 1)
+	c.Assert(expiring[0].Name, check.Equals, constants.ETCDKeyPair)
+	c.Assert(node.Expiring(time.Now()), check.HasLen, 0)
+}
+
+func writeCert(c *check.C, dir, name string, data []byte) {
+	err := ioutil.WriteFile(filepath.Join(dir, name+".cert"), data, 0644)
+	c.Assert(err, check.IsNil)
+}
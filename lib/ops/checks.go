@@ -69,6 +69,25 @@ func CheckServers(ctx context.Context,
 	return trace.Wrap(c.Run(ctx))
 }
 
+// DetectPathMTU returns the smallest path MTU between the specified servers
+// of the operation given with opKey
+func DetectPathMTU(ctx context.Context,
+	opKey SiteOperationKey,
+	infos checks.ServerInfos,
+	servers []storage.Server,
+	agentService AgentService,
+) (int, error) {
+	nodes, err := mergeServers(infos, servers)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	mtu, err := checks.DetectPathMTU(ctx, &remoteCommands{key: opKey, AgentService: agentService}, nodes)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	return mtu, nil
+}
+
 // FormatValidationError formats validation error as a human-readable text
 func FormatValidationError(err error) error {
 	errors := []error{err}
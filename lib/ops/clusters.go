@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/storage"
+)
+
+// ClusterSummary is a short description of the cluster state used to manage
+// multiple clusters connected to a Gravity Hub
+type ClusterSummary struct {
+	// Name is the cluster name
+	Name string `json:"name"`
+	// State is the cluster state
+	State string `json:"state"`
+	// Reason is the code describing the state the cluster is in
+	Reason storage.Reason `json:"reason,omitempty"`
+	// Application is the cluster application package
+	Application loc.Locator `json:"application"`
+	// Provider is the cluster provider
+	Provider string `json:"provider"`
+	// Labels is the cluster labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// Local is true if this is the cluster the Hub is running in
+	Local bool `json:"local"`
+	// Online is true if the cluster is connected to the Hub
+	Online bool `json:"online"`
+	// LastConnected is the time the cluster was last seen connected to the Hub
+	LastConnected time.Time `json:"last_connected,omitempty"`
+	// Nodes lists the cluster nodes.
+	// Only populated for the status of a single cluster
+	Nodes []Node `json:"nodes,omitempty"`
+	// ActiveOperations lists operations currently in progress.
+	// Only populated for the status of a single cluster
+	ActiveOperations []SiteOperation `json:"active_operations,omitempty"`
+	// LastOperation is the most recent completed operation.
+	// Only populated for the status of a single cluster
+	LastOperation *SiteOperation `json:"last_operation,omitempty"`
+}
+
+// NewClusterSummary returns a summary for the specified cluster
+func NewClusterSummary(cluster Site) ClusterSummary {
+	return ClusterSummary{
+		Name:        cluster.Domain,
+		State:       cluster.State,
+		Reason:      cluster.Reason,
+		Application: cluster.App.Package,
+		Provider:    cluster.Provider,
+		Labels:      cluster.Labels,
+		Local:       cluster.Local,
+		Online:      cluster.Local,
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/utils"
+)
+
+// concurrentOperations is the mutual exclusion matrix of cluster operations.
+// For each operation type, it lists the types of operations in progress the
+// operation may run concurrently with. All other operations are mutually exclusive
+var concurrentOperations = map[string][]string{
+	// several nodes can be joining at the same time, the expand-specific
+	// checks limit the number of concurrently joining nodes
+	OperationExpand: {OperationExpand},
+	// uninstall supersedes any operation in progress
+	OperationUninstall: {
+		OperationInstall,
+		OperationExpand,
+		OperationUpdate,
+		OperationShrink,
+		OperationGarbageCollect,
+		OperationUpdateRuntimeEnviron,
+		OperationUpdateConfig,
+		OperationPatch,
+		OperationUpdateBinary,
+		OperationImportMigration,
+		OperationRotateCertificates,
+		OperationEnableComponent,
+		OperationDisableComponent,
+	},
+}
+
+// queueableOperations lists the types of operations that wait for the
+// conflicting operations in progress to complete instead of being rejected.
+// Only the operations executed by the cluster controller can be queued
+// as the other operations are driven by the clients that started them
+var queueableOperations = []string{
+	OperationShrink,
+}
+
+// CanRunConcurrently returns true if the operation of the specified type
+// may run while an operation of the active type is in progress
+func CanRunConcurrently(operationType, activeType string) bool {
+	return utils.StringInSlice(concurrentOperations[operationType], activeType)
+}
+
+// IsQueueable returns true if the operation of the specified type
+// can be queued behind the conflicting operations in progress
+func IsQueueable(operationType string) bool {
+	return utils.StringInSlice(queueableOperations, operationType)
+}
+
+// ConflictingOperations returns the operations from the provided list the
+// specified operation cannot run concurrently with. Finished and queued
+// operations do not conflict with other operations
+func ConflictingOperations(operation SiteOperation, operations []SiteOperation) (conflicts []SiteOperation) {
+	for _, op := range operations {
+		if op.ID == operation.ID || op.IsFinished() || op.IsQueued() {
+			continue
+		}
+		if !CanRunConcurrently(operation.Type, op.Type) {
+			conflicts = append(conflicts, op)
+		}
+	}
+	return conflicts
+}
+
+// FormatOperations returns a textual representation of the specified
+// operations for error messages
+func FormatOperations(operations []SiteOperation) string {
+	var formatted []string
+	for _, op := range operations {
+		formatted = append(formatted, fmt.Sprintf("%v (%v)", op.TypeString(), op.ID))
+	}
+	return strings.Join(formatted, ", ")
+}
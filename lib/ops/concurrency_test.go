@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	check "gopkg.in/check.v1"
+)
+
+type ConcurrencySuite struct{}
+
+var _ = check.Suite(&ConcurrencySuite{})
+
+func (s *ConcurrencySuite) TestConcurrencyMatrix(c *check.C) {
+	c.Assert(CanRunConcurrently(OperationExpand, OperationExpand), check.Equals, true)
+	c.Assert(CanRunConcurrently(OperationExpand, OperationUpdate), check.Equals, false)
+	c.Assert(CanRunConcurrently(OperationUpdate, OperationExpand), check.Equals, false)
+	c.Assert(CanRunConcurrently(OperationShrink, OperationShrink), check.Equals, false)
+	c.Assert(CanRunConcurrently(OperationUninstall, OperationUpdate), check.Equals, true)
+	c.Assert(IsQueueable(OperationShrink), check.Equals, true)
+	c.Assert(IsQueueable(OperationUpdate), check.Equals, false)
+}
+
+func (s *ConcurrencySuite) TestFindsConflictingOperations(c *check.C) {
+	operation := SiteOperation{ID: "4", Type: OperationShrink, State: OperationStateQueued}
+	operations := []SiteOperation{
+		operation,
+		{ID: "3", Type: OperationShrink, State: OperationStateQueued},
+		{ID: "2", Type: OperationUpdate, State: OperationStateUpdateInProgress},
+		{ID: "1", Type: OperationExpand, State: OperationStateCompleted},
+	}
+	conflicts := ConflictingOperations(operation, operations)
+	c.Assert(conflicts, check.HasLen, 1)
+	c.Assert(conflicts[0].ID, check.Equals, "2")
+	c.Assert(FormatOperations(conflicts), check.Equals, "update (2)")
+}
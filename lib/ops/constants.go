@@ -85,6 +85,12 @@ const (
 	SiteStateUpdatingEnviron = "updating_cluster_environ"
 	// SiteStateUpdatingConfig is the state of the cluster when it's updating configuration
 	SiteStateUpdatingConfig = "updating_cluster_config"
+	// SiteStatePatching is the state of the cluster when it's patching the node operating systems
+	SiteStatePatching = "patching"
+	// SiteStateUpdatingBinary is the state of the cluster when it's replacing the gravity binary on nodes
+	SiteStateUpdatingBinary = "updating_binary"
+	// SiteStateRotatingCertificates is the state of the cluster when it's rotating the node certificates
+	SiteStateRotatingCertificates = "rotating_certificates"
 	// SiteStateDegraded means that the application installed on a deployed site is failing its health check
 	SiteStateDegraded = "degraded"
 	// SiteStateOffline means that OpsCenter cannot connect to remote site
@@ -132,9 +138,36 @@ const (
 	OperationUpdateConfig           = "operation_update_config"
 	OperationUpdateConfigInProgress = "update_config_in_progress"
 
+	// rolling node operating system patch operation
+	OperationPatch           = "operation_patch"
+	OperationPatchInProgress = "patch_in_progress"
+
+	// gravity binary update operation
+	OperationUpdateBinary           = "operation_update_binary"
+	OperationUpdateBinaryInProgress = "update_binary_in_progress"
+
+	// rolling node certificate rotation operation
+	OperationRotateCertificates           = "operation_rotate_certs"
+	OperationRotateCertificatesInProgress = "rotate_certs_in_progress"
+
+	// import of the application data migrated from another cluster
+	OperationImportMigration           = "operation_import_migration"
+	OperationImportMigrationInProgress = "import_migration_in_progress"
+
+	// installation of an optional application component
+	OperationEnableComponent           = "operation_enable_component"
+	OperationEnableComponentInProgress = "enable_component_in_progress"
+
+	// removal of an optional application component
+	OperationDisableComponent           = "operation_disable_component"
+	OperationDisableComponentInProgress = "disable_component_in_progress"
+
 	// common operation states
 	OperationStateCompleted = "completed"
 	OperationStateFailed    = "failed"
+	// OperationStateQueued is the state of the operation waiting
+	// for the conflicting operations in progress to complete
+	OperationStateQueued = "queued"
 
 	// Teleport node labels
 	// AdvertiseIP defines a label with advertise IP address
@@ -181,6 +214,26 @@ const (
 	// a shrink operation
 	AgentModeShrink = "shrink"
 
+	// AgentNodeLabels specifies the comma-separated list of additional
+	// Kubernetes node labels in key=value format
+	AgentNodeLabels = "node-labels"
+
+	// AgentNodeTaints specifies the comma-separated list of additional
+	// Kubernetes node taints in key=value:effect format
+	AgentNodeTaints = "node-taints"
+
+	// AgentAvailabilityZone specifies the cloud availability zone
+	// of the agent's instance
+	AgentAvailabilityZone = "availability-zone"
+
+	// AgentMachineSerial specifies the serial number of the agent's machine
+	// as reported by the system firmware
+	AgentMachineSerial = "machine-serial"
+
+	// AgentJoinFlags specifies the JSON-encoded list of command line flags
+	// the node has been joined with
+	AgentJoinFlags = "join-flags"
+
 	// InstallToken names the query parameter with a one-time install token
 	InstallToken = "install_token"
 
@@ -200,6 +253,12 @@ var (
 		OperationGarbageCollect:       SiteStateGarbageCollecting,
 		OperationUpdateRuntimeEnviron: SiteStateUpdatingEnviron,
 		OperationUpdateConfig:         SiteStateUpdatingConfig,
+		OperationPatch:                SiteStatePatching,
+		OperationUpdateBinary:         SiteStateUpdatingBinary,
+		OperationRotateCertificates:   SiteStateRotatingCertificates,
+		OperationImportMigration:      SiteStateActive,
+		OperationEnableComponent:      SiteStateActive,
+		OperationDisableComponent:     SiteStateActive,
 	}
 
 	// OperationSucceededToClusterState defines states the cluster transitions
@@ -213,6 +272,12 @@ var (
 		OperationGarbageCollect:       SiteStateActive,
 		OperationUpdateRuntimeEnviron: SiteStateActive,
 		OperationUpdateConfig:         SiteStateActive,
+		OperationPatch:                SiteStateActive,
+		OperationUpdateBinary:         SiteStateActive,
+		OperationRotateCertificates:   SiteStateActive,
+		OperationImportMigration:      SiteStateActive,
+		OperationEnableComponent:      SiteStateActive,
+		OperationDisableComponent:     SiteStateActive,
 	}
 
 	// OperationFailedToClusterState defines states the cluster transitions
@@ -228,5 +293,11 @@ var (
 		OperationGarbageCollect:       SiteStateActive,
 		OperationUpdateRuntimeEnviron: SiteStateUpdatingEnviron,
 		OperationUpdateConfig:         SiteStateUpdatingConfig,
+		OperationPatch:                SiteStatePatching,
+		OperationUpdateBinary:         SiteStateUpdatingBinary,
+		OperationRotateCertificates:   SiteStateActive,
+		OperationImportMigration:      SiteStateActive,
+		OperationEnableComponent:      SiteStateActive,
+		OperationDisableComponent:     SiteStateActive,
 	}
 )
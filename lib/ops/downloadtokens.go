@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+// DownloadTokens defines the interface to manage time-limited URLs
+// for downloading application installers without an account
+type DownloadTokens interface {
+	// CreateDownloadToken creates a new token granting access
+	// to download the installer of the specified application
+	CreateDownloadToken(context.Context, CreateDownloadTokenRequest) (*storage.DownloadToken, error)
+	// GetDownloadTokens returns active download tokens of the specified account
+	GetDownloadTokens(ctx context.Context, accountID string) ([]storage.DownloadToken, error)
+	// DeleteDownloadToken deletes the specified download token
+	DeleteDownloadToken(context.Context, DeleteDownloadTokenRequest) error
+}
+
+// CreateDownloadTokenRequest is a request to create an application download token
+type CreateDownloadTokenRequest struct {
+	// AccountID is the ID of the account to create the token in
+	AccountID string `json:"account_id"`
+	// Application is the application to grant access to
+	Application loc.Locator `json:"application"`
+	// TTL specifies how long the token is valid for
+	TTL time.Duration `json:"ttl"`
+}
+
+// CheckAndSetDefaults validates the request and sets defaults
+func (r *CreateDownloadTokenRequest) CheckAndSetDefaults() error {
+	if r.AccountID == "" {
+		return trace.BadParameter("missing account ID")
+	}
+	if r.Application.IsEmpty() {
+		return trace.BadParameter("missing application")
+	}
+	if r.TTL < 0 {
+		return trace.BadParameter("ttl can't be negative")
+	}
+	if r.TTL == 0 {
+		r.TTL = defaults.DownloadTokenTTL
+	}
+	if r.TTL > defaults.MaxDownloadTokenTTL {
+		return trace.BadParameter("ttl can't be longer than %v", defaults.MaxDownloadTokenTTL)
+	}
+	return nil
+}
+
+// DeleteDownloadTokenRequest is a request to delete an application download token
+type DeleteDownloadTokenRequest struct {
+	// AccountID is the ID of the account the token belongs to
+	AccountID string `json:"account_id"`
+	// Token is the token to delete
+	Token string `json:"token"`
+}
+
+// Check validates the request
+func (r DeleteDownloadTokenRequest) Check() error {
+	if r.AccountID == "" {
+		return trace.BadParameter("missing account ID")
+	}
+	if r.Token == "" {
+		return trace.BadParameter("missing token")
+	}
+	return nil
+}
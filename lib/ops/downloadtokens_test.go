@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+type DownloadTokensSuite struct{}
+
+var _ = check.Suite(&DownloadTokensSuite{})
+
+func (s *DownloadTokensSuite) TestValidatesCreateRequest(c *check.C) {
+	app := loc.MustParseLocator("example.com/app:1.0.0")
+	testCases := []struct {
+		comment string
+		req     CreateDownloadTokenRequest
+		ttl     time.Duration
+		err     bool
+	}{
+		{
+			comment: "default TTL",
+			req:     CreateDownloadTokenRequest{AccountID: "account", Application: app},
+			ttl:     defaults.DownloadTokenTTL,
+		},
+		{
+			comment: "explicit TTL",
+			req:     CreateDownloadTokenRequest{AccountID: "account", Application: app, TTL: time.Hour},
+			ttl:     time.Hour,
+		},
+		{
+			comment: "TTL above maximum",
+			req: CreateDownloadTokenRequest{AccountID: "account", Application: app,
+				TTL: defaults.MaxDownloadTokenTTL + time.Hour},
+			err: true,
+		},
+		{
+			comment: "missing application",
+			req:     CreateDownloadTokenRequest{AccountID: "account"},
+			err:     true,
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		err := tc.req.CheckAndSetDefaults()
+		if tc.err {
+			c.Assert(trace.IsBadParameter(err), check.Equals, true, comment)
+			continue
+		}
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(tc.req.TTL, check.Equals, tc.ttl, comment)
+	}
+}
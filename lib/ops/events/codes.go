@@ -139,6 +139,106 @@ var (
 		Name: OperationFailedEvent,
 		Code: OperationConfigFailureCode,
 	}
+	// OperationPatchStart is emitted when node operating system patching launches.
+	OperationPatchStart = events.Event{
+		Name: OperationStartedEvent,
+		Code: OperationPatchStartCode,
+	}
+	// OperationPatchComplete is emitted when node operating system patching successfully completes.
+	OperationPatchComplete = events.Event{
+		Name: OperationCompletedEvent,
+		Code: OperationPatchCompleteCode,
+	}
+	// OperationPatchFailure is emitted when node operating system patching fails.
+	OperationPatchFailure = events.Event{
+		Name: OperationFailedEvent,
+		Code: OperationPatchFailureCode,
+	}
+	// OperationRotateCertsStart is emitted when node certificate rotation launches.
+	OperationRotateCertsStart = events.Event{
+		Name: OperationStartedEvent,
+		Code: OperationRotateCertsStartCode,
+	}
+	// OperationRotateCertsComplete is emitted when node certificate rotation successfully completes.
+	OperationRotateCertsComplete = events.Event{
+		Name: OperationCompletedEvent,
+		Code: OperationRotateCertsCompleteCode,
+	}
+	// OperationRotateCertsFailure is emitted when node certificate rotation fails.
+	OperationRotateCertsFailure = events.Event{
+		Name: OperationFailedEvent,
+		Code: OperationRotateCertsFailureCode,
+	}
+	// OperationUpdateBinaryStart is emitted when gravity binary update launches.
+	OperationUpdateBinaryStart = events.Event{
+		Name: OperationStartedEvent,
+		Code: OperationUpdateBinaryStartCode,
+	}
+	// OperationUpdateBinaryComplete is emitted when gravity binary update successfully completes.
+	OperationUpdateBinaryComplete = events.Event{
+		Name: OperationCompletedEvent,
+		Code: OperationUpdateBinaryCompleteCode,
+	}
+	// OperationUpdateBinaryFailure is emitted when gravity binary update fails.
+	OperationUpdateBinaryFailure = events.Event{
+		Name: OperationFailedEvent,
+		Code: OperationUpdateBinaryFailureCode,
+	}
+	// OperationImportMigrationStart is emitted when the import of migrated application data launches.
+	OperationImportMigrationStart = events.Event{
+		Name: OperationStartedEvent,
+		Code: OperationImportMigrationStartCode,
+	}
+	// OperationImportMigrationComplete is emitted when the import of migrated application data successfully completes.
+	OperationImportMigrationComplete = events.Event{
+		Name: OperationCompletedEvent,
+		Code: OperationImportMigrationCompleteCode,
+	}
+	// OperationImportMigrationFailure is emitted when the import of migrated application data fails.
+	OperationImportMigrationFailure = events.Event{
+		Name: OperationFailedEvent,
+		Code: OperationImportMigrationFailureCode,
+	}
+	// OperationEnableComponentStart is emitted when the installation of an application component launches.
+	OperationEnableComponentStart = events.Event{
+		Name: OperationStartedEvent,
+		Code: OperationEnableComponentStartCode,
+	}
+	// OperationEnableComponentComplete is emitted when the installation of an application component successfully completes.
+	OperationEnableComponentComplete = events.Event{
+		Name: OperationCompletedEvent,
+		Code: OperationEnableComponentCompleteCode,
+	}
+	// OperationEnableComponentFailure is emitted when the installation of an application component fails.
+	OperationEnableComponentFailure = events.Event{
+		Name: OperationFailedEvent,
+		Code: OperationEnableComponentFailureCode,
+	}
+	// OperationDisableComponentStart is emitted when the removal of an application component launches.
+	OperationDisableComponentStart = events.Event{
+		Name: OperationStartedEvent,
+		Code: OperationDisableComponentStartCode,
+	}
+	// OperationDisableComponentComplete is emitted when the removal of an application component successfully completes.
+	OperationDisableComponentComplete = events.Event{
+		Name: OperationCompletedEvent,
+		Code: OperationDisableComponentCompleteCode,
+	}
+	// OperationDisableComponentFailure is emitted when the removal of an application component fails.
+	OperationDisableComponentFailure = events.Event{
+		Name: OperationFailedEvent,
+		Code: OperationDisableComponentFailureCode,
+	}
+	// OperationApprovalRequested is emitted when an operation requires approval by another user.
+	OperationApprovalRequested = events.Event{
+		Name: OperationApprovalRequestedEvent,
+		Code: OperationApprovalRequestedCode,
+	}
+	// OperationApproved is emitted when an operation is approved by another user.
+	OperationApproved = events.Event{
+		Name: OperationApprovedEvent,
+		Code: OperationApprovedCode,
+	}
 	// UserCreated is emitted when a user is created/updated.
 	UserCreated = events.Event{
 		Name: UserCreatedEvent,
@@ -234,6 +334,26 @@ var (
 		Name: InviteCreatedEvent,
 		Code: UserInviteCreatedCode,
 	}
+	// DownloadTokenCreated is emitted when an application download token is created.
+	DownloadTokenCreated = events.Event{
+		Name: DownloadTokenCreatedEvent,
+		Code: DownloadTokenCreatedCode,
+	}
+	// DownloadTokenDeleted is emitted when an application download token is deleted.
+	DownloadTokenDeleted = events.Event{
+		Name: DownloadTokenDeletedEvent,
+		Code: DownloadTokenDeletedCode,
+	}
+	// AuthConnectorCreated is emitted when an OIDC or SAML connector is created/updated.
+	AuthConnectorCreated = events.Event{
+		Name: AuthConnectorCreatedEvent,
+		Code: AuthConnectorCreatedCode,
+	}
+	// AuthConnectorDeleted is emitted when an OIDC or SAML connector is deleted.
+	AuthConnectorDeleted = events.Event{
+		Name: AuthConnectorDeletedEvent,
+		Code: AuthConnectorDeletedCode,
+	}
 	// ClusterUnhealthy is emitted when cluster becomes unhealthy.
 	ClusterUnhealthy = events.Event{
 		Name: ClusterDegradedEvent,
@@ -244,6 +364,16 @@ var (
 		Name: ClusterActivatedEvent,
 		Code: ClusterHealthyCode,
 	}
+	// NodeDegraded is emitted for every degraded node when cluster becomes unhealthy.
+	NodeDegraded = events.Event{
+		Name: NodeDegradedEvent,
+		Code: NodeDegradedCode,
+	}
+	// CertificatesRotated is emitted when node certificates are renewed.
+	CertificatesRotated = events.Event{
+		Name: CertificatesRotatedEvent,
+		Code: CertificatesRotatedCode,
+	}
 	// ApplicationInstall is emitted when a new application image is installed.
 	ApplicationInstall = events.Event{
 		Name: AppInstalledEvent,
@@ -264,6 +394,16 @@ var (
 		Name: AppUninstalledEvent,
 		Code: ApplicationUninstallCode,
 	}
+	// CommandExecuted is emitted when a mutating command line action succeeds.
+	CommandExecuted = events.Event{
+		Name: CommandExecutedEvent,
+		Code: CommandExecutedCode,
+	}
+	// CommandFailed is emitted when a mutating command line action fails.
+	CommandFailed = events.Event{
+		Name: CommandFailedEvent,
+		Code: CommandFailedCode,
+	}
 )
 
 // There is no strict algorithm for picking an event code, however existing
@@ -281,6 +421,8 @@ var (
 //    license expires, etc.) are in `3xxx` group.
 //
 //  * Application catalog related events are in `4xxx` group.
+//
+//  * Command line actions recorded in the audit log are in `5xxx` group.
 const (
 	// OpereationInstallStartCode is the install operation start event code.
 	OperationInstallStartCode = "G0001I"
@@ -330,6 +472,46 @@ const (
 	OperationConfigCompleteCode = "G0016I"
 	// OperationConfigFailureCode is the cluster configuration update operation failure event code.
 	OperationConfigFailureCode = "G0016E"
+	// OperationApprovalRequestedCode is the operation approval requested event code.
+	OperationApprovalRequestedCode = "G0017I"
+	// OperationApprovedCode is the operation approved event code.
+	OperationApprovedCode = "G0018I"
+	// OperationPatchStartCode is the node patch operation start event code.
+	OperationPatchStartCode = "G0019I"
+	// OperationPatchCompleteCode is the node patch operation complete event code.
+	OperationPatchCompleteCode = "G0020I"
+	// OperationPatchFailureCode is the node patch operation failure event code.
+	OperationPatchFailureCode = "G0020E"
+	// OperationRotateCertsStartCode is the node certificate rotation operation start event code.
+	OperationRotateCertsStartCode = "G0021I"
+	// OperationRotateCertsCompleteCode is the node certificate rotation operation complete event code.
+	OperationRotateCertsCompleteCode = "G0022I"
+	// OperationRotateCertsFailureCode is the node certificate rotation operation failure event code.
+	OperationRotateCertsFailureCode = "G0022E"
+	// OperationUpdateBinaryStartCode is the gravity binary update operation start event code.
+	OperationUpdateBinaryStartCode = "G0023I"
+	// OperationUpdateBinaryCompleteCode is the gravity binary update operation complete event code.
+	OperationUpdateBinaryCompleteCode = "G0024I"
+	// OperationUpdateBinaryFailureCode is the gravity binary update operation failure event code.
+	OperationUpdateBinaryFailureCode = "G0024E"
+	// OperationImportMigrationStartCode is the application data import operation start event code.
+	OperationImportMigrationStartCode = "G0025I"
+	// OperationImportMigrationCompleteCode is the application data import operation complete event code.
+	OperationImportMigrationCompleteCode = "G0026I"
+	// OperationImportMigrationFailureCode is the application data import operation failure event code.
+	OperationImportMigrationFailureCode = "G0026E"
+	// OperationEnableComponentStartCode is the application component installation operation start event code.
+	OperationEnableComponentStartCode = "G0027I"
+	// OperationEnableComponentCompleteCode is the application component installation operation complete event code.
+	OperationEnableComponentCompleteCode = "G0028I"
+	// OperationEnableComponentFailureCode is the application component installation operation failure event code.
+	OperationEnableComponentFailureCode = "G0028E"
+	// OperationDisableComponentStartCode is the application component removal operation start event code.
+	OperationDisableComponentStartCode = "G0029I"
+	// OperationDisableComponentCompleteCode is the application component removal operation complete event code.
+	OperationDisableComponentCompleteCode = "G0030I"
+	// OperationDisableComponentFailureCode is the application component removal operation failure event code.
+	OperationDisableComponentFailureCode = "G0030E"
 	// UserCreatedCode is the user created event code.
 	UserCreatedCode = "G1000I"
 	// UserDeletedCode is the user deleted event code.
@@ -368,10 +550,22 @@ const (
 	AuthGatewayUpdatedCode = "G1009I"
 	// UserInviteCreatedCode is the user invite created event code.
 	UserInviteCreatedCode = "G1010I"
+	// DownloadTokenCreatedCode is the application download token created event code.
+	DownloadTokenCreatedCode = "G1011I"
+	// DownloadTokenDeletedCode is the application download token deleted event code.
+	DownloadTokenDeletedCode = "G2011I"
+	// AuthConnectorCreatedCode is the OIDC or SAML connector created event code.
+	AuthConnectorCreatedCode = "G1012I"
+	// AuthConnectorDeletedCode is the OIDC or SAML connector deleted event code.
+	AuthConnectorDeletedCode = "G2012I"
 	// ClusterUnhealthyCode is the cluster goes unhealthy event code.
 	ClusterUnhealthyCode = "G3000W"
 	// ClusterHealthyCode is the cluster goes healthy event code.
 	ClusterHealthyCode = "G3001I"
+	// NodeDegradedCode is the node goes unhealthy event code.
+	NodeDegradedCode = "G3002W"
+	// CertificatesRotatedCode is the node certificates renewed event code.
+	CertificatesRotatedCode = "G3003I"
 	// ApplicationInstallCode is the application release install event code.
 	ApplicationInstallCode = "G4000I"
 	// ApplicationUpgradeCode is the application release upgrade event code.
@@ -380,6 +574,10 @@ const (
 	ApplicationRollbackCode = "G4002I"
 	// ApplicationUninstallCode is the application release uninstall event code.
 	ApplicationUninstallCode = "G4003I"
+	// CommandExecutedCode is the command line action success event code.
+	CommandExecutedCode = "G5000I"
+	// CommandFailedCode is the command line action failure event code.
+	CommandFailedCode = "G5000E"
 )
 
 const (
@@ -389,6 +587,10 @@ const (
 	OperationCompletedEvent = "operation.completed"
 	// OperationFailedEvent fires when an operation completes with error.
 	OperationFailedEvent = "operation.failed"
+	// OperationApprovalRequestedEvent fires when an operation requires approval.
+	OperationApprovalRequestedEvent = "operation.approval_requested"
+	// OperationApprovedEvent fires when an operation is approved.
+	OperationApprovedEvent = "operation.approved"
 
 	// AppInstalledEvent fires when an application image is installed.
 	AppInstalledEvent = "application.installed"
@@ -437,9 +639,26 @@ const (
 	AuthGatewayUpdatedEvent = "authgateway.updated"
 	// InviteCreatedEvent fires when a new user invitation is generated.
 	InviteCreatedEvent = "invite.created"
+	// DownloadTokenCreatedEvent fires when an application download token is created.
+	DownloadTokenCreatedEvent = "downloadtoken.created"
+	// DownloadTokenDeletedEvent fires when an application download token is deleted.
+	DownloadTokenDeletedEvent = "downloadtoken.deleted"
+	// AuthConnectorCreatedEvent fires when an OIDC or SAML connector is created/updated.
+	AuthConnectorCreatedEvent = "authconnector.created"
+	// AuthConnectorDeletedEvent fires when an OIDC or SAML connector is deleted.
+	AuthConnectorDeletedEvent = "authconnector.deleted"
 
 	// ClusterDegradedEvent fires when cluster health check fails.
 	ClusterDegradedEvent = "cluster.degraded"
 	// ClusterActivatedEvent fires when cluster becomes healthy again.
 	ClusterActivatedEvent = "cluster.activated"
+	// NodeDegradedEvent fires when a node fails health checks.
+	NodeDegradedEvent = "node.degraded"
+	// CertificatesRotatedEvent fires when node certificates are renewed.
+	CertificatesRotatedEvent = "certificates.rotated"
+
+	// CommandExecutedEvent fires when a mutating command line action succeeds.
+	CommandExecutedEvent = "command.executed"
+	// CommandFailedEvent fires when a mutating command line action fails.
+	CommandFailedEvent = "command.failed"
 )
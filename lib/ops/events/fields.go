@@ -105,6 +105,48 @@ func EventForOperation(operation ops.SiteOperation) (events.Event, error) {
 			return OperationConfigFailure, nil
 		}
 		return OperationConfigStart, nil
+	case ops.OperationPatch:
+		if operation.IsCompleted() {
+			return OperationPatchComplete, nil
+		} else if operation.IsFailed() {
+			return OperationPatchFailure, nil
+		}
+		return OperationPatchStart, nil
+	case ops.OperationRotateCertificates:
+		if operation.IsCompleted() {
+			return OperationRotateCertsComplete, nil
+		} else if operation.IsFailed() {
+			return OperationRotateCertsFailure, nil
+		}
+		return OperationRotateCertsStart, nil
+	case ops.OperationUpdateBinary:
+		if operation.IsCompleted() {
+			return OperationUpdateBinaryComplete, nil
+		} else if operation.IsFailed() {
+			return OperationUpdateBinaryFailure, nil
+		}
+		return OperationUpdateBinaryStart, nil
+	case ops.OperationImportMigration:
+		if operation.IsCompleted() {
+			return OperationImportMigrationComplete, nil
+		} else if operation.IsFailed() {
+			return OperationImportMigrationFailure, nil
+		}
+		return OperationImportMigrationStart, nil
+	case ops.OperationEnableComponent:
+		if operation.IsCompleted() {
+			return OperationEnableComponentComplete, nil
+		} else if operation.IsFailed() {
+			return OperationEnableComponentFailure, nil
+		}
+		return OperationEnableComponentStart, nil
+	case ops.OperationDisableComponent:
+		if operation.IsCompleted() {
+			return OperationDisableComponentComplete, nil
+		} else if operation.IsFailed() {
+			return OperationDisableComponentFailure, nil
+		}
+		return OperationDisableComponentStart, nil
 	}
 	return events.Event{}, trace.NotFound(
 		"operation does not have corresponding event: %v", operation)
@@ -165,6 +207,21 @@ func FieldsForRelease(release storage.Release) Fields {
 	}
 }
 
+// FieldsForAuditEvent returns event fields for the provided command line audit event.
+func FieldsForAuditEvent(event storage.AuditEvent) Fields {
+	fields := Fields{
+		FieldCommand:      event.Command,
+		FieldArgs:         event.Args,
+		FieldUser:         event.User,
+		FieldNodeHostname: event.Node,
+		FieldTime:         event.Created,
+	}
+	if event.Error != "" {
+		fields[FieldError] = event.Error
+	}
+	return fields
+}
+
 const (
 	// FieldOperationID contains ID of the operation.
 	FieldOperationID = "id"
@@ -196,4 +253,22 @@ const (
 	FieldTime = "time"
 	// FieldRoles contains roles of a new user.
 	FieldRoles = "roles"
+	// FieldCommand contains name of the executed command.
+	FieldCommand = "command"
+	// FieldArgs contains command line arguments of the executed command.
+	FieldArgs = "args"
+	// FieldError contains the error message of a failed action.
+	FieldError = "error"
+	// FieldApprovalID contains ID of the operation approval request.
+	FieldApprovalID = "approvalID"
+	// FieldRequestedBy contains name of the user who requested an operation approval.
+	FieldRequestedBy = "requestedBy"
+	// FieldPolicyRule contains name of the operation policy rule that requires approval.
+	FieldPolicyRule = "policyRule"
+	// FieldExpires contains the expiration time of a created token.
+	FieldExpires = "expires"
+	// FieldClientAddr contains the address of the client that triggered an event.
+	//
+	// It uses the same key as Teleport's own audit events.
+	FieldClientAddr = events.RemoteAddr
 )
@@ -0,0 +1,127 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+)
+
+// InstallResources defines the interface to provide the resources
+// that are created as a part of the install operation, e.g. the public
+// TLS certificate, auth connectors and initial users
+type InstallResources interface {
+	// UpsertInstallResources adds the specified resources to the cluster
+	// being installed replacing the resources with the same kind and name
+	UpsertInstallResources(context.Context, UpsertInstallResourcesRequest) error
+	// GetInstallResources returns the resources uploaded for the
+	// cluster being installed
+	GetInstallResources(SiteKey) ([]storage.UnknownResource, error)
+}
+
+// UpsertInstallResourcesRequest is a request to upload resources created
+// as a part of the install operation
+type UpsertInstallResourcesRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster
+	SiteDomain string `json:"site_domain"`
+	// Resources lists the resources to upload
+	Resources []storage.UnknownResource `json:"resources"`
+}
+
+// Check validates this request
+func (r UpsertInstallResourcesRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if len(r.Resources) == 0 {
+		return trace.BadParameter("no resources specified")
+	}
+	for _, resource := range r.Resources {
+		if err := CheckInstallResource(resource); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// SiteKey returns the key of the cluster this request is for
+func (r UpsertInstallResourcesRequest) SiteKey() SiteKey {
+	return SiteKey{
+		AccountID:  r.AccountID,
+		SiteDomain: r.SiteDomain,
+	}
+}
+
+// CheckInstallResource makes sure that the specified resource
+// can be created as a part of the install operation.
+// The resource is validated against its schema so that invalid resources
+// are rejected when uploaded rather than when the install operation creates them
+func CheckInstallResource(resource storage.UnknownResource) error {
+	if !utils.StringInSlice(InstallResourceKinds, resource.Kind) {
+		return trace.BadParameter("resource %q is not supported during install, supported are: %v",
+			resource.Kind, InstallResourceKinds)
+	}
+	if resource.Metadata.Name == "" {
+		return trace.BadParameter("missing %v resource name", resource.Kind)
+	}
+	if err := checkInstallResourceSchema(resource); err != nil {
+		return trace.BadParameter("invalid %v resource %q: %v",
+			resource.Kind, resource.Metadata.Name, err)
+	}
+	return nil
+}
+
+func checkInstallResourceSchema(resource storage.UnknownResource) error {
+	switch resource.Kind {
+	case storage.KindTLSKeyPair:
+		keyPair, err := storage.UnmarshalTLSKeyPair(resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(keyPair.CheckAndSetDefaults())
+	case teleservices.KindUser:
+		_, err := storage.UnmarshalUser(resource.Raw)
+		return trace.Wrap(err)
+	case teleservices.KindGithubConnector:
+		_, err := teleservices.GetGithubConnectorMarshaler().Unmarshal(resource.Raw)
+		return trace.Wrap(err)
+	case teleservices.KindOIDCConnector, teleservices.KindSAMLConnector:
+		connector, err := UnmarshalAuthConnector(resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(CheckAuthConnector(connector))
+	}
+	return nil
+}
+
+// InstallResourceKinds lists the resources that can be provided
+// before the install operation starts
+var InstallResourceKinds = []string{
+	storage.KindTLSKeyPair,
+	teleservices.KindOIDCConnector,
+	teleservices.KindSAMLConnector,
+	teleservices.KindGithubConnector,
+	teleservices.KindUser,
+}
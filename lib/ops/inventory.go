@@ -0,0 +1,110 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"sort"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+)
+
+// ClusterInventory describes the hardware and software installed
+// on the cluster nodes
+type ClusterInventory struct {
+	// ClusterName is the name of the cluster
+	ClusterName string `json:"cluster_name"`
+	// Application is the name of the cluster application
+	Application string `json:"application"`
+	// Version is the version of the cluster application
+	Version string `json:"version"`
+	// Nodes lists inventory of individual nodes
+	Nodes []NodeInventory `json:"nodes"`
+}
+
+// NodeInventory describes the hardware and software installed on a single node
+type NodeInventory struct {
+	// Hostname is the node hostname
+	Hostname string `json:"hostname"`
+	// AdvertiseIP is the node advertise IP
+	AdvertiseIP string `json:"advertise_ip"`
+	// Addrs lists IPv4 addresses of the node's network interfaces
+	Addrs []string `json:"addrs,omitempty"`
+	// Role is the node's application profile
+	Role string `json:"role"`
+	// ClusterRole is the node's Kubernetes role (master or node)
+	ClusterRole string `json:"cluster_role"`
+	// InstanceType is the node's instance type
+	InstanceType string `json:"instance_type,omitempty"`
+	// OS identifies the node's operating system
+	OS storage.OSInfo `json:"os"`
+	// CPUs is the number of CPUs
+	CPUs uint `json:"cpus,omitempty"`
+	// MemoryBytes is the total amount of RAM in bytes
+	MemoryBytes uint64 `json:"memory_bytes,omitempty"`
+	// DMI is the hardware identification data
+	systeminfo.DMI
+	// GravityVersion is the version of gravity installed on the node
+	GravityVersion string `json:"gravity_version,omitempty"`
+	// Error describes the failure to query the node, if any
+	Error string `json:"error,omitempty"`
+}
+
+// NewNodeInventory returns inventory of the local node from the provided system information
+func NewNodeInventory(info storage.System, dmi systeminfo.DMI, gravityVersion string) NodeInventory {
+	var addrs []string
+	for _, iface := range info.GetNetworkInterfaces() {
+		addrs = append(addrs, iface.IPv4)
+	}
+	sort.Strings(addrs)
+	return NodeInventory{
+		Hostname:       info.GetHostname(),
+		Addrs:          addrs,
+		OS:             info.GetOS(),
+		CPUs:           info.GetNumCPU(),
+		MemoryBytes:    info.GetMemory().Total,
+		DMI:            dmi,
+		GravityVersion: gravityVersion,
+	}
+}
+
+// NewClusterInventory combines the cluster state with the inventory
+// collected from individual nodes.
+// nodes maps node advertise IP to its inventory
+func NewClusterInventory(cluster storage.Site, nodes map[string]NodeInventory) ClusterInventory {
+	inventory := ClusterInventory{
+		ClusterName: cluster.Domain,
+		Application: cluster.App.Name,
+		Version:     cluster.App.Version,
+	}
+	for _, server := range cluster.ClusterState.Servers {
+		node, ok := nodes[server.AdvertiseIP]
+		if !ok {
+			node = NodeInventory{
+				Hostname: server.Hostname,
+				OS:       server.OSInfo,
+				Error:    "inventory not collected",
+			}
+		}
+		node.AdvertiseIP = server.AdvertiseIP
+		node.Role = server.Role
+		node.ClusterRole = server.ClusterRole
+		node.InstanceType = server.InstanceType
+		inventory.Nodes = append(inventory.Nodes, node)
+	}
+	return inventory
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	check "gopkg.in/check.v1"
+)
+
+type InventorySuite struct{}
+
+var _ = check.Suite(&InventorySuite{})
+
+func (s *InventorySuite) TestCombinesClusterStateWithNodes(c *check.C) {
+	cluster := storage.Site{
+		Domain: "example.com",
+		App: storage.Package{
+			Name:    "telekube",
+			Version: "5.5.0",
+		},
+		ClusterState: storage.ClusterState{
+			Servers: []storage.Server{
+				{
+					AdvertiseIP: "192.168.1.1",
+					Hostname:    "node-1",
+					Role:        "master",
+					ClusterRole: "master",
+				},
+				{
+					AdvertiseIP: "192.168.1.2",
+					Hostname:    "node-2",
+					Role:        "worker",
+					ClusterRole: "node",
+					OSInfo:      storage.OSInfo{ID: "centos", Version: "7.6"},
+				},
+			},
+		},
+	}
+	nodes := map[string]NodeInventory{
+		"192.168.1.1": {
+			Hostname:       "node-1",
+			Addrs:          []string{"10.0.0.1", "192.168.1.1"},
+			OS:             storage.OSInfo{ID: "ubuntu", Version: "18.04"},
+			CPUs:           4,
+			DMI:            systeminfo.DMI{SerialNumber: "4XYZ123"},
+			GravityVersion: "5.5.0",
+		},
+	}
+	c.Assert(NewClusterInventory(cluster, nodes), compare.DeepEquals, ClusterInventory{
+		ClusterName: "example.com",
+		Application: "telekube",
+		Version:     "5.5.0",
+		Nodes: []NodeInventory{
+			{
+				Hostname:       "node-1",
+				AdvertiseIP:    "192.168.1.1",
+				Addrs:          []string{"10.0.0.1", "192.168.1.1"},
+				Role:           "master",
+				ClusterRole:    "master",
+				OS:             storage.OSInfo{ID: "ubuntu", Version: "18.04"},
+				CPUs:           4,
+				DMI:            systeminfo.DMI{SerialNumber: "4XYZ123"},
+				GravityVersion: "5.5.0",
+			},
+			{
+				Hostname:    "node-2",
+				AdvertiseIP: "192.168.1.2",
+				Role:        "worker",
+				ClusterRole: "node",
+				OS:          storage.OSInfo{ID: "centos", Version: "7.6"},
+				Error:       "inventory not collected",
+			},
+		},
+	})
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gravitational/trace"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Labels defines the interface to manage labels of clusters and cluster nodes.
+//
+// Labels are arbitrary key/value pairs used to select clusters and nodes
+// with label selectors in CLI commands
+type Labels interface {
+	// UpdateLabels sets and removes labels of the cluster
+	// or of the cluster node specified in the request
+	UpdateLabels(context.Context, UpdateLabelsRequest) error
+}
+
+// UpdateLabelsRequest describes a request to update labels
+// of a cluster or of a cluster node
+type UpdateLabelsRequest struct {
+	// SiteKey identifies the cluster
+	SiteKey
+	// AdvertiseIP is the advertise IP of the node to update labels of.
+	// Labels of the cluster are updated if unspecified
+	AdvertiseIP string `json:"advertise_ip,omitempty"`
+	// Add lists the labels to set
+	Add map[string]string `json:"add,omitempty"`
+	// Remove lists the keys of the labels to remove
+	Remove []string `json:"remove,omitempty"`
+}
+
+// Check validates the request
+func (r UpdateLabelsRequest) Check() error {
+	if err := r.SiteKey.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if len(r.Add) == 0 && len(r.Remove) == 0 {
+		return trace.BadParameter("no labels to add or remove")
+	}
+	for key, value := range r.Add {
+		if err := CheckLabel(key, value); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	for _, key := range r.Remove {
+		if err := checkLabelKey(key); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of the specified labels with the request applied
+func (r UpdateLabelsRequest) Apply(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in)+len(r.Add))
+	for key, value := range in {
+		out[key] = value
+	}
+	for key, value := range r.Add {
+		out[key] = value
+	}
+	for _, key := range r.Remove {
+		delete(out, key)
+	}
+	return out
+}
+
+// CheckLabel makes sure that the label with the specified key and value
+// is a valid Kubernetes label as node labels are also applied to
+// the Kubernetes nodes, and that its key does not use a reserved prefix
+func CheckLabel(key, value string) error {
+	if err := checkLabelKey(key); err != nil {
+		return trace.Wrap(err)
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+		return trace.BadParameter("invalid value %q of label %q: %v", value, key, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// checkLabelKey makes sure that the specified label key is a valid
+// Kubernetes label key that does not belong to a reserved domain.
+//
+// Labels in the reserved domains, such as the node role labels, are managed
+// by Gravity and Kubernetes and should not be changed by users
+func checkLabelKey(key string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+		return trace.BadParameter("invalid label key %q: %v", key, strings.Join(errs, "; "))
+	}
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	prefix := parts[0]
+	for _, domain := range reservedLabelDomains {
+		if prefix == domain || strings.HasSuffix(prefix, "."+domain) {
+			return trace.BadParameter("label %q uses the reserved prefix %v/", key, prefix)
+		}
+	}
+	return nil
+}
+
+// ParseLabelUpdates parses label updates in the kubectl format:
+// key=value sets the label and key- removes the label with the specified key
+func ParseLabelUpdates(args []string) (add map[string]string, remove []string, err error) {
+	add = make(map[string]string)
+	for _, arg := range args {
+		if strings.HasSuffix(arg, "-") && !strings.Contains(arg, "=") {
+			remove = append(remove, strings.TrimSuffix(arg, "-"))
+			continue
+		}
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return nil, nil, trace.BadParameter("invalid label %q, expected key=value to set "+
+				"the label or key- to remove it", arg)
+		}
+		add[parts[0]] = parts[1]
+	}
+	return add, remove, nil
+}
+
+// ParseLabelSelector parses the label selector in the Kubernetes format,
+// e.g. env=prod,region!=us-east-1,tier in (web, db).
+// Empty selector matches everything
+func ParseLabelSelector(selector string) (labels.Selector, error) {
+	result, err := labels.Parse(selector)
+	if err != nil {
+		return nil, trace.BadParameter("invalid label selector %q: %v", selector, err)
+	}
+	return result, nil
+}
+
+// MatchLabels returns true if the specified labels match the selector
+func MatchLabels(selector labels.Selector, in map[string]string) bool {
+	return selector.Matches(labels.Set(in))
+}
+
+// reservedLabelDomains lists the label key prefixes that cannot be used
+// in user labels. Subdomains of these domains, e.g. node-role.kubernetes.io,
+// are reserved as well
+var reservedLabelDomains = []string{
+	"gravitational.io",
+	"kubernetes.io",
+	"k8s.io",
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+type LabelsSuite struct{}
+
+var _ = check.Suite(&LabelsSuite{})
+
+func (s *LabelsSuite) TestParsesLabelUpdates(c *check.C) {
+	add, remove, err := ParseLabelUpdates([]string{"env=prod", "zone=", "team-"})
+	c.Assert(err, check.IsNil)
+	c.Assert(add, check.DeepEquals, map[string]string{"env": "prod", "zone": ""})
+	c.Assert(remove, check.DeepEquals, []string{"team"})
+
+	_, _, err = ParseLabelUpdates([]string{"env"})
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
+
+func (s *LabelsSuite) TestAppliesLabelUpdates(c *check.C) {
+	req := UpdateLabelsRequest{
+		SiteKey: SiteKey{AccountID: "system", SiteDomain: "example.com"},
+		Add:     map[string]string{"env": "prod", "zone": "b"},
+		Remove:  []string{"team"},
+	}
+	c.Assert(req.Check(), check.IsNil)
+	in := map[string]string{"zone": "a", "team": "db"}
+	c.Assert(req.Apply(in), check.DeepEquals, map[string]string{"env": "prod", "zone": "b"})
+	c.Assert(in, check.DeepEquals, map[string]string{"zone": "a", "team": "db"},
+		check.Commentf("input labels should not be modified"))
+}
+
+func (s *LabelsSuite) TestValidatesLabelUpdates(c *check.C) {
+	key := SiteKey{AccountID: "system", SiteDomain: "example.com"}
+	testCases := []UpdateLabelsRequest{
+		{SiteKey: key},
+		{SiteKey: key, Add: map[string]string{"invalid key": "value"}},
+		{SiteKey: key, Add: map[string]string{"env": "invalid value"}},
+		{SiteKey: key, Remove: []string{"-invalid"}},
+		{SiteKey: key, Add: map[string]string{"gravitational.io/k8s-role": "master"}},
+		{SiteKey: key, Add: map[string]string{"node-role.kubernetes.io/master": ""}},
+		{SiteKey: key, Add: map[string]string{"k8s.io/zone": "a"}},
+		{SiteKey: key, Remove: []string{"kubernetes.io/hostname"}},
+	}
+	for _, req := range testCases {
+		c.Assert(trace.IsBadParameter(req.Check()), check.Equals, true,
+			check.Commentf("expected %#v to be invalid", req))
+	}
+}
+
+func (s *LabelsSuite) TestAllowsLabelsOutsideReservedDomains(c *check.C) {
+	for _, key := range []string{"env", "example.com/zone", "kubernetes.io.example.com/team"} {
+		c.Assert(CheckLabel(key, "value"), check.IsNil, check.Commentf("expected %q to be valid", key))
+	}
+}
+
+func (s *LabelsSuite) TestMatchesLabelSelector(c *check.C) {
+	selector, err := ParseLabelSelector("env=prod,zone!=a")
+	c.Assert(err, check.IsNil)
+	c.Assert(MatchLabels(selector, map[string]string{"env": "prod", "zone": "b"}), check.Equals, true)
+	c.Assert(MatchLabels(selector, map[string]string{"env": "prod", "zone": "a"}), check.Equals, false)
+	c.Assert(MatchLabels(selector, nil), check.Equals, false)
+
+	selector, err = ParseLabelSelector("")
+	c.Assert(err, check.IsNil)
+	c.Assert(MatchLabels(selector, nil), check.Equals, true)
+
+	_, err = ParseLabelSelector("env in (prod")
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	licenseapi "github.com/gravitational/license"
+	"github.com/gravitational/trace"
+)
+
+// ValidateLicenseRequest describes the cluster configuration to validate
+// a license against
+type ValidateLicenseRequest struct {
+	// License is the raw license string
+	License string `json:"license"`
+	// NodeCount is the total number of cluster nodes including
+	// the nodes being added. Zero skips the node count check
+	NodeCount int `json:"node_count,omitempty"`
+	// NodeCPUs lists the number of CPUs of each node being added
+	NodeCPUs []uint `json:"node_cpus,omitempty"`
+	// InstanceTypes lists the AWS instance types of the nodes being added
+	InstanceTypes []string `json:"instance_types,omitempty"`
+	// Now is the time to check the license expiration against.
+	// Defaults to the current time
+	Now time.Time `json:"now,omitempty"`
+}
+
+// LicenseEntitlements describes what the license permits
+type LicenseEntitlements struct {
+	// MaxNodes is the maximum number of cluster nodes, 0 means unlimited
+	MaxNodes int `json:"max_nodes"`
+	// MaxCPUs is the maximum number of CPUs per node, 0 means unlimited
+	MaxCPUs int `json:"max_cpus"`
+	// Expiration is the license expiration time, zero if the license does not expire
+	Expiration time.Time `json:"expiration,omitempty"`
+	// Features lists the optional features enabled by the license
+	Features []string `json:"features,omitempty"`
+	// Company is the company the license has been issued to
+	Company string `json:"company,omitempty"`
+	// Email is the email of the person the license has been issued to
+	Email string `json:"email,omitempty"`
+}
+
+// LicenseStatus describes the license entitlements and its expiration state
+type LicenseStatus struct {
+	// LicenseEntitlements describes what the license permits
+	LicenseEntitlements `json:",inline"`
+	// State is the license expiration state
+	State LicenseState `json:"state"`
+	// GraceEnds is the time the grace period of the expired license ends
+	GraceEnds time.Time `json:"grace_ends,omitempty"`
+}
+
+// LicenseState describes the license expiration state
+type LicenseState string
+
+const (
+	// LicenseStateActive is a license that is not about to expire
+	LicenseStateActive LicenseState = "active"
+	// LicenseStateExpiring is a license that expires within the warning period
+	LicenseStateExpiring LicenseState = "expiring"
+	// LicenseStateGracePeriod is an expired license that is still honored
+	LicenseStateGracePeriod LicenseState = "grace-period"
+	// LicenseStateExpired is an expired license past its grace period
+	LicenseStateExpired LicenseState = "expired"
+)
+
+const (
+	// LicenseFeatureEncryption means that the application packages are encrypted
+	// with the key provided by the license
+	LicenseFeatureEncryption = "encryption"
+	// LicenseFeatureShutdown means that the application is stopped
+	// when the license expires
+	LicenseFeatureShutdown = "shutdown-on-expiry"
+)
+
+// ValidateLicense parses the license and checks that it permits
+// the cluster configuration described by req.
+//
+// An expired license is honored during the grace period, the returned
+// status reflects whether the license is about to expire or is in the grace period.
+// Violations are reported as license errors (see utils.IsLicenseError)
+func ValidateLicense(req ValidateLicenseRequest) (*LicenseStatus, error) {
+	parsed, err := licenseapi.ParseLicense(req.License)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to parse license")
+	}
+	now := req.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	payload := parsed.GetPayload()
+	status := GetLicenseStatus(payload, now)
+	if status.State == LicenseStateExpired {
+		return nil, utils.NewLicenseError("the license expired on %v and its grace period ended on %v",
+			status.Expiration.Format(time.RFC3339), status.GraceEnds.Format(time.RFC3339))
+	}
+	if req.NodeCount != 0 && payload.MaxNodes != 0 && req.NodeCount > payload.MaxNodes {
+		return nil, utils.NewLicenseError("the license allows maximum of %v nodes, requested: %v",
+			payload.MaxNodes, req.NodeCount)
+	}
+	for _, numCPU := range req.NodeCPUs {
+		if payload.MaxCores != 0 && numCPU > uint(payload.MaxCores) {
+			return nil, utils.NewLicenseError("the license allows maximum of %v CPUs, requested: %v",
+				payload.MaxCores, numCPU)
+		}
+	}
+	if err := payload.CheckInstanceTypes(req.InstanceTypes); err != nil {
+		return nil, utils.NewLicenseError("%v", trace.UserMessage(err))
+	}
+	return &status, nil
+}
+
+// ParseLicenseStatus parses the license and returns its status
+// without enforcing it
+func ParseLicenseStatus(license string, now time.Time) (*LicenseStatus, error) {
+	parsed, err := licenseapi.ParseLicense(license)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to parse license")
+	}
+	status := GetLicenseStatus(parsed.GetPayload(), now)
+	return &status, nil
+}
+
+// GetLicenseStatus returns the status of the license with the specified payload
+// at the given time
+func GetLicenseStatus(payload licenseapi.Payload, now time.Time) LicenseStatus {
+	status := LicenseStatus{
+		LicenseEntitlements: GetLicenseEntitlements(payload),
+		State:               LicenseStateActive,
+	}
+	if payload.Expiration.IsZero() {
+		return status
+	}
+	status.GraceEnds = payload.Expiration.Add(defaults.LicenseGracePeriod)
+	switch {
+	case !now.Before(status.GraceEnds):
+		status.State = LicenseStateExpired
+	case !now.Before(payload.Expiration):
+		status.State = LicenseStateGracePeriod
+	case now.Add(defaults.LicenseExpiryWarningPeriod).After(payload.Expiration):
+		status.State = LicenseStateExpiring
+	}
+	return status
+}
+
+// GetLicenseEntitlements returns the entitlements of the license with the specified payload
+func GetLicenseEntitlements(payload licenseapi.Payload) LicenseEntitlements {
+	entitlements := LicenseEntitlements{
+		MaxNodes:   payload.MaxNodes,
+		MaxCPUs:    payload.MaxCores,
+		Expiration: payload.Expiration,
+		Company:    payload.Company,
+		Email:      payload.Email,
+	}
+	if len(payload.EncryptionKey) != 0 {
+		entitlements.Features = append(entitlements.Features, LicenseFeatureEncryption)
+	}
+	if payload.Shutdown {
+		entitlements.Features = append(entitlements.Features, LicenseFeatureShutdown)
+	}
+	return entitlements
+}
+
+// IsValid returns true if the license can be used, i.e. it has not
+// expired or is in the grace period
+func (r LicenseStatus) IsValid() bool {
+	return r.State != LicenseStateExpired
+}
+
+// Warning returns the human-readable warning about the license expiration
+// or an empty string if the license is not about to expire
+func (r LicenseStatus) Warning() string {
+	switch r.State {
+	case LicenseStateExpiring:
+		return fmt.Sprintf("the license expires on %v", r.Expiration.Format(time.RFC3339))
+	case LicenseStateGracePeriod:
+		return fmt.Sprintf("the license expired on %v, the grace period ends on %v",
+			r.Expiration.Format(time.RFC3339), r.GraceEnds.Format(time.RFC3339))
+	case LicenseStateExpired:
+		return fmt.Sprintf("the license expired on %v", r.Expiration.Format(time.RFC3339))
+	}
+	return ""
+}
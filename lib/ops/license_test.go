@@ -0,0 +1,110 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	licenseapi "github.com/gravitational/license"
+	check "gopkg.in/check.v1"
+)
+
+type LicenseSuite struct{}
+
+var _ = check.Suite(&LicenseSuite{})
+
+func (s *LicenseSuite) TestValidatesLicense(c *check.C) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	license := newTestLicense(c, licenseapi.Payload{
+		MaxNodes:   3,
+		MaxCores:   8,
+		Expiration: now.Add(365 * 24 * time.Hour),
+		Shutdown:   true,
+	})
+	status, err := ValidateLicense(ValidateLicenseRequest{
+		License:   license,
+		NodeCount: 3,
+		NodeCPUs:  []uint{4, 8},
+		Now:       now,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(status.State, check.Equals, LicenseStateActive)
+	c.Assert(status.MaxNodes, check.Equals, 3)
+	c.Assert(status.MaxCPUs, check.Equals, 8)
+	c.Assert(status.Features, check.DeepEquals, []string{LicenseFeatureShutdown})
+	c.Assert(status.Warning(), check.Equals, "")
+
+	_, err = ValidateLicense(ValidateLicenseRequest{License: license, NodeCount: 4, Now: now})
+	c.Assert(utils.IsLicenseError(err), check.Equals, true)
+	c.Assert(err.Error(), check.Equals, "the license allows maximum of 3 nodes, requested: 4")
+
+	_, err = ValidateLicense(ValidateLicenseRequest{License: license, NodeCPUs: []uint{16}, Now: now})
+	c.Assert(utils.IsLicenseError(err), check.Equals, true)
+}
+
+func (s *LicenseSuite) TestHonorsGracePeriod(c *check.C) {
+	expiration := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	license := newTestLicense(c, licenseapi.Payload{Expiration: expiration})
+	var testCases = []struct {
+		comment string
+		now     time.Time
+		state   LicenseState
+	}{
+		{
+			comment: "not about to expire",
+			now:     expiration.Add(-defaults.LicenseExpiryWarningPeriod - time.Hour),
+			state:   LicenseStateActive,
+		},
+		{
+			comment: "expires within the warning period",
+			now:     expiration.Add(-time.Hour),
+			state:   LicenseStateExpiring,
+		},
+		{
+			comment: "expired within the grace period",
+			now:     expiration.Add(time.Hour),
+			state:   LicenseStateGracePeriod,
+		},
+		{
+			comment: "expired past the grace period",
+			now:     expiration.Add(defaults.LicenseGracePeriod),
+			state:   LicenseStateExpired,
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		status, err := ParseLicenseStatus(license, tc.now)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(status.State, check.Equals, tc.state, comment)
+		_, err = ValidateLicense(ValidateLicenseRequest{License: license, Now: tc.now})
+		if tc.state == LicenseStateExpired {
+			c.Assert(utils.IsLicenseError(err), check.Equals, true, comment)
+		} else {
+			c.Assert(err, check.IsNil, comment)
+		}
+	}
+}
+
+func newTestLicense(c *check.C, payload licenseapi.Payload) string {
+	bytes, err := json.Marshal(payload)
+	c.Assert(err, check.IsNil)
+	return string(bytes)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"github.com/gravitational/gravity/lib/logging"
+
+	"github.com/gravitational/trace"
+)
+
+// UpdateLogLevelsRequest is a request to update log levels of cluster controllers
+type UpdateLogLevelsRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster
+	SiteDomain string `json:"site_domain"`
+	// Levels maps subsystems to log levels.
+	// The levels are merged into the existing overrides
+	Levels logging.Levels `json:"levels"`
+}
+
+// Check validates this request
+func (r UpdateLogLevelsRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if len(r.Levels) == 0 {
+		return trace.BadParameter("at least one log level is required")
+	}
+	return trace.Wrap(r.Levels.Check())
+}
+
+// SiteKey returns the key of the cluster this request is for
+func (r UpdateLogLevelsRequest) SiteKey() SiteKey {
+	return SiteKey{
+		AccountID:  r.AccountID,
+		SiteDomain: r.SiteDomain,
+	}
+}
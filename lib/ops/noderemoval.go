@@ -0,0 +1,267 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+// NodeRemovals defines the interface to verify that nodes have been removed
+// from the cluster and to keep track of nodes that have left the cluster
+// without a complete removal
+type NodeRemovals interface {
+	// VerifyNodeRemoval verifies that the node has been removed from
+	// the etcd cluster, Kubernetes and Teleport
+	VerifyNodeRemoval(context.Context, VerifyNodeRemovalRequest) (*NodeRemovalStatus, error)
+	// CreateNodeTombstone records the node that has left the cluster
+	// forcibly for later reconciliation
+	CreateNodeTombstone(context.Context, SiteKey, NodeTombstone) error
+	// GetNodeTombstones returns the nodes that have left the cluster forcibly
+	// and have not been reconciled yet
+	GetNodeTombstones(SiteKey) ([]NodeTombstone, error)
+	// DeleteNodeTombstone deletes the tombstone of the node with the specified hostname
+	DeleteNodeTombstone(ctx context.Context, key SiteKey, hostname string) error
+	// SimulateNodeRemoval reports the impact of removing the node from the cluster
+	// without making any changes
+	SimulateNodeRemoval(context.Context, SimulateNodeRemovalRequest) (*NodeRemovalSimulation, error)
+}
+
+// VerifyNodeRemovalRequest is a request to verify that a node has been removed from the cluster
+type VerifyNodeRemovalRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster
+	SiteDomain string `json:"site_domain"`
+	// Server is the removed node
+	Server storage.Server `json:"server"`
+}
+
+// Check validates this request
+func (r VerifyNodeRemovalRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if r.Server.Hostname == "" {
+		return trace.BadParameter("missing server hostname")
+	}
+	if r.Server.AdvertiseIP == "" {
+		return trace.BadParameter("missing server advertise IP")
+	}
+	return nil
+}
+
+// SiteKey returns the key of the cluster this request is for
+func (r VerifyNodeRemovalRequest) SiteKey() SiteKey {
+	return SiteKey{
+		AccountID:  r.AccountID,
+		SiteDomain: r.SiteDomain,
+	}
+}
+
+// NodeRemovalStatus describes whether a node has been removed
+// from each of the cluster subsystems
+type NodeRemovalStatus struct {
+	// Steps lists the results of the individual verification steps
+	Steps []NodeRemovalStep `json:"steps"`
+}
+
+// Removed returns true if the node has been removed from all subsystems
+func (r NodeRemovalStatus) Removed() bool {
+	for _, step := range r.Steps {
+		if !step.Removed {
+			return false
+		}
+	}
+	return true
+}
+
+// NodeRemovalStep is the result of verifying node removal from a single subsystem
+type NodeRemovalStep struct {
+	// Description describes what has been verified
+	Description string `json:"description"`
+	// Removed is whether the node has been removed from the subsystem
+	Removed bool `json:"removed"`
+	// Message explains why the node is not considered removed
+	Message string `json:"message,omitempty"`
+}
+
+// String returns a textual representation of this step
+func (r NodeRemovalStep) String() string {
+	if r.Removed {
+		return fmt.Sprintf("%v: removed", r.Description)
+	}
+	return fmt.Sprintf("%v: not removed: %v", r.Description, r.Message)
+}
+
+// NodeTombstone records the node that has left the cluster forcibly, i.e.
+// without the verified removal from all cluster subsystems
+type NodeTombstone struct {
+	// Server is the node that has left the cluster
+	Server storage.Server `json:"server"`
+	// Created is the time the node has left the cluster
+	Created time.Time `json:"created"`
+	// Reason describes why the node has left the cluster forcibly
+	Reason string `json:"reason,omitempty"`
+}
+
+// Check validates this tombstone
+func (r NodeTombstone) Check() error {
+	if r.Server.Hostname == "" {
+		return trace.BadParameter("missing server hostname")
+	}
+	if r.Server.AdvertiseIP == "" {
+		return trace.BadParameter("missing server advertise IP")
+	}
+	return nil
+}
+
+// String returns a textual representation of this tombstone
+func (r NodeTombstone) String() string {
+	return fmt.Sprintf("NodeTombstone(Hostname=%v, AdvertiseIP=%v, Created=%v)",
+		r.Server.Hostname, r.Server.AdvertiseIP, r.Created.Format(time.RFC3339))
+}
+
+// SimulateNodeRemovalRequest is a request to report the impact of removing a node
+// from the cluster
+type SimulateNodeRemovalRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster
+	SiteDomain string `json:"site_domain"`
+	// Server is the node to remove
+	Server storage.Server `json:"server"`
+}
+
+// Check validates this request
+func (r SimulateNodeRemovalRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if r.Server.Hostname == "" {
+		return trace.BadParameter("missing server hostname")
+	}
+	return nil
+}
+
+// SiteKey returns the key of the cluster this request is for
+func (r SimulateNodeRemovalRequest) SiteKey() SiteKey {
+	return SiteKey{
+		AccountID:  r.AccountID,
+		SiteDomain: r.SiteDomain,
+	}
+}
+
+// NodeRemovalSimulation describes the impact of removing a node from the cluster
+type NodeRemovalSimulation struct {
+	// Server is the node to remove
+	Server storage.Server `json:"server"`
+	// Pods lists the pods that would be evicted from the node
+	Pods []EvictedPod `json:"pods,omitempty"`
+	// Capacity describes whether the remaining nodes can accommodate
+	// the evicted pods
+	Capacity RemainingCapacity `json:"capacity"`
+	// LocalVolumes lists the persistent volumes local to the node
+	// that would be lost
+	LocalVolumes []LocalVolume `json:"local_volumes,omitempty"`
+	// Etcd describes the impact on the etcd cluster
+	Etcd EtcdQuorum `json:"etcd"`
+}
+
+// Safe returns true if removing the node neither loses data nor endangers
+// the cluster
+func (r NodeRemovalSimulation) Safe() bool {
+	for _, pod := range r.Pods {
+		if !pod.Rescheduled() {
+			return false
+		}
+	}
+	return r.Capacity.Sufficient && len(r.LocalVolumes) == 0 && !r.Etcd.Endangered
+}
+
+// EvictedPod describes a pod that would be evicted from the removed node
+type EvictedPod struct {
+	// Namespace is the pod namespace
+	Namespace string `json:"namespace"`
+	// Name is the pod name
+	Name string `json:"name"`
+	// Controller references the controller managing the pod as kind/name.
+	// Empty if the pod is not managed by a controller
+	Controller string `json:"controller,omitempty"`
+}
+
+// Rescheduled returns true if the pod would be re-created on another node
+func (r EvictedPod) Rescheduled() bool {
+	return r.Controller != ""
+}
+
+// String returns a textual representation of this pod
+func (r EvictedPod) String() string {
+	if r.Rescheduled() {
+		return fmt.Sprintf("%v/%v (%v)", r.Namespace, r.Name, r.Controller)
+	}
+	return fmt.Sprintf("%v/%v (not managed by a controller, will not be rescheduled)",
+		r.Namespace, r.Name)
+}
+
+// RemainingCapacity compares the resources requested by the evicted pods
+// with the unreserved resources of the remaining schedulable nodes
+type RemainingCapacity struct {
+	// RequestedCPU is the CPU requested by the evicted pods, in millicores
+	RequestedCPU int64 `json:"requested_cpu"`
+	// RequestedMemory is the memory requested by the evicted pods, in bytes
+	RequestedMemory int64 `json:"requested_memory"`
+	// AvailableCPU is the CPU available on the remaining nodes, in millicores
+	AvailableCPU int64 `json:"available_cpu"`
+	// AvailableMemory is the memory available on the remaining nodes, in bytes
+	AvailableMemory int64 `json:"available_memory"`
+	// Sufficient is whether each evicted pod fits on one of the remaining nodes
+	Sufficient bool `json:"sufficient"`
+	// UnschedulablePods lists the evicted pods that do not fit
+	// on any of the remaining nodes, with the reasons
+	UnschedulablePods []string `json:"unschedulable_pods,omitempty"`
+}
+
+// LocalVolume describes a persistent volume local to the removed node
+type LocalVolume struct {
+	// Name is the persistent volume name
+	Name string `json:"name"`
+	// StorageClass is the storage class of the volume
+	StorageClass string `json:"storage_class,omitempty"`
+	// Claim references the bound claim as namespace/name
+	Claim string `json:"claim,omitempty"`
+	// CapacityBytes is the volume capacity
+	CapacityBytes int64 `json:"capacity_bytes"`
+}
+
+// EtcdQuorum describes the impact of the node removal on the etcd cluster
+type EtcdQuorum struct {
+	// Member is whether the node is a member of the etcd cluster
+	Member bool `json:"member"`
+	// Members is the current number of etcd members
+	Members int `json:"members"`
+	// Endangered is whether the etcd cluster would be unable to tolerate
+	// the failure of another member after the removal
+	Endangered bool `json:"endangered"`
+	// Message describes the impact
+	Message string `json:"message"`
+}
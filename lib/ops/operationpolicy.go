@@ -0,0 +1,172 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+// CheckOperationPolicy evaluates the specified operation created at the given
+// time against the rules of the policy.
+//
+// Returns an AccessDenied error if the operation matches any rule that denies it.
+// Otherwise, returns the first matching rule that requires the operation to be
+// approved or nil if no rule requires approval
+func CheckOperationPolicy(policy storage.OperationPolicy, operation SiteOperation, now time.Time) (*storage.OperationPolicyRule, error) {
+	input := NewOperationPolicyInput(operation, now)
+	var approvalRule *storage.OperationPolicyRule
+	for _, rule := range policy.GetRules() {
+		if !rule.Matches(input) {
+			continue
+		}
+		if rule.RequiresApproval() {
+			if approvalRule == nil {
+				rule := rule
+				approvalRule = &rule
+			}
+			continue
+		}
+		if rule.Message != "" {
+			return nil, trace.AccessDenied("%v operation denied by policy rule %q: %v",
+				input.Operation, rule.Name, rule.Message)
+		}
+		return nil, trace.AccessDenied("%v operation denied by policy rule %q",
+			input.Operation, rule.Name)
+	}
+	return approvalRule, nil
+}
+
+// CheckRegoDecision converts the decision of the Rego operation policy
+// about the specified operation into the result of CheckOperationPolicy.
+//
+// Operations that require approval are attributed to the RegoPolicyRule rule
+func CheckRegoDecision(input storage.OperationPolicyInput, decision storage.OperationPolicyRegoDecision) (*storage.OperationPolicyRule, error) {
+	switch decision.Decision {
+	case storage.OperationPolicyAllow:
+		return nil, nil
+	case storage.OperationPolicyDeny:
+		if decision.Message != "" {
+			return nil, trace.AccessDenied("%v operation denied by rego policy: %v",
+				input.Operation, decision.Message)
+		}
+		return nil, trace.AccessDenied("%v operation denied by rego policy", input.Operation)
+	case storage.OperationPolicyRequireApproval:
+		return &storage.OperationPolicyRule{
+			Name:     RegoPolicyRule,
+			Decision: storage.OperationPolicyRequireApproval,
+			Message:  decision.Message,
+		}, nil
+	}
+	return nil, trace.BadParameter("rego policy returned unsupported decision %q, supported are: %v",
+		decision.Decision, append([]string{storage.OperationPolicyAllow}, storage.OperationPolicyDecisions...))
+}
+
+// RegoPolicyRule is the name of the rule approval requests
+// required by the Rego operation policy refer to
+const RegoPolicyRule = "rego"
+
+// NewOperationPolicyInput describes the specified operation for policy evaluation
+func NewOperationPolicyInput(operation SiteOperation, now time.Time) storage.OperationPolicyInput {
+	input := storage.OperationPolicyInput{
+		Operation: strings.TrimPrefix(operation.Type, "operation_"),
+		User:      operation.CreatedBy,
+		Time:      now,
+	}
+	switch {
+	case operation.Shrink != nil:
+		input.Force = operation.Shrink.Force
+	case operation.Uninstall != nil:
+		input.Force = operation.Uninstall.Force
+	}
+	for _, server := range targetServers(operation) {
+		input.Nodes = append(input.Nodes, server.Hostname, server.AdvertiseIP)
+	}
+	return input
+}
+
+// NewOperationApproval returns an approval request for the specified operation
+// or nil if the operation does not require approval under the policy.
+// rule is the matching policy rule that requires approval, if any
+func NewOperationApproval(policy storage.OperationPolicy, operation SiteOperation, rule *storage.OperationPolicyRule) *storage.OperationApproval {
+	requirements := policy.GetApproval()
+	if requirements == nil && rule == nil {
+		return nil
+	}
+	approval := storage.OperationApproval{
+		ClusterName: operation.SiteDomain,
+		Operation:   strings.TrimPrefix(operation.Type, "operation_"),
+		RequestedBy: operation.CreatedBy,
+	}
+	switch {
+	case operation.Shrink != nil:
+		approval.Force = operation.Shrink.Force
+	case operation.Uninstall != nil:
+		approval.Force = operation.Uninstall.Force
+	}
+	if rule != nil {
+		approval.Rule = rule.Name
+	} else if !requirements.Requires(approval.Operation, approval.Force) {
+		return nil
+	}
+	for _, server := range targetServers(operation) {
+		approval.Servers = append(approval.Servers, server.Hostname)
+	}
+	return &approval
+}
+
+// ApproveOperationRequest is a request to approve a cluster operation
+// started by another user
+type ApproveOperationRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster
+	SiteDomain string `json:"site_domain"`
+	// ApprovalID is the ID of the approval request
+	ApprovalID string `json:"approval_id"`
+}
+
+// Check validates this request
+func (r ApproveOperationRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if r.ApprovalID == "" {
+		return trace.BadParameter("missing approval request ID")
+	}
+	return nil
+}
+
+// SiteKey returns the key of the cluster this request is for
+func (r ApproveOperationRequest) SiteKey() SiteKey {
+	return SiteKey{
+		AccountID:  r.AccountID,
+		SiteDomain: r.SiteDomain,
+	}
+}
+
+// targetServers returns the servers targeted by the specified operation
+func targetServers(operation SiteOperation) []storage.Server {
+	if operation.Shrink != nil {
+		return operation.Shrink.Servers
+	}
+	return operation.Servers
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+type OperationPolicySuite struct{}
+
+var _ = check.Suite(&OperationPolicySuite{})
+
+func (s *OperationPolicySuite) TestChecksOperation(c *check.C) {
+	policy := storage.NewOperationPolicy(storage.OperationPolicySpecV2{
+		Rules: []storage.OperationPolicyRule{{
+			Name:       "protect-db",
+			Operations: []string{"shrink"},
+			Nodes:      []string{"10.0.0.1"},
+			Message:    "database nodes cannot be removed",
+		}},
+	})
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	operation := SiteOperation{
+		Type:      OperationShrink,
+		CreatedBy: "alice@example.com",
+		Servers:   []storage.Server{{Hostname: "db-1", AdvertiseIP: "10.0.0.1"}},
+	}
+	_, err := CheckOperationPolicy(policy, operation, now)
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true)
+	c.Assert(err.Error(), check.Equals,
+		`shrink operation denied by policy rule "protect-db": database nodes cannot be removed`)
+
+	operation.Servers = []storage.Server{{Hostname: "web-1", AdvertiseIP: "10.0.0.2"}}
+	rule, err := CheckOperationPolicy(policy, operation, now)
+	c.Assert(err, check.IsNil)
+	c.Assert(rule, check.IsNil)
+
+	operation.Type = OperationGarbageCollect
+	c.Assert(NewOperationPolicyInput(operation, now).Operation, check.Equals, "gc")
+}
+
+func (s *OperationPolicySuite) TestCreatesApproval(c *check.C) {
+	policy := storage.NewOperationPolicy(storage.OperationPolicySpecV2{
+		Approval: &storage.OperationPolicyApproval{
+			Operations: []string{"force_remove"},
+		},
+	})
+	operation := SiteOperation{
+		SiteDomain: "example.com",
+		Type:       OperationShrink,
+		CreatedBy:  "alice@example.com",
+		Shrink: &storage.ShrinkOperationState{
+			Servers: []storage.Server{{Hostname: "db-1", AdvertiseIP: "10.0.0.1"}},
+		},
+	}
+	c.Assert(NewOperationApproval(policy, operation, nil), check.IsNil)
+
+	operation.Shrink.Force = true
+	c.Assert(NewOperationApproval(policy, operation, nil), check.DeepEquals, &storage.OperationApproval{
+		ClusterName: "example.com",
+		Operation:   "shrink",
+		Servers:     []string{"db-1"},
+		Force:       true,
+		RequestedBy: "alice@example.com",
+	})
+}
+
+func (s *OperationPolicySuite) TestRuleRequiresApproval(c *check.C) {
+	policy := storage.NewOperationPolicy(storage.OperationPolicySpecV2{
+		Rules: []storage.OperationPolicyRule{
+			{
+				Name:       "review-updates",
+				Decision:   storage.OperationPolicyRequireApproval,
+				Operations: []string{"update", "update_config"},
+			},
+			{
+				Name:  "deny-contractors",
+				Users: []string{"*@contractor.example.com"},
+			},
+		},
+	})
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	operation := SiteOperation{
+		SiteDomain: "example.com",
+		Type:       OperationUpdateConfig,
+		CreatedBy:  "alice@example.com",
+	}
+	rule, err := CheckOperationPolicy(policy, operation, now)
+	c.Assert(err, check.IsNil)
+	c.Assert(rule, check.NotNil)
+	c.Assert(rule.Name, check.Equals, "review-updates")
+	c.Assert(NewOperationApproval(policy, operation, rule), check.DeepEquals, &storage.OperationApproval{
+		ClusterName: "example.com",
+		Operation:   "update_config",
+		Rule:        "review-updates",
+		RequestedBy: "alice@example.com",
+	})
+
+	// Denying rules take precedence over the rules that require approval
+	operation.CreatedBy = "bob@contractor.example.com"
+	_, err = CheckOperationPolicy(policy, operation, now)
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true)
+
+	operation.Type = OperationGarbageCollect
+	operation.CreatedBy = "alice@example.com"
+	rule, err = CheckOperationPolicy(policy, operation, now)
+	c.Assert(err, check.IsNil)
+	c.Assert(rule, check.IsNil)
+	c.Assert(NewOperationApproval(policy, operation, rule), check.IsNil)
+}
+
+func (s *OperationPolicySuite) TestChecksRegoDecision(c *check.C) {
+	input := storage.OperationPolicyInput{Operation: "shrink", User: "alice@example.com"}
+
+	rule, err := CheckRegoDecision(input, storage.OperationPolicyRegoDecision{Decision: "allow"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rule, check.IsNil)
+
+	_, err = CheckRegoDecision(input, storage.OperationPolicyRegoDecision{
+		Decision: "deny",
+		Message:  "database nodes cannot be removed",
+	})
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, "shrink operation denied by rego policy: database nodes cannot be removed")
+
+	rule, err = CheckRegoDecision(input, storage.OperationPolicyRegoDecision{Decision: "require_approval"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rule, check.DeepEquals, &storage.OperationPolicyRule{
+		Name:     RegoPolicyRule,
+		Decision: storage.OperationPolicyRequireApproval,
+	})
+
+	_, err = CheckRegoDecision(input, storage.OperationPolicyRegoDecision{Decision: "maybe"})
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
@@ -0,0 +1,32 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/storage"
+)
+
+// OperationTokens defines the interface to issue bearer tokens that grant
+// read-only access to the plan and progress of a single operation
+type OperationTokens interface {
+	// CreateOperationToken issues a new token granting access to the plan
+	// and progress of the specified operation.
+	// The tokens of an operation are revoked once it completes or fails
+	CreateOperationToken(context.Context, SiteOperationKey) (*storage.OperationToken, error)
+}
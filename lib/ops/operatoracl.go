@@ -26,6 +26,7 @@ import (
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/logging"
 	"github.com/gravitational/gravity/lib/modules"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/clusterconfig"
@@ -224,7 +225,12 @@ func (o *OperatorACL) GetLocalUser(key SiteKey) (storage.User, error) {
 }
 
 func (o *OperatorACL) GetClusterAgent(req ClusterAgentRequest) (*storage.LoginEntry, error) {
-	if err := o.ClusterAction(req.ClusterName, storage.KindCluster, teleservices.VerbRead); err != nil {
+	// Admin agent credentials give full access to the cluster
+	verb := teleservices.VerbRead
+	if req.Admin {
+		verb = teleservices.VerbUpdate
+	}
+	if err := o.ClusterAction(req.ClusterName, storage.KindCluster, verb); err != nil {
 		return nil, trace.Wrap(err)
 	}
 	return o.operator.GetClusterAgent(req)
@@ -238,6 +244,74 @@ func (o *OperatorACL) GetClusterNodes(key SiteKey) ([]Node, error) {
 	return o.operator.GetClusterNodes(key)
 }
 
+// GetClusterInventory returns hardware and software inventory of cluster nodes
+func (o *OperatorACL) GetClusterInventory(key SiteKey) (*ClusterInventory, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetClusterInventory(key)
+}
+
+// GetClusterProbes returns the last results of the health probes on cluster nodes
+func (o *OperatorACL) GetClusterProbes(key SiteKey) (*ClusterProbes, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetClusterProbes(key)
+}
+
+// GetStorageCapacity returns the block storage capacity available
+// for persistent volumes on cluster nodes
+func (o *OperatorACL) GetStorageCapacity(req StorageCapacityRequest) (*StorageCapacity, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetStorageCapacity(req)
+}
+
+// GetStorageUsage returns the persistent storage capacity and usage
+// of the application namespaces
+func (o *OperatorACL) GetStorageUsage(key SiteKey) (*StorageUsage, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetStorageUsage(key)
+}
+
+// GetNodeCertificates returns the expiration of the certificates
+// installed on cluster nodes
+func (o *OperatorACL) GetNodeCertificates(key SiteKey) ([]NodeCertificates, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetNodeCertificates(key)
+}
+
+// GetClusterSummaries returns summaries of all clusters managed by this operator
+func (o *OperatorACL) GetClusterSummaries(accountID string) ([]ClusterSummary, error) {
+	allSummaries, err := o.operator.GetClusterSummaries(accountID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// return only the clusters we have access to
+	var summaries []ClusterSummary
+	for _, summary := range allSummaries {
+		if err := o.ClusterAction(summary.Name, storage.KindCluster, teleservices.VerbRead); err != nil {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// GetClusterSummary returns the summary of the specified cluster
+func (o *OperatorACL) GetClusterSummary(key SiteKey) (*ClusterSummary, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetClusterSummary(key)
+}
+
 func (o *OperatorACL) ResetUserPassword(req ResetUserPasswordRequest) (string, error) {
 	if err := o.Action(teleservices.KindUser, teleservices.VerbUpdate); err != nil {
 		return "", trace.Wrap(err)
@@ -294,7 +368,7 @@ func (o *OperatorACL) GetExpandToken(key SiteKey) (*storage.ProvisioningToken, e
 }
 
 func (o *OperatorACL) GetTrustedClusterToken(key SiteKey) (storage.Token, error) {
-	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
 		return nil, trace.Wrap(err)
 	}
 	return o.operator.GetTrustedClusterToken(key)
@@ -395,6 +469,27 @@ func (o *OperatorACL) ActivateSite(req ActivateSiteRequest) error {
 	return o.operator.ActivateSite(req)
 }
 
+func (o *OperatorACL) EnableComponent(ctx context.Context, req ComponentRequest) (*SiteOperationKey, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.EnableComponent(ctx, req)
+}
+
+func (o *OperatorACL) UpdateClusterLicense(req UpdateLicenseRequest) error {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateClusterLicense(req)
+}
+
+func (o *OperatorACL) DisableComponent(ctx context.Context, req ComponentRequest) (*SiteOperationKey, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.DisableComponent(ctx, req)
+}
+
 func (o *OperatorACL) CompleteFinalInstallStep(req CompleteFinalInstallStepRequest) error {
 	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
 		return trace.Wrap(err)
@@ -504,6 +599,22 @@ func (o *OperatorACL) CreateClusterGarbageCollectOperation(ctx context.Context,
 	return o.operator.CreateClusterGarbageCollectOperation(ctx, req)
 }
 
+// CreatePatchOperation creates a new operation to patch the operating system on cluster nodes
+func (o *OperatorACL) CreatePatchOperation(ctx context.Context, req CreatePatchOperationRequest) (*SiteOperationKey, error) {
+	if err := o.ClusterAction(req.ClusterKey.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.CreatePatchOperation(ctx, req)
+}
+
+// CreateUpdateBinaryOperation creates a new operation to replace the gravity binary on cluster nodes
+func (o *OperatorACL) CreateUpdateBinaryOperation(ctx context.Context, req CreateUpdateBinaryOperationRequest) (*SiteOperationKey, error) {
+	if err := o.ClusterAction(req.ClusterKey.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.CreateUpdateBinaryOperation(ctx, req)
+}
+
 // CreateUpdateEnvarsOperation creates a new operation to update cluster environment variables
 func (o *OperatorACL) CreateUpdateEnvarsOperation(ctx context.Context, req CreateUpdateEnvarsOperationRequest) (*SiteOperationKey, error) {
 	if err := o.ClusterAction(req.ClusterKey.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
@@ -571,6 +682,13 @@ func (o *OperatorACL) CreateProgressEntry(key SiteOperationKey, entry ProgressEn
 	return o.operator.CreateProgressEntry(key, entry)
 }
 
+func (o *OperatorACL) GetOperationArtifacts(key SiteOperationKey) (io.ReadCloser, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetOperationArtifacts(key)
+}
+
 func (o *OperatorACL) GetSiteReport(key SiteKey) (io.ReadCloser, error) {
 	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
 		return nil, trace.Wrap(err)
@@ -578,6 +696,34 @@ func (o *OperatorACL) GetSiteReport(key SiteKey) (io.ReadCloser, error) {
 	return o.operator.GetSiteReport(key)
 }
 
+func (o *OperatorACL) UploadClusterReport(key SiteKey, reader io.Reader) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UploadClusterReport(key, reader)
+}
+
+func (o *OperatorACL) ImportMigration(req ImportMigrationRequest, reader io.Reader) (*SiteOperationKey, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.ImportMigration(req, reader)
+}
+
+func (o *OperatorACL) GetChartIndex(key SiteKey) (io.ReadCloser, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindApp, teleservices.VerbList); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetChartIndex(key)
+}
+
+func (o *OperatorACL) FetchChart(key SiteKey, locator loc.Locator) (io.ReadCloser, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindApp, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.FetchChart(key, locator)
+}
+
 func (o *OperatorACL) ValidateDomainName(domainName string) error {
 	if err := o.ClusterAction(domainName, storage.KindCluster, teleservices.VerbRead); err != nil {
 		// when installing via a one-time install link, the token does not have
@@ -746,6 +892,204 @@ func (o *OperatorACL) DeleteSMTPConfig(ctx context.Context, key SiteKey) error {
 	return o.operator.DeleteSMTPConfig(ctx, key)
 }
 
+func (o *OperatorACL) GetHealthReport(key SiteKey) (storage.HealthReport, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindHealthReport, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetHealthReport(key)
+}
+
+func (o *OperatorACL) UpdateHealthReport(ctx context.Context, key SiteKey, config storage.HealthReport) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindHealthReport, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateHealthReport(ctx, key, config)
+}
+
+func (o *OperatorACL) DeleteHealthReport(ctx context.Context, key SiteKey) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindHealthReport, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.DeleteHealthReport(ctx, key)
+}
+
+func (o *OperatorACL) GetAdmissionWebhook(key SiteKey) (storage.AdmissionWebhook, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindAdmissionWebhook, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetAdmissionWebhook(key)
+}
+
+func (o *OperatorACL) UpdateAdmissionWebhook(ctx context.Context, key SiteKey, webhook storage.AdmissionWebhook) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindAdmissionWebhook, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateAdmissionWebhook(ctx, key, webhook)
+}
+
+func (o *OperatorACL) DeleteAdmissionWebhook(ctx context.Context, key SiteKey) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindAdmissionWebhook, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.DeleteAdmissionWebhook(ctx, key)
+}
+
+func (o *OperatorACL) GetOperationPolicy(key SiteKey) (storage.OperationPolicy, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindOperationPolicy, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetOperationPolicy(key)
+}
+
+func (o *OperatorACL) UpdateOperationPolicy(ctx context.Context, key SiteKey, policy storage.OperationPolicy) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindOperationPolicy, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateOperationPolicy(ctx, key, policy)
+}
+
+func (o *OperatorACL) DeleteOperationPolicy(ctx context.Context, key SiteKey) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindOperationPolicy, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.DeleteOperationPolicy(ctx, key)
+}
+
+func (o *OperatorACL) GetRegistryConfig(key SiteKey) (storage.RegistryConfig, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindRegistryConfig, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetRegistryConfig(key)
+}
+
+func (o *OperatorACL) UpdateRegistryConfig(ctx context.Context, key SiteKey, config storage.RegistryConfig) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindRegistryConfig, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateRegistryConfig(ctx, key, config)
+}
+
+func (o *OperatorACL) DeleteRegistryConfig(ctx context.Context, key SiteKey) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindRegistryConfig, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.DeleteRegistryConfig(ctx, key)
+}
+
+func (o *OperatorACL) GetPersistentStorage(key SiteKey) (storage.PersistentStorage, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindPersistentStorage, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetPersistentStorage(key)
+}
+
+func (o *OperatorACL) UpdatePersistentStorage(ctx context.Context, req UpdatePersistentStorageRequest) error {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindPersistentStorage, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdatePersistentStorage(ctx, req)
+}
+
+// PatchPersistentStorage applies the JSON merge patch to the cluster
+// persistent storage configuration and validates the result
+func (o *OperatorACL) PatchPersistentStorage(ctx context.Context, req PatchResourceRequest) (*PatchResourceResponse, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindPersistentStorage, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.PatchPersistentStorage(ctx, req)
+}
+
+func (o *OperatorACL) GetRetentionPolicy(key SiteKey) (storage.RetentionPolicy, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindRetentionPolicy, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetRetentionPolicy(key)
+}
+
+func (o *OperatorACL) UpdateRetentionPolicy(ctx context.Context, key SiteKey, policy storage.RetentionPolicy) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindRetentionPolicy, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateRetentionPolicy(ctx, key, policy)
+}
+
+func (o *OperatorACL) DeleteRetentionPolicy(ctx context.Context, key SiteKey) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindRetentionPolicy, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.DeleteRetentionPolicy(ctx, key)
+}
+
+func (o *OperatorACL) VerifyNodeRemoval(ctx context.Context, req VerifyNodeRemovalRequest) (*NodeRemovalStatus, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.VerifyNodeRemoval(ctx, req)
+}
+
+func (o *OperatorACL) CreateNodeTombstone(ctx context.Context, key SiteKey, tombstone NodeTombstone) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.CreateNodeTombstone(ctx, key, tombstone)
+}
+
+func (o *OperatorACL) GetNodeTombstones(key SiteKey) ([]NodeTombstone, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetNodeTombstones(key)
+}
+
+func (o *OperatorACL) DeleteNodeTombstone(ctx context.Context, key SiteKey, hostname string) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.DeleteNodeTombstone(ctx, key, hostname)
+}
+
+func (o *OperatorACL) SimulateNodeRemoval(ctx context.Context, req SimulateNodeRemovalRequest) (*NodeRemovalSimulation, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.SimulateNodeRemoval(ctx, req)
+}
+
+func (o *OperatorACL) UpsertInstallResources(ctx context.Context, req UpsertInstallResourcesRequest) error {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpsertInstallResources(ctx, req)
+}
+
+func (o *OperatorACL) GetInstallResources(key SiteKey) ([]storage.UnknownResource, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetInstallResources(key)
+}
+
+func (o *OperatorACL) ApproveOperation(ctx context.Context, req ApproveOperationRequest) (*storage.OperationApproval, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.ApproveOperation(ctx, req)
+}
+
+func (o *OperatorACL) GetLogLevels(key SiteKey) (logging.Levels, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetLogLevels(key)
+}
+
+func (o *OperatorACL) UpdateLogLevels(ctx context.Context, req UpdateLogLevelsRequest) error {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateLogLevels(ctx, req)
+}
+
 func (o *OperatorACL) GetAlerts(key SiteKey) ([]storage.Alert, error) {
 	if err := o.ClusterAction(key.SiteDomain, storage.KindAlert, teleservices.VerbList); err != nil {
 		return nil, trace.Wrap(err)
@@ -821,6 +1165,14 @@ func (o *OperatorACL) UpdateClusterConfiguration(req UpdateClusterConfigRequest)
 	return o.operator.UpdateClusterConfiguration(req)
 }
 
+// PatchClusterConfiguration applies the JSON merge patch to the cluster configuration
+func (o *OperatorACL) PatchClusterConfiguration(ctx context.Context, req PatchResourceRequest) (*PatchResourceResponse, error) {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindClusterConfiguration, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.PatchClusterConfiguration(ctx, req)
+}
+
 func (o *OperatorACL) GetApplicationEndpoints(key SiteKey) ([]Endpoint, error) {
 	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
 		return nil, trace.Wrap(err)
@@ -999,7 +1351,11 @@ func (o *OperatorACL) UpsertGithubConnector(ctx context.Context, key SiteKey, co
 //
 // Returned connector exclude client secret unless withSecrets is true.
 func (o *OperatorACL) GetGithubConnector(key SiteKey, name string, withSecrets bool) (teleservices.GithubConnector, error) {
-	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+	if withSecrets {
+		if err := o.authConnectorSecretsAction(key, teleservices.KindGithubConnector); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	} else if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
 		if err := o.AuthConnectorActions(teleservices.KindGithubConnector, teleservices.VerbRead); err != nil {
 			return nil, trace.Wrap(err)
 		}
@@ -1011,7 +1367,11 @@ func (o *OperatorACL) GetGithubConnector(key SiteKey, name string, withSecrets b
 //
 // Returned connectors exclude client secret unless withSecrets is true.
 func (o *OperatorACL) GetGithubConnectors(key SiteKey, withSecrets bool) ([]teleservices.GithubConnector, error) {
-	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+	if withSecrets {
+		if err := o.authConnectorSecretsAction(key, teleservices.KindGithubConnector); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	} else if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
 		if err := o.AuthConnectorActions(teleservices.KindGithubConnector, teleservices.VerbList, teleservices.VerbRead); err != nil {
 			return nil, trace.Wrap(err)
 		}
@@ -1019,6 +1379,16 @@ func (o *OperatorACL) GetGithubConnectors(key SiteKey, withSecrets bool) ([]tele
 	return o.operator.GetGithubConnectors(key, withSecrets)
 }
 
+// authConnectorSecretsAction checks access to the secrets of auth connectors
+// of the specified kind: reading secrets requires the permission
+// to modify the connectors
+func (o *OperatorACL) authConnectorSecretsAction(key SiteKey, kind string) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(o.AuthConnectorActions(kind, teleservices.VerbUpdate))
+	}
+	return nil
+}
+
 // DeleteGithubConnector deletes a Github connector by name
 func (o *OperatorACL) DeleteGithubConnector(ctx context.Context, key SiteKey, name string) error {
 	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
@@ -1029,6 +1399,58 @@ func (o *OperatorACL) DeleteGithubConnector(ctx context.Context, key SiteKey, na
 	return o.operator.DeleteGithubConnector(ctx, key, name)
 }
 
+// UpsertAuthConnector creates or updates an OIDC or SAML connector
+func (o *OperatorACL) UpsertAuthConnector(ctx context.Context, key SiteKey, connector teleservices.Resource) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		if err := o.AuthConnectorActions(AuthConnectorKind(connector), teleservices.VerbCreate, teleservices.VerbUpdate); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return o.operator.UpsertAuthConnector(ctx, key, connector)
+}
+
+// GetAuthConnector returns the connector of the specified kind by name
+//
+// Returned connector excludes secrets unless withSecrets is true.
+func (o *OperatorACL) GetAuthConnector(key SiteKey, kind, name string, withSecrets bool) (teleservices.Resource, error) {
+	if withSecrets {
+		if err := o.authConnectorSecretsAction(key, kind); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	} else if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		if err := o.AuthConnectorActions(kind, teleservices.VerbRead); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return o.operator.GetAuthConnector(key, kind, name, withSecrets)
+}
+
+// GetAuthConnectors returns all connectors of the specified kind
+//
+// Returned connectors exclude secrets unless withSecrets is true.
+func (o *OperatorACL) GetAuthConnectors(key SiteKey, kind string, withSecrets bool) ([]teleservices.Resource, error) {
+	if withSecrets {
+		if err := o.authConnectorSecretsAction(key, kind); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	} else if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbRead); err != nil {
+		if err := o.AuthConnectorActions(kind, teleservices.VerbList, teleservices.VerbRead); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return o.operator.GetAuthConnectors(key, kind, withSecrets)
+}
+
+// DeleteAuthConnector deletes the connector of the specified kind by name
+func (o *OperatorACL) DeleteAuthConnector(ctx context.Context, key SiteKey, kind, name string) error {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		if err := o.AuthConnectorActions(kind, teleservices.VerbDelete); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return o.operator.DeleteAuthConnector(ctx, key, kind, name)
+}
+
 // UpsertAuthGateway updates auth gateway configuration.
 func (o *OperatorACL) UpsertAuthGateway(ctx context.Context, key SiteKey, gw storage.AuthGateway) error {
 	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
@@ -1070,6 +1492,65 @@ func (o *OperatorACL) EmitAuditEvent(ctx context.Context, req AuditEventRequest)
 	return o.operator.EmitAuditEvent(ctx, req)
 }
 
+// CreateDownloadToken creates a new token granting access
+// to download the installer of the specified application.
+// Only users with access to the application can share it
+func (o *OperatorACL) CreateDownloadToken(ctx context.Context, req CreateDownloadTokenRequest) (*storage.DownloadToken, error) {
+	if err := o.checker.CheckAccessToRule(o.repoContext(req.Application.Repository), teledefaults.Namespace, storage.KindApp, teleservices.VerbRead, false); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := o.Action(storage.KindToken, teleservices.VerbCreate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.CreateDownloadToken(ctx, req)
+}
+
+// GetDownloadTokens returns active download tokens of the specified account
+func (o *OperatorACL) GetDownloadTokens(ctx context.Context, accountID string) ([]storage.DownloadToken, error) {
+	if err := o.Action(storage.KindToken, teleservices.VerbList); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.GetDownloadTokens(ctx, accountID)
+}
+
+// DeleteDownloadToken deletes the specified download token
+func (o *OperatorACL) DeleteDownloadToken(ctx context.Context, req DeleteDownloadTokenRequest) error {
+	if err := o.Action(storage.KindToken, teleservices.VerbDelete); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.DeleteDownloadToken(ctx, req)
+}
+
+// CreateOperationToken issues a new token granting access to the plan
+// and progress of the specified operation
+func (o *OperatorACL) CreateOperationToken(ctx context.Context, key SiteOperationKey) (*storage.OperationToken, error) {
+	if err := o.ClusterAction(key.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return o.operator.CreateOperationToken(ctx, key)
+}
+
+// CheckAccess returns an access denied error if the current user
+// is not allowed to perform the specified action in the cluster
+func (o *OperatorACL) CheckAccess(ctx context.Context, req CheckAccessRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := o.ClusterAction(req.SiteDomain, req.Kind, req.Verb); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.CheckAccess(ctx, req)
+}
+
+// UpdateLabels sets and removes labels of the cluster
+// or of the cluster node specified in the request
+func (o *OperatorACL) UpdateLabels(ctx context.Context, req UpdateLabelsRequest) error {
+	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
+		return trace.Wrap(err)
+	}
+	return o.operator.UpdateLabels(ctx, req)
+}
+
 // CreateUserInvite creates a new invite token for a user.
 func (o *OperatorACL) CreateUserInvite(ctx context.Context, req CreateUserInviteRequest) (*storage.UserToken, error) {
 	if err := o.ClusterAction(req.SiteDomain, storage.KindCluster, teleservices.VerbUpdate); err != nil {
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/users"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+type OperatorACLSuite struct{}
+
+var _ = check.Suite(&OperatorACLSuite{})
+
+func (s *OperatorACLSuite) TestReadOnlyRoleCannotObtainCredentials(c *check.C) {
+	role, err := users.NewReadOnlyRole()
+	c.Assert(err, check.IsNil)
+	user := storage.NewUser("alice@example.com", storage.UserSpecV2{
+		Type:  storage.AdminUser,
+		Roles: []string{role.GetName()},
+	})
+	operator := OperatorWithACL(&testOperator{}, nil, user, teleservices.NewRoleSet(role))
+	key := SiteKey{AccountID: "account", SiteDomain: "example.com"}
+
+	_, err = operator.GetClusterAgent(ClusterAgentRequest{ClusterName: key.SiteDomain})
+	c.Assert(err, check.IsNil)
+	_, err = operator.GetClusterAgent(ClusterAgentRequest{ClusterName: key.SiteDomain, Admin: true})
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true, check.Commentf("%v", err))
+
+	_, err = operator.GetTrustedClusterToken(key)
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true, check.Commentf("%v", err))
+
+	_, err = operator.CreateOperationToken(context.TODO(), SiteOperationKey{
+		AccountID:   key.AccountID,
+		SiteDomain:  key.SiteDomain,
+		OperationID: "operation",
+	})
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true, check.Commentf("%v", err))
+
+	_, err = operator.GetGithubConnectors(key, false)
+	c.Assert(err, check.IsNil)
+	_, err = operator.GetGithubConnectors(key, true)
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+// testOperator implements the parts of the operator used by the ACL tests
+type testOperator struct {
+	Operator
+}
+
+func (r *testOperator) GetSiteByDomain(domain string) (*Site, error) {
+	return &Site{AccountID: "account", Domain: domain}, nil
+}
+
+func (r *testOperator) GetClusterAgent(ClusterAgentRequest) (*storage.LoginEntry, error) {
+	return &storage.LoginEntry{}, nil
+}
+
+func (r *testOperator) GetGithubConnectors(SiteKey, bool) ([]teleservices.GithubConnector, error) {
+	return nil, nil
+}
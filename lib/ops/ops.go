@@ -30,6 +30,7 @@ import (
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/logging"
 	"github.com/gravitational/gravity/lib/network/validation/proto"
 	"github.com/gravitational/gravity/lib/ops/monitoring"
 	"github.com/gravitational/gravity/lib/pack"
@@ -121,6 +122,19 @@ type Operator interface {
 	LogForwarders
 	Monitoring
 	SMTP
+	HealthReports
+	AdmissionWebhooks
+	OperationPolicies
+	RegistryConfigs
+	RetentionPolicies
+	PersistentStorage
+	NodeRemovals
+	InstallResources
+	LogLevels
+	OperationApprovals
+	DownloadTokens
+	OperationTokens
+	AccessChecker
 	Endpoints
 	Tokens
 	Certificates
@@ -131,6 +145,7 @@ type Operator interface {
 	RuntimeEnvironment
 	ClusterConfiguration
 	Audit
+	Labels
 }
 
 // Accounts represents a collection of accounts in the portal
@@ -410,12 +425,40 @@ type Sites interface {
 	// an application
 	ActivateSite(ActivateSiteRequest) error
 
+	// EnableComponent starts the operation that installs the optional
+	// application component into the cluster
+	EnableComponent(context.Context, ComponentRequest) (*SiteOperationKey, error)
+
+	// DisableComponent starts the operation that uninstalls the optional
+	// application component from the cluster
+	DisableComponent(context.Context, ComponentRequest) (*SiteOperationKey, error)
+
+	// UpdateClusterLicense validates and installs a new license into the cluster
+	UpdateClusterLicense(UpdateLicenseRequest) error
+
 	// CompleteFinalInstallStep marks the site as having completed the mandatory last installation step
 	CompleteFinalInstallStep(CompleteFinalInstallStepRequest) error
 
 	// GetSiteReport returns a tarball that contains all debugging information gathered for the site
 	GetSiteReport(SiteKey) (io.ReadCloser, error)
 
+	// UploadClusterReport stores the diagnostics report of the specified
+	// cluster read from the provided reader
+	UploadClusterReport(SiteKey, io.Reader) error
+
+	// ImportMigration verifies the migration archive read from the provided
+	// reader and starts the operation that imports the application data
+	// from it into the specified cluster
+	ImportMigration(ImportMigrationRequest, io.Reader) (*SiteOperationKey, error)
+
+	// GetChartIndex returns the index file of the Helm chart repository
+	// of the specified cluster
+	GetChartIndex(SiteKey) (io.ReadCloser, error)
+
+	// FetchChart returns the specified application as a Helm chart tarball
+	// from the chart repository of the specified cluster
+	FetchChart(SiteKey, loc.Locator) (io.ReadCloser, error)
+
 	// SignTLSKey signs X509 Public Key with X509 certificate authority of this site
 	SignTLSKey(TLSSignRequest) (*TLSSignResponse, error)
 
@@ -556,6 +599,82 @@ type ActivateSiteRequest struct {
 	StartApp bool `json:"start_app"`
 }
 
+// ComponentRequest is a request to enable or disable an optional
+// application component
+type ComponentRequest struct {
+	// AccountID is the ID of the account the site belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the site
+	SiteDomain string `json:"site_domain"`
+	// Component is the name of the application component
+	Component string `json:"component"`
+}
+
+// Check validates this request
+func (r ComponentRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if r.Component == "" {
+		return trace.BadParameter("missing component name")
+	}
+	return nil
+}
+
+// SiteKey returns the key of the cluster the request is for
+func (r ComponentRequest) SiteKey() SiteKey {
+	return SiteKey{
+		AccountID:  r.AccountID,
+		SiteDomain: r.SiteDomain,
+	}
+}
+
+// UpdateLicenseRequest is a request to install a new cluster license
+type UpdateLicenseRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster
+	SiteDomain string `json:"site_domain"`
+	// License is the raw license string
+	License string `json:"license"`
+}
+
+// Check validates this request
+func (r UpdateLicenseRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if r.License == "" {
+		return trace.BadParameter("missing license")
+	}
+	return nil
+}
+
+// ImportMigrationRequest describes the import of the application data
+// migrated from another cluster
+type ImportMigrationRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster to import the data into
+	SiteDomain string `json:"site_domain"`
+	// StorageClasses maps storage classes of the source cluster
+	// to the storage classes of this cluster
+	StorageClasses map[string]string `json:"storage_classes,omitempty"`
+}
+
+// Check validates this request
+func (r ImportMigrationRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	return nil
+}
+
+// SiteKey returns the key of the cluster to import the data into
+func (r ImportMigrationRequest) SiteKey() SiteKey {
+	return SiteKey{AccountID: r.AccountID, SiteDomain: r.SiteDomain}
+}
+
 // CompleteFinalInstallStepRequest is a request to mark site final install step as completed
 type CompleteFinalInstallStepRequest struct {
 	// AccountID is the ID of the account the site belongs to
@@ -611,6 +730,8 @@ type ClusterConfiguration interface {
 	GetClusterConfiguration(SiteKey) (clusterconfig.Interface, error)
 	// UpdateClusterConfiguration updates the cluster configuration from the specified request
 	UpdateClusterConfiguration(UpdateClusterConfigRequest) error
+	// PatchClusterConfiguration applies the JSON merge patch to the cluster configuration
+	PatchClusterConfiguration(context.Context, PatchResourceRequest) (*PatchResourceResponse, error)
 }
 
 // ClusterCertificate represents the cluster certificate
@@ -683,6 +804,24 @@ type Status interface {
 	CheckSiteStatus(ctx context.Context, key SiteKey) error
 	// GetClusterNodes returns a real-time information about cluster nodes
 	GetClusterNodes(SiteKey) ([]Node, error)
+	// GetClusterInventory returns hardware and software inventory of cluster nodes
+	GetClusterInventory(SiteKey) (*ClusterInventory, error)
+	// GetClusterProbes returns the last results of the health probes on cluster nodes
+	GetClusterProbes(SiteKey) (*ClusterProbes, error)
+	// GetStorageCapacity returns the block storage capacity available
+	// for persistent volumes on cluster nodes
+	GetStorageCapacity(StorageCapacityRequest) (*StorageCapacity, error)
+	// GetStorageUsage returns the persistent storage capacity and usage
+	// of the application namespaces
+	GetStorageUsage(SiteKey) (*StorageUsage, error)
+	// GetNodeCertificates returns the expiration of the certificates
+	// installed on cluster nodes
+	GetNodeCertificates(SiteKey) ([]NodeCertificates, error)
+	// GetClusterSummaries returns summaries of all clusters managed by this operator
+	GetClusterSummaries(accountID string) ([]ClusterSummary, error)
+	// GetClusterSummary returns the summary of the specified cluster
+	// including its nodes and operations
+	GetClusterSummary(SiteKey) (*ClusterSummary, error)
 }
 
 // Node represents a cluster node information based on Teleport node
@@ -740,6 +879,14 @@ type Operations interface {
 	// in the cluster
 	CreateClusterGarbageCollectOperation(context.Context, CreateClusterGarbageCollectOperationRequest) (*SiteOperationKey, error)
 
+	// CreatePatchOperation creates a new operation to patch the operating
+	// system on cluster nodes one node at a time
+	CreatePatchOperation(context.Context, CreatePatchOperationRequest) (*SiteOperationKey, error)
+
+	// CreateUpdateBinaryOperation creates a new operation to replace
+	// the gravity binary on cluster nodes one node at a time
+	CreateUpdateBinaryOperation(context.Context, CreateUpdateBinaryOperationRequest) (*SiteOperationKey, error)
+
 	// GetsiteOperation returns the operation information based on it's key
 	GetSiteOperation(SiteOperationKey) (*SiteOperation, error)
 
@@ -750,6 +897,11 @@ type Operations interface {
 	// related to this operation periodically
 	GetSiteOperationLogs(SiteOperationKey) (io.ReadCloser, error)
 
+	// GetOperationArtifacts returns a gzipped tarball with the artifacts
+	// of the specified operation: the operation record, plan, last progress
+	// entry and logs
+	GetOperationArtifacts(SiteOperationKey) (io.ReadCloser, error)
+
 	// CreateLogEntry appends the provided log entry to the operation's log file
 	CreateLogEntry(SiteOperationKey, LogEntry) error
 
@@ -921,6 +1073,10 @@ type RotateSecretsRequest struct {
 	Locator *loc.Locator `json:"locator,omitempty"`
 	// DryRun specifies whether only the package locator is generated
 	DryRun bool `json:"dry_run"`
+	// ServiceCIDR optionally overrides the service network
+	// used to generate the API server certificate.
+	// Defaults to the service network of the cluster configuration
+	ServiceCIDR string `json:"service_cidr,omitempty"`
 }
 
 // SiteKey returns a cluster key from this request
@@ -1002,6 +1158,8 @@ type ConfigurePackagesRequest struct {
 	Env map[string]string `json:"env,omitempty"`
 	// Config specifies optional cluster configuration resource in raw form
 	Config []byte `json:"config,omitempty"`
+	// DNS specifies optional cluster DNS configuration resource in raw form
+	DNS []byte `json:"dns,omitempty"`
 }
 
 // Proxy helps to manage connections and clients to remote ops centers
@@ -1075,6 +1233,12 @@ func (s *SiteOperation) IsFinished() bool {
 	return s.State == OperationStateCompleted || s.State == OperationStateFailed
 }
 
+// IsQueued returns true if the operation is waiting for the conflicting
+// operations in progress to complete
+func (s *SiteOperation) IsQueued() bool {
+	return s.State == OperationStateQueued
+}
+
 // IsAWS returns true if the operation has AWS provisioner
 func (s *SiteOperation) IsAWS() bool {
 	return utils.StringInSlice([]string{
@@ -1142,6 +1306,18 @@ func (s *SiteOperation) TypeString() string {
 		return "update runtime environment"
 	case OperationUpdateConfig:
 		return "update configuration"
+	case OperationPatch:
+		return "patch"
+	case OperationUpdateBinary:
+		return "update gravity binary"
+	case OperationRotateCertificates:
+		return "rotate certificates"
+	case OperationImportMigration:
+		return "import application data"
+	case OperationEnableComponent:
+		return "enable component"
+	case OperationDisableComponent:
+		return "disable component"
 	default:
 		return s.Type
 	}
@@ -1285,6 +1461,9 @@ type CreateSiteShrinkOperationRequest struct {
 	// Used in cases where we recieve an event where the node is being terminated, but may
 	// not have disconnected from the cluster yet.
 	NodeRemoved bool `json:"node_removed"`
+	// SkipCapacityCheck allows to remove the node even if the remaining nodes
+	// do not have the capacity to host the evicted pods
+	SkipCapacityCheck bool `json:"skip_capacity_check,omitempty"`
 }
 
 // CheckAndSetDefaults makes sure the request is correct and fills in some unset
@@ -1316,6 +1495,13 @@ type CreateSiteAppUpdateOperationRequest struct {
 	App string `json:"package"`
 	// StartAgents specifies whether the operation will automatically start the update agents
 	StartAgents bool `json:"start_agents"`
+	// CatchUp specifies whether the operation brings a node previously excluded
+	// from an update to the installed application version.
+	// The App must then be the currently installed application
+	CatchUp bool `json:"catch_up,omitempty"`
+	// Throttle specifies how much the resource usage of the operation
+	// on cluster nodes is throttled: none, low or medium
+	Throttle string `json:"throttle,omitempty"`
 }
 
 // Check validates this request
@@ -1338,6 +1524,53 @@ type CreateClusterGarbageCollectOperationRequest struct {
 	ClusterName string `json:"cluster_name"`
 }
 
+// Check validates this request
+func (r CreatePatchOperationRequest) Check() error {
+	if err := r.ClusterKey.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Command == "" && !r.Reboot {
+		return trace.BadParameter("either a patch command or a reboot is required")
+	}
+	if r.Command != "" && r.Reboot {
+		return trace.BadParameter("patch command and reboot are mutually exclusive")
+	}
+	return nil
+}
+
+// CreatePatchOperationRequest is a request
+// to patch the operating system on cluster nodes
+type CreatePatchOperationRequest struct {
+	// ClusterKey identifies the cluster
+	ClusterKey SiteKey `json:"cluster_key"`
+	// Command specifies the shell command to run on each node
+	Command string `json:"command,omitempty"`
+	// Reboot specifies whether each node is rebooted instead of running a command
+	Reboot bool `json:"reboot,omitempty"`
+}
+
+// Check validates this request
+func (r CreateUpdateBinaryOperationRequest) Check() error {
+	if err := r.ClusterKey.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Package.Name != constants.GravityPackage {
+		return trace.BadParameter("expected %v package but got %v",
+			constants.GravityPackage, r.Package)
+	}
+	return nil
+}
+
+// CreateUpdateBinaryOperationRequest is a request
+// to replace the gravity binary on cluster nodes
+type CreateUpdateBinaryOperationRequest struct {
+	// ClusterKey identifies the cluster
+	ClusterKey SiteKey `json:"cluster_key"`
+	// Package specifies the gravity binary package to install on nodes.
+	// The package must be available in the cluster package service
+	Package loc.Locator `json:"package"`
+}
+
 // CreateUpdateEnvarsOperationRequest is a request
 // to update cluster environment variables
 type CreateUpdateEnvarsOperationRequest struct {
@@ -1345,6 +1578,9 @@ type CreateUpdateEnvarsOperationRequest struct {
 	ClusterKey SiteKey `json:"cluster_key"`
 	// Env specifies the new cluster environment variables
 	Env map[string]string `json:"env"`
+	// Revision optionally specifies the revision of the environment
+	// the update is based on
+	Revision string `json:"revision,omitempty"`
 }
 
 // CreateUpdateConfigOperationRequest is a request
@@ -1354,6 +1590,9 @@ type CreateUpdateConfigOperationRequest struct {
 	ClusterKey SiteKey `json:"cluster_key"`
 	// Config specifies the new configuration as JSON-encoded payload
 	Config []byte `json:"config"`
+	// Revision optionally specifies the revision of the configuration
+	// the update is based on
+	Revision string `json:"revision,omitempty"`
 }
 
 // UpdateClusterEnvironRequest is a request
@@ -1544,6 +1783,17 @@ type NewSiteRequest struct {
 	DNSConfig storage.DNSConfig `json:"dns_config"`
 	// Docker specifies the cluster Docker configuration
 	Docker storage.DockerConfig `json:"docker"`
+	// DisabledComponents lists the optional application components
+	// to exclude from the cluster
+	DisabledComponents []string `json:"disabled_components,omitempty"`
+	// DefaultDenyNetworkPolicy specifies whether to deploy the baseline
+	// network policies
+	DefaultDenyNetworkPolicy bool `json:"default_deny_network_policy,omitempty"`
+	// CNI is the network plugin to install the cluster with.
+	// Defaults to the plugin configured in the application manifest
+	CNI string `json:"cni,omitempty"`
+	// SELinux specifies whether to configure SELinux on the cluster nodes
+	SELinux bool `json:"selinux,omitempty"`
 }
 
 // SiteKey is a key used to identify site
@@ -1641,6 +1891,20 @@ type Site struct {
 	DNSConfig storage.DNSConfig `json:"dns_config"`
 	// InstallToken specifies the original token the cluster was installed with
 	InstallToken string `json:"install_token"`
+	// DisabledComponents lists the optional application components
+	// excluded from the cluster
+	DisabledComponents []string `json:"disabled_components,omitempty"`
+	// DefaultDenyNetworkPolicy specifies whether the baseline network policies
+	// have been requested during installation
+	DefaultDenyNetworkPolicy bool `json:"default_deny_network_policy,omitempty"`
+	// InstallResources lists the resources (TLS key pair, auth connectors, users)
+	// uploaded before installation to be created at the end of install
+	InstallResources []storage.UnknownResource `json:"install_resources,omitempty"`
+	// CNI is the network plugin the cluster has been installed with.
+	// Empty for clusters installed without an explicit plugin selection
+	CNI string `json:"cni,omitempty"`
+	// SELinux specifies whether SELinux is configured on the cluster nodes
+	SELinux bool `json:"selinux,omitempty"`
 }
 
 // IsOnline returns whether this site is online
@@ -1891,6 +2155,82 @@ type SMTP interface {
 	DeleteSMTPConfig(context.Context, SiteKey) error
 }
 
+// HealthReports defines the interface to manage scheduled cluster health reports
+type HealthReports interface {
+	// GetHealthReport returns the cluster health report configuration
+	GetHealthReport(SiteKey) (storage.HealthReport, error)
+	// UpdateHealthReport updates the cluster health report configuration
+	UpdateHealthReport(context.Context, SiteKey, storage.HealthReport) error
+	// DeleteHealthReport deletes the cluster health report configuration
+	DeleteHealthReport(context.Context, SiteKey) error
+}
+
+// AdmissionWebhooks defines the interface to manage the resource admission webhook
+type AdmissionWebhooks interface {
+	// GetAdmissionWebhook returns the resource admission webhook
+	GetAdmissionWebhook(SiteKey) (storage.AdmissionWebhook, error)
+	// UpdateAdmissionWebhook updates the resource admission webhook
+	UpdateAdmissionWebhook(context.Context, SiteKey, storage.AdmissionWebhook) error
+	// DeleteAdmissionWebhook deletes the resource admission webhook
+	DeleteAdmissionWebhook(context.Context, SiteKey) error
+}
+
+// OperationPolicies defines the interface to manage the cluster operation policy
+type OperationPolicies interface {
+	// GetOperationPolicy returns the cluster operation policy
+	GetOperationPolicy(SiteKey) (storage.OperationPolicy, error)
+	// UpdateOperationPolicy updates the cluster operation policy
+	UpdateOperationPolicy(context.Context, SiteKey, storage.OperationPolicy) error
+	// DeleteOperationPolicy deletes the cluster operation policy
+	DeleteOperationPolicy(context.Context, SiteKey) error
+}
+
+// RegistryConfigs defines the interface to manage the cluster registry configuration
+type RegistryConfigs interface {
+	// GetRegistryConfig returns the cluster registry configuration
+	GetRegistryConfig(SiteKey) (storage.RegistryConfig, error)
+	// UpdateRegistryConfig updates the cluster registry configuration
+	UpdateRegistryConfig(context.Context, SiteKey, storage.RegistryConfig) error
+	// DeleteRegistryConfig resets the cluster registry configuration to defaults
+	DeleteRegistryConfig(context.Context, SiteKey) error
+}
+
+// RetentionPolicies defines the interface to manage the cluster retention policy
+type RetentionPolicies interface {
+	// GetRetentionPolicy returns the cluster retention policy
+	GetRetentionPolicy(SiteKey) (storage.RetentionPolicy, error)
+	// UpdateRetentionPolicy updates the cluster retention policy
+	UpdateRetentionPolicy(context.Context, SiteKey, storage.RetentionPolicy) error
+	// DeleteRetentionPolicy deletes the cluster retention policy
+	DeleteRetentionPolicy(context.Context, SiteKey) error
+}
+
+// PersistentStorage defines the interface to manage the cluster persistent storage configuration
+type PersistentStorage interface {
+	// GetPersistentStorage returns the cluster persistent storage configuration
+	GetPersistentStorage(SiteKey) (storage.PersistentStorage, error)
+	// UpdatePersistentStorage validates and updates the cluster persistent storage configuration
+	UpdatePersistentStorage(context.Context, UpdatePersistentStorageRequest) error
+	// PatchPersistentStorage applies the JSON merge patch to the cluster
+	// persistent storage configuration and validates the result
+	PatchPersistentStorage(context.Context, PatchResourceRequest) (*PatchResourceResponse, error)
+}
+
+// OperationApprovals defines the interface to approve operations that
+// require approval by a second user under the cluster operation policy
+type OperationApprovals interface {
+	// ApproveOperation approves the operation with the specified approval request
+	ApproveOperation(context.Context, ApproveOperationRequest) (*storage.OperationApproval, error)
+}
+
+// LogLevels defines the interface to manage log levels of cluster controllers
+type LogLevels interface {
+	// GetLogLevels returns the log level overrides of cluster controllers
+	GetLogLevels(SiteKey) (logging.Levels, error)
+	// UpdateLogLevels updates log levels of cluster controllers without restarting them
+	UpdateLogLevels(context.Context, UpdateLogLevelsRequest) error
+}
+
 // Monitoring defines the interface to manage monitoring and metrics
 type Monitoring interface {
 	// GetAlerts returns the list of configured monitoring alerts
@@ -2042,6 +2382,8 @@ type Identity interface {
 	GetGithubConnectors(key SiteKey, withSecrets bool) ([]teleservices.GithubConnector, error)
 	// DeleteGithubConnector deletes a Github connector by name
 	DeleteGithubConnector(ctx context.Context, key SiteKey, name string) error
+	// AuthConnectors manages OIDC and SAML connectors
+	AuthConnectors
 	// UpsertAuthGateway updates auth gateway configuration
 	UpsertAuthGateway(context.Context, SiteKey, storage.AuthGateway) error
 	// GetAuthGateway returns auth gateway configuration
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/roundtrip"
+	telehttplib "github.com/gravitational/teleport/lib/httplib"
+	"github.com/gravitational/trace"
+)
+
+// CreateOperationToken issues a new token granting access to the plan
+// and progress of the specified operation
+func (c *Client) CreateOperationToken(ctx context.Context, key ops.SiteOperationKey) (*storage.OperationToken, error) {
+	out, err := c.PostJSONWithContext(ctx, c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "operations", "common", key.OperationID, "token"),
+		map[string]string{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var token storage.OperationToken
+	if err := json.Unmarshal(out.Bytes(), &token); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &token, nil
+}
+
+// getWithOperationToken issues HTTP GET request to the operation endpoint
+// authenticated with a token scoped to the specified operation.
+//
+// The token is obtained with the client credentials and reused until the server
+// rejects it, e.g. after it has expired or has been rotated upon operation completion.
+// Servers that do not issue operation tokens are queried with the client credentials
+func (c *Client) getWithOperationToken(key ops.SiteOperationKey, endpoint string) (*roundtrip.Response, error) {
+	token, err := c.operationToken(key)
+	if err != nil {
+		if trace.IsNotFound(err) || trace.IsAccessDenied(err) {
+			return c.Get(endpoint, url.Values{})
+		}
+		return nil, trace.Wrap(err)
+	}
+	re, err := c.getWithToken(endpoint, token)
+	if err == nil || !trace.IsAccessDenied(err) {
+		return re, trace.Wrap(err)
+	}
+	c.tokens.reset(key)
+	token, err = c.operationToken(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return c.getWithToken(endpoint, token)
+}
+
+// operationToken returns the cached token for the specified operation
+// or issues a new one
+func (c *Client) operationToken(key ops.SiteOperationKey) (string, error) {
+	if token, ok := c.tokens.get(key); ok {
+		return token, nil
+	}
+	token, err := c.CreateOperationToken(context.TODO(), key)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	c.tokens.set(key, token.Token)
+	return token.Token, nil
+}
+
+// getWithToken issues HTTP GET request to the server authenticated
+// with the provided bearer token instead of the client credentials
+func (c *Client) getWithToken(endpoint, token string) (re *roundtrip.Response, err error) {
+	err = c.withRetry(context.TODO(), http.MethodGet, func() error {
+		re, err = telehttplib.ConvertResponse(c.RoundTrip(func() (*http.Response, error) {
+			req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			req.Header.Set("Authorization", httplib.AuthBearer+" "+token)
+			return c.HTTPClient().Do(req)
+		}))
+		return err
+	})
+	return re, err
+}
+
+// operationTokens caches the tokens issued for operations
+type operationTokens struct {
+	sync.Mutex
+	tokens map[ops.SiteOperationKey]string
+}
+
+func newOperationTokens() *operationTokens {
+	return &operationTokens{tokens: make(map[ops.SiteOperationKey]string)}
+}
+
+func (r *operationTokens) get(key ops.SiteOperationKey) (string, bool) {
+	r.Lock()
+	defer r.Unlock()
+	token, ok := r.tokens[key]
+	return token, ok
+}
+
+func (r *operationTokens) set(key ops.SiteOperationKey, token string) {
+	r.Lock()
+	defer r.Unlock()
+	r.tokens[key] = token
+}
+
+func (r *operationTokens) reset(key ops.SiteOperationKey) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.tokens, key)
+}
@@ -31,10 +31,13 @@ import (
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/logging"
+	"github.com/gravitational/gravity/lib/migration"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/clusterconfig"
+	helmutils "github.com/gravitational/gravity/lib/utils/helm"
 
 	"github.com/gravitational/roundtrip"
 	telehttplib "github.com/gravitational/teleport/lib/httplib"
@@ -47,6 +50,10 @@ const CurrentVersion = "portal/v1"
 type Client struct {
 	roundtrip.Client
 	dialer httplib.Dialer
+	// retry configures retries of the requests failing with transient errors
+	retry *RetryConfig
+	// tokens caches the tokens issued for operations
+	tokens *operationTokens
 }
 
 // NewAuthenticatedClient returns client authenticated as username with the given password
@@ -67,9 +74,11 @@ func NewClient(addr string, params ...ClientParam) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	client := &Client{Client: *c}
+	client := &Client{Client: *c, tokens: newOperationTokens()}
 	for _, param := range params {
-		param(client)
+		if err := param(client); err != nil {
+			return nil, trace.Wrap(err)
+		}
 	}
 	return client, nil
 }
@@ -366,6 +375,112 @@ func (c *Client) GetClusterNodes(key ops.SiteKey) ([]ops.Node, error) {
 	return nodes, nil
 }
 
+// GetClusterInventory returns hardware and software inventory of cluster nodes
+func (c *Client) GetClusterInventory(key ops.SiteKey) (*ops.ClusterInventory, error) {
+	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "inventory"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var inventory ops.ClusterInventory
+	err = json.Unmarshal(out.Bytes(), &inventory)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &inventory, nil
+}
+
+// GetClusterProbes returns the last results of the health probes on cluster nodes
+func (c *Client) GetClusterProbes(key ops.SiteKey) (*ops.ClusterProbes, error) {
+	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "probes"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var probes ops.ClusterProbes
+	err = json.Unmarshal(out.Bytes(), &probes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &probes, nil
+}
+
+// GetStorageCapacity returns the block storage capacity available
+// for persistent volumes on cluster nodes
+func (c *Client) GetStorageCapacity(req ops.StorageCapacityRequest) (*ops.StorageCapacity, error) {
+	out, err := c.Get(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "storage", "capacity"), url.Values{
+		"include_vendor": req.Filter.IncludeVendors,
+		"exclude_vendor": req.Filter.ExcludeVendors,
+		"include_path":   req.Filter.IncludePaths,
+		"exclude_path":   req.Filter.ExcludePaths,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var capacity ops.StorageCapacity
+	err = json.Unmarshal(out.Bytes(), &capacity)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &capacity, nil
+}
+
+// GetStorageUsage returns the persistent storage capacity and usage
+// of the application namespaces
+func (c *Client) GetStorageUsage(key ops.SiteKey) (*ops.StorageUsage, error) {
+	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "storage", "usage"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var usage ops.StorageUsage
+	err = json.Unmarshal(out.Bytes(), &usage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &usage, nil
+}
+
+// GetNodeCertificates returns the expiration of the certificates
+// installed on cluster nodes
+func (c *Client) GetNodeCertificates(key ops.SiteKey) ([]ops.NodeCertificates, error) {
+	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "certificates", "nodes"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var nodes []ops.NodeCertificates
+	err = json.Unmarshal(out.Bytes(), &nodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return nodes, nil
+}
+
+// GetClusterSummaries returns summaries of all clusters managed by the operator
+func (c *Client) GetClusterSummaries(accountID string) ([]ops.ClusterSummary, error) {
+	out, err := c.Get(c.Endpoint("accounts", accountID, "clusters", "summaries"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var summaries []ops.ClusterSummary
+	err = json.Unmarshal(out.Bytes(), &summaries)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return summaries, nil
+}
+
+// GetClusterSummary returns the summary of the specified cluster
+func (c *Client) GetClusterSummary(key ops.SiteKey) (*ops.ClusterSummary, error) {
+	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "summary"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var summary ops.ClusterSummary
+	err = json.Unmarshal(out.Bytes(), &summary)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &summary, nil
+}
+
 func (c *Client) ResetUserPassword(req ops.ResetUserPasswordRequest) (string, error) {
 	out, err := c.PutJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "reset-password"), req)
 	if err != nil {
@@ -419,6 +534,40 @@ func (c *Client) ActivateSite(req ops.ActivateSiteRequest) error {
 	return trace.Wrap(err)
 }
 
+// EnableComponent starts the operation that installs the optional
+// application component into the cluster
+func (c *Client) EnableComponent(ctx context.Context, req ops.ComponentRequest) (*ops.SiteOperationKey, error) {
+	out, err := c.PostJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "components", req.Component, "enable"), req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var key ops.SiteOperationKey
+	if err := json.Unmarshal(out.Bytes(), &key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &key, nil
+}
+
+// UpdateClusterLicense validates and installs a new license into the cluster
+func (c *Client) UpdateClusterLicense(req ops.UpdateLicenseRequest) error {
+	_, err := c.PostJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "license"), req)
+	return trace.Wrap(err)
+}
+
+// DisableComponent starts the operation that uninstalls the optional
+// application component from the cluster
+func (c *Client) DisableComponent(ctx context.Context, req ops.ComponentRequest) (*ops.SiteOperationKey, error) {
+	out, err := c.PostJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "components", req.Component, "disable"), req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var key ops.SiteOperationKey
+	if err := json.Unmarshal(out.Bytes(), &key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &key, nil
+}
+
 // CompleteFinalInstallStep marks the site as having completed the mandatory last installation step
 func (c *Client) CompleteFinalInstallStep(req ops.CompleteFinalInstallStepRequest) error {
 	_, err := c.PostJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "complete"), req)
@@ -560,6 +709,34 @@ func (c *Client) CreateClusterGarbageCollectOperation(ctx context.Context, req o
 	return &key, nil
 }
 
+// CreatePatchOperation creates a new operation to patch the operating system on cluster nodes
+func (c *Client) CreatePatchOperation(ctx context.Context, req ops.CreatePatchOperationRequest) (*ops.SiteOperationKey, error) {
+	out, err := c.PostJSON(c.Endpoint("accounts", req.ClusterKey.AccountID, "sites", req.ClusterKey.SiteDomain, "operations", "patch"), req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var key ops.SiteOperationKey
+	if err := json.Unmarshal(out.Bytes(), &key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &key, nil
+}
+
+// CreateUpdateBinaryOperation creates a new operation to replace the gravity binary on cluster nodes
+func (c *Client) CreateUpdateBinaryOperation(ctx context.Context, req ops.CreateUpdateBinaryOperationRequest) (*ops.SiteOperationKey, error) {
+	out, err := c.PostJSON(c.Endpoint("accounts", req.ClusterKey.AccountID, "sites", req.ClusterKey.SiteDomain, "operations", "binary"), req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var key ops.SiteOperationKey
+	if err := json.Unmarshal(out.Bytes(), &key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &key, nil
+}
+
 // CreateUpdateEnvarsOperation creates a new operation to update cluster runtime environment variables
 func (c *Client) CreateUpdateEnvarsOperation(ctx context.Context, req ops.CreateUpdateEnvarsOperationRequest) (*ops.SiteOperationKey, error) {
 	out, err := c.PostJSON(c.Endpoint("accounts", req.ClusterKey.AccountID, "sites", req.ClusterKey.SiteDomain, "operations", "envars"), req)
@@ -637,6 +814,15 @@ func (c *Client) GetSiteOperationLogs(key ops.SiteOperationKey) (io.ReadCloser,
 	return httplib.SetupWebsocketClient(context.TODO(), &c.Client, endpoint, c.dialer)
 }
 
+// GetOperationArtifacts returns a gzipped tarball with the artifacts of the specified operation
+func (c *Client) GetOperationArtifacts(key ops.SiteOperationKey) (io.ReadCloser, error) {
+	file, err := c.GetFile(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "operations", "common", key.OperationID, "artifacts"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return file.Body(), nil
+}
+
 func (c *Client) CreateLogEntry(key ops.SiteOperationKey, entry ops.LogEntry) error {
 	_, err := c.PostJSON(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "operations", "common", key.OperationID, "logs", "entry"), entry)
 	if err != nil {
@@ -656,7 +842,7 @@ func (c *Client) StreamOperationLogs(key ops.SiteOperationKey, reader io.Reader)
 }
 
 func (c *Client) GetSiteOperationProgress(key ops.SiteOperationKey) (*ops.ProgressEntry, error) {
-	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "operations", "common", key.OperationID, "progress"), url.Values{})
+	out, err := c.getWithOperationToken(key, c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "operations", "common", key.OperationID, "progress"))
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -691,6 +877,57 @@ func (c *Client) GetSiteReport(key ops.SiteKey) (io.ReadCloser, error) {
 	return file.Body(), nil
 }
 
+// UploadClusterReport uploads the diagnostics report of the specified cluster
+func (c *Client) UploadClusterReport(key ops.SiteKey, reader io.Reader) error {
+	_, err := c.PostStream(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "report"), reader)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// ImportMigration streams the migration archive read from the provided
+// reader into the specified cluster and returns the key of the operation
+// that imports it
+func (c *Client) ImportMigration(req ops.ImportMigrationRequest, reader io.Reader) (*ops.SiteOperationKey, error) {
+	endpoint := c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "migration")
+	if len(req.StorageClasses) != 0 {
+		query := url.Values{
+			"storage_class": migration.FormatStorageClasses(req.StorageClasses),
+		}
+		endpoint = fmt.Sprintf("%v?%v", endpoint, query.Encode())
+	}
+	out, err := c.PostStream(endpoint, reader)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var key ops.SiteOperationKey
+	if err := json.Unmarshal(out.Bytes(), &key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &key, nil
+}
+
+// GetChartIndex returns the chart repository index file of the specified cluster
+func (c *Client) GetChartIndex(key ops.SiteKey) (io.ReadCloser, error) {
+	file, err := c.GetFile(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "charts", "index.yaml"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return file.Body(), nil
+}
+
+// FetchChart returns the specified Helm chart from the chart repository
+// of the specified cluster
+func (c *Client) FetchChart(key ops.SiteKey, locator loc.Locator) (io.ReadCloser, error) {
+	file, err := c.GetFile(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "charts",
+		helmutils.ToChartFilename(locator.Name, locator.Version)), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return file.Body(), nil
+}
+
 func (c *Client) UpsertRepository(repository string) error {
 	_, err := c.PostForm(context.TODO(), c.Endpoint("repositories"), url.Values{
 		"name": []string{repository},
@@ -876,9 +1113,8 @@ func (c *Client) CreateOperationPlanChange(key ops.SiteOperationKey, change stor
 
 // GetOperationPlan returns plan for the specified operation
 func (c *Client) GetOperationPlan(key ops.SiteOperationKey) (*storage.OperationPlan, error) {
-	out, err := c.Get(c.Endpoint(
-		"accounts", key.AccountID, "sites", key.SiteDomain, "operations", "common", key.OperationID, "plan"),
-		url.Values{})
+	out, err := c.getWithOperationToken(key, c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "operations", "common", key.OperationID, "plan"))
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -1043,6 +1279,358 @@ func (c *Client) DeleteSMTPConfig(ctx context.Context, key ops.SiteKey) error {
 	return trace.Wrap(err)
 }
 
+// GetHealthReport returns the cluster health report configuration
+func (c *Client) GetHealthReport(key ops.SiteKey) (storage.HealthReport, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "healthreport"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var raw json.RawMessage
+	if err := json.Unmarshal(response.Bytes(), &raw); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	config, err := storage.UnmarshalHealthReport(raw)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return config, nil
+}
+
+// UpdateHealthReport updates the cluster health report configuration
+func (c *Client) UpdateHealthReport(ctx context.Context, key ops.SiteKey, config storage.HealthReport) error {
+	bytes, err := storage.MarshalHealthReport(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = c.PutJSON(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "healthreport"),
+		&UpsertResourceRawReq{Resource: bytes})
+	return trace.Wrap(err)
+}
+
+// DeleteHealthReport deletes the cluster health report configuration
+func (c *Client) DeleteHealthReport(ctx context.Context, key ops.SiteKey) error {
+	_, err := c.Delete(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "healthreport"))
+	return trace.Wrap(err)
+}
+
+// GetAdmissionWebhook returns the resource admission webhook
+func (c *Client) GetAdmissionWebhook(key ops.SiteKey) (storage.AdmissionWebhook, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "admissionwebhook"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var raw json.RawMessage
+	if err := json.Unmarshal(response.Bytes(), &raw); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	webhook, err := storage.UnmarshalAdmissionWebhook(raw)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return webhook, nil
+}
+
+// UpdateAdmissionWebhook updates the resource admission webhook
+func (c *Client) UpdateAdmissionWebhook(ctx context.Context, key ops.SiteKey, webhook storage.AdmissionWebhook) error {
+	bytes, err := storage.MarshalAdmissionWebhook(webhook)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = c.PutJSON(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "admissionwebhook"),
+		&UpsertResourceRawReq{Resource: bytes})
+	return trace.Wrap(err)
+}
+
+// DeleteAdmissionWebhook deletes the resource admission webhook
+func (c *Client) DeleteAdmissionWebhook(ctx context.Context, key ops.SiteKey) error {
+	_, err := c.Delete(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "admissionwebhook"))
+	return trace.Wrap(err)
+}
+
+// GetOperationPolicy returns the cluster operation policy
+func (c *Client) GetOperationPolicy(key ops.SiteKey) (storage.OperationPolicy, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "operationpolicy"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var raw json.RawMessage
+	if err := json.Unmarshal(response.Bytes(), &raw); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	policy, err := storage.UnmarshalOperationPolicy(raw)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return policy, nil
+}
+
+// UpdateOperationPolicy updates the cluster operation policy
+func (c *Client) UpdateOperationPolicy(ctx context.Context, key ops.SiteKey, policy storage.OperationPolicy) error {
+	bytes, err := storage.MarshalOperationPolicy(policy)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = c.PutJSON(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "operationpolicy"),
+		&UpsertResourceRawReq{Resource: bytes})
+	return trace.Wrap(err)
+}
+
+// DeleteOperationPolicy deletes the cluster operation policy
+func (c *Client) DeleteOperationPolicy(ctx context.Context, key ops.SiteKey) error {
+	_, err := c.Delete(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "operationpolicy"))
+	return trace.Wrap(err)
+}
+
+// GetRegistryConfig returns the cluster registry configuration
+func (c *Client) GetRegistryConfig(key ops.SiteKey) (storage.RegistryConfig, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "registry"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var raw json.RawMessage
+	if err := json.Unmarshal(response.Bytes(), &raw); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	config, err := storage.UnmarshalRegistryConfig(raw)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return config, nil
+}
+
+// UpdateRegistryConfig updates the cluster registry configuration
+func (c *Client) UpdateRegistryConfig(ctx context.Context, key ops.SiteKey, config storage.RegistryConfig) error {
+	bytes, err := storage.MarshalRegistryConfig(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = c.PutJSON(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "registry"),
+		&UpsertResourceRawReq{Resource: bytes})
+	return trace.Wrap(err)
+}
+
+// DeleteRegistryConfig resets the cluster registry configuration to defaults
+func (c *Client) DeleteRegistryConfig(ctx context.Context, key ops.SiteKey) error {
+	_, err := c.Delete(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "registry"))
+	return trace.Wrap(err)
+}
+
+// GetPersistentStorage returns the cluster persistent storage configuration
+func (c *Client) GetPersistentStorage(key ops.SiteKey) (storage.PersistentStorage, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "persistentstorage"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var raw json.RawMessage
+	if err := json.Unmarshal(response.Bytes(), &raw); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	config, err := storage.UnmarshalPersistentStorage(raw)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return config, nil
+}
+
+// UpdatePersistentStorage validates and updates the cluster persistent storage configuration
+func (c *Client) UpdatePersistentStorage(ctx context.Context, req ops.UpdatePersistentStorageRequest) error {
+	bytes, err := storage.MarshalPersistentStorage(req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = c.PutJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "persistentstorage"),
+		&UpdatePersistentStorageRawReq{Resource: bytes, Force: req.Force})
+	return trace.Wrap(err)
+}
+
+// PatchPersistentStorage applies the JSON merge patch to the cluster
+// persistent storage configuration and validates the result
+func (c *Client) PatchPersistentStorage(ctx context.Context, req ops.PatchResourceRequest) (*ops.PatchResourceResponse, error) {
+	return c.patchResource(ctx, c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "persistentstorage"), req)
+}
+
+// GetRetentionPolicy returns the cluster retention policy
+func (c *Client) GetRetentionPolicy(key ops.SiteKey) (storage.RetentionPolicy, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "retention"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var raw json.RawMessage
+	if err := json.Unmarshal(response.Bytes(), &raw); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	policy, err := storage.UnmarshalRetentionPolicy(raw)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return policy, nil
+}
+
+// UpdateRetentionPolicy updates the cluster retention policy
+func (c *Client) UpdateRetentionPolicy(ctx context.Context, key ops.SiteKey, policy storage.RetentionPolicy) error {
+	bytes, err := storage.MarshalRetentionPolicy(policy)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = c.PutJSON(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "retention"),
+		&UpsertResourceRawReq{Resource: bytes})
+	return trace.Wrap(err)
+}
+
+// DeleteRetentionPolicy deletes the cluster retention policy
+func (c *Client) DeleteRetentionPolicy(ctx context.Context, key ops.SiteKey) error {
+	_, err := c.Delete(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "retention"))
+	return trace.Wrap(err)
+}
+
+// VerifyNodeRemoval verifies that the node has been removed from
+// the etcd cluster, Kubernetes and Teleport
+func (c *Client) VerifyNodeRemoval(ctx context.Context, req ops.VerifyNodeRemovalRequest) (*ops.NodeRemovalStatus, error) {
+	response, err := c.PostJSON(c.Endpoint(
+		"accounts", req.AccountID, "sites", req.SiteDomain, "noderemoval", "verify"), req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var status ops.NodeRemovalStatus
+	if err := json.Unmarshal(response.Bytes(), &status); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &status, nil
+}
+
+// CreateNodeTombstone records the node that has left the cluster
+// forcibly for later reconciliation
+func (c *Client) CreateNodeTombstone(ctx context.Context, key ops.SiteKey, tombstone ops.NodeTombstone) error {
+	_, err := c.PostJSON(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "tombstones"), tombstone)
+	return trace.Wrap(err)
+}
+
+// GetNodeTombstones returns the nodes that have left the cluster forcibly
+// and have not been reconciled yet
+func (c *Client) GetNodeTombstones(key ops.SiteKey) ([]ops.NodeTombstone, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "tombstones"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var tombstones []ops.NodeTombstone
+	if err := json.Unmarshal(response.Bytes(), &tombstones); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return tombstones, nil
+}
+
+// DeleteNodeTombstone deletes the tombstone of the node with the specified hostname
+func (c *Client) DeleteNodeTombstone(ctx context.Context, key ops.SiteKey, hostname string) error {
+	_, err := c.Delete(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "tombstones", hostname))
+	return trace.Wrap(err)
+}
+
+// SimulateNodeRemoval reports the impact of removing the node from the cluster
+// without making any changes
+func (c *Client) SimulateNodeRemoval(ctx context.Context, req ops.SimulateNodeRemovalRequest) (*ops.NodeRemovalSimulation, error) {
+	response, err := c.PostJSON(c.Endpoint(
+		"accounts", req.AccountID, "sites", req.SiteDomain, "noderemoval", "simulate"), req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var simulation ops.NodeRemovalSimulation
+	if err := json.Unmarshal(response.Bytes(), &simulation); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &simulation, nil
+}
+
+// UpsertInstallResources adds the specified resources to the cluster
+// being installed replacing the resources with the same kind and name
+func (c *Client) UpsertInstallResources(ctx context.Context, req ops.UpsertInstallResourcesRequest) error {
+	_, err := c.PutJSON(c.Endpoint(
+		"accounts", req.AccountID, "sites", req.SiteDomain, "installresources"), &req)
+	return trace.Wrap(err)
+}
+
+// GetInstallResources returns the resources uploaded for the
+// cluster being installed
+func (c *Client) GetInstallResources(key ops.SiteKey) ([]storage.UnknownResource, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "installresources"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var resources []storage.UnknownResource
+	if err := json.Unmarshal(response.Bytes(), &resources); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return resources, nil
+}
+
+// ApproveOperation approves the operation with the specified approval request
+func (c *Client) ApproveOperation(ctx context.Context, req ops.ApproveOperationRequest) (*storage.OperationApproval, error) {
+	response, err := c.PostJSON(c.Endpoint(
+		"accounts", req.AccountID, "sites", req.SiteDomain, "approvals", req.ApprovalID), &req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var approval storage.OperationApproval
+	if err := json.Unmarshal(response.Bytes(), &approval); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &approval, nil
+}
+
+// GetLogLevels returns the log level overrides of cluster controllers
+func (c *Client) GetLogLevels(key ops.SiteKey) (logging.Levels, error) {
+	response, err := c.Get(c.Endpoint(
+		"accounts", key.AccountID, "sites", key.SiteDomain, "loglevels"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var levels logging.Levels
+	if err := json.Unmarshal(response.Bytes(), &levels); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return levels, nil
+}
+
+// UpdateLogLevels updates log levels of cluster controllers without restarting them
+func (c *Client) UpdateLogLevels(ctx context.Context, req ops.UpdateLogLevelsRequest) error {
+	_, err := c.PutJSON(c.Endpoint(
+		"accounts", req.AccountID, "sites", req.SiteDomain, "loglevels"), &req)
+	return trace.Wrap(err)
+}
+
 // GetAlerts returns a list of monitoring alerts for the cluster
 func (c *Client) GetAlerts(key ops.SiteKey) ([]storage.Alert, error) {
 	response, err := c.Get(c.Endpoint(
@@ -1185,6 +1773,47 @@ func (c *Client) UpdateClusterConfiguration(req ops.UpdateClusterConfigRequest)
 	return nil
 }
 
+// PatchClusterConfiguration applies the JSON merge patch to the cluster configuration
+func (c *Client) PatchClusterConfiguration(ctx context.Context, req ops.PatchResourceRequest) (*ops.PatchResourceResponse, error) {
+	return c.patchResource(ctx, c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "config"), req)
+}
+
+// UpdateResource updates the resource if it has not been modified
+// since the specified revision
+func (c *Client) UpdateResource(ctx context.Context, req ops.UpdateResourceRequest) error {
+	_, err := c.PostJSONWithContext(ctx, c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "resources", "update"), req)
+	return trace.Wrap(err)
+}
+
+// GetResourceRevision returns the current revision of the specified resource
+func (c *Client) GetResourceRevision(ctx context.Context, req ops.GetResourceRevisionRequest) (string, error) {
+	out, err := c.Get(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "resources", "revision"),
+		url.Values{
+			"kind": []string{req.Kind},
+			"name": []string{req.Name},
+		})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	var resp ops.GetResourceRevisionResponse
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		return "", trace.Wrap(err)
+	}
+	return resp.Revision, nil
+}
+
+func (c *Client) patchResource(ctx context.Context, endpoint string, req ops.PatchResourceRequest) (*ops.PatchResourceResponse, error) {
+	out, err := c.PatchJSON(ctx, endpoint, req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var resp ops.PatchResourceResponse
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &resp, nil
+}
+
 func (c *Client) GetApplicationEndpoints(key ops.SiteKey) ([]ops.Endpoint, error) {
 	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "endpoints"), url.Values{})
 	if err != nil {
@@ -1332,6 +1961,14 @@ type UpsertResourceRawReq struct {
 	TTL time.Duration `json:"ttl"`
 }
 
+// UpdatePersistentStorageRawReq is a request to update the persistent storage configuration
+type UpdatePersistentStorageRawReq struct {
+	// Resource is a raw JSON data of the persistent storage configuration
+	Resource json.RawMessage `json:"resource"`
+	// Force applies the configuration even if it fails validation
+	Force bool `json:"force"`
+}
+
 // UpsertUser creates or updates the user
 func (c *Client) UpsertUser(ctx context.Context, key ops.SiteKey, user teleservices.User) error {
 	data, err := teleservices.GetUserMarshaler().MarshalUser(user)
@@ -1389,6 +2026,53 @@ func (c *Client) DeleteUser(ctx context.Context, key ops.SiteKey, name string) e
 	return trace.Wrap(err)
 }
 
+// CreateDownloadToken creates a new token granting access
+// to download the installer of the specified application
+func (c *Client) CreateDownloadToken(ctx context.Context, req ops.CreateDownloadTokenRequest) (*storage.DownloadToken, error) {
+	out, err := c.PostJSON(c.Endpoint("accounts", req.AccountID, "tokens", "downloads"), req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var token storage.DownloadToken
+	if err := json.Unmarshal(out.Bytes(), &token); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &token, nil
+}
+
+// GetDownloadTokens returns active download tokens of the specified account
+func (c *Client) GetDownloadTokens(ctx context.Context, accountID string) ([]storage.DownloadToken, error) {
+	out, err := c.Get(c.Endpoint("accounts", accountID, "tokens", "downloads"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var tokens []storage.DownloadToken
+	if err := json.Unmarshal(out.Bytes(), &tokens); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return tokens, nil
+}
+
+// DeleteDownloadToken deletes the specified download token
+func (c *Client) DeleteDownloadToken(ctx context.Context, req ops.DeleteDownloadTokenRequest) error {
+	_, err := c.Delete(c.Endpoint("accounts", req.AccountID, "tokens", "downloads", req.Token))
+	return trace.Wrap(err)
+}
+
+// CheckAccess returns an access denied error if the current user
+// is not allowed to perform the specified action in the cluster
+func (c *Client) CheckAccess(ctx context.Context, req ops.CheckAccessRequest) error {
+	_, err := c.PostJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "access"), req)
+	return trace.Wrap(err)
+}
+
+// UpdateLabels sets and removes labels of the cluster
+// or of the cluster node specified in the request
+func (c *Client) UpdateLabels(ctx context.Context, req ops.UpdateLabelsRequest) error {
+	_, err := c.PutJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "labels"), req)
+	return trace.Wrap(err)
+}
+
 // CreateUserInvite creates a new invite token for a user.
 func (c *Client) CreateUserInvite(ctx context.Context, req ops.CreateUserInviteRequest) (*storage.UserToken, error) {
 	out, err := c.PostJSON(c.Endpoint("accounts", req.AccountID, "sites", req.SiteDomain, "tokens", "userinvites"), req)
@@ -1501,6 +2185,70 @@ func (c *Client) DeleteGithubConnector(ctx context.Context, key ops.SiteKey, nam
 	return trace.Wrap(err)
 }
 
+// UpsertAuthConnector creates or updates an OIDC or SAML connector
+func (c *Client) UpsertAuthConnector(ctx context.Context, key ops.SiteKey, connector teleservices.Resource) error {
+	data, err := ops.MarshalAuthConnector(connector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = c.PostJSON(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "authconnectors", ops.AuthConnectorKind(connector)),
+		&UpsertResourceRawReq{
+			Resource: data,
+		})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// GetAuthConnector returns the connector of the specified kind by name
+//
+// Returned connector excludes secrets unless withSecrets is true.
+func (c *Client) GetAuthConnector(key ops.SiteKey, kind, name string, withSecrets bool) (teleservices.Resource, error) {
+	if name == "" {
+		return nil, trace.BadParameter("missing connector name")
+	}
+	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "authconnectors", kind, name),
+		url.Values{constants.WithSecretsParam: []string{fmt.Sprintf("%t", withSecrets)}})
+	if err != nil {
+		return nil, err
+	}
+	return ops.UnmarshalAuthConnector(out.Bytes())
+}
+
+// GetAuthConnectors returns all connectors of the specified kind
+//
+// Returned connectors exclude secrets unless withSecrets is true.
+func (c *Client) GetAuthConnectors(key ops.SiteKey, kind string, withSecrets bool) ([]teleservices.Resource, error) {
+	out, err := c.Get(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "authconnectors", kind),
+		url.Values{constants.WithSecretsParam: []string{fmt.Sprintf("%t", withSecrets)}})
+	if err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(out.Bytes(), &items); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	connectors := make([]teleservices.Resource, len(items))
+	for i, raw := range items {
+		connector, err := ops.UnmarshalAuthConnector(raw)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		connectors[i] = connector
+	}
+	return connectors, nil
+}
+
+// DeleteAuthConnector deletes the connector of the specified kind by name
+func (c *Client) DeleteAuthConnector(ctx context.Context, key ops.SiteKey, kind, name string) error {
+	if name == "" {
+		return trace.BadParameter("missing connector name")
+	}
+	_, err := c.Delete(c.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain, "authconnectors", kind, name))
+	return trace.Wrap(err)
+}
+
 // UpsertAuthGateway updates auth gateway configuration.
 func (c *Client) UpsertAuthGateway(ctx context.Context, key ops.SiteKey, gw storage.AuthGateway) error {
 	bytes, err := storage.MarshalAuthGateway(gw)
@@ -1560,27 +2308,57 @@ func (c *Client) EmitAuditEvent(ctx context.Context, req ops.AuditEventRequest)
 
 // PostJSON issues HTTP POST request to the server with the provided JSON data
 func (c *Client) PostJSON(endpoint string, data interface{}) (*roundtrip.Response, error) {
-	return telehttplib.ConvertResponse(c.Client.PostJSON(context.TODO(), endpoint, data))
+	return c.PostJSONWithContext(context.TODO(), endpoint, data)
 }
 
 // PostJSONWithContext issues HTTP POST request to the server with the provided JSON data
 // bounded by the specified context
-func (c *Client) PostJSONWithContext(ctx context.Context, endpoint string, data interface{}) (*roundtrip.Response, error) {
-	return telehttplib.ConvertResponse(c.Client.PostJSON(ctx, endpoint, data))
+func (c *Client) PostJSONWithContext(ctx context.Context, endpoint string, data interface{}) (re *roundtrip.Response, err error) {
+	err = c.withRetry(ctx, http.MethodPost, func() error {
+		re, err = telehttplib.ConvertResponse(c.Client.PostJSON(ctx, endpoint, data))
+		return err
+	})
+	return re, err
 }
 
 // PutJSON issues HTTP PUT request to the server with the provided JSON data
-func (c *Client) PutJSON(endpoint string, data interface{}) (*roundtrip.Response, error) {
-	return telehttplib.ConvertResponse(c.Client.PutJSON(context.TODO(), endpoint, data))
+func (c *Client) PutJSON(endpoint string, data interface{}) (re *roundtrip.Response, err error) {
+	err = c.withRetry(context.TODO(), http.MethodPut, func() error {
+		re, err = telehttplib.ConvertResponse(c.Client.PutJSON(context.TODO(), endpoint, data))
+		return err
+	})
+	return re, err
+}
+
+// PatchJSON issues HTTP PATCH request to the server with the provided JSON data
+// bounded by the specified context
+func (c *Client) PatchJSON(ctx context.Context, endpoint string, data interface{}) (re *roundtrip.Response, err error) {
+	err = c.withRetry(ctx, http.MethodPatch, func() error {
+		re, err = telehttplib.ConvertResponse(c.Client.PatchJSON(ctx, endpoint, data))
+		return err
+	})
+	return re, err
 }
 
 // Get issues HTTP GET request to the server
-func (c *Client) Get(endpoint string, params url.Values) (*roundtrip.Response, error) {
-	return telehttplib.ConvertResponse(c.Client.Get(context.TODO(), endpoint, params))
+func (c *Client) Get(endpoint string, params url.Values) (re *roundtrip.Response, err error) {
+	err = c.withRetry(context.TODO(), http.MethodGet, func() error {
+		re, err = telehttplib.ConvertResponse(c.Client.Get(context.TODO(), endpoint, params))
+		return err
+	})
+	return re, err
 }
 
 // GetFile issues HTTP GET request to the server to download a file
-func (c *Client) GetFile(endpoint string, params url.Values) (*roundtrip.FileResponse, error) {
+func (c *Client) GetFile(endpoint string, params url.Values) (re *roundtrip.FileResponse, err error) {
+	err = c.withRetry(context.TODO(), http.MethodGet, func() error {
+		re, err = c.getFile(endpoint, params)
+		return err
+	})
+	return re, err
+}
+
+func (c *Client) getFile(endpoint string, params url.Values) (*roundtrip.FileResponse, error) {
 	re, err := c.Client.GetFile(context.TODO(), endpoint, params)
 	if err != nil {
 		if uerr, ok := err.(*url.Error); ok && uerr != nil && uerr.Err != nil {
@@ -1599,14 +2377,22 @@ func (c *Client) GetFile(endpoint string, params url.Values) (*roundtrip.FileRes
 }
 
 // Delete issues HTTP DELETE request to the server
-func (c *Client) Delete(endpoint string) (*roundtrip.Response, error) {
-	return telehttplib.ConvertResponse(c.Client.Delete(context.TODO(), endpoint))
+func (c *Client) Delete(endpoint string) (re *roundtrip.Response, err error) {
+	err = c.withRetry(context.TODO(), http.MethodDelete, func() error {
+		re, err = telehttplib.ConvertResponse(c.Client.Delete(context.TODO(), endpoint))
+		return err
+	})
+	return re, err
 }
 
 // DeleteWithParams issues HTTP DELETE request to the server
-func (c *Client) DeleteWithParams(endpoint string, params url.Values) (*roundtrip.Response, error) {
-	return telehttplib.ConvertResponse(c.Client.DeleteWithParams(context.TODO(),
-		endpoint, params))
+func (c *Client) DeleteWithParams(endpoint string, params url.Values) (re *roundtrip.Response, err error) {
+	err = c.withRetry(context.TODO(), http.MethodDelete, func() error {
+		re, err = telehttplib.ConvertResponse(c.Client.DeleteWithParams(context.TODO(),
+			endpoint, params))
+		return err
+	})
+	return re, err
 }
 
 // PostStream makes a POST request to the server using data from
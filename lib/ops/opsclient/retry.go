@@ -0,0 +1,192 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsclient
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryConfig configures retries of the client requests
+// failing with transient errors
+type RetryConfig struct {
+	// Timeout is the maximum time to retry a request for.
+	// Negative value disables retries
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive transient failures
+	// after which requests start failing fast
+	FailureThreshold int
+	// ResetTimeout is how long requests fail fast for before
+	// the service is tried again
+	ResetTimeout time.Duration
+	// Breaker is the circuit breaker shared by the clients of the same
+	// service. A new circuit breaker is created if unspecified
+	Breaker *CircuitBreaker
+	// Clock is used to track time, can be overridden in tests
+	Clock clockwork.Clock
+}
+
+// CheckAndSetDefaults sets defaults for unspecified configuration values
+func (r *RetryConfig) CheckAndSetDefaults() error {
+	if r.Timeout == 0 {
+		r.Timeout = defaults.OperatorRetryTimeout
+	}
+	if r.FailureThreshold == 0 {
+		r.FailureThreshold = defaults.OperatorFailureThreshold
+	}
+	if r.ResetTimeout == 0 {
+		r.ResetTimeout = defaults.OperatorBreakerResetTimeout
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.Breaker == nil {
+		r.Breaker = NewCircuitBreaker(r.FailureThreshold, r.ResetTimeout, r.Clock)
+	}
+	return nil
+}
+
+// WithRetry configures the client to retry the requests failing
+// with transient errors and to fail fast while the service is unavailable
+func WithRetry(config RetryConfig) ClientParam {
+	return func(c *Client) error {
+		if config.Timeout < 0 {
+			return nil
+		}
+		if err := config.CheckAndSetDefaults(); err != nil {
+			return trace.Wrap(err)
+		}
+		c.retry = &config
+		return nil
+	}
+}
+
+// withRetry invokes the request fn with the specified HTTP method
+// retrying it on transient errors as configured.
+//
+// Requests with methods that are not idempotent are only retried
+// if they have not reached the server, see isRetriable
+func (c *Client) withRetry(ctx context.Context, method string, fn func() error) error {
+	if c.retry == nil {
+		return fn()
+	}
+	interval := utils.NewExponentialBackOff(c.retry.Timeout)
+	return backoff.RetryNotify(func() error {
+		if err := c.retry.Breaker.Allow(); err != nil {
+			return &backoff.PermanentError{Err: trace.Wrap(err, "%v is unavailable", c.Endpoint())}
+		}
+		err := fn()
+		c.retry.Breaker.Report(err)
+		if err != nil && !isRetriable(method, err) {
+			return &backoff.PermanentError{Err: err}
+		}
+		return err
+	}, backoff.WithContext(interval, ctx), func(err error, d time.Duration) {
+		log.WithError(err).Debugf("Retrying %v request to %v in %v.", method, c.Endpoint(), d)
+	})
+}
+
+// isRetriable returns true if the request with the specified method
+// that failed with err can be retried.
+//
+// Requests with idempotent methods are retried on any transient error.
+// Other requests (e.g. the ones creating operations) might have been
+// processed by the server before the connection failed, so they are only
+// retried if the connection to the server could not be established
+func isRetriable(method string, err error) bool {
+	if !utils.IsTransientClusterError(err) {
+		return false
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return utils.IsConnectionRefusedError(err)
+}
+
+// NewCircuitBreaker returns a new circuit breaker that opens after
+// the specified number of consecutive transient failures and stays open
+// for resetTimeout before letting a request through again
+func NewCircuitBreaker(threshold int, resetTimeout time.Duration, clock clockwork.Clock) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:    threshold,
+		resetTimeout: resetTimeout,
+		clock:        clock,
+	}
+}
+
+// CircuitBreaker tracks transient failures of the requests to a service
+// and fails the requests fast once the service appears to be unavailable,
+// for example, while the cluster controller is restarting
+type CircuitBreaker struct {
+	sync.Mutex
+	threshold    int
+	resetTimeout time.Duration
+	clock        clockwork.Clock
+	// failures is the number of consecutive transient failures
+	failures int
+	// openedAt is the time the breaker has been opened or
+	// last let a request through while open
+	openedAt time.Time
+	// lastErr is the last transient error
+	lastErr error
+}
+
+// Allow returns an error if the breaker is open and the request
+// should fail fast. Once the reset timeout has passed, a single
+// request is let through to probe the service
+func (b *CircuitBreaker) Allow() error {
+	b.Lock()
+	defer b.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	now := b.clock.Now()
+	if now.Sub(b.openedAt) >= b.resetTimeout {
+		b.openedAt = now
+		return nil
+	}
+	return trace.ConnectionProblem(b.lastErr,
+		"failing fast after %v consecutive failures, next attempt in %v",
+		b.failures, b.openedAt.Add(b.resetTimeout).Sub(now))
+}
+
+// Report records the result of a request
+func (b *CircuitBreaker) Report(err error) {
+	b.Lock()
+	defer b.Unlock()
+	if !utils.IsTransientClusterError(err) {
+		b.failures = 0
+		b.lastErr = nil
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures == b.threshold {
+		b.openedAt = b.clock.Now()
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"gopkg.in/check.v1"
+)
+
+func TestOpsClient(t *testing.T) { check.TestingT(t) }
+
+type RetrySuite struct{}
+
+var _ = check.Suite(&RetrySuite{})
+
+func (s *RetrySuite) TestRetriesTransientErrors(c *check.C) {
+	client := newRetryClient(c, RetryConfig{FailureThreshold: 10})
+	attempts := 0
+	err := client.withRetry(context.TODO(), http.MethodGet, func() error {
+		attempts++
+		if attempts < 3 {
+			return trace.ConnectionProblem(nil, "connection refused")
+		}
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.Equals, 3)
+}
+
+func (s *RetrySuite) TestDoesNotRetryOtherErrors(c *check.C) {
+	client := newRetryClient(c, RetryConfig{})
+	attempts := 0
+	err := client.withRetry(context.TODO(), http.MethodGet, func() error {
+		attempts++
+		return trace.NotFound("not found")
+	})
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(attempts, check.Equals, 1)
+}
+
+func (s *RetrySuite) TestFailsFastWhenBreakerIsOpen(c *check.C) {
+	clock := clockwork.NewFakeClock()
+	client := newRetryClient(c, RetryConfig{
+		FailureThreshold: 2,
+		ResetTimeout:     time.Minute,
+		Clock:            clock,
+	})
+	attempts := 0
+	failing := func() error {
+		attempts++
+		return trace.ConnectionProblem(nil, "connection refused")
+	}
+	err := client.withRetry(context.TODO(), http.MethodGet, failing)
+	c.Assert(trace.IsConnectionProblem(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(attempts, check.Equals, 2, check.Commentf("expected the breaker to open after 2 failures"))
+
+	err = client.withRetry(context.TODO(), http.MethodGet, failing)
+	c.Assert(err, check.NotNil)
+	c.Assert(attempts, check.Equals, 2, check.Commentf("expected the request to fail fast"))
+
+	clock.Advance(time.Minute)
+	err = client.withRetry(context.TODO(), http.MethodGet, func() error {
+		attempts++
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.Equals, 3, check.Commentf("expected the breaker to let a request through"))
+	c.Assert(client.retry.Breaker.Allow(), check.IsNil)
+}
+
+func (s *RetrySuite) TestRetriesNonIdempotentRequestsOnlyIfNotSent(c *check.C) {
+	client := newRetryClient(c, RetryConfig{FailureThreshold: 10})
+	attempts := 0
+	err := client.withRetry(context.TODO(), http.MethodPost, func() error {
+		attempts++
+		return trace.ConnectionProblem(nil, "read: connection reset by peer")
+	})
+	c.Assert(trace.IsConnectionProblem(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(attempts, check.Equals, 1, check.Commentf("expected the request not to be retried"))
+
+	attempts = 0
+	err = client.withRetry(context.TODO(), http.MethodPut, func() error {
+		attempts++
+		if attempts < 3 {
+			return trace.ConnectionProblem(nil, "dial tcp: connection refused")
+		}
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(attempts, check.Equals, 3)
+}
+
+func (s *RetrySuite) TestRetriesCanBeDisabled(c *check.C) {
+	client := newRetryClient(c, RetryConfig{Timeout: -1})
+	c.Assert(client.retry, check.IsNil)
+}
+
+func newRetryClient(c *check.C, config RetryConfig) *Client {
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	client, err := NewClient("https://localhost:3009", WithRetry(config))
+	c.Assert(err, check.IsNil)
+	return client
+}
@@ -59,6 +59,44 @@ func (h *WebHandler) updateClusterConfig(w http.ResponseWriter, r *http.Request,
 	return nil
 }
 
+/* patchClusterConfig applies the JSON merge patch to the cluster configuration.
+   The patched configuration is applied with the update-config operation
+   returned in the response
+
+   PATCH /portal/v1/accounts/:account_id/sites/:site_domain/config
+
+   {
+      "patch": {"spec": {"global": {"featureGates": {"ExampleFeature": true}}}},
+      "resource_version": "<optional version the patch is based on>"
+   }
+
+Success response:
+
+   {
+      "resource": "<updated configuration>",
+      "resource_version": "<version of the configuration the patch has been applied to>",
+      "operation": {
+         "account_id": "account id",
+         "site_id": "site_id",
+         "operation_id": "operation id"
+      }
+   }
+*/
+func (h *WebHandler) patchClusterConfig(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	d := json.NewDecoder(r.Body)
+	var req ops.PatchResourceRequest
+	if err := d.Decode(&req); err != nil {
+		return trace.BadParameter(err.Error())
+	}
+	req.SiteKey = siteKey(p)
+	resp, err := context.Operator.PatchClusterConfiguration(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, resp)
+	return nil
+}
+
 /* createUpdateConfigOperation initiates the operatation of updating cluster configuration
 
    POST /portal/v1/accounts/:account_id/sites/:site_domain/operations/config
@@ -31,14 +31,18 @@ import (
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/migration"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/events"
 	"github.com/gravitational/gravity/lib/ops/opsclient"
+	"github.com/gravitational/gravity/lib/ops/resources"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
 	"github.com/gravitational/gravity/lib/users"
 	"github.com/gravitational/gravity/lib/utils/fields"
+	helmutils "github.com/gravitational/gravity/lib/utils/helm"
 
 	"github.com/gravitational/roundtrip"
 	"github.com/gravitational/teleport/lib/auth"
@@ -67,8 +71,16 @@ type WebHandlerConfig struct {
 	Authenticator users.Authenticator
 	// Devmode is whether the process is started in dev mode
 	Devmode bool
+	// Wizard is whether the process is started as an install wizard.
+	// In wizard mode the operation plan and progress can only be queried
+	// with an operation token
+	Wizard bool
 	// PublicAdvertiseAddr is the process public advertise address
 	PublicAdvertiseAddr teleutils.NetAddr
+	// Resources optionally returns the resource controller that uses
+	// the specified operator. It is required to update resources
+	// guarded by revisions
+	Resources func(ops.Operator) (resources.Resources, error)
 }
 
 // CheckAndSetDefaults validates the config and sets some defaults.
@@ -136,6 +148,8 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 	// Users API
 	h.GET("/portal/v1/currentuser", h.needsAuth(h.getCurrentUser))
 	h.GET("/portal/v1/currentuserinfo", h.needsAuth(h.getCurrentUserInfo))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/access", h.needsAuth(h.checkAccess))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/labels", h.needsAuth(h.updateLabels))
 	h.POST("/portal/v1/users", h.needsAuth(h.createUser))
 	h.DELETE("/portal/v1/users/:user_email", h.needsAuth(h.deleteLocalUser))
 	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/users/:user_email", h.needsAuth(h.updateUser))
@@ -152,6 +166,9 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 
 	// Tokens API
 	h.POST("/portal/v1/tokens/install", h.needsAuth(h.createInstallToken))
+	h.POST("/portal/v1/accounts/:account_id/tokens/downloads", h.needsAuth(h.createDownloadToken))
+	h.GET("/portal/v1/accounts/:account_id/tokens/downloads", h.needsAuth(h.getDownloadTokens))
+	h.DELETE("/portal/v1/accounts/:account_id/tokens/downloads/:token", h.needsAuth(h.deleteDownloadToken))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/tokens/userresets", h.needsAuth(h.resetUser))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/tokens/provision", h.needsAuth(h.createProvisioningToken))
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/tokens/expand", h.needsAuth(h.getExpandToken))
@@ -164,13 +181,26 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain", h.needsAuth(h.getSite))
 	h.GET("/portal/v1/accounts/:account_id/sites", h.needsAuth(h.getSites))
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/report", h.needsAuth(h.getSiteReport))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/report", h.needsAuth(h.uploadClusterReport))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/migration", h.needsAuth(h.importMigration))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/charts/:name", h.needsAuth(h.fetchChart))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/deactivate", h.needsAuth(h.deactivateSite))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/activate", h.needsAuth(h.activateSite))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/license", h.needsAuth(h.updateClusterLicense))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/components/:component/enable", h.needsAuth(h.enableComponent))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/components/:component/disable", h.needsAuth(h.disableComponent))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/complete", h.needsAuth(h.completeFinalInstallStep))
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/localuser", h.needsAuth(h.getLocalUser))
 	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/reset-password", h.needsAuth(h.resetUserPassword))
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/agent", h.needsAuth(h.getClusterAgent))
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/nodes", h.needsAuth(h.getClusterNodes))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/inventory", h.needsAuth(h.getClusterInventory))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/probes", h.needsAuth(h.getClusterProbes))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/storage/capacity", h.needsAuth(h.getStorageCapacity))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/storage/usage", h.needsAuth(h.getStorageUsage))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/certificates/nodes", h.needsAuth(h.getNodeCertificates))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/summary", h.needsAuth(h.getClusterSummary))
+	h.GET("/portal/v1/accounts/:account_id/clusters/summaries", h.needsAuth(h.getClusterSummaries))
 
 	// Status API
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/status", h.needsAuth(h.checkSiteStatus))
@@ -221,6 +251,8 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 
 	// garbage collection
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/gc", h.needsAuth(h.createClusterGarbageCollectOperation))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/patch", h.needsAuth(h.createPatchOperation))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/binary", h.needsAuth(h.createUpdateBinaryOperation))
 
 	// update - update installed application to a new version
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/update", h.needsAuth(h.createSiteUpdateOperation))
@@ -233,13 +265,15 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/logs", h.needsAuth(h.getSiteOperationLogs))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/logs/entry", h.needsAuth(h.createLogEntry))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/logs", h.needsAuth(h.streamOperationLogs))
-	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/progress", h.needsAuth(h.getSiteOperationProgress))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/token", h.needsAuth(h.createOperationToken))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/progress", h.needsOperationAuth(h.getSiteOperationProgress))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/progress", h.needsAuth(h.createProgressEntry))
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/crash-report", h.needsAuth(h.getSiteOperationCrashReport))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/artifacts", h.needsAuth(h.getOperationArtifacts))
 	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/complete", h.needsAuth(h.completeSiteOperation))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/plan", h.needsAuth(h.createOperationPlan))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/plan/changelog", h.needsAuth(h.createOperationPlanChange))
-	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/plan", h.needsAuth(h.getOperationPlan))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/plan", h.needsOperationAuth(h.getOperationPlan))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/plan/configure", h.needsAuth(h.configurePackages))
 
 	// log forwarders
@@ -254,6 +288,46 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/smtp", h.needsAuth(h.updateSMTPConfig))
 	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/smtp", h.needsAuth(h.deleteSMTPConfig))
 
+	// health reports
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/healthreport", h.needsAuth(h.getHealthReport))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/healthreport", h.needsAuth(h.updateHealthReport))
+	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/healthreport", h.needsAuth(h.deleteHealthReport))
+
+	// admission webhook
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/admissionwebhook", h.needsAuth(h.getAdmissionWebhook))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/admissionwebhook", h.needsAuth(h.updateAdmissionWebhook))
+	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/admissionwebhook", h.needsAuth(h.deleteAdmissionWebhook))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/operationpolicy", h.needsAuth(h.getOperationPolicy))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/operationpolicy", h.needsAuth(h.updateOperationPolicy))
+	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/operationpolicy", h.needsAuth(h.deleteOperationPolicy))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/registry", h.needsAuth(h.getRegistryConfig))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/registry", h.needsAuth(h.updateRegistryConfig))
+	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/registry", h.needsAuth(h.deleteRegistryConfig))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/persistentstorage", h.needsAuth(h.getPersistentStorage))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/persistentstorage", h.needsAuth(h.updatePersistentStorage))
+	h.PATCH("/portal/v1/accounts/:account_id/sites/:site_domain/persistentstorage", h.needsAuth(h.patchPersistentStorage))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/retention", h.needsAuth(h.getRetentionPolicy))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/retention", h.needsAuth(h.updateRetentionPolicy))
+	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/retention", h.needsAuth(h.deleteRetentionPolicy))
+
+	// node removal
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/noderemoval/verify", h.needsAuth(h.verifyNodeRemoval))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/noderemoval/simulate", h.needsAuth(h.simulateNodeRemoval))
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/tombstones", h.needsAuth(h.createNodeTombstone))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/tombstones", h.needsAuth(h.getNodeTombstones))
+	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/tombstones/:hostname", h.needsAuth(h.deleteNodeTombstone))
+
+	// install resources
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/installresources", h.needsAuth(h.upsertInstallResources))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/installresources", h.needsAuth(h.getInstallResources))
+
+	// operation approvals
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/approvals/:approval_id", h.needsAuth(h.approveOperation))
+
+	// log levels
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/loglevels", h.needsAuth(h.getLogLevels))
+	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/loglevels", h.needsAuth(h.updateLogLevels))
+
 	// monitoring
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/monitoring/alerts", h.needsAuth(h.getAlerts))
 	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/monitoring/alerts/:name", h.needsAuth(h.updateAlert))
@@ -271,6 +345,11 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 	// cluster configuration
 	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/config", h.needsAuth(h.getClusterConfiguration))
 	h.PUT("/portal/v1/accounts/:account_id/sites/:site_domain/config", h.needsAuth(h.updateClusterConfig))
+	h.PATCH("/portal/v1/accounts/:account_id/sites/:site_domain/config", h.needsAuth(h.patchClusterConfig))
+
+	// resources
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/resources/update", h.needsAuth(h.updateResource))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/resources/revision", h.needsAuth(h.getResourceRevision))
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/operations/config", h.needsAuth(h.createUpdateConfigOperation))
 
 	// validation
@@ -295,6 +374,16 @@ func NewWebHandler(cfg WebHandlerConfig) (*WebHandler, error) {
 	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/github/connectors/:id",
 		h.needsAuth(h.deleteGithubConnector))
 
+	// OIDC and SAML connector handlers
+	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind",
+		h.needsAuth(h.upsertAuthConnector))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind/:id",
+		h.needsAuth(h.getAuthConnector))
+	h.GET("/portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind",
+		h.needsAuth(h.getAuthConnectors))
+	h.DELETE("/portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind/:id",
+		h.needsAuth(h.deleteAuthConnector))
+
 	// user handlers
 	h.POST("/portal/v1/accounts/:account_id/sites/:site_domain/users",
 		h.needsAuth(h.upsertUser))
@@ -447,6 +536,113 @@ func (h *WebHandler) resetUser(w http.ResponseWriter, r *http.Request, p httprou
 	return nil
 }
 
+/*  createDownloadToken creates a new token granting access to download
+    the installer of the specified application
+
+    POST /portal/v1/accounts/:account_id/tokens/downloads
+
+    Success Response:
+
+      storage.DownloadToken
+*/
+func (h *WebHandler) createDownloadToken(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.CreateDownloadTokenRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	req.AccountID = p.ByName("account_id")
+	token, err := context.Operator.CreateDownloadToken(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, token)
+	return nil
+}
+
+/*  getDownloadTokens returns active download tokens of the account
+
+    GET /portal/v1/accounts/:account_id/tokens/downloads
+
+    Success Response:
+
+      []storage.DownloadToken
+*/
+func (h *WebHandler) getDownloadTokens(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	tokens, err := context.Operator.GetDownloadTokens(r.Context(), p.ByName("account_id"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, tokens)
+	return nil
+}
+
+/*  deleteDownloadToken deletes the specified download token
+
+    DELETE /portal/v1/accounts/:account_id/tokens/downloads/:token
+*/
+func (h *WebHandler) deleteDownloadToken(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteDownloadToken(r.Context(), ops.DeleteDownloadTokenRequest{
+		AccountID: p.ByName("account_id"),
+		Token:     p.ByName("token"),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("download token deleted"))
+	return nil
+}
+
+/*  checkAccess checks whether the current user is allowed to perform
+    the specified action in the cluster
+
+    POST /portal/v1/accounts/:account_id/sites/:site_domain/access
+
+    Input: ops.CheckAccessRequest
+
+    Success Response:
+
+      {
+        "message": "access granted"
+      }
+*/
+func (h *WebHandler) checkAccess(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.CheckAccessRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	req.SiteKey = siteKey(p)
+	if err := context.Operator.CheckAccess(r.Context(), req); err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("access granted"))
+	return nil
+}
+
+/*  updateLabels sets and removes labels of the cluster or of a cluster node
+
+    PUT /portal/v1/accounts/:account_id/sites/:site_domain/labels
+
+    Input: ops.UpdateLabelsRequest
+
+    Success Response:
+
+      {
+        "message": "labels updated"
+      }
+*/
+func (h *WebHandler) updateLabels(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.UpdateLabelsRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	req.SiteKey = siteKey(p)
+	if err := context.Operator.UpdateLabels(r.Context(), req); err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("labels updated"))
+	return nil
+}
+
 /*  createUserInvite creates a new invite token for a user.
 
     POST /portal/v1/accounts/:account_id/sites/:site_domain/usertokens/invites
@@ -1033,6 +1229,77 @@ func (h *WebHandler) activateSite(w http.ResponseWriter, r *http.Request, p http
 	return nil
 }
 
+/*  updateClusterLicense validates and installs a new cluster license
+
+    POST /portal/v1/accounts/:account_id/sites/:site_domain/license
+
+    Input: ops.UpdateLicenseRequest
+
+    Success response:
+    {
+      "message": "license updated"
+    }
+*/
+func (h *WebHandler) updateClusterLicense(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.UpdateLicenseRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := context.Operator.UpdateClusterLicense(req); err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("license updated"))
+	return nil
+}
+
+/*  enableComponent starts the operation that installs the optional application
+    component into the cluster
+
+    POST /portal/v1/accounts/:account_id/sites/:site_domain/components/:component/enable
+
+    Input: ops.ComponentRequest
+
+    Success response: ops.SiteOperationKey
+*/
+func (h *WebHandler) enableComponent(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	d := json.NewDecoder(r.Body)
+	var req ops.ComponentRequest
+	if err := d.Decode(&req); err != nil {
+		return trace.BadParameter("%v", err)
+	}
+	req.Component = p.ByName("component")
+	key, err := context.Operator.EnableComponent(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, key)
+	return nil
+}
+
+/*  disableComponent starts the operation that uninstalls the optional application
+    component from the cluster
+
+    POST /portal/v1/accounts/:account_id/sites/:site_domain/components/:component/disable
+
+    Input: ops.ComponentRequest
+
+    Success response: ops.SiteOperationKey
+*/
+func (h *WebHandler) disableComponent(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	d := json.NewDecoder(r.Body)
+	var req ops.ComponentRequest
+	if err := d.Decode(&req); err != nil {
+		return trace.BadParameter("%v", err)
+	}
+	req.Component = p.ByName("component")
+	key, err := context.Operator.DisableComponent(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, key)
+	return nil
+}
+
 /* getSiteReport returns a tarball with collected information about the site
 
    GET /portal/v1/accounts/:account_id/sites/:site_domain/report
@@ -1053,6 +1320,99 @@ func (h *WebHandler) getSiteReport(w http.ResponseWriter, r *http.Request, p htt
 	return trace.Wrap(err)
 }
 
+/* uploadClusterReport stores the diagnostics report of the cluster
+   streamed in the request body
+
+   POST /portal/v1/accounts/:account_id/sites/:site_domain/report
+
+   Success response:
+
+   {
+     "status": "ok",
+     "message": "report uploaded"
+   }
+*/
+func (h *WebHandler) uploadClusterReport(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.UploadClusterReport(siteKey(p), r.Body)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("report uploaded"))
+	return nil
+}
+
+/* importMigration verifies the migration archive streamed in the request body
+   and starts the operation that imports the application data from it
+
+   POST /portal/v1/accounts/:account_id/sites/:site_domain/migration?storage_class=<source>:<target>
+
+   Success response:
+
+   {
+      "account_id": "account id",
+      "site_id": "site_id",
+      "operation_id": "operation id"
+   }
+*/
+func (h *WebHandler) importMigration(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	storageClasses, err := migration.ParseStorageClasses(r.URL.Query()["storage_class"])
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	key, err := context.Operator.ImportMigration(ops.ImportMigrationRequest{
+		AccountID:      p.ByName("account_id"),
+		SiteDomain:     p.ByName("site_domain"),
+		StorageClasses: storageClasses,
+	}, r.Body)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, key)
+	return nil
+}
+
+/* fetchChart serves the Helm chart repository of the cluster
+
+   GET /portal/v1/accounts/:account_id/sites/:site_domain/charts/:name
+
+   The name parameter is either "index.yaml" for the repository index file
+   or the chart archive filename formatted as "<name>-<ver>.tgz", for example
+   "alpine-0.1.0.tgz".
+
+   The endpoint can be added to Helm clients as a chart repository:
+
+   helm repo add <name> https://<host>/portal/v1/accounts/<account>/sites/<cluster>/charts
+*/
+func (h *WebHandler) fetchChart(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	name := p.ByName("name")
+	if name == "index.yaml" {
+		index, err := context.Operator.GetChartIndex(siteKey(p))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		defer index.Close()
+		w.Header().Set("Content-Type", "application/yaml")
+		_, err = io.Copy(w, index)
+		return trace.Wrap(err)
+	}
+	chartName, chartVersion, err := helmutils.ParseChartFilename(name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	locator, err := loc.NewLocator(defaults.SystemAccountOrg, chartName, chartVersion)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	chart, err := context.Operator.FetchChart(siteKey(p), *locator)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer chart.Close()
+	w.Header().Set("Content-Type", "application/gzip")
+	_, err = io.Copy(w, chart)
+	return trace.Wrap(err)
+}
+
 /*  completeFinalInstallStep marks the site as having completed the last installation step
 
     POST /portal/v1/accounts/:account_id/sites/:site_domain/complete
@@ -1144,6 +1504,129 @@ func (h *WebHandler) getClusterNodes(w http.ResponseWriter, r *http.Request, p h
 	return nil
 }
 
+/*  getClusterInventory returns hardware and software inventory of cluster nodes
+
+    GET /portal/v1/accounts/:account_id/sites/:site_domain/inventory
+
+    Input: ops.SiteKey
+
+    Success response: ops.ClusterInventory
+*/
+func (h *WebHandler) getClusterInventory(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	inventory, err := context.Operator.GetClusterInventory(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, inventory)
+	return nil
+}
+
+/*  getClusterProbes returns the last results of the health probes on cluster nodes
+
+    GET /portal/v1/accounts/:account_id/sites/:site_domain/probes
+
+    Input: ops.SiteKey
+
+    Success response: ops.ClusterProbes
+*/
+func (h *WebHandler) getClusterProbes(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	probes, err := context.Operator.GetClusterProbes(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, probes)
+	return nil
+}
+
+/*  getStorageCapacity returns the block storage capacity available for persistent volumes
+
+    GET /portal/v1/accounts/:account_id/sites/:site_domain/storage/capacity?include_vendor=<vendor>&exclude_path=<path>
+
+    Input: ops.StorageCapacityRequest
+
+    Success response: ops.StorageCapacity
+*/
+func (h *WebHandler) getStorageCapacity(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	query := r.URL.Query()
+	capacity, err := context.Operator.GetStorageCapacity(ops.StorageCapacityRequest{
+		AccountID:  p.ByName("account_id"),
+		SiteDomain: p.ByName("site_domain"),
+		Filter: systeminfo.DeviceFilter{
+			IncludeVendors: query["include_vendor"],
+			ExcludeVendors: query["exclude_vendor"],
+			IncludePaths:   query["include_path"],
+			ExcludePaths:   query["exclude_path"],
+		},
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, capacity)
+	return nil
+}
+
+/*  getStorageUsage returns the persistent storage capacity and usage of the application namespaces
+
+    GET /portal/v1/accounts/:account_id/sites/:site_domain/storage/usage
+
+    Success response: ops.StorageUsage
+*/
+func (h *WebHandler) getStorageUsage(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	usage, err := context.Operator.GetStorageUsage(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, usage)
+	return nil
+}
+
+/*  getNodeCertificates returns the expiration of the certificates installed on cluster nodes
+
+    GET /portal/v1/accounts/:account_id/sites/:site_domain/certificates/nodes
+
+    Success response: []ops.NodeCertificates
+*/
+func (h *WebHandler) getNodeCertificates(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	nodes, err := context.Operator.GetNodeCertificates(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, nodes)
+	return nil
+}
+
+/*  getClusterSummaries returns summaries of all clusters managed by the operator
+
+    GET /portal/v1/accounts/:account_id/clusters/summaries
+
+    Success response: []ops.ClusterSummary
+*/
+func (h *WebHandler) getClusterSummaries(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	summaries, err := context.Operator.GetClusterSummaries(p.ByName("account_id"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, summaries)
+	return nil
+}
+
+/*  getClusterSummary returns the summary of the specified cluster
+
+    GET /portal/v1/accounts/:account_id/sites/:site_domain/summary
+
+    Input: ops.SiteKey
+
+    Success response: ops.ClusterSummary
+*/
+func (h *WebHandler) getClusterSummary(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	summary, err := context.Operator.GetClusterSummary(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, summary)
+	return nil
+}
+
 /*  resetUserPassword resets the user password and returns the new one
 
     PUT /portal/v1/accounts/:account_id/sites/:site_domain/reset-password
@@ -1550,6 +2033,24 @@ func (h *WebHandler) createOperationPlanChange(w http.ResponseWriter, r *http.Re
 	return nil
 }
 
+/* createOperationToken issues a new token granting access to the plan
+   and progress of the specified operation
+
+   POST /portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/token
+
+   Success response:
+
+     storage.OperationToken
+*/
+func (h *WebHandler) createOperationToken(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	token, err := context.Operator.CreateOperationToken(r.Context(), siteOperationKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, token)
+	return nil
+}
+
 /* getOperationPlan returns plan for the specified operation
 
    GET /portal/v1/accos/:account_id/sites/:site_domain/operations/common/:operation_id/plan
@@ -1849,17 +2350,36 @@ func (h *WebHandler) getSiteOperationCrashReport(w http.ResponseWriter, r *http.
 	return err
 }
 
-/*getSiteOperationProgress returns a progress report for this operation
-
-  GET /portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/progress
+/*getOperationArtifacts returns a file upload with a gzipped tarball of the operation artifacts
 
-Success Response:
+  GET /portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/artifacts
 
-  {
-	"site_id": "site id",
-	"operation_id": "operation id",
-	"created": "timestamp RFC 3339",
-    "completion": 39,
+*/
+func (h *WebHandler) getOperationArtifacts(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	key := siteOperationKey(p)
+	artifacts, err := context.Operator.GetOperationArtifacts(key)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer artifacts.Close()
+	w.Header().Set("Content-Type", "application/x-gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%v",
+		ops.OperationArtifactsTarball(key.OperationID)))
+	_, err = io.Copy(w, artifacts)
+	return trace.Wrap(err)
+}
+
+/*getSiteOperationProgress returns a progress report for this operation
+
+  GET /portal/v1/accounts/:account_id/sites/:site_domain/operations/common/:operation_id/progress
+
+Success Response:
+
+  {
+	"site_id": "site id",
+	"operation_id": "operation id",
+	"created": "timestamp RFC 3339",
+    "completion": 39,
 	"state": "one of 'in_progress', 'failed', or 'completed'",
 	"message": "message to display to user"
   }
@@ -2026,6 +2546,71 @@ func (h *WebHandler) createClusterGarbageCollectOperation(w http.ResponseWriter,
 	return nil
 }
 
+/* createPatchOperation creates a new operation to patch the operating system on cluster nodes
+
+   POST	/portal/v1/accounts/:account_id/sites/:site_domain/operations/patch
+
+   {
+      "command": "yum update -y",
+      "reboot": false
+   }
+
+
+Success response:
+
+   {
+      "account_id": "account id",
+      "site_id": "cluster_name",
+      "operation_id": "operation id"
+   }
+*/
+func (h *WebHandler) createPatchOperation(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	d := json.NewDecoder(r.Body)
+	var req ops.CreatePatchOperationRequest
+	if err := d.Decode(&req); err != nil {
+		return trace.BadParameter(err.Error())
+	}
+	req.ClusterKey = siteKey(p)
+	op, err := context.Operator.CreatePatchOperation(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, op)
+	return nil
+}
+
+/* createUpdateBinaryOperation creates a new operation to replace the gravity binary on cluster nodes
+
+   POST	/portal/v1/accounts/:account_id/sites/:site_domain/operations/binary
+
+   {
+      "package": "gravitational.io/gravity:6.1.5"
+   }
+
+
+Success response:
+
+   {
+      "account_id": "account id",
+      "site_id": "cluster_name",
+      "operation_id": "operation id"
+   }
+*/
+func (h *WebHandler) createUpdateBinaryOperation(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	d := json.NewDecoder(r.Body)
+	var req ops.CreateUpdateBinaryOperationRequest
+	if err := d.Decode(&req); err != nil {
+		return trace.BadParameter(err.Error())
+	}
+	req.ClusterKey = siteKey(p)
+	op, err := context.Operator.CreateUpdateBinaryOperation(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, op)
+	return nil
+}
+
 /* getLogForwarders returns a list of configured log forwarders
 
    GET /portal/v1/accounts/:account_id/sites/:site_domain/logs/forwarders
@@ -2181,157 +2766,858 @@ func (h *WebHandler) deleteSMTPConfig(w http.ResponseWriter, r *http.Request, p
 	return nil
 }
 
-/* getApplicationEndpoints returns application endpoints for a deployed cluster
+/* getHealthReport returns the cluster health report configuration
 
-     GET /portal/v1/accounts/:account_id/sites/:site_domain/endpoints
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/healthreport
 
    Success Response:
 
-     []ops.Endpoint
+     storage.HealthReport
 */
-func (h *WebHandler) getApplicationEndpoints(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
-	endpoints, err := context.Operator.GetApplicationEndpoints(siteKey(p))
+func (h *WebHandler) getHealthReport(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	config, err := context.Operator.GetHealthReport(siteKey(p))
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	roundtrip.ReplyJSON(w, http.StatusOK, endpoints)
+	roundtrip.ReplyJSON(w, http.StatusOK, config)
 	return nil
 }
 
-/* getAppInstaller returns a standalone installer for the specified application
+/* updateHealthReport updates the cluster health report configuration
 
-GET /portal/v1/accounts/:account_id/apps/:repository_id/:package_name/:version/installer
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/healthreport
 
    Success Response:
 
-     binary stream with application tarball
+     {
+       "message": "health report configuration updated"
+     }
 */
-func (h *WebHandler) getAppInstaller(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
-	accountID := p.ByName("account_id")
-	locator, err := loc.NewLocator(p.ByName("repository_id"), p.ByName("package_name"), p.ByName("version"))
+func (h *WebHandler) updateHealthReport(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req opsclient.UpsertResourceRawReq
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+
+	config, err := storage.UnmarshalHealthReport(req.Resource)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 
-	if err := r.ParseForm(); err != nil {
+	err = context.Operator.UpdateHealthReport(r.Context(), siteKey(p), config)
+	if err != nil {
 		return trace.Wrap(err)
 	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("health report configuration updated"))
+	return nil
+}
 
-	var installerReq ops.AppInstallerRequest
-	requestBytes := r.Form.Get("request")
+/* deleteHealthReport deletes the cluster health report configuration
 
-	err = json.Unmarshal([]byte(requestBytes), &installerReq)
+   DELETE /portal/v1/accounts/:account_id/sites/:site_domain/healthreport
+
+   Success Response:
+
+     {
+       "message": "health report configuration deleted"
+     }
+*/
+func (h *WebHandler) deleteHealthReport(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteHealthReport(r.Context(), siteKey(p))
 	if err != nil {
-		return trace.Wrap(err, "failed to unmarshal `%s`", requestBytes)
+		return trace.Wrap(err)
 	}
-	installerReq.AccountID = accountID
 
-	reader, err := context.Operator.GetAppInstaller(installerReq)
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("health report configuration deleted"))
+	return nil
+}
+
+/* getAdmissionWebhook returns the resource admission webhook
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/admissionwebhook
+
+   Success Response:
+
+     storage.AdmissionWebhook
+*/
+func (h *WebHandler) getAdmissionWebhook(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	webhook, err := context.Operator.GetAdmissionWebhook(siteKey(p))
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	defer reader.Close()
+	roundtrip.ReplyJSON(w, http.StatusOK, webhook)
+	return nil
+}
 
-	installerFilename := fmt.Sprintf("%v-%v-installer.tar.gz", locator.Name, locator.Version)
-	w.Header().Set("Content-Type", "application/x-gzip")
-	w.Header().Set("Content-Disposition", `attachment; filename="`+installerFilename+`"`)
-	_, err = io.Copy(w, reader)
-	return trace.Wrap(err)
+/* updateAdmissionWebhook updates the resource admission webhook
+
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/admissionwebhook
+
+   Success Response:
+
+     {
+       "message": "admission webhook updated"
+     }
+*/
+func (h *WebHandler) updateAdmissionWebhook(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req opsclient.UpsertResourceRawReq
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+
+	webhook, err := storage.UnmarshalAdmissionWebhook(req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = context.Operator.UpdateAdmissionWebhook(r.Context(), siteKey(p), webhook)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("admission webhook updated"))
+	return nil
 }
 
-/* getClusterCert returns the cluster certificate
+/* deleteAdmissionWebhook deletes the resource admission webhook
 
-     GET /portal/v1/accounts/:account_id/sites/:site_domain/certificate
+   DELETE /portal/v1/accounts/:account_id/sites/:site_domain/admissionwebhook
 
    Success Response:
 
-     ops.ClusterCertificate
+     {
+       "message": "admission webhook deleted"
+     }
 */
-func (h *WebHandler) getClusterCert(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
-	var withSecrets bool
-	var err error
-	if r.URL.Query().Get(constants.WithSecretsParam) != "" {
-		withSecrets, _, err = telehttplib.ParseBool(r.URL.Query(), constants.WithSecretsParam)
-		if err != nil {
-			return trace.Wrap(err)
-		}
+func (h *WebHandler) deleteAdmissionWebhook(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteAdmissionWebhook(r.Context(), siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
 	}
 
-	cert, err := context.Operator.GetClusterCertificate(siteKey(p), withSecrets)
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("admission webhook deleted"))
+	return nil
+}
+
+/* getOperationPolicy returns the cluster operation policy
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/operationpolicy
+
+   Success Response:
+
+     storage.OperationPolicy
+*/
+func (h *WebHandler) getOperationPolicy(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	policy, err := context.Operator.GetOperationPolicy(siteKey(p))
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	roundtrip.ReplyJSON(w, http.StatusOK, cert)
+	roundtrip.ReplyJSON(w, http.StatusOK, policy)
 	return nil
 }
 
-/* updateClusterCert updates the cluster certificate
+/* updateOperationPolicy updates the cluster operation policy
 
-     POST /portal/v1/accounts/:account_id/sites/:site_domain/certificate
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/operationpolicy
 
    Success Response:
 
-     ops.ClusterCertificate
+     {
+       "message": "operation policy updated"
+     }
 */
-func (h *WebHandler) updateClusterCert(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
-	var req ops.UpdateCertificateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return trace.BadParameter(err.Error())
+func (h *WebHandler) updateOperationPolicy(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req opsclient.UpsertResourceRawReq
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
 	}
-	cert, err := context.Operator.UpdateClusterCertificate(r.Context(), req)
+
+	policy, err := storage.UnmarshalOperationPolicy(req.Resource)
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	roundtrip.ReplyJSON(w, http.StatusOK, cert)
+
+	err = context.Operator.UpdateOperationPolicy(r.Context(), siteKey(p), policy)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("operation policy updated"))
 	return nil
 }
 
-/* deleteClusterCert deletes the cluster certificate
+/* deleteOperationPolicy deletes the cluster operation policy
 
-     DELETE /portal/v1/accounts/:account_id/sites/:site_domain/certificate
+   DELETE /portal/v1/accounts/:account_id/sites/:site_domain/operationpolicy
 
    Success Response:
 
-    200 certificate deleted
+     {
+       "message": "operation policy deleted"
+     }
 */
-func (h *WebHandler) deleteClusterCert(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
-	err := context.Operator.DeleteClusterCertificate(r.Context(), siteKey(p))
+func (h *WebHandler) deleteOperationPolicy(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteOperationPolicy(r.Context(), siteKey(p))
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	roundtrip.ReplyJSON(w, http.StatusOK, message("certificate deleted"))
+
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("operation policy deleted"))
 	return nil
 }
 
-/* emitAuditEvent saves the provided event in the audit log.
+/* getRegistryConfig returns the cluster registry configuration
 
-     POST /portal/v1/accounts/:account_id/sites/:site_domain/events
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/registry
 
-   Success response:
+   Success Response:
 
-     { "message": "audit log event saved" }
+     storage.RegistryConfig
 */
-func (h *WebHandler) emitAuditEvent(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
-	var req ops.AuditEventRequest
-	err := telehttplib.ReadJSON(r, &req)
+func (h *WebHandler) getRegistryConfig(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	config, err := context.Operator.GetRegistryConfig(siteKey(p))
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	events.Emit(r.Context(), context.Operator, req.Event, events.Fields(req.Fields))
-	roundtrip.ReplyJSON(w, http.StatusOK, message("audit log event saved"))
+	roundtrip.ReplyJSON(w, http.StatusOK, config)
 	return nil
 }
 
-func (s *WebHandler) wrap(fn func(w http.ResponseWriter, r *http.Request, p httprouter.Params) error) httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		if err := fn(w, r, p); err != nil {
-			trace.WriteError(w, err)
-		}
+/* updateRegistryConfig updates the cluster registry configuration
+
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/registry
+
+   Success Response:
+
+     {
+       "message": "registry configuration updated"
+     }
+*/
+func (h *WebHandler) updateRegistryConfig(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req opsclient.UpsertResourceRawReq
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+
+	config, err := storage.UnmarshalRegistryConfig(req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = context.Operator.UpdateRegistryConfig(r.Context(), siteKey(p), config)
+	if err != nil {
+		return trace.Wrap(err)
 	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("registry configuration updated"))
+	return nil
 }
 
-func (s *WebHandler) needsAuth(fn ServiceHandle) httprouter.Handle {
-	return NeedsAuth(s.cfg.Devmode, s.cfg.Backend, s.cfg.Operator, s.cfg.Authenticator, s.cfg.Users, fn)
+/* deleteRegistryConfig resets the cluster registry configuration to defaults
+
+   DELETE /portal/v1/accounts/:account_id/sites/:site_domain/registry
+
+   Success Response:
+
+     {
+       "message": "registry configuration deleted"
+     }
+*/
+func (h *WebHandler) deleteRegistryConfig(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteRegistryConfig(r.Context(), siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("registry configuration deleted"))
+	return nil
+}
+
+/* getPersistentStorage returns the cluster persistent storage configuration
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/persistentstorage
+
+   Success Response:
+
+     storage.PersistentStorage
+*/
+func (h *WebHandler) getPersistentStorage(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	config, err := context.Operator.GetPersistentStorage(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, config)
+	return nil
+}
+
+/* updatePersistentStorage validates and updates the cluster persistent storage configuration
+
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/persistentstorage
+
+   Input: opsclient.UpdatePersistentStorageRawReq
+
+   Success Response:
+
+     {
+       "message": "persistent storage configuration updated"
+     }
+*/
+func (h *WebHandler) updatePersistentStorage(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req opsclient.UpdatePersistentStorageRawReq
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+
+	config, err := storage.UnmarshalPersistentStorage(req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = context.Operator.UpdatePersistentStorage(r.Context(), ops.UpdatePersistentStorageRequest{
+		SiteKey:  siteKey(p),
+		Resource: config,
+		Force:    req.Force,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("persistent storage configuration updated"))
+	return nil
+}
+
+/* patchPersistentStorage applies the JSON merge patch to the cluster persistent
+   storage configuration and validates the result
+
+     PATCH /portal/v1/accounts/:account_id/sites/:site_domain/persistentstorage
+
+   Input: ops.PatchResourceRequest
+
+   Success Response:
+
+     ops.PatchResourceResponse
+*/
+func (h *WebHandler) patchPersistentStorage(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.PatchResourceRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	req.SiteKey = siteKey(p)
+	resp, err := context.Operator.PatchPersistentStorage(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+/* getRetentionPolicy returns the cluster retention policy
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/retention
+
+   Success Response:
+
+     storage.RetentionPolicy
+*/
+func (h *WebHandler) getRetentionPolicy(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	policy, err := context.Operator.GetRetentionPolicy(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, policy)
+	return nil
+}
+
+/* updateRetentionPolicy updates the cluster retention policy
+
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/retention
+
+   Success Response:
+
+     {
+       "message": "retention policy updated"
+     }
+*/
+func (h *WebHandler) updateRetentionPolicy(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req opsclient.UpsertResourceRawReq
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+
+	policy, err := storage.UnmarshalRetentionPolicy(req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = context.Operator.UpdateRetentionPolicy(r.Context(), siteKey(p), policy)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("retention policy updated"))
+	return nil
+}
+
+/* deleteRetentionPolicy deletes the cluster retention policy
+
+   DELETE /portal/v1/accounts/:account_id/sites/:site_domain/retention
+
+   Success Response:
+
+     {
+       "message": "retention policy deleted"
+     }
+*/
+func (h *WebHandler) deleteRetentionPolicy(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteRetentionPolicy(r.Context(), siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("retention policy deleted"))
+	return nil
+}
+
+/* verifyNodeRemoval verifies that the node has been removed from
+   the etcd cluster, Kubernetes and Teleport
+
+     POST /portal/v1/accounts/:account_id/sites/:site_domain/noderemoval/verify
+
+   Input: ops.VerifyNodeRemovalRequest
+
+   Success Response:
+
+     ops.NodeRemovalStatus
+*/
+func (h *WebHandler) verifyNodeRemoval(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.VerifyNodeRemovalRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	status, err := context.Operator.VerifyNodeRemoval(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, status)
+	return nil
+}
+
+/* createNodeTombstone records the node that has left the cluster
+   forcibly for later reconciliation
+
+     POST /portal/v1/accounts/:account_id/sites/:site_domain/tombstones
+
+   Input: ops.NodeTombstone
+
+   Success Response:
+
+     {
+       "message": "node tombstone created"
+     }
+*/
+func (h *WebHandler) createNodeTombstone(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var tombstone ops.NodeTombstone
+	if err := telehttplib.ReadJSON(r, &tombstone); err != nil {
+		return trace.Wrap(err)
+	}
+	err := context.Operator.CreateNodeTombstone(r.Context(), siteKey(p), tombstone)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("node tombstone created"))
+	return nil
+}
+
+/* getNodeTombstones returns the nodes that have left the cluster forcibly
+   and have not been reconciled yet
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/tombstones
+
+   Success Response:
+
+     []ops.NodeTombstone
+*/
+func (h *WebHandler) getNodeTombstones(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	tombstones, err := context.Operator.GetNodeTombstones(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, tombstones)
+	return nil
+}
+
+/* deleteNodeTombstone deletes the tombstone of the specified node
+
+     DELETE /portal/v1/accounts/:account_id/sites/:site_domain/tombstones/:hostname
+
+   Success Response:
+
+     {
+       "message": "node tombstone deleted"
+     }
+*/
+func (h *WebHandler) deleteNodeTombstone(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteNodeTombstone(r.Context(), siteKey(p), p.ByName("hostname"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("node tombstone deleted"))
+	return nil
+}
+
+/* simulateNodeRemoval reports the impact of removing the node from the cluster
+   without making any changes
+
+     POST /portal/v1/accounts/:account_id/sites/:site_domain/noderemoval/simulate
+
+   Input: ops.SimulateNodeRemovalRequest
+
+   Success Response:
+
+     ops.NodeRemovalSimulation
+*/
+func (h *WebHandler) simulateNodeRemoval(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.SimulateNodeRemovalRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	simulation, err := context.Operator.SimulateNodeRemoval(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, simulation)
+	return nil
+}
+
+/* upsertInstallResources adds the specified resources to the cluster being installed
+
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/installresources
+
+   Input: ops.UpsertInstallResourcesRequest
+
+   Success Response:
+
+     {
+       "message": "install resources updated"
+     }
+*/
+func (h *WebHandler) upsertInstallResources(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.UpsertInstallResourcesRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	err := context.Operator.UpsertInstallResources(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("install resources updated"))
+	return nil
+}
+
+/* getInstallResources returns the resources uploaded for the cluster being installed
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/installresources
+
+   Success Response:
+
+     []storage.UnknownResource
+*/
+func (h *WebHandler) getInstallResources(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	resources, err := context.Operator.GetInstallResources(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, resources)
+	return nil
+}
+
+/* approveOperation approves the operation with the specified approval request
+
+     POST /portal/v1/accounts/:account_id/sites/:site_domain/approvals/:approval_id
+
+   Input: ops.ApproveOperationRequest
+
+   Success Response:
+
+     storage.OperationApproval
+*/
+func (h *WebHandler) approveOperation(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.ApproveOperationRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	req.ApprovalID = p.ByName("approval_id")
+	approval, err := context.Operator.ApproveOperation(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, approval)
+	return nil
+}
+
+/* getLogLevels returns the log level overrides of cluster controllers
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/loglevels
+
+   Success Response:
+
+     logging.Levels
+*/
+func (h *WebHandler) getLogLevels(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	levels, err := context.Operator.GetLogLevels(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, levels)
+	return nil
+}
+
+/* updateLogLevels updates log levels of cluster controllers
+
+     PUT /portal/v1/accounts/:account_id/sites/:site_domain/loglevels
+
+   Input: ops.UpdateLogLevelsRequest
+
+   Success Response:
+
+     {
+       "message": "log levels updated"
+     }
+*/
+func (h *WebHandler) updateLogLevels(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.UpdateLogLevelsRequest
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	err := context.Operator.UpdateLogLevels(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("log levels updated"))
+	return nil
+}
+
+/* getApplicationEndpoints returns application endpoints for a deployed cluster
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/endpoints
+
+   Success Response:
+
+     []ops.Endpoint
+*/
+func (h *WebHandler) getApplicationEndpoints(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	endpoints, err := context.Operator.GetApplicationEndpoints(siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, endpoints)
+	return nil
+}
+
+/* getAppInstaller returns a standalone installer for the specified application
+
+GET /portal/v1/accounts/:account_id/apps/:repository_id/:package_name/:version/installer
+
+   Success Response:
+
+     binary stream with application tarball
+*/
+func (h *WebHandler) getAppInstaller(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	accountID := p.ByName("account_id")
+	locator, err := loc.NewLocator(p.ByName("repository_id"), p.ByName("package_name"), p.ByName("version"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return trace.Wrap(err)
+	}
+
+	var installerReq ops.AppInstallerRequest
+	requestBytes := r.Form.Get("request")
+
+	err = json.Unmarshal([]byte(requestBytes), &installerReq)
+	if err != nil {
+		return trace.Wrap(err, "failed to unmarshal `%s`", requestBytes)
+	}
+	installerReq.AccountID = accountID
+
+	reader, err := context.Operator.GetAppInstaller(installerReq)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer reader.Close()
+
+	installerFilename := fmt.Sprintf("%v-%v-installer.tar.gz", locator.Name, locator.Version)
+	w.Header().Set("Content-Type", "application/x-gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+installerFilename+`"`)
+	_, err = io.Copy(w, reader)
+	return trace.Wrap(err)
+}
+
+/* getClusterCert returns the cluster certificate
+
+     GET /portal/v1/accounts/:account_id/sites/:site_domain/certificate
+
+   Success Response:
+
+     ops.ClusterCertificate
+*/
+func (h *WebHandler) getClusterCert(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var withSecrets bool
+	var err error
+	if r.URL.Query().Get(constants.WithSecretsParam) != "" {
+		withSecrets, _, err = telehttplib.ParseBool(r.URL.Query(), constants.WithSecretsParam)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
+	cert, err := context.Operator.GetClusterCertificate(siteKey(p), withSecrets)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, cert)
+	return nil
+}
+
+/* updateClusterCert updates the cluster certificate
+
+     POST /portal/v1/accounts/:account_id/sites/:site_domain/certificate
+
+   Success Response:
+
+     ops.ClusterCertificate
+*/
+func (h *WebHandler) updateClusterCert(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.UpdateCertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return trace.BadParameter(err.Error())
+	}
+	cert, err := context.Operator.UpdateClusterCertificate(r.Context(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, cert)
+	return nil
+}
+
+/* deleteClusterCert deletes the cluster certificate
+
+     DELETE /portal/v1/accounts/:account_id/sites/:site_domain/certificate
+
+   Success Response:
+
+    200 certificate deleted
+*/
+func (h *WebHandler) deleteClusterCert(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	err := context.Operator.DeleteClusterCertificate(r.Context(), siteKey(p))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, message("certificate deleted"))
+	return nil
+}
+
+/* emitAuditEvent saves the provided event in the audit log.
+
+     POST /portal/v1/accounts/:account_id/sites/:site_domain/events
+
+   Success response:
+
+     { "message": "audit log event saved" }
+*/
+func (h *WebHandler) emitAuditEvent(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	var req ops.AuditEventRequest
+	err := telehttplib.ReadJSON(r, &req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	events.Emit(r.Context(), context.Operator, req.Event, events.Fields(req.Fields))
+	roundtrip.ReplyJSON(w, http.StatusOK, message("audit log event saved"))
+	return nil
+}
+
+func (s *WebHandler) wrap(fn func(w http.ResponseWriter, r *http.Request, p httprouter.Params) error) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if err := fn(w, r, p); err != nil {
+			trace.WriteError(w, err)
+		}
+	}
+}
+
+func (s *WebHandler) needsAuth(fn ServiceHandle) httprouter.Handle {
+	return NeedsAuth(s.cfg.Devmode, s.cfg.Backend, s.cfg.Operator, s.cfg.Authenticator, s.cfg.Users, fn)
+}
+
+// needsOperationAuth is authentication wrapper for the handlers that expose
+// the state of a single operation.
+//
+// Besides the regular credentials, it accepts a bearer token issued for the operation.
+// In wizard mode only operation tokens are accepted
+func (s *WebHandler) needsOperationAuth(fn ServiceHandle) httprouter.Handle {
+	handler := func(w http.ResponseWriter, r *http.Request, params httprouter.Params) error {
+		handlerContext, err := s.getOperationHandlerContext(w, r, siteOperationKey(params))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return fn(w, r.WithContext(handlerContext.Context), params, handlerContext)
+	}
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		err := handler(w, r, params)
+		if err != nil {
+			if trace.IsAccessDenied(err) {
+				log.WithFields(fields.FromRequest(r)).WithError(err).Warn("Access denied.")
+			}
+			trace.WriteError(w, err)
+		}
+	}
+}
+
+// getOperationHandlerContext returns the handler context for the request
+// authenticated with a token issued for the specified operation
+func (s *WebHandler) getOperationHandlerContext(w http.ResponseWriter, r *http.Request, key ops.SiteOperationKey) (*HandlerContext, error) {
+	token, err := s.getOperationToken(r)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if token == nil {
+		if s.cfg.Wizard {
+			return nil, trace.AccessDenied("operation token required")
+		}
+		return GetHandlerContext(w, r, s.cfg.Backend, s.cfg.Operator, s.cfg.Authenticator, s.cfg.Users)
+	}
+	if err := token.Verify(key.AccountID, key.SiteDomain, key.OperationID, s.cfg.Backend.Now().UTC()); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// the token only grants access to the operation handlers which
+	// are not bound to a user so the operator is not wrapped with ACL
+	ctx := context.WithValue(r.Context(), constants.ClientAddrContext, r.RemoteAddr)
+	ctx = context.WithValue(ctx, constants.OperatorContext, s.cfg.Operator)
+	return &HandlerContext{
+		Operator: s.cfg.Operator,
+		SiteKey:  key.SiteKey(),
+		Context:  ctx,
+	}, nil
+}
+
+// getOperationToken returns the operation token the request has been
+// authenticated with or nil if the request does not carry an operation token
+func (s *WebHandler) getOperationToken(r *http.Request) (*storage.OperationToken, error) {
+	if s.cfg.Backend == nil {
+		return nil, nil
+	}
+	creds, err := httplib.ParseAuthHeaders(r)
+	if err != nil || !creds.IsToken() {
+		return nil, nil
+	}
+	token, err := s.cfg.Backend.GetOperationToken(creds.Password)
+	if err != nil {
+		if !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		if s.cfg.Wizard {
+			return nil, trace.AccessDenied("invalid or expired operation token")
+		}
+		// not an operation token, authenticate with regular credentials
+		return nil, nil
+	}
+	return token, nil
 }
 
 // GetHandlerContext authenticates the user that made the request and returns
@@ -2348,6 +3634,7 @@ func GetHandlerContext(w http.ResponseWriter, r *http.Request, backend storage.B
 	// Enrich the request context with additional auth info.
 	ctx := r.Context()
 	ctx = context.WithValue(ctx, constants.UserContext, authResult.User.GetName())
+	ctx = context.WithValue(ctx, constants.ClientAddrContext, r.RemoteAddr)
 	if authResult.Session != nil {
 		ctx = context.WithValue(ctx, constants.WebSessionContext, authResult.Session.GetWebSession())
 	}
@@ -18,7 +18,10 @@ package opshandler
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strconv"
 	"testing"
@@ -30,7 +33,9 @@ import (
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/opsclient"
 	"github.com/gravitational/gravity/lib/ops/opsservice"
+	"github.com/gravitational/gravity/lib/ops/resources"
 	"github.com/gravitational/gravity/lib/ops/suite"
+	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/users"
 
@@ -55,6 +60,8 @@ type OpsHandlerSuite struct {
 	testAppPath string
 	testApp     loc.Locator
 	client      *opsclient.Client
+	services    opsservice.TestServices
+	resources   *testResources
 
 	dir string
 }
@@ -72,6 +79,7 @@ func (s *OpsHandlerSuite) SetUpSuite(c *C) {
 
 func (s *OpsHandlerSuite) SetUpTest(c *C) {
 	services := opsservice.SetupTestServices(c)
+	s.services = services
 
 	s.backend = services.Backend
 	s.users = services.Users
@@ -89,14 +97,20 @@ func (s *OpsHandlerSuite) SetUpTest(c *C) {
 	}))
 	c.Assert(err, IsNil)
 
-	_, err = s.suite.SetUpTestPackage(services.Apps, services.Packages, c)
+	testApp, err := s.suite.SetUpTestPackage(services.Apps, services.Packages, c)
 	c.Assert(err, IsNil)
+	s.testApp = *testApp
 
+	s.resources = &testResources{}
 	handler, err := NewWebHandler(WebHandlerConfig{
+		Backend:      s.backend,
 		Users:        s.users,
 		Operator:     services.Operator,
 		Applications: services.Apps,
 		Packages:     services.Packages,
+		Resources: func(ops.Operator) (resources.Resources, error) {
+			return s.resources, nil
+		},
 	})
 	c.Assert(err, IsNil)
 
@@ -336,6 +350,80 @@ func (s *OpsHandlerSuite) TestToken(c *C) {
 	c.Assert(found, Equals, false)
 }
 
+func (s *OpsHandlerSuite) TestOperationTokens(c *C) {
+	handler, err := NewWebHandler(WebHandlerConfig{
+		Backend:      s.backend,
+		Users:        s.users,
+		Operator:     s.services.Operator,
+		Applications: s.services.Apps,
+		Packages:     s.services.Packages,
+		Wizard:       true,
+	})
+	c.Assert(err, IsNil)
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	client, err := opsclient.NewAuthenticatedClient(server.URL, s.adminUser, "admin-password",
+		opsclient.HTTPClient(server.Client()))
+	c.Assert(err, IsNil)
+
+	account, err := client.CreateAccount(ops.NewAccountRequest{Org: "example.com"})
+	c.Assert(err, IsNil)
+	cluster, err := client.CreateSite(ops.NewSiteRequest{
+		AppPackage: s.testApp.String(),
+		AccountID:  account.ID,
+		Provider:   schema.ProviderOnPrem,
+		DomainName: "example.com",
+	})
+	c.Assert(err, IsNil)
+	key, err := client.CreateSiteInstallOperation(context.TODO(), ops.CreateSiteInstallOperationRequest{
+		AccountID:  account.ID,
+		SiteDomain: cluster.Domain,
+	})
+	c.Assert(err, IsNil)
+	progressURL := client.Endpoint("accounts", key.AccountID, "sites", key.SiteDomain,
+		"operations", "common", key.OperationID, "progress")
+
+	// regular credentials are rejected by the wizard
+	_, err = client.Get(progressURL, url.Values{})
+	c.Assert(trace.IsAccessDenied(err), Equals, true, Commentf("%v", err))
+
+	// the client obtains an operation token transparently
+	_, err = client.GetSiteOperationProgress(*key)
+	c.Assert(err, IsNil)
+
+	token, err := client.CreateOperationToken(context.TODO(), *key)
+	c.Assert(err, IsNil)
+	bearer, err := opsclient.NewBearerClient(server.URL, token.Token,
+		opsclient.HTTPClient(server.Client()))
+	c.Assert(err, IsNil)
+	_, err = bearer.GetSiteOperationProgress(*key)
+	c.Assert(err, IsNil)
+
+	// the token does not grant access to anything but the operation
+	_, err = bearer.GetSiteOperations(key.SiteKey())
+	c.Assert(trace.IsAccessDenied(err), Equals, true, Commentf("%v", err))
+	other := *key
+	other.OperationID = "other"
+	_, err = bearer.Get(bearer.Endpoint("accounts", other.AccountID, "sites", other.SiteDomain,
+		"operations", "common", other.OperationID, "progress"), url.Values{})
+	c.Assert(trace.IsAccessDenied(err), Equals, true, Commentf("%v", err))
+	other = *key
+	other.AccountID = "other"
+	_, err = bearer.Get(bearer.Endpoint("accounts", other.AccountID, "sites", other.SiteDomain,
+		"operations", "common", other.OperationID, "progress"), url.Values{})
+	c.Assert(trace.IsAccessDenied(err), Equals, true, Commentf("%v", err))
+
+	// the tokens are rotated once the operation completes
+	err = ops.CompleteOperation(*key, client)
+	c.Assert(err, IsNil)
+	_, err = bearer.GetSiteOperationProgress(*key)
+	c.Assert(trace.IsAccessDenied(err), Equals, true, Commentf("%v", err))
+	progress, err := client.GetSiteOperationProgress(*key)
+	c.Assert(err, IsNil)
+	c.Assert(progress.State, Equals, ops.ProgressStateCompleted)
+}
+
 func (s *OpsHandlerSuite) TestClusterAuthConfiguration(c *C) {
 	// should not exist
 	key := ops.SiteKey{AccountID: "a", SiteDomain: "b"}
@@ -359,3 +447,75 @@ func (s *OpsHandlerSuite) TestClusterAuthConfiguration(c *C) {
 	c.Assert(actual.GetType(), Equals, cap.GetType())
 	c.Assert(actual.GetSecondFactor(), Equals, cap.GetSecondFactor())
 }
+
+func (s *OpsHandlerSuite) TestUpdatesResourceWithRevision(c *C) {
+	key := ops.SiteKey{AccountID: "a", SiteDomain: "b"}
+	current := teleservices.UnknownResource{
+		ResourceHeader: teleservices.ResourceHeader{
+			Kind:     "kind1",
+			Metadata: teleservices.Metadata{Name: "resource1"},
+		},
+		Raw: []byte(`{"kind":"kind1","metadata":{"name":"resource1"},"spec":{"value":"old"}}`),
+	}
+	s.resources.resources = []teleservices.UnknownResource{current}
+	revision, err := s.client.GetResourceRevision(context.TODO(), ops.GetResourceRevisionRequest{
+		SiteKey: key,
+		Kind:    "kind1",
+		Name:    "resource1",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(revision, Equals, "1")
+
+	req := ops.UpdateResourceRequest{
+		SiteKey:  key,
+		Resource: json.RawMessage(`{"kind":"kind1","metadata":{"name":"resource1"},"spec":{"value":"new"}}`),
+		Revision: revision,
+	}
+	err = s.client.UpdateResource(context.TODO(), req)
+	c.Assert(err, IsNil)
+	c.Assert(string(s.resources.resources[0].Raw), Matches, `.*"new".*`)
+
+	// The revision is stale after the update
+	req.Resource = json.RawMessage(`{"kind":"kind1","metadata":{"name":"resource1"},"spec":{"value":"other"}}`)
+	err = s.client.UpdateResource(context.TODO(), req)
+	c.Assert(trace.IsCompareFailed(err), Equals, true, Commentf("%v", err))
+	c.Assert(string(s.resources.resources[0].Raw), Matches, `.*"new".*`)
+}
+
+// testResources keeps resources in memory
+type testResources struct {
+	resources []teleservices.UnknownResource
+}
+
+func (r *testResources) Create(ctx context.Context, req resources.CreateRequest) error {
+	for i, resource := range r.resources {
+		if resource.Kind == req.Resource.Kind && resource.Metadata.Name == req.Resource.Metadata.Name {
+			r.resources[i] = req.Resource
+			return nil
+		}
+	}
+	r.resources = append(r.resources, req.Resource)
+	return nil
+}
+
+func (r *testResources) GetCollection(req resources.ListRequest) (resources.Collection, error) {
+	var collection testCollection
+	for _, resource := range r.resources {
+		if resource.Kind == req.Kind && (req.Name == "" || resource.Metadata.Name == req.Name) {
+			collection = append(collection, resource)
+		}
+	}
+	return collection, nil
+}
+
+func (r *testResources) Remove(ctx context.Context, req resources.RemoveRequest) error {
+	return trace.NotImplemented("not implemented")
+}
+
+// testCollection is a slice of test resources
+type testCollection []teleservices.UnknownResource
+
+func (c testCollection) Resources() ([]teleservices.UnknownResource, error) { return c, nil }
+func (c testCollection) WriteText(io.Writer) error                          { return nil }
+func (c testCollection) WriteJSON(io.Writer) error                          { return nil }
+func (c testCollection) WriteYAML(io.Writer) error                          { return nil }
@@ -316,6 +316,92 @@ func (h *WebHandler) deleteGithubConnector(w http.ResponseWriter, r *http.Reques
 	return nil
 }
 
+/* upsertAuthConnector creates or updates an OIDC or SAML connector
+
+   POST /portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind
+*/
+func (h *WebHandler) upsertAuthConnector(w http.ResponseWriter, r *http.Request, p httprouter.Params, ctx *HandlerContext) error {
+	var req *opsclient.UpsertResourceRawReq
+	if err := telehttplib.ReadJSON(r, &req); err != nil {
+		return trace.Wrap(err)
+	}
+	connector, err := ops.UnmarshalAuthConnector(req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if ops.AuthConnectorKind(connector) != p.ByName("kind") {
+		return trace.BadParameter("expected %v connector, got %v", p.ByName("kind"), ops.AuthConnectorKind(connector))
+	}
+	if req.TTL != 0 {
+		connector.SetTTL(clockwork.NewRealClock(), req.TTL)
+	}
+	err = ctx.Operator.UpsertAuthConnector(r.Context(), siteKey(p), connector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, message("upserted %v connector", ops.AuthConnectorKind(connector)))
+	return nil
+}
+
+/* getAuthConnector returns an OIDC or SAML connector by name
+
+   GET /portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind/:id
+*/
+func (h *WebHandler) getAuthConnector(w http.ResponseWriter, r *http.Request, p httprouter.Params, ctx *HandlerContext) error {
+	withSecrets, _, err := telehttplib.ParseBool(r.URL.Query(), constants.WithSecretsParam)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	connector, err := ctx.Operator.GetAuthConnector(siteKey(p), p.ByName("kind"), p.ByName("id"), withSecrets)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	out, err := ops.MarshalAuthConnector(connector)
+	return rawMessage(w, out, err)
+}
+
+/* getAuthConnectors returns all OIDC or SAML connectors
+
+   GET /portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind
+*/
+func (h *WebHandler) getAuthConnectors(w http.ResponseWriter, r *http.Request, p httprouter.Params, ctx *HandlerContext) error {
+	withSecrets, _, err := telehttplib.ParseBool(r.URL.Query(), constants.WithSecretsParam)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	connectors, err := ctx.Operator.GetAuthConnectors(siteKey(p), p.ByName("kind"), withSecrets)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	items := make([]json.RawMessage, len(connectors))
+	for i, connector := range connectors {
+		data, err := ops.MarshalAuthConnector(connector)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		items[i] = data
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, items)
+	return nil
+}
+
+/* deleteAuthConnector deletes an OIDC or SAML connector by its name
+
+   DELETE /portal/v1/accounts/:account_id/sites/:site_domain/authconnectors/:kind/:id
+*/
+func (h *WebHandler) deleteAuthConnector(w http.ResponseWriter, r *http.Request, p httprouter.Params, ctx *HandlerContext) error {
+	kind, name := p.ByName("kind"), p.ByName("id")
+	err := ctx.Operator.DeleteAuthConnector(r.Context(), siteKey(p), kind, name)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("%v connector %q not found", kind, name)
+		}
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, message("%v connector deleted", kind))
+	return nil
+}
+
 func rawMessage(w http.ResponseWriter, data []byte, err error) error {
 	if err != nil {
 		return trace.Wrap(err)
@@ -0,0 +1,104 @@
+/*
+Copyright 2018 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opshandler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/resources"
+
+	"github.com/gravitational/roundtrip"
+	"github.com/gravitational/trace"
+	"github.com/julienschmidt/httprouter"
+)
+
+/* updateResource updates the resource if it has not been modified since
+   the specified revision
+
+   POST /portal/v1/accounts/:account_id/sites/:site_domain/resources/update
+
+   {
+      "resource": {"kind": "smtp", "metadata": {"name": "smtp"}, "spec": {...}},
+      "revision": "<revision the update is based on>"
+   }
+
+Success response:
+
+   {
+      "message": "resource updated",
+   }
+*/
+func (h *WebHandler) updateResource(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	if h.cfg.Resources == nil {
+		return trace.NotImplemented("revision-guarded resource updates are not supported")
+	}
+	d := json.NewDecoder(r.Body)
+	var req ops.UpdateResourceRequest
+	if err := d.Decode(&req); err != nil {
+		return trace.BadParameter(err.Error())
+	}
+	req.SiteKey = siteKey(p)
+	// The resources are updated with the operator of the authenticated user
+	// so the regular access checks apply
+	controller, err := h.cfg.Resources(context.Operator)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	tracker := resources.RevisionTracker{Backend: h.cfg.Backend}
+	err = tracker.UpdateWithRevision(r.Context(), controller, req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, statusOK("resource updated"))
+	return nil
+}
+
+/* getResourceRevision returns the current revision of the specified resource
+
+   GET /portal/v1/accounts/:account_id/sites/:site_domain/resources/revision?kind=<kind>&name=<name>
+
+Success response:
+
+   {
+      "revision": "<current revision>",
+   }
+*/
+func (h *WebHandler) getResourceRevision(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *HandlerContext) error {
+	if h.cfg.Resources == nil {
+		return trace.NotImplemented("resource revisions are not supported")
+	}
+	req := ops.GetResourceRevisionRequest{
+		SiteKey: siteKey(p),
+		Kind:    r.URL.Query().Get("kind"),
+		Name:    r.URL.Query().Get("name"),
+	}
+	// The resources are read with the operator of the authenticated user
+	// so the regular access checks apply
+	controller, err := h.cfg.Resources(context.Operator)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	tracker := resources.RevisionTracker{Backend: h.cfg.Backend}
+	revision, err := tracker.GetRevision(controller, req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, ops.GetResourceRevisionResponse{Revision: revision})
+	return nil
+}
@@ -24,6 +24,8 @@ import (
 
 	"github.com/gravitational/gravity/lib/clients"
 	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/logging"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/opsservice"
 	"github.com/gravitational/gravity/lib/storage"
@@ -31,6 +33,7 @@ import (
 
 	teleservices "github.com/gravitational/teleport/lib/services"
 	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
 )
 
 // RouterConfig specifies config parameters for Router
@@ -182,6 +185,82 @@ func (r *Router) GetClusterNodes(key ops.SiteKey) ([]ops.Node, error) {
 	return client.GetClusterNodes(key)
 }
 
+// GetClusterInventory returns hardware and software inventory of cluster nodes
+func (r *Router) GetClusterInventory(key ops.SiteKey) (*ops.ClusterInventory, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetClusterInventory(key)
+}
+
+// GetClusterProbes returns the last results of the health probes on cluster nodes
+func (r *Router) GetClusterProbes(key ops.SiteKey) (*ops.ClusterProbes, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetClusterProbes(key)
+}
+
+// GetStorageCapacity returns the block storage capacity available
+// for persistent volumes on cluster nodes
+func (r *Router) GetStorageCapacity(req ops.StorageCapacityRequest) (*ops.StorageCapacity, error) {
+	client, err := r.PickClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetStorageCapacity(req)
+}
+
+// GetStorageUsage returns the persistent storage capacity and usage
+// of the application namespaces
+func (r *Router) GetStorageUsage(key ops.SiteKey) (*ops.StorageUsage, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetStorageUsage(key)
+}
+
+// GetNodeCertificates returns the expiration of the certificates
+// installed on cluster nodes
+func (r *Router) GetNodeCertificates(key ops.SiteKey) ([]ops.NodeCertificates, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetNodeCertificates(key)
+}
+
+// GetClusterSummaries returns summaries of all clusters managed by this Ops Center
+func (r *Router) GetClusterSummaries(accountID string) ([]ops.ClusterSummary, error) {
+	return r.Local.GetClusterSummaries(accountID)
+}
+
+// GetClusterSummary returns the summary of the specified cluster.
+//
+// The summary of a connected remote cluster is requested from the cluster itself.
+// If the cluster cannot be reached, the summary is built from the state known
+// to this Ops Center
+func (r *Router) GetClusterSummary(key ops.SiteKey) (*ops.ClusterSummary, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if client == r.Local {
+		return r.Local.GetClusterSummary(key)
+	}
+	summary, err := client.GetClusterSummary(key)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to query summary from cluster %v.", key.SiteDomain)
+		return r.Local.GetClusterSummary(key)
+	}
+	summary.Local = false
+	summary.Online = true
+	return summary, nil
+}
+
 func (r *Router) ResetUserPassword(req ops.ResetUserPasswordRequest) (string, error) {
 	client, err := r.PickClient(req.SiteDomain)
 	if err != nil {
@@ -250,6 +329,30 @@ func (r *Router) ActivateSite(req ops.ActivateSiteRequest) error {
 	return client.ActivateSite(req)
 }
 
+func (r *Router) EnableComponent(ctx context.Context, req ops.ComponentRequest) (*ops.SiteOperationKey, error) {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.EnableComponent(ctx, req)
+}
+
+func (r *Router) UpdateClusterLicense(req ops.UpdateLicenseRequest) error {
+	client, err := r.PickClient(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateClusterLicense(req)
+}
+
+func (r *Router) DisableComponent(ctx context.Context, req ops.ComponentRequest) (*ops.SiteOperationKey, error) {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.DisableComponent(ctx, req)
+}
+
 func (r *Router) CompleteFinalInstallStep(req ops.CompleteFinalInstallStepRequest) error {
 	client, err := r.RemoteClient(req.SiteDomain)
 	if err != nil {
@@ -348,6 +451,16 @@ func (r *Router) CreateClusterGarbageCollectOperation(ctx context.Context, req o
 	return r.Local.CreateClusterGarbageCollectOperation(ctx, req)
 }
 
+// CreatePatchOperation creates a new operation to patch the operating system on cluster nodes
+func (r *Router) CreatePatchOperation(ctx context.Context, req ops.CreatePatchOperationRequest) (*ops.SiteOperationKey, error) {
+	return r.Local.CreatePatchOperation(ctx, req)
+}
+
+// CreateUpdateBinaryOperation creates a new operation to replace the gravity binary on cluster nodes
+func (r *Router) CreateUpdateBinaryOperation(ctx context.Context, req ops.CreateUpdateBinaryOperationRequest) (*ops.SiteOperationKey, error) {
+	return r.Local.CreateUpdateBinaryOperation(ctx, req)
+}
+
 // CreateUpdateEnvarsOperation creates a new operation to update cluster runtime environment variables
 func (r *Router) CreateUpdateEnvarsOperation(ctx context.Context, req ops.CreateUpdateEnvarsOperationRequest) (*ops.SiteOperationKey, error) {
 	return r.Local.CreateUpdateEnvarsOperation(ctx, req)
@@ -366,6 +479,15 @@ func (r *Router) GetSiteOperationLogs(key ops.SiteOperationKey) (io.ReadCloser,
 	return client.GetSiteOperationLogs(key)
 }
 
+// GetOperationArtifacts returns a gzipped tarball with the artifacts of the specified operation
+func (r *Router) GetOperationArtifacts(key ops.SiteOperationKey) (io.ReadCloser, error) {
+	client, err := r.PickOperationClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetOperationArtifacts(key)
+}
+
 func (r *Router) CreateLogEntry(key ops.SiteOperationKey, entry ops.LogEntry) error {
 	client, err := r.PickOperationClient(key.SiteDomain)
 	if err != nil {
@@ -424,6 +546,41 @@ func (r *Router) GetSiteReport(key ops.SiteKey) (io.ReadCloser, error) {
 	return client.GetSiteReport(key)
 }
 
+// UploadClusterReport stores the diagnostics report of the specified cluster
+// with the local operator: reports are uploaded by the clusters to this Ops Center
+func (r *Router) UploadClusterReport(key ops.SiteKey, reader io.Reader) error {
+	return r.Local.UploadClusterReport(key, reader)
+}
+
+// ImportMigration starts the operation that imports the migrated application data
+// into the specified cluster. The data is forwarded to the remote cluster over the tunnel
+func (r *Router) ImportMigration(req ops.ImportMigrationRequest, reader io.Reader) (*ops.SiteOperationKey, error) {
+	client, err := r.PickClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.ImportMigration(req, reader)
+}
+
+// GetChartIndex returns the chart repository index file of the specified cluster
+func (r *Router) GetChartIndex(key ops.SiteKey) (io.ReadCloser, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetChartIndex(key)
+}
+
+// FetchChart returns the specified Helm chart from the chart repository
+// of the specified cluster
+func (r *Router) FetchChart(key ops.SiteKey, locator loc.Locator) (io.ReadCloser, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.FetchChart(key, locator)
+}
+
 // ValidateServers runs pre-installation checks
 func (r *Router) ValidateServers(ctx context.Context, req ops.ValidateServersRequest) error {
 	client, err := r.WizardClient(req.SiteDomain)
@@ -603,6 +760,265 @@ func (r *Router) DeleteSMTPConfig(ctx context.Context, key ops.SiteKey) error {
 	return client.DeleteSMTPConfig(ctx, key)
 }
 
+// GetHealthReport returns the cluster health report configuration
+func (r *Router) GetHealthReport(key ops.SiteKey) (storage.HealthReport, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetHealthReport(key)
+}
+
+// UpdateHealthReport updates the cluster health report configuration
+func (r *Router) UpdateHealthReport(ctx context.Context, key ops.SiteKey, config storage.HealthReport) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateHealthReport(ctx, key, config)
+}
+
+// DeleteHealthReport deletes the cluster health report configuration
+func (r *Router) DeleteHealthReport(ctx context.Context, key ops.SiteKey) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.DeleteHealthReport(ctx, key)
+}
+
+// GetAdmissionWebhook returns the resource admission webhook
+func (r *Router) GetAdmissionWebhook(key ops.SiteKey) (storage.AdmissionWebhook, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetAdmissionWebhook(key)
+}
+
+// UpdateAdmissionWebhook updates the resource admission webhook
+func (r *Router) UpdateAdmissionWebhook(ctx context.Context, key ops.SiteKey, webhook storage.AdmissionWebhook) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateAdmissionWebhook(ctx, key, webhook)
+}
+
+// DeleteAdmissionWebhook deletes the resource admission webhook
+func (r *Router) DeleteAdmissionWebhook(ctx context.Context, key ops.SiteKey) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.DeleteAdmissionWebhook(ctx, key)
+}
+
+// GetOperationPolicy returns the cluster operation policy
+func (r *Router) GetOperationPolicy(key ops.SiteKey) (storage.OperationPolicy, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetOperationPolicy(key)
+}
+
+// UpdateOperationPolicy updates the cluster operation policy
+func (r *Router) UpdateOperationPolicy(ctx context.Context, key ops.SiteKey, policy storage.OperationPolicy) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateOperationPolicy(ctx, key, policy)
+}
+
+// DeleteOperationPolicy deletes the cluster operation policy
+func (r *Router) DeleteOperationPolicy(ctx context.Context, key ops.SiteKey) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.DeleteOperationPolicy(ctx, key)
+}
+
+// GetRegistryConfig returns the cluster registry configuration
+func (r *Router) GetRegistryConfig(key ops.SiteKey) (storage.RegistryConfig, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetRegistryConfig(key)
+}
+
+// UpdateRegistryConfig updates the cluster registry configuration
+func (r *Router) UpdateRegistryConfig(ctx context.Context, key ops.SiteKey, config storage.RegistryConfig) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateRegistryConfig(ctx, key, config)
+}
+
+// DeleteRegistryConfig resets the cluster registry configuration to defaults
+func (r *Router) DeleteRegistryConfig(ctx context.Context, key ops.SiteKey) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.DeleteRegistryConfig(ctx, key)
+}
+
+// GetPersistentStorage returns the cluster persistent storage configuration
+func (r *Router) GetPersistentStorage(key ops.SiteKey) (storage.PersistentStorage, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetPersistentStorage(key)
+}
+
+// UpdatePersistentStorage validates and updates the cluster persistent storage configuration
+func (r *Router) UpdatePersistentStorage(ctx context.Context, req ops.UpdatePersistentStorageRequest) error {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdatePersistentStorage(ctx, req)
+}
+
+// PatchPersistentStorage applies the JSON merge patch to the cluster
+// persistent storage configuration and validates the result
+func (r *Router) PatchPersistentStorage(ctx context.Context, req ops.PatchResourceRequest) (*ops.PatchResourceResponse, error) {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.PatchPersistentStorage(ctx, req)
+}
+
+// GetRetentionPolicy returns the cluster retention policy
+func (r *Router) GetRetentionPolicy(key ops.SiteKey) (storage.RetentionPolicy, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetRetentionPolicy(key)
+}
+
+// UpdateRetentionPolicy updates the cluster retention policy
+func (r *Router) UpdateRetentionPolicy(ctx context.Context, key ops.SiteKey, policy storage.RetentionPolicy) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateRetentionPolicy(ctx, key, policy)
+}
+
+// DeleteRetentionPolicy deletes the cluster retention policy
+func (r *Router) DeleteRetentionPolicy(ctx context.Context, key ops.SiteKey) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.DeleteRetentionPolicy(ctx, key)
+}
+
+// VerifyNodeRemoval verifies that the node has been removed from
+// the etcd cluster, Kubernetes and Teleport
+func (r *Router) VerifyNodeRemoval(ctx context.Context, req ops.VerifyNodeRemovalRequest) (*ops.NodeRemovalStatus, error) {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.VerifyNodeRemoval(ctx, req)
+}
+
+// CreateNodeTombstone records the node that has left the cluster
+// forcibly for later reconciliation
+func (r *Router) CreateNodeTombstone(ctx context.Context, key ops.SiteKey, tombstone ops.NodeTombstone) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.CreateNodeTombstone(ctx, key, tombstone)
+}
+
+// GetNodeTombstones returns the nodes that have left the cluster forcibly
+// and have not been reconciled yet
+func (r *Router) GetNodeTombstones(key ops.SiteKey) ([]ops.NodeTombstone, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetNodeTombstones(key)
+}
+
+// DeleteNodeTombstone deletes the tombstone of the node with the specified hostname
+func (r *Router) DeleteNodeTombstone(ctx context.Context, key ops.SiteKey, hostname string) error {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.DeleteNodeTombstone(ctx, key, hostname)
+}
+
+// SimulateNodeRemoval reports the impact of removing the node from the cluster
+// without making any changes
+func (r *Router) SimulateNodeRemoval(ctx context.Context, req ops.SimulateNodeRemovalRequest) (*ops.NodeRemovalSimulation, error) {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.SimulateNodeRemoval(ctx, req)
+}
+
+// UpsertInstallResources adds the specified resources to the cluster
+// being installed replacing the resources with the same kind and name
+func (r *Router) UpsertInstallResources(ctx context.Context, req ops.UpsertInstallResourcesRequest) error {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpsertInstallResources(ctx, req)
+}
+
+// GetInstallResources returns the resources uploaded for the
+// cluster being installed
+func (r *Router) GetInstallResources(key ops.SiteKey) ([]storage.UnknownResource, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetInstallResources(key)
+}
+
+// ApproveOperation approves the operation with the specified approval request
+func (r *Router) ApproveOperation(ctx context.Context, req ops.ApproveOperationRequest) (*storage.OperationApproval, error) {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.ApproveOperation(ctx, req)
+}
+
+// GetLogLevels returns the log level overrides of cluster controllers
+func (r *Router) GetLogLevels(key ops.SiteKey) (logging.Levels, error) {
+	client, err := r.RemoteClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetLogLevels(key)
+}
+
+// UpdateLogLevels updates log levels of cluster controllers without restarting them
+func (r *Router) UpdateLogLevels(ctx context.Context, req ops.UpdateLogLevelsRequest) error {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateLogLevels(ctx, req)
+}
+
 // GetAlerts returns a list of monitoring alerts
 func (r *Router) GetAlerts(key ops.SiteKey) ([]storage.Alert, error) {
 	client, err := r.RemoteClient(key.SiteDomain)
@@ -694,6 +1110,15 @@ func (r *Router) UpdateClusterConfiguration(req ops.UpdateClusterConfigRequest)
 	return client.UpdateClusterConfiguration(req)
 }
 
+// PatchClusterConfiguration applies the JSON merge patch to the cluster configuration
+func (r *Router) PatchClusterConfiguration(ctx context.Context, req ops.PatchResourceRequest) (*ops.PatchResourceResponse, error) {
+	client, err := r.RemoteClient(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.PatchClusterConfiguration(ctx, req)
+}
+
 func (r *Router) GetApplicationEndpoints(key ops.SiteKey) ([]ops.Endpoint, error) {
 	client, err := r.RemoteClient(key.SiteDomain)
 	if err != nil {
@@ -863,6 +1288,46 @@ func (r *Router) DeleteGithubConnector(ctx context.Context, key ops.SiteKey, nam
 	return client.DeleteGithubConnector(ctx, key, name)
 }
 
+// UpsertAuthConnector creates or updates an OIDC or SAML connector
+func (r *Router) UpsertAuthConnector(ctx context.Context, key ops.SiteKey, connector teleservices.Resource) error {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpsertAuthConnector(ctx, key, connector)
+}
+
+// GetAuthConnector returns the connector of the specified kind by name
+//
+// Returned connector excludes secrets unless withSecrets is true.
+func (r *Router) GetAuthConnector(key ops.SiteKey, kind, name string, withSecrets bool) (teleservices.Resource, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetAuthConnector(key, kind, name, withSecrets)
+}
+
+// GetAuthConnectors returns all connectors of the specified kind
+//
+// Returned connectors exclude secrets unless withSecrets is true.
+func (r *Router) GetAuthConnectors(key ops.SiteKey, kind string, withSecrets bool) ([]teleservices.Resource, error) {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.GetAuthConnectors(key, kind, withSecrets)
+}
+
+// DeleteAuthConnector deletes the connector of the specified kind by name
+func (r *Router) DeleteAuthConnector(ctx context.Context, key ops.SiteKey, kind, name string) error {
+	client, err := r.PickClient(key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.DeleteAuthConnector(ctx, key, kind, name)
+}
+
 // UpsertAuthGateway updates auth gateway configuration.
 func (r *Router) UpsertAuthGateway(ctx context.Context, key ops.SiteKey, gw storage.AuthGateway) error {
 	return r.Local.UpsertAuthGateway(ctx, key, gw)
@@ -891,6 +1356,55 @@ func (r *Router) EmitAuditEvent(ctx context.Context, req ops.AuditEventRequest)
 	return r.Local.EmitAuditEvent(ctx, req)
 }
 
+// CreateDownloadToken creates a new token granting access
+// to download the installer of the specified application
+func (r *Router) CreateDownloadToken(ctx context.Context, req ops.CreateDownloadTokenRequest) (*storage.DownloadToken, error) {
+	return r.Local.CreateDownloadToken(ctx, req)
+}
+
+// GetDownloadTokens returns active download tokens of the specified account
+func (r *Router) GetDownloadTokens(ctx context.Context, accountID string) ([]storage.DownloadToken, error) {
+	return r.Local.GetDownloadTokens(ctx, accountID)
+}
+
+// DeleteDownloadToken deletes the specified download token
+func (r *Router) DeleteDownloadToken(ctx context.Context, req ops.DeleteDownloadTokenRequest) error {
+	return r.Local.DeleteDownloadToken(ctx, req)
+}
+
+// CreateOperationToken issues a new token granting access to the plan
+// and progress of the specified operation
+func (r *Router) CreateOperationToken(ctx context.Context, key ops.SiteOperationKey) (*storage.OperationToken, error) {
+	client, err := r.PickOperationClient(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return client.CreateOperationToken(ctx, key)
+}
+
+// UpdateLabels sets and removes labels of the cluster
+// or of the cluster node specified in the request.
+//
+// Cluster labels are stored in the local record of the cluster so they
+// can be used to select clusters connected to this Ops Center, while
+// node labels are forwarded to the cluster the node belongs to
+func (r *Router) UpdateLabels(ctx context.Context, req ops.UpdateLabelsRequest) error {
+	if req.AdvertiseIP == "" {
+		return r.Local.UpdateLabels(ctx, req)
+	}
+	client, err := r.PickClient(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return client.UpdateLabels(ctx, req)
+}
+
+// CheckAccess returns an access denied error if the current user
+// is not allowed to perform the specified action in the cluster
+func (r *Router) CheckAccess(ctx context.Context, req ops.CheckAccessRequest) error {
+	return r.Local.CheckAccess(ctx, req)
+}
+
 // CreateUserInvite creates a new invite token for a user.
 func (r *Router) CreateUserInvite(ctx context.Context, req ops.CreateUserInviteRequest) (*storage.UserToken, error) {
 	client, err := r.PickClient(req.SiteDomain)
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gravitational/gravity/lib/admission"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// GetAdmissionWebhook returns the resource admission webhook
+func (o *Operator) GetAdmissionWebhook(key ops.SiteKey) (storage.AdmissionWebhook, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return getAdmissionWebhook(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace))
+}
+
+// UpdateAdmissionWebhook updates the resource admission webhook
+func (o *Operator) UpdateAdmissionWebhook(ctx context.Context, key ops.SiteKey, webhook storage.AdmissionWebhook) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	data, err := storage.MarshalAdmissionWebhook(webhook)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return updateConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.AdmissionWebhookConfigMap, defaults.KubeSystemNamespace, string(data), nil)
+}
+
+// DeleteAdmissionWebhook deletes the resource admission webhook
+func (o *Operator) DeleteAdmissionWebhook(ctx context.Context, key ops.SiteKey) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = rigging.ConvertError(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+		Delete(constants.AdmissionWebhookConfigMap, &metav1.DeleteOptions{}))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("no admission webhook found")
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// admit submits the change to the specified resource to the admission webhook.
+// object is the new resource state and should be nil for deletions.
+// The change is allowed if no admission webhook has been configured.
+//
+// Admission is only enforced by the cluster's own gravity-site which is the
+// only process guaranteed to have access to the Kubernetes API
+func (o *Operator) admit(ctx context.Context, key ops.SiteKey, kind, name string, object interface{}) error {
+	if !o.cfg.Local {
+		return nil
+	}
+	webhook, err := o.GetAdmissionWebhook(key)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	req := admission.Request{
+		Cluster:   key.SiteDomain,
+		Operation: admission.OperationDelete,
+		Kind:      kind,
+		Name:      name,
+		User:      storage.UserFromContext(ctx),
+	}
+	if object != nil {
+		req.Operation = admission.OperationUpsert
+		req.Object, err = json.Marshal(object)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return trace.Wrap(admission.Review(ctx, webhook, req))
+}
+
+func getAdmissionWebhook(client corev1.ConfigMapInterface) (storage.AdmissionWebhook, error) {
+	data, err := getConfigMap(client, constants.AdmissionWebhookConfigMap)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("no admission webhook found")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return storage.UnmarshalAdmissionWebhook([]byte(data))
+}
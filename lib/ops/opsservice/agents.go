@@ -36,7 +36,6 @@ import (
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/users"
 	"github.com/gravitational/gravity/lib/utils"
-	licenseapi "github.com/gravitational/license"
 
 	"github.com/gravitational/satellite/agent/proto/agentpb"
 	"github.com/gravitational/trace"
@@ -490,24 +489,21 @@ func (r *AgentPeerStore) checkLicense(ctx context.Context, numPeers int, cluster
 		return nil
 	}
 
-	license, err := licenseapi.ParseLicense(cluster.License)
-	if err != nil {
-		return trace.Wrap(err)
-	}
-
 	count, err := r.teleport.GetServerCount(ctx, clusterName)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 
-	err = license.GetPayload().CheckCount(count + numPeers + 1)
+	status, err := ops.ValidateLicense(ops.ValidateLicenseRequest{
+		License:   cluster.License,
+		NodeCount: count + numPeers + 1,
+		NodeCPUs:  []uint{info.GetNumCPU()},
+	})
 	if err != nil {
-		return trace.AccessDenied(trace.UserMessage(err))
+		return trace.Wrap(err)
 	}
-
-	err = checkLicenseCPU(license.GetPayload(), info.GetNumCPU())
-	if err != nil {
-		return trace.AccessDenied("peer %v not authorized", info.GetHostname())
+	if warning := status.Warning(); warning != "" {
+		r.Warnf("License check for peer %v: %v.", info.GetHostname(), warning)
 	}
 
 	r.Debugf("Verified license for %q.", clusterName)
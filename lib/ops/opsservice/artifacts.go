@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/gravitational/gravity/lib/archive"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/trace"
+)
+
+// GetOperationArtifacts returns a gzipped tarball with the artifacts
+// of the specified operation: the operation record, its plan, the last
+// progress entry and the operation log
+func (o *Operator) GetOperationArtifacts(key ops.SiteOperationKey) (io.ReadCloser, error) {
+	cluster, err := o.openSite(key.SiteKey())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return cluster.getOperationArtifacts(key)
+}
+
+func (s *site) getOperationArtifacts(key ops.SiteOperationKey) (io.ReadCloser, error) {
+	operation, err := s.backend().GetSiteOperation(key.SiteDomain, key.OperationID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	items := []*archive.Item{}
+	item, err := jsonItem(ops.OperationArtifactOperation, operation)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	items = append(items, item)
+	plan, err := s.service.GetOperationPlan(key)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	if plan != nil {
+		item, err := jsonItem(ops.OperationArtifactPlan, plan)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		items = append(items, item)
+	}
+	progress, err := s.backend().GetLastProgressEntry(key.SiteDomain, key.OperationID)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	if progress != nil {
+		item, err := jsonItem(ops.OperationArtifactProgress, progress)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		items = append(items, item)
+	}
+	item, err = s.operationLogItem(key)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	if item != nil {
+		items = append(items, item)
+	}
+
+	// use a pipe to avoid buffering the logs in memory
+	reader, writer := io.Pipe()
+	go func() {
+		gzWriter := gzip.NewWriter(writer)
+		tarball := archive.NewTarAppender(gzWriter)
+		err := tarball.Add(items...)
+		if errClose := tarball.Close(); err == nil {
+			err = errClose
+		}
+		if errClose := gzWriter.Close(); err == nil {
+			err = errClose
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader, nil
+}
+
+// operationLogItem returns the archive item with the log of the specified operation
+func (s *site) operationLogItem(key ops.SiteOperationKey) (*archive.Item, error) {
+	path := s.operationLogPath(key)
+	if len(s.service.cfg.InstallLogFiles) > 0 {
+		path = s.service.cfg.InstallLogFiles[0]
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, trace.ConvertSystemError(err)
+	}
+	// only archive the log written so far as the operation might still be active
+	reader := struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, fi.Size()), file}
+	return archive.ItemFromStream(ops.OperationArtifactLog,
+		reader, fi.Size(), defaults.SharedReadMask), nil
+}
+
+func jsonItem(path string, v interface{}) (*archive.Item, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return archive.ItemFromStringMode(path, string(data), defaults.SharedReadMask), nil
+}
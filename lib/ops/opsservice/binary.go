@@ -0,0 +1,77 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+)
+
+// CreateUpdateBinaryOperation creates a new operation to replace
+// the gravity binary on cluster nodes
+func (o *Operator) CreateUpdateBinaryOperation(ctx context.Context, r ops.CreateUpdateBinaryOperationRequest) (*ops.SiteOperationKey, error) {
+	err := r.Check()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.openSite(r.ClusterKey)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	key, err := cluster.createUpdateBinaryOperation(ctx, r)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+// createUpdateBinaryOperation creates a new operation to replace
+// the gravity binary on cluster nodes
+func (s *site) createUpdateBinaryOperation(ctx context.Context, req ops.CreateUpdateBinaryOperationRequest) (*ops.SiteOperationKey, error) {
+	_, err := s.packages().ReadPackageEnvelope(req.Package)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("package %v is not available in the cluster, "+
+				"make sure it has been uploaded first", req.Package)
+		}
+		return nil, trace.Wrap(err)
+	}
+	op := ops.SiteOperation{
+		ID:         uuid.New(),
+		AccountID:  s.key.AccountID,
+		SiteDomain: s.key.SiteDomain,
+		Type:       ops.OperationUpdateBinary,
+		Created:    s.clock().UtcNow(),
+		CreatedBy:  storage.UserFromContext(ctx),
+		Updated:    s.clock().UtcNow(),
+		State:      ops.OperationUpdateBinaryInProgress,
+		UpdateBinary: &storage.UpdateBinaryOperationState{
+			Package:         req.Package.String(),
+			PreviousPackage: s.gravityPackage.String(),
+		},
+	}
+	key, err := s.getOperationGroup().createSiteOperation(op)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
@@ -0,0 +1,583 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/rpc"
+	"github.com/gravitational/gravity/lib/status"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/pborman/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetNodeCertificates returns the expiration of the certificates
+// installed on cluster nodes
+func (o *Operator) GetNodeCertificates(key ops.SiteKey) ([]ops.NodeCertificates, error) {
+	cluster, err := o.openSite(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return cluster.getNodeCertificates(context.TODO())
+}
+
+// getNodeCertificates collects the certificates from all cluster nodes.
+// Nodes that fail to respond are reported with an error
+func (s *site) getNodeCertificates(ctx context.Context) ([]ops.NodeCertificates, error) {
+	var mu sync.Mutex
+	var nodes []ops.NodeCertificates
+	err := s.executeOnTeleportServers(ctx, func(ctx context.Context, runner *serverRunner) error {
+		certs, err := s.listNodeCertificates(runner)
+		node := ops.NodeCertificates{
+			Hostname:     runner.server.HostName(),
+			AdvertiseIP:  runner.server.(*teleportServer).IP,
+			Certificates: certs,
+		}
+		if err != nil {
+			node.Error = trace.UserMessage(err)
+		}
+		mu.Lock()
+		nodes = append(nodes, node)
+		mu.Unlock()
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		s.WithError(err).Warn("Failed to collect certificates from some nodes.")
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Hostname < nodes[j].Hostname
+	})
+	return nodes, nil
+}
+
+func (s *site) listNodeCertificates(runner *serverRunner) ([]ops.NodeCertificate, error) {
+	var out bytes.Buffer
+	err := runner.RunStream(&out, s.gravityCommand("system", "certificates", "ls", "--format=json")...)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to list certificates")
+	}
+	var certs []ops.NodeCertificate
+	if err := json.Unmarshal(out.Bytes(), &certs); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return certs, nil
+}
+
+// CertificateManagerConfig is the configuration of the certificate manager
+type CertificateManagerConfig struct {
+	// Operator is the cluster operator service
+	Operator *Operator
+	// RotateBefore is how long before the expiration the node
+	// certificates are rotated
+	RotateBefore time.Duration
+	// Clock is used to mock time in tests
+	Clock clockwork.Clock
+	// FieldLogger is used for logging
+	log.FieldLogger
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (r *CertificateManagerConfig) CheckAndSetDefaults() error {
+	if r.Operator == nil {
+		return trace.BadParameter("missing Operator")
+	}
+	if r.RotateBefore == 0 {
+		r.RotateBefore = defaults.CertificateRotationThreshold
+	}
+	if r.RotateBefore < 0 {
+		return trace.BadParameter("certificate rotation threshold cannot be negative")
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = log.WithField(trace.Component, "certmanager")
+	}
+	return nil
+}
+
+// CertificateManager tracks the expiration of the node certificates
+// and rotates them ahead of their expiration with a rolling operation
+type CertificateManager struct {
+	CertificateManagerConfig
+}
+
+// NewCertificateManager returns a new certificate manager
+func NewCertificateManager(config CertificateManagerConfig) (*CertificateManager, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &CertificateManager{CertificateManagerConfig: config}, nil
+}
+
+// Run periodically checks the node certificates and rotates the ones
+// that expire soon until the context is canceled
+func (r *CertificateManager) Run(ctx context.Context) {
+	r.Info("Starting certificate manager.")
+	ticker := r.Clock.NewTicker(defaults.CertificateRotationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.Chan():
+			if err := r.RotateIfDue(ctx); err != nil {
+				r.WithError(err).Warn("Failed to rotate node certificates.")
+			}
+		case <-ctx.Done():
+			r.Info("Stopping certificate manager.")
+			return
+		}
+	}
+}
+
+// RotateIfDue rotates the certificates of the nodes with certificates
+// that expire within the configured threshold.
+// The rotation is skipped if the cluster is not active, e.g. while
+// another operation is in progress
+func (r *CertificateManager) RotateIfDue(ctx context.Context) error {
+	cluster, err := r.Operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if cluster.State != ops.SiteStateActive {
+		r.Debugf("Cluster is %v, will retry certificate rotation.", cluster.State)
+		return nil
+	}
+	site, err := r.Operator.openSite(cluster.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	nodes, err := site.getNodeCertificates(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	deadline := r.Clock.Now().Add(r.RotateBefore)
+	servers := serversToRotate(cluster.ClusterState.Servers, nodes, deadline, r.FieldLogger)
+	if len(servers) == 0 {
+		return nil
+	}
+	leader, err := cluster.ClusterState.FindServerByIP(os.Getenv(constants.EnvPodIP))
+	if err != nil {
+		return trace.Wrap(err, "failed to determine the node the cluster controller is running on")
+	}
+	operation, err := site.createRotateCertificatesOperation(ctx, *leader, servers)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	r.WithField("operation", operation).Info("Rotating node certificates.")
+	return trace.Wrap(site.executeRotateCertificatesOperation(ctx, *operation))
+}
+
+// serversToRotate returns the servers with certificates that expire
+// before the specified deadline.
+// Nodes that failed to report their certificates are skipped
+func serversToRotate(servers []storage.Server, nodes []ops.NodeCertificates, deadline time.Time, logger log.FieldLogger) (result []storage.Server) {
+	for _, node := range nodes {
+		if node.Error != "" {
+			logger.WithField("node", node.Hostname).Warnf("Failed to check certificates: %v.", node.Error)
+			continue
+		}
+		expiring := node.Expiring(deadline)
+		if len(expiring) == 0 {
+			continue
+		}
+		for _, server := range servers {
+			if server.AdvertiseIP != node.AdvertiseIP {
+				continue
+			}
+			logger.WithField("node", server.Hostname).Infof("Certificates expire soon: %v.", formatCertificates(expiring))
+			result = append(result, server)
+		}
+	}
+	return result
+}
+
+// createRotateCertificatesOperation creates a new operation to rotate
+// the certificates on the specified servers along with its plan
+func (s *site) createRotateCertificatesOperation(ctx context.Context, leader storage.Server, servers []storage.Server) (*ops.SiteOperation, error) {
+	op := ops.SiteOperation{
+		ID:         uuid.New(),
+		AccountID:  s.key.AccountID,
+		SiteDomain: s.key.SiteDomain,
+		Type:       ops.OperationRotateCertificates,
+		Created:    s.clock().UtcNow(),
+		CreatedBy:  storage.UserFromContext(ctx),
+		Updated:    s.clock().UtcNow(),
+		State:      ops.OperationRotateCertificatesInProgress,
+	}
+	key, err := s.getOperationGroup().createSiteOperation(op)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plan, err := newRotateCertificatesPlan(op, leader, servers)
+	if err == nil {
+		err = s.service.CreateOperationPlan(*key, *plan)
+	}
+	if err != nil {
+		if errFail := ops.FailOperation(*key, s.service, trace.UserMessage(err)); errFail != nil {
+			s.WithError(errFail).Warn("Failed to mark operation failed.")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return &op, nil
+}
+
+// executeRotateCertificatesOperation executes the plan of the specified
+// certificate rotation operation and completes the operation
+func (s *site) executeRotateCertificatesOperation(ctx context.Context, operation ops.SiteOperation) error {
+	engine := &rotateCertsEngine{
+		FieldLogger: &fsm.Logger{
+			FieldLogger: s.WithField("operation", operation.ID),
+			Key:         operation.Key(),
+			Operator:    s.service,
+		},
+		site:      s,
+		operation: operation,
+	}
+	machine, err := fsm.New(fsm.Config{
+		Engine: engine,
+		Logger: engine.FieldLogger,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	machine.SetPreExec(engine.updateProgress)
+	fsmErr := machine.ExecutePlan(ctx, utils.DiscardProgress)
+	if fsmErr != nil {
+		engine.WithError(fsmErr).Warn("Failed to rotate node certificates.")
+	}
+	return trace.NewAggregate(fsmErr, machine.Complete(fsmErr))
+}
+
+// newRotateCertificatesPlan returns a new plan that rotates the certificates
+// on the specified servers one at a time: masters first, then regular nodes.
+//
+// All phases are executed by the cluster controller on the leader node.
+// As the services on the leader node restart after the certificate rotation,
+// the leader node is rotated last and is not waited on
+func newRotateCertificatesPlan(operation ops.SiteOperation, leader storage.Server, servers []storage.Server) (*storage.OperationPlan, error) {
+	masters, nodes := fsm.SplitServers(servers)
+	for i, master := range masters {
+		if master.AdvertiseIP == leader.AdvertiseIP {
+			// rotate the leader node last
+			masters = append(append(masters[:i:i], masters[i+1:]...), master)
+			break
+		}
+	}
+	builder := rotateCertsPlanBuilder{leader: leader}
+	var phases []storage.OperationPhase
+	if len(nodes) != 0 {
+		phases = append(phases, builder.nodes("/nodes", "Rotate certificates on regular nodes", nodes))
+	}
+	if len(masters) != 0 {
+		phase := builder.nodes("/masters", "Rotate certificates on master nodes", masters)
+		phases = append([]storage.OperationPhase{phase}, phases...)
+		if len(phases) > 1 {
+			phases[1].Requires = []string{phase.ID}
+		}
+	}
+	if len(phases) == 0 {
+		return nil, trace.NotFound("no nodes to rotate certificates on")
+	}
+	return &storage.OperationPlan{
+		OperationID:   operation.ID,
+		OperationType: operation.Type,
+		AccountID:     operation.AccountID,
+		ClusterName:   operation.SiteDomain,
+		Phases:        phases,
+		Servers:       servers,
+	}, nil
+}
+
+// nodes returns a new phase that rotates the certificates
+// on the specified servers one at a time
+func (r *rotateCertsPlanBuilder) nodes(id, description string, servers []storage.Server) storage.OperationPhase {
+	root := storage.OperationPhase{
+		ID:          id,
+		Description: description,
+	}
+	for i, server := range servers {
+		node := storage.OperationPhase{
+			ID:          fmt.Sprintf("%v/%v", id, server.Hostname),
+			Description: fmt.Sprintf("Rotate certificates on node %q", server.Hostname),
+		}
+		if i > 0 {
+			node.Requires = []string{root.Phases[i-1].ID}
+		}
+		node.Phases = append(node.Phases, r.phase(node.ID, rotateCertsPhase,
+			"Renew certificates on node %q", server))
+		if server.AdvertiseIP != r.leader.AdvertiseIP {
+			health := r.phase(node.ID, nodeHealthPhase, "Wait for node %q to become healthy", server)
+			health.Requires = []string{node.Phases[0].ID}
+			node.Phases = append(node.Phases, health)
+		}
+		root.Phases = append(root.Phases, node)
+	}
+	return root
+}
+
+// phase returns a new phase with the specified executor that targets
+// the given server and runs on the leader node
+func (r *rotateCertsPlanBuilder) phase(parentID, executor, format string, server storage.Server) storage.OperationPhase {
+	r.step++
+	return storage.OperationPhase{
+		ID:          fmt.Sprintf("%v/%v", parentID, executor),
+		Executor:    executor,
+		Description: fmt.Sprintf(format, server.Hostname),
+		Step:        r.step,
+		Data: &storage.OperationPhaseData{
+			Server:     &server,
+			ExecServer: &r.leader,
+		},
+	}
+}
+
+// rotateCertsPlanBuilder builds the certificate rotation operation plan
+type rotateCertsPlanBuilder struct {
+	// leader is the server driving the operation
+	leader storage.Server
+	// step is the number of the last added phase
+	step int
+}
+
+// rotateCertsEngine executes the certificate rotation operation plan
+type rotateCertsEngine struct {
+	log.FieldLogger
+	site      *site
+	operation ops.SiteOperation
+}
+
+// GetExecutor returns the executor for the specified phase
+func (r *rotateCertsEngine) GetExecutor(params fsm.ExecutorParams, remote fsm.Remote) (fsm.PhaseExecutor, error) {
+	if params.Phase.Data == nil || params.Phase.Data.Server == nil {
+		return nil, trace.NotFound("no server specified for phase %q", params.Phase.ID)
+	}
+	executor := rotateCertsExecutor{
+		FieldLogger: r.WithField("phase", params.Phase.ID),
+		site:        r.site,
+		server:      *params.Phase.Data.Server,
+	}
+	switch params.Phase.Executor {
+	case rotateCertsPhase:
+		return &rotateCerts{executor}, nil
+	case nodeHealthPhase:
+		return &nodeHealth{executor}, nil
+	}
+	return nil, trace.BadParameter("unknown executor %q for phase %q",
+		params.Phase.Executor, params.Phase.ID)
+}
+
+// ChangePhaseState creates a new changelog entry
+func (r *rotateCertsEngine) ChangePhaseState(ctx context.Context, change fsm.StateChange) error {
+	err := r.site.service.CreateOperationPlanChange(r.operation.Key(),
+		storage.PlanChange{
+			ID:          uuid.New(),
+			ClusterName: r.operation.SiteDomain,
+			OperationID: r.operation.ID,
+			PhaseID:     change.Phase,
+			NewState:    change.State,
+			Error:       utils.ToRawTrace(change.Error),
+			Transient:   change.IsTransient(),
+			Logs:        change.Logs,
+			Created:     time.Now().UTC(),
+		})
+	return trace.Wrap(err)
+}
+
+// GetPlan returns the up-to-date operation plan
+func (r *rotateCertsEngine) GetPlan() (*storage.OperationPlan, error) {
+	return r.site.service.GetOperationPlan(r.operation.Key())
+}
+
+// RunCommand is not supported as all phases are executed
+// by the cluster controller
+func (r *rotateCertsEngine) RunCommand(context.Context, rpc.RemoteRunner, storage.Server, fsm.Params) error {
+	return trace.NotImplemented("certificate rotation phases are executed by the cluster controller")
+}
+
+// Complete marks the operation as either completed or failed based
+// on the state of the operation plan
+func (r *rotateCertsEngine) Complete(fsmErr error) error {
+	plan, err := r.GetPlan()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if fsm.IsCompleted(plan) {
+		return trace.Wrap(ops.CompleteOperation(r.operation.Key(), r.site.service))
+	}
+	var message string
+	if fsmErr != nil {
+		message = trace.UserMessage(fsmErr)
+	}
+	return trace.Wrap(ops.FailOperation(r.operation.Key(), r.site.service, message))
+}
+
+// updateProgress creates a progress entry for the phase about to be executed
+func (r *rotateCertsEngine) updateProgress(ctx context.Context, params fsm.Params) error {
+	plan, err := r.GetPlan()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	phase, err := fsm.FindPhase(plan, params.PhaseID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if phase.Step == 0 {
+		// only the phases that target nodes report progress
+		return nil
+	}
+	var steps int
+	for _, phase := range fsm.FlattenPlan(plan) {
+		steps = utils.Max(steps, phase.Step)
+	}
+	key := r.operation.Key()
+	err = r.site.service.CreateProgressEntry(key, ops.ProgressEntry{
+		SiteDomain:  key.SiteDomain,
+		OperationID: key.OperationID,
+		Completion:  100 / utils.Max(steps, 1) * phase.Step,
+		Step:        phase.Step,
+		State:       ops.ProgressStateInProgress,
+		Message:     phase.Description,
+		Created:     time.Now().UTC(),
+	})
+	if err != nil {
+		r.WithError(err).Warn("Failed to create progress entry.")
+	}
+	return nil
+}
+
+// Execute renews the certificates on the node and schedules
+// the restart of its services
+func (r *rotateCerts) Execute(ctx context.Context) error {
+	r.Infof("Rotate certificates on %v.", r.server)
+	server, err := r.site.getTeleportServer(ops.AdvertiseIP, r.server.AdvertiseIP)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	runner := &serverRunner{
+		server: server,
+		runner: &teleportRunner{
+			FieldLogger:          r.FieldLogger,
+			domainName:           r.site.domainName,
+			TeleportProxyService: r.site.teleport(),
+		},
+	}
+	out, err := runner.Run(r.site.gravityCommand("system", "rotate-certs", r.site.domainName, "--restart")...)
+	if err != nil {
+		return trace.Wrap(err, "failed to rotate certificates on node %v: %s", r.server.Hostname, out)
+	}
+	return nil
+}
+
+// Execute waits for the node to restart its services and become healthy
+func (r *nodeHealth) Execute(ctx context.Context) error {
+	r.Infof("Wait for %v to become healthy.", r.server)
+	// give the services on the node the time to restart
+	// before checking its health
+	select {
+	case <-time.After(2 * defaults.ServiceRestartDelay):
+	case <-ctx.Done():
+		return trace.Wrap(ctx.Err())
+	}
+	b := utils.NewExponentialBackOff(defaults.NodeStatusTimeout)
+	err := utils.RetryWithInterval(ctx, b, func() error {
+		return trace.Wrap(r.checkNodeStatus(ctx))
+	})
+	return trace.Wrap(err)
+}
+
+func (r *nodeHealth) checkNodeStatus(ctx context.Context) error {
+	agent, err := status.FromPlanetAgent(ctx, []storage.Server{r.server})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, node := range agent.Nodes {
+		if node.AdvertiseIP != r.server.AdvertiseIP {
+			continue
+		}
+		if node.Status != status.NodeHealthy {
+			return trace.CompareFailed("node %v is %v", r.server.Hostname, node.Status)
+		}
+		return nil
+	}
+	return trace.NotFound("node %v is not reported by the cluster status", r.server.Hostname)
+}
+
+type rotateCerts struct {
+	rotateCertsExecutor
+}
+
+type nodeHealth struct {
+	rotateCertsExecutor
+}
+
+// rotateCertsExecutor implements the common parts of the
+// certificate rotation phase executors
+type rotateCertsExecutor struct {
+	log.FieldLogger
+	site   *site
+	server storage.Server
+}
+
+// Rollback is a no-op for the certificate rotation phases
+func (*rotateCertsExecutor) Rollback(context.Context) error {
+	return nil
+}
+
+// PreCheck is a no-op
+func (*rotateCertsExecutor) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is a no-op
+func (*rotateCertsExecutor) PostCheck(context.Context) error {
+	return nil
+}
+
+// formatCertificates returns the names and expiration times
+// of the specified certificates
+func formatCertificates(certs []ops.NodeCertificate) string {
+	var formatted []string
+	for _, cert := range certs {
+		formatted = append(formatted, fmt.Sprintf("%v (%v)",
+			cert.Name, cert.NotAfter.Format(constants.ShortDateFormat)))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+const (
+	// rotateCertsPhase renews the certificates on a node
+	rotateCertsPhase = "rotate"
+	// nodeHealthPhase waits for a node to become healthy
+	nodeHealthPhase = "health"
+)
@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/check.v1"
+)
+
+type CertRotationSuite struct{}
+
+var _ = check.Suite(&CertRotationSuite{})
+
+func (s *CertRotationSuite) TestSelectsServersToRotate(c *check.C) {
+	now := time.Now()
+	servers := []storage.Server{
+		newRotationServer("node-1", "10.0.0.1", schema.ServiceRoleMaster),
+		newRotationServer("node-2", "10.0.0.2", schema.ServiceRoleNode),
+		newRotationServer("node-3", "10.0.0.3", schema.ServiceRoleNode),
+	}
+	nodes := []ops.NodeCertificates{
+		{
+			AdvertiseIP: "10.0.0.1",
+			Certificates: []ops.NodeCertificate{
+				{Name: "root", IsCA: true, NotAfter: now.Add(24 * time.Hour)},
+				{Name: "etcd", NotAfter: now.Add(365 * 24 * time.Hour)},
+			},
+		},
+		{
+			AdvertiseIP: "10.0.0.2",
+			Certificates: []ops.NodeCertificate{
+				{Name: "kubelet", NotAfter: now.Add(24 * time.Hour)},
+			},
+		},
+		{
+			AdvertiseIP: "10.0.0.3",
+			Error:       "node is offline",
+		},
+	}
+	result := serversToRotate(servers, nodes, now.Add(30*24*time.Hour), log.StandardLogger())
+	c.Assert(result, check.DeepEquals, []storage.Server{servers[1]})
+}
+
+func (s *CertRotationSuite) TestRotatesLeaderLast(c *check.C) {
+	leader := newRotationServer("node-1", "10.0.0.1", schema.ServiceRoleMaster)
+	servers := []storage.Server{
+		leader,
+		newRotationServer("node-2", "10.0.0.2", schema.ServiceRoleMaster),
+		newRotationServer("node-3", "10.0.0.3", schema.ServiceRoleNode),
+	}
+	operation := ops.SiteOperation{
+		ID:         "1",
+		SiteDomain: "example.com",
+		Type:       ops.OperationRotateCertificates,
+	}
+	plan, err := newRotateCertificatesPlan(operation, leader, servers)
+	c.Assert(err, check.IsNil)
+	c.Assert(formatPhases(plan.Phases), check.DeepEquals, []string{
+		"/masters",
+		"/masters/node-2",
+		"/masters/node-2/rotate",
+		"/masters/node-2/health",
+		"/masters/node-1",
+		"/masters/node-1/rotate",
+		"/nodes",
+		"/nodes/node-3",
+		"/nodes/node-3/rotate",
+		"/nodes/node-3/health",
+	})
+	c.Assert(plan.Phases[1].Requires, check.DeepEquals, []string{"/masters"})
+	masters := plan.Phases[0].Phases
+	c.Assert(masters[1].Requires, check.DeepEquals, []string{"/masters/node-2"})
+	rotate := masters[0].Phases[0]
+	c.Assert(rotate.Data.Server.Hostname, check.Equals, "node-2")
+	c.Assert(rotate.Data.ExecServer.Hostname, check.Equals, "node-1")
+}
+
+func formatPhases(phases []storage.OperationPhase) (ids []string) {
+	for _, phase := range phases {
+		ids = append(ids, phase.ID)
+		ids = append(ids, formatPhases(phase.Phases)...)
+	}
+	return ids
+}
+
+func newRotationServer(hostname, addr string, role schema.ServiceRole) storage.Server {
+	return storage.Server{
+		Hostname:    hostname,
+		AdvertiseIP: addr,
+		ClusterRole: string(role),
+	}
+}
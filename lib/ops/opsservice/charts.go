@@ -0,0 +1,52 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/trace"
+)
+
+// GetChartIndex returns the index file of the cluster Helm chart repository
+func (o *Operator) GetChartIndex(key ops.SiteKey) (io.ReadCloser, error) {
+	if _, err := o.backend().GetSite(key.SiteDomain); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	reader, err := o.cfg.Apps.FetchIndexFile()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return ioutil.NopCloser(reader), nil
+}
+
+// FetchChart returns the specified application from the cluster
+// Helm chart repository as a chart tarball
+func (o *Operator) FetchChart(key ops.SiteKey, locator loc.Locator) (io.ReadCloser, error) {
+	if _, err := o.backend().GetSite(key.SiteDomain); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	reader, err := o.cfg.Apps.FetchChart(locator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return reader, nil
+}
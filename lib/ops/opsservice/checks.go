@@ -19,7 +19,9 @@ package opsservice
 import (
 	"context"
 
+	"github.com/gravitational/gravity/lib/checks"
 	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
 
 	"github.com/gravitational/trace"
 	log "github.com/sirupsen/logrus"
@@ -39,16 +41,47 @@ func (o *Operator) ValidateServers(ctx context.Context, req ops.ValidateServersR
 		return trace.Wrap(err)
 	}
 
-	infos, err := cluster.agentService().GetServerInfos(ctx, op.Key())
+	err = cluster.validateServers(ctx, op, req.Servers)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 
-	err = ops.CheckServers(ctx, op.Key(), infos, req.Servers,
-		cluster.agentService(), cluster.app.Manifest)
+	if op.Type != ops.OperationInstall {
+		return nil
+	}
+
+	_, err = cluster.updateSiteOperation(op)
+	return trace.Wrap(err)
+}
+
+// validateServers runs preflight checks on the specified servers of the operation.
+// For install operations, it also records the overlay network MTU
+// derived from the path MTU detected between the servers
+func (s *site) validateServers(ctx context.Context, op *ops.SiteOperation, servers []storage.Server) error {
+	infos, err := s.agentService().GetServerInfos(ctx, op.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = ops.CheckServers(ctx, op.Key(), infos, servers,
+		s.agentService(), s.app.Manifest.WithCNI(s.backendSite.CNI))
 	if err != nil {
 		return trace.Wrap(ops.FormatValidationError(err))
 	}
 
+	if op.Type != ops.OperationInstall || len(servers) < 2 || checks.IfTestsDisabled() {
+		return nil
+	}
+
+	mtu, err := ops.DetectPathMTU(ctx, op.Key(), infos, servers, s.agentService())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := checks.ValidatePathMTU(mtu, 0); err != nil {
+		return trace.Wrap(ops.FormatValidationError(err))
+	}
+	op.InstallExpand.OverlayMTU = checks.OverlayMTU(mtu)
+	log.WithField("path-mtu", mtu).Infof("Set overlay network MTU to %v.", op.InstallExpand.OverlayMTU)
+
 	return nil
 }
@@ -24,8 +24,10 @@ import (
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/resources"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/clusterconfig"
+	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/gravitational/rigging"
 	"github.com/gravitational/trace"
@@ -41,6 +43,17 @@ func (o *Operator) CreateUpdateConfigOperation(ctx context.Context, req ops.Crea
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	update, err := clusterconfig.Unmarshal(req.Config)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := checkClusterConfiguration(update); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = o.admit(ctx, req.ClusterKey, storage.KindClusterConfiguration, update.GetName(), update)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
 	cluster, err := o.openSite(req.ClusterKey)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -49,7 +62,13 @@ func (o *Operator) CreateUpdateConfigOperation(ctx context.Context, req ops.Crea
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	key, err := cluster.createUpdateConfigOperation(ctx, req, []byte(config))
+	// The operation is created under the resource lock so the revision
+	// the update is based on cannot change before the operation starts
+	tracker := resources.RevisionTracker{Backend: o.backend()}
+	key, err := tracker.StartOperation(req.ClusterKey, storage.KindClusterConfiguration,
+		constants.ClusterConfigurationMap, req.Revision, func() (*ops.SiteOperationKey, error) {
+			return cluster.createUpdateConfigOperation(ctx, req, []byte(config))
+		})
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -139,6 +158,50 @@ func NewConfigurationConfigMap(config []byte) *v1.ConfigMap {
 	}
 }
 
+// getClusterDNS returns the cluster DNS configuration resource
+// or the default configuration if none has been specified
+func (o *Operator) getClusterDNS() (storage.ClusterDNS, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	configmap, err := client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+		Get(constants.ClusterDNSMap, metav1.GetOptions{})
+	err = rigging.ConvertError(err)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return storage.DefaultClusterDNS(), nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	spec, ok := configmap.Data["spec"]
+	if !ok {
+		return storage.DefaultClusterDNS(), nil
+	}
+	dns, err := storage.UnmarshalClusterDNS([]byte(spec))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return dns, nil
+}
+
+// NewClusterDNSConfigMap creates the backing ConfigMap to host cluster DNS configuration
+func NewClusterDNSConfigMap(config []byte) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       constants.KindConfigMap,
+			APIVersion: metav1.SchemeGroupVersion.Version,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.ClusterDNSMap,
+			Namespace: defaults.KubeSystemNamespace,
+		},
+		Data: map[string]string{
+			"spec": string(config),
+		},
+	}
+}
+
 // createUpdateConfigOperation creates a new operation to update cluster configuration
 func (s *site) createUpdateConfigOperation(ctx context.Context, req ops.CreateUpdateConfigOperationRequest, prevConfig []byte) (*ops.SiteOperationKey, error) {
 	op := ops.SiteOperation{
@@ -162,6 +225,46 @@ func (s *site) createUpdateConfigOperation(ctx context.Context, req ops.CreateUp
 	return key, nil
 }
 
+// checkClusterConfiguration validates the specified cluster configuration
+func checkClusterConfiguration(config clusterconfig.Interface) error {
+	if globalConfig := config.GetGlobalConfig(); globalConfig != nil {
+		err := utils.ValidateKubernetesSubnets(globalConfig.PodCIDR, globalConfig.ServiceCIDR)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = validateOverlayMTU(globalConfig.OverlayMTU)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if kubeletConfig := config.GetKubeletConfig(); kubeletConfig != nil {
+		if err := kubeletConfig.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if apiServerConfig := config.GetAPIServerConfig(); apiServerConfig != nil {
+		if err := apiServerConfig.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// validateOverlayMTU verifies that the specified overlay network MTU
+// is within the range of supported path MTUs, if set
+func validateOverlayMTU(mtu int) error {
+	if mtu == 0 {
+		return nil
+	}
+	min := defaults.MinPathMTU - defaults.VxlanOverhead
+	max := defaults.MaxPathMTU - defaults.VxlanOverhead
+	if mtu < min || mtu > max {
+		return trace.BadParameter("overlay network MTU should be between %v and %v, got %v",
+			min, max, mtu)
+	}
+	return nil
+}
+
 func getOrCreateClusterConfigMap(client corev1.ConfigMapInterface) (configmap *v1.ConfigMap, err error) {
 	configmap, err = client.Get(constants.ClusterConfigurationMap, metav1.GetOptions{})
 	if err != nil {
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/teleport"
+	"github.com/gravitational/trace"
+)
+
+// GetClusterSummaries returns summaries of all clusters managed by this operator
+func (o *Operator) GetClusterSummaries(accountID string) ([]ops.ClusterSummary, error) {
+	clusters, err := o.GetSites(accountID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	summaries := make([]ops.ClusterSummary, 0, len(clusters))
+	for _, cluster := range clusters {
+		summaries = append(summaries, o.newClusterSummary(cluster))
+	}
+	return summaries, nil
+}
+
+// GetClusterSummary returns the summary of the specified cluster
+// including its nodes and operations
+func (o *Operator) GetClusterSummary(key ops.SiteKey) (*ops.ClusterSummary, error) {
+	cluster, err := o.GetSite(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	summary := o.newClusterSummary(*cluster)
+	if summary.Online {
+		summary.Nodes, err = o.GetClusterNodes(key)
+		if err != nil {
+			o.WithError(err).Warnf("Failed to query nodes of cluster %v.", key.SiteDomain)
+		}
+	}
+	summary.ActiveOperations, err = ops.GetActiveOperations(key, o)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	summary.LastOperation, _, err = ops.GetLastCompletedOperation(key, o)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	return &summary, nil
+}
+
+// newClusterSummary returns the summary of the specified cluster
+// with the status of its connection to this operator
+func (o *Operator) newClusterSummary(cluster ops.Site) ops.ClusterSummary {
+	summary := ops.NewClusterSummary(cluster)
+	if cluster.Local || o.cfg.Tunnel == nil {
+		return summary
+	}
+	remote, err := o.cfg.Tunnel.GetSite(cluster.Domain)
+	if err != nil {
+		return summary
+	}
+	summary.Online = remote.GetStatus() == teleport.RemoteClusterStatusOnline
+	summary.LastConnected = remote.GetLastConnected()
+	return summary
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"fmt"
+
+	appservice "github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+)
+
+// EnableComponent starts the operation that installs the optional application
+// component into the cluster.
+//
+// The operation exports the component applications' images into the registries
+// of all master nodes and executes their install hooks. The component is then
+// removed from the list of cluster's disabled components so that subsequent
+// upgrades update it along with the rest of the cluster.
+func (o *Operator) EnableComponent(ctx context.Context, req ops.ComponentRequest) (*ops.SiteOperationKey, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.openSite(req.SiteKey())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	record, err := o.cfg.Backend.GetSite(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !utils.StringInSlice(record.DisabledComponents, req.Component) {
+		return nil, trace.AlreadyExists("component %v is already enabled", req.Component)
+	}
+	apps, err := cluster.getComponentApps(req.Component)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	key, err := cluster.createComponentOperation(ctx, req.Component,
+		ops.OperationEnableComponent, ops.OperationEnableComponentInProgress)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = cluster.executeOperation(*key, func(ctx *operationContext) error {
+		return trace.Wrap(cluster.enableComponent(ctx, req.Component, apps))
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+// DisableComponent starts the operation that uninstalls the optional application
+// component from the cluster.
+//
+// The operation executes the component applications' uninstall hooks in the
+// reverse order and records the component as disabled so that subsequent
+// upgrades skip it. The component application packages are then removed from
+// the cluster package service, and the next garbage collection removes their
+// images from the cluster registries.
+func (o *Operator) DisableComponent(ctx context.Context, req ops.ComponentRequest) (*ops.SiteOperationKey, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.openSite(req.SiteKey())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	record, err := o.cfg.Backend.GetSite(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if utils.StringInSlice(record.DisabledComponents, req.Component) {
+		return nil, trace.AlreadyExists("component %v is already disabled", req.Component)
+	}
+	apps, err := cluster.getComponentApps(req.Component)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	key, err := cluster.createComponentOperation(ctx, req.Component,
+		ops.OperationDisableComponent, ops.OperationDisableComponentInProgress)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = cluster.executeOperation(*key, func(ctx *operationContext) error {
+		return trace.Wrap(cluster.disableComponent(ctx, req.Component, apps))
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+// createComponentOperation creates a new operation of the specified type
+// that enables or disables the application component
+func (s *site) createComponentOperation(ctx context.Context, component, operationType, state string) (*ops.SiteOperationKey, error) {
+	op := ops.SiteOperation{
+		ID:         uuid.New(),
+		AccountID:  s.key.AccountID,
+		SiteDomain: s.key.SiteDomain,
+		Type:       operationType,
+		Created:    s.clock().UtcNow(),
+		CreatedBy:  storage.UserFromContext(ctx),
+		Updated:    s.clock().UtcNow(),
+		State:      state,
+		Component: &storage.ComponentOperationState{
+			Name: component,
+		},
+	}
+	key, err := s.getOperationGroup().createSiteOperation(op)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+// enableComponent exports the images of the specified component applications
+// into the registries of all master nodes, runs their install hooks and
+// removes the component from the list of disabled components
+func (s *site) enableComponent(ctx *operationContext, component string, apps []appservice.Application) error {
+	cluster, err := s.backend().GetSite(s.key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	masters := cluster.ClusterState.Servers.Masters()
+	for i, app := range apps {
+		for _, master := range masters {
+			s.reportProgress(ctx, ops.ProgressEntry{
+				State:      ops.ProgressStateInProgress,
+				Completion: i * constants.Completed / len(apps),
+				Message:    fmt.Sprintf("Exporting images of %v to the registry on %v.", app.Package, master.Hostname),
+			})
+			err := s.apps().ExportApp(appservice.ExportAppRequest{
+				Package:         app.Package,
+				RegistryAddress: defaults.DockerRegistryAddr(master.AdvertiseIP),
+				CertName:        constants.DockerRegistry,
+			})
+			if err != nil {
+				return trace.Wrap(err, "failed to export images to the registry on %v", master.Hostname)
+			}
+		}
+		s.reportProgress(ctx, ops.ProgressEntry{
+			State:      ops.ProgressStateInProgress,
+			Completion: i * constants.Completed / len(apps),
+			Message:    fmt.Sprintf("Installing %v.", app.Package),
+		})
+		err = s.runComponentHooks(ctx, *cluster, app, schema.HookInstall, schema.HookInstalled)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	var disabled []string
+	for _, name := range cluster.DisabledComponents {
+		if name != component {
+			disabled = append(disabled, name)
+		}
+	}
+	cluster.DisabledComponents = disabled
+	if _, err := s.backend().UpdateSite(*cluster); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(s.completeComponentOperation(ctx, ops.OperationEnableComponentInProgress,
+		fmt.Sprintf("Component %v has been enabled.", component)))
+}
+
+// disableComponent runs the uninstall hooks of the specified component
+// applications, records the component as disabled and removes the
+// application packages from the cluster package service
+func (s *site) disableComponent(ctx *operationContext, component string, apps []appservice.Application) error {
+	cluster, err := s.backend().GetSite(s.key.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for i := len(apps) - 1; i >= 0; i-- {
+		s.reportProgress(ctx, ops.ProgressEntry{
+			State:      ops.ProgressStateInProgress,
+			Completion: (len(apps) - 1 - i) * constants.Completed / len(apps),
+			Message:    fmt.Sprintf("Uninstalling %v.", apps[i].Package),
+		})
+		err := s.runComponentHooks(ctx, *cluster, apps[i], schema.HookUninstall)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	cluster.DisabledComponents = append(cluster.DisabledComponents, component)
+	if _, err := s.backend().UpdateSite(*cluster); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, app := range apps {
+		ctx.Infof("Removing package %v.", app.Package)
+		// The cluster application still lists the component applications
+		// as dependencies, so the removal has to be forced
+		err := s.apps().DeleteApp(appservice.DeleteRequest{Package: app.Package, Force: true})
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err, "failed to remove package %v", app.Package)
+		}
+	}
+	return trace.Wrap(s.completeComponentOperation(ctx, ops.OperationDisableComponentInProgress,
+		fmt.Sprintf("Component %v has been disabled.", component)))
+}
+
+// completeComponentOperation marks the component operation completed
+func (s *site) completeComponentOperation(ctx *operationContext, state, message string) error {
+	_, err := s.compareAndSwapOperationState(swap{
+		key:            ctx.key(),
+		expectedStates: []string{state},
+		newOpState:     ops.OperationStateCompleted,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	s.reportProgress(ctx, ops.ProgressEntry{
+		State:      ops.ProgressStateCompleted,
+		Completion: constants.Completed,
+		Message:    message,
+	})
+	return nil
+}
+
+// getComponentApps returns applications that comprise the specified component
+// of the cluster application.
+//
+// Returns NotFound if any of the applications is not available in the cluster
+func (s *site) getComponentApps(name string) ([]appservice.Application, error) {
+	clusterApp := s.app
+	components := clusterApp.Manifest.Components
+	component, err := components.ByName(name)
+	if err != nil {
+		return nil, trace.NotFound("cluster image %v does not have component %q, available components: %v",
+			clusterApp.Package, name, components.Names())
+	}
+	var apps []appservice.Application
+	for _, name := range component.Apps {
+		locator, err := clusterApp.Manifest.Dependencies.ByName(name)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		app, err := s.apps().GetApp(*locator)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				return nil, trace.NotFound("package %v is not available in the cluster, "+
+					"upload the cluster image with \"gravity upload\" first", locator)
+			}
+			return nil, trace.Wrap(err)
+		}
+		apps = append(apps, *app)
+	}
+	return apps, nil
+}
+
+// runComponentHooks runs the specified hooks of the component application
+// skipping those the application does not define
+func (s *site) runComponentHooks(ctx *operationContext, cluster storage.Site, app appservice.Application, hooks ...schema.HookType) error {
+	for _, hook := range hooks {
+		if !app.Manifest.HasHook(hook) {
+			continue
+		}
+		ctx.Infof("Executing %v hook for %v.", hook, app.Package)
+		_, out, err := appservice.RunAppHook(context.TODO(), s.apps(),
+			appservice.HookRunRequest{
+				Application: app.Package,
+				Hook:        hook,
+				ServiceUser: cluster.ServiceUser,
+			})
+		if err != nil {
+			return trace.Wrap(err, "%v %v hook failed: %s", app.Package, hook, out)
+		}
+	}
+	return nil
+}
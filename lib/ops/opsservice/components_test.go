@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/gravity/lib/app/service/test"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type ComponentsSuite struct {
+	services TestServices
+	operator *Operator
+	cluster  *ops.Site
+}
+
+var _ = check.Suite(&ComponentsSuite{})
+
+func (s *ComponentsSuite) SetUpTest(c *check.C) {
+	s.services = SetupTestServices(c)
+	s.operator = s.services.Operator
+
+	test.CreateRuntimeApplication(s.services.Apps, c)
+	for _, locator := range []string{
+		"gravitational.io/gravity:0.0.1",
+		"gravitational.io/web-assets:0.0.1",
+		"gravitational.io/teleport:0.0.1",
+		"gravitational.io/planet:0.0.1",
+	} {
+		test.CreateDummyPackage(loc.MustParseLocator(locator), s.services.Packages, c)
+	}
+	test.CreateDummyApplication(loggingApp, c, s.services.Apps)
+	app := test.CreateDummyApplicationWithDependencies(s.services.Apps,
+		loc.MustParseLocator("example.com/app:0.0.1"), `dependencies:
+  packages:
+  - gravitational.io/gravity:0.0.1
+  - gravitational.io/web-assets:0.0.1
+  - gravitational.io/teleport:0.0.1
+  apps:
+  - example.com/logging:0.0.1
+components:
+- name: logging
+  apps: [logging]`, c)
+
+	account, err := s.operator.CreateAccount(ops.NewAccountRequest{
+		Org: "components.test",
+	})
+	c.Assert(err, check.IsNil)
+
+	s.cluster, err = s.operator.CreateSite(ops.NewSiteRequest{
+		AccountID:  account.ID,
+		AppPackage: app.Package.String(),
+		Provider:   schema.ProvisionerOnPrem,
+		DomainName: "components.test",
+	})
+	c.Assert(err, check.IsNil)
+
+	cluster, err := s.services.Backend.GetSite(s.cluster.Domain)
+	c.Assert(err, check.IsNil)
+	cluster.State = ops.SiteStateActive
+	_, err = s.services.Backend.UpdateSite(*cluster)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ComponentsSuite) TestDisablesAndEnablesComponent(c *check.C) {
+	key, err := s.operator.DisableComponent(context.TODO(), s.request("logging"))
+	c.Assert(err, check.IsNil)
+	s.waitForOperation(c, *key)
+	s.assertDisabled(c, "logging")
+
+	// component packages are removed from the cluster
+	_, err = s.services.Apps.GetApp(loggingApp)
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+
+	_, err = s.operator.DisableComponent(context.TODO(), s.request("logging"))
+	c.Assert(trace.IsAlreadyExists(err), check.Equals, true, check.Commentf("%v", err))
+
+	// enabling the component requires its packages to be uploaded again
+	_, err = s.operator.EnableComponent(context.TODO(), s.request("logging"))
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+	s.assertDisabled(c, "logging")
+
+	test.CreateDummyApplication(loggingApp, c, s.services.Apps)
+	key, err = s.operator.EnableComponent(context.TODO(), s.request("logging"))
+	c.Assert(err, check.IsNil)
+	s.waitForOperation(c, *key)
+	s.assertDisabled(c)
+}
+
+func (s *ComponentsSuite) TestEnableRejectsEnabledComponent(c *check.C) {
+	_, err := s.operator.EnableComponent(context.TODO(), s.request("logging"))
+	c.Assert(trace.IsAlreadyExists(err), check.Equals, true, check.Commentf("%v", err))
+	s.assertDisabled(c)
+}
+
+func (s *ComponentsSuite) TestRejectsUnknownComponent(c *check.C) {
+	_, err := s.operator.DisableComponent(context.TODO(), s.request("monitoring"))
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+	s.assertDisabled(c)
+}
+
+func (s *ComponentsSuite) request(component string) ops.ComponentRequest {
+	return ops.ComponentRequest{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Component:  component,
+	}
+}
+
+func (s *ComponentsSuite) waitForOperation(c *check.C, key ops.SiteOperationKey) {
+	for i := 0; i < 100; i++ {
+		operation, err := s.operator.GetSiteOperation(key)
+		c.Assert(err, check.IsNil)
+		if operation.IsFailed() {
+			progress, err := s.operator.GetSiteOperationProgress(key)
+			c.Assert(err, check.IsNil)
+			c.Fatalf("Operation %v failed: %v.", key.OperationID, progress.Message)
+		}
+		if operation.IsCompleted() {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Fatalf("Timed out waiting for operation %v.", key.OperationID)
+}
+
+func (s *ComponentsSuite) assertDisabled(c *check.C, components ...string) {
+	cluster, err := s.operator.GetSite(s.cluster.Key())
+	c.Assert(err, check.IsNil)
+	c.Assert(cluster.DisabledComponents, check.DeepEquals, components)
+}
+
+var loggingApp = loc.MustParseLocator("example.com/logging:0.0.1")
@@ -218,6 +218,10 @@ func (s *site) configureExpandPackages(ctx context.Context, opCtx *operationCont
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	dns, err := s.service.getClusterDNS()
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	planetConfig := planetConfig{
 		server:        *provisionedServer,
 		installExpand: opCtx.operation,
@@ -228,6 +232,7 @@ func (s *site) configureExpandPackages(ctx context.Context, opCtx *operationCont
 		manifest:      s.app.Manifest,
 		env:           env.GetKeyValues(),
 		config:        config,
+		dns:           dns,
 	}
 	if provisionedServer.IsMaster() {
 		err := s.configureTeleportMaster(opCtx, provisionedServer)
@@ -331,6 +336,14 @@ func (s *site) configurePackages(ctx *operationContext, req ops.ConfigurePackage
 		}
 	}
 
+	dns := storage.DefaultClusterDNS()
+	if len(req.DNS) != 0 {
+		dns, err = storage.UnmarshalClusterDNS(req.DNS)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
 	for i, master := range masters {
 		secretsPackage, err := s.planetSecretsPackage(master)
 		if err != nil {
@@ -383,6 +396,7 @@ func (s *site) configurePackages(ctx *operationContext, req ops.ConfigurePackage
 			manifest:      s.app.Manifest,
 			env:           req.Env,
 			config:        clusterConfig,
+			dns:           dns,
 		}
 		err = s.configurePlanetMaster(config, *secretsPackage, *configPackage)
 		if err != nil {
@@ -442,6 +456,7 @@ func (s *site) configurePackages(ctx *operationContext, req ops.ConfigurePackage
 			manifest:      s.app.Manifest,
 			env:           req.Env,
 			config:        clusterConfig,
+			dns:           dns,
 		}
 
 		err = s.configurePlanetNode(config, *secretsPackage, *configPackage)
@@ -526,7 +541,21 @@ func (s *site) configurePlanetCertAuthority(ctx *operationContext) error {
 		return trace.Wrap(err)
 	}
 
-	s.Debugf("generating apiserver keypair key")
+	return trace.Wrap(CreateCertAuthorityPackage(s.packages(), *caPackage,
+		utils.CertAuthority{TLSKeyPair: *planetCertAuthority}, ctx.operation.ID))
+}
+
+// CreateCertAuthorityPackage creates the certificate authority package of the cluster
+// with the specified certificate authority that issues the cluster certificates.
+//
+// The installer uses it to create the package with the certificate authority
+// provided by the operator before the package is generated during the install
+func CreateCertAuthorityPackage(packages pack.PackageService, caPackage loc.Locator, ca utils.CertAuthority, operationID string) error {
+	err := packages.UpsertRepository(caPackage.Repository, time.Time{})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
 	// we have to share the same private key for various apiservers
 	// due to this issue:
 	// https://github.com/kubernetes/kubernetes/issues/11000#issuecomment-232469678
@@ -538,32 +567,35 @@ func (s *site) configurePlanetCertAuthority(ctx *operationContext) error {
 				O: defaults.SystemAccountOrg,
 			},
 		},
-	}, planetCertAuthority, nil, defaults.CertificateExpiry)
+	}, &ca.TLSKeyPair, nil, defaults.CertificateExpiry)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 
 	// also add OpsCenter's cert authority (without private key)
-	opsCertAuthority, err := pack.ReadCertificateAuthority(s.packages())
+	opsCertAuthority, err := pack.ReadCertificateAuthority(packages)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 	opsCertAuthority.KeyPEM = nil
 
-	reader, err := utils.CreateTLSArchive(utils.TLSArchive{
+	archive := utils.TLSArchive{
 		constants.APIServerKeyPair: apiServer,
-		constants.RootKeyPair:      planetCertAuthority,
 		constants.OpsCenterKeyPair: opsCertAuthority,
-	})
+	}
+	if err := archive.AddCertAuthority(ca); err != nil {
+		return trace.Wrap(err)
+	}
+	reader, err := utils.CreateTLSArchive(archive)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 	defer reader.Close()
 
-	_, err = s.packages().CreatePackage(*caPackage, reader, pack.WithLabels(
+	_, err = packages.CreatePackage(caPackage, reader, pack.WithLabels(
 		map[string]string{
 			pack.PurposeLabel:     pack.PurposeCA,
-			pack.OperationIDLabel: ctx.operation.ID,
+			pack.OperationIDLabel: operationID,
 		}))
 	return trace.Wrap(err)
 }
@@ -600,10 +632,12 @@ func (s *site) getPlanetMasterSecretsPackage(ctx *operationContext, p planetMast
 		return nil, trace.Wrap(err)
 	}
 
-	caKeyPair, err := archive.GetKeyPair(constants.RootKeyPair)
+	ca, err := archive.CertAuthority()
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	caKeyPair := &ca.TLSKeyPair
+	chain := ca.IssuedCertChain()
 
 	baseKeyPair, err := archive.GetKeyPair(constants.APIServerKeyPair)
 	if err != nil {
@@ -692,6 +726,7 @@ func (s *site) getPlanetMasterSecretsPackage(ctx *operationContext, p planetMast
 		if err != nil {
 			return nil, trace.Wrap(err)
 		}
+		keyPair.CertPEM = append(keyPair.CertPEM, chain...)
 		if err := newArchive.AddKeyPair(name, *keyPair); err != nil {
 			return nil, trace.Wrap(err)
 		}
@@ -730,10 +765,12 @@ func (s *site) getPlanetNodeSecretsPackage(ctx *operationContext, node *Provisio
 		return nil, trace.Wrap(err)
 	}
 
-	caKeyPair, err := archive.GetKeyPair(constants.RootKeyPair)
+	ca, err := archive.CertAuthority()
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	caKeyPair := &ca.TLSKeyPair
+	chain := ca.IssuedCertChain()
 
 	newArchive := make(utils.TLSArchive)
 
@@ -785,6 +822,7 @@ func (s *site) getPlanetNodeSecretsPackage(ctx *operationContext, node *Provisio
 		if len(privateKeyPEM) == 0 {
 			privateKeyPEM = keyPair.KeyPEM
 		}
+		keyPair.CertPEM = append(keyPair.CertPEM, chain...)
 		if err := newArchive.AddKeyPair(keyName, *keyPair); err != nil {
 			return nil, trace.Wrap(err)
 		}
@@ -931,6 +969,9 @@ func (s *site) getPlanetConfig(config planetConfig) (args []string, err error) {
 		"service-subnet": config.installExpand.InstallExpand.Subnets.Service,
 		"pod-subnet":     config.installExpand.InstallExpand.Subnets.Overlay,
 	}
+	if mtu := config.installExpand.InstallExpand.OverlayMTU; mtu != 0 {
+		overrideArgs["overlay-mtu"] = strconv.Itoa(mtu)
+	}
 
 	for k, v := range config.env {
 		args = append(args, fmt.Sprintf("--env=%v=%v", k, strconv.Quote(v)))
@@ -983,6 +1024,19 @@ func (s *site) getPlanetConfig(config planetConfig) (args []string, err error) {
 	if len(manifest.KubeletArgs(*profile)) != 0 {
 		kubeletArgs = append(kubeletArgs, manifest.KubeletArgs(*profile)...)
 	}
+	if config.config != nil {
+		if kubeletConfig := config.config.GetKubeletConfig(); kubeletConfig != nil {
+			kubeletArgs = append(kubeletArgs, kubeletConfig.ExtraArgs...)
+		}
+		if node.IsMaster() {
+			args = append(args, addAPIServerConfig(config.config.GetAPIServerConfig())...)
+		}
+	}
+
+	if config.dns != nil && config.dns.GetProvider() == storage.DNSProviderNodeLocal {
+		// Pods resolve names via the node-local DNS cache
+		kubeletArgs = append(kubeletArgs, fmt.Sprintf("--cluster-dns=%v", defaults.NodeLocalDNSAddr))
+	}
 
 	if len(kubeletArgs) > 0 {
 		args = append(args, fmt.Sprintf("--kubelet-options=%v", strings.Join(kubeletArgs, " ")))
@@ -1016,12 +1070,16 @@ func (s *site) getPlanetConfig(config planetConfig) (args []string, err error) {
 		args = append(args, fmt.Sprintf("--taint=%v=%v:%v", taint.Key, taint.Value, taint.Effect))
 	}
 
+	for _, taint := range node.Taints {
+		args = append(args, fmt.Sprintf("--taint=%v=%v:%v", taint.Key, taint.Value, taint.Effect))
+	}
+
 	for k, v := range getNodeLabels(node, profile) {
 		args = append(args, fmt.Sprintf("--node-label=%v=%v", k, v))
 	}
 
-	// If the manifest contains an install hook to install a separate overlay network, disable flannel inside planet
-	if manifest.Hooks != nil && manifest.Hooks.NetworkInstall != nil {
+	// If the overlay network is installed by a network application hook, disable flannel inside planet
+	if manifest.HasNetworkApp(s.backendSite.CNI) {
 		args = append(args, "--disable-flannel=true")
 	}
 
@@ -1039,9 +1097,12 @@ func (s *site) getPlanetConfig(config planetConfig) (args []string, err error) {
 
 // getNodeLabels returns labels a Kubernetes node should register with
 func getNodeLabels(node ProvisionedServer, profile *schema.NodeProfile) map[string]string {
-	labels := profile.Labels
-	if labels == nil {
-		labels = make(map[string]string)
+	labels := make(map[string]string, len(profile.Labels)+len(node.Labels))
+	for key, value := range profile.Labels {
+		labels[key] = value
+	}
+	for key, value := range node.Labels {
+		labels[key] = value
 	}
 	if _, ok := labels[defaults.KubernetesRoleLabel]; ok {
 		role := schema.ServiceRoleNode
@@ -1077,6 +1138,8 @@ type planetConfig struct {
 	env map[string]string
 	// config specifies optional cluster configuration
 	config clusterconfig.Interface
+	// dns specifies the cluster DNS configuration
+	dns storage.ClusterDNS
 }
 
 // getPrincipals returns a list of SANs (x509's Subject Alternative Names)
@@ -1507,7 +1570,7 @@ func (s *site) addClusterConfig(config clusterconfig.Interface, overrideArgs map
 		return nil
 	}
 
-	if config := config.GetKubeletConfig(); config != nil {
+	if config := config.GetKubeletConfig(); config != nil && len(config.Config) != 0 {
 		args = append(args, fmt.Sprintf("--kubelet-config=%v",
 			base64.StdEncoding.EncodeToString(config.Config)))
 	}
@@ -1522,6 +1585,9 @@ func (s *site) addClusterConfig(config clusterconfig.Interface, overrideArgs map
 	if globalConfig.PodCIDR != "" {
 		overrideArgs["pod-subnet"] = globalConfig.PodCIDR
 	}
+	if globalConfig.OverlayMTU != 0 {
+		overrideArgs["overlay-mtu"] = strconv.Itoa(globalConfig.OverlayMTU)
+	}
 	if globalConfig.ServiceNodePortRange != "" {
 		args = append(args,
 			fmt.Sprintf("--service-node-portrange=%v", globalConfig.ServiceNodePortRange),
@@ -1543,6 +1609,22 @@ func (s *site) addClusterConfig(config clusterconfig.Interface, overrideArgs map
 	return args
 }
 
+// addAPIServerConfig returns the runtime arguments that configure
+// the API server with the specified configuration
+func addAPIServerConfig(config *clusterconfig.APIServer) (args []string) {
+	if config == nil {
+		return nil
+	}
+	if len(config.ExtraArgs) != 0 {
+		args = append(args, fmt.Sprintf("--apiserver-options=%v", strings.Join(config.ExtraArgs, " ")))
+	}
+	if config.AuditPolicy != "" {
+		args = append(args, fmt.Sprintf("--audit-policy=%v",
+			base64.StdEncoding.EncodeToString([]byte(config.AuditPolicy))))
+	}
+	return args
+}
+
 // configureDockerOptions creates a set of Docker-specific command line arguments to Planet on the specified node
 // based on the operation op and docker manifest configuration block.
 func configureDockerOptions(
@@ -307,6 +307,147 @@ func mapToArgs(args map[string][]string) sort.Interface {
 	return sort.StringSlice(result)
 }
 
+func (s *ConfigureSuite) TestConfiguresComponentArgs(c *check.C) {
+	server := storage.Server{
+		Hostname:    "node-1",
+		ClusterRole: "master",
+		Role:        "node",
+		AdvertiseIP: "172.12.13.0",
+	}
+	auditPolicy := `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata`
+	config := planetConfig{
+		master: masterConfig{addr: server.AdvertiseIP},
+		manifest: schema.Manifest{
+			NodeProfiles: schema.NodeProfiles{{Name: "node"}},
+		},
+		installExpand: ops.SiteOperation{
+			InstallExpand: &storage.InstallExpandOperationState{
+				Servers: []storage.Server{server},
+			},
+		},
+		server: ProvisionedServer{
+			Server:  server,
+			Profile: schema.NodeProfile{ServiceRole: schema.ServiceRoleMaster},
+		},
+		docker: storage.DockerConfig{StorageDriver: "overlay2"},
+		config: clusterconfig.New(clusterconfig.Spec{
+			ComponentConfigs: clusterconfig.ComponentConfigs{
+				Kubelet: &clusterconfig.Kubelet{
+					ExtraArgs: []string{"--max-pods=200", "--v=4"},
+				},
+				APIServer: &clusterconfig.APIServer{
+					ExtraArgs:   []string{"--audit-log-maxage=30"},
+					AuditPolicy: auditPolicy,
+				},
+			},
+		}),
+	}
+	args, err := s.cluster.getPlanetConfig(config)
+	c.Assert(err, check.IsNil)
+	kubeletArgs, args := stripItem(args, "--kubelet-")
+	c.Assert(kubeletArgs, check.Equals, "--kubelet-options=--max-pods=200 --v=4")
+	apiServerArgs, args := stripItem(args, "--apiserver-options")
+	c.Assert(apiServerArgs, check.Equals, "--apiserver-options=--audit-log-maxage=30")
+	policy, _ := stripItem(args, "--audit-policy")
+	c.Assert(policy, check.Equals, "--audit-policy="+base64.StdEncoding.EncodeToString([]byte(auditPolicy)))
+
+	config.server.Profile.ServiceRole = schema.ServiceRoleNode
+	args, err = s.cluster.getPlanetConfig(config)
+	c.Assert(err, check.IsNil)
+	apiServerArgs, args = stripItem(args, "--apiserver-options")
+	c.Assert(apiServerArgs, check.Equals, "", check.Commentf("expected no API server args on regular nodes"))
+	policy, _ = stripItem(args, "--audit-policy")
+	c.Assert(policy, check.Equals, "")
+}
+
+func (s *ConfigureSuite) TestPointsKubeletToNodeLocalDNS(c *check.C) {
+	server := storage.Server{
+		Hostname:    "node-1",
+		ClusterRole: "node",
+		Role:        "node",
+		AdvertiseIP: "172.12.13.0",
+	}
+	config := planetConfig{
+		master: masterConfig{addr: server.AdvertiseIP},
+		manifest: schema.Manifest{
+			NodeProfiles: schema.NodeProfiles{{Name: "node"}},
+		},
+		installExpand: ops.SiteOperation{
+			InstallExpand: &storage.InstallExpandOperationState{
+				Servers: []storage.Server{server},
+			},
+		},
+		server: ProvisionedServer{
+			Server:  server,
+			Profile: schema.NodeProfile{ServiceRole: schema.ServiceRoleNode},
+		},
+		docker: storage.DockerConfig{StorageDriver: "overlay2"},
+		dns:    storage.DefaultClusterDNS(),
+	}
+	args, err := s.cluster.getPlanetConfig(config)
+	c.Assert(err, check.IsNil)
+	kubeletArgs, _ := stripItem(args, "--kubelet-options")
+	c.Assert(kubeletArgs, check.Equals, "")
+
+	config.dns = storage.NewClusterDNS(storage.ClusterDNSSpecV2{
+		Provider: storage.DNSProviderNodeLocal,
+	})
+	args, err = s.cluster.getPlanetConfig(config)
+	c.Assert(err, check.IsNil)
+	kubeletArgs, _ = stripItem(args, "--kubelet-options")
+	c.Assert(kubeletArgs, check.Equals, "--kubelet-options=--cluster-dns=169.254.20.10")
+}
+
+func (s *ConfigureSuite) TestDisablesFlannelForNetworkPlugin(c *check.C) {
+	server := storage.Server{
+		Hostname:    "node-1",
+		ClusterRole: "master",
+		Role:        "node",
+		AdvertiseIP: "172.12.13.0",
+	}
+	config := planetConfig{
+		master: masterConfig{addr: server.AdvertiseIP},
+		manifest: schema.Manifest{
+			NodeProfiles: schema.NodeProfiles{{Name: "node"}},
+			SystemOptions: &schema.SystemOptions{
+				CNI: &schema.CNI{
+					Plugins: []schema.CNIPlugin{{Name: schema.CNICalico, App: "calico-app"}},
+				},
+			},
+		},
+		installExpand: ops.SiteOperation{
+			InstallExpand: &storage.InstallExpandOperationState{
+				Servers: []storage.Server{server},
+			},
+		},
+		server: ProvisionedServer{
+			Server:  server,
+			Profile: schema.NodeProfile{ServiceRole: schema.ServiceRoleMaster},
+		},
+		docker: storage.DockerConfig{StorageDriver: "overlay2"},
+		config: clusterconfig.New(clusterconfig.Spec{}),
+	}
+	var tests = []struct {
+		cni     string
+		comment string
+		flag    string
+	}{
+		{cni: "", flag: "", comment: "flannel is used without a network plugin"},
+		{cni: schema.CNIFlannel, flag: "", comment: "flannel is used if selected"},
+		{cni: schema.CNICalico, flag: "--disable-flannel=true", comment: "flannel is disabled for calico"},
+	}
+	for _, tc := range tests {
+		s.cluster.backendSite.CNI = tc.cni
+		args, err := s.cluster.getPlanetConfig(config)
+		c.Assert(err, check.IsNil)
+		flag, _ := stripItem(args, "--disable-flannel")
+		c.Assert(flag, check.Equals, tc.flag, check.Commentf(tc.comment))
+	}
+}
+
 func stripItem(args []string, name string) (item string, rest []string) {
 	for i, arg := range args {
 		if strings.HasPrefix(arg, name) {
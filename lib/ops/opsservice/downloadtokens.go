@@ -0,0 +1,96 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/events"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/users"
+
+	"github.com/gravitational/trace"
+)
+
+// CreateDownloadToken creates a new token granting access
+// to download the installer of the specified application
+func (o *Operator) CreateDownloadToken(ctx context.Context, req ops.CreateDownloadTokenRequest) (*storage.DownloadToken, error) {
+	if err := req.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if _, err := o.GetAccount(req.AccountID); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if _, err := o.cfg.Apps.GetApp(req.Application); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	tokenID, err := users.CryptoRandomToken(defaults.DownloadTokenBytes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	now := o.clock().UtcNow()
+	token, err := o.backend().CreateDownloadToken(storage.DownloadToken{
+		Token:       tokenID,
+		AccountID:   req.AccountID,
+		Application: req.Application,
+		CreatedBy:   storage.UserFromContext(ctx),
+		Created:     now,
+		Expires:     now.Add(req.TTL),
+		URL:         fmt.Sprintf("%v/portalapi/v1/download/%v", o.publicURL(), tokenID),
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	events.Emit(ctx, o, events.DownloadTokenCreated, events.Fields{
+		events.FieldName:    token.Application.String(),
+		events.FieldExpires: token.Expires,
+	})
+	return token, nil
+}
+
+// GetDownloadTokens returns active download tokens of the specified account
+func (o *Operator) GetDownloadTokens(ctx context.Context, accountID string) ([]storage.DownloadToken, error) {
+	tokens, err := o.backend().GetDownloadTokens(accountID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return tokens, nil
+}
+
+// DeleteDownloadToken deletes the specified download token
+func (o *Operator) DeleteDownloadToken(ctx context.Context, req ops.DeleteDownloadTokenRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	token, err := o.backend().GetDownloadToken(req.Token)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if token.AccountID != req.AccountID {
+		return trace.NotFound("download token not found")
+	}
+	if err := o.backend().DeleteDownloadToken(req.Token); err != nil {
+		return trace.Wrap(err)
+	}
+	events.Emit(ctx, o, events.DownloadTokenDeleted, events.Fields{
+		events.FieldName: token.Application.String(),
+	})
+	return nil
+}
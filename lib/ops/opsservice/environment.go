@@ -22,8 +22,10 @@ import (
 
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/resources"
 	"github.com/gravitational/gravity/lib/storage"
 
 	"github.com/gravitational/rigging"
@@ -48,7 +50,13 @@ func (o *Operator) CreateUpdateEnvarsOperation(ctx context.Context, r ops.Create
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	key, err := cluster.createUpdateEnvarsOperation(ctx, r, env)
+	// The operation is created under the resource lock so the revision
+	// the update is based on cannot change before the operation starts
+	tracker := resources.RevisionTracker{Backend: o.backend()}
+	key, err := tracker.StartOperation(r.ClusterKey, storage.KindRuntimeEnvironment,
+		constants.ClusterEnvironmentMap, r.Revision, func() (*ops.SiteOperationKey, error) {
+			return cluster.createUpdateEnvarsOperation(ctx, r, env)
+		})
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -118,6 +126,12 @@ func (o *Operator) UpdateClusterEnvironmentVariables(req ops.UpdateClusterEnviro
 		_, err := configmaps.Update(configmap)
 		return trace.Wrap(err)
 	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = kubernetes.Retry(context.TODO(), func() error {
+		return trace.Wrap(upsertProxyConfigMap(configmaps, req.Env))
+	})
 	return trace.Wrap(err)
 }
 
@@ -136,6 +150,22 @@ func NewEnvironmentConfigMap(data map[string]string) *v1.ConfigMap {
 	}
 }
 
+// NewProxyConfigMap creates the ConfigMap that projects the proxy settings
+// from the specified cluster environment
+func NewProxyConfigMap(env map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       constants.KindConfigMap,
+			APIVersion: metav1.SchemeGroupVersion.Version,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.ClusterProxyMap,
+			Namespace: defaults.KubeSystemNamespace,
+		},
+		Data: httplib.ProxyEnvironment(env),
+	}
+}
+
 // createUpdateEnvarsOperation creates a new operation to update cluster environment variables
 func (s *site) createUpdateEnvarsOperation(ctx context.Context, req ops.CreateUpdateEnvarsOperationRequest, prevEnv map[string]string) (*ops.SiteOperationKey, error) {
 	op := ops.SiteOperation{
@@ -177,3 +207,14 @@ func getOrCreateEnvironmentConfigMap(client corev1.ConfigMapInterface) (configma
 	}
 	return configmap, nil
 }
+
+func upsertProxyConfigMap(client corev1.ConfigMapInterface, env map[string]string) error {
+	configmap := NewProxyConfigMap(env)
+	_, err := client.Create(configmap)
+	err = rigging.ConvertError(err)
+	if err == nil || !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	_, err = client.Update(configmap)
+	return trace.Wrap(rigging.ConvertError(err))
+}
@@ -19,6 +19,7 @@ package opsservice
 import (
 	"context"
 
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
@@ -152,6 +153,66 @@ func (s *site) validateExpand(op *ops.SiteOperation, req *ops.OperationUpdateReq
 		return trace.Wrap(err)
 	}
 
-	err = setClusterRoles(req.Servers, *s.app, len(masters))
+	// assign the roles under the operation group lock and save them with the operation
+	// right away so the concurrently joining nodes see each other's master reservations
+	err = s.getOperationGroup().assignExpandRoles(op, req.Servers, *s.app, len(masters))
 	return trace.Wrap(err)
 }
+
+// getJoiningMasters returns the master servers being added
+// by the expand operations in progress other than the specified one.
+// Several nodes can be joining concurrently so these have to be accounted
+// for when assigning the cluster role to the joining node
+func (s *site) getJoiningMasters(operationID string) ([]storage.Server, error) {
+	operations, err := ops.GetActiveOperationsByType(s.key, s.service, ops.OperationExpand)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	return joiningMasters(operations, operationID), nil
+}
+
+// joiningMasters returns the master servers being added by the specified
+// expand operations other than the operation with the given ID
+func joiningMasters(operations []ops.SiteOperation, operationID string) (masters []storage.Server) {
+	for _, op := range operations {
+		if op.ID == operationID {
+			continue
+		}
+		for _, server := range op.Servers {
+			if server.ClusterRole == string(schema.ServiceRoleMaster) {
+				masters = append(masters, server)
+			}
+		}
+	}
+	return masters
+}
+
+// mayJoinAsMaster returns true if the node joining with the specified operation
+// requests the master role, or can be assigned it automatically given
+// the specified number of master nodes
+func mayJoinAsMaster(operation ops.SiteOperation, masters int) bool {
+	if operation.InstallExpand == nil {
+		return false
+	}
+	for _, profile := range operation.InstallExpand.Profiles {
+		switch profile.ServiceRole {
+		case string(schema.ServiceRoleMaster):
+			return true
+		case "":
+			if masters < defaults.MaxMasterNodes {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasMasters returns true if any of the specified servers is assigned the master role
+func hasMasters(servers []storage.Server) bool {
+	for _, server := range servers {
+		if server.ClusterRole == string(schema.ServiceRoleMaster) {
+			return true
+		}
+	}
+	return false
+}
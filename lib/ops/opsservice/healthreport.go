@@ -0,0 +1,93 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// GetHealthReport returns the cluster health report configuration
+func (o *Operator) GetHealthReport(key ops.SiteKey) (storage.HealthReport, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return getHealthReport(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace))
+}
+
+// UpdateHealthReport updates the cluster health report configuration.
+// The time of the last sent report is preserved unless explicitly set
+func (o *Operator) UpdateHealthReport(ctx context.Context, key ops.SiteKey, config storage.HealthReport) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	configmaps := client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace)
+	if config.GetLastSent().IsZero() {
+		current, err := getHealthReport(configmaps)
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		if current != nil {
+			config.SetLastSent(current.GetLastSent())
+		}
+	}
+	data, err := storage.MarshalHealthReport(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return updateConfigMap(configmaps, constants.HealthReportConfigMap,
+		defaults.KubeSystemNamespace, string(data), nil)
+}
+
+// DeleteHealthReport deletes the cluster health report configuration
+func (o *Operator) DeleteHealthReport(ctx context.Context, key ops.SiteKey) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = rigging.ConvertError(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+		Delete(constants.HealthReportConfigMap, &metav1.DeleteOptions{}))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("no health report configuration found")
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+func getHealthReport(client corev1.ConfigMapInterface) (storage.HealthReport, error) {
+	data, err := getConfigMap(client, constants.HealthReportConfigMap)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("no health report configuration found")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return storage.UnmarshalHealthReport([]byte(data))
+}
@@ -60,6 +60,11 @@ func (o *Operator) DeleteUser(ctx context.Context, key ops.SiteKey, name string)
 
 // UpsertClusterAuthPreference updates cluster authentication preference
 func (o *Operator) UpsertClusterAuthPreference(ctx context.Context, key ops.SiteKey, auth teleservices.AuthPreference) error {
+	err := o.admit(ctx, key, teleservices.KindClusterAuthPreference,
+		teleservices.MetaNameClusterAuthPreference, auth)
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	if err := o.cfg.Users.SetAuthPreference(auth); err != nil {
 		return trace.Wrap(err)
 	}
@@ -74,6 +79,14 @@ func (o *Operator) GetClusterAuthPreference(key ops.SiteKey) (teleservices.AuthP
 
 // UpsertGithubConnector creates or updates a Github connector
 func (o *Operator) UpsertGithubConnector(ctx context.Context, key ops.SiteKey, connector teleservices.GithubConnector) error {
+	withoutSecrets, err := githubConnectorWithoutSecrets(connector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = o.admit(ctx, key, teleservices.KindGithubConnector, connector.GetName(), withoutSecrets)
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	if err := o.cfg.Users.UpsertGithubConnector(connector); err != nil {
 		return trace.Wrap(err)
 	}
@@ -99,6 +112,9 @@ func (o *Operator) GetGithubConnectors(key ops.SiteKey, withSecrets bool) ([]tel
 
 // DeleteGithubConnector deletes a Github connector by name
 func (o *Operator) DeleteGithubConnector(ctx context.Context, key ops.SiteKey, name string) error {
+	if err := o.admit(ctx, key, teleservices.KindGithubConnector, name, nil); err != nil {
+		return trace.Wrap(err)
+	}
 	if err := o.cfg.Users.DeleteGithubConnector(name); err != nil {
 		return trace.Wrap(err)
 	}
@@ -107,3 +123,117 @@ func (o *Operator) DeleteGithubConnector(ctx context.Context, key ops.SiteKey, n
 	})
 	return nil
 }
+
+// githubConnectorWithoutSecrets returns a copy of the specified connector
+// with the client secret removed
+func githubConnectorWithoutSecrets(connector teleservices.GithubConnector) (teleservices.GithubConnector, error) {
+	marshaler := teleservices.GetGithubConnectorMarshaler()
+	data, err := marshaler.Marshal(connector)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	result, err := marshaler.Unmarshal(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	result.SetClientSecret("")
+	return result, nil
+}
+
+// UpsertAuthConnector creates or updates an OIDC or SAML connector
+func (o *Operator) UpsertAuthConnector(ctx context.Context, key ops.SiteKey, connector teleservices.Resource) error {
+	if err := ops.CheckAuthConnector(connector); err != nil {
+		return trace.Wrap(err)
+	}
+	withoutSecrets, err := ops.AuthConnectorWithoutSecrets(connector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = o.admit(ctx, key, ops.AuthConnectorKind(connector), connector.GetName(), withoutSecrets)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch c := connector.(type) {
+	case teleservices.OIDCConnector:
+		err = o.cfg.Users.UpsertOIDCConnector(c)
+	case teleservices.SAMLConnector:
+		// generates the signing key pair unless one has been provided
+		if err := c.CheckAndSetDefaults(); err != nil {
+			return trace.Wrap(err)
+		}
+		err = o.cfg.Users.UpsertSAMLConnector(c)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	events.Emit(ctx, o, events.AuthConnectorCreated, events.Fields{
+		events.FieldName: connector.GetName(),
+		events.FieldKind: ops.AuthConnectorKind(connector),
+	})
+	return nil
+}
+
+// GetAuthConnector returns the connector of the specified kind by name
+//
+// Returned connector excludes secrets unless withSecrets is true.
+func (o *Operator) GetAuthConnector(key ops.SiteKey, kind, name string, withSecrets bool) (teleservices.Resource, error) {
+	if err := ops.CheckAuthConnectorKind(kind); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if kind == teleservices.KindOIDCConnector {
+		return o.cfg.Users.GetOIDCConnector(name, withSecrets)
+	}
+	return o.cfg.Users.GetSAMLConnector(name, withSecrets)
+}
+
+// GetAuthConnectors returns all connectors of the specified kind
+//
+// Returned connectors exclude secrets unless withSecrets is true.
+func (o *Operator) GetAuthConnectors(key ops.SiteKey, kind string, withSecrets bool) ([]teleservices.Resource, error) {
+	if err := ops.CheckAuthConnectorKind(kind); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var result []teleservices.Resource
+	if kind == teleservices.KindOIDCConnector {
+		connectors, err := o.cfg.Users.GetOIDCConnectors(withSecrets)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		for _, connector := range connectors {
+			result = append(result, connector)
+		}
+		return result, nil
+	}
+	connectors, err := o.cfg.Users.GetSAMLConnectors(withSecrets)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, connector := range connectors {
+		result = append(result, connector)
+	}
+	return result, nil
+}
+
+// DeleteAuthConnector deletes the connector of the specified kind by name
+func (o *Operator) DeleteAuthConnector(ctx context.Context, key ops.SiteKey, kind, name string) error {
+	if err := ops.CheckAuthConnectorKind(kind); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := o.admit(ctx, key, kind, name, nil); err != nil {
+		return trace.Wrap(err)
+	}
+	var err error
+	if kind == teleservices.KindOIDCConnector {
+		err = o.cfg.Users.DeleteOIDCConnector(name)
+	} else {
+		err = o.cfg.Users.DeleteSAMLConnector(name)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	events.Emit(ctx, o, events.AuthConnectorDeleted, events.Fields{
+		events.FieldName: name,
+		events.FieldKind: kind,
+	})
+	return nil
+}
@@ -37,7 +37,6 @@ import (
 	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/dustin/go-humanize"
-	licenseapi "github.com/gravitational/license"
 	"github.com/gravitational/trace"
 	"github.com/pborman/uuid"
 	log "github.com/sirupsen/logrus"
@@ -185,6 +184,14 @@ func (s *site) createInstallExpandOperation(context context.Context, req createI
 	op.InstallExpand.Subnets = *subnets
 	ctx.Debugf("selected subnets: %v", subnets)
 
+	if op.Type == ops.OperationExpand {
+		installOp, err := ops.GetCompletedInstallOperation(s.key, s.service)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		op.InstallExpand.OverlayMTU = installOp.InstallExpand.OverlayMTU
+	}
+
 	key, err := s.getOperationGroup().createSiteOperation(*op)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -417,13 +424,7 @@ func (s *site) updateOperationState(op *ops.SiteOperation, req ops.OperationUpda
 	}
 
 	if req.ValidateServers {
-		validateReq := ops.ValidateServersRequest{
-			AccountID:   cluster.AccountID,
-			SiteDomain:  cluster.Domain,
-			OperationID: op.ID,
-			Servers:     req.Servers,
-		}
-		err = s.service.ValidateServers(context.TODO(), validateReq)
+		err = s.validateServers(context.TODO(), op, req.Servers)
 		if err != nil {
 			return trace.Wrap(err)
 		}
@@ -577,72 +578,38 @@ func (s *site) checkLicense(site *storage.Site, op *ops.SiteOperation, req ops.O
 		return nil // nothing to do
 	}
 
-	license, err := licenseapi.ParseLicense(site.License)
-	if err != nil {
-		return trace.Wrap(err, "failed to parse license")
+	validateReq := ops.ValidateLicenseRequest{
+		License: site.License,
+		Now:     s.clock().UtcNow(),
 	}
-
 	// for on-prem and AWS scenarios the license is checked a little bit differently
-	if op.Provisioner == schema.ProvisionerAWSTerraform {
-		err = s.checkLicenseAWS(license, op)
-	}
-	if op.Provisioner == schema.ProvisionerOnPrem {
-		err = s.checkLicenseOnPrem(license, op, req, infos)
-	}
-
-	return trace.Wrap(err)
-}
-
-func (s *site) checkLicenseAWS(license licenseapi.License, op *ops.SiteOperation) error {
-	count, err := s.numExistingServers(op)
-	if err != nil {
-		return trace.Wrap(err)
-	}
-
-	for _, profile := range op.InstallExpand.Profiles {
-		count += profile.Request.Count
-	}
-
-	// make sure that the license permits the total number of servers
-	err = license.GetPayload().CheckCount(count)
-	if err != nil {
-		return trace.Wrap(err)
-	}
-
-	var instanceTypes []string
-	for _, profile := range op.InstallExpand.Profiles {
-		instanceTypes = append(instanceTypes, profile.Request.InstanceType)
-	}
-
-	// make sure the the license permits all requested AWS instance types
-	err = license.GetPayload().CheckInstanceTypes(instanceTypes)
-	if err != nil {
-		return trace.Wrap(err)
+	switch op.Provisioner {
+	case schema.ProvisionerAWSTerraform:
+		for _, profile := range op.InstallExpand.Profiles {
+			validateReq.NodeCount += profile.Request.Count
+			validateReq.InstanceTypes = append(validateReq.InstanceTypes, profile.Request.InstanceType)
+		}
+	case schema.ProvisionerOnPrem:
+		validateReq.NodeCount += len(req.Servers)
+		for _, info := range infos {
+			validateReq.NodeCPUs = append(validateReq.NodeCPUs, info.GetNumCPU())
+		}
+	default:
+		return nil
 	}
 
-	return nil
-}
-
-func (s *site) checkLicenseOnPrem(license licenseapi.License, op *ops.SiteOperation, req ops.OperationUpdateRequest, infos checks.ServerInfos) error {
 	count, err := s.numExistingServers(op)
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	validateReq.NodeCount += count
 
-	count += len(req.Servers)
-
-	// make sure that the license permits the total number of servers
-	err = license.GetPayload().CheckCount(count)
+	status, err := ops.ValidateLicense(validateReq)
 	if err != nil {
 		return trace.Wrap(err)
 	}
-
-	// make sure that the license permits on-prem server configurations
-	for _, info := range infos {
-		err = checkLicenseCPU(license.GetPayload(), info.GetNumCPU())
-		if err != nil {
-			return trace.Wrap(err)
-		}
+	if warning := status.Warning(); warning != "" {
+		s.WithField("operation", op.ID).Warnf("License check: %v.", warning)
 	}
 
 	return nil
@@ -901,15 +868,6 @@ func (s *site) newProvisioningToken(operation ops.SiteOperation) (token string,
 	return token, nil
 }
 
-// checkLicenseCPU checks if the license supports the provided number of CPUs.
-func checkLicenseCPU(p licenseapi.Payload, numCPU uint) error {
-	if p.MaxCores != 0 && numCPU > uint(p.MaxCores) {
-		return trace.BadParameter(
-			"the license allows maximum of %v CPUs, requested: %v", p.MaxCores, numCPU)
-	}
-	return nil
-}
-
 // setClusterRoles assigns cluster roles to servers.
 func setClusterRoles(servers []storage.Server, app libapp.Application, masters int) error {
 	// count the number of servers designated as master by the node profile
@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/resources"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+// UpsertInstallResources adds the specified resources to the cluster
+// being installed replacing the resources with the same kind and name.
+//
+// The resources can only be uploaded until the operation plan
+// has been created as they are created by the plan's last phase
+func (o *Operator) UpsertInstallResources(ctx context.Context, req ops.UpsertInstallResourcesRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := o.checkCanUploadInstallResources(req.SiteKey()); err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := o.backend().GetSite(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster.InstallResources = resources.Merge(cluster.InstallResources, req.Resources)
+	_, err = o.backend().UpdateSite(*cluster)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, resource := range req.Resources {
+		o.Infof("Uploaded install resource %v/%v.", resource.Kind, resource.Metadata.Name)
+	}
+	return nil
+}
+
+// GetInstallResources returns the resources uploaded for the
+// cluster being installed
+func (o *Operator) GetInstallResources(key ops.SiteKey) ([]storage.UnknownResource, error) {
+	cluster, err := o.backend().GetSite(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return cluster.InstallResources, nil
+}
+
+// checkCanUploadInstallResources makes sure that the cluster specified with key
+// is being installed and the install operation has not started yet
+func (o *Operator) checkCanUploadInstallResources(key ops.SiteKey) error {
+	operation, _, err := ops.GetInstallOperation(key, o)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch operation.State {
+	case ops.OperationStateInstallInitiated, ops.OperationStateInstallPrechecks:
+	default:
+		return trace.CompareFailed("install operation %v has already started", operation.ID)
+	}
+	_, err = o.backend().GetOperationPlan(key.SiteDomain, operation.ID)
+	if err == nil {
+		return trace.CompareFailed("install operation %v has already started", operation.ID)
+	}
+	if !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/suite"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type InstallResourcesSuite struct {
+	services TestServices
+	cluster  *ops.Site
+	key      ops.SiteOperationKey
+}
+
+var _ = check.Suite(&InstallResourcesSuite{})
+
+func (s *InstallResourcesSuite) SetUpTest(c *check.C) {
+	s.services = SetupTestServices(c)
+
+	suite := &suite.OpsSuite{}
+	app, err := suite.SetUpTestPackage(s.services.Apps, s.services.Packages, c)
+	c.Assert(err, check.IsNil)
+
+	account, err := s.services.Operator.CreateAccount(ops.NewAccountRequest{
+		Org: "installresources.test",
+	})
+	c.Assert(err, check.IsNil)
+
+	s.cluster, err = s.services.Operator.CreateSite(ops.NewSiteRequest{
+		AccountID:  account.ID,
+		AppPackage: app.String(),
+		Provider:   schema.ProvisionerOnPrem,
+		DomainName: "installresources.test",
+	})
+	c.Assert(err, check.IsNil)
+
+	key, err := s.services.Operator.CreateSiteInstallOperation(context.TODO(),
+		ops.CreateSiteInstallOperationRequest{
+			AccountID:   account.ID,
+			SiteDomain:  s.cluster.Domain,
+			Provisioner: schema.ProvisionerOnPrem,
+		})
+	c.Assert(err, check.IsNil)
+	s.key = *key
+}
+
+func (s *InstallResourcesSuite) TestUpsertsResources(c *check.C) {
+	operator := s.services.Operator
+	err := operator.UpsertInstallResources(context.TODO(), s.request(
+		newGithubConnector("github", "first"), newGithubConnector("other", "first")))
+	c.Assert(err, check.IsNil)
+
+	err = operator.UpsertInstallResources(context.TODO(), s.request(
+		newGithubConnector("github", "second")))
+	c.Assert(err, check.IsNil)
+
+	resources, err := operator.GetInstallResources(s.cluster.Key())
+	c.Assert(err, check.IsNil)
+	c.Assert(resources, check.DeepEquals, []storage.UnknownResource{
+		newGithubConnector("github", "second"),
+		newGithubConnector("other", "first"),
+	})
+
+	cluster, err := operator.GetSite(s.cluster.Key())
+	c.Assert(err, check.IsNil)
+	c.Assert(cluster.InstallResources, check.DeepEquals, resources)
+}
+
+func (s *InstallResourcesSuite) TestRejectsUnsupportedResources(c *check.C) {
+	err := s.services.Operator.UpsertInstallResources(context.TODO(), s.request(
+		storage.UnknownResource{
+			ResourceHeader: teleservices.ResourceHeader{
+				Kind:     storage.KindLogForwarder,
+				Version:  teleservices.V2,
+				Metadata: teleservices.Metadata{Name: "forwarder"},
+			},
+		}))
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *InstallResourcesSuite) TestRejectsInvalidResources(c *check.C) {
+	connector := newGithubConnector("github", "first")
+	connector.Raw = []byte(`{"kind":"github","version":"v3","metadata":{"name":"github"},"spec":{"client_id":"first"}}`)
+	err := s.services.Operator.UpsertInstallResources(context.TODO(), s.request(connector))
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+
+	resources, err := s.services.Operator.GetInstallResources(s.cluster.Key())
+	c.Assert(err, check.IsNil)
+	c.Assert(resources, check.HasLen, 0)
+}
+
+func (s *InstallResourcesSuite) TestRejectsResourcesAfterPlanCreated(c *check.C) {
+	_, err := s.services.Backend.CreateOperationPlan(storage.OperationPlan{
+		OperationID:   s.key.OperationID,
+		OperationType: ops.OperationInstall,
+		AccountID:     s.key.AccountID,
+		ClusterName:   s.key.SiteDomain,
+	})
+	c.Assert(err, check.IsNil)
+
+	err = s.services.Operator.UpsertInstallResources(context.TODO(), s.request(
+		newGithubConnector("github", "first")))
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *InstallResourcesSuite) request(resources ...storage.UnknownResource) ops.UpsertInstallResourcesRequest {
+	return ops.UpsertInstallResourcesRequest{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Resources:  resources,
+	}
+}
+
+func newGithubConnector(name, clientID string) storage.UnknownResource {
+	return storage.UnknownResource{
+		ResourceHeader: teleservices.ResourceHeader{
+			Kind:     teleservices.KindGithubConnector,
+			Version:  teleservices.V3,
+			Metadata: teleservices.Metadata{Name: name},
+		},
+		Raw: []byte(fmt.Sprintf(
+			`{"kind":"github","version":"v3","metadata":{"name":%q},`+
+				`"spec":{"client_id":%q,"client_secret":"secret","redirect_url":"https://example.com/v1/webapi/github/callback"}}`,
+			name, clientID)),
+	}
+}
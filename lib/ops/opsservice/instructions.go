@@ -55,7 +55,7 @@ echo "$(date) [INFO] Install agent will be using ${TMPDIR:-/tmp} for temporary f
 {{.service_user_env}}={{.service_uid}} \
 {{.service_group_env}}={{.service_gid}} \
 {{.gravity_bin_path}} {{if .devmode}}--insecure{{end}} --debug install \
-    --advertise-addr={{.advertise_addr}} \
+    --advertise-addr={{.advertise_addr}} {{range .advertise_policy}}{{.}} {{end}}\
     --token={{.install_token}} \
     --cluster={{.cluster_name}} \
     --app={{.app}} \
@@ -79,7 +79,7 @@ echo "$(date) [INFO] Install agent will be using ${TMPDIR:-/tmp} for temporary f
 {{.service_group_env}}={{.service_gid}} \
 {{.gravity_bin_path}} {{if .devmode}}--insecure{{end}} --debug join {{.ops_url}} \
     --token={{.install_token}} \
-    --advertise-addr={{.advertise_addr}} \
+    --advertise-addr={{.advertise_addr}} {{range .advertise_policy}}{{.}} {{end}}\
     --server-addr={{.agent_server_addr}} \
     --role={{.profile}} \
     --cloud-provider={{.cloud_provider}} \
@@ -137,6 +137,7 @@ func (s *site) getJoinInstructions(token storage.ProvisioningToken, serverProfil
 		"gravity_bin_path":  defaults.GravityBin,
 		"cloud_provider":    s.provider,
 		"operation_id":      token.OperationID,
+		"advertise_policy":  advertisePolicyArgs(s.app.Manifest, serverProfile),
 	}
 	var out bytes.Buffer
 	err = joinTemplate.Execute(&out, vars)
@@ -145,3 +146,13 @@ func (s *site) getJoinInstructions(token storage.ProvisioningToken, serverProfil
 	}
 	return out.String(), nil
 }
+
+// advertisePolicyArgs returns the command line flags to select the advertise
+// address of the node with the specified profile as configured in the manifest
+func advertisePolicyArgs(manifest schema.Manifest, profile string) []string {
+	policy := manifest.AdvertiseAddrPolicy(profile)
+	if policy == nil {
+		return nil
+	}
+	return policy.ShellArgs()
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetClusterInventory returns hardware and software inventory of cluster nodes
+func (o *Operator) GetClusterInventory(key ops.SiteKey) (*ops.ClusterInventory, error) {
+	cluster, err := o.openSite(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return cluster.getClusterInventory(context.TODO())
+}
+
+// getClusterInventory queries inventory from all cluster nodes and combines
+// it with the cluster state.
+// Nodes that fail to respond are reported with an error instead of failing
+// the whole inventory
+func (s *site) getClusterInventory(ctx context.Context) (*ops.ClusterInventory, error) {
+	cluster, err := s.backend().GetSite(s.domainName)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var mu sync.Mutex
+	nodes := make(map[string]ops.NodeInventory)
+	err = s.executeOnTeleportServers(ctx, func(ctx context.Context, runner *serverRunner) error {
+		node, err := s.getNodeInventory(runner)
+		if err != nil {
+			node = &ops.NodeInventory{
+				Hostname: runner.server.HostName(),
+				Error:    trace.UserMessage(err),
+			}
+		}
+		mu.Lock()
+		nodes[runner.server.(*teleportServer).IP] = *node
+		mu.Unlock()
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		s.WithError(err).Warn("Failed to collect inventory from some nodes.")
+	}
+	inventory := ops.NewClusterInventory(*cluster, nodes)
+	return &inventory, nil
+}
+
+// executeOnTeleportServers executes fn concurrently for all cluster nodes
+// currently registered with teleport
+func (s *site) executeOnTeleportServers(ctx context.Context, fn func(context.Context, *serverRunner) error) error {
+	const noRetry = 1
+	servers, err := s.getTeleportServersWithTimeout(
+		nil,
+		defaults.TeleportServerQueryTimeout,
+		defaults.RetryInterval,
+		noRetry,
+		queryReturnsAtLeastOneServer)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	runner := &teleportRunner{
+		FieldLogger:          log.WithField(trace.Component, "teleport-runner"),
+		TeleportProxyService: s.teleport(),
+		domainName:           s.domainName,
+	}
+	remoteServers := make([]remoteServer, 0, len(servers))
+	for _, server := range servers {
+		teleportServer, err := newTeleportServer(server)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		remoteServers = append(remoteServers, teleportServer)
+	}
+	return s.executeOnServers(ctx, remoteServers, func(ctx context.Context, server remoteServer) error {
+		return fn(ctx, &serverRunner{server: server, runner: runner})
+	})
+}
+
+func (s *site) getNodeInventory(runner *serverRunner) (*ops.NodeInventory, error) {
+	var out bytes.Buffer
+	err := runner.RunStream(&out, s.gravityCommand("system", "inventory")...)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to collect inventory")
+	}
+	var node ops.NodeInventory
+	if err := json.Unmarshal(out.Bytes(), &node); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &node, nil
+}
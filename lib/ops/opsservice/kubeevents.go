@@ -0,0 +1,171 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops/events"
+
+	"github.com/gravitational/rigging"
+	teleevents "github.com/gravitational/teleport/lib/events"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// KubernetesEvents publishes significant cluster audit events as Kubernetes
+// events so they can be picked up by standard Kubernetes tooling and alerting
+type KubernetesEvents interface {
+	// Publish publishes the audit event with the specified fields as
+	// a Kubernetes event in the kube-system namespace.
+	// Events that are not significant for Kubernetes tooling are ignored
+	Publish(event teleevents.Event, fields teleevents.EventFields)
+}
+
+// NewKubernetesEvents returns a new publisher of Kubernetes events.
+//
+// The events are rate limited: events published in excess of the limit
+// are dropped as they are still recorded in the cluster audit log
+func NewKubernetesEvents(client corev1.EventsGetter) KubernetesEvents {
+	return newKubernetesEvents(func(event *v1.Event) error {
+		_, err := client.Events(event.Namespace).Create(event)
+		return rigging.ConvertError(err)
+	})
+}
+
+func newKubernetesEvents(create func(*v1.Event) error) *kubernetesEvents {
+	return &kubernetesEvents{
+		create:      create,
+		limiter:     rate.NewLimiter(rate.Every(defaults.KubernetesEventsInterval), defaults.KubernetesEventsBurst),
+		FieldLogger: logrus.WithField(trace.Component, "kubeevents"),
+	}
+}
+
+type kubernetesEvents struct {
+	// create creates the specified Kubernetes event
+	create func(*v1.Event) error
+	// limiter limits the rate of published events
+	limiter *rate.Limiter
+	logrus.FieldLogger
+}
+
+// Publish publishes the audit event with the specified fields as
+// a Kubernetes event in the kube-system namespace
+func (r *kubernetesEvents) Publish(event teleevents.Event, fields teleevents.EventFields) {
+	kubeEvent := newKubernetesEvent(event, fields, time.Now())
+	if kubeEvent == nil {
+		return
+	}
+	if !r.limiter.Allow() {
+		r.WithField("event", event.Name).Warn("Kubernetes events rate limit exceeded, dropping event.")
+		return
+	}
+	go func() {
+		if err := r.create(kubeEvent); err != nil {
+			r.WithError(err).WithField("event", event.Name).Warn("Failed to publish Kubernetes event.")
+		}
+	}()
+}
+
+// newKubernetesEvent returns a Kubernetes event for the specified audit event.
+// Returns nil if the audit event is not published to Kubernetes
+func newKubernetesEvent(event teleevents.Event, fields teleevents.EventFields, now time.Time) *v1.Event {
+	reason, eventType, message := describeKubernetesEvent(event, fields)
+	if reason == "" {
+		return nil
+	}
+	timestamp := metav1.NewTime(now)
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("gravity.%x", now.UnixNano()),
+			Namespace: defaults.KubeSystemNamespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       defaults.KubeSystemNamespace,
+		},
+		Reason:  reason,
+		Message: message,
+		Type:    eventType,
+		Source: v1.EventSource{
+			Component: defaults.KubernetesEventsComponent,
+			Host:      getField(fields, events.FieldNodeHostname),
+		},
+		FirstTimestamp: timestamp,
+		LastTimestamp:  timestamp,
+		Count:          1,
+	}
+}
+
+// describeKubernetesEvent returns the reason, type and message of the Kubernetes
+// event for the specified audit event.
+// Returns an empty reason if the audit event is not published to Kubernetes
+func describeKubernetesEvent(event teleevents.Event, fields teleevents.EventFields) (reason, eventType, message string) {
+	operation := fmt.Sprintf("Operation %v (%v)",
+		getField(fields, events.FieldOperationType), getField(fields, events.FieldOperationID))
+	if hostname := getField(fields, events.FieldNodeHostname); hostname != "" {
+		operation = fmt.Sprintf("%v on node %v", operation, hostname)
+	}
+	node := getField(fields, events.FieldNodeHostname)
+	if ip := getField(fields, events.FieldNodeIP); ip != "" {
+		node = strings.TrimSpace(fmt.Sprintf("%v (%v)", node, ip))
+	}
+	switch event.Name {
+	case events.OperationStartedEvent:
+		return "OperationStarted", v1.EventTypeNormal, operation + " started."
+	case events.OperationCompletedEvent:
+		return "OperationCompleted", v1.EventTypeNormal, operation + " completed."
+	case events.OperationFailedEvent:
+		return "OperationFailed", v1.EventTypeWarning, operation + " failed."
+	case events.ClusterDegradedEvent:
+		return "ClusterDegraded", v1.EventTypeWarning,
+			fmt.Sprintf("Cluster is degraded: %v.", getField(fields, events.FieldReason))
+	case events.ClusterActivatedEvent:
+		return "ClusterHealthy", v1.EventTypeNormal, "Cluster is healthy."
+	case events.NodeDegradedEvent:
+		state := getField(fields, events.FieldReason)
+		if state == "" {
+			state = "degraded"
+		}
+		return "NodeDegraded", v1.EventTypeWarning, fmt.Sprintf("Node %v is %v.", node, state)
+	case events.CertificatesRotatedEvent:
+		return "CertificatesRotated", v1.EventTypeNormal,
+			fmt.Sprintf("Certificates of node %v have been renewed.", node)
+	case events.TLSKeyPairCreatedEvent:
+		return "ClusterCertificateUpdated", v1.EventTypeNormal, "Cluster web certificate has been updated."
+	}
+	return "", "", ""
+}
+
+// getField returns the value of the specified event field as a string.
+// Unlike EventFields.GetString, it also formats values of named string
+// types like storage.Reason that have not been serialized yet
+func getField(fields teleevents.EventFields, key string) string {
+	value, ok := fields[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
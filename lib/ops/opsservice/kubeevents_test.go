@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops/events"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleevents "github.com/gravitational/teleport/lib/events"
+	"gopkg.in/check.v1"
+	"k8s.io/api/core/v1"
+)
+
+type KubeEventsSuite struct{}
+
+var _ = check.Suite(&KubeEventsSuite{})
+
+func (s *KubeEventsSuite) TestConvertsAuditEvents(c *check.C) {
+	now := time.Date(2019, time.October, 1, 10, 0, 0, 0, time.UTC)
+	event := newKubernetesEvent(events.OperationUpdateFailure, teleevents.EventFields{
+		events.FieldOperationID:   "1234",
+		events.FieldOperationType: "operation_update",
+	}, now)
+	c.Assert(event, check.NotNil)
+	c.Assert(event.Namespace, check.Equals, defaults.KubeSystemNamespace)
+	c.Assert(event.Reason, check.Equals, "OperationFailed")
+	c.Assert(event.Type, check.Equals, v1.EventTypeWarning)
+	c.Assert(event.Message, check.Equals, "Operation operation_update (1234) failed.")
+	c.Assert(event.Source.Component, check.Equals, defaults.KubernetesEventsComponent)
+
+	event = newKubernetesEvent(events.NodeDegraded, teleevents.EventFields{
+		events.FieldNodeHostname: "node-1",
+		events.FieldNodeIP:       "10.0.0.1",
+		events.FieldReason:       "offline",
+	}, now)
+	c.Assert(event, check.NotNil)
+	c.Assert(event.Reason, check.Equals, "NodeDegraded")
+	c.Assert(event.Message, check.Equals, "Node node-1 (10.0.0.1) is offline.")
+
+	event = newKubernetesEvent(events.ClusterUnhealthy, teleevents.EventFields{
+		events.FieldReason: storage.ReasonClusterDegraded,
+	}, now)
+	c.Assert(event, check.NotNil)
+	c.Assert(event.Message, check.Equals, fmt.Sprintf("Cluster is degraded: %v.", storage.ReasonClusterDegraded))
+
+	event = newKubernetesEvent(events.UserCreated, teleevents.EventFields{}, now)
+	c.Assert(event, check.IsNil, check.Commentf("insignificant events should not be published"))
+}
+
+func (s *KubeEventsSuite) TestRateLimitsEvents(c *check.C) {
+	created := make(chan *v1.Event, defaults.KubernetesEventsBurst*2)
+	publisher := newKubernetesEvents(func(event *v1.Event) error {
+		created <- event
+		return nil
+	})
+	for i := 0; i < defaults.KubernetesEventsBurst*2; i++ {
+		publisher.Publish(events.ClusterUnhealthy, teleevents.EventFields{})
+	}
+	for i := 0; i < defaults.KubernetesEventsBurst; i++ {
+		select {
+		case <-created:
+		case <-time.After(5 * time.Second):
+			c.Fatalf("expected %v events, got %v", defaults.KubernetesEventsBurst, i)
+		}
+	}
+	select {
+	case <-created:
+		c.Fatal("expected events in excess of the limit to be dropped")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+// UpdateLabels sets and removes labels of the cluster
+// or of the cluster node specified in the request.
+//
+// Node labels are also applied to the corresponding Kubernetes node
+// of the local cluster
+func (o *Operator) UpdateLabels(ctx context.Context, req ops.UpdateLabelsRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := o.backend().GetSite(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if req.AdvertiseIP == "" {
+		cluster.Labels = req.Apply(cluster.Labels)
+		if _, err := o.backend().UpdateSite(*cluster); err != nil {
+			return trace.Wrap(err)
+		}
+		o.WithField("cluster", cluster.Domain).Infof("Updated cluster labels: %v.", cluster.Labels)
+		return nil
+	}
+	server, err := findClusterServer(cluster, req.AdvertiseIP)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	server.Labels = req.Apply(server.Labels)
+	if _, err := o.backend().UpdateSite(*cluster); err != nil {
+		return trace.Wrap(err)
+	}
+	o.WithField("node", server.AdvertiseIP).Infof("Updated node labels: %v.", server.Labels)
+	if !cluster.Local {
+		return nil
+	}
+	return trace.Wrap(o.updateKubernetesNodeLabels(ctx, *server, req))
+}
+
+// updateKubernetesNodeLabels applies the label updates to the Kubernetes node
+// corresponding to the specified server
+func (o *Operator) updateKubernetesNodeLabels(ctx context.Context, server storage.Server, req ops.UpdateLabelsRequest) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	nodes := client.CoreV1().Nodes()
+	if len(req.Add) != 0 {
+		err := kubernetes.UpdateLabels(ctx, nodes, server.KubeNodeID(), req.Add)
+		if err != nil {
+			return trace.Wrap(err, "failed to update labels of Kubernetes node %v", server.KubeNodeID())
+		}
+	}
+	if len(req.Remove) != 0 {
+		err := kubernetes.RemoveLabels(ctx, nodes, server.KubeNodeID(), req.Remove)
+		if err != nil {
+			return trace.Wrap(err, "failed to remove labels of Kubernetes node %v", server.KubeNodeID())
+		}
+	}
+	return nil
+}
+
+// findClusterServer returns the server with the specified advertise IP
+// from the cluster state
+func findClusterServer(cluster *storage.Site, advertiseIP string) (*storage.Server, error) {
+	for i, server := range cluster.ClusterState.Servers {
+		if server.AdvertiseIP == advertiseIP {
+			return &cluster.ClusterState.Servers[i], nil
+		}
+	}
+	return nil, trace.NotFound("cluster %v has no node with advertise IP %v",
+		cluster.Domain, advertiseIP)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/suite"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type LabelsSuite struct {
+	services TestServices
+	cluster  *ops.Site
+}
+
+var _ = check.Suite(&LabelsSuite{})
+
+func (s *LabelsSuite) SetUpTest(c *check.C) {
+	s.services = SetupTestServices(c)
+
+	suite := &suite.OpsSuite{}
+	app, err := suite.SetUpTestPackage(s.services.Apps, s.services.Packages, c)
+	c.Assert(err, check.IsNil)
+
+	account, err := s.services.Operator.CreateAccount(ops.NewAccountRequest{
+		Org: "labels.test",
+	})
+	c.Assert(err, check.IsNil)
+
+	s.cluster, err = s.services.Operator.CreateSite(ops.NewSiteRequest{
+		AccountID:  account.ID,
+		AppPackage: app.String(),
+		Provider:   schema.ProvisionerOnPrem,
+		DomainName: "labels.test",
+	})
+	c.Assert(err, check.IsNil)
+
+	cluster, err := s.services.Backend.GetSite(s.cluster.Domain)
+	c.Assert(err, check.IsNil)
+	cluster.ClusterState.Servers = []storage.Server{
+		{Hostname: "node-1", AdvertiseIP: "10.0.0.1"},
+		{Hostname: "node-2", AdvertiseIP: "10.0.0.2", Labels: map[string]string{"zone": "a"}},
+	}
+	_, err = s.services.Backend.UpdateSite(*cluster)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *LabelsSuite) TestUpdatesClusterLabels(c *check.C) {
+	operator := s.services.Operator
+	err := operator.UpdateLabels(context.TODO(), ops.UpdateLabelsRequest{
+		SiteKey: s.cluster.Key(),
+		Add:     map[string]string{"env": "prod", "region": "us-east-1"},
+	})
+	c.Assert(err, check.IsNil)
+
+	err = operator.UpdateLabels(context.TODO(), ops.UpdateLabelsRequest{
+		SiteKey: s.cluster.Key(),
+		Remove:  []string{"region"},
+	})
+	c.Assert(err, check.IsNil)
+
+	summary, err := operator.GetClusterSummaries(s.cluster.AccountID)
+	c.Assert(err, check.IsNil)
+	c.Assert(summary, check.HasLen, 1)
+	c.Assert(summary[0].Labels["env"], check.Equals, "prod")
+	_, ok := summary[0].Labels["region"]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *LabelsSuite) TestUpdatesNodeLabels(c *check.C) {
+	err := s.services.Operator.UpdateLabels(context.TODO(), ops.UpdateLabelsRequest{
+		SiteKey:     s.cluster.Key(),
+		AdvertiseIP: "10.0.0.2",
+		Add:         map[string]string{"disk": "ssd"},
+		Remove:      []string{"zone"},
+	})
+	c.Assert(err, check.IsNil)
+
+	cluster, err := s.services.Backend.GetSite(s.cluster.Domain)
+	c.Assert(err, check.IsNil)
+	c.Assert(cluster.ClusterState.Servers[0].Labels, check.HasLen, 0)
+	c.Assert(cluster.ClusterState.Servers[1].Labels, check.DeepEquals, map[string]string{"disk": "ssd"})
+
+	err = s.services.Operator.UpdateLabels(context.TODO(), ops.UpdateLabelsRequest{
+		SiteKey:     s.cluster.Key(),
+		AdvertiseIP: "10.0.0.3",
+		Add:         map[string]string{"disk": "ssd"},
+	})
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+}
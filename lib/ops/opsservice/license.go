@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"bytes"
+
+	"github.com/gravitational/gravity/lib/ops"
+
+	licenseapi "github.com/gravitational/license"
+	"github.com/gravitational/trace"
+)
+
+// UpdateClusterLicense validates and installs a new license into the cluster.
+//
+// The license must be signed by the cluster's certificate authority and
+// permit the current cluster configuration
+func (o *Operator) UpdateClusterLicense(req ops.UpdateLicenseRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := o.cfg.Backend.GetSite(req.SiteDomain)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := ops.VerifyLicense(o.packages(), req.License); err != nil {
+		return trace.Wrap(err, "failed to validate provided license")
+	}
+	status, err := ops.ValidateLicense(ops.ValidateLicenseRequest{
+		License:   req.License,
+		NodeCount: len(cluster.ClusterState.Servers),
+		Now:       o.clock().UtcNow(),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if cluster.License != "" {
+		if err := checkEncryptionKey(cluster.License, req.License); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if warning := status.Warning(); warning != "" {
+		o.Warnf("Installing license for cluster %v: %v.", cluster.Domain, warning)
+	}
+	cluster.License = req.License
+	_, err = o.cfg.Backend.UpdateSite(*cluster)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	o.Infof("Updated license for cluster %v.", cluster.Domain)
+	return nil
+}
+
+// checkEncryptionKey makes sure that the new license carries the same
+// package encryption key as the installed license, otherwise the cluster
+// would be unable to decrypt its application packages
+func checkEncryptionKey(installed, new string) error {
+	installedLicense, err := licenseapi.ParseLicense(installed)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	newLicense, err := licenseapi.ParseLicense(new)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !bytes.Equal(installedLicense.GetPayload().EncryptionKey, newLicense.GetPayload().EncryptionKey) {
+		return trace.BadParameter("the new license has a different package encryption key than the installed license")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/logging"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/trace"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// GetLogLevels returns the log level overrides of cluster controllers
+func (o *Operator) GetLogLevels(key ops.SiteKey) (logging.Levels, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return getLogLevels(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace))
+}
+
+// UpdateLogLevels updates log levels of cluster controllers without restarting them.
+//
+// The levels are stored in a config map watched by all cluster controllers
+func (o *Operator) UpdateLogLevels(ctx context.Context, req ops.UpdateLogLevelsRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	configMaps := client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace)
+	levels, err := getLogLevels(configMaps)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	data, err := json.Marshal(levels.Merge(req.Levels))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return updateConfigMap(configMaps, constants.LogLevelsConfigMap,
+		defaults.KubeSystemNamespace, string(data), nil)
+}
+
+func getLogLevels(client corev1.ConfigMapInterface) (logging.Levels, error) {
+	data, err := getConfigMap(client, constants.LogLevelsConfigMap)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("no log levels configured")
+		}
+		return nil, trace.Wrap(err)
+	}
+	var levels logging.Levels
+	if err := json.Unmarshal([]byte(data), &levels); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return levels, nil
+}
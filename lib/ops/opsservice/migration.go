@@ -0,0 +1,173 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/migration"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+	"k8s.io/client-go/dynamic"
+)
+
+// ImportMigration verifies the migration archive read from the provided
+// reader and starts the operation that imports the application data
+// from it into the local cluster.
+//
+// The archive is staged in the state directory and verified completely
+// before the operation is created, so a corrupted or incomplete archive,
+// or one with the data of system namespaces, is rejected without
+// applying anything to the cluster
+func (o *Operator) ImportMigration(req ops.ImportMigrationRequest, reader io.Reader) (*ops.SiteOperationKey, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.backend().GetSite(req.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !cluster.Local {
+		return nil, trace.BadParameter("application data can only be imported into the local cluster")
+	}
+	site, err := o.openSite(req.SiteKey())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	file, err := ioutil.TempFile(o.cfg.StateDir, "migration")
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	path := file.Name()
+	manifest, err := migration.Verify(io.TeeReader(reader, file))
+	if errClose := file.Close(); err == nil {
+		err = trace.ConvertSystemError(errClose)
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, trace.Wrap(err, "failed to verify migration archive")
+	}
+	key, err := site.createImportMigrationOperation()
+	if err != nil {
+		os.Remove(path)
+		return nil, trace.Wrap(err)
+	}
+	err = site.executeOperation(*key, func(ctx *operationContext) error {
+		defer os.Remove(path)
+		return trace.Wrap(site.importMigration(ctx, req, *manifest, path))
+	})
+	if err != nil {
+		os.Remove(path)
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+// createImportMigrationOperation creates a new operation that imports
+// the migrated application data into the cluster
+func (s *site) createImportMigrationOperation() (*ops.SiteOperationKey, error) {
+	_, err := ops.GetCompletedInstallOperation(s.key, s.service)
+	if err != nil {
+		return nil, trace.Wrap(err, "application data can only be imported into an installed cluster")
+	}
+	op := ops.SiteOperation{
+		ID:         uuid.New(),
+		AccountID:  s.key.AccountID,
+		SiteDomain: s.key.SiteDomain,
+		Type:       ops.OperationImportMigration,
+		Created:    s.clock().UtcNow(),
+		Updated:    s.clock().UtcNow(),
+		State:      ops.OperationImportMigrationInProgress,
+	}
+	key, err := s.getOperationGroup().createSiteOperation(op)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+// importMigration imports the application data from the verified
+// migration archive at the specified path
+func (s *site) importMigration(ctx *operationContext, req ops.ImportMigrationRequest, manifest migration.Manifest, path string) error {
+	client, config, err := utils.GetKubeClient("")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	migrator, err := migration.New(migration.Config{
+		Client:      client,
+		Dynamic:     dynamicClient,
+		Executor:    kubernetes.NewPodExecutor(client, config),
+		FieldLogger: ctx.Entry,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer file.Close()
+	s.reportProgress(ctx, ops.ProgressEntry{
+		State:   ops.ProgressStateInProgress,
+		Message: fmt.Sprintf("Importing application data migrated from %v.", manifest.Cluster),
+	})
+	total := len(manifest.Resources) + len(manifest.Volumes)
+	importCtx, cancel := context.WithTimeout(context.Background(), defaults.MigrationImportTimeout)
+	defer cancel()
+	summary, err := migrator.Import(importCtx, migration.ImportRequest{
+		StorageClasses: req.StorageClasses,
+		Progress: func(imported int, message string) {
+			s.reportProgress(ctx, ops.ProgressEntry{
+				State:      ops.ProgressStateInProgress,
+				Completion: utils.Min(imported*constants.Completed/utils.Max(total, 1), constants.Completed-1),
+				Message:    message,
+			})
+		},
+	}, file)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = s.compareAndSwapOperationState(swap{
+		key:            ctx.key(),
+		expectedStates: []string{ops.OperationImportMigrationInProgress},
+		newOpState:     ops.OperationStateCompleted,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	s.reportProgress(ctx, ops.ProgressEntry{
+		State:      ops.ProgressStateCompleted,
+		Completion: constants.Completed,
+		Message: fmt.Sprintf("Imported %v resources and %v volumes migrated from %v, skipped %v existing resources.",
+			len(summary.Resources), len(summary.Volumes), summary.Cluster, len(summary.Skipped)),
+	})
+	return nil
+}
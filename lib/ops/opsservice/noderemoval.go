@@ -0,0 +1,413 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	libkubernetes "github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// VerifyNodeRemoval verifies that the node has been removed from
+// the etcd cluster, Kubernetes and Teleport
+func (o *Operator) VerifyNodeRemoval(ctx context.Context, req ops.VerifyNodeRemovalRequest) (*ops.NodeRemovalStatus, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	site, err := o.openSite(req.SiteKey())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &ops.NodeRemovalStatus{
+		Steps: []ops.NodeRemovalStep{
+			site.verifyEtcdMemberRemoved(req.Server),
+			verifyKubernetesNodeRemoved(client, req.Server),
+			site.verifyTeleportNodeRemoved(req.Server),
+		},
+	}, nil
+}
+
+// CreateNodeTombstone records the node that has left the cluster
+// forcibly for later reconciliation
+func (o *Operator) CreateNodeTombstone(ctx context.Context, key ops.SiteKey, tombstone ops.NodeTombstone) error {
+	if err := tombstone.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if tombstone.Created.IsZero() {
+		tombstone.Created = o.cfg.Clock.UtcNow()
+	}
+	tombstones, err := o.GetNodeTombstones(key)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var result []ops.NodeTombstone
+	for _, existing := range tombstones {
+		if existing.Server.Hostname != tombstone.Server.Hostname {
+			result = append(result, existing)
+		}
+	}
+	return o.updateNodeTombstones(append(result, tombstone))
+}
+
+// GetNodeTombstones returns the nodes that have left the cluster forcibly
+// and have not been reconciled yet
+func (o *Operator) GetNodeTombstones(key ops.SiteKey) ([]ops.NodeTombstone, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	data, err := getConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.NodeTombstonesConfigMap)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	var tombstones []ops.NodeTombstone
+	if err := json.Unmarshal([]byte(data), &tombstones); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return tombstones, nil
+}
+
+// DeleteNodeTombstone deletes the tombstone of the node with the specified hostname
+func (o *Operator) DeleteNodeTombstone(ctx context.Context, key ops.SiteKey, hostname string) error {
+	tombstones, err := o.GetNodeTombstones(key)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var result []ops.NodeTombstone
+	for _, tombstone := range tombstones {
+		if tombstone.Server.Hostname != hostname {
+			result = append(result, tombstone)
+		}
+	}
+	if len(result) == len(tombstones) {
+		return trace.NotFound("no tombstone found for node %v", hostname)
+	}
+	return o.updateNodeTombstones(result)
+}
+
+func (o *Operator) updateNodeTombstones(tombstones []ops.NodeTombstone) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if len(tombstones) == 0 {
+		err = rigging.ConvertError(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+			Delete(constants.NodeTombstonesConfigMap, &metav1.DeleteOptions{}))
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(tombstones)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return updateConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.NodeTombstonesConfigMap, defaults.KubeSystemNamespace, string(data), nil)
+}
+
+// verifyEtcdMemberRemoved verifies that the specified server
+// is not a member of the etcd cluster
+func (s *site) verifyEtcdMemberRemoved(server storage.Server) ops.NodeRemovalStep {
+	step := ops.NodeRemovalStep{Description: "etcd member"}
+	runner, err := s.pickShrinkMasterRunner(
+		log.WithField(trace.Component, "teleport-runner"), server)
+	if err != nil {
+		step.Message = trace.UserMessage(err)
+		return step
+	}
+	out, err := runner.Run(s.etcdctlCommand("member", "list")...)
+	if err != nil {
+		step.Message = fmt.Sprintf("failed to list etcd members: %s", out)
+		return step
+	}
+	provisionedServer := ProvisionedServer{Server: server}
+	memberID, err := utils.FindETCDMemberID(
+		string(out), provisionedServer.EtcdMemberName(s.domainName))
+	if trace.IsNotFound(err) {
+		step.Removed = true
+		return step
+	}
+	if err != nil {
+		step.Message = trace.UserMessage(err)
+		return step
+	}
+	step.Message = fmt.Sprintf("member %v is still registered", memberID)
+	return step
+}
+
+// verifyKubernetesNodeRemoved verifies that the Kubernetes node
+// of the specified server has been deleted
+func verifyKubernetesNodeRemoved(client *kubernetes.Clientset, server storage.Server) ops.NodeRemovalStep {
+	step := ops.NodeRemovalStep{Description: "Kubernetes node"}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%v=%v", defaults.KubernetesHostnameLabel, server.KubeNodeID()),
+	})
+	if err != nil {
+		step.Message = trace.UserMessage(rigging.ConvertError(err))
+		return step
+	}
+	if len(nodes.Items) != 0 {
+		step.Message = fmt.Sprintf("node %v is still registered", nodes.Items[0].Name)
+		return step
+	}
+	step.Removed = true
+	return step
+}
+
+// verifyTeleportNodeRemoved verifies that the specified server
+// is no longer registered with Teleport
+func (s *site) verifyTeleportNodeRemoved(server storage.Server) ops.NodeRemovalStep {
+	step := ops.NodeRemovalStep{Description: "Teleport node"}
+	_, err := s.getTeleportServerNoRetry(ops.Hostname, server.Hostname)
+	if trace.IsNotFound(err) {
+		step.Removed = true
+		return step
+	}
+	if err != nil {
+		step.Message = trace.UserMessage(err)
+		return step
+	}
+	step.Message = "node is still registered"
+	return step
+}
+
+// SimulateNodeRemoval reports the impact of removing the node from the cluster
+// without making any changes
+func (o *Operator) SimulateNodeRemoval(ctx context.Context, req ops.SimulateNodeRemovalRequest) (*ops.NodeRemovalSimulation, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.GetSite(req.SiteKey())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	server, err := cluster.ClusterState.FindServer(req.Server.Hostname)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	state, err := o.getWorkloadState()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return simulateNodeRemoval(*server, cluster.ClusterState.Servers,
+		state.nodes, state.pods, state.volumes), nil
+}
+
+// getNodeHeadroom returns the headroom for draining the specified server
+func (o *Operator) getNodeHeadroom(server storage.Server) (*libkubernetes.Headroom, error) {
+	state, err := o.getWorkloadState()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	headroom := libkubernetes.ComputeHeadroom(kubeNodeName(server, state.nodes),
+		state.nodes, state.pods, state.volumes)
+	return &headroom, nil
+}
+
+// getWorkloadState returns the nodes, pods and persistent volumes of the cluster
+func (o *Operator) getWorkloadState() (*workloadState, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	volumes, err := client.CoreV1().PersistentVolumes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	return &workloadState{
+		nodes:   nodes.Items,
+		pods:    pods.Items,
+		volumes: volumes.Items,
+	}, nil
+}
+
+// workloadState is a snapshot of the cluster objects used
+// to estimate the impact of removing a node
+type workloadState struct {
+	nodes   []v1.Node
+	pods    []v1.Pod
+	volumes []v1.PersistentVolume
+}
+
+// simulateNodeRemoval computes the impact of removing the specified server
+// given the current state of the cluster
+func simulateNodeRemoval(server storage.Server, servers storage.Servers, nodes []v1.Node, pods []v1.Pod, volumes []v1.PersistentVolume) *ops.NodeRemovalSimulation {
+	nodeName := kubeNodeName(server, nodes)
+	simulation := &ops.NodeRemovalSimulation{
+		Server: server,
+		Etcd:   etcdQuorum(server, servers),
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		if libkubernetes.IsDaemonSetPod(pod) || libkubernetes.IsMirrorPod(pod) {
+			// DaemonSet and static pods are bound to the node
+			// and are not rescheduled
+			continue
+		}
+		simulation.Pods = append(simulation.Pods, ops.EvictedPod{
+			Namespace:  pod.Namespace,
+			Name:       pod.Name,
+			Controller: podController(pod),
+		})
+	}
+	headroom := libkubernetes.ComputeHeadroom(nodeName, nodes, pods, volumes)
+	simulation.Capacity = ops.RemainingCapacity{
+		RequestedCPU:    headroom.RequestedCPU,
+		RequestedMemory: headroom.RequestedMemory,
+		AvailableCPU:    headroom.AvailableCPU,
+		AvailableMemory: headroom.AvailableMemory,
+		Sufficient:      headroom.HasResources(),
+	}
+	for _, pod := range headroom.UnschedulablePods {
+		simulation.Capacity.UnschedulablePods = append(simulation.Capacity.UnschedulablePods,
+			fmt.Sprintf("%v: %v", pod, pod.Reason))
+	}
+	for _, volume := range volumes {
+		if !isLocalVolume(volume, server.KubeNodeID()) {
+			continue
+		}
+		localVolume := ops.LocalVolume{
+			Name:         volume.Name,
+			StorageClass: volume.Spec.StorageClassName,
+		}
+		if capacity, ok := volume.Spec.Capacity[v1.ResourceStorage]; ok {
+			localVolume.CapacityBytes = capacity.Value()
+		}
+		if ref := volume.Spec.ClaimRef; ref != nil {
+			localVolume.Claim = fmt.Sprintf("%v/%v", ref.Namespace, ref.Name)
+		}
+		simulation.LocalVolumes = append(simulation.LocalVolumes, localVolume)
+	}
+	return simulation
+}
+
+// etcdQuorum describes the impact of removing the specified server
+// on the etcd cluster formed by the master nodes
+func etcdQuorum(server storage.Server, servers storage.Servers) ops.EtcdQuorum {
+	var members int
+	for i := range servers {
+		if servers[i].IsMaster() {
+			members++
+		}
+	}
+	quorum := ops.EtcdQuorum{
+		Member:  server.IsMaster(),
+		Members: members,
+	}
+	if !quorum.Member {
+		quorum.Message = "node is not an etcd member"
+		return quorum
+	}
+	remaining := members - 1
+	switch {
+	case remaining == 0:
+		quorum.Endangered = true
+		quorum.Message = "node is the last etcd member"
+	case etcdFaultTolerance(remaining) == 0:
+		quorum.Endangered = true
+		quorum.Message = fmt.Sprintf("the remaining %v etcd member(s) cannot tolerate "+
+			"the failure of another member", remaining)
+	default:
+		quorum.Message = fmt.Sprintf("the remaining %v etcd members can tolerate "+
+			"the failure of %v member(s)", remaining, etcdFaultTolerance(remaining))
+	}
+	return quorum
+}
+
+// etcdFaultTolerance returns the number of members the etcd cluster
+// of the specified size can lose without losing quorum
+func etcdFaultTolerance(members int) int {
+	return (members - 1) / 2
+}
+
+// kubeNodeName returns the name of the Kubernetes node of the specified server
+func kubeNodeName(server storage.Server, nodes []v1.Node) string {
+	for _, node := range nodes {
+		if node.Labels[defaults.KubernetesHostnameLabel] == server.KubeNodeID() {
+			return node.Name
+		}
+	}
+	return server.KubeNodeID()
+}
+
+// isLocalVolume returns true if the specified persistent volume is stored
+// on the node with the specified hostname label
+func isLocalVolume(volume v1.PersistentVolume, hostname string) bool {
+	if volume.Spec.Local == nil && volume.Spec.HostPath == nil {
+		return false
+	}
+	if volume.Spec.NodeAffinity == nil || volume.Spec.NodeAffinity.Required == nil {
+		return false
+	}
+	for _, term := range volume.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key == defaults.KubernetesHostnameLabel &&
+				expr.Operator == v1.NodeSelectorOpIn &&
+				utils.StringInSlice(expr.Values, hostname) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// podController returns the controller of the specified pod as kind/name
+// or an empty string if the pod is not managed by a controller
+func podController(pod v1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			return fmt.Sprintf("%v/%v", ref.Kind, ref.Name)
+		}
+	}
+	return ""
+}
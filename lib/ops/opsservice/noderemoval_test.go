@@ -0,0 +1,174 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type NodeRemovalSuite struct{}
+
+var _ = check.Suite(&NodeRemovalSuite{})
+
+func (s *NodeRemovalSuite) TestSimulatesNodeRemoval(c *check.C) {
+	servers := storage.Servers{
+		newRotationServer("node-1", "10.0.0.1", schema.ServiceRoleMaster),
+		newRotationServer("node-2", "10.0.0.2", schema.ServiceRoleMaster),
+		newRotationServer("node-3", "10.0.0.3", schema.ServiceRoleMaster),
+		newRotationServer("node-4", "10.0.0.4", schema.ServiceRoleNode),
+	}
+	nodes := []v1.Node{
+		newTestNode("10.0.0.1", "4", "8Gi"),
+		newTestNode("10.0.0.2", "4", "8Gi"),
+		newTestNode("10.0.0.3", "4", "8Gi"),
+		newTestNode("10.0.0.4", "2", "4Gi"),
+	}
+	nodes[3].Spec.Unschedulable = true
+	pods := []v1.Pod{
+		newTestPod("web-1", "10.0.0.3", "ReplicaSet", "1", "2Gi"),
+		newTestPod("bare", "10.0.0.3", "", "500m", "1Gi"),
+		newTestPod("agent", "10.0.0.3", "DaemonSet", "100m", "100Mi"),
+		newTestPod("db-1", "10.0.0.1", "StatefulSet", "3", "4Gi"),
+		newTestPod("db-2", "10.0.0.2", "StatefulSet", "3", "7Gi"),
+	}
+	volumes := []v1.PersistentVolume{
+		newTestVolume("pv-1", "10.0.0.3"),
+		newTestVolume("pv-2", "10.0.0.1"),
+	}
+
+	simulation := simulateNodeRemoval(servers[2], servers, nodes, pods, volumes)
+	c.Assert(simulation.Pods, check.DeepEquals, []ops.EvictedPod{
+		{Namespace: "default", Name: "web-1", Controller: "ReplicaSet/web-1"},
+		{Namespace: "default", Name: "bare"},
+	})
+	c.Assert(simulation.Capacity, check.DeepEquals, ops.RemainingCapacity{
+		RequestedCPU:    1000,
+		RequestedMemory: 2 * 1024 * 1024 * 1024,
+		AvailableCPU:    2000,
+		AvailableMemory: 5 * 1024 * 1024 * 1024,
+		Sufficient:      true,
+	})
+	c.Assert(simulation.LocalVolumes, check.DeepEquals, []ops.LocalVolume{{
+		Name:          "pv-1",
+		StorageClass:  "openebs-hostpath",
+		Claim:         "default/pv-1",
+		CapacityBytes: 10 * 1024 * 1024 * 1024,
+	}})
+	c.Assert(simulation.Etcd.Member, check.Equals, true)
+	c.Assert(simulation.Etcd.Members, check.Equals, 3)
+	c.Assert(simulation.Etcd.Endangered, check.Equals, true)
+	c.Assert(simulation.Safe(), check.Equals, false)
+
+	simulation = simulateNodeRemoval(servers[3], servers, nodes, pods, volumes)
+	c.Assert(simulation.Pods, check.HasLen, 0)
+	c.Assert(simulation.LocalVolumes, check.HasLen, 0)
+	c.Assert(simulation.Etcd.Member, check.Equals, false)
+	c.Assert(simulation.Safe(), check.Equals, true)
+}
+
+func (s *NodeRemovalSuite) TestEtcdQuorum(c *check.C) {
+	var servers storage.Servers
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"} {
+		servers = append(servers, newRotationServer(addr, addr, schema.ServiceRoleMaster))
+	}
+	quorum := etcdQuorum(servers[0], servers)
+	c.Assert(quorum.Endangered, check.Equals, false, check.Commentf(quorum.Message))
+	quorum = etcdQuorum(servers[0], servers[:2])
+	c.Assert(quorum.Endangered, check.Equals, true, check.Commentf(quorum.Message))
+	quorum = etcdQuorum(servers[0], servers[:1])
+	c.Assert(quorum.Endangered, check.Equals, true, check.Commentf(quorum.Message))
+}
+
+func newTestNode(name, cpu, memory string) v1.Node {
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"kubernetes.io/hostname": name},
+		},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func newTestPod(name, node, controllerKind, cpu, memory string) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse(cpu),
+						v1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if controllerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			Kind:       controllerKind,
+			Name:       name,
+			Controller: &controller,
+		}}
+	}
+	return pod
+}
+
+func newTestVolume(name, node string) v1.PersistentVolume {
+	return v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{
+				v1.ResourceStorage: resource.MustParse("10Gi"),
+			},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				Local: &v1.LocalVolumeSource{Path: "/var/openebs/local/" + name},
+			},
+			ClaimRef:         &v1.ObjectReference{Namespace: "default", Name: name},
+			StorageClassName: "openebs-hostpath",
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{
+						MatchExpressions: []v1.NodeSelectorRequirement{{
+							Key:      "kubernetes.io/hostname",
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{node},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
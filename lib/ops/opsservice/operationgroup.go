@@ -20,10 +20,9 @@ import (
 	"context"
 	"sync"
 
-	"github.com/gravitational/gravity/lib/defaults"
+	libapp "github.com/gravitational/gravity/lib/app"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/events"
-	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/utils"
 
@@ -68,11 +67,62 @@ func (g *operationGroup) createSiteOperation(operation ops.SiteOperation) (*ops.
 	g.Lock()
 	defer g.Unlock()
 
+	return g.createSiteOperationLocked(operation)
+}
+
+// queueSiteOperation creates the provided operation or, if the operation
+// conflicts with the operations in progress and can be queued, queues it
+// to be started once the conflicting operations have completed.
+// Returns true if the operation has been queued
+func (g *operationGroup) queueSiteOperation(operation ops.SiteOperation) (key *ops.SiteOperationKey, queued bool, err error) {
+	g.Lock()
+	defer g.Unlock()
+
+	conflicts, err := g.getConflictingOperations(operation)
+	if err != nil {
+		return nil, false, trace.Wrap(err)
+	}
+
+	if len(conflicts) == 0 || !ops.IsQueueable(operation.Type) {
+		key, err = g.createSiteOperationLocked(operation)
+		if err != nil {
+			return nil, false, trace.Wrap(err)
+		}
+		return key, false, nil
+	}
+
+	err = g.operator.checkOperationPolicy(g.siteKey, operation)
+	if err != nil {
+		return nil, false, trace.Wrap(err)
+	}
+
+	site, err := g.operator.openSite(g.siteKey)
+	if err != nil {
+		return nil, false, trace.Wrap(err)
+	}
+
+	operation.State = ops.OperationStateQueued
+	op, err := site.createSiteOperation(&operation)
+	if err != nil {
+		return nil, false, trace.Wrap(err)
+	}
+
+	log.Infof("Queued operation %v behind %v.", op, ops.FormatOperations(conflicts))
+	opKey := op.Key()
+	return &opKey, true, nil
+}
+
+func (g *operationGroup) createSiteOperationLocked(operation ops.SiteOperation) (*ops.SiteOperationKey, error) {
 	err := g.canCreateOperation(operation)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	err = g.operator.checkOperationPolicy(g.siteKey, operation)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	site, err := g.operator.openSite(g.siteKey)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -105,7 +155,8 @@ func (g *operationGroup) createSiteOperation(operation ops.SiteOperation) (*ops.
 func (g *operationGroup) emitAuditEvent(ctx context.Context, operation ops.SiteOperation) error {
 	// Audit events for the following operations are emitted by their agents.
 	switch operation.Type {
-	case ops.OperationInstall, ops.OperationUpdate, ops.OperationUpdateConfig, ops.OperationUpdateRuntimeEnviron:
+	case ops.OperationInstall, ops.OperationUpdate, ops.OperationUpdateConfig, ops.OperationUpdateRuntimeEnviron,
+		ops.OperationPatch, ops.OperationUpdateBinary:
 		return nil
 	}
 	// Expand operation start event is emitted by the joining agent.
@@ -125,6 +176,15 @@ func (g *operationGroup) canCreateOperation(operation ops.SiteOperation) error {
 		return trace.Wrap(err)
 	}
 
+	conflicts, err := g.getConflictingOperations(operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if len(conflicts) != 0 {
+		return trace.CompareFailed("%v operation cannot run while %v is in progress",
+			operation.TypeString(), ops.FormatOperations(conflicts))
+	}
+
 	switch operation.Type {
 	case ops.OperationInstall, ops.OperationUninstall:
 		// no special checks for install/uninstall are needed
@@ -153,6 +213,16 @@ func (g *operationGroup) canCreateOperation(operation ops.SiteOperation) error {
 	return nil
 }
 
+// getConflictingOperations returns the operations in progress the provided
+// operation cannot run concurrently with
+func (g *operationGroup) getConflictingOperations(operation ops.SiteOperation) ([]ops.SiteOperation, error) {
+	operations, err := ops.GetActiveOperations(g.siteKey, g.operator)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	return ops.ConflictingOperations(operation, operations), nil
+}
+
 // canCreateExpandOperation runs expand-specific checks
 //
 // In case of failed checks returns trace.CompareFailed error to indicate that
@@ -173,43 +243,55 @@ func (g *operationGroup) canCreateExpandOperation(site ops.Site, operation ops.S
 		return trace.CompareFailed("cannot expand %v cluster", site.State)
 	}
 
-	if len(operations) >= defaults.MaxExpandConcurrency {
+	if len(operations) >= g.operator.cfg.MaxExpandConcurrency {
 		return trace.CompareFailed("at most %v nodes can be joining simultaneously",
-			defaults.MaxExpandConcurrency)
+			g.operator.cfg.MaxExpandConcurrency)
 	}
 
-	// if an expand operation that's adding master node is currently running,
-	// it has to finish before another expand can be started
-	for _, op := range operations {
-		for _, node := range op.Servers {
-			if node.ClusterRole == string(schema.ServiceRoleMaster) {
-				return trace.CompareFailed("can't launch another expand while master node %v is joining",
-					node.AdvertiseIP)
-			}
-		}
+	// regular nodes can be joining concurrently with any other nodes
+	// but only one master node can be joining at a time
+	masters := joiningMasters(operations, "")
+	if len(masters) != 0 && mayJoinAsMaster(operation, len(site.Masters())+len(masters)) {
+		return trace.CompareFailed("can't join master node while master node %v is joining",
+			masters[0].AdvertiseIP)
 	}
 
-	// now check the opposite use-case: if we're about to add a master,
-	// it has to be the only operation running
-	for _, profile := range operation.InstallExpand.Profiles {
-		switch profile.ServiceRole {
-		case string(schema.ServiceRoleMaster):
-			// the joining node wants to be a master
-			return trace.CompareFailed("can't join master node while another node is joining")
-		case "":
-			// cluster role was not set explicitly on the joining node, so
-			// it will be auto-set to master if the max number of masters
-			// haven't been reached yet
-			if len(site.Masters()) < defaults.MaxMasterNodes {
-				return trace.CompareFailed("can't join master node while another node is joining")
-			}
-		}
+	return nil
+}
+
+// assignExpandRoles assigns cluster roles to the servers joining the cluster
+// with the specified expand operation given the number of existing master nodes
+// and saves the servers in the operation.
+//
+// The roles are assigned under the group lock so the master role is reserved
+// atomically: concurrently joining nodes take each other's roles into account
+func (g *operationGroup) assignExpandRoles(operation *ops.SiteOperation, servers []storage.Server, app libapp.Application, masters int) error {
+	g.Lock()
+	defer g.Unlock()
+
+	operations, err := ops.GetActiveOperationsByType(g.siteKey, g.operator, ops.OperationExpand)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
 	}
+	joining := joiningMasters(operations, operation.ID)
 
-	// if we've reached here, we're about to join a regular node, there are
-	// other regular nodes joining right now too and the total number of join
-	// operations is under the maximum
-	return nil
+	err = setClusterRoles(servers, app, masters+len(joining))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if len(joining) != 0 && hasMasters(servers) {
+		return trace.CompareFailed("can't join master node while master node %v is joining",
+			joining[0].AdvertiseIP)
+	}
+
+	site, err := g.operator.openSite(g.siteKey)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operation.Servers = servers
+	_, err = site.updateSiteOperation(operation)
+	return trace.Wrap(err)
 }
 
 // compareAndSwapOperationState changes the operation state according to the provided spec
@@ -275,9 +357,16 @@ func (g *operationGroup) onSiteOperationComplete(key ops.SiteOperationKey) error
 		return trace.Wrap(err)
 	}
 
-	if len(operations) > 0 {
+	var running []ops.SiteOperation
+	for _, op := range operations {
+		if !op.IsQueued() {
+			running = append(running, op)
+		}
+	}
+
+	if len(running) > 0 {
 		log.Debugf("%v more %q operation(-s) in progress for %v: %#v %#v",
-			len(operations), operation.Type, key.SiteDomain, key, operations)
+			len(running), operation.Type, key.SiteDomain, key, running)
 		return nil
 	}
 
@@ -296,6 +385,56 @@ func (g *operationGroup) onSiteOperationComplete(key ops.SiteOperationKey) error
 		return trace.Wrap(err)
 	}
 
+	return trace.Wrap(g.startQueuedOperations())
+}
+
+// startQueuedOperations starts the queued operations that no longer conflict
+// with the operations in progress in the order they have been queued.
+// A queued operation that cannot be started in the current cluster state is failed.
+//
+// Must be called with the group lock held.
+func (g *operationGroup) startQueuedOperations() error {
+	operations, err := g.operator.GetSiteOperations(g.siteKey)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	site, err := g.operator.openSite(g.siteKey)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	// backend returns operations in the last-to-first order
+	for i := len(operations) - 1; i >= 0; i-- {
+		operation := (*ops.SiteOperation)(&operations[i])
+		if !operation.IsQueued() {
+			continue
+		}
+		err := g.canCreateOperation(*operation)
+		if trace.IsCompareFailed(err) {
+			conflicts, _ := g.getConflictingOperations(*operation)
+			if len(conflicts) != 0 {
+				// keep waiting for the conflicting operations
+				continue
+			}
+			// the cluster is not in the state to run the operation
+			log.Warnf("Failing queued operation %v: %v.", operation, err)
+			if err := site.failQueuedOperation(*operation, err); err != nil {
+				return trace.Wrap(err)
+			}
+			continue
+		}
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if err := site.startQueuedOperation(*operation); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := g.emitAuditEvent(context.TODO(), *operation); err != nil {
+			log.WithError(err).Warn("Failed to emit audit event.")
+		}
+	}
+
 	return nil
 }
 
@@ -25,6 +25,7 @@ import (
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
 
+	"github.com/gravitational/trace"
 	"gopkg.in/check.v1"
 )
 
@@ -143,6 +144,109 @@ func (s *OperationGroupSuite) TestExpandMaxConcurrency(c *check.C) {
 	s.assertClusterState(c, ops.SiteStateExpanding)
 }
 
+// Makes sure regular nodes can be joining concurrently with a master node
+// while master nodes join one at a time
+func (s *OperationGroupSuite) TestExpandsOneMasterAtATime(c *check.C) {
+	group := s.operator.getOperationGroup(s.cluster.Key())
+	s.completeInstall(c, group)
+
+	roles := []schema.ServiceRole{schema.ServiceRoleMaster, schema.ServiceRoleNode, schema.ServiceRoleNode}
+	for i, role := range roles {
+		_, err := group.createSiteOperation(s.newExpandOperation(role, storage.Server{
+			Hostname:    fmt.Sprintf("node-%v", i),
+			AdvertiseIP: fmt.Sprintf("10.0.0.%v", i),
+			Role:        "node",
+			ClusterRole: string(role),
+		}))
+		c.Assert(err, check.IsNil)
+		s.assertClusterState(c, ops.SiteStateExpanding)
+	}
+
+	// another master cannot join while a master is joining
+	_, err := group.createSiteOperation(s.newExpandOperation(schema.ServiceRoleMaster))
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+
+	cluster, err := s.operator.openSite(s.cluster.Key())
+	c.Assert(err, check.IsNil)
+	masters, err := cluster.getJoiningMasters("")
+	c.Assert(err, check.IsNil)
+	c.Assert(masters, check.HasLen, 1)
+	c.Assert(masters[0].Hostname, check.Equals, "node-0")
+}
+
+// Makes sure the master role is reserved atomically by the first
+// of the concurrently joining nodes
+func (s *OperationGroupSuite) TestReservesMasterRole(c *check.C) {
+	group := s.operator.getOperationGroup(s.cluster.Key())
+	s.completeInstall(c, group)
+
+	// both nodes have not been assigned roles yet
+	var operations []*ops.SiteOperation
+	for i := 0; i < 2; i++ {
+		key, err := group.createSiteOperation(s.newExpandOperation(schema.ServiceRoleNode))
+		c.Assert(err, check.IsNil)
+		operation, err := s.operator.GetSiteOperation(*key)
+		c.Assert(err, check.IsNil)
+		operations = append(operations, operation)
+	}
+
+	cluster, err := s.operator.openSite(s.cluster.Key())
+	c.Assert(err, check.IsNil)
+
+	servers := []storage.Server{{Hostname: "node-0", AdvertiseIP: "10.0.0.0", Role: "node"}}
+	err = group.assignExpandRoles(operations[0], servers, *cluster.app, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(servers[0].ClusterRole, check.Equals, string(schema.ServiceRoleMaster))
+
+	// the role is saved with the operation
+	operation, err := s.operator.GetSiteOperation(operations[0].Key())
+	c.Assert(err, check.IsNil)
+	c.Assert(operation.Servers, check.DeepEquals, servers)
+
+	// the second node would become a master as well
+	err = group.assignExpandRoles(operations[1],
+		[]storage.Server{{Hostname: "node-1", AdvertiseIP: "10.0.0.1", Role: "node"}}, *cluster.app, 1)
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+
+	// but it joins as a regular node if the masters are complete
+	servers = []storage.Server{{Hostname: "node-1", AdvertiseIP: "10.0.0.1", Role: "node"}}
+	err = group.assignExpandRoles(operations[1], servers, *cluster.app, defaults.MaxMasterNodes-1)
+	c.Assert(err, check.IsNil)
+	c.Assert(servers[0].ClusterRole, check.Equals, string(schema.ServiceRoleNode))
+}
+
+func (s *OperationGroupSuite) completeInstall(c *check.C, group *operationGroup) {
+	key, err := group.createSiteOperation(ops.SiteOperation{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Type:       ops.OperationInstall,
+		State:      ops.OperationStateInstallInitiated,
+	})
+	c.Assert(err, check.IsNil)
+	_, err = group.compareAndSwapOperationState(swap{
+		key:            *key,
+		expectedStates: []string{ops.OperationStateInstallInitiated},
+		newOpState:     ops.OperationStateCompleted,
+	})
+	c.Assert(err, check.IsNil)
+	s.assertClusterState(c, ops.SiteStateActive)
+}
+
+func (s *OperationGroupSuite) newExpandOperation(role schema.ServiceRole, servers ...storage.Server) ops.SiteOperation {
+	return ops.SiteOperation{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Type:       ops.OperationExpand,
+		State:      ops.OperationStateExpandInitiated,
+		InstallExpand: &storage.InstallExpandOperationState{
+			Profiles: map[string]storage.ServerProfile{
+				"node": {ServiceRole: string(role)},
+			},
+		},
+		Servers: servers,
+	}
+}
+
 // Makes sure cannot expand shrinking cluster
 func (s *OperationGroupSuite) TestFailsToExpandShrinkingCluster(c *check.C) {
 	group := s.operator.getOperationGroup(s.cluster.Key())
@@ -258,6 +362,60 @@ func (s *OperationGroupSuite) TestMultiExpandClusterState(c *check.C) {
 	s.assertClusterState(c, ops.SiteStateActive)
 }
 
+// Makes sure conflicting operations are rejected or queued
+func (s *OperationGroupSuite) TestQueuesConflictingOperations(c *check.C) {
+	group := s.operator.getOperationGroup(s.cluster.Key())
+
+	// initiate and finalize the install operation
+	key, err := group.createSiteOperation(ops.SiteOperation{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Type:       ops.OperationInstall,
+		State:      ops.OperationStateInstallInitiated,
+	})
+	c.Assert(err, check.IsNil)
+	_, err = group.compareAndSwapOperationState(swap{
+		key:            *key,
+		expectedStates: []string{ops.OperationStateInstallInitiated},
+		newOpState:     ops.OperationStateCompleted,
+	})
+	c.Assert(err, check.IsNil)
+
+	// create update operation
+	_, err = group.createSiteOperation(ops.SiteOperation{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Type:       ops.OperationUpdate,
+		State:      ops.OperationStateUpdateInProgress,
+	})
+	c.Assert(err, check.IsNil)
+	s.assertClusterState(c, ops.SiteStateUpdating)
+
+	// garbage collection cannot be queued and should be rejected
+	_, err = group.createSiteOperation(ops.SiteOperation{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Type:       ops.OperationGarbageCollect,
+		State:      ops.OperationGarbageCollectInProgress,
+	})
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+
+	// shrink should be queued behind the update
+	key, queued, err := group.queueSiteOperation(ops.SiteOperation{
+		AccountID:  s.cluster.AccountID,
+		SiteDomain: s.cluster.Domain,
+		Type:       ops.OperationShrink,
+		State:      ops.OperationStateShrinkInProgress,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(queued, check.Equals, true)
+	s.assertClusterState(c, ops.SiteStateUpdating)
+
+	operation, err := s.operator.GetSiteOperation(*key)
+	c.Assert(err, check.IsNil)
+	c.Assert(operation.IsQueued(), check.Equals, true)
+}
+
 // Makes sure operations that modify cluster state servers behave correctly
 func (s *OperationGroupSuite) TestClusterStateModifications(c *check.C) {
 	group := s.operator.getOperationGroup(s.cluster.Key())
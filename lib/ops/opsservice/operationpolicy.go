@@ -0,0 +1,270 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/opa"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/events"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// GetOperationPolicy returns the cluster operation policy
+func (o *Operator) GetOperationPolicy(key ops.SiteKey) (storage.OperationPolicy, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return getOperationPolicy(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace))
+}
+
+// UpdateOperationPolicy updates the cluster operation policy
+func (o *Operator) UpdateOperationPolicy(ctx context.Context, key ops.SiteKey, policy storage.OperationPolicy) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if rego := policy.GetRego(); rego != nil {
+		// The server compiles the module and rejects it if it is invalid
+		client, err := newOPAClient(*rego)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = client.UpsertPolicy(ctx, defaults.OperationPolicyModule, rego.Module)
+		if err != nil {
+			return trace.Wrap(err, "failed to load rego policy")
+		}
+	}
+	data, err := storage.MarshalOperationPolicy(policy)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return updateConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.OperationPolicyConfigMap, defaults.KubeSystemNamespace, string(data), nil)
+}
+
+// DeleteOperationPolicy deletes the cluster operation policy
+func (o *Operator) DeleteOperationPolicy(ctx context.Context, key ops.SiteKey) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	policy, err := getOperationPolicy(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace))
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	if policy != nil && policy.GetRego() != nil {
+		o.unloadRegoPolicy(ctx, *policy.GetRego())
+	}
+	err = rigging.ConvertError(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+		Delete(constants.OperationPolicyConfigMap, &metav1.DeleteOptions{}))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("no operation policy found")
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// checkOperationPolicy verifies the specified operation against the cluster
+// operation policy. Any operation is allowed if no policy has been configured.
+//
+// If the policy requires the operation to be approved by another user and it
+// has not been approved yet, a new approval request is recorded and the
+// returned error refers to it.
+//
+// Like admission, the policy is only enforced by the cluster's own gravity-site
+func (o *Operator) checkOperationPolicy(key ops.SiteKey, operation ops.SiteOperation) error {
+	if !o.cfg.Local {
+		return nil
+	}
+	policy, err := o.GetOperationPolicy(key)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	var rule *storage.OperationPolicyRule
+	if rego := policy.GetRego(); rego != nil {
+		rule, err = checkRegoPolicy(*rego, operation, o.clock().UtcNow())
+	} else {
+		rule, err = ops.CheckOperationPolicy(policy, operation, o.clock().UtcNow())
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(o.checkOperationApproval(policy, operation, rule))
+}
+
+// checkRegoPolicy evaluates the specified operation against the Rego policy.
+// Operations are denied if the Open Policy Agent server cannot be reached
+func checkRegoPolicy(rego storage.OperationPolicyRego, operation ops.SiteOperation, now time.Time) (*storage.OperationPolicyRule, error) {
+	client, err := newOPAClient(rego)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	input := ops.NewOperationPolicyInput(operation, now)
+	var decision storage.OperationPolicyRegoDecision
+	err = client.Evaluate(context.TODO(), rego.Decision, input, &decision)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			// No decision means the operation is allowed
+			return nil, nil
+		}
+		return nil, trace.Wrap(err, "failed to evaluate rego operation policy")
+	}
+	return ops.CheckRegoDecision(input, decision)
+}
+
+// unloadRegoPolicy removes the Rego policy module from the Open Policy Agent server
+func (o *Operator) unloadRegoPolicy(ctx context.Context, rego storage.OperationPolicyRego) {
+	client, err := newOPAClient(rego)
+	if err == nil {
+		err = client.DeletePolicy(ctx, defaults.OperationPolicyModule)
+	}
+	if err != nil && !trace.IsNotFound(err) {
+		o.WithError(err).Warn("Failed to remove rego policy from Open Policy Agent server.")
+	}
+}
+
+func newOPAClient(rego storage.OperationPolicyRego) (*opa.Client, error) {
+	return opa.New(opa.Config{
+		URL:    rego.URL,
+		CACert: rego.CACert,
+	})
+}
+
+// checkOperationApproval returns an error if the specified operation requires
+// approval but has not been approved yet. An approval is consumed by the first
+// operation it applies to.
+// rule is the matching policy rule that requires approval, if any
+func (o *Operator) checkOperationApproval(policy storage.OperationPolicy, operation ops.SiteOperation, rule *storage.OperationPolicyRule) error {
+	request := ops.NewOperationApproval(policy, operation, rule)
+	if request == nil {
+		return nil
+	}
+	approvals, err := o.backend().GetOperationApprovals(request.ClusterName)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, approval := range approvals {
+		if !approval.Matches(*request) {
+			continue
+		}
+		if !approval.IsApproved() {
+			return approvalRequiredError(approval)
+		}
+		o.Infof("Operation %v has been approved by %v.", approval, approval.ApprovedBy)
+		return trace.Wrap(o.backend().DeleteOperationApproval(approval.ID))
+	}
+	now := o.clock().UtcNow()
+	request.ID = uuid.New()
+	request.Created = now
+	ttl := defaults.OperationApprovalTTL
+	if requirements := policy.GetApproval(); requirements != nil {
+		ttl = requirements.TTL.Duration
+	}
+	request.Expires = now.Add(ttl)
+	approval, err := o.backend().CreateOperationApproval(*request)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	events.Emit(context.TODO(), o, events.OperationApprovalRequested,
+		approvalEventFields(*approval).WithField(events.FieldUser, approval.RequestedBy))
+	return approvalRequiredError(*approval)
+}
+
+// ApproveOperation approves the operation with the specified approval request.
+// The operation cannot be approved by the user who requested it
+func (o *Operator) ApproveOperation(ctx context.Context, req ops.ApproveOperationRequest) (*storage.OperationApproval, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	approval, err := o.backend().GetOperationApproval(req.ApprovalID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if approval.ClusterName != req.SiteDomain {
+		return nil, trace.NotFound("approval request %v not found", req.ApprovalID)
+	}
+	if approval.IsApproved() {
+		return nil, trace.AlreadyExists("operation %v has already been approved by %v",
+			approval, approval.ApprovedBy)
+	}
+	user := storage.UserFromContext(ctx)
+	if user == "" {
+		return nil, trace.AccessDenied("operation can only be approved by an authenticated user")
+	}
+	if user == approval.RequestedBy {
+		return nil, trace.AccessDenied("operation cannot be approved by the user who requested it")
+	}
+	approval.ApprovedBy = user
+	approval.Approved = o.clock().UtcNow()
+	approval, err = o.backend().UpdateOperationApproval(*approval)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	events.Emit(ctx, o, events.OperationApproved, approvalEventFields(*approval))
+	return approval, nil
+}
+
+func approvalRequiredError(approval storage.OperationApproval) error {
+	return trace.AccessDenied("%v requires approval by another user, "+
+		"ask them to run \"gravity operation approve %v\" before %v and retry",
+		approval, approval.ID, approval.Expires.Format(constants.HumanDateFormatSeconds))
+}
+
+func approvalEventFields(approval storage.OperationApproval) events.Fields {
+	fields := events.Fields{
+		events.FieldApprovalID:    approval.ID,
+		events.FieldOperationType: approval.Operation,
+		events.FieldCluster:       approval.ClusterName,
+		events.FieldRequestedBy:   approval.RequestedBy,
+	}
+	if len(approval.Servers) != 0 {
+		fields[events.FieldNodeHostname] = strings.Join(approval.Servers, ",")
+	}
+	if approval.Rule != "" {
+		fields[events.FieldPolicyRule] = approval.Rule
+	}
+	return fields
+}
+
+func getOperationPolicy(client corev1.ConfigMapInterface) (storage.OperationPolicy, error) {
+	data, err := getConfigMap(client, constants.OperationPolicyConfigMap)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("no operation policy found")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return storage.UnmarshalOperationPolicy([]byte(data))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/users"
+
+	"github.com/gravitational/trace"
+)
+
+// CreateOperationToken issues a new token granting access to the plan
+// and progress of the specified operation
+func (o *Operator) CreateOperationToken(ctx context.Context, key ops.SiteOperationKey) (*storage.OperationToken, error) {
+	if _, err := o.GetSiteOperation(key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	tokenID, err := users.CryptoRandomToken(defaults.OperationTokenBytes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	now := o.clock().UtcNow()
+	token, err := o.backend().CreateOperationToken(storage.OperationToken{
+		Token:       tokenID,
+		AccountID:   key.AccountID,
+		SiteDomain:  key.SiteDomain,
+		OperationID: key.OperationID,
+		Created:     now,
+		Expires:     now.Add(defaults.OperationTokenTTL),
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return token, nil
+}
+
+// revokeOperationTokens deletes all tokens issued for the specified operation
+func (o *Operator) revokeOperationTokens(key ops.SiteOperationKey) error {
+	tokens, err := o.backend().GetOperationTokens(key.SiteDomain, key.OperationID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, token := range tokens {
+		err := o.backend().DeleteOperationToken(token.Token)
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
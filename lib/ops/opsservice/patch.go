@@ -0,0 +1,69 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+)
+
+// CreatePatchOperation creates a new operation to patch the operating
+// system on cluster nodes
+func (o *Operator) CreatePatchOperation(ctx context.Context, r ops.CreatePatchOperationRequest) (*ops.SiteOperationKey, error) {
+	err := r.Check()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.openSite(r.ClusterKey)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	key, err := cluster.createPatchOperation(ctx, r)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+// createPatchOperation creates a new operation to patch the operating
+// system on cluster nodes
+func (s *site) createPatchOperation(ctx context.Context, req ops.CreatePatchOperationRequest) (*ops.SiteOperationKey, error) {
+	op := ops.SiteOperation{
+		ID:         uuid.New(),
+		AccountID:  s.key.AccountID,
+		SiteDomain: s.key.SiteDomain,
+		Type:       ops.OperationPatch,
+		Created:    s.clock().UtcNow(),
+		CreatedBy:  storage.UserFromContext(ctx),
+		Updated:    s.clock().UtcNow(),
+		State:      ops.OperationPatchInProgress,
+		Patch: &storage.PatchOperationState{
+			Command: req.Command,
+			Reboot:  req.Reboot,
+		},
+	}
+	key, err := s.getOperationGroup().createSiteOperation(op)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
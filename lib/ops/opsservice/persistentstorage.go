@@ -0,0 +1,163 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/openebs"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	"github.com/gravitational/trace"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetPersistentStorage returns the cluster persistent storage configuration
+func (o *Operator) GetPersistentStorage(key ops.SiteKey) (storage.PersistentStorage, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	data, err := getConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.PersistentStorageConfigMap)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("no persistent storage configuration found")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return storage.UnmarshalPersistentStorage([]byte(data))
+}
+
+// UpdatePersistentStorage validates the persistent storage configuration
+// against the block devices of cluster nodes and updates it.
+// Unless forced, the update is refused if the configuration fails validation
+func (o *Operator) UpdatePersistentStorage(ctx context.Context, req ops.UpdatePersistentStorageRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := o.openSite(req.SiteKey)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !req.Force {
+		err := cluster.checkPersistentStorage(ctx, client, req.Resource)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	err = o.admit(ctx, req.SiteKey, storage.KindPersistentStorage, req.Resource.GetName(), req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	data, err := storage.MarshalPersistentStorage(req.Resource)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return updateConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.PersistentStorageConfigMap, defaults.KubeSystemNamespace, string(data), nil)
+}
+
+// checkPersistentStorage validates the specified persistent storage configuration
+// against the block devices of all cluster nodes and the block devices
+// claimed by OpenEBS
+func (s *site) checkPersistentStorage(ctx context.Context, client kubernetes.Interface, config storage.PersistentStorage) error {
+	claimed, err := getClaimedBlockDevices(client)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	nodes, err := s.getClusterBlockDevices(ctx, ops.NewDeviceFilter(config))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return ops.CheckPersistentStorage(config, nodes, claimed)
+}
+
+// getClusterBlockDevices lists block devices of all cluster nodes annotated
+// with the results of the specified device filter.
+// Nodes that fail to respond are reported with an error
+func (s *site) getClusterBlockDevices(ctx context.Context, filter systeminfo.DeviceFilter) ([]ops.NodeBlockDevices, error) {
+	cluster, err := s.backend().GetSite(s.domainName)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var mu sync.Mutex
+	devices := make(map[string]ops.NodeBlockDevices)
+	err = s.executeOnTeleportServers(ctx, func(ctx context.Context, runner *serverRunner) error {
+		node := ops.NodeBlockDevices{Hostname: runner.server.HostName()}
+		var err error
+		node.Devices, err = s.getNodeBlockDevices(runner, filter)
+		if err != nil {
+			node.Error = trace.UserMessage(err)
+		}
+		mu.Lock()
+		devices[runner.server.(*teleportServer).IP] = node
+		mu.Unlock()
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		s.WithError(err).Warn("Failed to collect block devices from some nodes.")
+	}
+	nodes := make([]ops.NodeBlockDevices, 0, len(cluster.ClusterState.Servers))
+	for _, server := range cluster.ClusterState.Servers {
+		node, ok := devices[server.AdvertiseIP]
+		if !ok {
+			node = ops.NodeBlockDevices{
+				Hostname: server.Hostname,
+				Error:    "block devices not collected",
+			}
+		}
+		node.AdvertiseIP = server.AdvertiseIP
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// getClaimedBlockDevices returns the block devices claimed by OpenEBS
+// block device claims.
+// Returns an empty list if OpenEBS is not installed in the cluster
+func getClaimedBlockDevices(client kubernetes.Interface) ([]ops.ClaimedDevice, error) {
+	devices, err := openebs.ListBlockDevices(client)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	var claimed []ops.ClaimedDevice
+	for _, device := range devices {
+		if !device.Claimed {
+			continue
+		}
+		claimed = append(claimed, ops.ClaimedDevice{
+			Name:   device.Name,
+			Node:   device.Node,
+			Path:   device.Path,
+			Vendor: device.Vendor,
+		})
+	}
+	return claimed, nil
+}
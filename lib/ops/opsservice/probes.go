@@ -0,0 +1,43 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/status"
+
+	"github.com/gravitational/trace"
+)
+
+// GetClusterProbes returns the last results of the health probes on cluster nodes
+func (o *Operator) GetClusterProbes(key ops.SiteKey) (*ops.ClusterProbes, error) {
+	cluster, err := o.backend().GetSite(key.SiteDomain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client, err := status.NewAgentClient(false)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	probes, err := client.Probes(context.TODO(), cluster.ClusterState.Servers)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return probes, nil
+}
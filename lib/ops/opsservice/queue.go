@@ -0,0 +1,87 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+// startQueuedOperation moves the queued operation into its in-progress state
+// and starts executing it
+func (s *site) startQueuedOperation(operation ops.SiteOperation) error {
+	var state string
+	var fn func(ctx *operationContext) error
+	switch operation.Type {
+	case ops.OperationShrink:
+		state = ops.OperationStateShrinkInProgress
+		fn = s.shrinkOperationStart
+	default:
+		return trace.BadParameter("%v operation cannot be queued", operation.TypeString())
+	}
+
+	_, err := s.setOperationState(operation.Key(), state)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	operation.State = state
+	clusterState, err := operation.ClusterState()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = s.setSiteState(clusterState)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = s.backend().CreateProgressEntry(storage.ProgressEntry{
+		SiteDomain:  s.key.SiteDomain,
+		OperationID: operation.ID,
+		Created:     s.clock().UtcNow(),
+		State:       ops.ProgressStateInProgress,
+		Message:     "initializing the operation",
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	return trace.Wrap(s.executeOperation(operation.Key(), fn))
+}
+
+// failQueuedOperation fails the queued operation that cannot be started
+// with the specified error
+func (s *site) failQueuedOperation(operation ops.SiteOperation, opErr error) error {
+	_, err := s.setOperationState(operation.Key(), ops.OperationStateFailed)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	_, err = s.backend().CreateProgressEntry(storage.ProgressEntry{
+		SiteDomain:  s.key.SiteDomain,
+		OperationID: operation.ID,
+		Created:     s.clock().UtcNow(),
+		State:       ops.ProgressStateFailed,
+		Completion:  constants.Completed,
+		Message:     trace.UserMessage(opErr),
+	})
+	return trace.Wrap(err)
+}
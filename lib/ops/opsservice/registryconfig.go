@@ -0,0 +1,78 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetRegistryConfig returns the cluster registry configuration
+func (o *Operator) GetRegistryConfig(key ops.SiteKey) (storage.RegistryConfig, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	data, err := getConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.RegistryConfigConfigMap)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("no registry configuration found")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return storage.UnmarshalRegistryConfig([]byte(data))
+}
+
+// UpdateRegistryConfig updates the cluster registry configuration
+func (o *Operator) UpdateRegistryConfig(ctx context.Context, key ops.SiteKey, config storage.RegistryConfig) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	data, err := storage.MarshalRegistryConfig(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return updateConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace),
+		constants.RegistryConfigConfigMap, defaults.KubeSystemNamespace, string(data), nil)
+}
+
+// DeleteRegistryConfig resets the cluster registry configuration to defaults
+func (o *Operator) DeleteRegistryConfig(ctx context.Context, key ops.SiteKey) error {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = rigging.ConvertError(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+		Delete(constants.RegistryConfigConfigMap, &metav1.DeleteOptions{}))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("no registry configuration found")
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
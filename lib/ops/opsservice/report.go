@@ -25,12 +25,13 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/gravitational/gravity/lib/app"
 	"github.com/gravitational/gravity/lib/archive"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/fsm"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/report"
 	"github.com/gravitational/gravity/lib/schema"
@@ -121,14 +122,19 @@ func (s *site) getReport(runner remoteRunner, servers []remoteServer, master rem
 		return nil, trace.Wrap(err)
 	}
 
-	err = runCollectors(*s, dir)
+	// limit the size of the collected files to keep the report manageable
+	reportWriter := report.NewLimitedFileWriter(report.NewFileWriter(dir),
+		defaults.ReportMaxFileSize, defaults.ReportMaxSize)
+
+	err = runCollectors(*s, reportWriter)
 	if err != nil {
 		// Intermediate steps in diagnostics collection are not fatal
 		// to collect all possible pieces in best-effort
 		log.Errorf("failed to run cluster collectors: %v", trace.DebugReport(err))
 	}
 
-	collectOperationsLogs(*s, dir)
+	collectOperationsLogs(*s, reportWriter)
+	collectOperationPlans(*s, reportWriter)
 
 	if len(servers) > 0 {
 		// Use the first master server to collect kubernetes diagnostics
@@ -138,18 +144,22 @@ func (s *site) getReport(runner remoteRunner, servers []remoteServer, master rem
 			log.Warningf("No master servers, collecting Kubernetes diagnostics from %v.", server)
 		}
 		serverRunner := &serverRunner{server: server, runner: runner}
-		reportWriter := getReportWriterForServer(dir, server)
-		if err := s.collectKubernetesInfo(reportWriter, serverRunner); err != nil {
+		serverWriter := getReportWriterForServer(reportWriter, server)
+		if err := s.collectKubernetesInfo(serverWriter, serverRunner); err != nil {
 			log.WithError(err).Error("Failed to collect Kubernetes info.")
 		}
-		if err := s.collectEtcdBackup(reportWriter, serverRunner); err != nil {
+		if err := s.collectEtcdBackup(serverWriter, serverRunner); err != nil {
 			log.WithError(err).Error("Failed to collect etcd backup.")
 		}
-		if err := s.collectDebugInfoFromServers(dir, servers, runner); err != nil {
+		if err := s.collectDebugInfoFromServers(reportWriter, servers, runner); err != nil {
 			log.WithError(err).Error("Failed to collect diagnostics from some nodes.")
 		}
 	}
 
+	if err := writeTruncatedFiles(dir, reportWriter.Truncated()); err != nil {
+		log.WithError(err).Warn("Failed to record truncated files.")
+	}
+
 	// use a pipe to avoid allocating a buffer
 	reader, writer := io.Pipe()
 	gzWriter := gzip.NewWriter(writer)
@@ -170,20 +180,19 @@ func (s *site) getReport(runner remoteRunner, servers []remoteServer, master rem
 }
 
 // collectDebugInfoFromServers collects diagnostic information from servers
-// and stores each piece into a file in directory dir.
+// and stores each piece into a file using the specified writer.
 // Files are named using the following pattern:
 //
 //   <server-name>-<resource>
 //
-func (s *site) collectDebugInfoFromServers(dir string, servers []remoteServer, runner remoteRunner) error {
+func (s *site) collectDebugInfoFromServers(reportWriter report.FileWriter, servers []remoteServer, runner remoteRunner) error {
 	err := s.executeOnServers(context.TODO(), servers, func(c context.Context, server remoteServer) error {
 		log.Debugf("collectDebugInfo for %v", server)
 		r := &serverRunner{
 			server: server,
 			runner: runner,
 		}
-		reportWriter := getReportWriterForServer(dir, server)
-		err := s.collectDebugInfo(reportWriter, r)
+		err := s.collectDebugInfo(getReportWriterForServer(reportWriter, server), r)
 		return trace.Wrap(err)
 	})
 	if err != nil {
@@ -237,7 +246,7 @@ func (s *site) collectEtcdBackup(reportWriter report.FileWriter, runner *serverR
 	return nil
 }
 
-func runCollectors(site site, dir string) error {
+func runCollectors(site site, reportWriter report.FileWriter) error {
 	storageSite, err := site.service.cfg.Backend.GetSite(site.domainName)
 	if err != nil {
 		return trace.Wrap(err)
@@ -247,7 +256,6 @@ func runCollectors(site site, dir string) error {
 		collectSiteInfo(*storageSite),
 		collectDumpHook,
 	}
-	reportWriter := report.NewFileWriter(dir)
 
 	// collect information from all collectors
 	for _, collector := range collectors {
@@ -259,14 +267,12 @@ func runCollectors(site site, dir string) error {
 	return nil
 }
 
-func collectOperationsLogs(site site, dir string) error {
+func collectOperationsLogs(site site, reportWriter report.FileWriter) error {
 	operations, err := site.service.GetSiteOperations(site.key)
 	if err != nil {
 		return trace.Wrap(err, "failed to get cluster operations")
 	}
 
-	reportWriter := report.NewFileWriter(dir)
-
 	for _, op := range operations {
 		operation := ops.SiteOperation(op)
 		err = collectOperationLogs(site, operation, reportWriter)
@@ -339,15 +345,117 @@ func collectOperationLogs(site site, operation ops.SiteOperation, reportWriter r
 	return trace.Wrap(err)
 }
 
+// collectOperationPlans stores the plans of the cluster operations along with
+// the captured phase execution logs
+func collectOperationPlans(site site, reportWriter report.FileWriter) error {
+	operations, err := site.service.GetSiteOperations(site.key)
+	if err != nil {
+		return trace.Wrap(err, "failed to get cluster operations")
+	}
+
+	for _, op := range operations {
+		operation := ops.SiteOperation(op)
+		err = collectOperationPlan(site, operation, reportWriter)
+		if err != nil && !trace.IsNotFound(err) {
+			log.Errorf("failed to collect plan for %q: %v", op.Type, trace.DebugReport(err))
+		}
+	}
+	return nil
+}
+
+// collectOperationPlan stores the plan of the specified operation in JSON format.
+// Phase execution logs are stored in separate files
+func collectOperationPlan(site site, operation ops.SiteOperation, reportWriter report.FileWriter) error {
+	plan, err := site.service.GetOperationPlan(operation.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	for _, phase := range fsm.FlattenPlan(plan) {
+		if phase.Checkpoint == nil || len(phase.Checkpoint.Logs) == 0 {
+			continue
+		}
+		logs, err := fsm.DecompressLogs(phase.Checkpoint.Logs)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to decompress logs of phase %v.", phase.ID)
+		} else {
+			err = writeReportFile(reportWriter, fmt.Sprintf(phaseLogsFilename,
+				operation.Type, operation.ID, phaseFilename(phase.ID)), logs)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+		}
+		// logs are stored separately
+		phase.Checkpoint.Logs = nil
+	}
+
+	bytes, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return writeReportFile(reportWriter, fmt.Sprintf(planFilename, operation.Type, operation.ID), bytes)
+}
+
+// writeTruncatedFiles records the names of the files truncated
+// due to the report size limits into the report directory dir
+func writeTruncatedFiles(dir string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	log.Warnf("Files truncated due to the report size limits: %v.", names)
+	return writeReportFile(report.NewFileWriter(dir), truncatedFilesFilename,
+		[]byte(strings.Join(names, "\n")+"\n"))
+}
+
+func writeReportFile(reportWriter report.FileWriter, name string, data []byte) error {
+	w, err := reportWriter.NewWriter(name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer w.Close()
+	_, err = w.Write(data)
+	return trace.Wrap(err)
+}
+
+// phaseFilename converts the specified phase ID into a file name,
+// e.g. /masters/node-1/drain becomes masters-node-1-drain
+func phaseFilename(phaseID string) string {
+	return strings.Replace(strings.Trim(phaseID, "/"), "/", "-", -1)
+}
+
 type collectorFn func(report.FileWriter, site) error
 
-func getReportWriterForServer(dir string, server remoteServer) report.FileWriter {
+func getReportWriterForServer(reportWriter report.FileWriter, server remoteServer) report.FileWriter {
 	return report.FileWriterFunc(func(name string) (io.WriteCloser, error) {
-		fileName := filepath.Join(dir, fmt.Sprintf("%s-%s", server.HostName(), name))
-		return report.NewPendingFileWriter(fileName), nil
+		return reportWriter.NewWriter(fmt.Sprintf("%s-%s", server.HostName(), name))
 	})
 }
 
+// uploadReport stores the report read from reader at the specified path.
+// Returns an error if the report is larger than maxSize bytes
+func uploadReport(path string, reader io.Reader, maxSize int64) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, defaults.PrivateFileMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	n, err := io.Copy(f, io.LimitReader(reader, maxSize+1))
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	if n > maxSize {
+		return trace.LimitExceeded("report exceeds the maximum size of %v bytes", maxSize)
+	}
+	return nil
+}
+
 func isActiveInstallOperation(op ops.SiteOperation) bool {
 	return op.Type == ops.OperationInstall && !op.IsCompleted()
 }
@@ -360,4 +468,17 @@ const (
 	// opLogsFilename defines the file pattern that stores operation log for a particular
 	// cluster operation
 	opLogsFilename = "%v.%v"
+	// planFilename defines the file pattern that stores the plan of a particular
+	// cluster operation
+	planFilename = "%v.%v.plan.json"
+	// phaseLogsFilename defines the file pattern that stores the execution logs
+	// of a particular operation phase
+	phaseLogsFilename = "%v.%v.phase-%v.log"
+	// truncatedFilesFilename is the name of the file that lists the files
+	// truncated due to the report size limits
+	truncatedFilesFilename = "truncated-files"
+	// reportsDir is the cluster state subdirectory with the uploaded reports
+	reportsDir = "reports"
+	// reportTimestampFormat is the timestamp format in the names of the uploaded reports
+	reportTimestampFormat = "20060102-150405"
 )
@@ -0,0 +1,244 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/storage/clusterconfig"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/cenkalti/backoff"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/gravitational/rigging"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// PatchClusterConfiguration applies the JSON merge patch to the cluster configuration.
+//
+// The patched configuration is not stored directly: instead, the patch creates
+// the same update-config operation as the full configuration update.
+// The operation is returned in the response and applies the configuration
+// once it is executed on one of the master nodes
+func (o *Operator) PatchClusterConfiguration(ctx context.Context, req ops.PatchResourceRequest) (*ops.PatchResourceResponse, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	configmap, err := getOrCreateClusterConfigMap(client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	resource, err := patchClusterConfiguration(configmap, req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// The configuration can only change with an update-config operation and
+	// the operation is refused if another operation is in progress, so the
+	// version check above cannot be invalidated by a concurrent update
+	key, err := o.CreateUpdateConfigOperation(ctx, ops.CreateUpdateConfigOperationRequest{
+		ClusterKey: req.SiteKey,
+		Config:     resource,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &ops.PatchResourceResponse{
+		Resource:        resource,
+		ResourceVersion: configmap.ResourceVersion,
+		Operation:       key,
+	}, nil
+}
+
+// patchClusterConfiguration applies the JSON merge patch from the specified
+// request to the cluster configuration stored in the given config map.
+// Returns the patched configuration resource
+func patchClusterConfiguration(configmap *v1.ConfigMap, req ops.PatchResourceRequest) ([]byte, error) {
+	if req.ResourceVersion != "" && req.ResourceVersion != configmap.ResourceVersion {
+		return nil, trace.CompareFailed(
+			"resource has been modified: expected version %v, current version %v",
+			req.ResourceVersion, configmap.ResourceVersion)
+	}
+	current, err := currentClusterConfiguration(configmap)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	patched, err := jsonpatch.MergePatch(current, req.Patch)
+	if err != nil {
+		return nil, trace.BadParameter("failed to apply patch: %v", err)
+	}
+	config, err := clusterconfig.Unmarshal(patched)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	resource, err := clusterconfig.Marshal(config)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return resource, nil
+}
+
+// currentClusterConfiguration returns the JSON representation of the cluster
+// configuration stored in the specified config map
+func currentClusterConfiguration(configmap *v1.ConfigMap) ([]byte, error) {
+	spec := configmap.Data["spec"]
+	if len(spec) == 0 {
+		return clusterconfig.Marshal(clusterconfig.NewEmpty())
+	}
+	return teleutils.ToJSON([]byte(spec))
+}
+
+// PatchPersistentStorage applies the JSON merge patch to the cluster persistent
+// storage configuration.
+// Unless forced, the patch is refused if the resulting configuration fails
+// validation against the block devices of cluster nodes
+func (o *Operator) PatchPersistentStorage(ctx context.Context, req ops.PatchResourceRequest) (*ops.PatchResourceResponse, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.openSite(req.SiteKey)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	configmaps := client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace)
+	return patchConfigMap(ctx, configmaps, req, configMapPatcher{
+		get: func() (*v1.ConfigMap, error) {
+			configmap, err := configmaps.Get(constants.PersistentStorageConfigMap, metav1.GetOptions{})
+			if err != nil {
+				err = rigging.ConvertError(err)
+				if trace.IsNotFound(err) {
+					return nil, trace.NotFound("no persistent storage configuration found")
+				}
+				return nil, trace.Wrap(err)
+			}
+			return configmap, nil
+		},
+		current: func(configmap *v1.ConfigMap) ([]byte, error) {
+			spec, ok := configmap.Data[constants.ResourceSpecKey]
+			if !ok {
+				return nil, trace.NotFound("no persistent storage configuration found")
+			}
+			return teleutils.ToJSON([]byte(spec))
+		},
+		apply: func(configmap *v1.ConfigMap, data []byte) ([]byte, error) {
+			config, err := storage.UnmarshalPersistentStorage(data)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			if !req.Force {
+				err := cluster.checkPersistentStorage(ctx, client, config)
+				if err != nil {
+					return nil, trace.Wrap(err)
+				}
+			}
+			err = o.admit(ctx, req.SiteKey, storage.KindPersistentStorage, config.GetName(), config)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			resource, err := storage.MarshalPersistentStorage(config)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			configmap.Data[constants.ResourceSpecKey] = string(resource)
+			return resource, nil
+		},
+	})
+}
+
+// patchConfigMap applies the JSON merge patch from the specified request
+// to the resource stored in a config map.
+//
+// If the request specifies the resource version, the patch is only applied
+// if the config map has not been modified since. Otherwise, the patch is
+// re-applied to the latest version of the resource if the config map
+// is modified concurrently
+func patchConfigMap(ctx context.Context, configmaps corev1.ConfigMapInterface, req ops.PatchResourceRequest, patcher configMapPatcher) (resp *ops.PatchResourceResponse, err error) {
+	err = utils.RetryWithInterval(ctx, backoff.NewExponentialBackOff(), func() error {
+		configmap, err := patcher.get()
+		if err != nil {
+			return &backoff.PermanentError{Err: err}
+		}
+		if req.ResourceVersion != "" && req.ResourceVersion != configmap.ResourceVersion {
+			return &backoff.PermanentError{Err: trace.CompareFailed(
+				"resource has been modified: expected version %v, current version %v",
+				req.ResourceVersion, configmap.ResourceVersion)}
+		}
+		current, err := patcher.current(configmap)
+		if err != nil {
+			return &backoff.PermanentError{Err: err}
+		}
+		patched, err := jsonpatch.MergePatch(current, req.Patch)
+		if err != nil {
+			return &backoff.PermanentError{Err: trace.BadParameter(
+				"failed to apply patch: %v", err)}
+		}
+		resource, err := patcher.apply(configmap, patched)
+		if err != nil {
+			return &backoff.PermanentError{Err: err}
+		}
+		// The update is rejected by the API server if the config map
+		// has been modified since it has been read
+		updated, err := configmaps.Update(configmap)
+		if err != nil {
+			if !errors.IsConflict(err) {
+				return &backoff.PermanentError{Err: rigging.ConvertError(err)}
+			}
+			if req.ResourceVersion != "" {
+				return &backoff.PermanentError{Err: trace.CompareFailed(
+					"resource has been modified: expected version %v", req.ResourceVersion)}
+			}
+			return trace.Wrap(rigging.ConvertError(err))
+		}
+		resp = &ops.PatchResourceResponse{
+			Resource:        resource,
+			ResourceVersion: updated.ResourceVersion,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return resp, nil
+}
+
+// configMapPatcher defines how a resource stored in a config map is patched
+type configMapPatcher struct {
+	// get retrieves the config map with the resource
+	get func() (*v1.ConfigMap, error)
+	// current returns the JSON representation of the resource
+	// stored in the specified config map
+	current func(configmap *v1.ConfigMap) ([]byte, error)
+	// apply validates the patched resource and updates the specified
+	// config map with it. Returns the resource as it is stored
+	apply func(configmap *v1.ConfigMap, data []byte) ([]byte, error)
+}
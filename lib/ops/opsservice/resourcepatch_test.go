@@ -0,0 +1,164 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage/clusterconfig"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+type ResourcePatchSuite struct{}
+
+var _ = check.Suite(&ResourcePatchSuite{})
+
+func (s *ResourcePatchSuite) TestPatchesResource(c *check.C) {
+	configmaps := newTestConfigMaps(`{"kind":"test","spec":{"a":"b","c":"d"}}`)
+	resp, err := patchConfigMap(context.TODO(), configmaps, ops.PatchResourceRequest{
+		Patch:           []byte(`{"spec":{"a":null,"e":"f"}}`),
+		ResourceVersion: "1",
+	}, configmaps.patcher())
+	c.Assert(err, check.IsNil)
+	c.Assert(string(resp.Resource), check.Equals, `{"kind":"test","spec":{"c":"d","e":"f"}}`)
+	c.Assert(resp.ResourceVersion, check.Equals, "2")
+	c.Assert(configmaps.configmap.Data["spec"], check.Equals, string(resp.Resource))
+}
+
+func (s *ResourcePatchSuite) TestRejectsStaleResourceVersion(c *check.C) {
+	configmaps := newTestConfigMaps(`{"spec":{"a":"b"}}`)
+	_, err := patchConfigMap(context.TODO(), configmaps, ops.PatchResourceRequest{
+		Patch:           []byte(`{"spec":{"a":"c"}}`),
+		ResourceVersion: "0",
+	}, configmaps.patcher())
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(configmaps.configmap.Data["spec"], check.Equals, `{"spec":{"a":"b"}}`)
+}
+
+func (s *ResourcePatchSuite) TestRejectsConcurrentUpdate(c *check.C) {
+	configmaps := newTestConfigMaps(`{"spec":{"a":"b"}}`)
+	configmaps.conflicts = 1
+	_, err := patchConfigMap(context.TODO(), configmaps, ops.PatchResourceRequest{
+		Patch:           []byte(`{"spec":{"a":"c"}}`),
+		ResourceVersion: "1",
+	}, configmaps.patcher())
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *ResourcePatchSuite) TestReappliesPatchOnConflict(c *check.C) {
+	configmaps := newTestConfigMaps(`{"spec":{"a":"b"}}`)
+	configmaps.conflicts = 1
+	resp, err := patchConfigMap(context.TODO(), configmaps, ops.PatchResourceRequest{
+		Patch: []byte(`{"spec":{"a":"c"}}`),
+	}, configmaps.patcher())
+	c.Assert(err, check.IsNil)
+	c.Assert(string(resp.Resource), check.Equals, `{"spec":{"a":"c"}}`)
+	c.Assert(configmaps.updates, check.Equals, 2)
+}
+
+func (s *ResourcePatchSuite) TestPatchesClusterConfiguration(c *check.C) {
+	configmap := NewConfigurationConfigMap(nil)
+	configmap.ResourceVersion = "1"
+	resource, err := patchClusterConfiguration(configmap, ops.PatchResourceRequest{
+		Patch:           []byte(`{"spec":{"global":{"podCIDR":"10.244.0.0/16"}}}`),
+		ResourceVersion: "1",
+	})
+	c.Assert(err, check.IsNil)
+	config, err := clusterconfig.Unmarshal(resource)
+	c.Assert(err, check.IsNil)
+	c.Assert(config.GetGlobalConfig().PodCIDR, check.Equals, "10.244.0.0/16")
+	// The config map is not modified: the configuration is applied
+	// with the update-config operation
+	c.Assert(configmap.Data["spec"], check.Equals, "")
+}
+
+func (s *ResourcePatchSuite) TestRejectsStaleClusterConfigurationVersion(c *check.C) {
+	configmap := NewConfigurationConfigMap(nil)
+	configmap.ResourceVersion = "2"
+	_, err := patchClusterConfiguration(configmap, ops.PatchResourceRequest{
+		Patch:           []byte(`{"spec":{"global":{"podCIDR":"10.244.0.0/16"}}}`),
+		ResourceVersion: "1",
+	})
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func newTestConfigMaps(spec string) *testConfigMaps {
+	return &testConfigMaps{
+		configmap: v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "test",
+				ResourceVersion: "1",
+			},
+			Data: map[string]string{"spec": spec},
+		},
+	}
+}
+
+// testConfigMaps stores a single config map and simulates
+// the optimistic concurrency control of the API server
+type testConfigMaps struct {
+	corev1.ConfigMapInterface
+	configmap v1.ConfigMap
+	// conflicts is the number of updates to reject with a conflict
+	conflicts int
+	// updates counts the update attempts
+	updates int
+}
+
+func (r *testConfigMaps) patcher() configMapPatcher {
+	return configMapPatcher{
+		get: func() (*v1.ConfigMap, error) {
+			return r.configmap.DeepCopy(), nil
+		},
+		current: func(configmap *v1.ConfigMap) ([]byte, error) {
+			return []byte(configmap.Data["spec"]), nil
+		},
+		apply: func(configmap *v1.ConfigMap, data []byte) ([]byte, error) {
+			configmap.Data["spec"] = string(data)
+			return data, nil
+		},
+	}
+}
+
+func (r *testConfigMaps) Update(configmap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	r.updates++
+	if r.conflicts > 0 {
+		r.conflicts--
+		r.bumpVersion()
+	}
+	if configmap.ResourceVersion != r.configmap.ResourceVersion {
+		return nil, errors.NewConflict(schema.GroupResource{Resource: "configmaps"},
+			configmap.Name, trace.CompareFailed("object has been modified"))
+	}
+	r.configmap = *configmap.DeepCopy()
+	r.bumpVersion()
+	return r.configmap.DeepCopy(), nil
+}
+
+func (r *testConfigMaps) bumpVersion() {
+	version, _ := strconv.Atoi(r.configmap.ResourceVersion)
+	r.configmap.ResourceVersion = strconv.Itoa(version + 1)
+}
@@ -136,8 +136,16 @@ type Config struct {
 	// AuditLog is used to submit events to the audit log
 	AuditLog teleevents.IAuditLog
 
+	// KubernetesEvents optionally publishes significant audit events
+	// as Kubernetes events
+	KubernetesEvents KubernetesEvents
+
 	// GetHelmClient is a factory method for creating a Helm client.
 	GetHelmClient helm.GetClientFunc
+
+	// MaxExpandConcurrency is the maximum number of nodes that can be joining
+	// the cluster concurrently
+	MaxExpandConcurrency int
 }
 
 // Operator implements Operator interface
@@ -228,6 +236,9 @@ func (cfg *Config) CheckAndSetDefaults() error {
 	if cfg.GetHelmClient == nil {
 		cfg.GetHelmClient = helm.NewClient
 	}
+	if cfg.MaxExpandConcurrency == 0 {
+		cfg.MaxExpandConcurrency = defaults.MaxExpandConcurrency
+	}
 	return nil
 }
 
@@ -256,6 +267,9 @@ func (cfg *Config) CheckRelaxed() error {
 	if cfg.GetHelmClient == nil {
 		cfg.GetHelmClient = helm.NewClient
 	}
+	if cfg.MaxExpandConcurrency == 0 {
+		cfg.MaxExpandConcurrency = defaults.MaxExpandConcurrency
+	}
 	return nil
 }
 
@@ -605,6 +619,11 @@ func (o *Operator) CreateSite(r ops.NewSiteRequest) (*ops.Site, error) {
 		labels[ops.SiteLabelName] = r.DomainName
 	}
 
+	cni, err := app.Manifest.SelectCNI(r.CNI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	clusterData := &storage.Site{
 		AccountID:    account.ID,
 		Domain:       r.DomainName,
@@ -625,7 +644,11 @@ func (o *Operator) CreateSite(r ops.NewSiteRequest) (*ops.Site, error) {
 		ClusterState: storage.ClusterState{
 			Docker: dockerConfig,
 		},
-		InstallToken: r.InstallToken,
+		InstallToken:             r.InstallToken,
+		DisabledComponents:       r.DisabledComponents,
+		DefaultDenyNetworkPolicy: r.DefaultDenyNetworkPolicy,
+		CNI:                      cni,
+		SELinux:                  r.SELinux,
 	}
 	if runtimeLoc := app.Manifest.Base(); runtimeLoc != nil {
 		runtimeApp, err := o.cfg.Apps.GetApp(*runtimeLoc)
@@ -717,6 +740,14 @@ func (o *Operator) GetCurrentUserInfo() (*ops.UserInfo, error) {
 	return nil, trace.BadParameter("not implemented")
 }
 
+// CheckAccess verifies that the specified cluster exists.
+// The permissions of the current user are checked by the access control
+// wrapper as this operator is not bound to a user
+func (o *Operator) CheckAccess(ctx context.Context, req ops.CheckAccessRequest) error {
+	_, err := o.openSite(req.SiteKey)
+	return trace.Wrap(err)
+}
+
 func (o *Operator) GetClusterAgent(req ops.ClusterAgentRequest) (*storage.LoginEntry, error) {
 	entry, err := storage.GetClusterAgentCreds(o.backend(), req.ClusterName,
 		req.Admin)
@@ -1031,13 +1062,20 @@ func (o *Operator) SetOperationState(key ops.SiteOperationKey, req ops.SetOperat
 	}
 	// change the state without "compare" part just to take leverage of
 	// the operation group locking to ensure atomicity
-	_, err = site.compareAndSwapOperationState(swap{
+	operation, err := site.compareAndSwapOperationState(swap{
 		key:        key,
 		newOpState: req.State,
 	})
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	// rotate the tokens once the operation has finished so that
+	// the tokens handed out while it was running can no longer be used
+	if operation.IsFinished() {
+		if err := o.revokeOperationTokens(key); err != nil {
+			o.WithError(err).Warn("Failed to revoke operation tokens.")
+		}
+	}
 	if req.Progress != nil {
 		err := o.CreateProgressEntry(key, *req.Progress)
 		if err != nil {
@@ -1122,6 +1160,24 @@ func (o *Operator) GetSiteReport(key ops.SiteKey) (io.ReadCloser, error) {
 	return cluster.getClusterReport()
 }
 
+// UploadClusterReport stores the diagnostics report of the specified cluster
+// in the cluster's state directory. Reports larger than defaults.ReportMaxSize
+// are rejected
+func (o *Operator) UploadClusterReport(key ops.SiteKey, reader io.Reader) error {
+	dir := o.siteDir(key.AccountID, key.SiteDomain, reportsDir)
+	if err := os.MkdirAll(dir, defaults.PrivateDirMask); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("report-%v.tar.gz",
+		o.clock().UtcNow().Format(reportTimestampFormat)))
+	err := uploadReport(path, reader, defaults.ReportMaxSize)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	log.WithField("cluster", key.SiteDomain).Infof("Stored diagnostics report in %v.", path)
+	return nil
+}
+
 func (o *Operator) GetSiteOperationProgress(key ops.SiteOperationKey) (*ops.ProgressEntry, error) {
 	pe, err := o.backend().GetLastProgressEntry(key.SiteDomain, key.OperationID)
 	if err != nil {
@@ -1408,6 +1464,9 @@ func (o *Operator) EmitAuditEvent(ctx context.Context, req ops.AuditEventRequest
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	if o.cfg.KubernetesEvents != nil {
+		o.cfg.KubernetesEvents.Publish(req.Event, req.Fields)
+	}
 	return nil
 }
 
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
@@ -99,11 +100,21 @@ func (s *site) createShrinkOperation(context context.Context, req ops.CreateSite
 		}
 	}
 
-	key, err := s.getOperationGroup().createSiteOperation(*op)
+	key, queued, err := s.getOperationGroup().queueSiteOperation(*op)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	if queued {
+		// the operation is started once the conflicting operations complete
+		s.reportProgress(ctx, ops.ProgressEntry{
+			State:      ops.ProgressStateInProgress,
+			Completion: 0,
+			Message:    "waiting for the operations in progress to complete",
+		})
+		return key, nil
+	}
+
 	s.reportProgress(ctx, ops.ProgressEntry{
 		State:      ops.ProgressStateInProgress,
 		Completion: 0,
@@ -151,6 +162,18 @@ func (s *site) validateShrinkRequest(req ops.CreateSiteShrinkOperationRequest, c
 				"node %q is offline, add --force flag to force removal", serverName)
 		}
 		log.Warnf("Node %q is offline, forcing removal.", serverName)
+	} else if req.SkipCapacityCheck {
+		log.Warnf("Skipping capacity check for node %q.", serverName)
+	} else if !req.NodeRemoved {
+		headroom, err := s.service.getNodeHeadroom(*server)
+		if err != nil {
+			return nil, trace.Wrap(err, "failed to verify cluster capacity")
+		}
+		if !headroom.Sufficient() {
+			return nil, trace.BadParameter("the remaining nodes cannot host the pods "+
+				"evicted from node %q:\n  * %v\nUse --skip-capacity-check to remove the node anyway.",
+				serverName, strings.Join(headroom.Problems(), "\n  * "))
+		}
 	}
 
 	return server, nil
@@ -411,7 +434,7 @@ func (s *site) shrinkOperationStart(ctx *operationContext) (err error) {
 	return nil
 }
 
-func (s *site) pickShrinkMasterRunner(ctx *operationContext, removedServer storage.Server) (*serverRunner, error) {
+func (s *site) pickShrinkMasterRunner(logger log.FieldLogger, removedServer storage.Server) (*serverRunner, error) {
 	masters, err := s.getTeleportServers(schema.ServiceLabelRole, string(schema.ServiceRoleMaster))
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -420,7 +443,7 @@ func (s *site) pickShrinkMasterRunner(ctx *operationContext, removedServer stora
 	for _, master := range masters {
 		if master.IP != removedServer.AdvertiseIP {
 			return &serverRunner{
-				&master, &teleportRunner{ctx, s.domainName, s.teleport()},
+				&master, &teleportRunner{logger, s.domainName, s.teleport()},
 			}, nil
 		}
 	}
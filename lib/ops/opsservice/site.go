@@ -697,6 +697,11 @@ func convertSite(in storage.Site, apps appservice.Applications) (*ops.Site, erro
 		DNSOverrides:             in.DNSOverrides,
 		DNSConfig:                in.DNSConfig,
 		InstallToken:             in.InstallToken,
+		DisabledComponents:       in.DisabledComponents,
+		DefaultDenyNetworkPolicy: in.DefaultDenyNetworkPolicy,
+		InstallResources:         in.InstallResources,
+		CNI:                      in.CNI,
+		SELinux:                  in.SELinux,
 	}
 	if in.License != "" {
 		parsed, err := license.ParseLicense(in.License)
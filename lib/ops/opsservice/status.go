@@ -90,18 +90,39 @@ func (s *site) canActivate() bool {
 		s.backendSite.Reason != storage.ReasonLicenseInvalid
 }
 
-// checkPlanetStatus checks the cluster health using planet agents
+// checkPlanetStatus checks the cluster health using planet agents.
+// If the active cluster has become unhealthy, emits an event for every degraded node
 func (s *site) checkPlanetStatus(ctx context.Context) error {
 	planetStatus, err := status.FromPlanetAgent(ctx, nil)
 	if err != nil {
 		return trace.Wrap(err)
 	}
 	if planetStatus.GetSystemStatus() != agentpb.SystemStatus_Running {
+		if s.backendSite.State == ops.SiteStateActive {
+			for _, node := range degradedNodes(planetStatus) {
+				events.Emit(ctx, s.service, events.NodeDegraded, node)
+			}
+		}
 		return trace.BadParameter("cluster is not healthy: %#v", planetStatus)
 	}
 	return nil
 }
 
+// degradedNodes returns the event fields of the nodes that are not healthy
+func degradedNodes(planetStatus *status.Agent) (nodes []events.Fields) {
+	for _, node := range planetStatus.Nodes {
+		if node.Status == status.NodeHealthy {
+			continue
+		}
+		nodes = append(nodes, events.Fields{
+			events.FieldNodeHostname: node.Hostname,
+			events.FieldNodeIP:       node.AdvertiseIP,
+			events.FieldReason:       node.Status,
+		})
+	}
+	return nodes
+}
+
 // checkStatusHook executes the application's status hook
 func (s *site) checkStatusHook(ctx context.Context) error {
 	if !s.app.Manifest.HasHook(schema.HookStatus) {
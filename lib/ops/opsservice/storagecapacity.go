@@ -0,0 +1,169 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/systeminfo"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetStorageCapacity returns the block storage capacity available
+// for persistent volumes on cluster nodes
+func (o *Operator) GetStorageCapacity(req ops.StorageCapacityRequest) (*ops.StorageCapacity, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := o.openSite(req.SiteKey())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	classes, err := getLocalStorageClasses(client)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	filter := req.Filter
+	if filter.IsEmpty() {
+		config, err := o.GetPersistentStorage(req.SiteKey())
+		if err != nil && !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		if config != nil {
+			filter = ops.NewDeviceFilter(config)
+		}
+	}
+	return cluster.getStorageCapacity(context.TODO(), filter, classes)
+}
+
+// getStorageCapacity queries block devices from all cluster nodes and
+// validates the combined capacity against the specified storage classes.
+// Nodes that fail to respond are reported with an error
+func (s *site) getStorageCapacity(ctx context.Context, filter systeminfo.DeviceFilter, classes []ops.StorageClassCapacity) (*ops.StorageCapacity, error) {
+	cluster, err := s.backend().GetSite(s.domainName)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var mu sync.Mutex
+	nodes := make(map[string]ops.NodeStorageCapacity)
+	err = s.executeOnTeleportServers(ctx, func(ctx context.Context, runner *serverRunner) error {
+		devices, err := s.getNodeBlockDevices(runner, filter)
+		node := ops.NewNodeStorageCapacity(runner.server.HostName(), devices)
+		if err != nil {
+			node.Error = trace.UserMessage(err)
+		}
+		mu.Lock()
+		nodes[runner.server.(*teleportServer).IP] = node
+		mu.Unlock()
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		s.WithError(err).Warn("Failed to collect block devices from some nodes.")
+	}
+	capacity := ops.NewStorageCapacity(*cluster, nodes, classes)
+	return &capacity, nil
+}
+
+func (s *site) getNodeBlockDevices(runner *serverRunner, filter systeminfo.DeviceFilter) ([]systeminfo.BlockDevice, error) {
+	args := []string{"system", "devices", "ls", "--format=json"}
+	for _, vendor := range filter.IncludeVendors {
+		args = append(args, "--include-vendor", vendor)
+	}
+	for _, vendor := range filter.ExcludeVendors {
+		args = append(args, "--exclude-vendor", vendor)
+	}
+	for _, path := range filter.IncludePaths {
+		args = append(args, "--include-path", path)
+	}
+	for _, path := range filter.ExcludePaths {
+		args = append(args, "--exclude-path", path)
+	}
+	var out bytes.Buffer
+	err := runner.RunStream(&out, s.gravityCommand(args...)...)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to list block devices")
+	}
+	var devices []systeminfo.BlockDevice
+	if err := json.Unmarshal(out.Bytes(), &devices); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return devices, nil
+}
+
+// getLocalStorageClasses returns storage classes provisioned from local block
+// devices along with the capacity requested by persistent volume claims
+func getLocalStorageClasses(client kubernetes.Interface) ([]ops.StorageClassCapacity, error) {
+	classes, err := client.StorageV1().StorageClasses().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	claims, err := client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var result []ops.StorageClassCapacity
+	for _, class := range classes.Items {
+		if !utils.StringInSlice(localStorageProvisioners, class.Provisioner) {
+			continue
+		}
+		capacity := ops.StorageClassCapacity{
+			Name:        class.Name,
+			Provisioner: class.Provisioner,
+		}
+		for _, claim := range claims.Items {
+			if claimStorageClass(claim) != class.Name {
+				continue
+			}
+			if request, ok := claim.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+				capacity.RequestedBytes += uint64(request.Value())
+			}
+		}
+		result = append(result, capacity)
+	}
+	return result, nil
+}
+
+// claimStorageClass returns the name of the storage class of the specified claim
+func claimStorageClass(claim v1.PersistentVolumeClaim) string {
+	if class, ok := claim.Annotations[v1.BetaStorageClassAnnotation]; ok {
+		return class
+	}
+	if claim.Spec.StorageClassName != nil {
+		return *claim.Spec.StorageClassName
+	}
+	return ""
+}
+
+// localStorageProvisioners lists provisioners of storage classes
+// backed by local block devices
+var localStorageProvisioners = []string{
+	"kubernetes.io/no-provisioner",
+	"openebs.io/local",
+}
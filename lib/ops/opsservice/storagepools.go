@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/openebs"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	log "github.com/sirupsen/logrus"
+)
+
+// StoragePoolControllerConfig is the configuration of the storage pool controller
+type StoragePoolControllerConfig struct {
+	// Operator is the cluster operator service
+	Operator *Operator
+	// Clock is used to mock time in tests
+	Clock clockwork.Clock
+	// FieldLogger is used for logging
+	log.FieldLogger
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (r *StoragePoolControllerConfig) CheckAndSetDefaults() error {
+	if r.Operator == nil {
+		return trace.BadParameter("missing Operator")
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = log.WithField(trace.Component, "storagepools")
+	}
+	return nil
+}
+
+// StoragePoolController reconciles the OpenEBS storage pools with
+// the persistent storage configuration of the cluster
+type StoragePoolController struct {
+	StoragePoolControllerConfig
+}
+
+// NewStoragePoolController returns a new storage pool controller
+func NewStoragePoolController(config StoragePoolControllerConfig) (*StoragePoolController, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &StoragePoolController{StoragePoolControllerConfig: config}, nil
+}
+
+// Run periodically reconciles the storage pools until the context is canceled
+func (r *StoragePoolController) Run(ctx context.Context) {
+	r.Info("Starting storage pool controller.")
+	ticker := r.Clock.NewTicker(defaults.StoragePoolReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.Chan():
+			if err := r.Reconcile(ctx); err != nil {
+				r.WithError(err).Warn("Failed to reconcile storage pools.")
+			}
+		case <-ctx.Done():
+			r.Info("Stopping storage pool controller.")
+			return
+		}
+	}
+}
+
+// Reconcile creates or updates the resources of the storage pools
+// declared in the persistent storage configuration.
+// The reconciliation is skipped if the cluster is not active, e.g. while
+// another operation is in progress
+func (r *StoragePoolController) Reconcile(ctx context.Context) error {
+	cluster, err := r.Operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if cluster.State != ops.SiteStateActive {
+		r.Debugf("Cluster is %v, will retry storage pool reconciliation.", cluster.State)
+		return nil
+	}
+	config, err := r.Operator.GetPersistentStorage(cluster.Key())
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	if len(config.GetPools()) == 0 {
+		return nil
+	}
+	client, err := r.Operator.GetKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(openebs.Reconcile(client, openebs.Config{
+		Pools:       config.GetPools(),
+		Servers:     cluster.ClusterState.Servers,
+		FieldLogger: r.FieldLogger,
+	}))
+}
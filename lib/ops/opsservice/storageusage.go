@@ -0,0 +1,163 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package opsservice
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeapi "k8s.io/client-go/kubernetes"
+)
+
+// GetStorageUsage returns the persistent storage capacity and usage
+// of the application namespaces
+func (o *Operator) GetStorageUsage(key ops.SiteKey) (*ops.StorageUsage, error) {
+	client, err := o.GetKubeClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	volumes, err := o.getVolumeUsage(client)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var quotas []storage.StorageQuota
+	config, err := o.GetPersistentStorage(key)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	if config != nil {
+		quotas = config.GetQuotas()
+	}
+	usage := ops.NewStorageUsage(volumes, quotas)
+	return &usage, nil
+}
+
+// getVolumeUsage returns the capacity of the volumes bound to persistent
+// volume claims along with the usage reported by the kubelets.
+// The kubelets are queried in parallel. Nodes that are not ready
+// or fail to report volume usage are logged and skipped
+func (o *Operator) getVolumeUsage(client kubeapi.Interface) ([]ops.VolumeUsage, error) {
+	claims, err := client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	type result struct {
+		node    string
+		summary *statsSummary
+		err     error
+	}
+	semaphoreC := make(chan struct{}, defaults.MaxStorageUsageConcurrency)
+	resultC := make(chan result, len(nodes.Items))
+	var queried int
+	for _, node := range nodes.Items {
+		if !kubernetes.IsNodeReady(node) {
+			o.WithField("node", node.Name).Warn("Node is not ready, skip querying volume usage.")
+			continue
+		}
+		queried++
+		go func(node string) {
+			semaphoreC <- struct{}{}
+			defer func() { <-semaphoreC }()
+			summary, err := getNodeStatsSummary(client, node)
+			resultC <- result{node: node, summary: summary, err: err}
+		}(node.Name)
+	}
+	used := make(map[claimKey]uint64)
+	for i := 0; i < queried; i++ {
+		r := <-resultC
+		if r.err != nil {
+			o.WithError(r.err).WithField("node", r.node).Warn("Failed to query volume usage.")
+			continue
+		}
+		for _, pod := range r.summary.Pods {
+			for _, volume := range pod.Volumes {
+				if volume.PVCRef == nil || volume.UsedBytes == nil {
+					continue
+				}
+				used[claimKey{namespace: volume.PVCRef.Namespace, name: volume.PVCRef.Name}] = *volume.UsedBytes
+			}
+		}
+	}
+	var volumes []ops.VolumeUsage
+	for _, claim := range claims.Items {
+		if claim.Status.Phase != v1.ClaimBound {
+			continue
+		}
+		volume := ops.VolumeUsage{
+			Namespace:    claim.Namespace,
+			Claim:        claim.Name,
+			StorageClass: claimStorageClass(claim),
+		}
+		if capacity, ok := claim.Status.Capacity[v1.ResourceStorage]; ok {
+			volume.CapacityBytes = uint64(capacity.Value())
+		}
+		volume.UsedBytes, volume.Reported = used[claimKey{namespace: claim.Namespace, name: claim.Name}]
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// getNodeStatsSummary queries the kubelet statistics summary
+// of the specified node via the API server proxy
+func getNodeStatsSummary(client kubeapi.Interface, node string) (*statsSummary, error) {
+	data, err := client.CoreV1().RESTClient().Get().
+		Resource("nodes").Name(node).SubResource("proxy").Suffix("stats/summary").
+		Timeout(defaults.StorageUsageTimeout).
+		DoRaw()
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	var summary statsSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &summary, nil
+}
+
+// claimKey identifies a persistent volume claim
+type claimKey struct {
+	namespace string
+	name      string
+}
+
+// statsSummary is the kubelet statistics summary.
+//
+// Only the fields required to collect the usage of the volumes
+// bound to persistent volume claims are decoded
+type statsSummary struct {
+	Pods []struct {
+		Volumes []struct {
+			UsedBytes *uint64 `json:"usedBytes"`
+			PVCRef    *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
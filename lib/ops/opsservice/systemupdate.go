@@ -25,13 +25,17 @@ import (
 )
 
 // rotateSecrets generates a new set of TLS keys for the given node
-// as a package that will be automatically downloaded during upgrade
-func (s *site) rotateSecrets(ctx *operationContext, secretsPackage loc.Locator, node *ProvisionedServer, installOp ops.SiteOperation) (*ops.RotatePackageResponse, error) {
+// as a package that will be automatically downloaded during upgrade.
+// serviceCIDR optionally overrides the service network selected during installation
+func (s *site) rotateSecrets(ctx *operationContext, secretsPackage loc.Locator, node *ProvisionedServer, installOp ops.SiteOperation, serviceCIDR string) (*ops.RotatePackageResponse, error) {
 	subnets := installOp.InstallExpand.Subnets
 	if subnets.IsEmpty() {
 		// Subnets are empty when updating an older installation
 		subnets = storage.DefaultSubnets
 	}
+	if serviceCIDR != "" {
+		subnets.Service = serviceCIDR
+	}
 
 	if !node.IsMaster() {
 		resp, err := s.getPlanetNodeSecretsPackage(ctx, node, secretsPackage)
@@ -31,6 +31,7 @@ import (
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/clusterconfig"
+	"github.com/gravitational/gravity/lib/system"
 	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/gravitational/trace"
@@ -74,7 +75,18 @@ func (o *Operator) RotateSecrets(req ops.RotateSecretsRequest) (resp *ops.Rotate
 		return nil, trace.Wrap(err)
 	}
 
-	resp, err = cluster.rotateSecrets(ctx, *secretsPackage, node, *op)
+	serviceCIDR := req.ServiceCIDR
+	if serviceCIDR == "" {
+		config, err := o.GetClusterConfiguration(req.SiteKey())
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if globalConfig := config.GetGlobalConfig(); globalConfig != nil {
+			serviceCIDR = globalConfig.ServiceCIDR
+		}
+	}
+
+	resp, err = cluster.rotateSecrets(ctx, *secretsPackage, node, *op, serviceCIDR)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -282,6 +294,11 @@ func (o *Operator) RotatePlanetConfig(req ops.RotatePlanetConfigRequest) (*ops.R
 		config.config = clusterConfig
 	}
 
+	config.dns, err = o.getClusterDNS()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	resp, err := cluster.getPlanetConfigPackage(config)
 	if err != nil && !trace.IsAlreadyExists(err) {
 		return nil, trace.Wrap(err)
@@ -368,6 +385,7 @@ func (s *site) createUpdateOperation(context context.Context, req ops.CreateSite
 		Provisioner: installOperation.Provisioner,
 		Update: &storage.UpdateOperationState{
 			UpdatePackage: req.App,
+			Throttle:      req.Throttle,
 		},
 	}
 
@@ -435,8 +453,18 @@ func (s *site) validateUpdateOperationRequest(req ops.CreateSiteAppUpdateOperati
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	err = pack.CheckUpdatePackage(*currentPackage, *updatePackage)
-	if err != nil {
+	if req.CatchUp {
+		if !currentPackage.IsEqualTo(*updatePackage) {
+			return trace.BadParameter("catch-up operation requires the installed application %v, got %v",
+				currentPackage, updatePackage)
+		}
+	} else {
+		err = pack.CheckUpdatePackage(*currentPackage, *updatePackage)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if _, err := system.ParseThrottleLevel(req.Throttle); err != nil {
 		return trace.Wrap(err)
 	}
 	// the new package must exist in the Ops Center
@@ -0,0 +1,69 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/trace"
+)
+
+// PatchResourceRequest is a request to partially update a cluster resource
+type PatchResourceRequest struct {
+	// SiteKey is the key of the cluster to update
+	SiteKey `json:"site_key"`
+	// Patch is the JSON merge patch (RFC 7386) to apply to the resource
+	Patch json.RawMessage `json:"patch"`
+	// ResourceVersion optionally specifies the version of the resource
+	// the patch is based on. If specified, the patch is rejected if the
+	// resource has been modified since
+	ResourceVersion string `json:"resource_version,omitempty"`
+	// Force applies the patched resource even if it fails validation
+	// against the cluster state
+	Force bool `json:"force,omitempty"`
+}
+
+// Check validates this request
+func (r PatchResourceRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if len(r.Patch) == 0 {
+		return trace.BadParameter("missing patch")
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(r.Patch, &patch); err != nil {
+		return trace.BadParameter("patch should be a JSON object: %v", err)
+	}
+	return nil
+}
+
+// PatchResourceResponse describes the result of a partial resource update
+type PatchResourceResponse struct {
+	// Resource is the updated resource
+	Resource json.RawMessage `json:"resource"`
+	// ResourceVersion is the version of the updated resource.
+	// It can be used as PatchResourceRequest.ResourceVersion of
+	// the subsequent patch.
+	// If the resource is updated with an operation, this is the version
+	// the patch has been applied to
+	ResourceVersion string `json:"resource_version"`
+	// Operation optionally specifies the operation that applies
+	// the updated resource.
+	// The resource is only updated once the operation has completed
+	Operation *SiteOperationKey `json:"operation,omitempty"`
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	"github.com/gravitational/trace"
+)
+
+// UpdatePersistentStorageRequest is a request to update the persistent
+// storage configuration of the cluster
+type UpdatePersistentStorageRequest struct {
+	// SiteKey is the key of the cluster to update
+	SiteKey
+	// Resource is the new persistent storage configuration
+	Resource storage.PersistentStorage
+	// Force applies the configuration even if it fails validation,
+	// e.g. when it excludes block devices already in use
+	Force bool
+}
+
+// Check validates this request
+func (r UpdatePersistentStorageRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if r.Resource == nil {
+		return trace.BadParameter("missing persistent storage configuration")
+	}
+	return nil
+}
+
+// NodeBlockDevices lists block devices of a node annotated with
+// the device filter results
+type NodeBlockDevices struct {
+	// Hostname is the node hostname
+	Hostname string `json:"hostname"`
+	// AdvertiseIP is the node advertise IP
+	AdvertiseIP string `json:"advertise_ip"`
+	// Devices lists block devices of the node
+	Devices []systeminfo.BlockDevice `json:"devices,omitempty"`
+	// Error describes the failure to query the node, if any
+	Error string `json:"error,omitempty"`
+}
+
+// ClaimedDevice describes a block device claimed by an OpenEBS block device claim
+type ClaimedDevice struct {
+	// Name is the name of the OpenEBS block device resource
+	Name string `json:"name"`
+	// Node is the name of the Kubernetes node the device is attached to
+	Node string `json:"node"`
+	// Path is the device path
+	Path string `json:"path"`
+	// Vendor is the device vendor
+	Vendor string `json:"vendor,omitempty"`
+}
+
+// NewDeviceFilter returns the device filter for the specified persistent
+// storage configuration. The filter is applied in addition to the default
+// node disk manager exclusions
+func NewDeviceFilter(config storage.PersistentStorage) systeminfo.DeviceFilter {
+	return systeminfo.DeviceFilter{
+		IncludeVendors: config.GetIncludeVendors(),
+		ExcludeVendors: config.GetExcludeVendors(),
+		IncludePaths:   config.GetIncludeDevices(),
+		ExcludePaths:   config.GetExcludeDevices(),
+	}
+}
+
+// CheckPersistentStorage validates the persistent storage configuration against
+// the block devices of cluster nodes listed with its device filter.
+//
+// The configuration is rejected if the block devices of any node could not be
+// listed, if any of its include filters does not match a single device, if
+// it excludes devices already claimed by OpenEBS or if any of its storage
+// pools claims a block device that is missing or excluded
+func CheckPersistentStorage(config storage.PersistentStorage, nodes []NodeBlockDevices, claimed []ClaimedDevice) error {
+	var errors []string
+	for _, node := range nodes {
+		if node.Error != "" {
+			errors = append(errors, fmt.Sprintf("failed to list block devices on node %v: %v",
+				node.Hostname, node.Error))
+		}
+	}
+	for _, path := range config.GetIncludeDevices() {
+		if !matchesAnyDevice(nodes, func(device systeminfo.BlockDevice) bool {
+			return strings.Contains(device.Path, path)
+		}) {
+			errors = append(errors, fmt.Sprintf("device filter %q does not match any block device", path))
+		}
+	}
+	for _, vendor := range config.GetIncludeVendors() {
+		if !matchesAnyDevice(nodes, func(device systeminfo.BlockDevice) bool {
+			return device.Vendor == vendor
+		}) {
+			errors = append(errors, fmt.Sprintf("vendor filter %q does not match any block device", vendor))
+		}
+	}
+	filter := NewDeviceFilter(config).WithDefaults()
+	for _, device := range claimed {
+		included, reason := checkClaimedDevice(filter, nodes, device)
+		if !included {
+			errors = append(errors, fmt.Sprintf("block device %v on node %v is claimed (%v) but would be excluded: %v",
+				device.Path, device.Node, device.Name, reason))
+		}
+	}
+	errors = append(errors, checkStoragePools(config.GetPools(), nodes)...)
+	if len(errors) == 0 {
+		return nil
+	}
+	return trace.BadParameter("persistent storage configuration failed validation:\n  %v\n"+
+		"use --force to apply it anyway", strings.Join(errors, "\n  "))
+}
+
+// checkClaimedDevice returns whether the specified claimed device remains
+// included. The device is looked up among the devices listed on its node
+// and is checked against the filter if it is not found
+func checkClaimedDevice(filter systeminfo.DeviceFilter, nodes []NodeBlockDevices, claimed ClaimedDevice) (included bool, reason string) {
+	for _, node := range nodes {
+		if node.Hostname != claimed.Node && node.AdvertiseIP != claimed.Node {
+			continue
+		}
+		for _, device := range node.Devices {
+			if device.Path == claimed.Path {
+				return device.Included, device.Reason
+			}
+		}
+	}
+	return filter.Check(systeminfo.BlockDevice{
+		Path:   claimed.Path,
+		Vendor: claimed.Vendor,
+	})
+}
+
+func matchesAnyDevice(nodes []NodeBlockDevices, match func(systeminfo.BlockDevice) bool) bool {
+	for _, node := range nodes {
+		for _, device := range node.Devices {
+			if match(device) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkStoragePools verifies that the nodes of the specified storage pools
+// are cluster nodes and that the block devices claimed by the pools
+// exist on them and are included by the device filter
+func checkStoragePools(pools []storage.StoragePool, nodes []NodeBlockDevices) (errors []string) {
+	for _, pool := range pools {
+		for _, poolNode := range pool.Nodes {
+			node := findNode(nodes, poolNode.Node)
+			if node == nil {
+				errors = append(errors, fmt.Sprintf("node %v of storage pool %v is not a cluster node",
+					poolNode.Node, pool.Name))
+				continue
+			}
+			if node.Error != "" {
+				// The failure to list the block devices has already been reported
+				continue
+			}
+			for _, path := range poolNode.Devices {
+				device := findDevice(node.Devices, path)
+				if device == nil {
+					errors = append(errors, fmt.Sprintf("block device %v of storage pool %v is not found on node %v",
+						path, pool.Name, poolNode.Node))
+				} else if !device.Included {
+					errors = append(errors, fmt.Sprintf("block device %v of storage pool %v on node %v is excluded: %v",
+						path, pool.Name, poolNode.Node, device.Reason))
+				}
+			}
+		}
+	}
+	return errors
+}
+
+// findNode returns the node with the specified hostname or advertise IP
+func findNode(nodes []NodeBlockDevices, name string) *NodeBlockDevices {
+	for i, node := range nodes {
+		if node.Hostname == name || node.AdvertiseIP == name {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+func findDevice(devices []systeminfo.BlockDevice, path string) *systeminfo.BlockDevice {
+	for i, device := range devices {
+		if device.Path == path {
+			return &devices[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"regexp"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+type PersistentStorageSuite struct{}
+
+var _ = check.Suite(&PersistentStorageSuite{})
+
+func (s *PersistentStorageSuite) TestValidatesDeviceFilter(c *check.C) {
+	nodes := []NodeBlockDevices{
+		{
+			Hostname:    "node-1",
+			AdvertiseIP: "192.168.1.1",
+			Devices: []systeminfo.BlockDevice{
+				{Path: "/dev/sdb", Vendor: "ATA", Included: true},
+				{Path: "/dev/sdc", Vendor: "ATA", Reason: `path matches excluded "/dev/sdc"`},
+			},
+		},
+	}
+	var testCases = []struct {
+		comment string
+		spec    storage.PersistentStorageSpecV2
+		nodes   []NodeBlockDevices
+		claimed []ClaimedDevice
+		errors  []string
+	}{
+		{
+			comment: "no claimed devices are excluded",
+			spec:    newPersistentStorageSpec([]string{"/dev/sd"}, []string{"/dev/sdc"}),
+			nodes:   nodes,
+			claimed: []ClaimedDevice{{Name: "bd-1", Node: "192.168.1.1", Path: "/dev/sdb"}},
+		},
+		{
+			comment: "claimed device is excluded",
+			spec:    newPersistentStorageSpec(nil, []string{"/dev/sdc"}),
+			nodes:   nodes,
+			claimed: []ClaimedDevice{{Name: "bd-2", Node: "node-1", Path: "/dev/sdc"}},
+			errors:  []string{`block device /dev/sdc on node node-1 is claimed (bd-2) but would be excluded: path matches excluded "/dev/sdc"`},
+		},
+		{
+			comment: "claimed device missing from node is checked against filter",
+			spec:    newPersistentStorageSpec(nil, []string{"/dev/sdd"}),
+			nodes:   nodes,
+			claimed: []ClaimedDevice{{Name: "bd-3", Node: "node-2", Path: "/dev/sdd"}},
+			errors:  []string{`block device /dev/sdd on node node-2 is claimed (bd-3) but would be excluded: path matches excluded "/dev/sdd"`},
+		},
+		{
+			comment: "include filter does not match any device",
+			spec:    newPersistentStorageSpec([]string{"/dev/nvme"}, nil),
+			nodes:   nodes,
+			errors:  []string{`device filter "/dev/nvme" does not match any block device`},
+		},
+		{
+			comment: "storage pool claims missing and excluded devices",
+			spec: storage.PersistentStorageSpecV2{
+				OpenEBS: storage.OpenEBS{
+					Filters: storage.OpenEBSFilters{
+						Devices: storage.OpenEBSFilter{Exclude: []string{"/dev/sdc"}},
+					},
+					Pools: []storage.StoragePool{{
+						Name:         "cstor-pool",
+						Type:         storage.StoragePoolCStor,
+						StorageClass: "openebs-cstor",
+						Nodes: []storage.StoragePoolNode{
+							{Node: "192.168.1.1", Devices: []string{"/dev/sdb", "/dev/sdc", "/dev/sde"}},
+							{Node: "node-3", Devices: []string{"/dev/sdb"}},
+						},
+					}},
+				},
+			},
+			nodes: nodes,
+			errors: []string{
+				`block device /dev/sdc of storage pool cstor-pool on node 192.168.1.1 is excluded: path matches excluded "/dev/sdc"`,
+				"block device /dev/sde of storage pool cstor-pool is not found on node 192.168.1.1",
+				"node node-3 of storage pool cstor-pool is not a cluster node",
+			},
+		},
+		{
+			comment: "node failed to respond",
+			spec:    newPersistentStorageSpec(nil, nil),
+			nodes:   []NodeBlockDevices{{Hostname: "node-1", Error: "connection refused"}},
+			errors:  []string{"failed to list block devices on node node-1: connection refused"},
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		err := CheckPersistentStorage(storage.NewPersistentStorage(tc.spec), tc.nodes, tc.claimed)
+		if len(tc.errors) == 0 {
+			c.Assert(err, check.IsNil, comment)
+			continue
+		}
+		c.Assert(trace.IsBadParameter(err), check.Equals, true, comment)
+		for _, message := range tc.errors {
+			c.Assert(err.Error(), check.Matches, "(?s).*"+regexp.QuoteMeta(message)+".*", comment)
+		}
+	}
+}
+
+func newPersistentStorageSpec(include, exclude []string) storage.PersistentStorageSpecV2 {
+	return storage.PersistentStorageSpecV2{
+		OpenEBS: storage.OpenEBS{
+			Filters: storage.OpenEBSFilters{
+				Devices: storage.OpenEBSFilter{
+					Include: include,
+					Exclude: exclude,
+				},
+			},
+		},
+	}
+}
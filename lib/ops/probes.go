@@ -0,0 +1,65 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"time"
+)
+
+// ClusterProbes lists the results of the health probes executed
+// by the monitoring agents on the cluster nodes
+type ClusterProbes struct {
+	// Timestamp is the time the probe results have been collected by the agents
+	Timestamp time.Time `json:"timestamp"`
+	// Duration is how long it took to query the results from the agents.
+	// The agents do not time the individual probes
+	Duration time.Duration `json:"duration"`
+	// Probes lists the results of the individual probes
+	Probes []Probe `json:"probes"`
+}
+
+// Probe describes the last result of a single health probe on a node
+type Probe struct {
+	// Node is the name of the node the probe has been executed on
+	Node string `json:"node"`
+	// AdvertiseIP is the advertise IP of the node
+	AdvertiseIP string `json:"advertise_ip"`
+	// Checker is the name of the checker that produced the probe
+	Checker string `json:"checker"`
+	// Detail is the checker-specific probe detail
+	Detail string `json:"detail,omitempty"`
+	// Status is the probe result: running or failed
+	Status string `json:"status"`
+	// Severity is the severity of the failed probe: critical or warning
+	Severity string `json:"severity,omitempty"`
+	// Error is the error message of the failed probe
+	Error string `json:"error,omitempty"`
+	// Remediation is the hint how to fix the failed probe
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// IsFailed returns true if the probe has failed
+func (r Probe) IsFailed() bool {
+	return r.Status != ProbeStatusRunning
+}
+
+const (
+	// ProbeStatusRunning is the status of a successful probe
+	ProbeStatusRunning = "running"
+	// ProbeStatusFailed is the status of a failed probe
+	ProbeStatusFailed = "failed"
+)
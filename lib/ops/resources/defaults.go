@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"strings"
+
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// DefaultResources returns the Gravity resources bundled with the specified
+// manifest that are created when the cluster is installed
+func DefaultResources(manifest schema.Manifest) (defaults []storage.UnknownResource, err error) {
+	if strings.TrimSpace(manifest.DefaultResources) == "" {
+		return nil, nil
+	}
+	err = ForEach(strings.NewReader(manifest.DefaultResources), func(resource storage.UnknownResource) error {
+		if isKubernetesResource(resource) {
+			return trace.BadParameter("default resources can only contain Gravity resources, "+
+				"got Kubernetes resource %q", resource.Kind)
+		}
+		if !utils.StringInSlice(DefaultResourceKinds, resource.Kind) {
+			return trace.BadParameter("resource %q is not supported as a default resource, "+
+				"supported are: %v", resource.Kind, DefaultResourceKinds)
+		}
+		defaults = append(defaults, resource)
+		return nil
+	})
+	if err != nil {
+		return nil, trace.Wrap(err, "invalid default resources")
+	}
+	return defaults, nil
+}
+
+// Merge returns the resources with the overrides applied: resources with
+// the same kind and name are replaced, others are appended
+func Merge(resources, overrides []storage.UnknownResource) (result []storage.UnknownResource) {
+	result = append(result, resources...)
+	for _, override := range overrides {
+		replaced := false
+		for i, resource := range result {
+			if resource.Kind == override.Kind && resource.Metadata.Name == override.Metadata.Name {
+				result[i] = override
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, override)
+		}
+	}
+	return result
+}
+
+// DefaultResourceKinds lists the resources that can be bundled
+// with the cluster image as defaults
+var DefaultResourceKinds = []string{
+	storage.KindLogForwarder,
+	storage.KindAlertTarget,
+	storage.KindSMTPConfig,
+	storage.KindAuthGateway,
+	storage.KindPersistentStorage,
+}
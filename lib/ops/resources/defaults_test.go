@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type DefaultsSuite struct{}
+
+var _ = check.Suite(&DefaultsSuite{})
+
+func (s *DefaultsSuite) TestDefaultResources(c *check.C) {
+	manifest := schema.Manifest{
+		DefaultResources: `kind: logforwarder
+version: v2
+metadata:
+  name: forwarder
+spec:
+  address: 192.168.100.1:514
+  protocol: udp
+---
+kind: authgateway
+version: v1
+spec:
+  sshPublicAddr: ["example.com"]
+`,
+	}
+	defaults, err := DefaultResources(manifest)
+	c.Assert(err, check.IsNil)
+	c.Assert(headers(defaults), check.DeepEquals, []string{
+		"logforwarder/forwarder",
+		"authgateway/",
+	})
+
+	defaults, err = DefaultResources(schema.Manifest{})
+	c.Assert(err, check.IsNil)
+	c.Assert(defaults, check.HasLen, 0)
+}
+
+func (s *DefaultsSuite) TestRejectsUnsupportedDefaultResources(c *check.C) {
+	var tests = []struct {
+		resources string
+		comment   string
+	}{
+		{
+			resources: `kind: user
+version: v2
+metadata:
+  name: admin@example.com
+`,
+			comment: "users cannot be bundled",
+		},
+		{
+			resources: `kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: config
+`,
+			comment: "Kubernetes resources cannot be bundled",
+		},
+	}
+	for _, tc := range tests {
+		_, err := DefaultResources(schema.Manifest{DefaultResources: tc.resources})
+		c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf(tc.comment))
+	}
+}
+
+func (s *DefaultsSuite) TestMergesResources(c *check.C) {
+	defaults := []storage.UnknownResource{
+		newResource(storage.KindLogForwarder, "forwarder", "default"),
+		newResource(storage.KindAuthGateway, "", "default"),
+	}
+	overrides := []storage.UnknownResource{
+		newResource(storage.KindAuthGateway, "", "override"),
+		newResource(storage.KindLogForwarder, "other", "override"),
+	}
+	c.Assert(Merge(defaults, overrides), check.DeepEquals, []storage.UnknownResource{
+		newResource(storage.KindLogForwarder, "forwarder", "default"),
+		newResource(storage.KindAuthGateway, "", "override"),
+		newResource(storage.KindLogForwarder, "other", "override"),
+	})
+}
+
+func headers(resources []storage.UnknownResource) (result []string) {
+	for _, resource := range resources {
+		result = append(result, resource.Kind+"/"+resource.Metadata.Name)
+	}
+	return result
+}
+
+func newResource(kind, name, raw string) storage.UnknownResource {
+	return storage.UnknownResource{
+		ResourceHeader: teleservices.ResourceHeader{
+			Kind:     kind,
+			Version:  teleservices.V2,
+			Metadata: teleservices.Metadata{Name: name},
+		},
+		Raw: []byte(raw),
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+)
+
+// ExportRequest describes a request to export cluster resources
+type ExportRequest struct {
+	// SiteKey is the key of the cluster to route request to.
+	ops.SiteKey
+	// Kinds lists the kinds of resources to export
+	Kinds []string
+	// WithSecrets is whether to include hidden resource fields
+	WithSecrets bool
+}
+
+// String returns the request string representation.
+func (r ExportRequest) String() string {
+	return fmt.Sprintf("ExportResources(Cluster=%v, Kinds=%v)", r.SiteDomain, r.Kinds)
+}
+
+// ImportRequest describes a request to import cluster resources
+type ImportRequest struct {
+	// SiteKey is the key of the cluster to route request to.
+	ops.SiteKey
+	// Kinds lists the kinds of resources that can be imported
+	// in the order they should be created in
+	Kinds []string
+	// Owner is the user to create resources for
+	Owner string
+	// Manual defines whether the operations triggered by resources
+	// should operate in manual mode
+	Manual bool
+	// Confirmed defines whether the operations triggered by resources
+	// have been explicitly approved
+	Confirmed bool
+	// OperationKinds lists the kinds of resources that are applied
+	// with a cluster operation
+	OperationKinds []string
+	// WithOperations defines whether resources of OperationKinds
+	// can be imported
+	WithOperations bool
+}
+
+// String returns the request string representation.
+func (r ImportRequest) String() string {
+	return fmt.Sprintf("ImportResources(Cluster=%v)", r.SiteDomain)
+}
+
+// Export writes the resources of all requested kinds into w as a single
+// YAML stream that can be applied with Import.
+// Kinds without resources in the cluster are skipped
+func (r *ResourceControl) Export(w io.Writer, req ExportRequest) error {
+	var exported []storage.UnknownResource
+	for _, kind := range req.Kinds {
+		collection, err := r.Resources.GetCollection(ListRequest{
+			SiteKey:     req.SiteKey,
+			Kind:        kind,
+			WithSecrets: req.WithSecrets,
+		})
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return trace.Wrap(err, "failed to export %v resources", kind)
+		}
+		resources, err := collection.Resources()
+		if err != nil {
+			return trace.Wrap(err, "failed to export %v resources", kind)
+		}
+		for _, resource := range resources {
+			exported = append(exported, storage.UnknownResource{
+				ResourceHeader: resource.ResourceHeader,
+				Raw:            resource.Raw,
+			})
+		}
+	}
+	return trace.Wrap(storage.Encode(exported, w))
+}
+
+// Import creates or updates all resources read from reader as a single unit.
+// All resources are validated before the cluster is modified and, if updating
+// any of the resources fails, the resources updated so far are reverted
+// to their previous state
+func (r *ResourceControl) Import(ctx context.Context, reader io.Reader, req ImportRequest, validator Validator) error {
+	var imported []storage.UnknownResource
+	err := ForEach(reader, func(resource storage.UnknownResource) error {
+		// Revisions are only checked for individual updates
+		stripped, _, err := withoutRevision(resource)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		resource = *stripped
+		if !utils.StringInSlice(req.Kinds, resource.Kind) {
+			return trace.BadParameter("resource %q cannot be imported, supported are: %v",
+				resource.Kind, req.Kinds)
+		}
+		if utils.StringInSlice(req.OperationKinds, resource.Kind) && !req.WithOperations {
+			return trace.BadParameter("%v resource %q is applied with a cluster operation, "+
+				"use --with-operations to import it", resource.Kind, resource.Metadata.Name)
+		}
+		if err := validator.Validate(resource); err != nil {
+			return trace.Wrap(err, "invalid %v resource %q", resource.Kind, resource.Metadata.Name)
+		}
+		imported = append(imported, resource)
+		return nil
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	order := make(map[string]int, len(req.Kinds))
+	for i, kind := range req.Kinds {
+		order[kind] = i
+	}
+	sort.SliceStable(imported, func(i, j int) bool {
+		return order[imported[i].Kind] < order[imported[j].Kind]
+	})
+	var applied []appliedResource
+	for _, resource := range imported {
+		previous, err := r.getResource(req.SiteKey, resource.Kind, resource.Metadata.Name)
+		if err != nil {
+			return trace.NewAggregate(err, r.revert(ctx, req, applied))
+		}
+		err = r.Resources.Create(ctx, req.createRequest(resource))
+		if err != nil {
+			err = trace.Wrap(err, "failed to import %v resource %q", resource.Kind, resource.Metadata.Name)
+			return trace.NewAggregate(err, r.revert(ctx, req, applied))
+		}
+		applied = append(applied, appliedResource{
+			resource: resource,
+			previous: previous,
+		})
+	}
+	return nil
+}
+
+// revert restores the specified resources to their previous state in reverse order
+func (r *ResourceControl) revert(ctx context.Context, req ImportRequest, applied []appliedResource) error {
+	var errors []error
+	for i := len(applied) - 1; i >= 0; i-- {
+		var err error
+		if previous := applied[i].previous; previous != nil {
+			err = r.Resources.Create(ctx, req.createRequest(*previous))
+		} else {
+			err = r.Resources.Remove(ctx, RemoveRequest{
+				SiteKey:   req.SiteKey,
+				Kind:      applied[i].resource.Kind,
+				Name:      applied[i].resource.Metadata.Name,
+				Force:     true,
+				Owner:     req.Owner,
+				Manual:    req.Manual,
+				Confirmed: req.Confirmed,
+			})
+		}
+		if err != nil {
+			errors = append(errors, trace.Wrap(err, "failed to revert %v resource %q",
+				applied[i].resource.Kind, applied[i].resource.Metadata.Name))
+		}
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// getResource returns the current state of the resource with the specified kind
+// and name or nil if the resource does not exist.
+// Resources that exist as a single instance are matched by kind only
+func (r *ResourceControl) getResource(key ops.SiteKey, kind, name string) (*storage.UnknownResource, error) {
+	return r.findResource(key, kind, name, true)
+}
+
+// findResource returns the resource with the specified kind and name
+// or nil if the resource does not exist.
+// withSecrets specifies whether hidden resource fields are included
+func (r *ResourceControl) findResource(key ops.SiteKey, kind, name string, withSecrets bool) (*storage.UnknownResource, error) {
+	collection, err := r.Resources.GetCollection(ListRequest{
+		SiteKey:     key,
+		Kind:        kind,
+		Name:        name,
+		WithSecrets: withSecrets,
+	})
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	resources, err := collection.Resources()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var matches []teleservices.UnknownResource
+	for _, resource := range resources {
+		if resource.Kind != kind {
+			continue
+		}
+		if resource.Metadata.Name == name {
+			return &storage.UnknownResource{
+				ResourceHeader: resource.ResourceHeader,
+				Raw:            resource.Raw,
+			}, nil
+		}
+		matches = append(matches, resource)
+	}
+	if len(matches) != 1 {
+		return nil, nil
+	}
+	return &storage.UnknownResource{
+		ResourceHeader: matches[0].ResourceHeader,
+		Raw:            matches[0].Raw,
+	}, nil
+}
+
+func (r ImportRequest) createRequest(resource storage.UnknownResource) CreateRequest {
+	return CreateRequest{
+		SiteKey: r.SiteKey,
+		Resource: teleservices.UnknownResource{
+			ResourceHeader: resource.ResourceHeader,
+			Raw:            resource.Raw,
+		},
+		Upsert:    true,
+		Owner:     r.Owner,
+		Manual:    r.Manual,
+		Confirmed: r.Confirmed,
+	}
+}
+
+// appliedResource describes a resource updated during import
+type appliedResource struct {
+	// resource is the imported resource
+	resource storage.UnknownResource
+	// previous is the state of the resource before the import
+	// or nil if the resource has been created
+	previous *storage.UnknownResource
+}
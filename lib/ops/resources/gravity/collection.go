@@ -93,6 +93,64 @@ func (c *githubCollection) WriteYAML(w io.Writer) error {
 	return utils.WriteYAML(c, w)
 }
 
+type authConnectorCollection struct {
+	connectors []teleservices.Resource
+}
+
+// Resources returns the resources collection in the generic format
+func (c *authConnectorCollection) Resources() (resources []teleservices.UnknownResource, err error) {
+	for _, item := range c.connectors {
+		resource, err := utils.ToUnknownResource(item)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// WriteText serializes collection in human-friendly text format
+func (c *authConnectorCollection) WriteText(w io.Writer) error {
+	t := goterm.NewTable(0, 10, 5, ' ', 0)
+	common.PrintTableHeader(t, []string{"Name", "Kind", "Display", "Identity Provider"})
+	for _, conn := range c.connectors {
+		switch conn := conn.(type) {
+		case teleservices.GithubConnector:
+			fmt.Fprintf(t, "%v\t%v\t%v\t%v\n", conn.GetName(),
+				teleservices.KindGithubConnector, conn.GetDisplay(), conn.GetClientID())
+		case teleservices.OIDCConnector:
+			fmt.Fprintf(t, "%v\t%v\t%v\t%v\n", conn.GetName(),
+				teleservices.KindOIDCConnector, conn.GetDisplay(), conn.GetIssuerURL())
+		case teleservices.SAMLConnector:
+			provider := conn.GetEntityDescriptorURL()
+			if provider == "" {
+				provider = conn.GetSSO()
+			}
+			fmt.Fprintf(t, "%v\t%v\t%v\t%v\n", conn.GetName(),
+				teleservices.KindSAMLConnector, conn.GetDisplay(), provider)
+		}
+	}
+	_, err := io.WriteString(w, t.String())
+	return trace.Wrap(err)
+}
+
+// WriteJSON serializes collection into JSON format
+func (c *authConnectorCollection) WriteJSON(w io.Writer) error {
+	return utils.WriteJSON(c, w)
+}
+
+func (c *authConnectorCollection) ToMarshal() interface{} {
+	if len(c.connectors) == 1 {
+		return c.connectors[0]
+	}
+	return c.connectors
+}
+
+// WriteYAML serializes collection into YAML format
+func (c *authConnectorCollection) WriteYAML(w io.Writer) error {
+	return utils.WriteYAML(c, w)
+}
+
 type userCollection struct {
 	users []teleservices.User
 }
@@ -430,6 +488,375 @@ func (r smtpConfigCollection) ToMarshal() interface{} {
 
 type smtpConfigCollection []storage.SMTPConfig
 
+func (c healthReportCollection) Resources() (resources []teleservices.UnknownResource, err error) {
+	for _, item := range c {
+		resource, err := utils.ToUnknownResource(item)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// WriteText serializes collection in human-friendly text format
+func (r healthReportCollection) WriteText(w io.Writer) error {
+	t := goterm.NewTable(0, 10, 5, ' ', 0)
+	common.PrintTableHeader(t, []string{"Schedule", "Recipients", "Webhook", "Last Sent"})
+	for _, config := range r {
+		fmt.Fprintf(t, "%v\t%v\t%v\t%v\n",
+			config.GetSchedule(),
+			formatHealthReportRecipients(config),
+			formatHealthReportWebhook(config),
+			formatHealthReportLastSent(config))
+	}
+	_, err := io.WriteString(w, t.String())
+	return trace.Wrap(err)
+}
+
+func formatHealthReportRecipients(config storage.HealthReport) string {
+	if len(config.GetRecipients()) == 0 {
+		return "-"
+	}
+	return strings.Join(config.GetRecipients(), ",")
+}
+
+func formatHealthReportWebhook(config storage.HealthReport) string {
+	if config.GetWebhookURL() == "" {
+		return "-"
+	}
+	return config.GetWebhookURL()
+}
+
+func formatHealthReportLastSent(config storage.HealthReport) string {
+	if config.GetLastSent().IsZero() {
+		return "never"
+	}
+	return config.GetLastSent().Format(constants.HumanDateFormat)
+}
+
+// WriteJSON serializes collection into JSON format
+func (r healthReportCollection) WriteJSON(w io.Writer) error {
+	return utils.WriteJSON(r, w)
+}
+
+// WriteYAML serializes collection into YAML format
+func (r healthReportCollection) WriteYAML(w io.Writer) error {
+	return utils.WriteYAML(r, w)
+}
+
+func (r healthReportCollection) ToMarshal() interface{} {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return r
+}
+
+type healthReportCollection []storage.HealthReport
+
+// Resources returns the resources collection in the generic format
+func (c admissionWebhookCollection) Resources() (resources []teleservices.UnknownResource, err error) {
+	for _, item := range c {
+		resource, err := utils.ToUnknownResource(item)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// WriteText serializes collection in human-friendly text format
+func (r admissionWebhookCollection) WriteText(w io.Writer) error {
+	t := goterm.NewTable(0, 10, 5, ' ', 0)
+	common.PrintTableHeader(t, []string{"URL", "Resources", "Failure Policy", "Timeout"})
+	for _, webhook := range r {
+		timeout := "default"
+		if webhook.GetTimeout() != 0 {
+			timeout = webhook.GetTimeout().String()
+		}
+		fmt.Fprintf(t, "%v\t%v\t%v\t%v\n",
+			webhook.GetURL(),
+			strings.Join(webhook.GetResources(), ","),
+			webhook.GetFailurePolicy(),
+			timeout)
+	}
+	_, err := io.WriteString(w, t.String())
+	return trace.Wrap(err)
+}
+
+// WriteJSON serializes collection into JSON format
+func (r admissionWebhookCollection) WriteJSON(w io.Writer) error {
+	return utils.WriteJSON(r, w)
+}
+
+// WriteYAML serializes collection into YAML format
+func (r admissionWebhookCollection) WriteYAML(w io.Writer) error {
+	return utils.WriteYAML(r, w)
+}
+
+func (r admissionWebhookCollection) ToMarshal() interface{} {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return r
+}
+
+type admissionWebhookCollection []storage.AdmissionWebhook
+
+func (c operationPolicyCollection) Resources() (resources []teleservices.UnknownResource, err error) {
+	for _, item := range c {
+		resource, err := utils.ToUnknownResource(item)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// WriteText serializes collection in human-friendly text format
+func (r operationPolicyCollection) WriteText(w io.Writer) error {
+	t := goterm.NewTable(0, 10, 5, ' ', 0)
+	common.PrintTableHeader(t, []string{"Rule", "Operations", "Users", "Nodes", "Schedule", "Message"})
+	for _, policy := range r {
+		for _, rule := range policy.GetRules() {
+			fmt.Fprintf(t, "%v\t%v\t%v\t%v\t%v\t%v\n",
+				rule.Name,
+				formatPolicyCriteria(rule.Operations),
+				formatPolicyCriteria(rule.Users),
+				formatPolicyCriteria(rule.Nodes),
+				formatPolicySchedule(rule.Schedule),
+				rule.Message)
+		}
+	}
+	_, err := io.WriteString(w, t.String())
+	return trace.Wrap(err)
+}
+
+// WriteJSON serializes collection into JSON format
+func (r operationPolicyCollection) WriteJSON(w io.Writer) error {
+	return utils.WriteJSON(r, w)
+}
+
+// WriteYAML serializes collection into YAML format
+func (r operationPolicyCollection) WriteYAML(w io.Writer) error {
+	return utils.WriteYAML(r, w)
+}
+
+func (r operationPolicyCollection) ToMarshal() interface{} {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return r
+}
+
+type operationPolicyCollection []storage.OperationPolicy
+
+func (c registryConfigCollection) Resources() (resources []teleservices.UnknownResource, err error) {
+	for _, item := range c {
+		resource, err := utils.ToUnknownResource(item)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// WriteText serializes collection in human-friendly text format
+func (r registryConfigCollection) WriteText(w io.Writer) error {
+	t := goterm.NewTable(0, 10, 5, ' ', 0)
+	common.PrintTableHeader(t, []string{"High Availability", "Sync Interval", "Allow Push", "Mirrored Images"})
+	for _, config := range r {
+		syncInterval := "-"
+		if config.GetHighAvailability() == storage.RegistryHighAvailabilityReplicated {
+			syncInterval = config.GetSyncInterval().String()
+		}
+		mirror := "-"
+		if images := config.GetMirrorImages(); len(images) != 0 {
+			mirror = fmt.Sprintf("%v every %v", strings.Join(images, ", "), config.GetMirrorInterval())
+		}
+		fmt.Fprintf(t, "%v\t%v\t%v\t%v\n",
+			config.GetHighAvailability(), syncInterval, config.GetAllowPush(), mirror)
+	}
+	_, err := io.WriteString(w, t.String())
+	return trace.Wrap(err)
+}
+
+// WriteJSON serializes collection into JSON format
+func (r registryConfigCollection) WriteJSON(w io.Writer) error {
+	return utils.WriteJSON(r, w)
+}
+
+// WriteYAML serializes collection into YAML format
+func (r registryConfigCollection) WriteYAML(w io.Writer) error {
+	return utils.WriteYAML(r, w)
+}
+
+func (r registryConfigCollection) ToMarshal() interface{} {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return r
+}
+
+type registryConfigCollection []storage.RegistryConfig
+
+func (c retentionPolicyCollection) Resources() (resources []teleservices.UnknownResource, err error) {
+	for _, item := range c {
+		resource, err := utils.ToUnknownResource(item)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// WriteText serializes collection in human-friendly text format
+func (r retentionPolicyCollection) WriteText(w io.Writer) error {
+	t := goterm.NewTable(0, 10, 5, ' ', 0)
+	common.PrintTableHeader(t, []string{"Operations", "Audit Log", "Export Webhook"})
+	for _, policy := range r {
+		fmt.Fprintf(t, "%v\t%v\t%v\n",
+			formatRetentionLimits(policy.GetOperations(), "operations"),
+			formatRetentionLimits(policy.GetAudit(), "days"),
+			formatRetentionWebhook(policy))
+	}
+	_, err := io.WriteString(w, t.String())
+	return trace.Wrap(err)
+}
+
+// WriteJSON serializes collection into JSON format
+func (r retentionPolicyCollection) WriteJSON(w io.Writer) error {
+	return utils.WriteJSON(r, w)
+}
+
+// WriteYAML serializes collection into YAML format
+func (r retentionPolicyCollection) WriteYAML(w io.Writer) error {
+	return utils.WriteYAML(r, w)
+}
+
+func (r retentionPolicyCollection) ToMarshal() interface{} {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return r
+}
+
+type retentionPolicyCollection []storage.RetentionPolicy
+
+func (c persistentStorageCollection) Resources() (resources []teleservices.UnknownResource, err error) {
+	for _, item := range c {
+		resource, err := utils.ToUnknownResource(item)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// WriteText serializes collection in human-friendly text format
+func (r persistentStorageCollection) WriteText(w io.Writer) error {
+	t := goterm.NewTable(0, 10, 5, ' ', 0)
+	common.PrintTableHeader(t, []string{"Include Devices", "Exclude Devices", "Include Vendors", "Exclude Vendors", "Quotas"})
+	for _, config := range r {
+		var quotas []string
+		for _, quota := range config.GetQuotas() {
+			quotas = append(quotas, quota.String())
+		}
+		fmt.Fprintf(t, "%v\t%v\t%v\t%v\t%v\n",
+			formatFilter(config.GetIncludeDevices()),
+			formatFilter(config.GetExcludeDevices()),
+			formatFilter(config.GetIncludeVendors()),
+			formatFilter(config.GetExcludeVendors()),
+			formatFilter(quotas))
+	}
+	_, err := io.WriteString(w, t.String())
+	return trace.Wrap(err)
+}
+
+// WriteJSON serializes collection into JSON format
+func (r persistentStorageCollection) WriteJSON(w io.Writer) error {
+	return utils.WriteJSON(r, w)
+}
+
+// WriteYAML serializes collection into YAML format
+func (r persistentStorageCollection) WriteYAML(w io.Writer) error {
+	return utils.WriteYAML(r, w)
+}
+
+func (r persistentStorageCollection) ToMarshal() interface{} {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return r
+}
+
+type persistentStorageCollection []storage.PersistentStorage
+
+func formatFilter(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
+
+func formatRetentionWebhook(policy storage.RetentionPolicy) string {
+	if policy.GetExportWebhookURL() == "" {
+		return "-"
+	}
+	return policy.GetExportWebhookURL()
+}
+
+// formatRetentionLimits returns the textual representation of the retention
+// limits for the records counted in the specified units
+func formatRetentionLimits(limits *storage.RetentionLimits, units string) string {
+	if limits == nil {
+		return "forever"
+	}
+	var parts []string
+	if limits.MaxAge.Duration != 0 {
+		parts = append(parts, fmt.Sprintf("max age %v", limits.MaxAge.Duration))
+	}
+	if limits.MaxCount != 0 {
+		parts = append(parts, fmt.Sprintf("last %v %v", limits.MaxCount, units))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatPolicyCriteria(values []string) string {
+	if len(values) == 0 {
+		return "*"
+	}
+	return strings.Join(values, ",")
+}
+
+func formatPolicySchedule(schedule *storage.OperationPolicySchedule) string {
+	if schedule == nil {
+		return "always"
+	}
+	days := "daily"
+	if len(schedule.Days) != 0 {
+		days = strings.Join(schedule.Days, ",")
+	}
+	timezone := schedule.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return fmt.Sprintf("%v %v-%v %v", days, formatTimeOfDay(schedule.From, "00:00"),
+		formatTimeOfDay(schedule.To, "24:00"), timezone)
+}
+
+func formatTimeOfDay(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
 // WriteText serializes collection in human-friendly text format
 func (r alertCollection) WriteText(w io.Writer) error {
 	t := goterm.NewTable(0, 10, 5, ' ', 0)
@@ -660,7 +1087,21 @@ func (r configCollection) WriteText(w io.Writer) error {
 	}
 	if config := r.GetKubeletConfig(); config != nil {
 		common.PrintCustomTableHeader(t, []string{"Kubelet"}, "-")
-		fmt.Fprintf(t, "%v\n", string(config.Config))
+		if len(config.ExtraArgs) != 0 {
+			fmt.Fprintf(t, "Extra Args:\t%v\n", strings.Join(config.ExtraArgs, " "))
+		}
+		if len(config.Config) != 0 {
+			fmt.Fprintf(t, "%v\n", string(config.Config))
+		}
+	}
+	if config := r.GetAPIServerConfig(); config != nil {
+		common.PrintCustomTableHeader(t, []string{"API Server"}, "-")
+		if len(config.ExtraArgs) != 0 {
+			fmt.Fprintf(t, "Extra Args:\t%v\n", strings.Join(config.ExtraArgs, " "))
+		}
+		if config.AuditPolicy != "" {
+			fmt.Fprintf(t, "Audit Policy:\n%v\n", config.AuditPolicy)
+		}
 	}
 	if config := r.GetGlobalConfig(); config != nil {
 		displayCloudConfig := config.CloudProvider != "" || config.CloudConfig != ""
@@ -677,6 +1118,9 @@ func (r configCollection) WriteText(w io.Writer) error {
 		if len(config.ProxyPortRange) != 0 {
 			fmt.Fprintf(t, "Proxy Port Range:\t%v\n", config.ProxyPortRange)
 		}
+		if config.OverlayMTU != 0 {
+			fmt.Fprintf(t, "Overlay Network MTU:\t%v\n", config.OverlayMTU)
+		}
 		if len(config.FeatureGates) != 0 {
 			fmt.Fprintf(t, "FeatureGates:\t%v\n", formatFeatureGates(config.FeatureGates))
 		}
@@ -0,0 +1,487 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gravity
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/modules"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/resources"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/storage/clusterconfig"
+
+	"github.com/ghodss/yaml"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dryRunCreate prints the change creating the resource from the request
+// would make to the cluster without persisting anything
+func (r *Resources) dryRunCreate(req resources.CreateRequest) error {
+	change, err := r.describeCreate(req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.writeChange(*change))
+}
+
+// dryRunRemove prints the change removing the resource from the request
+// would make to the cluster without persisting anything
+func (r *Resources) dryRunRemove(req resources.RemoveRequest) error {
+	change, err := r.describeRemove(req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.writeChange(*change))
+}
+
+// writeChange outputs the change using the controller's printer
+func (r *Resources) writeChange(change resourceChange) error {
+	var buf bytes.Buffer
+	if err := change.write(&buf); err != nil {
+		return trace.Wrap(err)
+	}
+	r.Printf("%s", buf.String())
+	return nil
+}
+
+// describeCreate validates the resource from the request, sets its defaults
+// the same way Create does and returns the resulting change
+func (r *Resources) describeCreate(req resources.CreateRequest) (*resourceChange, error) {
+	change := resourceChange{
+		kind: req.Resource.Kind,
+		name: req.Resource.Metadata.Name,
+	}
+	var (
+		resource interface{}
+		data     []byte
+		err      error
+	)
+	switch req.Resource.Kind {
+	case teleservices.KindGithubConnector:
+		var conn teleservices.GithubConnector
+		conn, err = teleservices.GetGithubConnectorMarshaler().Unmarshal(req.Resource.Raw)
+		if err == nil {
+			conn.SetClientSecret("")
+			resource = conn
+		}
+	case teleservices.KindOIDCConnector, teleservices.KindSAMLConnector:
+		var conn teleservices.Resource
+		conn, err = ops.UnmarshalAuthConnector(req.Resource.Raw)
+		if err == nil {
+			err = ops.CheckAuthConnector(conn)
+		}
+		if err == nil {
+			resource, err = ops.AuthConnectorWithoutSecrets(conn)
+		}
+	case teleservices.KindUser:
+		resource, err = teleservices.GetUserMarshaler().UnmarshalUser(req.Resource.Raw)
+	case storage.KindToken:
+		var token storage.Token
+		token, err = storage.GetTokenMarshaler().UnmarshalToken(req.Resource.Raw)
+		if err != nil {
+			break
+		}
+		if req.Owner != "" {
+			token.SetUser(req.Owner)
+		} else if token.GetUser() == "" {
+			token.SetUser(r.CurrentUser)
+		}
+		if err = token.CheckAndSetDefaults(); err != nil {
+			break
+		}
+		req.Owner = token.GetUser()
+		// do not print token here as a security precaution
+		change.name = ""
+		change.notes = append(change.notes, fmt.Sprintf("The token would be created for user %q.", token.GetUser()))
+	case storage.KindLogForwarder:
+		var forwarder storage.LogForwarder
+		forwarder, err = storage.GetLogForwarderMarshaler().Unmarshal(req.Resource.Raw)
+		if err == nil {
+			err = forwarder.CheckAndSetDefaults()
+		}
+		resource = forwarder
+	case storage.KindTLSKeyPair:
+		var keyPair storage.TLSKeyPair
+		keyPair, err = storage.UnmarshalTLSKeyPair(req.Resource.Raw)
+		if err == nil {
+			err = keyPair.CheckAndSetDefaults()
+		}
+		if err == nil {
+			// the private key is not printed as a security precaution
+			resource = storage.NewTLSKeyPair([]byte(keyPair.GetCert()), nil)
+		}
+	case teleservices.KindClusterAuthPreference:
+		resource, err = teleservices.GetAuthPreferenceMarshaler().Unmarshal(req.Resource.Raw)
+	case storage.KindSMTPConfig:
+		var config storage.SMTPConfig
+		config, err = storage.UnmarshalSMTPConfig(req.Resource.Raw)
+		if err == nil {
+			err = config.CheckAndSetDefaults()
+		}
+		resource = config
+	case storage.KindHealthReport:
+		var config storage.HealthReport
+		config, err = storage.UnmarshalHealthReport(req.Resource.Raw)
+		if err != nil {
+			break
+		}
+		if len(config.GetRecipients()) != 0 {
+			if err := r.checkSMTPConfig(req.SiteKey, "health report recipients "+
+				"can only be configured when cluster SMTP settings "+
+				"are configured, please create SMTP resource first"); err != nil {
+				return nil, trace.Wrap(err)
+			}
+		}
+		resource = config
+		data, err = storage.MarshalHealthReport(config)
+	case storage.KindAdmissionWebhook:
+		var webhook storage.AdmissionWebhook
+		webhook, err = storage.UnmarshalAdmissionWebhook(req.Resource.Raw)
+		if err == nil {
+			resource = webhook
+			data, err = storage.MarshalAdmissionWebhook(webhook)
+		}
+	case storage.KindOperationPolicy:
+		var policy storage.OperationPolicy
+		policy, err = storage.UnmarshalOperationPolicy(req.Resource.Raw)
+		if err == nil {
+			resource = policy
+			data, err = storage.MarshalOperationPolicy(policy)
+		}
+	case storage.KindRegistryConfig:
+		var config storage.RegistryConfig
+		config, err = storage.UnmarshalRegistryConfig(req.Resource.Raw)
+		if err == nil {
+			resource = config
+			data, err = storage.MarshalRegistryConfig(config)
+		}
+	case storage.KindRetentionPolicy:
+		var policy storage.RetentionPolicy
+		policy, err = storage.UnmarshalRetentionPolicy(req.Resource.Raw)
+		if err == nil {
+			resource = policy
+			data, err = storage.MarshalRetentionPolicy(policy)
+		}
+	case storage.KindPersistentStorage:
+		var config storage.PersistentStorage
+		config, err = storage.UnmarshalPersistentStorage(req.Resource.Raw)
+		if err == nil {
+			resource = config
+			data, err = storage.MarshalPersistentStorage(config)
+		}
+		if !req.Upsert {
+			change.notes = append(change.notes, "The configuration would also be validated "+
+				"against the block devices of the cluster nodes.")
+		}
+	case storage.KindAlert:
+		var alert storage.Alert
+		alert, err = storage.UnmarshalAlert(req.Resource.Raw)
+		if err == nil {
+			err = alert.CheckAndSetDefaults()
+		}
+		if err == nil {
+			resource = alert
+			data, err = storage.MarshalAlert(alert)
+		}
+	case storage.KindAlertTarget:
+		if err := r.checkSMTPConfig(req.SiteKey, "alert target can only "+
+			"be created when cluster SMTP settings "+
+			"are configured, please create SMTP "+
+			"resource first: https://gravitational.com/gravity/docs/cluster/#configuring-monitoring"); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		var target storage.AlertTarget
+		target, err = storage.UnmarshalAlertTarget(req.Resource.Raw)
+		if err == nil {
+			err = target.CheckAndSetDefaults()
+		}
+		if err == nil {
+			resource = target
+			data, err = storage.MarshalAlertTarget(target)
+		}
+	case storage.KindAuthGateway:
+		var gw storage.AuthGateway
+		gw, err = storage.UnmarshalAuthGateway(req.Resource.Raw)
+		if err != nil {
+			break
+		}
+		// Existing auth gateway configuration is updated only with
+		// the fields set in the new resource
+		var current storage.AuthGateway
+		current, err = r.Operator.GetAuthGateway(req.SiteKey)
+		if err != nil && !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		if current != nil {
+			gw.ApplyTo(current)
+			gw = current
+		}
+		resource = gw
+		data, err = storage.MarshalAuthGateway(gw)
+	case storage.KindRuntimeEnvironment:
+		resource, err = storage.UnmarshalEnvironmentVariables(req.Resource.Raw)
+		change.notes = append(change.notes, "An operation would be started to "+
+			"update the runtime environment of the cluster nodes.")
+	case storage.KindClusterConfiguration:
+		resource, err = clusterconfig.Unmarshal(req.Resource.Raw)
+		change.notes = append(change.notes, "An operation would be started to "+
+			"update the cluster configuration.")
+	case "":
+		return nil, trace.BadParameter("missing resource kind")
+	default:
+		return nil, trace.BadParameter("unsupported resource %q, supported are: %v",
+			req.Resource.Kind, modules.GetResources().SupportedResources())
+	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	change.resource = resource
+	if data != nil {
+		change.configMap = newResourceConfigMap(req.Resource.Kind, req.Resource.Metadata.Name, data)
+	}
+	exists, err := r.exists(req.SiteKey, req.Resource.Kind, req.Resource.Metadata.Name, req.Owner)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch {
+	case !exists:
+		change.action = "created"
+	case req.Resource.Kind == storage.KindToken && !req.Upsert:
+		return nil, trace.AlreadyExists("token already exists")
+	case req.Resource.Kind == storage.KindLogForwarder && !req.Upsert:
+		return nil, trace.AlreadyExists("log forwarder %q already exists", req.Resource.Metadata.Name)
+	default:
+		change.action = "updated"
+	}
+	return &change, nil
+}
+
+// describeRemove validates the removal request the same way Remove does
+// and returns the resulting change
+func (r *Resources) describeRemove(req resources.RemoveRequest) (*resourceChange, error) {
+	switch req.Kind {
+	case teleservices.KindGithubConnector, teleservices.KindOIDCConnector, teleservices.KindSAMLConnector,
+		teleservices.KindUser, storage.KindToken, storage.KindLogForwarder, storage.KindTLSKeyPair,
+		storage.KindHealthReport, storage.KindAdmissionWebhook, storage.KindOperationPolicy,
+		storage.KindRegistryConfig, storage.KindRetentionPolicy, storage.KindAlert, storage.KindAlertTarget,
+		storage.KindRuntimeEnvironment, storage.KindClusterConfiguration:
+	case storage.KindSMTPConfig:
+		alertTargets, err := r.Operator.GetAlertTargets(req.SiteKey)
+		if err != nil && !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		if len(alertTargets) != 0 {
+			return nil, trace.BadParameter("SMTP configuration can " +
+				"only be deleted if there is no alert target, " +
+				"please remove alert target using 'gravity " +
+				"resource rm alerttarget' first")
+		}
+	case "":
+		return nil, trace.BadParameter("missing resource kind")
+	default:
+		return nil, trace.BadParameter("unsupported resource %q, supported are: %v",
+			req.Kind, modules.GetResources().SupportedResourcesToRemove())
+	}
+	owner := req.Owner
+	if owner == "" {
+		owner = r.CurrentUser
+	}
+	change := resourceChange{
+		kind:   req.Kind,
+		name:   req.Name,
+		action: "removed",
+	}
+	if req.Kind == storage.KindToken {
+		change.name = ""
+	}
+	exists, err := r.exists(req.SiteKey, req.Kind, req.Name, owner)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !exists {
+		if !req.Force {
+			return nil, trace.NotFound("%v %q not found", req.Kind, req.Name)
+		}
+		change.action = ""
+		return &change, nil
+	}
+	if name, namespace := resourceConfigMapName(req.Kind, req.Name); name != "" {
+		change.notes = append(change.notes, fmt.Sprintf("ConfigMap %v/%v would be deleted.", namespace, name))
+	}
+	switch req.Kind {
+	case storage.KindRuntimeEnvironment, storage.KindClusterConfiguration:
+		change.notes = append(change.notes, "An operation would be started to "+
+			"update the cluster nodes.")
+	}
+	return &change, nil
+}
+
+// exists returns true if the resource with the specified kind and name exists
+func (r *Resources) exists(key ops.SiteKey, kind, name, user string) (bool, error) {
+	collection, err := r.GetCollection(resources.ListRequest{
+		SiteKey: key,
+		Kind:    kind,
+		Name:    name,
+		User:    user,
+	})
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return false, nil
+		}
+		return false, trace.Wrap(err)
+	}
+	items, err := collection.Resources()
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	return len(items) != 0, nil
+}
+
+// checkSMTPConfig makes sure that the cluster SMTP settings are configured.
+// Returns an error with the specified message otherwise
+func (r *Resources) checkSMTPConfig(key ops.SiteKey, message string) error {
+	if _, err := r.Operator.GetSMTPConfig(key); err != nil {
+		if trace.IsNotFound(err) {
+			return trace.BadParameter(message)
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// resourceChange describes the change applying a resource would make to the cluster
+type resourceChange struct {
+	// kind is the resource kind
+	kind string
+	// name is the resource name. It is omitted for the resources
+	// with sensitive names, like tokens
+	name string
+	// action describes what would happen to the resource:
+	// created, updated or removed. Empty action means no change
+	action string
+	// resource is the resource with defaults set
+	resource interface{}
+	// configMap is the resulting ConfigMap for the resources
+	// stored in Kubernetes
+	configMap *v1.ConfigMap
+	// notes lists additional effects of the change
+	notes []string
+}
+
+// write outputs the change to w
+func (r resourceChange) write(w io.Writer) error {
+	subject := r.kind
+	if r.name != "" {
+		subject = fmt.Sprintf("%v %q", r.kind, r.name)
+	}
+	if r.action == "" {
+		fmt.Fprintf(w, "Dry run: %v does not exist, nothing to do.\n", subject)
+		return nil
+	}
+	fmt.Fprintf(w, "Dry run: %v would be %v.\n", subject, r.action)
+	if r.resource != nil {
+		if err := writeYAML(w, r.resource); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if r.configMap != nil {
+		fmt.Fprintf(w, "Resulting ConfigMap %v/%v:\n", r.configMap.Namespace, r.configMap.Name)
+		if err := writeYAML(w, r.configMap); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	for _, note := range r.notes {
+		fmt.Fprintln(w, note)
+	}
+	fmt.Fprintln(w, "No changes have been made.")
+	return nil
+}
+
+// newResourceConfigMap returns the ConfigMap the resource of the specified kind
+// with the serialized data is stored in
+func newResourceConfigMap(kind, name string, data []byte) *v1.ConfigMap {
+	configMapName, namespace := resourceConfigMapName(kind, name)
+	if configMapName == "" {
+		return nil
+	}
+	var labels map[string]string
+	switch kind {
+	case storage.KindAlert:
+		labels = map[string]string{constants.MonitoringType: constants.MonitoringTypeAlert}
+	case storage.KindAlertTarget:
+		labels = map[string]string{constants.MonitoringType: constants.MonitoringTypeAlertTarget}
+	}
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: v1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Data: map[string]string{
+			constants.ResourceSpecKey: string(data),
+		},
+	}
+}
+
+// resourceConfigMapName returns the name and namespace of the ConfigMap
+// the resource of the specified kind is stored in.
+// Returns an empty name for resources not stored in ConfigMaps
+func resourceConfigMapName(kind, name string) (configMapName, namespace string) {
+	switch kind {
+	case storage.KindHealthReport:
+		return constants.HealthReportConfigMap, defaults.KubeSystemNamespace
+	case storage.KindAdmissionWebhook:
+		return constants.AdmissionWebhookConfigMap, defaults.KubeSystemNamespace
+	case storage.KindOperationPolicy:
+		return constants.OperationPolicyConfigMap, defaults.KubeSystemNamespace
+	case storage.KindRegistryConfig:
+		return constants.RegistryConfigConfigMap, defaults.KubeSystemNamespace
+	case storage.KindRetentionPolicy:
+		return constants.RetentionPolicyConfigMap, defaults.KubeSystemNamespace
+	case storage.KindPersistentStorage:
+		return constants.PersistentStorageConfigMap, defaults.KubeSystemNamespace
+	case storage.KindAuthGateway:
+		return constants.AuthGatewayConfigMap, defaults.KubeSystemNamespace
+	case storage.KindAlert:
+		return name, defaults.MonitoringNamespace
+	case storage.KindAlertTarget:
+		return constants.AlertTargetConfigMap, defaults.MonitoringNamespace
+	}
+	return "", ""
+}
+
+// writeYAML outputs the specified object to w in YAML format
+func writeYAML(w io.Writer, object interface{}) error {
+	data, err := yaml.Marshal(object)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = w.Write(data)
+	return trace.Wrap(err)
+}
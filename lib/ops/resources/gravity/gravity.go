@@ -18,6 +18,7 @@ package gravity
 
 import (
 	"context"
+	"strings"
 
 	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/modules"
@@ -79,6 +80,9 @@ func (r *Resources) Create(ctx context.Context, req resources.CreateRequest) err
 		return trace.Wrap(err)
 	}
 	r.Log.Infof("%s.", req)
+	if req.DryRun {
+		return trace.Wrap(r.dryRunCreate(req))
+	}
 	switch req.Resource.Kind {
 	case teleservices.KindGithubConnector:
 		conn, err := teleservices.GetGithubConnectorMarshaler().Unmarshal(req.Resource.Raw)
@@ -89,6 +93,15 @@ func (r *Resources) Create(ctx context.Context, req resources.CreateRequest) err
 			return trace.Wrap(err)
 		}
 		r.Printf("Created Github connector %q\n", conn.GetName())
+	case teleservices.KindOIDCConnector, teleservices.KindSAMLConnector:
+		conn, err := ops.UnmarshalAuthConnector(req.Resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if err := r.Operator.UpsertAuthConnector(ctx, req.SiteKey, conn); err != nil {
+			return trace.Wrap(err)
+		}
+		r.Printf("Created %v connector %q\n", strings.ToUpper(req.Resource.Kind), conn.GetName())
 	case teleservices.KindUser:
 		user, err := teleservices.GetUserMarshaler().UnmarshalUser(req.Resource.Raw)
 		if err != nil {
@@ -191,6 +204,81 @@ func (r *Resources) Create(ctx context.Context, req resources.CreateRequest) err
 			return trace.Wrap(err)
 		}
 		r.Println("Updated cluster SMTP configuration")
+	case storage.KindHealthReport:
+		config, err := storage.UnmarshalHealthReport(req.Resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if len(config.GetRecipients()) != 0 {
+			// Email reports require cluster SMTP settings
+			if _, err := r.Operator.GetSMTPConfig(req.SiteKey); err != nil {
+				if trace.IsNotFound(err) {
+					return trace.BadParameter("health report recipients " +
+						"can only be configured when cluster SMTP settings " +
+						"are configured, please create SMTP resource first")
+				}
+				return trace.Wrap(err)
+			}
+		}
+		err = r.Operator.UpdateHealthReport(ctx, req.SiteKey, config)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		r.Println("Updated cluster health report configuration")
+	case storage.KindAdmissionWebhook:
+		webhook, err := storage.UnmarshalAdmissionWebhook(req.Resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = r.Operator.UpdateAdmissionWebhook(ctx, req.SiteKey, webhook)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		r.Println("Updated cluster admission webhook")
+	case storage.KindOperationPolicy:
+		policy, err := storage.UnmarshalOperationPolicy(req.Resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = r.Operator.UpdateOperationPolicy(ctx, req.SiteKey, policy)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		r.Println("Updated cluster operation policy")
+	case storage.KindRegistryConfig:
+		config, err := storage.UnmarshalRegistryConfig(req.Resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = r.Operator.UpdateRegistryConfig(ctx, req.SiteKey, config)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		r.Println("Updated cluster registry configuration")
+	case storage.KindRetentionPolicy:
+		policy, err := storage.UnmarshalRetentionPolicy(req.Resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = r.Operator.UpdateRetentionPolicy(ctx, req.SiteKey, policy)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		r.Println("Updated cluster retention policy")
+	case storage.KindPersistentStorage:
+		config, err := storage.UnmarshalPersistentStorage(req.Resource.Raw)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = r.Operator.UpdatePersistentStorage(ctx, ops.UpdatePersistentStorageRequest{
+			SiteKey:  req.SiteKey,
+			Resource: config,
+			Force:    req.Upsert,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		r.Println("Updated persistent storage configuration")
 	case storage.KindAlert:
 		alert, err := storage.UnmarshalAlert(req.Resource.Raw)
 		if err != nil {
@@ -258,7 +346,22 @@ func (r *Resources) GetCollection(req resources.ListRequest) (resources.Collecti
 	}
 	r.Log.Debugf("%s.", req)
 	switch req.Kind {
-	case teleservices.KindGithubConnector, teleservices.KindAuthConnector:
+	case teleservices.KindAuthConnector:
+		return r.getAuthConnectors(req)
+	case teleservices.KindOIDCConnector, teleservices.KindSAMLConnector:
+		if req.Name != "" {
+			connector, err := r.Operator.GetAuthConnector(req.SiteKey, req.Kind, req.Name, req.WithSecrets)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			return &authConnectorCollection{connectors: []teleservices.Resource{connector}}, nil
+		}
+		connectors, err := r.Operator.GetAuthConnectors(req.SiteKey, req.Kind, req.WithSecrets)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &authConnectorCollection{connectors: connectors}, nil
+	case teleservices.KindGithubConnector:
 		if req.Name != "" {
 			connector, err := r.Operator.GetGithubConnector(req.SiteKey, req.Name, req.WithSecrets)
 			if err != nil {
@@ -357,6 +460,42 @@ func (r *Resources) GetCollection(req resources.ListRequest) (resources.Collecti
 			return nil, trace.Wrap(err)
 		}
 		return smtpConfigCollection{config}, nil
+	case storage.KindHealthReport:
+		config, err := r.Operator.GetHealthReport(req.SiteKey)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return healthReportCollection{config}, nil
+	case storage.KindAdmissionWebhook:
+		webhook, err := r.Operator.GetAdmissionWebhook(req.SiteKey)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return admissionWebhookCollection{webhook}, nil
+	case storage.KindOperationPolicy:
+		policy, err := r.Operator.GetOperationPolicy(req.SiteKey)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return operationPolicyCollection{policy}, nil
+	case storage.KindRegistryConfig:
+		config, err := r.Operator.GetRegistryConfig(req.SiteKey)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return registryConfigCollection{config}, nil
+	case storage.KindRetentionPolicy:
+		policy, err := r.Operator.GetRetentionPolicy(req.SiteKey)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return retentionPolicyCollection{policy}, nil
+	case storage.KindPersistentStorage:
+		config, err := r.Operator.GetPersistentStorage(req.SiteKey)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return persistentStorageCollection{config}, nil
 	case storage.KindAlert:
 		alerts, err := r.Operator.GetAlerts(req.SiteKey)
 		if err != nil {
@@ -402,12 +541,44 @@ func (r *Resources) GetCollection(req resources.ListRequest) (resources.Collecti
 		req.Kind, modules.GetResources().SupportedResources())
 }
 
+// getAuthConnectors returns all auth connectors of the cluster,
+// or the connector with the requested name regardless of its kind
+func (r *Resources) getAuthConnectors(req resources.ListRequest) (resources.Collection, error) {
+	var connectors []teleservices.Resource
+	github, err := r.Operator.GetGithubConnectors(req.SiteKey, req.WithSecrets)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, connector := range github {
+		connectors = append(connectors, connector)
+	}
+	for _, kind := range ops.AuthConnectorKinds {
+		other, err := r.Operator.GetAuthConnectors(req.SiteKey, kind, req.WithSecrets)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		connectors = append(connectors, other...)
+	}
+	if req.Name == "" {
+		return &authConnectorCollection{connectors: connectors}, nil
+	}
+	for _, connector := range connectors {
+		if connector.GetName() == req.Name {
+			return &authConnectorCollection{connectors: []teleservices.Resource{connector}}, nil
+		}
+	}
+	return nil, trace.NotFound("auth connector %q not found", req.Name)
+}
+
 // Remove removes the specified resource
 func (r *Resources) Remove(ctx context.Context, req resources.RemoveRequest) error {
 	if err := req.Check(); err != nil {
 		return trace.Wrap(err)
 	}
 	r.Log.Infof("%s.", req)
+	if req.DryRun {
+		return trace.Wrap(r.dryRunRemove(req))
+	}
 	switch req.Kind {
 	case teleservices.KindGithubConnector:
 		if err := r.Operator.DeleteGithubConnector(ctx, req.SiteKey, req.Name); err != nil {
@@ -417,6 +588,14 @@ func (r *Resources) Remove(ctx context.Context, req resources.RemoveRequest) err
 			return trace.Wrap(err)
 		}
 		r.Printf("Github connector %q has been deleted\n", req.Name)
+	case teleservices.KindOIDCConnector, teleservices.KindSAMLConnector:
+		if err := r.Operator.DeleteAuthConnector(ctx, req.SiteKey, req.Kind, req.Name); err != nil {
+			if trace.IsNotFound(err) && req.Force {
+				return nil
+			}
+			return trace.Wrap(err)
+		}
+		r.Printf("%v connector %q has been deleted\n", strings.ToUpper(req.Kind), req.Name)
 	case teleservices.KindUser:
 		if err := r.Operator.DeleteUser(ctx, req.SiteKey, req.Name); err != nil {
 			if trace.IsNotFound(err) && req.Force {
@@ -476,6 +655,46 @@ func (r *Resources) Remove(ctx context.Context, req resources.RemoveRequest) err
 			return trace.Wrap(err)
 		}
 		r.Println("SMTP configuration has been deleted")
+	case storage.KindHealthReport:
+		if err := r.Operator.DeleteHealthReport(ctx, req.SiteKey); err != nil {
+			if trace.IsNotFound(err) && req.Force {
+				return nil
+			}
+			return trace.Wrap(err)
+		}
+		r.Println("Health report configuration has been deleted")
+	case storage.KindAdmissionWebhook:
+		if err := r.Operator.DeleteAdmissionWebhook(ctx, req.SiteKey); err != nil {
+			if trace.IsNotFound(err) && req.Force {
+				return nil
+			}
+			return trace.Wrap(err)
+		}
+		r.Println("Admission webhook has been deleted")
+	case storage.KindOperationPolicy:
+		if err := r.Operator.DeleteOperationPolicy(ctx, req.SiteKey); err != nil {
+			if trace.IsNotFound(err) && req.Force {
+				return nil
+			}
+			return trace.Wrap(err)
+		}
+		r.Println("Operation policy has been deleted")
+	case storage.KindRegistryConfig:
+		if err := r.Operator.DeleteRegistryConfig(ctx, req.SiteKey); err != nil {
+			if trace.IsNotFound(err) && req.Force {
+				return nil
+			}
+			return trace.Wrap(err)
+		}
+		r.Println("Registry configuration has been reset to defaults")
+	case storage.KindRetentionPolicy:
+		if err := r.Operator.DeleteRetentionPolicy(ctx, req.SiteKey); err != nil {
+			if trace.IsNotFound(err) && req.Force {
+				return nil
+			}
+			return trace.Wrap(err)
+		}
+		r.Println("Retention policy has been deleted")
 	case storage.KindAlert:
 		if err := r.Operator.DeleteAlert(ctx, req.SiteKey, req.Name); err != nil {
 			if trace.IsNotFound(err) && req.Force {
@@ -518,6 +737,12 @@ func Validate(resource storage.UnknownResource) (err error) {
 	switch resource.Kind {
 	case teleservices.KindGithubConnector:
 		_, err = teleservices.GetGithubConnectorMarshaler().Unmarshal(resource.Raw)
+	case teleservices.KindOIDCConnector, teleservices.KindSAMLConnector:
+		var connector teleservices.Resource
+		connector, err = ops.UnmarshalAuthConnector(resource.Raw)
+		if err == nil {
+			err = ops.CheckAuthConnector(connector)
+		}
 	case teleservices.KindUser:
 		_, err = teleservices.GetUserMarshaler().UnmarshalUser(resource.Raw)
 	case storage.KindToken:
@@ -530,6 +755,18 @@ func Validate(resource storage.UnknownResource) (err error) {
 		_, err = teleservices.GetAuthPreferenceMarshaler().Unmarshal(resource.Raw)
 	case storage.KindSMTPConfig:
 		_, err = storage.UnmarshalSMTPConfig(resource.Raw)
+	case storage.KindHealthReport:
+		_, err = storage.UnmarshalHealthReport(resource.Raw)
+	case storage.KindAdmissionWebhook:
+		_, err = storage.UnmarshalAdmissionWebhook(resource.Raw)
+	case storage.KindOperationPolicy:
+		_, err = storage.UnmarshalOperationPolicy(resource.Raw)
+	case storage.KindRegistryConfig:
+		_, err = storage.UnmarshalRegistryConfig(resource.Raw)
+	case storage.KindRetentionPolicy:
+		_, err = storage.UnmarshalRetentionPolicy(resource.Raw)
+	case storage.KindPersistentStorage:
+		_, err = storage.UnmarshalPersistentStorage(resource.Raw)
 	case storage.KindAlert:
 		_, err = storage.UnmarshalAlert(resource.Raw)
 	case storage.KindAlertTarget:
@@ -540,6 +777,8 @@ func Validate(resource storage.UnknownResource) (err error) {
 		_, err = storage.UnmarshalEnvironmentVariables(resource.Raw)
 	case storage.KindClusterConfiguration:
 		_, err = clusterconfig.Unmarshal(resource.Raw)
+	case storage.KindClusterDNS:
+		_, err = storage.UnmarshalClusterDNS(resource.Raw)
 	default:
 		return trace.NotImplemented("unsupported resource %q, supported are: %v",
 			resource.Kind, modules.GetResources().SupportedResources())
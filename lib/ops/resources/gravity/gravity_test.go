@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/ops"
@@ -89,6 +90,39 @@ func (s *GravityResourcesSuite) TestGithubConnectorResource(c *check.C) {
 	compare.DeepCompare(c, collection, &githubCollection{[]teleservices.GithubConnector{}})
 }
 
+func (s *GravityResourcesSuite) TestOIDCConnectorResource(c *check.C) {
+	err := s.r.Create(context.TODO(), resources.CreateRequest{SiteKey: s.cluster.Key(), Resource: toUnknown(c, oidcConnector)})
+	c.Assert(err, check.IsNil)
+
+	collection, err := s.r.GetCollection(resources.ListRequest{SiteKey: s.cluster.Key(), Kind: teleservices.KindOIDCConnector, WithSecrets: true})
+	c.Assert(err, check.IsNil)
+	compare.DeepCompare(c, collection, &authConnectorCollection{[]teleservices.Resource{oidcConnector}})
+
+	collection, err = s.r.GetCollection(resources.ListRequest{SiteKey: s.cluster.Key(), Kind: teleservices.KindAuthConnector, Name: "oidc"})
+	c.Assert(err, check.IsNil)
+	connectors := collection.(*authConnectorCollection).connectors
+	c.Assert(connectors, check.HasLen, 1)
+	c.Assert(connectors[0].(teleservices.OIDCConnector).GetClientSecret(), check.Equals, "")
+
+	err = s.r.Remove(context.TODO(), resources.RemoveRequest{SiteKey: s.cluster.Key(), Kind: teleservices.KindOIDCConnector, Name: "oidc"})
+	c.Assert(err, check.IsNil)
+
+	collection, err = s.r.GetCollection(resources.ListRequest{SiteKey: s.cluster.Key(), Kind: teleservices.KindOIDCConnector})
+	c.Assert(err, check.IsNil)
+	compare.DeepCompare(c, collection, &authConnectorCollection{[]teleservices.Resource{}})
+}
+
+func (s *GravityResourcesSuite) TestRejectsInvalidOIDCConnector(c *check.C) {
+	connector := teleservices.NewOIDCConnector("invalid", teleservices.OIDCConnectorSpecV2{
+		IssuerURL:    "https://accounts.example.com",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://ops.example.com/callback",
+	})
+	err := s.r.Create(context.TODO(), resources.CreateRequest{SiteKey: s.cluster.Key(), Resource: toUnknown(c, connector)})
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+}
+
 func (s *GravityResourcesSuite) TestUser(c *check.C) {
 	err := s.r.Create(context.TODO(), resources.CreateRequest{SiteKey: s.cluster.Key(), Resource: toUnknown(c, user)})
 	c.Assert(err, check.IsNil)
@@ -126,6 +160,42 @@ func (s *GravityResourcesSuite) TestToken(c *check.C) {
 	c.Assert(err, check.FitsTypeOf, trace.NotFound(""))
 }
 
+func (s *GravityResourcesSuite) TestDryRun(c *check.C) {
+	req := resources.CreateRequest{SiteKey: s.cluster.Key(), Resource: toUnknown(c, githubConnector), DryRun: true}
+	change, err := s.r.describeCreate(req)
+	c.Assert(err, check.IsNil)
+	c.Assert(change.action, check.Equals, "created")
+	c.Assert(change.resource.(teleservices.GithubConnector).GetClientSecret(), check.Equals, "")
+
+	err = s.r.Create(context.TODO(), req)
+	c.Assert(err, check.IsNil)
+
+	collection, err := s.r.GetCollection(resources.ListRequest{SiteKey: s.cluster.Key(), Kind: teleservices.KindGithubConnector})
+	c.Assert(err, check.IsNil)
+	compare.DeepCompare(c, collection, &githubCollection{[]teleservices.GithubConnector{}})
+
+	removeReq := resources.RemoveRequest{SiteKey: s.cluster.Key(), Kind: teleservices.KindGithubConnector, Name: "github", DryRun: true}
+	err = s.r.Remove(context.TODO(), removeReq)
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+
+	removeReq.Force = true
+	change, err = s.r.describeRemove(removeReq)
+	c.Assert(err, check.IsNil)
+	c.Assert(change.action, check.Equals, "")
+}
+
+func (s *GravityResourcesSuite) TestDryRunConfigMap(c *check.C) {
+	configMap := newResourceConfigMap(storage.KindAlert, "cpu", []byte("data"))
+	c.Assert(configMap.Namespace, check.Equals, defaults.MonitoringNamespace)
+	c.Assert(configMap.Name, check.Equals, "cpu")
+	c.Assert(configMap.Labels, check.DeepEquals, map[string]string{
+		constants.MonitoringType: constants.MonitoringTypeAlert,
+	})
+	c.Assert(configMap.Data, check.DeepEquals, map[string]string{constants.ResourceSpecKey: "data"})
+
+	c.Assert(newResourceConfigMap(storage.KindLogForwarder, "forwarder", []byte("data")), check.IsNil)
+}
+
 func toUnknown(c *check.C, resource teleservices.Resource) teleservices.UnknownResource {
 	unknown, err := utils.ToUnknownResource(resource)
 	c.Assert(err, check.IsNil)
@@ -146,6 +216,20 @@ var (
 		},
 	})
 
+	oidcConnector = teleservices.NewOIDCConnector("oidc", teleservices.OIDCConnectorSpecV2{
+		IssuerURL:    "https://accounts.example.com",
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://ops.example.com/portalapi/v1/oidc/callback",
+		ClaimsToRoles: []teleservices.ClaimMapping{
+			{
+				Claim: "groups",
+				Value: "admins",
+				Roles: []string{"@teleadmin"},
+			},
+		},
+	})
+
 	user = storage.NewUser("test", storage.UserSpecV2{
 		AccountID: defaults.SystemAccountID,
 		Type:      storage.AgentUser,
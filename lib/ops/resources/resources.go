@@ -73,6 +73,10 @@ type ValidateFunc func(storage.UnknownResource) error
 type ResourceControl struct {
 	// Resources is the specific resource controller
 	Resources
+	// Updater optionally specifies the service that updates resources
+	// labeled with a revision. Without it, such updates are rejected
+	// unless they are forced
+	Updater ops.ResourceUpdater
 }
 
 // CreateRequest describes a request to create a resource
@@ -92,6 +96,18 @@ type CreateRequest struct {
 	// Confirmed defines whether the operation has been explicitly approved.
 	// This attribute is operation-specific
 	Confirmed bool
+	// SkipNodes lists hostnames or advertise IPs of nodes to exclude
+	// from the operation.
+	// This attribute is operation-specific
+	SkipNodes []string
+	// DryRun only validates the resource and outputs the change
+	// creating it would make without persisting anything
+	DryRun bool
+	// Revision optionally specifies the revision of the resource the update
+	// is based on. Only used for resources applied with a cluster operation
+	// which verifies the revision when the operation is created.
+	// This attribute is operation-specific
+	Revision string
 }
 
 // String returns the request string representation.
@@ -169,6 +185,9 @@ type RemoveRequest struct {
 	// Confirmed defines whether the operation has been explicitly approved.
 	// This attribute is operation-specific
 	Confirmed bool
+	// DryRun only validates the request and outputs the change
+	// removing the resource would make without persisting anything
+	DryRun bool
 }
 
 // String returns the request string representation.
@@ -194,6 +213,11 @@ func (r *RemoveRequest) Check() error {
 	switch kind {
 	case storage.KindAlertTarget:
 	case storage.KindSMTPConfig:
+	case storage.KindHealthReport:
+	case storage.KindAdmissionWebhook:
+	case storage.KindOperationPolicy:
+	case storage.KindRegistryConfig:
+	case storage.KindRetentionPolicy:
 	case storage.KindRuntimeEnvironment:
 	case storage.KindClusterConfiguration:
 	default:
@@ -225,18 +249,66 @@ func NewControl(resources Resources) *ResourceControl {
 	}
 }
 
-// Create creates all resources found in the provided data
+// Create creates all resources found in the provided data.
+//
+// Resources labeled with a revision are only updated if the revision
+// matches the current revision of the resource unless req.Upsert is set.
+// The revision is compared and the resource is updated atomically
+// by the cluster with r.Updater.
+// For resources applied with a cluster operation, the revision is passed
+// to the operation and is compared when the operation is created
 func (r *ResourceControl) Create(ctx context.Context, reader io.Reader, req CreateRequest) (err error) {
-	err = ForEach(reader, func(res storage.UnknownResource) error {
-		req.Resource = teleservices.UnknownResource{
+	err = ForEach(reader, func(resource storage.UnknownResource) error {
+		res, revision, err := withoutRevision(resource)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		createReq := req
+		switch {
+		case revision == "" || req.Upsert:
+		case isOperationResource(res.Kind):
+			// The revision is verified when the operation is created
+			createReq.Revision = revision
+		default:
+			err := r.updateWithRevision(ctx, *res, revision, req)
+			if err != nil || !req.DryRun {
+				return trace.Wrap(err)
+			}
+			// The revision has been verified, output the changes of the dry run
+			createReq.Upsert = true
+		}
+		createReq.Resource = teleservices.UnknownResource{
 			ResourceHeader: res.ResourceHeader,
 			Raw:            res.Raw,
 		}
-		return trace.Wrap(r.Resources.Create(ctx, req))
+		return trace.Wrap(r.Resources.Create(ctx, createReq))
 	})
 	return trace.Wrap(err)
 }
 
+// updateWithRevision updates the specified resource with r.Updater
+// if it has not been modified since the given revision
+func (r *ResourceControl) updateWithRevision(ctx context.Context, resource storage.UnknownResource, revision string, req CreateRequest) error {
+	if r.Updater == nil {
+		return trace.NotImplemented("%v %q is labeled with revision %v but revisions "+
+			"cannot be verified, use --force to overwrite it", resource.Kind, resource.Metadata.Name, revision)
+	}
+	err := r.Updater.UpdateResource(ctx, ops.UpdateResourceRequest{
+		SiteKey:  req.SiteKey,
+		Resource: resource.Raw,
+		Revision: revision,
+		Owner:    req.Owner,
+		DryRun:   req.DryRun,
+	})
+	return trace.Wrap(err)
+}
+
+// isOperationResource returns true if the resources of the specified kind
+// are applied with a cluster operation
+func isOperationResource(kind string) bool {
+	return kind == storage.KindRuntimeEnvironment || kind == storage.KindClusterConfiguration
+}
+
 // Get retrieves the specified resource collection and outputs it
 func (r *ResourceControl) Get(w io.Writer, req ListRequest, format constants.Format) error {
 	collection, err := r.Resources.GetCollection(req)
@@ -247,14 +319,57 @@ func (r *ResourceControl) Get(w io.Writer, req ListRequest, format constants.For
 	case constants.EncodingText:
 		return collection.WriteText(w)
 	case constants.EncodingJSON:
-		return collection.WriteJSON(w)
+		revisions, err := r.withRevisions(req, collection)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return utils.WriteJSON(revisions, w)
 	case constants.EncodingYAML:
-		return collection.WriteYAML(w)
+		revisions, err := r.withRevisions(req, collection)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return utils.WriteYAML(revisions, w)
 	}
 	return trace.BadParameter("unsupported format %q, supported are: %v",
 		format, constants.OutputFormats)
 }
 
+// withRevisions returns the resources of the specified collection labeled
+// with their current revisions obtained from r.Updater.
+// The resources are not labeled if revisions are not supported
+func (r *ResourceControl) withRevisions(req ListRequest, collection Collection) (revisionCollection, error) {
+	resources, err := collection.Resources()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	labeled := make(revisionCollection, 0, len(resources))
+	for _, resource := range resources {
+		if r.Updater != nil {
+			revision, err := r.Updater.GetResourceRevision(context.TODO(), ops.GetResourceRevisionRequest{
+				SiteKey: req.SiteKey,
+				Kind:    resource.Kind,
+				Name:    resource.Metadata.Name,
+			})
+			if err != nil && !trace.IsNotFound(err) {
+				return nil, trace.Wrap(err)
+			}
+			if revision != "" {
+				withLabel, err := setRevision(resource, revision)
+				if err != nil {
+					return nil, trace.Wrap(err)
+				}
+				resource = *withLabel
+			}
+		}
+		labeled = append(labeled, storage.UnknownResource{
+			ResourceHeader: resource.ResourceHeader,
+			Raw:            resource.Raw,
+		})
+	}
+	return labeled, nil
+}
+
 // Remove removes the specified resource
 func (r *ResourceControl) Remove(ctx context.Context, req RemoveRequest) error {
 	err := r.Resources.Remove(ctx, req)
@@ -22,9 +22,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/storage/keyval"
+
 	"github.com/ghodss/yaml"
 	teleservices "github.com/gravitational/teleport/lib/services"
 	"github.com/gravitational/trace"
@@ -68,18 +73,307 @@ kind1/resource3
 `)
 }
 
+func (s *ResourceControlSuite) TestExportsAndImportsResources(c *check.C) {
+	source := &testResources{}
+	control := NewControl(source)
+	err := control.Create(context.TODO(), strings.NewReader(resourceBytes), CreateRequest{})
+	c.Assert(err, check.IsNil)
+
+	var w bytes.Buffer
+	err = control.Export(&w, ExportRequest{Kinds: []string{"kind2", "kind1", "kind3"}})
+	c.Assert(err, check.IsNil)
+
+	target := &testResources{}
+	err = NewControl(target).Import(context.TODO(), &w, ImportRequest{
+		Kinds: []string{"kind1", "kind2"},
+	}, noopValidator)
+	c.Assert(err, check.IsNil)
+	c.Assert(target.names(), check.DeepEquals, []string{"resource1", "resource3", "resource2"})
+}
+
+func (s *ResourceControlSuite) TestRevertsFailedImport(c *check.C) {
+	resources := &testResources{}
+	control := NewControl(resources)
+	err := control.Create(context.TODO(), strings.NewReader(`
+kind: kind1
+metadata:
+  name: resource1
+spec:
+  value: old
+`), CreateRequest{})
+	c.Assert(err, check.IsNil)
+
+	resources.failKind = "kind2"
+	err = control.Import(context.TODO(), strings.NewReader(`
+kind: kind1
+metadata:
+  name: resource1
+spec:
+  value: new
+---
+kind: kind1
+metadata:
+  name: resource3
+---
+kind: kind2
+metadata:
+  name: resource2
+`), ImportRequest{Kinds: []string{"kind1", "kind2"}}, noopValidator)
+	c.Assert(err, check.NotNil)
+	c.Assert(resources.names(), check.DeepEquals, []string{"resource1"})
+	c.Assert(string(resources.resources[0].Raw), check.Matches, `.*"old".*`)
+}
+
+func (s *ResourceControlSuite) TestRejectsUnsupportedImport(c *check.C) {
+	resources := &testResources{}
+	err := NewControl(resources).Import(context.TODO(), strings.NewReader(resourceBytes),
+		ImportRequest{Kinds: []string{"kind1"}}, noopValidator)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(resources.resources, check.HasLen, 0)
+}
+
+func (s *ResourceControlSuite) TestImportsOperationResourcesExplicitly(c *check.C) {
+	resources := &testResources{}
+	req := ImportRequest{Kinds: []string{"kind1", "kind2"}, OperationKinds: []string{"kind2"}}
+	err := NewControl(resources).Import(context.TODO(), strings.NewReader(resourceBytes), req, noopValidator)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(resources.resources, check.HasLen, 0)
+
+	req.WithOperations = true
+	err = NewControl(resources).Import(context.TODO(), strings.NewReader(resourceBytes), req, noopValidator)
+	c.Assert(err, check.IsNil)
+	c.Assert(resources.names(), check.DeepEquals, []string{"resource1", "resource3", "resource2"})
+}
+
+func (s *ResourceControlSuite) TestRejectsStaleRevision(c *check.C) {
+	resources := &testResources{}
+	control := NewControl(resources)
+	control.Updater = newTestUpdater(c, resources)
+	key := ops.SiteKey{AccountID: "account", SiteDomain: "example.com"}
+	err := control.Create(context.TODO(), strings.NewReader(`
+kind: kind1
+metadata:
+  name: resource1
+spec:
+  value: old
+`), CreateRequest{SiteKey: key})
+	c.Assert(err, check.IsNil)
+
+	read := getResource(c, control, key)
+	c.Assert(read, check.Matches, `(?s).*gravitational.io/revision: "1".*`)
+
+	// Update based on the current revision succeeds and is stored without the revision
+	update := strings.Replace(read, "old", "new", 1)
+	err = control.Create(context.TODO(), strings.NewReader(update), CreateRequest{SiteKey: key})
+	c.Assert(err, check.IsNil)
+	c.Assert(resources.resources, check.HasLen, 1)
+	c.Assert(string(resources.resources[0].Raw), check.Matches, `.*"new".*`)
+	c.Assert(string(resources.resources[0].Raw), check.Not(check.Matches), `.*revision.*`)
+
+	// Update based on the stale revision is rejected
+	stale := strings.Replace(read, "old", "other", 1)
+	err = control.Create(context.TODO(), strings.NewReader(stale), CreateRequest{SiteKey: key})
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(string(resources.resources[0].Raw), check.Matches, `.*"new".*`)
+
+	// Forced update ignores the revision
+	err = control.Create(context.TODO(), strings.NewReader(stale), CreateRequest{SiteKey: key, Upsert: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(string(resources.resources[0].Raw), check.Matches, `.*"other".*`)
+}
+
+func (s *ResourceControlSuite) TestRejectsStaleRevisionAfterRevert(c *check.C) {
+	resources := &testResources{}
+	control := NewControl(resources)
+	control.Updater = newTestUpdater(c, resources)
+	key := ops.SiteKey{AccountID: "account", SiteDomain: "example.com"}
+	err := control.Create(context.TODO(), strings.NewReader(`
+kind: kind1
+metadata:
+  name: resource1
+spec:
+  value: old
+`), CreateRequest{SiteKey: key})
+	c.Assert(err, check.IsNil)
+	read := getResource(c, control, key)
+
+	// Change the resource and then change it back
+	err = control.Create(context.TODO(), strings.NewReader(strings.Replace(read, "old", "new", 1)),
+		CreateRequest{SiteKey: key})
+	c.Assert(err, check.IsNil)
+	err = control.Create(context.TODO(), strings.NewReader(strings.Replace(getResource(c, control, key), "new", "old", 1)),
+		CreateRequest{SiteKey: key})
+	c.Assert(err, check.IsNil)
+	c.Assert(getResource(c, control, key), check.Matches, `(?s).*gravitational.io/revision: "3".*`)
+
+	// The contents match the first read but the revision is stale
+	err = control.Create(context.TODO(), strings.NewReader(strings.Replace(read, "old", "other", 1)),
+		CreateRequest{SiteKey: key})
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(string(resources.resources[0].Raw), check.Matches, `.*"old".*`)
+
+	// Updates made without revisions are detected by the contents
+	err = control.Create(context.TODO(), strings.NewReader(strings.Replace(read, "old", "forced", 1)),
+		CreateRequest{SiteKey: key, Upsert: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(getResource(c, control, key), check.Matches, `(?s).*gravitational.io/revision: "4".*`)
+}
+
+func (s *ResourceControlSuite) TestChecksRevisionWhenStartingOperation(c *check.C) {
+	backend := newTestRevisionBackend(c)
+	tracker := RevisionTracker{Backend: backend}
+	key := ops.SiteKey{AccountID: "account", SiteDomain: "example.com"}
+	startOperation := func(id string) func() (*ops.SiteOperationKey, error) {
+		return func() (*ops.SiteOperationKey, error) {
+			backend.operations[id] = &storage.SiteOperation{ID: id, State: ops.OperationStateUpdateInProgress}
+			return &ops.SiteOperationKey{AccountID: key.AccountID, SiteDomain: key.SiteDomain, OperationID: id}, nil
+		}
+	}
+
+	_, err := tracker.StartOperation(key, "kind1", "resource1", "0", startOperation("op1"))
+	c.Assert(err, check.IsNil)
+	// The revision is incremented when the operation starts
+	_, err = tracker.StartOperation(key, "kind1", "resource1", "0", startOperation("op2"))
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(backend.operations["op2"], check.IsNil)
+
+	// and once the operation finishes
+	backend.operations["op1"].State = ops.OperationStateCompleted
+	_, err = tracker.StartOperation(key, "kind1", "resource1", "1", startOperation("op2"))
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+	_, err = tracker.StartOperation(key, "kind1", "resource1", "2", startOperation("op2"))
+	c.Assert(err, check.IsNil)
+
+	// An empty revision skips the check but the operation is still recorded
+	backend.operations["op2"].State = ops.OperationStateFailed
+	_, err = tracker.StartOperation(key, "kind1", "resource1", "", startOperation("op3"))
+	c.Assert(err, check.IsNil)
+	revision, err := backend.GetResourceRevision(key.SiteDomain, "kind1", "resource1")
+	c.Assert(err, check.IsNil)
+	c.Assert(revision.Revision, check.Equals, int64(5))
+	c.Assert(revision.OperationID, check.Equals, "op3")
+}
+
+func (s *ResourceControlSuite) TestRequiresUpdaterForRevision(c *check.C) {
+	resources := &testResources{}
+	control := NewControl(resources)
+	err := control.Create(context.TODO(), strings.NewReader(`
+kind: kind1
+metadata:
+  name: resource1
+  labels:
+    gravitational.io/revision: abc
+spec:
+  value: new
+`), CreateRequest{})
+	c.Assert(trace.IsNotImplemented(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(resources.resources, check.HasLen, 0)
+}
+
+func (s *ResourceControlSuite) TestRevisionIgnoresFieldOrder(c *check.C) {
+	first, err := Digest(teleservices.UnknownResource{
+		Raw: []byte(`{"kind":"kind1","metadata":{"name":"resource1"},"spec":{"a":1,"b":2}}`),
+	})
+	c.Assert(err, check.IsNil)
+	second, err := Digest(teleservices.UnknownResource{
+		Raw: []byte(`{"spec":{"b":2,"a":1},"metadata":{"name":"resource1","labels":{"gravitational.io/revision":"abc"}},"kind":"kind1"}`),
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(first, check.Equals, second)
+}
+
+// getResource returns the resource of kind "kind1" labeled with its revision
+func getResource(c *check.C, control *ResourceControl, key ops.SiteKey) string {
+	var w bytes.Buffer
+	err := control.Get(&w, ListRequest{SiteKey: key, Kind: "kind1"}, "yaml")
+	c.Assert(err, check.IsNil)
+	return w.String()
+}
+
+func newTestUpdater(c *check.C, resources Resources) *testUpdater {
+	return &testUpdater{
+		resources: resources,
+		tracker:   RevisionTracker{Backend: newTestRevisionBackend(c)},
+	}
+}
+
+// testUpdater updates resources guarded by revisions the way the cluster does
+type testUpdater struct {
+	resources Resources
+	tracker   RevisionTracker
+}
+
+func (r *testUpdater) UpdateResource(ctx context.Context, req ops.UpdateResourceRequest) error {
+	return r.tracker.UpdateWithRevision(ctx, r.resources, req)
+}
+
+func (r *testUpdater) GetResourceRevision(ctx context.Context, req ops.GetResourceRevisionRequest) (string, error) {
+	return r.tracker.GetRevision(r.resources, req)
+}
+
+func newTestRevisionBackend(c *check.C) *testRevisionBackend {
+	backend, err := keyval.NewBolt(keyval.BoltConfig{Path: filepath.Join(c.MkDir(), "bolt.db")})
+	c.Assert(err, check.IsNil)
+	return &testRevisionBackend{
+		Backend:    backend,
+		operations: make(map[string]*storage.SiteOperation),
+	}
+}
+
+// testRevisionBackend stores revisions in a Bolt backend and
+// keeps operations in memory
+type testRevisionBackend struct {
+	storage.Backend
+	operations map[string]*storage.SiteOperation
+}
+
+func (r *testRevisionBackend) GetSiteOperation(clusterName, operationID string) (*storage.SiteOperation, error) {
+	operation, ok := r.operations[operationID]
+	if !ok {
+		return nil, trace.NotFound("operation %v not found", operationID)
+	}
+	return operation, nil
+}
+
+var noopValidator = ValidateFunc(func(storage.UnknownResource) error { return nil })
+
 // testResources keeps created resources in memory
 type testResources struct {
 	resources []teleservices.UnknownResource
+	// failKind specifies the kind of resources that fail to be created
+	failKind string
 }
 
 func (r *testResources) Create(ctx context.Context, req CreateRequest) error {
+	if req.Resource.Kind == r.failKind {
+		return trace.BadParameter("failed to create %v", req.Resource.Kind)
+	}
+	for i, resource := range r.resources {
+		if resource.Kind == req.Resource.Kind && resource.Metadata.Name == req.Resource.Metadata.Name {
+			r.resources[i] = req.Resource
+			return nil
+		}
+	}
 	r.resources = append(r.resources, req.Resource)
 	return nil
 }
 
 func (r *testResources) GetCollection(req ListRequest) (Collection, error) {
-	return testCollection(r.resources), nil
+	var collection testCollection
+	for _, resource := range r.resources {
+		if (req.Kind == "" || resource.Kind == req.Kind) &&
+			(req.Name == "" || resource.Metadata.Name == req.Name) {
+			collection = append(collection, resource)
+		}
+	}
+	return collection, nil
+}
+
+func (r *testResources) names() (names []string) {
+	for _, resource := range r.resources {
+		names = append(names, resource.Metadata.Name)
+	}
+	return names
 }
 
 func (r *testResources) Remove(ctx context.Context, req RemoveRequest) error {
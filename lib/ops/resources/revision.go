@@ -0,0 +1,409 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// RevisionLabel is the label with the revision of a resource.
+//
+// The revision is added to resources output by "gravity resource get" and
+// is used to detect whether the resource has been modified by somebody else
+// before the edited copy is written back with "gravity resource create"
+const RevisionLabel = "gravitational.io/revision"
+
+// Digest computes the digest of the specified resource contents.
+//
+// The digest is used to detect the updates of the resource made without
+// the RevisionTracker, regardless of the backend the resource is stored in
+func Digest(resource teleservices.UnknownResource) (string, error) {
+	object, err := decodeObject(resource.Raw)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	deleteRevisionLabel(object)
+	// Map keys are marshaled in sorted order which makes the encoding
+	// independent of the field order in the original document
+	data, err := json.Marshal(object)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:revisionLength], nil
+}
+
+// setRevision returns a copy of the resource labeled with the specified revision
+func setRevision(resource teleservices.UnknownResource, revision string) (*teleservices.UnknownResource, error) {
+	object, err := decodeObject(resource.Raw)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	metadata, _ := object["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+		object["metadata"] = metadata
+	}
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if labels == nil {
+		labels = make(map[string]interface{})
+		metadata["labels"] = labels
+	}
+	labels[RevisionLabel] = revision
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	header := resource.ResourceHeader
+	header.Metadata.Labels = make(map[string]string, len(resource.Metadata.Labels)+1)
+	for key, value := range resource.Metadata.Labels {
+		header.Metadata.Labels[key] = value
+	}
+	header.Metadata.Labels[RevisionLabel] = revision
+	return &teleservices.UnknownResource{
+		ResourceHeader: header,
+		Raw:            data,
+	}, nil
+}
+
+// withoutRevision removes the revision label from the specified resource.
+// Returns the resource without the label and the revision it was labeled with,
+// or an empty string if the resource did not have a revision
+func withoutRevision(resource storage.UnknownResource) (*storage.UnknownResource, string, error) {
+	revision, ok := resource.Metadata.Labels[RevisionLabel]
+	if !ok {
+		return &resource, "", nil
+	}
+	object, err := decodeObject(resource.Raw)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	deleteRevisionLabel(object)
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	header := resource.ResourceHeader
+	header.Metadata.Labels = make(map[string]string, len(resource.Metadata.Labels))
+	for key, value := range resource.Metadata.Labels {
+		if key != RevisionLabel {
+			header.Metadata.Labels[key] = value
+		}
+	}
+	if len(header.Metadata.Labels) == 0 {
+		header.Metadata.Labels = nil
+	}
+	return &storage.UnknownResource{
+		ResourceHeader: header,
+		Raw:            data,
+	}, revision, nil
+}
+
+// RevisionBackend stores the revisions of the resources
+type RevisionBackend interface {
+	storage.ResourceRevisions
+	storage.Locks
+	// GetSiteOperation returns the specified cluster operation
+	GetSiteOperation(clusterName, operationID string) (*storage.SiteOperation, error)
+}
+
+// RevisionTracker assigns monotonic revisions to cluster resources.
+//
+// The revision of a resource is a counter incremented with every update
+// of the resource: with every revision-guarded update, with every operation
+// started to update the resource and whenever the resource contents are found
+// to have changed since the revision has been assigned. Unlike a digest of the
+// resource contents, a revision is never reused, so an update based on a stale
+// read is rejected even if the resource has been changed back in the meantime.
+//
+// The resources are locked for the duration of every revision-guarded update
+// so the comparison and the update are not interleaved with another update
+type RevisionTracker struct {
+	// Backend stores the revisions
+	Backend RevisionBackend
+}
+
+// GetRevision returns the current revision of the resource specified with req
+func (r RevisionTracker) GetRevision(resources Resources, req ops.GetResourceRevisionRequest) (string, error) {
+	if err := req.Check(); err != nil {
+		return "", trace.Wrap(err)
+	}
+	unlock, err := r.lock(req.SiteDomain, req.Kind, req.Name)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	defer unlock()
+	current, err := NewControl(resources).findResource(req.SiteKey, req.Kind, req.Name, false)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if current == nil {
+		return "", trace.NotFound("%v %q not found", req.Kind, req.Name)
+	}
+	revision, err := r.current(req.SiteDomain, req.Kind, req.Name, current)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return formatRevision(revision.Revision), nil
+}
+
+// UpdateWithRevision updates the resource specified with req using
+// the given resource controller if the revision of the resource currently
+// stored in the cluster matches the revision the update is based on.
+//
+// This is the server side of ops.ResourceUpdater
+func (r RevisionTracker) UpdateWithRevision(ctx context.Context, resources Resources, req ops.UpdateResourceRequest) error {
+	if err := req.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	var resource storage.UnknownResource
+	if err := json.Unmarshal(req.Resource, &resource); err != nil {
+		return trace.Wrap(err)
+	}
+	kind, name := resource.Kind, resource.Metadata.Name
+	unlock, err := r.lock(req.SiteDomain, kind, name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer unlock()
+	control := NewControl(resources)
+	current, err := control.findResource(req.SiteKey, kind, name, false)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if current == nil {
+		return trace.CompareFailed("%v %q has been removed since revision %v was read, "+
+			"re-read it or use --force to overwrite", kind, name, req.Revision)
+	}
+	revision, err := r.current(req.SiteDomain, kind, name, current)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := checkRevision(*revision, req.Revision); err != nil {
+		return trace.Wrap(err)
+	}
+	if req.DryRun {
+		return nil
+	}
+	err = resources.Create(ctx, CreateRequest{
+		SiteKey: req.SiteKey,
+		Resource: teleservices.UnknownResource{
+			ResourceHeader: resource.ResourceHeader,
+			Raw:            resource.Raw,
+		},
+		// The matching revision confirms the resource is
+		// an update of the existing one
+		Upsert: true,
+		Owner:  req.Owner,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	updated, err := control.findResource(req.SiteKey, kind, name, false)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	revision.Revision++
+	revision.Digest = ""
+	if updated != nil {
+		revision.Digest, err = Digest(teleservices.UnknownResource{
+			ResourceHeader: updated.ResourceHeader,
+			Raw:            updated.Raw,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return trace.Wrap(r.Backend.UpsertResourceRevision(*revision))
+}
+
+// StartOperation starts the operation that updates the specified resource
+// with startOperation if the resource has not been modified since the given
+// revision. The check is skipped if the revision is empty.
+//
+// Operations updating the resource are recorded regardless of the revision
+// so that the revision of the resource is incremented both when
+// the operation starts and once it finishes
+func (r RevisionTracker) StartOperation(key ops.SiteKey, kind, name, revision string,
+	startOperation func() (*ops.SiteOperationKey, error)) (*ops.SiteOperationKey, error) {
+	unlock, err := r.lock(key.SiteDomain, kind, name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer unlock()
+	// The contents of the resources updated with an operation only change
+	// with operations so the current revision does not need a digest
+	current, err := r.current(key.SiteDomain, kind, name, nil)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if revision != "" {
+		if err := checkRevision(*current, revision); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	operationKey, err := startOperation()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	current.Revision++
+	current.OperationID = operationKey.OperationID
+	err = r.Backend.UpsertResourceRevision(*current)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return operationKey, nil
+}
+
+// current returns the current revision of the specified resource.
+// The revision is incremented if the operation updating the resource
+// has finished or the resource contents have changed since the revision
+// has been assigned.
+// resource can be nil to skip the comparison of the resource contents
+func (r RevisionTracker) current(clusterName, kind, name string, resource *storage.UnknownResource) (*storage.ResourceRevision, error) {
+	revision, err := r.Backend.GetResourceRevision(clusterName, kind, name)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	if revision == nil {
+		revision = &storage.ResourceRevision{
+			ClusterName: clusterName,
+			Kind:        kind,
+			Name:        name,
+		}
+	}
+	updated := *revision
+	if updated.OperationID != "" {
+		operation, err := r.Backend.GetSiteOperation(clusterName, updated.OperationID)
+		if err != nil && !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		if operation == nil || (*ops.SiteOperation)(operation).IsFinished() {
+			updated.Revision++
+			updated.OperationID = ""
+		}
+	}
+	if resource != nil {
+		digest, err := Digest(teleservices.UnknownResource{
+			ResourceHeader: resource.ResourceHeader,
+			Raw:            resource.Raw,
+		})
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if digest != updated.Digest {
+			updated.Revision++
+			updated.Digest = digest
+		}
+	}
+	if updated == *revision {
+		return revision, nil
+	}
+	if err := r.Backend.UpsertResourceRevision(updated); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &updated, nil
+}
+
+// lock locks the specified resource for a revision-guarded update.
+// Returns the function to unlock the resource
+func (r RevisionTracker) lock(clusterName, kind, name string) (unlock func(), err error) {
+	lock := fmt.Sprintf("resource-%v-%v-%v", clusterName, kind, name)
+	if err := r.Backend.AcquireLock(lock, defaults.ResourceUpdateLockTTL); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return func() {
+		if err := r.Backend.ReleaseLock(lock); err != nil {
+			log.WithError(err).Warnf("Failed to release lock %v.", lock)
+		}
+	}, nil
+}
+
+// checkRevision verifies that the specified revision matches the current revision
+func checkRevision(current storage.ResourceRevision, revision string) error {
+	if formatRevision(current.Revision) != revision {
+		return trace.CompareFailed("%v %q has been modified since revision %v was read "+
+			"(current revision is %v), re-read it or use --force to overwrite",
+			current.Kind, current.Name, revision, current.Revision)
+	}
+	return nil
+}
+
+// formatRevision returns the textual representation of the specified revision
+func formatRevision(revision int64) string {
+	return strconv.FormatInt(revision, 10)
+}
+
+// revisionCollection is a collection of resources labeled with their revisions
+type revisionCollection []storage.UnknownResource
+
+// ToMarshal returns the single resource or the list of resources
+// in the collection
+func (r revisionCollection) ToMarshal() interface{} {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return []storage.UnknownResource(r)
+}
+
+// decodeObject decodes the specified resource data into a generic object.
+// Numbers are decoded as-is so they are not rounded when encoded back
+func decodeObject(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if object == nil {
+		object = make(map[string]interface{})
+	}
+	return object, nil
+}
+
+// deleteRevisionLabel removes the revision label from the specified object
+func deleteRevisionLabel(object map[string]interface{}) {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	if metadata == nil {
+		return
+	}
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if labels == nil {
+		return
+	}
+	delete(labels, RevisionLabel)
+	if len(labels) == 0 {
+		delete(metadata, "labels")
+	}
+}
+
+// revisionLength is the number of hex digits of the resource digest
+const revisionLength = 16
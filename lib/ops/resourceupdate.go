@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gravitational/trace"
+)
+
+// ResourceUpdater updates cluster resources guarded by revisions
+type ResourceUpdater interface {
+	// UpdateResource updates the resource if it has not been modified
+	// since the specified revision.
+	// Returns trace.CompareFailed if the resource has been modified or removed
+	UpdateResource(context.Context, UpdateResourceRequest) error
+	// GetResourceRevision returns the current revision of the specified resource
+	GetResourceRevision(context.Context, GetResourceRevisionRequest) (string, error)
+}
+
+// GetResourceRevisionRequest is a request to get the revision of a cluster resource
+type GetResourceRevisionRequest struct {
+	// SiteKey is the key of the cluster
+	SiteKey `json:"site_key"`
+	// Kind is the resource kind
+	Kind string `json:"kind"`
+	// Name is the resource name.
+	// Resources that exist as a single instance can have an empty name
+	Name string `json:"name,omitempty"`
+}
+
+// GetResourceRevisionResponse describes the revision of a cluster resource
+type GetResourceRevisionResponse struct {
+	// Revision is the current revision of the resource
+	Revision string `json:"revision"`
+}
+
+// Check validates this request
+func (r GetResourceRevisionRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if r.Kind == "" {
+		return trace.BadParameter("missing resource kind")
+	}
+	return nil
+}
+
+// UpdateResourceRequest is a request to update a cluster resource
+// based on the specified revision
+type UpdateResourceRequest struct {
+	// SiteKey is the key of the cluster to update
+	SiteKey `json:"site_key"`
+	// Resource is the updated resource without the revision label
+	Resource json.RawMessage `json:"resource"`
+	// Revision is the revision of the resource the update is based on
+	Revision string `json:"revision"`
+	// Owner is the user to update the resource for
+	Owner string `json:"owner,omitempty"`
+	// DryRun only verifies the revision without updating the resource
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Check validates this request
+func (r UpdateResourceRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if len(r.Resource) == 0 {
+		return trace.BadParameter("missing resource")
+	}
+	if r.Revision == "" {
+		return trace.BadParameter("missing revision")
+	}
+	return nil
+}
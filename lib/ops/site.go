@@ -84,17 +84,22 @@ func ConvertOpsSite(in Site) storage.Site {
 			Encrypted:     in.App.PackageEnvelope.Encrypted,
 			Manifest:      in.App.PackageEnvelope.Manifest,
 		},
-		Resources:       in.Resources,
-		Labels:          in.Labels,
-		Location:        in.Location,
-		Flavor:          in.Flavor,
-		UpdateInterval:  in.UpdateInterval,
-		NextUpdateCheck: in.NextUpdateCheck,
-		ClusterState:    in.ClusterState,
-		ServiceUser:     in.ServiceUser,
-		CloudConfig:     in.CloudConfig,
-		DNSOverrides:    in.DNSOverrides,
-		DNSConfig:       in.DNSConfig,
+		Resources:                in.Resources,
+		Labels:                   in.Labels,
+		Location:                 in.Location,
+		Flavor:                   in.Flavor,
+		UpdateInterval:           in.UpdateInterval,
+		NextUpdateCheck:          in.NextUpdateCheck,
+		ClusterState:             in.ClusterState,
+		ServiceUser:              in.ServiceUser,
+		CloudConfig:              in.CloudConfig,
+		DNSOverrides:             in.DNSOverrides,
+		DNSConfig:                in.DNSConfig,
+		DisabledComponents:       in.DisabledComponents,
+		DefaultDenyNetworkPolicy: in.DefaultDenyNetworkPolicy,
+		InstallResources:         in.InstallResources,
+		CNI:                      in.CNI,
+		SELinux:                  in.SELinux,
 	}
 	if in.License != nil {
 		cluster.License = in.License.Raw
@@ -0,0 +1,176 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gravitational/trace"
+)
+
+// StorageCapacityRequest is a request to compute the block storage capacity
+// available for persistent volumes in the cluster
+type StorageCapacityRequest struct {
+	// AccountID is the ID of the account the cluster belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster
+	SiteDomain string `json:"site_domain"`
+	// Filter selects block devices eligible for persistent storage
+	// in addition to the default node disk manager exclusions.
+	// Defaults to the filter of the cluster persistent storage configuration
+	Filter systeminfo.DeviceFilter `json:"filter"`
+}
+
+// Check validates this request
+func (r StorageCapacityRequest) Check() error {
+	if r.SiteDomain == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	return nil
+}
+
+// SiteKey returns the key of the cluster this request is for
+func (r StorageCapacityRequest) SiteKey() SiteKey {
+	return SiteKey{
+		AccountID:  r.AccountID,
+		SiteDomain: r.SiteDomain,
+	}
+}
+
+// StorageCapacity describes the block storage capacity available
+// for persistent volumes in the cluster
+type StorageCapacity struct {
+	// Nodes lists block storage capacity of individual nodes
+	Nodes []NodeStorageCapacity `json:"nodes"`
+	// TotalBytes is the combined size of eligible devices on all nodes
+	TotalBytes uint64 `json:"total_bytes"`
+	// StorageClasses lists storage classes backed by local block devices
+	StorageClasses []StorageClassCapacity `json:"storage_classes,omitempty"`
+	// Warnings lists storage classes that cannot be satisfied with the available capacity
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// NodeStorageCapacity describes block storage capacity of a single node
+type NodeStorageCapacity struct {
+	// Hostname is the node hostname
+	Hostname string `json:"hostname"`
+	// AdvertiseIP is the node advertise IP
+	AdvertiseIP string `json:"advertise_ip"`
+	// Devices lists devices eligible for persistent storage
+	Devices []systeminfo.BlockDevice `json:"devices,omitempty"`
+	// TotalBytes is the combined size of eligible devices
+	TotalBytes uint64 `json:"total_bytes"`
+	// Error describes the failure to query the node, if any
+	Error string `json:"error,omitempty"`
+}
+
+// StorageClassCapacity describes the capacity requested from a storage class
+type StorageClassCapacity struct {
+	// Name is the storage class name
+	Name string `json:"name"`
+	// Provisioner is the storage class provisioner
+	Provisioner string `json:"provisioner"`
+	// RequestedBytes is the combined size requested by persistent volume claims
+	// of this storage class
+	RequestedBytes uint64 `json:"requested_bytes"`
+}
+
+// NewNodeStorageCapacity returns the storage capacity of a node given the list of
+// its block devices annotated with the device filter results.
+//
+// Only the devices that passed the filter are accounted for. If both a disk and
+// its partitions are eligible, only the partitions are counted to avoid
+// accounting for the same space twice
+func NewNodeStorageCapacity(hostname string, devices []systeminfo.BlockDevice) NodeStorageCapacity {
+	parents := make(map[string]bool)
+	for _, device := range devices {
+		if device.Included && device.Parent != "" {
+			parents[device.Parent] = true
+		}
+	}
+	node := NodeStorageCapacity{Hostname: hostname}
+	for _, device := range devices {
+		if !device.Included || parents[device.Path] {
+			continue
+		}
+		node.Devices = append(node.Devices, device)
+		node.TotalBytes += device.SizeBytes
+	}
+	return node
+}
+
+// NewStorageCapacity combines the cluster state with the storage capacity
+// collected from individual nodes and validates it against the storage classes.
+// nodes maps node advertise IP to its storage capacity
+func NewStorageCapacity(cluster storage.Site, nodes map[string]NodeStorageCapacity, classes []StorageClassCapacity) StorageCapacity {
+	var capacity StorageCapacity
+	for _, server := range cluster.ClusterState.Servers {
+		node, ok := nodes[server.AdvertiseIP]
+		if !ok {
+			node = NodeStorageCapacity{
+				Hostname: server.Hostname,
+				Error:    "storage capacity not collected",
+			}
+		}
+		node.AdvertiseIP = server.AdvertiseIP
+		capacity.Nodes = append(capacity.Nodes, node)
+		capacity.TotalBytes += node.TotalBytes
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return classes[i].Name < classes[j].Name
+	})
+	capacity.StorageClasses = classes
+	capacity.Warnings = capacity.check()
+	return capacity
+}
+
+// check returns warnings for storage classes that cannot be satisfied
+// with the available capacity
+func (r StorageCapacity) check() (warnings []string) {
+	if len(r.StorageClasses) == 0 {
+		return nil
+	}
+	var requested uint64
+	var names []string
+	for _, class := range r.StorageClasses {
+		requested += class.RequestedBytes
+		names = append(names, class.Name)
+	}
+	if r.TotalBytes == 0 {
+		return []string{fmt.Sprintf("no block devices are eligible for storage classes %v",
+			strings.Join(names, ", "))}
+	}
+	for _, class := range r.StorageClasses {
+		if class.RequestedBytes > r.TotalBytes {
+			warnings = append(warnings, fmt.Sprintf(
+				"storage class %v requests %v but only %v is available",
+				class.Name, humanize.IBytes(class.RequestedBytes), humanize.IBytes(r.TotalBytes)))
+		}
+	}
+	if len(warnings) == 0 && requested > r.TotalBytes {
+		warnings = append(warnings, fmt.Sprintf(
+			"storage classes %v request %v in total but only %v is available",
+			strings.Join(names, ", "), humanize.IBytes(requested), humanize.IBytes(r.TotalBytes)))
+	}
+	return warnings
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	check "gopkg.in/check.v1"
+)
+
+type StorageCapacitySuite struct{}
+
+var _ = check.Suite(&StorageCapacitySuite{})
+
+func (s *StorageCapacitySuite) TestCountsLeafDevices(c *check.C) {
+	devices := []systeminfo.BlockDevice{
+		{Path: "/dev/sda", Type: "disk", SizeBytes: 100, MountPoints: []string{"/"}},
+		{Path: "/dev/sda1", Type: "part", Parent: "/dev/sda", SizeBytes: 100, MountPoints: []string{"/"}},
+		{Path: "/dev/sdb", Type: "disk", SizeBytes: 300, Included: true},
+		{Path: "/dev/sdb1", Type: "part", Parent: "/dev/sdb", SizeBytes: 100, Included: true},
+		{Path: "/dev/sdb2", Type: "part", Parent: "/dev/sdb", SizeBytes: 200, Included: true},
+		{Path: "/dev/sdc", Type: "disk", SizeBytes: 50, Included: true},
+	}
+	c.Assert(NewNodeStorageCapacity("node-1", devices), compare.DeepEquals, NodeStorageCapacity{
+		Hostname:   "node-1",
+		Devices:    devices[3:],
+		TotalBytes: 350,
+	})
+}
+
+func (s *StorageCapacitySuite) TestWarnsAboutUnsatisfiedStorageClasses(c *check.C) {
+	cluster := storage.Site{
+		ClusterState: storage.ClusterState{
+			Servers: []storage.Server{
+				{AdvertiseIP: "192.168.1.1", Hostname: "node-1"},
+				{AdvertiseIP: "192.168.1.2", Hostname: "node-2"},
+			},
+		},
+	}
+	nodes := map[string]NodeStorageCapacity{
+		"192.168.1.1": {Hostname: "node-1", TotalBytes: 1 << 30},
+	}
+	var testCases = []struct {
+		comment  string
+		classes  []StorageClassCapacity
+		warnings []string
+	}{
+		{
+			comment: "capacity is sufficient",
+			classes: []StorageClassCapacity{{Name: "local", RequestedBytes: 1 << 29}},
+		},
+		{
+			comment:  "single class exceeds capacity",
+			classes:  []StorageClassCapacity{{Name: "local", RequestedBytes: 2 << 30}},
+			warnings: []string{"storage class local requests 2.0GiB but only 1.0GiB is available"},
+		},
+		{
+			comment: "classes combined exceed capacity",
+			classes: []StorageClassCapacity{
+				{Name: "local-b", RequestedBytes: 1 << 29},
+				{Name: "local-a", RequestedBytes: 1 << 30},
+			},
+			warnings: []string{"storage classes local-a, local-b request 1.5GiB in total but only 1.0GiB is available"},
+		},
+	}
+	for _, tc := range testCases {
+		capacity := NewStorageCapacity(cluster, nodes, tc.classes)
+		c.Assert(capacity.Warnings, compare.DeepEquals, tc.warnings, check.Commentf(tc.comment))
+		c.Assert(capacity.TotalBytes, check.Equals, uint64(1<<30), check.Commentf(tc.comment))
+	}
+
+	capacity := NewStorageCapacity(cluster, nil, []StorageClassCapacity{{Name: "local"}})
+	c.Assert(capacity.Warnings, compare.DeepEquals, []string{"no block devices are eligible for storage classes local"})
+	c.Assert(capacity.Nodes, compare.DeepEquals, []NodeStorageCapacity{
+		{Hostname: "node-1", AdvertiseIP: "192.168.1.1", Error: "storage capacity not collected"},
+		{Hostname: "node-2", AdvertiseIP: "192.168.1.2", Error: "storage capacity not collected"},
+	})
+}
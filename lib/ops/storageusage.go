@@ -0,0 +1,176 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/dustin/go-humanize"
+)
+
+// StorageUsage describes the persistent storage capacity and usage
+// of the application namespaces
+type StorageUsage struct {
+	// Namespaces lists the persistent storage usage per namespace and storage class
+	Namespaces []NamespaceStorageUsage `json:"namespaces"`
+	// Quotas lists the usage of the configured storage quotas
+	Quotas []StorageQuotaUsage `json:"quotas,omitempty"`
+	// Warnings lists the storage quotas that are close to or over their limits
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// NamespaceStorageUsage describes the persistent storage capacity and usage
+// of the claims of a single storage class in a namespace
+type NamespaceStorageUsage struct {
+	// Namespace is the namespace name
+	Namespace string `json:"namespace"`
+	// StorageClass is the storage class of the claims
+	StorageClass string `json:"storage_class"`
+	// Claims is the number of bound persistent volume claims
+	Claims int `json:"claims"`
+	// CapacityBytes is the combined capacity of the bound volumes
+	CapacityBytes uint64 `json:"capacity_bytes"`
+	// UsedBytes is the combined space used on the volumes that report usage
+	UsedBytes uint64 `json:"used_bytes"`
+	// Unreported is the number of claims with volumes that do not report usage
+	Unreported int `json:"unreported,omitempty"`
+}
+
+// StorageQuotaUsage describes the usage of a storage quota
+type StorageQuotaUsage struct {
+	// StorageQuota is the quota configuration
+	storage.StorageQuota `json:",inline"`
+	// LimitBytes is the quota limit in bytes
+	LimitBytes uint64 `json:"limit_bytes"`
+	// UsedBytes is the space accounted against the quota
+	UsedBytes uint64 `json:"used_bytes"`
+}
+
+// Percent returns the used share of the quota limit in percent
+func (r StorageQuotaUsage) Percent() int {
+	if r.LimitBytes == 0 {
+		return 0
+	}
+	return int(r.UsedBytes * 100 / r.LimitBytes)
+}
+
+// VolumeUsage describes the capacity and usage of the volume
+// bound to a persistent volume claim
+type VolumeUsage struct {
+	// Namespace is the namespace of the claim
+	Namespace string
+	// Claim is the claim name
+	Claim string
+	// StorageClass is the storage class of the claim
+	StorageClass string
+	// CapacityBytes is the volume capacity
+	CapacityBytes uint64
+	// UsedBytes is the space used on the volume
+	UsedBytes uint64
+	// Reported is whether the volume usage has been reported by the kubelet.
+	// Volumes are only reported while mounted by a pod and only for
+	// the volume types that support usage statistics
+	Reported bool
+}
+
+// NewStorageUsage aggregates the usage of individual volumes per namespace
+// and storage class and checks it against the specified quotas.
+//
+// The volumes that do not report usage are accounted against the quotas
+// with their full capacity
+func NewStorageUsage(volumes []VolumeUsage, quotas []storage.StorageQuota) StorageUsage {
+	type key struct{ namespace, class string }
+	namespaces := make(map[key]*NamespaceStorageUsage)
+	for _, volume := range volumes {
+		k := key{namespace: volume.Namespace, class: volume.StorageClass}
+		usage, ok := namespaces[k]
+		if !ok {
+			usage = &NamespaceStorageUsage{
+				Namespace:    volume.Namespace,
+				StorageClass: volume.StorageClass,
+			}
+			namespaces[k] = usage
+		}
+		usage.Claims++
+		usage.CapacityBytes += volume.CapacityBytes
+		if volume.Reported {
+			usage.UsedBytes += volume.UsedBytes
+		} else {
+			usage.Unreported++
+		}
+	}
+	var result StorageUsage
+	for _, usage := range namespaces {
+		result.Namespaces = append(result.Namespaces, *usage)
+	}
+	sort.Slice(result.Namespaces, func(i, j int) bool {
+		if result.Namespaces[i].Namespace != result.Namespaces[j].Namespace {
+			return result.Namespaces[i].Namespace < result.Namespaces[j].Namespace
+		}
+		return result.Namespaces[i].StorageClass < result.Namespaces[j].StorageClass
+	})
+	for _, quota := range quotas {
+		limit, err := quota.LimitBytes()
+		if err != nil {
+			result.Warnings = append(result.Warnings, err.Error())
+			continue
+		}
+		usage := StorageQuotaUsage{StorageQuota: quota, LimitBytes: limit}
+		for _, volume := range volumes {
+			if volume.Namespace != quota.Namespace {
+				continue
+			}
+			if quota.StorageClass != "" && volume.StorageClass != quota.StorageClass {
+				continue
+			}
+			if volume.Reported {
+				usage.UsedBytes += volume.UsedBytes
+			} else {
+				usage.UsedBytes += volume.CapacityBytes
+			}
+		}
+		result.Quotas = append(result.Quotas, usage)
+		if warning := usage.check(); warning != "" {
+			result.Warnings = append(result.Warnings, warning)
+		}
+	}
+	return result
+}
+
+// check returns a warning if the quota usage has reached the warning threshold
+func (r StorageQuotaUsage) check() string {
+	threshold := r.WarningThreshold
+	if threshold == 0 {
+		threshold = 100
+	}
+	if r.Percent() < threshold {
+		return ""
+	}
+	target := fmt.Sprintf("namespace %v", r.Namespace)
+	if r.StorageClass != "" {
+		target = fmt.Sprintf("%v (storage class %v)", target, r.StorageClass)
+	}
+	if r.UsedBytes > r.LimitBytes {
+		return fmt.Sprintf("%v uses %v which exceeds its storage quota of %v",
+			target, humanize.IBytes(r.UsedBytes), humanize.IBytes(r.LimitBytes))
+	}
+	return fmt.Sprintf("%v uses %v%% (%v) of its storage quota of %v",
+		target, r.Percent(), humanize.IBytes(r.UsedBytes), humanize.IBytes(r.LimitBytes))
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/storage"
+
+	check "gopkg.in/check.v1"
+)
+
+type StorageUsageSuite struct{}
+
+var _ = check.Suite(&StorageUsageSuite{})
+
+func (s *StorageUsageSuite) TestAggregatesVolumeUsage(c *check.C) {
+	volumes := []VolumeUsage{
+		{Namespace: "db", Claim: "data-0", StorageClass: "local", CapacityBytes: 10 << 30, UsedBytes: 6 << 30, Reported: true},
+		{Namespace: "db", Claim: "data-1", StorageClass: "local", CapacityBytes: 10 << 30},
+		{Namespace: "db", Claim: "logs", StorageClass: "nfs", CapacityBytes: 5 << 30, UsedBytes: 1 << 30, Reported: true},
+		{Namespace: "app", Claim: "cache", StorageClass: "local", CapacityBytes: 1 << 30, UsedBytes: 1 << 20, Reported: true},
+	}
+	usage := NewStorageUsage(volumes, nil)
+	c.Assert(usage, compare.DeepEquals, StorageUsage{
+		Namespaces: []NamespaceStorageUsage{
+			{Namespace: "app", StorageClass: "local", Claims: 1, CapacityBytes: 1 << 30, UsedBytes: 1 << 20},
+			{Namespace: "db", StorageClass: "local", Claims: 2, CapacityBytes: 20 << 30, UsedBytes: 6 << 30, Unreported: 1},
+			{Namespace: "db", StorageClass: "nfs", Claims: 1, CapacityBytes: 5 << 30, UsedBytes: 1 << 30},
+		},
+	})
+}
+
+func (s *StorageUsageSuite) TestWarnsAboutStorageQuotas(c *check.C) {
+	volumes := []VolumeUsage{
+		{Namespace: "db", StorageClass: "local", CapacityBytes: 10 << 30, UsedBytes: 6 << 30, Reported: true},
+		{Namespace: "db", StorageClass: "local", CapacityBytes: 4 << 30},
+		{Namespace: "db", StorageClass: "nfs", CapacityBytes: 5 << 30, UsedBytes: 1 << 30, Reported: true},
+		{Namespace: "app", StorageClass: "local", CapacityBytes: 1 << 30, UsedBytes: 1 << 20, Reported: true},
+	}
+	var testCases = []struct {
+		comment  string
+		quota    storage.StorageQuota
+		used     uint64
+		warnings []string
+	}{
+		{
+			comment: "unreported volumes count with their capacity",
+			quota:   storage.StorageQuota{Namespace: "db", StorageClass: "local", Limit: "20Gi", WarningThreshold: 80},
+			used:    10 << 30,
+		},
+		{
+			comment:  "reaches the warning threshold",
+			quota:    storage.StorageQuota{Namespace: "db", Limit: "12Gi", WarningThreshold: 80},
+			used:     11 << 30,
+			warnings: []string{"namespace db uses 91% (11GiB) of its storage quota of 12GiB"},
+		},
+		{
+			comment:  "exceeds the limit",
+			quota:    storage.StorageQuota{Namespace: "db", StorageClass: "local", Limit: "8Gi", WarningThreshold: 80},
+			used:     10 << 30,
+			warnings: []string{"namespace db (storage class local) uses 10GiB which exceeds its storage quota of 8.0GiB"},
+		},
+		{
+			comment: "namespace without volumes",
+			quota:   storage.StorageQuota{Namespace: "monitoring", Limit: "1Gi", WarningThreshold: 80},
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		usage := NewStorageUsage(volumes, []storage.StorageQuota{tc.quota})
+		c.Assert(usage.Quotas, check.HasLen, 1, comment)
+		c.Assert(usage.Quotas[0].UsedBytes, check.Equals, tc.used, comment)
+		c.Assert(usage.Warnings, check.DeepEquals, tc.warnings, comment)
+	}
+}
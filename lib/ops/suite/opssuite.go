@@ -19,7 +19,10 @@ limitations under the License.
 package suite
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -28,6 +31,7 @@ import (
 
 	"github.com/gravitational/gravity/lib/app"
 	apptest "github.com/gravitational/gravity/lib/app/service/test"
+	"github.com/gravitational/gravity/lib/archive"
 	"github.com/gravitational/gravity/lib/compare"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
@@ -159,6 +163,24 @@ func (s *OpsSuite) SitesCRUD(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(reportStream.Close(), IsNil)
 
+	// download operation artifacts
+	artifactsStream, err := s.O.GetOperationArtifacts(*opKey)
+	c.Assert(err, IsNil)
+	gzReader, err := gzip.NewReader(artifactsStream)
+	c.Assert(err, IsNil)
+	artifacts := make(map[string][]byte)
+	err = archive.TarGlob(tar.NewReader(gzReader), ".", []string{"*"}, func(match string, r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		artifacts[match] = data
+		return trace.Wrap(err)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(artifactsStream.Close(), IsNil)
+	var artifactOperation ops.SiteOperation
+	c.Assert(json.Unmarshal(artifacts[ops.OperationArtifactOperation], &artifactOperation), IsNil)
+	c.Assert(artifactOperation.Key(), Equals, *opKey)
+	c.Assert(artifacts[ops.OperationArtifactProgress], NotNil)
+
 	operations, err = s.O.GetSiteOperations(siteKey)
 	c.Assert(err, IsNil)
 	c.Assert(operations, DeepEquals, ops.SiteOperations{storage.SiteOperation(*op)})
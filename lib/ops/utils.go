@@ -82,22 +82,27 @@ func GetCompletedInstallOperation(siteKey SiteKey, operator Operator) (*SiteOper
 	return nil, trace.NotFound("no completed install operation for %v found", siteKey)
 }
 
-// GetLastOperation returns the most recent operation and its progress for the specified site
+// GetLastOperation returns the most recent operation and its progress for the specified site.
+// Queued operations are skipped
 func GetLastOperation(siteKey SiteKey, operator Operator) (*SiteOperation, *ProgressEntry, error) {
 	operations, err := operator.GetSiteOperations(siteKey)
 	if err != nil {
 		return nil, nil, trace.Wrap(err)
 	}
-	if len(operations) == 0 {
-		return nil, nil, trace.NotFound("no operations found for %v", siteKey)
-	}
 	// backend is guaranteed to return operations in the last-to-first order
-	lastOperation := (*SiteOperation)(&operations[0])
-	progress, err := operator.GetSiteOperationProgress(lastOperation.Key())
-	if err != nil {
-		return nil, nil, trace.Wrap(err)
+	for _, operation := range operations {
+		lastOperation := (*SiteOperation)(&operation)
+		// queued operations have not started yet
+		if lastOperation.IsQueued() {
+			continue
+		}
+		progress, err := operator.GetSiteOperationProgress(lastOperation.Key())
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+		return lastOperation, progress, nil
 	}
-	return lastOperation, progress, nil
+	return nil, nil, trace.NotFound("no operations found for %v", siteKey)
 }
 
 // GetLastCompletedOperations returns the cluster's last completed operation
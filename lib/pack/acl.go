@@ -32,6 +32,12 @@ import (
 )
 
 func PackagesWithACL(packages PackageService, users users.Users, user storage.User, checker teleservices.AccessChecker) PackageService {
+	return NewACLService(packages, users, user, checker)
+}
+
+// NewACLService returns the package service that checks permissions
+// of the specified user before every operation
+func NewACLService(packages PackageService, users users.Users, user storage.User, checker teleservices.AccessChecker) *ACLService {
 	return &ACLService{
 		packages: packages,
 		users:    users,
@@ -143,6 +149,19 @@ func (a *ACLService) UpsertPackage(loc loc.Locator, data io.Reader, options ...P
 	return a.packages.UpsertPackage(loc, data, options...)
 }
 
+// CheckUploadAccess checks whether the user is allowed to create packages
+// in the specified repository, or to overwrite them if upsert is set.
+// Chunked uploads are authorized with it before any contents are received
+func (a *ACLService) CheckUploadAccess(repository string, upsert bool) error {
+	if err := a.repoAction(repository, teleservices.VerbCreate); err != nil {
+		return trace.Wrap(err)
+	}
+	if upsert {
+		return trace.Wrap(a.repoAction(repository, teleservices.VerbUpdate))
+	}
+	return nil
+}
+
 // DeletePackage deletes package from all repositories
 func (a *ACLService) DeletePackage(loc loc.Locator) error {
 	if err := a.repoAction(loc.Repository, teleservices.VerbDelete); err != nil {
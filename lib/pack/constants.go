@@ -19,6 +19,8 @@ package pack
 const (
 	// InstalledLabel is used to mark installed packages
 	InstalledLabel = "installed"
+	// StagedLabel marks packages unpacked ahead of time with 'gravity stage'
+	StagedLabel = "staged"
 	// LatestLabel is a pseudo label that allows system to find the latest version
 	LatestLabel = "latest"
 	// ConfigLabel means that this is a configuration package for another package
@@ -96,4 +98,9 @@ var (
 	InstalledLabels = map[string]string{
 		InstalledLabel: InstalledLabel,
 	}
+
+	// StagedLabels defines a label set for a staged package
+	StagedLabels = map[string]string{
+		StagedLabel: StagedLabel,
+	}
 )
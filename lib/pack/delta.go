@@ -0,0 +1,337 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pack
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeltaReader is implemented by package services that can serve
+// package contents for delta transfers
+type DeltaReader interface {
+	// GetPackageBlocks returns the block manifest of the specified package
+	// with the contents split into blocks of blockSize bytes
+	GetPackageBlocks(locator loc.Locator, blockSize int64) (*ChunkManifest, error)
+	// ReadPackageRangeCompressed returns size bytes of the package contents
+	// starting at offset. The contents are compressed in transit
+	ReadPackageRangeCompressed(locator loc.Locator, offset, size int64) (io.ReadCloser, error)
+}
+
+// NewBlockManifest computes the manifest of the data read from r split into
+// blocks of at most blockSize bytes.
+// In addition to the strong checksum, every block records the weak rolling
+// checksum used to locate the block in a different file
+func NewBlockManifest(r io.Reader, blockSize int64) (*ChunkManifest, error) {
+	if blockSize <= 0 {
+		return nil, trace.BadParameter("block size should be positive, got %v", blockSize)
+	}
+	var manifest ChunkManifest
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			block := buf[:n]
+			checksum := sha256.Sum256(block)
+			manifest.Chunks = append(manifest.Chunks, Chunk{
+				Offset: manifest.Size,
+				Size:   int64(n),
+				SHA256: hex.EncodeToString(checksum[:]),
+				Weak:   newRollingChecksum(block).Sum(),
+			})
+			manifest.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return &manifest, nil
+		}
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+}
+
+// SyncRequest describes a request to bring a file up to date with package contents
+type SyncRequest struct {
+	// Reader is the package service to download the package from
+	Reader DeltaReader
+	// Package is the package to synchronize the file with
+	Package loc.Locator
+	// Path is the path of the file to update.
+	// Existing contents of the file are used as the base of the delta transfer
+	Path string
+	// Mode is the access mode of the updated file
+	Mode os.FileMode
+	// BlockSize is the size of the blocks to match in the existing file.
+	// Defaults to defaults.DeltaBlockSize
+	BlockSize int64
+}
+
+func (r *SyncRequest) checkAndSetDefaults() error {
+	if r.Reader == nil {
+		return trace.BadParameter("missing Reader")
+	}
+	if r.Path == "" {
+		return trace.BadParameter("missing Path")
+	}
+	if r.Mode == 0 {
+		r.Mode = defaults.SharedReadMask
+	}
+	if r.BlockSize == 0 {
+		r.BlockSize = defaults.DeltaBlockSize
+	}
+	if r.BlockSize < defaults.MinDeltaBlockSize {
+		return trace.BadParameter("block size should be at least %v bytes, got %v",
+			defaults.MinDeltaBlockSize, r.BlockSize)
+	}
+	return nil
+}
+
+// SyncResult describes the outcome of the file synchronization
+type SyncResult struct {
+	// Unchanged is true if the file already had the package contents
+	Unchanged bool
+	// Reused is the number of bytes reused from the existing file
+	Reused int64
+	// Transferred is the number of bytes of package contents downloaded
+	Transferred int64
+}
+
+// SyncPackage updates the file at req.Path to have the contents of the specified
+// package transferring only the parts of the contents missing from the existing file.
+//
+// If the file already has the package contents, it is left intact.
+// Otherwise, the existing file is scanned with a rolling checksum for the blocks of
+// the package contents so the blocks are found even if they have moved, and the blocks
+// that are not found are downloaded compressed. Every block is verified against its
+// checksum before the file is atomically replaced
+func SyncPackage(ctx context.Context, req SyncRequest) (*SyncResult, error) {
+	if err := req.checkAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	manifest, err := req.Reader.GetPackageBlocks(req.Package, req.BlockSize)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := checkBlockManifest(*manifest, req.BlockSize); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	base, err := ioutil.ReadFile(req.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, trace.ConvertSystemError(err)
+	}
+	if base != nil {
+		existing, err := NewBlockManifest(bytes.NewReader(base), req.BlockSize)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if existing.Equals(*manifest) {
+			log.Infof("%v is up to date with package %v.", req.Path, req.Package)
+			if err := os.Chmod(req.Path, req.Mode); err != nil {
+				return nil, trace.ConvertSystemError(err)
+			}
+			return &SyncResult{Unchanged: true}, nil
+		}
+	}
+	f, err := ioutil.TempFile(filepath.Dir(req.Path), "."+filepath.Base(req.Path))
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	matches := matchBlocks(base, *manifest, req.BlockSize)
+	var result SyncResult
+	for i := 0; i < len(manifest.Chunks); {
+		if err := ctx.Err(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		block := manifest.Chunks[i]
+		if offset, ok := matches[i]; ok {
+			if _, err := f.Write(base[offset : offset+block.Size]); err != nil {
+				return nil, trace.ConvertSystemError(err)
+			}
+			result.Reused += block.Size
+			i++
+			continue
+		}
+		// Coalesce consecutive missing blocks into a single range
+		var size int64
+		j := i
+		for ; j < len(manifest.Chunks); j++ {
+			if _, ok := matches[j]; ok || size+manifest.Chunks[j].Size > defaults.DownloadChunkSize {
+				break
+			}
+			size += manifest.Chunks[j].Size
+		}
+		if j == i {
+			// Block is larger than the maximum range
+			j++
+		}
+		if err := downloadBlocks(f, req, manifest.Chunks[i:j]); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		for _, block := range manifest.Chunks[i:j] {
+			result.Transferred += block.Size
+		}
+		i = j
+	}
+	if err := f.Chmod(req.Mode); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	if err := os.Rename(f.Name(), req.Path); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	log.Infof("Updated %v from package %v: reused %v bytes, transferred %v bytes.",
+		req.Path, req.Package, result.Reused, result.Transferred)
+	return &result, nil
+}
+
+// checkBlockManifest verifies that the manifest has been split into blocks
+// of the requested size.
+// Package services that do not support delta transfers ignore the block size
+// and return the chunk manifest instead
+func checkBlockManifest(manifest ChunkManifest, blockSize int64) error {
+	for i, block := range manifest.Chunks {
+		if block.Size > blockSize || (block.Size < blockSize && i != len(manifest.Chunks)-1) {
+			return trace.NotImplemented("package service does not support delta transfers")
+		}
+	}
+	return nil
+}
+
+// matchBlocks locates the blocks of the manifest in base and returns
+// the offsets of the found blocks in base indexed by block number.
+// Only blocks of the full block size are matched
+func matchBlocks(base []byte, manifest ChunkManifest, blockSize int64) map[int]int64 {
+	matches := make(map[int]int64)
+	index := make(map[uint32][]int)
+	for i, block := range manifest.Chunks {
+		if block.Size == blockSize {
+			index[block.Weak] = append(index[block.Weak], i)
+		}
+	}
+	size := int(blockSize)
+	if len(index) == 0 || len(base) < size {
+		return matches
+	}
+	offset := 0
+	checksum := newRollingChecksum(base[:size])
+	for {
+		var matched bool
+		var strong string
+		for _, i := range index[checksum.Sum()] {
+			if _, ok := matches[i]; ok {
+				continue
+			}
+			if strong == "" {
+				sum := sha256.Sum256(base[offset : offset+size])
+				strong = hex.EncodeToString(sum[:])
+			}
+			if manifest.Chunks[i].SHA256 == strong {
+				matches[i] = int64(offset)
+				matched = true
+			}
+		}
+		if matched {
+			// Skip past the matched block
+			offset += size
+			if offset+size > len(base) {
+				return matches
+			}
+			checksum = newRollingChecksum(base[offset : offset+size])
+			continue
+		}
+		if offset+size >= len(base) {
+			return matches
+		}
+		checksum.roll(base[offset], base[offset+size])
+		offset++
+	}
+}
+
+// downloadBlocks downloads the specified consecutive blocks into file f
+// and verifies their checksums
+func downloadBlocks(f *os.File, req SyncRequest, blocks []Chunk) error {
+	first, last := blocks[0], blocks[len(blocks)-1]
+	rc, err := req.Reader.ReadPackageRangeCompressed(req.Package,
+		first.Offset, last.Offset+last.Size-first.Offset)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer rc.Close()
+	for _, block := range blocks {
+		hash := sha256.New()
+		n, err := io.CopyN(io.MultiWriter(f, hash), rc, block.Size)
+		if err != nil && err != io.EOF {
+			return trace.Wrap(err)
+		}
+		if n != block.Size {
+			return trace.ConnectionProblem(nil, "short read of block at offset %v: got %v bytes, expected %v",
+				block.Offset, n, block.Size)
+		}
+		if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != block.SHA256 {
+			return trace.BadParameter("checksum mismatch for block at offset %v of package %v: got %v, expected %v",
+				block.Offset, req.Package, checksum, block.SHA256)
+		}
+	}
+	return nil
+}
+
+// newRollingChecksum returns the rolling checksum of the specified window of data
+func newRollingChecksum(window []byte) *rollingChecksum {
+	r := &rollingChecksum{size: uint32(len(window))}
+	for i, c := range window {
+		r.a += uint32(c)
+		r.b += uint32(len(window)-i) * uint32(c)
+	}
+	return r
+}
+
+// Sum returns the value of the checksum
+func (r *rollingChecksum) Sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+// roll moves the window forward by one byte removing out and adding in
+func (r *rollingChecksum) roll(out, in byte) {
+	r.a = r.a - uint32(out) + uint32(in)
+	r.b = r.b - r.size*uint32(out) + r.a
+}
+
+// rollingChecksum is the weak checksum of a fixed size window of data
+// that can be efficiently updated as the window moves along the data.
+// It is the checksum used by rsync
+type rollingChecksum struct {
+	a, b uint32
+	size uint32
+}
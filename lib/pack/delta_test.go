@@ -0,0 +1,140 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pack
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+
+	"gopkg.in/check.v1"
+)
+
+type DeltaSuite struct{}
+
+var _ = check.Suite(&DeltaSuite{})
+
+func (s *DeltaSuite) TestRollingChecksum(c *check.C) {
+	data := randomData(256)
+	const window = 64
+	checksum := newRollingChecksum(data[:window])
+	for offset := 1; offset+window <= len(data); offset++ {
+		checksum.roll(data[offset-1], data[offset+window-1])
+		expected := newRollingChecksum(data[offset : offset+window]).Sum()
+		c.Assert(checksum.Sum(), check.Equals, expected, check.Commentf("offset %v", offset))
+	}
+}
+
+func (s *DeltaSuite) TestTransfersOnlyMissingBlocks(c *check.C) {
+	blockSize := defaults.MinDeltaBlockSize
+	base := randomData(int(blockSize) * 4)
+	// new version has data inserted in front and one modified block
+	data := append(randomData(100), base...)
+	copy(data[100+2*blockSize:], randomData(10))
+	reader := newTestDeltaReader(c, data, blockSize)
+	path := filepath.Join(c.MkDir(), "file")
+	c.Assert(ioutil.WriteFile(path, base, defaults.SharedReadMask), check.IsNil)
+
+	result, err := SyncPackage(context.TODO(), SyncRequest{
+		Reader:    reader,
+		Package:   reader.locator,
+		Path:      path,
+		Mode:      defaults.SharedExecutableMask,
+		BlockSize: blockSize,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Unchanged, check.Equals, false)
+	c.Assert(result.Reused+result.Transferred, check.Equals, int64(len(data)))
+	c.Assert(result.Reused >= 2*blockSize, check.Equals, true,
+		check.Commentf("expected blocks to be reused: %#v", result))
+	assertFile(c, path, data, defaults.SharedExecutableMask)
+
+	reader.ranges = nil
+	result, err = SyncPackage(context.TODO(), SyncRequest{
+		Reader:    reader,
+		Package:   reader.locator,
+		Path:      path,
+		Mode:      defaults.SharedExecutableMask,
+		BlockSize: blockSize,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Unchanged, check.Equals, true)
+	c.Assert(reader.ranges, check.HasLen, 0)
+}
+
+func (s *DeltaSuite) TestTransfersNewFile(c *check.C) {
+	data := randomData(int(defaults.MinDeltaBlockSize)*2 + 10)
+	reader := newTestDeltaReader(c, data, defaults.MinDeltaBlockSize)
+	path := filepath.Join(c.MkDir(), "file")
+
+	result, err := SyncPackage(context.TODO(), SyncRequest{
+		Reader:    reader,
+		Package:   reader.locator,
+		Path:      path,
+		BlockSize: defaults.MinDeltaBlockSize,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Transferred, check.Equals, int64(len(data)))
+	c.Assert(reader.ranges, check.DeepEquals, []int64{0}, check.Commentf("expected a single range"))
+	assertFile(c, path, data, defaults.SharedReadMask)
+}
+
+func newTestDeltaReader(c *check.C, data []byte, blockSize int64) *testDeltaReader {
+	manifest, err := NewBlockManifest(bytes.NewReader(data), blockSize)
+	c.Assert(err, check.IsNil)
+	return &testDeltaReader{
+		testChunkedReader: testChunkedReader{
+			locator:  loc.MustParseLocator("example.com/package:0.0.1"),
+			data:     append([]byte(nil), data...),
+			manifest: manifest,
+		},
+	}
+}
+
+type testDeltaReader struct {
+	testChunkedReader
+}
+
+func (r *testDeltaReader) GetPackageBlocks(locator loc.Locator, blockSize int64) (*ChunkManifest, error) {
+	return r.GetPackageChunks(locator)
+}
+
+func (r *testDeltaReader) ReadPackageRangeCompressed(locator loc.Locator, offset, size int64) (io.ReadCloser, error) {
+	return r.ReadPackageRange(locator, offset, size)
+}
+
+func assertFile(c *check.C, path string, data []byte, mode os.FileMode) {
+	contents, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(bytes.Equal(contents, data), check.Equals, true)
+	fi, err := os.Stat(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Mode().Perm(), check.Equals, mode)
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// ChunkedReader is implemented by package services that can serve
+// package contents in parts
+type ChunkedReader interface {
+	// GetPackageChunks returns the chunk manifest of the specified package
+	GetPackageChunks(loc.Locator) (*ChunkManifest, error)
+	// ReadPackageRange returns size bytes of the package contents starting at offset
+	ReadPackageRange(locator loc.Locator, offset, size int64) (io.ReadCloser, error)
+}
+
+// ChunkManifest describes package contents as a sequence of chunks
+type ChunkManifest struct {
+	// Size is the total size of the package contents in bytes
+	Size int64 `json:"size"`
+	// Chunks lists the chunks in order of their offsets
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Chunk describes a contiguous range of package contents
+type Chunk struct {
+	// Offset is the offset of the chunk from the start of the package contents
+	Offset int64 `json:"offset"`
+	// Size is the size of the chunk in bytes
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded sha-256 checksum of the chunk
+	SHA256 string `json:"sha256"`
+	// Weak is the rolling checksum of the chunk used to locate
+	// the chunk in a different file by delta transfers.
+	// Only set in block manifests
+	Weak uint32 `json:"weak,omitempty"`
+}
+
+// NewChunkManifest computes the chunk manifest of the data read from r
+// split into chunks of at most chunkSize bytes
+func NewChunkManifest(r io.Reader, chunkSize int64) (*ChunkManifest, error) {
+	if chunkSize <= 0 {
+		return nil, trace.BadParameter("chunk size should be positive, got %v", chunkSize)
+	}
+	var manifest ChunkManifest
+	for {
+		hash := sha256.New()
+		n, err := io.CopyN(hash, r, chunkSize)
+		if n > 0 {
+			manifest.Chunks = append(manifest.Chunks, Chunk{
+				Offset: manifest.Size,
+				Size:   n,
+				SHA256: hex.EncodeToString(hash.Sum(nil)),
+			})
+			manifest.Size += n
+		}
+		if err == io.EOF {
+			return &manifest, nil
+		}
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+}
+
+// Equals returns true if this manifest describes the same contents as other
+func (r ChunkManifest) Equals(other ChunkManifest) bool {
+	if r.Size != other.Size || len(r.Chunks) != len(other.Chunks) {
+		return false
+	}
+	for i, chunk := range r.Chunks {
+		if chunk != other.Chunks[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DownloadRequest describes a request to download package contents into a file
+type DownloadRequest struct {
+	// Reader is the package service to download the package from
+	Reader ChunkedReader
+	// Package is the package to download
+	Package loc.Locator
+	// Path is the path of the file to download the package contents into
+	Path string
+	// Progress is optional progress reporter
+	Progress ProgressReporter
+	// Manifest is the optional chunk manifest of the package.
+	// If unspecified, it is retrieved from Reader
+	Manifest *ChunkManifest
+}
+
+// DownloadPackage downloads contents of the package into the file at req.Path.
+//
+// The chunk manifest of the package is recorded next to the file so an
+// interrupted download can be resumed: chunks already present in the file are
+// verified against their checksums and only the missing or corrupted chunks
+// are downloaded again. Every chunk is verified before the function returns
+// successfully.
+func DownloadPackage(ctx context.Context, req DownloadRequest) error {
+	manifest := req.Manifest
+	if manifest == nil {
+		var err error
+		manifest, err = req.Reader.GetPackageChunks(req.Package)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	f, err := os.OpenFile(req.Path, os.O_RDWR|os.O_CREATE, defaults.SharedReadMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	recorded, err := readChunkManifest(chunkManifestPath(req.Path))
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	if recorded == nil || !recorded.Equals(*manifest) {
+		if recorded != nil {
+			log.Infof("Package %v has changed since the last download, starting over.", req.Package)
+		}
+		if err := f.Truncate(0); err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		if err := writeChunkManifest(chunkManifestPath(req.Path), *manifest); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	var current int64
+	for _, chunk := range manifest.Chunks {
+		if err := ctx.Err(); err != nil {
+			return trace.Wrap(err)
+		}
+		valid, err := verifyChunk(f, chunk)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !valid {
+			if err := downloadChunk(f, req, chunk); err != nil {
+				return trace.Wrap(err)
+			}
+		}
+		current += chunk.Size
+		if req.Progress != nil {
+			req.Progress.Report(current, manifest.Size)
+		}
+	}
+	if err := f.Truncate(manifest.Size); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return nil
+}
+
+// RemoveDownload removes the file downloaded with DownloadPackage
+// along with the recorded chunk manifest
+func RemoveDownload(path string) error {
+	for _, path := range []string{path, chunkManifestPath(path)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return trace.ConvertSystemError(err)
+		}
+	}
+	return nil
+}
+
+// verifyChunk returns true if the file f contains the specified chunk
+func verifyChunk(f *os.File, chunk Chunk) (bool, error) {
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.NewSectionReader(f, chunk.Offset, chunk.Size))
+	if err != nil {
+		return false, trace.ConvertSystemError(err)
+	}
+	return n == chunk.Size && hex.EncodeToString(hash.Sum(nil)) == chunk.SHA256, nil
+}
+
+// downloadChunk downloads the specified chunk into file f
+// and verifies its checksum
+func downloadChunk(f *os.File, req DownloadRequest, chunk Chunk) error {
+	rc, err := req.Reader.ReadPackageRange(req.Package, chunk.Offset, chunk.Size)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer rc.Close()
+	if _, err := f.Seek(chunk.Offset, io.SeekStart); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(rc, chunk.Size))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if n != chunk.Size {
+		return trace.ConnectionProblem(nil, "short read of chunk at offset %v: got %v bytes, expected %v",
+			chunk.Offset, n, chunk.Size)
+	}
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != chunk.SHA256 {
+		return trace.BadParameter("checksum mismatch for chunk at offset %v of package %v: got %v, expected %v",
+			chunk.Offset, req.Package, checksum, chunk.SHA256)
+	}
+	return nil
+}
+
+func readChunkManifest(path string) (*ChunkManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	var manifest ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		// A corrupted manifest is treated as missing
+		return nil, trace.NotFound("invalid chunk manifest %v: %v", path, err)
+	}
+	return &manifest, nil
+}
+
+func writeChunkManifest(path string, manifest ChunkManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(ioutil.WriteFile(path, data, defaults.SharedReadMask))
+}
+
+func chunkManifestPath(path string) string {
+	return path + ".chunks"
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pack
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gravitational/gravity/lib/loc"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func TestPack(t *testing.T) { check.TestingT(t) }
+
+type DownloadSuite struct{}
+
+var _ = check.Suite(&DownloadSuite{})
+
+func (s *DownloadSuite) TestChunkManifest(c *check.C) {
+	manifest, err := NewChunkManifest(bytes.NewReader([]byte("hello, world")), 5)
+	c.Assert(err, check.IsNil)
+	c.Assert(manifest.Size, check.Equals, int64(12))
+	c.Assert(manifest.Chunks, check.HasLen, 3)
+	c.Assert(manifest.Chunks[2].Offset, check.Equals, int64(10))
+	c.Assert(manifest.Chunks[2].Size, check.Equals, int64(2))
+	c.Assert(manifest.Chunks[0].SHA256, check.Equals,
+		"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+}
+
+func (s *DownloadSuite) TestResumesDownload(c *check.C) {
+	data := []byte("0123456789abcdefghij")
+	reader := newTestChunkedReader(c, data, 5)
+	path := filepath.Join(c.MkDir(), "package")
+
+	// interrupted download has left the first chunk and a corrupted second chunk
+	c.Assert(writeChunkManifest(chunkManifestPath(path), *reader.manifest), check.IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte("01234XXXX"), 0644), check.IsNil)
+
+	err := DownloadPackage(context.TODO(), DownloadRequest{
+		Reader:  reader,
+		Package: reader.locator,
+		Path:    path,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(reader.ranges, check.DeepEquals, []int64{5, 10, 15})
+	downloaded, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(downloaded), check.Equals, string(data))
+
+	c.Assert(RemoveDownload(path), check.IsNil)
+	_, err = os.Stat(chunkManifestPath(path))
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *DownloadSuite) TestRestartsDownloadIfPackageChanged(c *check.C) {
+	reader := newTestChunkedReader(c, []byte("0123456789"), 5)
+	path := filepath.Join(c.MkDir(), "package")
+
+	stale, err := NewChunkManifest(bytes.NewReader([]byte("01234abcdefgh")), 5)
+	c.Assert(err, check.IsNil)
+	c.Assert(writeChunkManifest(chunkManifestPath(path), *stale), check.IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte("01234abcdefgh"), 0644), check.IsNil)
+
+	err = DownloadPackage(context.TODO(), DownloadRequest{
+		Reader:  reader,
+		Package: reader.locator,
+		Path:    path,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(reader.ranges, check.DeepEquals, []int64{0, 5})
+	downloaded, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(downloaded), check.Equals, "0123456789")
+}
+
+func (s *DownloadSuite) TestRejectsCorruptedChunk(c *check.C) {
+	reader := newTestChunkedReader(c, []byte("0123456789"), 5)
+	reader.data[7] = 'X'
+	err := DownloadPackage(context.TODO(), DownloadRequest{
+		Reader:  reader,
+		Package: reader.locator,
+		Path:    filepath.Join(c.MkDir(), "package"),
+	})
+	c.Assert(err, check.ErrorMatches, "checksum mismatch for chunk at offset 5.*")
+}
+
+func newTestChunkedReader(c *check.C, data []byte, chunkSize int64) *testChunkedReader {
+	manifest, err := NewChunkManifest(bytes.NewReader(data), chunkSize)
+	c.Assert(err, check.IsNil)
+	return &testChunkedReader{
+		locator:  loc.MustParseLocator("example.com/package:0.0.1"),
+		data:     append([]byte(nil), data...),
+		manifest: manifest,
+	}
+}
+
+type testChunkedReader struct {
+	locator  loc.Locator
+	data     []byte
+	manifest *ChunkManifest
+	// ranges records offsets of the requested ranges
+	ranges []int64
+}
+
+func (r *testChunkedReader) GetPackageChunks(locator loc.Locator) (*ChunkManifest, error) {
+	if locator != r.locator {
+		return nil, trace.NotFound("package %v not found", locator)
+	}
+	return r.manifest, nil
+}
+
+func (r *testChunkedReader) ReadPackageRange(locator loc.Locator, offset, size int64) (io.ReadCloser, error) {
+	r.ranges = append(r.ranges, offset)
+	return ioutil.NopCloser(bytes.NewReader(r.data[offset : offset+size])), nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocipack
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// Credentials defines the credentials to access a registry
+type Credentials struct {
+	// Username is the registry user name
+	Username string
+	// Password is the registry password or access token
+	Password string
+	// IdentityToken is the OAuth2 refresh token used to obtain access tokens
+	IdentityToken string
+}
+
+// IsEmpty returns true if no credentials have been specified
+func (r Credentials) IsEmpty() bool {
+	return r.Username == "" && r.Password == "" && r.IdentityToken == ""
+}
+
+// DockerConfigPath returns the path to the Docker client configuration file.
+// Honors the DOCKER_CONFIG environment variable
+func DockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	return filepath.Join(os.Getenv(constants.EnvHome), ".docker", "config.json")
+}
+
+// CredentialsFromDockerConfig returns the credentials for the specified
+// registry from the Docker client configuration file at path, i.e.
+// the credentials saved with `docker login`.
+//
+// Returns empty credentials if the configuration file does not exist or has
+// no entry for the registry. Credential helpers are not supported
+func CredentialsFromDockerConfig(path, registry string) (*Credentials, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = trace.ConvertSystemError(err)
+		if trace.IsNotFound(err) {
+			return &Credentials{}, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, trace.Wrap(err, "failed to parse %v", path)
+	}
+	for address, auth := range config.Auths {
+		if normalizeRegistry(address) != normalizeRegistry(registry) {
+			continue
+		}
+		creds := Credentials{
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, trace.Wrap(err, "invalid auth entry for %v in %v", address, path)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, trace.BadParameter("invalid auth entry for %v in %v", address, path)
+			}
+			creds.Username, creds.Password = parts[0], parts[1]
+		}
+		return &creds, nil
+	}
+	return &Credentials{}, nil
+}
+
+// normalizeRegistry strips the scheme and path from the registry address
+// as saved by the Docker client
+func normalizeRegistry(address string) string {
+	address = strings.TrimPrefix(address, "https://")
+	address = strings.TrimPrefix(address, "http://")
+	if i := strings.Index(address, "/"); i >= 0 {
+		address = address[:i]
+	}
+	return strings.ToLower(address)
+}
+
+// credentialStore implements auth.CredentialStore with static credentials
+type credentialStore struct {
+	Credentials
+	refreshTokens map[string]string
+}
+
+// Basic returns basic auth for the given URL
+func (r *credentialStore) Basic(*url.URL) (string, string) {
+	return r.Username, r.Password
+}
+
+// RefreshToken returns a refresh token for the given URL and service
+func (r *credentialStore) RefreshToken(_ *url.URL, service string) string {
+	if token, ok := r.refreshTokens[service]; ok {
+		return token
+	}
+	return r.IdentityToken
+}
+
+// SetRefreshToken sets the refresh token for the given URL and service
+func (r *credentialStore) SetRefreshToken(_ *url.URL, service, token string) {
+	if r.refreshTokens == nil {
+		r.refreshTokens = make(map[string]string)
+	}
+	r.refreshTokens[service] = token
+}
+
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+type dockerAuth struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocipack
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/gravitational/trace"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// MediaTypeManifest specifies the media type of the OCI image manifest
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	// MediaTypeConfig specifies the media type of the config blob that
+	// stores the package envelope
+	MediaTypeConfig = "application/vnd.gravitational.package.config.v1+json"
+	// MediaTypeLayer specifies the media type of the layer that stores
+	// the package data
+	MediaTypeLayer = "application/vnd.gravitational.package.layer.v1.tar+gzip"
+)
+
+func init() {
+	err := distribution.RegisterManifestSchema(MediaTypeManifest, unmarshalManifest)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Manifest is an OCI image manifest that describes a package.
+// The package envelope is stored in the config blob and the package
+// data as the single layer
+type Manifest struct {
+	manifest.Versioned
+	// Config references the package envelope
+	Config distribution.Descriptor `json:"config"`
+	// Layers references the package data
+	Layers []distribution.Descriptor `json:"layers"`
+
+	canonical []byte
+}
+
+// References returns the descriptors of all blobs referenced by this manifest
+func (m *Manifest) References() []distribution.Descriptor {
+	return append([]distribution.Descriptor{m.Config}, m.Layers...)
+}
+
+// Payload returns the media type and the serialized form of this manifest
+func (m *Manifest) Payload() (mediaType string, payload []byte, err error) {
+	return MediaTypeManifest, m.canonical, nil
+}
+
+// newManifest returns a new manifest for the package with the specified
+// config and data blobs
+func newManifest(config, data distribution.Descriptor) (*Manifest, error) {
+	m := &Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     MediaTypeManifest,
+		},
+		Config: config,
+		Layers: []distribution.Descriptor{data},
+	}
+	var err error
+	m.canonical, err = json.MarshalIndent(m, "", "   ")
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return m, nil
+}
+
+func unmarshalManifest(payload []byte) (distribution.Manifest, distribution.Descriptor, error) {
+	var m Manifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, distribution.Descriptor{}, trace.Wrap(err)
+	}
+	m.canonical = payload
+	return &m, distribution.Descriptor{
+		Digest:    digest.FromBytes(payload),
+		Size:      int64(len(payload)),
+		MediaType: MediaTypeManifest,
+	}, nil
+}
+
+// checkManifest validates that the specified manifest describes a package
+func checkManifest(m distribution.Manifest) (*Manifest, error) {
+	pkg, ok := m.(*Manifest)
+	if !ok {
+		mediaType, _, _ := m.Payload()
+		return nil, trace.BadParameter("unsupported manifest type %q", mediaType)
+	}
+	if pkg.Config.MediaType != MediaTypeConfig {
+		return nil, trace.BadParameter("manifest does not describe a package: unsupported config type %q",
+			pkg.Config.MediaType)
+	}
+	if len(pkg.Layers) != 1 || pkg.Layers[0].MediaType != MediaTypeLayer {
+		return nil, trace.BadParameter("manifest does not describe a package: expected a single %q layer",
+			MediaTypeLayer)
+	}
+	return pkg, nil
+}
+
+// marshalConfig returns the contents of the config blob for the specified
+// package envelope
+func marshalConfig(envelope pack.PackageEnvelope) ([]byte, error) {
+	bytes, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return bytes, nil
+}
+
+// unmarshalConfig returns the package envelope from the contents of the config blob
+func unmarshalConfig(data []byte) (*pack.PackageEnvelope, error) {
+	var envelope pack.PackageEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &envelope, nil
+}
+
+// repositoryName returns the name of the registry repository that stores
+// the package specified with locator
+func repositoryName(namespace string, locator loc.Locator) string {
+	name := strings.ToLower(locator.Repository + "/" + locator.Name)
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// tagName returns the registry tag for the version of the specified package.
+// Build metadata separator ('+') is not valid in a tag and is replaced with '_'
+func tagName(locator loc.Locator) string {
+	return strings.Replace(locator.Version, "+", "_", -1)
+}
@@ -0,0 +1,475 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package ocipack implements a package service that stores packages
+in an OCI-compliant container registry (e.g. Harbor, Amazon ECR
+or Google Container Registry).
+
+A package gravitational.io/planet:1.0.0 is stored in the repository
+<namespace>/gravitational.io/planet with tag 1.0.0. The image manifest
+references the package envelope as the config blob and the package
+data as the single layer.
+*/
+package ocipack
+
+import (
+	"context"
+	"crypto/sha512"
+	"crypto/tls"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	registryclient "github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/docker/distribution/registry/client/transport"
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// Scheme is the URL scheme that identifies an OCI registry,
+// e.g. oci://registry.example.com/gravity
+const Scheme = "oci"
+
+// IsURL returns true if the specified URL references an OCI registry
+func IsURL(url string) bool {
+	return strings.HasPrefix(url, Scheme+"://")
+}
+
+// ParseURL parses the specified OCI registry URL in the
+// oci://<registry>[/<namespace>] format
+func ParseURL(registryURL string) (registry, namespace string, err error) {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return "", "", trace.Wrap(err)
+	}
+	if u.Scheme != Scheme {
+		return "", "", trace.BadParameter("expected %v:// URL but got %q", Scheme, registryURL)
+	}
+	if u.Host == "" {
+		return "", "", trace.BadParameter("registry address is missing in %q", registryURL)
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// Config defines the configuration of the OCI registry package service
+type Config struct {
+	// Registry is the address of the registry in host[:port] format
+	Registry string
+	// Namespace is an optional repository prefix to store packages under
+	Namespace string
+	// Credentials specifies the registry credentials.
+	// Anonymous access is used if unspecified
+	Credentials Credentials
+	// Insecure disables verification of the registry certificate
+	Insecure bool
+	// Transport is an optional HTTP transport to use
+	Transport http.RoundTripper
+	// Clock is used to timestamp created packages
+	Clock clockwork.Clock
+	// FieldLogger is the logger
+	logrus.FieldLogger
+}
+
+// CheckAndSetDefaults validates the configuration and sets default values
+func (r *Config) CheckAndSetDefaults() error {
+	if r.Registry == "" {
+		return trace.BadParameter("registry address is required")
+	}
+	r.Namespace = strings.Trim(r.Namespace, "/")
+	if r.Transport == nil {
+		r.Transport = &http.Transport{
+			Proxy:               httplib.ProxyFromPolicy,
+			TLSHandshakeTimeout: defaults.DialTimeout,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: r.Insecure,
+			},
+		}
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = logrus.WithFields(logrus.Fields{
+			trace.Component: "ocipack",
+			"registry":      r.Registry,
+		})
+	}
+	return nil
+}
+
+// New returns a new package service backed by the OCI registry
+func New(config Config) (*PackageService, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &PackageService{
+		Config:     config,
+		challenges: challenge.NewSimpleManager(),
+		creds:      &credentialStore{Credentials: config.Credentials},
+	}, nil
+}
+
+// PackageService stores packages in an OCI registry
+type PackageService struct {
+	Config
+	challenges challenge.Manager
+	creds      *credentialStore
+}
+
+// PackageDownloadURL returns the URL of the specified package
+func (r *PackageService) PackageDownloadURL(locator loc.Locator) string {
+	return fmt.Sprintf("%v://%v/%v:%v", Scheme, r.Registry,
+		repositoryName(r.Namespace, locator), tagName(locator))
+}
+
+// PortalURL returns the URL of the registry
+func (r *PackageService) PortalURL() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%v://%v", Scheme, r.Registry)
+	}
+	return fmt.Sprintf("%v://%v/%v", Scheme, r.Registry, r.Namespace)
+}
+
+// UpsertRepository is a no-op as registry repositories are created on push
+func (r *PackageService) UpsertRepository(repository string, expires time.Time) error {
+	return nil
+}
+
+// DeleteRepository is not supported
+func (r *PackageService) DeleteRepository(repository string) error {
+	return trace.NotImplemented("deleting repositories is not supported for OCI registries")
+}
+
+// GetRepository is not supported
+func (r *PackageService) GetRepository(repository string) (storage.Repository, error) {
+	return nil, trace.NotImplemented("querying repositories is not supported for OCI registries")
+}
+
+// GetRepositories is not supported
+func (r *PackageService) GetRepositories() ([]string, error) {
+	return nil, trace.NotImplemented("listing repositories is not supported for OCI registries")
+}
+
+// GetPackages is not supported
+func (r *PackageService) GetPackages(repository string) ([]pack.PackageEnvelope, error) {
+	return nil, trace.NotImplemented("listing packages is not supported for OCI registries")
+}
+
+// CreatePackage pushes a new package to the registry.
+// Returns trace.AlreadyExists if the package already exists
+func (r *PackageService) CreatePackage(locator loc.Locator, data io.Reader, options ...pack.PackageOption) (*pack.PackageEnvelope, error) {
+	_, err := r.ReadPackageEnvelope(locator)
+	if err == nil {
+		return nil, trace.AlreadyExists("package %v already exists", locator)
+	}
+	if !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	return r.UpsertPackage(locator, data, options...)
+}
+
+// UpsertPackage pushes the package to the registry replacing the existing one
+func (r *PackageService) UpsertPackage(locator loc.Locator, data io.Reader, options ...pack.PackageOption) (*pack.PackageEnvelope, error) {
+	ctx := context.TODO()
+	repo, err := r.repository(ctx, locator, "pull", "push")
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	r.WithField("package", locator).Info("Push package.")
+	layer, checksum, err := r.pushData(ctx, repo, data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	pkg := storage.Package{
+		Repository: locator.Repository,
+		Name:       locator.Name,
+		Version:    locator.Version,
+		Created:    r.Clock.Now().UTC(),
+	}
+	for _, option := range options {
+		option(&pkg)
+	}
+	envelope := pack.PackageEnvelope{
+		Locator:       locator,
+		SHA512:        checksum,
+		SizeBytes:     layer.Size,
+		RuntimeLabels: pkg.RuntimeLabels,
+		Hidden:        pkg.Hidden,
+		Encrypted:     pkg.Encrypted,
+		Type:          pkg.Type,
+		Manifest:      pkg.Manifest,
+		Created:       pkg.Created,
+		CreatedBy:     pkg.CreatedBy,
+	}
+	if err := r.pushManifest(ctx, repo, envelope, layer); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &envelope, nil
+}
+
+// UpdatePackageLabels updates the labels of the specified package
+func (r *PackageService) UpdatePackageLabels(locator loc.Locator, addLabels map[string]string, removeLabels []string) error {
+	ctx := context.TODO()
+	repo, err := r.repository(ctx, locator, "pull", "push")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	envelope, m, err := r.readEnvelope(ctx, repo, locator)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if envelope.RuntimeLabels == nil {
+		envelope.RuntimeLabels = make(map[string]string)
+	}
+	for _, label := range removeLabels {
+		delete(envelope.RuntimeLabels, label)
+	}
+	for name, value := range addLabels {
+		envelope.RuntimeLabels[name] = value
+	}
+	return trace.Wrap(r.pushManifest(ctx, repo, *envelope, m.Layers[0]))
+}
+
+// DeletePackage deletes the specified package from the registry
+func (r *PackageService) DeletePackage(locator loc.Locator) error {
+	ctx := context.TODO()
+	repo, err := r.repository(ctx, locator, "pull", "delete")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	desc, err := repo.Tags(ctx).Get(ctx, tagName(locator))
+	if err != nil {
+		return trace.Wrap(convertError(err, locator))
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(convertError(manifests.Delete(ctx, desc.Digest), locator))
+}
+
+// ReadPackage returns the package envelope and the reader for the package data
+func (r *PackageService) ReadPackage(locator loc.Locator) (*pack.PackageEnvelope, io.ReadCloser, error) {
+	ctx := context.TODO()
+	repo, err := r.repository(ctx, locator, "pull")
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	envelope, m, err := r.readEnvelope(ctx, repo, locator)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	reader, err := repo.Blobs(ctx).Open(ctx, m.Layers[0].Digest)
+	if err != nil {
+		return nil, nil, trace.Wrap(convertError(err, locator))
+	}
+	return envelope, reader, nil
+}
+
+// ReadPackageEnvelope returns the envelope of the specified package
+func (r *PackageService) ReadPackageEnvelope(locator loc.Locator) (*pack.PackageEnvelope, error) {
+	ctx := context.TODO()
+	repo, err := r.repository(ctx, locator, "pull")
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	envelope, _, err := r.readEnvelope(ctx, repo, locator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return envelope, nil
+}
+
+func (r *PackageService) readEnvelope(ctx context.Context, repo distribution.Repository, locator loc.Locator) (*pack.PackageEnvelope, *Manifest, error) {
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	m, err := manifests.Get(ctx, "", distribution.WithTag(tagName(locator)))
+	if err != nil {
+		return nil, nil, trace.Wrap(convertError(err, locator))
+	}
+	pkg, err := checkManifest(m)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	config, err := repo.Blobs(ctx).Get(ctx, pkg.Config.Digest)
+	if err != nil {
+		return nil, nil, trace.Wrap(convertError(err, locator))
+	}
+	envelope, err := unmarshalConfig(config)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	if !envelope.Locator.IsEqualTo(locator) {
+		return nil, nil, trace.BadParameter("expected package %v but registry has %v",
+			locator, envelope.Locator)
+	}
+	return envelope, pkg, nil
+}
+
+// pushData uploads the package data as a layer blob.
+// Returns the layer descriptor and the checksum of the data
+func (r *PackageService) pushData(ctx context.Context, repo distribution.Repository, data io.Reader) (desc distribution.Descriptor, checksum string, err error) {
+	writer, err := repo.Blobs(ctx).Create(ctx)
+	if err != nil {
+		return desc, "", trace.Wrap(err)
+	}
+	defer writer.Close()
+	digester := digest.Canonical.Digester()
+	hasher := sha512.New()
+	size, err := io.Copy(writer, io.TeeReader(data, io.MultiWriter(digester.Hash(), hasher)))
+	if err != nil {
+		return desc, "", trace.Wrap(err)
+	}
+	desc, err = writer.Commit(ctx, distribution.Descriptor{
+		MediaType: MediaTypeLayer,
+		Digest:    digester.Digest(),
+		Size:      size,
+	})
+	if err != nil {
+		return desc, "", trace.Wrap(err)
+	}
+	// registries are not required to return the media type
+	desc.MediaType = MediaTypeLayer
+	desc.Size = size
+	return desc, checksumString(hasher), nil
+}
+
+// pushManifest uploads the config blob for the specified package envelope
+// and tags the manifest referencing the config and the layer
+func (r *PackageService) pushManifest(ctx context.Context, repo distribution.Repository, envelope pack.PackageEnvelope, layer distribution.Descriptor) error {
+	configBytes, err := marshalConfig(envelope)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	config, err := repo.Blobs(ctx).Put(ctx, MediaTypeConfig, configBytes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	config.MediaType = MediaTypeConfig
+	m, err := newManifest(config, layer)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = manifests.Put(ctx, m, distribution.WithTag(tagName(envelope.Locator)))
+	return trace.Wrap(err)
+}
+
+// repository returns the registry repository for the specified package
+// authorized for the given actions
+func (r *PackageService) repository(ctx context.Context, locator loc.Locator, actions ...string) (distribution.Repository, error) {
+	name, err := reference.WithName(repositoryName(r.Namespace, locator))
+	if err != nil {
+		return nil, trace.Wrap(err, "package %v cannot be stored in a registry", locator)
+	}
+	baseURL, err := r.ping()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	transport := transport.NewTransport(r.Transport, auth.NewAuthorizer(r.challenges,
+		auth.NewTokenHandler(r.Transport, r.creds, name.Name(), actions...),
+		auth.NewBasicHandler(r.creds)))
+	repo, err := registryclient.NewRepository(ctx, name, baseURL, transport)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return repo, nil
+}
+
+// ping queries the registry to determine the supported authentication
+// schemes and returns the base URL of the registry
+func (r *PackageService) ping() (baseURL string, err error) {
+	baseURL = fmt.Sprintf("https://%v", r.Registry)
+	endpoint, err := url.Parse(baseURL + "/v2/")
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if challenges, err := r.challenges.GetChallenges(*endpoint); err == nil && len(challenges) != 0 {
+		return baseURL, nil
+	}
+	client := &http.Client{Transport: r.Transport, Timeout: defaults.DialTimeout}
+	resp, err := client.Get(endpoint.String())
+	if err != nil {
+		return "", trace.ConnectionProblem(err, "failed to connect to registry %v", r.Registry)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if err := r.challenges.AddResponse(resp); err != nil {
+		return "", trace.Wrap(err)
+	}
+	return baseURL, nil
+}
+
+// convertError converts the specified registry client error
+// to the corresponding trace error
+func convertError(err error, locator loc.Locator) error {
+	if err == nil {
+		return nil
+	}
+	switch e := err.(type) {
+	case distribution.ErrTagUnknown, distribution.ErrManifestUnknown:
+		return trace.NotFound("package %v not found", locator)
+	case *registryclient.UnexpectedHTTPResponseError:
+		if e.StatusCode == http.StatusNotFound {
+			return trace.NotFound("package %v not found", locator)
+		}
+	case errcode.Errors:
+		if len(e) != 0 {
+			return convertError(e[0], locator)
+		}
+	case errcode.Error:
+		switch e.Code {
+		case v2.ErrorCodeManifestUnknown, v2.ErrorCodeNameUnknown, v2.ErrorCodeBlobUnknown:
+			return trace.NotFound("package %v not found", locator)
+		case errcode.ErrorCodeUnauthorized, errcode.ErrorCodeDenied:
+			return trace.AccessDenied("access to package %v denied: %v", locator, e.Message)
+		}
+	}
+	if err == distribution.ErrBlobUnknown {
+		return trace.NotFound("package %v not found", locator)
+	}
+	return err
+}
+
+func checksumString(hasher hash.Hash) string {
+	// Same format as the local blob storage
+	return fmt.Sprintf("%x", hasher.Sum(nil)[:sha512.Size/2])
+}
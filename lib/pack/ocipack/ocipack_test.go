@@ -0,0 +1,337 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocipack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/opencontainers/go-digest"
+	"gopkg.in/check.v1"
+)
+
+func TestOCIPack(t *testing.T) { check.TestingT(t) }
+
+type OCISuite struct {
+	registry *fakeRegistry
+	server   *httptest.Server
+	clock    clockwork.FakeClock
+}
+
+var _ = check.Suite(&OCISuite{})
+
+func (s *OCISuite) SetUpTest(c *check.C) {
+	s.registry = newFakeRegistry()
+	s.server = httptest.NewTLSServer(s.registry)
+	s.clock = clockwork.NewFakeClockAt(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func (s *OCISuite) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+func (s *OCISuite) TestPackageRoundTrip(c *check.C) {
+	service := s.newService(c, Credentials{})
+	locator := loc.MustParseLocator("gravitational.io/planet:1.0.0+build.1")
+	data := []byte("package data")
+
+	envelope, err := service.CreatePackage(locator, bytes.NewReader(data),
+		pack.WithLabels(map[string]string{"purpose": "runtime"}),
+		pack.WithManifest("runtime", []byte("manifest")))
+	c.Assert(err, check.IsNil)
+	c.Assert(envelope.SizeBytes, check.Equals, int64(len(data)))
+	c.Assert(s.registry.tagged("gravity/gravitational.io/planet", "1.0.0_build.1"), check.Equals, true)
+
+	envelope, reader, err := service.ReadPackage(locator)
+	c.Assert(err, check.IsNil)
+	defer reader.Close()
+	obtained, err := ioutil.ReadAll(reader)
+	c.Assert(err, check.IsNil)
+	c.Assert(obtained, check.DeepEquals, data)
+	c.Assert(envelope.Locator, check.Equals, locator)
+	c.Assert(envelope.RuntimeLabels, check.DeepEquals, map[string]string{"purpose": "runtime"})
+	c.Assert(envelope.Type, check.Equals, "runtime")
+	c.Assert(envelope.Manifest, check.DeepEquals, []byte("manifest"))
+	c.Assert(envelope.Created, check.Equals, s.clock.Now())
+
+	_, err = service.CreatePackage(locator, bytes.NewReader(data))
+	c.Assert(trace.IsAlreadyExists(err), check.Equals, true, check.Commentf("%v", err))
+
+	err = service.UpdatePackageLabels(locator, map[string]string{"installed": "installed"}, []string{"purpose"})
+	c.Assert(err, check.IsNil)
+	envelope, err = service.ReadPackageEnvelope(locator)
+	c.Assert(err, check.IsNil)
+	c.Assert(envelope.RuntimeLabels, check.DeepEquals, map[string]string{"installed": "installed"})
+
+	c.Assert(service.DeletePackage(locator), check.IsNil)
+	_, err = service.ReadPackageEnvelope(locator)
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *OCISuite) TestMissingPackage(c *check.C) {
+	service := s.newService(c, Credentials{})
+	_, _, err := service.ReadPackage(loc.MustParseLocator("gravitational.io/missing:1.0.0"))
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *OCISuite) TestBasicAuth(c *check.C) {
+	s.registry.username, s.registry.password = "user", "secret"
+	locator := loc.MustParseLocator("gravitational.io/app:1.0.0")
+
+	service := s.newService(c, Credentials{Username: "user", Password: "wrong"})
+	_, err := service.UpsertPackage(locator, bytes.NewReader([]byte("data")))
+	c.Assert(err, check.NotNil)
+
+	service = s.newService(c, Credentials{Username: "user", Password: "secret"})
+	_, err = service.UpsertPackage(locator, bytes.NewReader([]byte("data")))
+	c.Assert(err, check.IsNil)
+}
+
+func (s *OCISuite) TestParsesURL(c *check.C) {
+	registry, namespace, err := ParseURL("oci://registry.example.com:5000/gravity/packages/")
+	c.Assert(err, check.IsNil)
+	c.Assert(registry, check.Equals, "registry.example.com:5000")
+	c.Assert(namespace, check.Equals, "gravity/packages")
+
+	_, _, err = ParseURL("https://registry.example.com")
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
+
+func (s *OCISuite) TestReadsDockerConfig(c *check.C) {
+	path := filepath.Join(c.MkDir(), "config.json")
+	config := fmt.Sprintf(`{"auths": {
+  "https://registry.example.com/v1/": {"auth": %q},
+  "gcr.io": {"username": "oauth2accesstoken", "password": "token"}
+}}`, base64.StdEncoding.EncodeToString([]byte("user:pass:word")))
+	c.Assert(ioutil.WriteFile(path, []byte(config), 0600), check.IsNil)
+
+	creds, err := CredentialsFromDockerConfig(path, "registry.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(*creds, check.DeepEquals, Credentials{Username: "user", Password: "pass:word"})
+
+	creds, err = CredentialsFromDockerConfig(path, "gcr.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(*creds, check.DeepEquals, Credentials{Username: "oauth2accesstoken", Password: "token"})
+
+	creds, err = CredentialsFromDockerConfig(path, "other.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.IsEmpty(), check.Equals, true)
+
+	creds, err = CredentialsFromDockerConfig(filepath.Join(c.MkDir(), "missing.json"), "gcr.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(creds.IsEmpty(), check.Equals, true)
+}
+
+func (s *OCISuite) newService(c *check.C, creds Credentials) *PackageService {
+	service, err := New(Config{
+		Registry:    strings.TrimPrefix(s.server.URL, "https://"),
+		Namespace:   "gravity",
+		Credentials: creds,
+		Transport:   s.server.Client().Transport,
+		Clock:       s.clock,
+	})
+	c.Assert(err, check.IsNil)
+	return service
+}
+
+// fakeRegistry implements a subset of the registry API sufficient
+// to push and pull packages
+type fakeRegistry struct {
+	sync.Mutex
+	// username and password enable basic authentication if set
+	username, password string
+	blobs              map[digest.Digest][]byte
+	uploads            map[string][]byte
+	manifests          map[digest.Digest]fakeManifest
+	tags               map[string]digest.Digest
+	nextID             int
+}
+
+type fakeManifest struct {
+	mediaType string
+	payload   []byte
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		blobs:     make(map[digest.Digest][]byte),
+		uploads:   make(map[string][]byte),
+		manifests: make(map[digest.Digest]fakeManifest),
+		tags:      make(map[string]digest.Digest),
+	}
+}
+
+var (
+	uploadsPath   = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/$`)
+	uploadPath    = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([^/]+)$`)
+	blobPath      = regexp.MustCompile(`^/v2/(.+)/blobs/([^/]+)$`)
+	manifestsPath = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+)
+
+func (r *fakeRegistry) tagged(name, tag string) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.tags[name+":"+tag]
+	return ok
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	if r.username != "" {
+		username, password, ok := req.BasicAuth()
+		if !ok || username != r.username || password != r.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED")
+			return
+		}
+	}
+	path := req.URL.Path
+	switch {
+	case path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case uploadsPath.MatchString(path) && req.Method == http.MethodPost:
+		name := uploadsPath.FindStringSubmatch(path)[1]
+		r.nextID++
+		id := fmt.Sprint(r.nextID)
+		r.uploads[id] = nil
+		w.Header().Set("Location", fmt.Sprintf("/v2/%v/blobs/uploads/%v", name, id))
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+	case uploadPath.MatchString(path):
+		r.serveUpload(w, req, uploadPath.FindStringSubmatch(path))
+	case blobPath.MatchString(path):
+		dgst := digest.Digest(blobPath.FindStringSubmatch(path)[2])
+		data, ok := r.blobs[dgst]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case manifestsPath.MatchString(path):
+		match := manifestsPath.FindStringSubmatch(path)
+		r.serveManifest(w, req, match[1], match[2])
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (r *fakeRegistry) serveUpload(w http.ResponseWriter, req *http.Request, match []string) {
+	name, id := match[1], match[2]
+	data, ok := r.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN")
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	data = append(data, body...)
+	switch req.Method {
+	case http.MethodPatch:
+		r.uploads[id] = data
+		w.Header().Set("Location", req.URL.Path)
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", fmt.Sprintf("0-%v", len(data)-1))
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		dgst := digest.Digest(req.URL.Query().Get("digest"))
+		if dgst != digest.FromBytes(data) {
+			writeError(w, http.StatusBadRequest, "DIGEST_INVALID")
+			return
+		}
+		delete(r.uploads, id)
+		r.blobs[dgst] = data
+		w.Header().Set("Location", fmt.Sprintf("/v2/%v/blobs/%v", name, dgst))
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *fakeRegistry) serveManifest(w http.ResponseWriter, req *http.Request, name, reference string) {
+	dgst, err := digest.Parse(reference)
+	if err != nil {
+		dgst = r.tags[name+":"+reference]
+	}
+	switch req.Method {
+	case http.MethodPut:
+		payload, _ := ioutil.ReadAll(req.Body)
+		dgst = digest.FromBytes(payload)
+		r.manifests[dgst] = fakeManifest{
+			mediaType: req.Header.Get("Content-Type"),
+			payload:   payload,
+		}
+		r.tags[name+":"+reference] = dgst
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		m, ok := r.manifests[dgst]
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(m.payload)))
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			w.Write(m.payload)
+		}
+	case http.MethodDelete:
+		if _, ok := r.manifests[dgst]; !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		delete(r.manifests, dgst)
+		for tag, tagged := range r.tags {
+			if tagged == dgst {
+				delete(r.tags, tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"errors": [{"code": %q, "message": %q}]}`, code, strings.ToLower(code))
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/run"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// ChunkedWriter is implemented by package services that can receive
+// package contents in parts
+type ChunkedWriter interface {
+	// CreateUpload starts a new upload session for the package contents
+	// described by spec or returns the existing session for the same contents
+	CreateUpload(spec UploadSpec) (*UploadSession, error)
+	// UploadPart uploads the part of the package contents described by chunk
+	UploadPart(session UploadSession, chunk Chunk, data io.Reader) error
+	// CompleteUpload assembles the uploaded parts into the package
+	CompleteUpload(session UploadSession) (*PackageEnvelope, error)
+}
+
+// UploadSpec describes the package contents to upload in parts
+type UploadSpec struct {
+	// Locator is the package to create
+	Locator loc.Locator `json:"locator"`
+	// Size is the total size of the package contents in bytes
+	Size int64 `json:"size"`
+	// SHA512 is the sha-512 checksum of the package contents
+	// in the format of the package envelope checksum
+	SHA512 string `json:"sha512"`
+	// ChunkSize is the size of all but the last part
+	ChunkSize int64 `json:"chunk_size"`
+	// Upsert is whether the existing package should be overwritten
+	Upsert bool `json:"upsert"`
+	// Labels specifies the package runtime labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// Hidden is whether the package should not be displayed
+	Hidden bool `json:"hidden"`
+	// Type specifies the application package type
+	Type string `json:"type,omitempty"`
+	// Manifest specifies the application manifest
+	Manifest []byte `json:"manifest,omitempty"`
+}
+
+// Check validates the upload specification
+func (r UploadSpec) Check() error {
+	if r.Locator.IsEmpty() {
+		return trace.BadParameter("missing package locator")
+	}
+	if r.Size < 0 {
+		return trace.BadParameter("package size should not be negative, got %v", r.Size)
+	}
+	if r.SHA512 == "" {
+		return trace.BadParameter("missing package checksum")
+	}
+	if r.ChunkSize <= 0 {
+		return trace.BadParameter("chunk size should be positive, got %v", r.ChunkSize)
+	}
+	return nil
+}
+
+// Options returns the package options described by this specification
+func (r UploadSpec) Options() []PackageOption {
+	options := []PackageOption{WithLabels(r.Labels), WithHidden(r.Hidden)}
+	if len(r.Manifest) != 0 {
+		options = append(options, WithManifest(r.Type, r.Manifest))
+	}
+	return options
+}
+
+// CheckPart verifies that the chunk is a valid part of the package contents
+func (r UploadSpec) CheckPart(chunk Chunk) error {
+	if chunk.Offset < 0 || chunk.Offset%r.ChunkSize != 0 || (chunk.Offset >= r.Size && r.Size != 0) {
+		return trace.BadParameter("invalid part offset %v", chunk.Offset)
+	}
+	if expected := r.partSize(chunk.Offset); chunk.Size != expected {
+		return trace.BadParameter("invalid size of part at offset %v: expected %v bytes, got %v",
+			chunk.Offset, expected, chunk.Size)
+	}
+	return nil
+}
+
+// Parts returns the number of parts the package contents are split into
+func (r UploadSpec) Parts() int {
+	if r.Size == 0 {
+		return 1
+	}
+	return int((r.Size + r.ChunkSize - 1) / r.ChunkSize)
+}
+
+func (r UploadSpec) partSize(offset int64) int64 {
+	if offset+r.ChunkSize > r.Size {
+		return r.Size - offset
+	}
+	return r.ChunkSize
+}
+
+// UploadSession describes the state of the package upload
+type UploadSession struct {
+	// ID uniquely identifies the upload session
+	ID string `json:"id"`
+	// Spec describes the uploaded package contents
+	Spec UploadSpec `json:"spec"`
+	// Parts lists the parts that have already been received
+	Parts []Chunk `json:"parts"`
+}
+
+// HasPart returns true if the session has already received the specified part
+func (r UploadSession) HasPart(chunk Chunk) bool {
+	for _, part := range r.Parts {
+		if part == chunk {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadRequest describes a request to upload package contents in parts
+type UploadRequest struct {
+	// Writer is the package service to upload the package to
+	Writer ChunkedWriter
+	// Package is the package to create
+	Package loc.Locator
+	// Reader provides the package contents
+	Reader io.ReaderAt
+	// Size is the size of the package contents
+	Size int64
+	// Upsert is whether the existing package should be overwritten
+	Upsert bool
+	// Options specifies additional package attributes
+	Options []PackageOption
+	// ChunkSize is the size of a single part
+	ChunkSize int64
+	// Parallel is the number of parts to upload concurrently
+	Parallel int
+	// Progress is optional progress reporter
+	Progress ProgressReporter
+}
+
+// CheckAndSetDefaults validates the request and sets defaults
+func (r *UploadRequest) CheckAndSetDefaults() error {
+	if r.Writer == nil {
+		return trace.BadParameter("missing Writer")
+	}
+	if r.Reader == nil {
+		return trace.BadParameter("missing Reader")
+	}
+	if r.ChunkSize == 0 {
+		r.ChunkSize = defaults.UploadChunkSize
+	}
+	if r.Parallel == 0 {
+		r.Parallel = defaults.UploadParallel
+	}
+	if r.Progress == nil {
+		r.Progress = DiscardReporter
+	}
+	return nil
+}
+
+// UploadPackage uploads the package contents in parts of req.ChunkSize bytes
+// with up to req.Parallel parts in flight.
+//
+// Each part is sent along with its checksum and retried on network errors.
+// The upload session is identified by the package contents, so an interrupted
+// upload of the same contents is resumed and only the parts the server has not
+// received yet are sent again. The server verifies the checksum of the
+// assembled contents before creating the package
+func UploadPackage(ctx context.Context, req UploadRequest) (*PackageEnvelope, error) {
+	if err := req.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	checksum, err := utils.SHA512HalfReader(io.NewSectionReader(req.Reader, 0, req.Size))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var pkg storage.Package
+	for _, option := range req.Options {
+		option(&pkg)
+	}
+	session, err := req.Writer.CreateUpload(UploadSpec{
+		Locator:   req.Package,
+		Size:      req.Size,
+		SHA512:    checksum,
+		ChunkSize: req.ChunkSize,
+		Upsert:    req.Upsert,
+		Labels:    pkg.RuntimeLabels,
+		Hidden:    pkg.Hidden,
+		Type:      pkg.Type,
+		Manifest:  pkg.Manifest,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	manifest, err := NewChunkManifest(io.NewSectionReader(req.Reader, 0, req.Size), req.ChunkSize)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(manifest.Chunks) == 0 {
+		manifest.Chunks = []Chunk{{SHA256: emptySHA256}}
+	}
+	var mu sync.Mutex
+	var current int64
+	report := func(size int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		current += size
+		req.Progress.Report(current, req.Size)
+	}
+	group, ctx := run.WithContext(ctx, run.WithParallel(req.Parallel))
+	for _, chunk := range manifest.Chunks {
+		chunk := chunk
+		if session.HasPart(chunk) {
+			report(chunk.Size)
+			continue
+		}
+		group.Go(ctx, func() error {
+			if err := ctx.Err(); err != nil {
+				return trace.Wrap(err)
+			}
+			err := utils.RetryOnNetworkError(defaults.DownloadRetryPeriod, defaults.DownloadRetryAttempts, func() error {
+				return req.Writer.UploadPart(*session, chunk,
+					io.NewSectionReader(req.Reader, chunk.Offset, chunk.Size))
+			})
+			if err != nil {
+				return trace.Wrap(err, "failed to upload part at offset %v of package %v", chunk.Offset, req.Package)
+			}
+			report(chunk.Size)
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	log.WithField("package", req.Package).Info("All parts uploaded.")
+	return req.Writer.CompleteUpload(*session)
+}
+
+// UploadSessionID returns the ID of the upload session for the contents
+// described by spec created by the specified user
+func UploadSessionID(user string, spec UploadSpec) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%v\x00%v\x00%v\x00%v\x00%v", user, spec.Locator, spec.SHA512, spec.Size, spec.ChunkSize)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// emptySHA256 is the sha-256 checksum of empty contents
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
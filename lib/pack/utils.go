@@ -71,13 +71,10 @@ func Unpack(p PackageService, loc loc.Locator, targetDir string, opts *dockerarc
 	var err error
 	// if target dir is not provided, unpack to the default location
 	if targetDir == "" {
-		stateDir, err := state.GetStateDir()
+		targetDir, err = DefaultUnpackedPath(loc)
 		if err != nil {
 			return trace.Wrap(err)
 		}
-		baseDir := filepath.Join(stateDir, defaults.LocalDir,
-			defaults.PackagesDir, defaults.UnpackedDir)
-		targetDir = PackagePath(baseDir, loc)
 		log.Infof("Unpacking %v into the default directory %v.",
 			loc, targetDir)
 	}
@@ -119,6 +116,65 @@ func UnpackIfNotUnpacked(p PackageService, loc loc.Locator, targetDir string, op
 	return nil
 }
 
+// DefaultUnpackedPath returns the directory the specified package
+// is unpacked into by default
+func DefaultUnpackedPath(loc loc.Locator) (string, error) {
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	baseDir := filepath.Join(stateDir, defaults.LocalDir,
+		defaults.PackagesDir, defaults.UnpackedDir)
+	return PackagePath(baseDir, loc), nil
+}
+
+// Stage unpacks the specified package into the default location ahead of time
+// and marks it as staged so the operation that needs the unpacked contents
+// can reuse them instead of unpacking the package again.
+//
+// The package is unpacked into a temporary directory first so the default
+// location only appears once the package has been fully unpacked
+func Stage(p PackageService, loc loc.Locator) error {
+	targetDir, err := DefaultUnpackedPath(loc)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	isUnpacked, err := IsUnpacked(targetDir)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !isUnpacked {
+		tempDir := targetDir + ".staging"
+		if err := os.RemoveAll(tempDir); err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		if err := Unpack(p, loc, tempDir, nil); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := os.Rename(tempDir, targetDir); err != nil {
+			return trace.ConvertSystemError(err)
+		}
+	}
+	return trace.Wrap(p.UpdatePackageLabels(loc, StagedLabels, nil))
+}
+
+// IsStaged returns true if the specified package has been staged
+// and its unpacked contents are in place
+func IsStaged(p PackageService, loc loc.Locator) (bool, error) {
+	env, err := p.ReadPackageEnvelope(loc)
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	if !env.HasLabel(StagedLabel, StagedLabel) {
+		return false, nil
+	}
+	targetDir, err := DefaultUnpackedPath(loc)
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	return IsUnpacked(targetDir)
+}
+
 // GetConfigPackage creates the config package without saving it into package service
 func GetConfigPackage(p PackageService, loc loc.Locator, confLoc loc.Locator, args []string) (io.Reader, error) {
 	_, reader, err := p.ReadPackage(loc)
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webpack
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+
+	"github.com/gravitational/trace"
+	"github.com/gravitational/ttlmap"
+	log "github.com/sirupsen/logrus"
+)
+
+// chunkCache caches the chunk and block manifests of packages so
+// the package contents are not read and hashed on every request,
+// e.g. when many nodes download the same package.
+//
+// Manifests are keyed by the package checksum so a package
+// updated in place is never served a stale manifest
+type chunkCache struct {
+	sync.Mutex
+	manifests *ttlmap.TTLMap
+}
+
+func newChunkCache() (*chunkCache, error) {
+	manifests, err := ttlmap.New(defaults.ChunkManifestCacheSize)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &chunkCache{manifests: manifests}, nil
+}
+
+// getManifest returns the manifest of the specified package with the contents
+// split into blocks of blockSize bytes, or into download chunks if blockSize is 0.
+// The manifest is computed and cached if it is not in the cache
+func (r *chunkCache) getManifest(service pack.PackageService, locator loc.Locator, blockSize int64) (*pack.ChunkManifest, error) {
+	env, reader, err := service.ReadPackage(locator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer reader.Close()
+	key := fmt.Sprintf("%v:%v:%v", env.Locator, env.SHA512, blockSize)
+	if manifest := r.get(key); manifest != nil {
+		return manifest, nil
+	}
+	var manifest *pack.ChunkManifest
+	if blockSize != 0 {
+		manifest, err = pack.NewBlockManifest(reader, blockSize)
+	} else {
+		manifest, err = pack.NewChunkManifest(reader, defaults.DownloadChunkSize)
+	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if env.SHA512 != "" {
+		r.set(key, manifest)
+	}
+	return manifest, nil
+}
+
+func (r *chunkCache) get(key string) *pack.ChunkManifest {
+	r.Lock()
+	defer r.Unlock()
+	value, ok := r.manifests.Get(key)
+	if !ok {
+		return nil
+	}
+	return value.(*pack.ChunkManifest)
+}
+
+func (r *chunkCache) set(key string, manifest *pack.ChunkManifest) {
+	r.Lock()
+	defer r.Unlock()
+	if err := r.manifests.Set(key, manifest, defaults.ChunkManifestCacheTTL); err != nil {
+		log.WithError(err).Warn("Failed to cache chunk manifest.")
+	}
+}
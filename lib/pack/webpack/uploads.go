@@ -0,0 +1,327 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webpack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/blob"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// uploadStore keeps the package uploads in progress in the backend shared
+// by the package service replicas, so the parts of an upload can be received
+// and the upload completed by any replica.
+//
+// The part contents are stored as BLOBs in the cluster object storage.
+// Every part BLOB starts with a header unique to the upload session and
+// the part offset so that the part BLOBs are never shared with packages or
+// other uploads and can be deleted along with the session.
+// A part is only recorded after its checksum has been verified, so
+// the recorded parts are the parts received intact
+type uploadStore struct {
+	// backend stores the upload sessions
+	backend storage.PackageUploads
+	// objects stores the contents of the received parts
+	objects blob.Objects
+	// ttl is the time after which an abandoned session is removed
+	ttl time.Duration
+}
+
+// create starts a new upload session for the specified user or returns
+// the existing session for the same package contents
+func (r *uploadStore) create(user string, spec pack.UploadSpec) (*pack.UploadSession, error) {
+	if err := r.removeExpired(); err != nil {
+		log.WithError(err).Warn("Failed to remove expired uploads.")
+	}
+	id := pack.UploadSessionID(user, spec)
+	session, err := r.get(user, id)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	// the session might have been started with different package attributes
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = r.backend.UpsertPackageUpload(storage.PackageUpload{
+		ID:      id,
+		User:    user,
+		Spec:    data,
+		Expires: time.Now().UTC().Add(r.ttl),
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if session == nil {
+		return &pack.UploadSession{ID: id, Spec: spec}, nil
+	}
+	session.Spec = spec
+	return session, nil
+}
+
+// get returns the upload session with the specified ID
+// started by the specified user
+func (r *uploadStore) get(user, id string) (*pack.UploadSession, error) {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return nil, trace.BadParameter("invalid upload ID %q", id)
+	}
+	upload, err := r.getUpload(id)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if upload.User != user {
+		return nil, trace.NotFound("upload %v not found", id)
+	}
+	var spec pack.UploadSpec
+	if err := json.Unmarshal(upload.Spec, &spec); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	parts, err := r.backend.GetPackageUploadParts(id)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	session := pack.UploadSession{ID: id, Spec: spec}
+	for _, part := range parts {
+		session.Parts = append(session.Parts, pack.Chunk{
+			Offset: part.Offset,
+			Size:   part.Size,
+			SHA256: part.SHA256,
+		})
+	}
+	return &session, nil
+}
+
+// writePart verifies the part read from data against its checksum
+// and stores it in the session
+func (r *uploadStore) writePart(session pack.UploadSession, chunk pack.Chunk, data io.Reader) error {
+	if err := session.Spec.CheckPart(chunk); err != nil {
+		return trace.Wrap(err)
+	}
+	header := partHeader(session.ID, chunk.Offset)
+	hash := sha256.New()
+	envelope, err := r.objects.WriteBLOB(io.MultiReader(
+		strings.NewReader(header),
+		io.TeeReader(io.LimitReader(data, chunk.Size+1), hash)))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := checkPart(chunk, envelope.SizeBytes-int64(len(header)), hash); err != nil {
+		// the BLOB is unique to the part contents which do not match
+		// the checksum so no other part refers to it
+		if err := r.objects.DeleteBLOB(envelope.SHA512); err != nil && !trace.IsNotFound(err) {
+			log.WithError(err).Warnf("Failed to remove BLOB %v.", envelope.SHA512)
+		}
+		return trace.Wrap(err)
+	}
+	err = r.backend.UpsertPackageUploadPart(session.ID, storage.PackageUploadPart{
+		Offset: chunk.Offset,
+		Size:   chunk.Size,
+		SHA256: chunk.SHA256,
+		BLOB:   envelope.SHA512,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.touch(session.ID))
+}
+
+// open verifies that all parts of the session have been received and returns
+// the reader for the assembled contents after verifying their checksum
+func (r *uploadStore) open(session pack.UploadSession) (io.ReadCloser, error) {
+	parts, err := r.backend.GetPackageUploadParts(session.ID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(parts) != session.Spec.Parts() {
+		return nil, trace.BadParameter("upload %v is incomplete: received %v of %v parts",
+			session.ID, len(parts), session.Spec.Parts())
+	}
+	reader := r.reader(session.ID, parts)
+	checksum, err := utils.SHA512HalfReader(reader)
+	reader.Close()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if checksum != session.Spec.SHA512 {
+		// the parts have been verified individually so the session
+		// can not be completed and a new upload has to start over
+		if err := r.remove(session.ID); err != nil {
+			log.WithError(err).Warnf("Failed to remove upload %v.", session.ID)
+		}
+		return nil, trace.BadParameter("checksum mismatch for package %v: got %v, expected %v",
+			session.Spec.Locator, checksum, session.Spec.SHA512)
+	}
+	return r.reader(session.ID, parts), nil
+}
+
+// reader returns the reader for the contents of the session parts in order
+func (r *uploadStore) reader(id string, parts []storage.PackageUploadPart) io.ReadCloser {
+	return &partsReader{objects: r.objects, id: id, parts: parts}
+}
+
+// remove removes the upload session along with the BLOBs of its parts
+func (r *uploadStore) remove(id string) error {
+	parts, err := r.backend.GetPackageUploadParts(id)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, part := range parts {
+		if err := r.objects.DeleteBLOB(part.BLOB); err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+	}
+	if err := r.backend.DeletePackageUpload(id); err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// removeExpired removes the sessions that have not been updated
+// for longer than the configured TTL
+func (r *uploadStore) removeExpired() error {
+	uploads, err := r.backend.GetPackageUploads()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	now := time.Now().UTC()
+	for _, upload := range uploads {
+		if now.Before(upload.Expires) {
+			continue
+		}
+		log.WithField("upload", upload.ID).Info("Removing expired upload.")
+		if err := r.remove(upload.ID); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// getUpload returns the upload session record with the specified ID
+// unless the session has expired
+func (r *uploadStore) getUpload(id string) (*storage.PackageUpload, error) {
+	upload, err := r.backend.GetPackageUpload(id)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !time.Now().UTC().Before(upload.Expires) {
+		return nil, trace.NotFound("upload %v not found", id)
+	}
+	return upload, nil
+}
+
+// touch extends the expiration time of the specified session
+func (r *uploadStore) touch(id string) error {
+	upload, err := r.getUpload(id)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	upload.Expires = time.Now().UTC().Add(r.ttl)
+	return trace.Wrap(r.backend.UpsertPackageUpload(*upload))
+}
+
+// checkPart verifies the size and the checksum of the received part
+func checkPart(chunk pack.Chunk, size int64, hash hash.Hash) error {
+	if size != chunk.Size {
+		return trace.BadParameter("size mismatch for part at offset %v: got %v bytes, expected %v",
+			chunk.Offset, size, chunk.Size)
+	}
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != chunk.SHA256 {
+		return trace.BadParameter("checksum mismatch for part at offset %v: got %v, expected %v",
+			chunk.Offset, checksum, chunk.SHA256)
+	}
+	return nil
+}
+
+// partHeader returns the header of the BLOB with the part of the specified
+// upload session at the given offset
+func partHeader(id string, offset int64) string {
+	return fmt.Sprintf("upload %v part %v\n", id, offset)
+}
+
+// partsReader reads the contents of the part BLOBs in order
+type partsReader struct {
+	objects blob.Objects
+	// id is the ID of the upload session
+	id    string
+	parts []storage.PackageUploadPart
+	rc    blob.ReadSeekCloser
+	r     io.Reader
+}
+
+// Read reads from the current part BLOB opening the next one
+// once the current BLOB is exhausted.
+// Implements io.Reader
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.rc == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			if err := r.next(); err != nil {
+				return 0, trace.Wrap(err)
+			}
+		}
+		n, err := r.r.Read(p)
+		if err == io.EOF {
+			r.rc.Close()
+			r.rc = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// next opens the BLOB of the next part skipping the part header
+func (r *partsReader) next() error {
+	part := r.parts[0]
+	rc, err := r.objects.OpenBLOB(part.BLOB)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	header := partHeader(r.id, part.Offset)
+	if _, err := rc.Seek(int64(len(header)), io.SeekStart); err != nil {
+		rc.Close()
+		return trace.Wrap(err)
+	}
+	r.rc, r.r, r.parts = rc, io.LimitReader(rc, part.Size), r.parts[1:]
+	return nil
+}
+
+// Close closes the current part BLOB.
+// Implements io.Closer
+func (r *partsReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	return r.rc.Close()
+}
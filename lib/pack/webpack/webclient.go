@@ -17,12 +17,15 @@ limitations under the License.
 package webpack
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/gravitational/gravity/lib/loc"
@@ -179,9 +182,56 @@ func (c *Client) createOrUpsertPackage(loc loc.Locator, data io.Reader, upsert b
 	return envelope, nil
 }
 
+// CreateUpload starts a new chunked upload session for the package contents
+// described by spec or returns the existing session for the same contents
+func (c *Client) CreateUpload(spec pack.UploadSpec) (*pack.UploadSession, error) {
+	out, err := c.PostJSON(c.Endpoint("repositories", spec.Locator.Repository, "uploads"), spec)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var session pack.UploadSession
+	if err := json.Unmarshal(out.Bytes(), &session); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &session, nil
+}
+
+// UploadPart uploads the part of the package contents described by chunk
+func (c *Client) UploadPart(session pack.UploadSession, chunk pack.Chunk, data io.Reader) error {
+	endpoint := c.Endpoint("repositories", session.Spec.Locator.Repository, "uploads", session.ID,
+		"parts", strconv.FormatInt(chunk.Offset, 10)) + "?" + url.Values{
+		"size":   []string{strconv.FormatInt(chunk.Size, 10)},
+		"sha256": []string{chunk.SHA256},
+	}.Encode()
+	_, err := telehttplib.ConvertResponse(c.RoundTrip(func() (*http.Response, error) {
+		req, err := http.NewRequest("PUT", endpoint, data)
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = chunk.Size
+		c.SetAuthHeader(req.Header)
+		return c.HTTPClient().Do(req)
+	}))
+	return trace.Wrap(err)
+}
+
+// CompleteUpload assembles the uploaded parts into the package
+func (c *Client) CompleteUpload(session pack.UploadSession) (*pack.PackageEnvelope, error) {
+	out, err := c.PostJSON(c.Endpoint("repositories", session.Spec.Locator.Repository,
+		"uploads", session.ID, "complete"), nil)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var envelope pack.PackageEnvelope
+	if err := json.Unmarshal(out.Bytes(), &envelope); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &envelope, nil
+}
+
 // UpdatePackageLabels updates package's labels
 func (c *Client) UpdatePackageLabels(loc loc.Locator, addLabels map[string]string, removeLabels []string) error {
-	_, err := c.PostJSON(context.TODO(), c.Endpoint("repositories", loc.Repository, "packages", loc.Name, loc.Version),
+	_, err := c.PostJSON(c.Endpoint("repositories", loc.Repository, "packages", loc.Name, loc.Version),
 		labels{
 			AddLabels:    addLabels,
 			RemoveLabels: removeLabels,
@@ -225,6 +275,81 @@ func (c *Client) ReadPackage(loc loc.Locator) (*pack.PackageEnvelope, io.ReadClo
 	return envelope, re.Body(), nil
 }
 
+// GetPackageChunks returns the chunk manifest of the specified package
+func (c *Client) GetPackageChunks(loc loc.Locator) (*pack.ChunkManifest, error) {
+	out, err := c.Get(
+		c.Endpoint("repositories", loc.Repository,
+			"packages", loc.Name, loc.Version, "chunks"), url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var manifest pack.ChunkManifest
+	if err := json.Unmarshal(out.Bytes(), &manifest); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &manifest, nil
+}
+
+// GetPackageBlocks returns the block manifest of the specified package
+// with the contents split into blocks of blockSize bytes
+func (c *Client) GetPackageBlocks(loc loc.Locator, blockSize int64) (*pack.ChunkManifest, error) {
+	out, err := c.Get(
+		c.Endpoint("repositories", loc.Repository,
+			"packages", loc.Name, loc.Version, "chunks"), url.Values{
+			"block_size": []string{strconv.FormatInt(blockSize, 10)},
+		})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var manifest pack.ChunkManifest
+	if err := json.Unmarshal(out.Bytes(), &manifest); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &manifest, nil
+}
+
+// ReadPackageRangeCompressed returns size bytes of the package contents starting at offset.
+// The contents are transferred compressed with gzip
+func (c *Client) ReadPackageRangeCompressed(loc loc.Locator, offset, size int64) (io.ReadCloser, error) {
+	endpoint := c.Endpoint("repositories", loc.Repository, "packages", loc.Name, loc.Version, "file") +
+		"?" + url.Values{"compress": []string{"true"}}.Encode()
+	rc, err := c.readPackageRange(loc, endpoint, offset, size)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	reader, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return reader, nil
+}
+
+// ReadPackageRange returns size bytes of the package contents starting at offset
+func (c *Client) ReadPackageRange(loc loc.Locator, offset, size int64) (io.ReadCloser, error) {
+	endpoint := c.Endpoint("repositories", loc.Repository, "packages", loc.Name, loc.Version, "file")
+	return c.readPackageRange(loc, endpoint, offset, size)
+}
+
+func (c *Client) readPackageRange(loc loc.Locator, endpoint string, offset, size int64) (io.ReadCloser, error) {
+	resp, err := telehttplib.ConvertResponse(c.RoundTrip(func() (*http.Response, error) {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.SetAuthHeader(req.Header)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, offset+size-1))
+		return c.HTTPClient().Do(req)
+	}))
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to read package %s", loc.String())
+	}
+	if resp.Code() != http.StatusPartialContent {
+		return nil, trace.BadParameter("expected partial content for package %s, got status %v",
+			loc.String(), resp.Code())
+	}
+	return ioutil.NopCloser(resp.Reader()), nil
+}
+
 func (c *Client) ReadPackageEnvelope(loc loc.Locator) (*pack.PackageEnvelope, error) {
 	out, err := c.Get(
 		c.Endpoint("repositories", loc.Repository,
@@ -254,6 +379,11 @@ func (c *Client) Get(u string, params url.Values) (*roundtrip.Response, error) {
 	return telehttplib.ConvertResponse(c.Client.Get(context.TODO(), u, params))
 }
 
+// PostJSON posts JSON "application/json" encoded request body
+func (c *Client) PostJSON(endpoint string, data interface{}) (*roundtrip.Response, error) {
+	return telehttplib.ConvertResponse(c.Client.PostJSON(context.TODO(), endpoint, data))
+}
+
 // Delete issues http Delete Request to the server
 func (c *Client) Delete(u string) (*roundtrip.Response, error) {
 	return telehttplib.ConvertResponse(c.Client.Delete(context.TODO(), u))
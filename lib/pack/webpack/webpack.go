@@ -17,6 +17,7 @@ limitations under the License.
 package webpack
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -25,6 +26,8 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/gravitational/gravity/lib/blob"
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/storage"
@@ -47,6 +50,10 @@ type Config struct {
 	Users users.Identity
 	// Authenticator is used to authenticate requests.
 	Authenticator users.Authenticator
+	// Uploads stores the sessions of chunked uploads in progress.
+	Uploads storage.PackageUploads
+	// Objects stores the parts of chunked uploads in progress.
+	Objects blob.Objects
 }
 
 // CheckAndSetDefaults validates the request and sets some defaults.
@@ -60,6 +67,12 @@ func (c *Config) CheckAndSetDefaults() error {
 	if c.Authenticator == nil {
 		c.Authenticator = users.NewAuthenticatorFromIdentity(c.Users)
 	}
+	if c.Uploads == nil {
+		return trace.BadParameter("missing parameter Uploads")
+	}
+	if c.Objects == nil {
+		return trace.BadParameter("missing parameter Objects")
+	}
 	return nil
 }
 
@@ -67,6 +80,8 @@ type Server struct {
 	httprouter.Router
 	cfg        Config
 	middleware *auth.AuthMiddleware
+	uploads    *uploadStore
+	chunks     *chunkCache
 }
 
 func NewHandler(cfg Config) (*Server, error) {
@@ -74,8 +89,18 @@ func NewHandler(cfg Config) (*Server, error) {
 		return nil, trace.Wrap(err)
 	}
 
+	chunks, err := newChunkCache()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
 	h := &Server{
 		cfg: cfg,
+		uploads: &uploadStore{
+			backend: cfg.Uploads,
+			objects: cfg.Objects,
+			ttl:     defaults.PackageUploadTTL,
+		},
+		chunks: chunks,
 	}
 
 	// Wrap the router in the authentication middleware which will detect
@@ -95,8 +120,12 @@ func NewHandler(cfg Config) (*Server, error) {
 	h.GET("/pack/v1/repositories/:repository/packages/:package_name/:package_version/file", h.needsAuth(h.getPackageFile))
 	h.HEAD("/pack/v1/repositories/:repository/packages/:package_name/:package_version/file", h.needsAuth(h.getPackageFile))
 	h.GET("/pack/v1/repositories/:repository/packages/:package_name/:package_version/envelope", h.needsAuth(h.getPackageEnvelope))
+	h.GET("/pack/v1/repositories/:repository/packages/:package_name/:package_version/chunks", h.needsAuth(h.getPackageChunks))
 	h.POST("/pack/v1/repositories/:repository/packages/:package_name/:package_version", h.needsAuth(h.updatePackageLabels))
 	h.DELETE("/pack/v1/repositories/:repository/packages/:package_name/:package_version", h.needsAuth(h.deletePackage))
+	h.POST("/pack/v1/repositories/:repository/uploads", h.needsUploadAuth(h.createUpload))
+	h.PUT("/pack/v1/repositories/:repository/uploads/:upload_id/parts/:offset", h.needsUploadAuth(h.uploadPart))
+	h.POST("/pack/v1/repositories/:repository/uploads/:upload_id/complete", h.needsUploadAuth(h.completeUpload))
 
 	return h, nil
 }
@@ -193,6 +222,34 @@ func (s *Server) getPackageEnvelope(w http.ResponseWriter, r *http.Request, p ht
 	return nil
 }
 
+// getPackageChunks returns the chunk manifest of the package contents
+// used by clients to resume interrupted downloads and verify the downloaded data.
+// If the block_size query parameter is given, returns the block manifest
+// with the contents split into blocks of the specified size for delta transfers
+func (s *Server) getPackageChunks(w http.ResponseWriter, r *http.Request, p httprouter.Params, service pack.PackageService) error {
+	loc, err := loc.NewLocator(p.ByName("repository"), p.ByName("package_name"), p.ByName("package_version"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var blockSize int64
+	if value := r.URL.Query().Get("block_size"); value != "" {
+		blockSize, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return trace.BadParameter("invalid block size %q: %v", value, err)
+		}
+		if blockSize < defaults.MinDeltaBlockSize || blockSize > defaults.DownloadChunkSize {
+			return trace.BadParameter("block size should be between %v and %v bytes, got %v",
+				defaults.MinDeltaBlockSize, defaults.DownloadChunkSize, blockSize)
+		}
+	}
+	manifest, err := s.chunks.getManifest(service, *loc, blockSize)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, manifest)
+	return nil
+}
+
 func (s *Server) getPackageFile(w http.ResponseWriter, r *http.Request, p httprouter.Params, service pack.PackageService) error {
 	loc, err := loc.NewLocator(p.ByName("repository"), p.ByName("package_name"), p.ByName("package_version"))
 	if err != nil {
@@ -210,10 +267,65 @@ func (s *Server) getPackageFile(w http.ResponseWriter, r *http.Request, p httpro
 		return trace.BadParameter("expected read seeker object")
 	}
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename=%v`, loc.String()))
+	if r.URL.Query().Get("compress") == "true" {
+		gzipWriter := newGzipResponseWriter(w)
+		defer gzipWriter.Close()
+		w = gzipWriter
+	}
 	http.ServeContent(w, r, loc.String(), time.Now(), readSeeker)
 	return nil
 }
 
+// newGzipResponseWriter returns a response writer that compresses
+// the response body with gzip
+func newGzipResponseWriter(w http.ResponseWriter) *gzipResponseWriter {
+	return &gzipResponseWriter{
+		ResponseWriter: w,
+		writer:         gzip.NewWriter(w),
+	}
+}
+
+// WriteHeader replaces the content length of the uncompressed body
+// with the content encoding header before sending the response headers.
+// Error responses are sent uncompressed
+func (r *gzipResponseWriter) WriteHeader(code int) {
+	r.headerWritten = true
+	r.compressed = code >= http.StatusOK && code < http.StatusMultipleChoices
+	if r.compressed {
+		r.Header().Del("Content-Length")
+		r.Header().Set("Content-Encoding", "gzip")
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write compresses data and writes it to the response body
+func (r *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !r.headerWritten {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.compressed {
+		return r.ResponseWriter.Write(data)
+	}
+	return r.writer.Write(data)
+}
+
+// Close flushes the compressed data to the response body
+func (r *gzipResponseWriter) Close() error {
+	if !r.compressed {
+		return nil
+	}
+	return r.writer.Close()
+}
+
+// gzipResponseWriter is a response writer that compresses the body
+// of successful responses
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer        *gzip.Writer
+	headerWritten bool
+	compressed    bool
+}
+
 func (s *Server) createPackage(w http.ResponseWriter, r *http.Request, p httprouter.Params, service pack.PackageService) error {
 	var files form.Files
 	var labelsMap string
@@ -291,6 +403,111 @@ func (s *Server) createPackage(w http.ResponseWriter, r *http.Request, p httprou
 	return nil
 }
 
+// createUpload starts a new chunked upload session or returns the existing
+// session for the same package contents with the list of parts already received
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request, p httprouter.Params, service *pack.ACLService, user string) error {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var spec pack.UploadSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return trace.BadParameter(err.Error())
+	}
+	if err := spec.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	if spec.Locator.Repository != p.ByName("repository") {
+		return trace.BadParameter("package %v does not belong to repository %v",
+			spec.Locator, p.ByName("repository"))
+	}
+	if err := service.CheckUploadAccess(spec.Locator.Repository, spec.Upsert); err != nil {
+		return trace.Wrap(err)
+	}
+	if !spec.Upsert {
+		_, err = service.ReadPackageEnvelope(spec.Locator)
+		if err == nil {
+			return trace.AlreadyExists("package %v already exists", spec.Locator)
+		}
+		if !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+	}
+	session, err := s.uploads.create(user, spec)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, session)
+	return nil
+}
+
+// uploadPart receives a single part of the chunked upload and verifies
+// it against the checksum specified with the sha256 query parameter
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, p httprouter.Params, service *pack.ACLService, user string) error {
+	session, err := s.getUpload(p, user)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	offset, err := strconv.ParseInt(p.ByName("offset"), 10, 64)
+	if err != nil {
+		return trace.BadParameter("invalid offset %q: %v", p.ByName("offset"), err)
+	}
+	size, err := strconv.ParseInt(r.URL.Query().Get("size"), 10, 64)
+	if err != nil {
+		return trace.BadParameter("invalid size %q: %v", r.URL.Query().Get("size"), err)
+	}
+	err = s.uploads.writePart(*session, pack.Chunk{
+		Offset: offset,
+		Size:   size,
+		SHA256: r.URL.Query().Get("sha256"),
+	}, r.Body)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, map[string]string{"status": "ok", "message": "part uploaded"})
+	return nil
+}
+
+// completeUpload assembles the received parts into the package after
+// verifying the checksum of the assembled contents
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, p httprouter.Params, service *pack.ACLService, user string) error {
+	session, err := s.getUpload(p, user)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	reader, err := s.uploads.open(*session)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer reader.Close()
+	var envelope *pack.PackageEnvelope
+	if session.Spec.Upsert {
+		envelope, err = service.UpsertPackage(session.Spec.Locator, reader, session.Spec.Options()...)
+	} else {
+		envelope, err = service.CreatePackage(session.Spec.Locator, reader, session.Spec.Options()...)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := s.uploads.remove(session.ID); err != nil {
+		log.WithError(err).Warnf("Failed to remove upload %v.", session.ID)
+	}
+	roundtrip.ReplyJSON(w, http.StatusOK, envelope)
+	return nil
+}
+
+// getUpload returns the upload session specified with the request parameters
+func (s *Server) getUpload(p httprouter.Params, user string) (*pack.UploadSession, error) {
+	session, err := s.uploads.get(user, p.ByName("upload_id"))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if session.Spec.Locator.Repository != p.ByName("repository") {
+		return nil, trace.NotFound("upload %v not found", session.ID)
+	}
+	return session, nil
+}
+
 func (s *Server) updatePackageLabels(w http.ResponseWriter, r *http.Request, p httprouter.Params, service pack.PackageService) error {
 	loc, err := loc.NewLocator(p.ByName("repository"), p.ByName("package_name"), p.ByName("package_version"))
 	if err != nil {
@@ -314,6 +531,15 @@ func (s *Server) updatePackageLabels(w http.ResponseWriter, r *http.Request, p h
 }
 
 func (s *Server) needsAuth(fn authHandle) httprouter.Handle {
+	return s.needsUploadAuth(func(w http.ResponseWriter, r *http.Request, p httprouter.Params, service *pack.ACLService, user string) error {
+		return fn(w, r, p, service)
+	})
+}
+
+// needsUploadAuth authenticates the request and passes the permission aware
+// package service along with the name of the authenticated user to the handler.
+// Chunked uploads use the user name to scope upload sessions
+func (s *Server) needsUploadAuth(fn uploadHandle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		logger := log.WithFields(fields.FromRequest(r))
 
@@ -327,8 +553,8 @@ func (s *Server) needsAuth(fn authHandle) httprouter.Handle {
 		// create a ACL aware wrapper packages service
 		// and pass it to the handlers, so every action will be automatically
 		// checked against current user
-		service := pack.PackagesWithACL(s.cfg.Packages, s.cfg.Users, authResult.User, authResult.Checker)
-		if err := fn(w, r, p, service); err != nil {
+		service := pack.NewACLService(s.cfg.Packages, s.cfg.Users, authResult.User, authResult.Checker)
+		if err := fn(w, r, p, service, authResult.User.GetName()); err != nil {
 			if trace.IsAccessDenied(err) {
 				logger.WithError(err).Warn("Access denied.")
 			} else if !trace.IsNotFound(err) && !trace.IsAlreadyExists(err) {
@@ -339,6 +565,9 @@ func (s *Server) needsAuth(fn authHandle) httprouter.Handle {
 	}
 }
 
+type uploadHandle func(
+	http.ResponseWriter, *http.Request, httprouter.Params, *pack.ACLService, string) error
+
 type authHandle func(
 	http.ResponseWriter, *http.Request, httprouter.Params, pack.PackageService) error
 
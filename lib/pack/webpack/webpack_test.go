@@ -18,24 +18,30 @@ package webpack
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gravitational/gravity/lib/blob/fs"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/pack/localpack"
 	"github.com/gravitational/gravity/lib/pack/suite"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/keyval"
 	"github.com/gravitational/gravity/lib/users"
 	"github.com/gravitational/gravity/lib/users/usersservice"
+	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/gravitational/roundtrip"
 	teleservices "github.com/gravitational/teleport/lib/services"
@@ -53,6 +59,7 @@ type WebpackSuite struct {
 	suite     suite.PackageSuite
 	webServer *httptest.Server
 	users     users.Identity
+	packages  *localpack.PackageServer
 	clock     *timetools.FreezedTime
 
 	agentUser storage.User
@@ -105,9 +112,12 @@ func (s *WebpackSuite) SetUpTest(c *C) {
 		Objects:     objects,
 	})
 	c.Assert(err, IsNil)
+	s.packages = service
 	webHandler, err := NewHandler(Config{
-		Users:    s.users,
-		Packages: service,
+		Users:      s.users,
+		Packages:   service,
+		Uploads:    s.backend,
+		Objects:    objects,
 	})
 	c.Assert(err, IsNil)
 	mux := http.NewServeMux()
@@ -291,3 +301,219 @@ func (s *WebpackSuite) TestUpsertPackages(c *C) {
 func (s *WebpackSuite) TestDeleteRepository(c *C) {
 	s.suite.DeleteRepository(c)
 }
+
+func (s *WebpackSuite) TestReadsPackageInChunks(c *C) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	locator := loc.MustParseLocator("example.com/package:0.0.1")
+	c.Assert(s.packages.UpsertRepository(locator.Repository, time.Time{}), IsNil)
+	_, err := s.packages.CreatePackage(locator, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	client := s.suite.S.(*Client)
+	manifest, err := client.GetPackageChunks(locator)
+	c.Assert(err, IsNil)
+	expected, err := pack.NewChunkManifest(bytes.NewReader(data), defaults.DownloadChunkSize)
+	c.Assert(err, IsNil)
+	c.Assert(manifest, DeepEquals, expected)
+
+	rc, err := client.ReadPackageRange(locator, 15, 10)
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	chunk, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(string(chunk), Equals, "5678901234")
+}
+
+func (s *WebpackSuite) TestRefreshesCachedChunksOnPackageUpdate(c *C) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	locator := loc.MustParseLocator("example.com/package:0.0.4")
+	c.Assert(s.packages.UpsertRepository(locator.Repository, time.Time{}), IsNil)
+	_, err := s.packages.CreatePackage(locator, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	client := s.suite.S.(*Client)
+	_, err = client.GetPackageChunks(locator)
+	c.Assert(err, IsNil)
+
+	update := bytes.Repeat([]byte("abcdefghij"), 20)
+	_, err = s.packages.UpsertPackage(locator, bytes.NewReader(update))
+	c.Assert(err, IsNil)
+	manifest, err := client.GetPackageChunks(locator)
+	c.Assert(err, IsNil)
+	expected, err := pack.NewChunkManifest(bytes.NewReader(update), defaults.DownloadChunkSize)
+	c.Assert(err, IsNil)
+	c.Assert(manifest, DeepEquals, expected)
+}
+
+func (s *WebpackSuite) TestUploadsPackageInParts(c *C) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	locator := loc.MustParseLocator("example.com/package:0.0.3")
+	c.Assert(s.packages.UpsertRepository(locator.Repository, time.Time{}), IsNil)
+	checksum, err := utils.SHA512HalfReader(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	// interrupted upload has delivered the second part
+	client := s.suite.S.(*Client)
+	session, err := client.CreateUpload(pack.UploadSpec{
+		Locator:   locator,
+		Size:      int64(len(data)),
+		SHA512:    checksum,
+		ChunkSize: 30,
+	})
+	c.Assert(err, IsNil)
+	manifest, err := pack.NewChunkManifest(bytes.NewReader(data), 30)
+	c.Assert(err, IsNil)
+	c.Assert(client.UploadPart(*session, manifest.Chunks[1], bytes.NewReader(data[30:60])), IsNil)
+
+	writer := &recordingWriter{ChunkedWriter: client}
+	envelope, err := pack.UploadPackage(context.TODO(), pack.UploadRequest{
+		Writer:    writer,
+		Package:   locator,
+		Reader:    bytes.NewReader(data),
+		Size:      int64(len(data)),
+		Options:   []pack.PackageOption{pack.WithLabels(map[string]string{"key": "value"})},
+		ChunkSize: 30,
+		Parallel:  2,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(envelope.SHA512, Equals, checksum)
+	c.Assert(envelope.RuntimeLabels, DeepEquals, map[string]string{"key": "value"})
+	sort.Slice(writer.offsets, func(i, j int) bool { return writer.offsets[i] < writer.offsets[j] })
+	c.Assert(writer.offsets, DeepEquals, []int64{0, 60, 90})
+
+	_, rc, err := s.packages.ReadPackage(locator)
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	uploaded, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(string(uploaded), Equals, string(data))
+
+	_, err = s.backend.GetPackageUpload(session.ID)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+	// only the package BLOB is left
+	blobs, err := s.suite.O.GetBLOBs()
+	c.Assert(err, IsNil)
+	c.Assert(blobs, DeepEquals, []string{envelope.SHA512})
+}
+
+func (s *WebpackSuite) TestCompletesUploadOnAnotherReplica(c *C) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	locator := loc.MustParseLocator("example.com/package:0.0.5")
+	c.Assert(s.packages.UpsertRepository(locator.Repository, time.Time{}), IsNil)
+	checksum, err := utils.SHA512HalfReader(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	// the replicas share the backend and the object storage
+	webHandler, err := NewHandler(Config{
+		Users:    s.users,
+		Packages: s.packages,
+		Uploads:  s.backend,
+		Objects:  s.suite.O,
+	})
+	c.Assert(err, IsNil)
+	mux := http.NewServeMux()
+	mux.Handle("/pack/", webHandler)
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	replica, err := NewAuthenticatedClient(
+		server.URL, s.adminUser.GetName(), "admin-password",
+		roundtrip.HTTPClient(server.Client()))
+	c.Assert(err, IsNil)
+
+	client := s.suite.S.(*Client)
+	spec := pack.UploadSpec{
+		Locator:   locator,
+		Size:      int64(len(data)),
+		SHA512:    checksum,
+		ChunkSize: 50,
+	}
+	session, err := client.CreateUpload(spec)
+	c.Assert(err, IsNil)
+	manifest, err := pack.NewChunkManifest(bytes.NewReader(data), 50)
+	c.Assert(err, IsNil)
+	c.Assert(client.UploadPart(*session, manifest.Chunks[0], bytes.NewReader(data[:50])), IsNil)
+
+	resumed, err := replica.CreateUpload(spec)
+	c.Assert(err, IsNil)
+	c.Assert(resumed.Parts, DeepEquals, manifest.Chunks[:1])
+	c.Assert(replica.UploadPart(*resumed, manifest.Chunks[1], bytes.NewReader(data[50:])), IsNil)
+	envelope, err := replica.CompleteUpload(*resumed)
+	c.Assert(err, IsNil)
+	c.Assert(envelope.SHA512, Equals, checksum)
+
+	_, rc, err := s.packages.ReadPackage(locator)
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	uploaded, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(string(uploaded), Equals, string(data))
+}
+
+func (s *WebpackSuite) TestRejectsCorruptedUpload(c *C) {
+	data := []byte("0123456789")
+	locator := loc.MustParseLocator("example.com/package:0.0.4")
+	c.Assert(s.packages.UpsertRepository(locator.Repository, time.Time{}), IsNil)
+
+	client := s.suite.S.(*Client)
+	session, err := client.CreateUpload(pack.UploadSpec{
+		Locator:   locator,
+		Size:      int64(len(data)),
+		SHA512:    "invalid",
+		ChunkSize: 5,
+	})
+	c.Assert(err, IsNil)
+	manifest, err := pack.NewChunkManifest(bytes.NewReader(data), 5)
+	c.Assert(err, IsNil)
+
+	err = client.UploadPart(*session, manifest.Chunks[0], bytes.NewReader([]byte("01235")))
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", err))
+	c.Assert(client.UploadPart(*session, manifest.Chunks[0], bytes.NewReader(data[:5])), IsNil)
+
+	_, err = client.CompleteUpload(*session)
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("incomplete upload: %v", err))
+
+	c.Assert(client.UploadPart(*session, manifest.Chunks[1], bytes.NewReader(data[5:])), IsNil)
+	_, err = client.CompleteUpload(*session)
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("checksum mismatch: %v", err))
+	_, err = s.packages.ReadPackageEnvelope(locator)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+}
+
+func (s *WebpackSuite) TestReadsPackageBlocksCompressed(c *C) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	locator := loc.MustParseLocator("example.com/package:0.0.2")
+	c.Assert(s.packages.UpsertRepository(locator.Repository, time.Time{}), IsNil)
+	_, err := s.packages.CreatePackage(locator, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	client := s.suite.S.(*Client)
+	manifest, err := client.GetPackageBlocks(locator, defaults.MinDeltaBlockSize)
+	c.Assert(err, IsNil)
+	expected, err := pack.NewBlockManifest(bytes.NewReader(data), defaults.MinDeltaBlockSize)
+	c.Assert(err, IsNil)
+	c.Assert(manifest, DeepEquals, expected)
+
+	_, err = client.GetPackageBlocks(locator, 1)
+	c.Assert(trace.IsBadParameter(err), Equals, true)
+
+	rc, err := client.ReadPackageRangeCompressed(locator, 15, 5000)
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	block, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(string(block), Equals, string(data[15:5015]))
+}
+
+// recordingWriter records the offsets of the uploaded parts
+type recordingWriter struct {
+	pack.ChunkedWriter
+	mu      sync.Mutex
+	offsets []int64
+}
+
+func (r *recordingWriter) UploadPart(session pack.UploadSession, chunk pack.Chunk, data io.Reader) error {
+	r.mu.Lock()
+	r.offsets = append(r.offsets, chunk.Offset)
+	r.mu.Unlock()
+	return r.ChunkedWriter.UploadPart(session, chunk, data)
+}
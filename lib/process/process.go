@@ -33,37 +33,47 @@ import (
 	"time"
 
 	"github.com/gravitational/gravity/lib/app"
+	appdocker "github.com/gravitational/gravity/lib/app/docker"
 	apphandler "github.com/gravitational/gravity/lib/app/handler"
 	appservice "github.com/gravitational/gravity/lib/app/service"
 	"github.com/gravitational/gravity/lib/autoscale/aws"
+	"github.com/gravitational/gravity/lib/autoscale/gce"
 	"github.com/gravitational/gravity/lib/blob"
 	blobclient "github.com/gravitational/gravity/lib/blob/client"
 	blobcluster "github.com/gravitational/gravity/lib/blob/cluster"
 	blobfs "github.com/gravitational/gravity/lib/blob/fs"
 	blobhandler "github.com/gravitational/gravity/lib/blob/handler"
+	blobs3 "github.com/gravitational/gravity/lib/blob/s3"
 	"github.com/gravitational/gravity/lib/clients"
 	cloudaws "github.com/gravitational/gravity/lib/cloudprovider/aws"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/docker"
+	"github.com/gravitational/gravity/lib/healthreport"
 	"github.com/gravitational/gravity/lib/helm"
 	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/modules"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/monitoring"
 	"github.com/gravitational/gravity/lib/ops/opshandler"
 	"github.com/gravitational/gravity/lib/ops/opsroute"
 	"github.com/gravitational/gravity/lib/ops/opsservice"
+	"github.com/gravitational/gravity/lib/ops/resources"
+	"github.com/gravitational/gravity/lib/ops/resources/gravity"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/pack/layerpack"
 	"github.com/gravitational/gravity/lib/pack/localpack"
 	"github.com/gravitational/gravity/lib/pack/webpack"
 	"github.com/gravitational/gravity/lib/processconfig"
+	"github.com/gravitational/gravity/lib/proxyproto"
+	"github.com/gravitational/gravity/lib/retention"
 	"github.com/gravitational/gravity/lib/rpc"
 	pb "github.com/gravitational/gravity/lib/rpc/proto"
 	rpcserver "github.com/gravitational/gravity/lib/rpc/server"
 	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/status"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/keyval"
 	"github.com/gravitational/gravity/lib/users"
@@ -222,30 +232,7 @@ func New(ctx context.Context, cfg processconfig.Config, tcfg telecfg.FileConfig)
 
 	processID := cfg.ProcessID()
 
-	blobUser := fmt.Sprintf("%v@%v", processID, constants.BlobUserSuffix)
-	blobKey, err := blob.UpsertUser(identity, blobUser)
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	peerPool, err := blobclient.NewPool(blobUser, blobKey.Token)
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	peerAddr, err := cfg.Pack.PeerAddr()
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	clusterObjects, err := blobcluster.New(blobcluster.Config{
-		Local:         objects,
-		Backend:       backend,
-		GetPeer:       peerPool.GetPeer,
-		ID:            processID,
-		AdvertiseAddr: fmt.Sprintf("https://%v", peerAddr.Addr),
-		// TODO: set WriteFactor to the number of controller instances
-	})
+	clusterObjects, err := newClusterObjects(cfg, backend, identity, objects)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -502,16 +489,19 @@ func (p *Process) leaderStatus() (string, bool) {
 }
 
 func (p *Process) startAutoscale(ctx context.Context) error {
-	_, err := cloudaws.NewLocalInstance()
+	site, err := p.operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if site.Provider == schema.ProviderGCE {
+		return trace.Wrap(p.startGCEAutoscale(site.Domain))
+	}
+	_, err = cloudaws.NewLocalInstance()
 	if err != nil {
 		p.Info("Not on AWS, skip autoscaler start.")
 		return nil
 	}
 	p.Info("Starting AWS autoscaler.")
-	site, err := p.operator.GetLocalSite()
-	if err != nil {
-		return trace.Wrap(err)
-	}
 	client, err := tryGetPrivilegedKubeClient()
 	if err != nil {
 		return trace.Wrap(err)
@@ -544,6 +534,31 @@ func (p *Process) startAutoscale(ctx context.Context) error {
 	return nil
 }
 
+// startGCEAutoscale starts the service that publishes discovery information
+// for the nodes joining from GCE managed instance groups
+func (p *Process) startGCEAutoscale(clusterName string) error {
+	p.Info("Starting GCE autoscaler.")
+	client, err := tryGetPrivilegedKubeClient()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	autoscaler, err := gce.New(gce.Config{
+		ClusterName: clusterName,
+		Client:      client,
+	})
+	if err != nil {
+		p.Warningf("Failed to start GCE autoscaler: %v. Cluster will continue without autoscaling support. Fix the problem and restart the process.", trace.DebugReport(err))
+		return nil
+	}
+	// publish discovery information about this cluster
+	p.RegisterClusterService(func(ctx context.Context) {
+		localCtx := context.WithValue(ctx, constants.UserContext,
+			constants.ServiceAutoscaler)
+		autoscaler.PublishDiscovery(localCtx, p.operator)
+	})
+	return nil
+}
+
 // runApplicationsSynchronizer runs a service that periodically exports
 // Docker images of the cluster's application images to the local Docker
 // registry.
@@ -616,6 +631,217 @@ func (p *Process) runRegistrySynchronizer(ctx context.Context) {
 	}
 }
 
+// runRegistryReplicator runs a service that periodically replicates the images
+// from the registry of this master node to the registries of other master nodes
+// if the cluster registry is configured for replication.
+//
+// Every gravity-site replicates its own registry since images pushed
+// to the cluster can end up in the registry of any master node
+func (p *Process) runRegistryReplicator(ctx context.Context) {
+	p.Info("Starting registry replicator.")
+	interval := defaults.RegistryConfigCheckInterval
+	for {
+		select {
+		case <-time.After(interval):
+			interval = defaults.RegistryConfigCheckInterval
+			config, err := p.getRegistryConfig()
+			if err != nil {
+				p.Errorf("Failed to query registry configuration: %v.",
+					trace.DebugReport(err))
+				continue
+			}
+			if config.GetHighAvailability() != storage.RegistryHighAvailabilityReplicated {
+				continue
+			}
+			interval = config.GetSyncInterval()
+			if err := p.replicateRegistry(ctx); err != nil {
+				p.Errorf("Failed to replicate registry: %v.",
+					trace.DebugReport(err))
+			}
+		case <-ctx.Done():
+			p.Info("Stopping registry replicator.")
+			return
+		}
+	}
+}
+
+// replicateRegistry pushes the images from the registry of this master node
+// to the registries of other master nodes
+func (p *Process) replicateRegistry(ctx context.Context) error {
+	cluster, err := p.operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	localIP := os.Getenv(constants.EnvPodIP)
+	var errors []error
+	for _, ip := range cluster.ClusterState.Servers.MasterIPs() {
+		if ip == localIP {
+			continue
+		}
+		// use the cert name of default registry, but connect via IP without relying on DNS
+		imageService, err := appdocker.NewImageService(appdocker.RegistryConnectionRequest{
+			RegistryAddress: fmt.Sprintf("%v:%v", ip, constants.DockerRegistryPort),
+			CertName:        constants.DockerRegistry,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		start := time.Now()
+		tags, err := imageService.Sync(ctx, defaults.ClusterRegistryDir, utils.DiscardPrinter)
+		if err != nil {
+			errors = append(errors, trace.Wrap(err, "failed to replicate registry to %v", ip))
+			continue
+		}
+		p.Debugf("Replicated %v images to registry on %v in %v.", len(tags), ip, time.Since(start))
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// runRegistryMirror runs a service that periodically mirrors the upstream
+// images configured with the cluster registry configuration into the
+// registry of this master node.
+//
+// Failures to reach the upstream registries are logged and retried on the next
+// iteration so the images are mirrored once the cluster has connectivity
+func (p *Process) runRegistryMirror(ctx context.Context) {
+	p.Info("Starting registry mirror.")
+	interval := defaults.RegistryConfigCheckInterval
+	for {
+		select {
+		case <-time.After(interval):
+			interval = defaults.RegistryConfigCheckInterval
+			config, err := p.getRegistryConfig()
+			if err != nil {
+				p.Errorf("Failed to query registry configuration: %v.",
+					trace.DebugReport(err))
+				continue
+			}
+			if len(config.GetMirrorImages()) == 0 {
+				continue
+			}
+			interval = config.GetMirrorInterval()
+			if err := p.mirrorImages(ctx, config.GetMirrorImages()); err != nil {
+				p.Errorf("Failed to mirror images: %v.",
+					trace.DebugReport(err))
+			}
+		case <-ctx.Done():
+			p.Info("Stopping registry mirror.")
+			return
+		}
+	}
+}
+
+// mirrorImages copies the specified upstream images into the registry of this master node
+func (p *Process) mirrorImages(ctx context.Context, images []string) error {
+	imageService, err := appdocker.NewImageService(appdocker.RegistryConnectionRequest{
+		RegistryAddress: constants.LocalRegistryAddr,
+		CertName:        constants.DockerRegistry,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var errors []error
+	for _, image := range images {
+		tag, err := imageService.Mirror(ctx, image)
+		if err != nil {
+			errors = append(errors, trace.Wrap(err, "failed to mirror %v", image))
+			continue
+		}
+		p.Debugf("Mirrored %v to %v.", image, tag)
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// getRegistryConfig returns the cluster registry configuration or
+// the default configuration if the registry has not been configured
+func (p *Process) getRegistryConfig() (storage.RegistryConfig, error) {
+	cluster, err := p.operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	config, err := p.operator.GetRegistryConfig(cluster.Key())
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return storage.DefaultRegistryConfig(), nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	return config, nil
+}
+
+// runNodeTombstoneReconciler runs a service that periodically reconciles
+// the nodes that have left the cluster forcibly: the nodes that are still
+// a part of the cluster are removed with a forced shrink operation and the
+// tombstones of the nodes verified to be removed are deleted
+func (p *Process) runNodeTombstoneReconciler(ctx context.Context) {
+	p.Info("Starting node tombstone reconciler.")
+	ticker := time.NewTicker(defaults.NodeTombstoneReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.reconcileNodeTombstones(ctx); err != nil {
+				p.WithError(err).Warn("Failed to reconcile node tombstones.")
+			}
+		case <-ctx.Done():
+			p.Info("Stopping node tombstone reconciler.")
+			return
+		}
+	}
+}
+
+func (p *Process) reconcileNodeTombstones(ctx context.Context) error {
+	cluster, err := p.operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	tombstones, err := p.operator.GetNodeTombstones(cluster.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, tombstone := range tombstones {
+		logger := p.WithField("node", tombstone.Server.Hostname)
+		if _, err := cluster.ClusterState.FindServer(tombstone.Server.Hostname); err == nil {
+			if cluster.State != ops.SiteStateActive {
+				logger.Debugf("Cluster is %v, will retry removal.", cluster.State)
+				continue
+			}
+			key, err := p.operator.CreateSiteShrinkOperation(ctx, ops.CreateSiteShrinkOperationRequest{
+				AccountID:   cluster.AccountID,
+				SiteDomain:  cluster.Domain,
+				Servers:     []string{tombstone.Server.Hostname},
+				Force:       true,
+				NodeRemoved: true,
+			})
+			if err != nil {
+				logger.WithError(err).Warn("Failed to launch operation to remove node.")
+				continue
+			}
+			logger.Infof("Launched operation %v to remove node.", key.OperationID)
+			continue
+		}
+		status, err := p.operator.VerifyNodeRemoval(ctx, ops.VerifyNodeRemovalRequest{
+			AccountID:  cluster.AccountID,
+			SiteDomain: cluster.Domain,
+			Server:     tombstone.Server,
+		})
+		if err != nil {
+			logger.WithError(err).Warn("Failed to verify node removal.")
+			continue
+		}
+		if !status.Removed() {
+			logger.Warnf("Node has not been completely removed: %v.", status.Steps)
+			continue
+		}
+		if err := p.operator.DeleteNodeTombstone(ctx, cluster.Key(), tombstone.Server.Hostname); err != nil {
+			logger.WithError(err).Warn("Failed to delete node tombstone.")
+			continue
+		}
+		logger.Info("Verified node removal.")
+	}
+	return nil
+}
+
 // runSiteStatusChecker periodically invokes app status hook; should be run in a goroutine
 func (p *Process) runSiteStatusChecker(ctx context.Context) {
 	p.Info("Starting cluster status checker.")
@@ -920,6 +1146,35 @@ func (p *Process) ReportHealth(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ReportCheck is an HTTP check that reports the cluster health in the format
+// compatible with Nagios plugins: the response body is a single line with
+// the check status (OK, WARNING, CRITICAL or UNKNOWN) followed by the reasons.
+// The check returns 200 OK if the cluster is healthy or has warnings and
+// 503 Service Unavailable otherwise.
+// If format=json is given in the query, the check result is returned as JSON
+func (p *Process) ReportCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), defaults.AgentHealthCheckTimeout)
+	defer cancel()
+
+	var servers []storage.Server
+	cluster, err := p.backend.GetLocalSite(defaults.SystemAccountID)
+	if err == nil {
+		servers = cluster.ClusterState.Servers
+	}
+	agent, err := status.FromPlanetAgent(ctx, servers)
+	if err != nil {
+		p.WithError(err).Warn("Failed to query cluster status.")
+	}
+	result := status.NewCheck(agent)
+	if r.URL.Query().Get("format") == string(constants.EncodingJSON) {
+		roundtrip.ReplyJSON(w, result.Code.HTTPStatus(), result)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(result.Code.HTTPStatus())
+	fmt.Fprintln(w, result.String())
+}
+
 // initCertificateAuthority makes sure this OpsCenter has certficate authority and generates
 // one if it does not exist yet
 func (p *Process) initCertificateAuthority() error {
@@ -1148,6 +1403,8 @@ func (p *Process) initService(ctx context.Context) (err error) {
 		Packages:      p.packages,
 		Users:         p.identity,
 		Authenticator: authenticator,
+		Uploads:       p.backend,
+		Objects:       p.clusterObjects,
 	})
 	if err != nil {
 		return trace.Wrap(err)
@@ -1197,8 +1454,9 @@ func (p *Process) initService(ctx context.Context) (err error) {
 
 	if p.inKubernetes() {
 		p.handlers.Registry, err = docker.NewRegistry(docker.Config{
-			Context: ctx,
-			Users:   p.identity,
+			Context:   ctx,
+			Users:     p.identity,
+			GetConfig: p.getRegistryConfig,
 		})
 		if err != nil {
 			return trace.Wrap(err)
@@ -1224,8 +1482,10 @@ func (p *Process) initService(ctx context.Context) (err error) {
 	}
 
 	var logs opsservice.LogForwardersControl
+	var kubeEvents opsservice.KubernetesEvents
 	if p.inKubernetes() {
 		logs = opsservice.NewLogForwardersControl(client)
+		kubeEvents = opsservice.NewKubernetesEvents(client.CoreV1())
 	}
 
 	agentService := opsservice.NewAgentService(p.agentServer, peerStore,
@@ -1242,28 +1502,30 @@ func (p *Process) initService(ctx context.Context) (err error) {
 
 	// start operator service and HTTP API
 	operator, err := opsservice.New(opsservice.Config{
-		Devmode:         p.cfg.Devmode,
-		StateDir:        p.cfg.DataDir,
-		Backend:         p.backend,
-		Leader:          p.leader,
-		Agents:          agentService,
-		Clients:         clusterClients,
-		Packages:        p.packages,
-		Apps:            applications,
-		Users:           p.identity,
-		TeleportProxy:   teleportProxy,
-		Tunnel:          reverseTunnel,
-		Metrics:         metrics,
-		Local:           p.mode == constants.ComponentSite,
-		Wizard:          p.mode == constants.ComponentInstaller,
-		Proxy:           proxy,
-		SNIHost:         seedConfig.SNIHost,
-		SeedConfig:      *seedConfig,
-		ProcessID:       p.id,
-		PublicAddr:      p.cfg.Pack.GetPublicAddr(),
-		InstallLogFiles: p.cfg.InstallLogFiles,
-		LogForwarders:   logs,
-		AuditLog:        authClient,
+		Devmode:              p.cfg.Devmode,
+		StateDir:             p.cfg.DataDir,
+		Backend:              p.backend,
+		Leader:               p.leader,
+		Agents:               agentService,
+		Clients:              clusterClients,
+		Packages:             p.packages,
+		Apps:                 applications,
+		Users:                p.identity,
+		TeleportProxy:        teleportProxy,
+		Tunnel:               reverseTunnel,
+		Metrics:              metrics,
+		Local:                p.mode == constants.ComponentSite,
+		Wizard:               p.mode == constants.ComponentInstaller,
+		Proxy:                proxy,
+		SNIHost:              seedConfig.SNIHost,
+		SeedConfig:           *seedConfig,
+		ProcessID:            p.id,
+		PublicAddr:           p.cfg.Pack.GetPublicAddr(),
+		InstallLogFiles:      p.cfg.InstallLogFiles,
+		LogForwarders:        logs,
+		AuditLog:             authClient,
+		KubernetesEvents:     kubeEvents,
+		MaxExpandConcurrency: p.cfg.OpsCenter.MaxExpandConcurrency,
 	})
 	if err != nil {
 		return trace.Wrap(err)
@@ -1297,7 +1559,9 @@ func (p *Process) initService(ctx context.Context) (err error) {
 		Packages:            p.packages,
 		Authenticator:       authenticator,
 		Backend:             p.backend,
+		Wizard:              p.mode == constants.ComponentInstaller,
 		PublicAdvertiseAddr: p.cfg.Pack.GetPublicAddr(),
+		Resources:           newResources,
 	})
 	if err != nil {
 		return trace.Wrap(err)
@@ -1306,6 +1570,60 @@ func (p *Process) initService(ctx context.Context) (err error) {
 	// site status checker executes status hook periodically
 	p.RegisterClusterService(p.runSiteStatusChecker)
 
+	// health reporter sends scheduled cluster health reports
+	reporter, err := healthreport.New(healthreport.Config{
+		Operator:    p.operator,
+		Packages:    p.packages,
+		FieldLogger: p.WithField(trace.Component, "healthreport"),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	p.RegisterClusterService(reporter.Run)
+
+	// retention collector purges the records past the cluster retention policy
+	collector, err := retention.New(retention.Config{
+		Operator:    p.operator,
+		Backend:     p.backend,
+		AuditLogDir: filepath.Join(p.teleportConfig.DataDir, teleport.LogsDir),
+		FieldLogger: p.WithField(trace.Component, "retention"),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	p.RegisterClusterService(collector.RunOperations)
+	p.RegisterClusterService(collector.RunAuditEvents)
+
+	// node tombstone reconciler completes the removal of the nodes
+	// that have left the cluster forcibly
+	p.RegisterClusterService(p.runNodeTombstoneReconciler)
+
+	// certificate manager rotates the node certificates ahead of their expiration
+	if p.inKubernetes() && !p.cfg.OpsCenter.CertificateRotation.Disabled {
+		certManager, err := opsservice.NewCertificateManager(opsservice.CertificateManagerConfig{
+			Operator:     operator,
+			RotateBefore: p.cfg.OpsCenter.CertificateRotation.RotateBefore,
+			FieldLogger:  p.WithField(trace.Component, "certmanager"),
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		p.RegisterClusterService(certManager.Run)
+	}
+
+	// storage pool controller reconciles the OpenEBS storage pools
+	// with the persistent storage configuration
+	if p.inKubernetes() {
+		poolController, err := opsservice.NewStoragePoolController(opsservice.StoragePoolControllerConfig{
+			Operator:    operator,
+			FieldLogger: p.WithField(trace.Component, "storagepools"),
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		p.RegisterClusterService(poolController.Run)
+	}
+
 	// a few services that are running only when gravity is started in
 	// local site mode
 	if p.inKubernetes() {
@@ -1322,9 +1640,13 @@ func (p *Process) initService(ctx context.Context) (err error) {
 
 		p.startService(p.runCertificateWatch(client))
 		p.startService(p.runAuthGatewayWatch(client))
+		p.startService(p.runLogLevelsWatch(client))
 		p.startService(p.runReloadEventsWatch(client))
 		p.startService(p.runRegistrySynchronizer)
 		p.startService(p.runApplicationsSynchronizer)
+		p.startService(p.runRegistryReplicator)
+		p.startService(p.runRegistryMirror)
+		p.startService(collector.RunAuditLog)
 
 		if err := p.startAutoscale(p.context); err != nil {
 			return trace.Wrap(err)
@@ -1477,6 +1799,7 @@ func (p *Process) ServeHealth() error {
 	healthMux := &httprouter.Router{}
 	healthMux.HandlerFunc("GET", "/readyz", p.ReportReadiness)
 	healthMux.HandlerFunc("GET", "/healthz", p.ReportHealth)
+	healthMux.HandlerFunc("GET", "/healthz/check", p.ReportCheck)
 	p.healthServer = &http.Server{
 		Addr:    p.cfg.HealthAddr.Addr,
 		Handler: healthMux,
@@ -1562,6 +1885,7 @@ func (p *Process) initMux(ctx context.Context) error {
 		mux.Handler(method, "/v2/*rest", p.handlers.Registry)
 		mux.HandlerFunc(method, "/readyz", p.ReportReadiness)
 		mux.HandlerFunc(method, "/healthz", p.ReportHealth)
+		mux.HandlerFunc(method, "/healthz/check", p.ReportCheck)
 	}
 	mux.NotFound = p.handlers.Web.NotFound
 
@@ -1607,6 +1931,57 @@ func (p *Process) ServeLocal(ctx context.Context, mux http.Handler, addr string)
 	return nil
 }
 
+// newClusterObjects returns the package BLOB storage shared by all
+// package service instances, using the provided local BLOB storage
+// either as a replica or as a cache depending on the configured backend
+func newClusterObjects(cfg processconfig.Config, backend storage.Backend, identity users.Identity, local blob.Objects) (blob.Objects, error) {
+	if cfg.Pack.Blobs.Backend == blob.BackendS3 {
+		s3Config := cfg.Pack.Blobs.S3
+		logrus.Infof("Using S3 BLOB storage in bucket %v.", s3Config.Bucket)
+		objects, err := blobs3.New(blobs3.Config{
+			Bucket:                    s3Config.Bucket,
+			Prefix:                    s3Config.Prefix,
+			Region:                    s3Config.Region,
+			Endpoint:                  s3Config.Endpoint,
+			ForcePathStyle:            s3Config.ForcePathStyle,
+			AccessKeyID:               s3Config.AccessKeyID,
+			SecretAccessKey:           s3Config.SecretAccessKey,
+			AbortIncompleteUploadDays: s3Config.AbortIncompleteUploadDays,
+			CacheCapacityBytes:        s3Config.CacheCapacityBytes,
+			Local:                     local,
+		})
+		return objects, trace.Wrap(err)
+	}
+
+	processID := cfg.ProcessID()
+
+	blobUser := fmt.Sprintf("%v@%v", processID, constants.BlobUserSuffix)
+	blobKey, err := blob.UpsertUser(identity, blobUser)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	peerPool, err := blobclient.NewPool(blobUser, blobKey.Token)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	peerAddr, err := cfg.Pack.PeerAddr()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	objects, err := blobcluster.New(blobcluster.Config{
+		Local:         local,
+		Backend:       backend,
+		GetPeer:       peerPool.GetPeer,
+		ID:            processID,
+		AdvertiseAddr: fmt.Sprintf("https://%v", peerAddr.Addr),
+		// TODO: set WriteFactor to the number of controller instances
+	})
+	return objects, trace.Wrap(err)
+}
+
 // startListening initializes the TLS listener and starts serving on the specified
 // address using the provided handler
 func (p *Process) startListening(handler http.Handler, addr string) (net.Listener, error) {
@@ -1615,11 +1990,19 @@ func (p *Process) startListening(handler http.Handler, addr string) (net.Listene
 		return nil, trace.Wrap(err)
 	}
 
-	webListener, err := tls.Listen("tcp", addr, tlsConfig)
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	listener, err = p.proxyProtocolListener(listener)
+	if err != nil {
+		listener.Close()
+		return nil, trace.Wrap(err)
+	}
+
+	webListener := tls.NewListener(listener, tlsConfig)
+
 	server := &http.Server{Handler: handler}
 	p.wg.Add(2)
 	go func() {
@@ -1641,6 +2024,30 @@ func (p *Process) startListening(handler http.Handler, addr string) (net.Listene
 	return webListener, nil
 }
 
+// proxyProtocolListener wraps the provided listener to accept the PROXY
+// protocol header from trusted load balancers if the auth gateway enables it,
+// so the original client addresses are preserved in audit logs
+func (p *Process) proxyProtocolListener(listener net.Listener) (net.Listener, error) {
+	if p.authGatewayConfig == nil || p.authGatewayConfig.GetProxyProtocol() == nil ||
+		!p.authGatewayConfig.GetProxyProtocol().Value() {
+		return listener, nil
+	}
+	trustedProxies, err := proxyproto.ParseTrustedProxies(p.authGatewayConfig.GetTrustedProxies())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(trustedProxies) == 0 {
+		p.Warnf("PROXY protocol is enabled but no trusted proxies are configured, "+
+			"ignoring PROXY protocol headers on %v.", listener.Addr())
+		return listener, nil
+	}
+	p.Infof("Accepting PROXY protocol on %v from %v.", listener.Addr(),
+		strings.Join(p.authGatewayConfig.GetTrustedProxies(), ","))
+	return proxyproto.NewListener(listener, proxyproto.Config{
+		TrustedProxies: trustedProxies,
+	})
+}
+
 // getTLSConfig returns the TLS config for this process.
 //
 // In case we're running inside Kubernetes cluster, certificate and key are
@@ -2288,3 +2695,16 @@ type proxyConfig struct {
 	sshPort           string
 	reverseTunnelAddr teleutils.NetAddr
 }
+
+// newResources returns the resource controller that manages cluster
+// resources with the specified operator
+func newResources(operator ops.Operator) (resources.Resources, error) {
+	controller, err := gravity.New(gravity.Config{
+		Operator: operator,
+		Silent:   localenv.Silent(true),
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return controller, nil
+}
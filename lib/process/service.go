@@ -19,6 +19,8 @@ package process
 import (
 	"context"
 
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/logging"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/processconfig"
 	"github.com/gravitational/gravity/lib/users"
@@ -68,8 +70,18 @@ func Run(ctx context.Context, configDir, importDir string, newProcess NewGravity
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	defaultLevel := logrus.GetLevel()
 	if gravityConfig.Devmode {
-		logrus.SetLevel(logrus.DebugLevel)
+		defaultLevel = logrus.DebugLevel
+	}
+	err = logging.Init(gravityConfig.Log, defaultLevel)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if len(gravityConfig.Proxy.Rules) != 0 {
+		policy := httplib.GetProxyPolicy()
+		policy.Rules = append(append([]httplib.ProxyRule(nil), gravityConfig.Proxy.Rules...), policy.Rules...)
+		httplib.SetProxyPolicy(policy)
 	}
 	gravityConfig.ImportDir = importDir
 	process, err := newProcess(ctx, *gravityConfig, *teleportConfig)
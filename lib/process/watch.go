@@ -18,11 +18,13 @@ package process
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	libkube "github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/logging"
 	"github.com/gravitational/gravity/lib/processconfig"
 	"github.com/gravitational/gravity/lib/storage"
 
@@ -174,6 +176,90 @@ func (p *Process) watchAuthGateway(ctx context.Context, client *kubernetes.Clien
 	}
 }
 
+// runLogLevelsWatch monitors config map with log level overrides
+// and applies them to the process logger.
+func (p *Process) runLogLevelsWatch(client *kubernetes.Clientset) clusterService {
+	return func(ctx context.Context) {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			err := p.watchLogLevels(ctx, client)
+			if err != nil {
+				p.WithError(err).Warn("Failed to start log levels watch.")
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				p.Debug("Log levels watcher stopped.")
+				return
+			}
+		}
+	}
+}
+
+// watchLogLevels observes changes to the log levels config map and
+// updates the levels of the process logger.
+func (p *Process) watchLogLevels(ctx context.Context, client *kubernetes.Clientset) error {
+	p.Debug("Restarting log levels watch.")
+	watcher, err := client.Core().ConfigMaps(defaults.KubeSystemNamespace).Watch(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", constants.LogLevelsConfigMap).String(),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer watcher.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				p.Debugf("Watcher channel closed: %v.", event)
+				return nil
+			}
+			configMap, ok := event.Object.(*v1.ConfigMap)
+			if !ok {
+				p.Warningf("Expected ConfigMap, got: %[1]T %[1]v.", event.Object)
+				continue
+			}
+			var overrides logging.Levels
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				err := json.Unmarshal([]byte(configMap.Data[constants.ResourceSpecKey]), &overrides)
+				if err != nil {
+					p.WithError(err).Warn("Failed to parse log levels.")
+					continue
+				}
+			case watch.Deleted:
+			default:
+				p.Debugf("Ignoring event: %v.", event.Type)
+				continue
+			}
+			err := p.setLogLevels(overrides)
+			if err != nil {
+				p.WithError(err).Warn("Failed to update log levels.")
+			}
+		case <-ctx.Done():
+			p.Debug("Stopping log levels watcher.")
+			return nil
+		}
+	}
+}
+
+// setLogLevels applies the specified overrides on top of the log levels
+// the process has been configured with
+func (p *Process) setLogLevels(overrides logging.Levels) error {
+	levels, err := p.cfg.Log.Levels()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	levels = levels.Merge(overrides)
+	err = logging.SetLevels(levels)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	p.Infof("Updated log levels: %v.", levels)
+	return nil
+}
+
 // reloadAuthGatewayConfig compares the provided auth gateway configuration
 // with the configuration the process is currently started with and makes a
 // decision on whether the configuration should be updated and/or the process
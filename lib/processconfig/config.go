@@ -23,11 +23,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/gravitational/gravity/lib/blob"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/helm"
+	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/logging"
 	"github.com/gravitational/gravity/lib/modules"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/storage"
@@ -112,6 +116,14 @@ type Config struct {
 	// Profile specifies performance profiling settings
 	Profile ProfileConfig `yaml:"profile"`
 
+	// Log specifies logging settings
+	Log logging.Config `yaml:"log"`
+
+	// Proxy specifies the HTTP proxy policy for outgoing connections.
+	// Its rules take precedence over the internal destinations
+	// excluded from proxying on the command line
+	Proxy httplib.ProxyPolicy `yaml:"proxy"`
+
 	// Devmode defines a development mode that takes several shortcuts in favor
 	// of simplicity.
 	// In this mode the following things are different:
@@ -181,6 +193,12 @@ func (cfg *Config) CheckAndSetDefaults() error {
 	if !utils.StringInSlice(modules.Get().ProcessModes(), cfg.Mode) {
 		return trace.BadParameter("unsupported process mode: %q", cfg.Mode)
 	}
+	if _, err := cfg.Log.Levels(); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := cfg.Proxy.Check(); err != nil {
+		return trace.Wrap(err)
+	}
 
 	if err := os.MkdirAll(cfg.DataDir, defaults.SharedDirMask); err != nil {
 		return trace.Wrap(err)
@@ -223,6 +241,10 @@ func (cfg *Config) CheckAndSetDefaults() error {
 		return trace.Wrap(err)
 	}
 
+	if err := cfg.Pack.Blobs.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+
 	return nil
 }
 
@@ -316,6 +338,66 @@ type PackageServiceConfig struct {
 
 	// ReadDir is an optional directory with extra packages
 	ReadDir string `yaml:"read_dir"`
+
+	// Blobs configures the storage for package BLOBs
+	Blobs BlobsConfig `yaml:"blobs"`
+}
+
+// BlobsConfig defines package BLOB storage configuration
+type BlobsConfig struct {
+	// Backend is the BLOB storage backend, "cluster" (default) replicates
+	// BLOBs between local disks of package service instances and "s3" stores
+	// them in an S3 bucket using local disks as a read-through cache
+	Backend string `yaml:"backend"`
+	// S3 configures the S3 backend
+	S3 S3BlobsConfig `yaml:"s3"`
+}
+
+// S3BlobsConfig configures the S3 BLOB storage backend
+type S3BlobsConfig struct {
+	// Bucket is the S3 bucket name
+	Bucket string `yaml:"bucket"`
+	// Prefix is the optional prefix of BLOB keys in the bucket
+	Prefix string `yaml:"prefix"`
+	// Region is the bucket region
+	Region string `yaml:"region"`
+	// Endpoint is the optional URL of an S3-compatible object storage, e.g. MinIO
+	Endpoint string `yaml:"endpoint"`
+	// ForcePathStyle enables path-style bucket addressing
+	ForcePathStyle bool `yaml:"force_path_style"`
+	// AccessKeyID is the optional access key ID, the default AWS
+	// credentials chain is used if unspecified
+	AccessKeyID string `yaml:"access_key_id"`
+	// SecretAccessKey is the optional secret access key
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// AbortIncompleteUploadDays is the number of days after which
+	// incomplete multipart uploads are aborted
+	AbortIncompleteUploadDays int64 `yaml:"abort_incomplete_upload_days"`
+	// CacheCapacityBytes is the maximum size of the local BLOB cache in bytes
+	CacheCapacityBytes int64 `yaml:"cache_capacity_bytes"`
+}
+
+// CheckAndSetDefaults validates BLOB storage configuration.
+func (c *BlobsConfig) CheckAndSetDefaults() error {
+	switch c.Backend {
+	case blob.BackendCluster:
+	case "":
+		c.Backend = blob.BackendCluster
+	case blob.BackendS3:
+		if c.S3.Bucket == "" {
+			return trace.BadParameter("S3 BLOB storage backend requires a bucket")
+		}
+		if c.S3.AbortIncompleteUploadDays < 0 {
+			return trace.BadParameter("abort_incomplete_upload_days can't be negative")
+		}
+		if c.S3.CacheCapacityBytes < 0 {
+			return trace.BadParameter("cache_capacity_bytes can't be negative")
+		}
+	default:
+		return trace.BadParameter("unsupported BLOB storage backend %q, supported are: %q, %q",
+			c.Backend, blob.BackendCluster, blob.BackendS3)
+	}
+	return nil
 }
 
 // PeerAddr returns peer address of the package service instance
@@ -377,6 +459,21 @@ func (c *ChartsConfig) CheckAndSetDefaults() error {
 type OpsCenterConfig struct {
 	// SeedConfig defines optional configuration to apply on OpsCenter start
 	SeedConfig *ops.SeedConfig `yaml:"seed_config"`
+	// MaxExpandConcurrency is the maximum number of nodes that can be
+	// joining the cluster concurrently
+	MaxExpandConcurrency int `yaml:"max_expand_concurrency"`
+	// CertificateRotation configures the automatic rotation
+	// of the node certificates
+	CertificateRotation CertificateRotationConfig `yaml:"certificate_rotation"`
+}
+
+// CertificateRotationConfig configures the automatic rotation of the node certificates
+type CertificateRotationConfig struct {
+	// Disabled disables the automatic rotation of the node certificates
+	Disabled bool `yaml:"disabled"`
+	// RotateBefore is how long before the expiration the node certificates
+	// are rotated. Defaults to defaults.CertificateRotationThreshold
+	RotateBefore time.Duration `yaml:"rotate_before"`
 }
 
 type packageLocator loc.Locator
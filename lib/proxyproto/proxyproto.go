@@ -0,0 +1,269 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package proxyproto implements a listener that accepts connections
+// prefixed with the HAProxy PROXY protocol header (versions 1 and 2)
+// as sent by load balancers such as HAProxy or AWS NLB, so that the
+// original client address is available to the server.
+//
+// See https://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/gravitational/teleport/lib/multiplexer"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// Config defines the PROXY protocol listener configuration
+type Config struct {
+	// TrustedProxies lists the networks of the proxies allowed to send
+	// the PROXY protocol header. At least one network is required
+	TrustedProxies []net.IPNet
+	// ReadHeaderTimeout is the maximum time to wait for the header
+	ReadHeaderTimeout time.Duration
+}
+
+// CheckAndSetDefaults validates the config and sets defaults
+func (r *Config) CheckAndSetDefaults() error {
+	if len(r.TrustedProxies) == 0 {
+		// accepting the header from any source would allow any client to spoof its address
+		return trace.BadParameter("PROXY protocol requires at least one trusted proxy")
+	}
+	if r.ReadHeaderTimeout == 0 {
+		r.ReadHeaderTimeout = defaults.ProxyProtocolReadHeaderTimeout
+	}
+	return nil
+}
+
+// ParseTrustedProxies parses the list of trusted proxy addresses.
+// Each entry is either a CIDR or a single IP address
+func ParseTrustedProxies(proxies []string) (networks []net.IPNet, err error) {
+	for _, proxy := range proxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, trace.BadParameter("invalid trusted proxy %q: expected IP address or CIDR", proxy)
+		}
+		networks = append(networks, *network)
+	}
+	return networks, nil
+}
+
+// NewListener returns a listener that reads the optional PROXY protocol
+// header from the connections accepted by the specified listener and
+// reports the client address from the header as the remote address.
+//
+// Connections without the header are passed through unchanged.
+// The header is only honored on connections from trusted proxies
+func NewListener(listener net.Listener, config Config) (net.Listener, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &proxyListener{Listener: listener, config: config}, nil
+}
+
+// Accept accepts the next connection.
+// The header is read lazily on first use so a slow client does not block the listener.
+// Implements net.Listener
+func (r *proxyListener) Accept() (net.Conn, error) {
+	conn, err := r.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !r.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: r.config.ReadHeaderTimeout,
+	}, nil
+}
+
+func (r *proxyListener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range r.config.TrustedProxies {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+type proxyListener struct {
+	net.Listener
+	config Config
+}
+
+// Conn is a connection that reports the client address
+// from the PROXY protocol header as its remote address
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	// remoteAddr is the client address from the header
+	remoteAddr net.Addr
+	// err is the error reading the header
+	err error
+}
+
+// Read reads data from the connection after the header.
+// Implements io.Reader
+func (r *Conn) Read(p []byte) (int, error) {
+	r.once.Do(r.readHeader)
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(p)
+}
+
+// RemoteAddr returns the client address from the header or the address
+// of the connection peer if the connection has no header.
+// Implements net.Conn
+func (r *Conn) RemoteAddr() net.Addr {
+	r.once.Do(r.readHeader)
+	if r.remoteAddr != nil {
+		return r.remoteAddr
+	}
+	return r.Conn.RemoteAddr()
+}
+
+func (r *Conn) readHeader() {
+	if err := r.Conn.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+		r.err = trace.Wrap(err)
+		return
+	}
+	defer r.Conn.SetReadDeadline(time.Time{})
+	r.remoteAddr, r.err = ReadHeader(r.reader)
+	if r.err != nil {
+		logrus.WithError(r.err).WithField("addr", r.Conn.RemoteAddr()).
+			Warn("Failed to read PROXY protocol header.")
+	}
+}
+
+// ReadHeader reads the optional PROXY protocol header from the reader.
+// Returns the client address from the header, or nil if the stream
+// does not start with a header or the header does not carry the address
+func ReadHeader(reader *bufio.Reader) (net.Addr, error) {
+	prefix, err := reader.Peek(len(v1Prefix))
+	if err != nil {
+		if err == io.EOF || err == bufio.ErrBufferFull {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	if bytes.Equal(prefix, v1Prefix) {
+		if unknown, _ := reader.Peek(len(v1Unknown)); bytes.Equal(unknown, v1Unknown) {
+			// the proxy could not determine the client address
+			_, err := reader.ReadSlice('\n')
+			return nil, trace.Wrap(err)
+		}
+		line, err := multiplexer.ReadProxyLine(reader)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if line.Protocol == multiplexer.UNKNOWN {
+			return nil, nil
+		}
+		return &line.Source, nil
+	}
+	if prefix[0] != v2Signature[0] {
+		return nil, nil
+	}
+	signature, err := reader.Peek(len(v2Signature))
+	if err != nil || !bytes.Equal(signature, v2Signature) {
+		return nil, nil
+	}
+	return readHeaderV2(reader)
+}
+
+// readHeaderV2 reads the binary version 2 header
+func readHeaderV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(v2Signature)+4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	versionCommand, family := header[12], header[13]
+	length := binary.BigEndian.Uint16(header[14:])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if versionCommand>>4 != 2 {
+		return nil, trace.BadParameter("unsupported PROXY protocol version %v", versionCommand>>4)
+	}
+	if versionCommand&0xf == v2CommandLocal {
+		// health checks from the proxy itself carry no client address
+		return nil, nil
+	}
+	switch family >> 4 {
+	case v2FamilyInet:
+		if len(payload) < 2*net.IPv4len+4 {
+			return nil, trace.BadParameter("truncated PROXY protocol address block")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[:net.IPv4len]),
+			Port: int(binary.BigEndian.Uint16(payload[2*net.IPv4len:])),
+		}, nil
+	case v2FamilyInet6:
+		if len(payload) < 2*net.IPv6len+4 {
+			return nil, trace.BadParameter("truncated PROXY protocol address block")
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[:net.IPv6len]),
+			Port: int(binary.BigEndian.Uint16(payload[2*net.IPv6len:])),
+		}, nil
+	}
+	// unsupported address family: the connection is used as-is
+	return nil, nil
+}
+
+var (
+	// v1Prefix starts the text version 1 header
+	v1Prefix = []byte("PROXY ")
+	// v1Unknown starts the version 1 header without the client address
+	v1Unknown = []byte("PROXY UNKNOWN")
+	// v2Signature starts the binary version 2 header
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+const (
+	v2CommandLocal = 0x0
+	v2FamilyInet   = 0x1
+	v2FamilyInet6  = 0x2
+)
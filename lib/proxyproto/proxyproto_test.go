@@ -0,0 +1,166 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+func TestProxyProto(t *testing.T) { check.TestingT(t) }
+
+type ProxyProtoSuite struct{}
+
+var _ = check.Suite(&ProxyProtoSuite{})
+
+func (s *ProxyProtoSuite) TestReadsHeader(c *check.C) {
+	testCases := []struct {
+		comment string
+		input   []byte
+		addr    net.Addr
+	}{
+		{
+			comment: "no header",
+			input:   []byte("GET / HTTP/1.1\r\n"),
+		},
+		{
+			comment: "version 1 header",
+			input:   []byte("PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"),
+			addr:    &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 56324},
+		},
+		{
+			comment: "version 1 header with unknown protocol",
+			input:   []byte("PROXY UNKNOWN\r\nGET / HTTP/1.1\r\n"),
+		},
+		{
+			comment: "version 2 header",
+			input: append(headerV2(0x21, 0x11,
+				append(append(net.ParseIP("192.168.1.1").To4(), net.ParseIP("10.0.0.1").To4()...), 0xdc, 0x04, 0x01, 0xbb)),
+				[]byte("GET / HTTP/1.1\r\n")...),
+			addr: &net.TCPAddr{IP: net.ParseIP("192.168.1.1").To4(), Port: 56324},
+		},
+		{
+			comment: "version 2 local command",
+			input:   append(headerV2(0x20, 0x00, nil), []byte("GET / HTTP/1.1\r\n")...),
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		reader := bufio.NewReader(bytes.NewReader(tc.input))
+		addr, err := ReadHeader(reader)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(addr, check.DeepEquals, tc.addr, comment)
+		rest, err := ioutil.ReadAll(reader)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(string(rest), check.Equals, "GET / HTTP/1.1\r\n", comment)
+	}
+}
+
+func (s *ProxyProtoSuite) TestRejectsMalformedHeader(c *check.C) {
+	_, err := ReadHeader(bufio.NewReader(bytes.NewReader([]byte("PROXY TCP4 garbage\r\n"))))
+	c.Assert(err, check.NotNil)
+	_, err = ReadHeader(bufio.NewReader(bytes.NewReader(headerV2(0x21, 0x11, []byte{1, 2}))))
+	c.Assert(err, check.NotNil)
+}
+
+func (s *ProxyProtoSuite) TestHonorsTrustedProxies(c *check.C) {
+	const header = "PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n"
+	testCases := []struct {
+		comment        string
+		trustedProxies []string
+		remoteHost     string
+		data           string
+	}{
+		{
+			comment:        "trusted source",
+			trustedProxies: []string{"127.0.0.0/8"},
+			remoteHost:     "192.168.1.1",
+			data:           "hello",
+		},
+		{
+			comment:        "untrusted source",
+			trustedProxies: []string{"10.0.0.1"},
+			remoteHost:     "127.0.0.1",
+			data:           header + "hello",
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		trustedProxies, err := ParseTrustedProxies(tc.trustedProxies)
+		c.Assert(err, check.IsNil, comment)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		c.Assert(err, check.IsNil, comment)
+		listener, err = NewListener(listener, Config{TrustedProxies: trustedProxies})
+		c.Assert(err, check.IsNil, comment)
+
+		go func() {
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(header + "hello"))
+			conn.Close()
+		}()
+
+		conn, err := listener.Accept()
+		c.Assert(err, check.IsNil, comment)
+		remoteHost, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(remoteHost, check.Equals, tc.remoteHost, comment)
+		data, err := ioutil.ReadAll(conn)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(string(data), check.Equals, tc.data, comment)
+		conn.Close()
+		listener.Close()
+	}
+}
+
+func (s *ProxyProtoSuite) TestRequiresTrustedProxies(c *check.C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer listener.Close()
+	_, err = NewListener(listener, Config{})
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *ProxyProtoSuite) TestParsesTrustedProxies(c *check.C) {
+	networks, err := ParseTrustedProxies([]string{"10.0.0.0/16", "192.168.1.10", "fd00::1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(networks, check.HasLen, 3)
+	c.Assert(networks[0].String(), check.Equals, "10.0.0.0/16")
+	c.Assert(networks[1].String(), check.Equals, "192.168.1.10/32")
+	c.Assert(networks[2].String(), check.Equals, "fd00::1/128")
+
+	_, err = ParseTrustedProxies([]string{"example.com"})
+	c.Assert(err, check.NotNil)
+}
+
+// headerV2 returns the binary version 2 header with the specified
+// version/command and family/protocol bytes and address block
+func headerV2(versionCommand, family byte, addrs []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, versionCommand, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addrs)))
+	return append(header, addrs...)
+}
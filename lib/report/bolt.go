@@ -0,0 +1,171 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/boltdb/bolt"
+	"github.com/gravitational/trace"
+)
+
+// NewBoltCollector returns a collector that dumps the contents of the bolt
+// database at the specified path into the file with the given name.
+//
+// The dump is sanitized: values of the fields that look sensitive (passwords,
+// tokens, private keys, etc.) are redacted, as well as the entire contents of
+// the buckets that store secrets
+func NewBoltCollector(name, path string) Collector {
+	return boltCollector{name: name, path: path}
+}
+
+// Collect dumps the database as a sequence of JSON entries, one per key.
+// Implements Collector
+func (r boltCollector) Collect(ctx context.Context, reportWriter FileWriter, runner utils.CommandRunner) error {
+	if _, err := os.Stat(r.path); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	db, err := bolt.Open(r.path, defaults.PrivateFileMask, &bolt.Options{
+		Timeout:  defaults.DBOpenTimeout,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return trace.Wrap(err, "failed to open %v", r.path)
+	}
+	defer db.Close()
+
+	w, err := reportWriter.NewWriter(r.name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer w.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			return dumpBucket(w, []string{string(name)}, bucket)
+		})
+	})
+}
+
+type boltCollector struct {
+	name string
+	path string
+}
+
+// dumpBucket writes the sanitized contents of the bucket with the specified path into w
+func dumpBucket(w io.Writer, path []string, bucket *bolt.Bucket) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return bucket.ForEach(func(key, value []byte) error {
+		keyPath := append(path[:len(path):len(path)], sanitizeKey(path, string(key)))
+		if value == nil {
+			return dumpBucket(w, keyPath, bucket.Bucket(key))
+		}
+		return trace.Wrap(enc.Encode(boltEntry{
+			Key:   strings.Join(keyPath, "/"),
+			Value: sanitizeValue(path, value),
+		}))
+	})
+}
+
+// boltEntry is a single entry in the database dump
+type boltEntry struct {
+	// Key is the full path to the key
+	Key string `json:"key"`
+	// Value is the sanitized value
+	Value interface{} `json:"value"`
+}
+
+// sanitizeKey returns the key name to use in the dump: the names of keys
+// in the secret buckets can be secrets themselves (e.g. tokens) and are redacted
+func sanitizeKey(path []string, key string) string {
+	if isSecretBucket(path) {
+		return redacted
+	}
+	return key
+}
+
+// sanitizeValue returns the value to use in the dump
+func sanitizeValue(path []string, value []byte) interface{} {
+	if isSecretBucket(path) {
+		return redacted
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return fmt.Sprintf("<%v bytes of non-JSON data>", len(value))
+	}
+	return sanitizeJSON(decoded)
+}
+
+// sanitizeJSON redacts the values of sensitive fields in the decoded JSON value
+func sanitizeJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range v {
+			if isSensitiveName(field) {
+				v[field] = redacted
+				continue
+			}
+			v[field] = sanitizeJSON(fieldValue)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = sanitizeJSON(item)
+		}
+	}
+	return value
+}
+
+func isSecretBucket(path []string) bool {
+	for _, name := range path {
+		lower := strings.ToLower(name)
+		for _, pattern := range secretBuckets {
+			if strings.Contains(lower, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isSensitiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, pattern := range sensitiveNames {
+		if strings.Contains(lower, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretBuckets lists the patterns of the buckets that store secrets
+var secretBuckets = []string{"token", "apikey", "session", "secret", "password"}
+
+// sensitiveNames lists the patterns of the field names that can contain secrets
+var sensitiveNames = []string{
+	"password", "secret", "token", "priv", "key", "license", "credential", "hash", "seed",
+}
+
+const redacted = "<redacted>"
@@ -0,0 +1,57 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/boltdb/bolt"
+	. "gopkg.in/check.v1"
+)
+
+func (r *S) TestDumpsSanitizedBoltDatabase(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "gravity.db")
+	db, err := bolt.Open(path, 0600, nil)
+	c.Assert(err, IsNil)
+	err = db.Update(func(tx *bolt.Tx) error {
+		sites, err := tx.CreateBucketIfNotExists([]byte("sites"))
+		c.Assert(err, IsNil)
+		site, err := sites.CreateBucketIfNotExists([]byte("example.com"))
+		c.Assert(err, IsNil)
+		c.Assert(site.Put([]byte("val"), []byte(`{"domain":"example.com","license":"abc","users":[{"name":"alice","password":"secret"}]}`)), IsNil)
+		c.Assert(site.Put([]byte("raw"), []byte{0xff, 0xfe}), IsNil)
+		tokens, err := tx.CreateBucketIfNotExists([]byte("tokens"))
+		c.Assert(err, IsNil)
+		c.Assert(tokens.Put([]byte("f00b4r"), []byte(`{"user":"agent@example.com"}`)), IsNil)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(db.Close(), IsNil)
+
+	out := c.MkDir()
+	err = NewBoltCollector("state.json", path).Collect(context.TODO(), NewFileWriter(out), utils.Runner)
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, out, "state.json"), Equals,
+		`{"key":"sites/example.com/raw","value":"<2 bytes of non-JSON data>"}
+{"key":"sites/example.com/val","value":{"domain":"example.com","license":"<redacted>","users":[{"name":"alice","password":"<redacted>"}]}}
+{"key":"tokens/<redacted>","value":"<redacted>"}
+`)
+}
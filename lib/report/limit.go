@@ -0,0 +1,115 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/gravitational/trace"
+)
+
+// NewLimitedFileWriter returns a file writer that caps the size of each file
+// at fileLimit bytes and the total size of all files at totalLimit bytes.
+// Data beyond the limits is discarded and the affected files are recorded
+// as truncated. A zero limit means no limit
+func NewLimitedFileWriter(writer FileWriter, fileLimit, totalLimit int64) *LimitedFileWriter {
+	return &LimitedFileWriter{
+		writer:     writer,
+		fileLimit:  fileLimit,
+		totalLimit: totalLimit,
+		truncated:  make(map[string]struct{}),
+	}
+}
+
+// NewWriter creates a new size-limited writer for the file with the specified name
+func (r *LimitedFileWriter) NewWriter(name string) (io.WriteCloser, error) {
+	w, err := r.writer.NewWriter(name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &limitedWriter{WriteCloser: w, name: name, parent: r}, nil
+}
+
+// Truncated returns the sorted names of the files that have been truncated
+func (r *LimitedFileWriter) Truncated() (names []string) {
+	r.Lock()
+	defer r.Unlock()
+	for name := range r.truncated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LimitedFileWriter is a file writer that limits the size of the written files
+type LimitedFileWriter struct {
+	sync.Mutex
+	writer     FileWriter
+	fileLimit  int64
+	totalLimit int64
+	// total is the number of bytes written to all files
+	total     int64
+	truncated map[string]struct{}
+}
+
+// allow returns the part of the size bytes that can still be written
+// to the file with the specified name, given its current size
+func (r *LimitedFileWriter) allow(name string, written, size int64) int64 {
+	r.Lock()
+	defer r.Unlock()
+	allowed := size
+	if r.fileLimit > 0 && written+allowed > r.fileLimit {
+		allowed = r.fileLimit - written
+	}
+	if r.totalLimit > 0 && r.total+allowed > r.totalLimit {
+		allowed = r.totalLimit - r.total
+	}
+	if allowed < 0 {
+		allowed = 0
+	}
+	if allowed < size {
+		r.truncated[name] = struct{}{}
+	}
+	r.total += allowed
+	return allowed
+}
+
+// Write writes data to the underlying file discarding
+// the data beyond the limits.
+// Implements io.Writer
+func (r *limitedWriter) Write(data []byte) (int, error) {
+	allowed := r.parent.allow(r.name, r.written, int64(len(data)))
+	if allowed > 0 {
+		n, err := r.WriteCloser.Write(data[:allowed])
+		r.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	// Report the discarded data as written so the collectors
+	// do not fail on truncated files
+	return len(data), nil
+}
+
+type limitedWriter struct {
+	io.WriteCloser
+	name    string
+	parent  *LimitedFileWriter
+	written int64
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+
+	. "gopkg.in/check.v1"
+)
+
+func (r *S) TestLimitsFileSizes(c *C) {
+	dir := c.MkDir()
+	writer := NewLimitedFileWriter(NewFileWriter(dir), 5, 8)
+
+	write := func(name, data string) {
+		w, err := writer.NewWriter(name)
+		c.Assert(err, IsNil)
+		n, err := w.Write([]byte(data))
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, len(data))
+		c.Assert(w.Close(), IsNil)
+	}
+	write("a", "abc")
+	write("b", "abcdefg")
+	write("c", "abc")
+
+	c.Assert(readFile(c, dir, "a"), Equals, "abc")
+	c.Assert(readFile(c, dir, "b"), Equals, "abcde", Commentf("expected the file limit to apply"))
+	c.Assert(readFile(c, dir, "c"), Equals, "", Commentf("expected the total limit to apply"))
+	c.Assert(writer.Truncated(), DeepEquals, []string{"b", "c"})
+}
+
+func readFile(c *C, dir, name string) string {
+	data, err := ioutil.ReadFile(dir + "/" + name)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
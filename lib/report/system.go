@@ -35,6 +35,8 @@ func NewSystemCollector() Collectors {
 	add(basicSystemInfo()...)
 	add(planetServices()...)
 	add(syslogExportLogs())
+	add(gravityUnitLogs())
+	add(localStateDump())
 	add(systemFileLogs()...)
 	add(planetLogs()...)
 	add(bashHistoryCollector{})
@@ -110,6 +112,27 @@ func syslogExportLogs() Collector {
 	return Script("gravity-system.log.gz", fmt.Sprintf(script, strings.Join(matches, " ")))
 }
 
+// gravityUnitLogs fetches the recent journal entries of the gravity
+// systemd units: package services (planet, teleport), agents and installer
+func gravityUnitLogs() Collector {
+	const script = `
+#!/bin/bash
+/bin/journalctl --no-pager --output=export --since=-%v %v | /bin/gzip -f`
+	units := []string{
+		"--unit=gravity__*",
+		fmt.Sprintf("--unit=%v", defaults.GravityRPCAgentServiceName),
+		fmt.Sprintf("--unit=%v", defaults.GravityRPCInstallerServiceName),
+	}
+	return Script("gravity-units-journal.log.gz", fmt.Sprintf(script,
+		defaults.ReportJournalPeriod, strings.Join(units, " ")))
+}
+
+// localStateDump fetches the sanitized dump of the node's local state database
+func localStateDump() Collector {
+	return NewBoltCollector("local-state.json",
+		filepath.Join(defaults.LocalGravityDir, defaults.GravityDBFile))
+}
+
 // systemFileLogs fetches gravity platform-related logs
 func systemFileLogs() Collectors {
 	const template = `
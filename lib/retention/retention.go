@@ -0,0 +1,473 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package retention implements the service that purges the records of finished
+// cluster operations, the files of the cluster audit log and the command line
+// audit events that are past the cluster retention policy.
+package retention
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleevents "github.com/gravitational/teleport/lib/events"
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/sirupsen/logrus"
+)
+
+// Operator defines the subset of the cluster operator used by the collector
+type Operator interface {
+	// GetLocalSite returns the local cluster record
+	GetLocalSite() (*ops.Site, error)
+	// GetRetentionPolicy returns the cluster retention policy
+	GetRetentionPolicy(ops.SiteKey) (storage.RetentionPolicy, error)
+}
+
+// Backend defines the subset of the cluster backend with operation records
+type Backend interface {
+	// GetSiteOperations returns the cluster operations sorted from newest to oldest
+	GetSiteOperations(siteDomain string) ([]storage.SiteOperation, error)
+	// GetOperationPlan returns the plan of the specified operation
+	GetOperationPlan(clusterName, operationID string) (*storage.OperationPlan, error)
+	// GetOperationPlanChangelog returns the plan change log of the specified operation
+	GetOperationPlanChangelog(clusterName, operationID string) (storage.PlanChangelog, error)
+	// DeleteSiteOperation removes the operation with its plan, change log and progress
+	DeleteSiteOperation(siteDomain, operationID string) error
+	// GetAuditEvents returns the command line audit events recorded since
+	// the specified time sorted by creation time
+	GetAuditEvents(since time.Time) ([]storage.AuditEvent, error)
+	// DeleteAuditEvent removes the command line audit event with the specified ID
+	DeleteAuditEvent(id string) error
+}
+
+// Config defines the collector configuration
+type Config struct {
+	// Operator is the cluster operator
+	Operator Operator
+	// Backend is the cluster backend with operation records
+	Backend Backend
+	// AuditLogDir is the directory with the cluster audit log of this node.
+	// The audit log is not purged if unspecified
+	AuditLogDir string
+	// Clock is used to determine the age of the records
+	Clock clockwork.Clock
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+	// export posts the records to the export webhook
+	export func(ctx context.Context, url string, export Export) error
+}
+
+// CheckAndSetDefaults validates the configuration and sets defaults
+func (r *Config) CheckAndSetDefaults() error {
+	if r.Operator == nil {
+		return trace.BadParameter("missing Operator")
+	}
+	if r.Backend == nil {
+		return trace.BadParameter("missing Backend")
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = logrus.WithField(trace.Component, "retention")
+	}
+	if r.export == nil {
+		r.export = postExport
+	}
+	return nil
+}
+
+// New returns a new collector
+func New(config Config) (*Collector, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Collector{Config: config}, nil
+}
+
+// Collector periodically purges the records past the cluster retention policy
+type Collector struct {
+	// Config is the collector configuration
+	Config
+}
+
+// RunOperations periodically purges the records of finished operations.
+// Operation records are shared by all master nodes so this runs on the leader.
+// Blocks until the context is canceled
+func (r *Collector) RunOperations(ctx context.Context) {
+	r.run(ctx, "operations", r.CollectOperations)
+}
+
+// RunAuditEvents periodically purges the command line audit events.
+// Audit events are shared by all master nodes so this runs on the leader.
+// Blocks until the context is canceled
+func (r *Collector) RunAuditEvents(ctx context.Context) {
+	r.run(ctx, "audit events", r.CollectAuditEvents)
+}
+
+// RunAuditLog periodically purges the files of the audit log of this node.
+// Every master node keeps its own audit log so this runs on all master nodes.
+// Blocks until the context is canceled
+func (r *Collector) RunAuditLog(ctx context.Context) {
+	if r.AuditLogDir == "" {
+		return
+	}
+	r.run(ctx, "audit log", r.CollectAuditLog)
+}
+
+func (r *Collector) run(ctx context.Context, name string, collect func(context.Context) (int, error)) {
+	r.Infof("Starting %v retention.", name)
+	ticker := r.Clock.NewTicker(defaults.RetentionCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.Chan():
+			purged, err := collect(ctx)
+			if err != nil {
+				r.WithError(err).Warnf("Failed to enforce %v retention.", name)
+			}
+			if purged != 0 {
+				r.Infof("Purged %v %v records.", purged, name)
+			}
+		case <-ctx.Done():
+			r.Infof("Stopping %v retention.", name)
+			return
+		}
+	}
+}
+
+// CollectOperations purges the records of finished operations that exceed
+// the retention limits and returns the number of purged operations.
+//
+// Operations that are in progress and the install operation are never purged.
+// If the export webhook is configured, every operation is posted to the webhook
+// before it is purged and is kept if the export fails
+func (r *Collector) CollectOperations(ctx context.Context) (purged int, err error) {
+	cluster, policy, err := r.getPolicy()
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	if policy == nil || policy.GetOperations() == nil {
+		return 0, nil
+	}
+	limits := *policy.GetOperations()
+	operations, err := r.Backend.GetSiteOperations(cluster.Domain)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	now := r.Clock.Now().UTC()
+	var index int
+	for _, operation := range operations {
+		op := ops.SiteOperation(operation)
+		if !op.IsFinished() || op.Type == ops.OperationInstall {
+			continue
+		}
+		finished := op.Updated
+		if finished.IsZero() {
+			finished = op.Created
+		}
+		exceeds := limits.Exceeds(finished, now, index)
+		index++
+		if !exceeds {
+			continue
+		}
+		if err := r.purgeOperation(ctx, policy, operation); err != nil {
+			return purged, trace.Wrap(err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (r *Collector) purgeOperation(ctx context.Context, policy storage.RetentionPolicy, operation storage.SiteOperation) error {
+	logger := r.WithField("operation", operation.ID)
+	if policy.GetExportWebhookURL() != "" {
+		record := OperationRecord{Operation: operation}
+		plan, err := r.Backend.GetOperationPlan(operation.SiteDomain, operation.ID)
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		record.Plan = plan
+		record.Changelog, err = r.Backend.GetOperationPlanChangelog(operation.SiteDomain, operation.ID)
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		err = r.exportWithTimeout(ctx, policy.GetExportWebhookURL(), Export{
+			Cluster:    operation.SiteDomain,
+			Operations: []OperationRecord{record},
+		})
+		if err != nil {
+			return trace.Wrap(err, "failed to export operation %v", operation.ID)
+		}
+	}
+	err := r.Backend.DeleteSiteOperation(operation.SiteDomain, operation.ID)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	logger.Debugf("Purged %v operation.", operation.Type)
+	return nil
+}
+
+// CollectAuditEvents purges the command line audit events that exceed
+// the audit retention limits and returns the number of purged events.
+//
+// If the export webhook is configured, the events are posted to the webhook
+// before they are purged and are kept if the export fails
+func (r *Collector) CollectAuditEvents(ctx context.Context) (purged int, err error) {
+	cluster, policy, err := r.getPolicy()
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	if policy == nil || policy.GetAudit() == nil {
+		return 0, nil
+	}
+	limits := *policy.GetAudit()
+	events, err := r.Backend.GetAuditEvents(time.Time{})
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	now := r.Clock.Now().UTC()
+	var expired []storage.AuditEvent
+	// events are sorted from oldest to newest
+	for i := len(events) - 1; i >= 0; i-- {
+		if limits.Exceeds(events[i].Created, now, len(events)-1-i) {
+			expired = append(expired, events[i])
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	if policy.GetExportWebhookURL() != "" {
+		err = r.exportWithTimeout(ctx, policy.GetExportWebhookURL(), Export{
+			Cluster:     cluster.Domain,
+			AuditEvents: expired,
+		})
+		if err != nil {
+			return 0, trace.Wrap(err, "failed to export audit events")
+		}
+	}
+	for _, event := range expired {
+		err := r.Backend.DeleteAuditEvent(event.ID)
+		if err != nil && !trace.IsNotFound(err) {
+			return purged, trace.Wrap(err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// CollectAuditLog purges the daily files of the audit log of this node that
+// exceed the retention limits and returns the number of purged files.
+//
+// The file of the current day is never purged.
+// If the export webhook is configured, the events of every file are posted
+// to the webhook before the file is purged and the file is kept if the export fails
+func (r *Collector) CollectAuditLog(ctx context.Context) (purged int, err error) {
+	cluster, policy, err := r.getPolicy()
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	if policy == nil || policy.GetAudit() == nil {
+		return 0, nil
+	}
+	limits := *policy.GetAudit()
+	files, err := listAuditLogFiles(r.AuditLogDir)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	now := r.Clock.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for i, file := range files {
+		if !file.day.Before(today) {
+			continue
+		}
+		// the file contains the events up to the end of the day
+		if !limits.Exceeds(file.day.Add(24*time.Hour), now, i) {
+			continue
+		}
+		if err := r.purgeAuditLogFile(ctx, cluster.Domain, policy, file); err != nil {
+			return purged, trace.Wrap(err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (r *Collector) purgeAuditLogFile(ctx context.Context, clusterName string, policy storage.RetentionPolicy, file auditLogFile) error {
+	if policy.GetExportWebhookURL() != "" {
+		events, err := readAuditLogFile(file.path)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = r.exportWithTimeout(ctx, policy.GetExportWebhookURL(), Export{
+			Cluster: clusterName,
+			AuditLog: []AuditLogFile{{
+				Name:   filepath.Base(file.path),
+				Events: events,
+			}},
+		})
+		if err != nil {
+			return trace.Wrap(err, "failed to export audit log file %v", file.path)
+		}
+	}
+	if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+		return trace.ConvertSystemError(err)
+	}
+	r.WithField("file", file.path).Debug("Purged audit log file.")
+	return nil
+}
+
+// getPolicy returns the local cluster and its retention policy.
+// The returned policy is nil if the cluster does not have one
+func (r *Collector) getPolicy() (*ops.Site, storage.RetentionPolicy, error) {
+	cluster, err := r.Operator.GetLocalSite()
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	policy, err := r.Operator.GetRetentionPolicy(cluster.Key())
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return cluster, nil, nil
+		}
+		return nil, nil, trace.Wrap(err)
+	}
+	return cluster, policy, nil
+}
+
+func (r *Collector) exportWithTimeout(ctx context.Context, url string, export Export) error {
+	ctx, cancel := context.WithTimeout(ctx, defaults.RetentionExportTimeout)
+	defer cancel()
+	return r.export(ctx, url, export)
+}
+
+// Export is the batch of records posted to the export webhook before they are purged
+type Export struct {
+	// Cluster is the name of the cluster the records belong to
+	Cluster string `json:"cluster"`
+	// Operations lists the records of purged operations
+	Operations []OperationRecord `json:"operations,omitempty"`
+	// AuditLog lists the purged audit log files
+	AuditLog []AuditLogFile `json:"audit_log,omitempty"`
+	// AuditEvents lists the purged command line audit events
+	AuditEvents []storage.AuditEvent `json:"audit_events,omitempty"`
+}
+
+// OperationRecord is the record of a purged operation
+type OperationRecord struct {
+	// Operation is the operation
+	Operation storage.SiteOperation `json:"operation"`
+	// Plan is the operation plan, if the operation had one
+	Plan *storage.OperationPlan `json:"plan,omitempty"`
+	// Changelog lists the operation plan changes
+	Changelog storage.PlanChangelog `json:"changelog,omitempty"`
+}
+
+// AuditLogFile is the purged daily file of the audit log
+type AuditLogFile struct {
+	// Name is the name of the file
+	Name string `json:"name"`
+	// Events lists the audit events from the file
+	Events []json.RawMessage `json:"events"`
+}
+
+// auditLogFile describes a daily file of the audit log
+type auditLogFile struct {
+	// path is the path to the file
+	path string
+	// day is the day the file contains the events of
+	day time.Time
+}
+
+// listAuditLogFiles returns the daily files of the audit log in the specified
+// directory sorted from newest to oldest.
+// The audit log keeps the files in a subdirectory per auth server
+func listAuditLogFiles(dir string) (files []auditLogFile, err error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*"+teleevents.LogfileExt))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), teleevents.LogfileExt)
+		day, err := time.Parse(teledefaults.AuditLogTimeFormat, name)
+		if err != nil {
+			continue
+		}
+		files = append(files, auditLogFile{path: path, day: day})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].day.After(files[j].day)
+	})
+	return files, nil
+}
+
+// readAuditLogFile returns the events from the specified audit log file
+func readAuditLogFile(path string) (events []json.RawMessage, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	// events are written on a single line each
+	const maxEventSize = 1024 * 1024
+	scanner.Buffer(nil, maxEventSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		events = append(events, json.RawMessage(append([]byte(nil), line...)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return events, nil
+}
+
+func postExport(ctx context.Context, url string, export Export) error {
+	data, err := json.Marshal(export)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return trace.BadParameter("webhook %v responded with %v", url, resp.Status)
+	}
+	return nil
+}
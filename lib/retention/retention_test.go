@@ -0,0 +1,289 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retention
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"gopkg.in/check.v1"
+)
+
+func TestRetention(t *testing.T) { check.TestingT(t) }
+
+type RetentionSuite struct {
+	clock clockwork.FakeClock
+}
+
+var _ = check.Suite(&RetentionSuite{})
+
+func (s *RetentionSuite) SetUpTest(c *check.C) {
+	s.clock = clockwork.NewFakeClockAt(time.Date(2019, time.June, 10, 12, 0, 0, 0, time.UTC))
+}
+
+func (s *RetentionSuite) TestPurgesOperations(c *check.C) {
+	now := s.clock.Now()
+	backend := &testBackend{operations: []storage.SiteOperation{
+		s.newOperation("6", ops.OperationUpdate, ops.OperationStateUpdateInProgress, now.Add(-100*day)),
+		s.newOperation("5", ops.OperationExpand, ops.OperationStateCompleted, now.Add(-time.Hour)),
+		s.newOperation("4", ops.OperationShrink, ops.OperationStateFailed, now.Add(-2*day)),
+		s.newOperation("3", ops.OperationExpand, ops.OperationStateCompleted, now.Add(-3*day)),
+		s.newOperation("2", ops.OperationUpdate, ops.OperationStateCompleted, now.Add(-60*day)),
+		s.newOperation("1", ops.OperationInstall, ops.OperationStateCompleted, now.Add(-100*day)),
+	}}
+	collector := s.newCollector(c, backend, storage.RetentionPolicySpecV2{
+		Operations: &storage.RetentionLimits{
+			MaxAge:   teleservices.NewDuration(30 * day),
+			MaxCount: 2,
+		},
+	}, nil)
+
+	purged, err := collector.CollectOperations(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(purged, check.Equals, 2)
+	c.Assert(backend.operationIDs(), check.DeepEquals, []string{"6", "5", "4", "1"},
+		check.Commentf("expected operations in progress and install operation to be kept"))
+}
+
+func (s *RetentionSuite) TestExportsBeforePurge(c *check.C) {
+	backend := &testBackend{operations: []storage.SiteOperation{
+		s.newOperation("2", ops.OperationExpand, ops.OperationStateCompleted, s.clock.Now()),
+		s.newOperation("1", ops.OperationExpand, ops.OperationStateCompleted, s.clock.Now().Add(-time.Hour)),
+	}}
+	var exports []Export
+	var exportErr error
+	collector := s.newCollector(c, backend, storage.RetentionPolicySpecV2{
+		Operations:       &storage.RetentionLimits{MaxCount: 1},
+		ExportWebhookURL: "https://example.com/export",
+	}, func(ctx context.Context, url string, export Export) error {
+		c.Assert(url, check.Equals, "https://example.com/export")
+		exports = append(exports, export)
+		return exportErr
+	})
+
+	exportErr = trace.ConnectionProblem(nil, "webhook unavailable")
+	_, err := collector.CollectOperations(context.TODO())
+	c.Assert(err, check.NotNil)
+	c.Assert(backend.operationIDs(), check.DeepEquals, []string{"2", "1"},
+		check.Commentf("expected operation to be kept if export fails"))
+
+	exportErr = nil
+	exports = nil
+	purged, err := collector.CollectOperations(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(purged, check.Equals, 1)
+	c.Assert(backend.operationIDs(), check.DeepEquals, []string{"2"})
+	c.Assert(exports, check.HasLen, 1)
+	c.Assert(exports[0].Cluster, check.Equals, "example.com")
+	c.Assert(exports[0].Operations, check.HasLen, 1)
+	c.Assert(exports[0].Operations[0].Operation.ID, check.Equals, "1")
+	c.Assert(exports[0].Operations[0].Plan.OperationID, check.Equals, "1")
+	c.Assert(exports[0].Operations[0].Changelog, check.HasLen, 1)
+}
+
+func (s *RetentionSuite) TestPurgesAuditLog(c *check.C) {
+	dir := c.MkDir()
+	serverDir := filepath.Join(dir, "server-id")
+	c.Assert(os.MkdirAll(serverDir, 0755), check.IsNil)
+	for _, name := range []string{
+		"2019-06-10.00:00:00.log",
+		"2019-06-09.00:00:00.log",
+		"2019-06-08.00:00:00.log",
+		"2019-05-01.00:00:00.log",
+		"events.log",
+	} {
+		err := ioutil.WriteFile(filepath.Join(serverDir, name),
+			[]byte(`{"event":"user.login"}`+"\n"+`{"event":"exec"}`+"\n"), 0640)
+		c.Assert(err, check.IsNil)
+	}
+	var exports []Export
+	collector := s.newCollector(c, &testBackend{}, storage.RetentionPolicySpecV2{
+		Audit: &storage.RetentionLimits{
+			MaxAge:   teleservices.NewDuration(30 * day),
+			MaxCount: 2,
+		},
+		ExportWebhookURL: "https://example.com/export",
+	}, func(ctx context.Context, url string, export Export) error {
+		exports = append(exports, export)
+		return nil
+	})
+	collector.AuditLogDir = dir
+
+	purged, err := collector.CollectAuditLog(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(purged, check.Equals, 2)
+	files, err := filepath.Glob(filepath.Join(serverDir, "*"))
+	c.Assert(err, check.IsNil)
+	c.Assert(files, check.DeepEquals, []string{
+		filepath.Join(serverDir, "2019-06-09.00:00:00.log"),
+		filepath.Join(serverDir, "2019-06-10.00:00:00.log"),
+		filepath.Join(serverDir, "events.log"),
+	})
+	c.Assert(exports, check.HasLen, 2)
+	c.Assert(exports[0].AuditLog[0].Name, check.Equals, "2019-06-08.00:00:00.log")
+	c.Assert(exports[0].AuditLog[0].Events, check.HasLen, 2)
+}
+
+func (s *RetentionSuite) TestPurgesAuditEvents(c *check.C) {
+	now := s.clock.Now()
+	backend := &testBackend{events: []storage.AuditEvent{
+		{ID: "1", Command: "leave", Created: now.Add(-60 * day)},
+		{ID: "2", Command: "resource create", Created: now.Add(-3 * day)},
+		{ID: "3", Command: "resource rm", Created: now.Add(-2 * day)},
+		{ID: "4", Command: "leave", Created: now.Add(-time.Hour)},
+	}}
+	var exports []Export
+	collector := s.newCollector(c, backend, storage.RetentionPolicySpecV2{
+		Audit: &storage.RetentionLimits{
+			MaxAge:   teleservices.NewDuration(30 * day),
+			MaxCount: 2,
+		},
+		ExportWebhookURL: "https://example.com/export",
+	}, func(ctx context.Context, url string, export Export) error {
+		exports = append(exports, export)
+		return nil
+	})
+
+	purged, err := collector.CollectAuditEvents(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(purged, check.Equals, 2)
+	c.Assert(backend.eventIDs(), check.DeepEquals, []string{"3", "4"})
+	c.Assert(exports, check.HasLen, 1)
+	c.Assert(exports[0].AuditEvents, check.HasLen, 2)
+}
+
+func (s *RetentionSuite) TestIgnoresMissingPolicy(c *check.C) {
+	backend := &testBackend{operations: []storage.SiteOperation{
+		s.newOperation("1", ops.OperationExpand, ops.OperationStateCompleted, s.clock.Now().Add(-1000*day)),
+	}}
+	collector, err := New(Config{
+		Operator: &testOperator{},
+		Backend:  backend,
+		Clock:    s.clock,
+	})
+	c.Assert(err, check.IsNil)
+	purged, err := collector.CollectOperations(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(purged, check.Equals, 0)
+	c.Assert(backend.operationIDs(), check.DeepEquals, []string{"1"})
+}
+
+func (s *RetentionSuite) newCollector(c *check.C, backend Backend, spec storage.RetentionPolicySpecV2, export func(context.Context, string, Export) error) *Collector {
+	policy := storage.NewRetentionPolicy(spec)
+	c.Assert(policy.CheckAndSetDefaults(), check.IsNil)
+	collector, err := New(Config{
+		Operator: &testOperator{policy: policy},
+		Backend:  backend,
+		Clock:    s.clock,
+		export:   export,
+	})
+	c.Assert(err, check.IsNil)
+	return collector
+}
+
+func (s *RetentionSuite) newOperation(id, typ, state string, updated time.Time) storage.SiteOperation {
+	return storage.SiteOperation{
+		ID:         id,
+		SiteDomain: "example.com",
+		Type:       typ,
+		State:      state,
+		Created:    updated.Add(-time.Minute),
+		Updated:    updated,
+	}
+}
+
+type testOperator struct {
+	policy storage.RetentionPolicy
+}
+
+func (r *testOperator) GetLocalSite() (*ops.Site, error) {
+	return &ops.Site{Domain: "example.com"}, nil
+}
+
+func (r *testOperator) GetRetentionPolicy(ops.SiteKey) (storage.RetentionPolicy, error) {
+	if r.policy == nil {
+		return nil, trace.NotFound("no retention policy found")
+	}
+	return r.policy, nil
+}
+
+type testBackend struct {
+	operations []storage.SiteOperation
+	events     []storage.AuditEvent
+}
+
+func (r *testBackend) GetSiteOperations(siteDomain string) ([]storage.SiteOperation, error) {
+	return append([]storage.SiteOperation(nil), r.operations...), nil
+}
+
+func (r *testBackend) GetOperationPlan(clusterName, operationID string) (*storage.OperationPlan, error) {
+	return &storage.OperationPlan{OperationID: operationID, ClusterName: clusterName}, nil
+}
+
+func (r *testBackend) GetOperationPlanChangelog(clusterName, operationID string) (storage.PlanChangelog, error) {
+	return storage.PlanChangelog{{OperationID: operationID, ClusterName: clusterName}}, nil
+}
+
+func (r *testBackend) DeleteSiteOperation(siteDomain, operationID string) error {
+	for i, op := range r.operations {
+		if op.ID == operationID {
+			r.operations = append(r.operations[:i], r.operations[i+1:]...)
+			return nil
+		}
+	}
+	return trace.NotFound("operation %v not found", operationID)
+}
+
+func (r *testBackend) GetAuditEvents(since time.Time) ([]storage.AuditEvent, error) {
+	return append([]storage.AuditEvent(nil), r.events...), nil
+}
+
+func (r *testBackend) DeleteAuditEvent(id string) error {
+	for i, event := range r.events {
+		if event.ID == id {
+			r.events = append(r.events[:i], r.events[i+1:]...)
+			return nil
+		}
+	}
+	return trace.NotFound("audit event %v not found", id)
+}
+
+func (r *testBackend) eventIDs() (ids []string) {
+	for _, event := range r.events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func (r *testBackend) operationIDs() (ids []string) {
+	for _, op := range r.operations {
+		ids = append(ids, op.ID)
+	}
+	return ids
+}
+
+const day = 24 * time.Hour
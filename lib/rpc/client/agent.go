@@ -94,13 +94,26 @@ func (c *client) command(ctx context.Context, log logrus.FieldLogger, w io.Write
 	return trace.Wrap(err)
 }
 
+// ExitCode returns the exit code of the remote command that failed with err.
+// Returns false if the exit code is unknown
+func ExitCode(err error) (int, bool) {
+	traceErr, ok := err.(trace.Error)
+	if !ok {
+		return 0, false
+	}
+	exitCode, ok := traceErr.GetFields()[exitCodeField].(int)
+	return exitCode, ok
+}
+
 type streamContext struct {
 	commands map[int32][]string
 	log      logrus.FieldLogger
+	// exitCode is the exit code of the last failed command
+	exitCode int32
 }
 
 func processStream(stream pb.IncomingMessageStream, log logrus.FieldLogger, out io.Writer) error {
-	streamCtx := &streamContext{map[int32][]string{}, log}
+	streamCtx := &streamContext{commands: map[int32][]string{}, log: log}
 	if out == nil {
 		out = ioutil.Discard
 	}
@@ -111,7 +124,11 @@ func processStream(stream pb.IncomingMessageStream, log logrus.FieldLogger, out
 			return nil
 		}
 		if err != nil {
-			return trace.Wrap(err)
+			traceErr := trace.Wrap(err)
+			if streamCtx.exitCode > 0 {
+				traceErr.AddField(exitCodeField, int(streamCtx.exitCode))
+			}
+			return traceErr
 		}
 
 		switch elem := msg.Element.(type) {
@@ -171,6 +188,9 @@ func (s *streamContext) processExecCompleted(msg *pb.ExecCompleted) error {
 		"seq":  msg.Seq,
 		"exit": msg.ExitCode,
 	}).Debug("Completed.")
+	if msg.ExitCode != 0 {
+		s.exitCode = msg.ExitCode
+	}
 	return nil
 }
 
@@ -205,3 +225,6 @@ func (s *streamContext) processError(msg *pb.Error) error {
 	s.log.Error(msg.Message)
 	return nil
 }
+
+// exitCodeField is the error field with the exit code of the failed remote command
+const exitCodeField = "exit_code"
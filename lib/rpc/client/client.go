@@ -19,6 +19,7 @@ package client
 import (
 	"context"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/gravitational/gravity/lib/defaults"
@@ -59,6 +60,8 @@ type Client interface {
 	Shutdown(context.Context, *pb.ShutdownRequest) error
 	// Abort requests remote agent to uninstall
 	Abort(context.Context) error
+	// ProtocolVersion returns the version of the RPC protocol spoken by the remote agent
+	ProtocolVersion(context.Context) (pb.ProtocolVersion, error)
 	// Close will close communication with remote agent
 	Close() error
 }
@@ -115,4 +118,9 @@ type client struct {
 	discovery  pb.DiscoveryClient
 	validation validationpb.ValidationClient
 	conn       *grpc.ClientConn
+
+	// mu guards version
+	mu sync.Mutex
+	// version is the negotiated protocol version of the remote agent
+	version pb.ProtocolVersion
 }
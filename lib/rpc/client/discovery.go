@@ -52,8 +52,18 @@ func (c *client) GetRuntimeConfig(ctx context.Context) (*pb.RuntimeConfig, error
 	return config, nil
 }
 
-// GetCurrentTime returns agent's current time as UTC timestamp
+// GetCurrentTime returns agent's current time as UTC timestamp.
+// For agents that do not implement the clock query API, the time
+// is queried with a remote command
 func (c *client) GetCurrentTime(ctx context.Context) (*time.Time, error) {
+	version, err := c.ProtocolVersion(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !version.SupportsCurrentTime() {
+		return c.currentTimeShim(ctx)
+	}
+
 	proto, err := c.discovery.GetCurrentTime(ctx, &types.Empty{})
 	if err != nil {
 		return nil, trace.Wrap(err)
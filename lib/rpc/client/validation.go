@@ -42,7 +42,15 @@ func (c *client) CheckBandwidth(ctx context.Context, req *validationpb.CheckBand
 }
 
 // CheckDisks executes disk performance test.
+// Returns NotImplemented error if the remote agent does not support disk checks
 func (c *client) CheckDisks(ctx context.Context, req *validationpb.CheckDisksRequest) (*validationpb.CheckDisksResponse, error) {
+	version, err := c.ProtocolVersion(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !version.SupportsDiskChecks() {
+		return nil, trace.NotImplemented("agent with protocol version %v does not support disk checks", version)
+	}
 	resp, err := c.validation.CheckDisks(ctx, req)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	validationpb "github.com/gravitational/gravity/lib/network/validation/proto"
+	pb "github.com/gravitational/gravity/lib/rpc/proto"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ProtocolVersion returns the version of the RPC protocol spoken by the remote agent.
+// Agents that predate explicit version negotiation are classified by probing
+// for the APIs they implement.
+// The result is cached for the lifetime of the client once the version
+// has been determined
+func (c *client) ProtocolVersion(ctx context.Context) (pb.ProtocolVersion, error) {
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+	if version != pb.ProtocolVersionUnknown {
+		return version, nil
+	}
+	// The lock is not held during the negotiation so an unresponsive agent
+	// does not block the other callers until their contexts expire.
+	// Concurrent callers might negotiate the version more than once
+	version, err := c.negotiate(ctx)
+	if err != nil {
+		return pb.ProtocolVersionUnknown, trace.Wrap(err)
+	}
+	c.mu.Lock()
+	c.version = version
+	c.mu.Unlock()
+	return version, nil
+}
+
+func (c *client) negotiate(ctx context.Context) (pb.ProtocolVersion, error) {
+	var md metadata.MD
+	_, err := c.discovery.GetRuntimeConfig(ctx, &types.Empty{}, grpc.Header(&md))
+	if err != nil {
+		return pb.ProtocolVersionUnknown, trace.Wrap(err)
+	}
+	version, err := pb.ProtocolVersionFromMetadata(md)
+	if err == nil {
+		return version, nil
+	}
+	if !trace.IsNotFound(err) {
+		return pb.ProtocolVersionUnknown, trace.Wrap(err)
+	}
+	// Agents before 6.0 do not advertise the protocol version.
+	// Tell them apart by probing for the disk check API: the empty request
+	// does not run any tests
+	_, err = c.validation.CheckDisks(ctx, &validationpb.CheckDisksRequest{})
+	if err == nil {
+		return pb.ProtocolVersion55, nil
+	}
+	if pb.IsUnimplementedError(err) {
+		return pb.ProtocolVersion52, nil
+	}
+	if isTransportError(ctx, err) {
+		return pb.ProtocolVersionUnknown, trace.Wrap(err, "failed to determine agent protocol version")
+	}
+	// The agent implements the API but rejected the request, e.g. 5.5 agents
+	// validate the request and fail with NotFound/BadParameter for an empty one
+	logrus.WithError(err).Debug("Disk check probe failed, assume 5.5 agent.")
+	return pb.ProtocolVersion55, nil
+}
+
+// isTransportError returns true if the specified error means the request
+// has not reached the agent or has been interrupted, as opposed to
+// the error returned by the agent
+func isTransportError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	s, ok := status.FromError(trace.Unwrap(err))
+	if !ok {
+		// Not a gRPC status: the request has not been completed
+		return true
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return true
+	}
+	return false
+}
+
+// currentTimeShim queries the remote time on agents that do not implement
+// the clock query API by running date(1) on the node
+func (c *client) currentTimeShim(ctx context.Context) (*time.Time, error) {
+	var out bytes.Buffer
+	err := c.Command(ctx, logrus.WithField(trace.Component, "rpc"), &out, "date", "-u", "+%s%N")
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	nanos, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return nil, trace.BadParameter("unexpected output from date: %q", out.String())
+	}
+	ts := time.Unix(0, nanos).UTC()
+	return &ts, nil
+}
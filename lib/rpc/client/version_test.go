@@ -0,0 +1,186 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	validationpb "github.com/gravitational/gravity/lib/network/validation/proto"
+	pb "github.com/gravitational/gravity/lib/rpc/proto"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/gravitational/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/check.v1"
+)
+
+func TestClient(t *testing.T) { check.TestingT(t) }
+
+type VersionSuite struct{}
+
+var _ = check.Suite(&VersionSuite{})
+
+func (s *VersionSuite) TestShimsAgent52(c *check.C) {
+	validation := &testValidation{errors: []error{status.Error(codes.Unimplemented, "unknown method")}}
+	discovery := &testDiscovery{}
+	clt := newTestClient(discovery, validation, &testAgent{output: "1571184000000000000\n"})
+
+	version, err := clt.ProtocolVersion(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(version, check.Equals, pb.ProtocolVersion52)
+
+	ts, err := clt.GetCurrentTime(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(*ts, check.Equals, time.Unix(0, 1571184000000000000).UTC())
+	c.Assert(discovery.currentTimeCalls, check.Equals, 0)
+
+	_, err = clt.CheckDisks(context.TODO(), &validationpb.CheckDisksRequest{})
+	c.Assert(trace.IsNotImplemented(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(validation.calls, check.Equals, 1)
+}
+
+func (s *VersionSuite) TestShimsAgent55(c *check.C) {
+	validation := &testValidation{}
+	discovery := &testDiscovery{}
+	clt := newTestClient(discovery, validation, &testAgent{output: "1571184000000000000\n"})
+
+	version, err := clt.ProtocolVersion(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(version, check.Equals, pb.ProtocolVersion55)
+
+	ts, err := clt.GetCurrentTime(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(*ts, check.Equals, time.Unix(0, 1571184000000000000).UTC())
+	c.Assert(discovery.currentTimeCalls, check.Equals, 0)
+
+	_, err = clt.CheckDisks(context.TODO(), &validationpb.CheckDisksRequest{})
+	c.Assert(err, check.IsNil)
+	c.Assert(validation.calls, check.Equals, 2)
+}
+
+func (s *VersionSuite) TestDoesNotCacheFailedNegotiation(c *check.C) {
+	validation := &testValidation{errors: []error{status.Error(codes.Unavailable, "connection reset")}}
+	clt := newTestClient(&testDiscovery{}, validation, &testAgent{})
+
+	_, err := clt.ProtocolVersion(context.TODO())
+	c.Assert(err, check.NotNil)
+
+	version, err := clt.ProtocolVersion(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(version, check.Equals, pb.ProtocolVersion55)
+	c.Assert(validation.calls, check.Equals, 2)
+
+	// The negotiated version is cached
+	_, err = clt.ProtocolVersion(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Assert(validation.calls, check.Equals, 2)
+}
+
+func (s *VersionSuite) TestProbeApplicationErrorMeansAgent55(c *check.C) {
+	for _, code := range []codes.Code{codes.NotFound, codes.InvalidArgument, codes.Unknown} {
+		validation := &testValidation{errors: []error{status.Error(code, "rejected request")}}
+		clt := newTestClient(&testDiscovery{}, validation, &testAgent{})
+
+		version, err := clt.ProtocolVersion(context.TODO())
+		comment := check.Commentf("%v", code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(version, check.Equals, pb.ProtocolVersion55, comment)
+	}
+}
+
+func (s *VersionSuite) TestFailsNegotiationOnTransportErrors(c *check.C) {
+	for _, code := range []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.Canceled} {
+		validation := &testValidation{errors: []error{status.Error(code, "transport failure")}}
+		clt := newTestClient(&testDiscovery{}, validation, &testAgent{})
+
+		_, err := clt.ProtocolVersion(context.TODO())
+		c.Assert(err, check.NotNil, check.Commentf("%v", code))
+	}
+}
+
+func newTestClient(discovery pb.DiscoveryClient, validation validationpb.ValidationClient, agent pb.AgentClient) *client {
+	return &client{
+		agent:      agent,
+		discovery:  discovery,
+		validation: validation,
+	}
+}
+
+// testDiscovery implements the discovery API of an agent
+// that does not advertise the protocol version
+type testDiscovery struct {
+	pb.DiscoveryClient
+	currentTimeCalls int
+}
+
+func (r *testDiscovery) GetRuntimeConfig(context.Context, *types.Empty, ...grpc.CallOption) (*pb.RuntimeConfig, error) {
+	return &pb.RuntimeConfig{}, nil
+}
+
+func (r *testDiscovery) GetCurrentTime(context.Context, *types.Empty, ...grpc.CallOption) (*types.Timestamp, error) {
+	r.currentTimeCalls++
+	return types.TimestampNow(), nil
+}
+
+// testValidation implements the disk check API.
+// The calls fail with the specified errors in order
+type testValidation struct {
+	validationpb.ValidationClient
+	errors []error
+	calls  int
+}
+
+func (r *testValidation) CheckDisks(context.Context, *validationpb.CheckDisksRequest, ...grpc.CallOption) (*validationpb.CheckDisksResponse, error) {
+	r.calls++
+	if len(r.errors) != 0 {
+		err := r.errors[0]
+		r.errors = r.errors[1:]
+		return nil, err
+	}
+	return &validationpb.CheckDisksResponse{}, nil
+}
+
+// testAgent implements the command API by replying with the specified output
+type testAgent struct {
+	pb.AgentClient
+	output string
+}
+
+func (r *testAgent) Command(context.Context, *pb.CommandArgs, ...grpc.CallOption) (pb.Agent_CommandClient, error) {
+	return &testCommandStream{messages: []*pb.Message{
+		{Element: &pb.Message_ExecOutput{ExecOutput: &pb.ExecOutput{Data: []byte(r.output)}}},
+	}}, nil
+}
+
+type testCommandStream struct {
+	grpc.ClientStream
+	messages []*pb.Message
+}
+
+func (r *testCommandStream) Recv() (*pb.Message, error) {
+	if len(r.messages) == 0 {
+		return nil, io.EOF
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
@@ -213,7 +213,10 @@ func deployAgentOnNode(ctx context.Context, req DeployAgentsRequest, node, nodeS
 		WithRetries("%s enter -- --notty %s -- package unpack %s %s --debug --ops-url=%s --insecure",
 			constants.GravityBin, defaults.GravityBin, secretsPackage, secretsPlanetDir, defaults.GravityServiceURL).
 		IgnoreError("/usr/bin/systemctl stop %s", defaults.GravityRPCAgentServiceName).
-		WithRetries("%s enter -- --notty %s -- package export --file-mask=%o %s %s --ops-url=%s --insecure",
+		// Only transfer the binary if it has changed and only the changed parts of it.
+		// Fall back to the full export if the installed binary does not support delta transfers
+		WithRetries("%[1]s enter -- --notty %[2]s -- package export --delta --file-mask=%[3]o %[4]s %[5]s --ops-url=%[6]s --insecure || "+
+			"%[1]s enter -- --notty %[2]s -- package export --file-mask=%[3]o %[4]s %[5]s --ops-url=%[6]s --insecure",
 			constants.GravityBin, defaults.GravityBin, defaults.SharedExecutableMask,
 			req.GravityPackage, gravityPlanetPath, defaults.GravityServiceURL).
 		C(runCmd).
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proto
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gravitational/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ProtocolVersion identifies a revision of the agent RPC protocol
+type ProtocolVersion int
+
+const (
+	// ProtocolVersionUnknown is used when the version of the remote agent
+	// could not be determined
+	ProtocolVersionUnknown ProtocolVersion = 0
+	// ProtocolVersion52 is the protocol spoken by agents of the 5.2 LTS line.
+	// These agents support neither disk performance checks nor
+	// clock queries
+	ProtocolVersion52 ProtocolVersion = 1
+	// ProtocolVersion55 is the protocol spoken by agents of the 5.5 LTS line.
+	// These agents support disk performance checks but not clock queries
+	ProtocolVersion55 ProtocolVersion = 2
+	// ProtocolVersion60 is the first protocol version that is advertised
+	// by agents explicitly
+	ProtocolVersion60 ProtocolVersion = 3
+	// ProtocolVersionCurrent is the protocol version of this binary
+	ProtocolVersionCurrent = ProtocolVersion60
+)
+
+// ProtocolVersionMetadataKey is the name of the gRPC header the agent uses
+// to advertise its protocol version
+const ProtocolVersionMetadataKey = "gravity-agent-protocol"
+
+// String returns the textual representation of this version
+func (r ProtocolVersion) String() string {
+	switch r {
+	case ProtocolVersion52:
+		return "5.2"
+	case ProtocolVersion55:
+		return "5.5"
+	case ProtocolVersion60:
+		return "6.0"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
+}
+
+// SupportsDiskChecks returns true if the agents with this protocol version
+// can execute disk performance checks
+func (r ProtocolVersion) SupportsDiskChecks() bool {
+	return r >= ProtocolVersion55
+}
+
+// SupportsCurrentTime returns true if the agents with this protocol version
+// implement the clock query API
+func (r ProtocolVersion) SupportsCurrentTime() bool {
+	return r >= ProtocolVersion60
+}
+
+// ProtocolVersionMetadata returns the gRPC metadata advertising
+// the current protocol version
+func ProtocolVersionMetadata() metadata.MD {
+	return metadata.Pairs(ProtocolVersionMetadataKey,
+		strconv.Itoa(int(ProtocolVersionCurrent)))
+}
+
+// ProtocolVersionFromMetadata extracts the protocol version from the specified
+// gRPC metadata.
+// Returns a NotFound error if the metadata does not advertise a version
+func ProtocolVersionFromMetadata(md metadata.MD) (ProtocolVersion, error) {
+	values := md.Get(ProtocolVersionMetadataKey)
+	if len(values) == 0 {
+		return ProtocolVersionUnknown, trace.NotFound("no protocol version advertised")
+	}
+	version, err := strconv.Atoi(values[0])
+	if err != nil {
+		return ProtocolVersionUnknown, trace.BadParameter("invalid protocol version %q", values[0])
+	}
+	return ProtocolVersion(version), nil
+}
+
+// IsUnimplementedError returns true if the specified error indicates
+// that the remote agent does not implement the API
+func IsUnimplementedError(err error) bool {
+	s, ok := status.FromError(trace.Unwrap(err))
+	return ok && s.Code() == codes.Unimplemented
+}
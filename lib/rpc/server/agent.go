@@ -23,11 +23,14 @@ import (
 	pb "github.com/gravitational/gravity/lib/rpc/proto"
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/gogo/protobuf/types"
 	"github.com/gravitational/trace"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Command executes the command given with req and streams the output of the command as a result
@@ -61,6 +64,11 @@ func (srv *agentServer) PeerJoin(ctx context.Context, req *pb.PeerJoinRequest) (
 		creds:            srv.Config.Client,
 		reconnectTimeout: srv.Config.ReconnectTimeout,
 	})
+	if utils.IsLicenseError(err) {
+		// Send license errors with a dedicated status code so the peer
+		// can stop reconnecting without inspecting the error message
+		return nil, status.Error(codes.PermissionDenied, trace.UserMessage(err))
+	}
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -291,6 +291,10 @@ func (r errorPeer) Abort(context.Context) error {
 	return trace.Wrap(r.error)
 }
 
+func (r errorPeer) ProtocolVersion(context.Context) (pb.ProtocolVersion, error) {
+	return pb.ProtocolVersionUnknown, trace.Wrap(r.error)
+}
+
 func (r errorPeer) Close() error {
 	return trace.Wrap(r.error)
 }
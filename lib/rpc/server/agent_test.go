@@ -320,6 +320,37 @@ func (r *S) TestQueriesSystemInfo(c *C) {
 	compare.DeepCompare(c, obtained, sysinfo)
 }
 
+func (r *S) TestNegotiatesProtocolVersion(c *C) {
+	creds := TestCredentials(c)
+	log := r.WithField("test", "NegotiatesProtocolVersion")
+	listener := listen(c)
+	srv, err := New(Config{
+		FieldLogger: log.WithField("server", listener.Addr()),
+		Listener:    listener,
+		Credentials: creds,
+	})
+	c.Assert(err, IsNil)
+
+	go srv.Serve()
+	defer withTestCtx(srv.Stop)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
+	defer cancel()
+	clt, err := client.New(ctx,
+		client.Config{
+			ServerAddr:  srv.Addr().String(),
+			Credentials: creds.Client,
+		})
+	c.Assert(err, IsNil)
+
+	version, err := clt.ProtocolVersion(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(version, Equals, pb.ProtocolVersionCurrent)
+
+	_, err = clt.GetCurrentTime(ctx)
+	c.Assert(err, IsNil)
+}
+
 func (r *S) clientExecutesCommandsWithClient(c *C, clt client.Client, srv *agentServer, expectedOutput string) {
 	defer withTestCtx(srv.Stop)
 
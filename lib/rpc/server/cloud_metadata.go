@@ -22,6 +22,8 @@ import (
 	"github.com/gravitational/gravity/lib/schema"
 
 	gcemeta "cloud.google.com/go/compute/metadata"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gravitational/trace"
 )
 
@@ -36,6 +38,26 @@ func GetCloudMetadata(provider string) (*pb.CloudMetadata, error) {
 	return nil, trace.BadParameter("unsupported cloud provider %q", provider)
 }
 
+// GetAvailabilityZone returns the availability zone of this instance
+// for the specified cloud provider
+func GetAvailabilityZone(provider string) (string, error) {
+	switch provider {
+	case schema.ProviderAWS:
+		zone, err := ec2metadata.New(session.New()).GetMetadata("placement/availability-zone")
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		return zone, nil
+	case schema.ProviderGCE:
+		zone, err := gcemeta.Zone()
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		return zone, nil
+	}
+	return "", trace.BadParameter("unsupported cloud provider %q", provider)
+}
+
 func getAWSMetadata() (*pb.CloudMetadata, error) {
 	instance, err := aws.NewLocalInstance()
 	if err != nil {
@@ -423,6 +423,18 @@ func (r *peer) GetCurrentTime(ctx context.Context) (*time.Time, error) {
 	return ts, nil
 }
 
+// ProtocolVersion returns the version of the RPC protocol spoken by this peer
+func (r *peer) ProtocolVersion(ctx context.Context) (pb.ProtocolVersion, error) {
+	if r.Client == nil {
+		return pb.ProtocolVersionUnknown, trace.ConnectionProblem(nil, "%v not connected", r.Addr())
+	}
+	version, err := r.Client.Client().ProtocolVersion(ctx)
+	if err != nil {
+		return pb.ProtocolVersionUnknown, trace.Wrap(err)
+	}
+	return version, nil
+}
+
 // Shutdown shuts down this peer
 func (r *peer) Shutdown(ctx context.Context, req *pb.ShutdownRequest) error {
 	if r.Client == nil {
@@ -60,6 +60,8 @@ func New(config Config) (*agentServer, error) {
 
 	opts := append([]grpc.ServerOption{},
 		grpc.Creds(config.Credentials.Server),
+		grpc.UnaryInterceptor(versionUnaryInterceptor),
+		grpc.StreamInterceptor(versionStreamInterceptor),
 	)
 
 	ctx, cancel := context.WithCancel(context.TODO())
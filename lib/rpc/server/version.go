@@ -0,0 +1,39 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+
+	pb "github.com/gravitational/gravity/lib/rpc/proto"
+
+	"google.golang.org/grpc"
+)
+
+// versionUnaryInterceptor advertises the agent's protocol version
+// with every unary call
+func versionUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	grpc.SetHeader(ctx, pb.ProtocolVersionMetadata())
+	return handler(ctx, req)
+}
+
+// versionStreamInterceptor advertises the agent's protocol version
+// with every streaming call
+func versionStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	stream.SetHeader(pb.ProtocolVersionMetadata())
+	return handler(srv, stream)
+}
@@ -82,6 +82,9 @@ func ValidateKubelet(profile NodeProfile, manifest Manifest) (failed []*pb.Probe
 		DefaultKernelModuleChecker,
 		monitoring.NewCGroupChecker("cpu", "cpuacct", "cpuset", "memory"),
 	)
+	if modules := manifest.CNIRequirements().KernelModules; len(modules) != 0 {
+		checkers = append(checkers, monitoring.NewKernelModuleChecker(modules...))
+	}
 	checker := monitoring.NewCompositeChecker("kubelet", checkers)
 
 	var probes health.Probes
@@ -0,0 +1,225 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/satellite/agent/health"
+	pb "github.com/gravitational/satellite/agent/proto/agentpb"
+	"github.com/gravitational/satellite/monitoring"
+	"github.com/gravitational/trace"
+)
+
+const (
+	// CNIFlannel is the flannel network plugin built into the runtime
+	CNIFlannel = "flannel"
+	// CNICalico is the calico network plugin
+	CNICalico = "calico"
+	// CNICilium is the cilium network plugin
+	CNICilium = "cilium"
+)
+
+// CNIPlugins lists the supported container network plugins
+var CNIPlugins = []string{CNIFlannel, CNICalico, CNICilium}
+
+// CNIRequirements describes the host requirements of a network plugin
+type CNIRequirements struct {
+	// KernelModules lists the kernel modules the plugin depends on
+	KernelModules []monitoring.ModuleRequest
+	// TCP lists the TCP ports the plugin listens on
+	TCP []int
+	// UDP lists the UDP ports the plugin listens on
+	UDP []int
+}
+
+// cniRequirements maps network plugins to their host requirements.
+// Flannel requirements are covered by the default checks
+var cniRequirements = map[string]CNIRequirements{
+	CNICalico: {
+		KernelModules: []monitoring.ModuleRequest{
+			moduleName("ip_set"),
+			moduleName("ipip"),
+			moduleName("xt_set"),
+		},
+		// BGP
+		TCP: []int{179},
+	},
+	CNICilium: {
+		KernelModules: []monitoring.ModuleRequest{
+			moduleName("vxlan"),
+		},
+		// health checks
+		TCP: []int{4240},
+		// VXLAN overlay
+		UDP: []int{8472},
+	},
+}
+
+// CNI returns the network plugin the manifest has been configured with
+// or an empty string if the plugin is not configured
+func (m Manifest) CNI() string {
+	if m.SystemOptions == nil || m.SystemOptions.CNI == nil {
+		return ""
+	}
+	return m.SystemOptions.CNI.Default
+}
+
+// WithCNI returns a copy of this manifest configured with the specified
+// network plugin so the plugin requirements are validated along with the manifest
+func (m Manifest) WithCNI(name string) Manifest {
+	options := &SystemOptions{}
+	if m.SystemOptions != nil {
+		options = m.SystemOptions.DeepCopy()
+	}
+	cni := CNI{}
+	if options.CNI != nil {
+		cni = *options.CNI
+	}
+	cni.Default = name
+	options.CNI = &cni
+	m.SystemOptions = options
+	return m
+}
+
+// SelectCNI validates the network plugin selected during install against
+// this manifest and returns the plugin to install the cluster with.
+// Returns the manifest default if no plugin has been selected
+func (m Manifest) SelectCNI(name string) (string, error) {
+	if name == "" {
+		name = m.CNI()
+	}
+	if name == "" {
+		return "", nil
+	}
+	if !utils.StringInSlice(CNIPlugins, name) {
+		return "", trace.BadParameter("unsupported network plugin %q, supported are: %v",
+			name, CNIPlugins)
+	}
+	if _, err := m.NetworkApp(name, loc.Locator{}); err != nil {
+		return "", trace.Wrap(err)
+	}
+	return name, nil
+}
+
+// NetworkApp returns the application that installs the overlay network with
+// its networkInstall hook for the cluster with the specified network plugin.
+// app is the application this manifest belongs to and is returned for clusters
+// without an explicit network plugin if the application defines the hook itself.
+// Returns nil if the overlay network is provided by flannel built into the runtime
+func (m Manifest) NetworkApp(cni string, app loc.Locator) (*loc.Locator, error) {
+	switch cni {
+	case "":
+		if m.Hooks != nil && m.Hooks.NetworkInstall != nil {
+			return &app, nil
+		}
+		return nil, nil
+	case CNIFlannel:
+		return nil, nil
+	}
+	plugin := m.cniPlugin(cni)
+	if plugin == nil {
+		return nil, trace.NotFound("application does not provide %v network plugin", cni)
+	}
+	locator, err := m.Dependencies.ByName(plugin.App)
+	if err != nil {
+		return nil, trace.Wrap(err, "network application %v of %v plugin is not a dependency",
+			plugin.App, cni)
+	}
+	return locator, nil
+}
+
+// HasNetworkApp returns true if the overlay network of the cluster with
+// the specified network plugin is installed by a networkInstall hook
+// instead of flannel built into the runtime
+func (m Manifest) HasNetworkApp(cni string) bool {
+	switch cni {
+	case "":
+		return m.Hooks != nil && m.Hooks.NetworkInstall != nil
+	case CNIFlannel:
+		return false
+	}
+	return m.cniPlugin(cni) != nil
+}
+
+// IsNetworkApp returns true if the application with the specified name
+// installs one of the network plugins configured in this manifest.
+// Network applications are installed by the networkInstall hook only
+func (m Manifest) IsNetworkApp(name string) bool {
+	return m.networkAppPlugin(name) != nil
+}
+
+// CNIRequirements returns the host requirements of the network plugin
+// this manifest has been configured with
+func (m Manifest) CNIRequirements() CNIRequirements {
+	return cniRequirements[m.CNI()]
+}
+
+// ValidateCNI validates that the ports required by the network plugin
+// this manifest has been configured with are available
+func ValidateCNI(manifest Manifest) (failed []*pb.Probe) {
+	reqs := manifest.CNIRequirements()
+	var portRanges []monitoring.PortRange
+	for _, port := range reqs.TCP {
+		portRanges = append(portRanges, cniPortRange("tcp", port, manifest.CNI()))
+	}
+	for _, port := range reqs.UDP {
+		portRanges = append(portRanges, cniPortRange("udp", port, manifest.CNI()))
+	}
+	if len(portRanges) == 0 {
+		return nil
+	}
+	var probes health.Probes
+	monitoring.NewPortChecker(portRanges...).Check(context.TODO(), &probes)
+	return probes.GetFailed()
+}
+
+func cniPortRange(proto string, port int, cni string) monitoring.PortRange {
+	return monitoring.PortRange{
+		Protocol:    proto,
+		From:        uint64(port),
+		To:          uint64(port),
+		Description: cni,
+	}
+}
+
+func (m Manifest) cniPlugin(name string) *CNIPlugin {
+	if m.SystemOptions == nil || m.SystemOptions.CNI == nil {
+		return nil
+	}
+	for i, plugin := range m.SystemOptions.CNI.Plugins {
+		if plugin.Name == name {
+			return &m.SystemOptions.CNI.Plugins[i]
+		}
+	}
+	return nil
+}
+
+func (m Manifest) networkAppPlugin(app string) *CNIPlugin {
+	if m.SystemOptions == nil || m.SystemOptions.CNI == nil {
+		return nil
+	}
+	for i, plugin := range m.SystemOptions.CNI.Plugins {
+		if plugin.App == app {
+			return &m.SystemOptions.CNI.Plugins[i]
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ limitations under the License.
 package schema
 
 import (
+	utils "github.com/gravitational/gravity/lib/utils"
 	v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -64,6 +65,43 @@ func (in *Azure) DeepCopy() *Azure {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CNI) DeepCopyInto(out *CNI) {
+	*out = *in
+	if in.Plugins != nil {
+		in, out := &in.Plugins, &out.Plugins
+		*out = make([]CNIPlugin, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CNI.
+func (in *CNI) DeepCopy() *CNI {
+	if in == nil {
+		return nil
+	}
+	out := new(CNI)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CNIPlugin) DeepCopyInto(out *CNIPlugin) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CNIPlugin.
+func (in *CNIPlugin) DeepCopy() *CNIPlugin {
+	if in == nil {
+		return nil
+	}
+	out := new(CNIPlugin)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CPU) DeepCopyInto(out *CPU) {
 	*out = *in
@@ -80,6 +118,27 @@ func (in *CPU) DeepCopy() *CPU {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Component) DeepCopyInto(out *Component) {
+	*out = *in
+	if in.Apps != nil {
+		in, out := &in.Apps, &out.Apps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Component.
+func (in *Component) DeepCopy() *Component {
+	if in == nil {
+		return nil
+	}
+	out := new(Component)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigurationExtension) DeepCopyInto(out *ConfigurationExtension) {
 	*out = *in
@@ -863,6 +922,13 @@ func (in *Manifest) DeepCopyInto(out *Manifest) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make(Components, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -940,6 +1006,27 @@ func (in *Network) DeepCopy() *Network {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicy) DeepCopyInto(out *NetworkPolicy) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicy.
+func (in *NetworkPolicy) DeepCopy() *NetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Networking) DeepCopyInto(out *Networking) {
 	*out = *in
@@ -1192,6 +1279,33 @@ func (in *SystemOptions) DeepCopyInto(out *SystemOptions) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(NetworkPolicy)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.CNI != nil {
+		in, out := &in.CNI, &out.CNI
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(CNI)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.AdvertiseAddress != nil {
+		in, out := &in.AdvertiseAddress, &out.AdvertiseAddress
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(utils.AdvertiseAddrPolicy)
+			**out = **in
+		}
+	}
 	return
 }
 
@@ -73,6 +73,13 @@ type Manifest struct {
 	Extensions *Extensions `json:"extensions,omitempty"`
 	// WebConfig allows to specify config.js used by UI to customize installer
 	WebConfig string `json:"webConfig,omitempty"`
+	// Components lists optional components that can be included or
+	// excluded at install time
+	Components Components `json:"components,omitempty"`
+	// DefaultResources is a YAML stream of Gravity resources (log forwarders,
+	// alert targets, auth gateway, persistent storage) created when the cluster
+	// is installed unless overridden with the resources provided during install
+	DefaultResources string `json:"defaultResources,omitempty"`
 }
 
 // BaseImage defines a base image type which is basically a locator with
@@ -233,6 +240,31 @@ func (m Manifest) SystemDocker() Docker {
 	return dockerConfigWithDefaults(m.SystemOptions.DockerConfig())
 }
 
+// AdvertiseAddrPolicy returns the policy to select the advertise address
+// of a node with the specified profile.
+// The policy of the node profile takes precedence over the global one.
+// Returns nil if no policy has been configured
+func (m Manifest) AdvertiseAddrPolicy(profileName string) *utils.AdvertiseAddrPolicy {
+	if profile, err := m.NodeProfiles.ByName(profileName); err == nil {
+		if profile.SystemOptions != nil && profile.SystemOptions.AdvertiseAddress != nil {
+			return profile.SystemOptions.AdvertiseAddress
+		}
+	}
+	if m.SystemOptions == nil {
+		return nil
+	}
+	return m.SystemOptions.AdvertiseAddress
+}
+
+// NetworkPolicy returns the baseline network policy configuration
+// or nil, if the baseline network policies are not enabled
+func (m Manifest) NetworkPolicy() *NetworkPolicy {
+	if m.SystemOptions == nil || m.SystemOptions.NetworkPolicy == nil || !m.SystemOptions.NetworkPolicy.DefaultDeny {
+		return nil
+	}
+	return m.SystemOptions.NetworkPolicy
+}
+
 // DescribeKind returns a human-friendly short description of the manifest kind.
 func (m Manifest) DescribeKind() string {
 	switch m.Kind {
@@ -491,6 +523,75 @@ func (d *Dependency) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Components is a list of optional application components
+type Components []Component
+
+// ByName returns a component by its name
+func (r Components) ByName(name string) (*Component, error) {
+	for _, component := range r {
+		if component.Name == name {
+			return &component, nil
+		}
+	}
+	return nil, trace.NotFound("component %q is not defined in the manifest", name)
+}
+
+// Names returns names of all components
+func (r Components) Names() (names []string) {
+	for _, component := range r {
+		names = append(names, component.Name)
+	}
+	return names
+}
+
+// Disabled returns names of the components to exclude from installation
+// given the lists of components explicitly included and excluded.
+// Components not mentioned in either list keep their default state
+func (r Components) Disabled(with, without []string) (disabled []string, err error) {
+	for _, name := range append(append([]string{}, with...), without...) {
+		if _, err := r.ByName(name); err != nil {
+			return nil, trace.BadParameter("unknown component %q, available components: %v",
+				name, strings.Join(r.Names(), ", "))
+		}
+	}
+	for _, name := range with {
+		if utils.StringInSlice(without, name) {
+			return nil, trace.BadParameter("component %q cannot be both included and excluded", name)
+		}
+	}
+	for _, component := range r {
+		switch {
+		case utils.StringInSlice(with, component.Name):
+		case utils.StringInSlice(without, component.Name), component.Disabled:
+			disabled = append(disabled, component.Name)
+		}
+	}
+	return disabled, nil
+}
+
+// Apps returns names of applications that comprise the specified components
+func (r Components) Apps(names []string) (apps []string) {
+	for _, component := range r {
+		if utils.StringInSlice(names, component.Name) {
+			apps = append(apps, component.Apps...)
+		}
+	}
+	return apps
+}
+
+// Component describes an optional part of the application
+// (e.g. monitoring stack) that can be included or excluded at install time
+type Component struct {
+	// Name is the component name
+	Name string `json:"name"`
+	// Description is verbose component description
+	Description string `json:"description,omitempty"`
+	// Disabled specifies whether the component is excluded by default
+	Disabled bool `json:"disabled,omitempty"`
+	// Apps lists names of application dependencies that make up the component
+	Apps []string `json:"apps"`
+}
+
 // Installer contains installer customizations
 type Installer struct {
 	// EULA describes the application end user license agreement
@@ -994,6 +1095,55 @@ type SystemOptions struct {
 	// AllowPrivileged controls whether privileged containers will be allowed
 	// in the cluster.
 	AllowPrivileged bool `json:"allowPrivileged,omitempty"`
+	// ProxyEnvironment controls whether the cluster proxy settings
+	// are injected into application hook jobs
+	ProxyEnvironment bool `json:"proxyEnvironment,omitempty"`
+	// NetworkPolicy configures the baseline network policies
+	NetworkPolicy *NetworkPolicy `json:"networkPolicy,omitempty"`
+	// CNI configures the container network plugins supported by the application
+	CNI *CNI `json:"cni,omitempty"`
+	// AdvertiseAddress configures how the advertise address of a node
+	// is selected if it has not been specified explicitly
+	AdvertiseAddress *utils.AdvertiseAddrPolicy `json:"advertiseAddress,omitempty"`
+}
+
+// CNI describes the container network plugins supported by the application
+type CNI struct {
+	// Default is the network plugin used unless another one
+	// is selected during install
+	Default string `json:"default,omitempty"`
+	// Plugins lists the network applications that install the plugins
+	// other than flannel which is built into the runtime
+	Plugins []CNIPlugin `json:"plugins,omitempty"`
+}
+
+// CNIPlugin describes the network application installing a network plugin
+type CNIPlugin struct {
+	// Name is the network plugin name, e.g. calico
+	Name string `json:"name"`
+	// App is the name of the application dependency that installs
+	// the plugin with its networkInstall hook
+	App string `json:"app"`
+}
+
+// NetworkPolicy describes the baseline set of network policies
+// deployed to the cluster
+type NetworkPolicy struct {
+	// DefaultDeny specifies whether to deny all traffic to and from
+	// pods in the application namespaces except for the traffic
+	// required by the platform
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+	// Namespaces lists the application namespaces to deploy the policies to.
+	// Defaults to the default namespace
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// GetNamespaces returns the list of namespaces to deploy the policies to
+func (r NetworkPolicy) GetNamespaces() []string {
+	if len(r.Namespaces) == 0 {
+		return []string{defaults.Namespace}
+	}
+	return r.Namespaces
 }
 
 // Runtime describes the application runtime
@@ -1165,7 +1315,16 @@ var (
 
 // ShouldSkipApp returns true if the specified application should not be
 // installed in the cluster described by the provided manifest.
-func ShouldSkipApp(manifest Manifest, app loc.Locator) bool {
+// disabledComponents optionally lists the manifest components excluded
+// from the cluster
+func ShouldSkipApp(manifest Manifest, app loc.Locator, disabledComponents ...string) bool {
+	if utils.StringInSlice(manifest.Components.Apps(disabledComponents), app.Name) {
+		return true
+	}
+	if manifest.IsNetworkApp(app.Name) {
+		// network applications are installed by their networkInstall hook
+		return true
+	}
 	switch app.Name {
 	case defaults.BandwagonPackageName:
 		// do not install bandwagon unless the app uses it in its post-install
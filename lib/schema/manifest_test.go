@@ -550,6 +550,90 @@ systemOptions:
 	c.Assert(err, NotNil)
 }
 
+func (s *ManifestSuite) TestParsesNetworkPolicy(c *C) {
+	m, err := ParseManifestYAML([]byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+systemOptions:
+  runtime:
+    version: "1.4.6"
+  networkPolicy:
+    defaultDeny: true`))
+	c.Assert(err, IsNil)
+	c.Assert(m.NetworkPolicy(), NotNil)
+	c.Assert(m.NetworkPolicy().GetNamespaces(), DeepEquals, []string{"default"})
+
+	m, err = ParseManifestYAML([]byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+systemOptions:
+  runtime:
+    version: "1.4.6"
+  networkPolicy:
+    namespaces: [app]`))
+	c.Assert(err, IsNil)
+	c.Assert(m.NetworkPolicy(), IsNil)
+}
+
+func (s *ManifestSuite) TestParsesAdvertiseAddrPolicy(c *C) {
+	m, err := ParseManifestYAML([]byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+installer:
+  flavors:
+    items:
+      - name: one
+        nodes:
+          - profile: master
+            count: 1
+          - profile: storage
+            count: 1
+systemOptions:
+  runtime:
+    version: "1.4.6"
+  advertiseAddress:
+    cidr: 10.0.0.0/8
+nodeProfiles:
+  - name: master
+  - name: storage
+    systemOptions:
+      advertiseAddress:
+        interface: ^eth1$
+        defaultRoute: false`))
+	c.Assert(err, IsNil)
+	c.Assert(m.AdvertiseAddrPolicy("master"), DeepEquals, &utils.AdvertiseAddrPolicy{CIDR: "10.0.0.0/8"})
+	c.Assert(m.AdvertiseAddrPolicy("storage"), DeepEquals, &utils.AdvertiseAddrPolicy{Interface: "^eth1$"})
+	c.Assert(m.AdvertiseAddrPolicy(""), DeepEquals, &utils.AdvertiseAddrPolicy{CIDR: "10.0.0.0/8"})
+
+	_, err = ParseManifestYAML([]byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+installer:
+  flavors:
+    items:
+      - name: one
+        nodes:
+          - profile: master
+            count: 1
+systemOptions:
+  runtime:
+    version: "1.4.6"
+nodeProfiles:
+  - name: master
+    systemOptions:
+      advertiseAddress:
+        cidr: 10.0.0.0`))
+	c.Assert(err, NotNil)
+}
+
 func (s *ManifestSuite) TestCanOverrideBooleans(c *C) {
 	bytes := []byte(`apiVersion: bundle.gravitational.io/v2
 kind: Bundle
@@ -641,3 +725,171 @@ extensions:
 			Commentf("Test case %v failed", tc))
 	}
 }
+
+func (s *ManifestSuite) TestComponents(c *C) {
+	bytes := []byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+dependencies:
+  apps:
+    - gravitational.io/monitoring-app:0.0.1
+    - gravitational.io/logging-app:0.0.1
+    - gravitational.io/sample-app:0.0.1
+components:
+  - name: monitoring
+    description: Monitoring stack
+    apps: [monitoring-app]
+  - name: logging
+    apps: [logging-app]
+  - name: sample
+    disabled: true
+    apps: [sample-app]`)
+	m, err := ParseManifestYAML(bytes)
+	c.Assert(err, IsNil)
+
+	disabled, err := m.Components.Disabled(nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(disabled, DeepEquals, []string{"sample"})
+
+	disabled, err = m.Components.Disabled([]string{"sample"}, []string{"logging"})
+	c.Assert(err, IsNil)
+	c.Assert(disabled, DeepEquals, []string{"logging"})
+	c.Assert(ShouldSkipApp(*m, loc.Locator{Name: "logging-app"}, disabled...), Equals, true)
+	c.Assert(ShouldSkipApp(*m, loc.Locator{Name: "sample-app"}, disabled...), Equals, false)
+	c.Assert(ShouldSkipApp(*m, loc.Locator{Name: "monitoring-app"}, disabled...), Equals, false)
+
+	_, err = m.Components.Disabled([]string{"unknown"}, nil)
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", err))
+
+	_, err = m.Components.Disabled([]string{"logging"}, []string{"logging"})
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", err))
+}
+
+func (s *ManifestSuite) TestComponentsValidation(c *C) {
+	bytes := []byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+dependencies:
+  apps:
+    - gravitational.io/logging-app:0.0.1
+components:
+  - name: logging
+    apps: [logging-app]
+  - name: logging
+    apps: [missing-app]`)
+	_, err := ParseManifestYAML(bytes)
+	c.Assert(err, NotNil)
+	c.Assert(err, ErrorMatches, `(?s).*duplicate component "logging".*`)
+	c.Assert(err, ErrorMatches, `(?s).*refers to undefined dependency "missing-app".*`)
+}
+
+func (s *ManifestSuite) TestCNI(c *C) {
+	bytes := []byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+dependencies:
+  apps:
+    - gravitational.io/calico-app:0.0.1
+systemOptions:
+  runtime:
+    version: "1.4.6"
+  cni:
+    default: calico
+    plugins:
+      - name: calico
+        app: calico-app`)
+	m, err := ParseManifestYAML(bytes)
+	c.Assert(err, IsNil)
+	app := loc.MustParseLocator("gravitational.io/myapp:0.0.1")
+
+	cni, err := m.SelectCNI("")
+	c.Assert(err, IsNil)
+	c.Assert(cni, Equals, CNICalico)
+
+	cni, err = m.SelectCNI(CNIFlannel)
+	c.Assert(err, IsNil)
+	c.Assert(cni, Equals, CNIFlannel)
+
+	_, err = m.SelectCNI(CNICilium)
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%v", err))
+
+	_, err = m.SelectCNI("weave")
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", err))
+
+	networkApp, err := m.NetworkApp(CNICalico, app)
+	c.Assert(err, IsNil)
+	c.Assert(*networkApp, DeepEquals, loc.MustParseLocator("gravitational.io/calico-app:0.0.1"))
+
+	networkApp, err = m.NetworkApp(CNIFlannel, app)
+	c.Assert(err, IsNil)
+	c.Assert(networkApp, IsNil)
+
+	c.Assert(ShouldSkipApp(*m, loc.Locator{Name: "calico-app"}), Equals, true)
+
+	flannel := m.WithCNI(CNIFlannel)
+	c.Assert(flannel.CNI(), Equals, CNIFlannel)
+	c.Assert(flannel.CNIRequirements().TCP, HasLen, 0)
+	c.Assert(m.CNI(), Equals, CNICalico)
+	c.Assert(m.CNIRequirements().TCP, DeepEquals, []int{179})
+}
+
+func (s *ManifestSuite) TestCNILegacyNetworkHook(c *C) {
+	bytes := []byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+hooks:
+  networkInstall:
+    job: |
+      apiVersion: batch/v1
+      kind: Job
+      metadata:
+        name: network-install
+      spec:
+        template:
+          metadata:
+            name: network-install
+          spec:
+            restartPolicy: OnFailure
+            containers:
+              - name: hook
+                image: quay.io/gravitational/debian-tall:0.0.1`)
+	m, err := ParseManifestYAML(bytes)
+	c.Assert(err, IsNil)
+	app := loc.MustParseLocator("gravitational.io/myapp:0.0.1")
+
+	networkApp, err := m.NetworkApp("", app)
+	c.Assert(err, IsNil)
+	c.Assert(*networkApp, DeepEquals, app)
+
+	networkApp, err = m.NetworkApp(CNIFlannel, app)
+	c.Assert(err, IsNil)
+	c.Assert(networkApp, IsNil)
+}
+
+func (s *ManifestSuite) TestCNIValidation(c *C) {
+	bytes := []byte(`apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: myapp
+  resourceVersion: 0.0.1
+systemOptions:
+  runtime:
+    version: "1.4.6"
+  cni:
+    default: cilium
+    plugins:
+      - name: calico
+        app: calico-app`)
+	_, err := ParseManifestYAML(bytes)
+	c.Assert(err, NotNil)
+	c.Assert(err, ErrorMatches, `(?s).*network plugin "calico" refers to undefined dependency "calico-app".*`)
+	c.Assert(err, ErrorMatches, `(?s).*default network plugin "cilium" is not provided by the application.*`)
+}
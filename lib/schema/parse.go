@@ -122,6 +122,23 @@ func CheckAndSetDefaults(manifest *Manifest) error {
 		if err != nil {
 			errors = append(errors, trace.Wrap(err))
 		}
+
+		if policy := manifest.AdvertiseAddrPolicy(profile.Name); policy != nil {
+			err = policy.Check()
+			if err != nil {
+				errors = append(errors, trace.Wrap(err, "node profile %q", profile.Name))
+			}
+		}
+	}
+
+	err = checkComponents(manifest.Components, manifest.Dependencies)
+	if err != nil {
+		errors = append(errors, trace.Wrap(err))
+	}
+
+	err = checkCNI(*manifest)
+	if err != nil {
+		errors = append(errors, trace.Wrap(err))
 	}
 
 	if manifest.WebConfig != "" {
@@ -261,6 +278,51 @@ func checkFlavors(flavors Flavors, profiles NodeProfiles) error {
 	return trace.NewAggregate(errors...)
 }
 
+// checkComponents makes sure the components have unique names and refer
+// to application dependencies
+func checkComponents(components Components, deps Dependencies) error {
+	var errors []error
+	names := make(map[string]struct{})
+	for _, component := range components {
+		if _, ok := names[component.Name]; ok {
+			errors = append(errors, trace.BadParameter(
+				"duplicate component %q", component.Name))
+		}
+		names[component.Name] = struct{}{}
+		if len(component.Apps) == 0 {
+			errors = append(errors, trace.BadParameter(
+				"component %q does not specify any applications", component.Name))
+		}
+		for _, app := range component.Apps {
+			if _, err := deps.ByName(app); err != nil {
+				errors = append(errors, trace.BadParameter(
+					"component %q refers to undefined dependency %q", component.Name, app))
+			}
+		}
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// checkCNI makes sure the network plugins refer to application dependencies
+// and the default plugin is provided by the application
+func checkCNI(manifest Manifest) error {
+	if manifest.SystemOptions == nil || manifest.SystemOptions.CNI == nil {
+		return nil
+	}
+	var errors []error
+	for _, plugin := range manifest.SystemOptions.CNI.Plugins {
+		if _, err := manifest.Dependencies.ByName(plugin.App); err != nil {
+			errors = append(errors, trace.BadParameter(
+				"network plugin %q refers to undefined dependency %q", plugin.Name, plugin.App))
+		}
+	}
+	if cni := manifest.CNI(); cni != "" && cni != CNIFlannel && manifest.cniPlugin(cni) == nil {
+		errors = append(errors, trace.BadParameter(
+			"default network plugin %q is not provided by the application", cni))
+	}
+	return trace.NewAggregate(errors...)
+}
+
 // checkProfile performs some sanity checks on node profile
 func checkProfile(profile NodeProfile) error {
 	var errors []error
@@ -559,6 +559,20 @@ const manifestSchema = `
           }
         },
         "systemOptions": {"$ref": "#/definitions/systemOptions"},
+        "components": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "required": ["name", "apps"],
+            "additionalProperties": false,
+            "properties": {
+              "name": {"type": "string"},
+              "description": {"type": "string"},
+              "disabled": {"type": "boolean"},
+              "apps": {"type": "array", "items": {"type": "string"}}
+            }
+          }
+        },
         "extensions": {
           "type": "object",
           "additionalProperties": false,
@@ -579,7 +593,8 @@ const manifestSchema = `
             "configuration": {"$ref": "#/definitions/onOff"}
           }
         },
-        "webConfig": {"type": "string"}
+        "webConfig": {"type": "string"},
+        "defaultResources": {"type": "string"}
       }
     },
     "providerAWS": {
@@ -646,6 +661,46 @@ const manifestSchema = `
       "properties": {
         "baseImage": {"type": "string"},
         "allowPrivileged": {"type": "boolean"},
+        "proxyEnvironment": {"type": "boolean"},
+        "cni": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "default": {"type": "string", "enum": ["flannel", "calico", "cilium"]},
+            "plugins": {
+              "type": "array",
+              "items": {
+                "type": "object",
+                "additionalProperties": false,
+                "required": ["name", "app"],
+                "properties": {
+                  "name": {"type": "string", "enum": ["calico", "cilium"]},
+                  "app": {"type": "string"}
+                }
+              }
+            }
+          }
+        },
+        "advertiseAddress": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "cidr": {"type": "string"},
+            "interface": {"type": "string"},
+            "defaultRoute": {"type": "boolean"}
+          }
+        },
+        "networkPolicy": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "defaultDeny": {"type": "boolean"},
+            "namespaces": {
+              "type": "array",
+              "items": {"type": "string"}
+            }
+          }
+        },
         "args": {
           "type": "array",
           "items": {"type": "string"}
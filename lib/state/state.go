@@ -94,6 +94,12 @@ func GravityRPCAgentDir(baseDir string) string {
 	return filepath.Join(baseDir, defaults.SiteDir, defaults.UpdateDir, defaults.AgentDir)
 }
 
+// VolumeSnapshotDir returns full path to the directory with the data
+// of the specified volume snapshot
+func VolumeSnapshotDir(baseDir, name string) string {
+	return filepath.Join(baseDir, defaults.SiteDir, defaults.VolumeSnapshotsDir, name)
+}
+
 // ShareDir returns full path to the planet share directory
 func ShareDir(baseDir string) string {
 	return filepath.Join(baseDir, defaults.PlanetDir, defaults.ShareDir)
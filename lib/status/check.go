@@ -0,0 +1,145 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	pb "github.com/gravitational/satellite/agent/proto/agentpb"
+)
+
+// CheckCode is the result code of the cluster health check.
+// Codes follow the Nagios plugin exit code convention
+type CheckCode int
+
+const (
+	// CheckOK indicates that the cluster is healthy
+	CheckOK CheckCode = 0
+	// CheckWarning indicates that the cluster is functional but some
+	// of the non-critical probes are failing
+	CheckWarning CheckCode = 1
+	// CheckCritical indicates that the cluster is not healthy
+	CheckCritical CheckCode = 2
+	// CheckUnknown indicates that the cluster health could not be determined
+	CheckUnknown CheckCode = 3
+)
+
+// String returns the textual representation of this check code
+func (r CheckCode) String() string {
+	switch r {
+	case CheckOK:
+		return "OK"
+	case CheckWarning:
+		return "WARNING"
+	case CheckCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// HTTPStatus returns the HTTP status code corresponding to this check code.
+// Warnings do not render the cluster unavailable
+func (r CheckCode) HTTPStatus() int {
+	switch r {
+	case CheckOK, CheckWarning:
+		return http.StatusOK
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// Check describes the result of the cluster health check
+type Check struct {
+	// Code is the check result code
+	Code CheckCode `json:"code"`
+	// Status is the textual check result: OK, WARNING, CRITICAL or UNKNOWN
+	Status string `json:"status"`
+	// Reasons lists the problems found by the check
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// String formats this check as a single line of Nagios plugin output, e.g.:
+//
+//	CRITICAL - node-1 (192.168.1.1): docker is not running
+func (r Check) String() string {
+	if len(r.Reasons) == 0 {
+		return fmt.Sprintf("%v - cluster is healthy", r.Status)
+	}
+	return fmt.Sprintf("%v - %v", r.Status, strings.Join(r.Reasons, "; "))
+}
+
+// NewCheck evaluates the cluster health from the specified planet agent status.
+// Offline nodes and failed critical probes result in a critical status,
+// failed warning probes in a warning.
+// If agent is nil, the status is unknown
+func NewCheck(agent *Agent) Check {
+	if agent == nil {
+		return newCheck(CheckUnknown, []string{"failed to query cluster status"})
+	}
+	var code CheckCode
+	var reasons []string
+	for _, node := range agent.Nodes {
+		name := nodeName(node)
+		if node.Status == NodeOffline {
+			code = CheckCritical
+			reasons = append(reasons, fmt.Sprintf("%v is offline", name))
+			continue
+		}
+		for _, probe := range node.FailedProbes {
+			code = CheckCritical
+			reasons = append(reasons, fmt.Sprintf("%v: %v", name, probe))
+		}
+		for _, probe := range node.WarnProbes {
+			if code == CheckOK {
+				code = CheckWarning
+			}
+			reasons = append(reasons, fmt.Sprintf("%v: %v", name, probe))
+		}
+	}
+	switch agent.GetSystemStatus() {
+	case pb.SystemStatus_Running:
+	case pb.SystemStatus_Degraded:
+		if code != CheckCritical {
+			code = CheckCritical
+			reasons = append(reasons, "cluster is degraded")
+		}
+	default:
+		if code == CheckOK {
+			code = CheckUnknown
+			reasons = append(reasons, "cluster status is unknown")
+		}
+	}
+	return newCheck(code, reasons)
+}
+
+func newCheck(code CheckCode, reasons []string) Check {
+	return Check{
+		Code:    code,
+		Status:  code.String(),
+		Reasons: reasons,
+	}
+}
+
+func nodeName(node ClusterServer) string {
+	if node.Hostname == "" {
+		return node.AdvertiseIP
+	}
+	return fmt.Sprintf("%v (%v)", node.Hostname, node.AdvertiseIP)
+}
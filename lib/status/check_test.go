@@ -0,0 +1,100 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"net/http"
+	"testing"
+
+	pb "github.com/gravitational/satellite/agent/proto/agentpb"
+	"gopkg.in/check.v1"
+)
+
+func TestStatus(t *testing.T) { check.TestingT(t) }
+
+type CheckSuite struct{}
+
+var _ = check.Suite(&CheckSuite{})
+
+func (s *CheckSuite) TestCheck(c *check.C) {
+	var testCases = []struct {
+		comment string
+		agent   *Agent
+		code    CheckCode
+		output  string
+	}{
+		{
+			comment: "healthy cluster",
+			agent: &Agent{
+				SystemStatus: SystemStatus(pb.SystemStatus_Running),
+				Nodes:        []ClusterServer{{AdvertiseIP: "192.168.1.1", Status: NodeHealthy}},
+			},
+			code:   CheckOK,
+			output: "OK - cluster is healthy",
+		},
+		{
+			comment: "warning probes",
+			agent: &Agent{
+				SystemStatus: SystemStatus(pb.SystemStatus_Running),
+				Nodes: []ClusterServer{{
+					Hostname:    "node-1",
+					AdvertiseIP: "192.168.1.1",
+					Status:      NodeHealthy,
+					WarnProbes:  []string{"disk is 85% full"},
+				}},
+			},
+			code:   CheckWarning,
+			output: "WARNING - node-1 (192.168.1.1): disk is 85% full",
+		},
+		{
+			comment: "failed and offline nodes",
+			agent: &Agent{
+				SystemStatus: SystemStatus(pb.SystemStatus_Degraded),
+				Nodes: []ClusterServer{
+					{
+						AdvertiseIP:  "192.168.1.1",
+						Status:       NodeDegraded,
+						FailedProbes: []string{"docker is not running"},
+						WarnProbes:   []string{"disk is 85% full"},
+					},
+					{AdvertiseIP: "192.168.1.2", Status: NodeOffline},
+				},
+			},
+			code:   CheckCritical,
+			output: "CRITICAL - 192.168.1.1: docker is not running; 192.168.1.1: disk is 85% full; 192.168.1.2 is offline",
+		},
+		{
+			comment: "degraded without failed probes",
+			agent:   &Agent{SystemStatus: SystemStatus(pb.SystemStatus_Degraded)},
+			code:    CheckCritical,
+			output:  "CRITICAL - cluster is degraded",
+		},
+		{
+			comment: "status unavailable",
+			code:    CheckUnknown,
+			output:  "UNKNOWN - failed to query cluster status",
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		result := NewCheck(tc.agent)
+		c.Assert(result.Code, check.Equals, tc.code, comment)
+		c.Assert(result.String(), check.Equals, tc.output, comment)
+	}
+	c.Assert(CheckWarning.HTTPStatus(), check.Equals, http.StatusOK)
+	c.Assert(CheckCritical.HTTPStatus(), check.Equals, http.StatusServiceUnavailable)
+}
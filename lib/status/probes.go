@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/checks"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/roundtrip"
+	pb "github.com/gravitational/satellite/agent/proto/agentpb"
+	"github.com/gravitational/satellite/monitoring"
+	"github.com/gravitational/trace"
+)
+
+// NewAgentClient returns a new client for the monitoring agent
+// running on this node.
+// If local is true, the client only queries the status of this node
+func NewAgentClient(local bool) (*AgentClient, error) {
+	urlFormat := "https://%v:%v"
+	if local {
+		urlFormat = "https://%v:%v/local"
+	}
+	planetClient, err := httplib.GetPlanetClient()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	addr := fmt.Sprintf(urlFormat, constants.Localhost, defaults.SatelliteRPCAgentPort)
+	client, err := roundtrip.NewClient(addr, "", roundtrip.HTTPClient(planetClient))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &AgentClient{
+		client: client,
+		addr:   addr,
+	}, nil
+}
+
+// AgentClient queries the health status from the monitoring agent
+type AgentClient struct {
+	client *roundtrip.Client
+	addr   string
+}
+
+// SystemStatus returns the last health status collected by the agent
+func (r *AgentClient) SystemStatus(ctx context.Context) (*pb.SystemStatus, error) {
+	resp, err := r.client.Get(ctx, r.addr, url.Values{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var status pb.SystemStatus
+	if err := json.Unmarshal(resp.Bytes(), &status); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &status, nil
+}
+
+// Probes returns the last results of all health probes on the cluster nodes.
+// The servers are used to report the nodes missing in the agent status
+func (r *AgentClient) Probes(ctx context.Context, servers []storage.Server) (*ops.ClusterProbes, error) {
+	start := time.Now()
+	status, err := r.SystemStatus(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to query health probes from agent")
+	}
+	probes := FromSystemStatus(*status, servers)
+	probes.Duration = time.Since(start)
+	return probes, nil
+}
+
+// FromSystemStatus converts the status reported by the monitoring agent
+// into the list of health probes.
+// Every server missing from the status is reported with a single failed probe
+func FromSystemStatus(status pb.SystemStatus, servers []storage.Server) *ops.ClusterProbes {
+	result := &ops.ClusterProbes{}
+	if status.Timestamp != nil {
+		result.Timestamp = status.Timestamp.ToTime()
+	}
+	nodes := nodes(status)
+	for _, server := range servers {
+		node, found := nodes[server.AdvertiseIP]
+		if !found {
+			result.Probes = append(result.Probes, ops.Probe{
+				Node:        server.Hostname,
+				AdvertiseIP: server.AdvertiseIP,
+				Checker:     monitoring.NodeStatusCheckerID,
+				Status:      ops.ProbeStatusFailed,
+				Severity:    strings.ToLower(pb.Probe_Critical.String()),
+				Error:       "no status reported for the node",
+				Remediation: checks.Remediation(monitoring.NodeStatusCheckerID),
+			})
+			continue
+		}
+		result.Probes = append(result.Probes, fromNodeProbes(*node, server.Hostname)...)
+		delete(nodes, server.AdvertiseIP)
+	}
+	for _, node := range status.Nodes {
+		if _, found := nodes[node.MemberStatus.Tags[publicIPAddrTag]]; found {
+			result.Probes = append(result.Probes, fromNodeProbes(*node, node.Name)...)
+		}
+	}
+	return result
+}
+
+func fromNodeProbes(node pb.NodeStatus, hostname string) (probes []ops.Probe) {
+	for _, probe := range node.Probes {
+		result := ops.Probe{
+			Node:        hostname,
+			AdvertiseIP: node.MemberStatus.Tags[publicIPAddrTag],
+			Checker:     probe.Checker,
+			Detail:      probe.Detail,
+			Status:      ops.ProbeStatusRunning,
+		}
+		if probe.Status != pb.Probe_Running {
+			result.Status = ops.ProbeStatusFailed
+			result.Severity = strings.ToLower(probe.Severity.String())
+			result.Error = probe.Error
+			result.Remediation = checks.Remediation(probe.Checker)
+		}
+		probes = append(probes, result)
+	}
+	return probes
+}
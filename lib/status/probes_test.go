@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/checks"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	pb "github.com/gravitational/satellite/agent/proto/agentpb"
+	"github.com/gravitational/satellite/monitoring"
+	"gopkg.in/check.v1"
+)
+
+type ProbesSuite struct{}
+
+var _ = check.Suite(&ProbesSuite{})
+
+func (s *ProbesSuite) TestConvertsSystemStatus(c *check.C) {
+	now := time.Date(2019, time.March, 1, 10, 0, 0, 0, time.UTC)
+	status := pb.SystemStatus{
+		Timestamp: pb.NewTimeToProto(now),
+		Nodes: []*pb.NodeStatus{
+			{
+				Name:         "192.168.1.1",
+				MemberStatus: &pb.MemberStatus{Tags: map[string]string{publicIPAddrTag: "192.168.1.1"}},
+				Probes: []*pb.Probe{
+					{Checker: "kernel-module", Detail: "overlay", Status: pb.Probe_Running},
+					{
+						Checker:  "port-checker",
+						Status:   pb.Probe_Failed,
+						Severity: pb.Probe_Critical,
+						Error:    "port 6443 is in use",
+					},
+				},
+			},
+		},
+	}
+	servers := []storage.Server{
+		{Hostname: "node-1", AdvertiseIP: "192.168.1.1"},
+		{Hostname: "node-2", AdvertiseIP: "192.168.1.2"},
+	}
+	c.Assert(FromSystemStatus(status, servers), check.DeepEquals, &ops.ClusterProbes{
+		Timestamp: now,
+		Probes: []ops.Probe{
+			{
+				Node:        "node-1",
+				AdvertiseIP: "192.168.1.1",
+				Checker:     "kernel-module",
+				Detail:      "overlay",
+				Status:      ops.ProbeStatusRunning,
+			},
+			{
+				Node:        "node-1",
+				AdvertiseIP: "192.168.1.1",
+				Checker:     "port-checker",
+				Status:      ops.ProbeStatusFailed,
+				Severity:    "critical",
+				Error:       "port 6443 is in use",
+				Remediation: checks.Remediation("port-checker"),
+			},
+			{
+				Node:        "node-2",
+				AdvertiseIP: "192.168.1.2",
+				Checker:     monitoring.NodeStatusCheckerID,
+				Status:      ops.ProbeStatusFailed,
+				Severity:    "critical",
+				Error:       "no status reported for the node",
+				Remediation: checks.Remediation(monitoring.NodeStatusCheckerID),
+			},
+		},
+	})
+}
@@ -21,19 +21,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/url"
 	"time"
 
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
-	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/utils"
 
-	"github.com/gravitational/roundtrip"
 	pb "github.com/gravitational/satellite/agent/proto/agentpb"
 	"github.com/gravitational/satellite/monitoring"
 	"github.com/gravitational/trace"
@@ -89,6 +86,18 @@ func FromCluster(ctx context.Context, operator ops.Operator, cluster ops.Site, o
 		status.Endpoints.Cluster.UI = clusterEndpoints.ManagementURLs()
 	}
 
+	if cluster.License != nil {
+		status.License, err = ops.ParseLicenseStatus(cluster.License.Raw, time.Now())
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to parse cluster license.")
+		}
+	}
+
+	status.StorageWarnings, err = getStorageWarnings(operator, cluster.Key())
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to query persistent storage usage.")
+	}
+
 	// FIXME: have status extension accept the operator/environment
 	err = status.Cluster.Extension.Collect()
 	if err != nil {
@@ -140,6 +149,27 @@ func FromCluster(ctx context.Context, operator ops.Operator, cluster ops.Site, o
 	return status, nil
 }
 
+// getStorageWarnings returns the warnings about the storage quotas
+// that are close to the limit.
+// The volume usage is only queried if the cluster has quotas configured
+func getStorageWarnings(operator ops.Operator, key ops.SiteKey) ([]string, error) {
+	config, err := operator.GetPersistentStorage(key)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	if len(config.GetQuotas()) == 0 {
+		return nil, nil
+	}
+	usage, err := operator.GetStorageUsage(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return usage.Warnings, nil
+}
+
 // FromPlanetAgent collects the cluster status from the planet agent
 func FromPlanetAgent(ctx context.Context, servers []storage.Server) (*Agent, error) {
 	return fromPlanetAgent(ctx, false, servers)
@@ -206,6 +236,11 @@ type Cluster struct {
 	ActiveOperations []*ClusterOperation `json:"active_operations,omitempty"`
 	// Endpoints contains cluster and application endpoints.
 	Endpoints Endpoints `json:"endpoints"`
+	// License describes the installed cluster license if any
+	License *ops.LicenseStatus `json:"license,omitempty"`
+	// StorageWarnings lists the persistent storage quotas that are
+	// close to or over their limits
+	StorageWarnings []string `json:"storage_warnings,omitempty"`
 	// Extension is a cluster status extension
 	Extension `json:",inline,omitempty"`
 }
@@ -420,33 +455,11 @@ func fromClusterState(systemStatus pb.SystemStatus, cluster []storage.Server) (o
 }
 
 func planetAgentStatus(ctx context.Context, local bool) (*pb.SystemStatus, error) {
-	urlFormat := "https://%v:%v"
-	if local {
-		urlFormat = "https://%v:%v/local"
-	}
-	planetClient, err := httplib.GetPlanetClient()
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-	httpClient := roundtrip.HTTPClient(planetClient)
-	addr := fmt.Sprintf(urlFormat, constants.Localhost, defaults.SatelliteRPCAgentPort)
-	client, err := roundtrip.NewClient(addr, "", httpClient)
+	client, err := NewAgentClient(local)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-
-	resp, err := client.Get(ctx, addr, url.Values{})
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	var status pb.SystemStatus
-	err = json.Unmarshal(resp.Bytes(), &status)
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	return &status, nil
+	return client.SystemStatus(ctx)
 }
 
 // nodes returns the set of node status objects keyed by IP
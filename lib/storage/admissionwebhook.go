@@ -0,0 +1,240 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+)
+
+// AdmissionWebhook describes the external validation webhook that is consulted
+// before changes to sensitive cluster resources are persisted
+type AdmissionWebhook interface {
+	// Resource provides common resource methods
+	teleservices.Resource
+	// CheckAndSetDefaults verifies that the object is valid
+	CheckAndSetDefaults() error
+	// GetURL returns the URL of the webhook
+	GetURL() string
+	// GetCACert returns the PEM-encoded CA certificate to verify the webhook with
+	GetCACert() string
+	// GetResources returns the list of resource kinds subject to validation
+	GetResources() []string
+	// GetFailurePolicy returns the policy to apply if the webhook cannot be reached
+	GetFailurePolicy() string
+	// GetTimeout returns the webhook request timeout
+	GetTimeout() time.Duration
+}
+
+const (
+	// AdmissionFailurePolicyFail rejects the change if the webhook cannot be reached
+	AdmissionFailurePolicyFail = "fail"
+	// AdmissionFailurePolicyIgnore allows the change if the webhook cannot be reached
+	AdmissionFailurePolicyIgnore = "ignore"
+)
+
+// AdmissionFailurePolicies lists supported admission webhook failure policies
+var AdmissionFailurePolicies = []string{AdmissionFailurePolicyFail, AdmissionFailurePolicyIgnore}
+
+// AdmissionResources lists resource kinds that can be subject to validation
+// by the admission webhook
+var AdmissionResources = []string{
+	KindClusterConfiguration,
+	teleservices.KindGithubConnector,
+	teleservices.KindOIDCConnector,
+	teleservices.KindSAMLConnector,
+	teleservices.KindClusterAuthPreference,
+	KindPersistentStorage,
+}
+
+// NewAdmissionWebhook creates a new admission webhook resource from the provided spec
+func NewAdmissionWebhook(spec AdmissionWebhookSpecV2) AdmissionWebhook {
+	return &AdmissionWebhookV2{
+		Kind:    KindAdmissionWebhook,
+		Version: teleservices.V2,
+		Metadata: teleservices.Metadata{
+			Name:      KindAdmissionWebhook,
+			Namespace: teledefaults.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// AdmissionWebhookV2 defines the external validation webhook for cluster resources
+type AdmissionWebhookV2 struct {
+	// Metadata is resource metadata
+	teleservices.Metadata `json:"metadata"`
+	// Kind is a resource kind
+	Kind string `json:"kind"`
+	// Version is a resource version
+	Version string `json:"version"`
+	// Spec defines the admission webhook
+	Spec AdmissionWebhookSpecV2 `json:"spec"`
+}
+
+// AdmissionWebhookSpecV2 defines the external validation webhook for cluster resources
+type AdmissionWebhookSpecV2 struct {
+	// URL is the https URL the admission requests are POSTed to
+	URL string `json:"url"`
+	// CACert is the PEM-encoded CA certificate to verify the webhook with.
+	// System roots are used if unspecified
+	CACert string `json:"ca_cert,omitempty"`
+	// Resources lists resource kinds subject to validation.
+	// Defaults to all supported resources
+	Resources []string `json:"resources,omitempty"`
+	// FailurePolicy specifies whether the change is rejected (fail) or
+	// allowed (ignore) if the webhook cannot be reached. Defaults to fail
+	FailurePolicy string `json:"failure_policy,omitempty"`
+	// Timeout specifies the webhook request timeout
+	Timeout teleservices.Duration `json:"timeout,omitempty"`
+}
+
+// GetURL returns the URL of the webhook
+func (r *AdmissionWebhookV2) GetURL() string {
+	return r.Spec.URL
+}
+
+// GetCACert returns the PEM-encoded CA certificate to verify the webhook with
+func (r *AdmissionWebhookV2) GetCACert() string {
+	return r.Spec.CACert
+}
+
+// GetResources returns the list of resource kinds subject to validation
+func (r *AdmissionWebhookV2) GetResources() []string {
+	return r.Spec.Resources
+}
+
+// GetFailurePolicy returns the policy to apply if the webhook cannot be reached
+func (r *AdmissionWebhookV2) GetFailurePolicy() string {
+	return r.Spec.FailurePolicy
+}
+
+// GetTimeout returns the webhook request timeout
+func (r *AdmissionWebhookV2) GetTimeout() time.Duration {
+	return r.Spec.Timeout.Duration
+}
+
+// CheckAndSetDefaults checks validity of all parameters and sets defaults
+func (r *AdmissionWebhookV2) CheckAndSetDefaults() error {
+	if r.Metadata.Name == "" {
+		r.Metadata.Name = KindAdmissionWebhook
+	}
+	if err := r.Metadata.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	u, err := url.ParseRequestURI(r.Spec.URL)
+	if err != nil || u.Scheme != "https" {
+		return trace.BadParameter("webhook URL %q should be an https URL", r.Spec.URL)
+	}
+	if r.Spec.CACert != "" {
+		if _, err := teleutils.ParseCertificatePEM([]byte(r.Spec.CACert)); err != nil {
+			return trace.BadParameter("invalid CA certificate: %v", err)
+		}
+	}
+	if len(r.Spec.Resources) == 0 {
+		r.Spec.Resources = append([]string(nil), AdmissionResources...)
+	}
+	for i, kind := range r.Spec.Resources {
+		kind = CanonicalKind(kind)
+		if !utils.StringInSlice(AdmissionResources, kind) {
+			return trace.BadParameter("unsupported resource %q, supported are: %v",
+				r.Spec.Resources[i], AdmissionResources)
+		}
+		r.Spec.Resources[i] = kind
+	}
+	if r.Spec.FailurePolicy == "" {
+		r.Spec.FailurePolicy = AdmissionFailurePolicyFail
+	}
+	if !utils.StringInSlice(AdmissionFailurePolicies, r.Spec.FailurePolicy) {
+		return trace.BadParameter("unsupported failure policy %q, supported are: %v",
+			r.Spec.FailurePolicy, AdmissionFailurePolicies)
+	}
+	if r.Spec.Timeout.Duration < 0 {
+		return trace.BadParameter("timeout cannot be negative")
+	}
+	return nil
+}
+
+// String returns a textual representation of this admission webhook
+func (r *AdmissionWebhookV2) String() string {
+	return fmt.Sprintf("AdmissionWebhookV2(URL=%v, Resources=%v, FailurePolicy=%v)",
+		r.Spec.URL, r.Spec.Resources, r.Spec.FailurePolicy)
+}
+
+// UnmarshalAdmissionWebhook unmarshals admission webhook from JSON or YAML
+func UnmarshalAdmissionWebhook(data []byte) (AdmissionWebhook, error) {
+	if len(data) == 0 {
+		return nil, trace.BadParameter("empty configuration")
+	}
+	jsonData, err := teleutils.ToJSON(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var hdr teleservices.ResourceHeader
+	err = json.Unmarshal(jsonData, &hdr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch hdr.Version {
+	case teleservices.V2:
+		var webhook AdmissionWebhookV2
+		err := teleutils.UnmarshalWithSchema(GetAdmissionWebhookSchema(), &webhook, jsonData)
+		if err != nil {
+			return nil, trace.BadParameter("%v", err)
+		}
+		if err := webhook.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &webhook, nil
+	}
+	return nil, trace.BadParameter(
+		"%v resource version %q is not supported", KindAdmissionWebhook, hdr.Version)
+}
+
+// MarshalAdmissionWebhook marshals admission webhook into JSON
+func MarshalAdmissionWebhook(webhook AdmissionWebhook, opts ...teleservices.MarshalOption) ([]byte, error) {
+	return json.Marshal(webhook)
+}
+
+// AdmissionWebhookSpecV2Schema is JSON schema for the admission webhook
+const AdmissionWebhookSpecV2Schema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["url"],
+  "properties": {
+    "url": {"type": "string"},
+    "ca_cert": {"type": "string"},
+    "resources": {"type": "array", "items": {"type": "string"}},
+    "failure_policy": {"type": "string"},
+    "timeout": {"type": "string"}
+  }
+}`
+
+// GetAdmissionWebhookSchema returns the admission webhook schema for version V2
+func GetAdmissionWebhookSchema() string {
+	return fmt.Sprintf(teleservices.V2SchemaTemplate, MetadataSchema,
+		AdmissionWebhookSpecV2Schema, "")
+}
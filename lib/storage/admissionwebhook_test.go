@@ -0,0 +1,97 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/compare"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	check "gopkg.in/check.v1"
+)
+
+type AdmissionWebhookSuite struct{}
+
+var _ = check.Suite(&AdmissionWebhookSuite{})
+
+func (s *AdmissionWebhookSuite) TestResourceParsing(c *check.C) {
+	spec := `kind: admissionwebhook
+version: v2
+spec:
+  url: https://opa.example.com/v1/gravity
+  resources: ["config", "github"]
+  failure_policy: ignore
+  timeout: 5s
+`
+	webhook, err := UnmarshalAdmissionWebhook([]byte(spec))
+	c.Assert(err, check.IsNil)
+	c.Assert(webhook, compare.DeepEquals, NewAdmissionWebhook(AdmissionWebhookSpecV2{
+		URL:           "https://opa.example.com/v1/gravity",
+		Resources:     []string{KindClusterConfiguration, teleservices.KindGithubConnector},
+		FailurePolicy: AdmissionFailurePolicyIgnore,
+		Timeout:       teleservices.NewDuration(5 * time.Second),
+	}))
+}
+
+func (s *AdmissionWebhookSuite) TestDefaults(c *check.C) {
+	webhook, err := UnmarshalAdmissionWebhook([]byte(`kind: admissionwebhook
+version: v2
+spec:
+  url: https://opa.example.com/v1/gravity
+`))
+	c.Assert(err, check.IsNil)
+	c.Assert(webhook.GetResources(), compare.DeepEquals, AdmissionResources)
+	c.Assert(webhook.GetFailurePolicy(), check.Equals, AdmissionFailurePolicyFail)
+}
+
+func (s *AdmissionWebhookSuite) TestValidation(c *check.C) {
+	var testCases = []struct {
+		comment string
+		spec    AdmissionWebhookSpecV2
+	}{
+		{
+			comment: "plain http URL",
+			spec:    AdmissionWebhookSpecV2{URL: "http://opa.example.com"},
+		},
+		{
+			comment: "unsupported resource",
+			spec: AdmissionWebhookSpecV2{
+				URL:       "https://opa.example.com",
+				Resources: []string{KindAlert},
+			},
+		},
+		{
+			comment: "unsupported failure policy",
+			spec: AdmissionWebhookSpecV2{
+				URL:           "https://opa.example.com",
+				FailurePolicy: "retry",
+			},
+		},
+		{
+			comment: "invalid CA certificate",
+			spec: AdmissionWebhookSpecV2{
+				URL:    "https://opa.example.com",
+				CACert: "not a certificate",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		err := NewAdmissionWebhook(tc.spec).CheckAndSetDefaults()
+		c.Assert(err, check.NotNil, check.Commentf(tc.comment))
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+
+	"github.com/gravitational/trace"
+)
+
+// AuditEvent describes a mutating action initiated from the command line
+type AuditEvent struct {
+	// ID uniquely identifies the event
+	ID string `json:"id"`
+	// Command is the full name of the executed command, e.g. "resource create"
+	Command string `json:"command"`
+	// Args lists the command line arguments
+	Args []string `json:"args,omitempty"`
+	// User is the name of the OS user who executed the command
+	User string `json:"user"`
+	// Node is the hostname of the node the command was executed on
+	Node string `json:"node"`
+	// Error is the error message if the command has failed
+	Error string `json:"error,omitempty"`
+	// Created is the time the command has completed
+	Created time.Time `json:"created"`
+}
+
+// Check validates this event
+func (r AuditEvent) Check() error {
+	if r.Command == "" {
+		return trace.BadParameter("missing command")
+	}
+	return nil
+}
+
+// IsSuccessful returns true if the command has completed successfully
+func (r AuditEvent) IsSuccessful() bool {
+	return r.Error == ""
+}
+
+// Outcome returns the textual representation of the command's outcome
+func (r AuditEvent) Outcome() string {
+	if r.IsSuccessful() {
+		return "success"
+	}
+	return "failure"
+}
+
+// String returns the event's string representation
+func (r AuditEvent) String() string {
+	return fmt.Sprintf("AuditEvent(command=%v, user=%v, node=%v, outcome=%v, created=%v)",
+		r.Command, r.User, r.Node, r.Outcome(), r.Created.Format(constants.HumanDateFormat))
+}
+
+// AuditLog stores the record of mutating command line actions
+type AuditLog interface {
+	// CreateAuditEvent records a new audit event
+	CreateAuditEvent(AuditEvent) (*AuditEvent, error)
+	// GetAuditEvents returns the events recorded since the specified time
+	// sorted by creation time
+	GetAuditEvents(since time.Time) ([]AuditEvent, error)
+	// DeleteAuditEvent removes the audit event with the specified ID
+	DeleteAuditEvent(id string) error
+}
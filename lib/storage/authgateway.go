@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gravitational/gravity/lib/proxyproto"
 	"github.com/gravitational/gravity/lib/utils"
 
 	teleconfig "github.com/gravitational/teleport/lib/config"
@@ -80,6 +81,14 @@ type AuthGateway interface {
 	GetPublicAddrs() []string
 	// SetPublicAddrs sets public addresses that apply to all services.
 	SetPublicAddrs([]string)
+	// GetProxyProtocol returns whether listeners accept the PROXY protocol header.
+	GetProxyProtocol() *teleservices.Bool
+	// SetProxyProtocol sets the PROXY protocol setting on the resource.
+	SetProxyProtocol(teleservices.Bool)
+	// GetTrustedProxies returns addresses of load balancers allowed to send the PROXY protocol header.
+	GetTrustedProxies() []string
+	// SetTrustedProxies sets trusted load balancer addresses on the resource.
+	SetTrustedProxies([]string)
 	// ApplyTo applies auth gateway settings to the provided auth gateway resource.
 	ApplyTo(AuthGateway)
 	// ApplyToTeleportConfig applies auth gateway settings to the provided Teleport config.
@@ -149,6 +158,12 @@ type AuthGatewaySpecV1 struct {
 	KubernetesPublicAddr *[]string `json:"kubernetes_public_addr,omitempty"`
 	// WebPublicAddr sets public addresses for web service.
 	WebPublicAddr *[]string `json:"web_public_addr,omitempty"`
+	// ProxyProtocol is whether listeners accept the PROXY protocol header
+	// sent by a load balancer to preserve client addresses.
+	ProxyProtocol *teleservices.Bool `json:"proxy_protocol,omitempty"`
+	// TrustedProxies lists IP addresses or CIDRs of load balancers allowed
+	// to send the PROXY protocol header. If empty, any source is allowed.
+	TrustedProxies *[]string `json:"trusted_proxies,omitempty"`
 }
 
 // ConnectionLimits defines connection limits setting on auth gateway resource.
@@ -210,6 +225,12 @@ func principalsChanged(old, new []string) bool {
 	return len(oldSet.Diff(newSet)) > 0
 }
 
+func trustedProxiesChanged(old, new []string) bool {
+	oldSet := utils.NewStringSetFromSlice(old)
+	newSet := utils.NewStringSetFromSlice(new)
+	return len(oldSet.Diff(newSet)) > 0 || len(newSet.Diff(oldSet)) > 0
+}
+
 // SettingsChanged returns true if connection settings are different between
 // this and provided auth gateway configuration.
 func (gw *AuthGatewayV1) SettingsChanged(other AuthGateway) bool {
@@ -229,6 +250,12 @@ func (gw *AuthGatewayV1) SettingsChanged(other AuthGateway) bool {
 			gw.GetDisconnectExpiredCert().Value() != other.GetDisconnectExpiredCert().Value()) {
 		return true
 	}
+	if isProxyProtocolEnabled(gw) != isProxyProtocolEnabled(other) {
+		return true
+	}
+	if trustedProxiesChanged(gw.GetTrustedProxies(), other.GetTrustedProxies()) {
+		return true
+	}
 	return false
 }
 
@@ -262,6 +289,12 @@ func (gw *AuthGatewayV1) ApplyTo(other AuthGateway) {
 	if gw.Spec.WebPublicAddr != nil {
 		other.SetWebPublicAddrs(*gw.Spec.WebPublicAddr)
 	}
+	if v := gw.GetProxyProtocol(); v != nil {
+		other.SetProxyProtocol(*v)
+	}
+	if gw.Spec.TrustedProxies != nil {
+		other.SetTrustedProxies(*gw.Spec.TrustedProxies)
+	}
 }
 
 // Apply applies auth gateway settings to the provided config.
@@ -295,6 +328,14 @@ func (gw *AuthGatewayV1) ApplyToTeleportConfig(config *teleconfig.FileConfig) {
 			U2F:           u2f,
 		}
 	}
+	if gw.Spec.ProxyProtocol != nil {
+		value := "off"
+		if gw.Spec.ProxyProtocol.Value() {
+			value = "on"
+		}
+		config.Auth.ProxyProtocol = value
+		config.Proxy.ProxyProtocol = value
+	}
 	// Make sure user-set values take precedence as Teleport may just
 	// grab first value from the list, for example when advertising
 	// Kubernetes proxy public address.
@@ -461,6 +502,35 @@ func (gw *AuthGatewayV1) SetWebPublicAddrs(value []string) {
 	gw.Spec.WebPublicAddr = &value
 }
 
+// GetProxyProtocol returns the PROXY protocol setting.
+func (gw *AuthGatewayV1) GetProxyProtocol() *teleservices.Bool {
+	return gw.Spec.ProxyProtocol
+}
+
+// SetProxyProtocol sets the PROXY protocol setting on the resource.
+func (gw *AuthGatewayV1) SetProxyProtocol(value teleservices.Bool) {
+	gw.Spec.ProxyProtocol = &value
+}
+
+// GetTrustedProxies returns addresses of trusted load balancers.
+func (gw *AuthGatewayV1) GetTrustedProxies() []string {
+	if gw.Spec.TrustedProxies != nil {
+		return *gw.Spec.TrustedProxies
+	}
+	return nil
+}
+
+// SetTrustedProxies sets addresses of trusted load balancers.
+func (gw *AuthGatewayV1) SetTrustedProxies(value []string) {
+	gw.Spec.TrustedProxies = &value
+}
+
+// isProxyProtocolEnabled returns true if the provided auth gateway
+// enables the PROXY protocol.
+func isProxyProtocolEnabled(gw AuthGateway) bool {
+	return gw.GetProxyProtocol() != nil && gw.GetProxyProtocol().Value()
+}
+
 // CheckAndSetDefaults validates the resource and fills in some defaults.
 func (gw *AuthGatewayV1) CheckAndSetDefaults() error {
 	if gw.Metadata.Name == "" {
@@ -503,6 +573,10 @@ func (gw *AuthGatewayV1) CheckAndSetDefaults() error {
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	_, err = proxyproto.ParseTrustedProxies(gw.GetTrustedProxies())
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	return nil
 }
 
@@ -576,6 +650,14 @@ func (gw AuthGatewayV1) String() string {
 		parts = append(parts, fmt.Sprintf("WebPublicAddr=%v",
 			strings.Join(*gw.Spec.WebPublicAddr, ",")))
 	}
+	if gw.Spec.ProxyProtocol != nil {
+		parts = append(parts, fmt.Sprintf("ProxyProtocol=%v",
+			gw.Spec.ProxyProtocol.Value()))
+	}
+	if gw.Spec.TrustedProxies != nil {
+		parts = append(parts, fmt.Sprintf("TrustedProxies=%v",
+			strings.Join(*gw.Spec.TrustedProxies, ",")))
+	}
 	return fmt.Sprintf("AuthGatewayV1(%s)", strings.Join(parts, ","))
 }
 
@@ -637,6 +719,8 @@ var AuthGatewaySpecV1Schema = fmt.Sprintf(`{
     "public_addr": {"type": "array", "items": {"type": "string"}},
     "ssh_public_addr": {"type": "array", "items": {"type": "string"}},
     "kubernetes_public_addr": {"type": "array", "items": {"type": "string"}},
-    "web_public_addr": {"type": "array", "items": {"type": "string"}}
+    "web_public_addr": {"type": "array", "items": {"type": "string"}},
+    "proxy_protocol": {"type": "boolean"},
+    "trusted_proxies": {"type": "array", "items": {"type": "string"}}
   }
 }`, fmt.Sprintf(teleservices.AuthPreferenceSpecSchemaTemplate, ""))
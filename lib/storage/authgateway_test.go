@@ -50,6 +50,8 @@ spec:
   ssh_public_addr: ["ssh.example.com"]
   kubernetes_public_addr: ["k8s.example.com"]
   web_public_addr: ["web.example.com"]
+  proxy_protocol: true
+  trusted_proxies: ["10.0.0.0/16", "192.168.1.10"]
 `
 	gw, err := UnmarshalAuthGateway([]byte(spec))
 	c.Assert(err, check.IsNil)
@@ -69,6 +71,8 @@ spec:
 		SSHPublicAddr:         &[]string{"ssh.example.com"},
 		KubernetesPublicAddr:  &[]string{"k8s.example.com"},
 		WebPublicAddr:         &[]string{"web.example.com"},
+		ProxyProtocol:         teleservices.NewBoolOption(true),
+		TrustedProxies:        &[]string{"10.0.0.0/16", "192.168.1.10"},
 	}))
 }
 
@@ -167,6 +171,14 @@ version: v1
 spec:
   ssh_public_addr: [""]`,
 		},
+		{
+			desc: "Invalid trusted proxy",
+			spec: `kind: authgateway
+version: v1
+spec:
+  proxy_protocol: true
+  trusted_proxies: ["10.0.0.0/33"]`,
+		},
 	}
 	for _, t := range tests {
 		_, err := UnmarshalAuthGateway([]byte(t.spec))
@@ -203,6 +215,29 @@ func (s *AuthGatewaySuite) TestSettingsChanged(c *check.C) {
 			}),
 			result: false,
 		},
+		{
+			gw1: NewAuthGateway(AuthGatewaySpecV1{}),
+			gw2: NewAuthGateway(AuthGatewaySpecV1{
+				ProxyProtocol: teleservices.NewBoolOption(true),
+			}),
+			result: true,
+		},
+		{
+			gw1: NewAuthGateway(AuthGatewaySpecV1{}),
+			gw2: NewAuthGateway(AuthGatewaySpecV1{
+				ProxyProtocol: teleservices.NewBoolOption(false),
+			}),
+			result: false,
+		},
+		{
+			gw1: NewAuthGateway(AuthGatewaySpecV1{
+				TrustedProxies: &[]string{"10.0.0.0/16"},
+			}),
+			gw2: NewAuthGateway(AuthGatewaySpecV1{
+				TrustedProxies: &[]string{"10.0.0.0/16", "10.1.0.0/16"},
+			}),
+			result: true,
+		},
 	}
 	for _, tc := range testCases {
 		c.Assert(tc.gw1.SettingsChanged(tc.gw2), check.Equals, tc.result,
@@ -249,6 +284,7 @@ func (s *AuthGatewaySuite) TestApplyToTeleportConfig(c *check.C) {
 		},
 		PublicAddr:           &[]string{"example.com"},
 		KubernetesPublicAddr: &[]string{"k8s.example.com"},
+		ProxyProtocol:        teleservices.NewBoolOption(false),
 	})
 	var config teleconfig.FileConfig
 	gw.ApplyToTeleportConfig(&config)
@@ -265,11 +301,13 @@ func (s *AuthGatewaySuite) TestApplyToTeleportConfig(c *check.C) {
 				Type:         "oidc",
 				SecondFactor: "off",
 			},
-			PublicAddr: teleutils.Strings([]string{"example.com"}),
+			PublicAddr:    teleutils.Strings([]string{"example.com"}),
+			ProxyProtocol: "off",
 		},
 		Proxy: teleconfig.Proxy{
 			PublicAddr:    teleutils.Strings([]string{"example.com"}),
 			SSHPublicAddr: teleutils.Strings([]string{"example.com"}),
+			ProxyProtocol: "off",
 			Kube: teleconfig.Kube{
 				PublicAddr: teleutils.Strings([]string{"k8s.example.com"}),
 			},
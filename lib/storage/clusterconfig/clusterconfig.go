@@ -19,6 +19,7 @@ package clusterconfig
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gravitational/gravity/lib/constants"
@@ -29,6 +30,7 @@ import (
 	teleutils "github.com/gravitational/teleport/lib/utils"
 	"github.com/gravitational/trace"
 	"github.com/jonboulle/clockwork"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Interface manages cluster configuration
@@ -37,6 +39,8 @@ type Interface interface {
 	teleservices.Resource
 	// GetKubeletConfig returns the configuration of the kubelet
 	GetKubeletConfig() *Kubelet
+	// GetAPIServerConfig returns the configuration of the API server
+	GetAPIServerConfig() *APIServer
 	// GetGlobalConfig returns the global configuration
 	GetGlobalConfig() *Global
 	// SetCloudProvider sets the cloud provider for this configuration
@@ -104,6 +108,11 @@ func (r *Resource) GetKubeletConfig() *Kubelet {
 	return r.Spec.ComponentConfigs.Kubelet
 }
 
+// GetAPIServerConfig returns the configuration of the API server
+func (r *Resource) GetAPIServerConfig() *APIServer {
+	return r.Spec.ComponentConfigs.APIServer
+}
+
 // GetGlobalConfig returns the global configuration
 func (r *Resource) GetGlobalConfig() *Global {
 	return r.Spec.Global
@@ -186,6 +195,8 @@ type Spec struct {
 type ComponentConfigs struct {
 	// Kubelet defines kubelet configuration
 	Kubelet *Kubelet `json:"kubelet,omitempty"`
+	// APIServer defines API server configuration
+	APIServer *APIServer `json:"apiServer,omitempty"`
 }
 
 // Kubelet defines kubelet configuration
@@ -197,6 +208,43 @@ type Kubelet struct {
 	Config json.RawMessage `json:"config,omitempty"`
 }
 
+// Check validates the kubelet configuration
+func (r Kubelet) Check() error {
+	return trace.Wrap(checkExtraArgs(r.ExtraArgs))
+}
+
+// APIServer defines API server configuration
+type APIServer struct {
+	// ExtraArgs lists additional command line arguments
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+	// AuditPolicy defines the API server audit policy
+	// as a YAML- or JSON-formatted audit.k8s.io Policy resource
+	AuditPolicy string `json:"auditPolicy,omitempty"`
+}
+
+// Check validates the API server configuration
+func (r APIServer) Check() error {
+	if err := checkExtraArgs(r.ExtraArgs); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.AuditPolicy == "" {
+		return nil
+	}
+	data, err := teleutils.ToJSON([]byte(r.AuditPolicy))
+	if err != nil {
+		return trace.BadParameter("invalid audit policy: %v", err)
+	}
+	var hdr metav1.TypeMeta
+	if err := json.Unmarshal(data, &hdr); err != nil {
+		return trace.BadParameter("invalid audit policy: %v", err)
+	}
+	if hdr.Kind != "Policy" || !strings.HasPrefix(hdr.APIVersion, "audit.k8s.io/") {
+		return trace.BadParameter("audit policy should be an audit.k8s.io Policy resource, got %v %v",
+			hdr.APIVersion, hdr.Kind)
+	}
+	return nil
+}
+
 // ControlPlaneComponent defines configuration of a control plane component
 type ControlPlaneComponent struct {
 	json.RawMessage
@@ -225,6 +273,10 @@ type Global struct {
 	// If (unspecified, 0, or 0-0) then ports will be randomly chosen.
 	// Targets: kube-proxy
 	ProxyPortRange string `json:"proxyPortRange,omitempty"`
+	// OverlayMTU overrides the MTU of the overlay network interfaces
+	// otherwise derived from the path MTU between nodes during installation.
+	// Targets: flannel, docker
+	OverlayMTU int `json:"overlayMTU,omitempty"`
 	// FeatureGates defines the set of key=value pairs that describe feature gates for alpha/experimental features.
 	// Targets: all components
 	FeatureGates map[string]bool `json:"featureGates,omitempty"`
@@ -269,6 +321,7 @@ const specSchemaTemplate = `{
             "serviceNodePortRange": {"type": "string"},
             "poxyPortRange": {"type": "string"},
             "podCIDR": {"type": "string"},
+            "overlayMTU": {"type": "integer"},
             "featureGates": {
               "type": "object",
               "patternProperties": {
@@ -277,10 +330,17 @@ const specSchemaTemplate = `{
             }
           }
         },
+        "apiServer": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "extraArgs": {"type": "array", "items": {"type": "string"}},
+            "auditPolicy": {"type": "string"}
+          }
+        },
         "kubelet": {
           "type": "object",
           "additionalProperties": false,
-          "required": ["config"],
           "properties": {
             "config": {
               "type": "object",
@@ -379,6 +439,16 @@ const specSchemaTemplate = `{
   }
 }`
 
+// checkExtraArgs validates the specified command line arguments
+func checkExtraArgs(args []string) error {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") || len(arg) == len("--") {
+			return trace.BadParameter("invalid argument %q, expected --name or --name=value", arg)
+		}
+	}
+	return nil
+}
+
 // getSpecSchema returns the formatted JSON schema for the cluster configuration resource
 func getSpecSchema() string {
 	return fmt.Sprintf(specSchemaTemplate,
@@ -187,6 +187,35 @@ spec:
 			},
 			comment: "consumes global configuration",
 		},
+		{
+			in: `kind: clusterconfiguration
+version: v1
+spec:
+  apiServer:
+    extraArgs: ['--audit-log-maxage=30']
+    auditPolicy: |
+      apiVersion: audit.k8s.io/v1
+      kind: Policy
+      rules:
+      - level: Metadata`,
+			resource: &Resource{
+				Kind:    storage.KindClusterConfiguration,
+				Version: "v1",
+				Metadata: teleservices.Metadata{
+					Name:      constants.ClusterConfigurationMap,
+					Namespace: defaults.KubeSystemNamespace,
+				},
+				Spec: Spec{
+					ComponentConfigs: ComponentConfigs{
+						APIServer: &APIServer{
+							ExtraArgs:   []string{"--audit-log-maxage=30"},
+							AuditPolicy: "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n- level: Metadata",
+						},
+					},
+				},
+			},
+			comment: "consumes API server configuration",
+		},
 	}
 	for _, tc := range testCases {
 		comment := Commentf(tc.comment)
@@ -216,6 +245,21 @@ spec:
 	}
 }
 
+func (*S) TestValidatesComponentConfigs(c *C) {
+	c.Assert(Kubelet{ExtraArgs: []string{"--max-pods=200"}}.Check(), IsNil)
+	c.Assert(Kubelet{ExtraArgs: []string{"max-pods=200"}}.Check(), NotNil)
+	c.Assert(APIServer{
+		ExtraArgs: []string{"--audit-log-maxage=30"},
+		AuditPolicy: `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata`,
+	}.Check(), IsNil)
+	c.Assert(APIServer{ExtraArgs: []string{"--"}}.Check(), NotNil)
+	c.Assert(APIServer{AuditPolicy: "kind: ConfigMap\napiVersion: v1"}.Check(), NotNil)
+	c.Assert(APIServer{AuditPolicy: "{"}.Check(), NotNil)
+}
+
 func validate(expectedConfig kubeletConfiguration) func(obtained, expected *Resource, c *C) {
 	return func(obtained, expected *Resource, c *C) {
 		configBytes := obtained.Spec.ComponentConfigs.Kubelet.Config
@@ -0,0 +1,271 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+)
+
+// ClusterDNS describes the cluster DNS configuration
+type ClusterDNS interface {
+	// Resource provides common resource methods
+	teleservices.Resource
+	// CheckAndSetDefaults verifies that the object is valid
+	CheckAndSetDefaults() error
+	// GetProvider returns the DNS provider
+	GetProvider() string
+	// GetUpstream returns the upstream resolver configuration
+	GetUpstream() DNSUpstream
+	// GetForwarders returns the list of per-zone forwarders
+	GetForwarders() []DNSForwarder
+}
+
+const (
+	// DNSProviderCoreDNS configures CoreDNS as the cluster DNS server
+	DNSProviderCoreDNS = "coredns"
+	// DNSProviderNodeLocal configures CoreDNS as the cluster DNS server
+	// fronted by the node-local DNS cache on each node
+	DNSProviderNodeLocal = "node-local-dns"
+
+	// DNSUpstreamPolicySequential queries upstream servers in order
+	DNSUpstreamPolicySequential = "sequential"
+	// DNSUpstreamPolicyRandom queries upstream servers in random order
+	DNSUpstreamPolicyRandom = "random"
+	// DNSUpstreamPolicyRoundRobin queries upstream servers in round-robin fashion
+	DNSUpstreamPolicyRoundRobin = "round_robin"
+)
+
+// DNSProviders lists supported DNS providers
+var DNSProviders = []string{DNSProviderCoreDNS, DNSProviderNodeLocal}
+
+// DNSUpstreamPolicies lists supported upstream resolver policies
+var DNSUpstreamPolicies = []string{
+	DNSUpstreamPolicySequential,
+	DNSUpstreamPolicyRandom,
+	DNSUpstreamPolicyRoundRobin,
+}
+
+// NewClusterDNS creates a new DNS configuration resource from the provided spec
+func NewClusterDNS(spec ClusterDNSSpecV2) ClusterDNS {
+	return &ClusterDNSV2{
+		Kind:    KindClusterDNS,
+		Version: teleservices.V2,
+		Metadata: teleservices.Metadata{
+			Name:      KindClusterDNS,
+			Namespace: teledefaults.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// DefaultClusterDNS returns the DNS configuration resource with default parameters
+func DefaultClusterDNS() ClusterDNS {
+	return NewClusterDNS(ClusterDNSSpecV2{
+		Provider: DNSProviderCoreDNS,
+	})
+}
+
+// ClusterDNSV2 defines the cluster DNS configuration
+type ClusterDNSV2 struct {
+	// Metadata is resource metadata
+	teleservices.Metadata `json:"metadata"`
+	// Kind is a resource kind
+	Kind string `json:"kind"`
+	// Version is a resource version
+	Version string `json:"version"`
+	// Spec defines the DNS configuration
+	Spec ClusterDNSSpecV2 `json:"spec"`
+}
+
+// ClusterDNSSpecV2 defines the cluster DNS configuration
+type ClusterDNSSpecV2 struct {
+	// Provider specifies the DNS provider to render configuration for
+	Provider string `json:"provider,omitempty"`
+	// Upstream configures the upstream resolvers
+	Upstream DNSUpstream `json:"upstream,omitempty"`
+	// Forwarders lists nameservers to forward queries for specific zones to
+	Forwarders []DNSForwarder `json:"forwarders,omitempty"`
+}
+
+// DNSUpstream configures the resolvers to forward the non-cluster queries to
+type DNSUpstream struct {
+	// Policy specifies the upstream server selection policy.
+	// If unspecified, the policy is derived from the host's resolv.conf
+	Policy string `json:"policy,omitempty"`
+	// Nameservers optionally overrides the list of upstream nameservers.
+	// If unspecified, nameservers are taken from the host's resolv.conf
+	Nameservers []string `json:"nameservers,omitempty"`
+}
+
+// DNSForwarder forwards queries for the specified zone to a set of nameservers
+type DNSForwarder struct {
+	// Zone is the DNS zone
+	Zone string `json:"zone"`
+	// Nameservers lists the nameservers serving the zone
+	Nameservers []string `json:"nameservers"`
+}
+
+// GetProvider returns the DNS provider
+func (r *ClusterDNSV2) GetProvider() string {
+	return r.Spec.Provider
+}
+
+// GetUpstream returns the upstream resolver configuration
+func (r *ClusterDNSV2) GetUpstream() DNSUpstream {
+	return r.Spec.Upstream
+}
+
+// GetForwarders returns the list of per-zone forwarders
+func (r *ClusterDNSV2) GetForwarders() []DNSForwarder {
+	return r.Spec.Forwarders
+}
+
+// CheckAndSetDefaults checks validity of all parameters and sets defaults
+func (r *ClusterDNSV2) CheckAndSetDefaults() error {
+	if r.Metadata.Name == "" {
+		r.Metadata.Name = KindClusterDNS
+	}
+	if err := r.Metadata.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Spec.Provider == "" {
+		r.Spec.Provider = DNSProviderCoreDNS
+	}
+	if !utils.StringInSlice(DNSProviders, r.Spec.Provider) {
+		return trace.BadParameter("unsupported DNS provider %q, supported are: %v",
+			r.Spec.Provider, DNSProviders)
+	}
+	if r.Spec.Upstream.Policy != "" && !utils.StringInSlice(DNSUpstreamPolicies, r.Spec.Upstream.Policy) {
+		return trace.BadParameter("unsupported upstream policy %q, supported are: %v",
+			r.Spec.Upstream.Policy, DNSUpstreamPolicies)
+	}
+	if err := checkNameservers(r.Spec.Upstream.Nameservers); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, forwarder := range r.Spec.Forwarders {
+		if forwarder.Zone == "" {
+			return trace.BadParameter("forwarder zone cannot be empty")
+		}
+		if len(forwarder.Nameservers) == 0 {
+			return trace.BadParameter("forwarder for zone %q needs at least one nameserver",
+				forwarder.Zone)
+		}
+		if err := checkNameservers(forwarder.Nameservers); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// String returns a textual representation of this DNS configuration
+func (r *ClusterDNSV2) String() string {
+	return fmt.Sprintf("ClusterDNSV2(Provider=%v, Upstream=%v, Forwarders=%v)",
+		r.Spec.Provider, r.Spec.Upstream, r.Spec.Forwarders)
+}
+
+func checkNameservers(nameservers []string) error {
+	for _, nameserver := range nameservers {
+		host := nameserver
+		if h, _, err := net.SplitHostPort(nameserver); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			return trace.BadParameter("nameserver %q should be an IP address with optional port",
+				nameserver)
+		}
+	}
+	return nil
+}
+
+// UnmarshalClusterDNS unmarshals DNS configuration from JSON or YAML
+func UnmarshalClusterDNS(data []byte) (ClusterDNS, error) {
+	if len(data) == 0 {
+		return nil, trace.BadParameter("empty configuration")
+	}
+	jsonData, err := teleutils.ToJSON(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var hdr teleservices.ResourceHeader
+	err = json.Unmarshal(jsonData, &hdr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch hdr.Version {
+	case teleservices.V2:
+		var config ClusterDNSV2
+		err := teleutils.UnmarshalWithSchema(GetClusterDNSSchema(), &config, jsonData)
+		if err != nil {
+			return nil, trace.BadParameter("%v", err)
+		}
+		if err := config.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &config, nil
+	}
+	return nil, trace.BadParameter(
+		"%v resource version %q is not supported", KindClusterDNS, hdr.Version)
+}
+
+// MarshalClusterDNS marshals DNS configuration into JSON
+func MarshalClusterDNS(config ClusterDNS, opts ...teleservices.MarshalOption) ([]byte, error) {
+	return json.Marshal(config)
+}
+
+// ClusterDNSSpecV2Schema is JSON schema for the DNS configuration
+const ClusterDNSSpecV2Schema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "provider": {"type": "string"},
+    "upstream": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "policy": {"type": "string"},
+        "nameservers": {"type": "array", "items": {"type": "string"}}
+      }
+    },
+    "forwarders": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["zone", "nameservers"],
+        "properties": {
+          "zone": {"type": "string"},
+          "nameservers": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    }
+  }
+}`
+
+// GetClusterDNSSchema returns the DNS configuration schema for version V2
+func GetClusterDNSSchema() string {
+	return fmt.Sprintf(teleservices.V2SchemaTemplate, MetadataSchema,
+		ClusterDNSSpecV2Schema, "")
+}
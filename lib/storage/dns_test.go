@@ -0,0 +1,106 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/gravitational/gravity/lib/compare"
+
+	check "gopkg.in/check.v1"
+)
+
+type ClusterDNSSuite struct{}
+
+var _ = check.Suite(&ClusterDNSSuite{})
+
+func (s *ClusterDNSSuite) TestResourceParsing(c *check.C) {
+	spec := `kind: dns
+version: v2
+spec:
+  provider: node-local-dns
+  upstream:
+    policy: round_robin
+    nameservers: ["1.1.1.1", "8.8.8.8:53"]
+  forwarders:
+  - zone: example.com
+    nameservers: ["10.0.0.1"]
+`
+	config, err := UnmarshalClusterDNS([]byte(spec))
+	c.Assert(err, check.IsNil)
+	expected := NewClusterDNS(ClusterDNSSpecV2{
+		Provider: DNSProviderNodeLocal,
+		Upstream: DNSUpstream{
+			Policy:      DNSUpstreamPolicyRoundRobin,
+			Nameservers: []string{"1.1.1.1", "8.8.8.8:53"},
+		},
+		Forwarders: []DNSForwarder{
+			{Zone: "example.com", Nameservers: []string{"10.0.0.1"}},
+		},
+	})
+	c.Assert(config, compare.DeepEquals, expected)
+}
+
+func (s *ClusterDNSSuite) TestDefaults(c *check.C) {
+	config, err := UnmarshalClusterDNS([]byte(`kind: dns
+version: v2
+spec: {}`))
+	c.Assert(err, check.IsNil)
+	c.Assert(config, compare.DeepEquals, DefaultClusterDNS())
+}
+
+func (s *ClusterDNSSuite) TestResourceValidation(c *check.C) {
+	tests := []struct {
+		desc string
+		spec string
+	}{
+		{
+			desc: "Unsupported provider",
+			spec: `kind: dns
+version: v2
+spec:
+  provider: bind`,
+		},
+		{
+			desc: "Unsupported upstream policy",
+			spec: `kind: dns
+version: v2
+spec:
+  upstream:
+    policy: fastest`,
+		},
+		{
+			desc: "Invalid upstream nameserver",
+			spec: `kind: dns
+version: v2
+spec:
+  upstream:
+    nameservers: ["dns.example.com"]`,
+		},
+		{
+			desc: "Forwarder without nameservers",
+			spec: `kind: dns
+version: v2
+spec:
+  forwarders:
+  - zone: example.com
+    nameservers: []`,
+		},
+	}
+	for _, t := range tests {
+		_, err := UnmarshalClusterDNS([]byte(t.spec))
+		c.Assert(err, check.NotNil, check.Commentf("Test case %q failed.", t.desc))
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+)
+
+// HealthReport describes the configuration of the scheduled cluster health reports
+type HealthReport interface {
+	// Resource provides common resource methods
+	teleservices.Resource
+	// CheckAndSetDefaults verifies that the object is valid
+	CheckAndSetDefaults() error
+	// GetSchedule returns the report schedule: daily or weekly
+	GetSchedule() string
+	// GetInterval returns the interval between reports
+	GetInterval() time.Duration
+	// GetRecipients returns the list of email recipients
+	GetRecipients() []string
+	// GetFrom returns the email sender address
+	GetFrom() string
+	// GetWebhookURL returns the URL to POST the report to
+	GetWebhookURL() string
+	// GetLastSent returns the time the last report has been sent
+	GetLastSent() time.Time
+	// SetLastSent sets the time the last report has been sent
+	SetLastSent(time.Time)
+}
+
+const (
+	// HealthReportDaily sends health reports once a day
+	HealthReportDaily = "daily"
+	// HealthReportWeekly sends health reports once a week
+	HealthReportWeekly = "weekly"
+)
+
+// HealthReportSchedules lists supported health report schedules
+var HealthReportSchedules = []string{HealthReportDaily, HealthReportWeekly}
+
+// NewHealthReport creates a new health report configuration resource from the provided spec
+func NewHealthReport(spec HealthReportSpecV2) HealthReport {
+	return &HealthReportV2{
+		Kind:    KindHealthReport,
+		Version: teleservices.V2,
+		Metadata: teleservices.Metadata{
+			Name:      KindHealthReport,
+			Namespace: teledefaults.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// HealthReportV2 defines the configuration of scheduled cluster health reports
+type HealthReportV2 struct {
+	// Metadata is resource metadata
+	teleservices.Metadata `json:"metadata"`
+	// Kind is a resource kind
+	Kind string `json:"kind"`
+	// Version is a resource version
+	Version string `json:"version"`
+	// Spec defines the health report configuration
+	Spec HealthReportSpecV2 `json:"spec"`
+	// Status describes the state of the health reports
+	Status HealthReportStatusV2 `json:"status,omitempty"`
+}
+
+// HealthReportSpecV2 defines the configuration of scheduled cluster health reports
+type HealthReportSpecV2 struct {
+	// Schedule specifies how often reports are sent: daily or weekly
+	Schedule string `json:"schedule,omitempty"`
+	// Recipients lists email addresses to send the report to.
+	// Requires cluster SMTP configuration
+	Recipients []string `json:"recipients,omitempty"`
+	// From specifies the sender email address.
+	// Defaults to the SMTP username
+	From string `json:"from,omitempty"`
+	// WebhookURL specifies the URL to POST the report to in JSON format
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// HealthReportStatusV2 describes the state of the health reports
+type HealthReportStatusV2 struct {
+	// LastSent is the time the last report has been sent
+	LastSent time.Time `json:"last_sent,omitempty"`
+}
+
+// GetSchedule returns the report schedule
+func (r *HealthReportV2) GetSchedule() string {
+	return r.Spec.Schedule
+}
+
+// GetInterval returns the interval between reports
+func (r *HealthReportV2) GetInterval() time.Duration {
+	if r.Spec.Schedule == HealthReportWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// GetRecipients returns the list of email recipients
+func (r *HealthReportV2) GetRecipients() []string {
+	return r.Spec.Recipients
+}
+
+// GetFrom returns the email sender address
+func (r *HealthReportV2) GetFrom() string {
+	return r.Spec.From
+}
+
+// GetWebhookURL returns the URL to POST the report to
+func (r *HealthReportV2) GetWebhookURL() string {
+	return r.Spec.WebhookURL
+}
+
+// GetLastSent returns the time the last report has been sent
+func (r *HealthReportV2) GetLastSent() time.Time {
+	return r.Status.LastSent
+}
+
+// SetLastSent sets the time the last report has been sent
+func (r *HealthReportV2) SetLastSent(t time.Time) {
+	r.Status.LastSent = t
+}
+
+// CheckAndSetDefaults checks validity of all parameters and sets defaults
+func (r *HealthReportV2) CheckAndSetDefaults() error {
+	if r.Metadata.Name == "" {
+		r.Metadata.Name = KindHealthReport
+	}
+	if err := r.Metadata.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Spec.Schedule == "" {
+		r.Spec.Schedule = HealthReportDaily
+	}
+	if !utils.StringInSlice(HealthReportSchedules, r.Spec.Schedule) {
+		return trace.BadParameter("unsupported schedule %q, supported are: %v",
+			r.Spec.Schedule, HealthReportSchedules)
+	}
+	if len(r.Spec.Recipients) == 0 && r.Spec.WebhookURL == "" {
+		return trace.BadParameter("at least one recipient or webhook URL is required")
+	}
+	for _, recipient := range r.Spec.Recipients {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return trace.BadParameter("invalid recipient address %q", recipient)
+		}
+	}
+	if r.Spec.From != "" {
+		if _, err := mail.ParseAddress(r.Spec.From); err != nil {
+			return trace.BadParameter("invalid sender address %q", r.Spec.From)
+		}
+	}
+	if r.Spec.WebhookURL != "" {
+		u, err := url.ParseRequestURI(r.Spec.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return trace.BadParameter("webhook URL %q should be an http or https URL",
+				r.Spec.WebhookURL)
+		}
+	}
+	return nil
+}
+
+// String returns a textual representation of this health report configuration
+func (r *HealthReportV2) String() string {
+	return fmt.Sprintf("HealthReportV2(Schedule=%v, Recipients=%v, WebhookURL=%v)",
+		r.Spec.Schedule, r.Spec.Recipients, r.Spec.WebhookURL)
+}
+
+// UnmarshalHealthReport unmarshals health report configuration from JSON or YAML
+func UnmarshalHealthReport(data []byte) (HealthReport, error) {
+	if len(data) == 0 {
+		return nil, trace.BadParameter("empty configuration")
+	}
+	jsonData, err := teleutils.ToJSON(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var hdr teleservices.ResourceHeader
+	err = json.Unmarshal(jsonData, &hdr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch hdr.Version {
+	case teleservices.V2:
+		var config HealthReportV2
+		err := teleutils.UnmarshalWithSchema(GetHealthReportSchema(), &config, jsonData)
+		if err != nil {
+			return nil, trace.BadParameter("%v", err)
+		}
+		if err := config.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &config, nil
+	}
+	return nil, trace.BadParameter(
+		"%v resource version %q is not supported", KindHealthReport, hdr.Version)
+}
+
+// MarshalHealthReport marshals health report configuration into JSON
+func MarshalHealthReport(config HealthReport, opts ...teleservices.MarshalOption) ([]byte, error) {
+	return json.Marshal(config)
+}
+
+// HealthReportSpecV2Schema is JSON schema for the health report configuration
+const HealthReportSpecV2Schema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "schedule": {"type": "string"},
+    "recipients": {"type": "array", "items": {"type": "string"}},
+    "from": {"type": "string"},
+    "webhook_url": {"type": "string"}
+  }
+}`
+
+// HealthReportStatusV2Schema is JSON schema for the health report status
+const HealthReportStatusV2Schema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "last_sent": {"type": "string"}
+  }
+}`
+
+// GetHealthReportSchema returns the health report configuration schema for version V2
+func GetHealthReportSchema() string {
+	return fmt.Sprintf(healthReportSchemaTemplate, MetadataSchema,
+		HealthReportSpecV2Schema, HealthReportStatusV2Schema)
+}
+
+// healthReportSchemaTemplate is the V2 resource schema template
+// extended with the status section
+const healthReportSchemaTemplate = `{
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["kind", "spec", "metadata", "version"],
+  "properties": {
+    "kind": {"type": "string"},
+    "version": {"type": "string", "default": "v2"},
+    "metadata": %v,
+    "spec": %v,
+    "status": %v
+  }
+}`
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/compare"
+
+	check "gopkg.in/check.v1"
+)
+
+type HealthReportSuite struct{}
+
+var _ = check.Suite(&HealthReportSuite{})
+
+func (s *HealthReportSuite) TestResourceParsing(c *check.C) {
+	spec := `kind: healthreport
+version: v2
+spec:
+  schedule: weekly
+  recipients: ["ops@example.com"]
+  webhook_url: https://hooks.example.com/gravity
+`
+	config, err := UnmarshalHealthReport([]byte(spec))
+	c.Assert(err, check.IsNil)
+	expected := NewHealthReport(HealthReportSpecV2{
+		Schedule:   HealthReportWeekly,
+		Recipients: []string{"ops@example.com"},
+		WebhookURL: "https://hooks.example.com/gravity",
+	})
+	c.Assert(config, compare.DeepEquals, expected)
+	c.Assert(config.GetInterval(), check.Equals, 7*24*time.Hour)
+}
+
+func (s *HealthReportSuite) TestPreservesStatus(c *check.C) {
+	config := NewHealthReport(HealthReportSpecV2{
+		WebhookURL: "http://localhost:8080",
+	})
+	c.Assert(config.CheckAndSetDefaults(), check.IsNil)
+	lastSent := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	config.SetLastSent(lastSent)
+	data, err := MarshalHealthReport(config)
+	c.Assert(err, check.IsNil)
+	parsed, err := UnmarshalHealthReport(data)
+	c.Assert(err, check.IsNil)
+	c.Assert(parsed.GetSchedule(), check.Equals, HealthReportDaily)
+	c.Assert(parsed.GetLastSent().Equal(lastSent), check.Equals, true)
+}
+
+func (s *HealthReportSuite) TestValidation(c *check.C) {
+	var testCases = []struct {
+		spec    string
+		comment string
+	}{
+		{
+			spec:    `{"kind": "healthreport", "version": "v2", "spec": {}}`,
+			comment: "no recipients",
+		},
+		{
+			spec:    `{"kind": "healthreport", "version": "v2", "spec": {"schedule": "hourly", "webhook_url": "http://localhost"}}`,
+			comment: "unsupported schedule",
+		},
+		{
+			spec:    `{"kind": "healthreport", "version": "v2", "spec": {"recipients": ["not an address"]}}`,
+			comment: "invalid recipient",
+		},
+		{
+			spec:    `{"kind": "healthreport", "version": "v2", "spec": {"webhook_url": "ftp://localhost"}}`,
+			comment: "invalid webhook URL",
+		},
+	}
+	for _, tc := range testCases {
+		_, err := UnmarshalHealthReport([]byte(tc.spec))
+		c.Assert(err, check.NotNil, check.Commentf(tc.comment))
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"github.com/pborman/uuid"
+)
+
+func (b *backend) CreateAuditEvent(e storage.AuditEvent) (*storage.AuditEvent, error) {
+	if err := e.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if e.ID == "" {
+		e.ID = uuid.New()
+	}
+	if e.Created.IsZero() {
+		e.Created = b.Now().UTC()
+	}
+	err := b.createVal(b.key(auditP, e.ID), e, forever)
+	if err != nil {
+		if trace.IsAlreadyExists(err) {
+			return nil, trace.Wrap(err, "audit event(%v) already exists", e.ID)
+		}
+		return nil, trace.Wrap(err)
+	}
+	return &e, nil
+}
+
+func (b *backend) GetAuditEvents(since time.Time) ([]storage.AuditEvent, error) {
+	ids, err := b.getKeys(b.key(auditP))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.AuditEvent
+	for _, id := range ids {
+		var e storage.AuditEvent
+		err := b.getVal(b.key(auditP, id), &e)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		if e.Created.Before(since) {
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+	return out, nil
+}
+
+func (b *backend) DeleteAuditEvent(id string) error {
+	err := b.deleteKey(b.key(auditP, id))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("audit event(%v) not found", id)
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
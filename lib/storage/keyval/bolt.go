@@ -18,6 +18,7 @@ package keyval
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"sort"
@@ -70,6 +71,10 @@ type BoltConfig struct {
 	Readonly bool `json:"readonly"`
 	// Multi enables multi-client support
 	Multi bool `json:"multi"`
+	// Snapshot makes opening the database non-blocking.
+	// If the database file is locked by another process, a read-only copy
+	// of the database file is opened instead
+	Snapshot bool `json:"snapshot"`
 	// When left unspecified, it will block for maximum of defaults.DBOpenTimeout.
 	// When set to a negative duration, it will fail immediately if the file is already locked.
 	// This option is only available on Darwin and Linux.
@@ -114,10 +119,18 @@ type blt struct {
 	clock clockwork.Clock
 	path  string
 	locks map[string]time.Time
+	// snapshot is the path to the database snapshot this engine reads from.
+	// The snapshot is removed when the engine is closed
+	snapshot string
+	// watchers receives the changes made with this engine
+	watchers broadcaster
 }
 
 // newBolt returns a new instance of BoltDB backend
 func newBolt(cfg BoltConfig, codec Codec) (*blt, error) {
+	if cfg.Snapshot {
+		return newSnapshotBolt(cfg, codec)
+	}
 	path, err := filepath.Abs(cfg.Path)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -275,7 +288,7 @@ func (b *blt) upsertDir(key key, ttl time.Duration) error {
 
 func (b *blt) createValBytes(k key, data []byte, ttl time.Duration) error {
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypePut, k, func(tx *bolt.Tx) error {
 		bkt, err := upsertBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -294,7 +307,7 @@ func (b *blt) createVal(k key, val interface{}, ttl time.Duration) error {
 		return trace.Wrap(err)
 	}
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypePut, k, func(tx *bolt.Tx) error {
 		bkt, err := upsertBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -309,7 +322,7 @@ func (b *blt) createVal(k key, val interface{}, ttl time.Duration) error {
 
 func (b *blt) upsertValBytes(k key, encoded []byte, ttl time.Duration) error {
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypePut, k, func(tx *bolt.Tx) error {
 		bkt, err := upsertBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -324,7 +337,7 @@ func (b *blt) upsertVal(k key, val interface{}, ttl time.Duration) error {
 		return trace.Wrap(err)
 	}
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypePut, k, func(tx *bolt.Tx) error {
 		bkt, err := upsertBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -335,7 +348,7 @@ func (b *blt) upsertVal(k key, val interface{}, ttl time.Duration) error {
 
 func (b *blt) updateValBytes(k key, data []byte, ttl time.Duration) error {
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypePut, k, func(tx *bolt.Tx) error {
 		bkt, err := upsertBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -354,7 +367,7 @@ func (b *blt) updateVal(k key, val interface{}, ttl time.Duration) error {
 		return trace.Wrap(err)
 	}
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypePut, k, func(tx *bolt.Tx) error {
 		bkt, err := upsertBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -400,7 +413,7 @@ func (b *blt) compareAndSwap(k key, val interface{}, prevVal interface{}, outVal
 
 func (b *blt) compareAndSwapBytes(k key, val, prevVal []byte, outVal *[]byte, ttl time.Duration) error {
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypePut, k, func(tx *bolt.Tx) error {
 		bkt, err := upsertBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -476,7 +489,7 @@ func (b *blt) getVal(k key, outVal interface{}) error {
 
 func (b *blt) compareAndDelete(k key, prevVal interface{}) error {
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypeDelete, k, func(tx *bolt.Tx) error {
 		bkt, err := getBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -499,7 +512,7 @@ func (b *blt) compareAndDelete(k key, prevVal interface{}) error {
 
 func (b *blt) deleteKey(k key) error {
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypeDelete, k, func(tx *bolt.Tx) error {
 		bkt, err := getBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -513,7 +526,7 @@ func (b *blt) deleteKey(k key) error {
 
 func (b *blt) deleteDir(k key) error {
 	buckets, key := b.split(k)
-	return b.db.Update(func(tx *bolt.Tx) error {
+	return b.updateAndPublish(storage.EventTypeDelete, k, func(tx *bolt.Tx) error {
 		bkt, err := getBucket(tx, buckets)
 		if err != nil {
 			return trace.Wrap(err)
@@ -548,6 +561,22 @@ func (b *blt) releaseLock(key key) error {
 	return b.deleteKey(key)
 }
 
+// updateAndPublish runs fn in a read-write transaction and notifies
+// the watchers about the change to the key k if the transaction succeeds
+func (b *blt) updateAndPublish(typ storage.EventType, k key, fn func(*bolt.Tx) error) error {
+	if err := b.db.Update(fn); err != nil {
+		return err
+	}
+	b.watchers.publish(typ, k)
+	return nil
+}
+
+// watch starts watching the keys with the specified prefix.
+// Only the changes made by this process are observed
+func (b *blt) watch(ctx context.Context, prefix key) (<-chan kvEvent, error) {
+	return b.watchers.watch(ctx, prefix)
+}
+
 func (b *blt) getKeys(key key) ([]string, error) {
 	out := []string{}
 	buckets := key
@@ -579,11 +608,17 @@ func (b *blt) Close() error {
 	if b.db == nil {
 		return trace.AlreadyExists("database %v is already closed", b.path)
 	}
+	b.watchers.closeAll()
 	err := b.db.Close()
 	if err != nil {
 		return trace.Wrap(err)
 	}
 	b.db = nil
+	if b.snapshot != "" {
+		if err := os.Remove(b.snapshot); err != nil {
+			return trace.ConvertSystemError(err)
+		}
+	}
 	return nil
 }
 
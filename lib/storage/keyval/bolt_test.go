@@ -129,6 +129,34 @@ func (s *BSuite) TestProvisioningTokensCRUD(c *C) {
 	s.suite.ProvisioningTokensCRUD(c)
 }
 
+func (s *BSuite) TestOperationApprovalsCRUD(c *C) {
+	s.suite.OperationApprovalsCRUD(c)
+}
+
+func (s *BSuite) TestDownloadTokensCRUD(c *C) {
+	s.suite.DownloadTokensCRUD(c)
+}
+
+func (s *BSuite) TestOperationTokensCRUD(c *C) {
+	s.suite.OperationTokensCRUD(c)
+}
+
+func (s *BSuite) TestVolumeSnapshotsCRUD(c *C) {
+	s.suite.VolumeSnapshotsCRUD(c)
+}
+
+func (s *BSuite) TestReleaseRecordsCRUD(c *C) {
+	s.suite.ReleaseRecordsCRUD(c)
+}
+
+func (s *BSuite) TestResourceRevisionsCRUD(c *C) {
+	s.suite.ResourceRevisionsCRUD(c)
+}
+
+func (s *BSuite) TestPackageUploadsCRUD(c *C) {
+	s.suite.PackageUploadsCRUD(c)
+}
+
 func (s *BSuite) TestAPIKeys(c *C) {
 	s.suite.APIKeysCRUD(c)
 }
@@ -217,6 +245,10 @@ func (s *BSuite) TestNamespacesCRUD(c *C) {
 	s.suite.NamespacesCRUD(c)
 }
 
+func (s *BSuite) TestWatch(c *C) {
+	s.suite.Watch(c)
+}
+
 func (s *BSuite) TestLoginAttempts(c *C) {
 	s.suite.LoginAttempts(c)
 }
@@ -240,3 +272,28 @@ func (s *BSuite) TestClusterLogin(c *C) {
 func (s *BSuite) TestIndexFile(c *C) {
 	s.suite.IndexFile(c)
 }
+
+func (s *BSuite) TestAuditEventsCRUD(c *C) {
+	s.suite.AuditEventsCRUD(c)
+}
+
+func (s *BSuite) TestReadsSnapshotOfLockedDatabase(c *C) {
+	account, err := s.backend.backend.CreateAccount(storage.Account{Org: "test"})
+	c.Assert(err, IsNil)
+
+	// s.backend holds the exclusive lock on the database file
+	snapshot, err := NewBolt(BoltConfig{
+		Path:     filepath.Join(s.backend.dir, "bolt.db"),
+		Multi:    true,
+		Snapshot: true,
+	})
+	c.Assert(err, IsNil)
+	defer snapshot.Close()
+
+	read, err := snapshot.GetAccount(account.ID)
+	c.Assert(err, IsNil)
+	c.Assert(read, DeepEquals, account)
+
+	_, err = snapshot.CreateAccount(storage.Account{Org: "test2"})
+	c.Assert(err, NotNil)
+}
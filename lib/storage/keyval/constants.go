@@ -51,6 +51,9 @@ const (
 	authRequestsP               = "authreqs"
 	provisioningTokensP         = "provtokens"
 	installTokensP              = "installtokens"
+	operationApprovalsP         = "opapprovals"
+	downloadTokensP             = "downloadtokens"
+	operationTokensP            = "operationtokens"
 	invitesP                    = "invites"
 	loginsP                     = "logins"
 	changesetsP                 = "changesets"
@@ -77,6 +80,12 @@ const (
 	dnsP                        = "dns"
 	chartsP                     = "charts"
 	indexP                      = "index"
+	auditP                      = "audit"
+	volumeSnapshotsP            = "volumesnapshots"
+	releaseRecordsP             = "releaserecords"
+	resourceRevisionsP          = "resourcerevisions"
+	packageUploadsP             = "packageuploads"
+	partsP                      = "parts"
 
 	// AllCollectionIDs identifies a collection without a specification (an ID)
 	AllCollectionIDs = "__all__"
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+func (b *backend) CreateDownloadToken(t storage.DownloadToken) (*storage.DownloadToken, error) {
+	if err := t.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err := b.createVal(b.key(downloadTokensP, t.Token), t, b.ttl(t.Expires))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &t, nil
+}
+
+func (b *backend) GetDownloadToken(token string) (*storage.DownloadToken, error) {
+	if token == "" {
+		return nil, trace.BadParameter("missing download token")
+	}
+	var t storage.DownloadToken
+	err := b.getVal(b.key(downloadTokensP, token), &t)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("download token not found")
+		}
+		return nil, trace.Wrap(err)
+	}
+	utils.UTC(&t.Created)
+	utils.UTC(&t.Expires)
+	// not all backends honor TTL so check the expiration explicitly
+	if !b.Now().UTC().Before(t.Expires) {
+		return nil, trace.NotFound("download token has expired")
+	}
+	return &t, nil
+}
+
+func (b *backend) GetDownloadTokens(accountID string) ([]storage.DownloadToken, error) {
+	tokens, err := b.getKeys(b.key(downloadTokensP))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.DownloadToken
+	for _, token := range tokens {
+		t, err := b.GetDownloadToken(token)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		if t.AccountID == accountID {
+			out = append(out, *t)
+		}
+	}
+	return out, nil
+}
+
+func (b *backend) DeleteDownloadToken(token string) error {
+	if token == "" {
+		return trace.BadParameter("missing download token")
+	}
+	err := b.deleteKey(b.key(downloadTokensP, token))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("download token not found")
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/cenkalti/backoff"
@@ -453,6 +454,52 @@ func (e *engine) getKeys(key key) ([]string, error) {
 	return vals, nil
 }
 
+// watch starts watching the keys with the specified prefix for changes
+// made by any etcd client
+func (e *engine) watch(ctx context.Context, prefix key) (<-chan kvEvent, error) {
+	watcher := e.Watcher(ekey(prefix), &client.WatcherOptions{Recursive: true})
+	eventsC := make(chan kvEvent)
+	go func() {
+		defer close(eventsC)
+		for {
+			resp, err := watcher.Next(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.WithError(err).Warnf("Failed to watch %v.", ekey(prefix))
+				}
+				return
+			}
+			event := kvEvent{
+				typ: storage.EventTypePut,
+				key: watchedKey(prefix, resp.Node.Key),
+			}
+			switch resp.Action {
+			case "delete", "compareAndDelete", "expire":
+				event.typ = storage.EventTypeDelete
+			}
+			select {
+			case eventsC <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return eventsC, nil
+}
+
+// watchedKey converts the etcd key reported by the watch on prefix to key
+func watchedKey(prefix key, etcdKey string) key {
+	rel := strings.TrimPrefix(strings.TrimPrefix(etcdKey, "/"),
+		strings.TrimPrefix(ekey(prefix), "/"))
+	out := append(key{}, prefix...)
+	for _, part := range strings.Split(rel, "/") {
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 func convertErr(e error) error {
 	if e == nil {
 		return nil
@@ -153,6 +153,34 @@ func (s *ESuite) TestProvisioningTokensCRUD(c *C) {
 	s.suite.ProvisioningTokensCRUD(c)
 }
 
+func (s *ESuite) TestOperationApprovalsCRUD(c *C) {
+	s.suite.OperationApprovalsCRUD(c)
+}
+
+func (s *ESuite) TestDownloadTokensCRUD(c *C) {
+	s.suite.DownloadTokensCRUD(c)
+}
+
+func (s *ESuite) TestOperationTokensCRUD(c *C) {
+	s.suite.OperationTokensCRUD(c)
+}
+
+func (s *ESuite) TestVolumeSnapshotsCRUD(c *C) {
+	s.suite.VolumeSnapshotsCRUD(c)
+}
+
+func (s *ESuite) TestReleaseRecordsCRUD(c *C) {
+	s.suite.ReleaseRecordsCRUD(c)
+}
+
+func (s *ESuite) TestResourceRevisionsCRUD(c *C) {
+	s.suite.ResourceRevisionsCRUD(c)
+}
+
+func (s *ESuite) TestPackageUploadsCRUD(c *C) {
+	s.suite.PackageUploadsCRUD(c)
+}
+
 func (s *ESuite) TestAPIKeys(c *C) {
 	s.suite.APIKeysCRUD(c)
 }
@@ -241,6 +269,10 @@ func (s *ESuite) TestNamespacesCRUD(c *C) {
 	s.suite.NamespacesCRUD(c)
 }
 
+func (s *ESuite) TestWatch(c *C) {
+	s.suite.Watch(c)
+}
+
 func (s *ESuite) TestLoginAttempts(c *C) {
 	s.suite.LoginAttempts(c)
 }
@@ -264,3 +296,7 @@ func (s *ESuite) TestClusterLogin(c *C) {
 func (s *ESuite) TestIndexFile(c *C) {
 	s.suite.IndexFile(c)
 }
+
+func (s *ESuite) TestAuditEventsCRUD(c *C) {
+	s.suite.AuditEventsCRUD(c)
+}
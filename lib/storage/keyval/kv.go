@@ -17,6 +17,7 @@ limitations under the License.
 package keyval
 
 import (
+	"context"
 	"io"
 	"time"
 )
@@ -45,6 +46,9 @@ type kvengine interface {
 	tryAcquireLock(token key, ttl time.Duration) error
 	releaseLock(token key) error
 	getKeys(key key) ([]string, error)
+	// watch starts watching the keys with the specified prefix for changes.
+	// The returned channel is closed when ctx is cancelled or the watch fails
+	watch(ctx context.Context, prefix key) (<-chan kvEvent, error)
 }
 
 type key []string
@@ -17,6 +17,7 @@ limitations under the License.
 package keyval
 
 import (
+	"context"
 	"time"
 
 	"github.com/gravitational/trace"
@@ -161,6 +162,11 @@ func (b *multiBolt) getKeys(key key) (keys []string, err error) {
 	return keys, trace.Wrap(err)
 }
 
+// watch is not supported since the changes can be made by other processes
+func (b *multiBolt) watch(ctx context.Context, prefix key) (<-chan kvEvent, error) {
+	return nil, trace.NotImplemented("multi-client bolt backend does not support watches")
+}
+
 func (b *multiBolt) key(prefix string, keys ...string) key {
 	return append([]string{"root", prefix}, keys...)
 }
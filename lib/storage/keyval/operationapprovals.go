@@ -0,0 +1,106 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+func (b *backend) CreateOperationApproval(a storage.OperationApproval) (*storage.OperationApproval, error) {
+	if err := a.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err := b.createVal(b.key(operationApprovalsP, a.ID), a, b.ttl(a.Expires))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &a, nil
+}
+
+func (b *backend) GetOperationApproval(id string) (*storage.OperationApproval, error) {
+	if id == "" {
+		return nil, trace.BadParameter("missing approval request ID")
+	}
+	var a storage.OperationApproval
+	err := b.getVal(b.key(operationApprovalsP, id), &a)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("approval request %v not found", id)
+		}
+		return nil, trace.Wrap(err)
+	}
+	utils.UTC(&a.Created)
+	utils.UTC(&a.Expires)
+	utils.UTC(&a.Approved)
+	// not all backends honor TTL so check the expiration explicitly
+	if !b.Now().UTC().Before(a.Expires) {
+		return nil, trace.NotFound("approval request %v has expired", id)
+	}
+	return &a, nil
+}
+
+func (b *backend) GetOperationApprovals(clusterName string) ([]storage.OperationApproval, error) {
+	ids, err := b.getKeys(b.key(operationApprovalsP))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.OperationApproval
+	for _, id := range ids {
+		a, err := b.GetOperationApproval(id)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		if a.ClusterName == clusterName {
+			out = append(out, *a)
+		}
+	}
+	return out, nil
+}
+
+func (b *backend) UpdateOperationApproval(a storage.OperationApproval) (*storage.OperationApproval, error) {
+	if err := a.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err := b.updateVal(b.key(operationApprovalsP, a.ID), a, b.ttl(a.Expires))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("approval request %v not found", a.ID)
+		}
+		return nil, trace.Wrap(err)
+	}
+	return &a, nil
+}
+
+func (b *backend) DeleteOperationApproval(id string) error {
+	if id == "" {
+		return trace.BadParameter("missing approval request ID")
+	}
+	err := b.deleteKey(b.key(operationApprovalsP, id))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("approval request %v not found", id)
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
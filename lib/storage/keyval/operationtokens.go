@@ -0,0 +1,97 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+func (b *backend) CreateOperationToken(t storage.OperationToken) (*storage.OperationToken, error) {
+	if err := t.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err := b.createVal(b.key(operationTokensP, t.Token), t, b.ttl(t.Expires))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &t, nil
+}
+
+func (b *backend) GetOperationToken(token string) (*storage.OperationToken, error) {
+	if token == "" {
+		return nil, trace.BadParameter("missing operation token")
+	}
+	var t storage.OperationToken
+	err := b.getVal(b.key(operationTokensP, token), &t)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("operation token not found")
+		}
+		return nil, trace.Wrap(err)
+	}
+	utils.UTC(&t.Created)
+	utils.UTC(&t.Expires)
+	// not all backends honor TTL so check the expiration explicitly
+	if !b.Now().UTC().Before(t.Expires) {
+		return nil, trace.NotFound("operation token has expired")
+	}
+	return &t, nil
+}
+
+func (b *backend) GetOperationTokens(clusterName, operationID string) ([]storage.OperationToken, error) {
+	if clusterName == "" {
+		return nil, trace.BadParameter("missing cluster name")
+	}
+	if operationID == "" {
+		return nil, trace.BadParameter("missing operation ID")
+	}
+	tokens, err := b.getKeys(b.key(operationTokensP))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.OperationToken
+	for _, token := range tokens {
+		t, err := b.GetOperationToken(token)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		if t.SiteDomain == clusterName && t.OperationID == operationID {
+			out = append(out, *t)
+		}
+	}
+	return out, nil
+}
+
+func (b *backend) DeleteOperationToken(token string) error {
+	if token == "" {
+		return trace.BadParameter("missing operation token")
+	}
+	err := b.deleteKey(b.key(operationTokensP, token))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("operation token not found")
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
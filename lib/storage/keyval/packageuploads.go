@@ -0,0 +1,122 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+func (b *backend) UpsertPackageUpload(u storage.PackageUpload) error {
+	if err := u.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	err := b.upsertVal(b.key(packageUploadsP, u.ID, valP), u, forever)
+	return trace.Wrap(err)
+}
+
+func (b *backend) GetPackageUpload(id string) (*storage.PackageUpload, error) {
+	if id == "" {
+		return nil, trace.BadParameter("missing upload ID")
+	}
+	var u storage.PackageUpload
+	err := b.getVal(b.key(packageUploadsP, id, valP), &u)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("upload %v not found", id)
+		}
+		return nil, trace.Wrap(err)
+	}
+	utils.UTC(&u.Expires)
+	return &u, nil
+}
+
+func (b *backend) GetPackageUploads() ([]storage.PackageUpload, error) {
+	ids, err := b.getKeys(b.key(packageUploadsP))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.PackageUpload
+	for _, id := range ids {
+		u, err := b.GetPackageUpload(id)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, *u)
+	}
+	return out, nil
+}
+
+func (b *backend) DeletePackageUpload(id string) error {
+	if id == "" {
+		return trace.BadParameter("missing upload ID")
+	}
+	err := b.deleteDir(b.key(packageUploadsP, id))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("upload %v not found", id)
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+func (b *backend) UpsertPackageUploadPart(id string, part storage.PackageUploadPart) error {
+	if id == "" {
+		return trace.BadParameter("missing upload ID")
+	}
+	if err := part.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	offset := strconv.FormatInt(part.Offset, 10)
+	err := b.upsertVal(b.key(packageUploadsP, id, partsP, offset), part, forever)
+	return trace.Wrap(err)
+}
+
+func (b *backend) GetPackageUploadParts(id string) ([]storage.PackageUploadPart, error) {
+	if id == "" {
+		return nil, trace.BadParameter("missing upload ID")
+	}
+	offsets, err := b.getKeys(b.key(packageUploadsP, id, partsP))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.PackageUploadPart
+	for _, offset := range offsets {
+		var part storage.PackageUploadPart
+		err := b.getVal(b.key(packageUploadsP, id, partsP, offset), &part)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, part)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Offset < out[j].Offset
+	})
+	return out, nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+func (b *backend) UpsertReleaseRecord(r storage.ReleaseRecord) (*storage.ReleaseRecord, error) {
+	if err := r.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err := b.upsertVal(b.key(sitesP, r.ClusterName, releaseRecordsP, r.Name, strconv.Itoa(r.Revision)), r, forever)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &r, nil
+}
+
+func (b *backend) GetReleaseRecord(clusterName, name string, revision int) (*storage.ReleaseRecord, error) {
+	if clusterName == "" {
+		return nil, trace.BadParameter("missing cluster name")
+	}
+	if name == "" {
+		return nil, trace.BadParameter("missing release name")
+	}
+	var r storage.ReleaseRecord
+	err := b.getVal(b.key(sitesP, clusterName, releaseRecordsP, name, strconv.Itoa(revision)), &r)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("release %v revision %v not found", name, revision)
+		}
+		return nil, trace.Wrap(err)
+	}
+	utils.UTC(&r.Updated)
+	return &r, nil
+}
+
+func (b *backend) GetReleaseRecords(clusterName, name string) ([]storage.ReleaseRecord, error) {
+	if clusterName == "" {
+		return nil, trace.BadParameter("missing cluster name")
+	}
+	if name == "" {
+		return nil, trace.BadParameter("missing release name")
+	}
+	keys, err := b.getKeys(b.key(sitesP, clusterName, releaseRecordsP, name))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.ReleaseRecord
+	for _, key := range keys {
+		revision, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		r, err := b.GetReleaseRecord(clusterName, name, revision)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Revision < out[j].Revision
+	})
+	return out, nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+func (b *backend) UpsertResourceRevision(r storage.ResourceRevision) error {
+	if err := r.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	err := b.upsertVal(b.resourceRevisionKey(r.ClusterName, r.Kind, r.Name), r, forever)
+	return trace.Wrap(err)
+}
+
+func (b *backend) GetResourceRevision(clusterName, kind, name string) (*storage.ResourceRevision, error) {
+	if clusterName == "" {
+		return nil, trace.BadParameter("missing cluster name")
+	}
+	if kind == "" {
+		return nil, trace.BadParameter("missing resource kind")
+	}
+	var r storage.ResourceRevision
+	err := b.getVal(b.resourceRevisionKey(clusterName, kind, name), &r)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("revision of %v %q not found", kind, name)
+		}
+		return nil, trace.Wrap(err)
+	}
+	return &r, nil
+}
+
+// resourceRevisionKey returns the key of the revision of the specified resource.
+// Resources that exist as a single instance can have an empty name
+func (b *backend) resourceRevisionKey(clusterName, kind, name string) key {
+	if name == "" {
+		name = singleResourceName
+	}
+	return b.key(sitesP, clusterName, resourceRevisionsP, kind, name)
+}
+
+// singleResourceName is the name of the resources that exist as a single
+// instance in revision keys. It is not a valid resource name
+const singleResourceName = "_"
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/boltdb/bolt"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// newSnapshotBolt returns a new instance of BoltDB backend
+// that never blocks on the database file lock.
+//
+// The database file is opened as configured if it is not locked.
+// Otherwise the engine reads from a consistent copy of the database file
+// in read-only mode. The copy is removed when the engine is closed
+func newSnapshotBolt(cfg BoltConfig, codec Codec) (*blt, error) {
+	cfg.Snapshot = false
+	cfg.Timeout = NoTimeout
+	b, err := newBolt(cfg, codec)
+	if err == nil {
+		return b, nil
+	}
+	if !trace.IsConnectionProblem(err) {
+		return nil, trace.Wrap(err)
+	}
+	logger := logrus.WithField("path", cfg.Path)
+	logger.Debug("Database is locked, will read from snapshot.")
+	cfg.Readonly = true
+	var errors []error
+	for i := 0; i < snapshotAttempts; i++ {
+		b, err = openSnapshot(cfg, codec)
+		if err == nil {
+			return b, nil
+		}
+		logger.WithError(err).Debug("Failed to read database snapshot.")
+		errors = append(errors, err)
+	}
+	return nil, trace.Wrap(trace.NewAggregate(errors...),
+		"failed to read snapshot of database %v", cfg.Path)
+}
+
+// openSnapshot copies the database file specified with cfg.Path and opens
+// the copy in read-only mode.
+// The copy is verified for consistency since the database file
+// can be modified while it is being copied
+func openSnapshot(cfg BoltConfig, codec Codec) (*blt, error) {
+	path, err := copySnapshot(cfg.Path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cfg.Path = path
+	b, err := newBolt(cfg, codec)
+	if err != nil {
+		os.Remove(path)
+		return nil, trace.Wrap(err)
+	}
+	b.snapshot = path
+	if err := b.check(); err != nil {
+		b.Close()
+		return nil, trace.Wrap(err)
+	}
+	return b, nil
+}
+
+// copySnapshot copies the database file at the specified path
+// into a temporary file and returns the path to the copy
+func copySnapshot(path string) (snapshot string, err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", trace.ConvertSystemError(err)
+	}
+	defer src.Close()
+	dst, err := ioutil.TempFile("", "gravity-snapshot")
+	if err != nil {
+		return "", trace.ConvertSystemError(err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(dst.Name())
+		}
+	}()
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return "", trace.ConvertSystemError(err)
+	}
+	if err := dst.Close(); err != nil {
+		return "", trace.ConvertSystemError(err)
+	}
+	return dst.Name(), nil
+}
+
+// check verifies consistency of the database
+func (b *blt) check() error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		var errors []error
+		// Drain the channel as the check runs in a separate goroutine
+		// for the duration of the transaction
+		for err := range tx.Check() {
+			errors = append(errors, err)
+		}
+		if len(errors) != 0 {
+			return trace.BadParameter("database snapshot is inconsistent: %v",
+				trace.NewAggregate(errors...))
+		}
+		return nil
+	})
+}
+
+// snapshotAttempts is the number of attempts to take a consistent
+// snapshot of the database file
+const snapshotAttempts = 3
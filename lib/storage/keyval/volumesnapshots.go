@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"sort"
+
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+func (b *backend) CreateVolumeSnapshot(s storage.VolumeSnapshot) (*storage.VolumeSnapshot, error) {
+	if err := s.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err := b.createVal(b.key(sitesP, s.ClusterName, volumeSnapshotsP, s.Name), s, forever)
+	if err != nil {
+		if trace.IsAlreadyExists(err) {
+			return nil, trace.AlreadyExists("volume snapshot %v already exists", s.Name)
+		}
+		return nil, trace.Wrap(err)
+	}
+	return &s, nil
+}
+
+func (b *backend) GetVolumeSnapshot(clusterName, name string) (*storage.VolumeSnapshot, error) {
+	if clusterName == "" {
+		return nil, trace.BadParameter("missing cluster name")
+	}
+	if name == "" {
+		return nil, trace.BadParameter("missing volume snapshot name")
+	}
+	var s storage.VolumeSnapshot
+	err := b.getVal(b.key(sitesP, clusterName, volumeSnapshotsP, name), &s)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("volume snapshot %v not found", name)
+		}
+		return nil, trace.Wrap(err)
+	}
+	utils.UTC(&s.Created)
+	return &s, nil
+}
+
+func (b *backend) GetVolumeSnapshots(clusterName string) ([]storage.VolumeSnapshot, error) {
+	if clusterName == "" {
+		return nil, trace.BadParameter("missing cluster name")
+	}
+	names, err := b.getKeys(b.key(sitesP, clusterName, volumeSnapshotsP))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	var out []storage.VolumeSnapshot
+	for _, name := range names {
+		s, err := b.GetVolumeSnapshot(clusterName, name)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+	return out, nil
+}
+
+func (b *backend) DeleteVolumeSnapshot(clusterName, name string) error {
+	if clusterName == "" {
+		return trace.BadParameter("missing cluster name")
+	}
+	if name == "" {
+		return trace.BadParameter("missing volume snapshot name")
+	}
+	err := b.deleteKey(b.key(sitesP, clusterName, volumeSnapshotsP, name))
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("volume snapshot %v not found", name)
+		}
+		return trace.Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keyval
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// Watch starts watching the resources of the specified kind and returns
+// the channel with change events
+func (b *backend) Watch(ctx context.Context, kind string) (<-chan storage.Event, error) {
+	spec, ok := watchKeys[kind]
+	if !ok {
+		return nil, trace.BadParameter("watching %q resources is not supported", kind)
+	}
+	prefix := b.key(spec.path[0], spec.path[1:]...)
+	kvEventsC, err := b.watch(ctx, prefix)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	eventsC := make(chan storage.Event)
+	go func() {
+		defer close(eventsC)
+		for kvEvent := range kvEventsC {
+			event, ok := spec.event(kind, kvEvent, len(prefix))
+			if !ok {
+				continue
+			}
+			select {
+			case eventsC <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return eventsC, nil
+}
+
+// kvEvent describes a change to a key
+type kvEvent struct {
+	// typ is the type of the change
+	typ storage.EventType
+	// key is the changed key
+	key key
+}
+
+// watchKey describes where the resources of a specific kind are stored
+type watchKey struct {
+	// path is the path of the resource collection relative to the root key
+	path []string
+	// val is the name of the key with the resource value inside the
+	// resource directory, or empty if the resource is stored directly
+	// under its name
+	val string
+}
+
+// event converts the key change into a resource change event.
+// prefixLen is the length of the resource collection key.
+// Returns false if the change does not describe a resource change
+func (r watchKey) event(kind string, event kvEvent, prefixLen int) (storage.Event, bool) {
+	rel := event.key[prefixLen:]
+	switch {
+	case len(rel) == 0 && event.typ == storage.EventTypeDelete:
+		// the whole collection has been removed
+		return storage.Event{Type: event.typ, Kind: kind}, true
+	case len(rel) == 1 && (r.val == "" || event.typ == storage.EventTypeDelete):
+	case len(rel) == 2 && r.val != "" && rel[1] == r.val:
+	default:
+		return storage.Event{}, false
+	}
+	return storage.Event{
+		Type: event.typ,
+		Kind: kind,
+		Name: strings.Replace(rel[0], "%2F", "/", -1),
+	}, true
+}
+
+// watchKeys maps the resource kinds that can be watched
+// to their location in the backend
+var watchKeys = map[string]watchKey{
+	storage.KindCluster:              {path: []string{sitesP}, val: valP},
+	teleservices.KindUser:            {path: []string{usersP}, val: valP},
+	teleservices.KindRole:            {path: []string{rolesP}},
+	teleservices.KindOIDCConnector:   {path: []string{connectorsP}},
+	teleservices.KindSAMLConnector:   {path: []string{authP, connectorsP, samlP}},
+	teleservices.KindGithubConnector: {path: []string{authP, connectorsP, githubP}},
+	teleservices.KindTrustedCluster:  {path: []string{trustedClustersP}},
+	teleservices.KindRemoteCluster:   {path: []string{remoteClustersP}},
+	teleservices.KindReverseTunnel:   {path: []string{tunnelsP}},
+}
+
+// broadcaster delivers key changes made by this process to the watchers
+type broadcaster struct {
+	sync.Mutex
+	watchers map[*kvWatcher]struct{}
+}
+
+// kvWatcher receives changes to the keys with the specific prefix
+type kvWatcher struct {
+	prefix  key
+	eventsC chan kvEvent
+}
+
+// watch registers a new watcher for the keys with the specified prefix.
+// The watcher is removed when the context is cancelled
+func (b *broadcaster) watch(ctx context.Context, prefix key) (<-chan kvEvent, error) {
+	watcher := &kvWatcher{
+		prefix:  prefix,
+		eventsC: make(chan kvEvent, watchBufferSize),
+	}
+	b.Lock()
+	if b.watchers == nil {
+		b.watchers = make(map[*kvWatcher]struct{})
+	}
+	b.watchers[watcher] = struct{}{}
+	b.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.remove(watcher)
+	}()
+	return watcher.eventsC, nil
+}
+
+// publish notifies the watchers about the change to the specified key.
+// Watchers that are not keeping up with changes are removed
+func (b *broadcaster) publish(typ storage.EventType, k key) {
+	b.Lock()
+	defer b.Unlock()
+	for watcher := range b.watchers {
+		if !hasPrefix(k, watcher.prefix) && !hasPrefix(watcher.prefix, k) {
+			continue
+		}
+		event := kvEvent{typ: typ, key: k}
+		if !hasPrefix(k, watcher.prefix) {
+			// a parent directory of the watched keys has been removed
+			event.key = watcher.prefix
+		}
+		select {
+		case watcher.eventsC <- event:
+		default:
+			log.WithField("prefix", watcher.prefix).Warn("Watcher is falling behind, closing.")
+			delete(b.watchers, watcher)
+			close(watcher.eventsC)
+		}
+	}
+}
+
+func (b *broadcaster) remove(watcher *kvWatcher) {
+	b.Lock()
+	defer b.Unlock()
+	if _, ok := b.watchers[watcher]; ok {
+		delete(b.watchers, watcher)
+		close(watcher.eventsC)
+	}
+}
+
+// closeAll removes all watchers
+func (b *broadcaster) closeAll() {
+	b.Lock()
+	defer b.Unlock()
+	for watcher := range b.watchers {
+		delete(b.watchers, watcher)
+		close(watcher.eventsC)
+	}
+}
+
+// hasPrefix returns true if the key k starts with prefix
+func hasPrefix(k, prefix key) bool {
+	if len(k) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if k[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// watchBufferSize is the number of changes buffered for a watcher
+// before it is considered to be falling behind
+const watchBufferSize = 1024
@@ -0,0 +1,565 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+)
+
+// OperationPolicy describes the rules that gate creation of cluster operations
+type OperationPolicy interface {
+	// Resource provides common resource methods
+	teleservices.Resource
+	// CheckAndSetDefaults verifies that the object is valid
+	CheckAndSetDefaults() error
+	// GetRules returns the list of policy rules
+	GetRules() []OperationPolicyRule
+	// GetApproval returns the approval requirements or nil if approval is not required
+	GetApproval() *OperationPolicyApproval
+	// GetRego returns the Rego policy or nil if the policy uses rules
+	GetRego() *OperationPolicyRego
+}
+
+// OperationPolicyOperations lists operation names policy rules can refer to
+var OperationPolicyOperations = []string{
+	"install", "expand", "update", "shrink", "uninstall",
+	"gc", "update_environ", "update_config",
+}
+
+// OperationApprovalOperations lists operation names that can require approval.
+// force_remove applies to forced node removals only
+var OperationApprovalOperations = []string{
+	"shrink", "force_remove", "uninstall",
+}
+
+const (
+	// OperationPolicyDeny is the decision of policy rules that deny matching operations
+	OperationPolicyDeny = "deny"
+	// OperationPolicyRequireApproval is the decision of policy rules that require
+	// matching operations to be approved by another user
+	OperationPolicyRequireApproval = "require_approval"
+)
+
+// OperationPolicyDecisions lists the supported decisions of policy rules
+var OperationPolicyDecisions = []string{
+	OperationPolicyDeny,
+	OperationPolicyRequireApproval,
+}
+
+// NewOperationPolicy creates a new operation policy resource from the provided spec
+func NewOperationPolicy(spec OperationPolicySpecV2) OperationPolicy {
+	return &OperationPolicyV2{
+		Kind:    KindOperationPolicy,
+		Version: teleservices.V2,
+		Metadata: teleservices.Metadata{
+			Name:      KindOperationPolicy,
+			Namespace: teledefaults.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// OperationPolicyV2 defines the rules that gate creation of cluster operations
+type OperationPolicyV2 struct {
+	// Metadata is resource metadata
+	teleservices.Metadata `json:"metadata"`
+	// Kind is a resource kind
+	Kind string `json:"kind"`
+	// Version is a resource version
+	Version string `json:"version"`
+	// Spec defines the policy rules
+	Spec OperationPolicySpecV2 `json:"spec"`
+}
+
+// OperationPolicySpecV2 defines the rules that gate creation of cluster operations
+type OperationPolicySpecV2 struct {
+	// Rules lists the policy rules. An operation is denied if it matches any
+	// rule that denies it, and requires approval if it only matches rules that
+	// require approval
+	Rules []OperationPolicyRule `json:"rules,omitempty"`
+	// Rego specifies the Rego policy evaluated by an Open Policy Agent
+	// server instead of the rules
+	Rego *OperationPolicyRego `json:"rego,omitempty"`
+	// Approval requires the specified operations to be approved by another user
+	Approval *OperationPolicyApproval `json:"approval,omitempty"`
+}
+
+// OperationPolicyRego defines the Rego policy module that decides whether
+// operations are allowed.
+//
+// The module is loaded into the Open Policy Agent server when the policy
+// is created and the decision document is evaluated with the operation
+// described by OperationPolicyInput as input. The decision document is an
+// object with the decision (allow, deny or require_approval) and an optional
+// message, operations are allowed if the document is undefined
+type OperationPolicyRego struct {
+	// Module is the source of the Rego policy module
+	Module string `json:"module"`
+	// URL is the address of the Open Policy Agent server
+	URL string `json:"url"`
+	// CACert is the optional certificate authority of the server
+	CACert string `json:"ca_cert,omitempty"`
+	// Decision is the path of the decision document.
+	// Defaults to gravity/operations/decision
+	Decision string `json:"decision,omitempty"`
+}
+
+// CheckAndSetDefaults validates the Rego policy and sets defaults
+func (r *OperationPolicyRego) CheckAndSetDefaults() error {
+	if strings.TrimSpace(r.Module) == "" {
+		return trace.BadParameter("rego policy module is required")
+	}
+	if !strings.HasPrefix(strings.TrimSpace(stripRegoComments(r.Module)), "package ") {
+		return trace.BadParameter("rego policy module should start with a package declaration")
+	}
+	if r.URL == "" {
+		return trace.BadParameter("Open Policy Agent server URL is required")
+	}
+	if _, err := url.ParseRequestURI(r.URL); err != nil {
+		return trace.BadParameter("invalid Open Policy Agent server URL %q", r.URL)
+	}
+	if r.Decision == "" {
+		r.Decision = defaults.OperationPolicyDecision
+	}
+	return nil
+}
+
+// OperationPolicyRegoDecision is the decision document of a Rego policy
+type OperationPolicyRegoDecision struct {
+	// Decision is allow, deny or require_approval
+	Decision string `json:"decision"`
+	// Message optionally explains the decision
+	Message string `json:"message,omitempty"`
+}
+
+// OperationPolicyAllow is the decision of Rego policies that allow operations
+const OperationPolicyAllow = "allow"
+
+// stripRegoComments removes comment lines from the specified Rego module
+func stripRegoComments(module string) string {
+	var lines []string
+	for _, line := range strings.Split(module, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// OperationPolicyApproval defines operations that have to be approved
+// by a user other than the one who started them
+type OperationPolicyApproval struct {
+	// Operations lists names of operations that require approval.
+	// Defaults to all operations that can require approval
+	Operations []string `json:"operations,omitempty"`
+	// TTL is how long approval requests remain valid
+	TTL teleservices.Duration `json:"ttl,omitempty"`
+}
+
+// Requires returns true if the specified operation requires approval
+func (r OperationPolicyApproval) Requires(operation string, force bool) bool {
+	operations := r.Operations
+	if len(operations) == 0 {
+		operations = OperationApprovalOperations
+	}
+	if utils.StringInSlice(operations, operation) {
+		return true
+	}
+	return operation == "shrink" && force && utils.StringInSlice(operations, "force_remove")
+}
+
+// CheckAndSetDefaults validates the approval requirements and sets defaults
+func (r *OperationPolicyApproval) CheckAndSetDefaults() error {
+	for _, operation := range r.Operations {
+		if !utils.StringInSlice(OperationApprovalOperations, operation) {
+			return trace.BadParameter("operation %q cannot require approval, supported are: %v",
+				operation, OperationApprovalOperations)
+		}
+	}
+	if r.TTL.Duration < 0 {
+		return trace.BadParameter("approval ttl cannot be negative")
+	}
+	if r.TTL.Duration == 0 {
+		r.TTL.Duration = defaults.OperationApprovalTTL
+	}
+	return nil
+}
+
+// OperationPolicyRule denies or requires approval of operations that match
+// all of its criteria. Empty criteria match any operation
+type OperationPolicyRule struct {
+	// Name identifies the rule
+	Name string `json:"name"`
+	// Decision is the decision for matching operations: deny (default)
+	// or require_approval
+	Decision string `json:"decision,omitempty"`
+	// Operations lists names of the operations the rule applies to
+	Operations []string `json:"operations,omitempty"`
+	// Users lists glob patterns of the users the rule applies to
+	Users []string `json:"users,omitempty"`
+	// Nodes lists glob patterns of hostnames or advertise addresses
+	// of the nodes targeted by the operation
+	Nodes []string `json:"nodes,omitempty"`
+	// Schedule limits the rule to the specified time window
+	Schedule *OperationPolicySchedule `json:"schedule,omitempty"`
+	// Message explains why the operation has been denied
+	// or requires approval
+	Message string `json:"message,omitempty"`
+}
+
+// RequiresApproval returns true if operations matching this rule
+// require approval instead of being denied
+func (r OperationPolicyRule) RequiresApproval() bool {
+	return r.Decision == OperationPolicyRequireApproval
+}
+
+// OperationPolicySchedule defines a recurring time window
+type OperationPolicySchedule struct {
+	// Days lists the days of week of the window: mon, tue, etc.
+	// Empty list means every day
+	Days []string `json:"days,omitempty"`
+	// From is the start of the window as HH:MM
+	From string `json:"from,omitempty"`
+	// To is the end of the window as HH:MM.
+	// Windows that end before they start extend past midnight
+	To string `json:"to,omitempty"`
+	// Timezone is the IANA name of the window timezone. Defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// OperationPolicyInput describes an operation evaluated against the policy
+type OperationPolicyInput struct {
+	// Operation is the operation name, e.g. shrink
+	Operation string `json:"operation"`
+	// User is the user that creates the operation
+	User string `json:"user"`
+	// Nodes lists hostnames and advertise addresses of the nodes targeted by the operation
+	Nodes []string `json:"nodes,omitempty"`
+	// Force specifies whether the operation is forced
+	Force bool `json:"force,omitempty"`
+	// Time is the time the operation is created
+	Time time.Time `json:"time"`
+}
+
+// GetRules returns the list of policy rules
+func (r *OperationPolicyV2) GetRules() []OperationPolicyRule {
+	return r.Spec.Rules
+}
+
+// GetApproval returns the approval requirements or nil if approval is not required
+func (r *OperationPolicyV2) GetApproval() *OperationPolicyApproval {
+	return r.Spec.Approval
+}
+
+// GetRego returns the Rego policy or nil if the policy uses rules
+func (r *OperationPolicyV2) GetRego() *OperationPolicyRego {
+	return r.Spec.Rego
+}
+
+// CheckAndSetDefaults checks validity of all parameters and sets defaults
+func (r *OperationPolicyV2) CheckAndSetDefaults() error {
+	if r.Metadata.Name == "" {
+		r.Metadata.Name = KindOperationPolicy
+	}
+	if err := r.Metadata.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	names := make(map[string]bool)
+	for _, rule := range r.Spec.Rules {
+		if err := rule.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+		if names[rule.Name] {
+			return trace.BadParameter("duplicate rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	if r.Spec.Rego != nil {
+		if len(r.Spec.Rules) != 0 {
+			return trace.BadParameter("rules and rego policy are mutually exclusive")
+		}
+		if err := r.Spec.Rego.CheckAndSetDefaults(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if r.Spec.Approval != nil {
+		if err := r.Spec.Approval.CheckAndSetDefaults(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// Check validates this rule
+func (r OperationPolicyRule) Check() error {
+	if r.Name == "" {
+		return trace.BadParameter("rule name is required")
+	}
+	if r.Decision != "" && !utils.StringInSlice(OperationPolicyDecisions, r.Decision) {
+		return trace.BadParameter("rule %q: unsupported decision %q, supported are: %v",
+			r.Name, r.Decision, OperationPolicyDecisions)
+	}
+	for _, operation := range r.Operations {
+		if !utils.StringInSlice(OperationPolicyOperations, operation) {
+			return trace.BadParameter("rule %q: unsupported operation %q, supported are: %v",
+				r.Name, operation, OperationPolicyOperations)
+		}
+	}
+	for _, pattern := range append(r.Users, r.Nodes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return trace.BadParameter("rule %q: invalid pattern %q", r.Name, pattern)
+		}
+	}
+	if r.Schedule != nil {
+		if err := r.Schedule.Check(); err != nil {
+			return trace.Wrap(err, "rule %q", r.Name)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the specified operation matches all criteria of this rule
+func (r OperationPolicyRule) Matches(input OperationPolicyInput) bool {
+	if len(r.Operations) != 0 && !utils.StringInSlice(r.Operations, input.Operation) {
+		return false
+	}
+	if len(r.Users) != 0 && !matchesAny(r.Users, input.User) {
+		return false
+	}
+	if len(r.Nodes) != 0 && !matchesAny(r.Nodes, input.Nodes...) {
+		return false
+	}
+	if r.Schedule != nil && !r.Schedule.Contains(input.Time) {
+		return false
+	}
+	return true
+}
+
+// Check validates this schedule
+func (r OperationPolicySchedule) Check() error {
+	for _, day := range r.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return trace.BadParameter("invalid day %q, expected one of mon, tue, wed, thu, fri, sat, sun", day)
+		}
+	}
+	if _, err := parseTimeOfDay(r.From); err != nil {
+		return trace.Wrap(err)
+	}
+	if _, err := parseTimeOfDay(r.To); err != nil {
+		return trace.Wrap(err)
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return trace.BadParameter("invalid timezone %q", r.Timezone)
+	}
+	return nil
+}
+
+// Contains returns true if the specified time is within this schedule.
+// The schedule is assumed to be valid
+func (r OperationPolicySchedule) Contains(t time.Time) bool {
+	location, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return false
+	}
+	t = t.In(location)
+	from, _ := parseTimeOfDay(r.From)
+	to, _ := parseTimeOfDay(r.To)
+	if to == 0 {
+		to = 24 * time.Hour
+	}
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	day := t.Weekday()
+	switch {
+	case from <= to:
+		if sinceMidnight < from || sinceMidnight >= to {
+			return false
+		}
+	case sinceMidnight >= from:
+		// Window starts on this day and extends past midnight
+	case sinceMidnight < to:
+		// Window started on the previous day
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, name := range r.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a textual representation of this operation policy
+func (r *OperationPolicyV2) String() string {
+	var names []string
+	for _, rule := range r.Spec.Rules {
+		names = append(names, rule.Name)
+	}
+	if r.Spec.Rego != nil {
+		return fmt.Sprintf("OperationPolicyV2(Rego=%v)", r.Spec.Rego.URL)
+	}
+	return fmt.Sprintf("OperationPolicyV2(Rules=%v)", names)
+}
+
+// UnmarshalOperationPolicy unmarshals operation policy from JSON or YAML
+func UnmarshalOperationPolicy(data []byte) (OperationPolicy, error) {
+	if len(data) == 0 {
+		return nil, trace.BadParameter("empty configuration")
+	}
+	jsonData, err := teleutils.ToJSON(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var hdr teleservices.ResourceHeader
+	err = json.Unmarshal(jsonData, &hdr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch hdr.Version {
+	case teleservices.V2:
+		var policy OperationPolicyV2
+		err := teleutils.UnmarshalWithSchema(GetOperationPolicySchema(), &policy, jsonData)
+		if err != nil {
+			return nil, trace.BadParameter("%v", err)
+		}
+		if err := policy.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &policy, nil
+	}
+	return nil, trace.BadParameter(
+		"%v resource version %q is not supported", KindOperationPolicy, hdr.Version)
+}
+
+// MarshalOperationPolicy marshals operation policy into JSON
+func MarshalOperationPolicy(policy OperationPolicy, opts ...teleservices.MarshalOption) ([]byte, error) {
+	return json.Marshal(policy)
+}
+
+// OperationPolicySpecV2Schema is JSON schema for the operation policy
+const OperationPolicySpecV2Schema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "rules": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["name"],
+        "properties": {
+          "name": {"type": "string"},
+          "decision": {"type": "string"},
+          "operations": {"type": "array", "items": {"type": "string"}},
+          "users": {"type": "array", "items": {"type": "string"}},
+          "nodes": {"type": "array", "items": {"type": "string"}},
+          "schedule": {
+            "type": "object",
+            "additionalProperties": false,
+            "properties": {
+              "days": {"type": "array", "items": {"type": "string"}},
+              "from": {"type": "string"},
+              "to": {"type": "string"},
+              "timezone": {"type": "string"}
+            }
+          },
+          "message": {"type": "string"}
+        }
+      }
+    },
+    "rego": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["module", "url"],
+      "properties": {
+        "module": {"type": "string"},
+        "url": {"type": "string"},
+        "ca_cert": {"type": "string"},
+        "decision": {"type": "string"}
+      }
+    },
+    "approval": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "operations": {"type": "array", "items": {"type": "string"}},
+        "ttl": {"type": "string"}
+      }
+    }
+  }
+}`
+
+// GetOperationPolicySchema returns the operation policy schema for version V2
+func GetOperationPolicySchema() string {
+	return fmt.Sprintf(teleservices.V2SchemaTemplate, MetadataSchema,
+		OperationPolicySpecV2Schema, "")
+}
+
+// matchesAny returns true if any of the values matches any of the glob patterns
+func matchesAny(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if matched, _ := path.Match(pattern, value); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseTimeOfDay parses the specified HH:MM value as duration since midnight.
+// Empty value is treated as midnight and 24:00 as the end of day
+func parseTimeOfDay(value string) (time.Duration, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "24:00":
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, trace.BadParameter("invalid time of day %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/defaults"
+
+	check "gopkg.in/check.v1"
+)
+
+type OperationPolicySuite struct{}
+
+var _ = check.Suite(&OperationPolicySuite{})
+
+func (s *OperationPolicySuite) TestResourceParsing(c *check.C) {
+	spec := `kind: operationpolicy
+version: v2
+spec:
+  rules:
+  - name: weekend-freeze
+    operations: ["update", "shrink"]
+    users: ["*@contractor.example.com"]
+    nodes: ["db-*"]
+    schedule:
+      days: ["sat", "sun"]
+      from: "00:00"
+      to: "23:59"
+      timezone: UTC
+    message: no changes over the weekend
+`
+	policy, err := UnmarshalOperationPolicy([]byte(spec))
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, compare.DeepEquals, NewOperationPolicy(OperationPolicySpecV2{
+		Rules: []OperationPolicyRule{{
+			Name:       "weekend-freeze",
+			Operations: []string{"update", "shrink"},
+			Users:      []string{"*@contractor.example.com"},
+			Nodes:      []string{"db-*"},
+			Schedule: &OperationPolicySchedule{
+				Days:     []string{"sat", "sun"},
+				From:     "00:00",
+				To:       "23:59",
+				Timezone: "UTC",
+			},
+			Message: "no changes over the weekend",
+		}},
+	}))
+}
+
+func (s *OperationPolicySuite) TestApprovalParsing(c *check.C) {
+	spec := `kind: operationpolicy
+version: v2
+spec:
+  approval:
+    operations: ["force_remove", "uninstall"]
+`
+	policy, err := UnmarshalOperationPolicy([]byte(spec))
+	c.Assert(err, check.IsNil)
+	approval := policy.GetApproval()
+	c.Assert(approval, check.NotNil)
+	c.Assert(approval.TTL.Duration, check.Equals, defaults.OperationApprovalTTL)
+	c.Assert(approval.Requires("shrink", false), check.Equals, false)
+	c.Assert(approval.Requires("shrink", true), check.Equals, true)
+	c.Assert(approval.Requires("uninstall", false), check.Equals, true)
+	c.Assert(approval.Requires("update", false), check.Equals, false)
+
+	approval = &OperationPolicyApproval{}
+	c.Assert(approval.Requires("shrink", false), check.Equals, true)
+
+	_, err = UnmarshalOperationPolicy([]byte(`kind: operationpolicy
+version: v2
+spec:
+  approval:
+    operations: ["update"]
+`))
+	c.Assert(err, check.NotNil)
+}
+
+func (s *OperationPolicySuite) TestValidation(c *check.C) {
+	var testCases = []struct {
+		comment string
+		rules   []OperationPolicyRule
+	}{
+		{
+			comment: "missing rule name",
+			rules:   []OperationPolicyRule{{Operations: []string{"shrink"}}},
+		},
+		{
+			comment: "duplicate rule name",
+			rules:   []OperationPolicyRule{{Name: "a"}, {Name: "a"}},
+		},
+		{
+			comment: "unsupported decision",
+			rules:   []OperationPolicyRule{{Name: "a", Decision: "warn"}},
+		},
+		{
+			comment: "unsupported operation",
+			rules:   []OperationPolicyRule{{Name: "a", Operations: []string{"reboot"}}},
+		},
+		{
+			comment: "invalid pattern",
+			rules:   []OperationPolicyRule{{Name: "a", Users: []string{"["}}},
+		},
+		{
+			comment: "invalid day",
+			rules: []OperationPolicyRule{{Name: "a", Schedule: &OperationPolicySchedule{
+				Days: []string{"someday"},
+			}}},
+		},
+		{
+			comment: "invalid time of day",
+			rules: []OperationPolicyRule{{Name: "a", Schedule: &OperationPolicySchedule{
+				From: "25:00",
+			}}},
+		},
+	}
+	for _, tc := range testCases {
+		err := NewOperationPolicy(OperationPolicySpecV2{Rules: tc.rules}).CheckAndSetDefaults()
+		c.Assert(err, check.NotNil, check.Commentf(tc.comment))
+	}
+}
+
+func (s *OperationPolicySuite) TestRegoValidation(c *check.C) {
+	module := "# operation policy\npackage gravity.operations\n\ndecision = {\"decision\": \"deny\"} { input.operation == \"shrink\" }\n"
+	var testCases = []struct {
+		comment string
+		spec    OperationPolicySpecV2
+		valid   bool
+	}{
+		{
+			comment: "valid policy",
+			spec:    OperationPolicySpecV2{Rego: &OperationPolicyRego{Module: module, URL: "http://opa:8181"}},
+			valid:   true,
+		},
+		{
+			comment: "missing module",
+			spec:    OperationPolicySpecV2{Rego: &OperationPolicyRego{URL: "http://opa:8181"}},
+		},
+		{
+			comment: "missing package declaration",
+			spec:    OperationPolicySpecV2{Rego: &OperationPolicyRego{Module: "allow = true", URL: "http://opa:8181"}},
+		},
+		{
+			comment: "missing server URL",
+			spec:    OperationPolicySpecV2{Rego: &OperationPolicyRego{Module: module}},
+		},
+		{
+			comment: "rules and rego policy",
+			spec: OperationPolicySpecV2{
+				Rules: []OperationPolicyRule{{Name: "a"}},
+				Rego:  &OperationPolicyRego{Module: module, URL: "http://opa:8181"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		policy := NewOperationPolicy(tc.spec)
+		err := policy.CheckAndSetDefaults()
+		if !tc.valid {
+			c.Assert(err, check.NotNil, check.Commentf(tc.comment))
+			continue
+		}
+		c.Assert(err, check.IsNil, check.Commentf(tc.comment))
+		c.Assert(policy.GetRego().Decision, check.Equals, "gravity/operations/decision")
+	}
+}
+
+func (s *OperationPolicySuite) TestMatchesRule(c *check.C) {
+	rule := OperationPolicyRule{
+		Name:       "a",
+		Operations: []string{"shrink"},
+		Users:      []string{"*@example.com"},
+		Nodes:      []string{"db-*"},
+	}
+	input := OperationPolicyInput{
+		Operation: "shrink",
+		User:      "alice@example.com",
+		Nodes:     []string{"db-1", "10.0.0.1"},
+	}
+	c.Assert(rule.Matches(input), check.Equals, true)
+	input.Operation = "expand"
+	c.Assert(rule.Matches(input), check.Equals, false)
+	input.Operation = "shrink"
+	input.User = "bob@contractor.com"
+	c.Assert(rule.Matches(input), check.Equals, false)
+	input.User = "alice@example.com"
+	input.Nodes = []string{"web-1", "10.0.0.2"}
+	c.Assert(rule.Matches(input), check.Equals, false)
+	c.Assert(OperationPolicyRule{Name: "any"}.Matches(input), check.Equals, true)
+}
+
+func (s *OperationPolicySuite) TestScheduleContains(c *check.C) {
+	// 2019-06-01 is a Saturday
+	saturday := func(hour, minute int) time.Time {
+		return time.Date(2019, time.June, 1, hour, minute, 0, 0, time.UTC)
+	}
+	var testCases = []struct {
+		comment  string
+		schedule OperationPolicySchedule
+		time     time.Time
+		contains bool
+	}{
+		{
+			comment:  "empty schedule covers the whole day",
+			schedule: OperationPolicySchedule{},
+			time:     saturday(13, 0),
+			contains: true,
+		},
+		{
+			comment:  "within daily window",
+			schedule: OperationPolicySchedule{From: "09:00", To: "17:00"},
+			time:     saturday(9, 0),
+			contains: true,
+		},
+		{
+			comment:  "window end is exclusive",
+			schedule: OperationPolicySchedule{From: "09:00", To: "17:00"},
+			time:     saturday(17, 0),
+			contains: false,
+		},
+		{
+			comment:  "window until end of day",
+			schedule: OperationPolicySchedule{From: "12:00", To: "24:00"},
+			time:     saturday(23, 59),
+			contains: true,
+		},
+		{
+			comment:  "different day",
+			schedule: OperationPolicySchedule{Days: []string{"mon", "tue"}},
+			time:     saturday(13, 0),
+			contains: false,
+		},
+		{
+			comment:  "overnight window started on the previous day",
+			schedule: OperationPolicySchedule{Days: []string{"fri"}, From: "22:00", To: "06:00"},
+			time:     saturday(5, 0),
+			contains: true,
+		},
+		{
+			comment:  "overnight window does not start on this day",
+			schedule: OperationPolicySchedule{Days: []string{"fri"}, From: "22:00", To: "06:00"},
+			time:     saturday(23, 0),
+			contains: false,
+		},
+		{
+			comment:  "timezone",
+			schedule: OperationPolicySchedule{Days: []string{"fri"}, Timezone: "America/Los_Angeles"},
+			time:     saturday(3, 0),
+			contains: true,
+		},
+	}
+	for _, tc := range testCases {
+		c.Assert(tc.schedule.Check(), check.IsNil, check.Commentf(tc.comment))
+		c.Assert(tc.schedule.Contains(tc.time), check.Equals, tc.contains, check.Commentf(tc.comment))
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// PackageUploads defines the interface to manage the sessions of
+// chunked package uploads in progress.
+//
+// The sessions are kept in the backend so that the parts of an upload
+// can be received by any package service replica
+type PackageUploads interface {
+	// UpsertPackageUpload creates or updates the package upload session
+	UpsertPackageUpload(PackageUpload) error
+	// GetPackageUpload returns the package upload session with the specified ID
+	GetPackageUpload(id string) (*PackageUpload, error)
+	// GetPackageUploads returns all package upload sessions
+	GetPackageUploads() ([]PackageUpload, error)
+	// DeletePackageUpload deletes the package upload session with all its parts
+	DeletePackageUpload(id string) error
+	// UpsertPackageUploadPart records the part received by the specified upload session
+	UpsertPackageUploadPart(id string, part PackageUploadPart) error
+	// GetPackageUploadParts returns the parts received by the specified
+	// upload session in order of their offsets
+	GetPackageUploadParts(id string) ([]PackageUploadPart, error)
+}
+
+// PackageUpload describes the session of a chunked package upload
+type PackageUpload struct {
+	// ID uniquely identifies the upload session
+	ID string `json:"id"`
+	// User is the name of the user that has started the upload
+	User string `json:"user"`
+	// Spec is the JSON-encoded specification of the uploaded package
+	Spec []byte `json:"spec"`
+	// Expires is the time after which the abandoned upload is removed
+	Expires time.Time `json:"expires"`
+}
+
+// Check validates this package upload session
+func (r PackageUpload) Check() error {
+	if r.ID == "" {
+		return trace.BadParameter("missing upload ID")
+	}
+	if r.User == "" {
+		return trace.BadParameter("missing upload user")
+	}
+	if len(r.Spec) == 0 {
+		return trace.BadParameter("missing upload specification")
+	}
+	return nil
+}
+
+// PackageUploadPart describes a part received by a package upload session
+type PackageUploadPart struct {
+	// Offset is the offset of the part in the package contents
+	Offset int64 `json:"offset"`
+	// Size is the part size in bytes
+	Size int64 `json:"size"`
+	// SHA256 is the sha-256 checksum of the part contents
+	SHA256 string `json:"sha256"`
+	// BLOB is the hash of the BLOB with the part contents
+	BLOB string `json:"blob"`
+}
+
+// Check validates this package upload part
+func (r PackageUploadPart) Check() error {
+	if r.Offset < 0 {
+		return trace.BadParameter("part offset should not be negative, got %v", r.Offset)
+	}
+	if r.SHA256 == "" {
+		return trace.BadParameter("missing part checksum")
+	}
+	if r.BLOB == "" {
+		return trace.BadParameter("missing part BLOB")
+	}
+	return nil
+}
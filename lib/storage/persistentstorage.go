@@ -0,0 +1,528 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/defaults"
+
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// PersistentStorage configures the block devices used for persistent storage
+// by OpenEBS
+type PersistentStorage interface {
+	// Resource provides common resource methods
+	teleservices.Resource
+	// CheckAndSetDefaults verifies that the object is valid
+	CheckAndSetDefaults() error
+	// GetIncludeDevices returns path patterns of devices to include
+	GetIncludeDevices() []string
+	// GetExcludeDevices returns path patterns of devices to exclude
+	GetExcludeDevices() []string
+	// GetIncludeVendors returns vendors of devices to include
+	GetIncludeVendors() []string
+	// GetExcludeVendors returns vendors of devices to exclude
+	GetExcludeVendors() []string
+	// GetQuotas returns the soft quotas on persistent storage usage
+	GetQuotas() []StorageQuota
+	// GetPools returns the OpenEBS storage pools
+	GetPools() []StoragePool
+}
+
+// DefaultPersistentStorage returns the persistent storage configuration used
+// if it has not been configured explicitly
+func DefaultPersistentStorage() PersistentStorage {
+	return NewPersistentStorage(PersistentStorageSpecV2{})
+}
+
+// NewPersistentStorage creates a new persistent storage resource from the provided spec
+func NewPersistentStorage(spec PersistentStorageSpecV2) PersistentStorage {
+	return &PersistentStorageV2{
+		Kind:    KindPersistentStorage,
+		Version: teleservices.V2,
+		Metadata: teleservices.Metadata{
+			Name:      KindPersistentStorage,
+			Namespace: teledefaults.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// PersistentStorageV2 configures the block devices used for persistent storage
+type PersistentStorageV2 struct {
+	// Metadata is resource metadata
+	teleservices.Metadata `json:"metadata"`
+	// Kind is a resource kind
+	Kind string `json:"kind"`
+	// Version is a resource version
+	Version string `json:"version"`
+	// Spec defines the persistent storage configuration
+	Spec PersistentStorageSpecV2 `json:"spec"`
+}
+
+// PersistentStorageSpecV2 defines the persistent storage configuration
+type PersistentStorageSpecV2 struct {
+	// OpenEBS configures the OpenEBS node disk manager
+	OpenEBS OpenEBS `json:"openebs"`
+	// Quotas lists soft quotas on persistent storage usage
+	Quotas []StorageQuota `json:"quotas,omitempty"`
+}
+
+// StorageQuota is a soft quota on the persistent storage used by
+// the persistent volume claims of a namespace.
+//
+// Exceeding the quota does not prevent the volumes from growing,
+// the cluster status warns about it instead
+type StorageQuota struct {
+	// Namespace is the namespace the quota applies to
+	Namespace string `json:"namespace"`
+	// StorageClass optionally restricts the quota to the claims
+	// of the specified storage class
+	StorageClass string `json:"storageClass,omitempty"`
+	// Limit is the storage limit in Kubernetes quantity format, e.g. 100Gi
+	Limit string `json:"limit"`
+	// WarningThreshold is the percentage of the limit that,
+	// once used, raises a warning
+	WarningThreshold int `json:"warningThreshold,omitempty"`
+}
+
+// LimitBytes returns the quota limit in bytes
+func (r StorageQuota) LimitBytes() (uint64, error) {
+	limit, err := resource.ParseQuantity(r.Limit)
+	if err != nil {
+		return 0, trace.BadParameter("invalid storage quota limit %q: %v", r.Limit, err)
+	}
+	if limit.Sign() <= 0 {
+		return 0, trace.BadParameter("storage quota limit must be positive, got %q", r.Limit)
+	}
+	return uint64(limit.Value()), nil
+}
+
+// Check validates the quota and sets defaults
+func (r *StorageQuota) Check() error {
+	if r.Namespace == "" {
+		return trace.BadParameter("storage quota is missing namespace")
+	}
+	if _, err := r.LimitBytes(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.WarningThreshold == 0 {
+		r.WarningThreshold = defaults.StorageQuotaWarningThreshold
+	}
+	if r.WarningThreshold < 0 || r.WarningThreshold > 100 {
+		return trace.BadParameter("storage quota warning threshold must be "+
+			"a percentage between 1 and 100, got %v", r.WarningThreshold)
+	}
+	return nil
+}
+
+// String returns a textual representation of this quota
+func (r StorageQuota) String() string {
+	if r.StorageClass != "" {
+		return fmt.Sprintf("%v/%v=%v", r.Namespace, r.StorageClass, r.Limit)
+	}
+	return fmt.Sprintf("%v=%v", r.Namespace, r.Limit)
+}
+
+// OpenEBS configures the OpenEBS node disk manager
+type OpenEBS struct {
+	// Filters selects the block devices managed by OpenEBS
+	Filters OpenEBSFilters `json:"filters"`
+	// Pools declares the OpenEBS storage pools and their storage classes
+	Pools []StoragePool `json:"pools,omitempty"`
+}
+
+// StoragePool declares an OpenEBS storage pool along with
+// the storage class that provisions volumes from it
+type StoragePool struct {
+	// Name is the name of the pool
+	Name string `json:"name"`
+	// Type is the pool type, either cstor or localpv
+	Type string `json:"type"`
+	// StorageClass is the name of the storage class provisioning volumes from the pool
+	StorageClass string `json:"storageClass"`
+	// Default marks the storage class as the default storage class of the cluster
+	Default bool `json:"default,omitempty"`
+	// Replicas is the number of replicas of each cStor volume.
+	// Defaults to the number of pool nodes up to 3
+	Replicas int `json:"replicas,omitempty"`
+	// RAIDType is the layout of the block devices of a cStor pool
+	// on each node, either stripe or mirror. Defaults to stripe
+	RAIDType string `json:"raidType,omitempty"`
+	// Path is the directory with the volumes of a LocalPV pool.
+	// If unspecified, LocalPV volumes are provisioned on dedicated block devices
+	Path string `json:"path,omitempty"`
+	// Nodes lists the nodes of the pool along with the block devices
+	// the pool claims on them.
+	// LocalPV pools without nodes span all cluster nodes
+	Nodes []StoragePoolNode `json:"nodes,omitempty"`
+}
+
+// StoragePoolNode describes the block devices a storage pool claims on a node
+type StoragePoolNode struct {
+	// Node is the hostname or the advertise IP of the node
+	Node string `json:"node"`
+	// Devices lists the paths of the block devices claimed by the pool
+	Devices []string `json:"devices,omitempty"`
+}
+
+// IsCStor returns true if this is a cStor pool
+func (r StoragePool) IsCStor() bool {
+	return r.Type == StoragePoolCStor
+}
+
+// Check validates the pool and sets defaults
+func (r *StoragePool) Check() error {
+	if r.Name == "" {
+		return trace.BadParameter("storage pool is missing name")
+	}
+	if r.StorageClass == "" {
+		return trace.BadParameter("storage pool %v is missing storage class", r.Name)
+	}
+	nodes := make(map[string]bool)
+	for _, node := range r.Nodes {
+		if node.Node == "" {
+			return trace.BadParameter("storage pool %v has a node without name", r.Name)
+		}
+		if nodes[node.Node] {
+			return trace.BadParameter("storage pool %v lists node %v more than once", r.Name, node.Node)
+		}
+		nodes[node.Node] = true
+		devices := make(map[string]bool)
+		for _, device := range node.Devices {
+			if device == "" || devices[device] {
+				return trace.BadParameter("storage pool %v lists an empty or duplicate "+
+					"block device on node %v", r.Name, node.Node)
+			}
+			devices[device] = true
+		}
+	}
+	switch r.Type {
+	case StoragePoolCStor:
+		return trace.Wrap(r.checkCStor())
+	case StoragePoolLocalPV:
+		return trace.Wrap(r.checkLocalPV())
+	}
+	return trace.BadParameter("storage pool %v has unsupported type %q, supported types are %v and %v",
+		r.Name, r.Type, StoragePoolCStor, StoragePoolLocalPV)
+}
+
+func (r *StoragePool) checkCStor() error {
+	if len(r.Nodes) == 0 {
+		return trace.BadParameter("cStor storage pool %v does not list any nodes", r.Name)
+	}
+	if r.Path != "" {
+		return trace.BadParameter("cStor storage pool %v cannot specify path", r.Name)
+	}
+	if r.RAIDType == "" {
+		r.RAIDType = StoragePoolRAIDStripe
+	}
+	for _, node := range r.Nodes {
+		switch r.RAIDType {
+		case StoragePoolRAIDStripe:
+			if len(node.Devices) == 0 {
+				return trace.BadParameter("cStor storage pool %v does not list any block devices on node %v",
+					r.Name, node.Node)
+			}
+		case StoragePoolRAIDMirror:
+			if len(node.Devices) == 0 || len(node.Devices)%2 != 0 {
+				return trace.BadParameter("mirrored cStor storage pool %v requires an even number "+
+					"of block devices on node %v", r.Name, node.Node)
+			}
+		default:
+			return trace.BadParameter("cStor storage pool %v has unsupported RAID type %q, "+
+				"supported types are %v and %v", r.Name, r.RAIDType, StoragePoolRAIDStripe, StoragePoolRAIDMirror)
+		}
+	}
+	if r.Replicas == 0 {
+		r.Replicas = len(r.Nodes)
+		if r.Replicas > defaults.CStorMaxDefaultReplicas {
+			r.Replicas = defaults.CStorMaxDefaultReplicas
+		}
+	}
+	if r.Replicas < 0 || r.Replicas > len(r.Nodes) {
+		return trace.BadParameter("cStor storage pool %v can have between 1 and %v replicas, got %v",
+			r.Name, len(r.Nodes), r.Replicas)
+	}
+	return nil
+}
+
+func (r *StoragePool) checkLocalPV() error {
+	if r.RAIDType != "" {
+		return trace.BadParameter("LocalPV storage pool %v cannot specify RAID type", r.Name)
+	}
+	if r.Replicas > 1 {
+		return trace.BadParameter("LocalPV storage pool %v cannot be replicated", r.Name)
+	}
+	for _, node := range r.Nodes {
+		if r.Path != "" && len(node.Devices) != 0 {
+			return trace.BadParameter("LocalPV storage pool %v with path cannot claim block devices", r.Name)
+		}
+	}
+	return nil
+}
+
+// OpenEBSFilters selects the block devices managed by OpenEBS in addition
+// to the default node disk manager exclusions
+type OpenEBSFilters struct {
+	// Devices filters the block devices by path.
+	// A device matches if its path contains the specified value
+	Devices OpenEBSFilter `json:"devices"`
+	// Vendors filters the block devices by vendor
+	Vendors OpenEBSFilter `json:"vendors"`
+}
+
+// OpenEBSFilter lists the values to include and exclude.
+// Empty include list matches any device
+type OpenEBSFilter struct {
+	// Include lists the values to include
+	Include []string `json:"include,omitempty"`
+	// Exclude lists the values to exclude
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// GetIncludeDevices returns path patterns of devices to include
+func (r *PersistentStorageV2) GetIncludeDevices() []string {
+	return r.Spec.OpenEBS.Filters.Devices.Include
+}
+
+// GetExcludeDevices returns path patterns of devices to exclude
+func (r *PersistentStorageV2) GetExcludeDevices() []string {
+	return r.Spec.OpenEBS.Filters.Devices.Exclude
+}
+
+// GetIncludeVendors returns vendors of devices to include
+func (r *PersistentStorageV2) GetIncludeVendors() []string {
+	return r.Spec.OpenEBS.Filters.Vendors.Include
+}
+
+// GetExcludeVendors returns vendors of devices to exclude
+func (r *PersistentStorageV2) GetExcludeVendors() []string {
+	return r.Spec.OpenEBS.Filters.Vendors.Exclude
+}
+
+// GetQuotas returns the soft quotas on persistent storage usage
+func (r *PersistentStorageV2) GetQuotas() []StorageQuota {
+	return r.Spec.Quotas
+}
+
+// GetPools returns the OpenEBS storage pools
+func (r *PersistentStorageV2) GetPools() []StoragePool {
+	return r.Spec.OpenEBS.Pools
+}
+
+// CheckAndSetDefaults checks validity of all parameters and sets defaults
+func (r *PersistentStorageV2) CheckAndSetDefaults() error {
+	if r.Metadata.Name == "" {
+		r.Metadata.Name = KindPersistentStorage
+	}
+	if err := r.Metadata.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, values := range [][]string{
+		r.GetIncludeDevices(), r.GetExcludeDevices(),
+		r.GetIncludeVendors(), r.GetExcludeVendors(),
+	} {
+		for _, value := range values {
+			if value == "" {
+				return trace.BadParameter("device filters cannot contain empty values")
+			}
+		}
+	}
+	quotas := make(map[StorageQuota]bool)
+	for i := range r.Spec.Quotas {
+		quota := &r.Spec.Quotas[i]
+		if err := quota.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+		key := StorageQuota{Namespace: quota.Namespace, StorageClass: quota.StorageClass}
+		if quotas[key] {
+			return trace.BadParameter("duplicate storage quota for namespace %q and storage class %q",
+				quota.Namespace, quota.StorageClass)
+		}
+		quotas[key] = true
+	}
+	pools := make(map[string]bool)
+	classes := make(map[string]bool)
+	var defaultClass string
+	for i := range r.Spec.OpenEBS.Pools {
+		pool := &r.Spec.OpenEBS.Pools[i]
+		if err := pool.Check(); err != nil {
+			return trace.Wrap(err)
+		}
+		if pools[pool.Name] {
+			return trace.BadParameter("duplicate storage pool %v", pool.Name)
+		}
+		pools[pool.Name] = true
+		if classes[pool.StorageClass] {
+			return trace.BadParameter("storage class %v is used by more than one storage pool",
+				pool.StorageClass)
+		}
+		classes[pool.StorageClass] = true
+		if pool.Default {
+			if defaultClass != "" {
+				return trace.BadParameter("only one default storage class can be specified, got %v and %v",
+					defaultClass, pool.StorageClass)
+			}
+			defaultClass = pool.StorageClass
+		}
+	}
+	return nil
+}
+
+// String returns a textual representation of this persistent storage configuration
+func (r *PersistentStorageV2) String() string {
+	return fmt.Sprintf("PersistentStorageV2(Devices=%v, Vendors=%v, Pools=%v, Quotas=%v)",
+		r.Spec.OpenEBS.Filters.Devices, r.Spec.OpenEBS.Filters.Vendors, r.Spec.OpenEBS.Pools, r.Spec.Quotas)
+}
+
+// UnmarshalPersistentStorage unmarshals persistent storage configuration from JSON or YAML
+func UnmarshalPersistentStorage(data []byte) (PersistentStorage, error) {
+	if len(data) == 0 {
+		return nil, trace.BadParameter("empty configuration")
+	}
+	jsonData, err := teleutils.ToJSON(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var hdr teleservices.ResourceHeader
+	err = json.Unmarshal(jsonData, &hdr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch hdr.Version {
+	case teleservices.V2:
+		var config PersistentStorageV2
+		err := teleutils.UnmarshalWithSchema(GetPersistentStorageSchema(), &config, jsonData)
+		if err != nil {
+			return nil, trace.BadParameter("%v", err)
+		}
+		if err := config.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &config, nil
+	}
+	return nil, trace.BadParameter(
+		"%v resource version %q is not supported", KindPersistentStorage, hdr.Version)
+}
+
+// MarshalPersistentStorage marshals persistent storage configuration into JSON
+func MarshalPersistentStorage(config PersistentStorage, opts ...teleservices.MarshalOption) ([]byte, error) {
+	return json.Marshal(config)
+}
+
+const (
+	// StoragePoolCStor is the type of cStor storage pools
+	StoragePoolCStor = "cstor"
+	// StoragePoolLocalPV is the type of LocalPV storage pools
+	StoragePoolLocalPV = "localpv"
+
+	// StoragePoolRAIDStripe stripes the data of a cStor pool
+	// across the block devices of a node
+	StoragePoolRAIDStripe = "stripe"
+	// StoragePoolRAIDMirror mirrors the data of a cStor pool
+	// between pairs of block devices of a node
+	StoragePoolRAIDMirror = "mirror"
+)
+
+// PersistentStorageSpecV2Schema is JSON schema for the persistent storage configuration
+const PersistentStorageSpecV2Schema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "openebs": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "filters": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "devices": %[1]v,
+            "vendors": %[1]v
+          }
+        },
+        "pools": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "additionalProperties": false,
+            "required": ["name", "type", "storageClass"],
+            "properties": {
+              "name": {"type": "string"},
+              "type": {"type": "string"},
+              "storageClass": {"type": "string"},
+              "default": {"type": "boolean"},
+              "replicas": {"type": "integer"},
+              "raidType": {"type": "string"},
+              "path": {"type": "string"},
+              "nodes": {
+                "type": "array",
+                "items": {
+                  "type": "object",
+                  "additionalProperties": false,
+                  "required": ["node"],
+                  "properties": {
+                    "node": {"type": "string"},
+                    "devices": {"type": "array", "items": {"type": "string"}}
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "quotas": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["namespace", "limit"],
+        "properties": {
+          "namespace": {"type": "string"},
+          "storageClass": {"type": "string"},
+          "limit": {"type": "string"},
+          "warningThreshold": {"type": "integer"}
+        }
+      }
+    }
+  }
+}`
+
+// openEBSFilterSchema is JSON schema for a single device filter
+const openEBSFilterSchema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "include": {"type": "array", "items": {"type": "string"}},
+    "exclude": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+// GetPersistentStorageSchema returns the persistent storage schema for version V2
+func GetPersistentStorageSchema() string {
+	return fmt.Sprintf(teleservices.V2SchemaTemplate, MetadataSchema,
+		fmt.Sprintf(PersistentStorageSpecV2Schema, openEBSFilterSchema), "")
+}
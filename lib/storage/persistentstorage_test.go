@@ -0,0 +1,165 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/gravitational/gravity/lib/compare"
+
+	"github.com/gravitational/trace"
+	check "gopkg.in/check.v1"
+)
+
+type PersistentStorageSuite struct{}
+
+var _ = check.Suite(&PersistentStorageSuite{})
+
+func (s *PersistentStorageSuite) TestResourceParsing(c *check.C) {
+	spec := `kind: persistentstorage
+version: v2
+spec:
+  openebs:
+    filters:
+      devices:
+        include: ["/dev/sd"]
+        exclude: ["/dev/sda"]
+      vendors:
+        exclude: ["QEMU"]
+  quotas:
+  - namespace: db
+    limit: 100Gi
+  - namespace: db
+    storageClass: openebs-hostpath
+    limit: 50Gi
+    warningThreshold: 90
+`
+	config, err := UnmarshalPersistentStorage([]byte(spec))
+	c.Assert(err, check.IsNil)
+	c.Assert(config, compare.DeepEquals, NewPersistentStorage(PersistentStorageSpecV2{
+		OpenEBS: OpenEBS{
+			Filters: OpenEBSFilters{
+				Devices: OpenEBSFilter{
+					Include: []string{"/dev/sd"},
+					Exclude: []string{"/dev/sda"},
+				},
+				Vendors: OpenEBSFilter{
+					Exclude: []string{"QEMU"},
+				},
+			},
+		},
+		Quotas: []StorageQuota{
+			{Namespace: "db", Limit: "100Gi", WarningThreshold: 80},
+			{Namespace: "db", StorageClass: "openebs-hostpath", Limit: "50Gi", WarningThreshold: 90},
+		},
+	}))
+}
+
+func (s *PersistentStorageSuite) TestParsesStoragePools(c *check.C) {
+	spec := `kind: persistentstorage
+version: v2
+spec:
+  openebs:
+    pools:
+    - name: cstor-pool
+      type: cstor
+      storageClass: openebs-cstor
+      default: true
+      raidType: mirror
+      nodes:
+      - node: node-1
+        devices: ["/dev/sdb", "/dev/sdc"]
+      - node: node-2
+        devices: ["/dev/sdb", "/dev/sdc"]
+    - name: local-pool
+      type: localpv
+      storageClass: openebs-hostpath
+      path: /var/openebs/local
+`
+	config, err := UnmarshalPersistentStorage([]byte(spec))
+	c.Assert(err, check.IsNil)
+	c.Assert(config.GetPools(), compare.DeepEquals, []StoragePool{
+		{
+			Name:         "cstor-pool",
+			Type:         StoragePoolCStor,
+			StorageClass: "openebs-cstor",
+			Default:      true,
+			Replicas:     2,
+			RAIDType:     StoragePoolRAIDMirror,
+			Nodes: []StoragePoolNode{
+				{Node: "node-1", Devices: []string{"/dev/sdb", "/dev/sdc"}},
+				{Node: "node-2", Devices: []string{"/dev/sdb", "/dev/sdc"}},
+			},
+		},
+		{
+			Name:         "local-pool",
+			Type:         StoragePoolLocalPV,
+			StorageClass: "openebs-hostpath",
+			Path:         "/var/openebs/local",
+		},
+	})
+}
+
+func (s *PersistentStorageSuite) TestValidatesConfig(c *check.C) {
+	var testCases = []struct {
+		spec    string
+		comment string
+	}{
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  openebs:\n    filters:\n      devices:\n        include: ['']",
+			comment: "empty filter value",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v1\nspec: {}",
+			comment: "unsupported version",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  quotas:\n  - namespace: db\n    limit: lots",
+			comment: "invalid quota limit",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  quotas:\n  - namespace: db\n    limit: 1Gi\n    warningThreshold: 120",
+			comment: "invalid quota warning threshold",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  quotas:\n  - namespace: db\n    limit: 1Gi\n  - namespace: db\n    limit: 2Gi",
+			comment: "duplicate quota",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  openebs:\n    pools:\n    - name: p\n      type: zfs\n      storageClass: c",
+			comment: "unsupported pool type",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  openebs:\n    pools:\n    - name: p\n      type: cstor\n      storageClass: c",
+			comment: "cStor pool without nodes",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  openebs:\n    pools:\n    - name: p\n      type: cstor\n      storageClass: c\n      raidType: mirror\n      nodes:\n      - node: n\n        devices: [/dev/sdb]",
+			comment: "odd number of mirrored devices",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  openebs:\n    pools:\n    - name: p\n      type: cstor\n      storageClass: c\n      replicas: 2\n      nodes:\n      - node: n\n        devices: [/dev/sdb]",
+			comment: "more replicas than nodes",
+		},
+		{
+			spec:    "kind: persistentstorage\nversion: v2\nspec:\n  openebs:\n    pools:\n    - name: p\n      type: localpv\n      storageClass: c\n    - name: q\n      type: localpv\n      storageClass: c",
+			comment: "storage class shared by pools",
+		},
+	}
+	for _, tc := range testCases {
+		_, err := UnmarshalPersistentStorage([]byte(tc.spec))
+		c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf(tc.comment))
+	}
+}
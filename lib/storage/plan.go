@@ -23,6 +23,7 @@ import (
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/utils"
 
+	teleservices "github.com/gravitational/teleport/lib/services"
 	"github.com/gravitational/trace"
 )
 
@@ -40,6 +41,9 @@ type OperationPlan struct {
 	Phases []OperationPhase `json:"phases"`
 	// Servers is the list of all cluster servers
 	Servers []Server `json:"servers"`
+	// SkippedServers lists servers intentionally excluded from the operation.
+	// These servers need to be brought up-to-date once they become available
+	SkippedServers []Server `json:"skipped_servers,omitempty"`
 	// GravityPackage is the gravity package locator to update to
 	GravityPackage loc.Locator `json:"gravity_package"`
 	// CreatedAt is the plan creation timestamp
@@ -81,12 +85,39 @@ type OperationPhase struct {
 	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
 	// Parallel enables parallel execution of sub-phases
 	Parallel bool `json:"parallel"`
+	// Pause marks the phase as a pause point: plan execution stops after
+	// the phase completes until the operation is resumed
+	Pause bool `json:"pause,omitempty" yaml:"pause,omitempty"`
+	// Timeout is the optional maximum amount of time the phase is allowed to run.
+	// A phase that exceeds its timeout is canceled, rolled back and marked as failed
+	Timeout *teleservices.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
 	// Updated is the last phase update time
 	Updated time.Time `json:"updated,omitempty" yaml:"updated,omitempty"`
 	// Data is optional phase-specific data attached to the phase
 	Data *OperationPhaseData `json:"data,omitempty" yaml:"data,omitempty"`
 	// Error is the error that happened during phase execution
 	Error *trace.RawTrace `json:"error,omitempty"`
+	// Checkpoint describes the execution history of the phase
+	Checkpoint *PhaseCheckpoint `json:"checkpoint,omitempty" yaml:"checkpoint,omitempty"`
+}
+
+// PhaseCheckpoint describes the execution history of a phase
+// as recorded in the plan changelog
+type PhaseCheckpoint struct {
+	// Attempts is the number of times the phase has been started
+	Attempts int `json:"attempts"`
+	// Started is the time the phase was first started
+	Started time.Time `json:"started,omitempty" yaml:"started,omitempty"`
+	// Updated is the time of the last phase state change
+	Updated time.Time `json:"updated,omitempty" yaml:"updated,omitempty"`
+	// LastError is the error of the last failed attempt
+	LastError *trace.RawTrace `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	// Transient indicates whether the last failure was caused by
+	// a transient error and the phase can be retried
+	Transient bool `json:"transient,omitempty" yaml:"transient,omitempty"`
+	// Logs is the compressed output of the last phase execution
+	// on a remote node
+	Logs []byte `json:"logs,omitempty" yaml:"-"`
 }
 
 // OperationPhaseData represents data attached to an operation phase
@@ -147,6 +178,31 @@ type UpdateOperationData struct {
 	// The list might be a subset of all cluster servers in case
 	// the operation only operates on a specific part
 	Servers []UpdateServer `json:"updates,omitempty"`
+	// Network describes the optional change of the cluster
+	// pod/service networks
+	Network *NetworkChange `json:"network,omitempty"`
+}
+
+// NetworkChange describes a change of the cluster pod/service networks
+type NetworkChange struct {
+	// PrevPodCIDR specifies the pod network before the change
+	PrevPodCIDR string `json:"prev_pod_cidr"`
+	// PodCIDR specifies the new pod network
+	PodCIDR string `json:"pod_cidr"`
+	// PrevServiceCIDR specifies the service network before the change
+	PrevServiceCIDR string `json:"prev_service_cidr"`
+	// ServiceCIDR specifies the new service network
+	ServiceCIDR string `json:"service_cidr"`
+}
+
+// PodCIDRChanged returns true if the pod network is changed
+func (r NetworkChange) PodCIDRChanged() bool {
+	return r.PrevPodCIDR != r.PodCIDR
+}
+
+// ServiceCIDRChanged returns true if the service network is changed
+func (r NetworkChange) ServiceCIDRChanged() bool {
+	return r.PrevServiceCIDR != r.ServiceCIDR
 }
 
 // UpdateServer describes an intent to update runtime/teleport configuration
@@ -215,6 +271,14 @@ type InstallOperationData struct {
 	Resources []byte `json:"resources,omitempty"`
 	// GravityResources specifies optional Gravity resources to create upon successful installation
 	GravityResources []UnknownResource `json:"gravity_resources,omitempty"`
+	// DNS specifies optional cluster DNS configuration resource
+	DNS []byte `json:"dns,omitempty"`
+	// PersistentStorage specifies optional persistent storage configuration
+	// resource with the OpenEBS storage pools to configure
+	PersistentStorage []byte `json:"persistent_storage,omitempty"`
+	// RegistryDir specifies the pre-seeded docker registry directory
+	// to populate the cluster registry from
+	RegistryDir string `json:"registry_dir,omitempty"`
 }
 
 // Application describes an application for the package cleaner
@@ -241,6 +305,17 @@ type PlanChange struct {
 	Created time.Time `json:"created"`
 	// Error is the error that happened during phase execution
 	Error *trace.RawTrace `json:"error"`
+	// Transient indicates whether the error is transient
+	Transient bool `json:"transient,omitempty"`
+	// Logs is the compressed output captured while executing the phase
+	// on a remote node. Changes that only attach the logs have no new state
+	Logs []byte `json:"logs,omitempty"`
+}
+
+// IsStateChange returns true if this change transitions the phase
+// into a new state
+func (c PlanChange) IsStateChange() bool {
+	return c.NewState != ""
 }
 
 // PlanChangelog is a list of plan state changes
@@ -250,7 +325,7 @@ type PlanChangelog []PlanChange
 func (c PlanChangelog) Latest(phaseID string) *PlanChange {
 	var latest *PlanChange
 	for i, change := range c {
-		if change.PhaseID != phaseID {
+		if change.PhaseID != phaseID || !change.IsStateChange() {
 			continue
 		}
 		if latest == nil || change.Created.After(latest.Created) {
@@ -260,6 +335,43 @@ func (c PlanChangelog) Latest(phaseID string) *PlanChange {
 	return latest
 }
 
+// Checkpoint returns the execution checkpoint for the specified phase
+// or nil if the changelog has no entries for the phase
+func (c PlanChangelog) Checkpoint(phaseID string) *PhaseCheckpoint {
+	var checkpoint *PhaseCheckpoint
+	var lastFailure, lastLogs time.Time
+	for _, change := range c {
+		if change.PhaseID != phaseID {
+			continue
+		}
+		if checkpoint == nil {
+			checkpoint = &PhaseCheckpoint{}
+		}
+		if len(change.Logs) != 0 && !change.Created.Before(lastLogs) {
+			lastLogs = change.Created
+			checkpoint.Logs = change.Logs
+		}
+		if !change.IsStateChange() {
+			continue
+		}
+		if change.NewState == OperationPhaseStateInProgress {
+			checkpoint.Attempts++
+			if checkpoint.Started.IsZero() || change.Created.Before(checkpoint.Started) {
+				checkpoint.Started = change.Created
+			}
+		}
+		if change.Created.After(checkpoint.Updated) {
+			checkpoint.Updated = change.Created
+		}
+		if change.NewState == OperationPhaseStateFailed && !change.Created.Before(lastFailure) {
+			lastFailure = change.Created
+			checkpoint.LastError = change.Error
+			checkpoint.Transient = change.Transient
+		}
+	}
+	return checkpoint
+}
+
 // HasSubphases returns true if the phase has 1 or more subphases
 func (p OperationPhase) HasSubphases() bool {
 	return len(p.Phases) > 0
@@ -270,6 +382,15 @@ func (p OperationPhase) IsUnstarted() bool {
 	return p.GetState() == OperationPhaseStateUnstarted
 }
 
+// GetTimeout returns the maximum amount of time the phase is allowed to run.
+// Returns 0 if the phase has no timeout
+func (p OperationPhase) GetTimeout() time.Duration {
+	if p.Timeout == nil {
+		return 0
+	}
+	return p.Timeout.Duration
+}
+
 // IsInProgress returns true if the phase is in "in progress" state
 func (p OperationPhase) IsInProgress() bool {
 	return p.GetState() == OperationPhaseStateInProgress
@@ -0,0 +1,300 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/docker/distribution/reference"
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+)
+
+// RegistryConfig configures availability and access to the cluster's Docker registry
+type RegistryConfig interface {
+	// Resource provides common resource methods
+	teleservices.Resource
+	// CheckAndSetDefaults verifies that the object is valid
+	CheckAndSetDefaults() error
+	// GetHighAvailability returns the high availability mode of the registry
+	GetHighAvailability() string
+	// GetSyncInterval returns the interval images are replicated between masters with
+	GetSyncInterval() time.Duration
+	// GetAllowPush returns whether images can be pushed to the registry directly
+	GetAllowPush() bool
+	// GetMirrorImages returns the upstream images to mirror into the registry
+	GetMirrorImages() []string
+	// GetMirrorInterval returns the interval the upstream images are mirrored with
+	GetMirrorInterval() time.Duration
+}
+
+const (
+	// RegistryHighAvailabilityNone specifies that the registry of each master
+	// node only contains the images of the installed applications
+	RegistryHighAvailabilityNone = "none"
+	// RegistryHighAvailabilityReplicated specifies that the images pushed to
+	// the registry of any master node are replicated to all other master nodes
+	RegistryHighAvailabilityReplicated = "replicated"
+	// RegistryHighAvailabilityShared specifies that all master nodes
+	// share the registry storage, e.g. a network filesystem mounted
+	// into the registry directory, so no replication is necessary
+	RegistryHighAvailabilityShared = "shared"
+)
+
+// RegistryHighAvailabilityModes lists the supported registry high availability modes
+var RegistryHighAvailabilityModes = []string{
+	RegistryHighAvailabilityNone,
+	RegistryHighAvailabilityReplicated,
+	RegistryHighAvailabilityShared,
+}
+
+// DefaultRegistryConfig returns the registry configuration used
+// if the cluster registry has not been configured explicitly
+func DefaultRegistryConfig() RegistryConfig {
+	config := NewRegistryConfig(RegistryConfigSpecV2{})
+	config.CheckAndSetDefaults()
+	return config
+}
+
+// NewRegistryConfig creates a new registry configuration resource from the provided spec
+func NewRegistryConfig(spec RegistryConfigSpecV2) RegistryConfig {
+	return &RegistryConfigV2{
+		Kind:    KindRegistryConfig,
+		Version: teleservices.V2,
+		Metadata: teleservices.Metadata{
+			Name:      KindRegistryConfig,
+			Namespace: teledefaults.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// RegistryConfigV2 configures availability and access to the cluster's Docker registry
+type RegistryConfigV2 struct {
+	// Metadata is resource metadata
+	teleservices.Metadata `json:"metadata"`
+	// Kind is a resource kind
+	Kind string `json:"kind"`
+	// Version is a resource version
+	Version string `json:"version"`
+	// Spec defines the registry configuration
+	Spec RegistryConfigSpecV2 `json:"spec"`
+}
+
+// RegistryConfigSpecV2 configures availability and access to the cluster's Docker registry
+type RegistryConfigSpecV2 struct {
+	// HighAvailability specifies how the images are made available
+	// on all master nodes: none, replicated or shared. Defaults to none
+	HighAvailability string `json:"high_availability,omitempty"`
+	// SyncInterval specifies how often images are replicated
+	// between master nodes in replicated mode
+	SyncInterval teleservices.Duration `json:"sync_interval,omitempty"`
+	// AllowPush allows users with permissions to create applications
+	// to push images to the registry through the cluster's web endpoint
+	AllowPush bool `json:"allow_push,omitempty"`
+	// Mirror configures mirroring of upstream images into the registry
+	Mirror *RegistryMirrorConfig `json:"mirror,omitempty"`
+}
+
+// RegistryMirrorConfig configures mirroring of upstream images into the registry
+type RegistryMirrorConfig struct {
+	// Images lists the images to mirror from upstream registries.
+	// Each image is a fully qualified reference with a tag and an optional digest,
+	// e.g. quay.io/coreos/etcd:v3.3.15@sha256:<digest>.
+	// If the digest is specified, the upstream image is verified against it
+	Images []string `json:"images,omitempty"`
+	// Interval specifies how often the images are mirrored
+	Interval teleservices.Duration `json:"interval,omitempty"`
+}
+
+// GetHighAvailability returns the high availability mode of the registry
+func (r *RegistryConfigV2) GetHighAvailability() string {
+	return r.Spec.HighAvailability
+}
+
+// GetSyncInterval returns the interval images are replicated between masters with
+func (r *RegistryConfigV2) GetSyncInterval() time.Duration {
+	return r.Spec.SyncInterval.Duration
+}
+
+// GetAllowPush returns whether images can be pushed to the registry directly
+func (r *RegistryConfigV2) GetAllowPush() bool {
+	return r.Spec.AllowPush
+}
+
+// GetMirrorImages returns the upstream images to mirror into the registry
+func (r *RegistryConfigV2) GetMirrorImages() []string {
+	if r.Spec.Mirror == nil {
+		return nil
+	}
+	return r.Spec.Mirror.Images
+}
+
+// GetMirrorInterval returns the interval the upstream images are mirrored with
+func (r *RegistryConfigV2) GetMirrorInterval() time.Duration {
+	if r.Spec.Mirror == nil {
+		return defaults.RegistryMirrorInterval
+	}
+	return r.Spec.Mirror.Interval.Duration
+}
+
+// CheckAndSetDefaults checks validity of all parameters and sets defaults
+func (r *RegistryConfigV2) CheckAndSetDefaults() error {
+	if r.Metadata.Name == "" {
+		r.Metadata.Name = KindRegistryConfig
+	}
+	if err := r.Metadata.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Spec.HighAvailability == "" {
+		r.Spec.HighAvailability = RegistryHighAvailabilityNone
+	}
+	if !utils.StringInSlice(RegistryHighAvailabilityModes, r.Spec.HighAvailability) {
+		return trace.BadParameter("unsupported high availability mode %q, supported are: %v",
+			r.Spec.HighAvailability, RegistryHighAvailabilityModes)
+	}
+	if r.Spec.SyncInterval.Duration < 0 {
+		return trace.BadParameter("sync interval cannot be negative")
+	}
+	if r.Spec.SyncInterval.Duration == 0 {
+		r.Spec.SyncInterval.Duration = defaults.RegistryReplicationInterval
+	}
+	if r.Spec.SyncInterval.Duration < defaults.MinRegistryReplicationInterval {
+		return trace.BadParameter("sync interval should be at least %v", defaults.MinRegistryReplicationInterval)
+	}
+	if r.Spec.Mirror != nil {
+		if err := r.Spec.Mirror.checkAndSetDefaults(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+func (r *RegistryMirrorConfig) checkAndSetDefaults() error {
+	for _, image := range r.Images {
+		if err := CheckMirrorImage(image); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if r.Interval.Duration < 0 {
+		return trace.BadParameter("mirror interval cannot be negative")
+	}
+	if r.Interval.Duration == 0 {
+		r.Interval.Duration = defaults.RegistryMirrorInterval
+	}
+	if r.Interval.Duration < defaults.MinRegistryMirrorInterval {
+		return trace.BadParameter("mirror interval should be at least %v", defaults.MinRegistryMirrorInterval)
+	}
+	return nil
+}
+
+// CheckMirrorImage verifies that the specified image can be mirrored:
+// the image should be referenced with the registry domain and a tag
+func CheckMirrorImage(image string) error {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return trace.BadParameter("invalid image reference %q: %v", image, err)
+	}
+	named, ok := ref.(reference.Named)
+	if !ok || !isRegistryDomain(reference.Domain(named)) {
+		return trace.BadParameter("image %q should include the registry domain, e.g. docker.io/library/nginx:1.17", image)
+	}
+	if _, ok := ref.(reference.Tagged); !ok {
+		return trace.BadParameter("image %q should include a tag", image)
+	}
+	return nil
+}
+
+// String returns a textual representation of this registry configuration
+func (r *RegistryConfigV2) String() string {
+	return fmt.Sprintf("RegistryConfigV2(HighAvailability=%v, SyncInterval=%v, AllowPush=%v, MirrorImages=%v)",
+		r.Spec.HighAvailability, r.Spec.SyncInterval.Duration, r.Spec.AllowPush, r.GetMirrorImages())
+}
+
+// isRegistryDomain returns true if the specified first component of the image
+// name is a registry domain and not a part of the repository path
+func isRegistryDomain(domain string) bool {
+	return strings.ContainsAny(domain, ".:") || domain == "localhost"
+}
+
+// UnmarshalRegistryConfig unmarshals registry configuration from JSON or YAML
+func UnmarshalRegistryConfig(data []byte) (RegistryConfig, error) {
+	if len(data) == 0 {
+		return nil, trace.BadParameter("empty configuration")
+	}
+	jsonData, err := teleutils.ToJSON(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var hdr teleservices.ResourceHeader
+	err = json.Unmarshal(jsonData, &hdr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch hdr.Version {
+	case teleservices.V2:
+		var config RegistryConfigV2
+		err := teleutils.UnmarshalWithSchema(GetRegistryConfigSchema(), &config, jsonData)
+		if err != nil {
+			return nil, trace.BadParameter("%v", err)
+		}
+		if err := config.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &config, nil
+	}
+	return nil, trace.BadParameter(
+		"%v resource version %q is not supported", KindRegistryConfig, hdr.Version)
+}
+
+// MarshalRegistryConfig marshals registry configuration into JSON
+func MarshalRegistryConfig(config RegistryConfig, opts ...teleservices.MarshalOption) ([]byte, error) {
+	return json.Marshal(config)
+}
+
+// RegistryConfigSpecV2Schema is JSON schema for the registry configuration
+const RegistryConfigSpecV2Schema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "high_availability": {"type": "string"},
+    "sync_interval": {"type": "string"},
+    "allow_push": {"type": "boolean"},
+    "mirror": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "images": {"type": "array", "items": {"type": "string"}},
+        "interval": {"type": "string"}
+      }
+    }
+  }
+}`
+
+// GetRegistryConfigSchema returns the registry configuration schema for version V2
+func GetRegistryConfigSchema() string {
+	return fmt.Sprintf(teleservices.V2SchemaTemplate, MetadataSchema,
+		RegistryConfigSpecV2Schema, "")
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/defaults"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	check "gopkg.in/check.v1"
+)
+
+type RegistryConfigSuite struct{}
+
+var _ = check.Suite(&RegistryConfigSuite{})
+
+func (s *RegistryConfigSuite) TestResourceParsing(c *check.C) {
+	spec := `kind: registry
+version: v2
+spec:
+  high_availability: replicated
+  sync_interval: 5m
+  allow_push: true
+`
+	config, err := UnmarshalRegistryConfig([]byte(spec))
+	c.Assert(err, check.IsNil)
+	c.Assert(config, compare.DeepEquals, NewRegistryConfig(RegistryConfigSpecV2{
+		HighAvailability: RegistryHighAvailabilityReplicated,
+		SyncInterval:     teleservices.NewDuration(5 * time.Minute),
+		AllowPush:        true,
+	}))
+}
+
+func (s *RegistryConfigSuite) TestSetsDefaults(c *check.C) {
+	config, err := UnmarshalRegistryConfig([]byte("kind: registry\nversion: v2\nspec: {}"))
+	c.Assert(err, check.IsNil)
+	c.Assert(config.GetHighAvailability(), check.Equals, RegistryHighAvailabilityNone)
+	c.Assert(config.GetSyncInterval(), check.Equals, defaults.RegistryReplicationInterval)
+	c.Assert(config.GetAllowPush(), check.Equals, false)
+	c.Assert(DefaultRegistryConfig(), compare.DeepEquals, config)
+}
+
+func (s *RegistryConfigSuite) TestParsesMirror(c *check.C) {
+	spec := `kind: registry
+version: v2
+spec:
+  mirror:
+    images:
+    - docker.io/library/nginx:1.17
+    - quay.io/coreos/etcd:v3.3.15@sha256:0e1c2c8ad0c7fb9bb3da2a1d32ef3faa7b1a8e9b9b6c6ea4a3a7c6e1f1e9c3a2
+`
+	config, err := UnmarshalRegistryConfig([]byte(spec))
+	c.Assert(err, check.IsNil)
+	c.Assert(config.GetMirrorImages(), check.DeepEquals, []string{
+		"docker.io/library/nginx:1.17",
+		"quay.io/coreos/etcd:v3.3.15@sha256:0e1c2c8ad0c7fb9bb3da2a1d32ef3faa7b1a8e9b9b6c6ea4a3a7c6e1f1e9c3a2",
+	})
+	c.Assert(config.GetMirrorInterval(), check.Equals, defaults.RegistryMirrorInterval)
+}
+
+func (s *RegistryConfigSuite) TestValidatesConfig(c *check.C) {
+	var testCases = []struct {
+		spec    string
+		comment string
+	}{
+		{spec: "high_availability: active", comment: "unsupported mode"},
+		{spec: "sync_interval: -1m", comment: "negative interval"},
+		{spec: "sync_interval: 1s", comment: "interval too short"},
+		{spec: "mirror: {images: [library/nginx:1.17]}", comment: "image without registry domain"},
+		{spec: "mirror: {images: [docker.io/library/nginx]}", comment: "image without tag"},
+		{spec: "mirror: {images: [docker.io/library/nginx:1.17@sha256:123]}", comment: "invalid digest"},
+		{spec: "mirror: {images: [docker.io/library/nginx:1.17], interval: 10s}", comment: "mirror interval too short"},
+	}
+	for _, tc := range testCases {
+		_, err := UnmarshalRegistryConfig([]byte("kind: registry\nversion: v2\nspec:\n  " + tc.spec))
+		c.Assert(err, check.NotNil, check.Commentf(tc.comment))
+	}
+}
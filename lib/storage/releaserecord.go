@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gravitational/gravity/lib/loc"
+
+	"github.com/gravitational/trace"
+)
+
+// ReleaseRecords defines the interface to manage the history
+// of application releases installed into the cluster
+type ReleaseRecords interface {
+	// UpsertReleaseRecord creates or updates the record of the release revision
+	UpsertReleaseRecord(ReleaseRecord) (*ReleaseRecord, error)
+	// GetReleaseRecord returns the record of the specified release revision
+	GetReleaseRecord(clusterName, name string, revision int) (*ReleaseRecord, error)
+	// GetReleaseRecords returns the records of all revisions of the specified
+	// release sorted by revision
+	GetReleaseRecords(clusterName, name string) ([]ReleaseRecord, error)
+}
+
+// ReleaseRecord describes a single revision of an application release
+type ReleaseRecord struct {
+	// Name is the release name
+	Name string `json:"name"`
+	// ClusterName is the name of the cluster the release is installed in
+	ClusterName string `json:"cluster_name"`
+	// Revision is the release revision
+	Revision int `json:"revision"`
+	// Namespace is the namespace the release is installed into
+	Namespace string `json:"namespace"`
+	// Application is the application package of this revision
+	Application loc.Locator `json:"application"`
+	// Dependencies lists the application packages this revision depends on
+	Dependencies []loc.Locator `json:"dependencies,omitempty"`
+	// Action is the action that has created this revision
+	Action string `json:"action"`
+	// Status is the status of this revision
+	Status string `json:"status"`
+	// Description describes the outcome of the action
+	Description string `json:"description,omitempty"`
+	// Updated is the time the revision has been last updated
+	Updated time.Time `json:"updated"`
+}
+
+// Check validates this release record
+func (r ReleaseRecord) Check() error {
+	if r.Name == "" {
+		return trace.BadParameter("missing Name")
+	}
+	if r.ClusterName == "" {
+		return trace.BadParameter("missing ClusterName")
+	}
+	if r.Revision <= 0 {
+		return trace.BadParameter("release %v: revision must be positive", r.Name)
+	}
+	if r.Application.IsEmpty() {
+		return trace.BadParameter("release %v: missing Application", r.Name)
+	}
+	return nil
+}
+
+// String returns a textual representation of this release record
+func (r ReleaseRecord) String() string {
+	return fmt.Sprintf("ReleaseRecord(Name=%v, Revision=%v, Application=%v, Status=%v)",
+		r.Name, r.Revision, r.Application, r.Status)
+}
+
+const (
+	// ReleaseActionInstall is the action of a revision created by install
+	ReleaseActionInstall = "install"
+	// ReleaseActionUpgrade is the action of a revision created by upgrade
+	ReleaseActionUpgrade = "upgrade"
+	// ReleaseActionRollback is the action of a revision created by rollback
+	ReleaseActionRollback = "rollback"
+
+	// ReleaseStatusDeployed is the status of the revision currently deployed
+	ReleaseStatusDeployed = "deployed"
+	// ReleaseStatusSuperseded is the status of a revision replaced by a later one
+	ReleaseStatusSuperseded = "superseded"
+	// ReleaseStatusFailed is the status of a revision whose action has failed
+	ReleaseStatusFailed = "failed"
+	// ReleaseStatusUninstalled is the status of the last revision
+	// of an uninstalled release
+	ReleaseStatusUninstalled = "uninstalled"
+)
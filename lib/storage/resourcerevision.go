@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/gravitational/trace"
+)
+
+// ResourceRevisions defines the interface to manage the revisions
+// of cluster resources
+type ResourceRevisions interface {
+	// GetResourceRevision returns the revision of the specified resource
+	GetResourceRevision(clusterName, kind, name string) (*ResourceRevision, error)
+	// UpsertResourceRevision creates or updates the revision of a resource
+	UpsertResourceRevision(ResourceRevision) error
+}
+
+// ResourceRevision tracks the revision of a cluster resource
+type ResourceRevision struct {
+	// ClusterName is the name of the cluster the resource belongs to
+	ClusterName string `json:"cluster_name"`
+	// Kind is the resource kind
+	Kind string `json:"kind"`
+	// Name is the resource name
+	Name string `json:"name"`
+	// Revision is the current revision of the resource.
+	// It is incremented with every observed update of the resource
+	Revision int64 `json:"revision"`
+	// Digest is the digest of the resource contents at the current revision
+	Digest string `json:"digest,omitempty"`
+	// OperationID optionally specifies the operation started
+	// to update the resource at the current revision
+	OperationID string `json:"operation_id,omitempty"`
+}
+
+// Check validates this resource revision
+func (r ResourceRevision) Check() error {
+	if r.ClusterName == "" {
+		return trace.BadParameter("missing ClusterName")
+	}
+	if r.Kind == "" {
+		return trace.BadParameter("missing Kind")
+	}
+	if r.Revision < 0 {
+		return trace.BadParameter("%v %q: revision cannot be negative", r.Kind, r.Name)
+	}
+	return nil
+}
@@ -73,6 +73,20 @@ const (
 	KindRelease = "release"
 	// KindInvite defines the user invite token.
 	KindInvite = "invite"
+	// KindClusterDNS defines the cluster DNS configuration resource type
+	KindClusterDNS = "dns"
+	// KindHealthReport defines the scheduled health report configuration resource type
+	KindHealthReport = "healthreport"
+	// KindPersistentStorage defines the persistent storage configuration resource type
+	KindPersistentStorage = "persistentstorage"
+	// KindAdmissionWebhook defines the resource validation webhook resource type
+	KindAdmissionWebhook = "admissionwebhook"
+	// KindOperationPolicy defines the cluster operation policy resource type
+	KindOperationPolicy = "operationpolicy"
+	// KindRegistryConfig defines the cluster registry configuration resource type
+	KindRegistryConfig = "registry"
+	// KindRetentionPolicy defines the cluster retention policy resource type
+	KindRetentionPolicy = "retention"
 )
 
 // CanonicalKind translates the specified kind to canonical form.
@@ -81,7 +95,11 @@ func CanonicalKind(kind string) string {
 	switch strings.ToLower(kind) {
 	case teleservices.KindGithubConnector:
 		return teleservices.KindGithubConnector
-	case teleservices.KindAuthConnector, "auth":
+	case teleservices.KindOIDCConnector:
+		return teleservices.KindOIDCConnector
+	case teleservices.KindSAMLConnector:
+		return teleservices.KindSAMLConnector
+	case teleservices.KindAuthConnector, "authconnectors", "auth":
 		return teleservices.KindAuthConnector
 	case teleservices.KindUser, "users":
 		return teleservices.KindUser
@@ -105,6 +123,20 @@ func CanonicalKind(kind string) string {
 		return KindClusterConfiguration
 	case KindAuthGateway, "gw":
 		return KindAuthGateway
+	case KindClusterDNS, "dnsconfig":
+		return KindClusterDNS
+	case KindHealthReport, "healthreports":
+		return KindHealthReport
+	case KindPersistentStorage, "storage":
+		return KindPersistentStorage
+	case KindAdmissionWebhook, "admission", "webhook":
+		return KindAdmissionWebhook
+	case KindOperationPolicy, "policy":
+		return KindOperationPolicy
+	case KindRegistryConfig, "registries":
+		return KindRegistryConfig
+	case KindRetentionPolicy, "retentionpolicy":
+		return KindRetentionPolicy
 	}
 	return kind
 }
@@ -160,6 +192,8 @@ func Encode(resources []UnknownResource, w io.Writer) error {
 var SupportedGravityResources = []string{
 	teleservices.KindClusterAuthPreference,
 	teleservices.KindGithubConnector,
+	teleservices.KindOIDCConnector,
+	teleservices.KindSAMLConnector,
 	teleservices.KindAuthConnector,
 	teleservices.KindUser,
 	KindToken,
@@ -171,12 +205,20 @@ var SupportedGravityResources = []string{
 	KindAuthGateway,
 	KindRuntimeEnvironment,
 	KindClusterConfiguration,
+	KindHealthReport,
+	KindAdmissionWebhook,
+	KindOperationPolicy,
+	KindRegistryConfig,
+	KindRetentionPolicy,
+	KindPersistentStorage,
 }
 
 // SupportedGravityResourcesToRemove is a list of resources supported by
 // "gravity resource rm" subcommand
 var SupportedGravityResourcesToRemove = []string{
 	teleservices.KindGithubConnector,
+	teleservices.KindOIDCConnector,
+	teleservices.KindSAMLConnector,
 	teleservices.KindUser,
 	KindToken,
 	KindLogForwarder,
@@ -186,6 +228,44 @@ var SupportedGravityResourcesToRemove = []string{
 	KindTLSKeyPair,
 	KindRuntimeEnvironment,
 	KindClusterConfiguration,
+	KindHealthReport,
+	KindAdmissionWebhook,
+	KindOperationPolicy,
+	KindRegistryConfig,
+	KindRetentionPolicy,
+}
+
+// SupportedGravityResourcesToExport is a list of resources exported by
+// "gravity resource get all --export" subcommand.
+// The resources are listed in the order they are imported in: dependent
+// resources follow the ones they depend on and the resources managed
+// with cluster operations come last
+var SupportedGravityResourcesToExport = []string{
+	teleservices.KindGithubConnector,
+	teleservices.KindOIDCConnector,
+	teleservices.KindSAMLConnector,
+	teleservices.KindUser,
+	KindLogForwarder,
+	KindSMTPConfig,
+	KindAlert,
+	KindAlertTarget,
+	KindTLSKeyPair,
+	KindAuthGateway,
+	KindHealthReport,
+	KindAdmissionWebhook,
+	KindOperationPolicy,
+	KindRegistryConfig,
+	KindRetentionPolicy,
+	KindPersistentStorage,
+	KindRuntimeEnvironment,
+	KindClusterConfiguration,
+}
+
+// GravityResourcesUpdatedWithOperations is a list of exported resources
+// that are applied with a cluster operation
+var GravityResourcesUpdatedWithOperations = []string{
+	KindRuntimeEnvironment,
+	KindClusterConfiguration,
 }
 
 // MetadataSchema is a copy of teleport/lib/services.MetadataSchema but with
@@ -0,0 +1,234 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	teledefaults "github.com/gravitational/teleport/lib/defaults"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	teleutils "github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/trace"
+)
+
+// RetentionPolicy defines how long the records of finished cluster operations
+// and the cluster audit log are kept
+type RetentionPolicy interface {
+	// Resource provides common resource methods
+	teleservices.Resource
+	// CheckAndSetDefaults verifies that the object is valid
+	CheckAndSetDefaults() error
+	// GetOperations returns the retention limits for operation records
+	// or nil if operation records are kept forever
+	GetOperations() *RetentionLimits
+	// GetAudit returns the retention limits for the audit log
+	// or nil if the audit log is kept forever
+	GetAudit() *RetentionLimits
+	// GetExportWebhookURL returns the URL the records are posted to before they are purged
+	GetExportWebhookURL() string
+}
+
+// NewRetentionPolicy creates a new retention policy resource from the provided spec
+func NewRetentionPolicy(spec RetentionPolicySpecV2) RetentionPolicy {
+	return &RetentionPolicyV2{
+		Kind:    KindRetentionPolicy,
+		Version: teleservices.V2,
+		Metadata: teleservices.Metadata{
+			Name:      KindRetentionPolicy,
+			Namespace: teledefaults.Namespace,
+		},
+		Spec: spec,
+	}
+}
+
+// RetentionPolicyV2 defines how long the records of finished cluster operations
+// and the cluster audit log are kept
+type RetentionPolicyV2 struct {
+	// Metadata is resource metadata
+	teleservices.Metadata `json:"metadata"`
+	// Kind is a resource kind
+	Kind string `json:"kind"`
+	// Version is a resource version
+	Version string `json:"version"`
+	// Spec defines the retention limits
+	Spec RetentionPolicySpecV2 `json:"spec"`
+}
+
+// RetentionPolicySpecV2 defines the retention limits
+type RetentionPolicySpecV2 struct {
+	// Operations limits the records of finished operations including
+	// their plans, plan change logs and progress entries
+	Operations *RetentionLimits `json:"operations,omitempty"`
+	// Audit limits the daily files of the cluster audit log
+	Audit *RetentionLimits `json:"audit,omitempty"`
+	// ExportWebhookURL specifies the URL to POST the records to in JSON
+	// format before they are purged. Records are not purged if the export fails
+	ExportWebhookURL string `json:"export_webhook_url,omitempty"`
+}
+
+// RetentionLimits defines the age and count limits of retained records.
+// Records that exceed any of the limits are purged
+type RetentionLimits struct {
+	// MaxAge is the age of the records after which they are purged
+	MaxAge teleservices.Duration `json:"max_age,omitempty"`
+	// MaxCount is the maximum number of records to keep
+	MaxCount int `json:"max_count,omitempty"`
+}
+
+// Check validates the retention limits
+func (r RetentionLimits) Check() error {
+	if r.MaxAge.Duration < 0 {
+		return trace.BadParameter("max age cannot be negative")
+	}
+	if r.MaxCount < 0 {
+		return trace.BadParameter("max count cannot be negative")
+	}
+	if r.MaxAge.Duration == 0 && r.MaxCount == 0 {
+		return trace.BadParameter("either max age or max count should be specified")
+	}
+	return nil
+}
+
+// Exceeds returns true if the record with the specified creation time and
+// position among the records sorted from newest to oldest exceeds the limits
+func (r RetentionLimits) Exceeds(created, now time.Time, index int) bool {
+	if r.MaxCount != 0 && index >= r.MaxCount {
+		return true
+	}
+	return r.MaxAge.Duration != 0 && now.Sub(created) > r.MaxAge.Duration
+}
+
+// String returns a textual representation of the limits
+func (r RetentionLimits) String() string {
+	return fmt.Sprintf("RetentionLimits(MaxAge=%v, MaxCount=%v)", r.MaxAge.Duration, r.MaxCount)
+}
+
+// GetOperations returns the retention limits for operation records
+func (r *RetentionPolicyV2) GetOperations() *RetentionLimits {
+	return r.Spec.Operations
+}
+
+// GetAudit returns the retention limits for the audit log
+func (r *RetentionPolicyV2) GetAudit() *RetentionLimits {
+	return r.Spec.Audit
+}
+
+// GetExportWebhookURL returns the URL the records are posted to before they are purged
+func (r *RetentionPolicyV2) GetExportWebhookURL() string {
+	return r.Spec.ExportWebhookURL
+}
+
+// CheckAndSetDefaults checks validity of all parameters and sets defaults
+func (r *RetentionPolicyV2) CheckAndSetDefaults() error {
+	if r.Metadata.Name == "" {
+		r.Metadata.Name = KindRetentionPolicy
+	}
+	if err := r.Metadata.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Spec.Operations == nil && r.Spec.Audit == nil {
+		return trace.BadParameter("retention policy should limit operations or audit log")
+	}
+	if r.Spec.Operations != nil {
+		if err := r.Spec.Operations.Check(); err != nil {
+			return trace.Wrap(err, "invalid operations retention")
+		}
+	}
+	if r.Spec.Audit != nil {
+		if err := r.Spec.Audit.Check(); err != nil {
+			return trace.Wrap(err, "invalid audit retention")
+		}
+	}
+	if r.Spec.ExportWebhookURL != "" {
+		u, err := url.ParseRequestURI(r.Spec.ExportWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return trace.BadParameter("invalid export webhook URL %q, expected http(s) URL",
+				r.Spec.ExportWebhookURL)
+		}
+	}
+	return nil
+}
+
+// String returns a textual representation of this retention policy
+func (r *RetentionPolicyV2) String() string {
+	return fmt.Sprintf("RetentionPolicyV2(Operations=%v, Audit=%v, ExportWebhookURL=%v)",
+		r.Spec.Operations, r.Spec.Audit, r.Spec.ExportWebhookURL)
+}
+
+// UnmarshalRetentionPolicy unmarshals retention policy from JSON or YAML
+func UnmarshalRetentionPolicy(data []byte) (RetentionPolicy, error) {
+	if len(data) == 0 {
+		return nil, trace.BadParameter("empty retention policy")
+	}
+	jsonData, err := teleutils.ToJSON(data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var hdr teleservices.ResourceHeader
+	err = json.Unmarshal(jsonData, &hdr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch hdr.Version {
+	case teleservices.V2:
+		var policy RetentionPolicyV2
+		err := teleutils.UnmarshalWithSchema(GetRetentionPolicySchema(), &policy, jsonData)
+		if err != nil {
+			return nil, trace.BadParameter("%v", err)
+		}
+		if err := policy.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &policy, nil
+	}
+	return nil, trace.BadParameter(
+		"%v resource version %q is not supported", KindRetentionPolicy, hdr.Version)
+}
+
+// MarshalRetentionPolicy marshals retention policy into JSON
+func MarshalRetentionPolicy(policy RetentionPolicy, opts ...teleservices.MarshalOption) ([]byte, error) {
+	return json.Marshal(policy)
+}
+
+// RetentionPolicySpecV2Schema is JSON schema for the retention policy
+var RetentionPolicySpecV2Schema = fmt.Sprintf(`{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "operations": %[1]v,
+    "audit": %[1]v,
+    "export_webhook_url": {"type": "string"}
+  }
+}`, retentionLimitsSchema)
+
+const retentionLimitsSchema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "max_age": {"type": "string"},
+    "max_count": {"type": "integer"}
+  }
+}`
+
+// GetRetentionPolicySchema returns the retention policy schema for version V2
+func GetRetentionPolicySchema() string {
+	return fmt.Sprintf(teleservices.V2SchemaTemplate, MetadataSchema,
+		RetentionPolicySpecV2Schema, "")
+}
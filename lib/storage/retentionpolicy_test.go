@@ -0,0 +1,80 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/compare"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	check "gopkg.in/check.v1"
+)
+
+type RetentionPolicySuite struct{}
+
+var _ = check.Suite(&RetentionPolicySuite{})
+
+func (s *RetentionPolicySuite) TestResourceParsing(c *check.C) {
+	spec := `kind: retention
+version: v2
+spec:
+  operations:
+    max_age: 8760h
+    max_count: 100
+  audit:
+    max_age: 2160h
+  export_webhook_url: https://archive.example.com/gravity
+`
+	policy, err := UnmarshalRetentionPolicy([]byte(spec))
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, compare.DeepEquals, NewRetentionPolicy(RetentionPolicySpecV2{
+		Operations: &RetentionLimits{
+			MaxAge:   teleservices.NewDuration(8760 * time.Hour),
+			MaxCount: 100,
+		},
+		Audit: &RetentionLimits{
+			MaxAge: teleservices.NewDuration(2160 * time.Hour),
+		},
+		ExportWebhookURL: "https://archive.example.com/gravity",
+	}))
+}
+
+func (s *RetentionPolicySuite) TestValidatesPolicy(c *check.C) {
+	var testCases = []struct {
+		spec    string
+		comment string
+	}{
+		{spec: "{}", comment: "no limits"},
+		{spec: "{operations: {}}", comment: "empty limits"},
+		{spec: "{operations: {max_age: -1h}}", comment: "negative age"},
+		{spec: "{audit: {max_count: -1}}", comment: "negative count"},
+		{spec: "{audit: {max_count: 10}, export_webhook_url: archive}", comment: "invalid webhook URL"},
+	}
+	for _, tc := range testCases {
+		_, err := UnmarshalRetentionPolicy([]byte("kind: retention\nversion: v2\nspec: " + tc.spec))
+		c.Assert(err, check.NotNil, check.Commentf(tc.comment))
+	}
+}
+
+func (s *RetentionPolicySuite) TestExceedsLimits(c *check.C) {
+	now := time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+	limits := RetentionLimits{MaxAge: teleservices.NewDuration(time.Hour), MaxCount: 2}
+	c.Assert(limits.Exceeds(now.Add(-time.Minute), now, 0), check.Equals, false)
+	c.Assert(limits.Exceeds(now.Add(-time.Minute), now, 2), check.Equals, true)
+	c.Assert(limits.Exceeds(now.Add(-2*time.Hour), now, 0), check.Equals, true)
+}
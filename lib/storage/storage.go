@@ -45,6 +45,7 @@ import (
 	"github.com/gravitational/trace"
 	"github.com/jonboulle/clockwork"
 	"github.com/tstranex/u2f"
+	v1 "k8s.io/api/core/v1"
 )
 
 // Accounts collection modifies and updates account entries,
@@ -439,6 +440,13 @@ type SiteOperation struct {
 	UpdateEnviron *UpdateEnvarsOperationState `json:"update_environ,omitempty"`
 	// UpdateConfig defines the state of the cluster configuration update operation
 	UpdateConfig *UpdateConfigOperationState `json:"update_config,omitempty"`
+	// Patch defines the state of the node operating system patch operation
+	Patch *PatchOperationState `json:"patch,omitempty"`
+	// UpdateBinary defines the state of the gravity binary update operation
+	UpdateBinary *UpdateBinaryOperationState `json:"update_binary,omitempty"`
+	// Component defines the state of the operation that enables
+	// or disables an optional application component
+	Component *ComponentOperationState `json:"component,omitempty"`
 }
 
 func (s *SiteOperation) Check() error {
@@ -583,6 +591,21 @@ type Site struct {
 	DNSConfig DNSConfig `json:"dns_config"`
 	// InstallToken specifies the original token the cluster was installed with
 	InstallToken string `json:"install_token"`
+	// DisabledComponents lists the optional application components
+	// excluded from the cluster
+	DisabledComponents []string `json:"disabled_components,omitempty"`
+	// DefaultDenyNetworkPolicy specifies whether the baseline network policies
+	// have been requested during installation
+	DefaultDenyNetworkPolicy bool `json:"default_deny_network_policy,omitempty"`
+	// InstallResources lists the resources (TLS key pair, auth connectors, users)
+	// uploaded before installation to be created at the end of install
+	InstallResources []UnknownResource `json:"install_resources,omitempty"`
+	// CNI is the network plugin the cluster has been installed with.
+	// Empty for clusters installed without an explicit plugin selection
+	CNI string `json:"cni,omitempty"`
+	// SELinux specifies whether SELinux is configured on the cluster nodes.
+	// Nodes joining the cluster are configured the same way
+	SELinux bool `json:"selinux,omitempty"`
 }
 
 func (s *Site) Check() error {
@@ -1305,6 +1328,211 @@ func (p *InstallToken) Check() error {
 	return nil
 }
 
+// DownloadTokens defines the interface to manage tokens that grant
+// time-limited access to download application installers
+type DownloadTokens interface {
+	// CreateDownloadToken creates a new download token
+	CreateDownloadToken(DownloadToken) (*DownloadToken, error)
+	// GetDownloadToken returns the download token with the specified ID
+	// if it has not expired yet
+	GetDownloadToken(token string) (*DownloadToken, error)
+	// GetDownloadTokens returns download tokens for the specified account
+	// that have not expired yet
+	GetDownloadTokens(accountID string) ([]DownloadToken, error)
+	// DeleteDownloadToken deletes the download token with the specified ID
+	DeleteDownloadToken(token string) error
+}
+
+// DownloadToken grants anyone in possession of it the permission to download
+// the installer of a specific application until the token expires
+type DownloadToken struct {
+	// Token is a unique randomly generated character sequence
+	Token string `json:"token"`
+	// AccountID is the account the token was created in
+	AccountID string `json:"account_id"`
+	// Application is the application the token grants access to
+	Application loc.Locator `json:"application"`
+	// CreatedBy is the user who created the token
+	CreatedBy string `json:"created_by"`
+	// Created is the time the token was created
+	Created time.Time `json:"created"`
+	// Expires is the time the token expires
+	Expires time.Time `json:"expires"`
+	// URL is the download URL the token is embedded in
+	URL string `json:"url"`
+}
+
+// Check validates this download token
+func (t *DownloadToken) Check() error {
+	if t.Token == "" {
+		return trace.BadParameter("missing Token")
+	}
+	if t.AccountID == "" {
+		return trace.BadParameter("missing AccountID")
+	}
+	if t.Application.IsEmpty() {
+		return trace.BadParameter("missing Application")
+	}
+	if t.Expires.IsZero() {
+		return trace.BadParameter("missing Expires")
+	}
+	return nil
+}
+
+// OperationTokens defines the interface to manage bearer tokens
+// scoped to a single cluster operation
+type OperationTokens interface {
+	// CreateOperationToken creates a new operation token
+	CreateOperationToken(OperationToken) (*OperationToken, error)
+	// GetOperationToken returns the operation token with the specified ID
+	// if it has not expired yet
+	GetOperationToken(token string) (*OperationToken, error)
+	// GetOperationTokens returns tokens of the specified operation
+	// that have not expired yet
+	GetOperationTokens(clusterName, operationID string) ([]OperationToken, error)
+	// DeleteOperationToken deletes the operation token with the specified ID
+	DeleteOperationToken(token string) error
+}
+
+// OperationToken grants anyone in possession of it read access to the plan
+// and progress of a specific operation until the token expires
+type OperationToken struct {
+	// Token is a unique randomly generated character sequence
+	Token string `json:"token"`
+	// AccountID is the account the operation belongs to
+	AccountID string `json:"account_id"`
+	// SiteDomain is the name of the cluster the operation belongs to
+	SiteDomain string `json:"site_domain"`
+	// OperationID is the ID of the operation the token grants access to
+	OperationID string `json:"operation_id"`
+	// Created is the time the token was created
+	Created time.Time `json:"created"`
+	// Expires is the time the token expires
+	Expires time.Time `json:"expires"`
+}
+
+// Check validates this operation token
+func (t *OperationToken) Check() error {
+	if t.Token == "" {
+		return trace.BadParameter("missing Token")
+	}
+	if t.SiteDomain == "" {
+		return trace.BadParameter("missing SiteDomain")
+	}
+	if t.OperationID == "" {
+		return trace.BadParameter("missing OperationID")
+	}
+	if t.Expires.IsZero() {
+		return trace.BadParameter("missing Expires")
+	}
+	return nil
+}
+
+// Verify returns an error if this token does not grant access
+// to the specified operation at the given time
+func (t *OperationToken) Verify(accountID, clusterName, operationID string, now time.Time) error {
+	if t.AccountID != accountID || t.SiteDomain != clusterName || t.OperationID != operationID {
+		return trace.AccessDenied("token is not valid for operation %v", operationID)
+	}
+	if !now.Before(t.Expires) {
+		return trace.AccessDenied("operation token has expired")
+	}
+	return nil
+}
+
+// OperationApprovals defines the interface to manage requests to approve cluster operations
+type OperationApprovals interface {
+	// CreateOperationApproval creates a new operation approval request
+	CreateOperationApproval(OperationApproval) (*OperationApproval, error)
+	// GetOperationApproval returns the approval request with the specified ID
+	// if it has not expired yet
+	GetOperationApproval(id string) (*OperationApproval, error)
+	// GetOperationApprovals returns approval requests for the specified cluster
+	// that have not expired yet
+	GetOperationApprovals(clusterName string) ([]OperationApproval, error)
+	// UpdateOperationApproval updates the specified approval request
+	UpdateOperationApproval(OperationApproval) (*OperationApproval, error)
+	// DeleteOperationApproval deletes the approval request with the specified ID
+	DeleteOperationApproval(id string) error
+}
+
+// OperationApproval is a request for another user to approve a cluster operation
+type OperationApproval struct {
+	// ID uniquely identifies the request
+	ID string `json:"id"`
+	// ClusterName is the name of the cluster the operation is for
+	ClusterName string `json:"cluster_name"`
+	// Operation is the name of the operation, e.g. shrink
+	Operation string `json:"operation"`
+	// Servers lists hostnames of the servers targeted by the operation
+	Servers []string `json:"servers,omitempty"`
+	// Force is whether the operation is forced
+	Force bool `json:"force,omitempty"`
+	// Rule is the name of the operation policy rule that requires approval.
+	// Empty if the approval is required by the policy approval requirements
+	Rule string `json:"rule,omitempty"`
+	// RequestedBy is the user who requested the operation
+	RequestedBy string `json:"requested_by"`
+	// Created is the time the request was created
+	Created time.Time `json:"created"`
+	// Expires is the time the request expires
+	Expires time.Time `json:"expires"`
+	// ApprovedBy is the user who approved the operation
+	ApprovedBy string `json:"approved_by,omitempty"`
+	// Approved is the time the operation was approved
+	Approved time.Time `json:"approved,omitempty"`
+}
+
+// Check validates this approval request
+func (a *OperationApproval) Check() error {
+	if a.ID == "" {
+		return trace.BadParameter("missing ID")
+	}
+	if a.ClusterName == "" {
+		return trace.BadParameter("missing ClusterName")
+	}
+	if a.Operation == "" {
+		return trace.BadParameter("missing Operation")
+	}
+	if a.RequestedBy == "" {
+		return trace.BadParameter("missing RequestedBy")
+	}
+	if a.Expires.IsZero() {
+		return trace.BadParameter("missing Expires")
+	}
+	return nil
+}
+
+// IsApproved returns true if the operation has been approved
+func (a OperationApproval) IsApproved() bool {
+	return a.ApprovedBy != ""
+}
+
+// Matches returns true if this and the other request are for the same operation
+func (a OperationApproval) Matches(other OperationApproval) bool {
+	return a.ClusterName == other.ClusterName &&
+		a.Operation == other.Operation &&
+		a.Force == other.Force &&
+		a.Rule == other.Rule &&
+		a.RequestedBy == other.RequestedBy &&
+		utils.CompareStringSlices(a.Servers, other.Servers)
+}
+
+// String returns a textual representation of this approval request
+func (a OperationApproval) String() string {
+	description := a.Operation
+	if a.Force {
+		description = "forced " + description
+	}
+	if len(a.Servers) != 0 {
+		description = fmt.Sprintf("%v of %v", description, strings.Join(a.Servers, ", "))
+	}
+	if a.Rule != "" {
+		description = fmt.Sprintf("%v (policy rule %q)", description, a.Rule)
+	}
+	return fmt.Sprintf("%v requested by %v", description, a.RequestedBy)
+}
+
 // Peer is a peer node of the package management service
 type Peer struct {
 	ID            string    `json:"id"`
@@ -1461,6 +1689,13 @@ type Backend interface {
 	WebSessions
 	UserTokens
 	Tokens
+	DownloadTokens
+	OperationTokens
+	OperationApprovals
+	VolumeSnapshots
+	ReleaseRecords
+	ResourceRevisions
+	PackageUploads
 	UserInvites
 	Applications
 	AppOperations
@@ -1484,6 +1719,8 @@ type Backend interface {
 	LegacyRoles
 	SystemMetadata
 	Charts
+	AuditLog
+	Watcher
 }
 
 const (
@@ -1549,6 +1786,12 @@ type Server struct {
 	InstanceType string `json:"instance_type"`
 	// InstanceID is cloud specific instance ID
 	InstanceID string `json:"instance_id"`
+	// AvailabilityZone is the cloud availability zone of the instance
+	AvailabilityZone string `json:"availability_zone,omitempty"`
+	// MachineSerial is the machine serial number reported by the system firmware
+	MachineSerial string `json:"machine_serial,omitempty"`
+	// JoinFlags lists the command line flags the node has joined the cluster with
+	JoinFlags []string `json:"join_flags,omitempty"`
 	// ClusterRole is the node's system role, "master" or "node"
 	ClusterRole string `json:"cluster_role"`
 	// Provisioner is the provisioner the server was provisioned with
@@ -1568,6 +1811,10 @@ type Server struct {
 	User OSUser `json:"user"`
 	// Created is the timestamp when the server was created
 	Created time.Time `json:"created"`
+	// Labels specifies additional Kubernetes labels for this node
+	Labels map[string]string `json:"labels,omitempty"`
+	// Taints specifies additional Kubernetes taints for this node
+	Taints []v1.Taint `json:"taints,omitempty"`
 }
 
 // IsEqualTo returns true if this and the provided server are the same server.
@@ -1779,6 +2026,9 @@ type InstallExpandOperationState struct {
 	Vars OperationVariables `json:"vars"`
 	// Package is the application being installed
 	Package loc.Locator `json:"package"`
+	// OverlayMTU is the overlay network MTU derived from the path MTU
+	// detected between the servers during installation
+	OverlayMTU int `json:"overlay_mtu,omitempty"`
 }
 
 // OperationVariables is operation-specific set of variables
@@ -2040,6 +2290,9 @@ type UpdateOperationState struct {
 	ServerUpdates []ServerUpdate `json:"server_updates,omitempty"`
 	// Manual specifies whether this update operation was created in manual mode
 	Manual bool `json:"manual"`
+	// Throttle specifies how much the resource usage of the operation
+	// on cluster nodes is throttled
+	Throttle string `json:"throttle,omitempty"`
 }
 
 // UpdateEnvarsOperationState describes the state of the operation to update cluster environment variables.
@@ -2067,6 +2320,32 @@ type UpdateConfigOperationState struct {
 	Config []byte `json:"config,omitempty"`
 }
 
+// PatchOperationState describes the state of the operation to patch
+// the operating system on cluster nodes
+type PatchOperationState struct {
+	// Command specifies the shell command to run on each node
+	Command string `json:"command,omitempty"`
+	// Reboot specifies whether each node is rebooted instead of running a command
+	Reboot bool `json:"reboot,omitempty"`
+}
+
+// UpdateBinaryOperationState describes the state of the operation to replace
+// the gravity binary on cluster nodes
+type UpdateBinaryOperationState struct {
+	// Package specifies the gravity binary package to install
+	Package string `json:"package"`
+	// PreviousPackage specifies the gravity binary package installed
+	// in the cluster when the operation started
+	PreviousPackage string `json:"previous_package,omitempty"`
+}
+
+// ComponentOperationState describes the state of the operation
+// that enables or disables an optional application component
+type ComponentOperationState struct {
+	// Name is the name of the application component
+	Name string `json:"name"`
+}
+
 // ServerUpdate represents server that is being updated
 type ServerUpdate struct {
 	// Server is a server being updated
@@ -19,6 +19,7 @@ limitations under the License.
 package suite
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"time"
@@ -1396,6 +1397,335 @@ func (s *StorageSuite) ProvisioningTokensCRUD(c *C) {
 	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%#v"))
 }
 
+func (s *StorageSuite) OperationApprovalsCRUD(c *C) {
+	created := s.Clock.Now().UTC()
+	approval := storage.OperationApproval{
+		ID:          "approval1",
+		ClusterName: "a.example.com",
+		Operation:   "shrink",
+		Servers:     []string{"node-2"},
+		Force:       true,
+		RequestedBy: "alice@example.com",
+		Created:     created,
+		Expires:     created.Add(time.Hour),
+	}
+
+	out, err := s.Backend.CreateOperationApproval(approval)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, approval)
+
+	out, err = s.Backend.GetOperationApproval(approval.ID)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, approval)
+
+	other := approval
+	other.ID = "approval2"
+	other.ClusterName = "b.example.com"
+	_, err = s.Backend.CreateOperationApproval(other)
+	c.Assert(err, IsNil)
+
+	approvals, err := s.Backend.GetOperationApprovals(approval.ClusterName)
+	c.Assert(err, IsNil)
+	c.Assert(approvals, DeepEquals, []storage.OperationApproval{approval})
+
+	approval.ApprovedBy = "bob@example.com"
+	approval.Approved = created.Add(time.Minute)
+	_, err = s.Backend.UpdateOperationApproval(approval)
+	c.Assert(err, IsNil)
+
+	out, err = s.Backend.GetOperationApproval(approval.ID)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, approval)
+	c.Assert(out.IsApproved(), Equals, true)
+
+	err = s.Backend.DeleteOperationApproval(approval.ID)
+	c.Assert(err, IsNil)
+
+	_, err = s.Backend.GetOperationApproval(approval.ID)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	s.Clock.Advance(2 * time.Hour)
+	_, err = s.Backend.GetOperationApproval(other.ID)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+}
+
+func (s *StorageSuite) DownloadTokensCRUD(c *C) {
+	created := s.Clock.Now().UTC()
+	token := storage.DownloadToken{
+		Token:       "token1",
+		AccountID:   "account1",
+		Application: loc.MustParseLocator("example.com/app:1.0.0"),
+		CreatedBy:   "alice@example.com",
+		Created:     created,
+		Expires:     created.Add(time.Hour),
+		URL:         "https://example.com/portalapi/v1/download/token1",
+	}
+
+	out, err := s.Backend.CreateDownloadToken(token)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, token)
+
+	out, err = s.Backend.GetDownloadToken(token.Token)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, token)
+
+	other := token
+	other.Token = "token2"
+	other.AccountID = "account2"
+	other.Expires = created.Add(3 * time.Hour)
+	_, err = s.Backend.CreateDownloadToken(other)
+	c.Assert(err, IsNil)
+
+	tokens, err := s.Backend.GetDownloadTokens(token.AccountID)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, DeepEquals, []storage.DownloadToken{token})
+
+	s.Clock.Advance(2 * time.Hour)
+	_, err = s.Backend.GetDownloadToken(token.Token)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	err = s.Backend.DeleteDownloadToken(other.Token)
+	c.Assert(err, IsNil)
+
+	_, err = s.Backend.GetDownloadToken(other.Token)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+}
+
+func (s *StorageSuite) OperationTokensCRUD(c *C) {
+	created := s.Clock.Now().UTC()
+	token := storage.OperationToken{
+		Token:       "token1",
+		AccountID:   "account1",
+		SiteDomain:  "a.example.com",
+		OperationID: "operation1",
+		Created:     created,
+		Expires:     created.Add(time.Hour),
+	}
+
+	out, err := s.Backend.CreateOperationToken(token)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, token)
+
+	out, err = s.Backend.GetOperationToken(token.Token)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, token)
+
+	other := token
+	other.Token = "token2"
+	other.OperationID = "operation2"
+	other.Expires = created.Add(3 * time.Hour)
+	_, err = s.Backend.CreateOperationToken(other)
+	c.Assert(err, IsNil)
+
+	tokens, err := s.Backend.GetOperationTokens(token.SiteDomain, token.OperationID)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, DeepEquals, []storage.OperationToken{token})
+
+	s.Clock.Advance(2 * time.Hour)
+	_, err = s.Backend.GetOperationToken(token.Token)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	err = s.Backend.DeleteOperationToken(other.Token)
+	c.Assert(err, IsNil)
+
+	_, err = s.Backend.GetOperationToken(other.Token)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+}
+
+func (s *StorageSuite) VolumeSnapshotsCRUD(c *C) {
+	created := s.Clock.Now().UTC()
+	snapshot := storage.VolumeSnapshot{
+		Name:        "snapshot1",
+		ClusterName: "a.example.com",
+		Created:     created,
+		CreatedBy:   "alice@example.com",
+		Volumes: []storage.SnapshotVolume{{
+			Namespace:     "default",
+			Claim:         "data",
+			StorageClass:  "openebs-cstor",
+			AccessModes:   []string{"ReadWriteOnce"},
+			Capacity:      "10Gi",
+			SnapshotName:  "data-snapshot1",
+			SnapshotClass: "openebs-cstor-snapshot",
+		}},
+		Etcd: &storage.EtcdSnapshot{
+			Node: "node-1",
+			Path: "/var/lib/gravity/site/snapshots/snapshot1/etcd.backup",
+		},
+	}
+
+	out, err := s.Backend.CreateVolumeSnapshot(snapshot)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, snapshot)
+
+	_, err = s.Backend.CreateVolumeSnapshot(snapshot)
+	c.Assert(trace.IsAlreadyExists(err), Equals, true)
+
+	out, err = s.Backend.GetVolumeSnapshot(snapshot.ClusterName, snapshot.Name)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, snapshot)
+
+	later := snapshot
+	later.Name = "snapshot2"
+	later.Created = created.Add(time.Hour)
+	later.Etcd = nil
+	_, err = s.Backend.CreateVolumeSnapshot(later)
+	c.Assert(err, IsNil)
+
+	other := snapshot
+	other.ClusterName = "b.example.com"
+	_, err = s.Backend.CreateVolumeSnapshot(other)
+	c.Assert(err, IsNil)
+
+	snapshots, err := s.Backend.GetVolumeSnapshots(snapshot.ClusterName)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, DeepEquals, []storage.VolumeSnapshot{snapshot, later})
+
+	err = s.Backend.DeleteVolumeSnapshot(snapshot.ClusterName, snapshot.Name)
+	c.Assert(err, IsNil)
+
+	_, err = s.Backend.GetVolumeSnapshot(snapshot.ClusterName, snapshot.Name)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	err = s.Backend.DeleteVolumeSnapshot(snapshot.ClusterName, snapshot.Name)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+}
+
+func (s *StorageSuite) ReleaseRecordsCRUD(c *C) {
+	updated := s.Clock.Now().UTC()
+	record := storage.ReleaseRecord{
+		Name:         "dashboard",
+		ClusterName:  "a.example.com",
+		Revision:     1,
+		Namespace:    "default",
+		Application:  loc.MustParseLocator("example.com/dashboard:1.0.0"),
+		Dependencies: []loc.Locator{loc.MustParseLocator("example.com/metrics:2.0.0")},
+		Action:       storage.ReleaseActionInstall,
+		Status:       storage.ReleaseStatusDeployed,
+		Updated:      updated,
+	}
+
+	out, err := s.Backend.UpsertReleaseRecord(record)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, record)
+
+	out, err = s.Backend.GetReleaseRecord(record.ClusterName, record.Name, record.Revision)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, record)
+
+	upgrade := record
+	upgrade.Revision = 2
+	upgrade.Application = loc.MustParseLocator("example.com/dashboard:1.1.0")
+	upgrade.Dependencies = nil
+	upgrade.Action = storage.ReleaseActionUpgrade
+	upgrade.Updated = updated.Add(time.Hour)
+	_, err = s.Backend.UpsertReleaseRecord(upgrade)
+	c.Assert(err, IsNil)
+
+	record.Status = storage.ReleaseStatusSuperseded
+	_, err = s.Backend.UpsertReleaseRecord(record)
+	c.Assert(err, IsNil)
+
+	other := record
+	other.ClusterName = "b.example.com"
+	_, err = s.Backend.UpsertReleaseRecord(other)
+	c.Assert(err, IsNil)
+
+	records, err := s.Backend.GetReleaseRecords(record.ClusterName, record.Name)
+	c.Assert(err, IsNil)
+	c.Assert(records, DeepEquals, []storage.ReleaseRecord{record, upgrade})
+
+	records, err = s.Backend.GetReleaseRecords(record.ClusterName, "unknown")
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 0)
+
+	_, err = s.Backend.GetReleaseRecord(record.ClusterName, record.Name, 3)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	invalid := record
+	invalid.Revision = 0
+	_, err = s.Backend.UpsertReleaseRecord(invalid)
+	c.Assert(trace.IsBadParameter(err), Equals, true)
+}
+
+func (s *StorageSuite) ResourceRevisionsCRUD(c *C) {
+	_, err := s.Backend.GetResourceRevision("a.example.com", storage.KindSMTPConfig, "")
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	single := storage.ResourceRevision{
+		ClusterName: "a.example.com",
+		Kind:        storage.KindSMTPConfig,
+		Revision:    1,
+		Digest:      "0123456789abcdef",
+	}
+	c.Assert(s.Backend.UpsertResourceRevision(single), IsNil)
+	named := storage.ResourceRevision{
+		ClusterName: "a.example.com",
+		Kind:        storage.KindAlertTarget,
+		Name:        "target",
+		Revision:    3,
+		OperationID: "op-1",
+	}
+	c.Assert(s.Backend.UpsertResourceRevision(named), IsNil)
+
+	out, err := s.Backend.GetResourceRevision(single.ClusterName, single.Kind, "")
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, single)
+
+	named.Revision++
+	named.OperationID = ""
+	c.Assert(s.Backend.UpsertResourceRevision(named), IsNil)
+	out, err = s.Backend.GetResourceRevision(named.ClusterName, named.Kind, named.Name)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, named)
+
+	_, err = s.Backend.GetResourceRevision("b.example.com", named.Kind, named.Name)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	invalid := named
+	invalid.Revision = -1
+	c.Assert(trace.IsBadParameter(s.Backend.UpsertResourceRevision(invalid)), Equals, true)
+}
+
+func (s *StorageSuite) PackageUploadsCRUD(c *C) {
+	_, err := s.Backend.GetPackageUpload("upload1")
+	c.Assert(trace.IsNotFound(err), Equals, true)
+
+	upload := storage.PackageUpload{
+		ID:      "upload1",
+		User:    "alice@example.com",
+		Spec:    []byte(`{"size":100}`),
+		Expires: s.Clock.Now().UTC().Add(time.Hour),
+	}
+	c.Assert(s.Backend.UpsertPackageUpload(upload), IsNil)
+	out, err := s.Backend.GetPackageUpload(upload.ID)
+	c.Assert(err, IsNil)
+	c.Assert(*out, DeepEquals, upload)
+
+	parts := []storage.PackageUploadPart{
+		{Offset: 100, Size: 50, SHA256: "sha2", BLOB: "blob2"},
+		{Offset: 0, Size: 50, SHA256: "sha1", BLOB: "blob1"},
+		{Offset: 50, Size: 50, SHA256: "sha3", BLOB: "blob3"},
+	}
+	for _, part := range parts {
+		c.Assert(s.Backend.UpsertPackageUploadPart(upload.ID, part), IsNil)
+	}
+	outParts, err := s.Backend.GetPackageUploadParts(upload.ID)
+	c.Assert(err, IsNil)
+	c.Assert(outParts, DeepEquals, []storage.PackageUploadPart{parts[1], parts[2], parts[0]})
+
+	uploads, err := s.Backend.GetPackageUploads()
+	c.Assert(err, IsNil)
+	c.Assert(uploads, DeepEquals, []storage.PackageUpload{upload})
+
+	c.Assert(s.Backend.DeletePackageUpload(upload.ID), IsNil)
+	_, err = s.Backend.GetPackageUpload(upload.ID)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+	outParts, err = s.Backend.GetPackageUploadParts(upload.ID)
+	c.Assert(err, IsNil)
+	c.Assert(outParts, HasLen, 0)
+}
+
 func (s *StorageSuite) SchemaVersionPresent(c *C) {
 	version, err := s.Backend.SchemaVersion()
 	c.Assert(err, IsNil)
@@ -1957,6 +2287,56 @@ func (s *StorageSuite) IndexFile(c *C) {
 	compare.DeepCompare(c, retrievedFile, updatedIndex2)
 }
 
+func (s *StorageSuite) AuditEventsCRUD(c *C) {
+	// No events initially.
+	events, err := s.Backend.GetAuditEvents(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 0)
+
+	first := storage.AuditEvent{
+		ID:      "1",
+		Command: "resource create",
+		Args:    []string{"resource", "create", "token.yaml"},
+		User:    "root",
+		Node:    "node-1",
+		Created: s.Clock.Now().UTC(),
+	}
+	second := storage.AuditEvent{
+		ID:      "2",
+		Command: "leave",
+		User:    "root",
+		Node:    "node-2",
+		Error:   "operation failed",
+		Created: s.Clock.Now().UTC().Add(time.Hour),
+	}
+	for _, e := range []storage.AuditEvent{second, first} {
+		_, err = s.Backend.CreateAuditEvent(e)
+		c.Assert(err, IsNil)
+	}
+
+	// Events are returned sorted by creation time.
+	events, err = s.Backend.GetAuditEvents(time.Time{})
+	c.Assert(err, IsNil)
+	compare.DeepCompare(c, events, []storage.AuditEvent{first, second})
+
+	// Older events are filtered out.
+	events, err = s.Backend.GetAuditEvents(s.Clock.Now().UTC().Add(time.Minute))
+	c.Assert(err, IsNil)
+	compare.DeepCompare(c, events, []storage.AuditEvent{second})
+
+	// Duplicate events are rejected.
+	_, err = s.Backend.CreateAuditEvent(first)
+	c.Assert(trace.IsAlreadyExists(err), Equals, true)
+
+	c.Assert(s.Backend.DeleteAuditEvent(first.ID), IsNil)
+	events, err = s.Backend.GetAuditEvents(time.Time{})
+	c.Assert(err, IsNil)
+	compare.DeepCompare(c, events, []storage.AuditEvent{second})
+
+	err = s.Backend.DeleteAuditEvent(first.ID)
+	c.Assert(trace.IsNotFound(err), Equals, true)
+}
+
 func newIndex() *repo.IndexFile {
 	return &repo.IndexFile{
 		APIVersion: repo.APIVersionV1,
@@ -1973,3 +2353,52 @@ func addToIndex(indexFile repo.IndexFile, name, version string) (updated, previo
 	}}
 	return indexCopy, &indexFile
 }
+
+func (s *StorageSuite) Watch(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	usersC, err := s.Backend.Watch(ctx, teleservices.KindUser)
+	c.Assert(err, IsNil)
+	rolesC, err := s.Backend.Watch(ctx, teleservices.KindRole)
+	c.Assert(err, IsNil)
+
+	_, err = s.Backend.Watch(ctx, "unknown")
+	c.Assert(trace.IsBadParameter(err), Equals, true)
+
+	u := storage.NewUser("watch@example.com", storage.UserSpecV2{Type: storage.AgentUser})
+	_, err = s.Backend.CreateUser(u)
+	c.Assert(err, IsNil)
+	// changes to the keys nested under the user are not reported
+	_, err = s.Backend.CreateAPIKey(storage.APIKey{Token: "key", UserEmail: u.GetName()})
+	c.Assert(err, IsNil)
+	err = s.Backend.DeleteUser(u.GetName())
+	c.Assert(err, IsNil)
+
+	expectEvent(c, usersC, storage.Event{Type: storage.EventTypePut, Kind: teleservices.KindUser, Name: u.GetName()})
+	expectEvent(c, usersC, storage.Event{Type: storage.EventTypeDelete, Kind: teleservices.KindUser, Name: u.GetName()})
+
+	role, err := teleservices.NewRole("watch", teleservices.RoleSpecV3{})
+	c.Assert(err, IsNil)
+	err = s.Backend.UpsertRole(role, storage.Forever)
+	c.Assert(err, IsNil)
+	err = s.Backend.DeleteAllRoles()
+	c.Assert(err, IsNil)
+
+	expectEvent(c, rolesC, storage.Event{Type: storage.EventTypePut, Kind: teleservices.KindRole, Name: "watch"})
+	expectEvent(c, rolesC, storage.Event{Type: storage.EventTypeDelete, Kind: teleservices.KindRole})
+
+	cancel()
+	for range usersC {
+	}
+}
+
+func expectEvent(c *C, eventsC <-chan storage.Event, expected storage.Event) {
+	select {
+	case event, ok := <-eventsC:
+		c.Assert(ok, Equals, true, Commentf("watch closed, expected %v", expected))
+		c.Assert(event, DeepEquals, expected)
+	case <-time.After(5 * time.Second):
+		c.Fatalf("timeout waiting for %v", expected)
+	}
+}
@@ -798,3 +798,14 @@ func UserFromContext(ctx context.Context) string {
 	}
 	return user
 }
+
+// ClientAddrFromContext extracts the address of the client attached to the provided context.
+//
+// Returns an empty string if no address is attached.
+func ClientAddrFromContext(ctx context.Context) string {
+	addr, ok := ctx.Value(constants.ClientAddrContext).(string)
+	if !ok {
+		return ""
+	}
+	return addr
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// VolumeSnapshots defines the interface to manage the metadata
+// of persistent volume snapshots
+type VolumeSnapshots interface {
+	// CreateVolumeSnapshot records a new volume snapshot
+	CreateVolumeSnapshot(VolumeSnapshot) (*VolumeSnapshot, error)
+	// GetVolumeSnapshot returns the volume snapshot with the specified name
+	GetVolumeSnapshot(clusterName, name string) (*VolumeSnapshot, error)
+	// GetVolumeSnapshots returns all volume snapshots of the specified cluster
+	// sorted by creation time
+	GetVolumeSnapshots(clusterName string) ([]VolumeSnapshot, error)
+	// DeleteVolumeSnapshot deletes the volume snapshot with the specified name
+	DeleteVolumeSnapshot(clusterName, name string) error
+}
+
+// VolumeSnapshot describes a set of persistent volume snapshots taken
+// together along with the optional etcd data backup
+type VolumeSnapshot struct {
+	// Name is the snapshot name, unique within the cluster
+	Name string `json:"name"`
+	// ClusterName is the name of the cluster the snapshot was taken in
+	ClusterName string `json:"cluster_name"`
+	// Created is the time the snapshot was taken
+	Created time.Time `json:"created"`
+	// CreatedBy is the user who took the snapshot
+	CreatedBy string `json:"created_by,omitempty"`
+	// Volumes lists the snapshots of individual persistent volume claims
+	Volumes []SnapshotVolume `json:"volumes"`
+	// Etcd is the etcd data backup taken along with the volume snapshots
+	Etcd *EtcdSnapshot `json:"etcd,omitempty"`
+	// Backup is the path to the application backup tarball
+	// if the snapshot was taken as a part of the cluster backup
+	Backup string `json:"backup,omitempty"`
+}
+
+// SnapshotVolume describes a snapshot of a single persistent volume claim
+type SnapshotVolume struct {
+	// Namespace is the namespace of the persistent volume claim
+	Namespace string `json:"namespace"`
+	// Claim is the name of the persistent volume claim
+	Claim string `json:"claim"`
+	// StorageClass is the storage class of the persistent volume claim
+	StorageClass string `json:"storage_class"`
+	// AccessModes lists the access modes of the persistent volume claim
+	AccessModes []string `json:"access_modes,omitempty"`
+	// Capacity is the requested capacity of the persistent volume claim
+	Capacity string `json:"capacity"`
+	// SnapshotName is the name of the Kubernetes volume snapshot resource
+	SnapshotName string `json:"snapshot_name"`
+	// SnapshotClass is the name of the volume snapshot class
+	SnapshotClass string `json:"snapshot_class"`
+}
+
+// EtcdSnapshot describes the etcd data backup taken along with volume snapshots
+type EtcdSnapshot struct {
+	// Node is the hostname of the node the backup is stored on
+	Node string `json:"node"`
+	// Path is the path to the backup file on the node
+	Path string `json:"path"`
+}
+
+// Check validates this volume snapshot
+func (r VolumeSnapshot) Check() error {
+	if r.Name == "" {
+		return trace.BadParameter("missing Name")
+	}
+	if r.ClusterName == "" {
+		return trace.BadParameter("missing ClusterName")
+	}
+	if len(r.Volumes) == 0 {
+		return trace.BadParameter("snapshot %v has no volumes", r.Name)
+	}
+	for _, volume := range r.Volumes {
+		if volume.Namespace == "" || volume.Claim == "" {
+			return trace.BadParameter("snapshot %v: volume is missing namespace or claim name", r.Name)
+		}
+		if volume.SnapshotName == "" {
+			return trace.BadParameter("snapshot %v: volume %v is missing snapshot name", r.Name, volume)
+		}
+	}
+	return nil
+}
+
+// String returns a textual representation of this snapshot volume
+func (r SnapshotVolume) String() string {
+	return fmt.Sprintf("%v/%v", r.Namespace, r.Claim)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Watcher defines the interface to watch the backend for resource changes
+type Watcher interface {
+	// Watch starts watching the resources of the specified kind and returns
+	// the channel with change events.
+	//
+	// The channel is closed when the context is cancelled or the watch
+	// can no longer deliver events, for example because the receiver has
+	// fallen behind. Receivers should then list the resources to catch up
+	// and start a new watch.
+	Watch(ctx context.Context, kind string) (<-chan Event, error)
+}
+
+// Event describes a change to a resource
+type Event struct {
+	// Type is the type of the change
+	Type EventType
+	// Kind is the resource kind
+	Kind string
+	// Name is the resource name.
+	// Empty if all resources of this kind have been removed
+	Name string
+}
+
+// String returns a textual representation of this event
+func (r Event) String() string {
+	return fmt.Sprintf("Event(Type=%v, Kind=%v, Name=%v)", r.Type, r.Kind, r.Name)
+}
+
+// EventType defines the type of a resource change
+type EventType string
+
+const (
+	// EventTypePut indicates that a resource has been created or updated
+	EventTypePut EventType = "put"
+	// EventTypeDelete indicates that a resource has been removed
+	EventTypeDelete EventType = "delete"
+)
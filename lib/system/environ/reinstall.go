@@ -0,0 +1,190 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package environ
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// PreserveDirectories moves the specified application data directories that
+// would be removed during the node teardown into the reinstall directory and
+// records their original locations so that RestoreDirectories can put them
+// back into the freshly installed cluster.
+// Directories outside of the Gravity state directories survive the teardown
+// and are left in place
+func PreserveDirectories(printer utils.Printer, logger log.FieldLogger, paths []string) error {
+	if _, err := os.Stat(preservedDirectoriesPath()); err == nil {
+		return trace.AlreadyExists("found data preserved by a previous reinstall in %v, "+
+			"complete the reinstall with `gravity install` or remove the directory before proceeding",
+			defaults.GravityReinstallDir)
+	}
+	var dirs []preservedDirectory
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			return trace.BadParameter("preserved directory %v should be an absolute path", path)
+		}
+		path = filepath.Clean(path)
+		isDir, err := utils.IsDirectory(path)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !isDir {
+			return trace.BadParameter("preserved path %v is not a directory", path)
+		}
+		if !isUnderDirectory(path, getStateDirectories()...) {
+			logger.WithField("path", path).Info("Directory is outside of state directories, leave in place.")
+			printer.PrintStep("Preserving %v in place", path)
+			continue
+		}
+		dirs = append(dirs, preservedDirectory{
+			Path:      path,
+			StashPath: filepath.Join(defaults.GravityReinstallDir, strconv.Itoa(i)),
+		})
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(defaults.GravityReinstallDir, defaults.SharedDirMask); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	// Record the directories before moving them so that a partially
+	// completed move can still be restored
+	data, err := json.Marshal(dirs)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = ioutil.WriteFile(preservedDirectoriesPath(), data, defaults.SharedReadMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	for _, dir := range dirs {
+		printer.PrintStep("Preserving %v", dir.Path)
+		if err := moveDirectory(dir.Path, dir.StashPath); err != nil {
+			return trace.Wrap(err, "failed to preserve %v", dir.Path)
+		}
+	}
+	return nil
+}
+
+// RestoreDirectories moves the directories preserved with PreserveDirectories
+// back to their original locations and removes the reinstall directory.
+// It is a no-op if there are no preserved directories.
+// Directories restored by a previous attempt are skipped
+func RestoreDirectories(logger log.FieldLogger) error {
+	data, err := ioutil.ReadFile(preservedDirectoriesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return trace.ConvertSystemError(err)
+	}
+	var dirs []preservedDirectory
+	if err := json.Unmarshal(data, &dirs); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, dir := range dirs {
+		logger := logger.WithField("path", dir.Path)
+		if _, err := os.Stat(dir.StashPath); os.IsNotExist(err) {
+			logger.Info("Directory has already been restored.")
+			continue
+		}
+		if err := removeEmptyDirectory(dir.Path); err != nil {
+			return trace.Wrap(err)
+		}
+		logger.Info("Restore preserved directory.")
+		if err := os.MkdirAll(filepath.Dir(dir.Path), defaults.SharedDirMask); err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		if err := moveDirectory(dir.StashPath, dir.Path); err != nil {
+			return trace.Wrap(err, "failed to restore %v", dir.Path)
+		}
+	}
+	return trace.ConvertSystemError(os.RemoveAll(defaults.GravityReinstallDir))
+}
+
+// removeEmptyDirectory removes the specified directory if it is empty
+// so that it can be replaced by the preserved one.
+// Returns an error if the directory exists and is not empty
+func removeEmptyDirectory(dir string) error {
+	empty, err := utils.IsDirectoryEmpty(dir)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	if !empty {
+		return trace.AlreadyExists("directory %v already exists and is not empty, "+
+			"will not overwrite it with the preserved data", dir)
+	}
+	return trace.ConvertSystemError(os.Remove(dir))
+}
+
+// moveDirectory moves the directory src to dst falling back
+// to copying if they are located on different filesystems
+func moveDirectory(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if linkErr, ok := err.(*os.LinkError); !ok || linkErr.Err != syscall.EXDEV {
+		return trace.ConvertSystemError(err)
+	}
+	var out bytes.Buffer
+	if err := utils.Exec(exec.Command("cp", "--archive", src, dst), &out); err != nil {
+		return trace.Wrap(err, "failed to copy %v to %v: %s", src, dst, out.String())
+	}
+	return trace.ConvertSystemError(os.RemoveAll(src))
+}
+
+// isUnderDirectory returns true if path is any of the specified
+// directories or is located under one
+func isUnderDirectory(path string, dirs ...string) bool {
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if path == dir || utils.HasOneOfPrefixes(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func preservedDirectoriesPath() string {
+	return filepath.Join(defaults.GravityReinstallDir, "directories.json")
+}
+
+// preservedDirectory describes an application data directory
+// preserved across the node reinstall
+type preservedDirectory struct {
+	// Path is the original location of the directory
+	Path string `json:"path"`
+	// StashPath is the location of the directory during the reinstall
+	StashPath string `json:"stash_path"`
+}
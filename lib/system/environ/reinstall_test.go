@@ -0,0 +1,67 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package environ
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type ReinstallSuite struct{}
+
+var _ = check.Suite(&ReinstallSuite{})
+
+func (s *ReinstallSuite) TestDetectsDirectoriesUnderStateDirectory(c *check.C) {
+	c.Assert(isUnderDirectory("/var/lib/gravity", "/var/lib/gravity"), check.Equals, true)
+	c.Assert(isUnderDirectory("/var/lib/gravity/local/data", "/var/lib/gravity/"), check.Equals, true)
+	c.Assert(isUnderDirectory("/var/lib/gravity-data", "/var/lib/gravity"), check.Equals, false)
+	c.Assert(isUnderDirectory("/var/lib", "/var/lib/gravity"), check.Equals, false)
+}
+
+func (s *ReinstallSuite) TestMovesDirectory(c *check.C) {
+	dir := c.MkDir()
+	src := filepath.Join(dir, "src")
+	c.Assert(os.MkdirAll(filepath.Join(src, "nested"), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(src, "nested", "file"), []byte("data"), 0644), check.IsNil)
+
+	dst := filepath.Join(dir, "dst")
+	c.Assert(moveDirectory(src, dst), check.IsNil)
+	data, err := ioutil.ReadFile(filepath.Join(dst, "nested", "file"))
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "data")
+	_, err = os.Stat(src)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *ReinstallSuite) TestDoesNotReplaceNonEmptyDirectory(c *check.C) {
+	dir := c.MkDir()
+	c.Assert(removeEmptyDirectory(filepath.Join(dir, "missing")), check.IsNil)
+
+	empty := filepath.Join(dir, "empty")
+	c.Assert(os.Mkdir(empty, 0755), check.IsNil)
+	c.Assert(removeEmptyDirectory(empty), check.IsNil)
+	_, err := os.Stat(empty)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644), check.IsNil)
+	err = removeEmptyDirectory(dir)
+	c.Assert(trace.IsAlreadyExists(err), check.Equals, true)
+}
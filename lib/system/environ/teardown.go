@@ -0,0 +1,480 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package environ
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/systemservice"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// UninstallNodeConfig defines the configuration of a node teardown
+type UninstallNodeConfig struct {
+	// Printer outputs the teardown progress
+	Printer utils.Printer
+	// Logger is used for logging
+	Logger log.FieldLogger
+	// PreserveData keeps the state directory with the cluster
+	// and application data in place
+	PreserveData bool
+	// KeepTeleport keeps the Teleport service running along with the
+	// files and mounts it uses, so the teardown can be driven remotely
+	// over Teleport and report its result. The remaining artifacts are
+	// removed by a subsequent teardown without this flag
+	KeepTeleport bool
+}
+
+// UninstallNode removes all Gravity services and artifacts from this node
+// on best-effort basis and verifies that nothing Gravity-related remains.
+// Returns the aggregate of teardown errors or the list of remaining artifacts
+func UninstallNode(config UninstallNodeConfig) error {
+	if config.KeepTeleport {
+		return trace.Wrap(uninstallNodeKeepingTeleport(config))
+	}
+	var errors []error
+	if err := UninstallServices(config.Printer, config.Logger); err != nil {
+		errors = append(errors, err)
+	}
+	if err := unmountDevicemapper(config.Printer, config.Logger); err != nil {
+		errors = append(errors, err)
+	}
+	if err := unmountStateDirectories(config.Printer, config.Logger); err != nil {
+		errors = append(errors, err)
+	}
+	if err := removeInterfaces(config.Printer); err != nil {
+		errors = append(errors, err)
+	}
+	if err := removeFirewallRules(config.Printer, config.Logger); err != nil {
+		errors = append(errors, err)
+	}
+	if err := removePaths(config.Printer, config.Logger, uninstallPaths(config.PreserveData)...); err != nil {
+		errors = append(errors, err)
+	}
+	if err := removeUnitFiles(config.Printer, config.Logger); err != nil {
+		errors = append(errors, err)
+	}
+	remaining, err := VerifyUninstall(config.PreserveData)
+	if err != nil {
+		errors = append(errors, err)
+	} else if len(remaining) != 0 {
+		errors = append(errors, trace.CompareFailed(
+			"the following Gravity artifacts remain on the node: %v", strings.Join(remaining, ", ")))
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// uninstallNodeKeepingTeleport stops and removes the Gravity services other
+// than Teleport and cleans up the host network configuration
+func uninstallNodeKeepingTeleport(config UninstallNodeConfig) error {
+	svm, err := systemservice.New()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var errors []error
+	err = uninstallPackageServices(svm, config.Printer, config.Logger, constants.TeleportPackage)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	err = uninstallServices(svm, defaults.GravityRPCInstallerServiceName, defaults.GravityRPCAgentServiceName)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	if err := unmountDevicemapper(config.Printer, config.Logger); err != nil {
+		errors = append(errors, err)
+	}
+	if err := removeInterfaces(config.Printer); err != nil {
+		errors = append(errors, err)
+	}
+	if err := removeFirewallRules(config.Printer, config.Logger); err != nil {
+		errors = append(errors, err)
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// VerifyUninstall returns the list of Gravity artifacts that remain on this node:
+// files and directories, mounts, systemd units, network interfaces and firewall chains.
+// If preserveData is true, the state directory is expected to remain
+func VerifyUninstall(preserveData bool) (remaining []string, err error) {
+	for _, path := range uninstallPaths(preserveData) {
+		if _, err := os.Stat(path); err == nil {
+			remaining = append(remaining, path)
+		}
+	}
+	mounts, err := stateDirectoryMounts()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, mount := range mounts {
+		remaining = append(remaining, fmt.Sprintf("mount %v", mount))
+	}
+	units, err := unitFiles()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, unit := range units {
+		remaining = append(remaining, fmt.Sprintf("systemd unit %v", unit))
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, iface := range ifaces {
+		if isGravityInterface(iface.Name) {
+			remaining = append(remaining, fmt.Sprintf("network interface %v", iface.Name))
+		}
+	}
+	for _, table := range firewallTables {
+		rules, err := listFirewallRules(table)
+		if err != nil {
+			log.WithError(err).WithField("table", table).Warn("Failed to list firewall rules.")
+			continue
+		}
+		for _, chain := range parseFirewallRules(rules).chains {
+			remaining = append(remaining, fmt.Sprintf("firewall chain %v/%v", table, chain))
+		}
+	}
+	return remaining, nil
+}
+
+// uninstallPaths returns the list of paths to remove during the node teardown
+func uninstallPaths(preserveData bool) (paths []string) {
+	if !preserveData {
+		return getPathsToRemove()
+	}
+	preserved := append([]string{}, state.StateLocatorPaths...)
+	if stateDir, err := state.GetStateDir(); err == nil {
+		preserved = append(preserved, stateDir)
+	}
+	for _, path := range getPathsToRemove() {
+		if !utils.StringInSlice(preserved, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func unmountStateDirectories(printer utils.Printer, logger log.FieldLogger) error {
+	mounts, err := stateDirectoryMounts()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var errors []error
+	for _, mount := range mounts {
+		printer.PrintStep("Unmounting %v", mount)
+		var out bytes.Buffer
+		if err := utils.Exec(exec.Command("umount", "--lazy", mount), &out); err != nil {
+			logger.WithFields(log.Fields{
+				log.ErrorKey: err,
+				"mount":      mount,
+				"output":     out.String(),
+			}).Warn("Failed to unmount.")
+			errors = append(errors, err)
+		}
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// stateDirectoryMounts returns the mount points under the Gravity state directories
+func stateDirectoryMounts() ([]string, error) {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	return parseMounts(f, getStateDirectories())
+}
+
+// parseMounts returns the mount points from the specified list of mounts
+// in /proc/mounts format that are located under any of the specified directories.
+// The mount points are sorted so that nested mounts come first
+func parseMounts(r io.Reader, dirs []string) (mounts []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		// Mount points with whitespace have it escaped as octal sequences
+		mount := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\134`, `\`).Replace(fields[1])
+		for _, dir := range dirs {
+			if mount == dir || strings.HasPrefix(mount, filepath.Clean(dir)+"/") {
+				mounts = append(mounts, mount)
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(mounts)))
+	return mounts, nil
+}
+
+func removeUnitFiles(printer utils.Printer, logger log.FieldLogger) error {
+	units, err := unitFiles()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if len(units) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(units))
+	for _, unit := range units {
+		paths = append(paths, defaults.InSystemUnitDir(unit))
+	}
+	if err := removePaths(printer, logger, paths...); err != nil {
+		return trace.Wrap(err)
+	}
+	var out bytes.Buffer
+	if err := utils.Exec(exec.Command("systemctl", "daemon-reload"), &out); err != nil {
+		return trace.Wrap(err, "failed to reload systemd units: %s", out.String())
+	}
+	return nil
+}
+
+// unitFiles returns the names of the Gravity systemd units
+func unitFiles() (units []string, err error) {
+	files, err := ioutil.ReadDir(defaults.SystemUnitDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, trace.ConvertSystemError(err)
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), unitPrefix) {
+			units = append(units, file.Name())
+		}
+	}
+	return units, nil
+}
+
+func removeFirewallRules(printer utils.Printer, logger log.FieldLogger) error {
+	var errors []error
+	for _, table := range firewallTables {
+		rules, err := listFirewallRules(table)
+		if err != nil {
+			logger.WithError(err).WithField("table", table).Warn("Failed to list firewall rules.")
+			continue
+		}
+		commands := parseFirewallRules(rules).commands()
+		if len(commands) == 0 {
+			continue
+		}
+		printer.PrintStep("Removing firewall rules from %v table", table)
+		for _, args := range commands {
+			var out bytes.Buffer
+			args = append([]string{"--table", table}, args...)
+			if err := utils.Exec(exec.Command("iptables", args...), &out); err != nil {
+				logger.WithFields(log.Fields{
+					log.ErrorKey: err,
+					"args":       args,
+					"output":     out.String(),
+				}).Warn("Failed to remove firewall rule.")
+				errors = append(errors, err)
+			}
+		}
+	}
+	return trace.NewAggregate(errors...)
+}
+
+func listFirewallRules(table string) (string, error) {
+	var out bytes.Buffer
+	if err := utils.Exec(exec.Command("iptables", "--table", table, "--list-rules"), &out); err != nil {
+		return "", trace.Wrap(err, "failed to list firewall rules: %s", out.String())
+	}
+	return out.String(), nil
+}
+
+// parseFirewallRules collects the Gravity-related chains and rules
+// from the specified output of iptables --list-rules
+func parseFirewallRules(rules string) (result firewallRules) {
+	for _, line := range strings.Split(rules, "\n") {
+		args := splitFirewallRule(line)
+		if len(args) < 2 {
+			continue
+		}
+		switch args[0] {
+		case "-N":
+			if isGravityChain(args[1]) {
+				result.chains = append(result.chains, args[1])
+			}
+		case "-A":
+			if !isGravityChain(args[1]) && isGravityRule(args[2:]) {
+				result.rules = append(result.rules, args[1:])
+			}
+		}
+	}
+	return result
+}
+
+// splitFirewallRule splits the specified rule into arguments
+// honoring the double-quoted arguments
+func splitFirewallRule(rule string) (args []string) {
+	var arg strings.Builder
+	var quoted, hasArg bool
+	for _, r := range rule {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			hasArg = true
+		case r == ' ' && !quoted:
+			if hasArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				hasArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// isGravityRule returns true if the specified rule in a non-Gravity chain
+// jumps to a Gravity chain or matches a Gravity network interface
+func isGravityRule(args []string) bool {
+	for i := 0; i < len(args)-1; i++ {
+		switch args[i] {
+		case "-j", "-g":
+			if isGravityChain(args[i+1]) {
+				return true
+			}
+		case "-i", "-o":
+			if isGravityInterface(args[i+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isGravityChain returns true if the specified chain is created by the
+// Kubernetes services, CNI plugins or the overlay network of the cluster.
+// Chains of the host Docker daemon and the user-managed chains are left
+// in place as the Docker daemon of the cluster does not manage iptables
+func isGravityChain(chain string) bool {
+	if utils.StringInSlice(gravityChains, chain) {
+		return true
+	}
+	if utils.HasOneOfPrefixes(chain, gravityChainPrefixes...) {
+		return true
+	}
+	// Chains of the CNI bridge plugin are named after the hash of the container ID
+	return strings.HasPrefix(chain, "CNI-") && isHex(strings.TrimPrefix(chain, "CNI-"))
+}
+
+// isGravityInterface returns true if the specified network interface
+// belongs to the overlay network of the cluster
+func isGravityInterface(name string) bool {
+	return utils.HasOneOfPrefixes(name, "flannel", "cni", "wormhole")
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// commands returns the iptables arguments to remove these rules and chains
+func (r firewallRules) commands() (commands [][]string) {
+	for _, rule := range r.rules {
+		commands = append(commands, append([]string{"--delete"}, rule...))
+	}
+	for _, chain := range r.chains {
+		commands = append(commands, []string{"--flush", chain})
+	}
+	for _, chain := range r.chains {
+		commands = append(commands, []string{"--delete-chain", chain})
+	}
+	return commands
+}
+
+// firewallRules describes the Gravity-related firewall configuration of a table
+type firewallRules struct {
+	// chains lists the Gravity chains
+	chains []string
+	// rules lists the rules in other chains that refer to Gravity chains
+	// or network interfaces, each starting with the chain name
+	rules [][]string
+}
+
+// gravityChains lists the chains with fixed names created by kube-proxy,
+// kubelet and the CNI port mapping plugin
+var gravityChains = []string{
+	"KUBE-SERVICES",
+	"KUBE-EXTERNAL-SERVICES",
+	"KUBE-NODEPORTS",
+	"KUBE-POSTROUTING",
+	"KUBE-FORWARD",
+	"KUBE-FIREWALL",
+	"KUBE-MARK-MASQ",
+	"KUBE-MARK-DROP",
+	"KUBE-LOAD-BALANCER",
+	"KUBE-KUBELET-CANARY",
+	"KUBE-PROXY-CANARY",
+	"CNI-HOSTPORT-DNAT",
+	"CNI-HOSTPORT-SETMARK",
+	"CNI-HOSTPORT-MASQ",
+}
+
+// gravityChainPrefixes lists the prefixes of the chains created per service,
+// endpoint or container by kube-proxy and the CNI plugins, and of the chains
+// of the overlay network
+var gravityChainPrefixes = []string{
+	"KUBE-SVC-",
+	"KUBE-SEP-",
+	"KUBE-FW-",
+	"KUBE-XLB-",
+	"CNI-DN-",
+	"CNI-SN-",
+	"FLANNEL-",
+	"WORMHOLE-",
+}
+
+// firewallTables lists the iptables tables Gravity creates rules in
+var firewallTables = []string{"filter", "nat", "mangle"}
+
+// unitPrefix is the prefix of the Gravity systemd unit names
+const unitPrefix = "gravity"
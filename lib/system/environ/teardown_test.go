@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package environ
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+func TestEnviron(t *testing.T) { check.TestingT(t) }
+
+type TeardownSuite struct{}
+
+var _ = check.Suite(&TeardownSuite{})
+
+func (s *TeardownSuite) TestParsesMounts(c *check.C) {
+	mounts, err := parseMounts(strings.NewReader(`/dev/sda1 / ext4 rw,relatime 0 0
+tmpfs /var/lib/gravity/planet/kubelet/pods/1/volumes/secret tmpfs rw 0 0
+/dev/sdb1 /var/lib/gravity ext4 rw 0 0
+tmpfs /var/lib/gravity\040data tmpfs rw 0 0
+tmpfs /var/lib/gravity-other tmpfs rw 0 0
+/dev/sdb1 /var/lib/gravity/planet/docker ext4 rw 0 0
+`), []string{"/var/lib/gravity"})
+	c.Assert(err, check.IsNil)
+	c.Assert(mounts, check.DeepEquals, []string{
+		"/var/lib/gravity/planet/kubelet/pods/1/volumes/secret",
+		"/var/lib/gravity/planet/docker",
+		"/var/lib/gravity",
+	})
+}
+
+func (s *TeardownSuite) TestParsesFirewallRules(c *check.C) {
+	rules := parseFirewallRules(`-P INPUT ACCEPT
+-P FORWARD DROP
+-N KUBE-FORWARD
+-N KUBE-SERVICES
+-N KUBE-SVC-ERIFXISQEP7F7OF4
+-N CNI-0c6a4a6b0d4e7f0c3c3b3f6a
+-N USER-CHAIN
+-N KUBE-USER-CHAIN
+-N CNI-USER-CHAIN
+-N DOCKER
+-N DOCKER-USER
+-A INPUT -m comment --comment "kubernetes service portals" -j KUBE-SERVICES
+-A FORWARD -m comment --comment "kubernetes forwarding rules" -j KUBE-FORWARD
+-A FORWARD -o docker0 -j DOCKER
+-A FORWARD -j DOCKER-USER
+-A FORWARD -o flannel.1 -j ACCEPT
+-A FORWARD -i eth0 -j USER-CHAIN
+-A FORWARD -i eth0 -j KUBE-USER-CHAIN
+-A KUBE-FORWARD -j ACCEPT
+`)
+	c.Assert(rules.commands(), check.DeepEquals, [][]string{
+		{"--delete", "INPUT", "-m", "comment", "--comment", "kubernetes service portals", "-j", "KUBE-SERVICES"},
+		{"--delete", "FORWARD", "-m", "comment", "--comment", "kubernetes forwarding rules", "-j", "KUBE-FORWARD"},
+		{"--delete", "FORWARD", "-o", "flannel.1", "-j", "ACCEPT"},
+		{"--flush", "KUBE-FORWARD"},
+		{"--flush", "KUBE-SERVICES"},
+		{"--flush", "KUBE-SVC-ERIFXISQEP7F7OF4"},
+		{"--flush", "CNI-0c6a4a6b0d4e7f0c3c3b3f6a"},
+		{"--delete-chain", "KUBE-FORWARD"},
+		{"--delete-chain", "KUBE-SERVICES"},
+		{"--delete-chain", "KUBE-SVC-ERIFXISQEP7F7OF4"},
+		{"--delete-chain", "CNI-0c6a4a6b0d4e7f0c3c3b3f6a"},
+	})
+}
@@ -131,7 +131,9 @@ func DisableAgentServices(logger log.FieldLogger) error {
 	return trace.NewAggregate(errors...)
 }
 
-func uninstallPackageServices(svm systemservice.ServiceManager, printer utils.Printer, logger log.FieldLogger) error {
+// uninstallPackageServices uninstalls the package services
+// except for the services of the packages with the specified names
+func uninstallPackageServices(svm systemservice.ServiceManager, printer utils.Printer, logger log.FieldLogger, skip ...string) error {
 	services, err := svm.ListPackageServices()
 	if err != nil {
 		return trace.Wrap(err)
@@ -146,6 +148,9 @@ func uninstallPackageServices(svm systemservice.ServiceManager, printer utils.Pr
 		return services[i].Package.Name == constants.TeleportPackage
 	})
 	for _, service := range services {
+		if utils.StringInSlice(skip, service.Package.Name) {
+			continue
+		}
 		printer.PrintStep("Uninstalling system service %v", service)
 		log := logger.WithField("package", service.Package)
 		err := svm.UninstallPackageService(service.Package)
@@ -0,0 +1,202 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package selinux implements SELinux configuration of a gravity node:
+// it loads the gravity policy module and labels the state directory and
+// device files used by the cluster
+package selinux
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// Config describes the SELinux configuration of a node
+type Config struct {
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+	// StateDir specifies the gravity state directory to label
+	StateDir string
+	// Paths lists the existing paths under the state directory to relabel.
+	// Files created later inherit the label of the state directory,
+	// so only the paths used by the cluster services are relabeled
+	// instead of the whole state directory
+	Paths []string
+	// Devices lists device files to label
+	Devices []string
+	// Runner executes commands.
+	// Defaults to utils.Runner
+	Runner utils.CommandRunner
+}
+
+// Bootstrap loads the gravity policy module and labels the state directory
+// and devices specified in config
+func Bootstrap(ctx context.Context, config Config) error {
+	if err := config.checkAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	config.Infof("Loading SELinux policy module %v.", PolicyModule)
+	if err := config.loadPolicy(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, label := range config.labels() {
+		config.Infof("Labeling %v as %v.", label.path, label.fileType)
+		err := config.run(ctx, "semanage", "fcontext", "--add",
+			"--type", label.fileType, label.spec())
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return trace.Wrap(config.relabel(ctx))
+}
+
+// Unload removes the labels and unloads the gravity policy module.
+// It reverts the changes made by Bootstrap
+func Unload(ctx context.Context, config Config) error {
+	if err := config.checkAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	for _, label := range config.labels() {
+		config.Infof("Removing SELinux label from %v.", label.path)
+		err := config.run(ctx, "semanage", "fcontext", "--delete", label.spec())
+		if err != nil {
+			config.WithError(err).Warnf("Failed to remove label from %v.", label.path)
+		}
+	}
+	if err := config.relabel(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	config.Infof("Unloading SELinux policy module %v.", PolicyModule)
+	return trace.Wrap(config.run(ctx, "semodule", "--remove", PolicyModule))
+}
+
+func (r *Config) checkAndSetDefaults() error {
+	if r.StateDir == "" {
+		return trace.BadParameter("state directory is required")
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = logrus.WithField(trace.Component, "selinux")
+	}
+	if r.Runner == nil {
+		r.Runner = utils.Runner
+	}
+	return nil
+}
+
+func (r *Config) loadPolicy(ctx context.Context) error {
+	dir, err := ioutil.TempDir("", "selinux")
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer os.RemoveAll(dir)
+	// semodule derives the module name from the file name
+	path := filepath.Join(dir, fmt.Sprintf("%v.cil", PolicyModule))
+	err = ioutil.WriteFile(path, []byte(policy), defaults.SharedReadMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return trace.Wrap(r.run(ctx, "semodule", "--install", path))
+}
+
+// relabel restores the file contexts of the state directory itself,
+// the configured paths under it and the devices
+func (r *Config) relabel(ctx context.Context) error {
+	// -i ignores the paths that do not exist
+	err := r.run(ctx, "restorecon", "-i", r.StateDir)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, path := range r.Paths {
+		err := r.run(ctx, "restorecon", "-R", "-i", filepath.Join(r.StateDir, path))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	for _, device := range r.Devices {
+		err := r.run(ctx, "restorecon", "-i", device)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+func (r *Config) labels() (labels []label) {
+	labels = append(labels, label{path: r.StateDir, fileType: StateDirType, recursive: true})
+	for _, device := range r.Devices {
+		labels = append(labels, label{path: device, fileType: DeviceType})
+	}
+	return labels
+}
+
+func (r *Config) run(ctx context.Context, args ...string) error {
+	var out bytes.Buffer
+	if err := r.Runner.RunStream(ctx, &out, args...); err != nil {
+		return trace.Wrap(err, "failed to execute %v: %s", args, out.String())
+	}
+	return nil
+}
+
+// label describes a file context assignment
+type label struct {
+	path      string
+	fileType  string
+	recursive bool
+}
+
+// spec returns the file context specification for this label
+func (r label) spec() string {
+	if r.recursive {
+		return fmt.Sprintf("%v(/.*)?", r.path)
+	}
+	return r.path
+}
+
+const (
+	// PolicyModule is the name of the gravity policy module
+	PolicyModule = "gravity"
+	// StateDirType is the SELinux type of the gravity state directory
+	StateDirType = "gravity_var_lib_t"
+	// DeviceType is the SELinux type of device files used by the cluster
+	DeviceType = "gravity_device_t"
+)
+
+// policy defines the gravity policy module in common intermediate language.
+// It declares the types for the state directory and device files
+// and grants access to them to the gravity services
+const policy = `
+(type gravity_var_lib_t)
+(roletype object_r gravity_var_lib_t)
+(typeattributeset file_type (gravity_var_lib_t))
+
+(type gravity_device_t)
+(roletype object_r gravity_device_t)
+(typeattributeset device_node (gravity_device_t))
+
+(allow unconfined_service_t gravity_var_lib_t (dir (create getattr setattr open read write search add_name remove_name rmdir)))
+(allow unconfined_service_t gravity_var_lib_t (file (create getattr setattr open read write append unlink rename lock)))
+(allow unconfined_service_t gravity_device_t (blk_file (getattr open read write ioctl lock)))
+`
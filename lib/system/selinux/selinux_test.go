@@ -0,0 +1,76 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package selinux
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func TestSELinux(t *testing.T) { TestingT(t) }
+
+type S struct{}
+
+var _ = Suite(&S{})
+
+func (_ *S) TestBootstrapsAndUnloads(c *C) {
+	var runner testRunner
+	config := Config{
+		StateDir: "/var/lib/gravity",
+		Paths:    []string{"planet", "secrets"},
+		Devices:  []string{"/dev/sdb"},
+		Runner:   &runner,
+	}
+
+	err := Bootstrap(context.TODO(), config)
+	c.Assert(err, IsNil)
+	c.Assert(runner.commands[0], Matches, "semodule --install .*/gravity.cil")
+	c.Assert(runner.commands[1:], DeepEquals, []string{
+		`semanage fcontext --add --type gravity_var_lib_t /var/lib/gravity(/.*)?`,
+		"semanage fcontext --add --type gravity_device_t /dev/sdb",
+		"restorecon -i /var/lib/gravity",
+		"restorecon -R -i /var/lib/gravity/planet",
+		"restorecon -R -i /var/lib/gravity/secrets",
+		"restorecon -i /dev/sdb",
+	})
+
+	runner.commands = nil
+	err = Unload(context.TODO(), config)
+	c.Assert(err, IsNil)
+	c.Assert(runner.commands, DeepEquals, []string{
+		`semanage fcontext --delete /var/lib/gravity(/.*)?`,
+		"semanage fcontext --delete /dev/sdb",
+		"restorecon -i /var/lib/gravity",
+		"restorecon -R -i /var/lib/gravity/planet",
+		"restorecon -R -i /var/lib/gravity/secrets",
+		"restorecon -i /dev/sdb",
+		"semodule --remove gravity",
+	})
+}
+
+type testRunner struct {
+	commands []string
+}
+
+func (r *testRunner) RunStream(ctx context.Context, w io.Writer, args ...string) error {
+	r.commands = append(r.commands, strings.Join(args, " "))
+	return nil
+}
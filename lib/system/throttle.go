@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// ParseThrottleLevel parses the throttle level from the specified string.
+// Empty string is parsed as ThrottleNone
+func ParseThrottleLevel(level string) (ThrottleLevel, error) {
+	switch ThrottleLevel(level) {
+	case "", ThrottleNone:
+		return ThrottleNone, nil
+	case ThrottleLow, ThrottleMedium:
+		return ThrottleLevel(level), nil
+	}
+	return "", trace.BadParameter("unknown throttle level %q, supported levels are: %v",
+		level, strings.Join(ThrottleLevels, ", "))
+}
+
+// Limits returns the resource limits for this throttle level
+// or nil if the level does not throttle resource usage
+func (r ThrottleLevel) Limits() *ThrottleLimits {
+	switch r {
+	case ThrottleLow:
+		return &ThrottleLimits{
+			Nice:        10,
+			IOPriority:  4,
+			CPUShares:   512,
+			BlkioWeight: 250,
+		}
+	case ThrottleMedium:
+		return &ThrottleLimits{
+			Nice:        19,
+			IOPriority:  7,
+			CPUShares:   128,
+			BlkioWeight: 100,
+		}
+	}
+	return nil
+}
+
+// ThrottleLevel defines how much the resource usage of a process is throttled
+type ThrottleLevel string
+
+// ThrottleLimits describes the resource limits of a throttled process
+type ThrottleLimits struct {
+	// Nice is the scheduling priority of the process, from -20 to 19
+	Nice int
+	// IOPriority is the I/O priority of the process within the best-effort
+	// I/O scheduling class, from 0 (highest) to 7 (lowest)
+	IOPriority int
+	// CPUShares is the relative share of CPU time of the process cgroup.
+	// The default share is 1024
+	CPUShares int
+	// BlkioWeight is the relative block I/O weight of the process cgroup,
+	// from 10 to 1000. The default weight is 500
+	BlkioWeight int
+}
+
+const (
+	// ThrottleNone does not throttle resource usage
+	ThrottleNone ThrottleLevel = "none"
+	// ThrottleLow lowers the CPU and I/O priority of the process
+	ThrottleLow ThrottleLevel = "low"
+	// ThrottleMedium lowers the CPU and I/O priority of the process
+	// further so it yields to the cluster workloads
+	ThrottleMedium ThrottleLevel = "medium"
+)
+
+// ThrottleLevels lists the supported throttle levels
+var ThrottleLevels = []string{string(ThrottleNone), string(ThrottleLow), string(ThrottleMedium)}
@@ -0,0 +1,124 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Throttle throttles the resource usage of the current process
+// and the processes it starts according to the specified level.
+//
+// The scheduling and I/O priorities of the process are lowered and
+// the process is moved into a cgroup with the reduced CPU and block I/O
+// weights if the cgroup controllers are available
+func Throttle(level ThrottleLevel) error {
+	limits := level.Limits()
+	if limits == nil {
+		return nil
+	}
+	logger := log.WithField("level", level)
+	// Scheduling and I/O priorities are per-thread on Linux so they are set
+	// for each thread of the process. New threads inherit the priorities
+	// of the thread that creates them
+	tids, err := threadIDs()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	ioprio := ioprioClassBestEffort<<ioprioClassShift | limits.IOPriority
+	for _, tid := range tids {
+		err := unix.Setpriority(unix.PRIO_PROCESS, tid, limits.Nice)
+		if err != nil && err != unix.ESRCH {
+			return trace.Wrap(trace.ConvertSystemError(err), "failed to set scheduling priority")
+		}
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio))
+		if errno != 0 && errno != unix.ESRCH {
+			return trace.Wrap(trace.ConvertSystemError(errno), "failed to set I/O priority")
+		}
+	}
+	for _, group := range []struct {
+		controller string
+		file       string
+		value      int
+	}{
+		{controller: "cpu", file: "cpu.shares", value: limits.CPUShares},
+		{controller: "blkio", file: "blkio.weight", value: limits.BlkioWeight},
+	} {
+		err := joinCgroup(group.controller, string(level), group.file, group.value)
+		if err != nil {
+			// The controller might not be available on the host
+			// so only the priorities are lowered
+			logger.WithError(err).Warnf("Failed to apply %v cgroup limits.", group.controller)
+		}
+	}
+	logger.Info("Throttled resource usage.")
+	return nil
+}
+
+// joinCgroup moves the current process into the throttle cgroup
+// with the specified controller setting
+func joinCgroup(controller, level, file string, value int) error {
+	root := filepath.Join(cgroupRoot, controller)
+	if _, err := os.Stat(root); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	dir := filepath.Join(root, throttleCgroupPrefix+level)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	err := ioutil.WriteFile(filepath.Join(dir, file), []byte(strconv.Itoa(value)), 0644)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644)
+	return trace.ConvertSystemError(err)
+}
+
+// threadIDs returns the IDs of the threads of the current process
+func threadIDs() (tids []int, err error) {
+	entries, err := ioutil.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		tids = append(tids, tid)
+	}
+	return tids, nil
+}
+
+const (
+	// cgroupRoot is the mount point of the cgroup controllers
+	cgroupRoot = "/sys/fs/cgroup"
+	// throttleCgroupPrefix is the name prefix of the cgroups for throttled processes
+	throttleCgroupPrefix = "gravity-throttle-"
+
+	// See https://www.kernel.org/doc/Documentation/block/ioprio.txt
+	ioprioWhoProcess      = 1
+	ioprioClassBestEffort = 2
+	ioprioClassShift      = 13
+)
@@ -0,0 +1,60 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"github.com/gravitational/trace"
+	. "gopkg.in/check.v1"
+)
+
+type ThrottleSuite struct{}
+
+var _ = Suite(&ThrottleSuite{})
+
+func (*ThrottleSuite) TestParsesThrottleLevel(c *C) {
+	var testCases = []struct {
+		level    string
+		expected ThrottleLevel
+		limited  bool
+		comment  string
+	}{
+		{level: "", expected: ThrottleNone, comment: "defaults to no throttling"},
+		{level: "none", expected: ThrottleNone, comment: "no throttling"},
+		{level: "low", expected: ThrottleLow, limited: true, comment: "low throttling"},
+		{level: "medium", expected: ThrottleMedium, limited: true, comment: "medium throttling"},
+	}
+	for _, tc := range testCases {
+		comment := Commentf(tc.comment)
+		level, err := ParseThrottleLevel(tc.level)
+		c.Assert(err, IsNil, comment)
+		c.Assert(level, Equals, tc.expected, comment)
+		c.Assert(level.Limits() != nil, Equals, tc.limited, comment)
+	}
+}
+
+func (*ThrottleSuite) TestRejectsUnknownThrottleLevel(c *C) {
+	_, err := ParseThrottleLevel("high")
+	c.Assert(trace.IsBadParameter(err), Equals, true)
+}
+
+func (*ThrottleSuite) TestMediumThrottlesMoreThanLow(c *C) {
+	low, medium := ThrottleLow.Limits(), ThrottleMedium.Limits()
+	c.Assert(medium.Nice > low.Nice, Equals, true)
+	c.Assert(medium.IOPriority > low.IOPriority, Equals, true)
+	c.Assert(medium.CPUShares < low.CPUShares, Equals, true)
+	c.Assert(medium.BlkioWeight < low.BlkioWeight, Equals, true)
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systeminfo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// BlockDevice describes a block device (disk or partition) on this host
+type BlockDevice struct {
+	// Path is the device path, e.g. /dev/sda
+	Path string `json:"path"`
+	// Type is the device type as reported by lsblk: disk, part, lvm, etc.
+	Type string `json:"type"`
+	// Parent is the path of the parent device for partitions
+	Parent string `json:"parent,omitempty"`
+	// SizeBytes is the device size in bytes
+	SizeBytes uint64 `json:"size_bytes"`
+	// Vendor is the device vendor
+	Vendor string `json:"vendor,omitempty"`
+	// Model is the device model
+	Model string `json:"model,omitempty"`
+	// Filesystem is the type of the filesystem on the device
+	Filesystem string `json:"filesystem,omitempty"`
+	// MountPoints lists mount points of the device and its partitions
+	MountPoints []string `json:"mount_points,omitempty"`
+	// Included is whether the device passes the device filter
+	Included bool `json:"included"`
+	// Reason explains why the device has been excluded by the device filter
+	Reason string `json:"reason,omitempty"`
+}
+
+// DeviceFilter selects block devices eligible for persistent storage.
+//
+// The filter mirrors the semantics of the node disk manager filters:
+// devices mounted as the OS disk are always excluded, vendors are compared
+// verbatim and paths match if the device path contains the specified value.
+// Empty include lists match any device
+type DeviceFilter struct {
+	// IncludeVendors lists vendors of devices to include
+	IncludeVendors []string `json:"include_vendors,omitempty"`
+	// ExcludeVendors lists vendors of devices to exclude
+	ExcludeVendors []string `json:"exclude_vendors,omitempty"`
+	// IncludePaths lists path patterns of devices to include
+	IncludePaths []string `json:"include_paths,omitempty"`
+	// ExcludePaths lists path patterns of devices to exclude
+	ExcludePaths []string `json:"exclude_paths,omitempty"`
+}
+
+// DefaultDeviceFilter returns the device filter with the default exclusions
+// of the node disk manager
+func DefaultDeviceFilter() DeviceFilter {
+	return DeviceFilter{
+		ExcludeVendors: []string{"CLOUDBYT", "OpenEBS"},
+		ExcludePaths: []string{"loop", "/dev/fd0", "/dev/sr0", "/dev/ram",
+			"/dev/dm-", "/dev/md", "/dev/rbd", "/dev/zd"},
+	}
+}
+
+// IsEmpty returns true if this filter does not include or exclude any devices
+func (r DeviceFilter) IsEmpty() bool {
+	return len(r.IncludeVendors) == 0 && len(r.ExcludeVendors) == 0 &&
+		len(r.IncludePaths) == 0 && len(r.ExcludePaths) == 0
+}
+
+// WithDefaults returns this filter extended with the default exclusions
+// of the node disk manager
+func (r DeviceFilter) WithDefaults() DeviceFilter {
+	filter := DefaultDeviceFilter()
+	filter.IncludeVendors = append(filter.IncludeVendors, r.IncludeVendors...)
+	filter.ExcludeVendors = append(filter.ExcludeVendors, r.ExcludeVendors...)
+	filter.IncludePaths = append(filter.IncludePaths, r.IncludePaths...)
+	filter.ExcludePaths = append(filter.ExcludePaths, r.ExcludePaths...)
+	return filter
+}
+
+// Check returns whether the specified device passes the filter.
+// If the device is excluded, the returned string contains the reason
+func (r DeviceFilter) Check(device BlockDevice) (included bool, reason string) {
+	for _, mountPoint := range device.MountPoints {
+		if utils.StringInSlice(osMountPoints, mountPoint) {
+			return false, fmt.Sprintf("OS disk, mounted on %v", mountPoint)
+		}
+	}
+	if len(r.IncludeVendors) != 0 && !utils.StringInSlice(r.IncludeVendors, device.Vendor) {
+		return false, fmt.Sprintf("vendor %q is not included", device.Vendor)
+	}
+	if utils.StringInSlice(r.ExcludeVendors, device.Vendor) {
+		return false, fmt.Sprintf("vendor %q is excluded", device.Vendor)
+	}
+	if len(r.IncludePaths) != 0 && matchPath(r.IncludePaths, device.Path) == "" {
+		return false, "path is not included"
+	}
+	if pattern := matchPath(r.ExcludePaths, device.Path); pattern != "" {
+		return false, fmt.Sprintf("path matches excluded %q", pattern)
+	}
+	return true, ""
+}
+
+// GetBlockDevices returns the list of block devices on this host
+// annotated with the result of the specified filter
+func GetBlockDevices(filter DeviceFilter) ([]BlockDevice, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("lsblk", "--pairs", "--bytes",
+		"--output=NAME,TYPE,SIZE,FSTYPE,VENDOR,MODEL,MOUNTPOINT,PKNAME")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to list block devices: %s", stderr.String())
+	}
+	devices, err := parseBlockDevices(bytes.NewReader(out))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for i := range devices {
+		devices[i].Included, devices[i].Reason = filter.Check(devices[i])
+	}
+	return devices, nil
+}
+
+// parseBlockDevices interprets the specified reader as an output from:
+//
+// $ lsblk --pairs --bytes --output=NAME,TYPE,SIZE,FSTYPE,VENDOR,MODEL,MOUNTPOINT,PKNAME
+// NAME="sda" TYPE="disk" SIZE="68719476736" FSTYPE="" VENDOR="ATA     " MODEL="VBOX HARDDISK   " MOUNTPOINT="" PKNAME=""
+// NAME="sda1" TYPE="part" SIZE="68718428160" FSTYPE="xfs" VENDOR="" MODEL="" MOUNTPOINT="/" PKNAME="sda"
+//
+// Mount points of partitions are also attributed to the parent disk so
+// that the OS disk can be recognized
+func parseBlockDevices(r io.Reader) (devices []BlockDevice, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		columns := make(map[string]string)
+		for _, match := range lsblkPairRegexp.FindAllStringSubmatch(line, -1) {
+			columns[match[1]] = strings.TrimSpace(match[2])
+		}
+		if columns["NAME"] == "" {
+			return nil, trace.BadParameter("unexpected lsblk output: %q", line)
+		}
+		device := BlockDevice{
+			Path:       devicePath(columns["NAME"]),
+			Type:       columns["TYPE"],
+			Vendor:     columns["VENDOR"],
+			Model:      columns["MODEL"],
+			Filesystem: columns["FSTYPE"],
+		}
+		if columns["SIZE"] != "" {
+			device.SizeBytes, err = strconv.ParseUint(columns["SIZE"], 10, 64)
+			if err != nil {
+				return nil, trace.Wrap(err, "invalid size for %v", device.Path)
+			}
+		}
+		if columns["PKNAME"] != "" {
+			device.Parent = devicePath(columns["PKNAME"])
+		}
+		if mountPoint := columns["MOUNTPOINT"]; mountPoint != "" {
+			device.MountPoints = append(device.MountPoints, mountPoint)
+			addParentMountPoint(devices, device.Parent, mountPoint)
+		}
+		devices = append(devices, device)
+	}
+	if err := s.Err(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return devices, nil
+}
+
+// addParentMountPoint records the mount point on the parent device and,
+// transitively, on its ancestors
+func addParentMountPoint(devices []BlockDevice, parent, mountPoint string) {
+	for parent != "" {
+		found := false
+		for i := range devices {
+			if devices[i].Path == parent {
+				devices[i].MountPoints = append(devices[i].MountPoints, mountPoint)
+				parent = devices[i].Parent
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+func matchPath(patterns []string, path string) string {
+	for _, pattern := range patterns {
+		if strings.Contains(path, pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+func devicePath(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return filepath.Join("/dev", name)
+}
+
+// osMountPoints lists mount points that identify the OS disk
+var osMountPoints = []string{"/", "/boot"}
+
+// lsblkPairRegexp matches a single KEY="value" pair in lsblk output
+var lsblkPairRegexp = regexp.MustCompile(`([A-Z:-]+)="([^"]*)"`)
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systeminfo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsesBlockDevices(t *testing.T) {
+	const output = `NAME="sda" TYPE="disk" SIZE="68719476736" FSTYPE="" VENDOR="ATA     " MODEL="VBOX HARDDISK   " MOUNTPOINT="" PKNAME=""
+NAME="sda1" TYPE="part" SIZE="68718428160" FSTYPE="xfs" VENDOR="" MODEL="" MOUNTPOINT="/" PKNAME="sda"
+NAME="sdb" TYPE="disk" SIZE="10737418240" FSTYPE="" VENDOR="ATA     " MODEL="VBOX HARDDISK   " MOUNTPOINT="" PKNAME=""
+NAME="loop0" TYPE="loop" SIZE="1048576" FSTYPE="squashfs" VENDOR="" MODEL="" MOUNTPOINT="/snap/core/1" PKNAME=""
+`
+	devices, err := parseBlockDevices(strings.NewReader(output))
+	assert.NoError(t, err)
+	assert.Equal(t, []BlockDevice{
+		{
+			Path:        "/dev/sda",
+			Type:        "disk",
+			SizeBytes:   68719476736,
+			Vendor:      "ATA",
+			Model:       "VBOX HARDDISK",
+			MountPoints: []string{"/"},
+		},
+		{
+			Path:        "/dev/sda1",
+			Type:        "part",
+			Parent:      "/dev/sda",
+			SizeBytes:   68718428160,
+			Filesystem:  "xfs",
+			MountPoints: []string{"/"},
+		},
+		{
+			Path:      "/dev/sdb",
+			Type:      "disk",
+			SizeBytes: 10737418240,
+			Vendor:    "ATA",
+			Model:     "VBOX HARDDISK",
+		},
+		{
+			Path:        "/dev/loop0",
+			Type:        "loop",
+			SizeBytes:   1048576,
+			Filesystem:  "squashfs",
+			MountPoints: []string{"/snap/core/1"},
+		},
+	}, devices)
+}
+
+func TestFiltersDevices(t *testing.T) {
+	filter := DefaultDeviceFilter()
+	filter.IncludeVendors = []string{"ATA"}
+	testCases := []struct {
+		device   BlockDevice
+		included bool
+		reason   string
+	}{
+		{
+			device:   BlockDevice{Path: "/dev/sda", Vendor: "ATA", MountPoints: []string{"/"}},
+			included: false,
+			reason:   "OS disk, mounted on /",
+		},
+		{
+			device:   BlockDevice{Path: "/dev/sdb", Vendor: "ATA"},
+			included: true,
+		},
+		{
+			device:   BlockDevice{Path: "/dev/sdc", Vendor: "QEMU"},
+			included: false,
+			reason:   `vendor "QEMU" is not included`,
+		},
+		{
+			device:   BlockDevice{Path: "/dev/loop0", Vendor: "ATA"},
+			included: false,
+			reason:   `path matches excluded "loop"`,
+		},
+	}
+	for _, tc := range testCases {
+		included, reason := filter.Check(tc.device)
+		assert.Equal(t, tc.included, included, tc.device.Path)
+		assert.Equal(t, tc.reason, reason, tc.device.Path)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systeminfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// dmiDir is the sysfs directory with DMI attributes
+const dmiDir = "/sys/class/dmi/id"
+
+// DMI describes the hardware identification data exposed by the
+// system firmware (Desktop Management Interface)
+type DMI struct {
+	// Vendor is the system manufacturer
+	Vendor string `json:"vendor,omitempty"`
+	// Product is the system product name
+	Product string `json:"product,omitempty"`
+	// SerialNumber is the system serial number
+	SerialNumber string `json:"serial_number,omitempty"`
+	// BoardSerial is the motherboard serial number
+	BoardSerial string `json:"board_serial,omitempty"`
+	// BIOSVersion is the firmware version
+	BIOSVersion string `json:"bios_version,omitempty"`
+}
+
+// QueryDMI returns the hardware identification data of this host.
+// Attributes that are not available (e.g. not exposed by the hypervisor
+// or not readable by the current user) are left empty
+func QueryDMI() (*DMI, error) {
+	return queryDMI(dmiDir)
+}
+
+func queryDMI(dir string) (*DMI, error) {
+	var dmi DMI
+	for name, value := range map[string]*string{
+		"sys_vendor":     &dmi.Vendor,
+		"product_name":   &dmi.Product,
+		"product_serial": &dmi.SerialNumber,
+		"board_serial":   &dmi.BoardSerial,
+		"bios_version":   &dmi.BIOSVersion,
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue
+			}
+			return nil, trace.ConvertSystemError(err)
+		}
+		*value = strings.TrimSpace(string(data))
+	}
+	return &dmi, nil
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systeminfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryDMI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dmi")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, value := range map[string]string{
+		"sys_vendor":     "Dell Inc.\n",
+		"product_name":   "PowerEdge R640\n",
+		"product_serial": "  4XYZ123 \n",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644))
+	}
+
+	dmi, err := queryDMI(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, &DMI{
+		Vendor:       "Dell Inc.",
+		Product:      "PowerEdge R640",
+		SerialNumber: "4XYZ123",
+	}, dmi)
+}
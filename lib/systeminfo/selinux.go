@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systeminfo
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// SELinuxMode describes the SELinux mode of the host
+type SELinuxMode string
+
+const (
+	// SELinuxDisabled means SELinux is disabled or not supported by the kernel
+	SELinuxDisabled SELinuxMode = "disabled"
+	// SELinuxPermissive means SELinux policy violations are logged but not enforced
+	SELinuxPermissive SELinuxMode = "permissive"
+	// SELinuxEnforcing means SELinux policy is enforced
+	SELinuxEnforcing SELinuxMode = "enforcing"
+)
+
+// GetSELinuxMode returns the SELinux mode of this host
+func GetSELinuxMode() (SELinuxMode, error) {
+	return getSELinuxMode(selinuxEnforceFile)
+}
+
+// IsEnforcing returns true if SELinux policy is enforced
+func (r SELinuxMode) IsEnforcing() bool {
+	return r == SELinuxEnforcing
+}
+
+// IsEnabled returns true if SELinux is enabled in either permissive or enforcing mode
+func (r SELinuxMode) IsEnabled() bool {
+	return r != SELinuxDisabled
+}
+
+func getSELinuxMode(path string) (SELinuxMode, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = trace.ConvertSystemError(err)
+		if trace.IsNotFound(err) {
+			return SELinuxDisabled, nil
+		}
+		return "", trace.Wrap(err)
+	}
+	switch value := strings.TrimSpace(string(data)); value {
+	case "1":
+		return SELinuxEnforcing, nil
+	case "0":
+		return SELinuxPermissive, nil
+	default:
+		return "", trace.BadParameter("unexpected value %q in %v", value, path)
+	}
+}
+
+// selinuxEnforceFile is the selinuxfs file with the current enforcing mode
+const selinuxEnforceFile = "/sys/fs/selinux/enforce"
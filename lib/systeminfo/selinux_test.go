@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systeminfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectsSELinuxMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selinux")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "enforce")
+
+	mode, err := getSELinuxMode(path)
+	assert.NoError(t, err)
+	assert.Equal(t, SELinuxDisabled, mode)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("0\n"), 0644))
+	mode, err = getSELinuxMode(path)
+	assert.NoError(t, err)
+	assert.Equal(t, SELinuxPermissive, mode)
+	assert.True(t, mode.IsEnabled())
+	assert.False(t, mode.IsEnforcing())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("1\n"), 0644))
+	mode, err = getSELinuxMode(path)
+	assert.NoError(t, err)
+	assert.Equal(t, SELinuxEnforcing, mode)
+	assert.True(t, mode.IsEnforcing())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("x"), 0644))
+	_, err = getSELinuxMode(path)
+	assert.Error(t, err)
+}
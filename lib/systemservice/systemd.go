@@ -99,6 +99,12 @@ Where={{.Where}}
 WantedBy=local-fs.target
 `))
 
+// PackageServiceName returns the name of the systemd unit
+// of the service started from the specified package
+func PackageServiceName(pkg loc.Locator) string {
+	return newSystemdUnit(pkg).serviceName()
+}
+
 func newSystemdUnit(pkg loc.Locator) *systemdUnit {
 	return &systemdUnit{pkg: pkg}
 }
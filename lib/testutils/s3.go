@@ -34,6 +34,8 @@ import (
 	"github.com/gravitational/trace"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
@@ -44,6 +46,8 @@ type S3 struct {
 	s3iface.S3API
 	// Objects is the objects stored in the fake S3
 	Objects map[string]S3Object
+	// Lifecycle is the bucket lifecycle configuration
+	Lifecycle *s3.BucketLifecycleConfiguration
 }
 
 // S3Object represents a file object stored in the fake S3
@@ -163,3 +167,51 @@ func (s *S3) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opt
 		ContentLength: aws.Int64(int64(len(object.Data))),
 	}, nil
 }
+
+func (s *S3) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	object, ok := s.Objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, awserr.New("NotFound", "Not Found", nil)
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(object.Data))),
+		LastModified:  aws.Time(object.Created),
+	}, nil
+}
+
+func (s *S3) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	delete(s.Objects, aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// PutObjectRequest returns a request that stores the object in the fake S3
+// when sent, it is used by the S3 upload manager for small objects
+func (s *S3) PutObjectRequest(input *s3.PutObjectInput) (*request.Request, *s3.PutObjectOutput) {
+	output := &s3.PutObjectOutput{}
+	req := request.New(aws.Config{}, metadata.ClientInfo{}, request.Handlers{}, nil,
+		&request.Operation{Name: "PutObject", HTTPMethod: "PUT"}, input, output)
+	req.Handlers.Send.PushBack(func(r *request.Request) {
+		data, err := ioutil.ReadAll(input.Body)
+		if err != nil {
+			r.Error = err
+			return
+		}
+		s.Objects[aws.StringValue(input.Key)] = S3Object{
+			Data:    data,
+			Created: time.Now().UTC().Truncate(time.Second),
+		}
+	})
+	return req, output
+}
+
+func (s *S3) GetBucketLifecycleConfiguration(input *s3.GetBucketLifecycleConfigurationInput) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	if s.Lifecycle == nil {
+		return nil, awserr.New("NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist", nil)
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: s.Lifecycle.Rules}, nil
+}
+
+func (s *S3) PutBucketLifecycleConfiguration(input *s3.PutBucketLifecycleConfigurationInput) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	s.Lifecycle = input.LifecycleConfiguration
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewDistribute returns a new executor that stages the new gravity binary
+// on the node specified with params
+func NewDistribute(params libfsm.ExecutorParams, operation ops.SiteOperation, logger log.FieldLogger) (*distribute, error) {
+	node, err := newNode(params, operation, logger)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &distribute{node: node}, nil
+}
+
+// Execute exports the gravity binary package from the cluster package service
+// into the staging directory on the node and makes sure the binary runs
+func (r *distribute) Execute(ctx context.Context) error {
+	r.Infof("Distribute %v to %v.", r.pkg, r.server)
+	_, err := r.command(ctx, "/bin/sh", "-c", fmt.Sprintf("rm -rf %[1]v && mkdir -p %[1]v", r.layout.hostDir))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.planetCommand(ctx, defaults.GravityBin, "package", "export",
+		fmt.Sprintf("--file-mask=%o", defaults.SharedExecutableMask),
+		r.pkg.String(), r.layout.planetStaging,
+		fmt.Sprintf("--ops-url=%v", defaults.GravityServiceURL), "--insecure")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	out, err := r.command(ctx, r.layout.hostStaging, "version", "--output=json")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := checkVersion(out, r.pkg); err != nil {
+		return trace.Wrap(err, "staged gravity binary on node %v", r.server.Hostname)
+	}
+	return nil
+}
+
+// Rollback removes the staged binary from the node
+func (r *distribute) Rollback(ctx context.Context) error {
+	_, err := r.command(ctx, "rm", "-rf", r.layout.hostDir)
+	return trace.Wrap(err)
+}
+
+// PreCheck is a no-op
+func (*distribute) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is a no-op
+func (*distribute) PostCheck(context.Context) error {
+	return nil
+}
+
+type distribute struct {
+	*node
+}
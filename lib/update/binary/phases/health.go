@@ -0,0 +1,103 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+	libstatus "github.com/gravitational/gravity/lib/status"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewHealth returns a new executor that verifies the new gravity binary
+// on the node specified with params
+func NewHealth(params libfsm.ExecutorParams, operation ops.SiteOperation, logger log.FieldLogger) (*health, error) {
+	node, err := newNode(params, operation, logger)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &health{node: node}, nil
+}
+
+// Execute waits for the node to report the new gravity version and to become healthy
+// and cancels the restore of the previous binary scheduled by the swap phase.
+// If the node does not pass the health checks in time, the previous
+// gravity binary is restored on the node
+func (r *health) Execute(ctx context.Context) error {
+	r.Infof("Wait for %v to become healthy.", r.server)
+	b := utils.NewExponentialBackOff(defaults.NodeStatusTimeout)
+	err := utils.RetryWithInterval(ctx, b, func() error {
+		if err := r.checkVersions(ctx); err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(r.checkNodeStatus(ctx))
+	})
+	if err == nil {
+		return trace.Wrap(r.disarmRestore(ctx))
+	}
+	r.WithError(err).Warn("Health check failed, restoring previous gravity binary.")
+	if errRestore := r.restoreOrWait(ctx); errRestore != nil {
+		return trace.NewAggregate(err, errRestore)
+	}
+	return trace.Wrap(err, "node %v failed the health check, previous gravity binary has been restored",
+		r.server.Hostname)
+}
+
+// Rollback is a no-op for this phase
+func (*health) Rollback(context.Context) error {
+	return nil
+}
+
+// PreCheck is a no-op
+func (*health) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is a no-op
+func (*health) PostCheck(context.Context) error {
+	return nil
+}
+
+func (r *health) checkNodeStatus(ctx context.Context) error {
+	status, err := libstatus.FromPlanetAgent(ctx, []storage.Server{r.server})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, node := range status.Nodes {
+		if node.AdvertiseIP != r.server.AdvertiseIP {
+			continue
+		}
+		if node.Status != libstatus.NodeHealthy {
+			return trace.CompareFailed("node %v is %v: %v", r.server.Hostname,
+				node.Status, strings.Join(node.FailedProbes, ", "))
+		}
+		return nil
+	}
+	return trace.NotFound("node %v is not reported by the planet agent", r.server.Hostname)
+}
+
+type health struct {
+	*node
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/modules"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/rpc"
+	rpcclient "github.com/gravitational/gravity/lib/rpc/client"
+	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+)
+
+// newNode returns a new node the gravity binary is updated on
+// from the specified phase parameters
+func newNode(params libfsm.ExecutorParams, operation ops.SiteOperation, logger log.FieldLogger) (*node, error) {
+	if params.Phase.Data == nil || params.Phase.Data.Server == nil {
+		return nil, trace.NotFound("no server specified for phase %q", params.Phase.ID)
+	}
+	if operation.UpdateBinary == nil {
+		return nil, trace.BadParameter("operation %v does not describe the gravity binary update", operation.ID)
+	}
+	pkg, err := loc.ParseLocator(operation.UpdateBinary.Package)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	creds, err := libfsm.GetClientCredentials()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	server := *params.Phase.Data.Server
+	return &node{
+		FieldLogger: logger,
+		server:      server,
+		pkg:         *pkg,
+		creds:       creds,
+		layout:      newLayout(server.StateDir()),
+	}, nil
+}
+
+// command runs the command specified with args on the node
+func (r *node) command(ctx context.Context, args ...string) ([]byte, error) {
+	agent, err := r.connect(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer agent.Close()
+	var out bytes.Buffer
+	err = agent.Command(ctx, r, &out, args...)
+	if err != nil {
+		return nil, trace.Wrap(err, "command %q failed on node %v: %s",
+			strings.Join(args, " "), r.server.Hostname, out.String())
+	}
+	return out.Bytes(), nil
+}
+
+// planetCommand runs the command specified with args inside the planet container on the node
+func (r *node) planetCommand(ctx context.Context, args ...string) ([]byte, error) {
+	return r.command(ctx, append([]string{defaults.GravityBin, "enter", "--", "--notty", args[0], "--"}, args[1:]...)...)
+}
+
+// swap replaces the gravity binaries on the node with the staged binary.
+// The previous binaries are backed up only once per operation so that
+// repeated swaps do not overwrite the backups
+func (r *node) swap(ctx context.Context) error {
+	r.Infof("Replace gravity binary on %v.", r.server)
+	// Replace the binary inside planet first as entering planet
+	// requires a working host binary
+	_, err := r.planetCommand(ctx, "/bin/sh", "-c", swapScript(r.layout.planetStaging, r.layout.planetTargets))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.command(ctx, "/bin/sh", "-c", swapScript(r.layout.hostStaging, r.layout.hostTargets))
+	return trace.Wrap(err)
+}
+
+// restore restores the backed up gravity binaries on the node
+func (r *node) restore(ctx context.Context) error {
+	r.Infof("Restore previous gravity binary on %v.", r.server)
+	// Restore the host binary first as entering planet
+	// requires a working host binary
+	_, err := r.command(ctx, "/bin/sh", "-c", restoreScript(r.layout.hostTargets))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.planetCommand(ctx, "/bin/sh", "-c", restoreScript(r.layout.planetTargets))
+	return trace.Wrap(err)
+}
+
+// armRestore schedules the restore of the backed up gravity binaries on the node
+// after defaults.BinaryRestoreTimeout unless disarmed with disarmRestore.
+// The restore runs as a transient systemd unit on the node so it does not depend
+// on the agent which might fail to start with the new binary
+func (r *node) armRestore(ctx context.Context) error {
+	r.Infof("Schedule restore of previous gravity binary on %v in %v.",
+		r.server, defaults.BinaryRestoreTimeout)
+	_, err := r.command(ctx, "/bin/sh", "-c", fmt.Sprintf(
+		"systemctl stop %[1]v.timer %[1]v.service 2>/dev/null; "+
+			"systemctl reset-failed %[1]v.service 2>/dev/null; "+
+			"systemd-run --unit=%[1]v --on-active=%[2]v /bin/sh -c %[3]v",
+		restoreUnit, int(defaults.BinaryRestoreTimeout.Seconds()),
+		shellQuote(restoreUnitScript(r.layout))))
+	return trace.Wrap(err)
+}
+
+// disarmRestore cancels the scheduled restore of the gravity binaries on the node
+func (r *node) disarmRestore(ctx context.Context) error {
+	r.Infof("Cancel scheduled restore of previous gravity binary on %v.", r.server)
+	_, err := r.command(ctx, "/bin/sh", "-c", fmt.Sprintf(
+		"systemctl stop %[1]v.timer 2>/dev/null; true", restoreUnit))
+	return trace.Wrap(err)
+}
+
+// restoreOrWait restores the backed up gravity binaries on the node.
+// If the agent on the node cannot be reached, it waits for the scheduled
+// restore to bring back the previous binary and the agent instead
+func (r *node) restoreOrWait(ctx context.Context) error {
+	err := r.restore(ctx)
+	if err == nil {
+		if err := r.restartAgent(ctx); err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(r.disarmRestore(ctx))
+	}
+	r.WithError(err).Warn("Failed to restore gravity binary via agent, wait for scheduled restore.")
+	b := utils.NewExponentialBackOff(defaults.BinaryRestoreTimeout + defaults.AgentRestartTimeout)
+	errWait := utils.RetryWithInterval(ctx, b, func() error {
+		ctx, cancel := context.WithTimeout(ctx, defaults.DialTimeout)
+		defer cancel()
+		out, err := r.command(ctx, "/bin/sh", "-c", fmt.Sprintf(
+			"systemctl is-active --quiet %v.timer && echo armed || echo done", restoreUnit))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if strings.TrimSpace(string(out)) != "done" {
+			return trace.CompareFailed("restore on node %v has not run yet", r.server.Hostname)
+		}
+		return nil
+	})
+	if errWait != nil {
+		return trace.NewAggregate(err, errWait)
+	}
+	return nil
+}
+
+// restartAgent restarts the node's agent and waits for it to come back
+func (r *node) restartAgent(ctx context.Context) error {
+	pid, err := r.agentPID(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	r.Infof("Restart agent on %v.", r.server)
+	_, err = r.command(ctx, "systemd-run",
+		fmt.Sprintf("--on-active=%v", int(defaults.AgentRestartDelay.Seconds())),
+		"/bin/systemctl", "restart", defaults.GravityRPCAgentServiceName)
+	if err != nil {
+		return trace.Wrap(err, "failed to restart agent on node %v", r.server.Hostname)
+	}
+	b := utils.NewExponentialBackOff(defaults.AgentRestartTimeout)
+	err = utils.RetryWithInterval(ctx, b, func() error {
+		ctx, cancel := context.WithTimeout(ctx, defaults.DialTimeout)
+		defer cancel()
+		newPID, err := r.agentPID(ctx)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if newPID == pid {
+			return trace.CompareFailed("agent on node %v has not restarted yet", r.server.Hostname)
+		}
+		return nil
+	})
+	return trace.Wrap(err)
+}
+
+// checkVersions verifies that both the host binary and the agent
+// on the node run the expected gravity version
+func (r *node) checkVersions(ctx context.Context) error {
+	out, err := r.command(ctx, defaults.GravityBin, "version", "--output=json")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := checkVersion(out, r.pkg); err != nil {
+		return trace.Wrap(err, "gravity binary on node %v", r.server.Hostname)
+	}
+	agent, err := r.connect(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer agent.Close()
+	var buf bytes.Buffer
+	err = agent.GravityCommand(ctx, r, &buf, "version", "--output=json")
+	if err != nil {
+		return trace.Wrap(err, "failed to query agent version on node %v: %s",
+			r.server.Hostname, buf.String())
+	}
+	if err := checkVersion(buf.Bytes(), r.pkg); err != nil {
+		return trace.Wrap(err, "agent on node %v", r.server.Hostname)
+	}
+	return nil
+}
+
+func (r *node) agentPID(ctx context.Context) (string, error) {
+	out, err := r.command(ctx, "/bin/systemctl", "show", "--property=MainPID",
+		defaults.GravityRPCAgentServiceName)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(out)), "MainPID=")), nil
+}
+
+func (r *node) connect(ctx context.Context) (rpcclient.Client, error) {
+	agent, err := rpcclient.New(ctx, rpcclient.Config{
+		ServerAddr:  rpc.AgentAddr(r.server.AdvertiseIP),
+		Credentials: r.creds,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to connect to the agent on node %v", r.server.Hostname)
+	}
+	return agent, nil
+}
+
+type node struct {
+	// FieldLogger specifies the logger for the phase
+	log.FieldLogger
+	server storage.Server
+	// pkg specifies the gravity binary package to install
+	pkg    loc.Locator
+	creds  credentials.TransportCredentials
+	layout layout
+}
+
+// checkVersion verifies that the output of the gravity version command
+// reports the version of the specified package
+func checkVersion(out []byte, pkg loc.Locator) error {
+	var version modules.Version
+	if err := json.Unmarshal(out, &version); err != nil {
+		return trace.Wrap(err, "failed to parse version: %s", out)
+	}
+	expected, err := pkg.SemVer()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	actual, err := semver.NewVersion(strings.TrimPrefix(version.Version, "v"))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !actual.Equal(*expected) {
+		return trace.CompareFailed("reports version %v, expected %v", version.Version, pkg.Version)
+	}
+	return nil
+}
+
+// newLayout returns the layout of the gravity binaries
+// on a node with the specified state directory
+func newLayout(stateDir string) layout {
+	hostDir := filepath.Join(state.GravityUpdateDir(stateDir), binaryDir)
+	planetDir := filepath.Join(defaults.GravityUpdateDir, binaryDir)
+	paths := append([]string{}, state.GravityBinPaths...)
+	paths = append(paths, filepath.Join(state.GravityRPCAgentDir(stateDir), constants.GravityBin))
+	var hostTargets []target
+	for _, path := range paths {
+		hostTargets = append(hostTargets, target{
+			path:   path,
+			backup: filepath.Join(hostDir, backupDir, path),
+		})
+	}
+	return layout{
+		hostDir:       hostDir,
+		hostStaging:   filepath.Join(hostDir, constants.GravityBin),
+		planetStaging: filepath.Join(planetDir, constants.GravityBin),
+		hostTargets:   hostTargets,
+		planetTargets: []target{{
+			path:   defaults.GravityBin,
+			backup: filepath.Join(planetDir, backupDir, planetBackupDir, defaults.GravityBin),
+		}},
+	}
+}
+
+// layout describes the locations of the gravity binaries on a node
+type layout struct {
+	// hostDir is the host directory with the staged binary and backups
+	hostDir string
+	// hostStaging is the host path of the staged binary
+	hostStaging string
+	// planetStaging is the path of the staged binary inside planet
+	planetStaging string
+	// hostTargets lists the binaries to replace on host
+	hostTargets []target
+	// planetTargets lists the binaries to replace inside planet
+	planetTargets []target
+}
+
+// target describes a gravity binary to replace
+type target struct {
+	// path is the path of the binary
+	path string
+	// backup is the path to back up the binary to
+	backup string
+}
+
+// swapScript returns the shell script that backs up and atomically
+// replaces each existing target binary with the staged binary
+func swapScript(staging string, targets []target) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	for _, t := range targets {
+		fmt.Fprintf(&b, "if [ -f %v ]; then\n", t.path)
+		fmt.Fprintf(&b, "  if [ ! -f %[2]v ]; then mkdir -p %[3]v && cp -p %[1]v %[2]v; fi\n",
+			t.path, t.backup, filepath.Dir(t.backup))
+		fmt.Fprintf(&b, "  cp %[2]v %[1]v.new && chmod %[3]o %[1]v.new && mv -f %[1]v.new %[1]v\n",
+			t.path, staging, defaults.SharedExecutableMask)
+		b.WriteString("fi\n")
+	}
+	return b.String()
+}
+
+// restoreScript returns the shell script that atomically restores
+// each backed up target binary
+func restoreScript(targets []target) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	for _, t := range targets {
+		fmt.Fprintf(&b, "if [ -f %v ]; then\n", t.backup)
+		fmt.Fprintf(&b, "  cp -p %[2]v %[1]v.new && mv -f %[1]v.new %[1]v\n", t.path, t.backup)
+		b.WriteString("fi\n")
+	}
+	return b.String()
+}
+
+// restoreUnitScript returns the shell script the scheduled restore unit runs
+// on the node: it restores the host binaries, then the binary inside planet with the
+// restored host binary and restarts the agent
+func restoreUnitScript(layout layout) string {
+	var b strings.Builder
+	b.WriteString(restoreScript(layout.hostTargets))
+	fmt.Fprintf(&b, "%v enter -- --notty /bin/sh -- -c %v\n",
+		defaults.GravityBin, shellQuote(restoreScript(layout.planetTargets)))
+	fmt.Fprintf(&b, "/bin/systemctl restart %v\n", defaults.GravityRPCAgentServiceName)
+	return b.String()
+}
+
+// shellQuote quotes the specified string as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+const (
+	// restoreUnit is the name of the transient systemd unit that restores
+	// the previous gravity binaries on a node unless cancelled
+	restoreUnit = "gravity-binary-restore"
+
+	// binaryDir is the update directory subdirectory with the staged binary
+	binaryDir = "binary"
+	// backupDir is the subdirectory with the backed up binaries
+	backupDir = "backup"
+	// planetBackupDir is the backup subdirectory for the binaries inside planet
+	planetBackupDir = "planet"
+)
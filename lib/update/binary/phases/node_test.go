@@ -0,0 +1,59 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"os/exec"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+func TestPhases(t *testing.T) { check.TestingT(t) }
+
+type NodeSuite struct{}
+
+var _ = check.Suite(&NodeSuite{})
+
+func (s *NodeSuite) TestRestoreUnitScript(c *check.C) {
+	layout := layout{
+		hostTargets: []target{{path: "/usr/bin/gravity", backup: "/var/lib/gravity/backup/usr/bin/gravity"}},
+		planetTargets: []target{{
+			path:   "/usr/bin/gravity",
+			backup: "/var/lib/gravity/planet/backup/usr/bin/gravity",
+		}},
+	}
+	c.Assert(restoreUnitScript(layout), check.Equals, `set -e
+if [ -f /var/lib/gravity/backup/usr/bin/gravity ]; then
+  cp -p /var/lib/gravity/backup/usr/bin/gravity /usr/bin/gravity.new && mv -f /usr/bin/gravity.new /usr/bin/gravity
+fi
+/usr/bin/gravity enter -- --notty /bin/sh -- -c 'set -e
+if [ -f /var/lib/gravity/planet/backup/usr/bin/gravity ]; then
+  cp -p /var/lib/gravity/planet/backup/usr/bin/gravity /usr/bin/gravity.new && mv -f /usr/bin/gravity.new /usr/bin/gravity
+fi
+'
+/bin/systemctl restart gravity-agent.service
+`)
+}
+
+func (s *NodeSuite) TestQuotesShellWords(c *check.C) {
+	for _, word := range []string{"", "plain", "it's quoted", "$HOME `date` \"x\""} {
+		out, err := exec.Command("/bin/sh", "-c", "printf %s "+shellQuote(word)).Output()
+		c.Assert(err, check.IsNil)
+		c.Assert(string(out), check.Equals, word)
+	}
+}
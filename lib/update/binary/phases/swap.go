@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewSwap returns a new executor that replaces the gravity binary
+// on the node specified with params with the staged binary
+func NewSwap(params libfsm.ExecutorParams, operation ops.SiteOperation, logger log.FieldLogger) (*swap, error) {
+	node, err := newNode(params, operation, logger)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &swap{node: node}, nil
+}
+
+// Execute backs up and replaces the gravity binaries on the node
+// and restarts the node's agent to pick up the new binary.
+// The restore of the previous binaries is scheduled on the node first so the node
+// recovers even if the agent does not come back with the new binary.
+// The health phase cancels the restore once the node is healthy
+func (r *swap) Execute(ctx context.Context) error {
+	if err := r.armRestore(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := r.swap(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.restartAgent(ctx))
+}
+
+// Rollback restores the previous gravity binaries on the node
+// and restarts the node's agent.
+// If the agent cannot be reached, it waits for the scheduled restore instead
+func (r *swap) Rollback(ctx context.Context) error {
+	return trace.Wrap(r.restoreOrWait(ctx))
+}
+
+// PreCheck is a no-op
+func (*swap) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is a no-op
+func (*swap) PostCheck(context.Context) error {
+	return nil
+}
+
+type swap struct {
+	*node
+}
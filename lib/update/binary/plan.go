@@ -0,0 +1,151 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package binary
+
+import (
+	"fmt"
+
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+	"github.com/gravitational/gravity/lib/update/binary/phases"
+
+	"github.com/gravitational/trace"
+)
+
+// NewOperationPlan creates a new operation plan for the specified operation.
+// All phases are executed from the leader node which drives the operation.
+// Servers with hostnames or advertise IPs listed in skipNodes are excluded from the plan
+func NewOperationPlan(
+	operator ops.Operator,
+	operation ops.SiteOperation,
+	leader storage.Server,
+	servers []storage.Server,
+	skipNodes []string,
+) (plan *storage.OperationPlan, err error) {
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	servers, skipped, err := update.SkipServers(servers, skipNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plan, err = newOperationPlan(cluster.DNSConfig, operation, leader, servers, skipped)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = operator.CreateOperationPlan(operation.Key(), *plan)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotImplemented(
+				"cluster operator does not implement the API required to update the gravity binary. " +
+					"Please make sure you're running the command on a compatible cluster.")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return plan, nil
+}
+
+// newOperationPlan returns a new plan for the specified operation
+// and the given set of servers.
+// skipped lists the servers excluded from the operation
+func newOperationPlan(
+	dnsConfig storage.DNSConfig,
+	operation ops.SiteOperation,
+	leader storage.Server,
+	servers, skipped []storage.Server,
+) (*storage.OperationPlan, error) {
+	if operation.UpdateBinary == nil {
+		return nil, trace.BadParameter("operation %v does not describe the gravity binary update", operation.ID)
+	}
+	masters, nodes := libfsm.SplitServers(servers)
+	builder := builder{leader: leader, pkg: operation.UpdateBinary.Package}
+	var phases update.Phases
+	if len(masters) != 0 {
+		phases = append(phases, *builder.nodes("masters", "Update gravity binary on master nodes", masters))
+	}
+	if len(nodes) != 0 {
+		updateNodes := *builder.nodes("nodes", "Update gravity binary on regular nodes", nodes)
+		if len(masters) != 0 {
+			updateNodes.Require(phases[0])
+		}
+		phases = append(phases, updateNodes)
+	}
+	if len(phases) == 0 {
+		return nil, trace.NotFound("no nodes to update")
+	}
+
+	plan := &storage.OperationPlan{
+		OperationID:    operation.ID,
+		OperationType:  operation.Type,
+		AccountID:      operation.AccountID,
+		ClusterName:    operation.SiteDomain,
+		Phases:         phases.AsPhases(),
+		Servers:        servers,
+		SkippedServers: skipped,
+		DNSConfig:      dnsConfig,
+	}
+	update.ResolvePlan(plan)
+
+	return plan, nil
+}
+
+// nodes returns a new phase to update the gravity binary
+// on the specified servers one at a time
+func (r builder) nodes(id, rootText string, servers []storage.Server) *update.Phase {
+	root := update.RootPhase(update.Phase{
+		ID:          id,
+		Description: rootText,
+	})
+	for i := range servers {
+		node := update.Phase{
+			ID:          servers[i].Hostname,
+			Description: fmt.Sprintf("Update gravity binary on node %q", servers[i].Hostname),
+		}
+		node.AddSequential(
+			r.phase(phases.Distribute, "Distribute %v to node %q", servers[i]),
+			r.phase(phases.Swap, "Replace gravity binary with %v on node %q", servers[i]),
+			r.phase(phases.Health, "Verify %v on node %q", servers[i]),
+		)
+		root.AddSequential(node)
+	}
+	return &root
+}
+
+// phase returns a new phase with the specified executor that targets
+// the given server and runs on the leader node
+func (r builder) phase(executor, format string, server storage.Server) update.Phase {
+	return update.Phase{
+		ID:          executor,
+		Executor:    executor,
+		Description: fmt.Sprintf(format, r.pkg, server.Hostname),
+		Data: &storage.OperationPhaseData{
+			Server:     &server,
+			ExecServer: &r.leader,
+		},
+	}
+}
+
+// builder builds the gravity binary update operation plan
+type builder struct {
+	// leader is the server driving the operation
+	leader storage.Server
+	// pkg is the gravity binary package to install
+	pkg string
+}
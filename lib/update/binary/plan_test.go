@@ -0,0 +1,140 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package binary
+
+import (
+	"testing"
+
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update/binary/phases"
+
+	"github.com/gravitational/trace"
+	. "gopkg.in/check.v1"
+)
+
+func TestBinary(t *testing.T) { TestingT(t) }
+
+type S struct{}
+
+var _ = Suite(&S{})
+
+func (S) TestPlanUpdatesMastersFirst(c *C) {
+	operation := newOperation()
+	servers := []storage.Server{master1, node1, master2}
+
+	plan, err := newOperationPlan(storage.DefaultDNSConfig, operation, master1, servers, nil)
+	c.Assert(err, IsNil)
+	c.Assert(plan, compare.DeepEquals, &storage.OperationPlan{
+		OperationID:   operation.ID,
+		OperationType: operation.Type,
+		AccountID:     operation.AccountID,
+		ClusterName:   operation.SiteDomain,
+		Servers:       servers,
+		DNSConfig:     storage.DefaultDNSConfig,
+		Phases: []storage.OperationPhase{
+			{
+				ID:          "/masters",
+				Description: "Update gravity binary on master nodes",
+				Phases: []storage.OperationPhase{
+					nodePhase("/masters", master1),
+					nodePhase("/masters", master2, "/masters/master-1"),
+				},
+			},
+			{
+				ID:          "/nodes",
+				Description: "Update gravity binary on regular nodes",
+				Requires:    []string{"/masters"},
+				Phases: []storage.OperationPhase{
+					nodePhase("/nodes", node1),
+				},
+			},
+		},
+	})
+}
+
+func (S) TestPlanExcludesSkippedNodes(c *C) {
+	plan, err := newOperationPlan(storage.DefaultDNSConfig, newOperation(), master1,
+		[]storage.Server{master1}, []storage.Server{node1})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Phases, HasLen, 1)
+	c.Assert(plan.SkippedServers, compare.DeepEquals, []storage.Server{node1})
+
+	_, err = newOperationPlan(storage.DefaultDNSConfig, newOperation(), master1,
+		nil, []storage.Server{master1, node1})
+	c.Assert(trace.IsNotFound(err), Equals, true, Commentf("%v", err))
+}
+
+func nodePhase(parent string, server storage.Server, requires ...string) storage.OperationPhase {
+	id := parent + "/" + server.Hostname
+	phase := func(executor, description string, requires ...string) storage.OperationPhase {
+		return storage.OperationPhase{
+			ID:          id + "/" + executor,
+			Executor:    executor,
+			Description: description,
+			Requires:    requires,
+			Data: &storage.OperationPhaseData{
+				Server:     &server,
+				ExecServer: &master1,
+			},
+		}
+	}
+	return storage.OperationPhase{
+		ID:          id,
+		Description: `Update gravity binary on node "` + server.Hostname + `"`,
+		Requires:    requires,
+		Phases: []storage.OperationPhase{
+			phase(phases.Distribute, `Distribute gravitational.io/gravity:6.1.5 to node "`+server.Hostname+`"`),
+			phase(phases.Swap, `Replace gravity binary with gravitational.io/gravity:6.1.5 on node "`+server.Hostname+`"`,
+				id+"/distribute"),
+			phase(phases.Health, `Verify gravitational.io/gravity:6.1.5 on node "`+server.Hostname+`"`, id+"/swap"),
+		},
+	}
+}
+
+func newOperation() ops.SiteOperation {
+	return ops.SiteOperation{
+		ID:         "1",
+		AccountID:  "0",
+		Type:       ops.OperationUpdateBinary,
+		SiteDomain: "cluster",
+		UpdateBinary: &storage.UpdateBinaryOperationState{
+			Package:         "gravitational.io/gravity:6.1.5",
+			PreviousPackage: "gravitational.io/gravity:6.1.4",
+		},
+	}
+}
+
+var (
+	master1 = storage.Server{
+		Hostname:    "master-1",
+		AdvertiseIP: "192.168.1.1",
+		ClusterRole: string(schema.ServiceRoleMaster),
+	}
+	master2 = storage.Server{
+		Hostname:    "master-2",
+		AdvertiseIP: "192.168.1.2",
+		ClusterRole: string(schema.ServiceRoleMaster),
+	}
+	node1 = storage.Server{
+		Hostname:    "node-1",
+		AdvertiseIP: "192.168.1.3",
+		ClusterRole: string(schema.ServiceRoleNode),
+	}
+)
@@ -19,8 +19,11 @@ package update
 
 import (
 	"path"
+	"time"
 
 	"github.com/gravitational/gravity/lib/storage"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
 )
 
 // AddSequential will append sub-phases which depend one upon another
@@ -84,6 +87,11 @@ func (p Phase) GetID() string {
 	return p.ID
 }
 
+// Timeout returns the phase timeout of the specified duration
+func Timeout(timeout time.Duration) *teleservices.Duration {
+	return &teleservices.Duration{Duration: timeout}
+}
+
 // RootPhase makes the specified phase root
 func RootPhase(sub Phase) Phase {
 	sub.ID = path.Join("/", sub.ID)
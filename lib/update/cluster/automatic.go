@@ -64,19 +64,23 @@ func AutomaticUpgrade(ctx context.Context, localEnv, updateEnv *localenv.LocalEn
 		Client:            clusterEnv.Client,
 		Users:             clusterEnv.Users,
 	}
-	fsm, err := New(ctx, config)
+	updater, err := New(ctx, config)
 	if err != nil {
 		return trace.Wrap(err, "failed to load or initialize upgrade plan")
 	}
-	defer fsm.Close()
+	defer updater.Close()
 
-	fsmErr := fsm.Run(ctx)
+	fsmErr := updater.Run(ctx)
+	if fsm.IsPaused(fsmErr) {
+		log.WithError(fsmErr).Info("Upgrade paused, waiting to be resumed.")
+		return nil
+	}
 	if fsmErr != nil {
 		log.WithError(fsmErr).Warn("Failed to execute plan.")
 		// fallthrough
 	}
 
-	err = fsm.Complete(fsmErr)
+	err = updater.Complete(fsmErr)
 	if err != nil {
 		return trace.Wrap(err)
 	}
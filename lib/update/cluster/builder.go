@@ -26,6 +26,7 @@ import (
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/update"
 	libphase "github.com/gravitational/gravity/lib/update/cluster/phases"
@@ -117,6 +118,19 @@ func (r phaseBuilder) corednsPhase(leadMaster storage.Server) *update.Phase {
 	return &phase
 }
 
+func (r phaseBuilder) networkPolicy(leadMaster storage.Server) *update.Phase {
+	phase := update.RootPhase(update.Phase{
+		ID:          "network-policy",
+		Description: "Update baseline network policies",
+		Executor:    networkPolicy,
+		Data: &storage.OperationPhaseData{
+			Server:  &leadMaster,
+			Package: &r.updateApp.Package,
+		},
+	})
+	return &phase
+}
+
 func (r phaseBuilder) app(updates []loc.Locator) *update.Phase {
 	root := update.RootPhase(update.Phase{
 		ID:          "app",
@@ -305,6 +319,25 @@ func (r phaseBuilder) nodes(leadMaster storage.UpdateServer, nodes []storage.Upd
 	return &root
 }
 
+// catchUpNode returns the phase that updates system software on a single
+// node that has been excluded from previous update operations
+func (r phaseBuilder) catchUpNode(leadMaster, server storage.UpdateServer, supportsTaints bool) *update.Phase {
+	id, format := "nodes", "Update system software on node %q"
+	if server.IsMaster() {
+		id, format = "masters", "Update system software on master node %q"
+	}
+	root := update.RootPhase(update.Phase{
+		ID:          id,
+		Description: fmt.Sprintf("Catch up node %q", server.Hostname),
+	})
+
+	node := r.node(server.Server, &root, format)
+	node.AddSequential(r.commonNode(server, leadMaster, supportsTaints,
+		waitsForEndpoints(true))...)
+	root.AddSequential(node)
+	return &root
+}
+
 func (r phaseBuilder) etcdPlan(
 	leadMaster storage.Server,
 	otherMasters []storage.Server,
@@ -384,6 +417,7 @@ func (r phaseBuilder) etcdPlan(
 		ID:          root.ChildLiteral("restore"),
 		Description: "Restore etcd data from backup",
 		Executor:    updateEtcdRestore,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &leadMaster,
 		},
@@ -414,6 +448,7 @@ func (r phaseBuilder) etcdPlan(
 		ID:          restartMasters.ChildLiteral(constants.GravityServiceName),
 		Description: fmt.Sprint("Restart ", constants.GravityServiceName, " service"),
 		Executor:    updateEtcdRestartGravity,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &leadMaster,
 		},
@@ -428,6 +463,7 @@ func (r phaseBuilder) etcdBackupNode(server storage.Server, parent update.Phase)
 		ID:          parent.ChildLiteral(server.Hostname),
 		Description: fmt.Sprintf("Backup etcd on node %q", server.Hostname),
 		Executor:    updateEtcdBackup,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &server,
 		},
@@ -439,6 +475,7 @@ func (r phaseBuilder) etcdShutdownNode(server storage.Server, parent update.Phas
 		ID:          parent.ChildLiteral(server.Hostname),
 		Description: fmt.Sprintf("Shutdown etcd on node %q", server.Hostname),
 		Executor:    updateEtcdShutdown,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &server,
 			Data:   strconv.FormatBool(isLeader),
@@ -451,6 +488,7 @@ func (r phaseBuilder) etcdUpgrade(server storage.Server, parent update.Phase) up
 		ID:          parent.ChildLiteral(server.Hostname),
 		Description: fmt.Sprintf("Upgrade etcd on node %q", server.Hostname),
 		Executor:    updateEtcdMaster,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &server,
 		},
@@ -462,6 +500,7 @@ func (r phaseBuilder) etcdRestart(server storage.Server, parent update.Phase) up
 		ID:          parent.ChildLiteral(server.Hostname),
 		Description: fmt.Sprintf("Restart etcd on node %q", server.Hostname),
 		Executor:    updateEtcdRestart,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &server,
 		},
@@ -482,6 +521,7 @@ func (r phaseBuilder) commonNode(server, leadMaster storage.UpdateServer, suppor
 		{
 			ID:          "drain",
 			Executor:    drainNode,
+			Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 			Description: fmt.Sprintf("Drain node %q", server.Hostname),
 			Data: &storage.OperationPhaseData{
 				Server:     &server.Server,
@@ -491,6 +531,7 @@ func (r phaseBuilder) commonNode(server, leadMaster storage.UpdateServer, suppor
 		{
 			ID:          "system-upgrade",
 			Executor:    updateSystem,
+			Timeout:     update.Timeout(defaults.SystemUpgradePhaseTimeout),
 			Description: fmt.Sprintf("Update system software on node %q", server.Hostname),
 			Data: &storage.OperationPhaseData{
 				ExecServer: &server.Server,
@@ -607,19 +648,30 @@ func supportsTaints(gravityPackage loc.Locator) (supports bool, err error) {
 }
 
 func shouldUpdateEtcd(p planConfig) (updateEtcd bool, installedEtcdVersion string, updateEtcdVersion string, err error) {
+	return compareEtcdVersions(p.installedRuntime.Manifest, p.updateRuntime.Manifest,
+		p.packageService, p.packageService)
+}
+
+// compareEtcdVersions determines whether etcd needs to be upgraded by comparing
+// etcd versions of the default runtime packages of the installed and update runtime
+// applications. Runtime packages are looked up in the specified package services
+func compareEtcdVersions(
+	installedRuntime, updateRuntime schema.Manifest,
+	installedPackages, updatePackages pack.PackageService,
+) (updateEtcd bool, installedEtcdVersion string, updateEtcdVersion string, err error) {
 	// TODO: should somehow maintain etcd version invariant across runtime packages
-	runtimePackage, err := p.installedRuntime.Manifest.DefaultRuntimePackage()
+	runtimePackage, err := installedRuntime.DefaultRuntimePackage()
 	if err != nil && !trace.IsNotFound(err) {
 		return false, "", "", trace.Wrap(err)
 	}
 	if err != nil {
-		runtimePackage, err = p.installedRuntime.Manifest.Dependencies.ByName(loc.LegacyPlanetMaster.Name)
+		runtimePackage, err = installedRuntime.Dependencies.ByName(loc.LegacyPlanetMaster.Name)
 		if err != nil {
 			log.Warnf("Failed to fetch the runtime package: %v.", err)
 			return false, "", "", trace.NotFound("runtime package not found")
 		}
 	}
-	installedVersion, err := getEtcdVersion("version-etcd", *runtimePackage, p.packageService)
+	installedVersion, err := getEtcdVersion("version-etcd", *runtimePackage, installedPackages)
 	if err != nil {
 		if !trace.IsNotFound(err) {
 			return false, "", "", trace.Wrap(err)
@@ -627,11 +679,11 @@ func shouldUpdateEtcd(p planConfig) (updateEtcd bool, installedEtcdVersion strin
 		// if the currently installed version doesn't have etcd version information, it needs to be upgraded
 		updateEtcd = true
 	}
-	runtimePackage, err = p.updateRuntime.Manifest.DefaultRuntimePackage()
+	runtimePackage, err = updateRuntime.DefaultRuntimePackage()
 	if err != nil {
 		return false, "", "", trace.Wrap(err)
 	}
-	updateVersion, err := getEtcdVersion("version-etcd", *runtimePackage, p.packageService)
+	updateVersion, err := getEtcdVersion("version-etcd", *runtimePackage, updatePackages)
 	if err != nil {
 		return false, "", "", trace.Wrap(err)
 	}
@@ -24,6 +24,7 @@ import (
 	apptest "github.com/gravitational/gravity/lib/app/service/test"
 	"github.com/gravitational/gravity/lib/archive"
 	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/opsservice"
@@ -33,6 +34,7 @@ import (
 	"github.com/gravitational/gravity/lib/update"
 
 	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
 	"gopkg.in/check.v1"
 )
 
@@ -148,6 +150,28 @@ func (s *PlanSuite) TestPlanWithoutRuntimeUpdate(c *check.C) {
 	})
 }
 
+func (s *PlanSuite) TestCannotSkipMastersWithEtcdUpdate(c *check.C) {
+	params := params{
+		installedRuntime:         loc.MustParseLocator("gravitational.io/runtime:1.0.0"),
+		installedApp:             loc.MustParseLocator("gravitational.io/app:1.0.0"),
+		updateRuntime:            loc.MustParseLocator("gravitational.io/runtime:2.0.0"),
+		updateApp:                loc.MustParseLocator("gravitational.io/app:2.0.0"),
+		installedRuntimeManifest: installedRuntimeManifest,
+		installedAppManifest:     installedAppManifest,
+		updateRuntimeManifest:    updateRuntimeManifest,
+		updateAppManifest:        updateAppManifest,
+		dnsConfig:                storage.DefaultDNSConfig,
+		leadMaster:               updates[0],
+	}
+	config := newTestPlan(c, params)
+	config.servers = updates[:1]
+	config.plan.Servers = servers[:1]
+	config.plan.SkippedServers = servers[1:2]
+
+	_, err := newOperationPlan(config)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+}
+
 func (s *PlanSuite) TestUpdatesEtcdFromManifestWithoutLabels(c *check.C) {
 	services := opsservice.SetupTestServices(c)
 	files := []*archive.Item{
@@ -470,6 +494,7 @@ func (r *params) leaderMasterPhase() storage.OperationPhase {
 			{
 				ID:          t("/masters/%v/drain"),
 				Executor:    drainNode,
+				Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 				Description: t("Drain node %q"),
 				Data: &storage.OperationPhaseData{
 					Server:     &r.leadMaster.Server,
@@ -480,6 +505,7 @@ func (r *params) leaderMasterPhase() storage.OperationPhase {
 			{
 				ID:          t("/masters/%v/system-upgrade"),
 				Executor:    updateSystem,
+				Timeout:     update.Timeout(defaults.SystemUpgradePhaseTimeout),
 				Description: t("Update system software on node %q"),
 				Data: &storage.OperationPhaseData{
 					ExecServer: &r.leadMaster.Server,
@@ -515,6 +541,7 @@ func (r *params) otherMasterPhase(server storage.UpdateServer) storage.Operation
 			{
 				ID:          t("/masters/%v/drain"),
 				Executor:    drainNode,
+				Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 				Description: t("Drain node %q"),
 				Data: &storage.OperationPhaseData{
 					Server:     &server.Server,
@@ -524,6 +551,7 @@ func (r *params) otherMasterPhase(server storage.UpdateServer) storage.Operation
 			{
 				ID:          t("/masters/%v/system-upgrade"),
 				Executor:    updateSystem,
+				Timeout:     update.Timeout(defaults.SystemUpgradePhaseTimeout),
 				Description: t("Update system software on node %q"),
 				Data: &storage.OperationPhaseData{
 					ExecServer: &server.Server,
@@ -591,6 +619,7 @@ func (r *params) nodePhase(server storage.UpdateServer) storage.OperationPhase {
 			{
 				ID:          t("/nodes/%v/drain"),
 				Executor:    drainNode,
+				Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 				Description: t("Drain node %q"),
 				Data: &storage.OperationPhaseData{
 					Server:     &server.Server,
@@ -600,6 +629,7 @@ func (r *params) nodePhase(server storage.UpdateServer) storage.OperationPhase {
 			{
 				ID:          t("/nodes/%v/system-upgrade"),
 				Executor:    updateSystem,
+				Timeout:     update.Timeout(defaults.SystemUpgradePhaseTimeout),
 				Description: t("Update system software on node %q"),
 				Data: &storage.OperationPhaseData{
 					ExecServer: &server.Server,
@@ -672,6 +702,7 @@ func (r params) etcd(otherMasters []storage.UpdateServer) storage.OperationPhase
 				ID:          "/etcd/restore",
 				Description: "Restore etcd data from backup",
 				Executor:    updateEtcdRestore,
+				Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 				Data: &storage.OperationPhaseData{
 					Server: &r.leadMaster.Server,
 				},
@@ -701,6 +732,7 @@ func (r params) etcdBackupNode(server storage.UpdateServer) storage.OperationPha
 		ID:          t("/etcd/backup/%v"),
 		Description: t("Backup etcd on node %q"),
 		Executor:    updateEtcdBackup,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &server.Server,
 		},
@@ -715,6 +747,7 @@ func (r params) etcdShutdownNode(server storage.UpdateServer, isLeader bool) sto
 		ID:          t("/etcd/shutdown/%v"),
 		Description: t("Shutdown etcd on node %q"),
 		Executor:    updateEtcdShutdown,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Requires:    []string{t("/etcd/backup/%v")},
 		Data: &storage.OperationPhaseData{
 			Server: &server.Server,
@@ -731,6 +764,7 @@ func (r params) etcdShutdownWorkerNode(server storage.UpdateServer) storage.Oper
 		ID:          t("/etcd/shutdown/%v"),
 		Description: t("Shutdown etcd on node %q"),
 		Executor:    updateEtcdShutdown,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &server.Server,
 			Data:   "false",
@@ -746,6 +780,7 @@ func (r params) etcdUpgradeNode(server storage.UpdateServer) storage.OperationPh
 		ID:          t("/etcd/upgrade/%v"),
 		Description: t("Upgrade etcd on node %q"),
 		Executor:    updateEtcdMaster,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Requires:    []string{t("/etcd/shutdown/%v")},
 		Data: &storage.OperationPhaseData{
 			Server: &server.Server,
@@ -761,6 +796,7 @@ func (r params) etcdRestartLeaderNode() storage.OperationPhase {
 		ID:          t("/etcd/restart/%v"),
 		Description: t("Restart etcd on node %q"),
 		Executor:    updateEtcdRestart,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Requires:    []string{"/etcd/restore"},
 		Data: &storage.OperationPhaseData{
 			Server: &r.leadMaster.Server,
@@ -776,6 +812,7 @@ func (r params) etcdRestartNode(server storage.UpdateServer) storage.OperationPh
 		ID:          t("/etcd/restart/%v"),
 		Description: t("Restart etcd on node %q"),
 		Executor:    updateEtcdRestart,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Requires:    []string{t("/etcd/upgrade/%v")},
 		Data: &storage.OperationPhaseData{
 			Server: &server.Server,
@@ -788,6 +825,7 @@ func (r params) etcdRestartGravity() storage.OperationPhase {
 		ID:          fmt.Sprint("/etcd/restart/", constants.GravityServiceName),
 		Description: fmt.Sprint("Restart ", constants.GravityServiceName, " service"),
 		Executor:    updateEtcdRestartGravity,
+		Timeout:     update.Timeout(defaults.EtcdPhaseTimeout),
 		Data: &storage.OperationPhaseData{
 			Server: &r.leadMaster.Server,
 		},
@@ -0,0 +1,321 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// InitCatchUpOperationPlan initializes the plan for the operation that brings
+// the specified node, previously excluded from an upgrade, to the cluster's
+// current version
+func InitCatchUpOperationPlan(
+	ctx context.Context,
+	clusterEnv *localenv.ClusterEnvironment,
+	opKey ops.SiteOperationKey,
+	leader *storage.Server,
+	node string,
+) (*storage.OperationPlan, error) {
+	operation, err := storage.GetOperationByID(clusterEnv.Backend, opKey.OperationID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if operation.Type != ops.OperationUpdate {
+		return nil, trace.BadParameter("expected update operation but got %q", operation.Type)
+	}
+
+	plan, err := clusterEnv.Backend.GetOperationPlan(operation.SiteDomain, operation.ID)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+
+	if plan != nil {
+		return nil, trace.AlreadyExists("plan is already initialized")
+	}
+
+	cluster, err := clusterEnv.Operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	plan, err = NewCatchUpOperationPlan(CatchUpConfig{
+		PlanConfig: PlanConfig{
+			Backend:   clusterEnv.Backend,
+			Apps:      clusterEnv.Apps,
+			Packages:  clusterEnv.ClusterPackages,
+			Client:    clusterEnv.Client,
+			DNSConfig: cluster.DNSConfig,
+			Operator:  clusterEnv.Operator,
+			Operation: operation,
+			Leader:    leader,
+		},
+		Node: node,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	_, err = clusterEnv.Backend.CreateOperationPlan(*plan)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return plan, nil
+}
+
+// NewCatchUpOperationPlan generates a plan that updates the system software
+// on a single node to the version the rest of the cluster is running.
+//
+// The plan only contains the node-specific subset of the regular update plan:
+// the cluster runtime and application are already up-to-date and are not touched.
+func NewCatchUpOperationPlan(config CatchUpConfig) (*storage.OperationPlan, error) {
+	if err := config.checkAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	servers, err := storage.GetLocalServers(config.Backend)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	servers, err = checkAndSetServerDefaults(servers, config.Client.CoreV1().Nodes())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	server, err := FindCatchUpServer(servers, config.Node)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if server.AdvertiseIP == config.Leader.AdvertiseIP {
+		return nil, trace.BadParameter("cannot catch up node %v running the operation", server.Hostname)
+	}
+	leader, err := FindCatchUpServer(servers, config.Leader.AdvertiseIP)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	installedPackage, err := FindSkippedPackage(config.Backend, config.Operation.SiteDomain, *server)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	installedApp, err := config.Apps.GetApp(*installedPackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	installedRuntime, err := config.Apps.GetApp(*(installedApp.Manifest.Base()))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	updatePackage, err := config.Operation.Update.Package()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateApp, err := config.Apps.GetApp(*updatePackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateRuntime, err := config.Apps.GetApp(*(updateApp.Manifest.Base()))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	updates, err := configUpdates(
+		installedApp.Manifest, updateApp.Manifest,
+		config.Operator, (*ops.SiteOperation)(config.Operation).Key(),
+		[]storage.Server{*server})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	// The leader is already running the update version
+	leaderUpdates, err := configUpdates(
+		updateApp.Manifest, updateApp.Manifest,
+		config.Operator, (*ops.SiteOperation)(config.Operation).Key(),
+		[]storage.Server{*leader})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	leaderUpdate := leaderUpdates[0]
+	// Secrets are only rotated for the node being updated
+	leaderUpdate.Runtime.SecretsPackage = nil
+
+	gravityPackage, err := updateRuntime.Manifest.Dependencies.ByName(constants.GravityPackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return newCatchUpOperationPlan(planConfig{
+		plan: storage.OperationPlan{
+			OperationID:    config.Operation.ID,
+			OperationType:  config.Operation.Type,
+			AccountID:      config.Operation.AccountID,
+			ClusterName:    config.Operation.SiteDomain,
+			Servers:        []storage.Server{*leader, *server},
+			DNSConfig:      config.DNSConfig,
+			GravityPackage: *gravityPackage,
+		},
+		operator:         config.Operator,
+		operation:        *config.Operation,
+		servers:          updates,
+		installedRuntime: *installedRuntime,
+		installedApp:     *installedApp,
+		updateRuntime:    *updateRuntime,
+		updateApp:        *updateApp,
+		packageService:   config.Packages,
+		leadMaster:       leaderUpdate,
+	})
+}
+
+// CatchUpConfig defines the configuration for creating a catch-up operation plan
+type CatchUpConfig struct {
+	PlanConfig
+	// Node specifies the hostname or advertise IP of the node to catch up
+	Node string
+}
+
+func (r *CatchUpConfig) checkAndSetDefaults() error {
+	if err := r.PlanConfig.checkAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.Node == "" {
+		return trace.BadParameter("node to catch up is required")
+	}
+	return nil
+}
+
+func newCatchUpOperationPlan(p planConfig) (*storage.OperationPlan, error) {
+	if len(p.servers) != 1 {
+		return nil, trace.BadParameter("expected a single node to catch up but got %v", len(p.servers))
+	}
+	server := p.servers[0]
+	builder := phaseBuilder{planConfig: p}
+	initPhase := *builder.init(p.leadMaster.Server)
+	checksPhase := *builder.checks().Require(initPhase)
+	// The leader is bootstrapped as well so that it has the operation plan
+	// in its local backend, but only the node receives the system update
+	bootstrapBuilder := phaseBuilder{planConfig: p}
+	bootstrapBuilder.servers = []storage.UpdateServer{p.leadMaster, server}
+	bootstrapPhase := *bootstrapBuilder.bootstrap().Require(initPhase)
+
+	installedGravityPackage, err := p.installedRuntime.Manifest.Dependencies.ByName(
+		constants.GravityPackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	supportsTaints, err := supportsTaints(*installedGravityPackage)
+	if err != nil {
+		log.Warnf("Failed to query support for taints/tolerations in installed runtime: %v.",
+			trace.DebugReport(err))
+	}
+
+	var root update.Phase
+	root.Add(initPhase, checksPhase, bootstrapPhase)
+	nodePhase := *builder.catchUpNode(p.leadMaster, server, supportsTaints).
+		Require(checksPhase, bootstrapPhase)
+	root.Add(nodePhase)
+	if server.IsMaster() {
+		root.Add(*builder.config(serversToStorage(server)).Require(nodePhase))
+	}
+
+	root.AddSequential(*builder.cleanup())
+	plan := p.plan
+	plan.Phases = root.Phases
+	update.ResolvePlan(&plan)
+
+	return &plan, nil
+}
+
+// FindCatchUpServer returns the server from the given list matching
+// the specified hostname or advertise IP
+func FindCatchUpServer(servers []storage.Server, node string) (*storage.Server, error) {
+	for _, server := range servers {
+		if server.Hostname == node || server.AdvertiseIP == node {
+			server := server
+			return &server, nil
+		}
+	}
+	return nil, trace.NotFound("node %v is not part of the cluster", node)
+}
+
+// FindSkippedPackage returns the application package the specified server
+// was running when it was first excluded from an update operation.
+//
+// The update operations are inspected starting from the most recent one until
+// the one that has updated the server is found.
+// Returns trace.NotFound if the server is not behind the cluster
+func FindSkippedPackage(backend storage.Backend, clusterName string, server storage.Server) (*loc.Locator, error) {
+	operations, err := storage.GetOperationsForCluster(backend, clusterName)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var installed *loc.Locator
+	for _, operation := range operations {
+		if operation.Type != ops.OperationUpdate || operation.State != ops.OperationStateCompleted {
+			continue
+		}
+		plan, err := backend.GetOperationPlan(clusterName, operation.ID)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		if hasServer(plan.Servers, server) {
+			break
+		}
+		if !hasServer(plan.SkippedServers, server) {
+			continue
+		}
+		phase, err := fsm.FindPhase(plan, "/init")
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if phase.Data == nil || phase.Data.InstalledPackage == nil {
+			return nil, trace.NotFound("operation %v does not record the installed package",
+				operation.ID)
+		}
+		installed = phase.Data.InstalledPackage
+	}
+	if installed == nil {
+		return nil, trace.NotFound("node %v has not been excluded from any upgrade "+
+			"since it was last updated", server.Hostname)
+	}
+	return installed, nil
+}
+
+func hasServer(servers []storage.Server, server storage.Server) bool {
+	for _, s := range servers {
+		if s.AdvertiseIP == server.AdvertiseIP {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"time"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/opsservice"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type CatchUpSuite struct{}
+
+var _ = check.Suite(&CatchUpSuite{})
+
+func (s *CatchUpSuite) TestCatchUpPlanForNode(c *check.C) {
+	params := params{
+		installedRuntime:         loc.MustParseLocator("gravitational.io/runtime:1.0.0"),
+		installedApp:             loc.MustParseLocator("gravitational.io/app:1.0.0"),
+		updateRuntime:            loc.MustParseLocator("gravitational.io/runtime:2.0.0"),
+		updateApp:                loc.MustParseLocator("gravitational.io/app:2.0.0"),
+		installedRuntimeManifest: installedRuntimeManifest,
+		installedAppManifest:     installedAppManifest,
+		updateRuntimeManifest:    updateRuntimeManifest,
+		updateAppManifest:        updateAppManifest,
+		dnsConfig:                storage.DefaultDNSConfig,
+		leadMaster:               updates[0],
+	}
+	config := newTestPlan(c, params)
+	config.servers = updates[2:3]
+	config.plan.Servers = []storage.Server{servers[0], servers[2]}
+
+	obtainedPlan, err := newCatchUpOperationPlan(config)
+	c.Assert(err, check.IsNil)
+	update.ResolvePlan(obtainedPlan)
+
+	c.Assert(phaseIDs(obtainedPlan.Phases), check.DeepEquals, []string{
+		"/init", "/checks", "/bootstrap", "/nodes", "/gc",
+	})
+	c.Assert(obtainedPlan.Phases[0].Data.Update.Servers, check.DeepEquals, updates[2:3])
+	c.Assert(phaseIDs(obtainedPlan.Phases[2].Phases), check.DeepEquals, []string{
+		"/bootstrap/node-1", "/bootstrap/node-3",
+	})
+	nodePhase := params.nodePhase(updates[2])
+	c.Assert(obtainedPlan.Phases[3], check.DeepEquals, storage.OperationPhase{
+		ID:          "/nodes",
+		Description: `Catch up node "node-3"`,
+		Requires:    []string{"/checks", "/bootstrap"},
+		Phases:      []storage.OperationPhase{nodePhase},
+	})
+	c.Assert(phaseIDs(obtainedPlan.Phases[4].Phases), check.DeepEquals, []string{"/gc/node-3"})
+}
+
+func (s *CatchUpSuite) TestCatchUpPlanForMaster(c *check.C) {
+	params := params{
+		installedRuntime:         loc.MustParseLocator("gravitational.io/runtime:1.0.0"),
+		installedApp:             loc.MustParseLocator("gravitational.io/app:1.0.0"),
+		updateRuntime:            loc.MustParseLocator("gravitational.io/runtime:2.0.0"),
+		updateApp:                loc.MustParseLocator("gravitational.io/app:2.0.0"),
+		installedRuntimeManifest: installedRuntimeManifest,
+		installedAppManifest:     installedAppManifest,
+		updateRuntimeManifest:    updateRuntimeManifest,
+		updateAppManifest:        updateAppManifest,
+		dnsConfig:                storage.DefaultDNSConfig,
+		leadMaster:               updates[0],
+	}
+	config := newTestPlan(c, params)
+	config.servers = updates[1:2]
+	config.plan.Servers = servers[0:2]
+
+	obtainedPlan, err := newCatchUpOperationPlan(config)
+	c.Assert(err, check.IsNil)
+	update.ResolvePlan(obtainedPlan)
+
+	c.Assert(phaseIDs(obtainedPlan.Phases), check.DeepEquals, []string{
+		"/init", "/checks", "/bootstrap", "/masters", "/config", "/gc",
+	})
+	c.Assert(phaseIDs(obtainedPlan.Phases[3].Phases), check.DeepEquals, []string{"/masters/node-2"})
+	c.Assert(phaseIDs(obtainedPlan.Phases[4].Phases), check.DeepEquals, []string{"/config/node-2"})
+	c.Assert(obtainedPlan.Phases[4].Requires, check.DeepEquals, []string{"/masters"})
+}
+
+func (s *CatchUpSuite) TestFindsPackageOfFirstSkippedUpdate(c *check.C) {
+	services := opsservice.SetupTestServices(c)
+	backend := services.Backend
+	_, err := backend.CreateSite(storage.Site{
+		Domain:    clusterName,
+		AccountID: "account",
+		Created:   time.Now().UTC(),
+	})
+	c.Assert(err, check.IsNil)
+
+	app1 := loc.MustParseLocator("gravitational.io/app:1.0.0")
+	app2 := loc.MustParseLocator("gravitational.io/app:2.0.0")
+	app3 := loc.MustParseLocator("gravitational.io/app:3.0.0")
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	createUpdate := func(installed loc.Locator, state string, included, skipped []storage.Server) {
+		created = created.Add(time.Hour)
+		op, err := backend.CreateSiteOperation(storage.SiteOperation{
+			AccountID:  "account",
+			SiteDomain: clusterName,
+			Type:       ops.OperationUpdate,
+			Created:    created,
+			Updated:    created,
+			State:      state,
+		})
+		c.Assert(err, check.IsNil)
+		_, err = backend.CreateOperationPlan(storage.OperationPlan{
+			OperationID:    op.ID,
+			OperationType:  op.Type,
+			ClusterName:    clusterName,
+			Servers:        included,
+			SkippedServers: skipped,
+			Phases: []storage.OperationPhase{{
+				ID:   "/init",
+				Data: &storage.OperationPhaseData{InstalledPackage: &installed},
+			}},
+		})
+		c.Assert(err, check.IsNil)
+	}
+
+	_, err = FindSkippedPackage(backend, clusterName, servers[2])
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+
+	createUpdate(app1, ops.OperationStateCompleted, servers[0:2], servers[2:3])
+	createUpdate(app2, ops.OperationStateFailed, servers[0:2], servers[2:3])
+	createUpdate(app2, ops.OperationStateCompleted, servers[0:2], servers[2:3])
+
+	installed, err := FindSkippedPackage(backend, clusterName, servers[2])
+	c.Assert(err, check.IsNil)
+	c.Assert(*installed, check.DeepEquals, app1)
+
+	_, err = FindSkippedPackage(backend, clusterName, servers[1])
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+
+	// once the node has been updated, only the subsequent operations are considered
+	createUpdate(app3, ops.OperationStateCompleted, servers, nil)
+	_, err = FindSkippedPackage(backend, clusterName, servers[2])
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func phaseIDs(phases []storage.OperationPhase) (ids []string) {
+	for _, phase := range phases {
+		ids = append(ids, phase.ID)
+	}
+	return ids
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/rpc"
 	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/system"
 	"github.com/gravitational/gravity/lib/update"
 	"github.com/gravitational/gravity/lib/users"
 	"github.com/gravitational/gravity/lib/utils"
@@ -39,8 +40,12 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// New returns new updater for the specified configuration
+// New returns new updater for the specified configuration.
+// The resource usage of the process is throttled as configured for the operation
 func New(ctx context.Context, config Config) (*update.Updater, error) {
+	if err := throttle(config.Operation); err != nil {
+		logrus.WithError(err).Warn("Failed to throttle resource usage.")
+	}
 	machine, err := newMachine(ctx, config)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -52,6 +57,19 @@ func New(ctx context.Context, config Config) (*update.Updater, error) {
 	return updater, nil
 }
 
+// throttle throttles the resource usage of the process executing
+// the specified update operation
+func throttle(operation *ops.SiteOperation) error {
+	if operation == nil || operation.Update == nil {
+		return nil
+	}
+	level, err := system.ParseThrottleLevel(operation.Update.Throttle)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(system.Throttle(level))
+}
+
 // checkAndSetDefaults validates FSM config and sets defaults
 func (c *Config) checkAndSetDefaults() error {
 	if err := c.Config.CheckAndSetDefaults(); err != nil {
@@ -287,6 +305,8 @@ func (f *engine) ChangePhaseState(ctx context.Context, change fsm.StateChange) e
 		PhaseID:     change.Phase,
 		NewState:    change.State,
 		Error:       utils.ToRawTrace(change.Error),
+		Transient:   change.IsTransient(),
+		Logs:        change.Logs,
 		Created:     time.Now().UTC(),
 	})
 	if err != nil {
@@ -41,6 +41,8 @@ const (
 	coredns = "coredns"
 	// updateApp is the phase to update the application
 	updateApp = "update_app"
+	// networkPolicy is the phase to update the baseline network policies
+	networkPolicy = "network_policy"
 	// electionStatus is the phase to control node leader elections
 	electionStatus = "election_status"
 	// taintNode is the phase to taint a node
@@ -122,6 +124,8 @@ func fsmSpec(c Config) fsm.FSMSpecFunc {
 			return libphase.NewUpdatePhaseBeforeApp(p, c.Apps, c.Client, logger)
 		case updateApp:
 			return libphase.NewUpdatePhaseApp(p, c.Operator, c.Apps, c.Client, logger)
+		case networkPolicy:
+			return libphase.NewPhaseNetworkPolicy(p, c.Operator, c.Apps, c.Client, logger)
 		case electionStatus:
 			return libphase.NewPhaseElectionChange(p, c.Operator, remote, logger)
 		case taintNode:
@@ -0,0 +1,298 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExportedPlan is an upgrade operation plan exported for offline review.
+//
+// The plan is accompanied by the state of the cluster it has been generated
+// for so it can only be imported into a cluster in the same state.
+// The plan references the nodes of the cluster so it is specific
+// to the cluster it has been generated for
+type ExportedPlan struct {
+	// Version is the format version
+	Version string `json:"version"`
+	// State is the cluster state the plan has been generated for
+	State PlanState `json:"state"`
+	// StateHash is the hash of the cluster state
+	StateHash string `json:"state_hash"`
+	// PlanHash is the hash of the cluster state and the plan
+	// the reviewers approve, see Hash
+	PlanHash string `json:"plan_hash"`
+	// Plan is the exported operation plan
+	Plan storage.OperationPlan `json:"plan"`
+}
+
+// Hash returns the hash of the cluster state and the plan.
+// The fields that identify the operation the plan has been exported from
+// are replaced on import and are not part of the hash
+func (r ExportedPlan) Hash() (string, error) {
+	plan := r.Plan
+	plan.OperationID = ""
+	plan.AccountID = ""
+	plan.ClusterName = ""
+	plan.CreatedAt = time.Time{}
+	data, err := json.Marshal(struct {
+		State PlanState             `json:"state"`
+		Plan  storage.OperationPlan `json:"plan"`
+	}{
+		State: r.State,
+		Plan:  plan,
+	})
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// PlanState describes the cluster state an upgrade plan depends on
+type PlanState struct {
+	// InstalledPackage is the cluster image installed in the cluster
+	InstalledPackage loc.Locator `json:"installed_package"`
+	// UpdatePackage is the cluster image to upgrade to
+	UpdatePackage loc.Locator `json:"update_package"`
+	// Servers lists the cluster nodes sorted by hostname
+	Servers []PlanServer `json:"servers"`
+}
+
+// PlanServer describes a cluster node an upgrade plan depends on
+type PlanServer struct {
+	// Hostname is the node hostname
+	Hostname string `json:"hostname"`
+	// AdvertiseIP is the node advertise IP address
+	AdvertiseIP string `json:"advertise_ip"`
+	// Role is the node application role
+	Role string `json:"role"`
+	// ClusterRole is the node system role, master or node
+	ClusterRole string `json:"cluster_role"`
+}
+
+// NewPlanState returns the state of the specified cluster for the upgrade
+// to the specified cluster image
+func NewPlanState(cluster ops.Site, updatePackage loc.Locator) PlanState {
+	state := PlanState{
+		InstalledPackage: cluster.App.Package,
+		UpdatePackage:    updatePackage,
+	}
+	for _, server := range cluster.ClusterState.Servers {
+		state.Servers = append(state.Servers, PlanServer{
+			Hostname:    server.Hostname,
+			AdvertiseIP: server.AdvertiseIP,
+			Role:        server.Role,
+			ClusterRole: server.ClusterRole,
+		})
+	}
+	sort.Slice(state.Servers, func(i, j int) bool {
+		return state.Servers[i].Hostname < state.Servers[j].Hostname
+	})
+	return state
+}
+
+// Hash returns the hash of this cluster state
+func (r PlanState) Hash() string {
+	data, err := json.Marshal(r)
+	if err != nil {
+		// Marshaling a struct of strings does not fail
+		panic(err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Diff returns the human-readable list of differences between this
+// cluster state and the specified state
+func (r PlanState) Diff(other PlanState) (diff []string) {
+	if !r.InstalledPackage.IsEqualTo(other.InstalledPackage) {
+		diff = append(diff, fmt.Sprintf("installed cluster image: %v, expected %v",
+			other.InstalledPackage, r.InstalledPackage))
+	}
+	if !r.UpdatePackage.IsEqualTo(other.UpdatePackage) {
+		diff = append(diff, fmt.Sprintf("upgrade cluster image: %v, expected %v",
+			other.UpdatePackage, r.UpdatePackage))
+	}
+	servers := make(map[string]PlanServer)
+	for _, server := range other.Servers {
+		servers[server.Hostname] = server
+	}
+	for _, server := range r.Servers {
+		otherServer, ok := servers[server.Hostname]
+		if !ok {
+			diff = append(diff, fmt.Sprintf("node %v is missing", server.Hostname))
+			continue
+		}
+		delete(servers, server.Hostname)
+		if server != otherServer {
+			diff = append(diff, fmt.Sprintf("node %v: %v/%v/%v, expected %v/%v/%v",
+				server.Hostname, otherServer.AdvertiseIP, otherServer.Role, otherServer.ClusterRole,
+				server.AdvertiseIP, server.Role, server.ClusterRole))
+		}
+	}
+	for _, server := range other.Servers {
+		if _, ok := servers[server.Hostname]; ok {
+			diff = append(diff, fmt.Sprintf("node %v is not in the plan", server.Hostname))
+		}
+	}
+	return diff
+}
+
+// ExportOperationPlan returns the plan of the specified upgrade operation
+// along with the state of the cluster it has been generated for.
+// Only plans that have not been started can be exported
+func ExportOperationPlan(cluster ops.Site, operation ops.SiteOperation, plan storage.OperationPlan) (*ExportedPlan, error) {
+	if operation.Type != ops.OperationUpdate {
+		return nil, trace.BadParameter("only upgrade operation plans can be exported, operation %v is %v",
+			operation.ID, operation.TypeString())
+	}
+	for _, phase := range fsm.FlattenPlan(&plan) {
+		if !phase.IsUnstarted() {
+			return nil, trace.BadParameter("plan of operation %v has already been started, "+
+				"only plans of operations created in manual mode that have not been started can be exported",
+				operation.ID)
+		}
+	}
+	updatePackage, err := updatePackageFor(operation)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	state := NewPlanState(cluster, *updatePackage)
+	exported := &ExportedPlan{
+		Version:   ExportedPlanVersion,
+		State:     state,
+		StateHash: state.Hash(),
+		Plan:      plan,
+	}
+	exported.PlanHash, err = exported.Hash()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return exported, nil
+}
+
+// CheckExportedPlan verifies that the exported plan is the plan that
+// has been reviewed, i.e. has the specified hash, and that it can be
+// imported into the cluster in the specified state
+func CheckExportedPlan(exported ExportedPlan, reviewedHash string, state PlanState) error {
+	if exported.Version != ExportedPlanVersion {
+		return trace.BadParameter("unsupported exported plan version %q, expected %q",
+			exported.Version, ExportedPlanVersion)
+	}
+	if exported.State.Hash() != exported.StateHash {
+		return trace.BadParameter("exported plan is corrupted: cluster state does not match its hash")
+	}
+	hash, err := exported.Hash()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if hash != exported.PlanHash {
+		return trace.BadParameter("exported plan is corrupted: plan does not match its hash")
+	}
+	if reviewedHash == "" {
+		return trace.BadParameter("the hash of the reviewed plan is required to import it")
+	}
+	if reviewedHash != hash {
+		return trace.BadParameter("exported plan has hash %v which does not match the reviewed hash %v",
+			hash, reviewedHash)
+	}
+	if exported.Plan.OperationType != ops.OperationUpdate {
+		return trace.BadParameter("expected upgrade operation plan but got %q", exported.Plan.OperationType)
+	}
+	if state.Hash() == exported.StateHash {
+		return nil
+	}
+	return trace.CompareFailed("cluster state has changed since the plan has been generated:\n  %v",
+		strings.Join(exported.State.Diff(state), "\n  "))
+}
+
+// InitExportedOperationPlan initializes the plan of the specified upgrade
+// operation with the previously exported plan.
+// The exported plan is verified against the reviewed hash and the current cluster state
+func InitExportedOperationPlan(
+	clusterEnv *localenv.ClusterEnvironment,
+	opKey ops.SiteOperationKey,
+	exported ExportedPlan,
+	reviewedHash string,
+) (*storage.OperationPlan, error) {
+	operation, err := storage.GetOperationByID(clusterEnv.Backend, opKey.OperationID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if operation.Type != ops.OperationUpdate {
+		return nil, trace.BadParameter("expected update operation but got %q", operation.Type)
+	}
+	_, err = clusterEnv.Backend.GetOperationPlan(operation.SiteDomain, operation.ID)
+	if err == nil {
+		return nil, trace.AlreadyExists("plan is already initialized")
+	}
+	if !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := clusterEnv.Operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updatePackage, err := updatePackageFor((ops.SiteOperation)(*operation))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := CheckExportedPlan(exported, reviewedHash, NewPlanState(*cluster, *updatePackage)); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plan := exported.Plan
+	plan.OperationID = operation.ID
+	plan.AccountID = operation.AccountID
+	plan.ClusterName = operation.SiteDomain
+	plan.CreatedAt = time.Now().UTC()
+	log.WithField("operation", operation.ID).Infof("Importing plan %v exported for cluster state %v.", exported.PlanHash, exported.StateHash)
+	_, err = clusterEnv.Backend.CreateOperationPlan(plan)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &plan, nil
+}
+
+func updatePackageFor(operation ops.SiteOperation) (*loc.Locator, error) {
+	if operation.Update == nil {
+		return nil, trace.BadParameter("operation %v does not reference the update package", operation.ID)
+	}
+	updatePackage, err := loc.ParseLocator(operation.Update.UpdatePackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return updatePackage, nil
+}
+
+// ExportedPlanVersion is the current version of the exported plan format
+const ExportedPlanVersion = "v1"
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type ExportSuite struct{}
+
+var _ = check.Suite(&ExportSuite{})
+
+func (s *ExportSuite) TestExportsAndChecksPlan(c *check.C) {
+	cluster := newExportCluster(
+		storage.Server{Hostname: "node-2", AdvertiseIP: "10.0.0.2", Role: "node", ClusterRole: "node"},
+		storage.Server{Hostname: "node-1", AdvertiseIP: "10.0.0.1", Role: "master", ClusterRole: "master"},
+	)
+	exported, err := ExportOperationPlan(cluster, newExportOperation(), newExportPlan())
+	c.Assert(err, check.IsNil)
+	c.Assert(exported.State.Servers[0].Hostname, check.Equals, "node-1")
+
+	// Make sure the plan survives the serialization
+	data, err := json.Marshal(exported)
+	c.Assert(err, check.IsNil)
+	var imported ExportedPlan
+	c.Assert(json.Unmarshal(data, &imported), check.IsNil)
+
+	state := NewPlanState(cluster, loc.MustParseLocator("gravitational.io/app:2.0.0"))
+	c.Assert(CheckExportedPlan(imported, exported.PlanHash, state), check.IsNil)
+
+	cluster.ClusterState.Servers[0].AdvertiseIP = "10.0.0.3"
+	cluster.ClusterState.Servers = append(cluster.ClusterState.Servers,
+		storage.Server{Hostname: "node-3", AdvertiseIP: "10.0.0.4", Role: "node", ClusterRole: "node"})
+	state = NewPlanState(cluster, loc.MustParseLocator("gravitational.io/app:2.0.1"))
+	err = CheckExportedPlan(imported, exported.PlanHash, state)
+	c.Assert(trace.IsCompareFailed(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(imported.State.Diff(state), check.DeepEquals, []string{
+		"upgrade cluster image: gravitational.io/app:2.0.1, expected gravitational.io/app:2.0.0",
+		"node node-2: 10.0.0.3/node/node, expected 10.0.0.2/node/node",
+		"node node-3 is not in the plan",
+	})
+}
+
+func (s *ExportSuite) TestRejectsStartedPlan(c *check.C) {
+	plan := newExportPlan()
+	plan.Phases[0].State = storage.OperationPhaseStateCompleted
+	_, err := ExportOperationPlan(newExportCluster(), newExportOperation(), plan)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
+
+func (s *ExportSuite) TestRejectsModifiedState(c *check.C) {
+	cluster := newExportCluster(storage.Server{Hostname: "node-1", AdvertiseIP: "10.0.0.1"})
+	exported, err := ExportOperationPlan(cluster, newExportOperation(), newExportPlan())
+	c.Assert(err, check.IsNil)
+	exported.State.Servers[0].AdvertiseIP = "10.0.0.2"
+	err = CheckExportedPlan(*exported, exported.PlanHash, exported.State)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
+
+func (s *ExportSuite) TestRequiresReviewedPlanHash(c *check.C) {
+	cluster := newExportCluster(storage.Server{Hostname: "node-1", AdvertiseIP: "10.0.0.1"})
+	exported, err := ExportOperationPlan(cluster, newExportOperation(), newExportPlan())
+	c.Assert(err, check.IsNil)
+	state := exported.State
+
+	// The hash does not depend on the operation the plan has been exported from
+	plan := newExportPlan()
+	plan.OperationID = "op-2"
+	plan.ClusterName = "other.example.com"
+	other, err := ExportOperationPlan(cluster, newExportOperation(), plan)
+	c.Assert(err, check.IsNil)
+	c.Assert(other.PlanHash, check.Equals, exported.PlanHash)
+
+	err = CheckExportedPlan(*exported, "", state)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+
+	err = CheckExportedPlan(*exported, "deadbeef", state)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+
+	// Modifying the plan after the review is detected
+	modified := *exported
+	modified.Plan.Phases = modified.Plan.Phases[:1]
+	err = CheckExportedPlan(modified, exported.PlanHash, state)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+
+	// Even if the hash in the file is updated as well
+	modified.PlanHash, err = modified.Hash()
+	c.Assert(err, check.IsNil)
+	err = CheckExportedPlan(modified, exported.PlanHash, state)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+}
+
+func newExportCluster(servers ...storage.Server) ops.Site {
+	return ops.Site{
+		App:          ops.Application{Package: loc.MustParseLocator("gravitational.io/app:1.0.0")},
+		ClusterState: storage.ClusterState{Servers: servers},
+	}
+}
+
+func newExportOperation() ops.SiteOperation {
+	return ops.SiteOperation{
+		ID:   "op-1",
+		Type: ops.OperationUpdate,
+		Update: &storage.UpdateOperationState{
+			UpdatePackage: "gravitational.io/app:2.0.0",
+		},
+	}
+}
+
+func newExportPlan() storage.OperationPlan {
+	return storage.OperationPlan{
+		OperationID:   "op-1",
+		OperationType: ops.OperationUpdate,
+		ClusterName:   "example.com",
+		Phases: []storage.OperationPhase{
+			{ID: "/init", State: storage.OperationPhaseStateUnstarted},
+			{ID: "/masters", Phases: []storage.OperationPhase{
+				{ID: "/masters/node-1"},
+			}},
+		},
+	}
+}
@@ -30,7 +30,6 @@ import (
 	"github.com/gravitational/rigging"
 	"github.com/gravitational/trace"
 	log "github.com/sirupsen/logrus"
-	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -138,30 +137,48 @@ func (p *updatePhaseCoreDNS) generateCorefile(context.Context) error {
 		return trace.Wrap(err)
 	}
 
-	conf, err := libinstall.GenerateCorefile(libinstall.CorednsConfig{
-		UpstreamNameservers: resolvConf.Servers,
-		Rotate:              resolvConf.Rotate,
-		Hosts:               p.DNSOverrides.Hosts,
-		Zones:               p.DNSOverrides.Zones,
-	})
+	dns, err := p.getClusterDNS()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	provider, err := libinstall.NewDNSProvider(dns.GetProvider())
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	p.Debug("Generated corefile: ", conf)
 
-	_, err = p.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).Create(&v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "coredns",
-			Namespace: constants.KubeSystemNamespace,
-		},
-		Data: map[string]string{
-			"Corefile": conf,
-		},
-	})
-	err = trace.ConvertSystemError(err)
-	if err != nil && !trace.IsAlreadyExists(err) {
+	configMaps, err := provider.Render(libinstall.NewCorednsConfig(
+		dns, p.DNSOverrides, resolvConf.Servers, resolvConf.Rotate))
+	if err != nil {
 		return trace.Wrap(err)
 	}
 
+	for _, configMap := range configMaps {
+		p.Debugf("Generated %v: %v", configMap.Name, configMap.Data)
+		_, err = p.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).Create(&configMap)
+		err = trace.ConvertSystemError(err)
+		if err != nil && !trace.IsAlreadyExists(err) {
+			return trace.Wrap(err)
+		}
+	}
+
 	return nil
 }
+
+// getClusterDNS returns the cluster DNS configuration specified during installation
+// or the default configuration
+func (p *updatePhaseCoreDNS) getClusterDNS() (storage.ClusterDNS, error) {
+	configMap, err := p.Client.CoreV1().ConfigMaps(constants.KubeSystemNamespace).
+		Get(constants.ClusterDNSMap, metav1.GetOptions{})
+	err = rigging.ConvertError(err)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return storage.DefaultClusterDNS(), nil
+		}
+		return nil, trace.Wrap(err)
+	}
+	dns, err := libinstall.ClusterDNSFromConfigMap(*configMap)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return dns, nil
+}
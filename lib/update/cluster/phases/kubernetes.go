@@ -22,6 +22,7 @@ import (
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/update"
@@ -105,6 +106,8 @@ func (p *phaseUntaint) Rollback(context.Context) error {
 // phaseDrain defines the operation of draining a node
 type phaseDrain struct {
 	kubernetesOperation
+	// dnsConfig specifies the cluster DNS configuration
+	dnsConfig storage.DNSConfig
 }
 
 // NewPhaseDrain returns a new executor for draining a node
@@ -115,15 +118,21 @@ func NewPhaseDrain(p fsm.ExecutorParams, client *kubeapi.Clientset, logger log.F
 	}
 	return &phaseDrain{
 		kubernetesOperation: *op,
+		dnsConfig:           p.Plan.DNSConfig,
 	}, nil
 }
 
 // Execute drains the specified node
 func (p *phaseDrain) Execute(ctx context.Context) error {
 	p.Infof("Drain %v.", p.Server)
+	kubernetes.CheckHeadroom(p.Client, p.Server.KubeNodeID(), p.FieldLogger)
 	ctx, cancel := context.WithTimeout(ctx, defaults.DrainTimeout)
 	defer cancel()
-	err := update.Retry(ctx, func() error {
+	err := runPreDrainHooks(ctx, p.Client, p.dnsConfig, p.Server.KubeNodeID())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = update.Retry(ctx, func() error {
 		return trace.Wrap(drain(ctx, p.Client, p.Server.KubeNodeID()))
 	}, defaults.DrainErrorTimeout)
 	return trace.Wrap(err)
@@ -288,6 +297,17 @@ func taint(ctx context.Context, client corev1.NodeInterface, node string, add ad
 	return nil
 }
 
+// runPreDrainHooks executes the pre-drain commands of the pods on the specified node
+// before the node is drained
+func runPreDrainHooks(ctx context.Context, client *kubeapi.Clientset, dnsConfig storage.DNSConfig, node string) error {
+	_, config, err := httplib.GetClusterKubeClient(dnsConfig.Addr())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = kubernetes.RunPreDrainHooks(ctx, client, kubernetes.NewPodExecutor(client, config), node)
+	return trace.Wrap(err)
+}
+
 func drain(ctx context.Context, client *kubeapi.Clientset, node string) error {
 	err := kubernetes.Drain(ctx, client, node)
 	return trace.Wrap(err)
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	kubeapi "k8s.io/client-go/kubernetes"
+)
+
+// phaseNetworkPolicy defines the operation of updating the baseline network policies
+type phaseNetworkPolicy struct {
+	kubernetesOperation
+	// policy specifies the baseline network policy configuration of the update application
+	policy schema.NetworkPolicy
+	// installedPolicy specifies the baseline network policy configuration
+	// of the installed application or nil, if the policies were not enabled
+	installedPolicy *schema.NetworkPolicy
+}
+
+// NewPhaseNetworkPolicy returns a new executor for updating the baseline network policies
+func NewPhaseNetworkPolicy(
+	p fsm.ExecutorParams,
+	operator ops.Operator,
+	apps app.Applications,
+	client *kubeapi.Clientset,
+	logger log.FieldLogger,
+) (*phaseNetworkPolicy, error) {
+	if p.Phase.Data == nil || p.Phase.Data.Package == nil {
+		return nil, trace.NotFound("no application package specified for phase %q", p.Phase.ID)
+	}
+	op, err := newKubernetesOperation(p, client, logger)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	update, err := apps.GetApp(*p.Phase.Data.Package)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	policy := kubernetes.GetNetworkPolicy(update.Manifest, cluster.DefaultDenyNetworkPolicy)
+	if policy == nil {
+		return nil, trace.NotFound("network policies are not enabled for %v", update.Package)
+	}
+	return &phaseNetworkPolicy{
+		kubernetesOperation: *op,
+		policy:              *policy,
+		installedPolicy:     kubernetes.GetNetworkPolicy(cluster.App.Manifest, cluster.DefaultDenyNetworkPolicy),
+	}, nil
+}
+
+// Execute creates or updates the baseline network policies
+func (p *phaseNetworkPolicy) Execute(context.Context) error {
+	p.Info("Update baseline network policies.")
+	return trace.Wrap(kubernetes.ApplyBaselineNetworkPolicies(p.Client, p.policy))
+}
+
+// Rollback removes the baseline network policies if they have not been
+// enabled for the installed application
+func (p *phaseNetworkPolicy) Rollback(context.Context) error {
+	if p.installedPolicy != nil {
+		return nil
+	}
+	p.Info("Remove baseline network policies.")
+	return trace.Wrap(kubernetes.RemoveBaselineNetworkPolicies(p.Client, p.policy))
+}
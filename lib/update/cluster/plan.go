@@ -28,6 +28,8 @@ import (
 	"github.com/gravitational/gravity/lib/archive"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/fsm"
+	libkubernetes "github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/ops"
@@ -47,13 +49,16 @@ import (
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
-// InitOperationPlan will initialize operation plan for an operation
+// InitOperationPlan will initialize operation plan for an operation.
+// skipNodes optionally lists hostnames or advertise IPs of nodes to exclude from the operation.
+// pauseAfter optionally lists IDs of the phases to pause the operation after
 func InitOperationPlan(
 	ctx context.Context,
 	localEnv, updateEnv *localenv.LocalEnvironment,
 	clusterEnv *localenv.ClusterEnvironment,
 	opKey ops.SiteOperationKey,
 	leader *storage.Server,
+	skipNodes, pauseAfter []string,
 ) (*storage.OperationPlan, error) {
 	operation, err := storage.GetOperationByID(clusterEnv.Backend, opKey.OperationID)
 	if err != nil {
@@ -89,14 +94,19 @@ func InitOperationPlan(
 	}
 
 	plan, err = NewOperationPlan(PlanConfig{
-		Backend:   clusterEnv.Backend,
-		Apps:      clusterEnv.Apps,
-		Packages:  clusterEnv.ClusterPackages,
-		Client:    clusterEnv.Client,
-		DNSConfig: dnsConfig,
-		Operator:  clusterEnv.Operator,
-		Operation: operation,
-		Leader:    leader,
+		Backend:    clusterEnv.Backend,
+		Apps:       clusterEnv.Apps,
+		Packages:   clusterEnv.ClusterPackages,
+		Client:     clusterEnv.Client,
+		DNSConfig:  dnsConfig,
+		Operator:   clusterEnv.Operator,
+		Operation:  operation,
+		Leader:     leader,
+		SkipNodes:  skipNodes,
+		PauseAfter: pauseAfter,
+
+		DisabledComponents:       cluster.DisabledComponents,
+		DefaultDenyNetworkPolicy: cluster.DefaultDenyNetworkPolicy,
 	})
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -126,6 +136,16 @@ func NewOperationPlan(config PlanConfig) (*storage.OperationPlan, error) {
 		return nil, trace.Wrap(err)
 	}
 
+	servers, skipped, err := update.SkipServers(servers, config.SkipNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, server := range skipped {
+		if server.AdvertiseIP == config.Leader.AdvertiseIP {
+			return nil, trace.BadParameter("cannot exclude node %v running the operation", server.Hostname)
+		}
+	}
+
 	updateCoreDNS, err := shouldUpdateCoreDNS(config.Client)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -200,6 +220,7 @@ func NewOperationPlan(config PlanConfig) (*storage.OperationPlan, error) {
 			AccountID:      config.Operation.AccountID,
 			ClusterName:    config.Operation.SiteDomain,
 			Servers:        servers,
+			SkippedServers: skipped,
 			DNSConfig:      config.DNSConfig,
 			GravityPackage: *gravityPackage,
 		},
@@ -218,11 +239,18 @@ func NewOperationPlan(config PlanConfig) (*storage.OperationPlan, error) {
 		updateDNSAppEarly: updateDNSAppEarly,
 		roles:             roles,
 		leadMaster:        *leader,
+
+		disabledComponents: config.DisabledComponents,
+		networkPolicy:      libkubernetes.GetNetworkPolicy(updateApp.Manifest, config.DefaultDenyNetworkPolicy),
 	})
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	if err := fsm.SetPausePoints(plan, config.PauseAfter); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	return plan, nil
 }
 
@@ -261,6 +289,15 @@ type PlanConfig struct {
 	Operation *storage.SiteOperation
 	Client    *kubernetes.Clientset
 	Leader    *storage.Server
+	// SkipNodes lists hostnames or advertise IPs of nodes to exclude from the operation
+	SkipNodes []string
+	// PauseAfter lists IDs of the phases to pause the operation after
+	PauseAfter []string
+	// DisabledComponents lists the application components excluded from the cluster
+	DisabledComponents []string
+	// DefaultDenyNetworkPolicy specifies whether the baseline network policies
+	// have been requested during installation
+	DefaultDenyNetworkPolicy bool
 }
 
 // planConfig collects parameters needed to generate an update operation plan
@@ -298,6 +335,11 @@ type planConfig struct {
 	roles []teleservices.Role
 	// leader refers to the master server running the update operation
 	leadMaster storage.UpdateServer
+	// disabledComponents lists the application components excluded from the cluster
+	disabledComponents []string
+	// networkPolicy specifies the baseline network policy configuration
+	// of the update application or nil, if the policies are not enabled
+	networkPolicy *schema.NetworkPolicy
 }
 
 func newOperationPlan(p planConfig) (*storage.OperationPlan, error) {
@@ -339,21 +381,34 @@ func newOperationPlan(p planConfig) (*storage.OperationPlan, error) {
 	// some system apps may need to be skipped depending on the manifest settings
 	runtimeUpdates := allRuntimeUpdates[:0]
 	for _, locator := range allRuntimeUpdates {
-		if !schema.ShouldSkipApp(p.updateApp.Manifest, locator) {
+		if !schema.ShouldSkipApp(p.updateApp.Manifest, locator, p.disabledComponents...) {
 			runtimeUpdates = append(runtimeUpdates, locator)
 		}
 	}
 
-	appUpdates, err := app.GetUpdatedDependencies(p.installedApp, p.updateApp)
+	allAppUpdates, err := app.GetUpdatedDependencies(p.installedApp, p.updateApp)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	// skip applications of the components excluded from the cluster
+	skipApps := p.updateApp.Manifest.Components.Apps(p.disabledComponents)
+	appUpdates := allAppUpdates[:0]
+	for _, locator := range allAppUpdates {
+		if !utils.StringInSlice(skipApps, locator.Name) {
+			appUpdates = append(appUpdates, locator)
+		}
+	}
+
 	// check if etcd upgrade is required or not
 	updateEtcd, currentVersion, desiredVersion, err := p.shouldUpdateEtcd(p)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	if updateEtcd && len(storage.Servers(p.plan.SkippedServers).Masters()) != 0 {
+		return nil, trace.BadParameter("etcd upgrade from %v to %v requires all master nodes, "+
+			"cannot exclude master nodes from the operation", currentVersion, desiredVersion)
+	}
 
 	var root update.Phase
 	root.Add(initPhase, checksPhase, preUpdatePhase)
@@ -404,6 +459,9 @@ func newOperationPlan(p planConfig) (*storage.OperationPlan, error) {
 		root.Add(configPhase, runtimePhase)
 	}
 
+	if p.networkPolicy != nil {
+		root.AddSequential(*builder.networkPolicy(p.leadMaster.Server))
+	}
 	root.AddSequential(*builder.app(appUpdates), *builder.cleanup())
 	plan := p.plan
 	plan.Phases = root.Phases
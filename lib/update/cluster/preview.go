@@ -0,0 +1,588 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/app/resources"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	libkubernetes "github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+	libphase "github.com/gravitational/gravity/lib/update/cluster/phases"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PreviewConfig defines the configuration to estimate the impact
+// of upgrading the cluster to a new cluster image
+type PreviewConfig struct {
+	// Backend is the cluster backend
+	Backend storage.Backend
+	// Apps is the cluster application service
+	Apps app.Applications
+	// Packages is the cluster package service
+	Packages pack.PackageService
+	// UpdateApps is the application service with the update cluster image
+	UpdateApps app.Applications
+	// UpdatePackages is the package service with the update cluster image
+	UpdatePackages pack.PackageService
+	// UpdatePackage specifies the cluster image to upgrade to
+	UpdatePackage loc.Locator
+	// Client is the optional Kubernetes client used to find out
+	// which workloads will be disrupted by the upgrade
+	Client *kubernetes.Clientset
+}
+
+// CheckAndSetDefaults validates this configuration and sets defaults
+func (r *PreviewConfig) CheckAndSetDefaults() error {
+	if r.Backend == nil {
+		return trace.BadParameter("cluster backend is required")
+	}
+	if r.Apps == nil {
+		return trace.BadParameter("cluster application service is required")
+	}
+	if r.Packages == nil {
+		return trace.BadParameter("cluster package service is required")
+	}
+	if r.UpdateApps == nil {
+		r.UpdateApps = r.Apps
+	}
+	if r.UpdatePackages == nil {
+		r.UpdatePackages = r.Packages
+	}
+	return nil
+}
+
+// Preview describes the impact of upgrading the cluster to a new cluster image.
+//
+// The preview is computed without creating the operation or its plan
+type Preview struct {
+	// InstalledPackage is the installed cluster image
+	InstalledPackage loc.Locator `json:"installed_package"`
+	// UpdatePackage is the cluster image to upgrade to
+	UpdatePackage loc.Locator `json:"update_package"`
+	// Runtime describes the Kubernetes runtime (planet) version change
+	Runtime *VersionChange `json:"runtime,omitempty"`
+	// Teleport describes the teleport version change
+	Teleport *VersionChange `json:"teleport,omitempty"`
+	// Etcd describes the etcd version change
+	Etcd *VersionChange `json:"etcd,omitempty"`
+	// Apps lists the applications that will be updated
+	Apps []PackageChange `json:"apps,omitempty"`
+	// Images lists the changed container images
+	Images []PackageChange `json:"images,omitempty"`
+	// Migrations lists the configuration and data migrations
+	// performed by the upgrade
+	Migrations []string `json:"migrations,omitempty"`
+	// Nodes lists the impact of the upgrade on individual nodes
+	Nodes []NodeImpact `json:"nodes,omitempty"`
+	// Workloads lists the workloads running on the nodes that will be drained
+	Workloads []WorkloadImpact `json:"workloads,omitempty"`
+	// Headroom describes whether the remaining nodes can host the pods
+	// evicted from each of the nodes that will be drained
+	Headroom []libkubernetes.Headroom `json:"headroom,omitempty"`
+}
+
+// CapacityProblems returns the reasons the pods evicted from the drained
+// nodes cannot be rescheduled on the remaining nodes
+func (r Preview) CapacityProblems() []string {
+	return libkubernetes.HeadroomProblems(r.Headroom...)
+}
+
+// DisruptedWorkloads returns the workloads that are expected to become
+// unavailable during the upgrade
+func (r Preview) DisruptedWorkloads() (result []WorkloadImpact) {
+	for _, workload := range r.Workloads {
+		if workload.Disrupted {
+			result = append(result, workload)
+		}
+	}
+	return result
+}
+
+// VersionChange describes a version change of a cluster component
+type VersionChange struct {
+	// From is the installed version
+	From string `json:"from,omitempty"`
+	// To is the update version
+	To string `json:"to"`
+}
+
+// String returns a textual representation of this version change
+func (r VersionChange) String() string {
+	if r.From == "" {
+		return fmt.Sprintf("(new) -> %v", r.To)
+	}
+	return fmt.Sprintf("%v -> %v", r.From, r.To)
+}
+
+// PackageChange describes a change of an application or a container image
+type PackageChange struct {
+	// Name is the application or image name
+	Name string `json:"name"`
+	// VersionChange describes the version change
+	VersionChange
+}
+
+// NodeImpact describes the impact of the upgrade on a cluster node
+type NodeImpact struct {
+	// Hostname is the node hostname
+	Hostname string `json:"hostname"`
+	// AdvertiseIP is the node advertise address
+	AdvertiseIP string `json:"advertise_ip"`
+	// Role is the node role
+	Role string `json:"role"`
+	// Drained is whether the node is drained of workloads during the upgrade
+	Drained bool `json:"drained"`
+	// Restarts lists the services restarted on this node
+	Restarts []string `json:"restarts,omitempty"`
+}
+
+// WorkloadImpact describes a workload affected by draining cluster nodes
+type WorkloadImpact struct {
+	// Namespace is the workload namespace
+	Namespace string `json:"namespace"`
+	// Kind is the workload kind, e.g. Deployment
+	Kind string `json:"kind"`
+	// Name is the workload name
+	Name string `json:"name"`
+	// Pods is the number of running workload pods
+	Pods int `json:"pods"`
+	// Nodes lists the nodes the workload pods are running on
+	Nodes []string `json:"nodes"`
+	// Disrupted is whether the workload is expected to become unavailable.
+	// Nodes are drained one at a time, so only workloads without replicas
+	// on other nodes (or without a controller to reschedule them) become
+	// unavailable
+	Disrupted bool `json:"disrupted"`
+}
+
+// String returns a textual representation of this workload
+func (r WorkloadImpact) String() string {
+	return fmt.Sprintf("%v/%v/%v", r.Namespace, strings.ToLower(r.Kind), r.Name)
+}
+
+// NewPreview estimates the impact of upgrading the cluster to the specified cluster image
+func NewPreview(config PreviewConfig) (*Preview, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := config.Backend.GetLocalSite(defaults.SystemAccountID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	installedApp, err := config.Apps.GetApp(cluster.App.Locator())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	installedRuntime, err := config.Apps.GetApp(*(installedApp.Manifest.Base()))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateApp, err := config.UpdateApps.GetApp(config.UpdatePackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateRuntime, err := config.UpdateApps.GetApp(*(updateApp.Manifest.Base()))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := pack.CheckUpdatePackage(installedApp.Package, updateApp.Package); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateEtcd, installedEtcd, updateEtcdVersion, err := compareEtcdVersions(
+		installedRuntime.Manifest, updateRuntime.Manifest,
+		config.Packages, config.UpdatePackages)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	links, err := config.Backend.GetOpsCenterLinks(cluster.Domain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	trustedClusters, err := config.Backend.GetTrustedClusters()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	roles, err := config.Backend.GetRoles()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	p := previewConfig{
+		installedApp:       *installedApp,
+		installedRuntime:   *installedRuntime,
+		updateApp:          *updateApp,
+		updateRuntime:      *updateRuntime,
+		servers:            cluster.ClusterState.Servers,
+		disabledComponents: cluster.DisabledComponents,
+		migrateLinks:       len(links) != 0 && len(trustedClusters) == 0,
+		migrateRoles:       libphase.NeedMigrateRoles(roles),
+	}
+	if updateEtcd {
+		p.etcd = &VersionChange{From: installedEtcd, To: updateEtcdVersion}
+	}
+	p.installedImages, err = collectImages(config.Apps, *installedApp, *installedRuntime)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	p.updateImages, err = collectImages(config.UpdateApps, *updateApp, *updateRuntime)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if config.Client != nil {
+		p.updateCoreDNS, err = shouldUpdateCoreDNS(config.Client)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		pods, err := config.Client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, trace.Wrap(rigging.ConvertError(err))
+		}
+		p.pods = pods.Items
+		nodes, err := config.Client.CoreV1().Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			return nil, trace.Wrap(rigging.ConvertError(err))
+		}
+		p.nodes = nodes.Items
+		volumes, err := config.Client.CoreV1().PersistentVolumes().List(metav1.ListOptions{})
+		if err != nil {
+			return nil, trace.Wrap(rigging.ConvertError(err))
+		}
+		p.volumes = volumes.Items
+	}
+	return newPreview(p)
+}
+
+type previewConfig struct {
+	installedApp       app.Application
+	installedRuntime   app.Application
+	updateApp          app.Application
+	updateRuntime      app.Application
+	servers            []storage.Server
+	disabledComponents []string
+	// etcd is the etcd version change if etcd needs to be upgraded
+	etcd          *VersionChange
+	updateCoreDNS bool
+	migrateLinks  bool
+	migrateRoles  bool
+	// installedImages and updateImages map container image repositories
+	// to tags for the installed and update cluster images respectively
+	installedImages map[string]string
+	updateImages    map[string]string
+	// pods lists the pods running in the cluster
+	pods []v1.Pod
+	// nodes lists the cluster nodes
+	nodes []v1.Node
+	// volumes lists the persistent volumes
+	volumes []v1.PersistentVolume
+}
+
+func newPreview(p previewConfig) (*Preview, error) {
+	preview := Preview{
+		InstalledPackage: p.installedApp.Package,
+		UpdatePackage:    p.updateApp.Package,
+	}
+	runtimeApps, err := updatedApps(p, p.installedRuntime, p.updateRuntime)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	apps, err := updatedApps(p, p.installedApp, p.updateApp)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	preview.Apps = append(runtimeApps, apps...)
+	preview.Images = changedImages(p.installedImages, p.updateImages)
+	if len(runtimeApps) == 0 {
+		// Nodes are only updated along with the runtime applications
+		return &preview, nil
+	}
+	preview.Etcd = p.etcd
+
+	installedTeleport, err := p.installedApp.Manifest.Dependencies.ByName(constants.TeleportPackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateTeleport, err := p.updateApp.Manifest.Dependencies.ByName(constants.TeleportPackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	installedGravity, err := p.installedRuntime.Manifest.Dependencies.ByName(constants.GravityPackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	updateGravity, err := p.updateRuntime.Manifest.Dependencies.ByName(constants.GravityPackage)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var drained []string
+	for _, server := range p.servers {
+		needsPlanetUpdate, needsTeleportUpdate, err := systemNeedsUpdate(
+			server.Role, server.ClusterRole,
+			p.installedApp.Manifest, p.updateApp.Manifest,
+			*installedTeleport, *updateTeleport)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		node := NodeImpact{
+			Hostname:    server.Hostname,
+			AdvertiseIP: server.AdvertiseIP,
+			Role:        server.ClusterRole,
+			Drained:     needsPlanetUpdate,
+		}
+		if needsPlanetUpdate {
+			if preview.Runtime == nil {
+				preview.Runtime, err = runtimeChange(p, server)
+				if err != nil {
+					return nil, trace.Wrap(err)
+				}
+			}
+			node.Restarts = append(node.Restarts, "Kubernetes runtime (planet)")
+			drained = append(drained, server.KubeNodeID())
+		}
+		if needsTeleportUpdate {
+			node.Restarts = append(node.Restarts, "teleport")
+			if preview.Teleport == nil {
+				preview.Teleport = &VersionChange{
+					From: installedTeleport.Version,
+					To:   updateTeleport.Version,
+				}
+			}
+		}
+		if server.IsMaster() {
+			if p.etcd != nil {
+				node.Restarts = append(node.Restarts, "etcd")
+			}
+			if !installedGravity.IsEqualTo(*updateGravity) {
+				node.Restarts = append(node.Restarts, constants.GravityServiceName)
+			}
+		}
+		preview.Nodes = append(preview.Nodes, node)
+	}
+	preview.Migrations = migrations(p)
+	preview.Workloads = drainedWorkloads(p.pods, drained)
+	if len(p.nodes) != 0 {
+		// Nodes are drained one at a time and made schedulable again
+		// once updated, so each node is checked against the current state
+		for _, node := range drained {
+			preview.Headroom = append(preview.Headroom,
+				libkubernetes.ComputeHeadroom(node, p.nodes, p.pods, p.volumes))
+		}
+	}
+	return &preview, nil
+}
+
+// runtimeChange returns the runtime package version change for the specified server
+func runtimeChange(p previewConfig, server storage.Server) (*VersionChange, error) {
+	installed, err := getRuntimePackage(p.installedApp.Manifest, server.Role,
+		schema.ServiceRole(server.ClusterRole))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	update, err := p.updateApp.Manifest.RuntimePackageForProfile(server.Role)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &VersionChange{From: installed.Version, To: update.Version}, nil
+}
+
+// updatedApps returns the list of dependencies of the update application
+// that are updated by the upgrade, skipping the same applications
+// the operation plan would skip
+func updatedApps(p previewConfig, installed, update app.Application) (result []PackageChange, err error) {
+	updates, err := app.GetUpdatedDependencies(installed, update)
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	installedDeps, err := app.GetDirectDeps(installed)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, locator := range updates {
+		if schema.ShouldSkipApp(p.updateApp.Manifest, locator, p.disabledComponents...) {
+			continue
+		}
+		change := PackageChange{Name: locator.Name}
+		change.To = locator.Version
+		for _, dep := range installedDeps {
+			if dep.Name == locator.Name {
+				change.From = dep.Version
+			}
+		}
+		result = append(result, change)
+	}
+	return result, nil
+}
+
+// migrations returns the list of configuration and data migrations
+// the upgrade will perform along with the runtime update
+func migrations(p previewConfig) (result []string) {
+	if p.etcd != nil {
+		result = append(result, fmt.Sprintf(
+			"etcd data is backed up and restored during etcd upgrade %v "+
+				"(Kubernetes API is unavailable)", p.etcd))
+	}
+	if p.updateCoreDNS {
+		result = append(result, "CoreDNS resources are provisioned")
+	}
+	if p.migrateLinks {
+		result = append(result, "Gravity Hub links are migrated to trusted clusters")
+	}
+	if p.migrateRoles {
+		result = append(result, "Cluster roles are migrated to a new format")
+	}
+	return append(result, "Node labels are updated")
+}
+
+// collectImages returns container images referenced by the hooks of the specified
+// applications and their application dependencies as a map of image repositories
+// to tags
+func collectImages(apps app.Applications, roots ...app.Application) (map[string]string, error) {
+	images := make(map[string]string)
+	var objects []resources.Resource
+	for _, root := range roots {
+		objects = append(objects, resources.NewResource(&root.Manifest))
+		for _, image := range root.Manifest.RuntimeImages() {
+			addImage(images, image)
+		}
+		for _, dep := range root.Manifest.Dependencies.GetApps() {
+			app, err := apps.GetApp(dep)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			objects = append(objects, resources.NewResource(&app.Manifest))
+		}
+	}
+	refs, err := resources.Resources(objects).Images()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, ref := range refs {
+		addImage(images, ref)
+	}
+	return images, nil
+}
+
+func addImage(images map[string]string, ref string) {
+	image, err := loc.ParseDockerImage(ref)
+	if err != nil {
+		images[ref] = ""
+		return
+	}
+	repository := image.Repository
+	if image.Registry != "" {
+		repository = fmt.Sprintf("%v/%v", image.Registry, image.Repository)
+	}
+	images[repository] = image.Tag
+}
+
+// changedImages returns the list of images that are new or have changed tags
+func changedImages(installed, update map[string]string) (result []PackageChange) {
+	for repository, tag := range update {
+		installedTag, ok := installed[repository]
+		if ok && installedTag == tag {
+			continue
+		}
+		change := PackageChange{Name: repository}
+		change.From = installedTag
+		change.To = tag
+		result = append(result, change)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// drainedWorkloads returns the workloads that have pods on the specified
+// nodes that will be drained during the upgrade.
+//
+// Daemon sets and static pods are not included as their pods are not
+// evicted during the drain
+func drainedWorkloads(pods []v1.Pod, drained []string) (result []WorkloadImpact) {
+	workloads := make(map[string]*WorkloadImpact)
+	var affected []string
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		kind, name := podController(pod)
+		if kind == "DaemonSet" || kind == "Node" {
+			continue
+		}
+		key := fmt.Sprintf("%v/%v/%v", pod.Namespace, kind, name)
+		workload, ok := workloads[key]
+		if !ok {
+			workload = &WorkloadImpact{
+				Namespace: pod.Namespace,
+				Kind:      kind,
+				Name:      name,
+				// Pods without controller are not recreated
+				Disrupted: kind == "Pod",
+			}
+			workloads[key] = workload
+		}
+		workload.Pods++
+		if !utils.StringInSlice(workload.Nodes, pod.Spec.NodeName) {
+			workload.Nodes = append(workload.Nodes, pod.Spec.NodeName)
+		}
+		if utils.StringInSlice(drained, pod.Spec.NodeName) && !utils.StringInSlice(affected, key) {
+			affected = append(affected, key)
+		}
+	}
+	for _, key := range affected {
+		workload := workloads[key]
+		if len(workload.Nodes) < 2 {
+			workload.Disrupted = true
+		}
+		sort.Strings(workload.Nodes)
+		result = append(result, *workload)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
+
+// podController returns the kind and name of the top-level controller
+// of the specified pod
+func podController(pod v1.Pod) (kind, name string) {
+	owner := metav1.GetControllerOf(&pod)
+	if owner == nil {
+		return "Pod", pod.Name
+	}
+	// Replica sets created by deployments are named after the deployment
+	// with the pod template hash appended
+	hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+		return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+	}
+	return owner.Kind, owner.Name
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type PreviewSuite struct{}
+
+var _ = check.Suite(&PreviewSuite{})
+
+func (s *PreviewSuite) TestPreviewsRuntimeUpdate(c *check.C) {
+	p := newTestPreviewConfig(previewUpdateAppManifest)
+	p.etcd = &VersionChange{From: "3.3.4", To: "3.3.11"}
+	p.migrateRoles = true
+	p.installedImages = map[string]string{"nginx": "1.0", "busybox": "1"}
+	p.updateImages = map[string]string{"nginx": "2.0", "busybox": "1", "redis": "5"}
+	p.pods = []v1.Pod{
+		newPod("default", "web-5d4f8-x1", "node-1", "ReplicaSet", "web-5d4f8", "5d4f8"),
+		newPod("default", "web-5d4f8-x2", "node-3", "ReplicaSet", "web-5d4f8", "5d4f8"),
+		newPod("default", "db-0", "node-3", "StatefulSet", "db", ""),
+		newPod("default", "standalone", "node-1", "", "", ""),
+		newPod("kube-system", "proxy-abc", "node-1", "DaemonSet", "proxy", ""),
+	}
+	ready := v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}}
+	p.nodes = []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Status: ready},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}, Status: ready},
+	}
+	p.volumes = []v1.PersistentVolume{{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "data-db-0"},
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{
+						MatchFields: []v1.NodeSelectorRequirement{{
+							Key:      "metadata.name",
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{"node-3"},
+						}},
+					}},
+				},
+			},
+		},
+	}}
+	p.pods[2].Spec.Volumes = []v1.Volume{{
+		Name: "data",
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "data-db-0"},
+		},
+	}}
+
+	preview, err := newPreview(p)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(preview.Runtime, check.DeepEquals, &VersionChange{From: "1.0.0", To: "2.0.0"})
+	c.Assert(preview.Teleport, check.DeepEquals, &VersionChange{From: "3.0.0", To: "3.0.1"})
+	c.Assert(preview.Etcd, check.DeepEquals, p.etcd)
+	c.Assert(preview.Apps, check.DeepEquals, []PackageChange{
+		{Name: "runtime-dep-2", VersionChange: VersionChange{From: "1.0.0", To: "2.0.0"}},
+		{Name: "runtime", VersionChange: VersionChange{From: "1.0.0", To: "2.0.0"}},
+		{Name: "app-dep-2", VersionChange: VersionChange{From: "1.0.0", To: "2.0.0"}},
+		{Name: "app", VersionChange: VersionChange{From: "1.0.0", To: "2.0.0"}},
+	})
+	c.Assert(preview.Images, check.DeepEquals, []PackageChange{
+		{Name: "nginx", VersionChange: VersionChange{From: "1.0", To: "2.0"}},
+		{Name: "redis", VersionChange: VersionChange{To: "5"}},
+	})
+	c.Assert(preview.Migrations, check.DeepEquals, []string{
+		"etcd data is backed up and restored during etcd upgrade 3.3.4 -> 3.3.11 (Kubernetes API is unavailable)",
+		"Cluster roles are migrated to a new format",
+		"Node labels are updated",
+	})
+	c.Assert(preview.Nodes, check.DeepEquals, []NodeImpact{
+		{
+			Hostname:    "node-1",
+			AdvertiseIP: "192.168.0.1",
+			Role:        string(schema.ServiceRoleMaster),
+			Drained:     true,
+			Restarts:    []string{"Kubernetes runtime (planet)", "teleport", "etcd", "gravity-site"},
+		},
+		{
+			Hostname:    "node-3",
+			AdvertiseIP: "192.168.0.3",
+			Role:        string(schema.ServiceRoleNode),
+			Drained:     true,
+			Restarts:    []string{"Kubernetes runtime (planet)", "teleport"},
+		},
+	})
+	c.Assert(preview.Workloads, check.DeepEquals, []WorkloadImpact{
+		{Namespace: "default", Kind: "Deployment", Name: "web", Pods: 2, Nodes: []string{"node-1", "node-3"}},
+		{Namespace: "default", Kind: "Pod", Name: "standalone", Pods: 1, Nodes: []string{"node-1"}, Disrupted: true},
+		{Namespace: "default", Kind: "StatefulSet", Name: "db", Pods: 1, Nodes: []string{"node-3"}, Disrupted: true},
+	})
+	c.Assert(preview.DisruptedWorkloads(), check.HasLen, 2)
+	c.Assert(preview.Headroom, check.HasLen, 2)
+	c.Assert(preview.CapacityProblems(), check.DeepEquals, []string{
+		"node node-3: pod default/db-0 (volume pv-1) cannot be rescheduled, " +
+			"the volume is not accessible from the other nodes",
+	})
+}
+
+func (s *PreviewSuite) TestPreviewsApplicationOnlyUpdate(c *check.C) {
+	p := newTestPreviewConfig(previewAppOnlyManifest)
+	p.updateRuntime = p.installedRuntime
+	p.etcd = &VersionChange{From: "3.3.4", To: "3.3.11"}
+	p.pods = []v1.Pod{
+		newPod("default", "db-0", "node-3", "StatefulSet", "db", ""),
+	}
+
+	preview, err := newPreview(p)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(preview.Apps, check.DeepEquals, []PackageChange{
+		{Name: "app-dep-2", VersionChange: VersionChange{From: "1.0.0", To: "2.0.0"}},
+		{Name: "app", VersionChange: VersionChange{From: "1.0.0", To: "2.0.0"}},
+	})
+	c.Assert(preview.Runtime, check.IsNil)
+	c.Assert(preview.Etcd, check.IsNil)
+	c.Assert(preview.Nodes, check.HasLen, 0)
+	c.Assert(preview.Workloads, check.HasLen, 0)
+}
+
+func (s *PreviewSuite) TestSkipsDisabledComponents(c *check.C) {
+	p := newTestPreviewConfig(previewUpdateAppManifest)
+	p.disabledComponents = []string{"extra"}
+
+	preview, err := newPreview(p)
+	c.Assert(err, check.IsNil)
+
+	for _, app := range preview.Apps {
+		c.Assert(app.Name, check.Not(check.Equals), "app-dep-2")
+	}
+}
+
+func newTestPreviewConfig(updateAppManifest string) previewConfig {
+	return previewConfig{
+		installedRuntime: newTestApp("gravitational.io/runtime:1.0.0", previewInstalledRuntimeManifest),
+		installedApp:     newTestApp("gravitational.io/app:1.0.0", previewInstalledAppManifest),
+		updateRuntime:    newTestApp("gravitational.io/runtime:2.0.0", previewUpdateRuntimeManifest),
+		updateApp:        newTestApp("gravitational.io/app:2.0.0", updateAppManifest),
+		servers: []storage.Server{
+			{
+				AdvertiseIP: "192.168.0.1",
+				Hostname:    "node-1",
+				Nodename:    "node-1",
+				Role:        "node",
+				ClusterRole: string(schema.ServiceRoleMaster),
+			},
+			{
+				AdvertiseIP: "192.168.0.3",
+				Hostname:    "node-3",
+				Nodename:    "node-3",
+				Role:        "node",
+				ClusterRole: string(schema.ServiceRoleNode),
+			},
+		},
+	}
+}
+
+func newTestApp(locator, manifest string) app.Application {
+	return app.Application{
+		Package:  loc.MustParseLocator(locator),
+		Manifest: schema.MustParseManifestYAML([]byte(manifest)),
+		PackageEnvelope: pack.PackageEnvelope{
+			Manifest: []byte(manifest),
+		},
+	}
+}
+
+func newPod(namespace, name, node, ownerKind, ownerName, hash string) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Spec: v1.PodSpec{
+			NodeName: node,
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+		},
+	}
+	if ownerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{
+			{Kind: ownerKind, Name: ownerName, Controller: &controller},
+		}
+	}
+	if hash != "" {
+		pod.Labels = map[string]string{"pod-template-hash": hash}
+	}
+	return pod
+}
+
+const previewInstalledRuntimeManifest = `apiVersion: bundle.gravitational.io/v2
+kind: Runtime
+metadata:
+  name: runtime
+  resourceVersion: 1.0.0
+dependencies:
+  packages:
+    - gravitational.io/gravity:1.0.0
+  apps:
+    - gravitational.io/runtime-dep-1:1.0.0
+    - gravitational.io/runtime-dep-2:1.0.0
+`
+
+const previewUpdateRuntimeManifest = `apiVersion: bundle.gravitational.io/v2
+kind: Runtime
+metadata:
+  name: runtime
+  resourceVersion: 2.0.0
+dependencies:
+  packages:
+    - gravitational.io/gravity:2.0.0
+  apps:
+    - gravitational.io/runtime-dep-1:1.0.0
+    - gravitational.io/runtime-dep-2:2.0.0
+`
+
+const previewInstalledAppManifest = `apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: app
+  resourceVersion: 1.0.0
+dependencies:
+  packages:
+    - gravitational.io/teleport:3.0.0
+  apps:
+    - gravitational.io/app-dep-1:1.0.0
+    - gravitational.io/app-dep-2:1.0.0
+nodeProfiles:
+  - name: node
+systemOptions:
+  dependencies:
+    runtimePackage: gravitational.io/planet:1.0.0
+`
+
+const previewUpdateAppManifest = `apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: app
+  resourceVersion: 2.0.0
+dependencies:
+  packages:
+    - gravitational.io/teleport:3.0.1
+  apps:
+    - gravitational.io/app-dep-1:1.0.0
+    - gravitational.io/app-dep-2:2.0.0
+components:
+  - name: extra
+    apps: [app-dep-2]
+nodeProfiles:
+  - name: node
+systemOptions:
+  dependencies:
+    runtimePackage: gravitational.io/planet:2.0.0
+`
+
+const previewAppOnlyManifest = `apiVersion: bundle.gravitational.io/v2
+kind: Bundle
+metadata:
+  name: app
+  resourceVersion: 2.0.0
+dependencies:
+  packages:
+    - gravitational.io/teleport:3.0.0
+  apps:
+    - gravitational.io/app-dep-1:1.0.0
+    - gravitational.io/app-dep-2:2.0.0
+nodeProfiles:
+  - name: node
+systemOptions:
+  dependencies:
+    runtimePackage: gravitational.io/planet:1.0.0
+`
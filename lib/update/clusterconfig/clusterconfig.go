@@ -76,6 +76,12 @@ func (r *dispatcher) Dispatch(config rollingupdate.Config, params fsm.ExecutorPa
 			config.Operator, *config.Operation, config.Apps,
 			config.ClusterPackages, config.HostLocalPackages,
 			logger)
+	case phases.UpdateNetwork:
+		return phases.NewUpdateNetwork(params, logger)
+	case phases.Services:
+		return phases.NewServices(params, config.Client, logger)
+	case phases.RestartPods:
+		return phases.NewRestartPods(params, config.Client, logger)
 	default:
 		return r.Dispatcher.Dispatch(config, params, remote, logger)
 	}
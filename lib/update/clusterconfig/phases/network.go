@@ -0,0 +1,99 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewUpdateNetwork returns a new executor to update the overlay network
+// configuration to the new pod network
+func NewUpdateNetwork(params libfsm.ExecutorParams, logger log.FieldLogger) (*updateNetwork, error) {
+	if params.Phase.Data == nil || params.Phase.Data.Update == nil || params.Phase.Data.Update.Network == nil {
+		return nil, trace.NotFound("no network change specified for phase %q", params.Phase.ID)
+	}
+	return &updateNetwork{
+		FieldLogger: logger,
+		network:     *params.Phase.Data.Update.Network,
+	}, nil
+}
+
+// Execute updates the overlay network configuration to the new pod network.
+// The overlay network on each node switches to the new network after the node
+// has been restarted
+func (r *updateNetwork) Execute(context.Context) error {
+	r.Infof("Update overlay network to %v.", r.network.PodCIDR)
+	return trace.Wrap(setOverlayNetwork(r.network.PodCIDR))
+}
+
+// Rollback restores the overlay network configuration to the previous pod network
+func (r *updateNetwork) Rollback(context.Context) error {
+	r.Infof("Restore overlay network to %v.", r.network.PrevPodCIDR)
+	return trace.Wrap(setOverlayNetwork(r.network.PrevPodCIDR))
+}
+
+// PreCheck is a no-op
+func (*updateNetwork) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is a no-op
+func (*updateNetwork) PostCheck(context.Context) error {
+	return nil
+}
+
+// setOverlayNetwork replaces the network in the flannel configuration
+// stored in etcd with the specified subnet keeping the rest of the configuration intact
+func setOverlayNetwork(subnet string) error {
+	out, err := libfsm.RunCommand(utils.PlanetCommandArgs(defaults.EtcdCtlBin,
+		"get", flannelConfigKey))
+	if err != nil {
+		return trace.Wrap(err, "failed to query overlay network configuration: %s", out)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(out), &config); err != nil {
+		return trace.Wrap(err, "failed to parse overlay network configuration: %s", out)
+	}
+	config["Network"] = subnet
+	data, err := json.Marshal(config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	out, err = libfsm.RunCommand(utils.PlanetCommandArgs(defaults.EtcdCtlBin,
+		"set", flannelConfigKey, string(data)))
+	return trace.Wrap(err, "failed to update overlay network configuration: %s", out)
+}
+
+// updateNetwork is the phase that updates the overlay network configuration
+type updateNetwork struct {
+	// FieldLogger specifies the logger for the phase
+	log.FieldLogger
+	network storage.NetworkChange
+}
+
+// flannelConfigKey is the etcd key with the flannel network configuration
+const flannelConfigKey = "/coreos.com/network/config"
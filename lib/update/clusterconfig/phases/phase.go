@@ -0,0 +1,26 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+const (
+	// UpdateNetwork defines the phase to update the overlay network configuration
+	UpdateNetwork = "update-network"
+	// Services defines the phase to move services to the new service network
+	Services = "services"
+	// RestartPods defines the phase to restart pods to apply the network change
+	RestartPods = "restart-pods"
+)
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/update"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewRestartPods returns a new executor to restart the cluster pods
+// so they are assigned addresses in the new networks
+func NewRestartPods(params libfsm.ExecutorParams, client *kubernetes.Clientset, logger log.FieldLogger) (*restartPods, error) {
+	if client == nil {
+		return nil, trace.BadParameter("phase %q must be run from a master node (requires kubernetes client)",
+			params.Phase.ID)
+	}
+	return &restartPods{
+		FieldLogger: logger,
+		client:      client,
+	}, nil
+}
+
+// Execute restarts the pods one namespace at a time starting with
+// the system namespace and waits for the pods in each namespace to
+// become ready before proceeding with the next one.
+// Pods using host network are not affected by the network change and
+// are left intact as are pods not managed by a controller since
+// these would not be recreated
+func (r *restartPods) Execute(ctx context.Context) error {
+	namespaces, err := r.namespaces()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, namespace := range namespaces {
+		if err := r.restartPods(ctx, namespace); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// Rollback is a no-op for this phase
+func (*restartPods) Rollback(context.Context) error {
+	return nil
+}
+
+// PreCheck is a no-op
+func (*restartPods) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is a no-op
+func (*restartPods) PostCheck(context.Context) error {
+	return nil
+}
+
+func (r *restartPods) restartPods(ctx context.Context, namespace string) error {
+	logger := r.WithField("namespace", namespace)
+	client := r.client.CoreV1().Pods(namespace)
+	pods, err := client.List(metav1.ListOptions{})
+	if err != nil {
+		return trace.Wrap(rigging.ConvertError(err))
+	}
+	var restarted int
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || isPodCompleted(pod) {
+			continue
+		}
+		if len(pod.OwnerReferences) == 0 {
+			logger.WithField("pod", pod.Name).Warn("Pod is not managed by a controller, restart it manually.")
+			continue
+		}
+		logger.WithField("pod", pod.Name).Info("Restart pod.")
+		err := rigging.ConvertError(client.Delete(pod.Name, &metav1.DeleteOptions{}))
+		if err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		restarted++
+	}
+	if restarted == 0 {
+		return nil
+	}
+	logger.Info("Wait for pods to become ready.")
+	err = update.Retry(ctx, func() error {
+		pods, err := client.List(metav1.ListOptions{})
+		if err != nil {
+			return trace.Wrap(rigging.ConvertError(err))
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				return trace.CompareFailed("pod %v is terminating", pod.Name)
+			}
+			if !isPodCompleted(pod) && !isPodReady(pod) {
+				return trace.CompareFailed("pod %v is not ready", pod.Name)
+			}
+		}
+		return nil
+	}, defaults.PodsRestartTimeout)
+	return trace.Wrap(err)
+}
+
+// namespaces returns the list of cluster namespaces with
+// the system namespace first
+func (r *restartPods) namespaces() (namespaces []string, err error) {
+	list, err := r.client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	for _, namespace := range list.Items {
+		if namespace.Name != defaults.KubeSystemNamespace {
+			namespaces = append(namespaces, namespace.Name)
+		}
+	}
+	sort.Strings(namespaces)
+	return append([]string{defaults.KubeSystemNamespace}, namespaces...), nil
+}
+
+func isPodCompleted(pod v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+func isPodReady(pod v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// restartPods is the phase that restarts pods to apply the network change
+type restartPods struct {
+	// FieldLogger specifies the logger for the phase
+	log.FieldLogger
+	client *kubernetes.Clientset
+}
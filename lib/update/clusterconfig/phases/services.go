@@ -0,0 +1,320 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewServices returns a new executor to move cluster services
+// to the new service network
+func NewServices(params libfsm.ExecutorParams, client *kubernetes.Clientset, logger log.FieldLogger) (*services, error) {
+	if params.Phase.Data == nil || params.Phase.Data.Update == nil || params.Phase.Data.Update.Network == nil {
+		return nil, trace.NotFound("no network change specified for phase %q", params.Phase.ID)
+	}
+	if client == nil {
+		return nil, trace.BadParameter("phase %q must be run from a master node (requires kubernetes client)",
+			params.Phase.ID)
+	}
+	return &services{
+		FieldLogger:  logger,
+		client:       client,
+		network:      *params.Phase.Data.Update.Network,
+		snapshotName: fmt.Sprintf("service-snapshot-%v", params.Plan.OperationID),
+	}, nil
+}
+
+// Execute recreates the services with cluster IPs from the previous
+// service network in the new service network.
+// The services are saved before they are recreated so they can be restored
+// during rollback even if the execution failed half-way
+func (r *services) Execute(context.Context) error {
+	list, err := r.listServices(r.network.PrevServiceCIDR)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := r.saveSnapshot(list); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.moveServices(r.network.PrevServiceCIDR, r.network.ServiceCIDR))
+}
+
+// Rollback restores the services from the snapshot taken during execution
+// and moves the remaining services back to the previous service network
+func (r *services) Rollback(context.Context) error {
+	snapshot, err := r.getSnapshot()
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	for _, service := range snapshot {
+		if err := r.restoreService(service); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if err := r.moveServices(r.network.ServiceCIDR, r.network.PrevServiceCIDR); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(r.removeSnapshot())
+}
+
+// PreCheck is a no-op
+func (*services) PreCheck(context.Context) error {
+	return nil
+}
+
+// PostCheck is a no-op
+func (*services) PostCheck(context.Context) error {
+	return nil
+}
+
+// moveServices recreates the services with cluster IPs in the network from
+// so that they are assigned the addresses with the same offset in the network to.
+// This keeps well-known service addresses like the one of the API server and
+// the cluster DNS consistent with the runtime configuration.
+// Services already located in the network to are left intact
+func (r *services) moveServices(from, to string) error {
+	_, fromNet, err := net.ParseCIDR(from)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, toNet, err := net.ParseCIDR(to)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	list, err := r.listServices(from)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, service := range list {
+		newIP := translateIP(net.ParseIP(service.Spec.ClusterIP), fromNet, toNet)
+		if err := r.recreateService(service, newIP); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// listServices returns the services with cluster IPs in the specified network
+func (r *services) listServices(cidr string) ([]v1.Service, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	list, err := r.client.CoreV1().Services(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	var result []v1.Service
+	for _, service := range list.Items {
+		ip := net.ParseIP(service.Spec.ClusterIP)
+		if ip == nil || !ipNet.Contains(ip) {
+			continue
+		}
+		result = append(result, service)
+	}
+	return result, nil
+}
+
+// restoreService recreates the specified service from the snapshot
+// unless it exists with the same cluster IP
+func (r *services) restoreService(service v1.Service) error {
+	existing, err := r.client.CoreV1().Services(service.Namespace).Get(service.Name, metav1.GetOptions{})
+	err = rigging.ConvertError(err)
+	if err != nil {
+		if !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		existing = nil
+	}
+	if !needsRestore(service, existing) {
+		return nil
+	}
+	return trace.Wrap(r.recreateService(service, net.ParseIP(service.Spec.ClusterIP)))
+}
+
+// saveSnapshot saves the specified services in the snapshot config map.
+// If the snapshot already exists, e.g. when the phase is re-executed, the services
+// missing from it are added while the saved ones are kept
+func (r *services) saveSnapshot(services []v1.Service) error {
+	client := r.client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace)
+	configMap, err := client.Get(r.snapshotName, metav1.GetOptions{})
+	err = rigging.ConvertError(err)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	exists := err == nil
+	var snapshot []v1.Service
+	if exists {
+		snapshot, err = decodeSnapshot(configMap.Data)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	} else {
+		configMap = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      r.snapshotName,
+				Namespace: defaults.KubeSystemNamespace,
+			},
+		}
+	}
+	configMap.Data, err = encodeSnapshot(mergeSnapshot(snapshot, services))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	r.WithField("config-map", r.snapshotName).Info("Save services.")
+	if exists {
+		_, err = client.Update(configMap)
+	} else {
+		_, err = client.Create(configMap)
+	}
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+// getSnapshot returns the services saved in the snapshot config map
+func (r *services) getSnapshot() ([]v1.Service, error) {
+	configMap, err := r.client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+		Get(r.snapshotName, metav1.GetOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	return decodeSnapshot(configMap.Data)
+}
+
+func (r *services) removeSnapshot() error {
+	err := r.client.CoreV1().ConfigMaps(defaults.KubeSystemNamespace).
+		Delete(r.snapshotName, &metav1.DeleteOptions{})
+	err = rigging.ConvertError(err)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// mergeSnapshot adds the services missing from the snapshot to it
+func mergeSnapshot(snapshot, services []v1.Service) []v1.Service {
+	saved := make(map[string]struct{}, len(snapshot))
+	for _, service := range snapshot {
+		saved[serviceKey(service)] = struct{}{}
+	}
+	for _, service := range services {
+		if _, ok := saved[serviceKey(service)]; ok {
+			continue
+		}
+		snapshot = append(snapshot, service)
+	}
+	return snapshot
+}
+
+// needsRestore returns true if the service saved in the snapshot
+// has to be recreated given the existing service (nil if it does not exist)
+func needsRestore(saved v1.Service, existing *v1.Service) bool {
+	return existing == nil || existing.Spec.ClusterIP != saved.Spec.ClusterIP
+}
+
+func encodeSnapshot(services []v1.Service) (map[string]string, error) {
+	data, err := json.Marshal(services)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return map[string]string{snapshotKey: string(data)}, nil
+}
+
+func decodeSnapshot(data map[string]string) ([]v1.Service, error) {
+	var services []v1.Service
+	if data[snapshotKey] == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(data[snapshotKey]), &services); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return services, nil
+}
+
+func serviceKey(service v1.Service) string {
+	return service.Namespace + "/" + service.Name
+}
+
+// recreateService replaces the specified service with a copy that
+// has the specified cluster IP.
+// If ip is nil, the address is allocated by the API server
+func (r *services) recreateService(service v1.Service, ip net.IP) error {
+	logger := r.WithField("service", service.Namespace+"/"+service.Name)
+	client := r.client.CoreV1().Services(service.Namespace)
+	err := client.Delete(service.Name, &metav1.DeleteOptions{})
+	err = rigging.ConvertError(err)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	service.ResourceVersion = ""
+	service.UID = ""
+	service.CreationTimestamp = metav1.Time{}
+	service.Status = v1.ServiceStatus{}
+	service.Spec.ClusterIP = ""
+	if ip != nil {
+		service.Spec.ClusterIP = ip.String()
+	}
+	logger.WithField("cluster-ip", service.Spec.ClusterIP).Info("Recreate service.")
+	_, err = client.Create(&service)
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+// translateIP returns the address with the same offset in the network to
+// as the specified address has in the network from.
+// Returns nil if the address does not fit into the network to
+func translateIP(ip net.IP, from, to *net.IPNet) net.IP {
+	ip4, fromIP, toIP := ip.To4(), from.IP.To4(), to.IP.To4()
+	if ip4 == nil || fromIP == nil || toIP == nil {
+		return nil
+	}
+	offset := binary.BigEndian.Uint32(ip4) - binary.BigEndian.Uint32(fromIP)
+	ones, bits := to.Mask.Size()
+	if uint64(offset) >= uint64(1)<<uint(bits-ones) {
+		return nil
+	}
+	result := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(result, binary.BigEndian.Uint32(toIP)+offset)
+	return result
+}
+
+// services is the phase that moves services to the new service network
+type services struct {
+	// FieldLogger specifies the logger for the phase
+	log.FieldLogger
+	client  *kubernetes.Clientset
+	network storage.NetworkChange
+	// snapshotName names the config map with the services saved
+	// before they have been recreated
+	snapshotName string
+}
+
+// snapshotKey is the config map key with the saved services
+const snapshotKey = "services"
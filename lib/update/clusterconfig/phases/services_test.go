@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"net"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPhases(t *testing.T) { TestingT(t) }
+
+type S struct{}
+
+var _ = Suite(&S{})
+
+func (S) TestTranslatesServiceIPs(c *C) {
+	var testCases = []struct {
+		comment  string
+		ip       string
+		from, to string
+		expected net.IP
+	}{
+		{
+			comment:  "API server address",
+			ip:       "10.100.0.1",
+			from:     "10.100.0.0/16",
+			to:       "10.210.0.0/16",
+			expected: net.ParseIP("10.210.0.1").To4(),
+		},
+		{
+			comment:  "address beyond the first octet",
+			ip:       "10.100.3.4",
+			from:     "10.100.0.0/16",
+			to:       "172.30.0.0/16",
+			expected: net.ParseIP("172.30.3.4").To4(),
+		},
+		{
+			comment: "address does not fit into smaller network",
+			ip:      "10.100.3.4",
+			from:    "10.100.0.0/16",
+			to:      "172.30.0.0/24",
+		},
+	}
+	for _, tc := range testCases {
+		comment := Commentf(tc.comment)
+		_, from, err := net.ParseCIDR(tc.from)
+		c.Assert(err, IsNil)
+		_, to, err := net.ParseCIDR(tc.to)
+		c.Assert(err, IsNil)
+		c.Assert(translateIP(net.ParseIP(tc.ip), from, to), DeepEquals, tc.expected, comment)
+	}
+}
+
+func (S) TestMergesServiceSnapshot(c *C) {
+	saved := newService("kube-system", "kube-dns", "10.100.0.2")
+	snapshot := mergeSnapshot([]v1.Service{saved}, []v1.Service{
+		// the service has already been recreated in the new network
+		newService("kube-system", "kube-dns", "10.210.0.2"),
+		newService("default", "kubernetes", "10.100.0.1"),
+	})
+	c.Assert(snapshot, DeepEquals, []v1.Service{
+		saved,
+		newService("default", "kubernetes", "10.100.0.1"),
+	})
+
+	data, err := encodeSnapshot(snapshot)
+	c.Assert(err, IsNil)
+	decoded, err := decodeSnapshot(data)
+	c.Assert(err, IsNil)
+	c.Assert(decoded, DeepEquals, snapshot)
+}
+
+func (S) TestRestoresServicesFromSnapshot(c *C) {
+	saved := newService("kube-system", "kube-dns", "10.100.0.2")
+	moved := newService("kube-system", "kube-dns", "10.210.0.2")
+	c.Assert(needsRestore(saved, nil), Equals, true, Commentf("service deleted but not recreated"))
+	c.Assert(needsRestore(saved, &moved), Equals, true, Commentf("service moved to the new network"))
+	c.Assert(needsRestore(saved, &saved), Equals, false, Commentf("service not moved"))
+}
+
+func newService(namespace, name, clusterIP string) v1.Service {
+	return v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       v1.ServiceSpec{ClusterIP: clusterIP},
+	}
+}
@@ -59,6 +59,7 @@ func NewUpdateConfig(
 		packages:     packages,
 		hostPackages: hostPackages,
 		servers:      params.Phase.Data.Update.Servers,
+		network:      params.Phase.Data.Update.Network,
 		manifest:     app.Manifest,
 	}, nil
 }
@@ -84,6 +85,11 @@ func (r *updateConfig) Execute(ctx context.Context) error {
 		if err != nil {
 			return trace.Wrap(err)
 		}
+		if update.Runtime.SecretsPackage != nil {
+			if err := r.rotateSecrets(update); err != nil {
+				return trace.Wrap(err)
+			}
+		}
 	}
 	err := r.operator.UpdateClusterConfiguration(ops.UpdateClusterConfigRequest{
 		ClusterKey: r.operation.ClusterKey(),
@@ -100,6 +106,13 @@ func (r *updateConfig) Rollback(context.Context) error {
 			if err != nil && !trace.IsNotFound(err) {
 				return trace.Wrap(err)
 			}
+			if update.Runtime.SecretsPackage == nil {
+				continue
+			}
+			err = packages.DeletePackage(*update.Runtime.SecretsPackage)
+			if err != nil && !trace.IsNotFound(err) {
+				return trace.Wrap(err)
+			}
 		}
 	}
 	err := r.operator.UpdateClusterConfiguration(ops.UpdateClusterConfigRequest{
@@ -109,6 +122,28 @@ func (r *updateConfig) Rollback(context.Context) error {
 	return trace.Wrap(err)
 }
 
+// rotateSecrets generates a new secrets package for the specified server
+// issuing the API server certificate for the new service network
+func (r *updateConfig) rotateSecrets(update storage.UpdateServer) error {
+	r.Infof("Generate new secrets package for %v.", update.Server)
+	req := ops.RotateSecretsRequest{
+		AccountID:   r.operation.AccountID,
+		ClusterName: r.operation.SiteDomain,
+		Server:      update.Server,
+		Locator:     update.Runtime.SecretsPackage,
+	}
+	if r.network != nil {
+		req.ServiceCIDR = r.network.ServiceCIDR
+	}
+	resp, err := r.operator.RotateSecrets(req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.packages.UpsertPackage(resp.Locator, resp.Reader,
+		pack.WithLabels(resp.Labels))
+	return trace.Wrap(err)
+}
+
 // PreCheck is a no-op
 func (r *updateConfig) PreCheck(context.Context) error {
 	return nil
@@ -127,11 +162,13 @@ type updateConfig struct {
 	packages     packageService
 	hostPackages packageService
 	servers      []storage.UpdateServer
+	network      *storage.NetworkChange
 	manifest     schema.Manifest
 }
 
 type operator interface {
 	RotatePlanetConfig(ops.RotatePlanetConfigRequest) (*ops.RotatePackageResponse, error)
+	RotateSecrets(ops.RotateSecretsRequest) (*ops.RotatePackageResponse, error)
 	UpdateClusterConfiguration(ops.UpdateClusterConfigRequest) error
 }
 
@@ -22,6 +22,7 @@ import (
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/clusterconfig"
 	"github.com/gravitational/gravity/lib/update"
+	"github.com/gravitational/gravity/lib/update/clusterconfig/phases"
 	"github.com/gravitational/gravity/lib/update/internal/rollingupdate"
 
 	"github.com/gravitational/trace"
@@ -43,7 +44,11 @@ func NewOperationPlan(
 	if err != nil {
 		return nil, trace.Wrap(err, "failed to query installed application")
 	}
-	plan, err = newOperationPlan(*app, cluster.DNSConfig, operator, operation, clusterConfig, servers)
+	network, err := getNetworkChange(operator, cluster.Key(), operation, clusterConfig)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plan, err = newOperationPlan(*app, cluster.DNSConfig, operator, operation, clusterConfig, servers, network)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -60,44 +65,77 @@ func NewOperationPlan(
 }
 
 // newOperationPlan returns a new plan for the specified operation
-// and the given set of servers
+// and the given set of servers.
+// network optionally specifies the change of the cluster pod/service networks
 func newOperationPlan(
 	app app.Application,
 	dnsConfig storage.DNSConfig,
-	operator rollingupdate.ConfigPackageRotator,
+	operator packageRotator,
 	operation ops.SiteOperation,
 	clusterConfig clusterconfig.Interface,
 	servers []storage.Server,
+	network *storage.NetworkChange,
 ) (*storage.OperationPlan, error) {
 	builder := rollingupdate.Builder{App: app.Package}
 	updates, err := rollingupdate.RuntimeConfigUpdates(app.Manifest, operator, operation.Key(), servers)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	if network != nil && network.ServiceCIDRChanged() {
+		// API server certificate is issued for the first address
+		// of the service network and needs to be regenerated
+		err = rotateMasterSecrets(operator, operation, *network, updates)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
 	masters, nodes := update.SplitServers(updates)
 	if len(masters) == 0 {
 		return nil, trace.NotFound("no master servers found in cluster state")
 	}
-	shouldUpdateNodes := shouldUpdateNodes(clusterConfig, len(nodes))
+	leader := masters[0].Server
+	shouldUpdateNodes := shouldUpdateNodes(clusterConfig, network, len(nodes))
 	updateServers := updates
 	if !shouldUpdateNodes {
 		updateServers = masters
 	}
 	config := *builder.Config("Update runtime configuration", updateServers)
+	if network != nil {
+		config.Data.Update.Network = network
+	}
+	phases := update.Phases{config}
+
+	masterDeps := []update.PhaseIder{config}
+	if network != nil && network.PodCIDRChanged() {
+		updateNetwork := *networkPhase(leader, *network).Require(config)
+		phases = append(phases, updateNetwork)
+		masterDeps = append(masterDeps, updateNetwork)
+	}
 	updateMasters := *builder.Masters(
 		masters,
 		"Update cluster configuration",
 		"Update configuration on node %q",
-	).Require(config)
-	phases := update.Phases{config, updateMasters}
+	).Require(masterDeps...)
+	phases = append(phases, updateMasters)
+	workloadDeps := []update.PhaseIder{updateMasters}
 
 	if shouldUpdateNodes {
 		updateNodes := *builder.Nodes(
-			nodes, masters[0].Server,
+			nodes, leader,
 			"Update cluster configuration",
 			"Update configuration on node %q",
 		).Require(config, updateMasters)
 		phases = append(phases, updateNodes)
+		workloadDeps = append(workloadDeps, updateNodes)
+	}
+
+	if network != nil {
+		if network.ServiceCIDRChanged() {
+			services := *servicesPhase(leader, *network).Require(workloadDeps...)
+			phases = append(phases, services)
+			workloadDeps = append(workloadDeps, services)
+		}
+		phases = append(phases, *podsPhase(leader).Require(workloadDeps...))
 	}
 
 	plan := &storage.OperationPlan{
@@ -114,10 +152,138 @@ func newOperationPlan(
 	return plan, nil
 }
 
-func shouldUpdateNodes(clusterConfig clusterconfig.Interface, numNodes int) bool {
+func networkPhase(leader storage.Server, network storage.NetworkChange) *update.Phase {
+	phase := update.RootPhase(update.Phase{
+		ID:          "network",
+		Executor:    phases.UpdateNetwork,
+		Description: "Update overlay network configuration",
+		Data: &storage.OperationPhaseData{
+			Server: &leader,
+			Update: &storage.UpdateOperationData{
+				Network: &network,
+			},
+		},
+	})
+	return &phase
+}
+
+func servicesPhase(leader storage.Server, network storage.NetworkChange) *update.Phase {
+	phase := update.RootPhase(update.Phase{
+		ID:          "services",
+		Executor:    phases.Services,
+		Description: "Move services to the new service network",
+		Data: &storage.OperationPhaseData{
+			Server: &leader,
+			Update: &storage.UpdateOperationData{
+				Network: &network,
+			},
+		},
+	})
+	return &phase
+}
+
+func podsPhase(leader storage.Server) *update.Phase {
+	phase := update.RootPhase(update.Phase{
+		ID:          "pods",
+		Executor:    phases.RestartPods,
+		Description: "Restart pods to apply the network change",
+		Data: &storage.OperationPhaseData{
+			Server: &leader,
+		},
+	})
+	return &phase
+}
+
+// rotateMasterSecrets assigns new secrets packages to the master servers in updates.
+// The secrets are generated for the new service network
+func rotateMasterSecrets(operator packageRotator, operation ops.SiteOperation, network storage.NetworkChange, updates []storage.UpdateServer) error {
+	for i, server := range updates {
+		if !server.IsMaster() {
+			continue
+		}
+		resp, err := operator.RotateSecrets(ops.RotateSecretsRequest{
+			AccountID:   operation.AccountID,
+			ClusterName: operation.SiteDomain,
+			Server:      server.Server,
+			DryRun:      true,
+			ServiceCIDR: network.ServiceCIDR,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		updates[i].Runtime.SecretsPackage = &resp.Locator
+	}
+	return nil
+}
+
+// getNetworkChange returns the change of the cluster pod/service networks
+// requested by the specified configuration.
+// Returns nil if the networks stay the same
+func getNetworkChange(operator ops.Operator, key ops.SiteKey, operation ops.SiteOperation, clusterConfig clusterconfig.Interface) (*storage.NetworkChange, error) {
+	installOperation, err := ops.GetCompletedInstallOperation(key, operator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	prevConfig := clusterconfig.Interface(clusterconfig.NewEmpty())
+	if operation.UpdateConfig != nil && len(operation.UpdateConfig.PrevConfig) != 0 {
+		prevConfig, err = clusterconfig.Unmarshal(operation.UpdateConfig.PrevConfig)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return networkChange(installOperation.InstallExpand.Subnets, prevConfig, clusterConfig), nil
+}
+
+// networkChange computes the change of the cluster networks between prevConfig
+// and config given the networks selected during installation.
+// Returns nil if the networks stay the same
+func networkChange(installed storage.Subnets, prevConfig, config clusterconfig.Interface) *storage.NetworkChange {
+	if installed.IsEmpty() {
+		// Subnets are empty for clusters installed with older versions
+		installed = storage.DefaultSubnets
+	}
+	prev := EffectiveSubnets(installed, prevConfig)
+	next := EffectiveSubnets(installed, config)
+	if prev == next {
+		return nil
+	}
+	return &storage.NetworkChange{
+		PrevPodCIDR:     prev.Overlay,
+		PodCIDR:         next.Overlay,
+		PrevServiceCIDR: prev.Service,
+		ServiceCIDR:     next.Service,
+	}
+}
+
+// EffectiveSubnets returns the networks in effect with the specified configuration
+func EffectiveSubnets(subnets storage.Subnets, config clusterconfig.Interface) storage.Subnets {
+	globalConfig := config.GetGlobalConfig()
+	if globalConfig == nil {
+		return subnets
+	}
+	if globalConfig.PodCIDR != "" {
+		subnets.Overlay = globalConfig.PodCIDR
+	}
+	if globalConfig.ServiceCIDR != "" {
+		subnets.Service = globalConfig.ServiceCIDR
+	}
+	return subnets
+}
+
+func shouldUpdateNodes(clusterConfig clusterconfig.Interface, network *storage.NetworkChange, numNodes int) bool {
 	var hasComponentUpdate bool
-	if config := clusterConfig.GetGlobalConfig(); config != nil && len(config.FeatureGates) != 0 {
+	if config := clusterConfig.GetGlobalConfig(); config != nil && (len(config.FeatureGates) != 0 || config.OverlayMTU != 0) {
+		hasComponentUpdate = true
+	}
+	// Nodes need to be restarted to pick up the new pod/service networks
+	if network != nil {
 		hasComponentUpdate = true
 	}
 	return (clusterConfig.GetKubeletConfig() != nil || hasComponentUpdate) && numNodes != 0
 }
+
+// packageRotator defines the subset of Operator for generating configuration packages
+type packageRotator interface {
+	rollingupdate.ConfigPackageRotator
+	RotateSecrets(ops.RotateSecretsRequest) (*ops.RotatePackageResponse, error)
+}
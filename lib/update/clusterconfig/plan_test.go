@@ -21,11 +21,13 @@ import (
 
 	"github.com/gravitational/gravity/lib/app"
 	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/storage/clusterconfig"
+	"github.com/gravitational/gravity/lib/update"
 	libphase "github.com/gravitational/gravity/lib/update/internal/rollingupdate/phases"
 
 	. "gopkg.in/check.v1"
@@ -66,7 +68,7 @@ func (S) TestSingleNodePlan(c *C) {
 	}
 	clusterConfig := clusterconfig.NewEmpty()
 
-	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, clusterConfig, servers)
+	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, clusterConfig, servers, nil)
 	c.Assert(err, IsNil)
 	c.Assert(plan, compare.DeepEquals, &storage.OperationPlan{
 		OperationID:   operation.ID,
@@ -109,6 +111,7 @@ func (S) TestSingleNodePlan(c *C) {
 							{
 								ID:          "/masters/node-1/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-1"`,
 								Data: &storage.OperationPhaseData{
 									Server: &servers[0],
@@ -218,7 +221,7 @@ func (S) TestMultiNodePlan(c *C) {
 	}
 	clusterConfig := clusterconfig.NewEmpty()
 
-	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, clusterConfig, servers)
+	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, clusterConfig, servers, nil)
 	c.Assert(err, IsNil)
 	c.Assert(plan, compare.DeepEquals, &storage.OperationPlan{
 		OperationID:   operation.ID,
@@ -282,6 +285,7 @@ func (S) TestMultiNodePlan(c *C) {
 							{
 								ID:          "/masters/node-1/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-1"`,
 								Data: &storage.OperationPhaseData{
 									Server: &servers[0],
@@ -370,6 +374,7 @@ func (S) TestMultiNodePlan(c *C) {
 							{
 								ID:          "/masters/node-3/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-3"`,
 								Data: &storage.OperationPhaseData{
 									Server: &servers[2],
@@ -496,7 +501,7 @@ kind: KubeletConfiguration
 address: "0.0.0.0"`),
 	}
 
-	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, clusterConfig, servers)
+	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, clusterConfig, servers, nil)
 	c.Assert(err, IsNil)
 	c.Assert(plan, compare.DeepEquals, &storage.OperationPlan{
 		OperationID:   operation.ID,
@@ -549,6 +554,7 @@ address: "0.0.0.0"`),
 							{
 								ID:          "/masters/node-1/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-1"`,
 								Data: &storage.OperationPhaseData{
 									Server: &servers[0],
@@ -630,6 +636,7 @@ address: "0.0.0.0"`),
 							{
 								ID:          "/nodes/node-2/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-2"`,
 								Data: &storage.OperationPhaseData{
 									Server:     &servers[1],
@@ -709,14 +716,156 @@ address: "0.0.0.0"`),
 	})
 }
 
+func (S) TestNetworkChange(c *C) {
+	installed := storage.Subnets{Overlay: "10.244.0.0/16", Service: "10.100.0.0/16"}
+	newConfig := func(podCIDR, serviceCIDR string) clusterconfig.Interface {
+		return clusterconfig.New(clusterconfig.Spec{
+			Global: &clusterconfig.Global{
+				PodCIDR:     podCIDR,
+				ServiceCIDR: serviceCIDR,
+			},
+		})
+	}
+	var testCases = []struct {
+		comment    string
+		prevConfig clusterconfig.Interface
+		config     clusterconfig.Interface
+		expected   *storage.NetworkChange
+	}{
+		{
+			comment:    "no network change",
+			prevConfig: clusterconfig.NewEmpty(),
+			config:     newConfig("", ""),
+		},
+		{
+			comment:    "explicit networks same as installed",
+			prevConfig: clusterconfig.NewEmpty(),
+			config:     newConfig("10.244.0.0/16", "10.100.0.0/16"),
+		},
+		{
+			comment:    "pod network change",
+			prevConfig: clusterconfig.NewEmpty(),
+			config:     newConfig("10.200.0.0/16", ""),
+			expected: &storage.NetworkChange{
+				PrevPodCIDR:     "10.244.0.0/16",
+				PodCIDR:         "10.200.0.0/16",
+				PrevServiceCIDR: "10.100.0.0/16",
+				ServiceCIDR:     "10.100.0.0/16",
+			},
+		},
+		{
+			comment:    "reset to installed networks",
+			prevConfig: newConfig("10.200.0.0/16", "10.210.0.0/16"),
+			config:     clusterconfig.NewEmpty(),
+			expected: &storage.NetworkChange{
+				PrevPodCIDR:     "10.200.0.0/16",
+				PodCIDR:         "10.244.0.0/16",
+				PrevServiceCIDR: "10.210.0.0/16",
+				ServiceCIDR:     "10.100.0.0/16",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		comment := Commentf(tc.comment)
+		c.Assert(networkChange(installed, tc.prevConfig, tc.config), compare.DeepEquals, tc.expected, comment)
+	}
+}
+
+func (S) TestBuildsPlanWithNetworkChange(c *C) {
+	operation := ops.SiteOperation{
+		ID:         "1",
+		AccountID:  "0",
+		Type:       ops.OperationUpdateConfig,
+		SiteDomain: "cluster",
+	}
+	servers := []storage.Server{
+		{Hostname: "node-1", Role: "node", ClusterRole: string(schema.ServiceRoleMaster)},
+		{Hostname: "node-2", Role: "knode", ClusterRole: string(schema.ServiceRoleNode)},
+	}
+	runtimeLoc := loc.Locator{Repository: "foo", Name: "runtime", Version: "0.0.1"}
+	app := app.Application{
+		Package: loc.MustParseLocator("gravitational.io/app:0.0.1"),
+		Manifest: schema.Manifest{
+			NodeProfiles: schema.NodeProfiles{
+				{
+					Name:        "node",
+					ServiceRole: "master",
+				},
+				{
+					Name:        "knode",
+					ServiceRole: "node",
+				},
+			},
+			SystemOptions: &schema.SystemOptions{
+				Dependencies: schema.SystemDependencies{
+					Runtime: &schema.Dependency{Locator: runtimeLoc},
+				},
+			},
+		},
+	}
+	network := &storage.NetworkChange{
+		PrevPodCIDR:     "10.244.0.0/16",
+		PodCIDR:         "10.200.0.0/16",
+		PrevServiceCIDR: "10.100.0.0/16",
+		ServiceCIDR:     "10.210.0.0/16",
+	}
+	clusterConfig := clusterconfig.New(clusterconfig.Spec{
+		Global: &clusterconfig.Global{
+			PodCIDR:     network.PodCIDR,
+			ServiceCIDR: network.ServiceCIDR,
+		},
+	})
+
+	operator := &recordingRotator{testRotator: testOperator}
+	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, operator, operation, clusterConfig, servers, network)
+	c.Assert(err, IsNil)
+
+	var ids []string
+	requires := make(map[string][]string)
+	for _, phase := range plan.Phases {
+		ids = append(ids, phase.ID)
+		requires[phase.ID] = phase.Requires
+	}
+	c.Assert(ids, DeepEquals, []string{"/update-config", "/network", "/masters", "/nodes", "/services", "/pods"})
+	c.Assert(requires["/masters"], DeepEquals, []string{"/update-config", "/network"})
+	c.Assert(requires["/services"], DeepEquals, []string{"/masters", "/nodes"})
+	c.Assert(requires["/pods"], DeepEquals, []string{"/masters", "/nodes", "/services"})
+
+	updates := plan.Phases[0].Data.Update
+	c.Assert(updates.Network, DeepEquals, network)
+	c.Assert(updates.Servers, HasLen, 2)
+	c.Assert(updates.Servers[0].Runtime.SecretsPackage, DeepEquals, &testOperator.secretsPackage)
+	c.Assert(updates.Servers[1].Runtime.SecretsPackage, IsNil)
+	c.Assert(operator.secretsRequests, HasLen, 1)
+	c.Assert(operator.secretsRequests[0].Server, DeepEquals, servers[0])
+	c.Assert(operator.secretsRequests[0].ServiceCIDR, Equals, network.ServiceCIDR)
+}
+
+func (r testRotator) RotateSecrets(ops.RotateSecretsRequest) (*ops.RotatePackageResponse, error) {
+	return &ops.RotatePackageResponse{Locator: r.secretsPackage}, nil
+}
+
 func (r testRotator) RotatePlanetConfig(ops.RotatePlanetConfigRequest) (*ops.RotatePackageResponse, error) {
 	return &ops.RotatePackageResponse{Locator: r.runtimeConfigPackage}, nil
 }
 
 var testOperator = testRotator{
 	runtimeConfigPackage: loc.Locator{Repository: "gravitational.io", Name: "planet-config", Version: "0.0.1"},
+	secretsPackage:       loc.Locator{Repository: "gravitational.io", Name: "planet-secrets", Version: "0.0.1"},
 }
 
 type testRotator struct {
 	runtimeConfigPackage loc.Locator
+	secretsPackage       loc.Locator
+}
+
+// recordingRotator records the secrets rotation requests
+type recordingRotator struct {
+	testRotator
+	secretsRequests []ops.RotateSecretsRequest
+}
+
+func (r *recordingRotator) RotateSecrets(req ops.RotateSecretsRequest) (*ops.RotatePackageResponse, error) {
+	r.secretsRequests = append(r.secretsRequests, req)
+	return r.testRotator.RotateSecrets(req)
 }
@@ -148,6 +148,8 @@ func (r *Engine) ChangePhaseState(ctx context.Context, change fsm.StateChange) e
 		PhaseID:     change.Phase,
 		NewState:    change.State,
 		Error:       utils.ToRawTrace(change.Error),
+		Transient:   change.IsTransient(),
+		Logs:        change.Logs,
 		Created:     time.Now().UTC(),
 	})
 	if err != nil {
@@ -26,12 +26,14 @@ import (
 	"github.com/gravitational/trace"
 )
 
-// NewOperationPlan creates a new operation plan for the specified operation
+// NewOperationPlan creates a new operation plan for the specified operation.
+// Servers with hostnames or advertise IPs listed in skipNodes are excluded from the plan
 func NewOperationPlan(
 	operator ops.Operator,
 	apps app.Applications,
 	operation ops.SiteOperation,
 	servers []storage.Server,
+	skipNodes []string,
 ) (plan *storage.OperationPlan, err error) {
 	cluster, err := operator.GetLocalSite()
 	if err != nil {
@@ -41,7 +43,11 @@ func NewOperationPlan(
 	if err != nil {
 		return nil, trace.Wrap(err, "failed to query installed application")
 	}
-	plan, err = newOperationPlan(*app, cluster.DNSConfig, operator, operation, servers)
+	servers, skipped, err := update.SkipServers(servers, skipNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plan, err = newOperationPlan(*app, cluster.DNSConfig, operator, operation, servers, skipped)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -58,13 +64,14 @@ func NewOperationPlan(
 }
 
 // newOperationPlan returns a new plan for the specified operation
-// and the given set of servers
+// and the given set of servers.
+// skipped lists the servers excluded from the operation
 func newOperationPlan(
 	app app.Application,
 	dnsConfig storage.DNSConfig,
 	operator rollingupdate.ConfigPackageRotator,
 	operation ops.SiteOperation,
-	servers []storage.Server,
+	servers, skipped []storage.Server,
 ) (*storage.OperationPlan, error) {
 	builder := rollingupdate.Builder{App: app.Package}
 	configUpdates, err := rollingupdate.RuntimeConfigUpdates(app.Manifest, operator, operation.Key(), servers)
@@ -92,13 +99,14 @@ func newOperationPlan(
 	}
 
 	plan := &storage.OperationPlan{
-		OperationID:   operation.ID,
-		OperationType: operation.Type,
-		AccountID:     operation.AccountID,
-		ClusterName:   operation.SiteDomain,
-		Phases:        phases.AsPhases(),
-		Servers:       servers,
-		DNSConfig:     dnsConfig,
+		OperationID:    operation.ID,
+		OperationType:  operation.Type,
+		AccountID:      operation.AccountID,
+		ClusterName:    operation.SiteDomain,
+		Phases:         phases.AsPhases(),
+		Servers:        servers,
+		SkippedServers: skipped,
+		DNSConfig:      dnsConfig,
 	}
 	update.ResolvePlan(plan)
 
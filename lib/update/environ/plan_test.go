@@ -21,10 +21,12 @@ import (
 
 	"github.com/gravitational/gravity/lib/app"
 	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
 	libphase "github.com/gravitational/gravity/lib/update/internal/rollingupdate/phases"
 
 	. "gopkg.in/check.v1"
@@ -64,7 +66,7 @@ func (S) TestSingleNodePlan(c *C) {
 		},
 	}
 
-	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, servers)
+	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, servers, nil)
 	c.Assert(err, IsNil)
 	c.Assert(plan, compare.DeepEquals, &storage.OperationPlan{
 		OperationID:   operation.ID,
@@ -107,6 +109,7 @@ func (S) TestSingleNodePlan(c *C) {
 							{
 								ID:          "/masters/node-1/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-1"`,
 								Data: &storage.OperationPhaseData{
 									Server: &servers[0],
@@ -217,7 +220,7 @@ func (S) TestMultiNodePlan(c *C) {
 		},
 	}
 
-	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, servers)
+	plan, err := newOperationPlan(app, storage.DefaultDNSConfig, testOperator, operation, servers, nil)
 	c.Assert(err, IsNil)
 	c.Assert(plan, compare.DeepEquals, &storage.OperationPlan{
 		OperationID:   operation.ID,
@@ -301,6 +304,7 @@ func (S) TestMultiNodePlan(c *C) {
 							{
 								ID:          "/masters/node-1/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-1"`,
 								Data: &storage.OperationPhaseData{
 									Server: &servers[0],
@@ -390,6 +394,7 @@ func (S) TestMultiNodePlan(c *C) {
 							{
 								ID:          "/masters/node-3/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-3"`,
 								Data: &storage.OperationPhaseData{
 									Server: &servers[2],
@@ -484,6 +489,7 @@ func (S) TestMultiNodePlan(c *C) {
 							{
 								ID:          "/nodes/node-2/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-2"`,
 								Data: &storage.OperationPhaseData{
 									Server:     &servers[1],
@@ -563,6 +569,7 @@ func (S) TestMultiNodePlan(c *C) {
 							{
 								ID:          "/nodes/node-4/drain",
 								Executor:    libphase.Drain,
+								Timeout:     update.Timeout(defaults.DrainPhaseTimeout),
 								Description: `Drain node "node-4"`,
 								Data: &storage.OperationPhaseData{
 									Server:     &servers[3],
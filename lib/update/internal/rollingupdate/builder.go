@@ -3,6 +3,7 @@ package rollingupdate
 import (
 	"fmt"
 
+	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/schema"
@@ -192,6 +193,7 @@ func (r Builder) endpoints(server, execer *storage.Server) update.Phase {
 func (r Builder) drain(server, execer *storage.Server) update.Phase {
 	node := r.node("drain", "Drain node %q", server.Hostname)
 	node.Executor = libphase.Drain
+	node.Timeout = update.Timeout(defaults.DrainPhaseTimeout)
 	node.Data = &storage.OperationPhaseData{
 		Server: server,
 	}
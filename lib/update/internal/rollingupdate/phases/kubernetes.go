@@ -23,6 +23,7 @@ import (
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/fsm"
 	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/update"
@@ -99,15 +100,21 @@ func NewDrain(params libfsm.ExecutorParams, client *kubeapi.Clientset, logger lo
 	}
 	return &drainer{
 		kubernetesOperation: *op,
+		dnsConfig:           params.Plan.DNSConfig,
 	}, nil
 }
 
 // Execute drains the specified node
 func (p *drainer) Execute(ctx context.Context) error {
 	p.Infof("Drain %v.", p.Server)
+	kubernetes.CheckHeadroom(p.Client, p.Server.KubeNodeID(), p.FieldLogger)
 	ctx, cancel := context.WithTimeout(ctx, defaults.DrainTimeout)
 	defer cancel()
-	err := retry(ctx, func() error {
+	err := runPreDrainHooks(ctx, p.Client, p.dnsConfig, p.Server.KubeNodeID())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = retry(ctx, func() error {
 		return trace.Wrap(drain(ctx, p.Client, p.Server.KubeNodeID()))
 	}, defaults.DrainErrorTimeout)
 	return trace.Wrap(err)
@@ -234,6 +241,17 @@ func taint(ctx context.Context, client corev1.NodeInterface, node string, add ad
 	return nil
 }
 
+// runPreDrainHooks executes the pre-drain commands of the pods on the specified node
+// before the node is drained
+func runPreDrainHooks(ctx context.Context, client *kubeapi.Clientset, dnsConfig storage.DNSConfig, node string) error {
+	_, config, err := httplib.GetClusterKubeClient(dnsConfig.Addr())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = kubernetes.RunPreDrainHooks(ctx, client, kubernetes.NewPodExecutor(client, config), node)
+	return trace.Wrap(err)
+}
+
 func drain(ctx context.Context, client *kubeapi.Clientset, node string) error {
 	err := kubernetes.Drain(ctx, client, node)
 	return trace.Wrap(err)
@@ -263,6 +281,8 @@ type untainter struct {
 // drainer defines the operation of draining a node
 type drainer struct {
 	kubernetesOperation
+	// dnsConfig specifies the cluster DNS configuration
+	dnsConfig storage.DNSConfig
 }
 
 // uncordoner defines the operation of uncordoning a node
@@ -67,19 +67,25 @@ func (r *restart) Execute(ctx context.Context) error {
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	updater, err := system.New(system.Config{
-		ChangesetID: r.operationID,
-		Backend:     r.backend,
-		Packages:    r.localPackages,
-		PackageUpdates: system.PackageUpdates{
-			Runtime: storage.PackageUpdate{
-				From: r.update.Runtime.Installed,
-				To:   r.update.Runtime.Update.Package,
-				ConfigPackage: &storage.PackageUpdate{
-					To: r.update.Runtime.Update.ConfigPackage,
-				},
+	updates := system.PackageUpdates{
+		Runtime: storage.PackageUpdate{
+			From: r.update.Runtime.Installed,
+			To:   r.update.Runtime.Update.Package,
+			ConfigPackage: &storage.PackageUpdate{
+				To: r.update.Runtime.Update.ConfigPackage,
 			},
 		},
+	}
+	if r.update.Runtime.SecretsPackage != nil {
+		updates.RuntimeSecrets = &storage.PackageUpdate{
+			To: *r.update.Runtime.SecretsPackage,
+		}
+	}
+	updater, err := system.New(system.Config{
+		ChangesetID:    r.operationID,
+		Backend:        r.backend,
+		Packages:       r.localPackages,
+		PackageUpdates: updates,
 	})
 	if err != nil {
 		return trace.Wrap(err)
@@ -115,6 +121,9 @@ func (*restart) PostCheck(context.Context) error {
 
 func (r *restart) pullUpdates() error {
 	updates := []loc.Locator{r.update.Runtime.Update.Package, r.update.Runtime.Update.ConfigPackage}
+	if r.update.Runtime.SecretsPackage != nil {
+		updates = append(updates, *r.update.Runtime.SecretsPackage)
+	}
 	for _, update := range updates {
 		r.Infof("Pulling package update: %v.", update)
 		_, err := libapp.PullPackage(libapp.PackagePullRequest{
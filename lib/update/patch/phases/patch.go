@@ -0,0 +1,172 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package phases
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/rpc"
+	rpcclient "github.com/gravitational/gravity/lib/rpc/client"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+)
+
+// NewPatch returns a new executor that patches the operating system
+// on the node specified with params using the node's RPC agent
+func NewPatch(params libfsm.ExecutorParams, operation ops.SiteOperation, logger log.FieldLogger) (*patch, error) {
+	if params.Phase.Data == nil || params.Phase.Data.Server == nil {
+		return nil, trace.NotFound("no server specified for phase %q", params.Phase.ID)
+	}
+	if operation.Patch == nil {
+		return nil, trace.BadParameter("operation %v does not describe the patch", operation.ID)
+	}
+	return &patch{
+		FieldLogger: logger,
+		server:      *params.Phase.Data.Server,
+		patch:       *operation.Patch,
+	}, nil
+}
+
+// Execute runs the patch command on the node or reboots it.
+// After a reboot, it waits for the node's agent to come back
+func (r *patch) Execute(ctx context.Context) error {
+	creds, err := libfsm.GetClientCredentials()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	agent, err := r.connect(ctx, creds)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer agent.Close()
+	if !r.patch.Reboot {
+		r.Infof("Run %q on %v.", r.patch.Command, r.server)
+		var out bytes.Buffer
+		err = agent.Command(ctx, r, &out, "/bin/sh", "-c", r.patch.Command)
+		if err != nil {
+			return trace.Wrap(err, "patch command failed on node %v: %s", r.server.Hostname, out.String())
+		}
+		r.WithField("output", out.String()).Info("Patch command completed.")
+		return nil
+	}
+	bootID, err := getBootID(ctx, agent, r)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	r.Infof("Reboot %v.", r.server)
+	err = agent.Command(ctx, r, nil, "systemd-run",
+		fmt.Sprintf("--on-active=%v", int(defaults.NodeRebootDelay.Seconds())),
+		"/bin/systemctl", "reboot")
+	if err != nil {
+		return trace.Wrap(err, "failed to reboot node %v", r.server.Hostname)
+	}
+	agent.Close()
+	return trace.Wrap(r.waitForReboot(ctx, creds, bootID))
+}
+
+// Rollback is a no-op for this phase
+func (*patch) Rollback(context.Context) error {
+	return nil
+}
+
+// PreCheck makes sure the patch is executed from a node other than the one being rebooted
+func (r *patch) PreCheck(context.Context) error {
+	if !r.patch.Reboot {
+		return nil
+	}
+	err := systeminfo.HasInterface(r.server.AdvertiseIP)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	return trace.BadParameter("node %v cannot reboot itself, execute this phase from another node",
+		r.server.Hostname)
+}
+
+// PostCheck is a no-op
+func (*patch) PostCheck(context.Context) error {
+	return nil
+}
+
+// waitForReboot waits until the node's agent is reachable again
+// and reports a boot ID other than bootID
+func (r *patch) waitForReboot(ctx context.Context, creds credentials.TransportCredentials, bootID string) error {
+	r.Infof("Wait for %v to reboot.", r.server)
+	b := utils.NewExponentialBackOff(defaults.NodeRebootTimeout)
+	err := utils.RetryWithInterval(ctx, b, func() error {
+		ctx, cancel := context.WithTimeout(ctx, defaults.DialTimeout)
+		defer cancel()
+		agent, err := r.connect(ctx, creds)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		defer agent.Close()
+		newBootID, err := getBootID(ctx, agent, r)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if newBootID == bootID {
+			return trace.CompareFailed("node %v has not rebooted yet", r.server.Hostname)
+		}
+		return nil
+	})
+	return trace.Wrap(err)
+}
+
+func (r *patch) connect(ctx context.Context, creds credentials.TransportCredentials) (rpcclient.Client, error) {
+	agent, err := rpcclient.New(ctx, rpcclient.Config{
+		ServerAddr:  rpc.AgentAddr(r.server.AdvertiseIP),
+		Credentials: creds,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to connect to the agent on node %v", r.server.Hostname)
+	}
+	return agent, nil
+}
+
+// getBootID returns the ID of the current boot of the node the agent is running on
+func getBootID(ctx context.Context, agent rpcclient.Client, logger log.FieldLogger) (string, error) {
+	var out bytes.Buffer
+	err := agent.Command(ctx, logger, &out, "cat", bootIDPath)
+	if err != nil {
+		return "", trace.Wrap(err, "failed to query boot ID: %s", out.String())
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+type patch struct {
+	// FieldLogger specifies the logger for the phase
+	log.FieldLogger
+	server storage.Server
+	patch  storage.PatchOperationState
+}
+
+// bootIDPath is the path to the kernel file with the ID of the current boot
+const bootIDPath = "/proc/sys/kernel/random/boot_id"
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patch
+
+import (
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+	libphase "github.com/gravitational/gravity/lib/update/internal/rollingupdate/phases"
+	"github.com/gravitational/gravity/lib/update/patch/phases"
+
+	"github.com/gravitational/trace"
+)
+
+// NewOperationPlan creates a new operation plan for the specified operation.
+// All phases are executed from the leader node which drives the operation.
+// Servers with hostnames or advertise IPs listed in skipNodes are excluded from the plan
+func NewOperationPlan(
+	operator ops.Operator,
+	operation ops.SiteOperation,
+	leader storage.Server,
+	servers []storage.Server,
+	skipNodes []string,
+) (plan *storage.OperationPlan, err error) {
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	servers, skipped, err := update.SkipServers(servers, skipNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plan, err = newOperationPlan(cluster.DNSConfig, operation, leader, servers, skipped)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = operator.CreateOperationPlan(operation.Key(), *plan)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotImplemented(
+				"cluster operator does not implement the API required to patch cluster nodes. " +
+					"Please make sure you're running the command on a compatible cluster.")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return plan, nil
+}
+
+// newOperationPlan returns a new plan for the specified operation
+// and the given set of servers.
+// skipped lists the servers excluded from the operation
+func newOperationPlan(
+	dnsConfig storage.DNSConfig,
+	operation ops.SiteOperation,
+	leader storage.Server,
+	servers, skipped []storage.Server,
+) (*storage.OperationPlan, error) {
+	if operation.Patch == nil {
+		return nil, trace.BadParameter("operation %v does not describe the patch", operation.ID)
+	}
+	if operation.Patch.Reboot {
+		for _, server := range servers {
+			if server.AdvertiseIP == leader.AdvertiseIP {
+				return nil, trace.BadParameter("node %v drives the operation and cannot be rebooted by it, "+
+					"exclude it from the operation and patch it from another master node", server.Hostname)
+			}
+		}
+	}
+	masters, nodes := libfsm.SplitServers(servers)
+	builder := builder{leader: leader}
+	var phases update.Phases
+	if len(masters) != 0 {
+		phases = append(phases, *builder.nodes("masters", "Patch master nodes", masters))
+	}
+	if len(nodes) != 0 {
+		patchNodes := *builder.nodes("nodes", "Patch regular nodes", nodes)
+		if len(masters) != 0 {
+			patchNodes.Require(phases[0])
+		}
+		phases = append(phases, patchNodes)
+	}
+	if len(phases) == 0 {
+		return nil, trace.NotFound("no nodes to patch")
+	}
+
+	plan := &storage.OperationPlan{
+		OperationID:    operation.ID,
+		OperationType:  operation.Type,
+		AccountID:      operation.AccountID,
+		ClusterName:    operation.SiteDomain,
+		Phases:         phases.AsPhases(),
+		Servers:        servers,
+		SkippedServers: skipped,
+		DNSConfig:      dnsConfig,
+	}
+	update.ResolvePlan(plan)
+
+	return plan, nil
+}
+
+// nodes returns a new phase to patch the specified servers one at a time
+func (r builder) nodes(id, rootText string, servers []storage.Server) *update.Phase {
+	root := update.RootPhase(update.Phase{
+		ID:          id,
+		Description: rootText,
+	})
+	for i := range servers {
+		node := update.Phase{
+			ID:          servers[i].Hostname,
+			Description: fmt.Sprintf("Patch node %q", servers[i].Hostname),
+		}
+		node.AddSequential(
+			r.phase(libphase.Drain, "Drain node %q", servers[i]),
+			r.phase(phases.Patch, "Patch node %q", servers[i]),
+			r.phase(phases.Health, "Wait for node %q to become healthy", servers[i]),
+			r.phase(libphase.Uncordon, "Uncordon node %q", servers[i]),
+		)
+		root.AddSequential(node)
+	}
+	return &root
+}
+
+// phase returns a new phase with the specified executor that targets
+// the given server and runs on the leader node
+func (r builder) phase(executor, format string, server storage.Server) update.Phase {
+	phase := update.Phase{
+		ID:          executor,
+		Executor:    executor,
+		Description: fmt.Sprintf(format, server.Hostname),
+		Data: &storage.OperationPhaseData{
+			Server:     &server,
+			ExecServer: &r.leader,
+		},
+	}
+	if executor == libphase.Drain {
+		phase.Timeout = update.Timeout(defaults.DrainPhaseTimeout)
+	}
+	return phase
+}
+
+// builder builds the node patch operation plan
+type builder struct {
+	// leader is the server driving the operation
+	leader storage.Server
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+	libphase "github.com/gravitational/gravity/lib/update/internal/rollingupdate/phases"
+	"github.com/gravitational/gravity/lib/update/patch/phases"
+
+	"github.com/gravitational/trace"
+	. "gopkg.in/check.v1"
+)
+
+func TestPatch(t *testing.T) { TestingT(t) }
+
+type S struct{}
+
+var _ = Suite(&S{})
+
+func (S) TestPlanPatchesMastersFirst(c *C) {
+	operation := newOperation(storage.PatchOperationState{Command: "yum update -y"})
+	servers := []storage.Server{master1, node1, master2}
+
+	plan, err := newOperationPlan(storage.DefaultDNSConfig, operation, master1, servers, nil)
+	c.Assert(err, IsNil)
+	c.Assert(plan, compare.DeepEquals, &storage.OperationPlan{
+		OperationID:   operation.ID,
+		OperationType: operation.Type,
+		AccountID:     operation.AccountID,
+		ClusterName:   operation.SiteDomain,
+		Servers:       servers,
+		DNSConfig:     storage.DefaultDNSConfig,
+		Phases: []storage.OperationPhase{
+			{
+				ID:          "/masters",
+				Description: "Patch master nodes",
+				Phases: []storage.OperationPhase{
+					nodePhase("/masters", master1),
+					nodePhase("/masters", master2, "/masters/master-1"),
+				},
+			},
+			{
+				ID:          "/nodes",
+				Description: "Patch regular nodes",
+				Requires:    []string{"/masters"},
+				Phases: []storage.OperationPhase{
+					nodePhase("/nodes", node1),
+				},
+			},
+		},
+	})
+}
+
+func (S) TestPlanRejectsRebootOfLeader(c *C) {
+	operation := newOperation(storage.PatchOperationState{Reboot: true})
+
+	_, err := newOperationPlan(storage.DefaultDNSConfig, operation, master1,
+		[]storage.Server{master1, master2}, nil)
+	c.Assert(trace.IsBadParameter(err), Equals, true, Commentf("%v", err))
+
+	plan, err := newOperationPlan(storage.DefaultDNSConfig, operation, master1,
+		[]storage.Server{master2}, []storage.Server{master1})
+	c.Assert(err, IsNil)
+	c.Assert(plan.Phases, HasLen, 1)
+	c.Assert(plan.SkippedServers, compare.DeepEquals, []storage.Server{master1})
+}
+
+func nodePhase(parent string, server storage.Server, requires ...string) storage.OperationPhase {
+	id := parent + "/" + server.Hostname
+	phase := func(executor, description string, requires ...string) storage.OperationPhase {
+		return storage.OperationPhase{
+			ID:          id + "/" + executor,
+			Executor:    executor,
+			Description: description,
+			Requires:    requires,
+			Data: &storage.OperationPhaseData{
+				Server:     &server,
+				ExecServer: &master1,
+			},
+		}
+	}
+	drain := phase(libphase.Drain, `Drain node "`+server.Hostname+`"`)
+	drain.Timeout = update.Timeout(defaults.DrainPhaseTimeout)
+	return storage.OperationPhase{
+		ID:          id,
+		Description: `Patch node "` + server.Hostname + `"`,
+		Requires:    requires,
+		Phases: []storage.OperationPhase{
+			drain,
+			phase(phases.Patch, `Patch node "`+server.Hostname+`"`, id+"/drain"),
+			phase(phases.Health, `Wait for node "`+server.Hostname+`" to become healthy`, id+"/patch"),
+			phase(libphase.Uncordon, `Uncordon node "`+server.Hostname+`"`, id+"/health"),
+		},
+	}
+}
+
+func newOperation(patch storage.PatchOperationState) ops.SiteOperation {
+	return ops.SiteOperation{
+		ID:         "1",
+		AccountID:  "0",
+		Type:       ops.OperationPatch,
+		SiteDomain: "cluster",
+		Patch:      &patch,
+	}
+}
+
+var (
+	master1 = storage.Server{
+		Hostname:    "master-1",
+		AdvertiseIP: "192.168.1.1",
+		ClusterRole: string(schema.ServiceRoleMaster),
+	}
+	master2 = storage.Server{
+		Hostname:    "master-2",
+		AdvertiseIP: "192.168.1.2",
+		ClusterRole: string(schema.ServiceRoleMaster),
+	}
+	node1 = storage.Server{
+		Hostname:    "node-1",
+		AdvertiseIP: "192.168.1.3",
+		ClusterRole: string(schema.ServiceRoleNode),
+	}
+)
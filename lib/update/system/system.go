@@ -91,7 +91,7 @@ func (r *System) Update(ctx context.Context, withStatus bool) error {
 		return trace.Wrap(err)
 	}
 
-	err = r.applyUpdates(changes)
+	err = r.applyUpdates(ctx, changes)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -135,7 +135,7 @@ func (r *System) Rollback(ctx context.Context, withStatus bool) (err error) {
 		return trace.Wrap(err)
 	}
 
-	err = r.applyUpdates(changes)
+	err = r.applyUpdates(ctx, changes)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -274,9 +274,13 @@ func (r *System) reinstallPackage(update storage.PackageUpdate) ([]pack.LabelUpd
 	return nil, trace.BadParameter("unsupported package: %v", update.To)
 }
 
-func (r *System) applyUpdates(updates []storage.PackageUpdate) error {
+func (r *System) applyUpdates(ctx context.Context, updates []storage.PackageUpdate) error {
 	var errors []error
 	for _, u := range updates {
+		if ctx.Err() != nil {
+			// Do not start new updates once canceled
+			return trace.NewAggregate(append(errors, ctx.Err())...)
+		}
 		r.WithField("update", u).Info("Applying.")
 		err := r.blockingReinstall(u)
 		if err != nil {
@@ -171,6 +171,11 @@ func (r *Updater) executePlan(ctx context.Context) error {
 	defer progress.Stop()
 
 	planErr := r.machine.ExecutePlan(ctx, progress)
+	if fsm.IsPaused(planErr) {
+		// Keep the operation active and the agents running until resumed
+		r.WithError(planErr).Info("Paused plan execution.")
+		return trace.Wrap(planErr)
+	}
 	if planErr != nil {
 		r.WithError(planErr).Warn("Failed to execute plan.")
 	}
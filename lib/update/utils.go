@@ -107,6 +107,40 @@ func SplitServers(servers []storage.UpdateServer) (masters, nodes []storage.Upda
 	return masters, nodes
 }
 
+// SkipServers splits the specified server list into servers to operate on
+// and servers that have been explicitly excluded from the operation.
+// A server is excluded if its hostname or advertise IP is in skip.
+func SkipServers(servers []storage.Server, skip []string) (included, skipped []storage.Server, err error) {
+	matched := make(map[string]bool, len(skip))
+	for _, server := range servers {
+		name := matchServer(server, skip)
+		if name == "" {
+			included = append(included, server)
+			continue
+		}
+		matched[name] = true
+		skipped = append(skipped, server)
+	}
+	for _, name := range skip {
+		if !matched[name] {
+			return nil, nil, trace.NotFound("node %q is not a member of the cluster", name)
+		}
+	}
+	if len(skipped) != 0 && len(storage.Servers(included).Masters()) == 0 {
+		return nil, nil, trace.BadParameter("cannot exclude all master nodes from the operation")
+	}
+	return included, skipped, nil
+}
+
+func matchServer(server storage.Server, names []string) string {
+	for _, name := range names {
+		if name == server.Hostname || name == server.AdvertiseIP {
+			return name
+		}
+	}
+	return ""
+}
+
 func hasEndpoints(client corev1.CoreV1Interface, labels labels.Set, fn endpointMatchFn) error {
 	list, err := client.Endpoints(metav1.NamespaceSystem).List(
 		metav1.ListOptions{
@@ -0,0 +1,57 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package update
+
+import (
+	"testing"
+
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func TestUpdate(t *testing.T) { check.TestingT(t) }
+
+type UtilsSuite struct{}
+
+var _ = check.Suite(&UtilsSuite{})
+
+func (s *UtilsSuite) TestSkipsServers(c *check.C) {
+	servers := []storage.Server{
+		{AdvertiseIP: "192.168.0.1", Hostname: "node-1", ClusterRole: string(schema.ServiceRoleMaster)},
+		{AdvertiseIP: "192.168.0.2", Hostname: "node-2", ClusterRole: string(schema.ServiceRoleMaster)},
+		{AdvertiseIP: "192.168.0.3", Hostname: "node-3", ClusterRole: string(schema.ServiceRoleNode)},
+	}
+
+	included, skipped, err := SkipServers(servers, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(included, check.DeepEquals, servers)
+	c.Assert(skipped, check.HasLen, 0)
+
+	included, skipped, err = SkipServers(servers, []string{"node-2", "192.168.0.3"})
+	c.Assert(err, check.IsNil)
+	c.Assert(included, check.DeepEquals, servers[:1])
+	c.Assert(skipped, check.DeepEquals, servers[1:])
+
+	_, _, err = SkipServers(servers, []string{"node-4"})
+	c.Assert(trace.IsNotFound(err), check.Equals, true, check.Commentf("%v", err))
+
+	_, _, err = SkipServers(servers, []string{"node-1", "node-2"})
+	c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf("%v", err))
+}
@@ -48,7 +48,11 @@ func GetBuiltinRoles() ([]teleservices.Role, error) {
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	return []teleservices.Role{admin, reader}, nil
+	readOnly, err := NewReadOnlyRole()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return []teleservices.Role{admin, reader, readOnly}, nil
 }
 
 // NewSystemRole creates a role with system label
@@ -188,6 +192,35 @@ func NewReaderRole() (teleservices.Role, error) {
 	})
 }
 
+// NewReadOnlyRole returns new role that allows to view the cluster status,
+// operation plans and logs, and cluster resources, but not to modify them
+func NewReadOnlyRole() (teleservices.Role, error) {
+	return NewSystemRole(constants.RoleReadOnly, teleservices.RoleSpecV3{
+		Allow: teleservices.RoleConditions{
+			Namespaces: []string{defaults.Namespace},
+			Logins:     noLogins(),
+			Rules: []teleservices.Rule{
+				{
+					Resources: []string{
+						storage.KindCluster,
+						storage.KindApp,
+						storage.KindRepository,
+						storage.KindLogForwarder,
+						storage.KindAlert,
+						storage.KindAlertTarget,
+						storage.KindHealthReport,
+						storage.KindRuntimeEnvironment,
+						storage.KindClusterConfiguration,
+						storage.KindInvite,
+						teleservices.KindRole,
+					},
+					Verbs: []string{teleservices.VerbList, teleservices.VerbRead},
+				},
+			},
+		},
+	})
+}
+
 // NewAdminRole returns new admin type role
 func NewAdminRole() (teleservices.Role, error) {
 	// Use current user for login if available
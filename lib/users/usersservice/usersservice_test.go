@@ -432,6 +432,64 @@ func (s *UsersSuite) TestBuiltinRoles(c *C) {
 				},
 			},
 		},
+		{
+			name: "2 - read-only role can view but not modify the cluster",
+			roles: []teleservices.Role{
+				MustCreateReadOnlyRole(),
+			},
+			checks: []check{
+				{
+					context: &users.Context{
+						Context: teleservices.Context{
+							Resource: storage.NewCluster("example.com"),
+						},
+					},
+					rule:      storage.KindCluster,
+					verb:      teleservices.VerbRead,
+					namespace: teledefaults.Namespace,
+					hasAccess: true,
+				},
+				{
+					context: &users.Context{
+						Context: teleservices.Context{
+							Resource: storage.NewCluster("example.com"),
+						},
+					},
+					rule:      storage.KindCluster,
+					verb:      teleservices.VerbUpdate,
+					namespace: teledefaults.Namespace,
+					hasAccess: false,
+				},
+				{
+					context:   &users.Context{},
+					rule:      storage.KindLogForwarder,
+					verb:      teleservices.VerbList,
+					namespace: teledefaults.Namespace,
+					hasAccess: true,
+				},
+				{
+					context:   &users.Context{},
+					rule:      storage.KindLogForwarder,
+					verb:      teleservices.VerbCreate,
+					namespace: teledefaults.Namespace,
+					hasAccess: false,
+				},
+				{
+					context:   &users.Context{},
+					rule:      storage.KindTLSKeyPair,
+					verb:      teleservices.VerbRead,
+					namespace: teledefaults.Namespace,
+					hasAccess: false,
+				},
+				{
+					context:   &users.Context{},
+					rule:      teleservices.KindUser,
+					verb:      teleservices.VerbCreate,
+					namespace: teledefaults.Namespace,
+					hasAccess: false,
+				},
+			},
+		},
 	}
 	for i, tc := range testCases {
 		var set teleservices.RoleSet
@@ -458,6 +516,14 @@ func MustCreateClusterAgent(name string, clusterName string) teleservices.Role {
 	return role
 }
 
+func MustCreateReadOnlyRole() teleservices.Role {
+	role, err := users.NewReadOnlyRole()
+	if err != nil {
+		panic(err)
+	}
+	return role
+}
+
 func findRule(c *C, resource string, rules []teleservices.Rule, verbs ...string) *teleservices.Rule {
 	for _, rule := range rules {
 		if teleutils.SliceContainsStr(rule.Resources, resource) {
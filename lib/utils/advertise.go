@@ -0,0 +1,186 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/gravitational/trace"
+	netutils "k8s.io/apimachinery/pkg/util/net"
+)
+
+// AdvertiseAddrPolicy defines how the advertise address of a node is selected
+// among the host's network interfaces when it is not specified explicitly.
+//
+// All specified criteria must match. Empty policy selects the address
+// of the interface with the default route
+type AdvertiseAddrPolicy struct {
+	// CIDR selects the address from the specified subnet
+	CIDR string `json:"cidr,omitempty"`
+	// Interface is the regular expression the name of the network
+	// interface should match, e.g. ^eth1$
+	Interface string `json:"interface,omitempty"`
+	// DefaultRoute selects the address of the interface with the default route
+	DefaultRoute bool `json:"defaultRoute,omitempty"`
+}
+
+// Check validates the policy
+func (r AdvertiseAddrPolicy) Check() error {
+	if r.CIDR != "" {
+		if _, _, err := net.ParseCIDR(r.CIDR); err != nil {
+			return trace.BadParameter("invalid advertise address subnet %q: %v", r.CIDR, err)
+		}
+	}
+	if r.Interface != "" {
+		if _, err := regexp.Compile(r.Interface); err != nil {
+			return trace.BadParameter("invalid advertise interface expression %q: %v", r.Interface, err)
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true if the policy specifies no criteria
+func (r AdvertiseAddrPolicy) IsEmpty() bool {
+	return r.CIDR == "" && r.Interface == "" && !r.DefaultRoute
+}
+
+// ShellArgs returns the policy as the command line flags of the install
+// and join commands quoted for use in shell scripts
+func (r AdvertiseAddrPolicy) ShellArgs() (args []string) {
+	if r.CIDR != "" {
+		args = append(args, fmt.Sprintf("--advertise-cidr=%v", r.CIDR))
+	}
+	if r.Interface != "" {
+		args = append(args, fmt.Sprintf("--advertise-interface='%v'", r.Interface))
+	}
+	if r.DefaultRoute {
+		args = append(args, "--advertise-default-route")
+	}
+	return args
+}
+
+// String returns a textual representation of the policy
+func (r AdvertiseAddrPolicy) String() string {
+	var criteria []string
+	if r.CIDR != "" {
+		criteria = append(criteria, fmt.Sprintf("cidr=%v", r.CIDR))
+	}
+	if r.Interface != "" {
+		criteria = append(criteria, fmt.Sprintf("interface=%v", r.Interface))
+	}
+	if r.DefaultRoute {
+		criteria = append(criteria, "default-route")
+	}
+	if len(criteria) == 0 {
+		return "default-route"
+	}
+	return strings.Join(criteria, ",")
+}
+
+// PickAdvertiseIPWithPolicy selects an advertise IP among the host's interfaces
+// using the specified policy.
+//
+// If several addresses match the policy, the first one in the order
+// of the host's interfaces is selected
+func PickAdvertiseIPWithPolicy(policy AdvertiseAddrPolicy) (string, error) {
+	if err := policy.Check(); err != nil {
+		return "", trace.Wrap(err)
+	}
+	if policy.IsEmpty() {
+		return PickAdvertiseIP()
+	}
+	addrs, err := hostAddrs()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	var defaultRouteIP net.IP
+	if policy.DefaultRoute {
+		defaultRouteIP, err = netutils.ChooseHostInterface()
+		if err != nil {
+			return "", trace.Wrap(err, "failed to determine the interface with the default route")
+		}
+	}
+	ip, err := selectAdvertiseIP(policy, addrs, defaultRouteIP)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return ip.String(), nil
+}
+
+// selectAdvertiseIP returns the first of the specified host addresses
+// that matches the policy
+func selectAdvertiseIP(policy AdvertiseAddrPolicy, addrs []hostAddr, defaultRouteIP net.IP) (net.IP, error) {
+	var subnet *net.IPNet
+	if policy.CIDR != "" {
+		_, subnet, _ = net.ParseCIDR(policy.CIDR)
+	}
+	var iface *regexp.Regexp
+	if policy.Interface != "" {
+		iface = regexp.MustCompile(policy.Interface)
+	}
+	for _, addr := range addrs {
+		if subnet != nil && !subnet.Contains(addr.ip) {
+			continue
+		}
+		if iface != nil && !iface.MatchString(addr.iface) {
+			continue
+		}
+		if policy.DefaultRoute && !addr.ip.Equal(defaultRouteIP) {
+			continue
+		}
+		return addr.ip, nil
+	}
+	return nil, trace.NotFound("none of the host's network interfaces matches "+
+		"the advertise address policy %v", policy)
+}
+
+// hostAddrs returns IPv4 addresses of the host's network interfaces that are up,
+// excluding the loopback interfaces
+func hostAddrs() (result []hostAddr, err error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			result = append(result, hostAddr{iface: iface.Name, ip: ipNet.IP.To4()})
+		}
+	}
+	return result, nil
+}
+
+// hostAddr is an IP address of a network interface
+type hostAddr struct {
+	// iface is the name of the network interface
+	iface string
+	// ip is the address
+	ip net.IP
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"net"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type AdvertiseSuite struct{}
+
+var _ = check.Suite(&AdvertiseSuite{})
+
+func (s *AdvertiseSuite) TestSelectsAdvertiseIP(c *check.C) {
+	addrs := []hostAddr{
+		{iface: "docker0", ip: net.ParseIP("172.17.0.1").To4()},
+		{iface: "eth0", ip: net.ParseIP("10.0.0.5").To4()},
+		{iface: "eth1", ip: net.ParseIP("192.168.1.5").To4()},
+		{iface: "eth1", ip: net.ParseIP("192.168.2.5").To4()},
+	}
+	defaultRouteIP := net.ParseIP("10.0.0.5")
+	testCases := []struct {
+		policy      AdvertiseAddrPolicy
+		ip          string
+		comment     string
+		outNotFound bool
+	}{
+		{
+			policy:  AdvertiseAddrPolicy{CIDR: "192.168.2.0/24"},
+			ip:      "192.168.2.5",
+			comment: "selects by subnet",
+		},
+		{
+			policy:  AdvertiseAddrPolicy{Interface: "^eth1$"},
+			ip:      "192.168.1.5",
+			comment: "selects the first address of the matching interface",
+		},
+		{
+			policy:  AdvertiseAddrPolicy{Interface: "^eth", CIDR: "192.168.0.0/16"},
+			ip:      "192.168.1.5",
+			comment: "all criteria must match",
+		},
+		{
+			policy:  AdvertiseAddrPolicy{DefaultRoute: true},
+			ip:      "10.0.0.5",
+			comment: "selects the address of the interface with the default route",
+		},
+		{
+			policy:      AdvertiseAddrPolicy{DefaultRoute: true, Interface: "^eth1$"},
+			comment:     "interface with the default route does not match",
+			outNotFound: true,
+		},
+		{
+			policy:      AdvertiseAddrPolicy{CIDR: "10.100.0.0/16"},
+			comment:     "no address in subnet",
+			outNotFound: true,
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		ip, err := selectAdvertiseIP(tc.policy, addrs, defaultRouteIP)
+		if tc.outNotFound {
+			c.Assert(trace.IsNotFound(err), check.Equals, true, comment)
+			continue
+		}
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(ip.String(), check.Equals, tc.ip, comment)
+	}
+}
+
+func (s *AdvertiseSuite) TestValidatesPolicy(c *check.C) {
+	c.Assert(AdvertiseAddrPolicy{CIDR: "10.0.0.0/8", Interface: "^eth[0-9]+$"}.Check(), check.IsNil)
+	c.Assert(AdvertiseAddrPolicy{CIDR: "10.0.0.0"}.Check(), check.NotNil)
+	c.Assert(AdvertiseAddrPolicy{Interface: "eth("}.Check(), check.NotNil)
+}
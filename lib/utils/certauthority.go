@@ -0,0 +1,238 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+
+	cfsslhelpers "github.com/cloudflare/cfssl/helpers"
+	"github.com/gravitational/license/authority"
+	"github.com/gravitational/trace"
+)
+
+// CertAuthority is the certificate authority that issues cluster certificates.
+//
+// The certificate authority can be issued by another certificate authority,
+// e.g. of a corporate PKI, in which case the certificates of its issuers
+// are appended to the issued certificates so they chain up to the root
+type CertAuthority struct {
+	// TLSKeyPair is the certificate authority certificate and private key
+	authority.TLSKeyPair
+	// ChainPEM is the PEM-encoded chain of certificates that issued
+	// the certificate authority, starting with its direct issuer
+	ChainPEM []byte
+}
+
+// ReadCertAuthority reads the certificate authority from the specified
+// certificate and private key files.
+//
+// The certificate file can contain the chain of the issuer certificates
+// after the certificate authority certificate
+func ReadCertAuthority(certPath, keyPath string) (*CertAuthority, error) {
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	ca, err := ParseCertAuthority(certPEM, keyPEM)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to read certificate authority from %v", certPath)
+	}
+	return ca, nil
+}
+
+// ParseCertAuthority parses the certificate authority from the specified
+// PEM-encoded certificates and private key.
+//
+// The first certificate is the certificate authority certificate,
+// the rest is the chain of its issuers
+func ParseCertAuthority(certsPEM, keyPEM []byte) (*CertAuthority, error) {
+	var certs [][]byte
+	for {
+		var block *pem.Block
+		block, certsPEM = pem.Decode(certsPEM)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, trace.BadParameter("expected only certificates, got %q", block.Type)
+		}
+		certs = append(certs, pem.EncodeToMemory(block))
+	}
+	if len(certs) == 0 {
+		return nil, trace.BadParameter("no certificates found")
+	}
+	if len(keyPEM) == 0 {
+		return nil, trace.BadParameter("missing certificate authority private key")
+	}
+	return &CertAuthority{
+		TLSKeyPair: authority.TLSKeyPair{
+			CertPEM: certs[0],
+			KeyPEM:  keyPEM,
+		},
+		ChainPEM: bytes.Join(certs[1:], nil),
+	}, nil
+}
+
+// Check makes sure that the certificate authority can issue cluster certificates
+// at the specified time: it has to be a valid certificate authority allowed to sign
+// certificates for server and client authentication, match the private key,
+// not expire soon and chain up to its issuers
+func (r CertAuthority) Check(now time.Time) error {
+	cert, err := cfsslhelpers.ParseCertificatePEM(r.CertPEM)
+	if err != nil {
+		return trace.BadParameter("failed to parse certificate authority certificate: %v", err)
+	}
+	name := cert.Subject.CommonName
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return trace.BadParameter("certificate %q is not a certificate authority, "+
+			"its basic constraints extension must specify CA:TRUE", name)
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return trace.BadParameter("certificate authority %q is not allowed to sign certificates, "+
+			"its key usage must include keyCertSign", name)
+	}
+	if !allowsAuthentication(cert.ExtKeyUsage) {
+		return trace.BadParameter("certificate authority %q must allow server and client "+
+			"authentication in its extended key usage", name)
+	}
+	if hasNameConstraints(cert) {
+		return trace.BadParameter("certificate authority %q has name constraints which "+
+			"are not supported as cluster certificates are issued for internal names and IPs", name)
+	}
+	if err := checkValidity(cert, now); err != nil {
+		return trace.Wrap(err)
+	}
+	if cert.NotAfter.Sub(now) < defaults.CertificateExpiryWarning {
+		return trace.BadParameter("certificate authority %q expires on %v, "+
+			"please provide a certificate authority with a longer validity period",
+			name, cert.NotAfter.Format(constants.ShortDateFormat))
+	}
+	if _, err := tls.X509KeyPair(r.CertPEM, r.KeyPEM); err != nil {
+		return trace.BadParameter("private key does not match certificate authority %q: %v", name, err)
+	}
+	if _, err := cfsslhelpers.ParsePrivateKeyPEM(r.KeyPEM); err != nil {
+		return trace.BadParameter("unsupported certificate authority private key: %v", err)
+	}
+	chain, err := cfsslhelpers.ParseCertificatesPEM(r.ChainPEM)
+	if err != nil {
+		return trace.BadParameter("failed to parse certificate authority chain: %v", err)
+	}
+	for _, issuer := range chain {
+		if err := cert.CheckSignatureFrom(issuer); err != nil {
+			return trace.BadParameter("certificate %q is not issued by %q from the chain: %v",
+				cert.Subject.CommonName, issuer.Subject.CommonName, err)
+		}
+		if err := checkValidity(issuer, now); err != nil {
+			return trace.Wrap(err)
+		}
+		cert = issuer
+	}
+	return nil
+}
+
+// IssuedCertChain returns the certificates to append to the certificates
+// issued by this certificate authority so they chain up to the root.
+// Returns nil if the certificate authority has no issuer chain
+func (r CertAuthority) IssuedCertChain() []byte {
+	if len(r.ChainPEM) == 0 {
+		return nil
+	}
+	chain := append([]byte{}, r.CertPEM...)
+	return append(chain, r.ChainPEM...)
+}
+
+// CertAuthority returns the root certificate authority from the archive
+// along with the optional chain of its issuers
+func (ta TLSArchive) CertAuthority() (*CertAuthority, error) {
+	keyPair, err := ta.GetKeyPair(constants.RootKeyPair)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ca := &CertAuthority{TLSKeyPair: *keyPair}
+	if chain, err := ta.GetKeyPair(constants.RootChainKeyPair); err == nil {
+		ca.ChainPEM = chain.CertPEM
+	}
+	return ca, nil
+}
+
+// AddCertAuthority adds the root certificate authority and
+// the chain of its issuers to the archive
+func (ta TLSArchive) AddCertAuthority(ca CertAuthority) error {
+	if err := ta.AddKeyPair(constants.RootKeyPair, ca.TLSKeyPair); err != nil {
+		return trace.Wrap(err)
+	}
+	if len(ca.ChainPEM) == 0 {
+		return nil
+	}
+	err := ta.AddKeyPair(constants.RootChainKeyPair, authority.TLSKeyPair{CertPEM: ca.ChainPEM})
+	return trace.Wrap(err)
+}
+
+// checkValidity makes sure that the certificate is valid at the specified time
+func checkValidity(cert *x509.Certificate, now time.Time) error {
+	if now.Before(cert.NotBefore) {
+		return trace.BadParameter("certificate %q is not valid before %v",
+			cert.Subject.CommonName, cert.NotBefore.Format(constants.ShortDateFormat))
+	}
+	if now.After(cert.NotAfter) {
+		return trace.BadParameter("certificate %q expired on %v",
+			cert.Subject.CommonName, cert.NotAfter.Format(constants.ShortDateFormat))
+	}
+	return nil
+}
+
+// allowsAuthentication returns true if the specified extended key usage
+// allows issuing certificates for server and client authentication.
+// Empty extended key usage allows any usage
+func allowsAuthentication(usages []x509.ExtKeyUsage) bool {
+	if len(usages) == 0 {
+		return true
+	}
+	var server, client bool
+	for _, usage := range usages {
+		switch usage {
+		case x509.ExtKeyUsageAny:
+			return true
+		case x509.ExtKeyUsageServerAuth:
+			server = true
+		case x509.ExtKeyUsageClientAuth:
+			client = true
+		}
+	}
+	return server && client
+}
+
+// hasNameConstraints returns true if the certificate restricts
+// the names of the certificates it can issue
+func hasNameConstraints(cert *x509.Certificate) bool {
+	return len(cert.PermittedDNSDomains) != 0 || len(cert.ExcludedDNSDomains) != 0 ||
+		len(cert.PermittedIPRanges) != 0 || len(cert.ExcludedIPRanges) != 0 ||
+		len(cert.PermittedEmailAddresses) != 0 || len(cert.ExcludedEmailAddresses) != 0 ||
+		len(cert.PermittedURIDomains) != 0 || len(cert.ExcludedURIDomains) != 0
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/gravitational/license/authority"
+	"gopkg.in/check.v1"
+)
+
+type CertAuthoritySuite struct{}
+
+var _ = check.Suite(&CertAuthoritySuite{})
+
+func (s *CertAuthoritySuite) TestChecksCertAuthority(c *check.C) {
+	now := time.Now()
+	root := newTestCert(c, testCertTemplate("root", now), nil)
+	intermediate := newTestCert(c, testCertTemplate("intermediate", now), root)
+	other := newTestCert(c, testCertTemplate("other", now), nil)
+
+	notCA := testCertTemplate("leaf", now)
+	notCA.IsCA = false
+	noCertSign := testCertTemplate("no-cert-sign", now)
+	noCertSign.KeyUsage = x509.KeyUsageDigitalSignature
+	serverOnly := testCertTemplate("server-only", now)
+	serverOnly.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	constrained := testCertTemplate("constrained", now)
+	constrained.PermittedDNSDomains = []string{"example.com"}
+	expiring := testCertTemplate("expiring", now)
+	expiring.NotAfter = now.Add(24 * time.Hour)
+
+	testCases := []struct {
+		ca      CertAuthority
+		comment string
+		valid   bool
+	}{
+		{
+			ca:      CertAuthority{TLSKeyPair: root.keyPair},
+			comment: "self-signed certificate authority",
+			valid:   true,
+		},
+		{
+			ca:      CertAuthority{TLSKeyPair: intermediate.keyPair, ChainPEM: root.keyPair.CertPEM},
+			comment: "intermediate certificate authority with chain",
+			valid:   true,
+		},
+		{
+			ca:      CertAuthority{TLSKeyPair: intermediate.keyPair, ChainPEM: other.keyPair.CertPEM},
+			comment: "chain does not match the issuer",
+		},
+		{
+			ca: CertAuthority{TLSKeyPair: authority.TLSKeyPair{
+				CertPEM: root.keyPair.CertPEM,
+				KeyPEM:  other.keyPair.KeyPEM,
+			}},
+			comment: "private key does not match",
+		},
+		{
+			ca:      CertAuthority{TLSKeyPair: newTestCert(c, notCA, nil).keyPair},
+			comment: "not a certificate authority",
+		},
+		{
+			ca:      CertAuthority{TLSKeyPair: newTestCert(c, noCertSign, nil).keyPair},
+			comment: "not allowed to sign certificates",
+		},
+		{
+			ca:      CertAuthority{TLSKeyPair: newTestCert(c, serverOnly, nil).keyPair},
+			comment: "client authentication not allowed",
+		},
+		{
+			ca:      CertAuthority{TLSKeyPair: newTestCert(c, constrained, nil).keyPair},
+			comment: "name constraints",
+		},
+		{
+			ca:      CertAuthority{TLSKeyPair: newTestCert(c, expiring, nil).keyPair},
+			comment: "expires soon",
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		err := tc.ca.Check(now)
+		if tc.valid {
+			c.Assert(err, check.IsNil, comment)
+		} else {
+			c.Assert(err, check.NotNil, comment)
+		}
+	}
+}
+
+func (s *CertAuthoritySuite) TestParsesCertAuthorityWithChain(c *check.C) {
+	now := time.Now()
+	root := newTestCert(c, testCertTemplate("root", now), nil)
+	intermediate := newTestCert(c, testCertTemplate("intermediate", now), root)
+
+	certsPEM := append(append([]byte{}, intermediate.keyPair.CertPEM...), root.keyPair.CertPEM...)
+	ca, err := ParseCertAuthority(certsPEM, intermediate.keyPair.KeyPEM)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(ca.CertPEM), check.Equals, string(intermediate.keyPair.CertPEM))
+	c.Assert(string(ca.ChainPEM), check.Equals, string(root.keyPair.CertPEM))
+	c.Assert(string(ca.IssuedCertChain()), check.Equals, string(certsPEM))
+
+	archive := TLSArchive{}
+	c.Assert(archive.AddCertAuthority(*ca), check.IsNil)
+	fromArchive, err := archive.CertAuthority()
+	c.Assert(err, check.IsNil)
+	c.Assert(*fromArchive, check.DeepEquals, *ca)
+
+	ca, err = ParseCertAuthority(root.keyPair.CertPEM, root.keyPair.KeyPEM)
+	c.Assert(err, check.IsNil)
+	c.Assert(ca.IssuedCertChain(), check.IsNil)
+
+	_, err = ParseCertAuthority(root.keyPair.KeyPEM, root.keyPair.KeyPEM)
+	c.Assert(err, check.NotNil)
+}
+
+// testCertTemplate returns a template of a certificate authority
+// valid for a year starting from the specified time
+func testCertTemplate(name string, now time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+// newTestCert generates a certificate from the template issued by
+// the specified parent or self-signed if the parent is nil
+func newTestCert(c *check.C, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, check.IsNil)
+	issuer, signer := template, crypto.Signer(key)
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	c.Assert(err, check.IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, check.IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, check.IsNil)
+	return &testCert{
+		cert: cert,
+		key:  key,
+		keyPair: authority.TLSKeyPair{
+			CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		},
+	}
+}
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	keyPair authority.TLSKeyPair
+}
@@ -19,6 +19,7 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -35,6 +36,8 @@ import (
 	"github.com/cenkalti/backoff"
 	etcd "github.com/coreos/etcd/client"
 	"github.com/gravitational/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
@@ -288,10 +291,18 @@ func ConvertS3Error(err error) error {
 	switch awsErr.Code() {
 	case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket:
 		return trace.NotFound(awsErr.Message())
+	case errCodeS3NotFound:
+		// HEAD requests have no response body so S3 reports
+		// a missing object with a generic code
+		return trace.NotFound("object not found")
 	}
 	return err
 }
 
+// errCodeS3NotFound is the error code S3 returns for HEAD requests
+// on missing objects
+const errCodeS3NotFound = "NotFound"
+
 // UnsupportedFilesystemError represents a condition when an action is being
 // performed on an unsupported filesystem, for example an attempt to create
 // a bolt database file on filesystem that does not support mmap
@@ -346,8 +357,8 @@ func IsConnectionRefusedError(err error) bool {
 // It detects unrecoverable errors and aborts the reconnect attempts
 func ShouldReconnectPeer(err error) error {
 	switch {
-	case isPeerDeniedError(err.Error()),
-		isLicenseError(err.Error()),
+	case IsLicenseError(err),
+		isPeerDeniedError(err.Error()),
 		isHostAlreadyRegisteredError(err.Error()):
 		return &backoff.PermanentError{Err: err}
 	}
@@ -420,6 +431,46 @@ func (r exitCodeError) OrigError() error {
 	return r.err
 }
 
+// Unwrap returns the original error if available.
+// Implements the error wrapping interface of the standard errors package
+func (r exitCodeError) Unwrap() error {
+	return r.err
+}
+
+// AsExitCodeError returns the first exit code error found in the chain
+// of errors wrapped by err.
+// Both trace errors and errors wrapped with the standard errors package are traversed
+func AsExitCodeError(err error) (ExitCodeError, bool) {
+	for i := 0; err != nil && i < maxErrorHops; i++ {
+		if exitErr, ok := err.(ExitCodeError); ok {
+			return exitErr, true
+		}
+		if orig := trace.Unwrap(err); orig != err {
+			err = orig
+			continue
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil, false
+}
+
+// ExitCodeFromError returns the process exit code for the specified error.
+// Returns 0 if err is nil, the exit code of the first exit code error
+// wrapped by err or the generic error exit code otherwise
+func ExitCodeFromError(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := AsExitCodeError(err); ok {
+		return exitErr.ExitCode()
+	}
+	return defaults.GenericErrorExitCode
+}
+
+// maxErrorHops limits the depth of the error chain traversed
+// when looking for a specific error
+const maxErrorHops = 50
+
 type exitCodeError struct {
 	code    int
 	message string
@@ -431,8 +482,43 @@ func isPeerDeniedError(message string) bool {
 	return strings.Contains(message, "peer not authorized")
 }
 
-func isLicenseError(message string) bool {
-	return strings.Contains(message, "license allows maximum of")
+// NewLicenseError returns a new error indicating that the installed
+// license does not permit the requested operation
+func NewLicenseError(format string, args ...interface{}) error {
+	return trace.Wrap(&LicenseError{Message: fmt.Sprintf(format, args...)})
+}
+
+// IsLicenseError returns true if err is a license error.
+//
+// License errors returned by the agent server to joining peers
+// are converted to gRPC PermissionDenied status errors
+func IsLicenseError(err error) bool {
+	switch origErr := trace.Unwrap(err).(type) {
+	case *LicenseError:
+		return true
+	case interface{ GRPCStatus() *status.Status }:
+		return origErr.GRPCStatus().Code() == codes.PermissionDenied
+	}
+	return false
+}
+
+// LicenseError indicates that the installed license does not
+// permit the requested operation
+type LicenseError struct {
+	// Message is the error message
+	Message string
+}
+
+// Error returns the error message.
+// Implements error
+func (r *LicenseError) Error() string {
+	return r.Message
+}
+
+// IsAccessDeniedError indicates that this error is an access denied error
+// so it can be classified with trace.IsAccessDenied
+func (r *LicenseError) IsAccessDeniedError() bool {
+	return true
 }
 
 func isHostAlreadyRegisteredError(message string) bool {
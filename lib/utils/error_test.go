@@ -0,0 +1,93 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gravitational/gravity/lib/defaults"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/check.v1"
+)
+
+type ErrorSuite struct{}
+
+var _ = check.Suite(&ErrorSuite{})
+
+func (s *ErrorSuite) TestExitCodeFromError(c *check.C) {
+	var testCases = []struct {
+		comment  string
+		err      error
+		exitCode int
+	}{
+		{
+			comment:  "no error",
+			exitCode: 0,
+		},
+		{
+			comment:  "error without exit code",
+			err:      trace.BadParameter("bad parameter"),
+			exitCode: defaults.GenericErrorExitCode,
+		},
+		{
+			comment:  "exit code error",
+			err:      NewExitCodeError(3),
+			exitCode: 3,
+		},
+		{
+			comment:  "exit code error wrapped with trace",
+			err:      trace.Wrap(trace.Wrap(NewFailedPreconditionError(trace.NotFound("not found")))),
+			exitCode: defaults.FailedPreconditionExitCode,
+		},
+		{
+			comment:  "exit code error wrapped with standard errors",
+			err:      trace.Wrap(fmt.Errorf("failed: %w", NewExitCodeError(4))),
+			exitCode: 4,
+		},
+	}
+	for _, tc := range testCases {
+		comment := check.Commentf(tc.comment)
+		c.Assert(ExitCodeFromError(tc.err), check.Equals, tc.exitCode, comment)
+	}
+}
+
+func (s *ErrorSuite) TestUnwrapsExitCodeError(c *check.C) {
+	origErr := errors.New("original error")
+	err := WrapExitCodeError(1, origErr)
+	c.Assert(errors.Is(err, origErr), check.Equals, true)
+	c.Assert(errors.Unwrap(NewExitCodeError(1)), check.IsNil)
+}
+
+func (s *ErrorSuite) TestClassifiesLicenseErrors(c *check.C) {
+	err := NewLicenseError("the license allows maximum of %v nodes, requested: %v", 3, 4)
+	c.Assert(IsLicenseError(err), check.Equals, true)
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true)
+	// license errors arrive to the joining peers as gRPC status errors
+	c.Assert(IsLicenseError(status.Error(codes.PermissionDenied, err.Error())), check.Equals, true)
+	c.Assert(IsLicenseError(status.Error(codes.Unknown, err.Error())), check.Equals, false)
+	c.Assert(IsLicenseError(trace.AccessDenied("peer not authorized")), check.Equals, false)
+
+	_, ok := ShouldReconnectPeer(status.Error(codes.PermissionDenied, "license")).(*backoff.PermanentError)
+	c.Assert(ok, check.Equals, true)
+	_, ok = ShouldReconnectPeer(status.Error(codes.Unavailable, "connection refused")).(*backoff.PermanentError)
+	c.Assert(ok, check.Equals, false)
+}
@@ -22,9 +22,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gravitational/gravity/lib/constants"
@@ -121,6 +123,7 @@ func RunStream(ctx context.Context, w io.Writer, args ...string) error {
 	name := args[0]
 	args = args[1:]
 	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = commandEnviron()
 	cmd.Stdout = w
 	if err := cmd.Start(); err != nil {
 		return trace.Wrap(err)
@@ -154,6 +157,9 @@ func ExecWithInput(cmd *exec.Cmd, input string, out io.Writer, setters ...Comman
 	cmd.Path = execPath
 	cmd.Stdout = out
 	cmd.Stderr = out
+	if cmd.Env == nil {
+		cmd.Env = commandEnviron()
+	}
 
 	for _, s := range setters {
 		s(cmd)
@@ -183,6 +189,60 @@ func ExecWithInput(cmd *exec.Cmd, input string, out io.Writer, setters ...Comman
 	return nil
 }
 
+// SetCommandEnv sets the environment variables to start the commands
+// executed with RunStream and Exec with, in addition to the environment
+// of this process. Commands with explicitly configured environment
+// are not affected
+func SetCommandEnv(vars map[string]string) {
+	commandEnv.Lock()
+	commandEnv.vars = vars
+	commandEnv.resolve = nil
+	commandEnv.Unlock()
+}
+
+// SetCommandEnvFunc sets the function that returns the environment
+// variables to start the commands with, see SetCommandEnv.
+// The function is called once, when the first command is started
+func SetCommandEnvFunc(resolve func() map[string]string) {
+	commandEnv.Lock()
+	commandEnv.vars = nil
+	commandEnv.resolve = resolve
+	commandEnv.Unlock()
+}
+
+// commandEnviron returns the environment to start commands with.
+// Returns nil to inherit the environment of this process if
+// no additional environment has been configured
+func commandEnviron() []string {
+	commandEnv.Lock()
+	defer commandEnv.Unlock()
+	if commandEnv.resolve != nil {
+		commandEnv.vars = commandEnv.resolve()
+		commandEnv.resolve = nil
+	}
+	if len(commandEnv.vars) == 0 {
+		return nil
+	}
+	var environ []string
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if _, ok := commandEnv.vars[name]; !ok {
+			environ = append(environ, kv)
+		}
+	}
+	for name, value := range commandEnv.vars {
+		environ = append(environ, fmt.Sprintf("%v=%v", name, value))
+	}
+	return environ
+}
+
+var commandEnv struct {
+	sync.Mutex
+	vars map[string]string
+	// resolve returns the environment when the first command is started
+	resolve func() map[string]string
+}
+
 func CombinedOutput(cmd *exec.Cmd, out io.Writer) (string, error) {
 	buf := &SafeByteBuffer{}
 	err := Exec(cmd, io.MultiWriter(buf, out))
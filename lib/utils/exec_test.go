@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	"gopkg.in/check.v1"
+)
+
+type ExecSuite struct{}
+
+var _ = check.Suite(&ExecSuite{})
+
+func (s *ExecSuite) TestStartsCommandsWithCommandEnv(c *check.C) {
+	defer SetCommandEnv(nil)
+	noProxy := os.Getenv("NO_PROXY")
+
+	SetCommandEnv(map[string]string{"NO_PROXY": "10.0.0.0/8,.local"})
+	var out bytes.Buffer
+	err := RunStream(context.TODO(), &out, "/bin/sh", "-c", "printf %s $NO_PROXY")
+	c.Assert(err, check.IsNil)
+	c.Assert(out.String(), check.Equals, "10.0.0.0/8,.local")
+	// The environment of this process is left intact
+	c.Assert(os.Getenv("NO_PROXY"), check.Equals, noProxy)
+
+	SetCommandEnv(nil)
+	c.Assert(commandEnviron(), check.IsNil)
+}
+
+func (s *ExecSuite) TestResolvesCommandEnvOnFirstCommand(c *check.C) {
+	defer SetCommandEnv(nil)
+	var resolved int
+	SetCommandEnvFunc(func() map[string]string {
+		resolved++
+		return map[string]string{"NO_PROXY": ".local"}
+	})
+	c.Assert(resolved, check.Equals, 0)
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		err := RunStream(context.TODO(), &out, "/bin/sh", "-c", "printf %s $NO_PROXY")
+		c.Assert(err, check.IsNil)
+		c.Assert(out.String(), check.Equals, ".local")
+	}
+	c.Assert(resolved, check.Equals, 1)
+}
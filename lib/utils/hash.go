@@ -26,8 +26,13 @@ import (
 
 // SHA512 half is a first half of SHA512 hash of the byte string
 func SHA512Half(v []byte) (string, error) {
+	return SHA512HalfReader(bytes.NewBuffer(v))
+}
+
+// SHA512HalfReader returns the first half of SHA512 hash of the data read from r
+func SHA512HalfReader(r io.Reader) (string, error) {
 	h := sha512.New()
-	_, err := io.Copy(h, bytes.NewBuffer(v))
+	_, err := io.Copy(h, r)
 	if err != nil {
 		return "", err
 	}
@@ -103,27 +103,33 @@ func (r *TransferRate) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &rate); err != nil {
 		return trace.Wrap(err, "could not unmarshal %q to a string", string(data))
 	}
-	if !strings.HasSuffix(rate, "/s") {
-		return trace.BadParameter("expected transfer rate as value/s but got %q", rate)
-	}
-	bytes, err := humanize.ParseBytes(strings.TrimSuffix(rate, "/s"))
+	parsed, err := ParseTransferRate(rate)
 	if err != nil {
-		return trace.Wrap(err, "could not parse %q as transfer rate", rate)
+		return trace.Wrap(err)
 	}
-	*r = TransferRate(bytes)
+	*r = parsed
 	return nil
 }
 
+// ParseTransferRate parses the provided data as a transfer rate (e.g. "10MB/s")
+func ParseTransferRate(data string) (TransferRate, error) {
+	if !strings.HasSuffix(data, "/s") {
+		return 0, trace.BadParameter("expected transfer rate as value/s but got %q", data)
+	}
+	bytes, err := humanize.ParseBytes(strings.TrimSuffix(data, "/s"))
+	if err != nil {
+		return 0, trace.Wrap(err, "could not parse %q as transfer rate", data)
+	}
+	return TransferRate(bytes), nil
+}
+
 // MustParseTransferRate parses the provided data as a transfer rate or panics
 func MustParseTransferRate(data string) TransferRate {
-	if !strings.HasSuffix(string(data), "/s") {
-		panic(trace.BadParameter("unsupported transfer rate format"))
-	}
-	bytes, err := humanize.ParseBytes(strings.TrimSuffix(string(data), "/s"))
+	rate, err := ParseTransferRate(data)
 	if err != nil {
 		panic(trace.Wrap(err))
 	}
-	return TransferRate(bytes)
+	return rate
 }
 
 // Int64Ptr returns a pointer to an int64 with value v
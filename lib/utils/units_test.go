@@ -38,6 +38,15 @@ func (s *UnitsSuite) TestCapacityAndTransferRate(c *check.C) {
 	c.Assert(o.Rate.BytesPerSecond(), check.Equals, uint64(50000000))
 }
 
+func (s *UnitsSuite) TestParsesTransferRate(c *check.C) {
+	rate, err := ParseTransferRate("10MB/s")
+	c.Assert(err, check.IsNil)
+	c.Assert(rate.BytesPerSecond(), check.Equals, uint64(10000000))
+
+	_, err = ParseTransferRate("10MB")
+	c.Assert(err, check.ErrorMatches, "expected transfer rate.*")
+}
+
 type capacityAndRate struct {
 	Capacity Capacity     `json:"capacity"`
 	Rate     TransferRate `json:"rate"`
@@ -99,6 +99,8 @@ type Config struct {
 	App *storage.Application
 	// RemoteApps lists optional applications from remote clusters
 	RemoteApps []storage.Application
+	// DisabledComponents lists the application components excluded from the cluster
+	DisabledComponents []string
 	// Apps is the cluster application service
 	Apps app.Applications
 	// Operator is the cluster operator service
@@ -182,6 +184,8 @@ func (r *engine) ChangePhaseState(ctx context.Context, change libfsm.StateChange
 			PhaseID:     change.Phase,
 			NewState:    change.State,
 			Error:       utils.ToRawTrace(change.Error),
+			Transient:   change.IsTransient(),
+			Logs:        change.Logs,
 			Created:     time.Now().UTC(),
 		})
 	if err != nil {
@@ -257,6 +261,7 @@ func configToExecutor(config Config) libfsm.FSMSpecFunc {
 			return libphase.NewRegistry(
 				params,
 				config.App.Locator,
+				config.DisabledComponents,
 				config.Apps,
 				config.Packages,
 				config.Silent, logger)
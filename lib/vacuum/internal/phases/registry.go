@@ -39,6 +39,7 @@ import (
 func NewRegistry(
 	params libfsm.ExecutorParams,
 	clusterApp loc.Locator,
+	disabledComponents []string,
 	clusterApps app.Applications,
 	clusterPackages pack.PackageService,
 	silent localenv.Silent,
@@ -68,6 +69,7 @@ func NewRegistry(
 			Silent:      silent,
 			FieldLogger: logger,
 		},
+		DisabledComponents: disabledComponents,
 	})
 	if err != nil {
 		return nil, trace.Wrap(err)
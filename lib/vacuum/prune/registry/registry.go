@@ -77,6 +77,9 @@ type Config struct {
 	Apps apps.Applications
 	// ImageService specifies the docker image service
 	ImageService docker.ImageService
+	// DisabledComponents lists the application components excluded
+	// from the cluster, their images are removed from the registry
+	DisabledComponents []string
 }
 
 // Prune removes unused docker images.
@@ -127,10 +130,11 @@ func (r *cleanup) Prune(ctx context.Context) (err error) {
 		return nil
 	}
 	err = appservice.SyncApp(ctx, appservice.SyncRequest{
-		PackService:  r.Packages,
-		AppService:   r.Apps,
-		ImageService: r.ImageService,
-		Package:      *r.App,
+		PackService:        r.Packages,
+		AppService:         r.Apps,
+		ImageService:       r.ImageService,
+		Package:            *r.App,
+		DisabledComponents: r.DisabledComponents,
 	})
 	if err != nil {
 		return trace.Wrap(err)
@@ -132,16 +132,17 @@ func (r *Collector) init() (*libfsm.FSM, error) {
 	}
 
 	machine, err := fsm.New(fsm.Config{
-		App:           r.App,
-		RemoteApps:    r.RemoteApps,
-		Apps:          r.Apps,
-		Packages:      r.Packages,
-		LocalPackages: r.LocalPackages,
-		Operation:     r.Operation,
-		Operator:      r.Operator,
-		RuntimePath:   r.RuntimePath,
-		Runner:        r.Runner,
-		Silent:        r.Silent,
+		App:                r.App,
+		RemoteApps:         r.RemoteApps,
+		Apps:               r.Apps,
+		Packages:           r.Packages,
+		LocalPackages:      r.LocalPackages,
+		Operation:          r.Operation,
+		Operator:           r.Operator,
+		RuntimePath:        r.RuntimePath,
+		Runner:             r.Runner,
+		Silent:             r.Silent,
+		DisabledComponents: r.DisabledComponents,
 	})
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -224,6 +225,8 @@ type Config struct {
 	App *storage.Application
 	// RemoteApps lists optional applications from remote clusters
 	RemoteApps []storage.Application
+	// DisabledComponents lists the application components excluded from the cluster
+	DisabledComponents []string
 	// Apps is the cluster application service
 	Apps app.Applications
 	// Packages is the cluster package service
@@ -0,0 +1,209 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumesnapshot
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/rigging"
+	"github.com/gravitational/trace"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// kubeAPI defines the subset of the Kubernetes API used to take
+// and restore volume snapshots
+type kubeAPI interface {
+	// listClaims returns the persistent volume claims in the specified namespace
+	// matching the label selector. Empty namespace selects all namespaces
+	listClaims(namespace, selector string) ([]v1.PersistentVolumeClaim, error)
+	// getClaim returns the specified persistent volume claim
+	getClaim(namespace, name string) (*v1.PersistentVolumeClaim, error)
+	// createClaim creates a new persistent volume claim
+	createClaim(v1.PersistentVolumeClaim) error
+	// getStorageClass returns the storage class with the specified name
+	getStorageClass(name string) (*storagev1.StorageClass, error)
+	// listSnapshotClasses returns all volume snapshot classes
+	listSnapshotClasses() ([]snapshotClass, error)
+	// createSnapshot creates a new volume snapshot
+	createSnapshot(snapshot) error
+	// getSnapshot returns the specified volume snapshot
+	getSnapshot(namespace, name string) (*snapshot, error)
+	// deleteSnapshot deletes the specified volume snapshot
+	deleteSnapshot(namespace, name string) error
+}
+
+// newKubeAPI returns the Kubernetes API implementation backed by the provided client.
+// Volume snapshot resources are accessed with the raw REST client as they
+// are not a part of the client library
+func newKubeAPI(client kubernetes.Interface) *kubeClient {
+	return &kubeClient{client: client}
+}
+
+type kubeClient struct {
+	client kubernetes.Interface
+}
+
+func (r *kubeClient) listClaims(namespace, selector string) ([]v1.PersistentVolumeClaim, error) {
+	claims, err := r.client.CoreV1().PersistentVolumeClaims(namespace).List(metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	return claims.Items, nil
+}
+
+func (r *kubeClient) getClaim(namespace, name string) (*v1.PersistentVolumeClaim, error) {
+	claim, err := r.client.CoreV1().PersistentVolumeClaims(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	return claim, nil
+}
+
+func (r *kubeClient) createClaim(claim v1.PersistentVolumeClaim) error {
+	_, err := r.client.CoreV1().PersistentVolumeClaims(claim.Namespace).Create(&claim)
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+func (r *kubeClient) getStorageClass(name string) (*storagev1.StorageClass, error) {
+	class, err := r.client.StorageV1().StorageClasses().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	return class, nil
+}
+
+func (r *kubeClient) listSnapshotClasses() ([]snapshotClass, error) {
+	data, err := r.client.CoreV1().RESTClient().Get().
+		AbsPath(snapshotAPIPath, "volumesnapshotclasses").
+		DoRaw()
+	if err != nil {
+		err = rigging.ConvertError(err)
+		if trace.IsNotFound(err) {
+			return nil, trace.NotFound("volume snapshot API %v is not available, "+
+				"make sure the OpenEBS CSI driver is installed along with the snapshot controller",
+				snapshotAPIVersion)
+		}
+		return nil, trace.Wrap(err)
+	}
+	var list snapshotClassList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return list.Items, nil
+}
+
+func (r *kubeClient) createSnapshot(snapshot snapshot) error {
+	snapshot.APIVersion = snapshotAPIVersion
+	snapshot.Kind = "VolumeSnapshot"
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = r.client.CoreV1().RESTClient().Post().
+		AbsPath(snapshotAPIPath, "namespaces", snapshot.Metadata.Namespace, "volumesnapshots").
+		SetHeader("Content-Type", "application/json").
+		Body(data).
+		DoRaw()
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+func (r *kubeClient) getSnapshot(namespace, name string) (*snapshot, error) {
+	data, err := r.client.CoreV1().RESTClient().Get().
+		AbsPath(snapshotAPIPath, "namespaces", namespace, "volumesnapshots", name).
+		DoRaw()
+	if err != nil {
+		return nil, trace.Wrap(rigging.ConvertError(err))
+	}
+	var snapshot snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &snapshot, nil
+}
+
+func (r *kubeClient) deleteSnapshot(namespace, name string) error {
+	_, err := r.client.CoreV1().RESTClient().Delete().
+		AbsPath(snapshotAPIPath, "namespaces", namespace, "volumesnapshots", name).
+		DoRaw()
+	return trace.Wrap(rigging.ConvertError(err))
+}
+
+// snapshotClassList is a list of volume snapshot classes
+type snapshotClassList struct {
+	Items []snapshotClass `json:"items"`
+}
+
+// snapshotClass is a volume snapshot class resource.
+//
+// Only the fields required to match the class with the storage
+// provisioner are decoded
+type snapshotClass struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+	// Driver is the name of the CSI driver that handles the snapshots of this class
+	Driver string `json:"driver"`
+}
+
+// isDefault returns true if this is the default snapshot class for its driver
+func (r snapshotClass) isDefault() bool {
+	return r.Metadata.Annotations[defaultSnapshotClassAnnotation] == "true"
+}
+
+// snapshot is a volume snapshot resource
+type snapshot struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Metadata   struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		Labels    map[string]string `json:"labels,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+		Source                  struct {
+			PersistentVolumeClaimName string `json:"persistentVolumeClaimName,omitempty"`
+		} `json:"source"`
+	} `json:"spec"`
+	Status *snapshotStatus `json:"status,omitempty"`
+}
+
+// snapshotStatus is the status of a volume snapshot
+type snapshotStatus struct {
+	ReadyToUse bool `json:"readyToUse,omitempty"`
+	Error      *struct {
+		Message string `json:"message,omitempty"`
+	} `json:"error,omitempty"`
+}
+
+const (
+	// snapshotAPIGroup is the API group of the volume snapshot resources
+	snapshotAPIGroup = "snapshot.storage.k8s.io"
+	// snapshotAPIVersion is the version of the volume snapshot API
+	// supported by the OpenEBS CSI drivers
+	snapshotAPIVersion = snapshotAPIGroup + "/v1beta1"
+	// snapshotAPIPath is the path of the volume snapshot API
+	snapshotAPIPath = "/apis/" + snapshotAPIVersion
+	// defaultSnapshotClassAnnotation marks the default snapshot class of a driver
+	defaultSnapshotClassAnnotation = "snapshot.storage.kubernetes.io/is-default-class"
+)
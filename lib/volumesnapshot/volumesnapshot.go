@@ -0,0 +1,526 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package volumesnapshot takes and restores the snapshots of persistent volumes
+// provisioned by the OpenEBS CSI drivers (cStor and ZFS) using the Kubernetes
+// volume snapshot API. The snapshots of all selected volumes are taken together
+// and recorded in the cluster backend under a single name, optionally along with
+// the etcd data backup.
+package volumesnapshot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Backend defines the subset of the cluster backend with volume snapshot records
+type Backend interface {
+	// CreateVolumeSnapshot records a new volume snapshot
+	CreateVolumeSnapshot(storage.VolumeSnapshot) (*storage.VolumeSnapshot, error)
+	// GetVolumeSnapshot returns the volume snapshot with the specified name
+	GetVolumeSnapshot(clusterName, name string) (*storage.VolumeSnapshot, error)
+	// GetVolumeSnapshots returns all volume snapshots of the specified cluster
+	GetVolumeSnapshots(clusterName string) ([]storage.VolumeSnapshot, error)
+	// DeleteVolumeSnapshot deletes the volume snapshot with the specified name
+	DeleteVolumeSnapshot(clusterName, name string) error
+}
+
+// Config defines the snapshotter configuration
+type Config struct {
+	// Client is the cluster Kubernetes client
+	Client kubernetes.Interface
+	// Backend is the cluster backend with volume snapshot records
+	Backend Backend
+	// ClusterName is the name of the cluster
+	ClusterName string
+	// Clock is used to timestamp the snapshots
+	Clock clockwork.Clock
+	// FieldLogger is used for logging
+	logrus.FieldLogger
+	// cluster is the Kubernetes API used to manage snapshots
+	cluster kubeAPI
+}
+
+// CheckAndSetDefaults validates the configuration and sets defaults
+func (r *Config) CheckAndSetDefaults() error {
+	if r.Client == nil && r.cluster == nil {
+		return trace.BadParameter("missing Client")
+	}
+	if r.Backend == nil {
+		return trace.BadParameter("missing Backend")
+	}
+	if r.ClusterName == "" {
+		return trace.BadParameter("missing ClusterName")
+	}
+	if r.Clock == nil {
+		r.Clock = clockwork.NewRealClock()
+	}
+	if r.FieldLogger == nil {
+		r.FieldLogger = logrus.WithField(trace.Component, "volumesnapshot")
+	}
+	if r.cluster == nil {
+		r.cluster = newKubeAPI(r.Client)
+	}
+	return nil
+}
+
+// New returns a new snapshotter
+func New(config Config) (*Snapshotter, error) {
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Snapshotter{Config: config}, nil
+}
+
+// Snapshotter takes and restores persistent volume snapshots
+type Snapshotter struct {
+	// Config is the snapshotter configuration
+	Config
+}
+
+// CreateRequest describes a request to take a volume snapshot
+type CreateRequest struct {
+	// Name is the name of the new snapshot
+	Name string
+	// Namespace limits the snapshot to the claims in the specified namespace.
+	// All namespaces are considered if unspecified
+	Namespace string
+	// Selector is the label selector of the claims to snapshot
+	Selector string
+	// Claims explicitly lists the claims to snapshot as namespace/name.
+	// Claims without a namespace are looked up in Namespace or in
+	// the default namespace
+	Claims []string
+	// SnapshotClass overrides the volume snapshot class to use
+	SnapshotClass string
+	// CreatedBy is the user who takes the snapshot
+	CreatedBy string
+	// Backup is the path to the application backup tarball taken
+	// along with the snapshot
+	Backup string
+	// Timeout is the maximum amount of time to wait for the snapshots to
+	// become ready to use
+	Timeout time.Duration
+	// BackupEtcd takes the etcd data backup. It is invoked right after
+	// the volume snapshots have been requested so the state of the cluster
+	// matches the state of the volumes.
+	// The etcd data is not backed up if unspecified
+	BackupEtcd func(ctx context.Context) (*storage.EtcdSnapshot, error)
+}
+
+// Check validates this request
+func (r *CreateRequest) Check() error {
+	if r.Name == "" {
+		return trace.BadParameter("missing snapshot name")
+	}
+	if r.Timeout == 0 {
+		r.Timeout = defaults.VolumeSnapshotTimeout
+	}
+	return nil
+}
+
+// Create takes the snapshots of the persistent volume claims selected by the request
+// and records them in the cluster backend
+func (r *Snapshotter) Create(ctx context.Context, req CreateRequest) (*storage.VolumeSnapshot, error) {
+	if err := req.Check(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	_, err := r.Backend.GetVolumeSnapshot(r.ClusterName, req.Name)
+	if err == nil {
+		return nil, trace.AlreadyExists("volume snapshot %v already exists", req.Name)
+	}
+	if !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	volumes, err := r.selectVolumes(req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	snapshot := storage.VolumeSnapshot{
+		Name:        req.Name,
+		ClusterName: r.ClusterName,
+		Created:     r.Clock.Now().UTC(),
+		CreatedBy:   req.CreatedBy,
+		Volumes:     volumes,
+		Backup:      req.Backup,
+	}
+	if err := r.createSnapshots(ctx, &snapshot, req); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	out, err := r.Backend.CreateVolumeSnapshot(snapshot)
+	if err != nil {
+		r.deleteSnapshots(snapshot.Volumes)
+		return nil, trace.Wrap(err)
+	}
+	return out, nil
+}
+
+func (r *Snapshotter) createSnapshots(ctx context.Context, snapshot *storage.VolumeSnapshot, req CreateRequest) (err error) {
+	var created []storage.SnapshotVolume
+	defer func() {
+		if err != nil {
+			r.deleteSnapshots(created)
+		}
+	}()
+	for _, volume := range snapshot.Volumes {
+		err := r.cluster.createSnapshot(newSnapshot(snapshot.Name, volume))
+		if err != nil {
+			return trace.Wrap(err, "failed to create snapshot of %v", volume)
+		}
+		r.WithField("claim", volume.String()).Info("Requested volume snapshot.")
+		created = append(created, volume)
+	}
+	if req.BackupEtcd != nil {
+		snapshot.Etcd, err = req.BackupEtcd(ctx)
+		if err != nil {
+			return trace.Wrap(err, "failed to backup etcd data")
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, req.Timeout)
+	defer cancel()
+	return trace.Wrap(r.waitReady(ctx, snapshot.Volumes))
+}
+
+// waitReady blocks until all snapshots are ready to use or the context expires
+func (r *Snapshotter) waitReady(ctx context.Context, volumes []storage.SnapshotVolume) error {
+	pending := volumes
+	errors := make(map[string]string)
+	for {
+		var notReady []storage.SnapshotVolume
+		for _, volume := range pending {
+			snapshot, err := r.cluster.getSnapshot(volume.Namespace, volume.SnapshotName)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			if snapshot.Status != nil && snapshot.Status.ReadyToUse {
+				r.WithField("claim", volume.String()).Info("Volume snapshot is ready.")
+				continue
+			}
+			if snapshot.Status != nil && snapshot.Status.Error != nil {
+				// snapshot errors may be transient and retried by the snapshot controller
+				errors[volume.String()] = snapshot.Status.Error.Message
+			}
+			notReady = append(notReady, volume)
+		}
+		if len(notReady) == 0 {
+			return nil
+		}
+		pending = notReady
+		select {
+		case <-r.Clock.After(defaults.VolumeSnapshotPollInterval):
+		case <-ctx.Done():
+			return trace.LimitExceeded("timed out waiting for the snapshots of %v to become ready%v",
+				pending, formatErrors(errors))
+		}
+	}
+}
+
+// RestoreRequest describes a request to restore a volume snapshot
+type RestoreRequest struct {
+	// Name is the name of the snapshot to restore
+	Name string
+	// Suffix is appended to the names of the restored claims.
+	// The claims are restored under their original names if unspecified
+	// which requires the original claims to have been deleted
+	Suffix string
+}
+
+// Restore creates persistent volume claims with the data of the specified snapshot.
+// Returns the names of the restored claims as namespace/name
+func (r *Snapshotter) Restore(ctx context.Context, req RestoreRequest) (claims []string, err error) {
+	snapshot, err := r.Backend.GetVolumeSnapshot(r.ClusterName, req.Name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var restored []v1.PersistentVolumeClaim
+	for _, volume := range snapshot.Volumes {
+		claim, err := newRestoredClaim(snapshot.Name, volume, req.Suffix)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		_, err = r.cluster.getClaim(claim.Namespace, claim.Name)
+		if err == nil {
+			return nil, trace.AlreadyExists("persistent volume claim %v/%v already exists, "+
+				"delete it or restore the snapshot with a suffix", claim.Namespace, claim.Name)
+		}
+		if !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		source, err := r.cluster.getSnapshot(volume.Namespace, volume.SnapshotName)
+		if err != nil {
+			return nil, trace.Wrap(err, "failed to find snapshot of %v", volume)
+		}
+		if source.Status == nil || !source.Status.ReadyToUse {
+			return nil, trace.BadParameter("snapshot of %v is not ready to use", volume)
+		}
+		restored = append(restored, *claim)
+	}
+	for _, claim := range restored {
+		if err := r.cluster.createClaim(claim); err != nil {
+			return claims, trace.Wrap(err, "failed to restore %v/%v", claim.Namespace, claim.Name)
+		}
+		r.WithField("claim", fmt.Sprintf("%v/%v", claim.Namespace, claim.Name)).Info("Restored volume.")
+		claims = append(claims, fmt.Sprintf("%v/%v", claim.Namespace, claim.Name))
+	}
+	return claims, nil
+}
+
+// Delete deletes the Kubernetes volume snapshots of the specified snapshot
+// along with its record.
+// Returns the deleted snapshot record
+func (r *Snapshotter) Delete(ctx context.Context, name string) (*storage.VolumeSnapshot, error) {
+	snapshot, err := r.Backend.GetVolumeSnapshot(r.ClusterName, name)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, volume := range snapshot.Volumes {
+		err := r.cluster.deleteSnapshot(volume.Namespace, volume.SnapshotName)
+		if err != nil && !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err, "failed to delete snapshot of %v", volume)
+		}
+	}
+	if err := r.Backend.DeleteVolumeSnapshot(r.ClusterName, name); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return snapshot, nil
+}
+
+// selectVolumes returns the claims to snapshot along with the snapshot class
+// for each claim
+func (r *Snapshotter) selectVolumes(req CreateRequest) (volumes []storage.SnapshotVolume, err error) {
+	classes, err := r.cluster.listSnapshotClasses()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	explicit := len(req.Claims) != 0
+	claims, err := r.getClaims(req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, claim := range claims {
+		logger := r.WithField("claim", fmt.Sprintf("%v/%v", claim.Namespace, claim.Name))
+		volume, err := r.newSnapshotVolume(req, claim, classes)
+		if err != nil {
+			if explicit {
+				return nil, trace.Wrap(err)
+			}
+			logger.WithError(err).Info("Skip volume.")
+			continue
+		}
+		volumes = append(volumes, *volume)
+	}
+	if len(volumes) == 0 {
+		return nil, trace.NotFound("no persistent volume claims provisioned by the OpenEBS CSI drivers found")
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].String() < volumes[j].String()
+	})
+	return volumes, nil
+}
+
+func (r *Snapshotter) getClaims(req CreateRequest) (claims []v1.PersistentVolumeClaim, err error) {
+	if len(req.Claims) == 0 {
+		return r.cluster.listClaims(req.Namespace, req.Selector)
+	}
+	for _, name := range req.Claims {
+		namespace, name := parseClaimName(name, req.Namespace)
+		claim, err := r.cluster.getClaim(namespace, name)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		claims = append(claims, *claim)
+	}
+	return claims, nil
+}
+
+func (r *Snapshotter) newSnapshotVolume(req CreateRequest, claim v1.PersistentVolumeClaim, classes []snapshotClass) (*storage.SnapshotVolume, error) {
+	if claim.Status.Phase != v1.ClaimBound {
+		return nil, trace.BadParameter("persistent volume claim %v/%v is not bound",
+			claim.Namespace, claim.Name)
+	}
+	if claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName == "" {
+		return nil, trace.BadParameter("persistent volume claim %v/%v has no storage class",
+			claim.Namespace, claim.Name)
+	}
+	storageClass, err := r.cluster.getStorageClass(*claim.Spec.StorageClassName)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if !utils.StringInSlice(SnapshotDrivers, storageClass.Provisioner) {
+		return nil, trace.BadParameter("persistent volume claim %v/%v is provisioned by %v "+
+			"which does not support snapshots, supported provisioners are %v",
+			claim.Namespace, claim.Name, storageClass.Provisioner, strings.Join(SnapshotDrivers, ", "))
+	}
+	class, err := selectSnapshotClass(classes, storageClass.Provisioner, req.SnapshotClass)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var accessModes []string
+	for _, mode := range claim.Spec.AccessModes {
+		accessModes = append(accessModes, string(mode))
+	}
+	capacity := claim.Spec.Resources.Requests[v1.ResourceStorage]
+	return &storage.SnapshotVolume{
+		Namespace:     claim.Namespace,
+		Claim:         claim.Name,
+		StorageClass:  storageClass.Name,
+		AccessModes:   accessModes,
+		Capacity:      capacity.String(),
+		SnapshotName:  fmt.Sprintf("%v-%v", claim.Name, req.Name),
+		SnapshotClass: class,
+	}, nil
+}
+
+// deleteSnapshots deletes the specified Kubernetes volume snapshots
+// logging the errors
+func (r *Snapshotter) deleteSnapshots(volumes []storage.SnapshotVolume) {
+	for _, volume := range volumes {
+		err := r.cluster.deleteSnapshot(volume.Namespace, volume.SnapshotName)
+		if err != nil && !trace.IsNotFound(err) {
+			r.WithError(err).WithField("claim", volume.String()).Warn("Failed to delete volume snapshot.")
+		}
+	}
+}
+
+// selectSnapshotClass returns the name of the snapshot class to use for
+// the volumes provisioned by the specified driver
+func selectSnapshotClass(classes []snapshotClass, driver, name string) (string, error) {
+	var matching []snapshotClass
+	for _, class := range classes {
+		if class.Driver != driver {
+			continue
+		}
+		if name != "" && class.Metadata.Name == name {
+			return name, nil
+		}
+		matching = append(matching, class)
+	}
+	if name != "" {
+		return "", trace.NotFound("volume snapshot class %v for driver %v not found", name, driver)
+	}
+	for _, class := range matching {
+		if class.isDefault() {
+			return class.Metadata.Name, nil
+		}
+	}
+	switch len(matching) {
+	case 0:
+		return "", trace.NotFound("no volume snapshot class found for driver %v", driver)
+	case 1:
+		return matching[0].Metadata.Name, nil
+	}
+	return "", trace.BadParameter("multiple volume snapshot classes found for driver %v, "+
+		"specify the snapshot class explicitly", driver)
+}
+
+func newSnapshot(name string, volume storage.SnapshotVolume) snapshot {
+	var s snapshot
+	s.Metadata.Name = volume.SnapshotName
+	s.Metadata.Namespace = volume.Namespace
+	s.Metadata.Labels = map[string]string{
+		constants.VolumeSnapshotLabel: name,
+	}
+	s.Spec.VolumeSnapshotClassName = volume.SnapshotClass
+	s.Spec.Source.PersistentVolumeClaimName = volume.Claim
+	return s
+}
+
+func newRestoredClaim(name string, volume storage.SnapshotVolume, suffix string) (*v1.PersistentVolumeClaim, error) {
+	capacity, err := resource.ParseQuantity(volume.Capacity)
+	if err != nil {
+		return nil, trace.Wrap(err, "invalid capacity of %v: %q", volume, volume.Capacity)
+	}
+	claimName := volume.Claim
+	if suffix != "" {
+		claimName = fmt.Sprintf("%v-%v", claimName, suffix)
+	}
+	var accessModes []v1.PersistentVolumeAccessMode
+	for _, mode := range volume.AccessModes {
+		accessModes = append(accessModes, v1.PersistentVolumeAccessMode(mode))
+	}
+	storageClass := volume.StorageClass
+	apiGroup := snapshotAPIGroup
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claimName,
+			Namespace: volume.Namespace,
+			Labels: map[string]string{
+				constants.VolumeSnapshotLabel: name,
+			},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+			AccessModes:      accessModes,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: capacity,
+				},
+			},
+			DataSource: &v1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     volume.SnapshotName,
+			},
+		},
+	}, nil
+}
+
+// parseClaimName parses the claim name in the namespace/name format
+func parseClaimName(claim, namespace string) (string, string) {
+	if parts := strings.SplitN(claim, "/", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	if namespace == "" {
+		namespace = defaults.Namespace
+	}
+	return namespace, claim
+}
+
+func formatErrors(errors map[string]string) string {
+	if len(errors) == 0 {
+		return ""
+	}
+	var out []string
+	for volume, message := range errors {
+		out = append(out, fmt.Sprintf("%v: %v", volume, message))
+	}
+	sort.Strings(out)
+	return ": " + strings.Join(out, ", ")
+}
+
+// SnapshotDrivers lists the OpenEBS CSI drivers that support volume snapshots
+var SnapshotDrivers = []string{
+	// cStor
+	"cstor.csi.openebs.io",
+	// ZFS local persistent volumes
+	"zfs.csi.openebs.io",
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumesnapshot
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gravitational/gravity/lib/compare"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/storage/keyval"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"gopkg.in/check.v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVolumeSnapshot(t *testing.T) { check.TestingT(t) }
+
+type SnapshotSuite struct {
+	backend     storage.Backend
+	cluster     *fakeCluster
+	snapshotter *Snapshotter
+}
+
+var _ = check.Suite(&SnapshotSuite{})
+
+func (s *SnapshotSuite) SetUpTest(c *check.C) {
+	var err error
+	s.backend, err = keyval.NewBolt(keyval.BoltConfig{Path: filepath.Join(c.MkDir(), "bolt.db")})
+	c.Assert(err, check.IsNil)
+	s.cluster = newFakeCluster()
+	s.cluster.storageClasses = map[string]storagev1.StorageClass{
+		"cstor":    newStorageClass("cstor", "cstor.csi.openebs.io"),
+		"zfs":      newStorageClass("zfs", "zfs.csi.openebs.io"),
+		"hostpath": newStorageClass("hostpath", "openebs.io/local"),
+	}
+	s.cluster.snapshotClasses = []snapshotClass{
+		newSnapshotClass("cstor-snapshot", "cstor.csi.openebs.io", false),
+		newSnapshotClass("zfs-snapshot", "zfs.csi.openebs.io", true),
+		newSnapshotClass("zfs-snapshot-retain", "zfs.csi.openebs.io", false),
+	}
+	s.cluster.addClaim(newClaim("default", "data", "cstor", "10Gi"))
+	s.cluster.addClaim(newClaim("db", "postgres", "zfs", "5Gi"))
+	s.cluster.addClaim(newClaim("db", "logs", "hostpath", "1Gi"))
+	s.snapshotter, err = New(Config{
+		Backend:     s.backend,
+		ClusterName: "example.com",
+		Clock:       clockwork.NewFakeClockAt(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)),
+		cluster:     s.cluster,
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SnapshotSuite) TearDownTest(c *check.C) {
+	if s.backend != nil {
+		s.backend.Close()
+	}
+}
+
+func (s *SnapshotSuite) TestCreatesSnapshotOfSupportedClaims(c *check.C) {
+	var etcdSnapshots int
+	snapshot, err := s.snapshotter.Create(context.TODO(), CreateRequest{
+		Name:      "snap1",
+		CreatedBy: "alice@example.com",
+		BackupEtcd: func(context.Context) (*storage.EtcdSnapshot, error) {
+			// the volume snapshots must have been requested by now
+			c.Assert(s.cluster.snapshots, check.HasLen, 2)
+			etcdSnapshots++
+			return &storage.EtcdSnapshot{Node: "node-1", Path: "/backup"}, nil
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(etcdSnapshots, check.Equals, 1)
+	expected := storage.VolumeSnapshot{
+		Name:        "snap1",
+		ClusterName: "example.com",
+		Created:     time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedBy:   "alice@example.com",
+		Volumes: []storage.SnapshotVolume{
+			{
+				Namespace:     "db",
+				Claim:         "postgres",
+				StorageClass:  "zfs",
+				AccessModes:   []string{"ReadWriteOnce"},
+				Capacity:      "5Gi",
+				SnapshotName:  "postgres-snap1",
+				SnapshotClass: "zfs-snapshot",
+			},
+			{
+				Namespace:     "default",
+				Claim:         "data",
+				StorageClass:  "cstor",
+				AccessModes:   []string{"ReadWriteOnce"},
+				Capacity:      "10Gi",
+				SnapshotName:  "data-snap1",
+				SnapshotClass: "cstor-snapshot",
+			},
+		},
+		Etcd: &storage.EtcdSnapshot{Node: "node-1", Path: "/backup"},
+	}
+	compare.DeepCompare(c, snapshot, &expected)
+	recorded, err := s.backend.GetVolumeSnapshot("example.com", "snap1")
+	c.Assert(err, check.IsNil)
+	compare.DeepCompare(c, recorded, &expected)
+	created := s.cluster.snapshots["default/data-snap1"]
+	c.Assert(created.Spec.Source.PersistentVolumeClaimName, check.Equals, "data")
+	c.Assert(created.Spec.VolumeSnapshotClassName, check.Equals, "cstor-snapshot")
+	c.Assert(created.Metadata.Labels[constants.VolumeSnapshotLabel], check.Equals, "snap1")
+
+	_, err = s.snapshotter.Create(context.TODO(), CreateRequest{Name: "snap1"})
+	c.Assert(trace.IsAlreadyExists(err), check.Equals, true)
+}
+
+func (s *SnapshotSuite) TestRejectsUnsupportedClaims(c *check.C) {
+	_, err := s.snapshotter.Create(context.TODO(), CreateRequest{
+		Name:   "snap1",
+		Claims: []string{"default/data", "db/logs"},
+	})
+	c.Assert(err, check.NotNil)
+	c.Assert(s.cluster.snapshots, check.HasLen, 0)
+
+	_, err = s.snapshotter.Create(context.TODO(), CreateRequest{
+		Name:      "snap1",
+		Namespace: "db",
+		Claims:    []string{"postgres"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.cluster.snapshots, check.HasLen, 1)
+}
+
+func (s *SnapshotSuite) TestCleansUpFailedSnapshots(c *check.C) {
+	s.cluster.notReady = map[string]string{"default/data-snap1": "volume is offline"}
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	_, err := s.snapshotter.Create(ctx, CreateRequest{Name: "snap1"})
+	c.Assert(trace.IsLimitExceeded(err), check.Equals, true)
+	c.Assert(err, check.ErrorMatches, ".*volume is offline.*")
+	c.Assert(s.cluster.snapshots, check.HasLen, 0)
+	_, err = s.backend.GetVolumeSnapshot("example.com", "snap1")
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+}
+
+func (s *SnapshotSuite) TestRestoresSnapshot(c *check.C) {
+	_, err := s.snapshotter.Create(context.TODO(), CreateRequest{Name: "snap1", Namespace: "default"})
+	c.Assert(err, check.IsNil)
+
+	// the original claim still exists
+	_, err = s.snapshotter.Restore(context.TODO(), RestoreRequest{Name: "snap1"})
+	c.Assert(trace.IsAlreadyExists(err), check.Equals, true)
+
+	claims, err := s.snapshotter.Restore(context.TODO(), RestoreRequest{Name: "snap1", Suffix: "restored"})
+	c.Assert(err, check.IsNil)
+	c.Assert(claims, check.DeepEquals, []string{"default/data-restored"})
+	claim := s.cluster.claims["default/data-restored"]
+	c.Assert(*claim.Spec.StorageClassName, check.Equals, "cstor")
+	c.Assert(claim.Spec.DataSource.Kind, check.Equals, "VolumeSnapshot")
+	c.Assert(claim.Spec.DataSource.Name, check.Equals, "data-snap1")
+	capacity := claim.Spec.Resources.Requests[v1.ResourceStorage]
+	c.Assert(capacity.String(), check.Equals, "10Gi")
+}
+
+func (s *SnapshotSuite) TestDeletesSnapshot(c *check.C) {
+	_, err := s.snapshotter.Create(context.TODO(), CreateRequest{Name: "snap1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.cluster.snapshots, check.HasLen, 2)
+
+	snapshot, err := s.snapshotter.Delete(context.TODO(), "snap1")
+	c.Assert(err, check.IsNil)
+	c.Assert(snapshot.Name, check.Equals, "snap1")
+	c.Assert(s.cluster.snapshots, check.HasLen, 0)
+	_, err = s.backend.GetVolumeSnapshot("example.com", "snap1")
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+}
+
+func (s *SnapshotSuite) TestSelectsSnapshotClass(c *check.C) {
+	classes := []snapshotClass{
+		newSnapshotClass("a", "zfs.csi.openebs.io", false),
+		newSnapshotClass("b", "zfs.csi.openebs.io", false),
+		newSnapshotClass("c", "cstor.csi.openebs.io", false),
+	}
+	class, err := selectSnapshotClass(classes, "cstor.csi.openebs.io", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(class, check.Equals, "c")
+	class, err = selectSnapshotClass(classes, "zfs.csi.openebs.io", "b")
+	c.Assert(err, check.IsNil)
+	c.Assert(class, check.Equals, "b")
+	_, err = selectSnapshotClass(classes, "zfs.csi.openebs.io", "")
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+	_, err = selectSnapshotClass(classes, "zfs.csi.openebs.io", "c")
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+}
+
+func newFakeCluster() *fakeCluster {
+	return &fakeCluster{
+		claims:    make(map[string]v1.PersistentVolumeClaim),
+		snapshots: make(map[string]snapshot),
+	}
+}
+
+// fakeCluster implements the Kubernetes API in memory.
+// Snapshots become ready to use immediately unless listed in notReady
+type fakeCluster struct {
+	claims          map[string]v1.PersistentVolumeClaim
+	storageClasses  map[string]storagev1.StorageClass
+	snapshotClasses []snapshotClass
+	snapshots       map[string]snapshot
+	// notReady maps snapshots that never become ready to their errors
+	notReady map[string]string
+}
+
+func (r *fakeCluster) addClaim(claim v1.PersistentVolumeClaim) {
+	r.claims[claim.Namespace+"/"+claim.Name] = claim
+}
+
+func (r *fakeCluster) listClaims(namespace, selector string) (claims []v1.PersistentVolumeClaim, err error) {
+	for _, claim := range r.claims {
+		if namespace == "" || claim.Namespace == namespace {
+			claims = append(claims, claim)
+		}
+	}
+	return claims, nil
+}
+
+func (r *fakeCluster) getClaim(namespace, name string) (*v1.PersistentVolumeClaim, error) {
+	claim, ok := r.claims[namespace+"/"+name]
+	if !ok {
+		return nil, trace.NotFound("claim %v/%v not found", namespace, name)
+	}
+	return &claim, nil
+}
+
+func (r *fakeCluster) createClaim(claim v1.PersistentVolumeClaim) error {
+	r.addClaim(claim)
+	return nil
+}
+
+func (r *fakeCluster) getStorageClass(name string) (*storagev1.StorageClass, error) {
+	class, ok := r.storageClasses[name]
+	if !ok {
+		return nil, trace.NotFound("storage class %v not found", name)
+	}
+	return &class, nil
+}
+
+func (r *fakeCluster) listSnapshotClasses() ([]snapshotClass, error) {
+	return r.snapshotClasses, nil
+}
+
+func (r *fakeCluster) createSnapshot(snapshot snapshot) error {
+	key := snapshot.Metadata.Namespace + "/" + snapshot.Metadata.Name
+	snapshot.Status = &snapshotStatus{ReadyToUse: true}
+	if message, ok := r.notReady[key]; ok {
+		snapshot.Status.ReadyToUse = false
+		snapshot.Status.Error = &struct {
+			Message string `json:"message,omitempty"`
+		}{Message: message}
+	}
+	r.snapshots[key] = snapshot
+	return nil
+}
+
+func (r *fakeCluster) getSnapshot(namespace, name string) (*snapshot, error) {
+	snapshot, ok := r.snapshots[namespace+"/"+name]
+	if !ok {
+		return nil, trace.NotFound("snapshot %v/%v not found", namespace, name)
+	}
+	return &snapshot, nil
+}
+
+func (r *fakeCluster) deleteSnapshot(namespace, name string) error {
+	key := namespace + "/" + name
+	if _, ok := r.snapshots[key]; !ok {
+		return trace.NotFound("snapshot %v not found", key)
+	}
+	delete(r.snapshots, key)
+	return nil
+}
+
+func newClaim(namespace, name, storageClass, capacity string) v1.PersistentVolumeClaim {
+	return v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: resource.MustParse(capacity),
+				},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+	}
+}
+
+func newStorageClass(name, provisioner string) storagev1.StorageClass {
+	return storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name},
+		Provisioner: provisioner,
+	}
+}
+
+func newSnapshotClass(name, driver string, isDefault bool) snapshotClass {
+	var class snapshotClass
+	class.Metadata.Name = name
+	class.Driver = driver
+	if isDefault {
+		class.Metadata.Annotations = map[string]string{defaultSnapshotClassAnnotation: "true"}
+	}
+	return class
+}
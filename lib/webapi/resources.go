@@ -26,6 +26,7 @@ import (
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/ops/resources"
 	"github.com/gravitational/gravity/lib/ops/resources/gravity"
+	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/utils"
 	"github.com/gravitational/gravity/lib/webapi/ui"
 
@@ -88,6 +89,73 @@ func (m *Handler) deleteResourceHandler(w http.ResponseWriter, r *http.Request,
 	return httplib.OK(), nil
 }
 
+// upsertInstallResourcesHandler is PUT handler that uploads the resources
+// created as a part of the install operation, e.g. the public TLS certificate,
+// auth connectors and initial users.
+//
+// PUT /portalapi/v1/sites/:domain/installresources
+//
+// Input:
+// {
+//   "content": "<one or more resources in YAML or JSON format>"
+// }
+func (m *Handler) upsertInstallResourcesHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params, ctx *AuthContext) (interface{}, error) {
+	var input installResourcesInput
+	if err := telehttplib.ReadJSON(r, &input); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var installResources []storage.UnknownResource
+	err := resources.ForEach(strings.NewReader(input.Content), func(resource storage.UnknownResource) error {
+		installResources = append(installResources, resource)
+		return nil
+	})
+	if err != nil {
+		return nil, trace.BadParameter("not a valid resource declaration: %v", err)
+	}
+	key := clusterKey(ctx, p)
+	err = ctx.Operator.UpsertInstallResources(r.Context(), ops.UpsertInstallResourcesRequest{
+		AccountID:  key.AccountID,
+		SiteDomain: key.SiteDomain,
+		Resources:  installResources,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return httplib.OK(), nil
+}
+
+// getInstallResourcesHandler is GET handler that returns the kinds and names
+// of the resources uploaded for the cluster being installed
+//
+// GET /portalapi/v1/sites/:domain/installresources
+func (m *Handler) getInstallResourcesHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params, ctx *AuthContext) (interface{}, error) {
+	installResources, err := ctx.Operator.GetInstallResources(clusterKey(ctx, p))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	items := make([]installResourceItem, 0, len(installResources))
+	for _, resource := range installResources {
+		items = append(items, installResourceItem{
+			Kind: resource.Kind,
+			Name: resource.Metadata.Name,
+		})
+	}
+	return items, nil
+}
+
+type installResourcesInput struct {
+	// Content lists the resources in YAML or JSON format
+	Content string `json:"content"`
+}
+
+// installResourceItem describes the resource uploaded for the cluster being installed
+type installResourceItem struct {
+	// Kind is the resource kind
+	Kind string `json:"kind"`
+	// Name is the resource name
+	Name string `json:"name"`
+}
+
 // deleteResource deletes a resource
 func (m *Handler) deleteResource(ctx context.Context, key ops.SiteKey, resourceKind string, resourceName string, authCtx *AuthContext) error {
 	controller, err := m.plugin.Resources(authCtx)
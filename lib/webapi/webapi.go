@@ -219,6 +219,8 @@ func NewAPI(cfg Config) (*Handler, error) {
 	h.PUT("/sites/:domain/resources", h.needsAuth(h.upsertResourceHandler))
 	h.POST("/sites/:domain/resources", h.needsAuth(h.upsertResourceHandler))
 	h.DELETE("/sites/:domain/resources/:kind/:name", h.needsAuth(h.deleteResourceHandler))
+	h.GET("/sites/:domain/installresources", h.needsAuth(h.getInstallResourcesHandler))
+	h.PUT("/sites/:domain/installresources", h.needsAuth(h.upsertInstallResourcesHandler))
 
 	// Tokens
 	h.GET("/tokens/user/:token", telehttplib.MakeHandler(h.getUserToken))
@@ -229,7 +231,7 @@ func NewAPI(cfg Config) (*Handler, error) {
 	// General validation
 	h.GET("/domains/:domain_name", h.needsAuth(h.validateDomainName))
 
-	h.GET("/sites/:domain/operations/:operation_id/progress", h.needsAuth(h.getSiteOperationProgress))
+	h.GET("/sites/:domain/operations/:operation_id/progress", h.needsOperationAuth(h.getSiteOperationProgress))
 
 	// Operations
 	h.GET("/sites/:domain/operations/:operation_id/agent", h.needsAuth(h.agentReport))
@@ -237,6 +239,7 @@ func NewAPI(cfg Config) (*Handler, error) {
 	h.DELETE("/sites/:domain/operations/:operation_id", h.needsAuth(h.deleteOperation))
 	h.GET("/sites/:domain/operations", h.needsAuth(h.getOperations))
 	h.POST("/sites/:domain/operations/:operation_id/prechecks", h.needsAuth(h.validateServers))
+	h.GET("/sites/:domain/operations/:operation_id/artifacts", h.needsAuth(h.getOperationArtifacts))
 
 	// Sites
 	h.POST("/sites", h.needsAuth(h.createSite))
@@ -271,6 +274,8 @@ func NewAPI(cfg Config) (*Handler, error) {
 	h.POST("/apps", h.needsAuth(h.uploadApp))
 	h.GET("/apps/:repository/:package/:version", h.needsAuth(h.getAppPackage))
 	h.GET("/apps/:repository/:package/:version/installer", h.needsAuth(h.getAppInstaller))
+	h.GET("/download/:token", telehttplib.MakeHandler(h.downloadAppInstaller))
+	h.GET("/download/:token/packages/:repository/:package/:version", telehttplib.MakeHandler(h.downloadAppPackage))
 
 	// User
 	h.GET("/sites/:domain/context", h.needsAuth(h.getWebContext))
@@ -624,9 +629,11 @@ func (m *Handler) GetHandlerContext(w http.ResponseWriter, r *http.Request) (*Au
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	ctx := context.WithValue(r.Context(), constants.UserContext, user.GetName())
+	ctx = context.WithValue(ctx, constants.ClientAddrContext, r.RemoteAddr)
 	return &AuthContext{
-		// Enrich request context with authenticated user information.
-		Context:        context.WithValue(r.Context(), constants.UserContext, user.GetName()),
+		// Enrich request context with authenticated user and client information.
+		Context:        ctx,
 		User:           user,
 		Operator:       ops.OperatorWithACL(m.cfg.Operator, m.cfg.Identity, user, checker),
 		Applications:   app.ApplicationsWithACL(m.cfg.Applications, m.cfg.Identity, user, checker),
@@ -649,6 +656,74 @@ func (m *Handler) needsAuth(fn authenticatedHandler) httprouter.Handle {
 	})
 }
 
+type operationHandler func(
+	http.ResponseWriter, *http.Request, httprouter.Params, ops.Operator) (interface{}, error)
+
+// needsOperationAuth is authentication wrapper for the handlers that expose
+// the state of a single operation specified with the first two route parameters
+// (cluster name and operation ID).
+//
+// Besides the web session, it accepts a bearer token issued for the operation.
+// The install wizard is reachable by anyone who can connect to it, so
+// only operation tokens are accepted in wizard mode
+func (m *Handler) needsOperationAuth(fn operationHandler) httprouter.Handle {
+	return telehttplib.MakeHandler(func(w http.ResponseWriter, r *http.Request, params httprouter.Params) (interface{}, error) {
+		token, err := m.getOperationToken(r)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if token == nil {
+			if m.cfg.Mode == constants.ComponentInstaller {
+				return nil, trace.AccessDenied("operation token required")
+			}
+			context, err := m.GetHandlerContext(w, r)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			result, err := fn(w, r.WithContext(context.Context), params, context.Operator)
+			return result, trace.Wrap(err)
+		}
+		siteDomain, operationID := params[0].Value, params[1].Value
+		cluster, err := m.cfg.Operator.GetSiteByDomain(siteDomain)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				return nil, trace.AccessDenied("token is not valid for operation %v", operationID)
+			}
+			return nil, trace.Wrap(err)
+		}
+		err = token.Verify(cluster.AccountID, siteDomain, operationID, m.cfg.Backend.Now().UTC())
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		// the token only grants access to the operation handlers which
+		// are not bound to a user so the operator is not wrapped with ACL
+		ctx := context.WithValue(r.Context(), constants.ClientAddrContext, r.RemoteAddr)
+		result, err := fn(w, r.WithContext(ctx), params, m.cfg.Operator)
+		return result, trace.Wrap(err)
+	})
+}
+
+// getOperationToken returns the operation token the request has been
+// authenticated with or nil if the request does not carry an operation token
+func (m *Handler) getOperationToken(r *http.Request) (*storage.OperationToken, error) {
+	creds, err := httplib.ParseAuthHeaders(r)
+	if err != nil || !creds.IsToken() {
+		return nil, nil
+	}
+	token, err := m.cfg.Backend.GetOperationToken(creds.Password)
+	if err != nil {
+		if !trace.IsNotFound(err) {
+			return nil, trace.Wrap(err)
+		}
+		if m.cfg.Mode == constants.ComponentInstaller {
+			return nil, trace.AccessDenied("invalid or expired operation token")
+		}
+		// not an operation token, authenticate with the web session
+		return nil, nil
+	}
+	return token, nil
+}
+
 // recoverPasswordComplete finalizes password recovery process
 //
 // POST /portalapi/v1/recoveries/start
@@ -810,9 +885,9 @@ func (m *Handler) validateProvider(w http.ResponseWriter, r *http.Request, p htt
 // }
 //
 
-func (m *Handler) getSiteOperationProgress(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *AuthContext) (interface{}, error) {
+func (m *Handler) getSiteOperationProgress(w http.ResponseWriter, r *http.Request, p httprouter.Params, operator ops.Operator) (interface{}, error) {
 	siteDomain, operationID := p[0].Value, p[1].Value
-	site, err := context.Operator.GetSiteByDomain(siteDomain)
+	site, err := operator.GetSiteByDomain(siteDomain)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -823,7 +898,7 @@ func (m *Handler) getSiteOperationProgress(w http.ResponseWriter, r *http.Reques
 		OperationID: operationID,
 	}
 
-	progressEntry, err := context.Operator.GetSiteOperationProgress(opKey)
+	progressEntry, err := operator.GetSiteOperationProgress(opKey)
 
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -959,6 +1034,8 @@ type siteCreateInput struct {
 	License string `json:"license"`
 	// Labels is a custom key/value metadata to attach to a new site
 	Labels map[string]string `json:"labels"`
+	// CNI is the network plugin to install the cluster with
+	CNI string `json:"cni,omitempty"`
 }
 
 type cloudProvider struct {
@@ -1043,6 +1120,7 @@ func (m *Handler) createSite(w http.ResponseWriter, r *http.Request, p httproute
 		License:      input.License,
 		Labels:       input.Labels,
 		InstallToken: m.cfg.InstallToken,
+		CNI:          input.CNI,
 	}
 
 	var vars storage.OperationVariables
@@ -1748,6 +1826,38 @@ func (m *Handler) getSiteReport(w http.ResponseWriter, r *http.Request, p httpro
 	return nil, trace.Wrap(err)
 }
 
+// getOperationArtifacts returns a tarball with the artifacts of the specified operation:
+// the operation record, the operation plan, the last progress entry and the operation log
+//
+//   GET /portalapi/v1/sites/:domain/operations/:operation_id/artifacts
+//
+// Input:
+//
+//   none
+//
+// Output:
+//
+//   operation-<operation_id>.tar.gz
+func (m *Handler) getOperationArtifacts(w http.ResponseWriter, r *http.Request, p httprouter.Params, context *AuthContext) (interface{}, error) {
+	key := ops.SiteOperationKey{
+		AccountID:   context.User.GetAccountID(),
+		SiteDomain:  p.ByName("domain"),
+		OperationID: p.ByName("operation_id"),
+	}
+	reader, err := context.Operator.GetOperationArtifacts(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/x-gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%v",
+		ops.OperationArtifactsTarball(key.OperationID)))
+
+	_, err = io.Copy(w, reader)
+	return nil, trace.Wrap(err)
+}
+
 // getServers obtains the list of server nodes for the specified site
 //
 // GET /portalapi/v1/sites/:domain/servers
@@ -2019,6 +2129,114 @@ func (m *Handler) getAppInstaller(w http.ResponseWriter, r *http.Request, p http
 	return nil, trace.Wrap(err)
 }
 
+/* downloadAppInstaller returns a binary byte stream of the standalone installer
+   for the application the specified download token grants access to.
+
+   The token authenticates the request so the installer can be shared
+   with users without an account until the token expires
+
+GET /portalapi/v1/download/:token
+
+*/
+func (m *Handler) downloadAppInstaller(w http.ResponseWriter, r *http.Request, p httprouter.Params) (interface{}, error) {
+	token, err := m.getDownloadToken(p.ByName("token"))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	reader, err := m.cfg.Operator.GetAppInstaller(ops.AppInstallerRequest{
+		AccountID:   token.AccountID,
+		Application: token.Application,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v-%v.tar"`,
+		token.Application.Name, token.Application.Version))
+	_, err = io.Copy(w, reader)
+	return nil, trace.Wrap(err)
+}
+
+/* downloadAppPackage streams the contents of the specified package if it is
+   the application the specified download token grants access to or
+   one of the application's dependencies.
+
+   The token authenticates the request so the packages of the application
+   can be pulled by users without an account until the token expires
+
+GET /portalapi/v1/download/:token/packages/:repository/:package/:version
+
+*/
+func (m *Handler) downloadAppPackage(w http.ResponseWriter, r *http.Request, p httprouter.Params) (interface{}, error) {
+	token, err := m.getDownloadToken(p.ByName("token"))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	locator, err := loc.NewLocator(p.ByName("repository"), p.ByName("package"), p.ByName("version"))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := m.checkDownloadTokenScope(*token, *locator); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	_, reader, err := m.cfg.Packages.ReadPackage(*locator)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer reader.Close()
+
+	readSeeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		return nil, trace.BadParameter("expected read seeker object")
+	}
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename=%v.tar`, locator.String()))
+	http.ServeContent(w, r, locator.String(), time.Now(), readSeeker)
+	return nil, nil
+}
+
+// getDownloadToken returns the active download token with the specified ID
+func (m *Handler) getDownloadToken(tokenID string) (*storage.DownloadToken, error) {
+	token, err := m.cfg.Backend.GetDownloadToken(tokenID)
+	if err != nil {
+		log.WithError(err).Warn("Failed to fetch download token.")
+		// we hide the error from the remote user to avoid giving any hints
+		return nil, trace.AccessDenied("bad or expired token")
+	}
+	return token, nil
+}
+
+// checkDownloadTokenScope verifies that the specified download token grants
+// access to the package with the given locator: the package is either
+// the application of the token or one of its dependencies
+func (m *Handler) checkDownloadTokenScope(token storage.DownloadToken, locator loc.Locator) error {
+	if locator.IsEqualTo(token.Application) {
+		return nil
+	}
+	application, err := m.cfg.Applications.GetApp(token.Application)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	dependencies, err := app.GetDependencies(application, m.cfg.Applications)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, dependency := range dependencies.Packages {
+		if dependency.IsEqualTo(locator) {
+			return nil
+		}
+	}
+	for _, dependency := range dependencies.Apps {
+		if dependency.IsEqualTo(locator) {
+			return nil
+		}
+	}
+	return trace.AccessDenied("download token does not grant access to %v", locator)
+}
+
 // getClusterMetrics returns basic cluster metrics.
 //
 //   GET /sites/:domain/monitoring/metrics?interval=<duration>&step=<duration>
@@ -34,6 +34,7 @@ import (
 	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/systeminfo"
@@ -44,28 +45,29 @@ import (
 	"github.com/gravitational/trace"
 )
 
-// outputAppHook runs an application hook and prints the output
-func outputAppHook(env *localenv.LocalEnvironment, req appservice.HookRunRequest) error {
-	out, err := runAppHook(env, req)
-	fmt.Printf("%s", out)
-	return trace.Wrap(err)
-}
-
-// runAppHook runs an application hook specified with hook
-func runAppHook(env *localenv.LocalEnvironment, req appservice.HookRunRequest) (string, error) {
+// runAppHook runs the application hook specified with req and streams
+// the logs of the hook job to stdout until the job completes.
+// If the hook fails, the returned error carries the exit code of the hook container
+func runAppHook(env *localenv.LocalEnvironment, req appservice.HookRunRequest) error {
 	registryURL, err := localAppEnviron()
 	if err != nil {
-		return "", trace.Wrap(err)
+		return trace.Wrap(err)
 	}
 	apps, err := env.AppServiceLocal(localenv.AppConfig{RegistryURL: registryURL})
 	if err != nil {
-		return "", trace.Wrap(err)
+		return trace.Wrap(err)
 	}
-	_, out, err := appservice.RunAppHook(context.TODO(), apps, req)
+	ref, err := appservice.StreamAppHook(context.TODO(), apps, req, utils.NopWriteCloser(os.Stdout))
 	if err != nil {
-		return string(out), trace.Wrap(err, "failed to run hook: %s", out)
+		if exitErr, ok := utils.AsExitCodeError(err); ok {
+			return utils.WrapExitCodeError(exitErr.ExitCode(), trace.Wrap(err,
+				"hook %v of %v exited with code %v", req.Hook, req.Application, exitErr.ExitCode()))
+		}
+		return trace.Wrap(err, "hook %v of %v failed", req.Hook, req.Application)
 	}
-	return string(out), nil
+	env.Printf("Hook %v of %v completed successfully (job %v/%v).\n",
+		req.Hook, req.Application, ref.Namespace, ref.Name)
+	return nil
 }
 
 // statusApp prints application status in json format
@@ -139,6 +141,70 @@ func uninstallAppPackage(env *localenv.LocalEnvironment, appPackage loc.Locator)
 	return nil
 }
 
+// enableComponent installs the optional component of the cluster application
+func enableComponent(env *localenv.LocalEnvironment, component string) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	key, err := operator.EnableComponent(context.TODO(), ops.ComponentRequest{
+		AccountID:  cluster.AccountID,
+		SiteDomain: cluster.Domain,
+		Component:  component,
+	})
+	if err != nil {
+		if trace.IsAlreadyExists(err) {
+			env.Printf("Component %v is already enabled\n", component)
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Started operation %v to enable component %v", key.OperationID, component)
+	ctx, cancel := context.WithTimeout(context.Background(), defaults.ComponentOperationTimeout)
+	defer cancel()
+	if err := waitForOperation(ctx, env, operator, *key); err != nil {
+		return trace.Wrap(err)
+	}
+	env.Printf("Component %v enabled\n", component)
+	return nil
+}
+
+// disableComponent uninstalls the optional component of the cluster application
+func disableComponent(env *localenv.LocalEnvironment, component string) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	key, err := operator.DisableComponent(context.TODO(), ops.ComponentRequest{
+		AccountID:  cluster.AccountID,
+		SiteDomain: cluster.Domain,
+		Component:  component,
+	})
+	if err != nil {
+		if trace.IsAlreadyExists(err) {
+			env.Printf("Component %v is already disabled\n", component)
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Started operation %v to disable component %v", key.OperationID, component)
+	ctx, cancel := context.WithTimeout(context.Background(), defaults.ComponentOperationTimeout)
+	defer cancel()
+	if err := waitForOperation(ctx, env, operator, *key); err != nil {
+		return trace.Wrap(err)
+	}
+	env.Printf("Component %v disabled\n", component)
+	return nil
+}
+
 // importApp imports an application from the specified directory creating a new
 // package named packageName.
 func importApp(env *localenv.LocalEnvironment, registryURL, dockerURL, source string, req *appservice.ImportRequest,
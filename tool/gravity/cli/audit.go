@@ -0,0 +1,196 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/events"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/tool/common"
+
+	teleevents "github.com/gravitational/teleport/lib/events"
+	"github.com/gravitational/trace"
+)
+
+// isAuditedCommand returns true if the specified command mutates the state
+// of the node or the cluster and should be recorded in the audit log
+func isAuditedCommand(g *Application, cmd string) bool {
+	switch cmd {
+	case g.InstallCmd.FullCommand(),
+		g.JoinCmd.FullCommand(),
+		g.AutoJoinCmd.FullCommand(),
+		g.LeaveCmd.FullCommand(),
+		g.RemoveCmd.FullCommand(),
+		g.UpgradeCmd.FullCommand(),
+		g.UpdateTriggerCmd.FullCommand(),
+		g.UpdateCatchUpCmd.FullCommand(),
+		g.ResourceCreateCmd.FullCommand(),
+		g.ResourceRemoveCmd.FullCommand():
+		return true
+	}
+	return false
+}
+
+// recordAuditEvent records the outcome of the specified command in the local
+// audit log and forwards it to the cluster audit log if the cluster is available.
+// Failures are logged but otherwise ignored to not affect the outcome of the command
+func recordAuditEvent(env *localenv.LocalEnvironment, cmd string, args []string, cmdErr error) {
+	event := newAuditEvent(cmd, args, cmdErr)
+	created, err := env.Backend.CreateAuditEvent(event)
+	if err != nil {
+		log.WithError(err).Warn("Failed to record audit event.")
+		return
+	}
+	if err := emitAuditEvent(env, *created); err != nil {
+		log.WithError(err).Debug("Failed to forward audit event to cluster.")
+	}
+}
+
+func newAuditEvent(cmd string, args []string, cmdErr error) storage.AuditEvent {
+	event := storage.AuditEvent{
+		Command: cmd,
+		Args:    redactArgs(args),
+	}
+	if current, err := user.Current(); err == nil {
+		event.User = current.Username
+	}
+	if sudoUser := os.Getenv(constants.EnvSudoUser); sudoUser != "" {
+		event.User = sudoUser
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		event.Node = hostname
+	}
+	if cmdErr != nil {
+		event.Error = trace.UserMessage(cmdErr)
+	}
+	return event
+}
+
+// emitAuditEvent ships the provided event to the audit log of the local cluster
+func emitAuditEvent(env *localenv.LocalEnvironment, event storage.AuditEvent) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	auditEvent := events.CommandExecuted
+	if !event.IsSuccessful() {
+		auditEvent = events.CommandFailed
+	}
+	return operator.EmitAuditEvent(context.TODO(), ops.AuditEventRequest{
+		SiteKey: cluster.Key(),
+		Event:   auditEvent,
+		Fields:  teleevents.EventFields(events.FieldsForAuditEvent(event)),
+	})
+}
+
+// redactArgs returns a copy of the command line arguments with values
+// of the sensitive flags hidden
+func redactArgs(args []string) []string {
+	result := make([]string, 0, len(args))
+	var redactNext bool
+	for _, arg := range args {
+		// A boolean flag (i.e. status --token) is not followed by a value
+		if redactNext && !strings.HasPrefix(arg, "-") {
+			result = append(result, redactedValue)
+			redactNext = false
+			continue
+		}
+		redactNext = false
+		name := strings.SplitN(arg, "=", 2)[0]
+		if !isSensitiveFlag(name) {
+			result = append(result, arg)
+			continue
+		}
+		if strings.Contains(arg, "=") {
+			result = append(result, fmt.Sprintf("%v=%v", name, redactedValue))
+		} else {
+			result = append(result, arg)
+			redactNext = true
+		}
+	}
+	return result
+}
+
+func isSensitiveFlag(name string) bool {
+	for _, flag := range sensitiveFlags {
+		if name == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func listAuditEvents(env *localenv.LocalEnvironment, since time.Duration) error {
+	var from time.Time
+	if since != 0 {
+		from = time.Now().UTC().Add(-since)
+	}
+	auditEvents, err := env.Backend.GetAuditEvents(from)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if len(auditEvents) == 0 {
+		env.Println("No actions recorded.")
+		return nil
+	}
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Time", "User", "Node", "Command", "Outcome"})
+	for _, event := range auditEvents {
+		outcome := event.Outcome()
+		if !event.IsSuccessful() {
+			outcome = fmt.Sprintf("%v: %v", outcome, event.Error)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n",
+			event.Created.Format(constants.HumanDateFormat),
+			event.User,
+			event.Node,
+			strings.Join(event.Args, " "),
+			outcome)
+	}
+	w.Flush()
+	return nil
+}
+
+// sensitiveFlags lists command line flags whose values are not recorded
+var sensitiveFlags = []string{
+	// install and join tokens
+	"--token",
+	"--ops-token",
+	"--password",
+	// paths to private keys
+	"--ca-key",
+	"--registry-key",
+	// application values can include credentials
+	"--set",
+}
+
+const redactedValue = "<redacted>"
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (*S) TestRedactsSensitiveArgs(c *check.C) {
+	var testCases = []struct {
+		comment string
+		args    []string
+		result  []string
+	}{
+		{
+			comment: "Redacts flag value given with equal sign",
+			args:    []string{"join", "10.0.0.1", "--token=secret"},
+			result:  []string{"join", "10.0.0.1", "--token=<redacted>"},
+		},
+		{
+			comment: "Redacts flag value given as a separate argument",
+			args:    []string{"join", "--token", "secret", "--role", "node"},
+			result:  []string{"join", "--token", "<redacted>", "--role", "node"},
+		},
+		{
+			comment: "Redacts private key paths",
+			args:    []string{"install", "--ca-cert", "ca.pem", "--ca-key=ca-key.pem", "--registry-key", "registry.key"},
+			result:  []string{"install", "--ca-cert", "ca.pem", "--ca-key=<redacted>", "--registry-key", "<redacted>"},
+		},
+		{
+			comment: "Redacts application values",
+			args:    []string{"app", "install", "app.tar", "--set", "password=secret"},
+			result:  []string{"app", "install", "app.tar", "--set", "<redacted>"},
+		},
+		{
+			comment: "Does not redact the flag following a boolean flag",
+			args:    []string{"status", "--token", "--quiet"},
+			result:  []string{"status", "--token", "--quiet"},
+		},
+		{
+			comment: "Keeps other arguments intact",
+			args:    []string{"resource", "create", "token.yaml"},
+			result:  []string{"resource", "create", "token.yaml"},
+		},
+	}
+	for _, tc := range testCases {
+		c.Assert(redactArgs(tc.args), check.DeepEquals, tc.result, check.Commentf(tc.comment))
+	}
+}
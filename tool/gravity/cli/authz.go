@@ -0,0 +1,208 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/localenv/credentials"
+	"github.com/gravitational/gravity/lib/modules"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/opsclient"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleclient "github.com/gravitational/teleport/lib/client"
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+)
+
+// accessRule describes the cluster permission required to run a command
+type accessRule struct {
+	// kind is the resource kind
+	kind string
+	// verb is the action on the resource
+	verb string
+	// readOnly is whether the command only reads the cluster state
+	// and can be run by users other than root
+	readOnly bool
+}
+
+// commandAccessRule returns the cluster permission required to run
+// the specified command.
+// Returns false if the command is not subject to authorization
+func commandAccessRule(g *Application, cmd string) (rule accessRule, ok bool) {
+	switch cmd {
+	case g.StatusCmd.FullCommand(),
+		g.PlanCmd.FullCommand(),
+		g.PlanDisplayCmd.FullCommand(),
+		g.NodeListCmd.FullCommand():
+		return accessRule{kind: storage.KindCluster, verb: teleservices.VerbRead, readOnly: true}, true
+	case g.UpdateTriggerCmd.FullCommand(),
+		g.UpgradeCmd.FullCommand(),
+		g.RemoveCmd.FullCommand(),
+		g.NodeLabelCmd.FullCommand():
+		return accessRule{kind: storage.KindCluster, verb: teleservices.VerbUpdate}, true
+	}
+	return accessRule{}, false
+}
+
+// isUserCommand returns true if the specified command is run by a user
+// other than root and only reads the cluster state.
+// Such commands have no access to the node state and query the cluster
+// with the credentials of the user instead
+func isUserCommand(g *Application, cmd string) bool {
+	rule, ok := commandAccessRule(g, cmd)
+	return ok && rule.readOnly && !runningAsRoot() && *g.StateDir == ""
+}
+
+// resourceAccessVerbs returns the verbs required to create a resource
+// or to remove it if remove is set
+func resourceAccessVerbs(upsert, remove bool) []string {
+	switch {
+	case remove:
+		return []string{teleservices.VerbDelete}
+	case upsert:
+		return []string{teleservices.VerbCreate, teleservices.VerbUpdate}
+	}
+	return []string{teleservices.VerbCreate}
+}
+
+// authorizeCommand checks the user the specified command is run with
+// against the cluster roles.
+//
+// The user is identified with the credentials obtained by logging into
+// the cluster with 'tsh login'. When run with sudo, the credentials of the user
+// who invoked sudo are used. Only root without a cluster login is not checked:
+// it owns the node and its credentials anyway
+func authorizeCommand(env *localenv.LocalEnvironment, g *Application, cmd string) error {
+	rule, ok := commandAccessRule(g, cmd)
+	if !ok {
+		return nil
+	}
+	return trace.Wrap(checkClusterAccess(env, rule.kind, rule.verb))
+}
+
+// authorizeResource checks whether the user is allowed to create
+// (or remove, if remove is set) resources of the specified kind
+func authorizeResource(env *localenv.LocalEnvironment, kind string, upsert, remove bool) error {
+	kind = modules.GetResources().CanonicalKind(kind)
+	for _, verb := range resourceAccessVerbs(upsert, remove) {
+		if err := checkClusterAccess(env, kind, verb); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// checkClusterAccess asks the cluster whether the current user is allowed
+// to perform the action specified with verb on resources of the given kind.
+// Fails closed if the user or the permissions cannot be determined
+func checkClusterAccess(env *localenv.LocalEnvironment, kind, verb string) error {
+	operator, err := getCallerOperator(env)
+	if err != nil {
+		if !credentials.IsCredentialsNotFoundError(trace.Unwrap(err)) {
+			return trace.Wrap(err, "failed to determine the cluster user")
+		}
+		if runningAsRoot() {
+			return nil
+		}
+		return trace.AccessDenied("no cluster credentials found, log into " +
+			"the cluster with 'tsh login' to run this command as a non-root user")
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err, "failed to check the cluster permissions")
+	}
+	err = operator.CheckAccess(context.TODO(), ops.CheckAccessRequest{
+		SiteKey: cluster.Key(),
+		Kind:    kind,
+		Verb:    verb,
+	})
+	if err != nil {
+		if trace.IsAccessDenied(err) {
+			return trace.AccessDenied("cluster roles do not allow you to %v %v, "+
+				"ask the cluster administrator to grant the permission", verb, kind)
+		}
+		return trace.Wrap(err, "failed to check the cluster permissions")
+	}
+	return nil
+}
+
+// getCallerOperator returns the operator of the cluster the user running the command
+// has logged into. It is a variable to be replaced in tests
+var getCallerOperator = callerOperator
+
+// callerOperator returns the operator of the cluster that authenticates requests
+// with the credentials of the user running the command.
+// Returns the credentials not found error if the user has not logged into a cluster
+func callerOperator(env *localenv.LocalEnvironment) (ops.Operator, error) {
+	keyStoreDir, err := callerKeyStoreDir()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	creds, err := credentials.FromTeleportProfile(keyStoreDir)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	operator, err := localenv.NewOpsClient(creds.Entry, creds.URL,
+		opsclient.HTTPClient(env.HTTPClient(httplib.WithTLSClientConfig(creds.TLS))))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return operator, nil
+}
+
+// readerOperator returns the operator to read the cluster state with.
+// Root uses the credentials of the node while other users query the cluster
+// with their own cluster credentials
+func readerOperator(env *localenv.LocalEnvironment) (ops.Operator, error) {
+	if !runningAsRoot() {
+		return getCallerOperator(env)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return operator, nil
+}
+
+// callerKeyStoreDir returns the Teleport key store directory of the user
+// running the command. With sudo, this is the key store of the user
+// who invoked sudo rather than that of root
+func callerKeyStoreDir() (string, error) {
+	sudoUser := os.Getenv(constants.EnvSudoUser)
+	if sudoUser == "" || !runningAsRoot() {
+		// Use the default key store in the home directory of the current user
+		return "", nil
+	}
+	u, err := user.Lookup(sudoUser)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return filepath.Join(u.HomeDir, teleclient.ProfileDir), nil
+}
+
+// runningAsRoot returns true if the process is run by root
+func runningAsRoot() bool {
+	return os.Geteuid() == 0
+}
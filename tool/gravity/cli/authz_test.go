@@ -0,0 +1,159 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/localenv/credentials"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	teleservices "github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/check.v1"
+)
+
+func (*S) TestCommandAccessRules(c *check.C) {
+	g := RegisterCommands(kingpin.New("gravity", ""))
+	var testCases = []struct {
+		comment string
+		cmd     string
+		rule    accessRule
+		ok      bool
+	}{
+		{
+			comment: "status is read-only",
+			cmd:     g.StatusCmd.FullCommand(),
+			rule:    accessRule{kind: storage.KindCluster, verb: teleservices.VerbRead, readOnly: true},
+			ok:      true,
+		},
+		{
+			comment: "plan display is read-only",
+			cmd:     g.PlanDisplayCmd.FullCommand(),
+			rule:    accessRule{kind: storage.KindCluster, verb: teleservices.VerbRead, readOnly: true},
+			ok:      true,
+		},
+		{
+			comment: "upgrade trigger requires update permission",
+			cmd:     g.UpdateTriggerCmd.FullCommand(),
+			rule:    accessRule{kind: storage.KindCluster, verb: teleservices.VerbUpdate},
+			ok:      true,
+		},
+		{
+			comment: "node removal requires update permission",
+			cmd:     g.RemoveCmd.FullCommand(),
+			rule:    accessRule{kind: storage.KindCluster, verb: teleservices.VerbUpdate},
+			ok:      true,
+		},
+		{
+			comment: "other commands are not checked",
+			cmd:     g.VersionCmd.FullCommand(),
+		},
+	}
+	for _, tc := range testCases {
+		rule, ok := commandAccessRule(g, tc.cmd)
+		comment := check.Commentf(tc.comment)
+		c.Assert(ok, check.Equals, tc.ok, comment)
+		c.Assert(rule, check.DeepEquals, tc.rule, comment)
+	}
+	c.Assert(resourceAccessVerbs(false, false), check.DeepEquals, []string{teleservices.VerbCreate})
+	c.Assert(resourceAccessVerbs(true, false), check.DeepEquals,
+		[]string{teleservices.VerbCreate, teleservices.VerbUpdate})
+	c.Assert(resourceAccessVerbs(false, true), check.DeepEquals, []string{teleservices.VerbDelete})
+}
+
+func (*S) TestDeniesCommandForbiddenByClusterRoles(c *check.C) {
+	operator := &testCallerOperator{
+		accessErr: trace.AccessDenied("access denied"),
+	}
+	defer replaceCallerOperator(operator, nil)()
+
+	g := RegisterCommands(kingpin.New("gravity", ""))
+	cmd, err := g.Parse([]string{"--state-dir", c.MkDir(), "update", "trigger"})
+	c.Assert(err, check.IsNil)
+	c.Assert(cmd, check.Equals, g.UpdateTriggerCmd.FullCommand())
+
+	err = Execute(g, cmd, nil)
+	c.Assert(trace.IsAccessDenied(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(operator.checks, check.DeepEquals, []ops.CheckAccessRequest{{
+		SiteKey: ops.SiteKey{AccountID: "account", SiteDomain: "example.com"},
+		Kind:    storage.KindCluster,
+		Verb:    teleservices.VerbUpdate,
+	}})
+}
+
+func (*S) TestFailsClosedIfPermissionsCannotBeChecked(c *check.C) {
+	operator := &testCallerOperator{
+		clusterErr: trace.ConnectionProblem(nil, "cluster is unreachable"),
+	}
+	defer replaceCallerOperator(operator, nil)()
+
+	err := checkClusterAccess(nil, storage.KindCluster, teleservices.VerbUpdate)
+	c.Assert(trace.IsConnectionProblem(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(operator.checks, check.HasLen, 0)
+}
+
+func (*S) TestRootWithoutClusterLoginIsNotChecked(c *check.C) {
+	if !runningAsRoot() {
+		c.Skip("requires root")
+	}
+	_, notFoundErr := credentials.FromTeleportProfile(c.MkDir())
+	c.Assert(credentials.IsCredentialsNotFoundError(trace.Unwrap(notFoundErr)), check.Equals, true,
+		check.Commentf("%v", notFoundErr))
+	defer replaceCallerOperator(nil, notFoundErr)()
+
+	err := checkClusterAccess(nil, storage.KindCluster, teleservices.VerbUpdate)
+	c.Assert(err, check.IsNil)
+}
+
+// replaceCallerOperator makes the commands use the specified operator or error
+// as the identity of the caller and returns the function to restore the original
+func replaceCallerOperator(operator ops.Operator, err error) (restore func()) {
+	original := getCallerOperator
+	getCallerOperator = func(*localenv.LocalEnvironment) (ops.Operator, error) {
+		if err != nil {
+			return nil, err
+		}
+		return operator, nil
+	}
+	return func() {
+		getCallerOperator = original
+	}
+}
+
+// testCallerOperator implements the operator of the cluster the caller has logged into
+type testCallerOperator struct {
+	ops.Operator
+	clusterErr error
+	accessErr  error
+	checks     []ops.CheckAccessRequest
+}
+
+func (r *testCallerOperator) GetLocalSite() (*ops.Site, error) {
+	if r.clusterErr != nil {
+		return nil, r.clusterErr
+	}
+	return &ops.Site{AccountID: "account", Domain: "example.com"}, nil
+}
+
+func (r *testCallerOperator) CheckAccess(ctx context.Context, req ops.CheckAccessRequest) error {
+	r.checks = append(r.checks, req)
+	return r.accessErr
+}
@@ -22,6 +22,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/gravitational/gravity/lib/app"
@@ -38,11 +39,15 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
-func backup(env *localenv.LocalEnvironment, tarball string, timeout time.Duration, follow, silent bool) (err error) {
+func backup(env *localenv.LocalEnvironment, tarball string, timeout time.Duration, follow, silent bool, snapshot string) (err error) {
 	ctx := context.Background()
 	// if we're streaming logs to stdout, no much sense in showing our progress indicator
 	noProgress := silent || follow
-	progress := utils.NewProgress(ctx, "backup", 2, noProgress)
+	steps := 2
+	if snapshot != "" {
+		steps++
+	}
+	progress := utils.NewProgress(ctx, "backup", steps, noProgress)
 	defer progress.Stop()
 	progress.NextStep("backing up to %v", tarball)
 	return runBackupRestore(env, "backup",
@@ -77,7 +82,21 @@ func backup(env *localenv.LocalEnvironment, tarball string, timeout time.Duratio
 				return trace.Wrap(err)
 			}
 			progress.NextStep("backup is written to %v", tarball)
-			return nil
+			if snapshot == "" {
+				return nil
+			}
+			// take the volume and etcd snapshots right after the application
+			// backup so they capture the same state of the cluster
+			progress.NextStep("taking snapshot %v of persistent volumes and etcd data", snapshot)
+			path, err := filepath.Abs(tarball)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			return trace.Wrap(createVolumeSnapshot(env, volumeSnapshotConfig{
+				name:   snapshot,
+				etcd:   true,
+				backup: path,
+			}))
 		})
 }
 
@@ -0,0 +1,277 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/rpc"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+	"github.com/gravitational/gravity/lib/update/binary"
+
+	"github.com/gravitational/trace"
+	"github.com/gravitational/version"
+	"github.com/sirupsen/logrus"
+)
+
+// updateBinary starts the operation to replace the gravity binary on cluster
+// nodes with this binary one node at a time
+func updateBinary(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, config updateBinaryConfig) error {
+	if !config.confirmed {
+		localEnv.Println(updateBinaryBanner)
+		resp, err := confirm()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !resp {
+			localEnv.Println("Action cancelled by user.")
+			return nil
+		}
+	}
+	clusterEnv, err := localEnv.NewClusterEnvironment()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	pkg, err := uploadGravityBinary(localEnv, clusterEnv.ClusterPackages)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	updater, err := newUpdater(ctx, localEnv, updateEnv, updateBinaryInitializer{
+		pkg:       *pkg,
+		skipNodes: config.skipNodes,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	if !config.manual {
+		err = updater.Run(ctx)
+		return trace.Wrap(err)
+	}
+	localEnv.Println(updateEnvironManualOperationBanner)
+	return nil
+}
+
+// uploadGravityBinary uploads this gravity binary into the cluster package service
+// unless the package for this version already exists and returns its locator
+func uploadGravityBinary(localEnv *localenv.LocalEnvironment, packages pack.PackageService) (*loc.Locator, error) {
+	pkg, err := loc.NewLocator(defaults.SystemAccountOrg, constants.GravityPackage, version.Get().Version)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	_, err = packages.ReadPackageEnvelope(*pkg)
+	if err == nil {
+		logrus.WithField("package", pkg).Info("Package already exists in the cluster.")
+		return pkg, nil
+	}
+	if !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
+	}
+	path, err := os.Executable()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	localEnv.PrintStep("Uploading %v to the cluster", pkg)
+	_, err = packages.CreatePackage(*pkg, f)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return pkg, nil
+}
+
+type updateBinaryConfig struct {
+	// manual specifies whether the operation is created in manual mode
+	manual bool
+	// confirmed suppresses confirmation prompt
+	confirmed bool
+	// skipNodes lists nodes to exclude from the operation
+	skipNodes []string
+}
+
+func executeUpdateBinaryPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params PhaseParams, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getUpdateBinaryUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	err = updater.RunPhase(context.TODO(), params.PhaseID, params.Timeout, params.Force)
+	return trace.Wrap(err)
+}
+
+func setUpdateBinaryPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params SetPhaseParams, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getUpdateBinaryUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	return updater.SetPhase(context.TODO(), params.PhaseID, params.State)
+}
+
+func rollbackUpdateBinaryPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params PhaseParams, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getUpdateBinaryUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	err = updater.RollbackPhase(context.TODO(), params.PhaseID, params.Timeout, params.Force)
+	return trace.Wrap(err)
+}
+
+func completeUpdateBinaryPlan(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getUpdateBinaryUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	return trace.Wrap(updater.Complete(nil))
+}
+
+func getUpdateBinaryUpdater(env, updateEnv *localenv.LocalEnvironment, operation ops.SiteOperation) (*update.Updater, error) {
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	creds, err := libfsm.GetClientCredentials()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	runner := libfsm.NewAgentRunner(creds)
+	return updateBinaryInitializer{}.newUpdater(context.TODO(), clusterEnv.Operator, operation,
+		env, updateEnv, clusterEnv, runner)
+}
+
+func (r updateBinaryInitializer) validatePreconditions(*localenv.LocalEnvironment, ops.Operator, ops.Site) error {
+	return nil
+}
+
+func (r updateBinaryInitializer) newOperation(operator ops.Operator, cluster ops.Site) (*ops.SiteOperationKey, error) {
+	key, err := operator.CreateUpdateBinaryOperation(context.TODO(),
+		ops.CreateUpdateBinaryOperationRequest{
+			ClusterKey: cluster.Key(),
+			Package:    r.pkg,
+		},
+	)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotImplemented(
+				"cluster operator does not implement the API required for updating the gravity binary. " +
+					"Please make sure you're running the command on a compatible cluster.")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+func (r updateBinaryInitializer) newOperationPlan(
+	ctx context.Context,
+	operator ops.Operator,
+	cluster ops.Site,
+	operation ops.SiteOperation,
+	localEnv, updateEnv *localenv.LocalEnvironment,
+	clusterEnv *localenv.ClusterEnvironment,
+	leader *storage.Server,
+) (*storage.OperationPlan, error) {
+	plan, err := binary.NewOperationPlan(operator, operation, *leader, cluster.ClusterState.Servers, r.skipNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return plan, nil
+}
+
+func (updateBinaryInitializer) newUpdater(
+	ctx context.Context,
+	operator ops.Operator,
+	operation ops.SiteOperation,
+	localEnv, updateEnv *localenv.LocalEnvironment,
+	clusterEnv *localenv.ClusterEnvironment,
+	runner rpc.AgentRepository,
+) (*update.Updater, error) {
+	config := binary.Config{
+		Config: update.Config{
+			Operation:    &operation,
+			Operator:     operator,
+			Backend:      clusterEnv.Backend,
+			LocalBackend: updateEnv.Backend,
+			Silent:       localEnv.Silent,
+			Runner:       runner,
+			FieldLogger: logrus.WithFields(logrus.Fields{
+				trace.Component: "update:binary",
+				"operation":     operation,
+			}),
+		},
+		Apps:              clusterEnv.Apps,
+		Client:            clusterEnv.Client,
+		ClusterPackages:   clusterEnv.ClusterPackages,
+		HostLocalPackages: localEnv.Packages,
+	}
+	return binary.New(ctx, config)
+}
+
+func (updateBinaryInitializer) updateDeployRequest(req deployAgentsRequest) deployAgentsRequest {
+	return req
+}
+
+type updateBinaryInitializer struct {
+	// pkg is the gravity binary package to install
+	pkg loc.Locator
+	// skipNodes lists nodes to exclude from the operation
+	skipNodes []string
+}
+
+const updateBinaryBanner = `Updating the gravity binary replaces the gravity binary on cluster nodes
+with this binary one node at a time, master nodes first, and restarts the node agents.
+The previous binary is restored on a node that fails the health check.
+
+The operation will start automatically once you approve it.
+If you want to review the operation plan first or execute it manually step by step,
+run the operation in manual mode by specifying '--manual' flag.
+
+Are you sure?`
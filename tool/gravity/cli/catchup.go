@@ -0,0 +1,143 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/rpc"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+	clusterupdate "github.com/gravitational/gravity/lib/update/cluster"
+
+	"github.com/gravitational/trace"
+)
+
+// updateCatchUp starts the operation to update the specified node excluded
+// from a previous upgrade to the currently installed cluster version
+func updateCatchUp(
+	localEnv *localenv.LocalEnvironment,
+	updateEnv *localenv.LocalEnvironment,
+	node string,
+	manual, noValidateVersion bool,
+) error {
+	ctx := context.TODO()
+	init := &catchUpInitializer{
+		node:       node,
+		unattended: !manual,
+	}
+	updater, err := newUpdater(ctx, localEnv, updateEnv, init)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	if !noValidateVersion {
+		if err := validateBinaryVersion(updater); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if !manual {
+		// The node is updating in background
+		return nil
+	}
+	localEnv.Println(updateClusterManualOperationBanner)
+	return nil
+}
+
+func (r *catchUpInitializer) validatePreconditions(localEnv *localenv.LocalEnvironment, operator ops.Operator, cluster ops.Site) error {
+	clusterEnv, err := localEnv.NewClusterEnvironment()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	server, err := clusterupdate.FindCatchUpServer(cluster.ClusterState.Servers, r.node)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	installed, err := clusterupdate.FindSkippedPackage(clusterEnv.Backend, cluster.Domain, *server)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	localEnv.PrintStep("Updating node %v from %v to %v",
+		server.Hostname, installed.Version, cluster.App.Package.Version)
+	return nil
+}
+
+func (r *catchUpInitializer) newOperation(operator ops.Operator, cluster ops.Site) (*ops.SiteOperationKey, error) {
+	return operator.CreateSiteAppUpdateOperation(context.TODO(), ops.CreateSiteAppUpdateOperationRequest{
+		AccountID:  cluster.AccountID,
+		SiteDomain: cluster.Domain,
+		App:        cluster.App.Package.String(),
+		CatchUp:    true,
+	})
+}
+
+func (r *catchUpInitializer) newOperationPlan(
+	ctx context.Context,
+	operator ops.Operator,
+	cluster ops.Site,
+	operation ops.SiteOperation,
+	localEnv, updateEnv *localenv.LocalEnvironment,
+	clusterEnv *localenv.ClusterEnvironment,
+	leader *storage.Server,
+) (*storage.OperationPlan, error) {
+	plan, err := clusterupdate.InitCatchUpOperationPlan(
+		ctx, clusterEnv, operation.Key(), leader, r.node,
+	)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	r.servers = plan.Servers
+	return plan, nil
+}
+
+func (r *catchUpInitializer) newUpdater(
+	ctx context.Context,
+	operator ops.Operator,
+	operation ops.SiteOperation,
+	localEnv, updateEnv *localenv.LocalEnvironment,
+	clusterEnv *localenv.ClusterEnvironment,
+	runner rpc.AgentRepository,
+) (*update.Updater, error) {
+	return clusterInitializer{}.newUpdater(ctx, operator, operation,
+		localEnv, updateEnv, clusterEnv, runner)
+}
+
+func (r *catchUpInitializer) updateDeployRequest(req deployAgentsRequest) deployAgentsRequest {
+	// Agents are only required on the nodes participating in the operation
+	var servers []storage.Server
+	for _, server := range req.clusterState.Servers {
+		if storage.Servers(r.servers).FindByIP(server.AdvertiseIP) != nil {
+			servers = append(servers, server)
+		}
+	}
+	req.clusterState.Servers = servers
+	if r.unattended {
+		req.leaderParams = constants.RPCAgentUpgradeFunction
+	}
+	return req
+}
+
+type catchUpInitializer struct {
+	// node is the hostname or advertise IP of the node to update
+	node       string
+	unattended bool
+	// servers lists the servers participating in the operation
+	servers []storage.Server
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/fatih/color"
+	"github.com/gravitational/trace"
+)
+
+// listNodeCertificates outputs the certificates of this node along with
+// their expiration. It is invoked remotely on each node to collect
+// the certificates of the cluster nodes
+func listNodeCertificates(format constants.Format, w io.Writer) error {
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	certs, err := ops.ReadNodeCertificates(state.SecretDir(stateDir))
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(certs, w))
+	case constants.EncodingText:
+		printNodeCertificates(certs, time.Now(), w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+// listClusterCertificates outputs the certificates of all cluster nodes
+// along with their expiration
+func listClusterCertificates(env *localenv.LocalEnvironment, format constants.Format, w io.Writer) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	nodes, err := operator.GetNodeCertificates(cluster.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(nodes, w))
+	case constants.EncodingText:
+		now := time.Now()
+		for i, node := range nodes {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "Node %v (%v):\n", node.Hostname, node.AdvertiseIP)
+			if node.Error != "" {
+				fmt.Fprintf(w, "%v %v.\n", color.RedString("ERROR:"), node.Error)
+				continue
+			}
+			printNodeCertificates(node.Certificates, now, w)
+		}
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+func printNodeCertificates(certs []ops.NodeCertificate, now time.Time, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Certificate", "Subject", "Issuer", "Expires"})
+	for _, cert := range certs {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n",
+			cert.Name,
+			formatDeviceValue(cert.Subject),
+			formatDeviceValue(cert.Issuer),
+			formatCertificateExpiry(cert, now))
+	}
+	w.Flush()
+}
+
+// formatCertificateExpiry returns the certificate expiration time
+// highlighting the certificates that have expired or expire soon
+func formatCertificateExpiry(cert ops.NodeCertificate, now time.Time) string {
+	expires := cert.NotAfter.Format(constants.HumanDateFormat)
+	switch {
+	case cert.NotAfter.Before(now):
+		return color.RedString("%v (expired)", expires)
+	case cert.NotAfter.Sub(now) < defaults.CertificateExpiryWarning:
+		return color.YellowString("%v (expires soon)", expires)
+	}
+	return expires
+}
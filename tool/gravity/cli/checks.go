@@ -19,17 +19,21 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 
 	"github.com/gravitational/gravity/lib/checks"
+	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/utils"
 
 	pb "github.com/gravitational/satellite/agent/proto/agentpb"
 	"github.com/gravitational/trace"
 )
 
-func checkManifest(env *localenv.LocalEnvironment, manifestPath, profileName string, autoFix bool) error {
+func checkManifest(env *localenv.LocalEnvironment, manifestPath, profileName string, autoFix bool, format constants.Format) error {
 	data, err := ioutil.ReadFile(manifestPath)
 	if err != nil {
 		return trace.Wrap(err)
@@ -40,11 +44,19 @@ func checkManifest(env *localenv.LocalEnvironment, manifestPath, profileName str
 		return trace.Wrap(err)
 	}
 
-	result, err := checks.ValidateLocal(context.TODO(), checks.LocalChecksRequest{
+	req := checks.LocalChecksRequest{
 		Manifest: *manifest,
 		Role:     profileName,
 		AutoFix:  autoFix,
-	})
+	}
+	if format == constants.EncodingJSON {
+		return checkManifestJSON(req, os.Stdout)
+	}
+	if format != constants.EncodingText {
+		return trace.BadParameter("unsupported output format %q", format)
+	}
+
+	result, err := checks.ValidateLocal(context.TODO(), req)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -62,11 +74,41 @@ func checkManifest(env *localenv.LocalEnvironment, manifestPath, profileName str
 	return trace.NewAggregate(failedErr, fixableErr)
 }
 
-func printFailedChecks(failed []*pb.Probe) {
+// checkManifestJSON runs the local checks and outputs the results as JSON to w
+func checkManifestJSON(req checks.LocalChecksRequest, w io.Writer) error {
+	results, err := checks.RunLocal(context.TODO(), req)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := printJSON(results, w); err != nil {
+		return trace.Wrap(err)
+	}
+	if !results.Passed {
+		return utils.NewExitCodeError(1)
+	}
+	return nil
+}
+
+// testPathMTU detects the path MTU to the node given with addr
+// and outputs it along with the suitable overlay network MTU to w
+func testPathMTU(addr string, w io.Writer) error {
+	mtu, err := checks.ProbePathMTU(context.TODO(), utils.Runner, addr)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	fmt.Fprintf(w, "Path MTU to %v:\t%v\n", addr, mtu)
+	fmt.Fprintf(w, "Overlay network MTU:\t%v\n", checks.OverlayMTU(mtu))
+	if err := checks.ValidatePathMTU(mtu, 0); err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+func printFailedChecks(w io.Writer, failed []*pb.Probe) {
 	if len(failed) == 0 {
 		return
 	}
 
-	fmt.Printf("Failed checks:\n")
-	fmt.Printf(checks.FormatFailedChecks(failed))
+	fmt.Fprintf(w, "Failed checks:\n")
+	fmt.Fprint(w, checks.FormatFailedChecks(failed))
 }
@@ -35,10 +35,10 @@ import (
 // resetConfig executes the loop to reset cluster configuration to defaults
 func resetConfig(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, manual, confirmed bool) error {
 	config := libclusterconfig.NewEmpty()
-	return trace.Wrap(updateConfig(ctx, localEnv, updateEnv, config, manual, confirmed))
+	return trace.Wrap(updateConfig(ctx, localEnv, updateEnv, config, manual, confirmed, ""))
 }
 
-func updateConfig(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, config libclusterconfig.Interface, manual, confirmed bool) error {
+func updateConfig(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, config libclusterconfig.Interface, manual, confirmed bool, revision string) error {
 	if err := validateCloudConfig(localEnv, config); err != nil {
 		return trace.Wrap(err)
 	}
@@ -57,7 +57,7 @@ func updateConfig(ctx context.Context, localEnv, updateEnv *localenv.LocalEnviro
 			return nil
 		}
 	}
-	updater, err := newConfigUpdater(ctx, localEnv, updateEnv, config)
+	updater, err := newConfigUpdater(ctx, localEnv, updateEnv, config, revision)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -70,7 +70,7 @@ func updateConfig(ctx context.Context, localEnv, updateEnv *localenv.LocalEnviro
 	return nil
 }
 
-func newConfigUpdater(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, config libclusterconfig.Interface) (*update.Updater, error) {
+func newConfigUpdater(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, config libclusterconfig.Interface, revision string) (*update.Updater, error) {
 	configBytes, err := libclusterconfig.Marshal(config)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -78,6 +78,7 @@ func newConfigUpdater(ctx context.Context, localEnv, updateEnv *localenv.LocalEn
 	init := configInitializer{
 		resource: configBytes,
 		config:   config,
+		revision: revision,
 	}
 	return newUpdater(ctx, localEnv, updateEnv, init)
 }
@@ -88,6 +89,15 @@ func executeConfigPhase(env *localenv.LocalEnvironment, environ LocalEnvironment
 		return trace.Wrap(err)
 	}
 	defer updateEnv.Close()
+	if params.isResume() {
+		initialized, err := isConfigPlanInitialized(env, operation)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !initialized {
+			return trace.Wrap(runConfigOperation(context.TODO(), env, updateEnv, operation))
+		}
+	}
 	updater, err := getConfigUpdater(env, updateEnv, operation)
 	if err != nil {
 		return trace.Wrap(err)
@@ -97,6 +107,51 @@ func executeConfigPhase(env *localenv.LocalEnvironment, environ LocalEnvironment
 	return trace.Wrap(err)
 }
 
+// isConfigPlanInitialized returns true if the plan has been created
+// for the specified update-config operation.
+// The plan is missing if the operation has been created with the cluster API,
+// for example, by patching the cluster configuration
+func isConfigPlanInitialized(env *localenv.LocalEnvironment, operation ops.SiteOperation) (bool, error) {
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	_, err = clusterEnv.Backend.GetOperationPlan(operation.SiteDomain, operation.ID)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return false, nil
+		}
+		return false, trace.Wrap(err)
+	}
+	return true, nil
+}
+
+// runConfigOperation creates the plan for the existing update-config operation
+// and executes it
+func runConfigOperation(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, operation ops.SiteOperation) error {
+	if operation.UpdateConfig == nil {
+		return trace.BadParameter("operation %v does not specify the configuration", operation.ID)
+	}
+	config, err := libclusterconfig.Unmarshal(operation.UpdateConfig.Config)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := validateCloudConfig(localEnv, config); err != nil {
+		return trace.Wrap(err)
+	}
+	key := operation.Key()
+	updater, err := newUpdater(ctx, localEnv, updateEnv, configInitializer{
+		resource:  operation.UpdateConfig.Config,
+		config:    config,
+		operation: &key,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	return trace.Wrap(updater.Run(ctx))
+}
+
 func setConfigPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params SetPhaseParams, operation ops.SiteOperation) error {
 	updateEnv, err := environ.NewUpdateEnv()
 	if err != nil {
@@ -182,10 +237,14 @@ func (r configInitializer) validatePreconditions(*localenv.LocalEnvironment, ops
 }
 
 func (r configInitializer) newOperation(operator ops.Operator, cluster ops.Site) (*ops.SiteOperationKey, error) {
+	if r.operation != nil {
+		return r.operation, nil
+	}
 	key, err := operator.CreateUpdateConfigOperation(context.TODO(),
 		ops.CreateUpdateConfigOperationRequest{
 			ClusterKey: cluster.Key(),
 			Config:     r.resource,
+			Revision:   r.revision,
 		},
 	)
 	if err != nil {
@@ -251,6 +310,11 @@ func (configInitializer) updateDeployRequest(req deployAgentsRequest) deployAgen
 type configInitializer struct {
 	resource []byte
 	config   libclusterconfig.Interface
+	// operation optionally specifies the existing operation to create the plan for
+	operation *ops.SiteOperationKey
+	// revision optionally specifies the revision of the configuration
+	// the update is based on
+	revision string
 }
 
 func validateCloudConfig(localEnv *localenv.LocalEnvironment, config libclusterconfig.Interface) error {
@@ -0,0 +1,211 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gravitational/trace"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// listClusters outputs the list of clusters connected to the specified Gravity Hub
+// with labels matching the specified label selector
+func listClusters(env *localenv.LocalEnvironment, opsCenterURL, selector string, format constants.Format, w io.Writer) error {
+	labelSelector, err := ops.ParseLabelSelector(selector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := env.OperatorService(opsCenterURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	summaries, err := operator.GetClusterSummaries(defaults.SystemAccountID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	summaries = filterClusterSummaries(summaries, labelSelector)
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(summaries, w))
+	case constants.EncodingText:
+		printClusterSummaries(summaries, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+// remoteClusterStatus outputs the status of the specified cluster
+// connected to the Gravity Hub
+func remoteClusterStatus(env *localenv.LocalEnvironment, opsCenterURL, clusterName string, format constants.Format, w io.Writer) error {
+	operator, err := env.OperatorService(opsCenterURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	summary, err := operator.GetClusterSummary(ops.SiteKey{
+		AccountID:  defaults.SystemAccountID,
+		SiteDomain: clusterName,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(summary, w))
+	case constants.EncodingText:
+		printClusterSummary(*summary, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+// labelCluster updates labels of the specified cluster connected to the Gravity Hub.
+// Labels are specified as key=value to set the label and key- to remove it
+func labelCluster(env *localenv.LocalEnvironment, opsCenterURL, clusterName string, args []string) error {
+	add, remove, err := ops.ParseLabelUpdates(args)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := env.OperatorService(opsCenterURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = operator.UpdateLabels(context.TODO(), ops.UpdateLabelsRequest{
+		SiteKey: ops.SiteKey{
+			AccountID:  defaults.SystemAccountID,
+			SiteDomain: clusterName,
+		},
+		Add:    add,
+		Remove: remove,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	env.Printf("Cluster %v labels updated.\n", clusterName)
+	return nil
+}
+
+// filterClusterSummaries returns the summaries of the clusters with labels
+// matching the specified selector
+func filterClusterSummaries(summaries []ops.ClusterSummary, selector labels.Selector) []ops.ClusterSummary {
+	result := make([]ops.ClusterSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		if ops.MatchLabels(selector, summary.Labels) {
+			result = append(result, summary)
+		}
+	}
+	return result
+}
+
+func printClusterSummaries(summaries []ops.ClusterSummary, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Name", "State", "Connection", "Image", "Provider", "Labels"})
+	for _, summary := range summaries {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			summary.Name,
+			summary.State,
+			formatConnection(summary),
+			formatImage(summary),
+			summary.Provider,
+			formatValue(formatLabels(summary.Labels)))
+	}
+	w.Flush()
+}
+
+func printClusterSummary(summary ops.ClusterSummary, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "Cluster:\t%v\n", summary.Name)
+	fmt.Fprintf(w, "State:\t%v\n", summary.State)
+	if summary.Reason != "" {
+		fmt.Fprintf(w, "Reason:\t%v\n", summary.Reason)
+	}
+	fmt.Fprintf(w, "Connection:\t%v\n", formatConnection(summary))
+	fmt.Fprintf(w, "Cluster image:\t%v\n", formatImage(summary))
+	if summary.Provider != "" {
+		fmt.Fprintf(w, "Provider:\t%v\n", summary.Provider)
+	}
+	if len(summary.Labels) != 0 {
+		fmt.Fprintf(w, "Labels:\t%v\n", formatLabels(summary.Labels))
+	}
+	if len(summary.ActiveOperations) != 0 {
+		fmt.Fprintf(w, "Active operations:\n")
+		for _, op := range summary.ActiveOperations {
+			printSummaryOperation(op, w)
+		}
+	}
+	if summary.LastOperation != nil {
+		fmt.Fprintf(w, "Last completed operation:\n")
+		printSummaryOperation(*summary.LastOperation, w)
+	}
+	if len(summary.Nodes) != 0 {
+		fmt.Fprintf(w, "Nodes:\n")
+		for _, node := range summary.Nodes {
+			fmt.Fprintf(w, "    * %v (%v)\t%v\n", node.Hostname, node.AdvertiseIP, node.Profile)
+		}
+	}
+	w.Flush()
+}
+
+func printSummaryOperation(op ops.SiteOperation, w io.Writer) {
+	fmt.Fprintf(w, "    * %v (%v)\n", op.TypeString(), op.ID)
+	fmt.Fprintf(w, "      %v:\t%v (%v)\n", op.State,
+		op.Created.Format(constants.HumanDateFormat),
+		humanize.RelTime(op.Created, time.Now(), "ago", ""))
+}
+
+func formatConnection(summary ops.ClusterSummary) string {
+	switch {
+	case summary.Local:
+		return "local"
+	case summary.Online:
+		return "online"
+	case summary.LastConnected.IsZero():
+		return "offline"
+	}
+	return fmt.Sprintf("offline (last seen %v)",
+		humanize.RelTime(summary.LastConnected, time.Now(), "ago", ""))
+}
+
+func formatImage(summary ops.ClusterSummary) string {
+	if summary.Application.Name == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%v:%v", summary.Application.Name, summary.Application.Version)
+}
+
+func printJSON(v interface{}, w io.Writer) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = fmt.Fprintln(w, string(bytes))
+	return trace.Wrap(err)
+}
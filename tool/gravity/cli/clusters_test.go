@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"gopkg.in/check.v1"
+)
+
+func (*S) TestPrintsClusterSummaries(c *check.C) {
+	summaries := []ops.ClusterSummary{
+		{
+			Name:        "hub.example.com",
+			State:       ops.SiteStateActive,
+			Application: loc.MustParseLocator("gravitational.io/opscenter:5.5.0"),
+			Provider:    "onprem",
+			Local:       true,
+			Online:      true,
+		},
+		{
+			Name:        "edge.example.com",
+			State:       ops.SiteStateActive,
+			Application: loc.MustParseLocator("example.com/app:1.0.0"),
+			Provider:    "aws",
+			Online:      true,
+		},
+		{
+			Name:     "lab.example.com",
+			State:    ops.SiteStateDegraded,
+			Provider: "onprem",
+		},
+	}
+	var out bytes.Buffer
+	printClusterSummaries(summaries, &out)
+	c.Assert(out.String(), check.Matches, `(?s).*hub\.example\.com\s+active\s+local\s+opscenter:5\.5\.0\s+onprem.*`)
+	c.Assert(out.String(), check.Matches, `(?s).*edge\.example\.com\s+active\s+online\s+app:1\.0\.0\s+aws.*`)
+	c.Assert(out.String(), check.Matches, `(?s).*lab\.example\.com\s+degraded\s+offline\s+-\s+onprem.*`)
+}
+
+func (*S) TestFiltersClusterSummariesBySelector(c *check.C) {
+	summaries := []ops.ClusterSummary{
+		{Name: "edge-1", Labels: map[string]string{"env": "prod", "region": "eu"}},
+		{Name: "edge-2", Labels: map[string]string{"env": "prod", "region": "us"}},
+		{Name: "lab"},
+	}
+	selector, err := ops.ParseLabelSelector("env=prod,region!=us")
+	c.Assert(err, check.IsNil)
+	c.Assert(filterClusterSummaries(summaries, selector), check.DeepEquals, summaries[:1])
+
+	selector, err = ops.ParseLabelSelector("")
+	c.Assert(err, check.IsNil)
+	c.Assert(filterClusterSummaries(summaries, selector), check.DeepEquals, summaries)
+}
@@ -18,11 +18,18 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/gravitational/gravity/lib/app"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	libfsm "github.com/gravitational/gravity/lib/fsm"
+	libkubernetes "github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/ops"
@@ -35,6 +42,7 @@ import (
 	"github.com/gravitational/version"
 
 	"github.com/coreos/go-semver/semver"
+	"github.com/fatih/color"
 	"github.com/gravitational/trace"
 )
 
@@ -53,14 +61,129 @@ func updateCheck(env *localenv.LocalEnvironment, updatePackage string) error {
 	return trace.Wrap(err)
 }
 
+// previewUpdate prints the impact of upgrading the cluster to the cluster
+// image at the specified path without creating the operation
+func previewUpdate(env *localenv.LocalEnvironment, path string) error {
+	imageEnv, err := localenv.NewImageEnvironment(path)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer imageEnv.Close()
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	preview, err := clusterupdate.NewPreview(clusterupdate.PreviewConfig{
+		Backend:        clusterEnv.Backend,
+		Apps:           clusterEnv.Apps,
+		Packages:       clusterEnv.Packages,
+		UpdateApps:     imageEnv.Apps,
+		UpdatePackages: imageEnv.Packages,
+		UpdatePackage:  imageEnv.Manifest.Locator(),
+		Client:         clusterEnv.Client,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	printUpdatePreview(*preview, clusterEnv.Client != nil)
+	return nil
+}
+
+func printUpdatePreview(preview clusterupdate.Preview, hasWorkloads bool) {
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "Upgrade:\t%v -> %v\n", preview.InstalledPackage, preview.UpdatePackage.Version)
+	for _, change := range []struct {
+		name    string
+		version *clusterupdate.VersionChange
+	}{
+		{"Kubernetes runtime", preview.Runtime},
+		{"Teleport", preview.Teleport},
+		{"Etcd", preview.Etcd},
+	} {
+		if change.version != nil {
+			fmt.Fprintf(w, "%v:\t%v\n", change.name, change.version)
+		}
+	}
+	if len(preview.Apps) != 0 {
+		fmt.Fprintln(w, "Applications:")
+		for _, app := range preview.Apps {
+			fmt.Fprintf(w, "    %v\t%v\n", app.Name, app.VersionChange)
+		}
+	}
+	if len(preview.Images) != 0 {
+		fmt.Fprintln(w, "Images:")
+		for _, image := range preview.Images {
+			fmt.Fprintf(w, "    %v\t%v\n", image.Name, image.VersionChange)
+		}
+	}
+	if len(preview.Migrations) != 0 {
+		fmt.Fprintln(w, "Migrations:")
+		for _, migration := range preview.Migrations {
+			fmt.Fprintf(w, "    * %v\n", migration)
+		}
+	}
+	fmt.Fprintln(w, "Nodes:")
+	if len(preview.Nodes) == 0 {
+		fmt.Fprintln(w, "    No system software updates, nodes are not restarted")
+	}
+	for _, node := range preview.Nodes {
+		fmt.Fprintf(w, "    %v (%v, %v)\n", node.Hostname, node.AdvertiseIP, node.Role)
+		if node.Drained {
+			fmt.Fprintf(w, "        Drained:\t%v\n", color.YellowString("yes"))
+		}
+		if len(node.Restarts) != 0 {
+			fmt.Fprintf(w, "        Restarts:\t%v\n", strings.Join(node.Restarts, ", "))
+		}
+	}
+	if hasWorkloads {
+		disrupted := preview.DisruptedWorkloads()
+		fmt.Fprintf(w, "Evicted workloads:\t%v\n", len(preview.Workloads))
+		fmt.Fprintf(w, "Disrupted workloads:\t%v\n", len(disrupted))
+		for _, workload := range disrupted {
+			fmt.Fprintf(w, "    [%v]\t%v on %v\n", constants.WarnMark,
+				color.YellowString(workload.String()), strings.Join(workload.Nodes, ", "))
+		}
+		problems := preview.CapacityProblems()
+		if len(problems) == 0 {
+			fmt.Fprintln(w, "Capacity:\tsufficient to drain the nodes")
+		} else {
+			fmt.Fprintln(w, "Capacity:\tinsufficient to drain the nodes")
+		}
+		for _, problem := range problems {
+			fmt.Fprintf(w, "    [%v]\t%v\n", constants.WarnMark, color.YellowString(problem))
+		}
+	} else {
+		fmt.Fprintln(w, "Workloads:\tunknown, run on a master node to see the affected workloads")
+	}
+	w.Flush()
+}
+
 func updateTrigger(
 	localEnv *localenv.LocalEnvironment,
 	updateEnv *localenv.LocalEnvironment,
 	updatePackage string,
 	manual, noValidateVersion bool,
+	skipNodes []string,
+	selector string,
+	pauseAfter []string,
+	planPath, planHash, throttle string,
+	skipCapacityCheck bool,
 ) error {
 	ctx := context.TODO()
-	updater, err := newClusterUpdater(ctx, localEnv, updateEnv, updatePackage, manual, noValidateVersion)
+	if selector != "" {
+		if planPath != "" {
+			return trace.BadParameter("--selector cannot be used with --plan: " +
+				"the nodes to skip are defined by the exported plan")
+		}
+		var err error
+		skipNodes, err = skipUnselectedNodes(localEnv, skipNodes, selector)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	updater, err := newClusterUpdater(ctx, localEnv, updateEnv, updatePackage, manual, noValidateVersion,
+		skipNodes, pauseAfter, planPath, planHash, throttle, skipCapacityCheck)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -78,10 +201,33 @@ func newClusterUpdater(
 	localEnv, updateEnv *localenv.LocalEnvironment,
 	updatePackage string,
 	manual, noValidateVersion bool,
+	skipNodes, pauseAfter []string,
+	planPath, planHash, throttle string,
+	skipCapacityCheck bool,
 ) (updater, error) {
 	init := &clusterInitializer{
-		updatePackage: updatePackage,
-		unattended:    !manual,
+		updatePackage:     updatePackage,
+		unattended:        !manual,
+		skipNodes:         skipNodes,
+		pauseAfter:        pauseAfter,
+		throttle:          throttle,
+		skipCapacityCheck: skipCapacityCheck,
+	}
+	if planPath != "" {
+		if len(skipNodes) != 0 || len(pauseAfter) != 0 {
+			return nil, trace.BadParameter("--skip-nodes and --pause-after cannot be used with --plan: " +
+				"the nodes to skip and the pause points are defined by the exported plan")
+		}
+		if planHash == "" {
+			return nil, trace.BadParameter("--plan-hash is required with --plan: " +
+				"specify the hash of the reviewed plan printed by 'gravity plan export'")
+		}
+		exportedPlan, err := readExportedPlan(planPath)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		init.exportedPlan = exportedPlan
+		init.planHash = planHash
 	}
 	updater, err := newUpdater(ctx, localEnv, updateEnv, init)
 	if err != nil {
@@ -108,9 +254,19 @@ func executeUpdatePhase(env *localenv.LocalEnvironment, environ LocalEnvironment
 	}
 	defer updater.Close()
 	err = updater.RunPhase(context.TODO(), params.PhaseID, params.Timeout, params.Force)
+	if libfsm.IsPaused(err) {
+		env.Println(formatPausedOperation(err))
+		return nil
+	}
 	return trace.Wrap(err)
 }
 
+func formatPausedOperation(err error) string {
+	return fmt.Sprintf(`The %v.
+Validate the cluster state and run "gravity plan resume" to continue.`,
+		trace.Unwrap(err).Error())
+}
+
 func rollbackUpdatePhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params PhaseParams, operation ops.SiteOperation) error {
 	updateEnv, err := environ.NewUpdateEnv()
 	if err != nil {
@@ -206,14 +362,81 @@ func (r *clusterInitializer) validatePreconditions(localEnv *localenv.LocalEnvir
 		return trace.Wrap(err)
 	}
 	r.updateLoc = updateApp.Package
+	if err := r.checkCapacity(localEnv, cluster); err != nil {
+		return trace.Wrap(err)
+	}
+	if r.exportedPlan != nil {
+		// Fail early before the operation is created if the cluster
+		// has changed since the plan has been exported
+		state := clusterupdate.NewPlanState(cluster, r.updateLoc)
+		if err := clusterupdate.CheckExportedPlan(*r.exportedPlan, r.planHash, state); err != nil {
+			return trace.Wrap(err)
+		}
+	}
 	return nil
 }
 
+// checkCapacity verifies that the pods evicted from each node drained
+// during the upgrade can be rescheduled on the remaining nodes
+func (r *clusterInitializer) checkCapacity(localEnv *localenv.LocalEnvironment, cluster ops.Site) error {
+	if r.skipCapacityCheck {
+		localEnv.Println(color.YellowString("Skipping cluster capacity check."))
+		return nil
+	}
+	clusterEnv, err := localEnv.NewClusterEnvironment()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if clusterEnv.Client == nil {
+		return trace.BadParameter("this operation can only be executed on one of the master nodes")
+	}
+	preview, err := clusterupdate.NewPreview(clusterupdate.PreviewConfig{
+		Backend:       clusterEnv.Backend,
+		Apps:          clusterEnv.Apps,
+		Packages:      clusterEnv.Packages,
+		UpdatePackage: r.updateLoc,
+		Client:        clusterEnv.Client,
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to compute upgrade preview.")
+		localEnv.Println(color.YellowString("Failed to verify cluster capacity: %v.", trace.UserMessage(err)))
+		return nil
+	}
+	_, skipped, err := update.SkipServers(cluster.ClusterState.Servers, r.skipNodes)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var headroom []libkubernetes.Headroom
+	for _, node := range preview.Headroom {
+		if !isSkippedNode(node.Node, skipped) {
+			headroom = append(headroom, node)
+		}
+	}
+	problems := libkubernetes.HeadroomProblems(headroom...)
+	if len(problems) == 0 {
+		return nil
+	}
+	return trace.BadParameter("the cluster does not have the capacity to host the pods "+
+		"evicted from the drained nodes:\n  * %v\nUse --skip-capacity-check to upgrade anyway.",
+		strings.Join(problems, "\n  * "))
+}
+
+// isSkippedNode returns true if the Kubernetes node belongs to one of the skipped servers
+func isSkippedNode(node string, skipped []storage.Server) bool {
+	for _, server := range skipped {
+		if server.KubeNodeID() == node {
+			return true
+		}
+	}
+	return false
+}
+
 func (r clusterInitializer) newOperation(operator ops.Operator, cluster ops.Site) (*ops.SiteOperationKey, error) {
 	return operator.CreateSiteAppUpdateOperation(context.TODO(), ops.CreateSiteAppUpdateOperationRequest{
 		AccountID:  cluster.AccountID,
 		SiteDomain: cluster.Domain,
 		App:        r.updateLoc.String(),
+		Throttle:   r.throttle,
 	})
 }
 
@@ -226,8 +449,15 @@ func (r clusterInitializer) newOperationPlan(
 	clusterEnv *localenv.ClusterEnvironment,
 	leader *storage.Server,
 ) (*storage.OperationPlan, error) {
+	if r.exportedPlan != nil {
+		plan, err := clusterupdate.InitExportedOperationPlan(clusterEnv, operation.Key(), *r.exportedPlan, r.planHash)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return plan, nil
+	}
 	plan, err := clusterupdate.InitOperationPlan(
-		ctx, localEnv, updateEnv, clusterEnv, operation.Key(), leader,
+		ctx, localEnv, updateEnv, clusterEnv, operation.Key(), leader, r.skipNodes, r.pauseAfter,
 	)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -273,6 +503,20 @@ type clusterInitializer struct {
 	updateLoc     loc.Locator
 	updatePackage string
 	unattended    bool
+	// skipNodes lists nodes to exclude from the operation
+	skipNodes []string
+	// pauseAfter lists phases to pause the operation after
+	pauseAfter []string
+	// exportedPlan is the previously exported plan to execute
+	// instead of generating a new one
+	exportedPlan *clusterupdate.ExportedPlan
+	// planHash is the hash of the reviewed exported plan
+	planHash string
+	// throttle specifies how much the resource usage of the operation is throttled
+	throttle string
+	// skipCapacityCheck allows to start the operation even if the cluster
+	// cannot host the pods evicted from the drained nodes
+	skipCapacityCheck bool
 }
 
 const (
@@ -281,6 +525,20 @@ const (
 See https://gravitational.com/gravity/docs/cluster/#managing-an-ongoing-operation for details on working with operation plan.`
 )
 
+// readExportedPlan reads the plan exported with 'gravity plan export'
+// from the file at the specified path
+func readExportedPlan(path string) (*clusterupdate.ExportedPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	var plan clusterupdate.ExportedPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, trace.BadParameter("failed to parse exported plan %v: %v", path, err)
+	}
+	return &plan, nil
+}
+
 func checkCanUpdate(cluster ops.Site, operator ops.Operator, manifest schema.Manifest) error {
 	existingGravityPackage, err := cluster.App.Manifest.Dependencies.ByName(constants.GravityPackage)
 	if err != nil {
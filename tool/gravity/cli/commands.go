@@ -42,6 +42,8 @@ type Application struct {
 	StateDir *string
 	// EtcdRetryTimeout is the retry timeout for transient etcd errors
 	EtcdRetryTimeout *time.Duration
+	// OperatorRetryTimeout is the retry timeout for transient cluster controller errors
+	OperatorRetryTimeout *time.Duration
 	// UID is the user ID to run the command as
 	UID *int
 	// GID is the group ID to run the command as
@@ -54,6 +56,10 @@ type Application struct {
 	UserLogFile *string
 	// SystemLogFile is the path to the system log file
 	SystemLogFile *string
+	// NoProxyPolicy specifies how NO_PROXY is extended for internal destinations
+	NoProxyPolicy *string
+	// NoProxy lists additional destinations to exclude from proxying
+	NoProxy *[]string
 	// VersionCmd output the binary version
 	VersionCmd VersionCmd
 	// InstallCmd launches cluster installation
@@ -62,12 +68,26 @@ type Application struct {
 	JoinCmd JoinCmd
 	// AutoJoinCmd uses cloud provider info to join existing cluster
 	AutoJoinCmd AutoJoinCmd
+	// StageCmd stages cluster image packages on the node ahead of join
+	StageCmd StageCmd
 	// LeaveCmd removes the current node from the cluster
 	LeaveCmd LeaveCmd
 	// RemoveCmd removes the specified node from the cluster
 	RemoveCmd RemoveCmd
+	// UninstallCmd tears down Gravity on this node or the entire cluster
+	UninstallCmd UninstallCmd
+	// ReinstallCmd reinstalls Gravity on this node preserving application data
+	ReinstallCmd ReinstallCmd
 	// PlanCmd manages an operation plan
 	PlanCmd PlanCmd
+	// OperationCmd combines cluster operation subcommands
+	OperationCmd OperationCmd
+	// OperationApproveCmd approves an operation started by another user
+	OperationApproveCmd OperationApproveCmd
+	// OperationQueueCmd manages queued cluster operations
+	OperationQueueCmd OperationQueueCmd
+	// OperationQueueListCmd lists queued cluster operations
+	OperationQueueListCmd OperationQueueListCmd
 	// UpdatePlanInitCmd creates a new update operation plan
 	UpdatePlanInitCmd UpdatePlanInitCmd
 	// PlanDisplayCmd displays plan of an operation
@@ -84,14 +104,24 @@ type Application struct {
 	PlanResumeCmd PlanResumeCmd
 	// PlanCompleteCmd completes the operation plan
 	PlanCompleteCmd PlanCompleteCmd
+	// PlanDiffCmd compares plans of two operations
+	PlanDiffCmd PlanDiffCmd
+	// PlanExportCmd exports the operation plan for offline review
+	PlanExportCmd PlanExportCmd
 	// UpdateCmd combines app update related commands
 	UpdateCmd UpdateCmd
 	// UpdateCheckCmd checks if a new app version is available
 	UpdateCheckCmd UpdateCheckCmd
 	// UpdateTriggerCmd launches app update
 	UpdateTriggerCmd UpdateTriggerCmd
+	// UpdateCatchUpCmd updates a node excluded from a previous update
+	UpdateCatchUpCmd UpdateCatchUpCmd
+	// UpdateBinaryCmd replaces the gravity binary on cluster nodes
+	UpdateBinaryCmd UpdateBinaryCmd
 	// UpdateUploadCmd uploads new app version to local cluster
 	UpdateUploadCmd UpdateUploadCmd
+	// UpdateDownloadCmd downloads new app version ahead of the upgrade
+	UpdateDownloadCmd UpdateDownloadCmd
 	// UpdateCompleteCmd marks update operation as complete
 	UpdateCompleteCmd UpdateCompleteCmd
 	// UpdateSystemCmd updates system packages
@@ -102,12 +132,42 @@ type Application struct {
 	StatusCmd StatusCmd
 	// StatusResetCmd resets the cluster to active state
 	StatusResetCmd StatusResetCmd
+	// StatusCheckCmd runs the Nagios-compatible cluster health check
+	StatusCheckCmd StatusCheckCmd
+	// WaitCmd waits for a cluster condition to be met
+	WaitCmd WaitCmd
+	// StorageCmd combines persistent storage related subcommands
+	StorageCmd StorageCmd
+	// StorageUsageCmd displays persistent storage usage per namespace
+	StorageUsageCmd StorageUsageCmd
+	// StorageSnapshotCmd combines persistent volume snapshot subcommands
+	StorageSnapshotCmd StorageSnapshotCmd
+	// StorageSnapshotCreateCmd takes a snapshot of persistent volumes
+	StorageSnapshotCreateCmd StorageSnapshotCreateCmd
+	// StorageSnapshotListCmd lists persistent volume snapshots
+	StorageSnapshotListCmd StorageSnapshotListCmd
+	// StorageSnapshotRestoreCmd restores a persistent volume snapshot
+	StorageSnapshotRestoreCmd StorageSnapshotRestoreCmd
+	// StorageSnapshotRemoveCmd removes a persistent volume snapshot
+	StorageSnapshotRemoveCmd StorageSnapshotRemoveCmd
+	// StorageMigrationCmd combines application data migration subcommands
+	StorageMigrationCmd StorageMigrationCmd
+	// StorageMigrationExportCmd exports application data into an archive
+	StorageMigrationExportCmd StorageMigrationExportCmd
+	// StorageMigrationImportCmd imports application data from an archive
+	StorageMigrationImportCmd StorageMigrationImportCmd
+	// StorageMigrationPushCmd migrates application data to another cluster
+	StorageMigrationPushCmd StorageMigrationPushCmd
 	// BackupCmd launches app backup hook
 	BackupCmd BackupCmd
 	// RestoreCmd launches app restore hook
 	RestoreCmd RestoreCmd
 	// CheckCmd checks that the host satisfies app manifest requirements
 	CheckCmd CheckCmd
+	// NetworkCmd combines network diagnostic subcommands
+	NetworkCmd NetworkCmd
+	// NetworkMTUTestCmd probes the path MTU to a remote node
+	NetworkMTUTestCmd NetworkMTUTestCmd
 	// AppCmd combines subcommands for app service
 	AppCmd AppCmd
 	// AppInstallCmd installs an application from an application image
@@ -122,6 +182,10 @@ type Application struct {
 	AppUninstallCmd AppUninstallCmd
 	// AppHistoryCmd displays revision history for a release
 	AppHistoryCmd AppHistoryCmd
+	// AppEnableCmd enables an optional application component
+	AppEnableCmd AppEnableCmd
+	// AppDisableCmd disables an optional application component
+	AppDisableCmd AppDisableCmd
 	// AppSyncCmd synchronizes an application image with a cluster
 	AppSyncCmd AppSyncCmd
 	// AppSearchCmd searches for applications.
@@ -146,8 +210,10 @@ type Application struct {
 	AppPullCmd AppPullCmd
 	// AppPushCmd pushes app to specified cluster
 	AppPushCmd AppPushCmd
-	// AppHookCmd launches specified app hook
+	// AppHookCmd combines application hook subcommands
 	AppHookCmd AppHookCmd
+	// AppHookRunCmd launches specified app hook
+	AppHookRunCmd AppHookRunCmd
 	// AppUnpackCmd unpacks specified app resources
 	AppUnpackCmd AppUnpackCmd
 	// WizardCmd starts installer in UI mode
@@ -198,6 +264,12 @@ type Application struct {
 	UsersInviteCmd UsersInviteCmd
 	// UsersResetCmd generates a user password reset link
 	UsersResetCmd UsersResetCmd
+	// LicenseCmd combines cluster license related subcommands
+	LicenseCmd LicenseCmd
+	// LicenseShowCmd displays the installed cluster license
+	LicenseShowCmd LicenseShowCmd
+	// LicenseInstallCmd installs a new cluster license
+	LicenseInstallCmd LicenseInstallCmd
 	// APIKeyCmd combines subcommands for API tokens
 	APIKeyCmd APIKeyCmd
 	// APIKeyCreateCmd creates a new token
@@ -270,6 +342,20 @@ type Application struct {
 	SystemReportCmd SystemReportCmd
 	// SystemStateDirCmd shows local state directory
 	SystemStateDirCmd SystemStateDirCmd
+	// SystemInventoryCmd outputs hardware and software inventory of the node
+	SystemInventoryCmd SystemInventoryCmd
+	// SystemDevicesCmd combines block device related subcommands
+	SystemDevicesCmd SystemDevicesCmd
+	// SystemDevicesListCmd lists block devices of the node
+	SystemDevicesListCmd SystemDevicesListCmd
+	// SystemCertificatesCmd combines node certificate related subcommands
+	SystemCertificatesCmd SystemCertificatesCmd
+	// SystemCertificatesListCmd lists the certificates of the node
+	SystemCertificatesListCmd SystemCertificatesListCmd
+	// SystemLogLevelCmd combines log level related subcommands
+	SystemLogLevelCmd SystemLogLevelCmd
+	// SystemLogLevelSetCmd updates log levels of cluster controllers
+	SystemLogLevelSetCmd SystemLogLevelSetCmd
 	// SystemDevicemapperCmd combines devicemapper related subcommands
 	SystemDevicemapperCmd SystemDevicemapperCmd
 	// SystemDevicemapperMountCmd configures devicemapper environment
@@ -291,6 +377,8 @@ type Application struct {
 	// GarbageCollectCmd prunes unused resources (package/journal files/docker images)
 	// in the cluster
 	GarbageCollectCmd GarbageCollectCmd
+	// PatchCmd patches the operating system on cluster nodes one node at a time
+	PatchCmd PatchCmd
 	// PlanetCmd combines planet subcommands
 	PlanetCmd PlanetCmd
 	// [DEPRECATED] PlanetEnterCmd enters planet container
@@ -313,6 +401,34 @@ type Application struct {
 	ResourceGetCmd ResourceGetCmd
 	// TopCmd displays cluster metrics in terminal
 	TopCmd TopCmd
+	// AuditCmd combines subcommands for the command line audit log
+	AuditCmd AuditCmd
+	// AuditListCmd lists recorded audit events
+	AuditListCmd AuditListCmd
+	// NodeCmd combines subcommands for cluster nodes
+	NodeCmd NodeCmd
+	// NodeInspectCmd displays the record of a cluster node
+	NodeInspectCmd NodeInspectCmd
+	// NodeListCmd lists cluster nodes
+	NodeListCmd NodeListCmd
+	// NodeLabelCmd updates labels of a cluster node
+	NodeLabelCmd NodeLabelCmd
+	// InventoryCmd combines subcommands for the cluster inventory
+	InventoryCmd InventoryCmd
+	// InventoryExportCmd exports hardware and software inventory of cluster nodes
+	InventoryExportCmd InventoryExportCmd
+	// ClustersCmd combines subcommands for managing clusters connected to Gravity Hub
+	ClustersCmd ClustersCmd
+	// ClustersListCmd lists clusters connected to Gravity Hub
+	ClustersListCmd ClustersListCmd
+	// ClustersStatusCmd displays the status of a cluster connected to Gravity Hub
+	ClustersStatusCmd ClustersStatusCmd
+	// ClustersLabelCmd updates labels of a cluster connected to Gravity Hub
+	ClustersLabelCmd ClustersLabelCmd
+
+	// backendSnapshot specifies whether the local state database
+	// is read from a snapshot when it is locked by another process
+	backendSnapshot bool
 }
 
 // VersionCmd displays the binary version
@@ -322,6 +438,11 @@ type VersionCmd struct {
 	Output *constants.Format
 }
 
+// PlanExportCmd exports the operation plan for offline review
+type PlanExportCmd struct {
+	*kingpin.CmdClause
+}
+
 // DNSConfig returns DNS configuration
 func (r InstallCmd) DNSConfig() (config storage.DNSConfig) {
 	for _, addr := range *r.DNSListenAddrs {
@@ -338,6 +459,14 @@ type InstallCmd struct {
 	Path *string
 	// AdvertiseAddr is local node advertise IP address
 	AdvertiseAddr *string
+	// AdvertiseCIDR selects the advertise address from the subnet if it has not been specified
+	AdvertiseCIDR *string
+	// AdvertiseInterface selects the advertise address of the interface with the matching name
+	// if it has not been specified
+	AdvertiseInterface *string
+	// AdvertiseDefaultRoute selects the advertise address of the interface with the default route
+	// if it has not been specified
+	AdvertiseDefaultRoute *bool
 	// Token is unique install token
 	Token *string
 	// CloudProvider enables cloud provider integration
@@ -382,10 +511,31 @@ type InstallCmd struct {
 	ServiceGID *string
 	// GCENodeTags lists additional node tags on GCE
 	GCENodeTags *[]string
+	// SELinux specifies whether to configure SELinux on the nodes
+	SELinux *bool
+	// DefaultDenyNetworkPolicy specifies whether to deploy the baseline network policies
+	DefaultDenyNetworkPolicy *bool
+	// RegistryDir specifies the pre-seeded docker registry directory
+	RegistryDir *string
+	// CNI specifies the network plugin to install the cluster with
+	CNI *string
+	// NodeLabels specifies additional Kubernetes labels for this node
+	NodeLabels *map[string]string
+	// NodeTaints specifies additional Kubernetes taints for this node
+	NodeTaints *[]string
+	// With lists the optional application components to include
+	With *[]string
+	// Without lists the optional application components to exclude
+	Without *[]string
 	// DNSHosts is a list of DNS host overrides
 	DNSHosts *[]string
 	// DNSZones is a list of DNS zone overrides
 	DNSZones *[]string
+	// CACert is the path to the certificate authority certificate
+	// to issue the cluster certificates with
+	CACert *string
+	// CAKey is the path to the certificate authority private key
+	CAKey *string
 	// Remote specifies whether the host should not be part of the cluster
 	Remote *bool
 	// FromService specifies whether this process runs in service mode.
@@ -403,6 +553,14 @@ type JoinCmd struct {
 	PeerAddr *string
 	// AdvertiseAddr is local node advertise IP address
 	AdvertiseAddr *string
+	// AdvertiseCIDR selects the advertise address from the subnet if it has not been specified
+	AdvertiseCIDR *string
+	// AdvertiseInterface selects the advertise address of the interface with the matching name
+	// if it has not been specified
+	AdvertiseInterface *string
+	// AdvertiseDefaultRoute selects the advertise address of the interface with the default route
+	// if it has not been specified
+	AdvertiseDefaultRoute *bool
 	// Token is join token
 	Token *string
 	// Role is local node profile
@@ -415,6 +573,10 @@ type JoinCmd struct {
 	ServerAddr *string
 	// Mounts is additional app mounts
 	Mounts *configure.KeyVal
+	// NodeLabels specifies additional Kubernetes labels for this node
+	NodeLabels *map[string]string
+	// NodeTaints specifies additional Kubernetes taints for this node
+	NodeTaints *[]string
 	// CloudProvider turns on cloud provider integration
 	CloudProvider *string
 	// OperationID is the ID of the operation created via UI
@@ -454,6 +616,39 @@ type AutoJoinCmd struct {
 	FromService *bool
 }
 
+// UninstallCmd tears down Gravity on this node or the entire cluster
+type UninstallCmd struct {
+	*kingpin.CmdClause
+	// Node uninstalls Gravity from this node only
+	Node *bool
+	// All uninstalls Gravity from all cluster nodes
+	All *bool
+	// PreserveData keeps the state directory with the cluster and application data
+	PreserveData *bool
+	// Confirmed suppresses confirmation prompt
+	Confirmed *bool
+	// KeepTeleport keeps the Teleport service running
+	KeepTeleport *bool
+}
+
+// ReinstallCmd reinstalls Gravity on this node preserving application data
+type ReinstallCmd struct {
+	*kingpin.CmdClause
+	// Preserve lists application data directories to preserve
+	Preserve *[]string
+	// Confirmed suppresses confirmation prompt
+	Confirmed *bool
+}
+
+// StageCmd stages cluster image packages on the node ahead of join
+type StageCmd struct {
+	*kingpin.CmdClause
+	// Path is the path to the unpacked cluster image
+	Path *string
+	// Role is the profile of the node to stage packages for
+	Role *string
+}
+
 // LeaveCmd removes the current node from the cluster
 type LeaveCmd struct {
 	*kingpin.CmdClause
@@ -461,6 +656,9 @@ type LeaveCmd struct {
 	Force *bool
 	// Confirm suppresses confirmation prompt
 	Confirm *bool
+	// SkipCapacityCheck allows to leave even if the remaining nodes
+	// cannot host the evicted pods
+	SkipCapacityCheck *bool
 }
 
 // RemoveCmd removes the specified node from the cluster
@@ -472,6 +670,11 @@ type RemoveCmd struct {
 	Force *bool
 	// Confirm suppresses confirmation prompt
 	Confirm *bool
+	// DryRun reports the impact of removing the node without removing it
+	DryRun *bool
+	// SkipCapacityCheck allows to remove the node even if the remaining nodes
+	// cannot host the evicted pods
+	SkipCapacityCheck *bool
 }
 
 // ResumeCmd resumes active operation
@@ -487,6 +690,28 @@ type ResumeCmd struct {
 	PhaseTimeout *time.Duration
 }
 
+// OperationCmd combines cluster operation subcommands
+type OperationCmd struct {
+	*kingpin.CmdClause
+}
+
+// OperationApproveCmd approves an operation started by another user
+type OperationApproveCmd struct {
+	*kingpin.CmdClause
+	// ApprovalID is the ID of the approval request
+	ApprovalID *string
+}
+
+// OperationQueueCmd manages queued cluster operations
+type OperationQueueCmd struct {
+	*kingpin.CmdClause
+}
+
+// OperationQueueListCmd lists queued cluster operations
+type OperationQueueListCmd struct {
+	*kingpin.CmdClause
+}
+
 // PlanCmd manages an operation plan
 type PlanCmd struct {
 	*kingpin.CmdClause
@@ -503,6 +728,10 @@ type PlanDisplayCmd struct {
 	Output *constants.Format
 	// Short is a shorthand for short output format
 	Short *bool
+	// Phase is the phase to display
+	Phase *string
+	// Logs displays the output captured while executing the phase
+	Logs *bool
 }
 
 // PlanExecuteCmd executes a phase of an active operation
@@ -541,6 +770,8 @@ type PlanResumeCmd struct {
 	*kingpin.CmdClause
 	// Force forces rollback of the phase given in Phase
 	Force *bool
+	// Auto retries phases that failed with transient errors
+	Auto *bool
 	// PhaseTimeout is the rollback timeout
 	PhaseTimeout *time.Duration
 }
@@ -550,6 +781,17 @@ type PlanCompleteCmd struct {
 	*kingpin.CmdClause
 }
 
+// PlanDiffCmd compares plans of two operations
+type PlanDiffCmd struct {
+	*kingpin.CmdClause
+	// From is the ID of the first operation
+	From *string
+	// To is the ID of the second operation
+	To *string
+	// Output is output format
+	Output *constants.Format
+}
+
 // InstallPlanCmd combines subcommands for install plan
 type InstallPlanCmd struct {
 	*kingpin.CmdClause
@@ -595,6 +837,46 @@ type UpdateTriggerCmd struct {
 	Manual *bool
 	// SkipVersionCheck suppresses version mismatch errors
 	SkipVersionCheck *bool
+	// SkipNodes lists nodes to exclude from the operation
+	SkipNodes *[]string
+	// Selector limits the operation to the nodes with labels matching the selector
+	Selector *string
+	// PauseAfter lists phases to pause the operation after
+	PauseAfter *[]string
+	// Plan is the path to the plan exported with 'gravity plan export'
+	// to execute instead of generating a new one
+	Plan *string
+	// PlanHash is the hash of the reviewed exported plan
+	PlanHash *string
+	// Throttle specifies how much the resource usage of the operation is throttled
+	Throttle *string
+	// SkipCapacityCheck allows to upgrade even if the cluster does not have
+	// the capacity to host the pods evicted from the drained nodes
+	SkipCapacityCheck *bool
+}
+
+// UpdateCatchUpCmd brings a node excluded from a previous update
+// to the currently installed cluster version
+type UpdateCatchUpCmd struct {
+	*kingpin.CmdClause
+	// Node is the hostname or advertise IP of the node to update
+	Node *string
+	// Manual starts operation in manual mode
+	Manual *bool
+	// SkipVersionCheck suppresses version mismatch errors
+	SkipVersionCheck *bool
+}
+
+// UpdateBinaryCmd replaces the gravity binary on cluster nodes
+// with this binary one node at a time
+type UpdateBinaryCmd struct {
+	*kingpin.CmdClause
+	// Manual is whether the operation is not executed automatically
+	Manual *bool
+	// Confirmed suppresses confirmation prompt
+	Confirmed *bool
+	// SkipNodes lists nodes to exclude from the operation
+	SkipNodes *[]string
 }
 
 // UpdateUploadCmd uploads new app version to local cluster
@@ -604,6 +886,18 @@ type UpdateUploadCmd struct {
 	OpsCenterURL *string
 }
 
+// UpdateDownloadCmd downloads new app version from Gravity Hub
+// into the update directory ahead of the upgrade
+type UpdateDownloadCmd struct {
+	*kingpin.CmdClause
+	// App is the cluster image to download
+	App *string
+	// OpsCenterURL is the Gravity Hub to download the cluster image from
+	OpsCenterURL *string
+	// Delete removes the previously downloaded cluster image
+	Delete *bool
+}
+
 // UpdateCompleteCmd marks update operation as completed
 type UpdateCompleteCmd struct {
 	*kingpin.CmdClause
@@ -646,6 +940,24 @@ type UpgradeCmd struct {
 	Resume *bool
 	// SkipVersionCheck suppresses version mismatch errors
 	SkipVersionCheck *bool
+	// SkipNodes lists nodes to exclude from the operation
+	SkipNodes *[]string
+	// Selector limits the operation to the nodes with labels matching the selector
+	Selector *string
+	// PauseAfter lists phases to pause the operation after
+	PauseAfter *[]string
+	// Plan is the path to the plan exported with 'gravity plan export'
+	// to execute instead of generating a new one
+	Plan *string
+	// PlanHash is the hash of the reviewed exported plan
+	PlanHash *string
+	// Preview specifies the cluster image tarball to preview the upgrade to
+	Preview *string
+	// Throttle specifies how much the resource usage of the operation is throttled
+	Throttle *string
+	// SkipCapacityCheck allows to upgrade even if the cluster does not have
+	// the capacity to host the pods evicted from the drained nodes
+	SkipCapacityCheck *bool
 }
 
 // StatusCmd displays cluster status
@@ -659,6 +971,15 @@ type StatusCmd struct {
 	OperationID *string
 	// Seconds displays status continuously
 	Seconds *int
+	// Watch continuously redraws cluster status in place
+	Watch *bool
+	// Interval is the status refresh interval in watch mode
+	Interval *time.Duration
+	// DegradedTimeout is how long the cluster can stay degraded
+	// in watch mode before the command exits with an error
+	DegradedTimeout *time.Duration
+	// Probes lists the results of the individual health probes
+	Probes *bool
 	// Output is output format
 	Output *constants.Format
 }
@@ -668,6 +989,129 @@ type StatusResetCmd struct {
 	*kingpin.CmdClause
 }
 
+// StatusCheckCmd runs the cluster health check and exits with
+// a Nagios-compatible exit code
+type StatusCheckCmd struct {
+	*kingpin.CmdClause
+	// Output is output format
+	Output *constants.Format
+}
+
+// WaitCmd waits for a cluster condition to be met
+type WaitCmd struct {
+	*kingpin.CmdClause
+	// For is the condition to wait for
+	For *string
+	// Timeout is the maximum time to wait
+	Timeout *time.Duration
+	// Interval is the interval between condition checks
+	Interval *time.Duration
+}
+
+// StorageCmd combines persistent storage related subcommands
+type StorageCmd struct {
+	*kingpin.CmdClause
+}
+
+// StorageUsageCmd displays persistent storage usage per namespace
+type StorageUsageCmd struct {
+	*kingpin.CmdClause
+	// Format is the output format
+	Format *constants.Format
+}
+
+// StorageSnapshotCmd combines persistent volume snapshot subcommands
+type StorageSnapshotCmd struct {
+	*kingpin.CmdClause
+}
+
+// StorageSnapshotCreateCmd takes a snapshot of persistent volumes
+type StorageSnapshotCreateCmd struct {
+	*kingpin.CmdClause
+	// Name is the snapshot name
+	Name *string
+	// Namespace limits the snapshot to the claims in the namespace
+	Namespace *string
+	// Selector is the label selector of the claims to snapshot
+	Selector *string
+	// Claims lists the claims to snapshot
+	Claims *[]string
+	// SnapshotClass overrides the volume snapshot class
+	SnapshotClass *string
+	// Etcd is whether to backup etcd data along with the volumes
+	Etcd *bool
+	// Timeout is the maximum time to wait for the snapshots to become ready
+	Timeout *time.Duration
+}
+
+// StorageSnapshotListCmd lists persistent volume snapshots
+type StorageSnapshotListCmd struct {
+	*kingpin.CmdClause
+	// Format is the output format
+	Format *constants.Format
+}
+
+// StorageSnapshotRestoreCmd restores a persistent volume snapshot
+type StorageSnapshotRestoreCmd struct {
+	*kingpin.CmdClause
+	// Name is the snapshot name
+	Name *string
+	// Suffix is appended to the names of the restored claims
+	Suffix *string
+}
+
+// StorageSnapshotRemoveCmd removes a persistent volume snapshot
+type StorageSnapshotRemoveCmd struct {
+	*kingpin.CmdClause
+	// Name is the snapshot name
+	Name *string
+}
+
+// StorageMigrationCmd combines application data migration subcommands
+type StorageMigrationCmd struct {
+	*kingpin.CmdClause
+}
+
+// StorageMigrationExportCmd exports application data into an archive
+type StorageMigrationExportCmd struct {
+	*kingpin.CmdClause
+	// Path is the path of the archive to create
+	Path *string
+	// Namespaces lists the namespaces to export
+	Namespaces *[]string
+	// Claims limits the exported persistent volume claims
+	Claims *[]string
+	// RateLimit is the maximum transfer rate
+	RateLimit *string
+}
+
+// StorageMigrationImportCmd imports application data from an archive
+type StorageMigrationImportCmd struct {
+	*kingpin.CmdClause
+	// Path is the path of the archive to import
+	Path *string
+	// StorageClasses maps the storage classes of the source cluster
+	StorageClasses *[]string
+}
+
+// StorageMigrationPushCmd migrates application data to another cluster
+type StorageMigrationPushCmd struct {
+	*kingpin.CmdClause
+	// OpsCenterURL is the URL of the Ops Center or the cluster controller
+	// of the target cluster
+	OpsCenterURL *string
+	// Cluster is the name of the target cluster
+	Cluster *string
+	// Namespaces lists the namespaces to migrate
+	Namespaces *[]string
+	// Claims limits the migrated persistent volume claims
+	Claims *[]string
+	// RateLimit is the maximum transfer rate
+	RateLimit *string
+	// StorageClasses maps the storage classes of this cluster
+	StorageClasses *[]string
+}
+
 // BackupCmd launches app backup hook
 type BackupCmd struct {
 	*kingpin.CmdClause
@@ -677,6 +1121,9 @@ type BackupCmd struct {
 	Timeout *time.Duration
 	// Follow tails operation logs
 	Follow *bool
+	// Snapshot is the name of the persistent volume snapshot to take
+	// along with the backup
+	Snapshot *string
 }
 
 // RestoreCmd launches app restore hook
@@ -699,6 +1146,20 @@ type CheckCmd struct {
 	Profile *string
 	// AutoFix enables automatic fixing of some failed checks
 	AutoFix *bool
+	// Format is the output format
+	Format *constants.Format
+}
+
+// NetworkCmd combines network diagnostic subcommands
+type NetworkCmd struct {
+	*kingpin.CmdClause
+}
+
+// NetworkMTUTestCmd probes the path MTU to a remote node
+type NetworkMTUTestCmd struct {
+	*kingpin.CmdClause
+	// Addr is the address of the remote node
+	Addr *string
 }
 
 // AppCmd combines subcommands for app service
@@ -780,6 +1241,20 @@ type AppHistoryCmd struct {
 	Release *string
 }
 
+// AppEnableCmd installs an optional application component into the cluster.
+type AppEnableCmd struct {
+	*kingpin.CmdClause
+	// Component is the name of the component to enable.
+	Component *string
+}
+
+// AppDisableCmd uninstalls an optional application component from the cluster.
+type AppDisableCmd struct {
+	*kingpin.CmdClause
+	// Component is the name of the component to disable.
+	Component *string
+}
+
 // AppSyncCmd synchronizes an application image with a cluster.
 type AppSyncCmd struct {
 	*kingpin.CmdClause
@@ -913,6 +1388,9 @@ type AppPullCmd struct {
 	Package *loc.Locator
 	// OpsCenterURL is app service URL to pull from
 	OpsCenterURL *string
+	// URL is the optional Gravity Hub or OCI registry URL to pull from.
+	// Takes precedence over OpsCenterURL
+	URL *string
 	// Labels is labels to apply to pulled app
 	Labels *configure.KeyVal
 	// Force overwrites existing app
@@ -926,17 +1404,27 @@ type AppPushCmd struct {
 	Package *loc.Locator
 	// OpsCenterURL is app service URL to push to
 	OpsCenterURL *string
+	// URL is the optional Gravity Hub or OCI registry URL to push to.
+	// Takes precedence over OpsCenterURL
+	URL *string
 }
 
-// AppHookCmd launches specified app hook
+// AppHookCmd combines application hook subcommands
 type AppHookCmd struct {
 	*kingpin.CmdClause
+}
+
+// AppHookRunCmd launches specified app hook
+type AppHookRunCmd struct {
+	*kingpin.CmdClause
 	// Package is app locator
 	Package *loc.Locator
 	// HookName specifies hook to launch
 	HookName *string
 	// Env is additional environment variables to provide to hook
 	Env *map[string]string
+	// Timeout overrides the hook job deadline
+	Timeout *time.Duration
 }
 
 // AppUnpackCmd unpacks app resources
@@ -1013,6 +1501,14 @@ type OpsAgentCmd struct {
 	PackageAddr *string
 	// AdvertiseAddr is agent advertise IP address
 	AdvertiseAddr *net.IP
+	// AdvertiseCIDR selects the advertise address from the subnet if it has not been specified
+	AdvertiseCIDR *string
+	// AdvertiseInterface selects the advertise address of the interface with the matching name
+	// if it has not been specified
+	AdvertiseInterface *string
+	// AdvertiseDefaultRoute selects the advertise address of the interface with the default route
+	// if it has not been specified
+	AdvertiseDefaultRoute *bool
 	// ServerAddr is RPC server address
 	ServerAddr *string
 	// Token is agent token
@@ -1071,6 +1567,9 @@ type PackExportCmd struct {
 	OpsCenterURL *string
 	// FileMask is file mask for exported package
 	FileMask *string
+	// Delta specifies whether to only transfer the parts of the package
+	// that differ from the existing file
+	Delta *bool
 }
 
 // PackListCmd lists packages
@@ -1124,6 +1623,9 @@ type PackPushCmd struct {
 	Package *loc.Locator
 	// OpsCenterURL is pack service URL to push into
 	OpsCenterURL *string
+	// URL is the optional Gravity Hub or OCI registry URL to push to.
+	// Takes precedence over OpsCenterURL
+	URL *string
 }
 
 // PackPullCmd pulls package from specified cluster
@@ -1133,6 +1635,9 @@ type PackPullCmd struct {
 	Package *loc.Locator
 	// OpsCenterURL is pack service URL to pull from
 	OpsCenterURL *string
+	// URL is the optional Gravity Hub or OCI registry URL to pull from.
+	// Takes precedence over OpsCenterURL
+	URL *string
 	// Labels is labels to update pulled package with
 	Labels *configure.KeyVal
 	// Force overwrites existing package
@@ -1204,6 +1709,25 @@ type UsersResetCmd struct {
 	TTL *time.Duration
 }
 
+// LicenseCmd combines cluster license related subcommands
+type LicenseCmd struct {
+	*kingpin.CmdClause
+}
+
+// LicenseShowCmd displays the installed cluster license
+type LicenseShowCmd struct {
+	*kingpin.CmdClause
+	// Output is the output format
+	Output *constants.Format
+}
+
+// LicenseInstallCmd installs a new cluster license
+type LicenseInstallCmd struct {
+	*kingpin.CmdClause
+	// Path is the path to the license file
+	Path *string
+}
+
 // APIKeyCmd combines subcommands for API tokens
 type APIKeyCmd struct {
 	*kingpin.CmdClause
@@ -1243,6 +1767,8 @@ type ReportCmd struct {
 	*kingpin.CmdClause
 	// FilePath is the report tarball path
 	FilePath *string
+	// Upload is the address of the Ops Center to upload the report to
+	Upload *string
 }
 
 // SiteCmd combines cluster related subcommands
@@ -1353,6 +1879,14 @@ type SystemRotateCertsCmd struct {
 	ValidFor *time.Duration
 	// CAPath is CA to use
 	CAPath *string
+	// CACert is the path to the new certificate authority certificate
+	CACert *string
+	// CAKey is the path to the new certificate authority private key
+	CAKey *string
+	// CAPhase is the step of the certificate authority replacement to execute
+	CAPhase *string
+	// Restart restarts the runtime container after the rotation
+	Restart *bool
 }
 
 // SystemExportCACmd exports cluster CA
@@ -1498,6 +2032,59 @@ type SystemStateDirCmd struct {
 	*kingpin.CmdClause
 }
 
+// SystemInventoryCmd outputs hardware and software inventory of the node
+type SystemInventoryCmd struct {
+	*kingpin.CmdClause
+}
+
+// SystemDevicesCmd combines block device related subcommands
+type SystemDevicesCmd struct {
+	*kingpin.CmdClause
+}
+
+// SystemDevicesListCmd lists block devices of the node along with
+// whether they pass the persistent storage device filters
+type SystemDevicesListCmd struct {
+	*kingpin.CmdClause
+	// Format is the output format
+	Format *constants.Format
+	// IncludeVendors lists vendors of devices to include
+	IncludeVendors *[]string
+	// ExcludeVendors lists vendors of devices to exclude
+	ExcludeVendors *[]string
+	// IncludePaths lists path patterns of devices to include
+	IncludePaths *[]string
+	// ExcludePaths lists path patterns of devices to exclude
+	ExcludePaths *[]string
+}
+
+// SystemCertificatesCmd combines node certificate related subcommands
+type SystemCertificatesCmd struct {
+	*kingpin.CmdClause
+}
+
+// SystemCertificatesListCmd lists the certificates of the node
+// or all cluster nodes along with their expiration
+type SystemCertificatesListCmd struct {
+	*kingpin.CmdClause
+	// Cluster lists the certificates of all cluster nodes
+	Cluster *bool
+	// Format is the output format
+	Format *constants.Format
+}
+
+// SystemLogLevelCmd combines log level related subcommands
+type SystemLogLevelCmd struct {
+	*kingpin.CmdClause
+}
+
+// SystemLogLevelSetCmd updates log levels of cluster controllers
+type SystemLogLevelSetCmd struct {
+	*kingpin.CmdClause
+	// Levels lists subsystem=level items
+	Levels *[]string
+}
+
 // SystemDevicemapperCmd combines devicemapper related subcommands
 type SystemDevicemapperCmd struct {
 	*kingpin.CmdClause
@@ -1575,6 +2162,21 @@ type GarbageCollectCmd struct {
 	Confirmed *bool
 }
 
+// PatchCmd patches the operating system on cluster nodes one node at a time
+type PatchCmd struct {
+	*kingpin.CmdClause
+	// Command is the shell command to run on each node
+	Command *string
+	// Reboot reboots each node instead of running a command
+	Reboot *bool
+	// Manual is whether the operation is not executed automatically
+	Manual *bool
+	// Confirmed suppresses confirmation prompt
+	Confirmed *bool
+	// SkipNodes lists nodes to exclude from the operation
+	SkipNodes *[]string
+}
+
 // GarbageCollectPlanCmd displays the plan of the garbage collection operation
 type GarbageCollectPlanCmd struct {
 	*kingpin.CmdClause
@@ -1615,11 +2217,25 @@ type ExecCmd struct {
 	Cmd *string
 	// Args is additional arguments to the command Cmd
 	Args *[]string
+	// All executes the command on all cluster nodes
+	All *bool
+	// Role executes the command on the cluster nodes with the specified role
+	Role *string
+	// Selector executes the command on the cluster nodes with labels matching the selector
+	Selector *string
+	// Parallel is the number of nodes to execute the command on concurrently
+	Parallel *int
+	// Timeout is the command execution timeout on each node
+	Timeout *time.Duration
+	// Output is the output format of the aggregated results
+	Output *constants.Format
 }
 
 // ShellCmd is an alias for exec with -ti /bin/bash
 type ShellCmd struct {
 	*kingpin.CmdClause
+	// REPL starts the interactive troubleshooting shell instead
+	REPL *bool
 }
 
 // ResourceCmd combines resource related subcommands
@@ -1642,6 +2258,15 @@ type ResourceCreateCmd struct {
 	Manual *bool
 	// Confirmed suppresses confirmation prompt
 	Confirmed *bool
+	// SkipNodes lists nodes to exclude from the operation
+	// triggered by the resource
+	SkipNodes *[]string
+	// Import applies the resources exported from a cluster as a single unit
+	Import *bool
+	// WithOperations allows the import of resources applied with a cluster operation
+	WithOperations *bool
+	// DryRun outputs the change without persisting anything
+	DryRun *bool
 }
 
 // ResourceRemoveCmd removes specified resource
@@ -1661,6 +2286,8 @@ type ResourceRemoveCmd struct {
 	Manual *bool
 	// Confirmed suppresses confirmation prompt
 	Confirmed *bool
+	// DryRun outputs the change without persisting anything
+	DryRun *bool
 }
 
 // ResourceGetCmd shows specified resource
@@ -1676,6 +2303,10 @@ type ResourceGetCmd struct {
 	WithSecrets *bool
 	// User is resource owner
 	User *string
+	// Capacity displays the block storage capacity for persistent storage
+	Capacity *bool
+	// Export outputs all supported resources as a single YAML stream
+	Export *bool
 }
 
 // TopCmd displays cluster metrics in terminal.
@@ -1686,3 +2317,97 @@ type TopCmd struct {
 	// Step is the max time b/w two datapoints.
 	Step *time.Duration
 }
+
+// AuditCmd combines subcommands for the command line audit log
+type AuditCmd struct {
+	*kingpin.CmdClause
+}
+
+// AuditListCmd lists recorded audit events
+type AuditListCmd struct {
+	*kingpin.CmdClause
+	// Since limits the output to events recorded within the specified duration
+	Since *time.Duration
+}
+
+// NodeCmd combines subcommands for cluster nodes
+type NodeCmd struct {
+	*kingpin.CmdClause
+}
+
+// NodeInspectCmd displays the record of a cluster node
+type NodeInspectCmd struct {
+	*kingpin.CmdClause
+	// Name is the hostname, advertise IP or cloud node name of the node
+	Name *string
+	// Output is the output format
+	Output *constants.Format
+}
+
+// NodeListCmd lists cluster nodes
+type NodeListCmd struct {
+	*kingpin.CmdClause
+	// Selector lists only the nodes with labels matching the selector
+	Selector *string
+	// Output is the output format
+	Output *constants.Format
+}
+
+// NodeLabelCmd updates labels of a cluster node
+type NodeLabelCmd struct {
+	*kingpin.CmdClause
+	// Name is the hostname, advertise IP or cloud node name of the node
+	Name *string
+	// Labels lists the label updates: key=value sets the label, key- removes it
+	Labels *[]string
+}
+
+// InventoryCmd combines subcommands for the cluster inventory
+type InventoryCmd struct {
+	*kingpin.CmdClause
+}
+
+// InventoryExportCmd exports hardware and software inventory of cluster nodes
+type InventoryExportCmd struct {
+	*kingpin.CmdClause
+	// Format is the output format: json or csv
+	Format *constants.Format
+}
+
+// ClustersCmd combines subcommands for managing clusters connected to Gravity Hub
+type ClustersCmd struct {
+	*kingpin.CmdClause
+}
+
+// ClustersListCmd lists clusters connected to Gravity Hub
+type ClustersListCmd struct {
+	*kingpin.CmdClause
+	// OpsCenterURL is the Gravity Hub URL
+	OpsCenterURL *string
+	// Selector lists only the clusters with labels matching the selector
+	Selector *string
+	// Format is the output format
+	Format *constants.Format
+}
+
+// ClustersStatusCmd displays the status of a cluster connected to Gravity Hub
+type ClustersStatusCmd struct {
+	*kingpin.CmdClause
+	// Name is the cluster name
+	Name *string
+	// OpsCenterURL is the Gravity Hub URL
+	OpsCenterURL *string
+	// Format is the output format
+	Format *constants.Format
+}
+
+// ClustersLabelCmd updates labels of a cluster connected to Gravity Hub
+type ClustersLabelCmd struct {
+	*kingpin.CmdClause
+	// Name is the cluster name
+	Name *string
+	// Labels lists the label updates: key=value sets the label, key- removes it
+	Labels *[]string
+	// OpsCenterURL is the Gravity Hub URL
+	OpsCenterURL *string
+}
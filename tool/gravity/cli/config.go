@@ -18,6 +18,7 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net"
@@ -31,6 +32,7 @@ import (
 	"github.com/gravitational/gravity/lib/app"
 	appservice "github.com/gravitational/gravity/lib/app"
 	autoscaleaws "github.com/gravitational/gravity/lib/autoscale/aws"
+	autoscalegce "github.com/gravitational/gravity/lib/autoscale/gce"
 	awscloud "github.com/gravitational/gravity/lib/cloudprovider/aws"
 	cloudaws "github.com/gravitational/gravity/lib/cloudprovider/aws"
 	cloudgce "github.com/gravitational/gravity/lib/cloudprovider/gce"
@@ -67,6 +69,7 @@ import (
 	teledefaults "github.com/gravitational/teleport/lib/defaults"
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -76,6 +79,10 @@ type InstallConfig struct {
 	// AdvertiseAddr is advertise address of this server.
 	// Also specifies the address advertised as wizard service endpoint
 	AdvertiseAddr string
+	// AdvertisePolicy specifies how to select the advertise address
+	// if it has not been specified.
+	// Overrides the policy from the application manifest
+	AdvertisePolicy utils.AdvertiseAddrPolicy
 	// Token is install token
 	Token string
 	// CloudProvider is optional cloud provider
@@ -130,6 +137,25 @@ type InstallConfig struct {
 	LocalBackend storage.Backend
 	// GCENodeTags defines the VM instance tags on GCE
 	GCENodeTags []string
+	// SELinux specifies whether to configure SELinux on the nodes
+	SELinux bool
+	// DefaultDenyNetworkPolicy specifies whether to deploy the baseline network policies
+	DefaultDenyNetworkPolicy bool
+	// RegistryDir specifies the optional pre-seeded docker registry directory
+	// to populate the cluster registry from instead of exporting the images
+	RegistryDir string
+	// CNI specifies the network plugin to install the cluster with.
+	// Defaults to the plugin configured in the application manifest
+	CNI string
+	// NodeLabels specifies additional Kubernetes labels for this node
+	NodeLabels map[string]string
+	// NodeTaints specifies additional Kubernetes taints for this node
+	// in key=value:effect format
+	NodeTaints []string
+	// With lists the optional application components to include
+	With []string
+	// Without lists the optional application components to exclude
+	Without []string
 	// LocalClusterClient is a factory for creating client to the installed cluster
 	LocalClusterClient func() (*opsclient.Client, error)
 	// Mode specifies the installer mode
@@ -155,9 +181,20 @@ type InstallConfig struct {
 	ServiceUser *systeminfo.User
 	// FromService specifies whether the process runs in service mode
 	FromService bool
+	// CACertPath is the path to the certificate of the certificate authority
+	// to issue the cluster certificates with, optionally followed by
+	// the chain of its issuers
+	CACertPath string
+	// CAKeyPath is the path to the private key of the certificate authority
+	CAKeyPath string
 	// writeStateDir is the directory where installer stores state for the duration
 	// of the operation
 	writeStateDir string
+	// nodeVars specifies the agent runtime parameters with additional
+	// Kubernetes labels and taints for this node
+	nodeVars map[string]string
+	// certAuthority is the certificate authority read from CACertPath and CAKeyPath
+	certAuthority *utils.CertAuthority
 }
 
 // NewInstallConfig creates install config from the passed CLI args and flags
@@ -174,6 +211,11 @@ func NewInstallConfig(env *localenv.LocalEnvironment, g *Application) InstallCon
 		UserLogFile:   *g.UserLogFile,
 		SystemLogFile: *g.SystemLogFile,
 		AdvertiseAddr: *g.InstallCmd.AdvertiseAddr,
+		AdvertisePolicy: utils.AdvertiseAddrPolicy{
+			CIDR:         *g.InstallCmd.AdvertiseCIDR,
+			Interface:    *g.InstallCmd.AdvertiseInterface,
+			DefaultRoute: *g.InstallCmd.AdvertiseDefaultRoute,
+		},
 		Token:         *g.InstallCmd.Token,
 		CloudProvider: *g.InstallCmd.CloudProvider,
 		SiteDomain:    *g.InstallCmd.Cluster,
@@ -188,23 +230,33 @@ func NewInstallConfig(env *localenv.LocalEnvironment, g *Application) InstallCon
 			StorageDriver: g.InstallCmd.DockerStorageDriver.value,
 			Args:          *g.InstallCmd.DockerArgs,
 		},
-		DNSConfig:          g.InstallCmd.DNSConfig(),
-		GCENodeTags:        *g.InstallCmd.GCENodeTags,
-		LocalPackages:      env.Packages,
-		LocalApps:          env.Apps,
-		LocalBackend:       env.Backend,
-		LocalClusterClient: env.SiteOperator,
-		Mode:               mode,
-		ServiceUID:         *g.InstallCmd.ServiceUID,
-		ServiceGID:         *g.InstallCmd.ServiceGID,
-		AppPackage:         *g.InstallCmd.App,
-		ResourcesPath:      *g.InstallCmd.ResourcesPath,
-		DNSHosts:           *g.InstallCmd.DNSHosts,
-		DNSZones:           *g.InstallCmd.DNSZones,
-		Flavor:             *g.InstallCmd.Flavor,
-		Remote:             *g.InstallCmd.Remote,
-		FromService:        *g.InstallCmd.FromService,
-		Printer:            env,
+		DNSConfig:                g.InstallCmd.DNSConfig(),
+		GCENodeTags:              *g.InstallCmd.GCENodeTags,
+		SELinux:                  *g.InstallCmd.SELinux,
+		NodeLabels:               *g.InstallCmd.NodeLabels,
+		NodeTaints:               *g.InstallCmd.NodeTaints,
+		With:                     *g.InstallCmd.With,
+		Without:                  *g.InstallCmd.Without,
+		LocalPackages:            env.Packages,
+		LocalApps:                env.Apps,
+		LocalBackend:             env.Backend,
+		LocalClusterClient:       env.SiteOperator,
+		Mode:                     mode,
+		ServiceUID:               *g.InstallCmd.ServiceUID,
+		ServiceGID:               *g.InstallCmd.ServiceGID,
+		AppPackage:               *g.InstallCmd.App,
+		ResourcesPath:            *g.InstallCmd.ResourcesPath,
+		DNSHosts:                 *g.InstallCmd.DNSHosts,
+		DNSZones:                 *g.InstallCmd.DNSZones,
+		Flavor:                   *g.InstallCmd.Flavor,
+		Remote:                   *g.InstallCmd.Remote,
+		FromService:              *g.InstallCmd.FromService,
+		Printer:                  env,
+		DefaultDenyNetworkPolicy: *g.InstallCmd.DefaultDenyNetworkPolicy,
+		RegistryDir:              *g.InstallCmd.RegistryDir,
+		CNI:                      *g.InstallCmd.CNI,
+		CACertPath:               *g.InstallCmd.CACert,
+		CAKeyPath:                *g.InstallCmd.CAKey,
 	}
 }
 
@@ -263,7 +315,11 @@ func (i *InstallConfig) CheckAndSetDefaults() (err error) {
 		return trace.Wrap(err)
 	}
 	if i.AdvertiseAddr == "" {
-		i.AdvertiseAddr, err = selectAdvertiseAddr()
+		policy, err := i.getAdvertiseAddrPolicy()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		i.AdvertiseAddr, err = selectAdvertiseAddr(policy)
 		if err != nil {
 			return trace.Wrap(err)
 		}
@@ -292,6 +348,45 @@ func (i *InstallConfig) CheckAndSetDefaults() (err error) {
 	if i.DNSConfig.IsEmpty() {
 		i.DNSConfig = storage.DefaultDNSConfig
 	}
+	i.nodeVars, err = newNodeVars(i.NodeLabels, i.NodeTaints)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := i.validateSELinux(); err != nil {
+		return trace.Wrap(err)
+	}
+	if i.RegistryDir != "" {
+		i.RegistryDir, err = filepath.Abs(i.RegistryDir)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if (i.CACertPath == "") != (i.CAKeyPath == "") {
+		return trace.BadParameter("both --ca-cert and --ca-key must be specified")
+	}
+	if i.CACertPath != "" {
+		i.certAuthority, err = utils.ReadCertAuthority(i.CACertPath, i.CAKeyPath)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// validateSELinux makes sure SELinux is enabled on this node if SELinux
+// support has been requested
+func (i *InstallConfig) validateSELinux() error {
+	mode, err := systeminfo.GetSELinuxMode()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if i.SELinux && !mode.IsEnabled() {
+		return trace.BadParameter("SELinux support requested but SELinux is disabled on this node")
+	}
+	if !i.SELinux && mode.IsEnforcing() {
+		i.Warn("SELinux is in enforcing mode on this node. " +
+			"Specify --selinux to configure SELinux policy for the cluster.")
+	}
 	return nil
 }
 
@@ -349,11 +444,27 @@ func (i *InstallConfig) NewInstallerConfig(
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	disabledComponents, err := app.Manifest.Components.Disabled(i.With, i.Without)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
 	if !i.Remote {
 		if err := i.validateCloudConfig(app.Manifest); err != nil {
 			return nil, trace.Wrap(err)
 		}
 	}
+	cni, err := app.Manifest.SelectCNI(i.CNI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if i.RegistryDir != "" {
+		i.Infof("Validating registry directory %v.", i.RegistryDir)
+		err := install.ValidateRegistryDir(context.TODO(), i.RegistryDir, *app,
+			disabledComponents, wizard.Packages)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
 	token, err := generateInstallToken(wizard.Operator, i.Token)
 	if err != nil && !trace.IsAlreadyExists(err) {
 		return nil, trace.Wrap(err)
@@ -365,42 +476,49 @@ func (i *InstallConfig) NewInstallerConfig(
 		i.SiteDomain = generateClusterName()
 	}
 	return &install.Config{
-		FieldLogger:        i.FieldLogger,
-		AdvertiseAddr:      i.AdvertiseAddr,
-		LocalPackages:      i.LocalPackages,
-		LocalApps:          i.LocalApps,
-		LocalBackend:       i.LocalBackend,
-		Printer:            i.Printer,
-		SiteDomain:         i.SiteDomain,
-		StateDir:           i.StateDir,
-		WriteStateDir:      i.writeStateDir,
-		UserLogFile:        i.UserLogFile,
-		SystemLogFile:      i.SystemLogFile,
-		CloudProvider:      i.CloudProvider,
-		GCENodeTags:        i.GCENodeTags,
-		SystemDevice:       i.SystemDevice,
-		DockerDevice:       i.DockerDevice,
-		Mounts:             i.Mounts,
-		DNSConfig:          i.DNSConfig,
-		PodCIDR:            i.PodCIDR,
-		ServiceCIDR:        i.ServiceCIDR,
-		VxlanPort:          i.VxlanPort,
-		Docker:             i.Docker,
-		Insecure:           i.Insecure,
-		LocalClusterClient: i.LocalClusterClient,
-		Role:               i.Role,
-		ServiceUser:        *i.ServiceUser,
-		Token:              *token,
-		App:                app,
-		Flavor:             flavor,
-		DNSOverrides:       *dnsOverrides,
-		RuntimeResources:   kubernetesResources,
-		ClusterResources:   gravityResources,
-		Process:            process,
-		Apps:               wizard.Apps,
-		Packages:           wizard.Packages,
-		Operator:           wizard.Operator,
-		LocalAgent:         !i.Remote,
+		FieldLogger:              i.FieldLogger,
+		AdvertiseAddr:            i.AdvertiseAddr,
+		LocalPackages:            i.LocalPackages,
+		LocalApps:                i.LocalApps,
+		LocalBackend:             i.LocalBackend,
+		Printer:                  i.Printer,
+		SiteDomain:               i.SiteDomain,
+		StateDir:                 i.StateDir,
+		WriteStateDir:            i.writeStateDir,
+		UserLogFile:              i.UserLogFile,
+		SystemLogFile:            i.SystemLogFile,
+		CloudProvider:            i.CloudProvider,
+		GCENodeTags:              i.GCENodeTags,
+		SELinux:                  i.SELinux,
+		SystemDevice:             i.SystemDevice,
+		DockerDevice:             i.DockerDevice,
+		Mounts:                   i.Mounts,
+		DNSConfig:                i.DNSConfig,
+		PodCIDR:                  i.PodCIDR,
+		ServiceCIDR:              i.ServiceCIDR,
+		VxlanPort:                i.VxlanPort,
+		Docker:                   i.Docker,
+		Insecure:                 i.Insecure,
+		LocalClusterClient:       i.LocalClusterClient,
+		Role:                     i.Role,
+		ServiceUser:              *i.ServiceUser,
+		Token:                    *token,
+		App:                      app,
+		Flavor:                   flavor,
+		DNSOverrides:             *dnsOverrides,
+		RuntimeResources:         kubernetesResources,
+		ClusterResources:         gravityResources,
+		Process:                  process,
+		Apps:                     wizard.Apps,
+		Packages:                 wizard.Packages,
+		Operator:                 wizard.Operator,
+		LocalAgent:               !i.Remote,
+		DisabledComponents:       disabledComponents,
+		NodeVars:                 i.nodeVars,
+		DefaultDenyNetworkPolicy: i.DefaultDenyNetworkPolicy,
+		RegistryDir:              i.RegistryDir,
+		CNI:                      cni,
+		CertAuthority:            i.certAuthority,
 	}, nil
 
 }
@@ -410,21 +528,22 @@ func (i *InstallConfig) validateApplicationDir() error {
 	return trace.Wrap(err)
 }
 
-// getAdvertiseAddr returns the advertise address to use for the ???
-// asks the user to choose it among the host's interfaces
-func (i *InstallConfig) getAdvertiseAddr() (string, error) {
-	// if it was set explicitly with --advertise-addr flag, use it
-	if i.AdvertiseAddr != "" {
-		return i.AdvertiseAddr, nil
+// getAdvertiseAddrPolicy returns the policy to select the advertise address with.
+// The policy specified on the command line takes precedence over the one
+// configured for the node profile in the application manifest
+func (i *InstallConfig) getAdvertiseAddrPolicy() (utils.AdvertiseAddrPolicy, error) {
+	if !i.AdvertisePolicy.IsEmpty() {
+		return i.AdvertisePolicy, nil
 	}
-	// otherwise, try to pick an address among machine's interfaces
-	addr, err := utils.PickAdvertiseIP()
+	app, err := i.getApp()
 	if err != nil {
-		return "", trace.Wrap(err, "could not pick advertise address among "+
-			"the host's network interfaces, please set the advertise address "+
-			"via --advertise-addr flag")
+		return utils.AdvertiseAddrPolicy{}, trace.Wrap(err)
 	}
-	return addr, nil
+	if policy := app.Manifest.AdvertiseAddrPolicy(i.Role); policy != nil {
+		i.WithField("policy", policy).Info("Using advertise address policy from manifest.")
+		return *policy, nil
+	}
+	return utils.AdvertiseAddrPolicy{}, nil
 }
 
 // getApp returns the application package for this installer
@@ -632,6 +751,9 @@ type JoinConfig struct {
 	UserLogFile string
 	// AdvertiseAddr is the advertise IP for the joining node
 	AdvertiseAddr string
+	// AdvertisePolicy specifies how to select the advertise address
+	// if it has not been specified
+	AdvertisePolicy utils.AdvertiseAddrPolicy
 	// ServerAddr is the installer RPC server address as host:port
 	ServerAddr string
 	// PeerAddrs is the list of peers to try connecting to
@@ -646,6 +768,11 @@ type JoinConfig struct {
 	DockerDevice string
 	// Mounts is a list of additional mounts
 	Mounts map[string]string
+	// NodeLabels specifies additional Kubernetes labels for this node
+	NodeLabels map[string]string
+	// NodeTaints specifies additional Kubernetes taints for this node
+	// in key=value:effect format
+	NodeTaints []string
 	// CloudProvider is the node cloud provider
 	CloudProvider string
 	// Manual turns on manual plan execution mode
@@ -656,6 +783,15 @@ type JoinConfig struct {
 	OperationID string
 	// FromService specifies whether the process runs in service mode
 	FromService bool
+	// Flags lists the command line flags the node is joined with.
+	// They are recorded with the node for reference
+	Flags []string
+	// LocalApps is the machine-local apps service used to look up
+	// the advertise address policy of the application being joined
+	LocalApps appservice.Applications
+	// nodeVars specifies the agent runtime parameters with additional
+	// Kubernetes labels and taints for this node
+	nodeVars map[string]string
 }
 
 // NewJoinConfig populates join configuration from the provided CLI application
@@ -665,21 +801,33 @@ func NewJoinConfig(g *Application) JoinConfig {
 		UserLogFile:   *g.UserLogFile,
 		PeerAddrs:     *g.JoinCmd.PeerAddr,
 		AdvertiseAddr: *g.JoinCmd.AdvertiseAddr,
-		ServerAddr:    *g.JoinCmd.ServerAddr,
-		Token:         *g.JoinCmd.Token,
-		Role:          *g.JoinCmd.Role,
-		SystemDevice:  *g.JoinCmd.SystemDevice,
-		DockerDevice:  *g.JoinCmd.DockerDevice,
-		Mounts:        *g.JoinCmd.Mounts,
-		OperationID:   *g.JoinCmd.OperationID,
-		FromService:   *g.JoinCmd.FromService,
+		AdvertisePolicy: utils.AdvertiseAddrPolicy{
+			CIDR:         *g.JoinCmd.AdvertiseCIDR,
+			Interface:    *g.JoinCmd.AdvertiseInterface,
+			DefaultRoute: *g.JoinCmd.AdvertiseDefaultRoute,
+		},
+		ServerAddr:   *g.JoinCmd.ServerAddr,
+		Token:        *g.JoinCmd.Token,
+		Role:         *g.JoinCmd.Role,
+		SystemDevice: *g.JoinCmd.SystemDevice,
+		DockerDevice: *g.JoinCmd.DockerDevice,
+		Mounts:       *g.JoinCmd.Mounts,
+		NodeLabels:   *g.JoinCmd.NodeLabels,
+		NodeTaints:   *g.JoinCmd.NodeTaints,
+		OperationID:  *g.JoinCmd.OperationID,
+		FromService:  *g.JoinCmd.FromService,
+		Flags:        redactArgs(os.Args[1:]),
 	}
 }
 
 // CheckAndSetDefaults validates the configuration and sets default values
 func (j *JoinConfig) CheckAndSetDefaults() (err error) {
 	if j.AdvertiseAddr == "" {
-		j.AdvertiseAddr, err = selectAdvertiseAddr()
+		policy, err := j.getAdvertiseAddrPolicy()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		j.AdvertiseAddr, err = selectAdvertiseAddr(policy)
 		if err != nil {
 			return trace.Wrap(err)
 		}
@@ -687,9 +835,42 @@ func (j *JoinConfig) CheckAndSetDefaults() (err error) {
 	if err := checkLocalAddr(j.AdvertiseAddr); err != nil {
 		return trace.Wrap(err)
 	}
+	j.nodeVars, err = newNodeVars(j.NodeLabels, j.NodeTaints)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if len(j.Flags) != 0 {
+		flags, err := json.Marshal(j.Flags)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		j.nodeVars[ops.AgentJoinFlags] = string(flags)
+	}
 	return nil
 }
 
+// getAdvertiseAddrPolicy returns the policy to select the advertise address with.
+// The policy specified on the command line takes precedence over the one
+// configured for the node profile in the application manifest.
+// The manifest is only available when joining from the installer tarball
+func (j *JoinConfig) getAdvertiseAddrPolicy() (utils.AdvertiseAddrPolicy, error) {
+	if !j.AdvertisePolicy.IsEmpty() || j.LocalApps == nil {
+		return j.AdvertisePolicy, nil
+	}
+	app, err := install.GetApp(j.LocalApps)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return j.AdvertisePolicy, nil
+		}
+		return utils.AdvertiseAddrPolicy{}, trace.Wrap(err)
+	}
+	if policy := app.Manifest.AdvertiseAddrPolicy(j.Role); policy != nil {
+		log.WithField("policy", policy).Info("Using advertise address policy from manifest.")
+		return *policy, nil
+	}
+	return j.AdvertisePolicy, nil
+}
+
 // NewPeerConfig converts the CLI join configuration to peer configuration
 func (j *JoinConfig) NewPeerConfig(env, joinEnv *localenv.LocalEnvironment) (config *expand.PeerConfig, err error) {
 	peers, err := j.GetPeers()
@@ -733,7 +914,27 @@ func (j *JoinConfig) GetRuntimeConfig() proto.RuntimeConfig {
 		SystemDevice: j.SystemDevice,
 		DockerDevice: j.DockerDevice,
 		Mounts:       convertMounts(j.Mounts),
+		KeyValues:    j.nodeVars,
+	}
+}
+
+// newNodeVars validates the specified Kubernetes node labels and taints
+// and returns them as agent runtime parameters
+func newNodeVars(labels map[string]string, taintSpecs []string) (map[string]string, error) {
+	for key, value := range labels {
+		if err := ops.CheckNodeLabel(key, value); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	var taints []v1.Taint
+	if len(taintSpecs) != 0 {
+		var err error
+		taints, err = ops.ParseNodeTaints(taintSpecs)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
 	}
+	return ops.NodeVars(labels, taints), nil
 }
 
 func (r *removeConfig) checkAndSetDefaults() error {
@@ -747,6 +948,11 @@ type removeConfig struct {
 	server    string
 	force     bool
 	confirmed bool
+	// dryRun reports the impact of the removal without removing the node
+	dryRun bool
+	// skipCapacityCheck allows to remove the node even if the remaining
+	// nodes cannot host the evicted pods
+	skipCapacityCheck bool
 }
 
 func (r *autojoinConfig) newJoinConfig() JoinConfig {
@@ -761,6 +967,7 @@ func (r *autojoinConfig) newJoinConfig() JoinConfig {
 		PeerAddrs:     r.serviceURL,
 		Token:         r.token,
 		FromService:   r.fromService,
+		Flags:         redactArgs(os.Args[1:]),
 	}
 }
 
@@ -828,7 +1035,10 @@ func (r *agentConfig) checkAndSetDefaults() (err error) {
 		return trace.BadParameter("package service address is required")
 	}
 	if r.advertiseAddr == "" {
-		return trace.BadParameter("advertise address is required")
+		r.advertiseAddr, err = selectAdvertiseAddr(r.advertisePolicy)
+		if err != nil {
+			return trace.Wrap(err)
+		}
 	}
 	if r.serverAddr == "" {
 		return trace.BadParameter("server address is required")
@@ -846,24 +1056,37 @@ func (r *agentConfig) checkAndSetDefaults() (err error) {
 }
 
 type agentConfig struct {
-	systemLogFile string
-	serviceName   string
-	userLogFile   string
-	advertiseAddr string
-	serverAddr    string
-	packageAddr   string
-	token         string
-	vars          configure.KeyVal
-	serviceUID    string
-	serviceGID    string
-	cloudProvider string
+	systemLogFile   string
+	serviceName     string
+	userLogFile     string
+	advertiseAddr   string
+	advertisePolicy utils.AdvertiseAddrPolicy
+	serverAddr      string
+	packageAddr     string
+	token           string
+	vars            configure.KeyVal
+	serviceUID      string
+	serviceGID      string
+	cloudProvider   string
+}
+
+// advertiseIP returns the specified advertise IP as a string
+// or an empty string if the IP has not been specified
+func advertiseIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
 }
 
 func updateJoinConfigFromCloudMetadata(ctx context.Context, config *autojoinConfig) error {
+	if gcemeta.OnGCE() {
+		return updateJoinConfigFromGCEMetadata(ctx, config)
+	}
 	instance, err := cloudaws.NewLocalInstance()
 	if err != nil {
 		log.WithError(err).Warn("Failed to fetch instance metadata on AWS.")
-		return trace.BadParameter("autojoin only supports AWS")
+		return trace.BadParameter("autojoin only supports AWS and GCE")
 	}
 
 	autoscaler, err := autoscaleaws.New(autoscaleaws.Config{
@@ -889,6 +1112,46 @@ func updateJoinConfigFromCloudMetadata(ctx context.Context, config *autojoinConf
 	return nil
 }
 
+// updateJoinConfigFromGCEMetadata discovers the cluster to join via the labels
+// of the managed instance group this instance belongs to and fetches
+// the join token and the cluster service URL from the Secret Manager
+func updateJoinConfigFromGCEMetadata(ctx context.Context, config *autojoinConfig) error {
+	autoscaler, err := autoscalegce.New(autoscalegce.Config{
+		ClusterName: config.clusterName,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	group, err := autoscaler.DiscoverInstanceGroup(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	joinToken, err := autoscaler.GetJoinToken(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	serviceURL, err := autoscaler.GetServiceURL(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	advertiseAddr, err := autoscaler.Metadata.InternalIP()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if config.role == "" {
+		config.role = group.Role()
+	}
+	config.token = joinToken
+	config.serviceURL = serviceURL
+	config.advertiseAddr = advertiseAddr
+	return nil
+}
+
 func convertMounts(mounts map[string]string) (result []*proto.Mount) {
 	result = make([]*proto.Mount, 0, len(mounts))
 	for name, source := range mounts {
@@ -1111,9 +1374,9 @@ func checkLocalAddr(addr string) error {
 }
 
 // selectAdvertiseAddr selects an advertise address from one of the host's interfaces
-func selectAdvertiseAddr() (string, error) {
-	// otherwise, try to pick an address among machine's interfaces
-	addr, err := utils.PickAdvertiseIP()
+// using the specified policy
+func selectAdvertiseAddr(policy utils.AdvertiseAddrPolicy) (string, error) {
+	addr, err := utils.PickAdvertiseIPWithPolicy(policy)
 	if err != nil {
 		return "", trace.Wrap(err, "could not pick advertise address among "+
 			"the host's network interfaces, please set the advertise address "+
@@ -0,0 +1,222 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/gravitational/trace"
+)
+
+// listDevices outputs block devices of this node along with whether
+// they pass the specified device filter
+func listDevices(filter systeminfo.DeviceFilter, format constants.Format, w io.Writer) error {
+	devices, err := systeminfo.GetBlockDevices(filter)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(devices, w))
+	case constants.EncodingText:
+		printDevices(devices, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+// newDeviceFilter returns the default device filter extended with the specified
+// include/exclude lists
+func newDeviceFilter(cmd SystemDevicesListCmd) systeminfo.DeviceFilter {
+	return systeminfo.DeviceFilter{
+		IncludeVendors: *cmd.IncludeVendors,
+		ExcludeVendors: *cmd.ExcludeVendors,
+		IncludePaths:   *cmd.IncludePaths,
+		ExcludePaths:   *cmd.ExcludePaths,
+	}.WithDefaults()
+}
+
+// getStorageCapacity outputs the block storage capacity available for
+// persistent volumes on cluster nodes
+func getStorageCapacity(env *localenv.LocalEnvironment, kind string, format constants.Format, w io.Writer) error {
+	if storage.CanonicalKind(kind) != storage.KindPersistentStorage {
+		return trace.BadParameter("--capacity only applies to %v", storage.KindPersistentStorage)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	capacity, err := operator.GetStorageCapacity(ops.StorageCapacityRequest{
+		AccountID:  cluster.AccountID,
+		SiteDomain: cluster.Domain,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(capacity, w))
+	case constants.EncodingText:
+		printStorageCapacity(*capacity, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+func printStorageCapacity(capacity ops.StorageCapacity, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Node", "Advertise IP", "Devices", "Capacity"})
+	for _, node := range capacity.Nodes {
+		size := humanize.IBytes(node.TotalBytes)
+		if node.Error != "" {
+			size = fmt.Sprintf("unknown (%v)", node.Error)
+		}
+		var devices []string
+		for _, device := range node.Devices {
+			devices = append(devices, device.Path)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n",
+			node.Hostname,
+			node.AdvertiseIP,
+			formatDeviceValue(strings.Join(devices, ", ")),
+			size)
+	}
+	fmt.Fprintf(w, "Total:\t\t\t%v\n", humanize.IBytes(capacity.TotalBytes))
+	w.Flush()
+	if len(capacity.StorageClasses) != 0 {
+		fmt.Fprintln(out)
+		w.Init(out, 0, 8, 1, '\t', 0)
+		common.PrintTableHeader(w, []string{"Storage Class", "Provisioner", "Requested"})
+		for _, class := range capacity.StorageClasses {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", class.Name, class.Provisioner,
+				humanize.IBytes(class.RequestedBytes))
+		}
+		w.Flush()
+	}
+	for _, warning := range capacity.Warnings {
+		fmt.Fprintf(out, "%v %v.\n", color.YellowString("WARNING:"), warning)
+	}
+}
+
+// getStorageUsage outputs the persistent volume capacity and usage
+// of the application namespaces along with the storage quotas
+func getStorageUsage(env *localenv.LocalEnvironment, format constants.Format, w io.Writer) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	usage, err := operator.GetStorageUsage(cluster.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(usage, w))
+	case constants.EncodingText:
+		printStorageUsage(*usage, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+func printStorageUsage(usage ops.StorageUsage, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Namespace", "Storage Class", "Claims", "Capacity", "Used"})
+	for _, namespace := range usage.Namespaces {
+		used := humanize.IBytes(namespace.UsedBytes)
+		if namespace.Unreported != 0 {
+			used = fmt.Sprintf("%v (%v of %v claims not reported)", used,
+				namespace.Unreported, namespace.Claims)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n",
+			namespace.Namespace,
+			formatDeviceValue(namespace.StorageClass),
+			namespace.Claims,
+			humanize.IBytes(namespace.CapacityBytes),
+			used)
+	}
+	w.Flush()
+	if len(usage.Quotas) != 0 {
+		fmt.Fprintln(out)
+		w.Init(out, 0, 8, 1, '\t', 0)
+		common.PrintTableHeader(w, []string{"Quota Namespace", "Storage Class", "Limit", "Used", "Warning At"})
+		for _, quota := range usage.Quotas {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v (%v%%)\t%v%%\n",
+				quota.Namespace,
+				formatDeviceValue(quota.StorageClass),
+				humanize.IBytes(quota.LimitBytes),
+				humanize.IBytes(quota.UsedBytes),
+				quota.Percent(),
+				quota.WarningThreshold)
+		}
+		w.Flush()
+	}
+	for _, warning := range usage.Warnings {
+		fmt.Fprintf(out, "%v %v.\n", color.YellowString("WARNING:"), warning)
+	}
+}
+
+func printDevices(devices []systeminfo.BlockDevice, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Device", "Type", "Size", "Vendor", "Model", "Filesystem", "Mount Points", "Included"})
+	for _, device := range devices {
+		included := "yes"
+		if !device.Included {
+			included = fmt.Sprintf("no (%v)", device.Reason)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			device.Path,
+			device.Type,
+			humanize.IBytes(device.SizeBytes),
+			formatDeviceValue(device.Vendor),
+			formatDeviceValue(device.Model),
+			formatDeviceValue(device.Filesystem),
+			formatDeviceValue(strings.Join(device.MountPoints, ", ")),
+			included)
+	}
+	w.Flush()
+}
+
+func formatDeviceValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
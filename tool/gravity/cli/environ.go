@@ -36,6 +36,8 @@ func updateEnviron(
 	localEnv, updateEnv *localenv.LocalEnvironment,
 	env storage.EnvironmentVariables,
 	manual, confirmed bool,
+	skipNodes []string,
+	revision string,
 ) error {
 	if !confirmed {
 		if manual {
@@ -52,7 +54,7 @@ func updateEnviron(
 			return nil
 		}
 	}
-	updater, err := newEnvironUpdater(ctx, localEnv, updateEnv, env)
+	updater, err := newEnvironUpdater(ctx, localEnv, updateEnv, env, skipNodes, revision)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -65,9 +67,11 @@ func updateEnviron(
 	return nil
 }
 
-func newEnvironUpdater(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, environ storage.EnvironmentVariables) (*update.Updater, error) {
+func newEnvironUpdater(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, environ storage.EnvironmentVariables, skipNodes []string, revision string) (*update.Updater, error) {
 	init := environInitializer{
-		environ: environ,
+		environ:   environ,
+		skipNodes: skipNodes,
+		revision:  revision,
 	}
 	return newUpdater(ctx, localEnv, updateEnv, init)
 }
@@ -176,6 +180,7 @@ func (r environInitializer) newOperation(operator ops.Operator, cluster ops.Site
 		ops.CreateUpdateEnvarsOperationRequest{
 			ClusterKey: cluster.Key(),
 			Env:        r.environ.GetKeyValues(),
+			Revision:   r.revision,
 		},
 	)
 	if err != nil {
@@ -189,7 +194,7 @@ func (r environInitializer) newOperation(operator ops.Operator, cluster ops.Site
 	return key, nil
 }
 
-func (environInitializer) newOperationPlan(
+func (r environInitializer) newOperationPlan(
 	ctx context.Context,
 	operator ops.Operator,
 	cluster ops.Site,
@@ -198,7 +203,7 @@ func (environInitializer) newOperationPlan(
 	clusterEnv *localenv.ClusterEnvironment,
 	leader *storage.Server,
 ) (*storage.OperationPlan, error) {
-	plan, err := environ.NewOperationPlan(operator, clusterEnv.Apps, operation, cluster.ClusterState.Servers)
+	plan, err := environ.NewOperationPlan(operator, clusterEnv.Apps, operation, cluster.ClusterState.Servers, r.skipNodes)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -240,6 +245,11 @@ func (environInitializer) updateDeployRequest(req deployAgentsRequest) deployAge
 
 type environInitializer struct {
 	environ storage.EnvironmentVariables
+	// skipNodes lists nodes to exclude from the operation
+	skipNodes []string
+	// revision optionally specifies the revision of the environment
+	// the update is based on
+	revision string
 }
 
 const (
@@ -0,0 +1,293 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	rpcclient "github.com/gravitational/gravity/lib/rpc/client"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// clusterExecConfig configures execution of a host command on multiple cluster nodes
+type clusterExecConfig struct {
+	// all selects all cluster nodes
+	all bool
+	// role selects the cluster nodes with the specified role
+	role string
+	// selector selects the cluster nodes with labels matching the label selector
+	selector string
+	// parallel is the maximum number of nodes to run the command on concurrently
+	parallel int
+	// timeout is the command execution timeout on each node
+	timeout time.Duration
+	// command is the command to execute. Each argument is a template
+	// rendered with the node the command runs on
+	command []string
+	// format is the output format of the results
+	format constants.Format
+}
+
+// clusterExec executes a host command on the selected cluster nodes using
+// the deployed RPC agents and outputs the aggregated results.
+// Returns an error with the highest exit code if the command has failed on any node
+func clusterExec(env *localenv.LocalEnvironment, config clusterExecConfig) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	selector, err := ops.ParseLabelSelector(config.selector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	servers, err := selectExecServers(cluster.ClusterState.Servers, config.all, config.role, selector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	creds, err := libfsm.GetClientCredentials()
+	if err != nil {
+		return trace.Wrap(err, "failed to load RPC agent credentials. "+
+			"Make sure the agents are running with: sudo gravity agent deploy")
+	}
+	agents := libfsm.NewAgentRunner(creds)
+	run := func(ctx context.Context, server storage.Server, w io.Writer, args []string) error {
+		agent, err := agents.GetClient(ctx, server.AdvertiseIP)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		logger := logrus.WithField("server", server.AdvertiseIP)
+		return trace.Wrap(agent.Command(ctx, logger, w, args...))
+	}
+	results := runOnServers(context.TODO(), servers, config, run)
+	if err := outputExecResults(results, config.format, os.Stdout); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(execResultsError(results))
+}
+
+// selectExecServers returns the servers to execute the command on:
+// either all servers, or the servers with the specified role.
+// The role matches either the node profile or the cluster role.
+// The servers are further narrowed down to those with labels matching selector
+func selectExecServers(servers []storage.Server, all bool, role string, selector labels.Selector) (result []storage.Server, err error) {
+	if all && role != "" {
+		return nil, trace.BadParameter("--all and --role are mutually exclusive")
+	}
+	for _, server := range servers {
+		if role != "" && server.Role != role && server.ClusterRole != role {
+			continue
+		}
+		if !ops.MatchLabels(selector, server.Labels) {
+			continue
+		}
+		result = append(result, server)
+	}
+	if len(result) == 0 {
+		if role != "" {
+			return nil, trace.NotFound("no cluster nodes with role %q matching selector %q", role, selector)
+		}
+		return nil, trace.NotFound("no cluster nodes matching selector %q", selector)
+	}
+	return result, nil
+}
+
+// renderExecCommand renders the command arguments as templates
+// with the specified server.
+// For example, {{.AdvertiseIP}} is replaced with the server's advertise IP
+func renderExecCommand(command []string, server storage.Server) (args []string, err error) {
+	for _, arg := range command {
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, trace.BadParameter("invalid command template %q: %v", arg, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, server); err != nil {
+			return nil, trace.BadParameter("failed to render command template %q: %v", arg, err)
+		}
+		args = append(args, buf.String())
+	}
+	return args, nil
+}
+
+// execRunner executes the command specified with args on the given server
+// writing its output into w
+type execRunner func(ctx context.Context, server storage.Server, w io.Writer, args []string) error
+
+// runOnServers executes the configured command on the specified servers
+// running at most config.parallel commands at a time.
+// Results are returned in the order of servers
+func runOnServers(ctx context.Context, servers []storage.Server, config clusterExecConfig, run execRunner) []execResult {
+	parallel := config.parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	results := make([]execResult, len(servers))
+	// this semaphore limits the number of commands running concurrently
+	semaphoreCh := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		semaphoreCh <- struct{}{}
+		go func(i int, server storage.Server) {
+			defer func() {
+				<-semaphoreCh
+				wg.Done()
+			}()
+			results[i] = runOnServer(ctx, server, config, run)
+		}(i, server)
+	}
+	wg.Wait()
+	return results
+}
+
+func runOnServer(ctx context.Context, server storage.Server, config clusterExecConfig, run execRunner) execResult {
+	result := execResult{
+		Hostname:    server.Hostname,
+		AdvertiseIP: server.AdvertiseIP,
+	}
+	args, err := renderExecCommand(config.command, server)
+	if err != nil {
+		result.setError(err)
+		return result
+	}
+	if config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.timeout)
+		defer cancel()
+	}
+	var out bytes.Buffer
+	start := time.Now()
+	err = run(ctx, server, &out, args)
+	result.Duration = time.Since(start)
+	result.Output = out.String()
+	if err != nil {
+		result.setError(err)
+	}
+	return result
+}
+
+// execResult is the result of executing a command on a cluster node
+type execResult struct {
+	// Hostname is the node hostname
+	Hostname string `json:"hostname"`
+	// AdvertiseIP is the node advertise IP
+	AdvertiseIP string `json:"advertise_ip"`
+	// ExitCode is the command exit code.
+	// It is set to 1 if the command has failed with an unknown exit code
+	ExitCode int `json:"exit_code"`
+	// Output is the combined command output
+	Output string `json:"output"`
+	// Error is the error message if the command has failed
+	Error string `json:"error,omitempty"`
+	// Duration is the command execution time
+	Duration time.Duration `json:"duration"`
+}
+
+func (r *execResult) setError(err error) {
+	r.Error = trace.UserMessage(err)
+	r.ExitCode = 1
+	if exitCode, ok := rpcclient.ExitCode(err); ok {
+		r.ExitCode = exitCode
+	}
+}
+
+// execResultsError returns an error with the highest exit code
+// among the results or nil if the command has succeeded everywhere
+func execResultsError(results []execResult) error {
+	var exitCode int
+	var failed []string
+	for _, result := range results {
+		if result.ExitCode == 0 {
+			continue
+		}
+		failed = append(failed, result.Hostname)
+		if result.ExitCode > exitCode {
+			exitCode = result.ExitCode
+		}
+	}
+	if exitCode == 0 {
+		return nil
+	}
+	return utils.NewExitCodeErrorWithMessage(exitCode,
+		fmt.Sprintf("command failed on %v of %v nodes: %v",
+			len(failed), len(results), strings.Join(failed, ", ")))
+}
+
+func outputExecResults(results []execResult, format constants.Format, w io.Writer) error {
+	switch format {
+	case constants.EncodingText:
+		printExecResults(results, w)
+		return nil
+	case constants.EncodingJSON:
+		bytes, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		_, err = fmt.Fprintln(w, string(bytes))
+		return trace.Wrap(err)
+	}
+	return trace.BadParameter("unknown output format %q, supported are: %v, %v",
+		format, constants.EncodingText, constants.EncodingJSON)
+}
+
+func printExecResults(results []execResult, w io.Writer) {
+	for _, result := range results {
+		fmt.Fprintf(w, "==> %v (%v) <==\n", result.Hostname, result.AdvertiseIP)
+		if result.Output != "" {
+			fmt.Fprint(w, result.Output)
+			if !strings.HasSuffix(result.Output, "\n") {
+				fmt.Fprintln(w)
+			}
+		}
+		if result.Error != "" {
+			fmt.Fprintf(w, "ERROR: %v\n", result.Error)
+		}
+		fmt.Fprintln(w)
+	}
+	var t tabwriter.Writer
+	t.Init(w, 0, 10, 2, ' ', 0)
+	common.PrintTableHeader(&t, []string{"Node", "Advertise IP", "Exit code", "Duration"})
+	for _, result := range results {
+		fmt.Fprintf(&t, "%v\t%v\t%v\t%v\n", result.Hostname, result.AdvertiseIP,
+			result.ExitCode, result.Duration.Round(time.Millisecond))
+	}
+	t.Flush()
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func (*S) TestSelectsExecServers(c *check.C) {
+	servers := []storage.Server{
+		{Hostname: "node-1", Role: "master", ClusterRole: "master", Labels: map[string]string{"zone": "a"}},
+		{Hostname: "node-2", Role: "worker", ClusterRole: "node", Labels: map[string]string{"zone": "a"}},
+		{Hostname: "node-3", Role: "worker", ClusterRole: "node", Labels: map[string]string{"zone": "b"}},
+	}
+	everything := labels.Everything()
+	result, err := selectExecServers(servers, true, "", everything)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 3)
+
+	result, err = selectExecServers(servers, false, "worker", everything)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, servers[1:])
+
+	result, err = selectExecServers(servers, false, "node", everything)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, servers[1:])
+
+	_, err = selectExecServers(servers, false, "db", everything)
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+
+	_, err = selectExecServers(servers, true, "worker", everything)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+
+	selector, err := ops.ParseLabelSelector("zone=a")
+	c.Assert(err, check.IsNil)
+	result, err = selectExecServers(servers, false, "", selector)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, servers[:2])
+
+	result, err = selectExecServers(servers, false, "worker", selector)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, servers[1:2])
+
+	selector, err = ops.ParseLabelSelector("zone=c")
+	c.Assert(err, check.IsNil)
+	_, err = selectExecServers(servers, true, "", selector)
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+}
+
+func (*S) TestSkipsUnselectedServers(c *check.C) {
+	servers := []storage.Server{
+		{Hostname: "node-1", AdvertiseIP: "10.0.0.1", Labels: map[string]string{"zone": "a"}},
+		{Hostname: "node-2", AdvertiseIP: "10.0.0.2", Labels: map[string]string{"zone": "b"}},
+		{Hostname: "node-3", AdvertiseIP: "10.0.0.3"},
+	}
+	selector, err := ops.ParseLabelSelector("zone=a")
+	c.Assert(err, check.IsNil)
+	skipped, err := unselectedServers(servers, nil, selector)
+	c.Assert(err, check.IsNil)
+	c.Assert(skipped, check.DeepEquals, []string{"10.0.0.2", "10.0.0.3"})
+
+	skipped, err = unselectedServers(servers, []string{"node-2"}, selector)
+	c.Assert(err, check.IsNil)
+	c.Assert(skipped, check.DeepEquals, []string{"node-2", "10.0.0.3"})
+
+	selector, err = ops.ParseLabelSelector("zone=c")
+	c.Assert(err, check.IsNil)
+	_, err = unselectedServers(servers, nil, selector)
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+}
+
+func (*S) TestRendersExecCommand(c *check.C) {
+	server := storage.Server{Hostname: "node-1", AdvertiseIP: "10.0.0.1"}
+	args, err := renderExecCommand([]string{"ping", "-c1", "{{.AdvertiseIP}}"}, server)
+	c.Assert(err, check.IsNil)
+	c.Assert(args, check.DeepEquals, []string{"ping", "-c1", "10.0.0.1"})
+
+	_, err = renderExecCommand([]string{"echo", "{{.Unknown}}"}, server)
+	c.Assert(trace.IsBadParameter(err), check.Equals, true)
+}
+
+func (*S) TestAggregatesExecResults(c *check.C) {
+	servers := []storage.Server{
+		{Hostname: "node-1", AdvertiseIP: "10.0.0.1"},
+		{Hostname: "node-2", AdvertiseIP: "10.0.0.2"},
+		{Hostname: "node-3", AdvertiseIP: "10.0.0.3"},
+	}
+	var mu sync.Mutex
+	var running, maxRunning int
+	run := func(ctx context.Context, server storage.Server, w io.Writer, args []string) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		fmt.Fprintln(w, strings.Join(args, " "))
+		switch server.Hostname {
+		case "node-2":
+			err := trace.Wrap(fmt.Errorf("exit status 2"))
+			err.AddField("exit_code", 2)
+			return err
+		case "node-3":
+			return trace.ConnectionProblem(nil, "no agent")
+		}
+		return nil
+	}
+	results := runOnServers(context.TODO(), servers, clusterExecConfig{
+		parallel: 2,
+		command:  []string{"echo", "{{.Hostname}}"},
+	}, run)
+	c.Assert(maxRunning <= 2, check.Equals, true)
+	c.Assert(results, check.HasLen, 3)
+	c.Assert(results[0].Output, check.Equals, "echo node-1\n")
+	c.Assert(results[0].ExitCode, check.Equals, 0)
+	c.Assert(results[1].Output, check.Equals, "echo node-2\n")
+	c.Assert(results[1].ExitCode, check.Equals, 2)
+	c.Assert(results[2].ExitCode, check.Equals, 1)
+	c.Assert(results[2].Error, check.Equals, "no agent")
+
+	err := execResultsError(results)
+	exitErr, ok := utils.AsExitCodeError(err)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(exitErr.ExitCode(), check.Equals, 2)
+	c.Assert(err.Error(), check.Equals, "command failed on 2 of 3 nodes: node-2, node-3")
+	c.Assert(execResultsError(results[:1]), check.IsNil)
+}
@@ -186,17 +186,18 @@ func newCollector(env *localenv.LocalEnvironment) (*vacuum.Collector, error) {
 			Locator:  cluster.App.Package,
 			Manifest: cluster.App.Manifest,
 		},
-		RemoteApps:    remoteApps,
-		Apps:          clusterApps,
-		Packages:      clusterPackages,
-		LocalPackages: env.Packages,
-		Operator:      operator,
-		Operation:     operation,
-		Servers:       cluster.ClusterState.Servers,
-		ClusterKey:    cluster.Key(),
-		RuntimePath:   runtimePath,
-		Silent:        env.Silent,
-		Runner:        runner,
+		RemoteApps:         remoteApps,
+		Apps:               clusterApps,
+		Packages:           clusterPackages,
+		LocalPackages:      env.Packages,
+		Operator:           operator,
+		Operation:          operation,
+		Servers:            cluster.ClusterState.Servers,
+		ClusterKey:         cluster.Key(),
+		RuntimePath:        runtimePath,
+		Silent:             env.Silent,
+		Runner:             runner,
+		DisabledComponents: cluster.DisabledComponents,
 	})
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -248,16 +249,17 @@ func getGarbageCollector(env *localenv.LocalEnvironment, operation *ops.SiteOper
 			Locator:  cluster.App.Package,
 			Manifest: cluster.App.Manifest,
 		},
-		Apps:          clusterApps,
-		Packages:      clusterPackages,
-		LocalPackages: env.Packages,
-		Operator:      operator,
-		Operation:     operation,
-		Servers:       cluster.ClusterState.Servers,
-		ClusterKey:    cluster.Key(),
-		RuntimePath:   runtimePath,
-		Silent:        env.Silent,
-		Runner:        runner,
+		Apps:               clusterApps,
+		Packages:           clusterPackages,
+		LocalPackages:      env.Packages,
+		Operator:           operator,
+		Operation:          operation,
+		Servers:            cluster.ClusterState.Servers,
+		ClusterKey:         cluster.Key(),
+		RuntimePath:        runtimePath,
+		Silent:             env.Silent,
+		Runner:             runner,
+		DisabledComponents: cluster.DisabledComponents,
 	})
 }
 
@@ -327,6 +329,7 @@ func removeUnusedImages(env *localenv.LocalEnvironment, dryRun, confirmed bool)
 			FieldLogger: logrus.WithField(trace.Component, "gc/registry"),
 			Silent:      env.Silent,
 		},
+		DisabledComponents: cluster.DisabledComponents,
 	}
 	pruner, err := registry.New(config)
 	if err != nil {
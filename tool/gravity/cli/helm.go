@@ -26,6 +26,7 @@ import (
 	"text/tabwriter"
 
 	"github.com/gravitational/gravity/lib/app"
+	"github.com/gravitational/gravity/lib/app/releases"
 	"github.com/gravitational/gravity/lib/catalog"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
@@ -35,9 +36,11 @@ import (
 	"github.com/gravitational/gravity/lib/ops/events"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
 	helmutils "github.com/gravitational/gravity/lib/utils/helm"
 
 	"github.com/ghodss/yaml"
+	teleevents "github.com/gravitational/teleport/lib/events"
 	"github.com/gravitational/trace"
 	"k8s.io/helm/pkg/repo"
 )
@@ -156,22 +159,40 @@ func releaseInstall(env *localenv.LocalEnvironment, conf releaseInstallConfig) e
 	env.PrintStep("Installing application %v:%v",
 		imageEnv.Manifest.Metadata.Name,
 		imageEnv.Manifest.Metadata.ResourceVersion)
-	tmp, err := ioutil.TempDir("", "")
+	helmClient, err := helm.NewClient(helm.ClientConfig{
+		DNSAddress: env.DNS.Addr(),
+	})
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	defer os.RemoveAll(tmp)
-	err = pack.Unpack(imageEnv.Packages, imageEnv.Manifest.Locator(), tmp, nil)
+	defer helmClient.Close()
+	if env.InGravity() {
+		lifecycle, err := newReleases(env, helmClient)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		record, err := lifecycle.Install(context.TODO(), releases.InstallRequest{
+			Application: imageEnv.Manifest.Locator(),
+			Name:        conf.Name,
+			Namespace:   conf.Namespace,
+			Values:      conf.Files,
+			Set:         conf.Values,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(emitRelease(env, helmClient, record.Name, events.ApplicationInstall,
+			"Installed release %v", record.Name))
+	}
+	tmp, err := ioutil.TempDir("", "")
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	helmClient, err := helm.NewClient(helm.ClientConfig{
-		DNSAddress: env.DNS.Addr(),
-	})
+	defer os.RemoveAll(tmp)
+	err = pack.Unpack(imageEnv.Packages, imageEnv.Manifest.Locator(), tmp, nil)
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	defer helmClient.Close()
 	release, err := helmClient.Install(helm.InstallParameters{
 		Path:      filepath.Join(tmp, "resources"),
 		Values:    conf.Files,
@@ -260,6 +281,24 @@ func releaseUpgrade(env *localenv.LocalEnvironment, conf releaseUpgradeConfig) e
 	env.PrintStep("Upgrading release %v (%v) to version %v",
 		release.GetName(), release.GetChart(),
 		imageEnv.Manifest.Metadata.ResourceVersion)
+	if env.InGravity() {
+		lifecycle, err := newReleases(env, helmClient)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		_, err = lifecycle.Upgrade(context.TODO(), releases.UpgradeRequest{
+			Release:     release.GetName(),
+			Application: imageEnv.Manifest.Locator(),
+			Values:      conf.Files,
+			Set:         conf.Values,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(emitRelease(env, helmClient, release.GetName(), events.ApplicationUpgrade,
+			"Upgraded release %v to version %v", release.GetName(),
+			imageEnv.Manifest.Metadata.ResourceVersion))
+	}
 	tmp, err := ioutil.TempDir("", "")
 	if err != nil {
 		return trace.Wrap(err)
@@ -292,6 +331,21 @@ func releaseRollback(env *localenv.LocalEnvironment, conf releaseRollbackConfig)
 		return trace.Wrap(err)
 	}
 	defer helmClient.Close()
+	if env.InGravity() {
+		lifecycle, err := newReleases(env, helmClient)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		record, err := lifecycle.Rollback(context.TODO(), releases.RollbackRequest{
+			Release:  conf.Release,
+			Revision: conf.Revision,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		return trace.Wrap(emitRelease(env, helmClient, record.Name, events.ApplicationRollback,
+			"Rolled back release %v to %v", record.Name, record.Application))
+	}
 	release, err := helmClient.Rollback(helm.RollbackParameters{
 		Release:  conf.Release,
 		Revision: conf.Revision,
@@ -312,9 +366,21 @@ func releaseUninstall(env *localenv.LocalEnvironment, conf releaseUninstallConfi
 		return trace.Wrap(err)
 	}
 	defer helmClient.Close()
-	release, err := helmClient.Uninstall(conf.Release)
-	if err != nil {
-		return trace.Wrap(err)
+	var release storage.Release
+	if env.InGravity() {
+		lifecycle, err := newReleases(env, helmClient)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		release, err = lifecycle.Uninstall(context.TODO(), conf.Release)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	} else {
+		release, err = helmClient.Uninstall(conf.Release)
+		if err != nil {
+			return trace.Wrap(err)
+		}
 	}
 	env.EmitAuditEvent(context.TODO(), events.ApplicationUninstall, events.FieldsForRelease(release))
 	env.PrintStep("Uninstalled release %v", release.GetName())
@@ -329,6 +395,22 @@ func releaseHistory(env *localenv.LocalEnvironment, conf releaseHistoryConfig) e
 		return trace.Wrap(err)
 	}
 	defer helmClient.Close()
+	if env.InGravity() {
+		lifecycle, err := newReleases(env, helmClient)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		records, err := lifecycle.History(conf.Release)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		// Releases installed before the release history has been
+		// recorded only have the Helm revisions
+		if len(records) != 0 {
+			printReleaseRecords(records)
+			return nil
+		}
+	}
 	releases, err := helmClient.Revisions(conf.Release)
 	if err != nil {
 		return trace.Wrap(err)
@@ -349,6 +431,59 @@ func releaseHistory(env *localenv.LocalEnvironment, conf releaseHistoryConfig) e
 	return nil
 }
 
+// printReleaseRecords outputs the recorded revisions of a release
+func printReleaseRecords(records []storage.ReleaseRecord) {
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "Revision\tApplication\tAction\tStatus\tUpdated\tDescription\n")
+	fmt.Fprintf(w, "--------\t-----------\t------\t------\t-------\t-----------\n")
+	for _, r := range records {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			r.Revision,
+			r.Application,
+			r.Action,
+			r.Status,
+			r.Updated.Format(constants.HumanDateFormatSeconds),
+			r.Description)
+	}
+	w.Flush()
+}
+
+// newReleases returns the release lifecycle manager for the local cluster
+func newReleases(env *localenv.LocalEnvironment, helmClient helm.Client) (*releases.Releases, error) {
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if clusterEnv.Client == nil {
+		return nil, trace.BadParameter("this operation can only be executed on one of the master nodes")
+	}
+	cluster, err := clusterEnv.Operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return releases.New(releases.Config{
+		Apps:        clusterEnv.Apps,
+		Packages:    clusterEnv.ClusterPackages,
+		Records:     clusterEnv.Backend,
+		Helm:        helmClient,
+		ClusterName: cluster.Domain,
+		ServiceUser: cluster.ServiceUser,
+	})
+}
+
+// emitRelease emits the audit event for the specified release
+// and outputs the message
+func emitRelease(env *localenv.LocalEnvironment, helmClient helm.Client, name string, event teleevents.Event, format string, args ...interface{}) error {
+	release, err := helmClient.Get(name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	env.EmitAuditEvent(context.TODO(), event, events.FieldsForRelease(release))
+	env.PrintStep(format, args...)
+	return nil
+}
+
 func appSearch(env *localenv.LocalEnvironment, pattern string, remoteOnly, all bool) error {
 	result, err := catalog.Search(catalog.SearchRequest{
 		Pattern: pattern,
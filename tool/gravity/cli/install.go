@@ -30,7 +30,6 @@ import (
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/expand"
-	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/install"
 	installerclient "github.com/gravitational/gravity/lib/install/client"
 	clinstall "github.com/gravitational/gravity/lib/install/engine/cli"
@@ -281,74 +280,6 @@ func clientTerminationHandler(interrupt *signals.InterruptHandler, printer utils
 	}
 }
 
-type leaveConfig struct {
-	force     bool
-	confirmed bool
-}
-
-func leave(env *localenv.LocalEnvironment, c leaveConfig) error {
-	err := tryLeave(env, c)
-	if err != nil {
-		if !c.force || isCancelledError(err) {
-			return trace.Wrap(err)
-		}
-		log.WithError(err).Warn("Failed to leave cluster, forcing.")
-		err := systemUninstall(env, true)
-		if err != nil {
-			return trace.Wrap(err)
-		}
-	}
-	return nil
-}
-
-func tryLeave(env *localenv.LocalEnvironment, c leaveConfig) error {
-	if err := checkRunningAsRoot(); err != nil {
-		return trace.Wrap(err)
-	}
-
-	err := httplib.InGravity(env.DNS.Addr())
-	if err != nil {
-		return trace.NotFound(
-			"no running cluster detected, please use --force flag to clean up the local state")
-	}
-
-	operator, err := env.SiteOperator()
-	if err != nil {
-		return trace.Wrap(err)
-	}
-
-	site, err := operator.GetLocalSite()
-	if err != nil {
-		return trace.Wrap(err)
-	}
-
-	server, err := findLocalServer(*site)
-	if err != nil {
-		return trace.NotFound(
-			"this server is not a part of the running cluster, please use --force flag to clean up the local state")
-	}
-
-	if !c.confirmed {
-		err = enforceConfirmation(
-			"Please confirm removing %v (%v) from the cluster", server.Hostname, server.AdvertiseIP)
-		if err != nil {
-			return trace.Wrap(err)
-		}
-	}
-
-	err = remove(env, removeConfig{
-		server:    server.Hostname,
-		confirmed: true,
-		force:     c.force,
-	})
-	if err != nil {
-		return trace.BadParameter(
-			"error launching shrink operation, please use --force flag to force delete: %v", err)
-	}
-
-	return nil
-}
-
 func remove(env *localenv.LocalEnvironment, c removeConfig) error {
 	if err := checkRunningAsRoot(); err != nil {
 		return trace.Wrap(err)
@@ -373,6 +304,20 @@ func remove(env *localenv.LocalEnvironment, c removeConfig) error {
 		return trace.Wrap(err)
 	}
 
+	if c.dryRun {
+		simulation, err := operator.SimulateNodeRemoval(context.TODO(),
+			ops.SimulateNodeRemovalRequest{
+				AccountID:  site.AccountID,
+				SiteDomain: site.Domain,
+				Server:     *server,
+			})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		printNodeRemovalSimulation(*simulation, os.Stdout)
+		return nil
+	}
+
 	if !c.confirmed {
 		err = enforceConfirmation(
 			"Please confirm removing %v (%v) from the cluster", server.Hostname, server.AdvertiseIP)
@@ -383,10 +328,11 @@ func remove(env *localenv.LocalEnvironment, c removeConfig) error {
 
 	key, err := operator.CreateSiteShrinkOperation(context.TODO(),
 		ops.CreateSiteShrinkOperationRequest{
-			AccountID:  site.AccountID,
-			SiteDomain: site.Domain,
-			Servers:    []string{server.Hostname},
-			Force:      c.force,
+			AccountID:         site.AccountID,
+			SiteDomain:        site.Domain,
+			Servers:           []string{server.Hostname},
+			Force:             c.force,
+			SkipCapacityCheck: c.skipCapacityCheck,
 		})
 	if err != nil {
 		return trace.Wrap(err)
@@ -666,6 +612,9 @@ func InstallerClient(env *localenv.LocalEnvironment, config installerclient.Conf
 func join(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, config JoinConfig) error {
 	env.PrintStep("Starting agent")
 
+	if config.LocalApps == nil {
+		config.LocalApps = env.Apps
+	}
 	if err := config.CheckAndSetDefaults(); err != nil {
 		return trace.Wrap(err)
 	}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	"github.com/gravitational/trace"
+	"github.com/gravitational/version"
+)
+
+// exportInventory outputs hardware and software inventory of cluster nodes
+// to w in the specified format
+func exportInventory(env *localenv.LocalEnvironment, format constants.Format, w io.Writer) error {
+	if format != constants.EncodingJSON && format != constants.EncodingCSV {
+		return trace.BadParameter("unsupported format %q, supported are: %v, %v",
+			format, constants.EncodingJSON, constants.EncodingCSV)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	inventory, err := operator.GetClusterInventory(cluster.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if format == constants.EncodingCSV {
+		return trace.Wrap(writeInventoryCSV(*inventory, w))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return trace.Wrap(enc.Encode(inventory))
+}
+
+// printNodeInventory outputs hardware and software inventory of this node as JSON.
+// It is invoked remotely on each node to collect the cluster inventory
+func printNodeInventory(w io.Writer) error {
+	info, err := systeminfo.New()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	dmi, err := systeminfo.QueryDMI()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	node := ops.NewNodeInventory(info, *dmi, version.Get().Version)
+	return trace.Wrap(json.NewEncoder(w).Encode(node))
+}
+
+// writeInventoryCSV writes the inventory to w as CSV with one row per node
+func writeInventoryCSV(inventory ops.ClusterInventory, w io.Writer) error {
+	out := csv.NewWriter(w)
+	err := out.Write([]string{
+		"cluster", "application", "version", "hostname", "advertise_ip", "addrs",
+		"role", "cluster_role", "instance_type", "os", "os_version", "cpus", "memory_bytes",
+		"vendor", "product", "serial_number", "board_serial", "bios_version",
+		"gravity_version", "error",
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, node := range inventory.Nodes {
+		err := out.Write([]string{
+			inventory.ClusterName,
+			inventory.Application,
+			inventory.Version,
+			node.Hostname,
+			node.AdvertiseIP,
+			strings.Join(node.Addrs, " "),
+			node.Role,
+			node.ClusterRole,
+			node.InstanceType,
+			node.OS.ID,
+			node.OS.Version,
+			strconv.FormatUint(uint64(node.CPUs), 10),
+			strconv.FormatUint(node.MemoryBytes, 10),
+			node.Vendor,
+			node.Product,
+			node.SerialNumber,
+			node.BoardSerial,
+			node.BIOSVersion,
+			node.GravityVersion,
+			node.Error,
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	out.Flush()
+	return trace.Wrap(out.Error())
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/systeminfo"
+
+	"gopkg.in/check.v1"
+)
+
+func (*S) TestWritesInventoryCSV(c *check.C) {
+	inventory := ops.ClusterInventory{
+		ClusterName: "example.com",
+		Application: "telekube",
+		Version:     "5.5.0",
+		Nodes: []ops.NodeInventory{
+			{
+				Hostname:    "node-1",
+				AdvertiseIP: "192.168.1.1",
+				Addrs:       []string{"10.0.0.1", "192.168.1.1"},
+				Role:        "master",
+				ClusterRole: "master",
+				OS:          storage.OSInfo{ID: "ubuntu", Version: "18.04"},
+				CPUs:        4,
+				MemoryBytes: 8589934592,
+				DMI: systeminfo.DMI{
+					Vendor:       "Dell Inc.",
+					Product:      "PowerEdge R640",
+					SerialNumber: "4XYZ123",
+				},
+				GravityVersion: "5.5.0",
+			},
+			{
+				Hostname:    "node-2",
+				AdvertiseIP: "192.168.1.2",
+				Role:        "worker",
+				ClusterRole: "node",
+				Error:       "connection refused",
+			},
+		},
+	}
+	var buf bytes.Buffer
+	c.Assert(writeInventoryCSV(inventory, &buf), check.IsNil)
+	c.Assert(buf.String(), check.Equals, `cluster,application,version,hostname,advertise_ip,addrs,role,cluster_role,instance_type,os,os_version,cpus,memory_bytes,vendor,product,serial_number,board_serial,bios_version,gravity_version,error
+example.com,telekube,5.5.0,node-1,192.168.1.1,10.0.0.1 192.168.1.1,master,master,,ubuntu,18.04,4,8589934592,Dell Inc.,PowerEdge R640,4XYZ123,,,5.5.0,
+example.com,telekube,5.5.0,node-2,192.168.1.2,,worker,node,,,,0,0,,,,,,,connection refused
+`)
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"github.com/gravitational/trace"
+)
+
+type leaveConfig struct {
+	force     bool
+	confirmed bool
+	// skipCapacityCheck allows to leave even if the remaining
+	// nodes cannot host the evicted pods
+	skipCapacityCheck bool
+}
+
+// leaveContext describes the node leaving the cluster
+type leaveContext struct {
+	// operator is the cluster ops service
+	operator ops.Operator
+	// cluster is the cluster the node is leaving
+	cluster ops.Site
+	// server is the node leaving the cluster
+	server storage.Server
+}
+
+// leave removes this node from the cluster and verifies that it has been
+// removed from the etcd cluster, Kubernetes and Teleport.
+// With force, the local state is cleaned up even if the node could not be
+// removed and the node tombstone is recorded with the cluster so the
+// removal is completed once the cluster can reconcile it
+func leave(env *localenv.LocalEnvironment, c leaveConfig) error {
+	if err := checkRunningAsRoot(); err != nil {
+		return trace.Wrap(err)
+	}
+	leaveCtx, err := newLeaveContext(env)
+	if err == nil {
+		err = tryLeave(env, c, *leaveCtx)
+	}
+	if err == nil {
+		return nil
+	}
+	if !c.force || isCancelledError(err) {
+		return trace.Wrap(err)
+	}
+	log.WithError(err).Warn("Failed to leave cluster, forcing.")
+	env.PrintStep("Failed to leave the cluster: %v", trace.UserMessage(err))
+	if leaveCtx != nil {
+		err = recordNodeTombstone(env, *leaveCtx, trace.UserMessage(err))
+		if err != nil {
+			log.WithError(err).Warn("Failed to record node tombstone.")
+			env.PrintStep("Failed to record node tombstone, please remove the node with 'gravity remove --force' from a master node")
+		}
+	}
+	return trace.Wrap(systemUninstall(env, true))
+}
+
+// newLeaveContext returns the context of this node leaving the cluster.
+// The returned ops service talks to the cluster through another master
+// node if possible so it stays accessible after this node has been removed
+func newLeaveContext(env *localenv.LocalEnvironment) (*leaveContext, error) {
+	err := httplib.InGravity(env.DNS.Addr())
+	if err != nil {
+		return nil, trace.NotFound(
+			"no running cluster detected, please use --force flag to clean up the local state")
+	}
+
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	server, err := findLocalServer(*cluster)
+	if err != nil {
+		return nil, trace.NotFound(
+			"this server is not a part of the running cluster, please use --force flag to clean up the local state")
+	}
+
+	leaveCtx := &leaveContext{
+		operator: operator,
+		cluster:  *cluster,
+		server:   *server,
+	}
+	master := findOtherMaster(*cluster, *server)
+	if master == nil {
+		return leaveCtx, nil
+	}
+	leaveCtx.operator, err = env.SiteOperatorAt(master.AdvertiseIP)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return leaveCtx, nil
+}
+
+func tryLeave(env *localenv.LocalEnvironment, c leaveConfig, leaveCtx leaveContext) error {
+	server := leaveCtx.server
+	if !c.confirmed {
+		err := enforceConfirmation(
+			"Please confirm removing %v (%v) from the cluster", server.Hostname, server.AdvertiseIP)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaults.LeaveOperationTimeout)
+	defer cancel()
+	key, err := leaveCtx.operator.CreateSiteShrinkOperation(ctx,
+		ops.CreateSiteShrinkOperationRequest{
+			AccountID:         leaveCtx.cluster.AccountID,
+			SiteDomain:        leaveCtx.cluster.Domain,
+			Servers:           []string{server.Hostname},
+			Force:             c.force,
+			SkipCapacityCheck: c.skipCapacityCheck,
+		})
+	if err != nil {
+		return trace.BadParameter(
+			"error launching shrink operation, please use --force flag to force delete: %v", err)
+	}
+	env.PrintStep("Launched operation %v to remove %v from the cluster", key.OperationID, server.Hostname)
+
+	if err := waitForOperation(ctx, env, leaveCtx.operator, *key); err != nil {
+		return trace.Wrap(err)
+	}
+
+	status, err := leaveCtx.operator.VerifyNodeRemoval(ctx, ops.VerifyNodeRemovalRequest{
+		AccountID:  leaveCtx.cluster.AccountID,
+		SiteDomain: leaveCtx.cluster.Domain,
+		Server:     server,
+	})
+	if err != nil {
+		return trace.Wrap(err, "failed to verify node removal")
+	}
+	for _, step := range status.Steps {
+		if step.Removed {
+			env.PrintStep("Verified %v removal", step.Description)
+		} else {
+			env.PrintStep("Failed to verify %v removal: %v", step.Description, step.Message)
+		}
+	}
+	if !status.Removed() {
+		return trace.CompareFailed(
+			"node has not been completely removed, please use --force flag to clean up the local state")
+	}
+	env.PrintStep("%v has left the cluster", server.Hostname)
+	return nil
+}
+
+// waitForOperation waits for the cluster operation specified with key
+// to complete and outputs its progress.
+// Errors querying the operation progress are retried as the operation might
+// be resumed by another cluster controller, e.g. if this node has been the leader
+// and is leaving the cluster
+func waitForOperation(ctx context.Context, env *localenv.LocalEnvironment, operator ops.Operator, key ops.SiteOperationKey) error {
+	ticker := time.NewTicker(defaults.RetryInterval)
+	defer ticker.Stop()
+	var message string
+	for {
+		select {
+		case <-ticker.C:
+			progress, err := operator.GetSiteOperationProgress(key)
+			if err != nil {
+				log.WithError(err).Warn("Failed to query operation progress.")
+				continue
+			}
+			if progress.Message != message {
+				message = progress.Message
+				env.PrintStep("%v", message)
+			}
+			if !progress.IsCompleted() {
+				continue
+			}
+			if progress.State == ops.ProgressStateFailed {
+				return trace.BadParameter("operation %v failed: %v", key.OperationID, progress.Message)
+			}
+			return nil
+		case <-ctx.Done():
+			return trace.LimitExceeded("timed out waiting for operation %v to complete", key.OperationID)
+		}
+	}
+}
+
+// recordNodeTombstone records the tombstone of the node that is leaving
+// the cluster forcibly so the cluster completes its removal
+func recordNodeTombstone(env *localenv.LocalEnvironment, leaveCtx leaveContext, reason string) error {
+	err := leaveCtx.operator.CreateNodeTombstone(context.TODO(), leaveCtx.cluster.Key(),
+		ops.NodeTombstone{
+			Server: leaveCtx.server,
+			Reason: reason,
+		})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Recorded node tombstone, the cluster will complete the removal of %v",
+		leaveCtx.server.Hostname)
+	return nil
+}
+
+// findOtherMaster returns a master node of the cluster other than
+// the specified server or nil if there are no other master nodes
+func findOtherMaster(cluster ops.Site, server storage.Server) *storage.Server {
+	masters := cluster.ClusterState.Servers.Masters()
+	for i := range masters {
+		if masters[i].AdvertiseIP != server.AdvertiseIP {
+			return &masters[i]
+		}
+	}
+	return nil
+}
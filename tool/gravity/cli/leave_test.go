@@ -0,0 +1,42 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+
+	"gopkg.in/check.v1"
+)
+
+type LeaveSuite struct{}
+
+var _ = check.Suite(&LeaveSuite{})
+
+func (*LeaveSuite) TestFindsOtherMaster(c *check.C) {
+	master1 := storage.Server{Hostname: "node-1", AdvertiseIP: "10.0.0.1", ClusterRole: string(schema.ServiceRoleMaster)}
+	master2 := storage.Server{Hostname: "node-2", AdvertiseIP: "10.0.0.2", ClusterRole: string(schema.ServiceRoleMaster)}
+	worker := storage.Server{Hostname: "node-3", AdvertiseIP: "10.0.0.3", ClusterRole: string(schema.ServiceRoleNode)}
+	cluster := ops.Site{ClusterState: storage.ClusterState{
+		Servers: storage.Servers{master1, master2, worker},
+	}}
+	c.Assert(findOtherMaster(cluster, master1), check.DeepEquals, &master2)
+	c.Assert(findOtherMaster(cluster, worker), check.DeepEquals, &master1)
+	cluster.ClusterState.Servers = storage.Servers{master1, worker}
+	c.Assert(findOtherMaster(cluster, master1), check.IsNil)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/gravitational/trace"
+)
+
+// showLicense displays the entitlements and the expiration state
+// of the license installed in the local cluster
+func showLicense(env *localenv.LocalEnvironment, format constants.Format) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if cluster.License == nil {
+		return trace.NotFound("cluster %v does not have a license installed", cluster.Domain)
+	}
+	status, err := ops.ParseLicenseStatus(cluster.License.Raw, time.Now())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingText:
+		printLicenseStatus(*status, len(cluster.ClusterState.Servers), time.Now(), os.Stdout)
+		return nil
+	case constants.EncodingJSON:
+		bytes, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		fmt.Println(string(bytes))
+		return nil
+	}
+	return trace.BadParameter("unknown output format %q, supported are: %v, %v",
+		format, constants.EncodingText, constants.EncodingJSON)
+}
+
+// installLicense validates the license from the specified file
+// and installs it into the local cluster
+func installLicense(env *localenv.LocalEnvironment, path string) error {
+	license, err := ioutil.ReadFile(path)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = operator.UpdateClusterLicense(ops.UpdateLicenseRequest{
+		AccountID:  cluster.AccountID,
+		SiteDomain: cluster.Domain,
+		License:    string(license),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	env.PrintStep("License has been installed")
+	status, err := ops.ParseLicenseStatus(string(license), time.Now())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if warning := status.Warning(); warning != "" {
+		env.Printf("%v\n", color.YellowString("WARNING: %v", warning))
+	}
+	return nil
+}
+
+func printLicenseStatus(status ops.LicenseStatus, nodes int, now time.Time, w io.Writer) {
+	var t tabwriter.Writer
+	t.Init(w, 0, 10, 2, ' ', 0)
+	if status.Company != "" {
+		fmt.Fprintf(&t, "Issued to:\t%v\n", status.Company)
+	}
+	if status.Email != "" {
+		fmt.Fprintf(&t, "Email:\t%v\n", status.Email)
+	}
+	fmt.Fprintf(&t, "Nodes:\t%v of %v\n", nodes, licenseLimit(status.MaxNodes))
+	fmt.Fprintf(&t, "CPUs per node:\t%v\n", licenseLimit(status.MaxCPUs))
+	if status.Expiration.IsZero() {
+		fmt.Fprintf(&t, "Expires:\tnever\n")
+	} else {
+		fmt.Fprintf(&t, "Expires:\t%v (%v)\n", status.Expiration.Format(constants.HumanDateFormat),
+			humanize.RelTime(status.Expiration, now, "ago", "from now"))
+	}
+	if len(status.Features) != 0 {
+		fmt.Fprintf(&t, "Features:\t%v\n", strings.Join(status.Features, ", "))
+	}
+	fmt.Fprintf(&t, "State:\t%v\n", status.State)
+	t.Flush()
+	if warning := status.Warning(); warning != "" {
+		fmt.Fprintf(w, "\n%v\n", color.YellowString("WARNING: %v", warning))
+	}
+}
+
+func licenseLimit(limit int) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%v", limit)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/kubernetes"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/migration"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"k8s.io/client-go/dynamic"
+)
+
+// migrationConfig describes the application data to migrate
+type migrationConfig struct {
+	// namespaces lists the namespaces to migrate
+	namespaces []string
+	// claims optionally limits the migrated persistent volume claims
+	claims []string
+	// rateLimit is the maximum transfer rate, e.g. 10MB/s
+	rateLimit string
+	// storageClasses maps the storage classes of this cluster
+	// to the storage classes of the target cluster as source:target
+	storageClasses []string
+}
+
+// exportMigration exports the application data of the local cluster
+// into the archive at the specified path, "-" exports to stdout
+func exportMigration(env *localenv.LocalEnvironment, path string, config migrationConfig) error {
+	var printer utils.Printer = env
+	out := io.Writer(os.Stdout)
+	if path != "-" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, defaults.SharedReadMask)
+		if err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		defer f.Close()
+		out = f
+	} else {
+		printer = utils.DiscardPrinter
+	}
+	manifest, err := exportMigrationTo(env, printer, out, config)
+	if err != nil {
+		if path != "-" {
+			os.Remove(path)
+		}
+		return trace.Wrap(err)
+	}
+	printer.PrintStep("Exported %v resources and %v volumes to %v",
+		len(manifest.Resources), len(manifest.Volumes), path)
+	return nil
+}
+
+// importMigration imports the application data from the archive
+// at the specified path into the local cluster, "-" imports from stdin
+func importMigration(env *localenv.LocalEnvironment, path string, storageClasses []string) error {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return trace.ConvertSystemError(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	req, err := newImportMigrationRequest(storageClasses)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req.AccountID = cluster.AccountID
+	req.SiteDomain = cluster.Domain
+	env.PrintStep("Verifying application data for cluster %v", cluster.Domain)
+	key, err := operator.ImportMigration(*req, in)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(waitForImportMigration(env, operator, *key))
+}
+
+// pushMigration exports the application data of the local cluster and
+// streams it directly into the target cluster.
+// The target cluster is reached either through the Ops Center it is connected
+// to or directly via its cluster controller as specified with opsURL
+func pushMigration(env *localenv.LocalEnvironment, opsURL, clusterName string, config migrationConfig) error {
+	req, err := newImportMigrationRequest(config.storageClasses)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := env.OperatorService(opsURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if clusterName == "" {
+		cluster, err := operator.GetLocalSite()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		clusterName = cluster.Domain
+	}
+	req.AccountID = defaults.SystemAccountID
+	req.SiteDomain = clusterName
+	reader, writer := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := exportMigrationTo(env, env, writer, config)
+		writer.CloseWithError(err)
+		errCh <- err
+	}()
+	env.PrintStep("Migrating application data to cluster %v", clusterName)
+	key, err := operator.ImportMigration(*req, reader)
+	reader.CloseWithError(err)
+	// export fails with a closed pipe if the import has been aborted,
+	// otherwise the export error is the cause of the import failure
+	if errExport := <-errCh; errExport != nil && trace.Unwrap(errExport) != io.ErrClosedPipe {
+		return trace.Wrap(errExport)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(waitForImportMigration(env, operator, *key))
+}
+
+// exportMigrationTo writes the application data of the local cluster
+// as a migration archive to w
+func exportMigrationTo(env *localenv.LocalEnvironment, printer utils.Printer, w io.Writer, config migrationConfig) (*migration.Manifest, error) {
+	var rateLimit utils.TransferRate
+	if config.rateLimit != "" {
+		var err error
+		rateLimit, err = utils.ParseTransferRate(config.rateLimit)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	client, kubeConfig, err := httplib.GetClusterKubeClient(env.DNS.Addr())
+	if err != nil {
+		return nil, trace.Wrap(err, "this operation can only be executed on one of the master nodes")
+	}
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := env.LocalCluster()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	migrator, err := migration.New(migration.Config{
+		Client:      client,
+		Dynamic:     dynamicClient,
+		Executor:    kubernetes.NewPodExecutor(client, kubeConfig),
+		FieldLogger: log,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	printer.PrintStep("Exporting application data from namespaces %v", config.namespaces)
+	return migrator.Export(context.TODO(), migration.ExportRequest{
+		Cluster:    cluster.Domain,
+		Namespaces: config.namespaces,
+		Claims:     config.claims,
+		RateLimit:  int64(rateLimit.BytesPerSecond()),
+	}, w)
+}
+
+func newImportMigrationRequest(storageClasses []string) (*ops.ImportMigrationRequest, error) {
+	mappings, err := migration.ParseStorageClasses(storageClasses)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &ops.ImportMigrationRequest{StorageClasses: mappings}, nil
+}
+
+// waitForImportMigration waits for the operation importing the verified
+// application data to complete and outputs its progress
+func waitForImportMigration(env *localenv.LocalEnvironment, operator ops.Operator, key ops.SiteOperationKey) error {
+	env.PrintStep("Application data has been verified, started operation %v to import it", key.OperationID)
+	ctx, cancel := context.WithTimeout(context.Background(), defaults.MigrationImportTimeout)
+	defer cancel()
+	return trace.Wrap(waitForOperation(ctx, env, operator, key))
+}
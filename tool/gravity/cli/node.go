@@ -0,0 +1,433 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/ghodss/yaml"
+	"github.com/gravitational/trace"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// inspectNode outputs the details of the cluster node specified with name
+// (hostname, advertise IP or cloud node name) in the specified format.
+//
+// Besides the node record, the details include the node profile, the results
+// of the health probes, node-specific packages and the phases of recent
+// operations that operated on the node. Details that fail to be collected
+// are reported as errors instead of failing the command
+func inspectNode(env *localenv.LocalEnvironment, name string, format constants.Format, w io.Writer) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	server, err := findServer(*cluster, []string{name})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	node := collectNodeDetails(env, operator, *cluster, *server)
+	return trace.Wrap(outputNode(node, format, w))
+}
+
+// listNodes outputs the nodes of the local cluster with labels matching
+// the specified label selector in the specified format
+func listNodes(env *localenv.LocalEnvironment, selector string, format constants.Format, w io.Writer) error {
+	labelSelector, err := ops.ParseLabelSelector(selector)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := readerOperator(env)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	servers := filterServers(cluster.ClusterState.Servers, labelSelector)
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(servers, w))
+	case constants.EncodingText:
+		printServers(servers, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+// labelNode updates labels of the cluster node specified with name
+// (hostname, advertise IP or cloud node name).
+// Labels are specified as key=value to set the label and key- to remove it
+func labelNode(env *localenv.LocalEnvironment, name string, args []string) error {
+	add, remove, err := ops.ParseLabelUpdates(args)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	server, err := findServer(*cluster, []string{name})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	err = operator.UpdateLabels(context.TODO(), ops.UpdateLabelsRequest{
+		SiteKey:     cluster.Key(),
+		AdvertiseIP: server.AdvertiseIP,
+		Add:         add,
+		Remove:      remove,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	env.Printf("Node %v labels updated.\n", server.Hostname)
+	return nil
+}
+
+// skipUnselectedNodes returns the nodes to exclude from the operation:
+// the specified nodes to skip along with the nodes of the local cluster
+// with labels not matching the specified selector
+func skipUnselectedNodes(env *localenv.LocalEnvironment, skipNodes []string, selector string) ([]string, error) {
+	labelSelector, err := ops.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return unselectedServers(cluster.ClusterState.Servers, skipNodes, labelSelector)
+}
+
+// unselectedServers returns the specified nodes to skip extended with
+// the advertise IPs of the servers with labels not matching the selector
+func unselectedServers(servers []storage.Server, skipNodes []string, selector labels.Selector) ([]string, error) {
+	if len(filterServers(servers, selector)) == 0 {
+		return nil, trace.NotFound("no cluster nodes matching selector %q", selector)
+	}
+	result := append([]string(nil), skipNodes...)
+	for _, server := range servers {
+		if ops.MatchLabels(selector, server.Labels) {
+			continue
+		}
+		if utils.StringInSlice(skipNodes, server.AdvertiseIP) || utils.StringInSlice(skipNodes, server.Hostname) {
+			continue
+		}
+		result = append(result, server.AdvertiseIP)
+	}
+	return result, nil
+}
+
+// filterServers returns the servers with labels matching the specified selector
+func filterServers(servers []storage.Server, selector labels.Selector) []storage.Server {
+	result := make([]storage.Server, 0, len(servers))
+	for _, server := range servers {
+		if ops.MatchLabels(selector, server.Labels) {
+			result = append(result, server)
+		}
+	}
+	return result
+}
+
+func printServers(servers []storage.Server, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Hostname", "Advertise IP", "Role", "Labels"})
+	for _, server := range servers {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n",
+			server.Hostname,
+			server.AdvertiseIP,
+			server.Role,
+			formatValue(formatLabels(server.Labels)))
+	}
+	w.Flush()
+}
+
+// nodeDetails aggregates the information about a cluster node
+type nodeDetails struct {
+	// Server is the node record
+	Server storage.Server `json:"server"`
+	// Profile is the node profile from the cluster image manifest
+	Profile *schema.NodeProfile `json:"profile,omitempty"`
+	// Probes lists the last results of the health probes on the node
+	Probes []ops.Probe `json:"probes,omitempty"`
+	// Packages lists the node-specific packages in the cluster package service
+	Packages []nodePackage `json:"packages,omitempty"`
+	// Phases lists the phases of recent operations that operated on the node
+	Phases []nodePhase `json:"phases,omitempty"`
+	// Errors lists the details that could not be collected
+	Errors []string `json:"errors,omitempty"`
+}
+
+// nodePackage describes a node-specific package
+type nodePackage struct {
+	// Package is the package locator
+	Package loc.Locator `json:"package"`
+	// Purpose is the package purpose, e.g. planet-config
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// nodePhase describes an operation phase that operated on the node
+type nodePhase struct {
+	// OperationID is the ID of the operation
+	OperationID string `json:"operation_id"`
+	// OperationType is the type of the operation
+	OperationType string `json:"operation_type"`
+	// Phase is the phase ID
+	Phase string `json:"phase"`
+	// State is the phase state
+	State string `json:"state"`
+	// Updated is the time the phase was last updated
+	Updated time.Time `json:"updated"`
+}
+
+func collectNodeDetails(env *localenv.LocalEnvironment, operator ops.Operator, cluster ops.Site, server storage.Server) nodeDetails {
+	node := nodeDetails{Server: server}
+	addError := func(err error, what string) {
+		log.WithError(err).Warnf("Failed to collect node %v.", what)
+		node.Errors = append(node.Errors, fmt.Sprintf("failed to collect %v: %v", what, trace.UserMessage(err)))
+	}
+	profile, err := cluster.App.Manifest.NodeProfiles.ByName(server.Role)
+	if err != nil {
+		addError(err, "profile")
+	} else {
+		node.Profile = profile
+	}
+	probes, err := operator.GetClusterProbes(cluster.Key())
+	if err != nil {
+		addError(err, "health probes")
+	} else {
+		for _, probe := range probes.Probes {
+			if probe.AdvertiseIP == server.AdvertiseIP {
+				node.Probes = append(node.Probes, probe)
+			}
+		}
+	}
+	node.Packages, err = getNodePackages(env, cluster, server)
+	if err != nil {
+		addError(err, "packages")
+	}
+	node.Phases, err = getNodePhases(operator, cluster, server)
+	if err != nil {
+		addError(err, "operation phases")
+	}
+	return node
+}
+
+// getNodePackages returns the packages created for the specified node
+// in the cluster package service
+func getNodePackages(env *localenv.LocalEnvironment, cluster ops.Site, server storage.Server) (packages []nodePackage, err error) {
+	packageService, err := env.ClusterPackages()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	envelopes, err := packageService.GetPackages(cluster.Domain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, envelope := range envelopes {
+		if envelope.RuntimeLabels[pack.AdvertiseIPLabel] != server.AdvertiseIP {
+			continue
+		}
+		packages = append(packages, nodePackage{
+			Package: envelope.Locator,
+			Purpose: envelope.RuntimeLabels[pack.PurposeLabel],
+		})
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Package.String() < packages[j].Package.String()
+	})
+	return packages, nil
+}
+
+// getNodePhases returns the phases of the most recent operations
+// that operated on the specified node
+func getNodePhases(operator ops.Operator, cluster ops.Site, server storage.Server) (phases []nodePhase, err error) {
+	operations, err := operator.GetSiteOperations(cluster.Key())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// operations are returned in the last-to-first order
+	if len(operations) > defaults.NodeInspectOperations {
+		operations = operations[:defaults.NodeInspectOperations]
+	}
+	for _, op := range operations {
+		operation := ops.SiteOperation(op)
+		plan, err := operator.GetOperationPlan(operation.Key())
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		for _, phase := range fsm.ServerPhases(*plan, server.AdvertiseIP) {
+			phases = append(phases, nodePhase{
+				OperationID:   operation.ID,
+				OperationType: operation.Type,
+				Phase:         phase.ID,
+				State:         phase.GetState(),
+				Updated:       phase.GetLastUpdateTime(),
+			})
+		}
+	}
+	return phases, nil
+}
+
+func outputNode(node nodeDetails, format constants.Format, w io.Writer) error {
+	switch format {
+	case constants.EncodingText:
+		printNode(node, w)
+		return nil
+	case constants.EncodingJSON:
+		bytes, err := json.MarshalIndent(node, "", "  ")
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		_, err = fmt.Fprintln(w, string(bytes))
+		return trace.Wrap(err)
+	case constants.EncodingYAML:
+		bytes, err := yaml.Marshal(node)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		_, err = w.Write(bytes)
+		return trace.Wrap(err)
+	}
+	return trace.BadParameter("unsupported output format %q, supported are: %v, %v, %v",
+		format, constants.EncodingText, constants.EncodingJSON, constants.EncodingYAML)
+}
+
+func printNode(node nodeDetails, w io.Writer) {
+	server := node.Server
+	var t tabwriter.Writer
+	t.Init(w, 0, 10, 2, ' ', 0)
+	fmt.Fprintf(&t, "Hostname:\t%v\n", server.Hostname)
+	fmt.Fprintf(&t, "Advertise IP:\t%v\n", server.AdvertiseIP)
+	fmt.Fprintf(&t, "Role:\t%v (%v)\n", server.Role, server.ClusterRole)
+	fmt.Fprintf(&t, "OS:\t%v %v\n", server.OSInfo.ID, server.OSInfo.Version)
+	fmt.Fprintf(&t, "Provisioner:\t%v\n", formatValue(server.Provisioner))
+	fmt.Fprintf(&t, "Node name:\t%v\n", formatValue(server.Nodename))
+	fmt.Fprintf(&t, "Instance ID:\t%v\n", formatValue(server.InstanceID))
+	fmt.Fprintf(&t, "Instance type:\t%v\n", formatValue(server.InstanceType))
+	fmt.Fprintf(&t, "Availability zone:\t%v\n", formatValue(server.AvailabilityZone))
+	fmt.Fprintf(&t, "Machine serial:\t%v\n", formatValue(server.MachineSerial))
+	fmt.Fprintf(&t, "Labels:\t%v\n", formatValue(formatLabels(server.Labels)))
+	fmt.Fprintf(&t, "Joined:\t%v\n", server.Created.Format(constants.HumanDateFormatSeconds))
+	fmt.Fprintf(&t, "Join flags:\t%v\n", formatValue(strings.Join(server.JoinFlags, " ")))
+	if node.Profile != nil {
+		fmt.Fprintf(&t, "Profile:\t%v\n", formatValue(node.Profile.Description))
+		fmt.Fprintf(&t, "Expand policy:\t%v\n", formatValue(node.Profile.ExpandPolicy))
+	}
+	t.Flush()
+
+	if len(server.Mounts) != 0 {
+		fmt.Fprintln(w, "\nMounts:")
+		t.Init(w, 0, 10, 2, ' ', 0)
+		common.PrintTableHeader(&t, []string{"Name", "Source", "Destination"})
+		for _, mount := range server.Mounts {
+			fmt.Fprintf(&t, "%v\t%v\t%v\n", mount.Name, mount.Source, mount.Destination)
+		}
+		t.Flush()
+	}
+
+	if len(node.Probes) != 0 {
+		fmt.Fprintln(w, "\nHealth probes:")
+		t.Init(w, 0, 10, 2, ' ', 0)
+		common.PrintTableHeader(&t, []string{"Probe", "Status", "Detail"})
+		for _, probe := range node.Probes {
+			detail := probe.Detail
+			if probe.Error != "" {
+				detail = strings.TrimSpace(fmt.Sprintf("%v %v", detail, probe.Error))
+			}
+			fmt.Fprintf(&t, "%v\t%v\t%v\n", probe.Checker, probe.Status, formatValue(detail))
+		}
+		t.Flush()
+	}
+
+	if len(node.Packages) != 0 {
+		fmt.Fprintln(w, "\nPackages:")
+		t.Init(w, 0, 10, 2, ' ', 0)
+		common.PrintTableHeader(&t, []string{"Package", "Purpose"})
+		for _, pkg := range node.Packages {
+			fmt.Fprintf(&t, "%v\t%v\n", pkg.Package, formatValue(pkg.Purpose))
+		}
+		t.Flush()
+	}
+
+	if len(node.Phases) != 0 {
+		fmt.Fprintln(w, "\nRecent operation phases:")
+		t.Init(w, 0, 10, 2, ' ', 0)
+		common.PrintTableHeader(&t, []string{"Operation", "Phase", "State", "Updated"})
+		for _, phase := range node.Phases {
+			fmt.Fprintf(&t, "%v (%v)\t%v\t%v\t%v\n", phase.OperationType, phase.OperationID,
+				phase.Phase, phase.State, phase.Updated.Format(constants.HumanDateFormatSeconds))
+		}
+		t.Flush()
+	}
+
+	for _, err := range node.Errors {
+		fmt.Fprintf(w, "\nWARNING: %v\n", err)
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	values := make([]string, 0, len(labels))
+	for key, value := range labels {
+		values = append(values, fmt.Sprintf("%v=%v", key, value))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+func formatValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
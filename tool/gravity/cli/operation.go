@@ -29,6 +29,7 @@ import (
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/storage"
 	"github.com/gravitational/gravity/lib/system/signals"
+	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
@@ -83,6 +84,34 @@ func resumeOperation(localEnv *localenv.LocalEnvironment, environ LocalEnvironme
 	return trace.Wrap(restartInstallOrJoin(localEnv))
 }
 
+// resumeOperationAuto resumes the operation specified with params and keeps
+// resuming it after failures caused by transient errors
+func resumeOperationAuto(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params PhaseParams) error {
+	op, err := getActiveOperation(localEnv, environ, params.OperationID)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.Wrap(resumeOperation(localEnv, environ, params))
+		}
+		return trace.Wrap(err)
+	}
+	params.OperationID = op.ID
+	return trace.Wrap(fsm.AutoResume(context.TODO(), fsm.AutoResumeConfig{
+		Resume: func(context.Context) error {
+			return resumeOperation(localEnv, environ, params)
+		},
+		GetPlan: func() (*storage.OperationPlan, error) {
+			return getOperationPlan(localEnv, environ, *op)
+		},
+		BackOff: utils.NewExponentialBackOff(defaults.TransientErrorTimeout),
+		OnRetry: func(failed []storage.OperationPhase) {
+			for _, phase := range failed {
+				localEnv.PrintStep("Phase %v failed with a transient error (attempt %v), will retry",
+					phase.ID, phase.Checkpoint.Attempts)
+			}
+		},
+	}))
+}
+
 // executePhase executes a phase for the operation specified with params
 func executePhase(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params PhaseParams) error {
 	op, err := getActiveOperation(localEnv, environ, params.OperationID)
@@ -100,6 +129,10 @@ func executePhase(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentF
 		return executeEnvironPhase(localEnv, environ, params, *op)
 	case ops.OperationUpdateConfig:
 		return executeConfigPhase(localEnv, environ, params, *op)
+	case ops.OperationPatch:
+		return executePatchPhase(localEnv, environ, params, *op)
+	case ops.OperationUpdateBinary:
+		return executeUpdateBinaryPhase(localEnv, environ, params, *op)
 	case ops.OperationGarbageCollect:
 		return executeGarbageCollectPhase(localEnv, params, op)
 	default:
@@ -122,6 +155,10 @@ func setPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, p
 		err = setEnvironPhase(env, environ, params, *op)
 	case ops.OperationUpdateConfig:
 		err = setConfigPhase(env, environ, params, *op)
+	case ops.OperationPatch:
+		err = setPatchPhase(env, environ, params, *op)
+	case ops.OperationUpdateBinary:
+		err = setUpdateBinaryPhase(env, environ, params, *op)
 	case ops.OperationGarbageCollect:
 		err = setGarbageCollectPhase(env, params, op)
 	default:
@@ -151,6 +188,10 @@ func rollbackPhase(localEnv *localenv.LocalEnvironment, environ LocalEnvironment
 		return rollbackEnvironPhase(localEnv, environ, params, *op)
 	case ops.OperationUpdateConfig:
 		return rollbackConfigPhase(localEnv, environ, params, *op)
+	case ops.OperationPatch:
+		return rollbackPatchPhase(localEnv, environ, params, *op)
+	case ops.OperationUpdateBinary:
+		return rollbackUpdateBinaryPhase(localEnv, environ, params, *op)
 	default:
 		return trace.BadParameter("operation type %q does not support plan rollback", op.Type)
 	}
@@ -172,6 +213,10 @@ func completeOperationPlan(localEnv *localenv.LocalEnvironment, environ LocalEnv
 		err = completeEnvironPlan(localEnv, environ, *op)
 	case ops.OperationUpdateConfig:
 		err = completeConfigPlan(localEnv, environ, *op)
+	case ops.OperationPatch:
+		err = completePatchPlan(localEnv, environ, *op)
+	case ops.OperationUpdateBinary:
+		err = completeUpdateBinaryPlan(localEnv, environ, *op)
 	default:
 		return trace.BadParameter("operation type %q does not support plan completion", op.Type)
 	}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/gravitational/trace"
+)
+
+// listQueuedOperations outputs the cluster operations waiting for
+// the conflicting operations in progress to complete
+func listQueuedOperations(env *localenv.LocalEnvironment, w io.Writer) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	operations, err := operator.GetSiteOperations(cluster.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	var all []ops.SiteOperation
+	for _, op := range operations {
+		all = append(all, ops.SiteOperation(op))
+	}
+
+	printQueuedOperations(all, w)
+	return nil
+}
+
+// printQueuedOperations outputs the queued operations from the provided list
+// in the order they will be started along with the operations they wait for
+func printQueuedOperations(operations []ops.SiteOperation, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"ID", "Type", "Created", "Created By", "Waiting For"})
+	// operations are listed in the last-to-first order
+	for i := len(operations) - 1; i >= 0; i-- {
+		operation := operations[i]
+		if !operation.IsQueued() {
+			continue
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n",
+			operation.ID,
+			operation.TypeString(),
+			operation.Created.Format(constants.HumanDateFormatSeconds),
+			operation.CreatedBy,
+			ops.FormatOperations(ops.ConflictingOperations(operation, operations)))
+	}
+	w.Flush()
+}
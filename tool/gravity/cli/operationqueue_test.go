@@ -0,0 +1,47 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/ops"
+
+	"gopkg.in/check.v1"
+)
+
+type OperationQueueSuite struct{}
+
+var _ = check.Suite(&OperationQueueSuite{})
+
+func (s *OperationQueueSuite) TestPrintsQueuedOperations(c *check.C) {
+	created := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	printQueuedOperations([]ops.SiteOperation{
+		{ID: "3", Type: ops.OperationShrink, State: ops.OperationStateQueued, Created: created, CreatedBy: "bob@example.com"},
+		{ID: "2", Type: ops.OperationShrink, State: ops.OperationStateQueued, Created: created, CreatedBy: "alice@example.com"},
+		{ID: "1", Type: ops.OperationUpdate, State: ops.OperationStateUpdateInProgress, Created: created},
+	}, &buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	c.Assert(lines, check.HasLen, 4)
+	c.Assert(strings.Fields(lines[2])[0], check.Equals, "2",
+		check.Commentf("expected operations in the order they will be started"))
+	c.Assert(strings.HasSuffix(lines[2], "update (1)"), check.Equals, true)
+	c.Assert(strings.Fields(lines[3])[0], check.Equals, "3")
+}
@@ -34,7 +34,9 @@ import (
 	"github.com/gravitational/gravity/lib/utils"
 	"github.com/gravitational/gravity/tool/common"
 
+	"github.com/cenkalti/backoff"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/dustin/go-humanize"
 	"github.com/gravitational/configure"
 	"github.com/gravitational/trace"
 )
@@ -120,7 +122,7 @@ func unpackPackage(env *localenv.LocalEnvironment, loc loc.Locator, dir, opsCent
 	return nil
 }
 
-func exportPackage(env *localenv.LocalEnvironment, loc loc.Locator, opsCenterURL, targetPath string, mode os.FileMode) error {
+func exportPackage(env *localenv.LocalEnvironment, loc loc.Locator, opsCenterURL, targetPath string, mode os.FileMode, delta bool) error {
 	packageService, err := env.PackageService(opsCenterURL)
 	if err != nil {
 		return trace.Wrap(err)
@@ -134,6 +136,16 @@ func exportPackage(env *localenv.LocalEnvironment, loc loc.Locator, opsCenterURL
 
 	ctx, cancel := context.WithTimeout(context.Background(), defaults.TransientErrorTimeout)
 	defer cancel()
+	if delta {
+		reader, ok := packageService.(pack.DeltaReader)
+		if ok {
+			err = syncPackage(ctx, env, reader, loc, targetPath, mode)
+			if !trace.IsNotImplemented(err) {
+				return trace.Wrap(err)
+			}
+		}
+		log.Warnf("Package service does not support delta transfers, exporting %v in full.", loc)
+	}
 	err = utils.CopyWithRetries(ctx, targetPath, func() (io.ReadCloser, error) {
 		_, rc, err := packageService.ReadPackage(loc)
 		return rc, trace.Wrap(err)
@@ -146,6 +158,34 @@ func exportPackage(env *localenv.LocalEnvironment, loc loc.Locator, opsCenterURL
 	return nil
 }
 
+// syncPackage updates the file at targetPath with the contents of the specified
+// package transferring only the parts of the package that differ from the file
+func syncPackage(ctx context.Context, env *localenv.LocalEnvironment, reader pack.DeltaReader, loc loc.Locator, targetPath string, mode os.FileMode) error {
+	var result *pack.SyncResult
+	err := utils.RetryWithInterval(ctx, utils.NewExponentialBackOff(defaults.TransientErrorTimeout), func() (err error) {
+		result, err = pack.SyncPackage(ctx, pack.SyncRequest{
+			Reader:  reader,
+			Package: loc,
+			Path:    targetPath,
+			Mode:    mode,
+		})
+		if err != nil && !trace.IsConnectionProblem(err) {
+			return &backoff.PermanentError{Err: err}
+		}
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if result.Unchanged {
+		env.Printf("%v is up to date in file %v\n", loc, targetPath)
+		return nil
+	}
+	env.Printf("%v exported to file %v (%v reused, %v transferred)\n", loc, targetPath,
+		humanize.Bytes(uint64(result.Reused)), humanize.Bytes(uint64(result.Transferred)))
+	return nil
+}
+
 func listPackages(app *localenv.LocalEnvironment, repositoryFilter string, opsCenterURL string) error {
 	var repository string
 	return foreachPackage(app, repositoryFilter, opsCenterURL, func(env pack.PackageEnvelope) error {
@@ -357,6 +397,15 @@ func pullPackage(app *localenv.LocalEnvironment, loc loc.Locator, opsCenterURL s
 	return nil
 }
 
+// remoteURL returns the URL of the remote package service for the push and
+// pull commands. url specified as an argument takes precedence over opsCenterURL
+func remoteURL(url, opsCenterURL string) string {
+	if url != "" {
+		return url
+	}
+	return opsCenterURL
+}
+
 func foreachRepository(repository string, packageService pack.PackageService, fn func(repository string) error) (err error) {
 	var repositories []string
 	if repository != "" {
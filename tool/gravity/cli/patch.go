@@ -0,0 +1,244 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+
+	libfsm "github.com/gravitational/gravity/lib/fsm"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/rpc"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/update"
+	"github.com/gravitational/gravity/lib/update/patch"
+
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// patchNodes starts the operation to patch the operating system on cluster
+// nodes one node at a time
+func patchNodes(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironment, config patchConfig) error {
+	if config.command == "" && !config.reboot {
+		return trace.BadParameter("either --command or --reboot is required")
+	}
+	if config.command != "" && config.reboot {
+		return trace.BadParameter("--command and --reboot are mutually exclusive")
+	}
+	if !config.confirmed {
+		localEnv.Println(patchBanner)
+		resp, err := confirm()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !resp {
+			localEnv.Println("Action cancelled by user.")
+			return nil
+		}
+	}
+	updater, err := newUpdater(ctx, localEnv, updateEnv, patchInitializer{
+		command:   config.command,
+		reboot:    config.reboot,
+		skipNodes: config.skipNodes,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	if !config.manual {
+		err = updater.Run(ctx)
+		return trace.Wrap(err)
+	}
+	localEnv.Println(updateEnvironManualOperationBanner)
+	return nil
+}
+
+type patchConfig struct {
+	// command is the shell command to run on each node
+	command string
+	// reboot reboots each node instead of running a command
+	reboot bool
+	// manual specifies whether the operation is created in manual mode
+	manual bool
+	// confirmed suppresses confirmation prompt
+	confirmed bool
+	// skipNodes lists nodes to exclude from the operation
+	skipNodes []string
+}
+
+func executePatchPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params PhaseParams, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getPatchUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	err = updater.RunPhase(context.TODO(), params.PhaseID, params.Timeout, params.Force)
+	return trace.Wrap(err)
+}
+
+func setPatchPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params SetPhaseParams, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getPatchUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	return updater.SetPhase(context.TODO(), params.PhaseID, params.State)
+}
+
+func rollbackPatchPhase(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, params PhaseParams, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getPatchUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	err = updater.RollbackPhase(context.TODO(), params.PhaseID, params.Timeout, params.Force)
+	return trace.Wrap(err)
+}
+
+func completePatchPlan(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory, operation ops.SiteOperation) error {
+	updateEnv, err := environ.NewUpdateEnv()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updateEnv.Close()
+	updater, err := getPatchUpdater(env, updateEnv, operation)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer updater.Close()
+	return trace.Wrap(updater.Complete(nil))
+}
+
+func getPatchUpdater(env, updateEnv *localenv.LocalEnvironment, operation ops.SiteOperation) (*update.Updater, error) {
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	creds, err := libfsm.GetClientCredentials()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	runner := libfsm.NewAgentRunner(creds)
+	return patchInitializer{}.newUpdater(context.TODO(), clusterEnv.Operator, operation,
+		env, updateEnv, clusterEnv, runner)
+}
+
+func (r patchInitializer) validatePreconditions(*localenv.LocalEnvironment, ops.Operator, ops.Site) error {
+	return nil
+}
+
+func (r patchInitializer) newOperation(operator ops.Operator, cluster ops.Site) (*ops.SiteOperationKey, error) {
+	key, err := operator.CreatePatchOperation(context.TODO(),
+		ops.CreatePatchOperationRequest{
+			ClusterKey: cluster.Key(),
+			Command:    r.command,
+			Reboot:     r.reboot,
+		},
+	)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, trace.NotImplemented(
+				"cluster operator does not implement the API required for patching nodes. " +
+					"Please make sure you're running the command on a compatible cluster.")
+		}
+		return nil, trace.Wrap(err)
+	}
+	return key, nil
+}
+
+func (r patchInitializer) newOperationPlan(
+	ctx context.Context,
+	operator ops.Operator,
+	cluster ops.Site,
+	operation ops.SiteOperation,
+	localEnv, updateEnv *localenv.LocalEnvironment,
+	clusterEnv *localenv.ClusterEnvironment,
+	leader *storage.Server,
+) (*storage.OperationPlan, error) {
+	plan, err := patch.NewOperationPlan(operator, operation, *leader, cluster.ClusterState.Servers, r.skipNodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return plan, nil
+}
+
+func (patchInitializer) newUpdater(
+	ctx context.Context,
+	operator ops.Operator,
+	operation ops.SiteOperation,
+	localEnv, updateEnv *localenv.LocalEnvironment,
+	clusterEnv *localenv.ClusterEnvironment,
+	runner rpc.AgentRepository,
+) (*update.Updater, error) {
+	config := patch.Config{
+		Config: update.Config{
+			Operation:    &operation,
+			Operator:     operator,
+			Backend:      clusterEnv.Backend,
+			LocalBackend: updateEnv.Backend,
+			Silent:       localEnv.Silent,
+			Runner:       runner,
+			FieldLogger: logrus.WithFields(logrus.Fields{
+				trace.Component: "update:patch",
+				"operation":     operation,
+			}),
+		},
+		Apps:              clusterEnv.Apps,
+		Client:            clusterEnv.Client,
+		ClusterPackages:   clusterEnv.ClusterPackages,
+		HostLocalPackages: localEnv.Packages,
+	}
+	return patch.New(ctx, config)
+}
+
+func (patchInitializer) updateDeployRequest(req deployAgentsRequest) deployAgentsRequest {
+	return req
+}
+
+type patchInitializer struct {
+	// command is the shell command to run on each node
+	command string
+	// reboot reboots each node instead of running a command
+	reboot bool
+	// skipNodes lists nodes to exclude from the operation
+	skipNodes []string
+}
+
+const patchBanner = `Patching drains and patches cluster nodes one at a time, master nodes first.
+The operation might take a while to complete depending on the cluster size.
+
+The operation will start automatically once you approve it.
+If you want to review the operation plan first or execute it manually step by step,
+run the operation in manual mode by specifying '--manual' flag.
+
+Are you sure?`
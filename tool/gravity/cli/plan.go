@@ -19,6 +19,7 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -57,7 +58,7 @@ func initUpdateOperationPlan(localEnv, updateEnv *localenv.LocalEnvironment) err
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	_, err = clusterupdate.InitOperationPlan(ctx, localEnv, updateEnv, clusterEnv, operation.Key(), leader)
+	_, err = clusterupdate.InitOperationPlan(ctx, localEnv, updateEnv, clusterEnv, operation.Key(), leader, nil, nil)
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -76,54 +77,225 @@ To restart the installation, use 'gravity resume' after fixing the issues.
 		}
 		return trace.Wrap(err)
 	}
+	plan, err := getOperationPlan(localEnv, environ, *op)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(outputPlan(*plan, format))
+}
+
+// displayOperationPhase outputs the specified phase of the operation plan.
+// If logs is set, the output captured while executing the phase on a remote
+// node is displayed instead
+func displayOperationPhase(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, operationID, phaseID string, format constants.Format, logs bool) error {
+	op, err := getLastOperation(localEnv, environ, operationID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	plan, err := getOperationPlan(localEnv, environ, *op)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	phase, err := fsm.FindPhase(plan, phaseID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if logs {
+		return trace.Wrap(outputPhaseLogs(os.Stdout, *phase))
+	}
+	plan.Phases = []storage.OperationPhase{*phase}
+	return trace.Wrap(outputPlan(*plan, format))
+}
+
+// displayClusterOperationPlan outputs the plan of the specified operation
+// (or the last cluster operation if operationID is empty) as stored in the cluster.
+// It is used by users other than root, which have no access to the local
+// operation state and are authorized with the cluster roles instead.
+// If phaseID is set, only the specified phase is displayed
+func displayClusterOperationPlan(env *localenv.LocalEnvironment, operationID, phaseID string, format constants.Format) error {
+	operator, err := getCallerOperator(env)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var op *ops.SiteOperation
+	if operationID != "" {
+		op, err = operator.GetSiteOperation(cluster.OperationKey(operationID))
+	} else {
+		op, _, err = ops.GetLastOperation(cluster.Key(), operator)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	plan, err := operator.GetOperationPlan(op.Key())
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if phaseID != "" {
+		phase, err := fsm.FindPhase(plan, phaseID)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		plan.Phases = []storage.OperationPhase{*phase}
+	}
+	return trace.Wrap(outputPlan(*plan, format))
+}
+
+// exportOperationPlan outputs the plan of the specified upgrade operation
+// along with the state of the cluster it has been generated for.
+// The exported plan can be reviewed offline and then executed with
+// 'gravity upgrade --plan'
+func exportOperationPlan(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, operationID string) error {
+	op, err := getLastOperation(localEnv, environ, operationID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	plan, err := getOperationPlan(localEnv, environ, *op)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := localEnv.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	exported, err := clusterupdate.ExportOperationPlan(*cluster, *op, *plan)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := printJSON(exported, os.Stdout); err != nil {
+		return trace.Wrap(err)
+	}
+	// The plan itself is written to stdout
+	fmt.Fprintf(os.Stderr, "Plan hash: %v\n"+
+		"Specify it with 'gravity upgrade --plan-hash' to execute the plan once reviewed.\n",
+		exported.PlanHash)
+	return nil
+}
+
+// diffOperationPlans outputs the differences between the plans
+// of the operations specified with fromID and toID
+func diffOperationPlans(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, fromID, toID string, format constants.Format) error {
+	from, err := getOperationPlanByID(localEnv, environ, fromID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	to, err := getOperationPlanByID(localEnv, environ, toID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	diff := fsm.DiffPlans(*from, *to)
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(diff, os.Stdout))
+	case constants.EncodingText:
+		fsm.FormatPlanDiffText(os.Stdout, diff)
+		return nil
+	default:
+		return trace.BadParameter("unknown output format %q", format)
+	}
+}
+
+// getOperationPlanByID returns the plan of the operation with the specified ID.
+// Users other than root are served the plan as stored in the cluster
+func getOperationPlanByID(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, operationID string) (*storage.OperationPlan, error) {
+	if runningAsRoot() {
+		op, err := getLastOperation(localEnv, environ, operationID)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return getOperationPlan(localEnv, environ, *op)
+	}
+	operator, err := localEnv.SiteOperator()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plan, err := operator.GetOperationPlan(cluster.OperationKey(operationID))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return plan, nil
+}
+
+// outputPhaseLogs writes the output captured during the last execution
+// of the specified phase on a remote node to w
+func outputPhaseLogs(w io.Writer, phase storage.OperationPhase) error {
+	if phase.Checkpoint == nil || len(phase.Checkpoint.Logs) == 0 {
+		fmt.Fprintf(w, "No logs have been captured for phase %v.\n", phase.ID)
+		return nil
+	}
+	logs, err := fsm.DecompressLogs(phase.Checkpoint.Logs)
+	if err != nil {
+		return trace.Wrap(err, "failed to decompress logs of phase %v", phase.ID)
+	}
+	_, err = w.Write(logs)
+	return trace.Wrap(err)
+}
+
+// getOperationPlan returns the up-to-date plan of the specified operation
+func getOperationPlan(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, op ops.SiteOperation) (plan *storage.OperationPlan, err error) {
 	if op.IsCompleted() {
-		return displayClusterOperationPlan(localEnv, op.Key(), format)
+		return getClusterOperationPlan(localEnv, op.Key())
 	}
 	switch op.Type {
 	case ops.OperationInstall:
-		err = displayInstallOperationPlan(op.Key(), format)
+		plan, err = getInstallOperationPlan(op.Key())
 	case ops.OperationExpand:
-		err = displayExpandOperationPlan(environ, op.Key(), format)
+		plan, err = getExpandOperationPlan(environ, op.Key())
 	case ops.OperationUpdate:
-		err = displayUpdateOperationPlan(localEnv, environ, op.Key(), format)
+		plan, err = getUpdateOperationPlan(localEnv, environ, op.Key())
 	case ops.OperationUpdateRuntimeEnviron:
-		err = displayUpdateOperationPlan(localEnv, environ, op.Key(), format)
+		plan, err = getUpdateOperationPlan(localEnv, environ, op.Key())
 	case ops.OperationUpdateConfig:
-		err = displayUpdateOperationPlan(localEnv, environ, op.Key(), format)
-	case ops.OperationGarbageCollect:
-		err = displayClusterOperationPlan(localEnv, op.Key(), format)
+		plan, err = getUpdateOperationPlan(localEnv, environ, op.Key())
+	case ops.OperationPatch, ops.OperationUpdateBinary:
+		plan, err = getUpdateOperationPlan(localEnv, environ, op.Key())
+	case ops.OperationGarbageCollect, ops.OperationRotateCertificates:
+		plan, err = getClusterOperationPlan(localEnv, op.Key())
 	default:
-		return trace.BadParameter("unknown operation type %q", op.Type)
+		return nil, trace.BadParameter("unknown operation type %q", op.Type)
 	}
 	if err != nil && trace.IsNotFound(err) {
 		// Fallback to cluster plan
-		return displayClusterOperationPlan(localEnv, op.Key(), format)
+		return getClusterOperationPlan(localEnv, op.Key())
 	}
-	return trace.Wrap(err)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return plan, nil
 }
 
-func displayClusterOperationPlan(env *localenv.LocalEnvironment, opKey ops.SiteOperationKey, format constants.Format) error {
+func getClusterOperationPlan(env *localenv.LocalEnvironment, opKey ops.SiteOperationKey) (*storage.OperationPlan, error) {
 	clusterEnv, err := env.NewClusterEnvironment()
 	if err != nil {
-		return trace.Wrap(err)
+		return nil, trace.Wrap(err)
 	}
 	plan, err := clusterEnv.Operator.GetOperationPlan(opKey)
 	if err != nil {
-		return trace.Wrap(err)
+		return nil, trace.Wrap(err)
 	}
-	err = outputPlan(*plan, format)
-	return trace.Wrap(err)
+	return plan, nil
 }
 
-func displayUpdateOperationPlan(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, opKey ops.SiteOperationKey, format constants.Format) error {
+func getUpdateOperationPlan(localEnv *localenv.LocalEnvironment, environ LocalEnvironmentFactory, opKey ops.SiteOperationKey) (*storage.OperationPlan, error) {
 	updateEnv, err := environ.NewUpdateEnv()
 	if err != nil {
-		return trace.Wrap(err)
+		return nil, trace.Wrap(err)
 	}
 	defer updateEnv.Close()
 	plan, err := fsm.GetOperationPlan(updateEnv.Backend, opKey)
 	if err != nil {
-		return trace.Wrap(err)
+		return nil, trace.Wrap(err)
 	}
 	reconciledPlan, err := tryReconcilePlan(context.TODO(), localEnv, updateEnv, *plan)
 	if err != nil {
@@ -131,40 +303,36 @@ func displayUpdateOperationPlan(localEnv *localenv.LocalEnvironment, environ Loc
 	} else {
 		plan = reconciledPlan
 	}
-	err = outputPlan(*plan, format)
-	if err != nil {
-		return trace.Wrap(err)
-	}
-	return nil
+	return plan, nil
 }
 
-func displayInstallOperationPlan(opKey ops.SiteOperationKey, format constants.Format) error {
+func getInstallOperationPlan(opKey ops.SiteOperationKey) (*storage.OperationPlan, error) {
 	plan, err := getPlanFromWizard(opKey)
 	if err == nil {
 		log.Debug("Showing install operation plan retrieved from wizard process.")
-		return trace.Wrap(outputPlan(*plan, format))
+		return plan, nil
 	}
 	plan, err = getPlanFromWizardBackend(opKey)
 	if err != nil {
-		return trace.Wrap(err, "failed to get plan for the install operation.\n"+
+		return nil, trace.Wrap(err, "failed to get plan for the install operation.\n"+
 			"Make suer you are running 'gravity plan' from the installer node.")
 	}
-	return trace.Wrap(outputPlan(*plan, format))
+	return plan, nil
 }
 
-// displayExpandOperationPlan shows plan of the join operation from the local join backend
-func displayExpandOperationPlan(environ LocalEnvironmentFactory, opKey ops.SiteOperationKey, format constants.Format) error {
+// getExpandOperationPlan returns plan of the join operation from the local join backend
+func getExpandOperationPlan(environ LocalEnvironmentFactory, opKey ops.SiteOperationKey) (*storage.OperationPlan, error) {
 	joinEnv, err := environ.NewJoinEnv()
 	if err != nil {
-		return trace.Wrap(err)
+		return nil, trace.Wrap(err)
 	}
 	defer joinEnv.Close()
 	plan, err := fsm.GetOperationPlan(joinEnv.Backend, opKey)
 	if err != nil {
-		return trace.Wrap(err)
+		return nil, trace.Wrap(err)
 	}
 	log.Debug("Showing join operation plan retrieved from local join backend.")
-	return outputPlan(*plan, format)
+	return plan, nil
 }
 
 func outputPlan(plan storage.OperationPlan, format constants.Format) (err error) {
@@ -175,9 +343,11 @@ func outputPlan(plan storage.OperationPlan, format constants.Format) (err error)
 		err = fsm.FormatOperationPlanJSON(os.Stdout, plan)
 	case constants.EncodingText:
 		fsm.FormatOperationPlanText(os.Stdout, plan)
+		printSkippedServers(os.Stdout, plan)
 		err = explainPlan(plan.Phases)
 	case constants.EncodingShort:
 		fsm.FormatOperationPlanShort(os.Stdout, plan)
+		printSkippedServers(os.Stdout, plan)
 		err = explainPlan(plan.Phases)
 	default:
 		return trace.BadParameter("unknown output format %q", format)
@@ -188,6 +358,16 @@ func outputPlan(plan storage.OperationPlan, format constants.Format) (err error)
 	return nil
 }
 
+// printSkippedServers outputs the list of servers excluded from the operation
+func printSkippedServers(w io.Writer, plan storage.OperationPlan) {
+	if len(plan.SkippedServers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nThe following nodes have been excluded from the operation: %v.\n"+
+		"Use 'gravity update catch-up <node>' to update them after the operation has completed.\n",
+		storage.Servers(plan.SkippedServers))
+}
+
 func explainPlan(phases []storage.OperationPhase) (err error) {
 	for _, phase := range phases {
 		if phase.State == storage.OperationPhaseStateFailed {
@@ -230,10 +410,11 @@ func tryReconcilePlan(ctx context.Context, localEnv, updateEnv *localenv.LocalEn
 }
 
 func getPlanFromWizardBackend(opKey ops.SiteOperationKey) (*storage.OperationPlan, error) {
-	wizardEnv, err := localenv.NewLocalWizardEnvironment()
+	wizardEnv, err := localenv.NewLocalWizardSnapshotEnvironment()
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	defer wizardEnv.Close()
 	plan, err := fsm.GetOperationPlan(wizardEnv.Backend, opKey)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -24,10 +24,12 @@ import (
 
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/modules"
 	"github.com/gravitational/gravity/lib/schema"
 	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/system"
 	"github.com/gravitational/gravity/lib/utils"
 	"github.com/gravitational/gravity/tool/common"
 
@@ -45,12 +47,16 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.Insecure = g.Flag("insecure", "Skip TLS verification.").Default("false").Bool()
 	g.StateDir = g.Flag("state-dir", "Gravity local state directory.").String()
 	g.EtcdRetryTimeout = g.Flag("etcd-retry-timeout", "Retry timeout for transient etcd errors.").Hidden().Duration()
+	g.OperatorRetryTimeout = g.Flag("operator-retry-timeout", "Retry timeout for transient cluster controller errors, negative value disables retries.").Hidden().Duration()
 	g.UID = app.Flag("uid", "Effective user ID for this operation. Must be >= 0.").Default(strconv.Itoa(defaults.PlaceholderUserID)).Hidden().Int()
 	g.GID = g.Flag("gid", "Effective group ID for this operation. Must be >= 0.").Default(strconv.Itoa(defaults.PlaceholderGroupID)).Hidden().Int()
 	g.ProfileEndpoint = g.Flag("httpprofile", "Enable profiling endpoint on specified host/port i.e. localhost:6060.").Hidden().String()
 	g.ProfileTo = g.Flag("profile-dir", "Store periodic state snapshots in the specified directory.").Hidden().String()
 	g.UserLogFile = g.Flag("log-file", "Path to the log file with diagnostic information.").Default(defaults.GravityUserLog).String()
 	g.SystemLogFile = g.Flag("system-log-file", "Path to the log file with system level logs.").Default(defaults.GravitySystemLog).Hidden().String()
+	g.NoProxyPolicy = g.Flag("no-proxy-policy", fmt.Sprintf("Policy for excluding internal destinations from HTTP proxying. One of: %v. The \"all\" policy excludes all IP addresses, \"cluster\" excludes only the cluster subnets and local networks.", httplib.NoProxyPolicies)).
+		Default(string(httplib.NoProxyAll)).OverrideDefaultFromEnvar(constants.NoProxyPolicyEnvVar).Enum(httplib.NoProxyPolicies...)
+	g.NoProxy = g.Flag("no-proxy", "Additional destination to exclude from HTTP proxying. Can be specified multiple times.").Strings()
 
 	g.VersionCmd.CmdClause = g.Command("version", "Print version information and exit.")
 	g.VersionCmd.Output = common.Format(g.VersionCmd.Flag("output", "Output format: text or json.").Short('o').Default(string(constants.EncodingText)))
@@ -58,6 +64,9 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.InstallCmd.CmdClause = g.Command("install", "Install cluster image on this node.")
 	g.InstallCmd.Path = g.InstallCmd.Arg("path", "Path to the directory with the unpacked cluster image. Defaults to the current directory.").String()
 	g.InstallCmd.AdvertiseAddr = g.InstallCmd.Flag("advertise-addr", "IP address this node will advertise to other cluster nodes. Must be present on the node. Will be auto-selected if not specified.").String()
+	g.InstallCmd.AdvertiseCIDR = g.InstallCmd.Flag("advertise-cidr", "Select the advertise address from this subnet if --advertise-addr is not specified.").String()
+	g.InstallCmd.AdvertiseInterface = g.InstallCmd.Flag("advertise-interface", "Select the advertise address of the network interface with the name matching this regular expression if --advertise-addr is not specified.").String()
+	g.InstallCmd.AdvertiseDefaultRoute = g.InstallCmd.Flag("advertise-default-route", "Select the advertise address of the network interface with the default route if --advertise-addr is not specified.").Bool()
 	g.InstallCmd.Token = g.InstallCmd.Flag("token", "Unique install token to authorize other nodes to join the cluster. Generated automatically if unspecified.").String()
 	g.InstallCmd.CloudProvider = g.InstallCmd.Flag("cloud-provider", fmt.Sprintf("Cloud provider integration: %v. Auto-detected if not set.", schema.SupportedProviders)).String()
 	g.InstallCmd.Cluster = g.InstallCmd.Flag("cluster", "Cluster name. Will be auto-generated if not specified.").String()
@@ -91,21 +100,36 @@ func RegisterCommands(app *kingpin.Application) *Application {
 		Default(defaults.ServiceGroupID).
 		OverrideDefaultFromEnvar(constants.ServiceGroupEnvVar).
 		String()
+	g.InstallCmd.SELinux = g.InstallCmd.Flag("selinux", "Load the gravity SELinux policy and label system directories and devices on all nodes. Requires SELinux to be enabled.").Bool()
+	g.InstallCmd.DefaultDenyNetworkPolicy = g.InstallCmd.Flag("default-deny-network-policy", "Deploy network policies denying all traffic in the application namespaces except for the traffic required by the platform.").Bool()
+	g.InstallCmd.RegistryDir = g.InstallCmd.Flag("registry-dir", "Path to a pre-seeded Docker registry directory to populate the cluster registry from instead of exporting the application images. Must be present on all master nodes.").String()
+	g.InstallCmd.CNI = g.InstallCmd.Flag("cni", fmt.Sprintf("Network plugin to install the cluster with, one of: %v. Defaults to the plugin configured in the application manifest.", strings.Join(schema.CNIPlugins, ", "))).Enum(schema.CNIPlugins...)
+	g.InstallCmd.NodeLabels = g.InstallCmd.Flag("k8s-label", "Additional Kubernetes label for this node in key=value format. Can be specified multiple times.").StringMap()
+	g.InstallCmd.NodeTaints = g.InstallCmd.Flag("taint", "Additional Kubernetes taint for this node in key=value:effect format. Can be specified multiple times.").Strings()
+	g.InstallCmd.With = g.InstallCmd.Flag("with", "Include the optional application component disabled by default. Can be specified multiple times.").Strings()
+	g.InstallCmd.Without = g.InstallCmd.Flag("without", "Exclude the optional application component from installation. Can be specified multiple times.").Strings()
 	g.InstallCmd.GCENodeTags = g.InstallCmd.Flag("gce-node-tag", "Override node tag on the instance in GCE required for load balanacing. Defaults to the cluster name.").Strings()
 	g.InstallCmd.DNSHosts = g.InstallCmd.Flag("dns-host", "Specify an IP address that will be returned for the given domain within the cluster. Accepts <domain>/<ip> format. Can be specified multiple times.").Hidden().Strings()
 	g.InstallCmd.DNSZones = g.InstallCmd.Flag("dns-zone", "Specify an upstream server for the given zone within the cluster. Accepts <zone>/<nameserver> format where <nameserver> can be either <ip> or <ip>:<port>. Can be specified multiple times.").Strings()
+	g.InstallCmd.CACert = g.InstallCmd.Flag("ca-cert", "Path to the certificate of the certificate authority to issue cluster certificates with, optionally followed by the certificates of its issuers. Requires --ca-key.").String()
+	g.InstallCmd.CAKey = g.InstallCmd.Flag("ca-key", "Path to the private key of the certificate authority specified with --ca-cert.").String()
 	g.InstallCmd.Remote = g.InstallCmd.Flag("remote", "Do not use this node in the cluster.").Bool()
 	g.InstallCmd.FromService = g.InstallCmd.Flag("from-service", "Run in service mode.").Hidden().Bool()
 
 	g.JoinCmd.CmdClause = g.Command("join", "Join the existing cluster or an on-going install operation.")
 	g.JoinCmd.PeerAddr = g.JoinCmd.Arg("peer-addrs", "One or several IP addresses of cluster nodes to join, as comma-separated values.").String()
 	g.JoinCmd.AdvertiseAddr = g.JoinCmd.Flag("advertise-addr", "IP address this node will advertise to other cluster nodes.").String()
+	g.JoinCmd.AdvertiseCIDR = g.JoinCmd.Flag("advertise-cidr", "Select the advertise address from this subnet if --advertise-addr is not specified.").String()
+	g.JoinCmd.AdvertiseInterface = g.JoinCmd.Flag("advertise-interface", "Select the advertise address of the network interface with the name matching this regular expression if --advertise-addr is not specified.").String()
+	g.JoinCmd.AdvertiseDefaultRoute = g.JoinCmd.Flag("advertise-default-route", "Select the advertise address of the network interface with the default route if --advertise-addr is not specified.").Bool()
 	g.JoinCmd.Token = g.JoinCmd.Flag("token", "Unique token to authorize this node to join the cluster.").String()
 	g.JoinCmd.Role = g.JoinCmd.Flag("role", "Role of this node.").String()
 	g.JoinCmd.DockerDevice = g.JoinCmd.Flag("docker-device", "Docker device to use.").Hidden().String()
 	g.JoinCmd.SystemDevice = g.JoinCmd.Flag("system-device", "Device to use for system data directory.").Hidden().String()
 	g.JoinCmd.ServerAddr = g.JoinCmd.Flag("server-addr", "Address of the agent server.").Hidden().String()
 	g.JoinCmd.Mounts = configure.KeyValParam(g.JoinCmd.Flag("mount", "One or several mounts in form <mount-name>:<path>, e.g. data:/var/lib/data."))
+	g.JoinCmd.NodeLabels = g.JoinCmd.Flag("k8s-label", "Additional Kubernetes label for this node in key=value format. Can be specified multiple times.").StringMap()
+	g.JoinCmd.NodeTaints = g.JoinCmd.Flag("taint", "Additional Kubernetes taint for this node in key=value:effect format. Can be specified multiple times.").Strings()
 	g.JoinCmd.CloudProvider = g.JoinCmd.Flag("cloud-provider", "[DEPRECATED] This flag has no effect and will be removed in a future version.").String()
 	g.JoinCmd.OperationID = g.JoinCmd.Flag("operation-id", "ID of the operation that was created via UI.").Hidden().String()
 	g.JoinCmd.FromService = g.JoinCmd.Flag("from-service", "Run in service mode.").Hidden().Bool()
@@ -121,15 +145,33 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.AutoJoinCmd.Token = g.AutoJoinCmd.Flag("token", "Unique token to authorize this node to join the cluster.").Hidden().String()
 	g.AutoJoinCmd.FromService = g.AutoJoinCmd.Flag("from-service", "Run in service mode.").Hidden().Bool()
 
+	g.StageCmd.CmdClause = g.Command("stage", "Place cluster image packages on this node ahead of time so joining the cluster later is faster.")
+	g.StageCmd.Path = g.StageCmd.Arg("path", "Path to the directory with the unpacked cluster image. Defaults to the directory with the gravity binary.").String()
+	g.StageCmd.Role = g.StageCmd.Flag("role", "Role of the node to stage packages for. Packages for all roles are staged if unspecified.").String()
+
 	g.LeaveCmd.CmdClause = g.Command("leave", "Decommission this node from the cluster.")
-	g.LeaveCmd.Force = g.LeaveCmd.Flag("force", "Force local state cleanup.").Bool()
+	g.LeaveCmd.Force = g.LeaveCmd.Flag("force", "Force local state cleanup if the node could not be removed from the cluster.").Bool()
 	g.LeaveCmd.Confirm = g.LeaveCmd.Flag("confirm", "Do not ask for confirmation.").Bool()
+	g.LeaveCmd.SkipCapacityCheck = g.LeaveCmd.Flag("skip-capacity-check", "Leave the cluster even if the remaining nodes do not have the capacity to host the evicted pods.").Bool()
 
 	g.RemoveCmd.CmdClause = g.Command("remove", "Remove a node from the cluster.")
 	g.RemoveCmd.Node = g.RemoveCmd.Arg("node", "Node to remove: can be IP address, hostname or name from `kubectl get nodes` output).").
 		Required().String()
 	g.RemoveCmd.Force = g.RemoveCmd.Flag("force", "Force removal of an offline node.").Bool()
 	g.RemoveCmd.Confirm = g.RemoveCmd.Flag("confirm", "Do not ask for confirmation.").Bool()
+	g.RemoveCmd.DryRun = g.RemoveCmd.Flag("dry-run", "Report the pods that would be evicted, the remaining capacity, the local volumes that would be lost and the impact on etcd quorum without removing the node.").Bool()
+	g.RemoveCmd.SkipCapacityCheck = g.RemoveCmd.Flag("skip-capacity-check", "Remove the node even if the remaining nodes do not have the capacity to host the evicted pods.").Bool()
+
+	g.UninstallCmd.CmdClause = g.Command("uninstall", "Uninstall Gravity from this node or all cluster nodes and verify that nothing Gravity-related remains.")
+	g.UninstallCmd.Node = g.UninstallCmd.Flag("node", "Uninstall Gravity from this node only. This is the default.").Bool()
+	g.UninstallCmd.All = g.UninstallCmd.Flag("all", "Uninstall Gravity from all cluster nodes.").Bool()
+	g.UninstallCmd.PreserveData = g.UninstallCmd.Flag("preserve-data", "Keep the state directory with the cluster and application data.").Bool()
+	g.UninstallCmd.Confirmed = g.UninstallCmd.Flag("confirm", "Do not ask for confirmation.").Bool()
+	g.UninstallCmd.KeepTeleport = g.UninstallCmd.Flag("keep-teleport", "Keep the Teleport service running, used when the teardown is driven from another node.").Hidden().Bool()
+
+	g.ReinstallCmd.CmdClause = g.Command("reinstall", "Tear down Gravity on this node and install it again preserving application data. Run from the installer tarball directory, install flags are passed after --.")
+	g.ReinstallCmd.Preserve = g.ReinstallCmd.Flag("preserve", "Application data directory to preserve and restore into the new cluster. Can be specified multiple times.").Strings()
+	g.ReinstallCmd.Confirmed = g.ReinstallCmd.Flag("confirm", "Do not ask for confirmation.").Bool()
 
 	g.ResumeCmd.CmdClause = g.Command("resume", "Resume the last aborted operation.")
 	g.ResumeCmd.OperationID = g.ResumeCmd.Flag("operation-id", "ID of the active operation. It not specified, the last operation will be used.").Hidden().String()
@@ -144,14 +186,16 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.PlanDisplayCmd.CmdClause = g.PlanCmd.Command("display", "Display a plan for an ongoing operation.").Default()
 	g.PlanDisplayCmd.Output = common.Format(g.PlanDisplayCmd.Flag("output", fmt.Sprintf("Output format: %v.", constants.OutputFormats)).Short('o').Default(string(constants.EncodingText)))
 	g.PlanDisplayCmd.Short = g.PlanDisplayCmd.Flag("short", "Short output format.").Bool()
+	g.PlanDisplayCmd.Phase = g.PlanDisplayCmd.Flag("phase", "Phase ID to display.").String()
+	g.PlanDisplayCmd.Logs = g.PlanDisplayCmd.Flag("logs", "Display the output captured while executing the phase on a remote node.").Bool()
 
 	g.PlanExecuteCmd.CmdClause = g.PlanCmd.Command("execute", "Execute the specified operation phase.")
-	g.PlanExecuteCmd.Phase = g.PlanExecuteCmd.Flag("phase", "Phase ID to execute.").String()
+	g.PlanExecuteCmd.Phase = g.PlanExecuteCmd.Flag("phase", "Phase ID to execute. Can be a glob pattern like /masters/* to execute all matching phases.").String()
 	g.PlanExecuteCmd.Force = g.PlanExecuteCmd.Flag("force", "Force execution of the specified phase.").Bool()
 	g.PlanExecuteCmd.PhaseTimeout = g.PlanExecuteCmd.Flag("timeout", "Phase execution timeout.").Default(defaults.PhaseTimeout).Hidden().Duration()
 
 	g.PlanRollbackCmd.CmdClause = g.PlanCmd.Command("rollback", "Rollback the specified operation phase.")
-	g.PlanRollbackCmd.Phase = g.PlanRollbackCmd.Flag("phase", "Phase ID to rollback.").String()
+	g.PlanRollbackCmd.Phase = g.PlanRollbackCmd.Flag("phase", "Phase ID to rollback. Can be a glob pattern like /masters/* to rollback all matching phases.").String()
 	g.PlanRollbackCmd.Force = g.PlanRollbackCmd.Flag("force", "Force rollback of the specified phase.").Bool()
 	g.PlanRollbackCmd.PhaseTimeout = g.PlanRollbackCmd.Flag("timeout", "Phase rollback timeout.").Default(defaults.PhaseTimeout).Hidden().Duration()
 
@@ -161,10 +205,26 @@ func RegisterCommands(app *kingpin.Application) *Application {
 
 	g.PlanResumeCmd.CmdClause = g.PlanCmd.Command("resume", "Resume the last aborted operation.")
 	g.PlanResumeCmd.Force = g.PlanResumeCmd.Flag("force", "Force execution of the specified phase.").Bool()
+	g.PlanResumeCmd.Auto = g.PlanResumeCmd.Flag("auto", "Keep resuming the operation with backoff after failures caused by transient errors.").Bool()
 	g.PlanResumeCmd.PhaseTimeout = g.PlanResumeCmd.Flag("timeout", "Phase execution timeout.").Default(defaults.PhaseTimeout).Hidden().Duration()
 
 	g.PlanCompleteCmd.CmdClause = g.PlanCmd.Command("complete", "Mark the current operation as completed.")
 
+	g.PlanDiffCmd.CmdClause = g.PlanCmd.Command("diff", "Compare plans of two operations, e.g. a failed operation and its retry.")
+	g.PlanDiffCmd.From = g.PlanDiffCmd.Arg("from", "ID of the first operation.").Required().String()
+	g.PlanDiffCmd.To = g.PlanDiffCmd.Arg("to", "ID of the second operation.").Required().String()
+	g.PlanDiffCmd.Output = common.Format(g.PlanDiffCmd.Flag("output", "Output format: text or json.").Short('o').Default(string(constants.EncodingText)))
+
+	g.PlanExportCmd.CmdClause = g.PlanCmd.Command("export", "Export the plan of an upgrade operation created in manual mode for offline review.")
+
+	g.OperationCmd.CmdClause = g.Command("operation", "Manage cluster operations.")
+
+	g.OperationApproveCmd.CmdClause = g.OperationCmd.Command("approve", "Approve an operation started by another user that requires approval.")
+	g.OperationApproveCmd.ApprovalID = g.OperationApproveCmd.Arg("id", "ID of the approval request.").Required().String()
+
+	g.OperationQueueCmd.CmdClause = g.OperationCmd.Command("queue", "Manage operations waiting for the conflicting operations in progress to complete.")
+	g.OperationQueueListCmd.CmdClause = g.OperationQueueCmd.Command("ls", "List queued operations.")
+
 	g.UpdateCmd.CmdClause = g.Command("update", "Update actions on cluster.")
 
 	g.UpdateCheckCmd.CmdClause = g.UpdateCmd.Command("check", "Check if an update is available for the specified cluster image.").Hidden()
@@ -174,6 +234,23 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.UpdateTriggerCmd.App = g.UpdateTriggerCmd.Arg("image", "Cluster image version to upgrade to in the 'name:version' or 'name' (for latest version) format.").String()
 	g.UpdateTriggerCmd.Manual = g.UpdateTriggerCmd.Flag("manual", "Manual operation. Do not trigger automatic update.").Short('m').Bool()
 	g.UpdateTriggerCmd.SkipVersionCheck = g.UpdateTriggerCmd.Flag("skip-version-check", "Bypass version compatibility check.").Hidden().Bool()
+	g.UpdateTriggerCmd.SkipNodes = g.UpdateTriggerCmd.Flag("skip-nodes", "Hostname or advertise IP of a node to exclude from the upgrade. Can be specified multiple times.").Strings()
+	g.UpdateTriggerCmd.Selector = g.UpdateTriggerCmd.Flag("selector", "Upgrade only the nodes with labels matching the label selector, e.g. zone=a,env!=test. Other nodes are excluded from the upgrade.").Short('l').String()
+	g.UpdateTriggerCmd.PauseAfter = g.UpdateTriggerCmd.Flag("pause-after", "ID of the phase to pause the upgrade after until it is resumed. Can be specified multiple times.").Strings()
+	g.UpdateTriggerCmd.Plan = g.UpdateTriggerCmd.Flag("plan", "Path to the plan exported with 'gravity plan export' to execute instead of generating a new plan.").String()
+	g.UpdateTriggerCmd.PlanHash = g.UpdateTriggerCmd.Flag("plan-hash", "Hash of the reviewed plan printed by 'gravity plan export', required with --plan.").String()
+	g.UpdateTriggerCmd.Throttle = g.UpdateTriggerCmd.Flag("throttle", "Throttle the resource usage of the operation on cluster nodes so it does not starve the cluster workloads. One of: none, low, medium.").Default(string(system.ThrottleNone)).Enum(system.ThrottleLevels...)
+	g.UpdateTriggerCmd.SkipCapacityCheck = g.UpdateTriggerCmd.Flag("skip-capacity-check", "Start the upgrade even if the cluster does not have the capacity to host the pods evicted from the drained nodes.").Bool()
+
+	g.UpdateCatchUpCmd.CmdClause = g.UpdateCmd.Command("catch-up", "Update a node excluded from a previous upgrade to the installed cluster image.")
+	g.UpdateCatchUpCmd.Node = g.UpdateCatchUpCmd.Arg("node", "Hostname or advertise IP of the node to update.").Required().String()
+	g.UpdateCatchUpCmd.Manual = g.UpdateCatchUpCmd.Flag("manual", "Manual operation. Do not trigger automatic update.").Short('m').Bool()
+	g.UpdateCatchUpCmd.SkipVersionCheck = g.UpdateCatchUpCmd.Flag("skip-version-check", "Bypass version compatibility check.").Hidden().Bool()
+
+	g.UpdateBinaryCmd.CmdClause = g.UpdateCmd.Command("binary", "Replace the gravity binary on cluster nodes with this binary one node at a time.")
+	g.UpdateBinaryCmd.Manual = g.UpdateBinaryCmd.Flag("manual", "Do not start the operation automatically").Short('m').Bool()
+	g.UpdateBinaryCmd.Confirmed = g.UpdateBinaryCmd.Flag("confirm", "Do not ask for confirmation").Bool()
+	g.UpdateBinaryCmd.SkipNodes = g.UpdateBinaryCmd.Flag("skip-nodes", "Hostname or advertise IP of a node to exclude from the operation. Can be specified multiple times.").Strings()
 
 	g.UpdatePlanInitCmd.CmdClause = g.UpdateCmd.Command("init-plan", "Initialize operation plan.").Hidden()
 
@@ -186,10 +263,23 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.UpgradeCmd.Force = g.UpgradeCmd.Flag("force", "Force phase execution even if pre-conditions are not satisfied.").Bool()
 	g.UpgradeCmd.Resume = g.UpgradeCmd.Flag("resume", "Resume upgrade from the last failed step.").Bool()
 	g.UpgradeCmd.SkipVersionCheck = g.UpgradeCmd.Flag("skip-version-check", "Bypass version compatibility check.").Hidden().Bool()
+	g.UpgradeCmd.SkipNodes = g.UpgradeCmd.Flag("skip-nodes", "Hostname or advertise IP of a node to exclude from the upgrade. Can be specified multiple times.").Strings()
+	g.UpgradeCmd.Selector = g.UpgradeCmd.Flag("selector", "Upgrade only the nodes with labels matching the label selector, e.g. zone=a,env!=test. Other nodes are excluded from the upgrade.").Short('l').String()
+	g.UpgradeCmd.PauseAfter = g.UpgradeCmd.Flag("pause-after", "ID of the phase to pause the upgrade after until it is resumed. Can be specified multiple times.").Strings()
+	g.UpgradeCmd.Plan = g.UpgradeCmd.Flag("plan", "Path to the plan exported with 'gravity plan export' to execute instead of generating a new plan.").String()
+	g.UpgradeCmd.PlanHash = g.UpgradeCmd.Flag("plan-hash", "Hash of the reviewed plan printed by 'gravity plan export', required with --plan.").String()
+	g.UpgradeCmd.Throttle = g.UpgradeCmd.Flag("throttle", "Throttle the resource usage of the operation on cluster nodes so it does not starve the cluster workloads. One of: none, low, medium.").Default(string(system.ThrottleNone)).Enum(system.ThrottleLevels...)
+	g.UpgradeCmd.SkipCapacityCheck = g.UpgradeCmd.Flag("skip-capacity-check", "Start the upgrade even if the cluster does not have the capacity to host the pods evicted from the drained nodes.").Bool()
+	g.UpgradeCmd.Preview = g.UpgradeCmd.Flag("preview", "Print the impact of upgrading to the specified cluster image tarball (or unpacked tarball) without starting the upgrade.").String()
 
 	g.UpdateUploadCmd.CmdClause = g.UpdateCmd.Command("upload", "Upload update package to locally running site").Hidden()
 	g.UpdateUploadCmd.OpsCenterURL = g.UpdateUploadCmd.Flag("ops-url", "Optional Gravity Hub URL to upload new packages to (defaults to local gravity site)").Default(defaults.GravityServiceURL).String()
 
+	g.UpdateDownloadCmd.CmdClause = g.UpdateCmd.Command("download", "Download the cluster image from Gravity Hub into the update directory ahead of the upgrade.")
+	g.UpdateDownloadCmd.App = g.UpdateDownloadCmd.Arg("image", "Cluster image to download in the 'version', 'name:version' or 'name' (for latest version) format.").String()
+	g.UpdateDownloadCmd.OpsCenterURL = g.UpdateDownloadCmd.Flag("ops-url", "Optional Gravity Hub URL to download the cluster image from (defaults to the current Gravity Hub)").String()
+	g.UpdateDownloadCmd.Delete = g.UpdateDownloadCmd.Flag("delete", "Remove the downloaded cluster image.").Bool()
+
 	// manual update flow commands
 	g.UpdateCompleteCmd.CmdClause = g.UpdateCmd.Command("complete", "Mark update operation as completed").Hidden()
 	g.UpdateCompleteCmd.Failed = g.UpdateCompleteCmd.Flag("failed", "Mark update operation as failure").Short('f').Bool()
@@ -206,21 +296,76 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.StatusCmd.Tail = g.StatusCmd.Flag("tail", "Tail logs of the currently running operation until it completes.").Bool()
 	g.StatusCmd.OperationID = g.StatusCmd.Flag("operation-id", "Check status of the operation with the given ID.").Short('o').String()
 	g.StatusCmd.Seconds = g.StatusCmd.Flag("seconds", "Continuously display status every N seconds.").Short('s').Int()
+	g.StatusCmd.Watch = g.StatusCmd.Flag("watch", "Continuously redraw cluster status in place.").Short('w').Bool()
+	g.StatusCmd.Interval = g.StatusCmd.Flag("interval", "Status refresh interval in watch mode.").Default(defaults.StatusWatchInterval.String()).Duration()
+	g.StatusCmd.DegradedTimeout = g.StatusCmd.Flag("degraded-timeout", "Exit with an error if the cluster stays degraded for longer than the specified duration in watch mode. Disabled by default.").Duration()
+	g.StatusCmd.Probes = g.StatusCmd.Flag("probes", "List the last results of all health probes on cluster nodes.").Bool()
 	g.StatusCmd.Output = common.Format(g.StatusCmd.Flag("output", "Output format: json or text.").Default(string(constants.EncodingText)))
 
 	// reset cluster state, for debugging/emergencies
 	g.StatusResetCmd.CmdClause = g.Command("status-reset", "Reset the cluster state to 'active'").Hidden()
 
+	g.StatusCheckCmd.CmdClause = g.Command("status-check", "Check cluster health and exit with a Nagios-compatible exit code: 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN).")
+	g.StatusCheckCmd.Output = common.Format(g.StatusCheckCmd.Flag("output", "Output format: json or text.").Short('o').Default(string(constants.EncodingText)))
+
+	g.WaitCmd.CmdClause = g.Command("wait", fmt.Sprintf("Wait for a cluster condition to be met. Exits with %v on timeout and with %v if the condition can no longer be met.",
+		defaults.TimeoutExitCode, defaults.FailedPreconditionExitCode))
+	g.WaitCmd.For = g.WaitCmd.Flag("for", "Condition to wait for: cluster-healthy, operation-complete=<operation-id> or node-ready=<hostname or IP>.").Required().String()
+	g.WaitCmd.Timeout = g.WaitCmd.Flag("timeout", "Maximum time to wait for the condition.").Default(defaults.WaitTimeout.String()).Duration()
+	g.WaitCmd.Interval = g.WaitCmd.Flag("interval", "Interval between condition checks.").Default(defaults.WaitInterval.String()).Duration()
+
+	g.StorageCmd.CmdClause = g.Command("storage", "Operations on cluster persistent storage.")
+	g.StorageUsageCmd.CmdClause = g.StorageCmd.Command("usage", "Display persistent volume capacity and usage per namespace and storage class along with the storage quotas.")
+	g.StorageUsageCmd.Format = common.Format(g.StorageUsageCmd.Flag("format", "Output format: text or json.").Default(string(constants.EncodingText)))
+	g.StorageSnapshotCmd.CmdClause = g.StorageCmd.Command("snapshot", "Operations on snapshots of persistent volumes provisioned by the OpenEBS CSI drivers.")
+	g.StorageSnapshotCreateCmd.CmdClause = g.StorageSnapshotCmd.Command("create", "Take a snapshot of persistent volumes along with etcd data.")
+	g.StorageSnapshotCreateCmd.Name = g.StorageSnapshotCreateCmd.Arg("name", "Snapshot name.").Required().String()
+	g.StorageSnapshotCreateCmd.Namespace = g.StorageSnapshotCreateCmd.Flag("namespace", "Snapshot the claims in the specified namespace. Defaults to all namespaces.").Short('n').String()
+	g.StorageSnapshotCreateCmd.Selector = g.StorageSnapshotCreateCmd.Flag("selector", "Label selector of the claims to snapshot.").Short('l').String()
+	g.StorageSnapshotCreateCmd.Claims = g.StorageSnapshotCreateCmd.Flag("claim", "Persistent volume claim to snapshot as namespace/name. Can be specified multiple times.").Strings()
+	g.StorageSnapshotCreateCmd.SnapshotClass = g.StorageSnapshotCreateCmd.Flag("snapshot-class", "Volume snapshot class to use. Defaults to the default class of the volume driver.").String()
+	g.StorageSnapshotCreateCmd.Etcd = g.StorageSnapshotCreateCmd.Flag("etcd", "Backup etcd data along with the volumes. Use --no-etcd to disable.").Default("true").Bool()
+	g.StorageSnapshotCreateCmd.Timeout = g.StorageSnapshotCreateCmd.Flag("timeout", "Maximum time to wait for the snapshots to become ready.").Default(defaults.VolumeSnapshotTimeout.String()).Duration()
+	g.StorageSnapshotListCmd.CmdClause = g.StorageSnapshotCmd.Command("ls", "List snapshots of persistent volumes.")
+	g.StorageSnapshotListCmd.Format = common.Format(g.StorageSnapshotListCmd.Flag("format", "Output format: text or json.").Default(string(constants.EncodingText)))
+	g.StorageSnapshotRestoreCmd.CmdClause = g.StorageSnapshotCmd.Command("restore", "Restore persistent volume claims from a snapshot.")
+	g.StorageSnapshotRestoreCmd.Name = g.StorageSnapshotRestoreCmd.Arg("name", "Snapshot name.").Required().String()
+	g.StorageSnapshotRestoreCmd.Suffix = g.StorageSnapshotRestoreCmd.Flag("suffix", "Suffix to append to the names of the restored claims. The claims are restored under their original names if unspecified.").String()
+	g.StorageSnapshotRemoveCmd.CmdClause = g.StorageSnapshotCmd.Command("rm", "Remove a snapshot of persistent volumes.")
+	g.StorageSnapshotRemoveCmd.Name = g.StorageSnapshotRemoveCmd.Arg("name", "Snapshot name.").Required().String()
+	g.StorageMigrationCmd.CmdClause = g.StorageCmd.Command("migration", "Migrate application data and resources between clusters.")
+	g.StorageMigrationExportCmd.CmdClause = g.StorageMigrationCmd.Command("export", "Export persistent volume data and application resources into an archive.")
+	g.StorageMigrationExportCmd.Path = g.StorageMigrationExportCmd.Arg("path", "Path of the archive to create, - for stdout.").Required().String()
+	g.StorageMigrationExportCmd.Namespaces = g.StorageMigrationExportCmd.Flag("namespace", "Namespace to export. Can be specified multiple times.").Short('n').Required().Strings()
+	g.StorageMigrationExportCmd.Claims = g.StorageMigrationExportCmd.Flag("claim", "Persistent volume claim to export as name or namespace/name. Can be specified multiple times. Defaults to all claims in the namespaces.").Strings()
+	g.StorageMigrationExportCmd.RateLimit = g.StorageMigrationExportCmd.Flag("rate-limit", "Maximum transfer rate, e.g. 10MB/s. Unlimited if unspecified.").String()
+	g.StorageMigrationImportCmd.CmdClause = g.StorageMigrationCmd.Command("import", "Import persistent volume data and application resources from an archive.")
+	g.StorageMigrationImportCmd.Path = g.StorageMigrationImportCmd.Arg("path", "Path of the archive to import, - for stdin.").Required().String()
+	g.StorageMigrationImportCmd.StorageClasses = g.StorageMigrationImportCmd.Flag("storage-class", "Storage class mapping as source:target. Can be specified multiple times.").Strings()
+	g.StorageMigrationPushCmd.CmdClause = g.StorageMigrationCmd.Command("push", "Stream persistent volume data and application resources directly into another cluster.")
+	g.StorageMigrationPushCmd.OpsCenterURL = g.StorageMigrationPushCmd.Flag("ops-url", "URL of the Ops Center the target cluster is connected to or of the target cluster itself.").Required().String()
+	g.StorageMigrationPushCmd.Cluster = g.StorageMigrationPushCmd.Flag("cluster", "Name of the target cluster. Required when migrating through an Ops Center.").String()
+	g.StorageMigrationPushCmd.Namespaces = g.StorageMigrationPushCmd.Flag("namespace", "Namespace to migrate. Can be specified multiple times.").Short('n').Required().Strings()
+	g.StorageMigrationPushCmd.Claims = g.StorageMigrationPushCmd.Flag("claim", "Persistent volume claim to migrate as name or namespace/name. Can be specified multiple times. Defaults to all claims in the namespaces.").Strings()
+	g.StorageMigrationPushCmd.RateLimit = g.StorageMigrationPushCmd.Flag("rate-limit", "Maximum transfer rate, e.g. 10MB/s. Unlimited if unspecified.").String()
+	g.StorageMigrationPushCmd.StorageClasses = g.StorageMigrationPushCmd.Flag("storage-class", "Storage class mapping as source:target. Can be specified multiple times.").Strings()
+
 	// backup
 	g.BackupCmd.CmdClause = g.Command("backup", "Launch the cluster's backup hook.")
 	g.BackupCmd.Tarball = g.BackupCmd.Arg("to", "Tarball to create with results of the backup hook.").Required().String()
 	g.BackupCmd.Timeout = g.BackupCmd.Flag("timeout", "Active deadline for the backup job, in Go duration format (e.g. 30s, 5m, etc.). If not specified, the value from manifest is used. If that is not specified as well, the default value of 20 minutes is used.").Duration()
 	g.BackupCmd.Follow = g.BackupCmd.Flag("follow", "Output backup job logs to the stdout.").Bool()
+	g.BackupCmd.Snapshot = g.BackupCmd.Flag("snapshot", "Take a snapshot of persistent volumes and etcd data with the specified name right after the backup.").String()
 
 	g.CheckCmd.CmdClause = g.Command("check", "Check the node environment to satisfy cluster manifest requirements.")
 	g.CheckCmd.ManifestFile = g.CheckCmd.Arg("manifest", "Path to the cluster manifest file.").Default(defaults.ManifestFileName).String()
 	g.CheckCmd.Profile = g.CheckCmd.Flag("profile", "Node profile name to check against.").Short('p').Required().String()
 	g.CheckCmd.AutoFix = g.CheckCmd.Flag("autofix", "Attempt to auto-fix some of the problems.").Bool()
+	g.CheckCmd.Format = common.Format(g.CheckCmd.Flag("format", "Output format: text or json. With json, the results are written to stdout and the command exits with code 1 if any checks failed.").Default(string(constants.EncodingText)))
+
+	g.NetworkCmd.CmdClause = g.Command("network", "Diagnose the cluster network.")
+	g.NetworkMTUTestCmd.CmdClause = g.NetworkCmd.Command("mtu-test", "Detect the path MTU to a remote node and the suitable overlay network MTU.")
+	g.NetworkMTUTestCmd.Addr = g.NetworkMTUTestCmd.Arg("addr", "Address of the remote node.").Required().String()
 
 	// restore
 	g.RestoreCmd.CmdClause = g.Command("restore", "Launch the cluster's restore hook.")
@@ -266,6 +411,12 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.AppHistoryCmd.CmdClause = g.AppCmd.Command("history", "Display revision history for a release.")
 	g.AppHistoryCmd.Release = g.AppHistoryCmd.Arg("release", "Release name to display revisions for.").Required().String()
 
+	g.AppEnableCmd.CmdClause = g.AppCmd.Command("enable", "Enable an optional component of the cluster application.")
+	g.AppEnableCmd.Component = g.AppEnableCmd.Arg("component", "Name of the component to enable.").Required().String()
+
+	g.AppDisableCmd.CmdClause = g.AppCmd.Command("disable", "Disable an optional component of the cluster application.")
+	g.AppDisableCmd.Component = g.AppDisableCmd.Arg("component", "Name of the component to disable.").Required().String()
+
 	g.AppSyncCmd.CmdClause = g.AppCmd.Command("sync", "Synchronize an application image with a cluster.")
 	g.AppSyncCmd.Image = g.AppSyncCmd.Arg("image", "Specifies application image to install. Can be an image tarball, an unpacked image tarball, or an image name in the form of <name>:<version>.").Required().String()
 	g.AppSyncCmd.Registry = g.AppSyncCmd.Flag("registry", "Address of Docker registry to push application images to.").String()
@@ -333,20 +484,26 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	// pull an application from a remote OpsCenter
 	g.AppPullCmd.CmdClause = g.AppCmd.Command("pull", "pull an application package from remote Gravity Hub").Hidden()
 	g.AppPullCmd.Package = Locator(g.AppPullCmd.Arg("pkg", "application package").Required())
-	g.AppPullCmd.OpsCenterURL = g.AppPullCmd.Flag("ops-url", "remote Gravity Hub URL").Required().String()
+	g.AppPullCmd.URL = g.AppPullCmd.Arg("url", "remote Gravity Hub or OCI registry (oci://<registry>/<namespace>) URL").String()
+	g.AppPullCmd.OpsCenterURL = g.AppPullCmd.Flag("ops-url", "remote Gravity Hub URL").String()
 	g.AppPullCmd.Labels = configure.KeyValParam(g.AppPullCmd.Flag("labels", "labels to add to the package"))
 	g.AppPullCmd.Force = g.AppPullCmd.Flag("force", "overwrite destination app if it already exists").Bool()
 
 	// push an application to a remote OpsCenter
 	g.AppPushCmd.CmdClause = g.AppCmd.Command("push", "push an application package to remote Gravity Hub").Hidden()
 	g.AppPushCmd.Package = Locator(g.AppPushCmd.Arg("pkg", "application package").Required())
-	g.AppPushCmd.OpsCenterURL = g.AppPushCmd.Flag("ops-url", "remote Gravity Hub URL").Required().String()
+	g.AppPushCmd.URL = g.AppPushCmd.Arg("url", "remote Gravity Hub or OCI registry (oci://<registry>/<namespace>) URL").String()
+	g.AppPushCmd.OpsCenterURL = g.AppPushCmd.Flag("ops-url", "remote Gravity Hub URL").String()
+
+	// application hooks
+	g.AppHookCmd.CmdClause = g.AppCmd.Command("hook", "operations on application hooks").Hidden()
 
 	// run an application hook
-	g.AppHookCmd.CmdClause = g.AppCmd.Command("hook", "run the specified application hook").Hidden()
-	g.AppHookCmd.Package = Locator(g.AppHookCmd.Arg("pkg", "application package").Required())
-	g.AppHookCmd.HookName = g.AppHookCmd.Arg("hook-name", fmt.Sprintf("name of the hook (one of %v)", schema.AllHooks())).Required().String()
-	g.AppHookCmd.Env = g.AppHookCmd.Flag("env", "additional environment variables to provide to hook job as key=value pairs. Can be specified multiple times").StringMap()
+	g.AppHookRunCmd.CmdClause = g.AppHookCmd.Command("run", "run the specified application hook and stream its logs")
+	g.AppHookRunCmd.Package = Locator(g.AppHookRunCmd.Arg("pkg", "application package").Required())
+	g.AppHookRunCmd.HookName = g.AppHookRunCmd.Arg("hook-name", fmt.Sprintf("name of the hook (one of %v)", schema.AllHooks())).Required().String()
+	g.AppHookRunCmd.Env = g.AppHookRunCmd.Flag("env", "additional environment variables to provide to hook job as key=value pairs. Can be specified multiple times").StringMap()
+	g.AppHookRunCmd.Timeout = g.AppHookRunCmd.Flag("timeout", fmt.Sprintf("Maximum time the hook job is active. Defaults to the value from the manifest or %v if unspecified.", defaults.HookJobDeadline)).Duration()
 
 	// unpack application resources
 	g.AppUnpackCmd.CmdClause = g.AppCmd.Command("unpack", "unpack application resources").Hidden()
@@ -381,7 +538,10 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	// TODO: move this functionality to crpcAgent
 	g.OpsAgentCmd.CmdClause = g.OpsCmd.Command("agent", "Start an agent to perform a set of tasks").Hidden()
 	g.OpsAgentCmd.PackageAddr = g.OpsAgentCmd.Arg("package-addr", "Address of the package service").Required().String()
-	g.OpsAgentCmd.AdvertiseAddr = g.OpsAgentCmd.Flag("advertise-addr", "IP address to advertise").Required().IP()
+	g.OpsAgentCmd.AdvertiseAddr = g.OpsAgentCmd.Flag("advertise-addr", "IP address to advertise. Will be auto-selected if not specified").IP()
+	g.OpsAgentCmd.AdvertiseCIDR = g.OpsAgentCmd.Flag("advertise-cidr", "Select the advertise address from this subnet if --advertise-addr is not specified").String()
+	g.OpsAgentCmd.AdvertiseInterface = g.OpsAgentCmd.Flag("advertise-interface", "Select the advertise address of the network interface with the name matching this regular expression if --advertise-addr is not specified").String()
+	g.OpsAgentCmd.AdvertiseDefaultRoute = g.OpsAgentCmd.Flag("advertise-default-route", "Select the advertise address of the network interface with the default route if --advertise-addr is not specified").Bool()
 	g.OpsAgentCmd.ServerAddr = g.OpsAgentCmd.Flag("server-addr", "Address of the agent server").Required().String()
 	g.OpsAgentCmd.Token = g.OpsAgentCmd.Flag("token", "Unique token to authorize the agent to the server").Required().String()
 	g.OpsAgentCmd.ServiceName = g.OpsAgentCmd.Flag("service-name", "Start agent in a systemd service with this name").String()
@@ -413,6 +573,7 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.PackExportCmd.File = g.PackExportCmd.Arg("file", "output file with a package").Required().String()
 	g.PackExportCmd.OpsCenterURL = g.PackExportCmd.Flag("ops-url", "optional remote Gravity Hub URL").String()
 	g.PackExportCmd.FileMask = g.PackExportCmd.Flag("file-mask", "optional output file access mode (octal, as specified with chmod)").Default(strconv.FormatUint(defaults.SharedReadWriteMask, 8)).String()
+	g.PackExportCmd.Delta = g.PackExportCmd.Flag("delta", "only transfer the parts of the package that differ from the existing output file").Bool()
 
 	// list packages
 	g.PackListCmd.CmdClause = g.PackCmd.Command("list", "list local packages").Hidden()
@@ -441,11 +602,13 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	// push package to remote OpsCenter
 	g.PackPushCmd.CmdClause = g.PackCmd.Command("push", "push package to remote Gravity Hub").Hidden()
 	g.PackPushCmd.Package = Locator(g.PackPushCmd.Arg("pkg", "package name to push").Required())
+	g.PackPushCmd.URL = g.PackPushCmd.Arg("url", "remote Gravity Hub or OCI registry (oci://<registry>/<namespace>) URL").String()
 	g.PackPushCmd.OpsCenterURL = g.PackPushCmd.Flag("ops-url", "optional remote Gravity Hub URL").String()
 
 	// pull package from remote OpsCenter
 	g.PackPullCmd.CmdClause = g.PackCmd.Command("pull", "pull package from remote Gravity Hub").Hidden()
 	g.PackPullCmd.Package = Locator(g.PackPullCmd.Arg("pkg", "package name to pull").Required())
+	g.PackPullCmd.URL = g.PackPullCmd.Arg("url", "remote Gravity Hub or OCI registry (oci://<registry>/<namespace>) URL").String()
 	g.PackPullCmd.OpsCenterURL = g.PackPullCmd.Flag("ops-url", "remote Gravity Hub URL").String()
 	g.PackPullCmd.Labels = configure.KeyValParam(g.PackPullCmd.Flag("labels", "labels to add to the package"))
 	g.PackPullCmd.Force = g.PackPullCmd.Flag("force", "overwrite destination package if it already exists").Bool()
@@ -493,6 +656,16 @@ func RegisterCommands(app *kingpin.Application) *Application {
 			int(defaults.MaxUserResetTokenTTL/time.Hour))).
 		Default(fmt.Sprintf("%v", defaults.UserResetTokenTTL)).Duration()
 
+	g.LicenseCmd.CmdClause = g.Command("license", "Manage cluster license.")
+
+	// show the installed license
+	g.LicenseShowCmd.CmdClause = g.LicenseCmd.Command("show", "Display the installed license entitlements and expiration.")
+	g.LicenseShowCmd.Output = common.Format(g.LicenseShowCmd.Flag("output", "Output format: text or json.").Short('o').Default(string(constants.EncodingText)))
+
+	// install a new license
+	g.LicenseInstallCmd.CmdClause = g.LicenseCmd.Command("install", "Validate and install a new cluster license.")
+	g.LicenseInstallCmd.Path = g.LicenseInstallCmd.Arg("path", "Path to the license file.").Required().String()
+
 	// operations with api keys
 	g.APIKeyCmd.CmdClause = g.Command("apikey", "operations with api keys")
 
@@ -514,7 +687,8 @@ func RegisterCommands(app *kingpin.Application) *Application {
 
 	// get cluster diagnostics report
 	g.ReportCmd.CmdClause = g.Command("report", "Collect tarball with cluster's diagnostic information.")
-	g.ReportCmd.FilePath = g.ReportCmd.Flag("file", "File name with collected diagnostic information.").Default("report.tar.gz").String()
+	g.ReportCmd.FilePath = g.ReportCmd.Flag("file", "File name with collected diagnostic information. Use - to stream the tarball to stdout.").Default("report.tar.gz").String()
+	g.ReportCmd.Upload = g.ReportCmd.Flag("upload", "Also upload the report to the Ops Center with the specified address. Requires a prior login to the Ops Center.").String()
 
 	// operations on sites
 	g.SiteCmd.CmdClause = g.Command("site", "operations on gravity sites")
@@ -571,6 +745,10 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.SystemRotateCertsCmd.ClusterName = g.SystemRotateCertsCmd.Arg("cluster-name", "Name of the local cluster").Required().String()
 	g.SystemRotateCertsCmd.ValidFor = g.SystemRotateCertsCmd.Flag("valid-for", "Validity duration in Go format").Default("26280h").Duration()
 	g.SystemRotateCertsCmd.CAPath = g.SystemRotateCertsCmd.Flag("ca-path", "Use previously exported CA file instead of package").String()
+	g.SystemRotateCertsCmd.CACert = g.SystemRotateCertsCmd.Flag("ca-cert", "Path to the certificate of the new certificate authority to renew certificates with, optionally followed by the certificates of its issuers").String()
+	g.SystemRotateCertsCmd.CAKey = g.SystemRotateCertsCmd.Flag("ca-key", "Path to the private key of the new certificate authority").String()
+	g.SystemRotateCertsCmd.CAPhase = g.SystemRotateCertsCmd.Flag("ca-phase", "Step of the certificate authority replacement to execute: trust (add the new certificate authority to the trusted ones), issue (renew certificates with the new certificate authority) or finalize (stop trusting the previous certificate authority)").Default(caPhaseIssue).Enum(caPhases...)
+	g.SystemRotateCertsCmd.Restart = g.SystemRotateCertsCmd.Flag("restart", "Restart the runtime container to pick up the renewed certificates").Bool()
 
 	g.SystemExportCACmd.CmdClause = g.SystemCmd.Command("export-ca", "Export cluster CA, must be run on a master node").Hidden()
 	g.SystemExportCACmd.ClusterName = g.SystemExportCACmd.Arg("cluster-name", "Name of the local cluster").Required().String()
@@ -642,6 +820,25 @@ func RegisterCommands(app *kingpin.Application) *Application {
 
 	g.SystemStateDirCmd.CmdClause = g.SystemCmd.Command("state-dir", "show where all gravity data is stored on the node").Hidden()
 
+	g.SystemInventoryCmd.CmdClause = g.SystemCmd.Command("inventory", "output hardware and software inventory of the node as JSON").Hidden()
+
+	g.SystemDevicesCmd.CmdClause = g.SystemCmd.Command("devices", "operations on block devices of the node")
+	g.SystemDevicesListCmd.CmdClause = g.SystemDevicesCmd.Command("ls", "List block devices of the node and whether they are eligible for persistent storage")
+	g.SystemDevicesListCmd.Format = common.Format(g.SystemDevicesListCmd.Flag("format", "Output format: text or json.").Default(string(constants.EncodingText)))
+	g.SystemDevicesListCmd.IncludeVendors = g.SystemDevicesListCmd.Flag("include-vendor", "Only include devices from the specified vendor. Can be repeated").Strings()
+	g.SystemDevicesListCmd.ExcludeVendors = g.SystemDevicesListCmd.Flag("exclude-vendor", "Exclude devices from the specified vendor. Can be repeated").Strings()
+	g.SystemDevicesListCmd.IncludePaths = g.SystemDevicesListCmd.Flag("include-path", "Only include devices with paths containing the specified value. Can be repeated").Strings()
+	g.SystemDevicesListCmd.ExcludePaths = g.SystemDevicesListCmd.Flag("exclude-path", "Exclude devices with paths containing the specified value. Can be repeated").Strings()
+
+	g.SystemCertificatesCmd.CmdClause = g.SystemCmd.Command("certificates", "operations on certificates of the node")
+	g.SystemCertificatesListCmd.CmdClause = g.SystemCertificatesCmd.Command("ls", "List certificates of the node and when they expire")
+	g.SystemCertificatesListCmd.Cluster = g.SystemCertificatesListCmd.Flag("cluster", "List certificates of all cluster nodes").Bool()
+	g.SystemCertificatesListCmd.Format = common.Format(g.SystemCertificatesListCmd.Flag("format", "Output format: text or json.").Default(string(constants.EncodingText)))
+
+	g.SystemLogLevelCmd.CmdClause = g.SystemCmd.Command("loglevel", "operations on log levels of cluster controllers")
+	g.SystemLogLevelSetCmd.CmdClause = g.SystemLogLevelCmd.Command("set", "Update log levels of cluster controllers without restarting them")
+	g.SystemLogLevelSetCmd.Levels = g.SystemLogLevelSetCmd.Arg("levels", "Levels as subsystem=level, e.g. fsm=debug. Level without subsystem sets the default level").Required().Strings()
+
 	// manage docker devicemapper environment
 	g.SystemDevicemapperCmd.CmdClause = g.SystemCmd.Command("devicemapper", "manage docker devicemapper environment").Hidden()
 	g.SystemDevicemapperMountCmd.CmdClause = g.SystemDevicemapperCmd.Command("mount", "configure devicemapper environment").Hidden()
@@ -660,6 +857,14 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.GarbageCollectCmd.Manual = g.GarbageCollectCmd.Flag("manual", "Do not start the operation automatically").Short('m').Bool()
 	g.GarbageCollectCmd.Confirmed = g.GarbageCollectCmd.Flag("confirm", "Confirm to remove unrelated docker images").Short('c').Bool()
 
+	// patching node operating systems
+	g.PatchCmd.CmdClause = g.Command("patch", "Patch the operating system on cluster nodes one node at a time")
+	g.PatchCmd.Command = g.PatchCmd.Flag("command", "Shell command to run on each node after it has been drained").String()
+	g.PatchCmd.Reboot = g.PatchCmd.Flag("reboot", "Reboot each node after it has been drained").Bool()
+	g.PatchCmd.Manual = g.PatchCmd.Flag("manual", "Do not start the operation automatically").Short('m').Bool()
+	g.PatchCmd.Confirmed = g.PatchCmd.Flag("confirm", "Do not ask for confirmation").Bool()
+	g.PatchCmd.SkipNodes = g.PatchCmd.Flag("skip-nodes", "Hostname or advertise IP of a node to exclude from the operation. Can be specified multiple times.").Strings()
+
 	// system clean up tasks
 	systemGCCmd := g.SystemCmd.Command("gc", "Run system clean up tasks")
 
@@ -691,13 +896,20 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.EnterCmd.CmdClause = g.Command("enter", "enter planet").Hidden()
 	g.EnterCmd.Args = g.EnterCmd.Arg("arg", "additional arguments to the container").Strings()
 
-	g.ExecCmd.CmdClause = g.Command("exec", "Execute command in the node's Planet container.").Interspersed(false)
+	g.ExecCmd.CmdClause = g.Command("exec", "Execute command in the node's Planet container, or a host command on multiple cluster nodes with --all, --role or --selector.").Interspersed(false)
 	g.ExecCmd.TTY = g.ExecCmd.Flag("tty", "Allocate a pseudo-TTY.").Short('t').Bool()
 	g.ExecCmd.Stdin = g.ExecCmd.Flag("interactive", "Keep stdin open.").Short('i').Bool()
+	g.ExecCmd.All = g.ExecCmd.Flag("all", "Execute the host command on all cluster nodes instead.").Bool()
+	g.ExecCmd.Role = g.ExecCmd.Flag("role", "Execute the host command on the cluster nodes with the specified role (e.g. worker or master) instead.").String()
+	g.ExecCmd.Selector = g.ExecCmd.Flag("selector", "Execute the host command on the cluster nodes with labels matching the label selector (e.g. zone=a,env!=test) instead. Can be combined with --role.").Short('l').String()
+	g.ExecCmd.Parallel = g.ExecCmd.Flag("parallel", "Maximum number of nodes to execute the command on concurrently, with --all, --role or --selector.").Default(strconv.Itoa(defaults.MaxOperationConcurrency)).Int()
+	g.ExecCmd.Timeout = g.ExecCmd.Flag("timeout", "Command execution timeout on each node, with --all, --role or --selector.").Default(defaults.ClusterExecTimeout).Duration()
+	g.ExecCmd.Output = common.Format(g.ExecCmd.Flag("output", "Output format of the aggregated results with --all, --role or --selector: text or json.").Short('o').Default(string(constants.EncodingText)))
 	g.ExecCmd.Cmd = g.ExecCmd.Arg("command", "The command to execute.").Required().String()
 	g.ExecCmd.Args = g.ExecCmd.Arg("arg", "Additional arguments to the command.").Strings()
 
 	g.ShellCmd.CmdClause = g.Command("shell", "Start interactive shell in the node's Planet container.")
+	g.ShellCmd.REPL = g.ShellCmd.Flag("repl", "Start the interactive troubleshooting shell that reuses the local environment across commands.").Bool()
 
 	// resource management
 	g.ResourceCmd.CmdClause = g.Command("resource", "Manage cluster configuration resources.")
@@ -705,10 +917,14 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	// create one or many resources
 	g.ResourceCreateCmd.CmdClause = g.ResourceCmd.Command("create", fmt.Sprintf("Create or update a configuration resource, e.g. gravity resource create oidc.yaml. Supported resources are: %v.", modules.GetResources().SupportedResources()))
 	g.ResourceCreateCmd.Filename = g.ResourceCreateCmd.Arg("filename", "Resource definition file.").String()
-	g.ResourceCreateCmd.Upsert = g.ResourceCreateCmd.Flag("force", "Overwrites a resource if it already exists.").Short('f').Bool()
+	g.ResourceCreateCmd.Upsert = g.ResourceCreateCmd.Flag("force", "Overwrites a resource if it already exists, even if it has been modified since the revision it was read at. Applies persistent storage configuration that fails validation against the cluster block devices.").Short('f').Bool()
 	g.ResourceCreateCmd.User = g.ResourceCreateCmd.Flag("user", "User to create the resource for. Defaults to the currently logged in user.").String()
 	g.ResourceCreateCmd.Manual = g.ResourceCreateCmd.Flag("manual", "Manually execute operation phases for resource which trigger an operation.").Short('m').Bool()
 	g.ResourceCreateCmd.Confirmed = g.ResourceCreateCmd.Flag("confirm", "Do not ask for confirmation.").Bool()
+	g.ResourceCreateCmd.SkipNodes = g.ResourceCreateCmd.Flag("skip-nodes", "Hostname or advertise IP of a node to exclude from the operation triggered by the resource. Can be specified multiple times.").Strings()
+	g.ResourceCreateCmd.Import = g.ResourceCreateCmd.Flag("import", "Apply resources exported with 'gravity resource get all --export' as a single unit, reverting the changes if any of them fails.").Bool()
+	g.ResourceCreateCmd.WithOperations = g.ResourceCreateCmd.Flag("with-operations", "Import the resources that are applied with a cluster operation, e.g. runtimeenvironment or clusterconfiguration. Only applies to --import.").Bool()
+	g.ResourceCreateCmd.DryRun = g.ResourceCreateCmd.Flag("dry-run", "Validate the resource and print the change it would make, including the defaults and the resulting ConfigMap, without persisting anything.").Bool()
 
 	// remove one or many resources
 	g.ResourceRemoveCmd.CmdClause = g.ResourceCmd.Command("rm", fmt.Sprintf("Remove a configuration resource, e.g. gravity resource rm oidc google. Supported resources are: %v.", modules.GetResources().SupportedResourcesToRemove()))
@@ -719,6 +935,7 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.ResourceRemoveCmd.User = g.ResourceRemoveCmd.Flag("user", "User to remove the resource for. Defaults to the currently logged in user.").String()
 	g.ResourceRemoveCmd.Manual = g.ResourceRemoveCmd.Flag("manual", "Manually execute operation phases for resources which trigger an operation.").Short('m').Bool()
 	g.ResourceRemoveCmd.Confirmed = g.ResourceRemoveCmd.Flag("confirm", "Do not ask for confirmation.").Bool()
+	g.ResourceRemoveCmd.DryRun = g.ResourceRemoveCmd.Flag("dry-run", "Validate the request and print the change it would make without persisting anything.").Bool()
 
 	// get resources returns resources
 	g.ResourceGetCmd.CmdClause = g.ResourceCmd.Command("get", fmt.Sprintf("Get configuration resources, e.g. gravity get oidc. Supported resources are: %v.",
@@ -729,11 +946,46 @@ func RegisterCommands(app *kingpin.Application) *Application {
 	g.ResourceGetCmd.Format = common.Format(g.ResourceGetCmd.Flag("format", fmt.Sprintf("Output format: %v.", constants.OutputFormats)).Default(string(constants.EncodingText)))
 	g.ResourceGetCmd.WithSecrets = g.ResourceGetCmd.Flag("with-secrets", "Include secret properties like private keys.").Default("false").Bool()
 	g.ResourceGetCmd.User = g.ResourceGetCmd.Flag("user", "User to display resources for. Defaults to the currently logged in user.").String()
+	g.ResourceGetCmd.Capacity = g.ResourceGetCmd.Flag("capacity", "Display block storage capacity available on cluster nodes. Only applies to persistentstorage.").Bool()
+	g.ResourceGetCmd.Export = g.ResourceGetCmd.Flag("export", "Output all supported resources as a single YAML stream that can be applied with 'gravity resource create --import'. Only applies to all.").Bool()
 
 	g.TopCmd.CmdClause = g.Command("top", "Display cluster monitoring information.")
 	g.TopCmd.Interval = g.TopCmd.Flag("interval", "Interval to display data for, in Go duration format.").Default(defaults.MetricsInterval.String()).Duration()
 	g.TopCmd.Step = g.TopCmd.Flag("step", "Max time b/w two datapoints, in Go duration format.").Default(defaults.MetricsStep.String()).Duration()
 
+	g.AuditCmd.CmdClause = g.Command("audit", "View the log of mutating actions executed on this node.")
+	g.AuditListCmd.CmdClause = g.AuditCmd.Command("ls", "List recorded actions.").Alias("list")
+	g.AuditListCmd.Since = g.AuditListCmd.Flag("since", "Only display actions recorded within the specified duration, in Go duration format (e.g. 24h).").Duration()
+
+	g.NodeCmd.CmdClause = g.Command("node", "Inspect and label cluster nodes.")
+	g.NodeInspectCmd.CmdClause = g.NodeCmd.Command("inspect", "Display the details of a cluster node: its record, profile, health probes, packages and recent operation phases.")
+	g.NodeInspectCmd.Name = g.NodeInspectCmd.Arg("name", "Hostname, advertise IP or cloud node name of the node.").Required().String()
+	g.NodeInspectCmd.Output = common.Format(g.NodeInspectCmd.Flag("output", fmt.Sprintf("Output format: %v.", constants.OutputFormats)).Short('o').Default(string(constants.EncodingText)))
+	g.NodeListCmd.CmdClause = g.NodeCmd.Command("ls", "List cluster nodes.").Alias("list")
+	g.NodeListCmd.Selector = g.NodeListCmd.Flag("selector", "List only the nodes with labels matching the label selector, e.g. zone=a,env!=test.").Short('l').String()
+	g.NodeListCmd.Output = common.Format(g.NodeListCmd.Flag("output", "Output format: text or json.").Short('o').Default(string(constants.EncodingText)))
+	g.NodeLabelCmd.CmdClause = g.NodeCmd.Command("label", "Set or remove labels of a cluster node. Node labels are also applied to the Kubernetes node.")
+	g.NodeLabelCmd.Name = g.NodeLabelCmd.Arg("name", "Hostname, advertise IP or cloud node name of the node.").Required().String()
+	g.NodeLabelCmd.Labels = g.NodeLabelCmd.Arg("labels", "Labels to update: key=value sets the label, key- removes it.").Required().Strings()
+
+	g.InventoryCmd.CmdClause = g.Command("inventory", "Manage the inventory of cluster nodes.")
+	g.InventoryExportCmd.CmdClause = g.InventoryCmd.Command("export", "Export hardware and software inventory of cluster nodes, e.g. for import into a CMDB.")
+	g.InventoryExportCmd.Format = common.Format(g.InventoryExportCmd.Flag("format", "Output format: json or csv.").Default(string(constants.EncodingJSON)))
+
+	g.ClustersCmd.CmdClause = g.Command("clusters", "Manage clusters connected to Gravity Hub.")
+	g.ClustersListCmd.CmdClause = g.ClustersCmd.Command("ls", "List clusters connected to Gravity Hub.").Alias("list")
+	g.ClustersListCmd.OpsCenterURL = g.ClustersListCmd.Flag("ops-url", "Gravity Hub URL. Defaults to the Hub from the current login entry.").String()
+	g.ClustersListCmd.Selector = g.ClustersListCmd.Flag("selector", "List only the clusters with labels matching the label selector, e.g. env=prod,region!=us-east-1.").Short('l').String()
+	g.ClustersListCmd.Format = common.Format(g.ClustersListCmd.Flag("output", "Output format: text or json.").Short('o').Default(string(constants.EncodingText)))
+	g.ClustersStatusCmd.CmdClause = g.ClustersCmd.Command("status", "Display the status of a cluster connected to Gravity Hub.")
+	g.ClustersStatusCmd.Name = g.ClustersStatusCmd.Arg("name", "Cluster name.").Required().String()
+	g.ClustersStatusCmd.OpsCenterURL = g.ClustersStatusCmd.Flag("ops-url", "Gravity Hub URL. Defaults to the Hub from the current login entry.").String()
+	g.ClustersStatusCmd.Format = common.Format(g.ClustersStatusCmd.Flag("output", "Output format: text or json.").Short('o').Default(string(constants.EncodingText)))
+	g.ClustersLabelCmd.CmdClause = g.ClustersCmd.Command("label", "Set or remove labels of a cluster connected to Gravity Hub.")
+	g.ClustersLabelCmd.Name = g.ClustersLabelCmd.Arg("name", "Cluster name.").Required().String()
+	g.ClustersLabelCmd.Labels = g.ClustersLabelCmd.Arg("labels", "Labels to update: key=value sets the label, key- removes it.").Required().Strings()
+	g.ClustersLabelCmd.OpsCenterURL = g.ClustersLabelCmd.Flag("ops-url", "Gravity Hub URL. Defaults to the Hub from the current login entry.").String()
+
 	return g
 }
 
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/system/environ"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// reinstall tears down Gravity on this node preserving the specified
+// application data directories and starts a new installation with
+// the installer this command is run from.
+// The preserved directories are restored by the install operation
+// before the application volumes are configured
+func reinstall(env *localenv.LocalEnvironment, config reinstallConfig) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	if utils.StringInSlice([]string{defaults.GravityBin, defaults.GravityBinAlternate}, executable) {
+		return trace.BadParameter("%v is removed during the teardown, "+
+			"run reinstall from the installer tarball directory", executable)
+	}
+	if !config.confirmed {
+		preserved := "without preserving any data"
+		if len(config.preserve) != 0 {
+			preserved = "preserving " + strings.Join(config.preserve, ", ")
+		}
+		env.Printf("This action will uninstall Gravity from this node %v and install it again.\n", preserved)
+		if err := enforceConfirmation("Are you sure?"); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	logger := log.WithField(trace.Component, "reinstall")
+	// stop the services to make sure the application data
+	// is not modified while it is being preserved
+	if err := environ.UninstallServices(env, logger); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := environ.PreserveDirectories(env, logger, config.preserve); err != nil {
+		return trace.Wrap(err)
+	}
+	// close the backend before attempting to unmount as the open file might
+	// prevent the umount from succeeding
+	env.Backend.Close()
+	err = environ.UninstallNode(environ.UninstallNodeConfig{
+		Printer: env,
+		Logger:  logger,
+	})
+	if err != nil {
+		return trace.Wrap(err, "failed to tear down the node, the preserved data is kept in %v "+
+			"and will be restored by `gravity install` once the teardown issues are resolved",
+			defaults.GravityReinstallDir)
+	}
+	env.PrintStep("Starting installation")
+	args := append([]string{executable, "install"}, config.installArgs...)
+	return trace.ConvertSystemError(syscall.Exec(executable, args, os.Environ()))
+}
+
+type reinstallConfig struct {
+	// preserve lists application data directories to preserve
+	preserve []string
+	// installArgs lists the arguments for the new installation
+	installArgs []string
+	// confirmed suppresses confirmation prompt
+	confirmed bool
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+)
+
+// printNodeRemovalSimulation outputs the impact of removing a node
+func printNodeRemovalSimulation(simulation ops.NodeRemovalSimulation, out io.Writer) {
+	fmt.Fprintf(out, "Dry run: removing %v (%v) from the cluster.\n\n",
+		simulation.Server.Hostname, simulation.Server.AdvertiseIP)
+
+	if len(simulation.Pods) == 0 {
+		fmt.Fprintln(out, "No pods would be evicted.")
+	} else {
+		fmt.Fprintln(out, "Pods that would be evicted:")
+		for _, pod := range simulation.Pods {
+			fmt.Fprintf(out, "  * %v\n", pod)
+		}
+	}
+	fmt.Fprintln(out)
+
+	capacity := simulation.Capacity
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Resource", "Requested By Evicted Pods", "Available On Remaining Nodes"})
+	fmt.Fprintf(w, "CPU\t%vm\t%vm\n", capacity.RequestedCPU, capacity.AvailableCPU)
+	fmt.Fprintf(w, "Memory\t%v\t%v\n",
+		humanize.IBytes(uint64(capacity.RequestedMemory)),
+		humanize.IBytes(uint64(capacity.AvailableMemory)))
+	w.Flush()
+	fmt.Fprintln(out)
+
+	if len(simulation.LocalVolumes) != 0 {
+		fmt.Fprintln(out, "Local persistent volumes that would be lost:")
+		for _, volume := range simulation.LocalVolumes {
+			fmt.Fprintf(out, "  * %v (class %q, claim %q, %v)\n", volume.Name,
+				volume.StorageClass, volume.Claim, humanize.IBytes(uint64(volume.CapacityBytes)))
+		}
+	} else {
+		fmt.Fprintln(out, "No local persistent volumes would be lost.")
+	}
+	fmt.Fprintf(out, "etcd: %v.\n\n", simulation.Etcd.Message)
+
+	for _, pod := range simulation.Pods {
+		if !pod.Rescheduled() {
+			fmt.Fprintf(out, "%v pod %v/%v is not managed by a controller and would not be rescheduled.\n",
+				color.YellowString("WARNING:"), pod.Namespace, pod.Name)
+		}
+	}
+	if !capacity.Sufficient {
+		fmt.Fprintf(out, "%v remaining nodes do not have enough capacity for the evicted pods.\n",
+			color.YellowString("WARNING:"))
+		for _, pod := range capacity.UnschedulablePods {
+			fmt.Fprintf(out, "  * %v\n", pod)
+		}
+	}
+	if len(simulation.LocalVolumes) != 0 {
+		fmt.Fprintf(out, "%v data on %v local persistent volume(s) would be lost.\n",
+			color.YellowString("WARNING:"), len(simulation.LocalVolumes))
+	}
+	if simulation.Etcd.Endangered {
+		fmt.Fprintf(out, "%v etcd quorum would be endangered.\n", color.YellowString("WARNING:"))
+	}
+	if simulation.Safe() {
+		fmt.Fprintln(out, "The node can be removed safely.")
+	}
+	fmt.Fprintln(out, "No changes have been made.")
+}
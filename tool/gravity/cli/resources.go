@@ -19,6 +19,7 @@ package cli
 import (
 	"bytes"
 	"context"
+	"io"
 	"os"
 
 	"github.com/gravitational/gravity/lib/constants"
@@ -36,8 +37,9 @@ import (
 // upsert controls whether the resource is expected to exist.
 // manual controls whether the operation is created in manual mode if resource creation is implemented
 // as a cluster operation.
-// confirmed specifies if the user has explicitly approved the operation
-func createResource(env *localenv.LocalEnvironment, factory LocalEnvironmentFactory, filename string, upsert bool, user string, manual, confirmed bool) error {
+// confirmed specifies if the user has explicitly approved the operation.
+// dryRun only outputs the changes without persisting anything
+func createResource(env *localenv.LocalEnvironment, factory LocalEnvironmentFactory, filename string, upsert bool, user string, manual, confirmed bool, skipNodes []string, dryRun bool) error {
 	operator, err := env.SiteOperator()
 	if err != nil {
 		return trace.Wrap(err)
@@ -62,13 +64,19 @@ func createResource(env *localenv.LocalEnvironment, factory LocalEnvironmentFact
 	}
 	defer reader.Close()
 	control := resources.NewControl(gravityResources)
+	control.Updater = operator
 	err = resources.ForEach(reader, func(resource storage.UnknownResource) error {
+		if err := authorizeResource(env, resource.Kind, upsert, false); err != nil {
+			return trace.Wrap(err)
+		}
 		req := resources.CreateRequest{
 			SiteKey:   cluster.Key(),
 			Upsert:    upsert,
 			Owner:     user,
 			Manual:    manual,
 			Confirmed: confirmed,
+			SkipNodes: skipNodes,
+			DryRun:    dryRun,
 		}
 		return trace.Wrap(control.Create(context.TODO(), bytes.NewReader(resource.Raw), req))
 	})
@@ -83,7 +91,11 @@ func removeResource(
 	force bool,
 	user string,
 	manual, confirmed bool,
+	dryRun bool,
 ) error {
+	if err := authorizeResource(env, kind, false, true); err != nil {
+		return trace.Wrap(err)
+	}
 	operator, err := env.SiteOperator()
 	if err != nil {
 		return trace.Wrap(err)
@@ -110,6 +122,7 @@ func removeResource(
 		Owner:     user,
 		Manual:    manual,
 		Confirmed: confirmed,
+		DryRun:    dryRun,
 	}
 	err = resources.NewControl(gravityResources).Remove(context.TODO(), req)
 	return trace.Wrap(err)
@@ -146,6 +159,88 @@ func getResources(env *localenv.LocalEnvironment, kind string, name string, with
 	return nil
 }
 
+// exportResources outputs all supported cluster resources into w
+// as a single YAML stream.
+// Without secrets, TLS key pair is not exported as it cannot be
+// imported without the private key
+func exportResources(env *localenv.LocalEnvironment, kind string, withSecrets bool, w io.Writer) error {
+	if kind != allResources {
+		return trace.BadParameter("--export only applies to %q", allResources)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := env.LocalCluster()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	gravityResources, err := gravity.New(gravity.Config{
+		Operator:    operator,
+		CurrentUser: env.CurrentUser(),
+		Silent:      env.Silent,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	var kinds []string
+	for _, kind := range storage.SupportedGravityResourcesToExport {
+		if kind == storage.KindTLSKeyPair && !withSecrets {
+			log.Warn("Not exporting TLS key pair without secrets.")
+			continue
+		}
+		kinds = append(kinds, kind)
+	}
+	err = resources.NewControl(gravityResources).Export(w, resources.ExportRequest{
+		SiteKey:     cluster.Key(),
+		Kinds:       kinds,
+		WithSecrets: withSecrets,
+	})
+	return trace.Wrap(err)
+}
+
+// importResources applies the resources exported with exportResources
+// from the specified filename as a single unit
+func importResources(env *localenv.LocalEnvironment, factory LocalEnvironmentFactory, filename, user string, manual, confirmed, withOperations bool) error {
+	for _, kind := range storage.SupportedGravityResourcesToExport {
+		if err := authorizeResource(env, kind, true, false); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := env.LocalCluster()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	gravityResources, err := gravity.New(gravity.Config{
+		Operator:                operator,
+		CurrentUser:             env.CurrentUser(),
+		Silent:                  env.Silent,
+		ClusterOperationHandler: NewDefaultClusterOperationHandler(factory),
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	reader, err := common.GetReader(filename)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer reader.Close()
+	err = resources.NewControl(gravityResources).Import(context.TODO(), reader, resources.ImportRequest{
+		SiteKey:        cluster.Key(),
+		Kinds:          storage.SupportedGravityResourcesToExport,
+		Owner:          user,
+		Manual:         manual,
+		Confirmed:      confirmed,
+		OperationKinds: storage.GravityResourcesUpdatedWithOperations,
+		WithOperations: withOperations,
+	}, resources.ValidateFunc(gravity.Validate))
+	return trace.Wrap(err)
+}
+
 // NewDefaultClusterOperationHandler creates an instance of the default cluster operation
 // handler
 func NewDefaultClusterOperationHandler(factory LocalEnvironmentFactory) clusterOperationHandler {
@@ -173,7 +268,7 @@ func (r clusterOperationHandler) RemoveResource(req resources.RemoveRequest) err
 	switch req.Kind {
 	case storage.KindRuntimeEnvironment:
 		env := storage.NewEnvironment(nil)
-		return trace.Wrap(updateEnviron(context.TODO(), localEnv, updateEnv, env, req.Manual, req.Confirmed))
+		return trace.Wrap(updateEnviron(context.TODO(), localEnv, updateEnv, env, req.Manual, req.Confirmed, nil, ""))
 	case storage.KindClusterConfiguration:
 		return trace.Wrap(resetConfig(context.TODO(), localEnv, updateEnv, req.Manual, req.Confirmed))
 	}
@@ -204,14 +299,17 @@ func (r clusterOperationHandler) UpdateResource(req resources.CreateRequest) err
 			return trace.Wrap(err)
 		}
 		return trace.Wrap(updateEnviron(context.TODO(), localEnv, updateEnv,
-			env, req.Manual, req.Confirmed))
+			env, req.Manual, req.Confirmed, req.SkipNodes, req.Revision))
 	case storage.KindClusterConfiguration:
+		if len(req.SkipNodes) != 0 {
+			return trace.BadParameter("excluding nodes is not supported for %q resource", req.Resource.Kind)
+		}
 		config, err := clusterconfig.Unmarshal(req.Resource.Raw)
 		if err != nil {
 			return trace.Wrap(err)
 		}
 		return trace.Wrap(updateConfig(context.TODO(), localEnv, updateEnv,
-			config, req.Manual, req.Confirmed))
+			config, req.Manual, req.Confirmed, req.Revision))
 	}
 	// unreachable
 	return trace.BadParameter("unknown resource kind %q", req.Resource.Kind)
@@ -220,3 +318,6 @@ func (r clusterOperationHandler) UpdateResource(req resources.CreateRequest) err
 type clusterOperationHandler struct {
 	LocalEnvironmentFactory
 }
+
+// allResources is the pseudo resource kind that refers to all supported resources
+const allResources = "all"
@@ -18,18 +18,26 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/httplib"
+	"github.com/gravitational/gravity/lib/kubernetes"
 	"github.com/gravitational/gravity/lib/loc"
 	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/events"
 	"github.com/gravitational/gravity/lib/pack"
 	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/systemservice"
 	"github.com/gravitational/gravity/lib/users"
 	"github.com/gravitational/gravity/lib/utils"
 
@@ -45,9 +53,27 @@ type rotateOptions struct {
 	validFor time.Duration
 	// caPath is optional CA to use
 	caPath string
+	// caCertPath is the optional path to the certificate of the new
+	// certificate authority to issue the renewed certificates with
+	caCertPath string
+	// caKeyPath is the optional path to the private key of the new
+	// certificate authority
+	caKeyPath string
+	// caPhase is the step of the certificate authority replacement
+	// to execute, one of caPhases
+	caPhase string
+	// restart is whether to restart the runtime container
+	// to pick up the renewed certificates
+	restart bool
 }
 
 func rotateCertificates(env *localenv.LocalEnvironment, o rotateOptions) (err error) {
+	switch o.caPhase {
+	case caPhaseTrust:
+		return trace.Wrap(trustCertAuthority(env, o))
+	case caPhaseFinalize:
+		return trace.Wrap(finalizeCertAuthority(env, o))
+	}
 	var archive utils.TLSArchive
 	if o.caPath != "" {
 		archive, err = readCertAuthorityFromFile(o.caPath)
@@ -58,7 +84,7 @@ func rotateCertificates(env *localenv.LocalEnvironment, o rotateOptions) (err er
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	caKeyPair, err := archive.GetKeyPair(constants.RootKeyPair)
+	ca, err := archive.CertAuthority()
 	if err != nil {
 		return trace.Wrap(err)
 	}
@@ -74,7 +100,19 @@ func rotateCertificates(env *localenv.LocalEnvironment, o rotateOptions) (err er
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	for _, certName := range certNames {
+	if o.caCertPath != "" {
+		ca, err = replaceCertAuthority(env, archive, o, state.SecretDir(stateDir))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = writeCertAuthorityKey(*ca, state.SecretDir(stateDir))
+		if err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	caKeyPair := &ca.TLSKeyPair
+	chain := ca.IssuedCertChain()
+	for _, certName := range ops.RotatedCertificateNames {
 		// read x509 cert from disk
 		cert, err := readCertificate(state.Secret(stateDir, certName+".cert"))
 		if err != nil {
@@ -92,6 +130,7 @@ func rotateCertificates(env *localenv.LocalEnvironment, o rotateOptions) (err er
 		if err != nil {
 			return trace.Wrap(err)
 		}
+		keyPair.CertPEM = append(keyPair.CertPEM, chain...)
 		// save new key pair
 		err = ioutil.WriteFile(state.Secret(stateDir, certName+".cert"), keyPair.CertPEM, defaults.SharedReadMask)
 		if err != nil {
@@ -102,9 +141,222 @@ func rotateCertificates(env *localenv.LocalEnvironment, o rotateOptions) (err er
 			return trace.Wrap(err)
 		}
 	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.WithError(err).Warn("Failed to determine hostname.")
+	}
+	env.EmitAuditEvent(context.TODO(), events.CertificatesRotated, events.Fields{
+		events.FieldNodeHostname: hostname,
+	})
+	if o.restart {
+		return trace.Wrap(scheduleRuntimeRestart(env))
+	}
+	return nil
+}
+
+// scheduleRuntimeRestart schedules the restart of the runtime container
+// so the services pick up the renewed certificates.
+// The restart is delayed so the command can complete first when it is
+// executed remotely, e.g. by the automatic certificate rotation
+func scheduleRuntimeRestart(env *localenv.LocalEnvironment) error {
+	runtimePackage, err := pack.FindRuntimePackage(env.Packages)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	out, err := utils.RunCommand(context.TODO(), log, "systemd-run",
+		fmt.Sprintf("--on-active=%v", int(defaults.ServiceRestartDelay.Seconds())),
+		"/bin/systemctl", "restart", systemservice.PackageServiceName(*runtimePackage))
+	if err != nil {
+		return trace.Wrap(err, "failed to schedule restart of %v: %s", runtimePackage, out)
+	}
+	env.Printf("Scheduled restart of %v in %v\n", runtimePackage, defaults.ServiceRestartDelay)
+	return nil
+}
+
+// trustCertAuthority executes the first step of the certificate authority
+// replacement: it adds the new certificate authority to the root certificate
+// bundle on the node, so the node trusts the certificates issued by both
+// the current and the new certificate authority before any certificate
+// is reissued. On master nodes, it also updates the certificate authority
+// bundle of the service account token secrets
+func trustCertAuthority(env *localenv.LocalEnvironment, o rotateOptions) error {
+	ca, err := readNewCertAuthority(o)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	secretsDir := state.SecretDir(stateDir)
+	err = backupSecrets(env, secretsDir)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	certPath := filepath.Join(secretsDir, defaults.RootCertFilename)
+	bundle, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	bundle = appendCertificate(bundle, ca.CertPEM)
+	err = ioutil.WriteFile(certPath, bundle, defaults.SharedReadMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	env.Printf("Added certificate authority from %v to %v\n", o.caCertPath, certPath)
+	if err := updateServiceAccountCA(env, bundle); err != nil {
+		return trace.Wrap(err)
+	}
+	if o.restart {
+		return trace.Wrap(scheduleRuntimeRestart(env))
+	}
+	return nil
+}
+
+// finalizeCertAuthority executes the last step of the certificate authority
+// replacement: it removes the previous certificate authority from the
+// root certificate bundle on the node once all certificates have been
+// reissued by the certificate authority of the cluster
+func finalizeCertAuthority(env *localenv.LocalEnvironment, o rotateOptions) error {
+	archive, err := readCertAuthorityPackage(env.Packages, o.clusterName)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	ca, err := archive.CertAuthority()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	secretsDir := state.SecretDir(stateDir)
+	err = backupSecrets(env, secretsDir)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	certPath := filepath.Join(secretsDir, defaults.RootCertFilename)
+	err = ioutil.WriteFile(certPath, ca.CertPEM, defaults.SharedReadMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	env.Printf("Replaced %v with the certificate authority of the cluster\n", certPath)
+	if err := updateServiceAccountCA(env, ca.CertPEM); err != nil {
+		return trace.Wrap(err)
+	}
+	if o.restart {
+		return trace.Wrap(scheduleRuntimeRestart(env))
+	}
+	return nil
+}
+
+// updateServiceAccountCA updates the certificate authority bundle
+// of the service account token secrets.
+// The secrets are only updated from master nodes
+func updateServiceAccountCA(env *localenv.LocalEnvironment, bundle []byte) error {
+	client, _, err := httplib.GetClusterKubeClient(env.DNS.Addr())
+	if err != nil {
+		log.WithError(err).Warn("Failed to create Kubernetes client.")
+		env.Println("Not a master node, skip updating service account secrets")
+		return nil
+	}
+	err = kubernetes.UpdateServiceAccountCA(client, bundle)
+	if err != nil {
+		return trace.Wrap(err, "failed to update service account secrets")
+	}
+	env.Println("Updated certificate authority of service account secrets")
 	return nil
 }
 
+// readNewCertAuthority reads and validates the new certificate authority
+// specified in the options
+func readNewCertAuthority(o rotateOptions) (*utils.CertAuthority, error) {
+	if o.caCertPath == "" || o.caKeyPath == "" {
+		return nil, trace.BadParameter("both --ca-cert and --ca-key must be specified")
+	}
+	ca, err := utils.ReadCertAuthority(o.caCertPath, o.caKeyPath)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := ca.Check(time.Now()); err != nil {
+		return nil, trace.Wrap(err, "invalid certificate authority")
+	}
+	return ca, nil
+}
+
+// appendCertificate appends the PEM-encoded certificate to the bundle
+// unless the bundle already contains it
+func appendCertificate(bundle, certPEM []byte) []byte {
+	if bytes.Contains(bundle, bytes.TrimSpace(certPEM)) {
+		return bundle
+	}
+	if len(bundle) != 0 && !bytes.HasSuffix(bundle, []byte("\n")) {
+		bundle = append(bundle, '\n')
+	}
+	return append(bundle, certPEM...)
+}
+
+// replaceCertAuthority replaces the certificate authority in the archive
+// with the one specified in the options and updates the certificate authority
+// package so the nodes renew their certificates with the new certificate authority.
+//
+// The root certificate bundle on the node must already trust the new certificate
+// authority, see trustCertAuthority
+func replaceCertAuthority(env *localenv.LocalEnvironment, archive utils.TLSArchive, o rotateOptions, secretsDir string) (*utils.CertAuthority, error) {
+	ca, err := readNewCertAuthority(o)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	bundle, err := ioutil.ReadFile(filepath.Join(secretsDir, defaults.RootCertFilename))
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	if !bytes.Contains(bundle, bytes.TrimSpace(ca.CertPEM)) {
+		return nil, trace.BadParameter("the node does not trust the new certificate authority yet, "+
+			"execute the command with --ca-phase=%v on every node first", caPhaseTrust)
+	}
+	env.Printf("Using certificate authority from %v\n", o.caCertPath)
+	delete(archive, constants.RootKeyPair)
+	delete(archive, constants.RootChainKeyPair)
+	if err := archive.AddCertAuthority(*ca); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = updateCertAuthorityPackage(env.Packages, o.clusterName, archive)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	clusterPackages, err := env.ClusterPackages()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = updateCertAuthorityPackage(clusterPackages, o.clusterName, archive)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return ca, nil
+}
+
+// writeCertAuthorityKey replaces the certificate authority private key
+// in the specified secrets directory if the node has one.
+// The root certificate bundle is left intact so the node keeps trusting
+// the certificates issued by the previous certificate authority
+// until the replacement is finalized
+func writeCertAuthorityKey(ca utils.CertAuthority, secretsDir string) error {
+	keyPath := filepath.Join(secretsDir, constants.RootKeyPair+".key")
+	if _, err := utils.StatFile(keyPath); err != nil {
+		if trace.IsNotFound(err) {
+			return nil
+		}
+		return trace.Wrap(err)
+	}
+	err := ioutil.WriteFile(keyPath, ca.KeyPEM, defaults.PrivateFileMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	// WriteFile does not change the mode of an existing file
+	return trace.ConvertSystemError(os.Chmod(keyPath, defaults.PrivateFileMask))
+}
+
 func exportCertificateAuthority(env *localenv.LocalEnvironment, clusterName, path string) error {
 	archive, err := readCertAuthorityPackage(env.Packages, clusterName)
 	if err != nil {
@@ -175,8 +427,7 @@ func certToCSR(cert *x509.Certificate) csr.CertificateRequest {
 }
 
 func readCertAuthorityPackage(packages pack.PackageService, clusterName string) (utils.TLSArchive, error) {
-	locator, err := loc.ParseLocator(fmt.Sprintf("%v/%v:0.0.1", clusterName,
-		constants.CertAuthorityPackage))
+	locator, err := certAuthorityLocator(clusterName)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -188,6 +439,28 @@ func readCertAuthorityPackage(packages pack.PackageService, clusterName string)
 	return utils.ReadTLSArchive(reader)
 }
 
+// updateCertAuthorityPackage replaces the contents of the certificate
+// authority package with the specified archive
+func updateCertAuthorityPackage(packages pack.PackageService, clusterName string, archive utils.TLSArchive) error {
+	locator, err := certAuthorityLocator(clusterName)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	reader, err := utils.CreateTLSArchive(archive)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer reader.Close()
+	_, err = packages.UpsertPackage(*locator, reader, pack.WithLabels(
+		map[string]string{pack.PurposeLabel: pack.PurposeCA}))
+	return trace.Wrap(err)
+}
+
+func certAuthorityLocator(clusterName string) (*loc.Locator, error) {
+	return loc.ParseLocator(fmt.Sprintf("%v/%v:0.0.1", clusterName,
+		constants.CertAuthorityPackage))
+}
+
 func readCertAuthorityFromFile(path string) (utils.TLSArchive, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -198,11 +471,17 @@ func readCertAuthorityFromFile(path string) (utils.TLSArchive, error) {
 
 const renewDuration = "26280h" // 3 years
 
-var certNames = []string{
-	constants.APIServerKeyPair,
-	constants.ETCDKeyPair,
-	constants.KubeletKeyPair,
-	constants.ProxyKeyPair,
-	constants.SchedulerKeyPair,
-	constants.KubectlKeyPair,
-}
+const (
+	// caPhaseTrust adds the new certificate authority to the root
+	// certificate bundle on the node
+	caPhaseTrust = "trust"
+	// caPhaseIssue reissues the certificates on the node with
+	// the new certificate authority
+	caPhaseIssue = "issue"
+	// caPhaseFinalize removes the previous certificate authority from
+	// the root certificate bundle on the node
+	caPhaseFinalize = "finalize"
+)
+
+// caPhases lists the steps of the certificate authority replacement
+var caPhases = []string{caPhaseTrust, caPhaseIssue, caPhaseFinalize}
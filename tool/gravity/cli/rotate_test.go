@@ -0,0 +1,65 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/license/authority"
+	"gopkg.in/check.v1"
+)
+
+type RotateSuite struct{}
+
+var _ = check.Suite(&RotateSuite{})
+
+func (s *RotateSuite) TestAppendsCertificateToBundle(c *check.C) {
+	oldCA := []byte("-----BEGIN CERTIFICATE-----\nold\n-----END CERTIFICATE-----")
+	newCA := []byte("-----BEGIN CERTIFICATE-----\nnew\n-----END CERTIFICATE-----\n")
+	bundle := appendCertificate(oldCA, newCA)
+	c.Assert(string(bundle), check.Equals, string(oldCA)+"\n"+string(newCA))
+	// Adding the same certificate again is a no-op
+	c.Assert(string(appendCertificate(bundle, newCA)), check.Equals, string(bundle))
+}
+
+func (s *RotateSuite) TestWritesPrivateCertAuthorityKey(c *check.C) {
+	dir := c.MkDir()
+	keyPath := filepath.Join(dir, constants.RootKeyPair+".key")
+	c.Assert(ioutil.WriteFile(keyPath, []byte("old key"), defaults.SharedReadMask), check.IsNil)
+	ca := utils.CertAuthority{TLSKeyPair: authority.TLSKeyPair{KeyPEM: []byte("new key")}}
+	c.Assert(writeCertAuthorityKey(ca, dir), check.IsNil)
+	data, err := ioutil.ReadFile(keyPath)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "new key")
+	fi, err := os.Stat(keyPath)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Mode().Perm(), check.Equals, os.FileMode(defaults.PrivateFileMask))
+}
+
+func (s *RotateSuite) TestSkipsMissingCertAuthorityKey(c *check.C) {
+	dir := c.MkDir()
+	ca := utils.CertAuthority{TLSKeyPair: authority.TLSKeyPair{KeyPEM: []byte("new key")}}
+	c.Assert(writeCertAuthorityKey(ca, dir), check.IsNil)
+	_, err := os.Stat(filepath.Join(dir, constants.RootKeyPair+".key"))
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
@@ -76,6 +76,11 @@ func Run(g *Application) error {
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	// configure the process to avoid common proxy related installation problems
+	err = ConfigureNoProxy(g, cmd)
+	if err != nil {
+		return trace.Wrap(err)
+	}
 	return Execute(g, cmd, extraArgs)
 }
 
@@ -114,6 +119,8 @@ func InitAndCheck(g *Application, cmd string) error {
 		g.JoinCmd.FullCommand(),
 		g.AutoJoinCmd.FullCommand(),
 		g.UpdateTriggerCmd.FullCommand(),
+		g.UpdateCatchUpCmd.FullCommand(),
+		g.UpdateBinaryCmd.FullCommand(),
 		g.UpdatePlanInitCmd.FullCommand(),
 		g.UpgradeCmd.FullCommand(),
 		g.RPCAgentRunCmd.FullCommand(),
@@ -151,6 +158,8 @@ func InitAndCheck(g *Application, cmd string) error {
 	switch cmd {
 	case g.UpdateCompleteCmd.FullCommand(),
 		g.UpdateTriggerCmd.FullCommand(),
+		g.UpdateCatchUpCmd.FullCommand(),
+		g.UpdateBinaryCmd.FullCommand(),
 		g.RemoveCmd.FullCommand():
 		if err := checkRunningInGravity(g); err != nil {
 			return trace.Wrap(err)
@@ -163,6 +172,8 @@ func InitAndCheck(g *Application, cmd string) error {
 		g.UpgradeCmd.FullCommand(),
 		g.SystemRollbackCmd.FullCommand(),
 		g.SystemUninstallCmd.FullCommand(),
+		g.UninstallCmd.FullCommand(),
+		g.ReinstallCmd.FullCommand(),
 		g.UpdateSystemCmd.FullCommand(),
 		g.RPCAgentShutdownCmd.FullCommand(),
 		g.RPCAgentInstallCmd.FullCommand(),
@@ -173,20 +184,28 @@ func InitAndCheck(g *Application, cmd string) error {
 		g.PlanetEnterCmd.FullCommand(),
 		g.UpdatePlanInitCmd.FullCommand(),
 		g.ResumeCmd.FullCommand(),
-		g.PlanCmd.FullCommand(),
-		g.PlanDisplayCmd.FullCommand(),
 		g.PlanExecuteCmd.FullCommand(),
 		g.PlanRollbackCmd.FullCommand(),
 		g.PlanResumeCmd.FullCommand(),
 		g.PlanCompleteCmd.FullCommand(),
+		g.PlanExportCmd.FullCommand(),
 		g.InstallCmd.FullCommand(),
 		g.JoinCmd.FullCommand(),
 		g.AutoJoinCmd.FullCommand(),
+		g.StageCmd.FullCommand(),
 		g.SystemDevicemapperMountCmd.FullCommand(),
 		g.SystemDevicemapperUnmountCmd.FullCommand(),
 		g.BackupCmd.FullCommand(),
 		g.RestoreCmd.FullCommand(),
+		g.StorageSnapshotCreateCmd.FullCommand(),
+		g.StorageSnapshotRestoreCmd.FullCommand(),
+		g.StorageSnapshotRemoveCmd.FullCommand(),
+		g.StorageMigrationExportCmd.FullCommand(),
+		g.StorageMigrationImportCmd.FullCommand(),
+		g.StorageMigrationPushCmd.FullCommand(),
 		g.GarbageCollectCmd.FullCommand(),
+		g.PatchCmd.FullCommand(),
+		g.UpdateBinaryCmd.FullCommand(),
 		g.SystemGCRegistryCmd.FullCommand(),
 		g.OpsAgentCmd.FullCommand(),
 		g.CheckCmd.FullCommand(),
@@ -233,9 +252,10 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		return statusSite()
 	}
 
+	g.backendSnapshot = g.isInformationalCommand(cmd)
 	var localEnv *localenv.LocalEnvironment
 	switch cmd {
-	case g.InstallCmd.FullCommand(), g.JoinCmd.FullCommand():
+	case g.InstallCmd.FullCommand(), g.JoinCmd.FullCommand(), g.StageCmd.FullCommand():
 		if *g.StateDir != "" {
 			if err := state.SetStateDir(*g.StateDir); err != nil {
 				return trace.Wrap(err)
@@ -247,13 +267,32 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		}
 		defer localEnv.Close()
 	default:
-		localEnv, err = g.NewLocalEnv()
+		if isUserCommand(g, cmd) {
+			localEnv, err = g.NewUserEnv()
+		} else {
+			localEnv, err = g.NewLocalEnv()
+		}
 		if err != nil {
 			return trace.Wrap(err)
 		}
 		defer localEnv.Close()
 	}
 
+	audited := isAuditedCommand(g, cmd)
+	if audited {
+		defer func() {
+			recordAuditEvent(localEnv, cmd, os.Args[1:], err)
+		}()
+	}
+
+	if err := authorizeCommand(localEnv, g, cmd); err != nil {
+		if !audited && trace.IsAccessDenied(err) {
+			// record denied attempts to run the commands that are not audited otherwise
+			recordAuditEvent(localEnv, cmd, os.Args[1:], err)
+		}
+		return trace.Wrap(err)
+	}
+
 	// the following commands must run when Kubernetes is available (can
 	// be inside gravity cluster or generic Kubernetes cluster)
 	switch cmd {
@@ -278,7 +317,12 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			userLogFile:   *g.UserLogFile,
 			serviceName:   *g.OpsAgentCmd.ServiceName,
 			packageAddr:   *g.OpsAgentCmd.PackageAddr,
-			advertiseAddr: g.OpsAgentCmd.AdvertiseAddr.String(),
+			advertiseAddr: advertiseIP(*g.OpsAgentCmd.AdvertiseAddr),
+			advertisePolicy: utils.AdvertiseAddrPolicy{
+				CIDR:         *g.OpsAgentCmd.AdvertiseCIDR,
+				Interface:    *g.OpsAgentCmd.AdvertiseInterface,
+				DefaultRoute: *g.OpsAgentCmd.AdvertiseDefaultRoute,
+			},
 			serverAddr:    *g.OpsAgentCmd.ServerAddr,
 			token:         *g.OpsAgentCmd.Token,
 			vars:          *g.OpsAgentCmd.Vars,
@@ -306,6 +350,8 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			token:         *g.AutoJoinCmd.Token,
 			advertiseAddr: *g.AutoJoinCmd.AdvertiseAddr,
 		})
+	case g.StageCmd.FullCommand():
+		return stage(localEnv, *g.StageCmd.Path, *g.StageCmd.Role)
 	case g.UpdateCheckCmd.FullCommand():
 		return updateCheck(localEnv, *g.UpdateCheckCmd.App)
 	case g.UpdateTriggerCmd.FullCommand():
@@ -318,7 +364,36 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			*g.UpdateTriggerCmd.App,
 			*g.UpdateTriggerCmd.Manual,
 			*g.UpdateTriggerCmd.SkipVersionCheck,
+			*g.UpdateTriggerCmd.SkipNodes,
+			*g.UpdateTriggerCmd.Selector,
+			*g.UpdateTriggerCmd.PauseAfter,
+			*g.UpdateTriggerCmd.Plan,
+			*g.UpdateTriggerCmd.PlanHash,
+			*g.UpdateTriggerCmd.Throttle,
+			*g.UpdateTriggerCmd.SkipCapacityCheck,
+		)
+	case g.UpdateCatchUpCmd.FullCommand():
+		updateEnv, err := g.NewUpdateEnv()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		defer updateEnv.Close()
+		return updateCatchUp(localEnv, updateEnv,
+			*g.UpdateCatchUpCmd.Node,
+			*g.UpdateCatchUpCmd.Manual,
+			*g.UpdateCatchUpCmd.SkipVersionCheck,
 		)
+	case g.UpdateBinaryCmd.FullCommand():
+		updateEnv, err := g.NewUpdateEnv()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		defer updateEnv.Close()
+		return updateBinary(context.TODO(), localEnv, updateEnv, updateBinaryConfig{
+			manual:    *g.UpdateBinaryCmd.Manual,
+			confirmed: *g.UpdateBinaryCmd.Confirmed,
+			skipNodes: *g.UpdateBinaryCmd.SkipNodes,
+		})
 	case g.UpdatePlanInitCmd.FullCommand():
 		updateEnv, err := g.NewUpdateEnv()
 		if err != nil {
@@ -327,6 +402,9 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		defer updateEnv.Close()
 		return initUpdateOperationPlan(localEnv, updateEnv)
 	case g.UpgradeCmd.FullCommand():
+		if *g.UpgradeCmd.Preview != "" {
+			return previewUpdate(localEnv, *g.UpgradeCmd.Preview)
+		}
 		updateEnv, err := g.NewUpdateEnv()
 		if err != nil {
 			return trace.Wrap(err)
@@ -348,6 +426,13 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			*g.UpgradeCmd.App,
 			*g.UpgradeCmd.Manual,
 			*g.UpgradeCmd.SkipVersionCheck,
+			*g.UpgradeCmd.SkipNodes,
+			*g.UpgradeCmd.Selector,
+			*g.UpgradeCmd.PauseAfter,
+			*g.UpgradeCmd.Plan,
+			*g.UpgradeCmd.PlanHash,
+			*g.UpgradeCmd.Throttle,
+			*g.UpgradeCmd.SkipCapacityCheck,
 		)
 	case g.ResumeCmd.FullCommand():
 		return resumeOperation(localEnv, g,
@@ -373,13 +458,16 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			State:       *g.PlanSetCmd.State,
 		})
 	case g.PlanResumeCmd.FullCommand():
-		return resumeOperation(localEnv, g,
-			PhaseParams{
-				Force:            *g.PlanResumeCmd.Force,
-				Timeout:          *g.PlanResumeCmd.PhaseTimeout,
-				SkipVersionCheck: *g.PlanCmd.SkipVersionCheck,
-				OperationID:      *g.PlanCmd.OperationID,
-			})
+		params := PhaseParams{
+			Force:            *g.PlanResumeCmd.Force,
+			Timeout:          *g.PlanResumeCmd.PhaseTimeout,
+			SkipVersionCheck: *g.PlanCmd.SkipVersionCheck,
+			OperationID:      *g.PlanCmd.OperationID,
+		}
+		if *g.PlanResumeCmd.Auto {
+			return resumeOperationAuto(localEnv, g, params)
+		}
+		return resumeOperation(localEnv, g, params)
 	case g.PlanRollbackCmd.FullCommand():
 		return rollbackPhase(localEnv, g,
 			PhaseParams{
@@ -394,20 +482,43 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		if *g.PlanDisplayCmd.Short {
 			outputFormat = constants.EncodingShort
 		}
+		if !runningAsRoot() {
+			return displayClusterOperationPlan(localEnv, *g.PlanCmd.OperationID,
+				*g.PlanDisplayCmd.Phase, outputFormat)
+		}
+		if *g.PlanDisplayCmd.Phase != "" {
+			return displayOperationPhase(localEnv, g, *g.PlanCmd.OperationID,
+				*g.PlanDisplayCmd.Phase, outputFormat, *g.PlanDisplayCmd.Logs)
+		}
+		if *g.PlanDisplayCmd.Logs {
+			return trace.BadParameter("--logs requires --phase")
+		}
 		return displayOperationPlan(localEnv, g,
 			*g.PlanCmd.OperationID, outputFormat)
 	case g.PlanCompleteCmd.FullCommand():
 		return completeOperationPlan(localEnv, g, *g.PlanCmd.OperationID)
+	case g.PlanExportCmd.FullCommand():
+		return exportOperationPlan(localEnv, g, *g.PlanCmd.OperationID)
+	case g.PlanDiffCmd.FullCommand():
+		return diffOperationPlans(localEnv, g, *g.PlanDiffCmd.From,
+			*g.PlanDiffCmd.To, *g.PlanDiffCmd.Output)
+	case g.OperationApproveCmd.FullCommand():
+		return approveOperation(localEnv, *g.OperationApproveCmd.ApprovalID)
+	case g.OperationQueueListCmd.FullCommand():
+		return listQueuedOperations(localEnv, os.Stdout)
 	case g.LeaveCmd.FullCommand():
 		return leave(localEnv, leaveConfig{
-			force:     *g.LeaveCmd.Force,
-			confirmed: *g.LeaveCmd.Confirm,
+			force:             *g.LeaveCmd.Force,
+			confirmed:         *g.LeaveCmd.Confirm,
+			skipCapacityCheck: *g.LeaveCmd.SkipCapacityCheck,
 		})
 	case g.RemoveCmd.FullCommand():
 		return remove(localEnv, removeConfig{
-			server:    *g.RemoveCmd.Node,
-			force:     *g.RemoveCmd.Force,
-			confirmed: *g.RemoveCmd.Confirm,
+			server:            *g.RemoveCmd.Node,
+			force:             *g.RemoveCmd.Force,
+			confirmed:         *g.RemoveCmd.Confirm,
+			dryRun:            *g.RemoveCmd.DryRun,
+			skipCapacityCheck: *g.RemoveCmd.SkipCapacityCheck,
 		})
 	case g.StatusCmd.FullCommand():
 		printOptions := printOptions{
@@ -419,6 +530,15 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		if *g.StatusCmd.Tail {
 			return tailStatus(localEnv, *g.StatusCmd.OperationID)
 		}
+		if *g.StatusCmd.Probes {
+			return statusProbes(localEnv, *g.StatusCmd.Output, os.Stdout)
+		}
+		if *g.StatusCmd.Watch {
+			return statusWatch(localEnv, printOptions, statusWatchConfig{
+				interval:        *g.StatusCmd.Interval,
+				degradedTimeout: *g.StatusCmd.DegradedTimeout,
+			})
+		}
 		if *g.StatusCmd.Seconds != 0 {
 			return statusPeriodic(localEnv, printOptions, *g.StatusCmd.Seconds)
 		} else {
@@ -426,6 +546,14 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		}
 	case g.UpdateUploadCmd.FullCommand():
 		return uploadUpdate(localEnv, *g.UpdateUploadCmd.OpsCenterURL)
+	case g.UpdateDownloadCmd.FullCommand():
+		if *g.UpdateDownloadCmd.Delete {
+			return deleteUpdateDownload(localEnv)
+		}
+		return downloadUpdate(localEnv, updateDownloadConfig{
+			image:  *g.UpdateDownloadCmd.App,
+			hubURL: *g.UpdateDownloadCmd.OpsCenterURL,
+		})
 	case g.AppPackageCmd.FullCommand():
 		return appPackage(localEnv)
 		// app commands
@@ -476,6 +604,10 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		return releaseHistory(localEnv, releaseHistoryConfig{
 			Release: *g.AppHistoryCmd.Release,
 		})
+	case g.AppEnableCmd.FullCommand():
+		return enableComponent(localEnv, *g.AppEnableCmd.Component)
+	case g.AppDisableCmd.FullCommand():
+		return disableComponent(localEnv, *g.AppDisableCmd.Component)
 	case g.AppSyncCmd.FullCommand():
 		return appSync(localEnv, appSyncConfig{
 			Image: *g.AppSyncCmd.Image,
@@ -552,22 +684,31 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		return uninstallAppPackage(localEnv,
 			*g.AppPackageUninstallCmd.Locator)
 	case g.AppPullCmd.FullCommand():
+		url := remoteURL(*g.AppPullCmd.URL, *g.AppPullCmd.OpsCenterURL)
+		if url == "" {
+			return trace.BadParameter("specify the URL to pull from")
+		}
 		return pullApp(localEnv,
 			*g.AppPullCmd.Package,
-			*g.AppPullCmd.OpsCenterURL,
+			url,
 			*g.AppPullCmd.Labels,
 			*g.AppPullCmd.Force)
 	case g.AppPushCmd.FullCommand():
+		url := remoteURL(*g.AppPushCmd.URL, *g.AppPushCmd.OpsCenterURL)
+		if url == "" {
+			return trace.BadParameter("specify the URL to push to")
+		}
 		return pushApp(localEnv,
 			*g.AppPushCmd.Package,
-			*g.AppPushCmd.OpsCenterURL)
-	case g.AppHookCmd.FullCommand():
+			url)
+	case g.AppHookRunCmd.FullCommand():
 		req := appapi.HookRunRequest{
-			Application: *g.AppHookCmd.Package,
-			Hook:        schema.HookType(*g.AppHookCmd.HookName),
-			Env:         *g.AppHookCmd.Env,
+			Application: *g.AppHookRunCmd.Package,
+			Hook:        schema.HookType(*g.AppHookRunCmd.HookName),
+			Env:         *g.AppHookRunCmd.Env,
+			Timeout:     *g.AppHookRunCmd.Timeout,
 		}
-		return outputAppHook(localEnv, req)
+		return runAppHook(localEnv, req)
 	case g.AppUnpackCmd.FullCommand():
 		return unpackAppResources(localEnv,
 			*g.AppUnpackCmd.Package,
@@ -597,7 +738,8 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			*g.PackExportCmd.Locator,
 			*g.PackExportCmd.OpsCenterURL,
 			*g.PackExportCmd.File,
-			os.FileMode(mode))
+			os.FileMode(mode),
+			*g.PackExportCmd.Delta)
 	case g.PackListCmd.FullCommand():
 		return listPackages(localEnv,
 			*g.PackListCmd.Repository,
@@ -621,11 +763,11 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 	case g.PackPushCmd.FullCommand():
 		return pushPackage(localEnv,
 			*g.PackPushCmd.Package,
-			*g.PackPushCmd.OpsCenterURL)
+			remoteURL(*g.PackPushCmd.URL, *g.PackPushCmd.OpsCenterURL))
 	case g.PackPullCmd.FullCommand():
 		return pullPackage(localEnv,
 			*g.PackPullCmd.Package,
-			*g.PackPullCmd.OpsCenterURL,
+			remoteURL(*g.PackPullCmd.URL, *g.PackPullCmd.OpsCenterURL),
 			*g.PackPullCmd.Labels,
 			*g.PackPullCmd.Force)
 	case g.PackLabelsCmd.FullCommand():
@@ -669,7 +811,7 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			*g.APIKeyDeleteCmd.Email,
 			*g.APIKeyDeleteCmd.Token)
 	case g.ReportCmd.FullCommand():
-		return getClusterReport(localEnv, *g.ReportCmd.FilePath)
+		return getClusterReport(localEnv, *g.ReportCmd.FilePath, *g.ReportCmd.Upload)
 	// cluster commands
 	case g.SiteListCmd.FullCommand():
 		return listSites(localEnv, *g.SiteListCmd.OpsCenterURL)
@@ -683,6 +825,54 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		return resetPassword(localEnv)
 	case g.StatusResetCmd.FullCommand():
 		return resetClusterState(localEnv)
+	case g.StatusCheckCmd.FullCommand():
+		return statusCheck(localEnv, *g.StatusCheckCmd.Output, os.Stdout)
+	case g.StorageUsageCmd.FullCommand():
+		return getStorageUsage(localEnv, *g.StorageUsageCmd.Format, os.Stdout)
+	case g.StorageSnapshotCreateCmd.FullCommand():
+		return createVolumeSnapshot(localEnv, volumeSnapshotConfig{
+			name:          *g.StorageSnapshotCreateCmd.Name,
+			namespace:     *g.StorageSnapshotCreateCmd.Namespace,
+			selector:      *g.StorageSnapshotCreateCmd.Selector,
+			claims:        *g.StorageSnapshotCreateCmd.Claims,
+			snapshotClass: *g.StorageSnapshotCreateCmd.SnapshotClass,
+			etcd:          *g.StorageSnapshotCreateCmd.Etcd,
+			timeout:       *g.StorageSnapshotCreateCmd.Timeout,
+		})
+	case g.StorageSnapshotListCmd.FullCommand():
+		return listVolumeSnapshots(localEnv, *g.StorageSnapshotListCmd.Format, os.Stdout)
+	case g.StorageSnapshotRestoreCmd.FullCommand():
+		return restoreVolumeSnapshot(localEnv,
+			*g.StorageSnapshotRestoreCmd.Name,
+			*g.StorageSnapshotRestoreCmd.Suffix)
+	case g.StorageSnapshotRemoveCmd.FullCommand():
+		return removeVolumeSnapshot(localEnv, *g.StorageSnapshotRemoveCmd.Name)
+	case g.StorageMigrationExportCmd.FullCommand():
+		return exportMigration(localEnv, *g.StorageMigrationExportCmd.Path, migrationConfig{
+			namespaces: *g.StorageMigrationExportCmd.Namespaces,
+			claims:     *g.StorageMigrationExportCmd.Claims,
+			rateLimit:  *g.StorageMigrationExportCmd.RateLimit,
+		})
+	case g.StorageMigrationImportCmd.FullCommand():
+		return importMigration(localEnv,
+			*g.StorageMigrationImportCmd.Path,
+			*g.StorageMigrationImportCmd.StorageClasses)
+	case g.StorageMigrationPushCmd.FullCommand():
+		return pushMigration(localEnv,
+			*g.StorageMigrationPushCmd.OpsCenterURL,
+			*g.StorageMigrationPushCmd.Cluster,
+			migrationConfig{
+				namespaces:     *g.StorageMigrationPushCmd.Namespaces,
+				claims:         *g.StorageMigrationPushCmd.Claims,
+				rateLimit:      *g.StorageMigrationPushCmd.RateLimit,
+				storageClasses: *g.StorageMigrationPushCmd.StorageClasses,
+			})
+	case g.WaitCmd.FullCommand():
+		return wait(localEnv, waitConfig{
+			condition: *g.WaitCmd.For,
+			timeout:   *g.WaitCmd.Timeout,
+			interval:  *g.WaitCmd.Interval,
+		})
 	case g.LocalSiteCmd.FullCommand():
 		return getLocalSite(localEnv)
 	// system service commands
@@ -691,6 +881,10 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			clusterName: *g.SystemRotateCertsCmd.ClusterName,
 			validFor:    *g.SystemRotateCertsCmd.ValidFor,
 			caPath:      *g.SystemRotateCertsCmd.CAPath,
+			caCertPath:  *g.SystemRotateCertsCmd.CACert,
+			caKeyPath:   *g.SystemRotateCertsCmd.CAKey,
+			caPhase:     *g.SystemRotateCertsCmd.CAPhase,
+			restart:     *g.SystemRotateCertsCmd.Restart,
 		})
 	case g.SystemExportCACmd.FullCommand():
 		return exportCertificateAuthority(localEnv,
@@ -727,12 +921,15 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			*g.SystemRollbackCmd.WithStatus)
 	case g.SystemStepDownCmd.FullCommand():
 		return stepDown(localEnv)
+	case g.SystemLogLevelSetCmd.FullCommand():
+		return setLogLevels(localEnv, *g.SystemLogLevelSetCmd.Levels)
 	case g.BackupCmd.FullCommand():
 		return backup(localEnv,
 			*g.BackupCmd.Tarball,
 			*g.BackupCmd.Timeout,
 			*g.BackupCmd.Follow,
-			*g.Silent)
+			*g.Silent,
+			*g.BackupCmd.Snapshot)
 	case g.RestoreCmd.FullCommand():
 		return restore(localEnv,
 			*g.RestoreCmd.Tarball,
@@ -769,6 +966,20 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			*g.SystemServiceStatusCmd.Name)
 	case g.SystemUninstallCmd.FullCommand():
 		return systemUninstall(localEnv, *g.SystemUninstallCmd.Confirmed)
+	case g.UninstallCmd.FullCommand():
+		return uninstall(localEnv, uninstallConfig{
+			all:          *g.UninstallCmd.All,
+			node:         *g.UninstallCmd.Node,
+			preserveData: *g.UninstallCmd.PreserveData,
+			confirmed:    *g.UninstallCmd.Confirmed,
+			keepTeleport: *g.UninstallCmd.KeepTeleport,
+		})
+	case g.ReinstallCmd.FullCommand():
+		return reinstall(localEnv, reinstallConfig{
+			preserve:    *g.ReinstallCmd.Preserve,
+			installArgs: extraArgs,
+			confirmed:   *g.ReinstallCmd.Confirmed,
+		})
 	case g.SystemReportCmd.FullCommand():
 		return systemReport(localEnv,
 			*g.SystemReportCmd.Filter,
@@ -776,12 +987,36 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			os.Stdout)
 	case g.SystemStateDirCmd.FullCommand():
 		return printStateDir()
+	case g.SystemInventoryCmd.FullCommand():
+		return printNodeInventory(os.Stdout)
+	case g.SystemDevicesListCmd.FullCommand():
+		return listDevices(newDeviceFilter(g.SystemDevicesListCmd),
+			*g.SystemDevicesListCmd.Format, os.Stdout)
+	case g.SystemCertificatesListCmd.FullCommand():
+		if *g.SystemCertificatesListCmd.Cluster {
+			return listClusterCertificates(localEnv,
+				*g.SystemCertificatesListCmd.Format, os.Stdout)
+		}
+		return listNodeCertificates(*g.SystemCertificatesListCmd.Format, os.Stdout)
 	case g.SystemExportRuntimeJournalCmd.FullCommand():
 		return exportRuntimeJournal(localEnv, *g.SystemExportRuntimeJournalCmd.OutputFile)
 	case g.SystemStreamRuntimeJournalCmd.FullCommand():
 		return streamRuntimeJournal(localEnv)
 	case g.GarbageCollectCmd.FullCommand():
 		return garbageCollect(localEnv, *g.GarbageCollectCmd.Manual, *g.GarbageCollectCmd.Confirmed)
+	case g.PatchCmd.FullCommand():
+		updateEnv, err := g.NewUpdateEnv()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		defer updateEnv.Close()
+		return patchNodes(context.TODO(), localEnv, updateEnv, patchConfig{
+			command:   *g.PatchCmd.Command,
+			reboot:    *g.PatchCmd.Reboot,
+			manual:    *g.PatchCmd.Manual,
+			confirmed: *g.PatchCmd.Confirmed,
+			skipNodes: *g.PatchCmd.SkipNodes,
+		})
 	case g.SystemGCJournalCmd.FullCommand():
 		return removeUnusedJournalFiles(localEnv,
 			*g.SystemGCJournalCmd.MachineIDFile,
@@ -797,12 +1032,26 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 	case g.PlanetEnterCmd.FullCommand(), g.EnterCmd.FullCommand():
 		return planetEnter(localEnv, extraArgs)
 	case g.ExecCmd.FullCommand():
+		if *g.ExecCmd.All || *g.ExecCmd.Role != "" || *g.ExecCmd.Selector != "" {
+			return clusterExec(localEnv, clusterExecConfig{
+				all:      *g.ExecCmd.All,
+				role:     *g.ExecCmd.Role,
+				selector: *g.ExecCmd.Selector,
+				parallel: *g.ExecCmd.Parallel,
+				timeout:  *g.ExecCmd.Timeout,
+				command:  append([]string{*g.ExecCmd.Cmd}, *g.ExecCmd.Args...),
+				format:   *g.ExecCmd.Output,
+			})
+		}
 		return planetExec(localEnv,
 			*g.ExecCmd.TTY,
 			*g.ExecCmd.Stdin,
 			*g.ExecCmd.Cmd,
 			*g.ExecCmd.Args)
 	case g.ShellCmd.FullCommand():
+		if *g.ShellCmd.REPL {
+			return runShell(context.TODO(), newShell(localEnv, g), os.Stdin, os.Stdout)
+		}
 		return planetShell(localEnv)
 	case g.PlanetStatusCmd.FullCommand():
 		return getPlanetStatus(localEnv, extraArgs)
@@ -821,13 +1070,33 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		return resetUser(localEnv,
 			*g.UsersResetCmd.Name,
 			*g.UsersResetCmd.TTL)
+	case g.LicenseShowCmd.FullCommand():
+		return showLicense(localEnv, *g.LicenseShowCmd.Output)
+	case g.LicenseInstallCmd.FullCommand():
+		return installLicense(localEnv, *g.LicenseInstallCmd.Path)
 	case g.ResourceCreateCmd.FullCommand():
+		if *g.ResourceCreateCmd.Import {
+			if *g.ResourceCreateCmd.DryRun {
+				return trace.BadParameter("--dry-run cannot be used with --import")
+			}
+			return importResources(localEnv, g,
+				*g.ResourceCreateCmd.Filename,
+				*g.ResourceCreateCmd.User,
+				*g.ResourceCreateCmd.Manual,
+				*g.ResourceCreateCmd.Confirmed,
+				*g.ResourceCreateCmd.WithOperations)
+		}
+		if *g.ResourceCreateCmd.WithOperations {
+			return trace.BadParameter("--with-operations can only be used with --import")
+		}
 		return createResource(localEnv, g,
 			*g.ResourceCreateCmd.Filename,
 			*g.ResourceCreateCmd.Upsert,
 			*g.ResourceCreateCmd.User,
 			*g.ResourceCreateCmd.Manual,
-			*g.ResourceCreateCmd.Confirmed)
+			*g.ResourceCreateCmd.Confirmed,
+			*g.ResourceCreateCmd.SkipNodes,
+			*g.ResourceCreateCmd.DryRun)
 	case g.ResourceRemoveCmd.FullCommand():
 		return removeResource(localEnv, g,
 			*g.ResourceRemoveCmd.Kind,
@@ -835,8 +1104,21 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 			*g.ResourceRemoveCmd.Force,
 			*g.ResourceRemoveCmd.User,
 			*g.ResourceRemoveCmd.Manual,
-			*g.ResourceRemoveCmd.Confirmed)
+			*g.ResourceRemoveCmd.Confirmed,
+			*g.ResourceRemoveCmd.DryRun)
 	case g.ResourceGetCmd.FullCommand():
+		if *g.ResourceGetCmd.Capacity {
+			return getStorageCapacity(localEnv,
+				*g.ResourceGetCmd.Kind,
+				*g.ResourceGetCmd.Format,
+				os.Stdout)
+		}
+		if *g.ResourceGetCmd.Export {
+			return exportResources(localEnv,
+				*g.ResourceGetCmd.Kind,
+				*g.ResourceGetCmd.WithSecrets,
+				os.Stdout)
+		}
 		return getResources(localEnv,
 			*g.ResourceGetCmd.Kind,
 			*g.ResourceGetCmd.Name,
@@ -868,11 +1150,33 @@ func Execute(g *Application, cmd string, extraArgs []string) (err error) {
 		return checkManifest(localEnv,
 			*g.CheckCmd.ManifestFile,
 			*g.CheckCmd.Profile,
-			*g.CheckCmd.AutoFix)
+			*g.CheckCmd.AutoFix,
+			*g.CheckCmd.Format)
+	case g.NetworkMTUTestCmd.FullCommand():
+		return testPathMTU(*g.NetworkMTUTestCmd.Addr, os.Stdout)
 	case g.TopCmd.FullCommand():
 		return top(localEnv,
 			*g.TopCmd.Interval,
 			*g.TopCmd.Step)
+	case g.AuditListCmd.FullCommand():
+		return listAuditEvents(localEnv, *g.AuditListCmd.Since)
+	case g.NodeInspectCmd.FullCommand():
+		return inspectNode(localEnv, *g.NodeInspectCmd.Name, *g.NodeInspectCmd.Output, os.Stdout)
+	case g.NodeListCmd.FullCommand():
+		return listNodes(localEnv, *g.NodeListCmd.Selector, *g.NodeListCmd.Output, os.Stdout)
+	case g.NodeLabelCmd.FullCommand():
+		return labelNode(localEnv, *g.NodeLabelCmd.Name, *g.NodeLabelCmd.Labels)
+	case g.InventoryExportCmd.FullCommand():
+		return exportInventory(localEnv, *g.InventoryExportCmd.Format, os.Stdout)
+	case g.ClustersListCmd.FullCommand():
+		return listClusters(localEnv, *g.ClustersListCmd.OpsCenterURL,
+			*g.ClustersListCmd.Selector, *g.ClustersListCmd.Format, os.Stdout)
+	case g.ClustersStatusCmd.FullCommand():
+		return remoteClusterStatus(localEnv, *g.ClustersStatusCmd.OpsCenterURL,
+			*g.ClustersStatusCmd.Name, *g.ClustersStatusCmd.Format, os.Stdout)
+	case g.ClustersLabelCmd.FullCommand():
+		return labelCluster(localEnv, *g.ClustersLabelCmd.OpsCenterURL,
+			*g.ClustersLabelCmd.Name, *g.ClustersLabelCmd.Labels)
 	}
 	return trace.NotFound("unknown command %v", cmd)
 }
@@ -959,7 +1263,7 @@ func checkRunningInGravity(environ LocalEnvironmentFactory) error {
 }
 
 func checkRunningAsRoot() error {
-	if os.Geteuid() != 0 {
+	if !runningAsRoot() {
 		return trace.BadParameter("this command should be run as root")
 	}
 	return nil
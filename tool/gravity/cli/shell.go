@@ -0,0 +1,170 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// shellPrompt is the prompt displayed by the interactive shell
+const shellPrompt = "gravity> "
+
+// shellCommand is a command available in the interactive shell
+type shellCommand struct {
+	// name is the command name, optionally with a subcommand, e.g. "package ls"
+	name string
+	// usage describes the command arguments
+	usage string
+	// help is a short description of the command
+	help string
+	// run executes the command with the specified arguments
+	run func(ctx context.Context, args []string) error
+}
+
+// shell is an interactive troubleshooting shell.
+// All commands share the same local environment so the local state database
+// and the cluster credentials are only initialized once per session
+type shell struct {
+	commands []shellCommand
+}
+
+// newShell returns a new shell with commands bound to the specified local environment
+func newShell(env *localenv.LocalEnvironment, environ LocalEnvironmentFactory) *shell {
+	return &shell{
+		commands: []shellCommand{
+			{
+				name:  "package ls",
+				usage: "[repository]",
+				help:  "List packages in the local package service",
+				run: func(ctx context.Context, args []string) error {
+					var repository string
+					if len(args) != 0 {
+						repository = args[0]
+					}
+					return listPackages(env, repository, "")
+				},
+			},
+			{
+				name:  "plan",
+				usage: "[operation-id]",
+				help:  "Display the plan of the last or the specified operation",
+				run: func(ctx context.Context, args []string) error {
+					var operationID string
+					if len(args) != 0 {
+						operationID = args[0]
+					}
+					return displayOperationPlan(env, environ, operationID, constants.EncodingText)
+				},
+			},
+			{
+				name: "status",
+				help: "Display the cluster status",
+				run: func(ctx context.Context, args []string) error {
+					return status(env, printOptions{format: constants.EncodingText})
+				},
+			},
+			{
+				name: "etcd health",
+				help: "Display the health of the etcd cluster",
+				run: func(ctx context.Context, args []string) error {
+					out, err := utils.RunCommand(ctx, log,
+						utils.PlanetCommandArgs(defaults.EtcdCtlBin, "cluster-health")...)
+					env.Print(string(out))
+					return trace.Wrap(err)
+				},
+			},
+		},
+	}
+}
+
+// lookup finds the command matching the specified input.
+// Returns the command and the remaining arguments
+func (r *shell) lookup(fields []string) (*shellCommand, []string, error) {
+	var match *shellCommand
+	var matchLen int
+	for i, cmd := range r.commands {
+		words := strings.Fields(cmd.name)
+		if len(words) > len(fields) || len(words) <= matchLen {
+			continue
+		}
+		if strings.Join(fields[:len(words)], " ") == cmd.name {
+			match = &r.commands[i]
+			matchLen = len(words)
+		}
+	}
+	if match == nil {
+		return nil, nil, trace.NotFound("unknown command %q, type 'help' for the list of commands",
+			strings.Join(fields, " "))
+	}
+	return match, fields[matchLen:], nil
+}
+
+// printHelp outputs the list of available commands to w
+func (r *shell) printHelp(w io.Writer) {
+	t := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, cmd := range r.commands {
+		fmt.Fprintf(t, "%v\t%v\n", strings.TrimSpace(cmd.name+" "+cmd.usage), cmd.help)
+	}
+	fmt.Fprintf(t, "help\tDisplay this help\n")
+	fmt.Fprintf(t, "exit\tExit the shell\n")
+	t.Flush()
+}
+
+// runShell reads commands from in and executes them until the input
+// is exhausted or the user exits the shell.
+// Command failures are reported to out and do not terminate the session
+func runShell(ctx context.Context, sh *shell, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, shellPrompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return trace.Wrap(scanner.Err())
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			sh.printHelp(out)
+			continue
+		}
+		cmd, args, err := sh.lookup(fields)
+		if err != nil {
+			fmt.Fprintln(out, trace.UserMessage(err))
+			continue
+		}
+		if err := cmd.run(ctx, args); err != nil {
+			fmt.Fprintf(out, "%v failed: %v\n", cmd.name, trace.UserMessage(err))
+		}
+	}
+}
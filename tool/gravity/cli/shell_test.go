@@ -0,0 +1,59 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func (*S) TestShellDispatchesCommands(c *check.C) {
+	var calls []string
+	record := func(name string) func(context.Context, []string) error {
+		return func(_ context.Context, args []string) error {
+			calls = append(calls, strings.TrimSpace(name+" "+strings.Join(args, " ")))
+			return nil
+		}
+	}
+	sh := &shell{
+		commands: []shellCommand{
+			{name: "package ls", run: record("package ls")},
+			{name: "plan", run: record("plan")},
+			{name: "status", run: func(context.Context, []string) error {
+				return trace.BadParameter("cluster is degraded")
+			}},
+		},
+	}
+	in := strings.NewReader(`
+package ls gravitational.io
+plan 1234
+package rm foo
+status
+exit
+plan
+`)
+	var out bytes.Buffer
+	err := runShell(context.TODO(), sh, in, &out)
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.DeepEquals, []string{"package ls gravitational.io", "plan 1234"})
+	c.Assert(out.String(), check.Matches, `(?s).*unknown command "package rm foo".*`)
+	c.Assert(out.String(), check.Matches, `(?s).*status failed: cluster is degraded.*`)
+}
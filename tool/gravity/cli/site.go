@@ -30,6 +30,7 @@ import (
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/logging"
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/process"
 	gcfg "github.com/gravitational/gravity/lib/processconfig"
@@ -121,12 +122,19 @@ func listSites(env *localenv.LocalEnvironment, opsCenterURL string) error {
 	return nil
 }
 
-func getClusterReport(env *localenv.LocalEnvironment, targetFile string) error {
-	f, err := os.Create(targetFile)
-	if err != nil {
-		return trace.Wrap(err)
+// getClusterReport collects the cluster diagnostics report into targetFile
+// or streams it to stdout if targetFile is "-". If opsCenterURL is specified,
+// the report is uploaded to that Ops Center as well
+func getClusterReport(env *localenv.LocalEnvironment, targetFile, opsCenterURL string) error {
+	var w io.Writer = os.Stdout
+	if targetFile != "-" {
+		f, err := os.Create(targetFile)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		defer f.Close()
+		w = f
 	}
-	defer f.Close()
 
 	operator, err := env.SiteOperator()
 	if err != nil {
@@ -147,11 +155,26 @@ func getClusterReport(env *localenv.LocalEnvironment, targetFile string) error {
 	}
 	defer report.Close()
 
-	if _, err := io.Copy(f, report); err != nil {
+	if opsCenterURL != "" {
+		opsCenter, err := env.OperatorService(opsCenterURL)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		err = opsCenter.UploadClusterReport(site.Key(), io.TeeReader(report, w))
+		if err != nil {
+			return trace.Wrap(err, "failed to upload report to %v", opsCenterURL)
+		}
+	} else if _, err := io.Copy(w, report); err != nil {
 		return trace.Wrap(err)
 	}
 
+	if targetFile == "-" {
+		return nil
+	}
 	fmt.Printf("report for %v exported to %v\n", site, targetFile)
+	if opsCenterURL != "" {
+		fmt.Printf("report uploaded to %v\n", opsCenterURL)
+	}
 	return nil
 }
 
@@ -313,6 +336,62 @@ func resetClusterState(app *localenv.LocalEnvironment) error {
 	return nil
 }
 
+// approveOperation approves the operation with the specified approval request
+func approveOperation(env *localenv.LocalEnvironment, approvalID string) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	approval, err := operator.ApproveOperation(context.TODO(), ops.ApproveOperationRequest{
+		AccountID:  cluster.AccountID,
+		SiteDomain: cluster.Domain,
+		ApprovalID: approvalID,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	env.Printf("approved %v, it can be retried until %v\n", approval,
+		approval.Expires.Format(constants.HumanDateFormatSeconds))
+	return nil
+}
+
+// setLogLevels updates log levels of cluster controllers
+func setLogLevels(env *localenv.LocalEnvironment, items []string) error {
+	levels, err := logging.ParseLevels(items)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	err = operator.UpdateLogLevels(context.TODO(), ops.UpdateLogLevelsRequest{
+		AccountID:  cluster.AccountID,
+		SiteDomain: cluster.Domain,
+		Levels:     levels,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	env.Printf("log levels updated: %v\n", levels)
+	return nil
+}
+
 func stepDown(env *localenv.LocalEnvironment) error {
 	operator, err := env.SiteOperator()
 	if err != nil {
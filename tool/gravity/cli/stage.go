@@ -0,0 +1,115 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"path/filepath"
+
+	appservice "github.com/gravitational/gravity/lib/app/service"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/install"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/schema"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+)
+
+// stage places the packages of the cluster image unpacked in path into
+// the node's local package service and unpacks the runtime packages so that
+// a subsequent join only has to pull the configuration packages from the cluster.
+//
+// Staging is meant to be done when building a machine image for the nodes
+// that join the cluster later, e.g. autoscaled workers
+func stage(env *localenv.LocalEnvironment, path, role string) error {
+	if path == "" {
+		path = filepath.Dir(utils.Exe.Path)
+	}
+	installerEnv, err := localenv.NewLocalEnvironment(localenv.LocalEnvironmentArgs{
+		StateDir:        path,
+		ReadonlyBackend: true,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer installerEnv.Close()
+	app, err := install.GetApp(installerEnv.Apps)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return trace.NotFound("%v does not contain a cluster image, "+
+				"provide a path to the unpacked cluster image tarball", path)
+		}
+		return trace.Wrap(err)
+	}
+	locators, err := stagedPackages(app.Manifest, role)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Staging packages of %v", app.Package)
+	_, err = appservice.PullApp(appservice.AppPullRequest{
+		SrcPack:  installerEnv.Packages,
+		SrcApp:   installerEnv.Apps,
+		DstPack:  env.Packages,
+		DstApp:   env.Apps,
+		Package:  app.Package,
+		Progress: env.Reporter,
+	})
+	if err != nil && !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	for _, locator := range locators {
+		env.PrintStep("Unpacking %v", locator)
+		if err := pack.Stage(env.Packages, locator); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	env.PrintStep("Packages have been staged, the node is ready to join the cluster")
+	return nil
+}
+
+// stagedPackages returns the packages to unpack ahead of time on a node
+// with the specified role or on a node with any role if role is empty
+func stagedPackages(manifest schema.Manifest, role string) (locators []loc.Locator, err error) {
+	if role != "" {
+		runtimePackage, err := manifest.RuntimePackageForProfile(role)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		locators = append(locators, *runtimePackage)
+	} else {
+		for _, profile := range manifest.NodeProfiles {
+			runtimePackage, err := manifest.RuntimePackage(profile)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			locators = append(locators, *runtimePackage)
+		}
+	}
+	for _, name := range []string{constants.TeleportPackage, constants.WebAssetsPackage} {
+		locator, err := manifest.Dependencies.ByName(name)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		locators = append(locators, *locator)
+	}
+	return loc.Deduplicate(locators), nil
+}
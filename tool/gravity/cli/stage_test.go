@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/schema"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+type StageSuite struct{}
+
+var _ = check.Suite(&StageSuite{})
+
+func (*StageSuite) TestStagedPackages(c *check.C) {
+	planet := loc.MustParseLocator("gravitational.io/planet:0.0.1")
+	gpuPlanet := loc.MustParseLocator("gravitational.io/planet-gpu:0.0.1")
+	teleport := loc.MustParseLocator("gravitational.io/teleport:3.0.5")
+	manifest := schema.Manifest{
+		SystemOptions: &schema.SystemOptions{
+			Dependencies: schema.SystemDependencies{
+				Runtime: &schema.Dependency{Locator: planet},
+			},
+		},
+		Dependencies: schema.Dependencies{
+			Packages: []schema.Dependency{
+				{Locator: loc.MustParseLocator("gravitational.io/gravity:5.5.0")},
+				{Locator: teleport},
+			},
+		},
+		NodeProfiles: schema.NodeProfiles{
+			{Name: "master"},
+			{Name: "worker"},
+			{Name: "gpu", SystemOptions: &schema.SystemOptions{
+				Dependencies: schema.SystemDependencies{
+					Runtime: &schema.Dependency{Locator: gpuPlanet},
+				},
+			}},
+		},
+	}
+
+	locators, err := stagedPackages(manifest, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(locators, check.DeepEquals, []loc.Locator{planet, gpuPlanet, teleport})
+
+	locators, err = stagedPackages(manifest, "gpu")
+	c.Assert(err, check.IsNil)
+	c.Assert(locators, check.DeepEquals, []loc.Locator{gpuPlanet, teleport})
+
+	_, err = stagedPackages(manifest, "db")
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+}
@@ -17,11 +17,13 @@ limitations under the License.
 package cli
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -32,27 +34,23 @@ import (
 	"github.com/gravitational/gravity/lib/ops"
 	"github.com/gravitational/gravity/lib/schema"
 	statusapi "github.com/gravitational/gravity/lib/status"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+	"github.com/gravitational/gravity/tool/common"
 	"github.com/prometheus/alertmanager/api/v2/models"
 
 	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	pb "github.com/gravitational/satellite/agent/proto/agentpb"
 	"github.com/gravitational/trace"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func status(env *localenv.LocalEnvironment, printOptions printOptions) error {
-	clusterOperator, err := env.SiteOperator()
-	if err != nil {
-		log.WithError(err).Warn("Failed to create cluster operator.")
-	}
-	clusterEnv, err := env.NewClusterEnvironment()
+	operator, err := newStatusOperator(env)
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	operator := statusOperator{
-		Operator:        clusterEnv.Operator,
-		clusterOperator: clusterOperator,
-	}
 
 	status, err := statusOnce(context.TODO(), operator, printOptions.operationID, env)
 	if err == nil {
@@ -98,6 +96,112 @@ func status(env *localenv.LocalEnvironment, printOptions printOptions) error {
 	return trace.Wrap(printStatus(operator, clusterStatus, printOptions))
 }
 
+// statusCheck runs the cluster health check and returns an error with
+// the Nagios-compatible exit code if the cluster is not healthy
+func statusCheck(env *localenv.LocalEnvironment, format constants.Format, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), defaults.AgentHealthCheckTimeout)
+	defer cancel()
+
+	var servers []storage.Server
+	operator, err := env.SiteOperator()
+	if err == nil {
+		cluster, err := operator.GetLocalSite()
+		if err == nil {
+			servers = cluster.ClusterState.Servers
+		}
+	}
+	agent, err := statusapi.FromPlanetAgent(ctx, servers)
+	if err != nil {
+		log.WithError(err).Warn("Failed to query status from planet agent.")
+	}
+	result := statusapi.NewCheck(agent)
+	switch format {
+	case constants.EncodingJSON:
+		err = printJSON(result, w)
+	case constants.EncodingText:
+		_, err = fmt.Fprintln(w, result.String())
+	default:
+		return trace.BadParameter("unsupported output format %q", format)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return utils.NewExitCodeError(int(result.Code))
+}
+
+// statusProbes lists the last results of the health probes on cluster nodes.
+// The probes are queried via the cluster controller with a fallback
+// to the local cluster state if the controller is not available
+func statusProbes(env *localenv.LocalEnvironment, format constants.Format, w io.Writer) error {
+	probes, err := clusterProbes(env)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return printJSON(probes, w)
+	case constants.EncodingText:
+		printProbes(*probes, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+func clusterProbes(env *localenv.LocalEnvironment) (*ops.ClusterProbes, error) {
+	operator, err := env.SiteOperator()
+	if err == nil {
+		var probes *ops.ClusterProbes
+		probes, err = localClusterProbes(operator)
+		if err == nil {
+			return probes, nil
+		}
+	}
+	log.WithError(err).Warn("Failed to query health probes from cluster controller.")
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return localClusterProbes(clusterEnv.Operator)
+}
+
+func localClusterProbes(operator ops.Operator) (*ops.ClusterProbes, error) {
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	probes, err := operator.GetClusterProbes(cluster.Key())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return probes, nil
+}
+
+func printProbes(probes ops.ClusterProbes, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Node", "Probe", "Status", "Detail", "Remediation"})
+	for _, probe := range probes.Probes {
+		status := probe.Status
+		if probe.IsFailed() && probe.Severity != "" {
+			status = fmt.Sprintf("%v (%v)", probe.Status, probe.Severity)
+		}
+		detail := probe.Detail
+		if probe.Error != "" {
+			detail = strings.TrimSpace(fmt.Sprintf("%v %v", detail, probe.Error))
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n",
+			formatValue(probe.Node),
+			probe.Checker,
+			status,
+			formatValue(detail),
+			formatValue(probe.Remediation))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\nCollected at %v in %v.\n",
+		probes.Timestamp.Format(constants.HumanDateFormatSeconds),
+		probes.Duration.Round(time.Millisecond))
+}
+
 func tailStatus(env *localenv.LocalEnvironment, operationID string) error {
 	operator, err := env.SiteOperator()
 	if err != nil {
@@ -154,6 +258,136 @@ func statusPeriodic(env *localenv.LocalEnvironment, printOptions printOptions, s
 	}
 }
 
+// statusWatchConfig defines the behavior of the status watch mode
+type statusWatchConfig struct {
+	// interval specifies the status refresh interval
+	interval time.Duration
+	// degradedTimeout specifies the maximum duration the cluster is allowed
+	// to stay degraded before the watch exits with an error.
+	// Zero value disables the check
+	degradedTimeout time.Duration
+}
+
+// statusWatch continuously redraws the cluster status in place with the configured
+// interval until interrupted or until the cluster has been degraded for longer
+// than the configured timeout
+func statusWatch(env *localenv.LocalEnvironment, printOptions printOptions, config statusWatchConfig) error {
+	if config.interval <= 0 {
+		return trace.BadParameter("refresh interval must be positive: %v", config.interval)
+	}
+	if printOptions.format != constants.EncodingText {
+		return trace.BadParameter("watch mode only supports text output")
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	redraw := terminal.IsTerminal(int(os.Stdout.Fd()))
+	tracker := degradationTracker{timeout: config.degradedTimeout}
+	ticker := time.NewTicker(config.interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		status := watchStatusOnce(operator, printOptions.operationID, env)
+		var buf bytes.Buffer
+		if redraw {
+			buf.WriteString(clearScreen)
+		}
+		fmt.Fprintf(&buf, "Every %v: gravity status    %v\n\n",
+			config.interval, now.UTC().Format(constants.HumanDateFormatSeconds))
+		printStatusText(&buf, status)
+		if _, err := buf.WriteTo(os.Stdout); err != nil {
+			return trace.Wrap(err)
+		}
+		if tracker.exceeded(status.IsDegraded(), now) {
+			return utils.WrapExitCodeError(int(statusapi.CheckCritical),
+				trace.LimitExceeded("cluster has been degraded for longer than %v",
+					config.degradedTimeout))
+		}
+		<-ticker.C
+	}
+}
+
+// watchStatusOnce collects cluster status for a single watch iteration.
+// Collection errors are not fatal in watch mode: the cluster is reported
+// as degraded instead
+func watchStatusOnce(operator ops.Operator, operationID string, env *localenv.LocalEnvironment) clusterStatus {
+	status, err := statusOnce(context.TODO(), operator, operationID, env)
+	if err != nil {
+		log.WithError(err).Warn("Failed to collect cluster status.")
+	}
+	if status == nil {
+		status = &statusapi.Status{
+			Cluster: &statusapi.Cluster{
+				State: ops.SiteStateDegraded,
+			},
+		}
+	}
+	if status.Agent == nil {
+		status.Agent, err = statusapi.FromPlanetAgent(context.TODO(), nil)
+		if err != nil {
+			log.WithError(err).Warn("Failed to query status from planet agent.")
+		}
+	}
+	return clusterStatus{Status: *status}
+}
+
+// degradationTracker keeps track of how long the cluster has been
+// continuously degraded
+type degradationTracker struct {
+	// timeout specifies the maximum allowed duration of sustained degradation.
+	// Zero value disables the check
+	timeout time.Duration
+	// since specifies the time the cluster was first observed degraded.
+	// Zero value means the cluster is currently not degraded
+	since time.Time
+}
+
+// exceeded records the cluster state observed at the specified time and
+// returns true if the cluster has been degraded for longer than the timeout
+func (r *degradationTracker) exceeded(degraded bool, now time.Time) bool {
+	if !degraded {
+		r.since = time.Time{}
+		return false
+	}
+	if r.since.IsZero() {
+		r.since = now
+	}
+	return r.timeout > 0 && now.Sub(r.since) > r.timeout
+}
+
+// clearScreen is the ANSI escape sequence that moves the cursor home
+// and clears the terminal screen
+const clearScreen = "\033[H\033[2J"
+
+// newStatusOperator returns the operator to query the cluster status with.
+// Root reads the cluster state from etcd directly while other users
+// can only query the cluster controller with their cluster credentials
+func newStatusOperator(env *localenv.LocalEnvironment) (*statusOperator, error) {
+	if !runningAsRoot() {
+		clusterOperator, err := getCallerOperator(env)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &statusOperator{
+			Operator:        clusterOperator,
+			clusterOperator: clusterOperator,
+		}, nil
+	}
+	clusterOperator, err := env.SiteOperator()
+	if err != nil {
+		log.WithError(err).Warn("Failed to create cluster operator.")
+	}
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &statusOperator{
+		Operator:        clusterEnv.Operator,
+		clusterOperator: clusterOperator,
+	}, nil
+}
+
 // statusOnce collects cluster status information
 func statusOnce(ctx context.Context, operator ops.Operator, operationID string, env *localenv.LocalEnvironment) (*statusapi.Status, error) {
 	cluster, err := operator.GetLocalSite()
@@ -199,7 +433,7 @@ func printStatusWithOptions(status clusterStatus, printOptions printOptions) err
 	case constants.EncodingJSON:
 		return trace.Wrap(printStatusJSON(status))
 	default:
-		printStatusText(status)
+		printStatusText(os.Stdout, status)
 	}
 	return nil
 }
@@ -257,10 +491,10 @@ func printStatusJSON(status clusterStatus) error {
 	return nil
 }
 
-func printStatusText(cluster clusterStatus) {
+func printStatusText(out io.Writer, cluster clusterStatus) {
 	w := new(tabwriter.Writer)
 
-	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	w.Init(out, 0, 8, 1, '\t', 0)
 
 	if cluster.Cluster != nil {
 		if cluster.Status.IsDegraded() {
@@ -285,7 +519,7 @@ func printStatusText(cluster clusterStatus) {
 	w.Flush()
 
 	if len(cluster.FailedLocalProbes) != 0 {
-		printFailedChecks(cluster.FailedLocalProbes)
+		printFailedChecks(out, cluster.FailedLocalProbes)
 	}
 }
 
@@ -297,6 +531,14 @@ func printClusterStatus(cluster statusapi.Cluster, w io.Writer) {
 	if cluster.Token.Token != "" {
 		fmt.Fprintf(w, "Join token:\t%v\n", cluster.Token.Token)
 	}
+	if cluster.License != nil {
+		if warning := cluster.License.Warning(); warning != "" {
+			fmt.Fprintf(w, "License:\t%v\n", color.YellowString(warning))
+		}
+	}
+	for _, warning := range cluster.StorageWarnings {
+		fmt.Fprintf(w, "Storage:\t%v\n", color.YellowString(warning))
+	}
 	if cluster.Extension != nil {
 		cluster.Extension.WriteTo(w)
 	}
@@ -0,0 +1,51 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type StatusSuite struct{}
+
+var _ = check.Suite(&StatusSuite{})
+
+func (*StatusSuite) TestDegradationTracker(c *check.C) {
+	start := time.Now()
+	tracker := degradationTracker{timeout: time.Minute}
+
+	c.Assert(tracker.exceeded(true, start), check.Equals, false)
+	c.Assert(tracker.exceeded(true, start.Add(30*time.Second)), check.Equals, false)
+	c.Assert(tracker.exceeded(true, start.Add(2*time.Minute)), check.Equals, true,
+		check.Commentf("Expected sustained degradation to exceed the timeout."))
+
+	// Recovery resets the tracker
+	c.Assert(tracker.exceeded(false, start.Add(3*time.Minute)), check.Equals, false)
+	c.Assert(tracker.exceeded(true, start.Add(4*time.Minute)), check.Equals, false,
+		check.Commentf("Expected degradation period to restart after recovery."))
+	c.Assert(tracker.exceeded(true, start.Add(5*time.Minute+time.Second)), check.Equals, true)
+}
+
+func (*StatusSuite) TestDegradationTrackerDisabled(c *check.C) {
+	start := time.Now()
+	tracker := degradationTracker{}
+
+	c.Assert(tracker.exceeded(true, start), check.Equals, false)
+	c.Assert(tracker.exceeded(true, start.Add(24*time.Hour)), check.Equals, false)
+}
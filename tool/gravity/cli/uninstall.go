@@ -0,0 +1,211 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/rpc"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/system/environ"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/trace"
+)
+
+// uninstall tears down Gravity on this node or, with all set,
+// on all cluster nodes
+func uninstall(env *localenv.LocalEnvironment, config uninstallConfig) error {
+	if config.all && config.node {
+		return trace.BadParameter("--node and --all are mutually exclusive")
+	}
+	if config.all && config.keepTeleport {
+		return trace.BadParameter("--keep-teleport can only be used with --node")
+	}
+	if !config.confirmed {
+		scope := "this node"
+		if config.all {
+			scope = "all cluster nodes"
+		}
+		data := "and all the cluster and application data"
+		if config.preserveData {
+			data = "preserving the state directory"
+		}
+		env.Printf("This action will uninstall Gravity from %v %v.\n", scope, data)
+		if err := enforceConfirmation("Are you sure?"); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if config.all {
+		if err := uninstallRemoteNodes(env, config); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	// close the backend before attempting to unmount as the open file might
+	// prevent the umount from succeeding
+	env.Backend.Close()
+	err := environ.UninstallNode(environ.UninstallNodeConfig{
+		Printer:      env,
+		Logger:       log.WithField(trace.Component, "uninstall"),
+		PreserveData: config.preserveData,
+		KeepTeleport: config.keepTeleport,
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if config.keepTeleport {
+		env.PrintStep("Gravity services other than Teleport have been uninstalled from this node")
+		return nil
+	}
+	env.PrintStep("Gravity has been uninstalled, no Gravity artifacts remain on this node")
+	return nil
+}
+
+// uninstallRemoteNodes uninstalls Gravity from all cluster nodes other than this one
+// in parallel and waits for the results.
+//
+// As the nodes are accessed over Teleport, the teardown on each node runs in two steps:
+// the first step tears down everything but the Teleport service and reports the result,
+// the second step runs in a transient systemd unit and removes Teleport and the files
+// remaining on the node
+func uninstallRemoteNodes(env *localenv.LocalEnvironment, config uninstallConfig) error {
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	local, err := findLocalServer(*cluster)
+	if err != nil {
+		return trace.Wrap(err, "failed to find local node in cluster state")
+	}
+	teleportClient, err := env.TeleportClient(constants.Localhost)
+	if err != nil {
+		return trace.Wrap(err, "failed to create a teleport client")
+	}
+	ctx := context.TODO()
+	proxy, err := teleportClient.ConnectToProxy(ctx)
+	if err != nil {
+		return trace.Wrap(err, "failed to connect to teleport proxy")
+	}
+	var servers []storage.Server
+	for _, server := range cluster.ClusterState.Servers {
+		if server.AdvertiseIP != local.AdvertiseIP {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		return nil
+	}
+	env.PrintStep("Uninstalling Gravity from %v remote nodes", len(servers))
+	errCh := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server storage.Server) {
+			err := uninstallRemoteNode(ctx, proxy, server, config)
+			if err != nil {
+				env.PrintStep("Failed to uninstall Gravity from node %v (%v)", server.Hostname, server.AdvertiseIP)
+				errCh <- trace.Wrap(err, "failed to uninstall Gravity from node %v", server.Hostname)
+				return
+			}
+			env.PrintStep("Uninstalled Gravity from node %v (%v)", server.Hostname, server.AdvertiseIP)
+			errCh <- nil
+		}(server)
+	}
+	err = utils.CollectErrors(ctx, errCh)
+	if err != nil {
+		return trace.Wrap(err, "failed to uninstall Gravity from some nodes, this node has been left intact "+
+			"so the command can be retried")
+	}
+	return nil
+}
+
+// uninstallRemoteNode tears down the specified node and waits for the result.
+// Once the teardown has succeeded, starts the removal of Teleport and the
+// remaining files on the node
+func uninstallRemoteNode(ctx context.Context, proxy *client.ProxyClient, server storage.Server, config uninstallConfig) error {
+	var flags string
+	if config.preserveData {
+		flags = " --preserve-data"
+	}
+	logger := log.WithField("node", server.Hostname)
+	nodeClient, err := proxy.ConnectToNode(ctx, rpc.NewDeployServer(server).NodeAddr, defaults.SSHUser, false)
+	if err != nil {
+		return trace.Wrap(err, "failed to connect to node")
+	}
+	defer nodeClient.Close()
+	var out lockedBuffer
+	err = utils.NewSSHCommands(nodeClient.Client).
+		C(`"$(command -v %v)" uninstall --node --confirm --keep-teleport%v`, constants.GravityBin, flags).
+		WithLogger(logger).
+		WithOutput(&out).
+		Run(ctx)
+	if err != nil {
+		return trace.Wrap(err, "teardown failed: %s", out.String())
+	}
+	err = utils.NewSSHCommands(nodeClient.Client).
+		C(`systemd-run --unit=%v "$(command -v %v)" uninstall --node --confirm%v`,
+			defaults.GravityUninstallServiceName, constants.GravityBin, flags).
+		WithLogger(logger).
+		Run(ctx)
+	if err != nil {
+		return trace.Wrap(err, "failed to start removal of the remaining Gravity artifacts")
+	}
+	return nil
+}
+
+// lockedBuffer is a buffer safe for concurrent writes of the
+// standard output and error streams of a remote command
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write writes the specified data to the buffer
+func (r *lockedBuffer) Write(data []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	return r.buf.Write(data)
+}
+
+// String returns the contents of the buffer
+func (r *lockedBuffer) String() string {
+	r.Lock()
+	defer r.Unlock()
+	return r.buf.String()
+}
+
+type uninstallConfig struct {
+	// all uninstalls Gravity from all cluster nodes
+	all bool
+	// node uninstalls Gravity from this node only
+	node bool
+	// preserveData keeps the state directory in place
+	preserveData bool
+	// confirmed suppresses confirmation prompt
+	confirmed bool
+	// keepTeleport keeps the Teleport service on this node
+	// when the teardown is driven from another node
+	keepTeleport bool
+}
@@ -105,8 +105,24 @@ func newUpdater(ctx context.Context, localEnv, updateEnv *localenv.LocalEnvironm
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	clusterState := cluster.ClusterState
+	if len(plan.SkippedServers) != 0 {
+		if operation.Type == ops.OperationPatch {
+			localEnv.Printf("The following nodes are excluded from the operation and will need to be patched "+
+				"separately: %v.\n", storage.Servers(plan.SkippedServers))
+		} else if operation.Type == ops.OperationUpdateBinary {
+			localEnv.Printf("The following nodes are excluded from the operation and will keep "+
+				"the current gravity binary: %v.\n", storage.Servers(plan.SkippedServers))
+		} else {
+			localEnv.Printf("The following nodes are excluded from the operation and will need to be updated "+
+				"separately with 'gravity update catch-up <node>': %v.\n",
+				storage.Servers(plan.SkippedServers))
+		}
+		// Do not deploy agents on the excluded nodes as they might not be available
+		clusterState.Servers = excludeServers(clusterState.Servers, plan.SkippedServers)
+	}
 	req := init.updateDeployRequest(deployAgentsRequest{
-		clusterState: cluster.ClusterState,
+		clusterState: clusterState,
 		clusterName:  cluster.Domain,
 		clusterEnv:   clusterEnv,
 		proxy:        proxy,
@@ -163,3 +179,13 @@ type updater interface {
 	RollbackPhase(ctx context.Context, phase string, phaseTimeout time.Duration, force bool) error
 	Complete(error) error
 }
+
+// excludeServers returns the list of servers without the specified servers
+func excludeServers(servers, exclude []storage.Server) (result []storage.Server) {
+	for _, server := range servers {
+		if storage.Servers(exclude).FindByIP(server.AdvertiseIP) == nil {
+			result = append(result, server)
+		}
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	appservice "github.com/gravitational/gravity/lib/app/service"
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/gravitational/trace"
+)
+
+// updateDownloadConfig configures gravity update download
+type updateDownloadConfig struct {
+	// image is the cluster image to download in the 'version',
+	// 'name:version' or 'name' (for latest version) format
+	image string
+	// hubURL is the Gravity Hub to download the cluster image from
+	hubURL string
+}
+
+// downloadUpdate pulls the specified cluster image with all its dependencies
+// from the Gravity Hub into the update staging directory without creating
+// the update operation.
+//
+// The staging directory has the same layout as an unpacked cluster image
+// tarball so the staged image is uploaded and installed with the gravity
+// binary it contains. The download is resumed if interrupted
+func downloadUpdate(env *localenv.LocalEnvironment, config updateDownloadConfig) error {
+	hubURL, err := env.SelectOpsCenter(config.hubURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	cluster, err := env.LocalCluster()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	updateLoc, err := updateImageLocator(cluster.App.Package, config.image)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	remoteApps, err := env.AppService(hubURL, localenv.AppConfig{})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	remotePackages, err := env.PackageService(hubURL)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	updateApp, err := remoteApps.GetApp(*updateLoc)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := pack.CheckUpdatePackage(cluster.App.Package, updateApp.Package); err != nil {
+		return trace.Wrap(err)
+	}
+	stagingDir, err := updateDownloadDir()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	stagingEnv, err := localenv.New(stagingDir)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer stagingEnv.Close()
+	stagingApps, err := stagingEnv.AppServiceLocal(localenv.AppConfig{})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Downloading cluster image %v from %v", updateApp.Package, hubURL)
+	_, err = appservice.PullApp(appservice.AppPullRequest{
+		SrcPack:  remotePackages,
+		DstPack:  stagingEnv.Packages,
+		SrcApp:   remoteApps,
+		DstApp:   stagingApps,
+		Package:  updateApp.Package,
+		Progress: env.Reporter,
+	})
+	if err != nil && !trace.IsAlreadyExists(err) {
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Verifying checksums of downloaded packages")
+	if err := verifyStagedPackages(stagingEnv.Packages, remotePackages); err != nil {
+		return trace.Wrap(err)
+	}
+	gravityPackage, err := updateApp.Manifest.Dependencies.ByName(constants.GravityPackage)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	gravityPath := filepath.Join(stagingDir, constants.GravityBin)
+	if err := exportExecutable(stagingEnv.Packages, *gravityPackage, gravityPath); err != nil {
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Cluster image %v has been downloaded to %v", updateApp.Package, stagingDir)
+	env.Printf(updateDownloadCompleteBanner, gravityPath, stagingDir, gravityPath, gravityPath)
+	return nil
+}
+
+// deleteUpdateDownload removes the cluster image staged with gravity update download
+func deleteUpdateDownload(env *localenv.LocalEnvironment) error {
+	stagingDir, err := updateDownloadDir()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	env.PrintStep("Removed downloaded update data from %v", stagingDir)
+	return nil
+}
+
+// updateImageLocator returns the locator of the cluster image to update to.
+// The image is specified either with the version of the installed image
+// or in the 'name:version' or 'name' (for latest version) format
+func updateImageLocator(installed loc.Locator, image string) (*loc.Locator, error) {
+	if image == "" {
+		return nil, trace.BadParameter("specify the cluster image version to download")
+	}
+	if !strings.Contains(image, ":") {
+		if version, err := semver.NewVersion(image); err == nil {
+			locator := installed.WithVersion(version)
+			return &locator, nil
+		}
+	}
+	locator, err := loc.MakeLocator(image)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return locator, nil
+}
+
+// verifyStagedPackages validates that the contents of every staged package
+// match its checksum and the checksum of the package in the Gravity Hub
+func verifyStagedPackages(staged, remote pack.PackageService) error {
+	return pack.ForeachPackage(staged, func(env pack.PackageEnvelope) error {
+		remoteEnv, err := remote.ReadPackageEnvelope(env.Locator)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if remoteEnv.SHA512 != env.SHA512 {
+			return trace.BadParameter("checksum of package %v does not match the Gravity Hub, "+
+				"remove the downloaded data with --delete and download again", env.Locator)
+		}
+		_, reader, err := staged.ReadPackage(env.Locator)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		defer reader.Close()
+		hash, err := utils.SHA512HalfReader(reader)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if hash != env.SHA512 {
+			return trace.BadParameter("package %v is corrupted, "+
+				"remove the downloaded data with --delete and download again", env.Locator)
+		}
+		return nil
+	})
+}
+
+// exportExecutable writes the contents of the specified package
+// as an executable file at path
+func exportExecutable(packages pack.PackageService, locator loc.Locator, path string) error {
+	_, reader, err := packages.ReadPackage(locator)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	defer reader.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, defaults.SharedExecutableMask)
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, reader); err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(f.Close())
+}
+
+// updateDownloadDir returns the directory the cluster image
+// downloaded with gravity update download is staged in
+func updateDownloadDir() (string, error) {
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return filepath.Join(state.GravityUpdateDir(stateDir), defaults.UpdateDownloadDir), nil
+}
+
+const updateDownloadCompleteBanner = `
+To upgrade the cluster during the maintenance window, upload the image and start the upgrade:
+
+	sudo %v --insecure update upload --state-dir=%v
+	sudo %v upgrade
+
+Remove the downloaded data after the upgrade with: sudo %v update download --delete
+`
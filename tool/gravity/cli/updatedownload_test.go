@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/blob/fs"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/loc"
+	"github.com/gravitational/gravity/lib/pack"
+	"github.com/gravitational/gravity/lib/pack/localpack"
+	"github.com/gravitational/gravity/lib/storage/keyval"
+
+	"gopkg.in/check.v1"
+)
+
+func (*S) TestParsesUpdateImage(c *check.C) {
+	installed := loc.MustParseLocator("gravitational.io/app:1.0.0")
+	testCases := []struct {
+		image    string
+		expected string
+	}{
+		{image: "2.0.0", expected: "gravitational.io/app:2.0.0"},
+		{image: "other:2.0.0", expected: "gravitational.io/other:2.0.0"},
+		{image: "example.com/app:2.0.0", expected: "example.com/app:2.0.0"},
+	}
+	for _, tc := range testCases {
+		locator, err := updateImageLocator(installed, tc.image)
+		c.Assert(err, check.IsNil, check.Commentf(tc.image))
+		c.Assert(locator.String(), check.Equals, tc.expected, check.Commentf(tc.image))
+	}
+	_, err := updateImageLocator(installed, "")
+	c.Assert(err, check.NotNil)
+}
+
+func (*S) TestVerifiesStagedPackages(c *check.C) {
+	locator := loc.MustParseLocator("gravitational.io/planet:1.0.0")
+	staged := newTestPackages(c)
+	_, err := staged.CreatePackage(locator, strings.NewReader("planet"))
+	c.Assert(err, check.IsNil)
+
+	remote := newTestPackages(c)
+	_, err = remote.CreatePackage(locator, strings.NewReader("planet"))
+	c.Assert(err, check.IsNil)
+	c.Assert(verifyStagedPackages(staged, remote), check.IsNil)
+
+	modified := newTestPackages(c)
+	_, err = modified.CreatePackage(locator, strings.NewReader("modified planet"))
+	c.Assert(err, check.IsNil)
+	c.Assert(verifyStagedPackages(staged, modified), check.NotNil)
+}
+
+func newTestPackages(c *check.C) pack.PackageService {
+	dir := c.MkDir()
+	backend, err := keyval.NewBolt(keyval.BoltConfig{Path: filepath.Join(dir, "bolt.db")})
+	c.Assert(err, check.IsNil)
+	objects, err := fs.New(dir)
+	c.Assert(err, check.IsNil)
+	packages, err := localpack.New(localpack.Config{
+		Backend:     backend,
+		UnpackedDir: filepath.Join(dir, defaults.UnpackedDir),
+		Objects:     objects,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(packages.UpsertRepository(defaults.SystemAccountOrg, time.Time{}), check.IsNil)
+	return packages
+}
@@ -23,23 +23,29 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/httplib"
 	"github.com/gravitational/gravity/lib/install"
 	"github.com/gravitational/gravity/lib/localenv"
 	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/ops/opsclient"
 	"github.com/gravitational/gravity/lib/pack/webpack"
 	"github.com/gravitational/gravity/lib/processconfig"
 	rpcserver "github.com/gravitational/gravity/lib/rpc/server"
 	"github.com/gravitational/gravity/lib/state"
 	"github.com/gravitational/gravity/lib/storage"
+	libclusterconfig "github.com/gravitational/gravity/lib/storage/clusterconfig"
+	"github.com/gravitational/gravity/lib/storage/keyval"
 	"github.com/gravitational/gravity/lib/systeminfo"
+	"github.com/gravitational/gravity/lib/update/clusterconfig"
 	"github.com/gravitational/gravity/lib/utils"
 	"github.com/gravitational/gravity/tool/common"
 
 	"github.com/gravitational/roundtrip"
 	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -67,6 +73,18 @@ func (g *Application) NewLocalEnv() (env *localenv.LocalEnvironment, err error)
 	return g.getEnv(localStateDir)
 }
 
+// NewUserEnv returns an instance of the local environment that keeps its state
+// in the home directory of the current user.
+// It is used for commands run by users other than root, which have
+// no access to the node state
+func (g *Application) NewUserEnv() (*localenv.LocalEnvironment, error) {
+	stateDir, err := utils.EnsureLocalPath("", defaults.LocalDataDir, defaults.LocalDir)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return g.getEnv(stateDir)
+}
+
 // NewInstallEnv returns an instance of the local environment for commands that
 // initialize cluster environment (i.e. install or join).
 func (g *Application) NewInstallEnv() (env *localenv.LocalEnvironment, err error) {
@@ -91,7 +109,6 @@ func (g *Application) NewUpdateEnv() (*localenv.LocalEnvironment, error) {
 
 // NewJoinEnv returns an instance of local environment where join-specific data is stored
 func (g *Application) NewJoinEnv() (*localenv.LocalEnvironment, error) {
-	const failImmediatelyIfLocked = -1
 	stateDir, err := state.GravityInstallDir()
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -106,8 +123,9 @@ func (g *Application) NewJoinEnv() (*localenv.LocalEnvironment, error) {
 		Silent:           localenv.Silent(*g.Silent),
 		Debug:            *g.Debug,
 		EtcdRetryTimeout: *g.EtcdRetryTimeout,
-		BoltOpenTimeout:  failImmediatelyIfLocked,
+		BoltOpenTimeout:  keyval.NoTimeout,
 		Reporter:         common.ProgressReporter(*g.Silent),
+		OperatorRetry:    opsclient.RetryConfig{Timeout: *g.OperatorRetryTimeout},
 	})
 }
 
@@ -119,6 +137,7 @@ func (g *Application) getEnv(stateDir string) (*localenv.LocalEnvironment, error
 		Debug:            *g.Debug,
 		EtcdRetryTimeout: *g.EtcdRetryTimeout,
 		Reporter:         common.ProgressReporter(*g.Silent),
+		OperatorRetry:    opsclient.RetryConfig{Timeout: *g.OperatorRetryTimeout},
 	})
 }
 
@@ -126,6 +145,7 @@ func (g *Application) getEnvWithArgs(args localenv.LocalEnvironmentArgs) (*local
 	if *g.StateDir != defaults.LocalGravityDir {
 		args.LocalKeyStoreDir = *g.StateDir
 	}
+	args.BackendSnapshot = g.backendSnapshot
 	// set insecure in devmode so we won't need to use
 	// --insecure flag all the time
 	cfg, _, err := processconfig.ReadConfig("")
@@ -135,6 +155,20 @@ func (g *Application) getEnvWithArgs(args localenv.LocalEnvironmentArgs) (*local
 	return localenv.NewLocalEnvironment(args)
 }
 
+// isInformationalCommand returns true if the specified command only
+// displays the local state and should not block or fail if the local
+// state database is locked by another process, e.g. an active operation
+func (g *Application) isInformationalCommand(cmd string) bool {
+	switch cmd {
+	case g.StatusCmd.FullCommand(),
+		g.PlanDisplayCmd.FullCommand(),
+		g.OperationQueueListCmd.FullCommand(),
+		g.AuditListCmd.FullCommand():
+		return true
+	}
+	return false
+}
+
 // isUpdateCommand returns true if the specified command is
 // an upgrade related command
 func (g *Application) isUpdateCommand(cmd string) bool {
@@ -147,6 +181,7 @@ func (g *Application) isUpdateCommand(cmd string) bool {
 		g.PlanCompleteCmd.FullCommand(),
 		g.UpdatePlanInitCmd.FullCommand(),
 		g.UpdateTriggerCmd.FullCommand(),
+		g.UpdateCatchUpCmd.FullCommand(),
 		g.UpgradeCmd.FullCommand():
 		return true
 	case g.RPCAgentRunCmd.FullCommand():
@@ -174,29 +209,197 @@ func (g *Application) isExpandCommand(cmd string) bool {
 	return false
 }
 
-// ConfigureNoProxy configures the current process to not use any configured HTTP proxy when connecting to any
-// destination by IP address, or a domain with a suffix of .local. Gravity internally connects to nodes by IP address,
-// and by queries to kubernetes using the .local suffix. The side effect is, connections towards the internet by IP
-// address and not a configured domain name will not be able to invoke a proxy. This should be a reasonable tradeoff,
-// because with a cluster that changes over time, it's difficult for us to accuratly detect what IP addresses need to
-// have no_proxy set.
-func ConfigureNoProxy() {
-	// The golang HTTP proxy env variable detection only uses the first detected http proxy env variable
-	// so we need to grab both to make sure we edit the correct one.
-	// https://github.com/golang/net/blob/c21de06aaf072cea07f3a65d6970e5c7d8b6cd6d/http/httpproxy/proxy.go#L91-L107
-	proxy := map[string]string{
-		"NO_PROXY": os.Getenv("NO_PROXY"),
-		"no_proxy": os.Getenv("no_proxy"),
-	}
-
-	for k, v := range proxy {
-		if len(v) != 0 {
-			os.Setenv(k, strings.Join([]string{v, "0.0.0.0/0", ".local"}, ","))
-			return
+// ConfigureNoProxy configures the proxy policy of the current process to not use any configured HTTP proxy
+// when connecting to internal destinations. Gravity internally connects to nodes by IP address, and by queries
+// to kubernetes using the .local suffix. The excluded destinations are also added to the NO_PROXY environment
+// of the child processes. The environment of the current process is left intact.
+//
+// With the default policy, any destination specified by IP address bypasses the proxy. The side effect is,
+// connections towards the internet by IP address will not be able to invoke a proxy. Setups that require
+// IP-addressed upstream proxies can use the cluster policy which only excludes the cluster subnets,
+// the addresses of the cluster nodes and the networks of this node.
+//
+// With the cluster policy, the subnets and the node addresses are read from the cluster state
+// the first time the command makes an HTTP request or starts a child process, so the commands
+// that do neither do not query the cluster state.
+func ConfigureNoProxy(g *Application, cmd string) error {
+	config := httplib.NoProxyConfig{
+		Policy: httplib.NoProxyPolicy(*g.NoProxyPolicy),
+		Extra:  *g.NoProxy,
+	}
+	if config.Policy != httplib.NoProxyCluster {
+		rule, err := config.Rule()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if rule != nil {
+			setNoProxyRule(config.Policy, *rule)
+		}
+		return nil
+	}
+	addrs, err := httplib.LocalNoProxyAddrs()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	config.Addrs = addrs
+	if cmd == g.InstallCmd.FullCommand() {
+		config.Subnets = []string{*g.InstallCmd.PodCIDR, *g.InstallCmd.ServiceCIDR}
+		rule, err := config.Rule()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		setNoProxyRule(config.Policy, *rule)
+		return nil
+	}
+	noProxy := &clusterNoProxy{config: config}
+	httplib.SetProxyPolicyFunc(noProxy.policy)
+	utils.SetCommandEnvFunc(noProxy.env)
+	return nil
+}
+
+func setNoProxyRule(policy httplib.NoProxyPolicy, rule httplib.ProxyRule) {
+	httplib.SetProxyPolicy(httplib.ProxyPolicy{
+		Rules: []httplib.ProxyRule{rule},
+	})
+	noProxyEnv := httplib.NoProxyEnv(rule, os.Getenv)
+	utils.SetCommandEnv(noProxyEnv)
+	log.WithFields(logrus.Fields{
+		"policy":   policy,
+		"no-proxy": noProxyEnv["NO_PROXY"],
+	}).Debug("Configured proxy policy.")
+}
+
+// clusterNoProxy resolves the no-proxy rule of the cluster policy on first use
+type clusterNoProxy struct {
+	once   sync.Once
+	config httplib.NoProxyConfig
+	rule   httplib.ProxyRule
+}
+
+// policy returns the proxy policy for the HTTP clients of this process
+func (r *clusterNoProxy) policy() httplib.ProxyPolicy {
+	return httplib.ProxyPolicy{
+		Rules: []httplib.ProxyRule{r.resolve()},
+	}
+}
+
+// env returns the NO_PROXY environment for the child processes
+func (r *clusterNoProxy) env() map[string]string {
+	return httplib.NoProxyEnv(r.resolve(), os.Getenv)
+}
+
+func (r *clusterNoProxy) resolve() httplib.ProxyRule {
+	r.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), defaults.LocalClusterQueryTimeout)
+		defer cancel()
+		subnets := storage.DefaultSubnets
+		network, err := getLocalClusterNetwork(ctx)
+		if err != nil {
+			log.WithError(err).Debug("Using default subnets for the proxy policy.")
+		} else {
+			subnets = network.subnets
+			r.config.Addrs = append(r.config.Addrs, network.servers...)
 		}
+		r.config.Subnets = []string{subnets.Overlay, subnets.Service}
+		// the cluster policy always excludes at least the .local domains
+		r.rule = httplib.ProxyRule{Destinations: r.config.Entries()}
+		log.WithFields(logrus.Fields{
+			"policy":   r.config.Policy,
+			"no-proxy": strings.Join(r.rule.Destinations, ","),
+		}).Debug("Configured proxy policy.")
+	})
+	return r.rule
+}
+
+// clusterNetwork describes the networks of a cluster
+type clusterNetwork struct {
+	// subnets are the pod and service subnets in effect
+	subnets storage.Subnets
+	// servers lists the addresses of the cluster nodes
+	servers []string
+}
+
+// getLocalClusterNetwork returns the networks of the cluster this node
+// is a member of read from the cluster state.
+// Returns NotFound if this node is not a cluster member
+func getLocalClusterNetwork(ctx context.Context) (*clusterNetwork, error) {
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	isFile, err := utils.IsFile(state.Secret(stateDir, defaults.EtcdCertFilename))
+	if err != nil && !trace.IsNotFound(err) {
+		return nil, trace.Wrap(err)
 	}
+	if !isFile {
+		return nil, trace.NotFound("not a cluster node")
+	}
+	type result struct {
+		network *clusterNetwork
+		err     error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		network, err := queryLocalClusterNetwork()
+		resultC <- result{network: network, err: err}
+	}()
+	select {
+	case result := <-resultC:
+		return result.network, trace.Wrap(result.err)
+	case <-ctx.Done():
+		return nil, trace.LimitExceeded("timed out querying cluster state")
+	}
+}
 
-	os.Setenv("NO_PROXY", strings.Join([]string{"0.0.0.0/0", ".local"}, ","))
+func queryLocalClusterNetwork() (*clusterNetwork, error) {
+	etcdConfig, err := keyval.LocalEtcdConfig(0)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	backend, err := keyval.NewETCD(*etcdConfig)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer backend.Close()
+	cluster, err := backend.GetLocalSite(defaults.SystemAccountID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	operations, err := storage.GetOperationsForCluster(backend, cluster.Domain)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	subnets := storage.DefaultSubnets
+	var config libclusterconfig.Interface
+	// operations are sorted with the most recent operation first
+	for _, operation := range operations {
+		if operation.State != ops.OperationStateCompleted {
+			continue
+		}
+		switch operation.Type {
+		case ops.OperationInstall:
+			// Subnets are empty for clusters installed with older versions
+			if operation.InstallExpand != nil && !operation.InstallExpand.Subnets.IsEmpty() {
+				subnets = operation.InstallExpand.Subnets
+			}
+		case ops.OperationUpdateConfig:
+			if config != nil || operation.UpdateConfig == nil || len(operation.UpdateConfig.Config) == 0 {
+				continue
+			}
+			config, err = libclusterconfig.Unmarshal(operation.UpdateConfig.Config)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+		}
+	}
+	if config != nil {
+		subnets = clusterconfig.EffectiveSubnets(subnets, config)
+	}
+	network := &clusterNetwork{subnets: subnets}
+	for _, server := range cluster.ClusterState.Servers {
+		network.servers = append(network.servers, server.AdvertiseIP)
+	}
+	return network, nil
 }
 
 func getLocalStateDir(stateDir string) (localStateDir string, err error) {
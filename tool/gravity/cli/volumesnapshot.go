@@ -0,0 +1,258 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gravitational/gravity/lib/constants"
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	"github.com/gravitational/gravity/lib/state"
+	"github.com/gravitational/gravity/lib/storage"
+	"github.com/gravitational/gravity/lib/utils"
+	"github.com/gravitational/gravity/lib/volumesnapshot"
+	"github.com/gravitational/gravity/tool/common"
+
+	"github.com/gravitational/trace"
+)
+
+// volumeSnapshotConfig describes a volume snapshot to take
+type volumeSnapshotConfig struct {
+	// name is the snapshot name
+	name string
+	// namespace limits the snapshot to the claims in the namespace
+	namespace string
+	// selector is the label selector of the claims to snapshot
+	selector string
+	// claims explicitly lists the claims to snapshot
+	claims []string
+	// snapshotClass overrides the volume snapshot class
+	snapshotClass string
+	// etcd is whether to backup etcd data along with the volumes
+	etcd bool
+	// backup is the path to the application backup tarball
+	// taken along with the snapshot
+	backup string
+	// timeout is the maximum time to wait for the snapshots to become ready
+	timeout time.Duration
+}
+
+func createVolumeSnapshot(env *localenv.LocalEnvironment, config volumeSnapshotConfig) error {
+	snapshotter, cluster, err := newVolumeSnapshotter(env)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	req := volumesnapshot.CreateRequest{
+		Name:          config.name,
+		Namespace:     config.namespace,
+		Selector:      config.selector,
+		Claims:        config.claims,
+		SnapshotClass: config.snapshotClass,
+		CreatedBy:     env.CurrentUser(),
+		Backup:        config.backup,
+		Timeout:       config.timeout,
+	}
+	if config.etcd {
+		node, err := findLocalServer(*cluster)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if !node.IsMaster() {
+			return trace.BadParameter("etcd data can only be backed up on one of the master nodes")
+		}
+		req.BackupEtcd = func(ctx context.Context) (*storage.EtcdSnapshot, error) {
+			env.PrintStep("Backing up etcd data")
+			return backupEtcdForSnapshot(ctx, config.name, node.Hostname)
+		}
+	}
+	env.PrintStep("Taking snapshot %v of persistent volumes", config.name)
+	snapshot, err := snapshotter.Create(context.TODO(), req)
+	if err != nil {
+		if config.etcd {
+			removeVolumeSnapshotDir(config.name)
+		}
+		return trace.Wrap(err)
+	}
+	env.PrintStep("Snapshot %v of %v is ready", snapshot.Name, formatSnapshotVolumes(snapshot.Volumes))
+	if snapshot.Etcd != nil {
+		env.PrintStep("etcd data is backed up to %v on node %v", snapshot.Etcd.Path, snapshot.Etcd.Node)
+	}
+	return nil
+}
+
+func restoreVolumeSnapshot(env *localenv.LocalEnvironment, name, suffix string) error {
+	snapshotter, _, err := newVolumeSnapshotter(env)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	snapshot, err := snapshotter.Backend.GetVolumeSnapshot(snapshotter.ClusterName, name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	claims, err := snapshotter.Restore(context.TODO(), volumesnapshot.RestoreRequest{
+		Name:   name,
+		Suffix: suffix,
+	})
+	for _, claim := range claims {
+		env.PrintStep("Restored persistent volume claim %v", claim)
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if snapshot.Etcd != nil {
+		env.PrintStep("etcd data taken along with the snapshot is not restored automatically, "+
+			"the backup is available at %v on node %v", snapshot.Etcd.Path, snapshot.Etcd.Node)
+	}
+	return nil
+}
+
+func removeVolumeSnapshot(env *localenv.LocalEnvironment, name string) error {
+	snapshotter, cluster, err := newVolumeSnapshotter(env)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	snapshot, err := snapshotter.Delete(context.TODO(), name)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if snapshot.Etcd != nil {
+		if node, err := findLocalServer(*cluster); err == nil && node.Hostname == snapshot.Etcd.Node {
+			removeVolumeSnapshotDir(name)
+		} else {
+			env.PrintStep("Remove etcd data backup %v on node %v manually",
+				snapshot.Etcd.Path, snapshot.Etcd.Node)
+		}
+	}
+	env.PrintStep("Snapshot %v has been removed", name)
+	return nil
+}
+
+func listVolumeSnapshots(env *localenv.LocalEnvironment, format constants.Format, w io.Writer) error {
+	snapshotter, _, err := newVolumeSnapshotter(env)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	snapshots, err := snapshotter.Backend.GetVolumeSnapshots(snapshotter.ClusterName)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	switch format {
+	case constants.EncodingJSON:
+		return trace.Wrap(printJSON(snapshots, w))
+	case constants.EncodingText:
+		printVolumeSnapshots(snapshots, w)
+		return nil
+	}
+	return trace.BadParameter("unsupported output format %q", format)
+}
+
+func printVolumeSnapshots(snapshots []storage.VolumeSnapshot, out io.Writer) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 1, '\t', 0)
+	common.PrintTableHeader(w, []string{"Name", "Created", "Volumes", "Etcd", "Backup"})
+	for _, snapshot := range snapshots {
+		etcd := "-"
+		if snapshot.Etcd != nil {
+			etcd = fmt.Sprintf("%v:%v", snapshot.Etcd.Node, snapshot.Etcd.Path)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n",
+			snapshot.Name,
+			snapshot.Created.Format(constants.HumanDateFormat),
+			formatSnapshotVolumes(snapshot.Volumes),
+			etcd,
+			formatDeviceValue(snapshot.Backup))
+	}
+	w.Flush()
+}
+
+// newVolumeSnapshotter returns the volume snapshotter for the local cluster
+func newVolumeSnapshotter(env *localenv.LocalEnvironment) (*volumesnapshot.Snapshotter, *ops.Site, error) {
+	clusterEnv, err := env.NewClusterEnvironment()
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	if clusterEnv.Client == nil {
+		return nil, nil, trace.BadParameter("this operation can only be executed on one of the master nodes")
+	}
+	cluster, err := clusterEnv.Operator.GetLocalSite()
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	snapshotter, err := volumesnapshot.New(volumesnapshot.Config{
+		Client:      clusterEnv.Client,
+		Backend:     clusterEnv.Backend,
+		ClusterName: cluster.Domain,
+	})
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	return snapshotter, cluster, nil
+}
+
+// backupEtcdForSnapshot backs up etcd data on this node into the directory
+// of the specified volume snapshot
+func backupEtcdForSnapshot(ctx context.Context, name, hostname string) (*storage.EtcdSnapshot, error) {
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	dir := state.VolumeSnapshotDir(stateDir, name)
+	if err := os.MkdirAll(dir, defaults.SharedDirMask); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	// the state directory is mounted inside the planet container
+	// under the default location
+	planetPath := filepath.Join(state.VolumeSnapshotDir(defaults.GravityDir, name),
+		defaults.VolumeSnapshotEtcdBackupFile)
+	out, err := utils.RunPlanetCommand(ctx, log, "etcd", "backup", planetPath)
+	if err != nil {
+		return nil, trace.Wrap(err, "failed to backup etcd data: %s", out)
+	}
+	return &storage.EtcdSnapshot{
+		Node: hostname,
+		Path: filepath.Join(dir, defaults.VolumeSnapshotEtcdBackupFile),
+	}, nil
+}
+
+// removeVolumeSnapshotDir removes the local directory of the specified volume snapshot
+func removeVolumeSnapshotDir(name string) {
+	stateDir, err := state.GetStateDir()
+	if err != nil {
+		log.WithError(err).Warn("Failed to determine state directory.")
+		return
+	}
+	if err := os.RemoveAll(state.VolumeSnapshotDir(stateDir, name)); err != nil {
+		log.WithError(err).Warnf("Failed to remove directory of snapshot %v.", name)
+	}
+}
+
+func formatSnapshotVolumes(volumes []storage.SnapshotVolume) string {
+	var out []string
+	for _, volume := range volumes {
+		out = append(out, volume.String())
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/localenv"
+	"github.com/gravitational/gravity/lib/ops"
+	statusapi "github.com/gravitational/gravity/lib/status"
+	"github.com/gravitational/gravity/lib/utils"
+
+	pb "github.com/gravitational/satellite/agent/proto/agentpb"
+	"github.com/gravitational/trace"
+)
+
+// waitConfig configures gravity wait
+type waitConfig struct {
+	// condition is the condition to wait for
+	condition string
+	// timeout is the maximum time to wait for the condition
+	timeout time.Duration
+	// interval is the interval between condition checks
+	interval time.Duration
+}
+
+// wait blocks until the configured condition is met.
+//
+// Returns an error with defaults.TimeoutExitCode if the condition has not been
+// met before the timeout, or with defaults.FailedPreconditionExitCode if the
+// condition can no longer be met (e.g. the awaited operation has failed)
+func wait(env *localenv.LocalEnvironment, config waitConfig) error {
+	condition, err := parseWaitCondition(config.condition)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	operator, err := env.SiteOperator()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), config.timeout)
+	defer cancel()
+	check := func(ctx context.Context) (string, error) {
+		return condition.check(ctx, operator)
+	}
+	if err := waitFor(ctx, condition.String(), config.interval, check); err != nil {
+		return trace.Wrap(err)
+	}
+	env.Printf("Condition %v is met.\n", condition)
+	return nil
+}
+
+// waitCheckFunc checks whether the awaited condition is met.
+// It returns a human-readable reason if the condition is not met yet, or an error.
+// A permanent error (see waitConditionFailed) aborts the wait, any other error
+// is considered transient and the condition is checked again
+type waitCheckFunc func(ctx context.Context) (reason string, err error)
+
+// waitFor checks the condition with the specified interval until it is met,
+// fails permanently or the context expires
+func waitFor(ctx context.Context, name string, interval time.Duration, check waitCheckFunc) error {
+	if interval <= 0 {
+		return trace.BadParameter("check interval must be positive: %v", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastReason string
+	for {
+		reason, err := check(ctx)
+		if err == nil && reason == "" {
+			return nil
+		}
+		if err != nil {
+			if utils.ExitCodeFromError(err) == defaults.FailedPreconditionExitCode {
+				return trace.Wrap(err)
+			}
+			log.WithError(err).Debugf("Failed to check condition %v.", name)
+			reason = trace.UserMessage(err)
+		}
+		if reason != lastReason {
+			log.Infof("Waiting for %v: %v.", name, reason)
+			lastReason = reason
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			message := fmt.Sprintf("timed out waiting for %v", name)
+			if lastReason != "" {
+				message = fmt.Sprintf("%v: %v", message, lastReason)
+			}
+			return utils.NewExitCodeErrorWithMessage(defaults.TimeoutExitCode, message)
+		}
+	}
+}
+
+// waitConditionFailed returns an error indicating that the awaited
+// condition can no longer be met
+func waitConditionFailed(format string, args ...interface{}) error {
+	return utils.NewFailedPreconditionError(trace.BadParameter(format, args...))
+}
+
+// parseWaitCondition parses the condition specification in one of the formats:
+//
+//	cluster-healthy
+//	operation-complete=<operation-id>
+//	node-ready=<hostname or advertise IP>
+func parseWaitCondition(spec string) (*waitCondition, error) {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, "="); i != -1 {
+		kind, arg = spec[:i], spec[i+1:]
+	}
+	switch kind {
+	case waitClusterHealthy:
+		if arg != "" {
+			return nil, trace.BadParameter("condition %v does not accept an argument", kind)
+		}
+	case waitOperationComplete, waitNodeReady:
+		if arg == "" {
+			return nil, trace.BadParameter("condition %v requires an argument: %v=<value>", kind, kind)
+		}
+	default:
+		return nil, trace.BadParameter("unknown condition %q, supported are: %v, %v=<id>, %v=<name>",
+			spec, waitClusterHealthy, waitOperationComplete, waitNodeReady)
+	}
+	return &waitCondition{kind: kind, arg: arg}, nil
+}
+
+// waitCondition is a condition gravity wait can wait for
+type waitCondition struct {
+	// kind is the condition kind
+	kind string
+	// arg is the optional condition argument
+	arg string
+}
+
+// String returns the condition specification
+func (r waitCondition) String() string {
+	if r.arg == "" {
+		return r.kind
+	}
+	return fmt.Sprintf("%v=%v", r.kind, r.arg)
+}
+
+// check checks whether the condition is met
+func (r waitCondition) check(ctx context.Context, operator ops.Operator) (reason string, err error) {
+	cluster, err := operator.GetLocalSite()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	switch r.kind {
+	case waitClusterHealthy:
+		agent, err := statusapi.FromPlanetAgent(ctx, cluster.ClusterState.Servers)
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		return clusterHealthyReason(cluster.State, agent), nil
+	case waitOperationComplete:
+		operation, err := operator.GetSiteOperation(cluster.OperationKey(r.arg))
+		if trace.IsNotFound(err) {
+			return "", waitConditionFailed("operation %v not found", r.arg)
+		}
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		return operationCompleteReason(*operation)
+	case waitNodeReady:
+		agent, err := statusapi.FromPlanetAgent(ctx, cluster.ClusterState.Servers)
+		if err != nil {
+			return "", trace.Wrap(err)
+		}
+		return nodeReadyReason(r.arg, agent.Nodes), nil
+	}
+	return "", trace.BadParameter("unknown condition %v", r.kind)
+}
+
+// clusterHealthyReason returns the reason the cluster is not healthy
+// or an empty string if it is
+func clusterHealthyReason(state string, agent *statusapi.Agent) string {
+	if state != ops.SiteStateActive {
+		return fmt.Sprintf("cluster is %v", state)
+	}
+	if status := agent.GetSystemStatus(); status != pb.SystemStatus_Running {
+		return fmt.Sprintf("cluster system status is %v", strings.ToLower(status.String()))
+	}
+	return ""
+}
+
+// operationCompleteReason returns the reason the operation has not completed
+// yet or an empty string if it has completed successfully.
+// Returns a permanent error if the operation has failed
+func operationCompleteReason(operation ops.SiteOperation) (string, error) {
+	switch {
+	case operation.IsCompleted():
+		return "", nil
+	case operation.IsFailed():
+		return "", waitConditionFailed("operation %v has failed", operation.String())
+	}
+	return fmt.Sprintf("operation %v is %v", operation.ID, operation.State), nil
+}
+
+// nodeReadyReason returns the reason the node with the specified hostname
+// or advertise IP is not ready or an empty string if it is healthy
+func nodeReadyReason(name string, nodes []statusapi.ClusterServer) string {
+	for _, node := range nodes {
+		if node.Hostname != name && node.AdvertiseIP != name {
+			continue
+		}
+		if node.Status != statusapi.NodeHealthy {
+			return fmt.Sprintf("node %v is %v", name, node.Status)
+		}
+		return ""
+	}
+	return fmt.Sprintf("node %v has not joined the cluster", name)
+}
+
+const (
+	// waitClusterHealthy waits for the cluster to become active and healthy
+	waitClusterHealthy = "cluster-healthy"
+	// waitOperationComplete waits for the specified operation to complete successfully
+	waitOperationComplete = "operation-complete"
+	// waitNodeReady waits for the specified node to become healthy
+	waitNodeReady = "node-ready"
+)
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/gravity/lib/defaults"
+	"github.com/gravitational/gravity/lib/ops"
+	statusapi "github.com/gravitational/gravity/lib/status"
+	"github.com/gravitational/gravity/lib/utils"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func (*S) TestParsesWaitConditions(c *check.C) {
+	condition, err := parseWaitCondition("cluster-healthy")
+	c.Assert(err, check.IsNil)
+	c.Assert(*condition, check.Equals, waitCondition{kind: waitClusterHealthy})
+
+	condition, err = parseWaitCondition("operation-complete=a1b2")
+	c.Assert(err, check.IsNil)
+	c.Assert(*condition, check.Equals, waitCondition{kind: waitOperationComplete, arg: "a1b2"})
+	c.Assert(condition.String(), check.Equals, "operation-complete=a1b2")
+
+	for _, spec := range []string{"cluster-healthy=yes", "node-ready", "node-ready=", "pods-running"} {
+		_, err = parseWaitCondition(spec)
+		c.Assert(trace.IsBadParameter(err), check.Equals, true, check.Commentf(spec))
+	}
+}
+
+func (*S) TestWaitsForCondition(c *check.C) {
+	var checks int
+	err := waitFor(context.TODO(), "test", time.Millisecond, func(context.Context) (string, error) {
+		checks++
+		switch checks {
+		case 1:
+			return "", trace.ConnectionProblem(nil, "transient")
+		case 2:
+			return "not yet", nil
+		}
+		return "", nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(checks, check.Equals, 3)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	err = waitFor(ctx, "test", time.Millisecond, func(context.Context) (string, error) {
+		return "not yet", nil
+	})
+	c.Assert(utils.ExitCodeFromError(err), check.Equals, defaults.TimeoutExitCode)
+	c.Assert(err.Error(), check.Equals, "timed out waiting for test: not yet")
+
+	_, err = operationCompleteReason(ops.SiteOperation{ID: "a1b2", State: ops.OperationStateFailed})
+	c.Assert(err, check.NotNil)
+	err = waitFor(context.TODO(), "test", time.Millisecond, func(context.Context) (string, error) {
+		return "", err
+	})
+	c.Assert(utils.ExitCodeFromError(err), check.Equals, defaults.FailedPreconditionExitCode)
+}
+
+func (*S) TestChecksNodeReady(c *check.C) {
+	nodes := []statusapi.ClusterServer{
+		{Hostname: "node-1", AdvertiseIP: "10.0.0.1", Status: statusapi.NodeHealthy},
+		{Hostname: "node-2", AdvertiseIP: "10.0.0.2", Status: statusapi.NodeDegraded},
+	}
+	c.Assert(nodeReadyReason("node-1", nodes), check.Equals, "")
+	c.Assert(nodeReadyReason("10.0.0.1", nodes), check.Equals, "")
+	c.Assert(nodeReadyReason("node-2", nodes), check.Equals, "node node-2 is degraded")
+	c.Assert(nodeReadyReason("node-3", nodes), check.Equals, "node node-3 has not joined the cluster")
+}
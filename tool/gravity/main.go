@@ -25,7 +25,6 @@ import (
 	"github.com/gravitational/gravity/tool/gravity/cli"
 
 	teleutils "github.com/gravitational/teleport/lib/utils"
-	"github.com/gravitational/trace"
 	log "github.com/sirupsen/logrus"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
@@ -34,18 +33,15 @@ func main() {
 	teleutils.InitLogger(teleutils.LoggingForCLI, log.InfoLevel)
 	stdlog.SetOutput(log.StandardLogger().Writer())
 
-	// configure the process to avoid common proxy related installation problems
-	cli.ConfigureNoProxy()
-
 	app := kingpin.New("gravity", "Gravity cluster management tool.")
 	if err := run(app); err != nil {
 		log.WithError(err).Warn("Command failed.")
-		if errCode, ok := trace.Unwrap(err).(utils.ExitCodeError); ok {
+		if errCode, ok := utils.AsExitCodeError(err); ok {
 			common.PrintError(errCode.OrigError())
-			os.Exit(errCode.ExitCode())
+		} else {
+			common.PrintError(err)
 		}
-		common.PrintError(err)
-		os.Exit(255)
+		os.Exit(utils.ExitCodeFromError(err))
 	}
 }
 
@@ -21,6 +21,7 @@ import (
 
 	"github.com/gravitational/gravity/lib/app/service"
 	"github.com/gravitational/gravity/lib/builder"
+	"github.com/gravitational/gravity/lib/builder/diskimage"
 	"github.com/gravitational/gravity/lib/utils"
 
 	"github.com/gravitational/trace"
@@ -42,6 +43,10 @@ type BuildParameters struct {
 	Silent bool
 	// Insecure turns on insecure verify mode
 	Insecure bool
+	// Upstream specifies how to check vendored resources against upstream
+	Upstream string
+	// DiskImage optionally configures a bootable disk image to build
+	DiskImage *diskimage.Config
 }
 
 // build builds an installer tarball according to the provided parameters
@@ -55,6 +60,8 @@ func build(ctx context.Context, params BuildParameters, req service.VendorReques
 		Overwrite:        params.Overwrite,
 		SkipVersionCheck: params.SkipVersionCheck,
 		VendorReq:        req,
+		Upstream:         builder.UpstreamMode(params.Upstream),
+		DiskImage:        params.DiskImage,
 		Progress:         utils.NewProgress(ctx, "Build", 6, params.Silent),
 	})
 	if err != nil {
@@ -63,3 +70,23 @@ func build(ctx context.Context, params BuildParameters, req service.VendorReques
 	defer installerBuilder.Close()
 	return builder.Build(ctx, installerBuilder)
 }
+
+// diskImageConfig returns the disk image configuration from the command line
+// flags or nil if no disk image has been requested
+func diskImageConfig(cmd BuildCmd) *diskimage.Config {
+	if *cmd.DiskImage == "" {
+		return nil
+	}
+	return &diskimage.Config{
+		BaseImage:    *cmd.BaseImage,
+		Format:       diskimage.Format(*cmd.DiskImage),
+		Size:         *cmd.DiskSize,
+		FirstBoot:    diskimage.FirstBootMode(*cmd.FirstBoot),
+		InstallFlags: *cmd.InstallFlags,
+		AMI: diskimage.AMIConfig{
+			Name:   *cmd.AMIName,
+			Region: *cmd.AMIRegion,
+			Bucket: *cmd.AMIBucket,
+		},
+	}
+}
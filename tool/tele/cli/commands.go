@@ -77,6 +77,24 @@ type BuildCmd struct {
 	Parallel *int
 	// Quiet allows to suppress console output
 	Quiet *bool
+	// Upstream specifies how to check vendored resources against upstream
+	Upstream *string
+	// DiskImage is the format of the bootable disk image to build
+	DiskImage *string
+	// BaseImage is the path to the base operating system disk image
+	BaseImage *string
+	// DiskSize is the virtual size of the disk image
+	DiskSize *string
+	// FirstBoot defines how the cluster is installed on first boot
+	FirstBoot *string
+	// InstallFlags lists flags for the installation on first boot
+	InstallFlags *[]string
+	// AMIName is the name of the AMI to register
+	AMIName *string
+	// AMIRegion is the AWS region to register the AMI in
+	AMIRegion *string
+	// AMIBucket is the S3 bucket to import the AMI from
+	AMIBucket *string
 }
 
 type ListCmd struct {
@@ -18,7 +18,10 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/gravitational/gravity/lib/builder"
+	"github.com/gravitational/gravity/lib/builder/diskimage"
 	"github.com/gravitational/gravity/lib/constants"
 	"github.com/gravitational/gravity/lib/defaults"
 	"github.com/gravitational/gravity/lib/loc"
@@ -53,6 +56,15 @@ func RegisterCommands(app *kingpin.Application) Application {
 	tele.BuildCmd.SkipVersionCheck = tele.BuildCmd.Flag("skip-version-check", "Skip version compatibility check.").Hidden().Bool()
 	tele.BuildCmd.Parallel = tele.BuildCmd.Flag("parallel", "Specifies the number of concurrent tasks. If < 0, the number of tasks is not restricted, if unspecified, then tasks are capped at the number of logical CPU cores.").Int()
 	tele.BuildCmd.Quiet = tele.BuildCmd.Flag("quiet", "Suppress any output to stdout.").Short('q').Bool()
+	tele.BuildCmd.Upstream = tele.BuildCmd.Flag("upstream", fmt.Sprintf("Compare vendored third-party resources listed in %v against upstream and fail (check) or update them (refresh) on drift.", builder.UpstreamFileName)).Enum(builder.UpstreamModes...)
+	tele.BuildCmd.DiskImage = tele.BuildCmd.Flag("disk-image", fmt.Sprintf("Also build a bootable disk image with the cluster image preinstalled, one of: %v.", strings.Join(diskimage.Formats, ", "))).Enum(diskimage.Formats...)
+	tele.BuildCmd.BaseImage = tele.BuildCmd.Flag("base-image", "Path to the base operating system cloud image (raw or qcow2) to build the disk image from.").String()
+	tele.BuildCmd.DiskSize = tele.BuildCmd.Flag("disk-size", "Virtual size of the disk image, e.g. 50G. Defaults to the size of the base image.").String()
+	tele.BuildCmd.FirstBoot = tele.BuildCmd.Flag("first-boot", fmt.Sprintf("How the cluster is installed on first boot: 'install' starts the installation right away, 'configure' waits for the installation flags in %v.", diskimage.ConfigPath)).Default(string(diskimage.FirstBootConfigure)).Enum(diskimage.FirstBootModes...)
+	tele.BuildCmd.InstallFlags = tele.BuildCmd.Flag("install-flag", "Flag to pass to 'gravity install' on first boot, e.g. --install-flag=--cloud-provider=aws. Can be repeated.").Strings()
+	tele.BuildCmd.AMIName = tele.BuildCmd.Flag("ami-name", "Name of the AMI to register when building an AMI.").String()
+	tele.BuildCmd.AMIRegion = tele.BuildCmd.Flag("ami-region", "AWS region to register the AMI in.").Default(defaults.AWSRegion).String()
+	tele.BuildCmd.AMIBucket = tele.BuildCmd.Flag("ami-bucket", "S3 bucket to upload the disk image to for the AMI import.").String()
 
 	tele.ListCmd.CmdClause = app.Command("ls", "List cluster and application images published to Gravity Hub.")
 	tele.ListCmd.Runtimes = tele.ListCmd.Flag("runtimes", "Show only runtimes.").Short('r').Hidden().Bool()
@@ -58,6 +58,8 @@ func Run(tele Application) error {
 			SkipVersionCheck: *tele.BuildCmd.SkipVersionCheck,
 			Silent:           *tele.BuildCmd.Quiet,
 			Insecure:         *tele.Insecure,
+			Upstream:         *tele.BuildCmd.Upstream,
+			DiskImage:        diskImageConfig(tele.BuildCmd),
 		}, service.VendorRequest{
 			PackageName:            *tele.BuildCmd.Name,
 			PackageVersion:         *tele.BuildCmd.Version,
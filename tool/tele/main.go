@@ -20,6 +20,7 @@ import (
 	stdlog "log"
 	"os"
 
+	"github.com/gravitational/gravity/lib/utils"
 	"github.com/gravitational/gravity/tool/common"
 	"github.com/gravitational/gravity/tool/tele/cli"
 
@@ -36,7 +37,7 @@ func main() {
 	if err := run(app); err != nil {
 		log.Error(trace.DebugReport(err))
 		common.PrintError(err)
-		os.Exit(255)
+		os.Exit(utils.ExitCodeFromError(err))
 	}
 }
 
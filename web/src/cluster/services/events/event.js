@@ -55,6 +55,8 @@ export const CodeEnum = {
   GITHUB_CONNECTOR_DELETED: 'G2002I',
   LOGFORWARDER_CREATED: 'G1003I',
   LOGFORWARDER_DELETED: 'G2003I',
+  OPERATION_APPROVAL_REQUESTED: 'G0017I',
+  OPERATION_APPROVED: 'G0018I',
   OPERATION_CONFIG_COMPLETE: 'G0016I',
   OPERATION_CONFIG_FAILURE: 'G0016E',
   OPERATION_CONFIG_START: 'G0015I',
@@ -211,6 +213,14 @@ export const eventConfig = {
     desc: 'OIDC Auth Connector Deleted',
     formatter: ({ user, name }) => `User ${user} deleted OIDC connector ${name}`
   },
+  [CodeEnum.OPERATION_APPROVAL_REQUESTED]: {
+    desc: 'Operation Approval Requested',
+    formatter: ({ requestedBy, type, approvalID }) => `User ${requestedBy} requested approval of ${type} operation (${approvalID})`,
+  },
+  [CodeEnum.OPERATION_APPROVED]: {
+    desc: 'Operation Approved',
+    formatter: ({ user, requestedBy, type, approvalID }) => `User ${user} approved ${type} operation requested by ${requestedBy} (${approvalID})`,
+  },
   [CodeEnum.OPERATION_CONFIG_COMPLETE]: {
     desc: 'Cluster Configuration Completed',
     formatter: () => `Cluster configuration has been updated`,
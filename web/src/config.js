@@ -178,6 +178,7 @@ const cfg = {
     operationStartPath: '/portalapi/v1/sites/:siteId/operations/:opId/start',
     operationPrecheckPath: '/portalapi/v1/sites/:siteId/operations/:opId/prechecks',
     operationLogsPath: `/portal/v1/accounts/${accountId}/sites/:siteId/operations/common/:opId/logs?access_token=:token`,
+    operationTokenPath: `/portal/v1/accounts/${accountId}/sites/:siteId/operations/common/:opId/token`,
     shrinkSitePath: '/portalapi/v1/sites/:siteId/shrink',
 
     // auth & session management
@@ -464,6 +465,10 @@ const cfg = {
     return generatePath(cfg.api.operationProgressPath, { siteId, opId });
   },
 
+  getOperationTokenUrl(siteId, opId) {
+    return generatePath(cfg.api.operationTokenPath, { siteId, opId });
+  },
+
   getOperationStartUrl(siteId, opId) {
     return generatePath(cfg.api.operationStartPath, { siteId, opId });
   },
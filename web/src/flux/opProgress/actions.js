@@ -14,16 +14,59 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+import $ from 'jQuery';
 import reactor from 'app/reactor';
 import api from 'app/services/api';
 import cfg from 'app/config';
 
 import { OP_PROGRESS_RECEIVE } from './actionTypes';
 
+// operation tokens by operation ID
+const opTokens = {};
+
+// The install wizard only serves the progress of an operation
+// to the clients presenting a token issued for the operation
 export function fetchOpProgress(siteId, opId){
   let url = cfg.getOperationProgressUrl(siteId, opId);
-  return api.get(url).then((data)=>{
-    reactor.dispatch(OP_PROGRESS_RECEIVE, data);
-  });
+  return getOpToken(siteId, opId)
+    .then(token => {
+      // users that cannot create tokens fall back to the session
+      if(!token){
+        return api.get(url);
+      }
+      return api.ajax({
+        url,
+        beforeSend: xhr => xhr.setRequestHeader('Authorization', `Bearer ${token}`)
+      }, false);
+    })
+    .then(data => {
+      reactor.dispatch(OP_PROGRESS_RECEIVE, data);
+    })
+    .fail(err => {
+      if(err.status === 403){
+        // the token has expired or has been revoked, request a new one next time
+        delete opTokens[opId];
+      }
+    });
+}
+
+function getOpToken(siteId, opId){
+  if(opTokens[opId]){
+    return $.Deferred().resolve(opTokens[opId]);
+  }
+  let deferred = $.Deferred();
+  api.post(cfg.getOperationTokenUrl(siteId, opId))
+    .done(json => {
+      opTokens[opId] = json.token;
+      deferred.resolve(json.token);
+    })
+    .fail(err => {
+      if(err.status === 403){
+        deferred.resolve(null);
+        return;
+      }
+      deferred.reject(err);
+    });
+  return deferred;
 }
 